@@ -1,23 +1,36 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/awsl-project/maxx/internal/adapter/client"
+	"github.com/awsl-project/maxx/internal/adapter/provider"
+	"github.com/awsl-project/maxx/internal/batch"
 	_ "github.com/awsl-project/maxx/internal/adapter/provider/custom" // Register custom adapter
 	_ "github.com/awsl-project/maxx/internal/adapter/provider/kiro"   // Register kiro adapter
+	"github.com/awsl-project/maxx/internal/capture"
 	"github.com/awsl-project/maxx/internal/cooldown"
 	"github.com/awsl-project/maxx/internal/core"
+	"github.com/awsl-project/maxx/internal/domain"
 	"github.com/awsl-project/maxx/internal/executor"
 	"github.com/awsl-project/maxx/internal/handler"
+	"github.com/awsl-project/maxx/internal/limiter"
+	"github.com/awsl-project/maxx/internal/logsink"
+	"github.com/awsl-project/maxx/internal/pii"
+	"github.com/awsl-project/maxx/internal/redaction"
 	"github.com/awsl-project/maxx/internal/repository/cached"
 	"github.com/awsl-project/maxx/internal/repository/sqlite"
+	"github.com/awsl-project/maxx/internal/retrybudget"
+	"github.com/awsl-project/maxx/internal/secrets"
 	"github.com/awsl-project/maxx/internal/stats"
 	"github.com/awsl-project/maxx/internal/router"
 	"github.com/awsl-project/maxx/internal/service"
@@ -86,6 +99,11 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	// Provider credentials are encrypted at rest with MAXX_MASTER_KEY
+	if err := secrets.LoadFromEnv(); err != nil {
+		log.Fatalf("Failed to load master key: %v", err)
+	}
+
 	// Create repositories
 	providerRepo := sqlite.NewProviderRepository(db)
 	routeRepo := sqlite.NewRouteRepository(db)
@@ -99,10 +117,20 @@ func main() {
 	antigravityQuotaRepo := sqlite.NewAntigravityQuotaRepository(db)
 	cooldownRepo := sqlite.NewCooldownRepository(db)
 	failureCountRepo := sqlite.NewFailureCountRepository(db)
+	roundRobinStateRepo := sqlite.NewRoundRobinStateRepository(db)
 	apiTokenRepo := sqlite.NewAPITokenRepository(db)
 	modelMappingRepo := sqlite.NewModelMappingRepository(db)
 	usageStatsRepo := sqlite.NewUsageStatsRepository(db)
 	responseModelRepo := sqlite.NewResponseModelRepository(db)
+	messageBatchRepo := sqlite.NewMessageBatchRepository(db)
+	providerEventRepo := sqlite.NewProviderEventRepository(db)
+
+	// Encrypt credentials stored in plaintext or with the previous master key
+	if count, err := providerRepo.ReencryptSecrets(); err != nil {
+		log.Fatalf("Failed to re-encrypt provider credentials: %v", err)
+	} else if count > 0 {
+		log.Printf("Re-encrypted the credentials of %d providers", count)
+	}
 
 	// Initialize cooldown manager with database persistence
 	cooldown.Default().SetRepository(cooldownRepo)
@@ -110,6 +138,11 @@ func main() {
 	if err := cooldown.Default().LoadFromDatabase(); err != nil {
 		log.Printf("Warning: Failed to load cooldowns from database: %v", err)
 	}
+	if raw, err := settingRepo.Get(domain.SettingKeyCooldownScopes); err == nil {
+		if err := cooldown.LoadScopePolicy(raw); err != nil {
+			log.Printf("Warning: Failed to load cooldown scope policy: %v", err)
+		}
+	}
 
 	// Generate instance ID and mark stale requests as failed
 	instanceID := generateInstanceID()
@@ -150,13 +183,27 @@ func main() {
 	}
 
 	// Create router
-	r := router.NewRouter(cachedRouteRepo, cachedProviderRepo, cachedRoutingStrategyRepo, cachedRetryConfigRepo, cachedProjectRepo)
+	r := router.NewRouter(cachedRouteRepo, cachedProviderRepo, cachedRoutingStrategyRepo, cachedRetryConfigRepo, cachedProjectRepo, roundRobinStateRepo)
+
+	// Restore round-robin rotation and persist it periodically
+	if err := r.RoundRobin().Load(); err != nil {
+		log.Printf("Warning: Failed to load round-robin state: %v", err)
+	}
+	r.RoundRobin().StartPersistence(settingRepo)
+
+	// Flush cooldown and failure count changes periodically
+	cooldown.Default().StartPersistence(settingRepo)
 
 	// Initialize provider adapters
 	if err := r.InitAdapters(); err != nil {
 		log.Printf("Warning: Failed to initialize adapters: %v", err)
 	}
 
+	// Pre-refresh provider tokens in the background when enabled
+	if val, err := settingRepo.Get(domain.SettingKeyProviderWarmup); err == nil && val == "true" {
+		go r.WarmupAdapters(context.Background(), 30*time.Second)
+	}
+
 	// Start cooldown cleanup goroutine
 	go func() {
 		ticker := time.NewTicker(1 * time.Hour)
@@ -176,6 +223,10 @@ func main() {
 
 	// Create WebSocket hub
 	wsHub := handler.NewWebSocketHub()
+	wsHub.SetSettingRepo(settingRepo)
+
+	// Record provider lifecycle events, broadcasting them over WebSocket
+	cooldown.Default().SetEventSink(service.NewProviderEventLog(providerEventRepo, wsHub).Record)
 
 	// Create Antigravity task service for periodic quota refresh and auto-sorting
 	antigravityTaskSvc := service.NewAntigravityTaskService(
@@ -187,12 +238,22 @@ func main() {
 		wsHub,
 	)
 
+	// Live dashboard deltas, clients subscribe over WebSocket to get the starting snapshot
+	liveDashboard := stats.NewLiveDashboard(usageStatsRepo, wsHub)
+	wsHub.RegisterSnapshot("dashboard", func() handler.WSMessage {
+		return handler.WSMessage{Type: "dashboard_snapshot", Data: liveDashboard.Snapshot()}
+	})
+
 	// Start background tasks
 	core.StartBackgroundTasks(core.BackgroundTaskDeps{
 		UsageStats:         usageStatsRepo,
 		ProxyRequest:       proxyRequestRepo,
+		ProviderEvent:      providerEventRepo,
 		Settings:           settingRepo,
 		AntigravityTaskSvc: antigravityTaskSvc,
+		Router:             r,
+		Broadcaster:        wsHub,
+		LiveDashboard:      liveDashboard,
 	})
 
 	// Setup log output to broadcast via WebSocket
@@ -205,8 +266,78 @@ func main() {
 	// Create stats aggregator
 	statsAggregator := stats.NewStatsAggregator(usageStatsRepo)
 
+	// Load global redaction rules for captured request/response bodies
+	if raw, err := settingRepo.Get(domain.SettingKeyRedactionRules); err == nil {
+		if err := redaction.LoadGlobalRules(raw); err != nil {
+			log.Printf("Warning: Failed to load redaction rules: %v", err)
+		}
+	}
+
+	// Load PII detection settings
+	if err := pii.LoadFromSettings(settingRepo); err != nil {
+		log.Printf("Warning: Failed to load PII detection patterns: %v", err)
+	}
+
+	// Load global concurrency limit settings
+	limiter.LoadFromSettings(settingRepo)
+	cooldown.LoadQueueFromSettings(settingRepo)
+
+	// Load the response spill-to-disk threshold
+	provider.LoadSpillThresholdFromSettings(settingRepo)
+	provider.LoadPoolConfigFromSettings(settingRepo)
+	provider.LoadTimingCaptureFromSettings(settingRepo)
+	// Refuse to start with an invalid upstream TLS policy rather than connect with weaker defaults
+	if err := provider.LoadTLSPolicyFromSettings(settingRepo); err != nil {
+		log.Fatalf("Invalid upstream TLS policy: %v", err)
+	}
+	client.LoadPromptCacheKeySessionFromSettings(settingRepo)
+
+	// Load the retry budget that throttles retries during upstream outages
+	retrybudget.LoadFromSettings(settingRepo)
+
+	// Ship request records to the external log sink
+	if err := logsink.LoadFromSettings(settingRepo); err != nil {
+		log.Printf("Warning: Failed to configure request log sink: %v", err)
+	}
+
+	// Mirror sampled request/response pairs to JSONL files for offline analysis
+	if err := capture.LoadFromSettings(settingRepo); err != nil {
+		log.Printf("Warning: Failed to configure request capture: %v", err)
+	}
+
 	// Create executor
-	exec := executor.NewExecutor(r, proxyRequestRepo, attemptRepo, cachedRetryConfigRepo, cachedSessionRepo, cachedModelMappingRepo, wsHub, projectWaiter, instanceID, statsAggregator)
+	exec := executor.NewExecutor(r, proxyRequestRepo, attemptRepo, cachedRetryConfigRepo, cachedSessionRepo, cachedProjectRepo, cachedModelMappingRepo, settingRepo, wsHub, projectWaiter, instanceID, statsAggregator)
+	// Route conditions read the current load and timezone from the executor
+	r.SetConditionSource(exec)
+
+	// Cancel in-flight requests and persist in-memory state before exiting on SIGINT/SIGTERM
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+		<-sigCh
+		log.Println("Shutting down, persisting in-memory state")
+		// Give cancelled requests a moment to record their reason and notify their clients
+		if n := exec.CancelAll(domain.CancelReasonShutdown); n > 0 {
+			log.Printf("Cancelled %d in-flight requests", n)
+			deadline := time.Now().Add(3 * time.Second)
+			for exec.InFlight() > 0 && time.Now().Before(deadline) {
+				time.Sleep(50 * time.Millisecond)
+			}
+		}
+		if err := cooldown.Default().Flush(); err != nil {
+			log.Printf("Warning: Failed to persist cooldown state: %v", err)
+		}
+		if err := r.RoundRobin().Flush(); err != nil {
+			log.Printf("Warning: Failed to persist round-robin state: %v", err)
+		}
+		logsink.Global().Close()
+		capture.Global().Close()
+		os.Exit(0)
+	}()
+
+	// Start Message Batches processor
+	batchProcessor := batch.NewProcessor(exec, messageBatchRepo, settingRepo, wsHub)
+	go batchProcessor.Start(context.Background())
 
 	// Create client adapter
 	clientAdapter := client.NewAdapter()
@@ -226,9 +357,12 @@ func main() {
 		cachedModelMappingRepo,
 		usageStatsRepo,
 		responseModelRepo,
+		messageBatchRepo,
+		providerEventRepo,
 		*addr,
 		r, // Router implements ProviderAdapterRefresher interface
 	)
+	go adminService.DiscoverProvidersOnStartup(context.Background())
 
 	// Create backup service
 	backupService := service.NewBackupService(
@@ -242,6 +376,7 @@ func main() {
 		cachedModelMappingRepo,
 		r, // Router implements ProviderAdapterRefresher interface
 	)
+	backupService.SetDatabase(db)
 
 	// Create auth middleware
 	authMiddleware := handler.NewAuthMiddleware()
@@ -256,10 +391,14 @@ func main() {
 	if tokenAuthMiddleware.IsEnabled() {
 		log.Println("Proxy token authentication is enabled")
 	}
+	authMiddleware.SetTokenAuth(tokenAuthMiddleware) // API tokens with admin access can call the admin API
 
 	// Create handlers
 	proxyHandler := handler.NewProxyHandler(clientAdapter, exec, cachedSessionRepo, tokenAuthMiddleware)
-	adminHandler := handler.NewAdminHandler(adminService, backupService, logPath)
+	proxyHandler.SetBatchHandler(handler.NewBatchHandler(messageBatchRepo, cachedSessionRepo, tokenAuthMiddleware, batchProcessor))
+	proxyHandler.SetProjectRepo(cachedProjectRepo)
+	proxyHandler.SetAdminAuth(authMiddleware)
+	adminHandler := handler.NewAdminHandler(adminService, backupService, wsHub, logPath)
 	authHandler := handler.NewAuthHandler(authMiddleware)
 	antigravityHandler := handler.NewAntigravityHandler(adminService, antigravityQuotaRepo, wsHub)
 	antigravityHandler.SetTaskService(antigravityTaskSvc)
@@ -267,6 +406,8 @@ func main() {
 
 	// Use already-created cached project repository for project proxy handler
 	projectProxyHandler := handler.NewProjectProxyHandler(proxyHandler, cachedProjectRepo)
+	adminHandler.SetReplayHandler(handler.NewReplayHandler(proxyHandler, projectProxyHandler))
+	adminHandler.SetRequestCanceller(exec)
 
 	// Setup routes
 	mux := http.NewServeMux()
@@ -284,12 +425,16 @@ func main() {
 	// Proxy routes - catch all AI API endpoints
 	// Claude API
 	mux.Handle("/v1/messages", proxyHandler)
+	mux.Handle("/v1/messages/batches", proxyHandler)
+	mux.Handle("/v1/messages/batches/", proxyHandler)
 	// OpenAI API
 	mux.Handle("/v1/chat/completions", proxyHandler)
 	// Codex API
 	mux.Handle("/responses", proxyHandler)
 	// Gemini API (Google AI Studio style)
 	mux.Handle("/v1beta/models/", proxyHandler)
+	// Resumable stream reconnects
+	mux.Handle("/v1/resume", proxyHandler)
 
 	// Health check
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
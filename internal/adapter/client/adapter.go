@@ -9,10 +9,27 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+	"sync/atomic"
 
 	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
 )
 
+// promptCacheKeySession makes OpenAI/Codex requests use their prompt_cache_key as
+// session ID, so requests sharing an upstream cache also stick to the same route
+var promptCacheKeySession atomic.Bool
+
+// SetPromptCacheKeySession enables or disables prompt_cache_key session IDs
+func SetPromptCacheKeySession(enabled bool) {
+	promptCacheKeySession.Store(enabled)
+}
+
+// LoadPromptCacheKeySessionFromSettings applies the prompt_cache_key session setting
+func LoadPromptCacheKeySessionFromSettings(settingRepo repository.SystemSettingRepository) {
+	val, _ := settingRepo.Get(domain.SettingKeyPromptCacheKeySession)
+	SetPromptCacheKeySession(val == "true")
+}
+
 // RequestInfo contains extracted request information
 type RequestInfo struct {
 	SessionID    string
@@ -118,15 +135,54 @@ func (a *Adapter) ExtractInfo(req *http.Request, clientType domain.ClientType) (
 	return info, body, nil
 }
 
-func (a *Adapter) extractModel(req *http.Request, clientType domain.ClientType, body []byte) string {
-	// For Gemini, try URL first
+// GeminiPathModel returns the model of a Gemini URL such as
+// /v1beta/models/{model}:generateContent, empty when the path has none
+func GeminiPathModel(path string) string {
+	if matches := geminiModelPattern.FindStringSubmatch(path); len(matches) > 1 {
+		return matches[1]
+	}
+	if matches := geminiInternalPattern.FindStringSubmatch(path); len(matches) > 1 {
+		return matches[1]
+	}
+	return ""
+}
+
+// geminiModelSlotPattern matches the model slot of a Gemini URL, including an empty
+// one such as /v1beta/models/:generateContent
+var geminiModelSlotPattern = regexp.MustCompile(`(/v1(?:beta|internal)/models/)([^/:]*)(:)`)
+
+// SetModel returns the request body and URI with the request model set to model.
+// Gemini URLs with a model slot get it in the path, like the client would have sent
+// it; other requests get it in the body's "model" field.
+func SetModel(clientType domain.ClientType, uri string, body []byte, model string) ([]byte, string, error) {
 	if clientType == domain.ClientTypeGemini {
-		path := req.URL.Path
-		if matches := geminiModelPattern.FindStringSubmatch(path); len(matches) > 1 {
-			return matches[1]
+		if loc := geminiModelSlotPattern.FindStringSubmatchIndex(uri); loc != nil {
+			return body, uri[:loc[4]] + model + uri[loc[5]:], nil
 		}
-		if matches := geminiInternalPattern.FindStringSubmatch(path); len(matches) > 1 {
-			return matches[1]
+	}
+
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal(body, &data); err != nil {
+		return body, uri, err
+	}
+	if data == nil {
+		data = make(map[string]json.RawMessage)
+	}
+	data["model"], _ = json.Marshal(model)
+	out, err := json.Marshal(data)
+	if err != nil {
+		return body, uri, err
+	}
+	return out, uri, nil
+}
+
+// extractModel returns the request model. Gemini encodes it in the URL path, which
+// wins over any "model" field in the body; model mapping is then applied to it like
+// to any other request model and the mapped model is written back into the path.
+func (a *Adapter) extractModel(req *http.Request, clientType domain.ClientType, body []byte) string {
+	if clientType == domain.ClientTypeGemini {
+		if model := GeminiPathModel(req.URL.Path); model != "" {
+			return model
 		}
 	}
 
@@ -152,6 +208,14 @@ func (a *Adapter) extractSessionID(req *http.Request, clientType domain.ClientTy
 			if sid, ok := metadata["session_id"].(string); ok && sid != "" {
 				return sid
 			}
+		}
+		// Then prompt_cache_key when enabled, it groups requests sharing an upstream cache
+		if promptCacheKeySession.Load() && (clientType == domain.ClientTypeOpenAI || clientType == domain.ClientTypeCodex) {
+			if key, ok := data["prompt_cache_key"].(string); ok && key != "" {
+				return key
+			}
+		}
+		if metadata, ok := data["metadata"].(map[string]interface{}); ok {
 			// Then try user_id (Claude Code format: "user_{hash}_account__session_{uuid}")
 			if userID, ok := metadata["user_id"].(string); ok && userID != "" {
 				const sessionMarker = "_session_"
@@ -202,8 +266,17 @@ func (a *Adapter) generateSessionID(req *http.Request, body []byte) string {
 // DetectClientType detects the client type from the request
 func (a *Adapter) DetectClientType(req *http.Request, body []byte) domain.ClientType {
 	// First layer: endpoint detection
-	path := req.URL.Path
+	if clientType := DetectClientTypeFromPath(req.URL.Path); clientType != "" {
+		return clientType
+	}
+
+	// Second layer: body detection (fallback)
+	return a.DetectClientTypeFromBody(body)
+}
 
+// DetectClientTypeFromPath detects the client type from a well-known endpoint path,
+// returning an empty string for paths that don't identify a format
+func DetectClientTypeFromPath(path string) domain.ClientType {
 	switch {
 	case strings.HasPrefix(path, "/v1/messages"):
 		return domain.ClientTypeClaude
@@ -216,12 +289,21 @@ func (a *Adapter) DetectClientType(req *http.Request, body []byte) domain.Client
 	case strings.HasPrefix(path, "/v1internal/models/"):
 		return domain.ClientTypeGemini
 	}
+	return ""
+}
 
-	// Second layer: body detection (fallback)
-	return a.detectFromBodyBytes(body)
+// ParseClientType returns the client type named by s (case-insensitive),
+// or an empty string when s is not a known client type
+func ParseClientType(s string) domain.ClientType {
+	switch ct := domain.ClientType(strings.ToLower(strings.TrimSpace(s))); ct {
+	case domain.ClientTypeClaude, domain.ClientTypeCodex, domain.ClientTypeGemini, domain.ClientTypeOpenAI:
+		return ct
+	}
+	return ""
 }
 
-func (a *Adapter) detectFromBodyBytes(body []byte) domain.ClientType {
+// DetectClientTypeFromBody guesses the client type from the shape of the request body
+func (a *Adapter) DetectClientTypeFromBody(body []byte) domain.ClientType {
 	var data map[string]interface{}
 	if err := json.Unmarshal(body, &data); err != nil {
 		return ""
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestExtractModelGeminiPath(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		clientType domain.ClientType
+		body       string
+		want       string
+	}{
+		{"generate content", "/v1beta/models/gemini-2.5-pro:generateContent", domain.ClientTypeGemini, `{"contents":[]}`, "gemini-2.5-pro"},
+		{"stream with query", "/v1beta/models/gemini-2.5-flash:streamGenerateContent?alt=sse", domain.ClientTypeGemini, `{}`, "gemini-2.5-flash"},
+		{"internal path", "/v1internal/models/gemini-2.5-pro:generateContent", domain.ClientTypeGemini, `{}`, "gemini-2.5-pro"},
+		{"path wins over body", "/v1beta/models/gemini-2.5-pro:generateContent", domain.ClientTypeGemini, `{"model":"other"}`, "gemini-2.5-pro"},
+		{"body without path model", "/v1beta/generateContent", domain.ClientTypeGemini, `{"model":"gemini-2.5-pro"}`, "gemini-2.5-pro"},
+		{"other formats use the body", "/v1/messages", domain.ClientTypeClaude, `{"model":"claude-sonnet-4"}`, "claude-sonnet-4"},
+	}
+
+	a := NewAdapter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.target, nil)
+			if got := a.ExtractModel(req, []byte(tt.body), tt.clientType); got != tt.want {
+				t.Errorf("ExtractModel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetModel(t *testing.T) {
+	tests := []struct {
+		name       string
+		clientType domain.ClientType
+		uri        string
+		body       string
+		wantURI    string
+		wantBody   string
+	}{
+		{"claude body", domain.ClientTypeClaude, "/v1/messages", `{"messages":[]}`, "/v1/messages", `{"messages":[],"model":"m"}`},
+		{"empty model field", domain.ClientTypeOpenAI, "/v1/chat/completions", `{"model":"","messages":[]}`, "/v1/chat/completions", `{"messages":[],"model":"m"}`},
+		{"gemini empty path slot", domain.ClientTypeGemini, "/v1beta/models/:generateContent", `{}`, "/v1beta/models/m:generateContent", `{}`},
+		{"gemini internal path", domain.ClientTypeGemini, "/v1internal/models/:streamGenerateContent?alt=sse", `{}`, "/v1internal/models/m:streamGenerateContent?alt=sse", `{}`},
+		{"gemini without a path slot", domain.ClientTypeGemini, "/v1beta/generateContent", `{}`, "/v1beta/generateContent", `{"model":"m"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, uri, err := SetModel(tt.clientType, tt.uri, []byte(tt.body), "m")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if uri != tt.wantURI || string(body) != tt.wantBody {
+				t.Errorf("SetModel() = %s %q, want %s %q", body, uri, tt.wantBody, tt.wantURI)
+			}
+		})
+	}
+
+	if _, _, err := SetModel(domain.ClientTypeClaude, "/v1/messages", []byte("not json"), "m"); err == nil {
+		t.Error("SetModel() on a non-JSON body succeeded")
+	}
+}
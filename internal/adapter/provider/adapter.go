@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/awsl-project/maxx/internal/domain"
@@ -35,3 +36,36 @@ func GetAdapterFactory(providerType string) (AdapterFactory, bool) {
 	f, ok := adapterFactories[providerType]
 	return f, ok
 }
+
+// Warmer is implemented by adapters that can prepare upstream credentials
+// ahead of the first request, e.g. by pre-refreshing an OAuth access token
+type Warmer interface {
+	Warmup(ctx context.Context) error
+}
+
+// Warmup warms up the adapter if it supports it, adapters without a
+// Warmup method are a no-op
+func Warmup(ctx context.Context, a ProviderAdapter) error {
+	if w, ok := a.(Warmer); ok {
+		return w.Warmup(ctx)
+	}
+	return nil
+}
+
+// ErrModelListNotSupported is returned by ListModels for adapters that can't list their models
+var ErrModelListNotSupported = errors.New("provider does not support listing models")
+
+// ModelLister is implemented by adapters that can ask their upstream which
+// models it serves
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]domain.ModelInfo, error)
+}
+
+// ListModels lists the upstream models of the adapter, adapters without a
+// ListModels method return ErrModelListNotSupported
+func ListModels(ctx context.Context, a ProviderAdapter) ([]domain.ModelInfo, error) {
+	if l, ok := a.(ModelLister); ok {
+		return l.ListModels(ctx)
+	}
+	return nil, ErrModelListNotSupported
+}
@@ -41,10 +41,20 @@ func NewAdapter(p *domain.Provider) (provider.ProviderAdapter, error) {
 	if p.Config == nil || p.Config.Antigravity == nil {
 		return nil, fmt.Errorf("provider %s missing antigravity config", p.Name)
 	}
+	switch p.Config.Antigravity.SystemPromptPolicy {
+	case "", SystemPromptMerge, SystemPromptKeep, SystemPromptReplace:
+	default:
+		return nil, fmt.Errorf("provider %s has unknown system prompt policy %q", p.Name, p.Config.Antigravity.SystemPromptPolicy)
+	}
+	switch p.Config.Antigravity.ThinkingSignatureFallback {
+	case "", ThinkingFallbackFilter, ThinkingFallbackDisable:
+	default:
+		return nil, fmt.Errorf("provider %s has unknown thinking signature fallback %q", p.Name, p.Config.Antigravity.ThinkingSignatureFallback)
+	}
 	return &AntigravityAdapter{
 		provider:   p,
 		tokenCache: &TokenCache{},
-		httpClient: newUpstreamHTTPClient(),
+		httpClient: newUpstreamHTTPClient(p),
 	}, nil
 }
 
@@ -103,11 +113,12 @@ func (a *AntigravityAdapter) Execute(ctx context.Context, w http.ResponseWriter,
 		// Get access token
 		accessToken, err := a.getAccessToken(ctx)
 		if err != nil {
-			return domain.NewProxyErrorWithMessage(err, true, "failed to get access token")
+			return err
 		}
 
 		// [SessionID Support] Extract metadata.user_id from original request for sessionId (like Antigravity-Manager)
 		sessionID := extractSessionID(requestBody)
+		toolScope := ToolSignatureScopeFromConfig(config, sessionID)
 
 		// Transform request based on client type
 		var geminiBody []byte
@@ -119,14 +130,14 @@ func (a *AntigravityAdapter) Execute(ctx context.Context, w http.ResponseWriter,
 				effectiveMappedModel string
 				hasThinking          bool
 			)
-			geminiBody, effectiveMappedModel, hasThinking, err = TransformClaudeToGemini(requestBody, mappedModel, actualStream, sessionID, GlobalSignatureCache())
+			geminiBody, effectiveMappedModel, hasThinking, err = TransformClaudeToGemini(requestBody, mappedModel, actualStream, toolScope, GlobalSignatureCache(), SystemPromptPolicyFromConfig(config), ThinkingFallbackFromConfig(config))
 			if err != nil {
 				return domain.NewProxyErrorWithMessage(err, true, fmt.Sprintf("failed to transform Claude request: %v", err))
 			}
 			mappedModel = effectiveMappedModel
 
 			// Apply minimal post-processing for features not yet fully integrated
-			geminiBody = applyClaudePostProcess(geminiBody, sessionID, hasThinking, requestBody, mappedModel)
+			geminiBody = applyClaudePostProcess(geminiBody, toolScope, hasThinking, requestBody, mappedModel)
 		} else if clientType == domain.ClientTypeOpenAI {
 			// TODO: Implement OpenAI transformation in the future
 			return domain.NewProxyErrorWithMessage(domain.ErrFormatConversion, true, "OpenAI transformation not yet implemented")
@@ -203,7 +214,7 @@ func (a *AntigravityAdapter) Execute(ctx context.Context, w http.ResponseWriter,
 				// Get new token
 				accessToken, err = a.getAccessToken(ctx)
 				if err != nil {
-					return domain.NewProxyErrorWithMessage(err, true, "failed to refresh access token")
+					return err
 				}
 
 				// Retry request with only required headers
@@ -343,6 +354,14 @@ func (a *AntigravityAdapter) Execute(ctx context.Context, w http.ResponseWriter,
 	return domain.NewProxyErrorWithMessage(domain.ErrUpstreamError, true, "all upstream endpoints failed")
 }
 
+// Warmup pre-refreshes the access token so the first request doesn't pay the refresh latency
+func (a *AntigravityAdapter) Warmup(ctx context.Context) error {
+	_, err := a.getAccessToken(ctx)
+	return err
+}
+
+// getAccessToken returns the cached access token, refreshing it when expired
+// A failed refresh is returned as the *domain.ProxyError of the request
 func (a *AntigravityAdapter) getAccessToken(ctx context.Context) (string, error) {
 	// Check cache
 	a.tokenMu.RLock()
@@ -353,12 +372,16 @@ func (a *AntigravityAdapter) getAccessToken(ctx context.Context) (string, error)
 	}
 	a.tokenMu.RUnlock()
 
-	// Refresh token
+	// Refresh token, retrying transient failures
 	config := a.provider.Config.Antigravity
-	accessToken, expiresIn, err := refreshGoogleToken(ctx, config.RefreshToken)
+	token, err := provider.RefreshWithRetry(ctx, func(ctx context.Context) (googleToken, error) {
+		accessToken, expiresIn, err := refreshGoogleToken(ctx, config.RefreshToken)
+		return googleToken{accessToken, expiresIn}, err
+	})
 	if err != nil {
-		return "", err
+		return "", provider.RefreshProxyError(err)
 	}
+	accessToken, expiresIn := token.accessToken, token.expiresIn
 
 	// Cache token
 	a.tokenMu.Lock()
@@ -371,6 +394,15 @@ func (a *AntigravityAdapter) getAccessToken(ctx context.Context) (string, error)
 	return accessToken, nil
 }
 
+// googleTokenURL is the Google OAuth token endpoint
+var googleTokenURL = "https://oauth2.googleapis.com/token"
+
+// googleToken is a refreshed access token and its lifetime in seconds
+type googleToken struct {
+	accessToken string
+	expiresIn   int
+}
+
 func refreshGoogleToken(ctx context.Context, refreshToken string) (string, int, error) {
 	data := url.Values{}
 	data.Set("grant_type", "refresh_token")
@@ -378,7 +410,7 @@ func refreshGoogleToken(ctx context.Context, refreshToken string) (string, int,
 	data.Set("client_id", OAuthClientID)
 	data.Set("client_secret", OAuthClientSecret)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://oauth2.googleapis.com/token", strings.NewReader(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", googleTokenURL, strings.NewReader(data.Encode()))
 	if err != nil {
 		return "", 0, err
 	}
@@ -393,7 +425,7 @@ func refreshGoogleToken(ctx context.Context, refreshToken string) (string, int,
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", 0, fmt.Errorf("token refresh failed: %s", string(body))
+		return "", 0, &provider.RefreshError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var result struct {
@@ -407,33 +439,33 @@ func refreshGoogleToken(ctx context.Context, refreshToken string) (string, int,
 	return result.AccessToken, result.ExpiresIn, nil
 }
 
-func newUpstreamHTTPClient() *http.Client {
+func newUpstreamHTTPClient(p *domain.Provider) *http.Client {
 	// Mirrors Antigravity-Manager's reqwest client settings:
-	// connect_timeout=20s, pool_max_idle_per_host=16, pool_idle_timeout=90s, tcp_keepalive=60s, timeout=600s.
+	// connect_timeout=20s, tcp_keepalive=60s, timeout=600s.
+	// The pool (Antigravity-Manager: pool_max_idle_per_host=16, pool_idle_timeout=90s)
+	// follows the shared upstream pool settings, whose defaults match.
 	dialer := &net.Dialer{
 		Timeout:   20 * time.Second,
 		KeepAlive: 60 * time.Second,
 	}
 
-	transport := &http.Transport{
-		Proxy:                 http.ProxyFromEnvironment,
-		DialContext:           dialer.DialContext,
-		ForceAttemptHTTP2:     true,
-		MaxIdleConnsPerHost:   16,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   20 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-	}
-
 	return &http.Client{
-		Transport: transport,
-		Timeout:   600 * time.Second,
+		Transport: provider.NewPooledTransport(p, func() *http.Transport {
+			return &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				DialContext:           dialer.DialContext,
+				ForceAttemptHTTP2:     true,
+				TLSHandshakeTimeout:   20 * time.Second,
+				ExpectContinueTimeout: 1 * time.Second,
+			}
+		}),
+		Timeout: 600 * time.Second,
 	}
 }
 
 // applyClaudePostProcess applies minimal post-processing for advanced features
 // not yet fully integrated into the transform functions
-func applyClaudePostProcess(geminiBody []byte, sessionID string, hasThinking bool, _ []byte, mappedModel string) []byte {
+func applyClaudePostProcess(geminiBody []byte, toolScope string, hasThinking bool, _ []byte, mappedModel string) []byte {
 	var request map[string]interface{}
 	if err := json.Unmarshal(geminiBody, &request); err != nil {
 		return geminiBody
@@ -448,7 +480,7 @@ func applyClaudePostProcess(geminiBody []byte, sessionID string, hasThinking boo
 
 	// 2. Process contents for additional signature validation
 	if contents, ok := request["contents"].([]interface{}); ok {
-		if processContentsForSignatures(contents, sessionID, mappedModel) {
+		if processContentsForSignatures(contents, toolScope, mappedModel) {
 			modified = true
 		}
 	}
@@ -514,6 +546,9 @@ func (a *AntigravityAdapter) handleNonStreamResponse(ctx context.Context, w http
 
 	// Unwrap v1internal response wrapper (extract "response" field)
 	unwrappedBody := unwrapV1InternalResponse(body)
+	if scrubber := a.newResponseScrubber(); scrubber != nil {
+		unwrappedBody = scrubber.scrubPayload(unwrappedBody, true)
+	}
 
 	// Send events via EventChannel (executor will process them)
 	eventChan := ctxutil.GetEventChan(ctx)
@@ -570,6 +605,11 @@ func (a *AntigravityAdapter) handleNonStreamResponse(ctx context.Context, w http
 func (a *AntigravityAdapter) handleStreamResponse(ctx context.Context, w http.ResponseWriter, resp *http.Response, clientType domain.ClientType) error {
 	eventChan := ctxutil.GetEventChan(ctx)
 
+	// Abort the upstream as soon as the client goes away
+	guard := provider.GuardStream(ctx, resp.Body)
+	defer guard.Stop()
+	w = guard.Writer(w)
+
 	// Send initial response info (for streaming, we only capture status and headers)
 	eventChan.SendResponseInfo(&domain.ResponseInfo{
 		Status:  resp.StatusCode,
@@ -596,31 +636,36 @@ func (a *AntigravityAdapter) handleStreamResponse(ctx context.Context, w http.Re
 
 	// Extract sessionID for signature caching (like CLIProxyAPI)
 	requestBody := ctxutil.GetRequestBody(ctx)
-	sessionID := extractSessionID(requestBody)
+	toolScope := ToolSignatureScopeFromConfig(a.provider.Config.Antigravity, extractSessionID(requestBody))
 
 	// Get original request model for Claude response (like Antigravity-Manager)
 	requestModel := ctxutil.GetRequestModel(ctx)
 
 	var claudeState *ClaudeStreamingState
 	if isClaudeClient {
-		claudeState = NewClaudeStreamingStateWithSession(sessionID, requestModel)
+		claudeState = NewClaudeStreamingStateWithSession(toolScope, requestModel, requestBody)
 	}
+	scrubber := a.newResponseScrubber()
 
-	// Collect all SSE events for response body and token extraction
-	var sseBuffer strings.Builder
+	// Collect all SSE events for response body and token extraction,
+	// large responses spill to a temp file
+	sseBuffer := provider.NewSpillBuffer()
+	defer sseBuffer.Close()
 
 	// Helper to extract tokens and send events
 	sendFinalEvents := func() {
 		if sseBuffer.Len() > 0 {
+			body := sseBuffer.String()
+
 			// Send updated response body
 			eventChan.SendResponseInfo(&domain.ResponseInfo{
 				Status:  resp.StatusCode,
 				Headers: flattenHeaders(resp.Header),
-				Body:    sseBuffer.String(),
+				Body:    body,
 			})
 
 			// Extract and send token usage
-			if metrics := usage.ExtractFromStreamContent(sseBuffer.String()); metrics != nil {
+			if metrics := usage.ExtractFromStreamContent(body); metrics != nil {
 				eventChan.SendMetrics(&domain.AdapterMetrics{
 					InputTokens:          metrics.InputTokens,
 					OutputTokens:         metrics.OutputTokens,
@@ -636,7 +681,7 @@ func (a *AntigravityAdapter) handleStreamResponse(ctx context.Context, w http.Re
 			if claudeState != nil {
 				modelVersion = claudeState.GetModelVersion()
 			} else {
-				modelVersion = extractModelVersionFromSSE(sseBuffer.String())
+				modelVersion = extractModelVersionFromSSE(body)
 			}
 			if modelVersion != "" {
 				eventChan.SendResponseModel(modelVersion)
@@ -676,6 +721,9 @@ func (a *AntigravityAdapter) handleStreamResponse(ctx context.Context, w http.Re
 
 				// Unwrap v1internal SSE chunk before processing
 				unwrappedLine := unwrapV1InternalSSEChunk(lineBytes)
+				if scrubber != nil {
+					unwrappedLine = scrubber.scrubSSELine(unwrappedLine)
+				}
 
 				// Collect original SSE for token extraction (extractor handles v1internal wrapper)
 				sseBuffer.WriteString(line)
@@ -745,6 +793,10 @@ func (a *AntigravityAdapter) handleStreamResponse(ctx context.Context, w http.Re
 func (a *AntigravityAdapter) handleCollectedStreamResponse(ctx context.Context, w http.ResponseWriter, resp *http.Response, clientType domain.ClientType, requestModel string) error {
 	eventChan := ctxutil.GetEventChan(ctx)
 
+	// Abort the upstream as soon as the client goes away
+	guard := provider.GuardStream(ctx, resp.Body)
+	defer guard.Stop()
+
 	// Send initial response info
 	eventChan.SendResponseInfo(&domain.ResponseInfo{
 		Status:  resp.StatusCode,
@@ -761,13 +813,16 @@ func (a *AntigravityAdapter) handleCollectedStreamResponse(ctx context.Context,
 	if isClaudeClient {
 		// Extract sessionID for signature caching (like CLIProxyAPI)
 		requestBody := ctxutil.GetRequestBody(ctx)
-		sessionID := extractSessionID(requestBody)
-		claudeState = NewClaudeStreamingStateWithSession(sessionID, requestModel)
+		toolScope := ToolSignatureScopeFromConfig(a.provider.Config.Antigravity, extractSessionID(requestBody))
+		claudeState = NewClaudeStreamingStateWithSession(toolScope, requestModel, requestBody)
 	}
 
+	scrubber := a.newResponseScrubber()
+
 	// Collect upstream SSE for attempt/debug and token extraction.
 	var upstreamSSE strings.Builder
 	var lastPayload []byte
+	var lastGrounding json.RawMessage
 	var responseBody []byte
 
 	var lineBuffer bytes.Buffer
@@ -798,6 +853,9 @@ func (a *AntigravityAdapter) handleCollectedStreamResponse(ctx context.Context,
 				if len(unwrappedLine) == 0 {
 					continue
 				}
+				if scrubber != nil {
+					unwrappedLine = scrubber.scrubSSELine(unwrappedLine)
+				}
 
 				// Track last Gemini payload for non-Claude responses (best-effort)
 				lineStr := strings.TrimSpace(string(unwrappedLine))
@@ -805,6 +863,9 @@ func (a *AntigravityAdapter) handleCollectedStreamResponse(ctx context.Context,
 					dataStr := strings.TrimSpace(strings.TrimPrefix(lineStr, "data: "))
 					if dataStr != "" && dataStr != "[DONE]" {
 						lastPayload = []byte(dataStr)
+						if grounding := extractGroundingMetadata(lastPayload); grounding != nil {
+							lastGrounding = grounding
+						}
 					}
 				}
 
@@ -878,7 +939,7 @@ func (a *AntigravityAdapter) handleCollectedStreamResponse(ctx context.Context,
 		}
 		switch clientType {
 		case domain.ClientTypeGemini:
-			responseBody = lastPayload
+			responseBody = withGroundingMetadata(lastPayload, lastGrounding)
 		case domain.ClientTypeOpenAI:
 			return domain.NewProxyErrorWithMessage(domain.ErrFormatConversion, false, "OpenAI response transformation not yet implemented")
 		default:
@@ -892,6 +953,14 @@ func (a *AntigravityAdapter) handleCollectedStreamResponse(ctx context.Context,
 	return nil
 }
 
+// newResponseScrubber returns a scrubber for echoed injected artifacts, nil when the provider doesn't enable it
+func (a *AntigravityAdapter) newResponseScrubber() *responseScrubber {
+	if config := a.provider.Config.Antigravity; config == nil || !config.StripResponseArtifacts {
+		return nil
+	}
+	return &responseScrubber{}
+}
+
 // parseRateLimitInfo parses 429 RESOURCE_EXHAUSTED errors and extracts cooldown information
 // Returns RateLimitInfo and optional channel for async cooldown updates
 func (a *AntigravityAdapter) parseRateLimitInfo(ctx context.Context, body []byte, provider *domain.Provider) (*domain.RateLimitInfo, chan time.Time) {
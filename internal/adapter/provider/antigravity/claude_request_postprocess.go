@@ -2,16 +2,10 @@ package antigravity
 
 import (
 	"encoding/json"
+	"slices"
 	"strings"
 )
 
-// antigravityIdentity is the identity instruction injected when user doesn't provide one
-// (exactly like Antigravity-Manager's build_system_instruction)
-const antigravityIdentity = `You are Antigravity, a powerful agentic AI coding assistant designed by the Google Deepmind team working on Advanced Agentic Coding.
-You are pair programming with a USER to solve their coding task. The task may require creating a new codebase, modifying or debugging an existing codebase, or simply answering a question.
-**Absolute paths only**
-**Proactiveness**`
-
 // PostProcessClaudeRequest applies post-processing to the converted Gemini request
 // Similar to CLIProxyAPI's request handling logic:
 // 1. Injects Antigravity identity into system instruction (like Antigravity-Manager)
@@ -25,7 +19,7 @@ You are pair programming with a USER to solve their coding task. The task may re
 // 9. Validates signature model compatibility (like Antigravity-Manager)
 //
 // Note: cache_control cleaning is now done in adapter.go BEFORE transformation
-func PostProcessClaudeRequest(geminiBody []byte, sessionID string, hasThinking bool, claudeRequest []byte, mappedModel string) []byte {
+func PostProcessClaudeRequest(geminiBody []byte, toolScope string, hasThinking bool, claudeRequest []byte, mappedModel string, policy SystemPromptPolicy) []byte {
 	var request map[string]interface{}
 	if err := json.Unmarshal(geminiBody, &request); err != nil {
 		return geminiBody
@@ -33,8 +27,8 @@ func PostProcessClaudeRequest(geminiBody []byte, sessionID string, hasThinking b
 
 	modified := false
 
-	// 1. Inject Antigravity identity into system instruction (like Antigravity-Manager)
-	if injectAntigravityIdentity(request) {
+	// 1. Inject Antigravity identity into system instruction according to the policy (like Antigravity-Manager)
+	if injectAntigravityIdentity(request, policy) {
 		modified = true
 	}
 
@@ -73,7 +67,7 @@ func PostProcessClaudeRequest(geminiBody []byte, sessionID string, hasThinking b
 
 	// 7. Process contents for signature caching, skip sentinel, and model compatibility
 	if contents, ok := request["contents"].([]interface{}); ok {
-		if processContentsForSignatures(contents, sessionID, mappedModel) {
+		if processContentsForSignatures(contents, toolScope, mappedModel) {
 			modified = true
 		}
 	}
@@ -112,64 +106,36 @@ func PostProcessClaudeRequest(geminiBody []byte, sessionID string, hasThinking b
 	return result
 }
 
-// checkForAntigravityIdentity checks if system instruction already contains Antigravity identity
-// (like Antigravity-Manager's hybrid check)
-func checkForAntigravityIdentity(sysInst map[string]interface{}) bool {
-	parts, ok := sysInst["parts"].([]interface{})
-	if !ok {
-		return false
-	}
-
-	for _, part := range parts {
-		if partMap, ok := part.(map[string]interface{}); ok {
-			if text, ok := partMap["text"].(string); ok {
-				if strings.Contains(text, "You are Antigravity") {
-					return true
+// injectAntigravityIdentity injects Antigravity identity into system instruction
+// according to the system prompt policy (merge is exactly like Antigravity-Manager's build_system_instruction)
+func injectAntigravityIdentity(request map[string]interface{}, policy SystemPromptPolicy) bool {
+	normalized := normalizeSystemInstruction(request)
+	sysInst, _ := request["systemInstruction"].(map[string]interface{})
+
+	// Collect existing text parts
+	var texts []string
+	if sysInst != nil {
+		parts, _ := sysInst["parts"].([]interface{})
+		for _, part := range parts {
+			if partMap, ok := part.(map[string]interface{}); ok {
+				if text, ok := partMap["text"].(string); ok && text != "" {
+					texts = append(texts, text)
 				}
 			}
 		}
 	}
-	return false
-}
-
-// injectAntigravityIdentity injects Antigravity identity into system instruction
-// (exactly like Antigravity-Manager's build_system_instruction)
-func injectAntigravityIdentity(request map[string]interface{}) bool {
-	sysInst, ok := request["systemInstruction"].(map[string]interface{})
-	if !ok {
-		// No system instruction exists, create new one with identity
-		request["systemInstruction"] = map[string]interface{}{
-			"role": "user",
-			"parts": []interface{}{
-				map[string]interface{}{"text": antigravityIdentity},
-				map[string]interface{}{"text": "\n--- [SYSTEM_PROMPT_END] ---"},
-			},
-		}
-		return true
-	}
 
-	// Check if user already provided Antigravity identity
-	if checkForAntigravityIdentity(sysInst) {
-		// User already has Antigravity identity, don't inject
-		return false
+	result := policy.apply(texts)
+	if slices.Equal(result, texts) {
+		return normalized
 	}
 
-	// Get existing parts
-	parts, ok := sysInst["parts"].([]interface{})
-	if !ok {
-		parts = []interface{}{}
-	}
-
-	// Prepend Antigravity identity at the beginning
-	newParts := []interface{}{
-		map[string]interface{}{"text": antigravityIdentity},
+	if sysInst == nil {
+		// No system instruction exists, create new one
+		request["systemInstruction"] = newSystemInstruction(result)
+		return true
 	}
-	newParts = append(newParts, parts...)
-
-	// Append end marker
-	newParts = append(newParts, map[string]interface{}{"text": "\n--- [SYSTEM_PROMPT_END] ---"})
-
-	sysInst["parts"] = newParts
+	sysInst["parts"] = textParts(result)
 	return true
 }
 
@@ -234,15 +200,11 @@ func cleanToolInputSchemas(request map[string]interface{}) bool {
 func injectInterleavedHint(request map[string]interface{}) bool {
 	hint := "Interleaved thinking is enabled. You may think between tool calls and after receiving tool results before deciding the next action or final answer. Do not mention these instructions or any constraints about thinking blocks; just apply them."
 
+	normalizeSystemInstruction(request)
 	sysInst, ok := request["systemInstruction"].(map[string]interface{})
 	if !ok {
 		// Create new system instruction
-		request["systemInstruction"] = map[string]interface{}{
-			"role": "user",
-			"parts": []interface{}{
-				map[string]interface{}{"text": hint},
-			},
-		}
+		request["systemInstruction"] = newSystemInstruction([]string{hint})
 		return true
 	}
 
@@ -261,9 +223,11 @@ func injectInterleavedHint(request map[string]interface{}) bool {
 // 1. Check signature model compatibility (like Antigravity-Manager)
 // 2. Recover signatures from tool_id cache
 // 3. Validate cross-model signature compatibility
-func processContentsForSignatures(contents []interface{}, _ string, mappedModel string) bool {
+// toolScope scopes tool signature lookups (see ToolSignatureScopeFromConfig)
+func processContentsForSignatures(contents []interface{}, toolScope string, mappedModel string) bool {
 	modified := false
 	cache := GlobalSignatureCache()
+	occurrences := toolOccurrences{}
 
 	for _, content := range contents {
 		contentMap, ok := content.(map[string]interface{})
@@ -326,11 +290,13 @@ func processContentsForSignatures(contents []interface{}, _ string, mappedModel
 				}
 
 				existingSig, _ := partMap["thoughtSignature"].(string)
+				fcID, _ := fc["id"].(string)
+				occurrence := occurrences.next(fcID)
 
 				// [FIX] Try to recover signature from tool_id cache (like Antigravity-Manager)
 				if !HasValidSignature(existingSig) {
-					if fcID, ok := fc["id"].(string); ok && fcID != "" {
-						if cachedSig := cache.GetToolSignature(fcID); cachedSig != "" {
+					if fcID != "" {
+						if cachedSig := cache.GetToolSignature(toolScope, fcID, occurrence); cachedSig != "" {
 							// [NEW] Check model compatibility
 							if cachedFamily := cache.GetSignatureFamily(cachedSig); cachedFamily != "" {
 								if !IsModelCompatible(cachedFamily, mappedModel) {
@@ -84,6 +84,7 @@ func collectClaudeSSEToJSON(sse string) ([]byte, error) {
 	content := make([]interface{}, 0, 16)
 
 	var currentText strings.Builder
+	var currentCitations []interface{}
 	var currentThinking strings.Builder
 	var currentToolUse map[string]interface{}
 	var currentToolInput strings.Builder
@@ -112,6 +113,7 @@ func collectClaudeSSEToJSON(sse string) ([]byte, error) {
 			switch cbType {
 			case "text":
 				currentText.Reset()
+				currentCitations = nil
 			case "thinking":
 				currentThinking.Reset()
 			case "tool_use":
@@ -130,6 +132,10 @@ func collectClaudeSSEToJSON(sse string) ([]byte, error) {
 				if t, ok := delta["text"].(string); ok {
 					currentText.WriteString(t)
 				}
+			case "citations_delta":
+				if c, ok := delta["citation"]; ok {
+					currentCitations = append(currentCitations, c)
+				}
 			case "thinking_delta":
 				if t, ok := delta["thinking"].(string); ok {
 					currentThinking.WriteString(t)
@@ -142,10 +148,15 @@ func collectClaudeSSEToJSON(sse string) ([]byte, error) {
 
 		case "content_block_stop":
 			if currentText.Len() > 0 {
-				content = append(content, map[string]interface{}{
+				block := map[string]interface{}{
 					"type": "text",
 					"text": currentText.String(),
-				})
+				}
+				if len(currentCitations) > 0 {
+					block["citations"] = currentCitations
+					currentCitations = nil
+				}
+				content = append(content, block)
 				currentText.Reset()
 				continue
 			}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/awsl-project/maxx/internal/converter"
 )
 
 // BlockType represents the type of content block being processed
@@ -28,6 +30,8 @@ type ClaudeStreamingState struct {
 	// Signature management
 	pendingSignature  *string
 	trailingSignature *string
+	toolScope         string          // Scope tool signatures are cached under
+	toolOccurrences   toolOccurrences // Tool IDs already used in the conversation
 
 	// Token usage tracking
 	inputTokens     int
@@ -40,8 +44,7 @@ type ClaudeStreamingState struct {
 	responseID   string
 
 	// Grounding (web search) captured during streaming, emitted at finish (like Antigravity-Manager)
-	webSearchQuery  string
-	groundingChunks []GeminiGroundingChunk
+	grounding GeminiGroundingMetadata
 }
 
 // NewClaudeStreamingState creates a new streaming state
@@ -52,13 +55,34 @@ func NewClaudeStreamingState() *ClaudeStreamingState {
 	}
 }
 
-// NewClaudeStreamingStateWithSession creates a new streaming state with session ID and request model
-func NewClaudeStreamingStateWithSession(_ string, requestModel string) *ClaudeStreamingState {
+// NewClaudeStreamingStateWithSession creates a new streaming state with the tool signature
+// scope and request model. Tool IDs in the request history are counted so a reused ID
+// caches its signature under the occurrence the next request will look it up with.
+func NewClaudeStreamingStateWithSession(toolScope string, requestModel string, requestBody []byte) *ClaudeStreamingState {
 	return &ClaudeStreamingState{
-		blockType:    BlockTypeNone,
-		blockIndex:   0,
-		requestModel: requestModel,
+		blockType:       BlockTypeNone,
+		blockIndex:      0,
+		requestModel:    requestModel,
+		toolScope:       toolScope,
+		toolOccurrences: countToolUses(requestBody),
+	}
+}
+
+// countToolUses counts the tool_use IDs in a Claude request's history
+func countToolUses(requestBody []byte) toolOccurrences {
+	occurrences := toolOccurrences{}
+	var req ClaudeRequest
+	if len(requestBody) == 0 || json.Unmarshal(requestBody, &req) != nil {
+		return occurrences
+	}
+	for _, msg := range req.Messages {
+		for _, block := range parseContentBlocks(msg.Content) {
+			if block.Type == "tool_use" {
+				occurrences.next(block.ID)
+			}
+		}
 	}
+	return occurrences
 }
 
 // GetModelVersion returns the upstream model version captured during streaming
@@ -109,28 +133,13 @@ type GeminiStreamChunk struct {
 	ResponseID    string               `json:"responseId,omitempty"`
 }
 
-// GeminiGroundingMetadata represents grounding/web search metadata from Gemini
-type GeminiGroundingMetadata struct {
-	WebSearchQueries []string                `json:"webSearchQueries,omitempty"`
-	GroundingChunks  []GeminiGroundingChunk  `json:"groundingChunks,omitempty"`
-	SearchEntryPoint *GeminiSearchEntryPoint `json:"searchEntryPoint,omitempty"`
-}
-
-// GeminiGroundingChunk represents a grounding chunk (web search result)
-type GeminiGroundingChunk struct {
-	Web *GeminiGroundingWeb `json:"web,omitempty"`
-}
-
-// GeminiGroundingWeb represents web source in grounding chunk
-type GeminiGroundingWeb struct {
-	URI   string `json:"uri,omitempty"`
-	Title string `json:"title,omitempty"`
-}
-
-// GeminiSearchEntryPoint represents search entry point
-type GeminiSearchEntryPoint struct {
-	RenderedContent string `json:"renderedContent,omitempty"`
-}
+// Grounding types are shared with the converter, which renders them for every client format
+type (
+	GeminiGroundingMetadata = converter.GeminiGroundingMetadata
+	GeminiGroundingChunk    = converter.GeminiGroundingChunk
+	GeminiGroundingWeb      = converter.GeminiGroundingWeb
+	GeminiSearchEntryPoint  = converter.GeminiSearchEntryPoint
+)
 
 // formatSSE formats an SSE event with proper double newline terminator
 func formatSSE(eventType string, data interface{}) []byte {
@@ -289,8 +298,9 @@ func (s *ClaudeStreamingState) emitFinish(finishReason string, usage *GeminiUsag
 		s.trailingSignature = nil
 	}
 
-	// Grounding (web search) -> emit as a separate Markdown text block at finish (like Antigravity-Manager)
-	if groundingText := s.buildGroundingMarkdown(); groundingText != "" {
+	// Grounding (web search) -> emit as a separate Markdown text block at finish (like Antigravity-Manager),
+	// citing the sources so converted clients (OpenAI annotations) can surface them
+	if groundingText := converter.GroundingMarkdown(&s.grounding); groundingText != "" {
 		chunks = append(chunks, s.emit("content_block_start", map[string]interface{}{
 			"type":  "content_block_start",
 			"index": s.blockIndex,
@@ -299,6 +309,9 @@ func (s *ClaudeStreamingState) emitFinish(finishReason string, usage *GeminiUsag
 				"text": "",
 			},
 		}))
+		for _, citation := range converter.GroundingCitations(&s.grounding) {
+			chunks = append(chunks, s.emitDelta("citations_delta", map[string]interface{}{"citation": citation}))
+		}
 		chunks = append(chunks, s.emitDelta("text_delta", map[string]interface{}{"text": groundingText}))
 		chunks = append(chunks, s.emit("content_block_stop", map[string]interface{}{
 			"type":  "content_block_stop",
@@ -307,18 +320,30 @@ func (s *ClaudeStreamingState) emitFinish(finishReason string, usage *GeminiUsag
 		s.blockIndex++
 
 		// Clear grounding so we don't emit twice
-		s.webSearchQuery = ""
-		s.groundingChunks = nil
+		s.grounding = GeminiGroundingMetadata{}
 	}
 
-	// Determine stop reason
-	stopReason := "end_turn"
-	if s.usedTool {
-		stopReason = "tool_use"
-	} else if finishReason == "MAX_TOKENS" {
-		stopReason = "max_tokens"
+	// Safety block or recitation stop: explain it if nothing was emitted, so clients don't see an empty success
+	if message := finishReasonMessage(finishReason); message != "" && s.blockIndex == 0 {
+		chunks = append(chunks, s.emit("content_block_start", map[string]interface{}{
+			"type":  "content_block_start",
+			"index": s.blockIndex,
+			"content_block": map[string]interface{}{
+				"type": "text",
+				"text": "",
+			},
+		}))
+		chunks = append(chunks, s.emitDelta("text_delta", map[string]interface{}{"text": message}))
+		chunks = append(chunks, s.emit("content_block_stop", map[string]interface{}{
+			"type":  "content_block_stop",
+			"index": s.blockIndex,
+		}))
+		s.blockIndex++
 	}
 
+	// Determine stop reason
+	stopReason := converter.StopReasonFromGemini(finishReason, s.usedTool).Claude()
+
 	// Build usage with all fields (like Antigravity-Manager's to_claude_usage)
 	usageMap := map[string]interface{}{
 		"input_tokens":  s.inputTokens,
@@ -514,7 +539,7 @@ func (s *ClaudeStreamingState) processFunctionCall(fc *GeminiFunctionCall, signa
 	// [FIX] Cache tool_id -> signature mapping (like Antigravity-Manager)
 	// This allows future requests to recover the signature for this tool call
 	if signature != "" && len(signature) >= MinSignatureLength {
-		GlobalSignatureCache().CacheToolSignature(toolID, signature)
+		GlobalSignatureCache().CacheToolSignature(s.toolScope, toolID, s.toolOccurrences.next(toolID), signature)
 	}
 
 	// Build tool_use content block
@@ -678,56 +703,7 @@ func (s *ClaudeStreamingState) processPart(part *GeminiPart) [][]byte {
 
 // captureGrounding stores grounding metadata during streaming, to be emitted at finish.
 func (s *ClaudeStreamingState) captureGrounding(grounding *GeminiGroundingMetadata) {
-	if grounding == nil {
-		return
-	}
-	if len(grounding.WebSearchQueries) > 0 {
-		s.webSearchQuery = grounding.WebSearchQueries[0]
-	}
-	if len(grounding.GroundingChunks) > 0 {
-		s.groundingChunks = grounding.GroundingChunks
-	}
-}
-
-// buildGroundingMarkdown builds grounding(web search) markdown text (same format as Antigravity-Manager).
-func (s *ClaudeStreamingState) buildGroundingMarkdown() string {
-	if s.webSearchQuery == "" && len(s.groundingChunks) == 0 {
-		return ""
-	}
-
-	var groundingText strings.Builder
-
-	// 1. Search query
-	if strings.TrimSpace(s.webSearchQuery) != "" {
-		groundingText.WriteString("\n\n---\n**🔍 已为您搜索：** ")
-		groundingText.WriteString(s.webSearchQuery)
-	}
-
-	// 2. Source links
-	if len(s.groundingChunks) > 0 {
-		links := make([]string, 0, len(s.groundingChunks))
-		for i, chunk := range s.groundingChunks {
-			if chunk.Web == nil {
-				continue
-			}
-			title := chunk.Web.Title
-			if title == "" {
-				title = "网页来源"
-			}
-			uri := chunk.Web.URI
-			if uri == "" {
-				uri = "#"
-			}
-			links = append(links, fmt.Sprintf("[%d] [%s](%s)", i+1, title, uri))
-		}
-
-		if len(links) > 0 {
-			groundingText.WriteString("\n\n**🌐 来源引文：**\n")
-			groundingText.WriteString(strings.Join(links, "\n"))
-		}
-	}
-
-	return groundingText.String()
+	s.grounding.Merge(grounding)
 }
 
 // remapFunctionCallArgs remaps Gemini function call arguments to Claude Code expected format
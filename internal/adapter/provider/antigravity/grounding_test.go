@@ -0,0 +1,164 @@
+package antigravity
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/converter"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+const groundingFixture = `{"webSearchQueries":["maxx proxy"],"groundingChunks":[{"web":{"uri":"https://example.com/a","title":"Example A"}},{"web":{"uri":"https://example.com/b","title":"Example B"}}]}`
+
+var groundingURLs = []string{"https://example.com/a", "https://example.com/b"}
+
+const groundingResponse = `{"response":{"candidates":[{"content":{"role":"model","parts":[{"text":"maxx is a proxy."}]},"finishReason":"STOP","groundingMetadata":` + groundingFixture + `}],"modelVersion":"gemini-2.5-flash"}}`
+
+// groundingStream answers in two chunks, the grounding comes with the first one
+const groundingStream = `data: {"response":{"candidates":[{"content":{"role":"model","parts":[{"text":"maxx is "}]},"groundingMetadata":` + groundingFixture + `}]}}` + "\n\n" +
+	`data: {"response":{"candidates":[{"content":{"role":"model","parts":[{"text":"a proxy."}]},"finishReason":"STOP"}]}}` + "\n\n"
+
+func citedURLs(citations []converter.ClaudeCitation) []string {
+	var urls []string
+	for _, c := range citations {
+		if c.Type == converter.ClaudeCitationWebSearch {
+			urls = append(urls, c.URL)
+		}
+	}
+	return urls
+}
+
+func annotatedURLs(t *testing.T, content string, annotations []converter.OpenAIAnnotation) []string {
+	t.Helper()
+	var urls []string
+	for _, a := range annotations {
+		if a.Type != "url_citation" || a.URLCitation == nil {
+			t.Fatalf("annotation = %+v", a)
+		}
+		cited := string([]rune(content)[a.URLCitation.StartIndex:a.URLCitation.EndIndex])
+		if !strings.Contains(cited, a.URLCitation.URL) {
+			t.Errorf("annotation span %q does not hold %s", cited, a.URLCitation.URL)
+		}
+		urls = append(urls, a.URLCitation.URL)
+	}
+	return urls
+}
+
+func assertURLs(t *testing.T, what string, got []string) {
+	t.Helper()
+	if strings.Join(got, " ") != strings.Join(groundingURLs, " ") {
+		t.Errorf("%s cites %v, want %v", what, got, groundingURLs)
+	}
+}
+
+// claudeToOpenAI converts a Claude response the way the executor does for OpenAI clients
+func claudeToOpenAI(t *testing.T, body []byte) *converter.OpenAIMessage {
+	t.Helper()
+	out, err := converter.GetGlobalRegistry().TransformResponse(domain.ClientTypeClaude, domain.ClientTypeOpenAI, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp converter.OpenAIResponse
+	if err := json.Unmarshal(out, &resp); err != nil || len(resp.Choices) != 1 {
+		t.Fatalf("OpenAI response = %s, %v", out, err)
+	}
+	return resp.Choices[0].Message
+}
+
+func TestNonStreamGroundingCitedForEveryClient(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := scrubTestAdapter(false).handleNonStreamResponse(scrubTestContext(), w, upstreamResponse(groundingResponse), domain.ClientTypeClaude); err != nil {
+		t.Fatal(err)
+	}
+	var claudeResp converter.ClaudeResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &claudeResp); err != nil {
+		t.Fatal(err)
+	}
+	last := claudeResp.Content[len(claudeResp.Content)-1]
+	if !strings.Contains(last.Text, "[1] [Example A](https://example.com/a)") {
+		t.Errorf("grounding block = %q", last.Text)
+	}
+	assertURLs(t, "Claude response", citedURLs(last.Citations))
+
+	msg := claudeToOpenAI(t, w.Body.Bytes())
+	content, _ := msg.Content.(string)
+	if !strings.HasPrefix(content, "maxx is a proxy.") {
+		t.Errorf("OpenAI content = %q", content)
+	}
+	assertURLs(t, "OpenAI response", annotatedURLs(t, content, msg.Annotations))
+
+	w = httptest.NewRecorder()
+	if err := scrubTestAdapter(false).handleNonStreamResponse(scrubTestContext(), w, upstreamResponse(groundingResponse), domain.ClientTypeGemini); err != nil {
+		t.Fatal(err)
+	}
+	var geminiResp converter.GeminiResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &geminiResp); err != nil || len(geminiResp.Candidates) != 1 {
+		t.Fatalf("Gemini response = %s, %v", w.Body.String(), err)
+	}
+	assertURLs(t, "Gemini response", citedURLs(converter.GroundingCitations(geminiResp.Candidates[0].GroundingMetadata)))
+}
+
+func TestStreamGroundingCitedForEveryClient(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := scrubTestAdapter(false).handleStreamResponse(scrubTestContext(), w, upstreamResponse(groundingStream), domain.ClientTypeClaude); err != nil {
+		t.Fatal(err)
+	}
+	sse := w.Body.String()
+	if !strings.Contains(sse, `"type":"citations_delta"`) {
+		t.Fatalf("Claude stream has no citations_delta: %s", sse)
+	}
+
+	collected, err := collectClaudeSSEToJSON(sse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claudeResp converter.ClaudeResponse
+	if err := json.Unmarshal(collected, &claudeResp); err != nil {
+		t.Fatal(err)
+	}
+	assertURLs(t, "collected Claude stream", citedURLs(claudeResp.Content[len(claudeResp.Content)-1].Citations))
+
+	// OpenAI clients get the Claude stream converted, annotations arrive once the sources block is done
+	state := converter.NewTransformState()
+	out, err := converter.GetGlobalRegistry().TransformStreamChunk(domain.ClientTypeClaude, domain.ClientTypeOpenAI, []byte(sse), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var content strings.Builder
+	var annotations []converter.OpenAIAnnotation
+	events, _ := converter.ParseSSE(string(out))
+	for _, event := range events {
+		var chunk converter.OpenAIStreamChunk
+		if json.Unmarshal(event.Data, &chunk) != nil || len(chunk.Choices) == 0 || chunk.Choices[0].Delta == nil {
+			continue
+		}
+		if s, ok := chunk.Choices[0].Delta.Content.(string); ok {
+			content.WriteString(s)
+		}
+		annotations = append(annotations, chunk.Choices[0].Delta.Annotations...)
+	}
+	assertURLs(t, "OpenAI stream", annotatedURLs(t, content.String(), annotations))
+
+	// Gemini clients get the chunks as they are
+	w = httptest.NewRecorder()
+	if err := scrubTestAdapter(false).handleStreamResponse(scrubTestContext(), w, upstreamResponse(groundingStream), domain.ClientTypeGemini); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(w.Body.String(), `"groundingMetadata"`) {
+		t.Errorf("Gemini stream lost the grounding: %s", w.Body.String())
+	}
+}
+
+func TestCollectedStreamKeepsGeminiGrounding(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := scrubTestAdapter(false).handleCollectedStreamResponse(scrubTestContext(), w, upstreamResponse(groundingStream), domain.ClientTypeGemini, "gemini-2.5-flash"); err != nil {
+		t.Fatal(err)
+	}
+	var geminiResp converter.GeminiResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &geminiResp); err != nil || len(geminiResp.Candidates) != 1 {
+		t.Fatalf("Gemini response = %s, %v", w.Body.String(), err)
+	}
+	assertURLs(t, "collected Gemini stream", citedURLs(converter.GroundingCitations(geminiResp.Candidates[0].GroundingMetadata)))
+}
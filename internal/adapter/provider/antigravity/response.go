@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+
+	"github.com/awsl-project/maxx/internal/converter"
 )
 
 // Response headers to exclude when copying
@@ -34,21 +36,28 @@ func unwrapV1InternalResponse(body []byte) []byte {
 // unwrapV1InternalSSEChunk unwraps a single SSE chunk from v1internal format
 // Input: "data: {"response": {...}}\n"
 // Output: "data: {...}\n\n" (with double newline for proper SSE format)
-// Returns nil for empty lines (they are already handled by \n\n terminator)
+// Returns nil for empty lines (they are already handled by \n\n terminator) and for
+// comment lines (": keep-alive"), which carry nothing for the client. Other fields
+// (event:, id:) end with a single \n so they stay part of the event their data completes.
 func unwrapV1InternalSSEChunk(line []byte) []byte {
 	lineStr := strings.TrimSpace(string(line))
 
 	// Skip empty lines - we already add \n\n after each data line
-	if lineStr == "" {
+	if lineStr == "" || strings.HasPrefix(lineStr, ":") {
 		return nil
 	}
 
-	// Non-data lines pass through with proper SSE terminator
-	if !strings.HasPrefix(lineStr, "data: ") {
-		return []byte(lineStr + "\n\n")
+	// Non-data lines belong to the event of the next data line
+	if !strings.HasPrefix(lineStr, "data:") {
+		return []byte(lineStr + "\n")
 	}
 
-	jsonPart := strings.TrimPrefix(lineStr, "data: ")
+	// Normalize "data:{...}" to the "data: " prefix the stream handlers expect
+	jsonPart := strings.TrimSpace(strings.TrimPrefix(lineStr, "data:"))
+	if jsonPart == "" {
+		return nil
+	}
+	lineStr = "data: " + jsonPart
 
 	// Non-JSON data passes through with proper SSE terminator
 	if !strings.HasPrefix(jsonPart, "{") {
@@ -284,14 +293,18 @@ func convertGeminiToClaudeResponse(geminiBody []byte, requestModel string) ([]by
 			}
 		}
 
-		// Grounding (web search)
-		if candidate.GroundingMetadata != nil {
-			if groundingText := buildGroundingText(candidate.GroundingMetadata); groundingText != "" {
-				flushThinking()
-				flushText()
-				textBuilder.WriteString(groundingText)
-				flushText()
+		// Grounding (web search): a markdown block citing its sources
+		if groundingText := converter.GroundingMarkdown(candidate.GroundingMetadata); groundingText != "" {
+			flushThinking()
+			flushText()
+			block := map[string]interface{}{
+				"type": "text",
+				"text": groundingText,
+			}
+			if citations := converter.GroundingCitations(candidate.GroundingMetadata); len(citations) > 0 {
+				block["citations"] = citations
 			}
+			contentBlocks = append(contentBlocks, block)
 		}
 
 		flushThinking()
@@ -308,13 +321,21 @@ func convertGeminiToClaudeResponse(geminiBody []byte, requestModel string) ([]by
 		}
 	}
 
-	stopReason := "end_turn"
-	if hasToolUse {
-		stopReason = "tool_use"
-	} else if len(geminiResp.Candidates) > 0 && geminiResp.Candidates[0].FinishReason == "MAX_TOKENS" {
-		stopReason = "max_tokens"
+	finishReason := ""
+	if len(geminiResp.Candidates) > 0 {
+		finishReason = geminiResp.Candidates[0].FinishReason
+	}
+
+	// Safety block or recitation stop: explain it if nothing was produced, so clients don't see an empty success
+	if message := finishReasonMessage(finishReason); message != "" && len(contentBlocks) == 0 {
+		contentBlocks = append(contentBlocks, map[string]interface{}{
+			"type": "text",
+			"text": message,
+		})
 	}
 
+	stopReason := converter.StopReasonFromGemini(finishReason, hasToolUse).Claude()
+
 	// Usage (like Antigravity-Manager's to_claude_usage)
 	usage := map[string]interface{}{
 		"input_tokens":  0,
@@ -352,39 +373,45 @@ func convertGeminiToClaudeResponse(geminiBody []byte, requestModel string) ([]by
 	return json.Marshal(claudeResp)
 }
 
-// buildGroundingText converts grounding metadata into a markdown text snippet
-func buildGroundingText(grounding *GeminiGroundingMetadata) string {
-	if grounding == nil {
-		return ""
+// extractGroundingMetadata returns the raw grounding metadata of the first candidate of a Gemini payload
+func extractGroundingMetadata(payload []byte) json.RawMessage {
+	var resp struct {
+		Candidates []struct {
+			GroundingMetadata json.RawMessage `json:"groundingMetadata,omitempty"`
+		} `json:"candidates"`
 	}
-
-	var b strings.Builder
-
-	if len(grounding.WebSearchQueries) > 0 {
-		b.WriteString("\n\n---\n**🔍 已为您搜索：** ")
-		b.WriteString(strings.Join(grounding.WebSearchQueries, ", "))
+	if err := json.Unmarshal(payload, &resp); err != nil || len(resp.Candidates) == 0 {
+		return nil
 	}
-
-	if len(grounding.GroundingChunks) > 0 {
-		var links []string
-		for i, chunk := range grounding.GroundingChunks {
-			if chunk.Web != nil {
-				title := chunk.Web.Title
-				if title == "" {
-					title = "网页来源"
-				}
-				uri := chunk.Web.URI
-				if uri == "" {
-					uri = "#"
-				}
-				links = append(links, fmt.Sprintf("[%d] [%s](%s)", i+1, title, uri))
-			}
-		}
-		if len(links) > 0 {
-			b.WriteString("\n\n**🌐 来源引文：**\n")
-			b.WriteString(strings.Join(links, "\n"))
-		}
+	if grounding := resp.Candidates[0].GroundingMetadata; len(grounding) > 0 && string(grounding) != "null" {
+		return grounding
 	}
+	return nil
+}
 
-	return b.String()
+// withGroundingMetadata passes grounding through to Gemini clients: a collected
+// stream answers with its last payload, which may not carry the grounding of an
+// earlier chunk
+func withGroundingMetadata(payload []byte, grounding json.RawMessage) []byte {
+	if grounding == nil || extractGroundingMetadata(payload) != nil {
+		return payload
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return payload
+	}
+	candidates, _ := resp["candidates"].([]interface{})
+	if len(candidates) == 0 {
+		return payload
+	}
+	candidate, ok := candidates[0].(map[string]interface{})
+	if !ok {
+		return payload
+	}
+	candidate["groundingMetadata"] = grounding
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return payload
+	}
+	return out
 }
@@ -0,0 +1,132 @@
+package antigravity
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// responseArtifacts are texts we inject into the system prompt that the upstream
+// occasionally echoes back. Longest first, so the full identity is removed before its lines.
+var responseArtifacts = func() []string {
+	lines := strings.Split(AntigravityIdentity, "\n")
+	return []string{
+		AntigravityIdentity,
+		lines[0], // "You are Antigravity, ..."
+		lines[1], // "You are pair programming with a USER ..."
+		strings.TrimSpace(systemPromptEndMarker),
+		"[SYSTEM_PROMPT_END]",
+	}
+}()
+
+// responseScrubber removes echoed injected artifacts from the text parts of Gemini responses.
+// An artifact may be split across stream chunks, so a chunk ending with the start of an
+// artifact is held back and prepended to the next text.
+// Thought parts are left untouched, their text is bound to the thought signature.
+type responseScrubber struct {
+	pending string
+}
+
+// scrubText removes complete artifacts and holds back a trailing partial one
+func (s *responseScrubber) scrubText(text string) string {
+	text = s.pending + text
+	s.pending = ""
+	for _, artifact := range responseArtifacts {
+		text = strings.ReplaceAll(text, artifact+"\n", "")
+		text = strings.ReplaceAll(text, artifact, "")
+	}
+	if n := partialArtifactSuffix(text); n > 0 {
+		s.pending = text[len(text)-n:]
+		text = text[:len(text)-n]
+	}
+	return text
+}
+
+// partialArtifactSuffix returns the length of the longest suffix of text that is a
+// proper prefix of an artifact
+func partialArtifactSuffix(text string) int {
+	longest := 0
+	for _, artifact := range responseArtifacts {
+		for k := min(len(artifact)-1, len(text)); k > longest; k-- {
+			if strings.HasSuffix(text, artifact[:k]) {
+				longest = k
+				break
+			}
+		}
+	}
+	return longest
+}
+
+// scrubPayload scrubs the text parts of a Gemini response or stream chunk.
+// Held back text is released when a candidate finishes, or at the end of the payload when final.
+// The payload is returned unchanged when nothing was removed.
+func (s *responseScrubber) scrubPayload(payload []byte, final bool) []byte {
+	var data map[string]interface{}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return payload
+	}
+	candidates, _ := data["candidates"].([]interface{})
+
+	changed := false
+	for _, c := range candidates {
+		candidate, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		content, _ := candidate["content"].(map[string]interface{})
+		parts, _ := content["parts"].([]interface{})
+		for _, p := range parts {
+			part, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			text, ok := part["text"].(string)
+			if !ok {
+				continue
+			}
+			if thought, _ := part["thought"].(bool); thought {
+				continue
+			}
+			if scrubbed := s.scrubText(text); scrubbed != text {
+				part["text"] = scrubbed
+				changed = true
+			}
+		}
+
+		finishReason, _ := candidate["finishReason"].(string)
+		if s.pending != "" && (final || finishReason != "") {
+			if content == nil {
+				content = map[string]interface{}{"role": "model"}
+				candidate["content"] = content
+			}
+			content["parts"] = append(parts, map[string]interface{}{"text": s.pending})
+			s.pending = ""
+			changed = true
+		}
+	}
+
+	if !changed {
+		return payload
+	}
+	result, err := json.Marshal(data)
+	if err != nil {
+		return payload
+	}
+	return result
+}
+
+// scrubSSELine scrubs the payload of an unwrapped "data: {...}" SSE line
+func (s *responseScrubber) scrubSSELine(line []byte) []byte {
+	lineStr := strings.TrimSpace(string(line))
+	if !strings.HasPrefix(lineStr, "data: ") {
+		return line
+	}
+	payload := strings.TrimPrefix(lineStr, "data: ")
+	if !strings.HasPrefix(payload, "{") {
+		return line
+	}
+	scrubbed := s.scrubPayload([]byte(payload), false)
+	if string(scrubbed) == payload {
+		return line
+	}
+	return []byte("data: " + string(scrubbed) + "\n\n")
+}
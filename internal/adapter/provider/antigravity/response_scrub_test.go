@@ -0,0 +1,107 @@
+package antigravity
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	ctxutil "github.com/awsl-project/maxx/internal/context"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func scrubTestAdapter(enabled bool) *AntigravityAdapter {
+	return &AntigravityAdapter{provider: &domain.Provider{
+		Name:   "antigravity",
+		Config: &domain.ProviderConfig{Antigravity: &domain.ProviderConfigAntigravity{StripResponseArtifacts: enabled}},
+	}}
+}
+
+func scrubTestContext() context.Context {
+	ctx := ctxutil.WithEventChan(context.Background(), domain.NewAdapterEventChan())
+	ctx = ctxutil.WithRequestModel(ctx, "claude-sonnet-4-5")
+	return ctxutil.WithRequestBody(ctx, []byte(`{}`))
+}
+
+func upstreamResponse(body string) *http.Response {
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+}
+
+// geminiTextChunk builds a v1internal-wrapped stream chunk with one text part
+func geminiTextChunk(text, finishReason string) string {
+	candidate := fmt.Sprintf(`{"content":{"role":"model","parts":[{"text":%q}]}`, text)
+	if finishReason != "" {
+		candidate += fmt.Sprintf(`,"finishReason":%q`, finishReason)
+	}
+	candidate += "}"
+	return fmt.Sprintf(`data: {"response":{"candidates":[%s]}}`+"\n\n", candidate)
+}
+
+func TestNonStreamResponseScrubsEchoedIdentity(t *testing.T) {
+	echoed := AntigravityIdentity + "\n--- [SYSTEM_PROMPT_END] ---\nThe fix is in main.go."
+	body := fmt.Sprintf(`{"response":{"candidates":[{"content":{"role":"model","parts":[{"text":%q}]},"finishReason":"STOP"}]}}`, echoed)
+
+	for _, clientType := range []domain.ClientType{domain.ClientTypeClaude, domain.ClientTypeGemini} {
+		w := httptest.NewRecorder()
+		if err := scrubTestAdapter(true).handleNonStreamResponse(scrubTestContext(), w, upstreamResponse(body), clientType); err != nil {
+			t.Fatal(err)
+		}
+		out := w.Body.String()
+		if strings.Contains(out, "You are Antigravity") || strings.Contains(out, "SYSTEM_PROMPT_END") {
+			t.Errorf("%s response still leaks injected artifacts: %s", clientType, out)
+		}
+		if !strings.Contains(out, "The fix is in main.go.") {
+			t.Errorf("%s response lost the model's answer: %s", clientType, out)
+		}
+	}
+
+	// Disabled by default: the response is passed through untouched
+	w := httptest.NewRecorder()
+	if err := scrubTestAdapter(false).handleNonStreamResponse(scrubTestContext(), w, upstreamResponse(body), domain.ClientTypeGemini); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(w.Body.String(), "SYSTEM_PROMPT_END") {
+		t.Error("artifacts were stripped although the option is off")
+	}
+}
+
+func TestStreamResponseScrubsArtifactsSplitAcrossChunks(t *testing.T) {
+	upstream := geminiTextChunk("Sure. --- [SYSTEM_", "") +
+		geminiTextChunk("PROMPT_END] ---\nYou are Antigravity, a powerful agentic AI ", "") +
+		geminiTextChunk("coding assistant designed by the Google Deepmind team working on Advanced Agentic Coding.\nHere is the plan: use a --- separator.", "") +
+		geminiTextChunk(" You are", "STOP")
+
+	w := httptest.NewRecorder()
+	if err := scrubTestAdapter(true).handleStreamResponse(scrubTestContext(), w, upstreamResponse(upstream), domain.ClientTypeClaude); err != nil {
+		t.Fatal(err)
+	}
+
+	collected, err := collectClaudeSSEToJSON(w.Body.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(collected)
+	if strings.Contains(out, "Antigravity") || strings.Contains(out, "SYSTEM_PROMPT_END") {
+		t.Errorf("streamed response still leaks injected artifacts: %s", out)
+	}
+	// Held back text that turned out not to be an artifact is released
+	if !strings.Contains(out, `Sure. Here is the plan: use a --- separator. You are`) {
+		t.Errorf("streamed response = %s, want the legitimate text kept", out)
+	}
+}
+
+func TestScrubberLeavesThoughtsAndCleanTextAlone(t *testing.T) {
+	s := &responseScrubber{}
+	thought := fmt.Sprintf(`{"candidates":[{"content":{"parts":[{"text":%q,"thought":true}]}}]}`, "[SYSTEM_PROMPT_END] reasoning")
+	if got := string(s.scrubPayload([]byte(thought), true)); got != thought {
+		t.Errorf("thought part was modified: %s", got)
+	}
+
+	clean := `{"candidates":[{"content":{"parts":[{"text":"You are right, use Antigravity mode."}]},"finishReason":"STOP"}]}`
+	if got := string(s.scrubPayload([]byte(clean), true)); !strings.Contains(got, "You are right, use Antigravity mode.") {
+		t.Errorf("clean text was modified: %s", got)
+	}
+}
@@ -0,0 +1,60 @@
+package antigravity
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/converter"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestUnwrapV1InternalSSEChunkKeepAlives(t *testing.T) {
+	for line, want := range map[string]string{
+		": keep-alive\n":                    "",
+		":\n":                               "",
+		"\n":                                "",
+		"data:\n":                           "",
+		"event: message\n":                  "event: message\n",
+		`data:{"response":{"a":1}}` + "\n":  "data: {\"a\":1}\n\n",
+		`data: {"response":{"a":1}}` + "\n": "data: {\"a\":1}\n\n",
+		"data: [DONE]\n":                    "data: [DONE]\n\n",
+	} {
+		if got := string(unwrapV1InternalSSEChunk([]byte(line))); got != want {
+			t.Errorf("unwrap(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestStreamResponseWithKeepAlives(t *testing.T) {
+	plain := geminiTextChunk("Hello", "") + geminiTextChunk(" world", "STOP")
+	withKeepAlives := ": keep-alive\n\n" + geminiTextChunk("Hello", "") + ": keep-alive\n\n:\n" +
+		strings.Replace(geminiTextChunk(" world", "STOP"), "data: ", "data:", 1) + ": keep-alive\n\n"
+
+	stream := func(upstream string, clientType domain.ClientType) string {
+		w := httptest.NewRecorder()
+		if err := scrubTestAdapter(false).handleStreamResponse(scrubTestContext(), w, upstreamResponse(upstream), clientType); err != nil {
+			t.Fatal(err)
+		}
+		return w.Body.String()
+	}
+
+	// Gemini clients get the same events, without comments or blank events
+	got, want := stream(withKeepAlives, domain.ClientTypeGemini), stream(plain, domain.ClientTypeGemini)
+	if got != want {
+		t.Errorf("Gemini stream with keep-alives = %q, want %q", got, want)
+	}
+	events, rest := converter.ParseSSE(got)
+	if len(events) != 2 || rest != "" || strings.Contains(got, ":\n") || strings.Contains(got, "\n\n\n") {
+		t.Errorf("Gemini stream = %q", got)
+	}
+
+	// Claude clients get a complete message with all the text
+	collected, err := collectClaudeSSEToJSON(stream(withKeepAlives, domain.ClientTypeClaude))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(collected), `"text":"Hello world"`) {
+		t.Errorf("Claude message = %s", collected)
+	}
+}
@@ -1,6 +1,7 @@
 package antigravity
 
 import (
+	"fmt"
 	"os"
 	"strings"
 )
@@ -90,3 +91,27 @@ func BuildSafetySettingsMap(threshold SafetyThreshold) []map[string]interface{}
 	}
 	return settings
 }
+
+// isSafetyFinishReason reports whether a Gemini finishReason means the response
+// was blocked by safety or policy filters
+func isSafetyFinishReason(finishReason string) bool {
+	switch finishReason {
+	case "SAFETY", "PROHIBITED_CONTENT", "BLOCKLIST", "SPII", "IMAGE_SAFETY":
+		return true
+	}
+	return false
+}
+
+// finishReasonMessage builds the explanatory text returned to Claude clients when
+// Gemini stops a response before producing anything, so the stop is not mistaken
+// for an empty success. RECITATION is Gemini's copyright check stopping output that
+// recites training data, not a safety refusal. Empty for other finish reasons.
+func finishReasonMessage(finishReason string) string {
+	switch {
+	case isSafetyFinishReason(finishReason):
+		return fmt.Sprintf("[Response blocked by upstream safety filter (finishReason: %s)]", finishReason)
+	case finishReason == "RECITATION":
+		return fmt.Sprintf("[Response stopped by upstream recitation check (finishReason: %s)]", finishReason)
+	}
+	return ""
+}
@@ -0,0 +1,137 @@
+package antigravity
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var safetyFinishReasons = []string{
+	"SAFETY",
+	"PROHIBITED_CONTENT",
+	"BLOCKLIST",
+	"SPII",
+	"IMAGE_SAFETY",
+}
+
+func TestConvertGeminiToClaudeResponseSafetyBlock(t *testing.T) {
+	for _, reason := range safetyFinishReasons {
+		t.Run(reason, func(t *testing.T) {
+			body := fmt.Sprintf(`{"candidates":[{"content":{"parts":[]},"finishReason":%q}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":0}}`, reason)
+
+			out, err := convertGeminiToClaudeResponse([]byte(body), "claude-sonnet-4-5")
+			if err != nil {
+				t.Fatalf("convert failed: %v", err)
+			}
+
+			var resp struct {
+				StopReason string `json:"stop_reason"`
+				Content    []struct {
+					Type string `json:"type"`
+					Text string `json:"text"`
+				} `json:"content"`
+			}
+			if err := json.Unmarshal(out, &resp); err != nil {
+				t.Fatalf("invalid response JSON: %v", err)
+			}
+			if resp.StopReason != "refusal" {
+				t.Errorf("stop_reason = %q, want %q", resp.StopReason, "refusal")
+			}
+			if len(resp.Content) != 1 || resp.Content[0].Type != "text" || !strings.Contains(resp.Content[0].Text, reason) {
+				t.Errorf("content = %+v, want single text block mentioning %s", resp.Content, reason)
+			}
+		})
+	}
+}
+
+func TestConvertGeminiToClaudeResponseSafetyKeepsPartialText(t *testing.T) {
+	body := `{"candidates":[{"content":{"parts":[{"text":"partial"}]},"finishReason":"SAFETY"}]}`
+
+	out, err := convertGeminiToClaudeResponse([]byte(body), "claude-sonnet-4-5")
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatalf("invalid response JSON: %v", err)
+	}
+	if resp["stop_reason"] != "refusal" {
+		t.Errorf("stop_reason = %v, want refusal", resp["stop_reason"])
+	}
+	content := resp["content"].([]interface{})
+	if len(content) != 1 || content[0].(map[string]interface{})["text"] != "partial" {
+		t.Errorf("content = %v, want partial text only", content)
+	}
+}
+
+func TestClaudeStreamingStateSafetyBlock(t *testing.T) {
+	for _, reason := range safetyFinishReasons {
+		t.Run(reason, func(t *testing.T) {
+			state := NewClaudeStreamingStateWithSession("", "claude-sonnet-4-5", nil)
+			line := fmt.Sprintf(`data: {"candidates":[{"content":{"parts":[]},"finishReason":%q}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":0}}`, reason)
+
+			var sse strings.Builder
+			sse.Write(state.ProcessGeminiSSELine(line))
+			sse.Write(state.EmitForceStop())
+
+			collected, err := collectClaudeSSEToJSON(sse.String())
+			if err != nil {
+				t.Fatalf("collect failed: %v", err)
+			}
+
+			var resp struct {
+				StopReason string `json:"stop_reason"`
+				Content    []struct {
+					Type string `json:"type"`
+					Text string `json:"text"`
+				} `json:"content"`
+			}
+			if err := json.Unmarshal(collected, &resp); err != nil {
+				t.Fatalf("invalid collected JSON: %v", err)
+			}
+			if resp.StopReason != "refusal" {
+				t.Errorf("stop_reason = %q, want %q", resp.StopReason, "refusal")
+			}
+			if len(resp.Content) != 1 || !strings.Contains(resp.Content[0].Text, reason) {
+				t.Errorf("content = %+v, want single text block mentioning %s", resp.Content, reason)
+			}
+		})
+	}
+}
+
+func TestRecitationStopIsNotRefusal(t *testing.T) {
+	body := `{"candidates":[{"content":{"parts":[]},"finishReason":"RECITATION"}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":0}}`
+
+	out, err := convertGeminiToClaudeResponse([]byte(body), "claude-sonnet-4-5")
+	if err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	state := NewClaudeStreamingStateWithSession("", "claude-sonnet-4-5", nil)
+	var sse strings.Builder
+	sse.Write(state.ProcessGeminiSSELine("data: " + body))
+	sse.Write(state.EmitForceStop())
+	collected, err := collectClaudeSSEToJSON(sse.String())
+	if err != nil {
+		t.Fatalf("collect failed: %v", err)
+	}
+
+	for name, message := range map[string][]byte{"non-streaming": out, "streaming": collected} {
+		var resp struct {
+			StopReason string `json:"stop_reason"`
+			Content    []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		}
+		if err := json.Unmarshal(message, &resp); err != nil {
+			t.Fatalf("%s: invalid JSON: %v", name, err)
+		}
+		if resp.StopReason != "end_turn" {
+			t.Errorf("%s: stop_reason = %q, want end_turn", name, resp.StopReason)
+		}
+		if len(resp.Content) != 1 || !strings.Contains(resp.Content[0].Text, "recitation") || strings.Contains(resp.Content[0].Text, "safety") {
+			t.Errorf("%s: content = %+v, want the recitation explanation", name, resp.Content)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package antigravity
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
 )
 
 // SignatureCache provides a two-layer signature cache (like Antigravity-Manager):
@@ -17,6 +21,10 @@ type SignatureCache struct {
 
 	// Layer 2: Thinking Signature -> Model Family
 	thinkingFamilies map[string]signatureCacheEntry
+
+	// Lookup outcomes since startup, kept across clears
+	toolHits, toolMisses     int64
+	familyHits, familyMisses int64
 }
 
 type signatureCacheEntry struct {
@@ -65,8 +73,49 @@ func HasValidSignature(signature string) bool {
 	return signature != "" && len(signature) >= MinSignatureLength
 }
 
-// CacheToolSignature stores a signature for a specific tool call ID (Layer 1).
-func (c *SignatureCache) CacheToolSignature(toolID, signature string) {
+// Tool signature scopes
+const (
+	ToolSignatureScopeGlobal  = "global"  // Tool IDs are keyed as is (default)
+	ToolSignatureScopeSession = "session" // Tool IDs are keyed per session, so equal IDs in different sessions don't collide
+)
+
+// ToolSignatureScopeFromConfig returns the scope tool IDs are cached under:
+// the session ID when the provider scopes tool signatures by session, otherwise empty
+func ToolSignatureScopeFromConfig(config *domain.ProviderConfigAntigravity, sessionID string) string {
+	if config == nil || config.ToolSignatureScope != ToolSignatureScopeSession {
+		return ""
+	}
+	return sessionID
+}
+
+// toolSignatureKey builds the Layer 1 key of a tool call. Some clients reuse tool IDs
+// across turns, so the n-th tool_use carrying an ID in a conversation (occurrence n,
+// 0-based) gets its own key; the first one is keyed by the bare ID.
+func toolSignatureKey(scope, toolID string, occurrence int) string {
+	key := toolID
+	if occurrence > 0 {
+		key = fmt.Sprintf("%s#%d", toolID, occurrence)
+	}
+	if scope != "" {
+		key = scope + "\x00" + key
+	}
+	return key
+}
+
+// toolOccurrences counts the tool_use IDs seen while walking a conversation
+type toolOccurrences map[string]int
+
+// next returns the occurrence of toolID and counts it
+func (o toolOccurrences) next(toolID string) int {
+	n := o[toolID]
+	o[toolID] = n + 1
+	return n
+}
+
+// CacheToolSignature stores a signature for a tool call (Layer 1).
+// scope is empty unless tool IDs are scoped by session, occurrence counts earlier
+// tool calls with the same ID in the conversation.
+func (c *SignatureCache) CacheToolSignature(scope, toolID string, occurrence int, signature string) {
 	if signature == "" || len(signature) < MinSignatureLength {
 		return
 	}
@@ -75,7 +124,7 @@ func (c *SignatureCache) CacheToolSignature(toolID, signature string) {
 	defer c.mu.Unlock()
 
 	now := time.Now()
-	c.toolSignatures[toolID] = signatureCacheEntry{data: signature, timestamp: now}
+	c.toolSignatures[toolSignatureKey(scope, toolID, occurrence)] = signatureCacheEntry{data: signature, timestamp: now}
 
 	if len(c.toolSignatures) > signatureCacheMaxEntries {
 		for key, entry := range c.toolSignatures {
@@ -86,20 +135,24 @@ func (c *SignatureCache) CacheToolSignature(toolID, signature string) {
 	}
 }
 
-// GetToolSignature retrieves a cached signature for a tool call ID
-func (c *SignatureCache) GetToolSignature(toolID string) string {
+// GetToolSignature retrieves a cached signature for a tool call
+func (c *SignatureCache) GetToolSignature(scope, toolID string, occurrence int) string {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	entry, ok := c.toolSignatures[toolID]
+	key := toolSignatureKey(scope, toolID, occurrence)
+	entry, ok := c.toolSignatures[key]
 	if !ok {
+		c.toolMisses++
 		return ""
 	}
 	now := time.Now()
 	if entry.expired(now) {
-		delete(c.toolSignatures, toolID)
+		delete(c.toolSignatures, key)
+		c.toolMisses++
 		return ""
 	}
+	c.toolHits++
 	return entry.data
 }
 
@@ -131,22 +184,136 @@ func (c *SignatureCache) GetSignatureFamily(signature string) string {
 
 	entry, ok := c.thinkingFamilies[signature]
 	if !ok {
+		c.familyMisses++
 		return ""
 	}
 	now := time.Now()
 	if entry.expired(now) {
 		delete(c.thinkingFamilies, signature)
+		c.familyMisses++
 		return ""
 	}
+	c.familyHits++
 	return entry.data
 }
 
 // Clear clears all caches (for tests or manual reset).
 func (c *SignatureCache) Clear() {
+	c.ClearAll()
+}
+
+// ClearAll clears both layers and returns the number of entries removed.
+// The lookup statistics are kept.
+func (c *SignatureCache) ClearAll() int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	removed := len(c.toolSignatures) + len(c.thinkingFamilies)
 	c.toolSignatures = make(map[string]signatureCacheEntry)
 	c.thinkingFamilies = make(map[string]signatureCacheEntry)
+	return removed
+}
+
+// ClearSession removes the tool signatures cached under a session and returns
+// how many were removed. Only providers scoping tool signatures by session
+// cache under the session ID.
+func (c *SignatureCache) ClearSession(sessionID string) int {
+	if sessionID == "" {
+		return 0
+	}
+	prefix := sessionID + "\x00"
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for key := range c.toolSignatures {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.toolSignatures, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// ClearTool removes the signatures cached for a tool call ID, every occurrence
+// in every scope, and returns how many were removed.
+func (c *SignatureCache) ClearTool(toolID string) int {
+	if toolID == "" {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for key := range c.toolSignatures {
+		if toolIDOfKey(key) == toolID {
+			delete(c.toolSignatures, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// toolIDOfKey returns the tool ID a Layer 1 key was built from, see toolSignatureKey
+func toolIDOfKey(key string) string {
+	if i := strings.IndexByte(key, 0); i >= 0 {
+		key = key[i+1:]
+	}
+	if i := strings.LastIndexByte(key, '#'); i >= 0 {
+		if _, err := strconv.Atoi(key[i+1:]); err == nil {
+			key = key[:i]
+		}
+	}
+	return key
+}
+
+// SignatureCacheLayerStats describes one layer of the signature cache
+type SignatureCacheLayerStats struct {
+	Entries int     `json:"entries"` // unexpired entries
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`  // lookups of missing or expired entries
+	HitRate float64 `json:"hitRate"` // percentage of lookups that hit (0-100), 0 before any lookup
+}
+
+// SignatureCacheStats describes the signature cache. Lookup counts are since startup.
+type SignatureCacheStats struct {
+	ToolSignatures   SignatureCacheLayerStats `json:"toolSignatures"`
+	ThinkingFamilies SignatureCacheLayerStats `json:"thinkingFamilies"`
+	Sessions         int                      `json:"sessions"` // sessions with tool signatures cached under their ID
+	TTLSeconds       int64                    `json:"ttlSeconds"`
+}
+
+// Stats returns the current size and hit rate of the cache
+func (c *SignatureCache) Stats() SignatureCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	stats := SignatureCacheStats{
+		ToolSignatures:   layerStats(c.toolSignatures, c.toolHits, c.toolMisses, now),
+		ThinkingFamilies: layerStats(c.thinkingFamilies, c.familyHits, c.familyMisses, now),
+		TTLSeconds:       int64(SignatureCacheTTL / time.Second),
+	}
+	sessions := make(map[string]struct{})
+	for key, entry := range c.toolSignatures {
+		if i := strings.IndexByte(key, 0); i >= 0 && !entry.expired(now) {
+			sessions[key[:i]] = struct{}{}
+		}
+	}
+	stats.Sessions = len(sessions)
+	return stats
+}
+
+func layerStats(entries map[string]signatureCacheEntry, hits, misses int64, now time.Time) SignatureCacheLayerStats {
+	stats := SignatureCacheLayerStats{Hits: hits, Misses: misses}
+	for _, entry := range entries {
+		if !entry.expired(now) {
+			stats.Entries++
+		}
+	}
+	if total := hits + misses; total > 0 {
+		stats.HitRate = float64(hits) * 100 / float64(total)
+	}
+	return stats
 }
 
 // IsModelCompatible checks if two models are compatible (same family)
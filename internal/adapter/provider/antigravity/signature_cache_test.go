@@ -0,0 +1,216 @@
+package antigravity
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func testSignature(name string) string {
+	return name + strings.Repeat("s", MinSignatureLength)
+}
+
+// respondWithToolCall caches a tool call signature the way a streamed response does
+func respondWithToolCall(toolScope string, history []ClaudeMessage, toolID, signature string) {
+	body, _ := json.Marshal(ClaudeRequest{Messages: history})
+	state := NewClaudeStreamingStateWithSession(toolScope, "claude-sonnet-4-5", body)
+	state.processFunctionCall(&GeminiFunctionCall{Name: "read", ID: toolID}, signature)
+}
+
+func toolUseMessage(toolID string) ClaudeMessage {
+	return ClaudeMessage{Role: "assistant", Content: []interface{}{
+		map[string]interface{}{"type": "tool_use", "id": toolID, "name": "read", "input": map[string]interface{}{}},
+	}}
+}
+
+func toolResultMessage(toolID string) ClaudeMessage {
+	return ClaudeMessage{Role: "user", Content: []interface{}{
+		map[string]interface{}{"type": "tool_result", "tool_use_id": toolID, "content": "ok"},
+	}}
+}
+
+// recoveredSignatures returns the signatures buildContents attaches to the history's tool calls
+func recoveredSignatures(t *testing.T, toolScope string, history []ClaudeMessage) []string {
+	t.Helper()
+	contents, err := buildContents(history, "claude-sonnet-4-5", toolScope, GlobalSignatureCache())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signatures []string
+	for _, content := range contents {
+		for _, part := range content["parts"].([]map[string]interface{}) {
+			if _, ok := part["functionCall"]; ok {
+				sig, _ := part["thoughtSignature"].(string)
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+	return signatures
+}
+
+func TestToolSignatureScopeFromConfig(t *testing.T) {
+	if got := ToolSignatureScopeFromConfig(nil, "session-a"); got != "" {
+		t.Errorf("nil config scope = %q, want global", got)
+	}
+	if got := ToolSignatureScopeFromConfig(&domain.ProviderConfigAntigravity{}, "session-a"); got != "" {
+		t.Errorf("default scope = %q, want global", got)
+	}
+	config := &domain.ProviderConfigAntigravity{ToolSignatureScope: ToolSignatureScopeSession}
+	if got := ToolSignatureScopeFromConfig(config, "session-a"); got != "session-a" {
+		t.Errorf("session scope = %q, want the session ID", got)
+	}
+}
+
+func TestToolSignaturesReusedIDAcrossSessions(t *testing.T) {
+	GlobalSignatureCache().Clear()
+	t.Cleanup(GlobalSignatureCache().Clear)
+	ClearThoughtSignature()
+	t.Cleanup(ClearThoughtSignature)
+
+	sigA, sigB := testSignature("a"), testSignature("b")
+	history := []ClaudeMessage{{Role: "user", Content: "hi"}, toolUseMessage("toolu_1"), toolResultMessage("toolu_1")}
+
+	// Scoped by session, both sessions keep their own signature for the shared ID
+	respondWithToolCall("session-a", nil, "toolu_1", sigA)
+	respondWithToolCall("session-b", nil, "toolu_1", sigB)
+	ClearThoughtSignature()
+	if got := recoveredSignatures(t, "session-a", history); len(got) != 1 || got[0] != sigA {
+		t.Errorf("session a recovered %v, want its own signature", got)
+	}
+	if got := recoveredSignatures(t, "session-b", history); len(got) != 1 || got[0] != sigB {
+		t.Errorf("session b recovered %v, want its own signature", got)
+	}
+
+	// Unscoped, the later session overwrites the earlier one
+	respondWithToolCall("", nil, "toolu_1", sigA)
+	respondWithToolCall("", nil, "toolu_1", sigB)
+	ClearThoughtSignature()
+	if got := recoveredSignatures(t, "", history); len(got) != 1 || got[0] != sigB {
+		t.Errorf("global scope recovered %v, want the latest signature", got)
+	}
+}
+
+func TestToolSignaturesReusedIDWithinSession(t *testing.T) {
+	GlobalSignatureCache().Clear()
+	t.Cleanup(GlobalSignatureCache().Clear)
+	ClearThoughtSignature()
+	t.Cleanup(ClearThoughtSignature)
+
+	sig1, sig2 := testSignature("first"), testSignature("second")
+
+	// The client sends the same tool ID for two unrelated calls in one conversation
+	turn1 := []ClaudeMessage{{Role: "user", Content: "read a"}}
+	respondWithToolCall("session-a", turn1, "call_0", sig1)
+	turn2 := append(turn1, toolUseMessage("call_0"), toolResultMessage("call_0"), ClaudeMessage{Role: "user", Content: "read b"})
+	respondWithToolCall("session-a", turn2, "call_0", sig2)
+	ClearThoughtSignature()
+
+	history := append(turn2, toolUseMessage("call_0"), toolResultMessage("call_0"))
+	got := recoveredSignatures(t, "session-a", history)
+	if len(got) != 2 || got[0] != sig1 || got[1] != sig2 {
+		t.Errorf("recovered %v, want each call to keep its own signature", got)
+	}
+
+	// Post-processing resolves the reused ID the same way
+	contents := []interface{}{
+		map[string]interface{}{"role": "model", "parts": []interface{}{
+			map[string]interface{}{"functionCall": map[string]interface{}{"name": "read", "id": "call_0"}},
+		}},
+		map[string]interface{}{"role": "model", "parts": []interface{}{
+			map[string]interface{}{"functionCall": map[string]interface{}{"name": "read", "id": "call_0"}},
+		}},
+	}
+	processContentsForSignatures(contents, "session-a", "claude-sonnet-4-5")
+	for i, want := range []string{sig1, sig2} {
+		part := contents[i].(map[string]interface{})["parts"].([]interface{})[0].(map[string]interface{})
+		if part["thoughtSignature"] != want {
+			t.Errorf("post-processed call %d signature = %v, want %s", i, part["thoughtSignature"], want)
+		}
+	}
+}
+
+func TestSignatureCacheClearByScope(t *testing.T) {
+	cache := newSignatureCache()
+	sig := testSignature("x")
+	cache.CacheToolSignature("session-a", "toolu_1", 0, sig)
+	cache.CacheToolSignature("session-a", "toolu_1", 1, sig)
+	cache.CacheToolSignature("session-a", "toolu_2", 0, sig)
+	cache.CacheToolSignature("session-b", "toolu_1", 0, sig)
+	cache.CacheToolSignature("", "toolu_1", 0, sig)
+	cache.CacheToolSignature("", "toolu_1#x", 0, sig)
+	cache.CacheThinkingFamily(sig, "claude-sonnet-4-5")
+
+	// Every occurrence of the tool ID in every scope, but not IDs that merely share its prefix
+	if removed := cache.ClearTool("toolu_1"); removed != 4 {
+		t.Errorf("ClearTool removed %d, want 4", removed)
+	}
+	if cache.GetToolSignature("session-a", "toolu_2", 0) != sig || cache.GetToolSignature("", "toolu_1#x", 0) != sig {
+		t.Error("ClearTool removed other tool IDs")
+	}
+
+	cache.CacheToolSignature("session-b", "toolu_3", 0, sig)
+	if removed := cache.ClearSession("session-a"); removed != 1 {
+		t.Errorf("ClearSession removed %d, want 1", removed)
+	}
+	if cache.GetToolSignature("session-a", "toolu_2", 0) != "" {
+		t.Error("session a signature survived ClearSession")
+	}
+	if cache.GetToolSignature("session-b", "toolu_3", 0) != sig || cache.GetSignatureFamily(sig) == "" {
+		t.Error("ClearSession removed another session's signatures or the thinking families")
+	}
+	if removed := cache.ClearSession(""); removed != 0 {
+		t.Errorf("ClearSession with no session removed %d", removed)
+	}
+
+	// Unscoped toolu_1#x, session b's toolu_3 and the thinking family
+	if removed := cache.ClearAll(); removed != 3 {
+		t.Errorf("ClearAll removed %d, want 3", removed)
+	}
+	if stats := cache.Stats(); stats.ToolSignatures.Entries != 0 || stats.ThinkingFamilies.Entries != 0 {
+		t.Errorf("entries after ClearAll = %+v", stats)
+	}
+}
+
+func TestSignatureCacheStats(t *testing.T) {
+	cache := newSignatureCache()
+	sig := testSignature("x")
+	cache.CacheToolSignature("session-a", "toolu_1", 0, sig)
+	cache.CacheToolSignature("session-b", "toolu_1", 0, sig)
+	cache.CacheToolSignature("", "toolu_2", 0, sig)
+	cache.CacheToolSignature("", "toolu_short", 0, "short") // too short to cache
+	cache.CacheThinkingFamily(sig, "claude-sonnet-4-5")
+
+	// 3 tool hits, 1 miss
+	for _, key := range []struct {
+		scope, id string
+	}{{"session-a", "toolu_1"}, {"session-b", "toolu_1"}, {"", "toolu_2"}, {"", "toolu_short"}} {
+		cache.GetToolSignature(key.scope, key.id, 0)
+	}
+	// An expired entry counts as a miss and is no longer an entry
+	cache.mu.Lock()
+	cache.thinkingFamilies["expired"] = signatureCacheEntry{data: "gemini", timestamp: time.Now().Add(-SignatureCacheTTL - time.Minute)}
+	cache.mu.Unlock()
+	cache.GetSignatureFamily(sig)
+	cache.GetSignatureFamily("expired")
+
+	stats := cache.Stats()
+	want := SignatureCacheStats{
+		ToolSignatures:   SignatureCacheLayerStats{Entries: 3, Hits: 3, Misses: 1, HitRate: 75},
+		ThinkingFamilies: SignatureCacheLayerStats{Entries: 1, Hits: 1, Misses: 1, HitRate: 50},
+		Sessions:         2,
+		TTLSeconds:       int64(SignatureCacheTTL / time.Second),
+	}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	// Clearing keeps the lookup statistics
+	cache.ClearAll()
+	stats = cache.Stats()
+	if stats.ToolSignatures.Hits != 3 || stats.ToolSignatures.Entries != 0 || stats.Sessions != 0 {
+		t.Errorf("stats after clear = %+v", stats)
+	}
+}
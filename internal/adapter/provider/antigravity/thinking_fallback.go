@@ -0,0 +1,91 @@
+package antigravity
+
+import (
+	"log"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// Thinking signature fallbacks, selected by ProviderConfigAntigravity.ThinkingSignatureFallback
+const (
+	// ThinkingFallbackFilter drops or downgrades the invalid thinking blocks and keeps thinking on (default)
+	ThinkingFallbackFilter = "filter"
+	// ThinkingFallbackDisable removes all thinking from the request and sends it without thinking
+	ThinkingFallbackDisable = "disable"
+)
+
+// ThinkingFallbackFromConfig reads the thinking signature fallback from the provider config
+func ThinkingFallbackFromConfig(config *domain.ProviderConfigAntigravity) string {
+	if config == nil || config.ThinkingSignatureFallback == "" {
+		return ThinkingFallbackFilter
+	}
+	return config.ThinkingSignatureFallback
+}
+
+// hasInvalidThinkingSignature reports whether any assistant thinking block in the
+// history carries a signature that cannot be validated
+func hasInvalidThinkingSignature(messages []ClaudeMessage) bool {
+	for _, msg := range messages {
+		if msg.Role != "assistant" && msg.Role != "model" {
+			continue
+		}
+		for _, block := range parseContentBlocks(msg.Content) {
+			switch block.Type {
+			case "thinking":
+				if !hasValidThinkingSignature(block.Thinking, block.Signature) {
+					return true
+				}
+			case "redacted_thinking":
+				if block.Data == "" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// disableThinking turns the request into a non-thinking one: the thinking config
+// is cleared and every thinking block is removed from the assistant history
+func disableThinking(claudeReq *ClaudeRequest) {
+	claudeReq.Thinking = nil
+
+	for i := range claudeReq.Messages {
+		role := claudeReq.Messages[i].Role
+		if role != "assistant" && role != "model" {
+			continue
+		}
+
+		blocks := parseContentBlocks(claudeReq.Messages[i].Content)
+		if blocks == nil {
+			continue
+		}
+
+		filtered := make([]ContentBlock, 0, len(blocks))
+		for _, block := range blocks {
+			if block.Type == "thinking" || block.Type == "redacted_thinking" {
+				continue
+			}
+			filtered = append(filtered, block)
+		}
+		if len(filtered) == 0 {
+			filtered = append(filtered, ContentBlock{
+				Type: "text",
+				Text: "",
+			})
+		}
+		claudeReq.Messages[i].Content = filtered
+	}
+}
+
+// applyThinkingFallback downgrades the request to non-thinking when the fallback
+// asks for it and the history has signatures that cannot be validated.
+// It returns true when thinking was disabled.
+func applyThinkingFallback(claudeReq *ClaudeRequest, fallback string) bool {
+	if fallback != ThinkingFallbackDisable || !hasInvalidThinkingSignature(claudeReq.Messages) {
+		return false
+	}
+	log.Printf("[Antigravity] Invalid thinking signatures in history, downgrading request to non-thinking")
+	disableThinking(claudeReq)
+	return true
+}
@@ -0,0 +1,127 @@
+package antigravity
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const validThinkingSignature = "sig-0123456789abcdef"
+
+func thinkingConversation(signature string) []byte {
+	body := map[string]interface{}{
+		"model":      "claude-sonnet-4-5",
+		"max_tokens": 4096,
+		"thinking":   map[string]interface{}{"type": "enabled", "budget_tokens": 2048},
+		"messages": []interface{}{
+			map[string]interface{}{"role": "user", "content": "What is 2 + 2?"},
+			map[string]interface{}{"role": "assistant", "content": []interface{}{
+				map[string]interface{}{"type": "thinking", "thinking": "Adding the numbers.", "signature": signature},
+				map[string]interface{}{"type": "text", "text": "4"},
+			}},
+			map[string]interface{}{"role": "user", "content": "And 3 + 3?"},
+		},
+	}
+	data, _ := json.Marshal(body)
+	return data
+}
+
+func transformWithFallback(t *testing.T, body []byte, fallback string) (map[string]interface{}, bool) {
+	t.Helper()
+	out, _, hasThinking, err := TransformClaudeToGemini(body, "claude-sonnet-4-5-thinking", false, "", newSignatureCache(), SystemPromptPolicy{}, fallback)
+	if err != nil {
+		t.Fatalf("TransformClaudeToGemini failed: %v", err)
+	}
+	var geminiReq map[string]interface{}
+	if err := json.Unmarshal(out, &geminiReq); err != nil {
+		t.Fatalf("invalid Gemini request: %v", err)
+	}
+	return geminiReq, hasThinking
+}
+
+// thoughtParts counts the thought parts and the text parts containing text
+func thoughtParts(geminiReq map[string]interface{}, text string) (thoughts, texts int) {
+	contents, _ := geminiReq["contents"].([]interface{})
+	for _, c := range contents {
+		parts, _ := c.(map[string]interface{})["parts"].([]interface{})
+		for _, p := range parts {
+			part := p.(map[string]interface{})
+			if part["thought"] == true {
+				thoughts++
+			} else if s, _ := part["text"].(string); strings.Contains(s, text) {
+				texts++
+			}
+		}
+	}
+	return thoughts, texts
+}
+
+func hasThinkingConfig(geminiReq map[string]interface{}) bool {
+	genConfig, _ := geminiReq["generationConfig"].(map[string]interface{})
+	_, ok := genConfig["thinkingConfig"]
+	return ok
+}
+
+func TestThinkingFallbackInvalidSignature(t *testing.T) {
+	tests := []struct {
+		name         string
+		signature    string
+		fallback     string
+		wantThinking bool
+		wantThoughts int
+		wantTexts    int // invalid thinking kept as plain text
+	}{
+		{"filter stripped signature", "", ThinkingFallbackFilter, true, 0, 1},
+		{"filter short signature", "short", ThinkingFallbackFilter, true, 0, 1},
+		{"default is filter", "", "", true, 0, 1},
+		{"disable stripped signature", "", ThinkingFallbackDisable, false, 0, 0},
+		{"disable short signature", "short", ThinkingFallbackDisable, false, 0, 0},
+		{"filter valid signature", validThinkingSignature, ThinkingFallbackFilter, true, 1, 0},
+		{"disable valid signature", validThinkingSignature, ThinkingFallbackDisable, true, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			geminiReq, hasThinking := transformWithFallback(t, thinkingConversation(tt.signature), tt.fallback)
+			if hasThinking != tt.wantThinking {
+				t.Errorf("hasThinking = %v, want %v", hasThinking, tt.wantThinking)
+			}
+			if got := hasThinkingConfig(geminiReq); got != tt.wantThinking {
+				t.Errorf("thinkingConfig present = %v, want %v", got, tt.wantThinking)
+			}
+			thoughts, texts := thoughtParts(geminiReq, "Adding the numbers.")
+			if thoughts != tt.wantThoughts || texts != tt.wantTexts {
+				t.Errorf("got %d thought parts and %d text parts with the thinking, want %d and %d",
+					thoughts, texts, tt.wantThoughts, tt.wantTexts)
+			}
+		})
+	}
+}
+
+func TestThinkingFallbackOverridesDefaultThinking(t *testing.T) {
+	// Opus 4.5 enables thinking by default, a downgraded request must stay non-thinking
+	body := map[string]interface{}{
+		"model":      "claude-opus-4-5",
+		"max_tokens": 4096,
+		"messages": []interface{}{
+			map[string]interface{}{"role": "user", "content": "Hi"},
+			map[string]interface{}{"role": "assistant", "content": []interface{}{
+				map[string]interface{}{"type": "redacted_thinking"},
+				map[string]interface{}{"type": "thinking", "thinking": "Greeting."},
+			}},
+			map[string]interface{}{"role": "user", "content": "Hello again"},
+		},
+	}
+	data, _ := json.Marshal(body)
+
+	geminiReq, hasThinking := transformWithFallback(t, data, ThinkingFallbackDisable)
+	if hasThinking || hasThinkingConfig(geminiReq) {
+		t.Errorf("downgraded request still has thinking enabled")
+	}
+	if thoughts, texts := thoughtParts(geminiReq, "Greeting."); thoughts != 0 || texts != 0 {
+		t.Errorf("downgraded request kept thinking content: %d thought parts, %d text parts", thoughts, texts)
+	}
+	if contents := geminiReq["contents"].([]interface{}); len(contents) != 3 {
+		t.Errorf("got %d contents, want the 3 conversation turns", len(contents))
+	}
+}
@@ -0,0 +1,73 @@
+package antigravity
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/adapter/provider"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// withTokenEndpoint points the Google token refresh at handler, with fast retries
+func withTokenEndpoint(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	oldURL, oldDelays := googleTokenURL, provider.RefreshRetryDelays
+	googleTokenURL = srv.URL
+	provider.RefreshRetryDelays = []time.Duration{time.Millisecond, time.Millisecond}
+	t.Cleanup(func() {
+		srv.Close()
+		googleTokenURL, provider.RefreshRetryDelays = oldURL, oldDelays
+	})
+}
+
+func testAdapter() *AntigravityAdapter {
+	return &AntigravityAdapter{
+		provider: &domain.Provider{Config: &domain.ProviderConfig{
+			Antigravity: &domain.ProviderConfigAntigravity{RefreshToken: "refresh"},
+		}},
+		tokenCache: &TokenCache{},
+	}
+}
+
+func TestGetAccessTokenRetriesFlakyRefresh(t *testing.T) {
+	var calls atomic.Int32
+	withTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"ya29.token","expires_in":3600}`))
+	})
+
+	token, err := testAdapter().getAccessToken(context.Background())
+	if err != nil || token != "ya29.token" {
+		t.Fatalf("getAccessToken = %q, %v; want the token after retries", token, err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("token endpoint called %d times, want 3", n)
+	}
+}
+
+func TestGetAccessTokenRevokedRefreshToken(t *testing.T) {
+	var calls atomic.Int32
+	withTokenEndpoint(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`))
+	})
+
+	_, err := testAdapter().getAccessToken(context.Background())
+	var proxyErr *domain.ProxyError
+	if !errors.As(err, &proxyErr) || proxyErr.Retryable || !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("getAccessToken error = %v, want non-retryable invalid credentials", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("token endpoint called %d times, want no retry", n)
+	}
+}
@@ -7,11 +7,12 @@ import (
 )
 
 // buildContents converts Claude messages to Gemini contents
+// toolScope scopes tool signature lookups (see ToolSignatureScopeFromConfig)
 // Reference: Antigravity-Manager's build_contents
 func buildContents(
 	messages []ClaudeMessage,
 	mappedModel string,
-	sessionID string,
+	toolScope string,
 	signatureCache *SignatureCache,
 ) ([]map[string]interface{}, error) {
 	contents := []map[string]interface{}{}
@@ -19,6 +20,7 @@ func buildContents(
 	// State shared across the full conversation (matches Antigravity-Manager)
 	toolIDToName := make(map[string]string)
 	lastThoughtSignature := ""
+	occurrences := toolOccurrences{}
 
 	for _, msg := range messages {
 		parts := []map[string]interface{}{}
@@ -59,7 +61,11 @@ func buildContents(
 					})
 
 				case "tool_use":
-					part := processToolUseBlock(block, lastThoughtSignature, signatureCache)
+					occurrence := occurrences.next(block.ID)
+					if occurrence > 0 {
+						log.Printf("[Antigravity] Tool ID %s reused in conversation (occurrence %d), disambiguating its signature", block.ID, occurrence)
+					}
+					part := processToolUseBlock(block, lastThoughtSignature, signatureCache, toolScope, occurrence)
 					parts = append(parts, part)
 					toolIDToName[block.ID] = block.Name
 
@@ -156,6 +162,8 @@ func processToolUseBlock(
 	block ContentBlock,
 	lastThoughtSignature string,
 	signatureCache *SignatureCache,
+	toolScope string,
+	occurrence int,
 ) map[string]interface{} {
 	// Clean args to remove JSON Schema fields that Gemini doesn't support
 	// Reference: Antigravity-Manager's clean_json_schema call after building functionCall
@@ -185,7 +193,7 @@ func processToolUseBlock(
 		signature = lastThoughtSignature
 	}
 	if signature == "" && signatureCache != nil {
-		signature = signatureCache.GetToolSignature(block.ID)
+		signature = signatureCache.GetToolSignature(toolScope, block.ID, occurrence)
 	}
 	if signature == "" {
 		// Final fallback: global signature store (best-effort)
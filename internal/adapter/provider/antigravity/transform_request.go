@@ -13,8 +13,10 @@ func TransformClaudeToGemini(
 	claudeReqBody []byte,
 	mappedModel string,
 	stream bool,
-	sessionID string,
+	toolScope string,
 	signatureCache *SignatureCache,
+	systemPolicy SystemPromptPolicy,
+	thinkingFallback string,
 ) (geminiReqBody []byte, effectiveMappedModel string, hasThinking bool, err error) {
 	effectiveMappedModel = mappedModel
 
@@ -37,7 +39,8 @@ func TransformClaudeToGemini(
 		effectiveMappedModel = mappedModel
 	}
 
-	// 4. Thinking block pre-filtering
+	// 4. Thinking block pre-filtering, or drop thinking entirely when signatures can't be trusted
+	thinkingDisabled := applyThinkingFallback(&claudeReq, thinkingFallback)
 	filterInvalidThinkingBlocks(&claudeReq.Messages)
 
 	// 5. Tool loop recovery
@@ -48,18 +51,21 @@ func TransformClaudeToGemini(
 
 	// 7. Calculate final thinking mode state (before building request)
 	// Reference: Antigravity-Manager's thinking mode resolution (line 170-251)
-	hasThinking = calculateFinalThinkingState(&claudeReq, mappedModel, signatureCache)
+	// A request downgraded by the fallback stays non-thinking even for thinking-by-default models
+	if !thinkingDisabled {
+		hasThinking = calculateFinalThinkingState(&claudeReq, mappedModel, signatureCache)
+	}
 
 	// 8. Build Gemini request
 	geminiReq := make(map[string]interface{})
 
 	// 7.1 System instruction
-	if systemInstruction := buildSystemInstruction(&claudeReq, mappedModel); systemInstruction != nil {
+	if systemInstruction := buildSystemInstruction(&claudeReq, mappedModel, systemPolicy); systemInstruction != nil {
 		geminiReq["systemInstruction"] = systemInstruction
 	}
 
 	// 7.2 Message contents
-	contents, err := buildContents(claudeReq.Messages, mappedModel, sessionID, signatureCache)
+	contents, err := buildContents(claudeReq.Messages, mappedModel, toolScope, signatureCache)
 	if err != nil {
 		return nil, effectiveMappedModel, hasThinking, fmt.Errorf("failed to build contents: %w", err)
 	}
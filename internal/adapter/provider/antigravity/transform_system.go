@@ -1,68 +1,163 @@
 package antigravity
 
 import (
+	"regexp"
 	"strings"
+
+	"github.com/awsl-project/maxx/internal/domain"
 )
 
-// buildSystemInstruction builds Gemini systemInstruction from Claude system prompt
-// Reference: Antigravity-Manager's build_system_instruction
-func buildSystemInstruction(claudeReq *ClaudeRequest, modelName string) map[string]interface{} {
-	parts := []map[string]interface{}{}
+// System prompt policies, selected by ProviderConfigAntigravity.SystemPromptPolicy
+const (
+	// SystemPromptMerge prepends the Antigravity identity to the client's prompt (default)
+	SystemPromptMerge = "merge"
+	// SystemPromptKeep sends the client's prompt untouched, the identity is only used when the client sent none
+	SystemPromptKeep = "keep"
+	// SystemPromptReplace drops the client's prompt and sends only the identity
+	SystemPromptReplace = "replace"
+)
 
-	// 1. Check if user already provided Antigravity identity
-	userHasAntigravity := false
-	if claudeReq.System != nil {
-		systemText := extractSystemText(claudeReq.System)
-		if strings.Contains(systemText, "You are Antigravity") {
-			userHasAntigravity = true
+// systemPromptEndMarker closes the injected system prompt
+const systemPromptEndMarker = "\n--- [SYSTEM_PROMPT_END] ---"
+
+// problematicInstructions match client system prompt lines known to conflict
+// with the injected identity or its end marker
+var problematicInstructions = []*regexp.Regexp{
+	regexp.MustCompile(`(?m)^.*\[SYSTEM_PROMPT_END\].*$\n?`),
+	regexp.MustCompile(`(?m)^x-anthropic-billing-header:.*$\n?`),
+	regexp.MustCompile(`(?m)^You are Claude Code, Anthropic's official CLI for Claude\.?\s*$\n?`),
+}
+
+// SystemPromptPolicy controls how the Antigravity identity is combined with the client's system prompt
+type SystemPromptPolicy struct {
+	Mode             string
+	StripProblematic bool
+}
+
+// SystemPromptPolicyFromConfig reads the policy from the provider config
+func SystemPromptPolicyFromConfig(config *domain.ProviderConfigAntigravity) SystemPromptPolicy {
+	if config == nil {
+		return SystemPromptPolicy{Mode: SystemPromptMerge}
+	}
+	return SystemPromptPolicy{
+		Mode:             config.SystemPromptPolicy,
+		StripProblematic: config.StripProblematicInstructions,
+	}
+}
+
+// apply combines the client's system prompt texts with the Antigravity identity
+func (p SystemPromptPolicy) apply(texts []string) []string {
+	if p.StripProblematic {
+		texts = stripProblematicInstructions(texts)
+	}
+
+	switch p.Mode {
+	case SystemPromptKeep:
+		if len(texts) > 0 {
+			return texts
+		}
+	case SystemPromptReplace:
+		return []string{AntigravityIdentity, systemPromptEndMarker}
+	}
+
+	// Merge: don't inject twice if the client already provided the identity
+	for _, text := range texts {
+		if strings.Contains(text, "You are Antigravity") {
+			return texts
 		}
 	}
+	result := make([]string, 0, len(texts)+2)
+	result = append(result, AntigravityIdentity)
+	result = append(result, texts...)
+	return append(result, systemPromptEndMarker)
+}
 
-	// 2. Inject Antigravity Identity (if user hasn't provided it)
-	if !userHasAntigravity {
-		parts = append(parts, map[string]interface{}{
-			"text": AntigravityIdentity,
-		})
+// stripProblematicInstructions removes known-problematic lines and drops texts left empty
+func stripProblematicInstructions(texts []string) []string {
+	result := make([]string, 0, len(texts))
+	for _, text := range texts {
+		for _, re := range problematicInstructions {
+			text = re.ReplaceAllString(text, "")
+		}
+		if strings.TrimSpace(text) != "" {
+			result = append(result, text)
+		}
 	}
+	return result
+}
 
-	// 3. Add user's system prompt
+// buildSystemInstruction builds Gemini systemInstruction from Claude system prompt
+// Reference: Antigravity-Manager's build_system_instruction
+func buildSystemInstruction(claudeReq *ClaudeRequest, modelName string, policy SystemPromptPolicy) map[string]interface{} {
+	// Collect user's system prompt
+	var texts []string
 	if claudeReq.System != nil {
 		switch sys := claudeReq.System.(type) {
 		case string:
 			if sys != "" {
-				parts = append(parts, map[string]interface{}{
-					"text": sys,
-				})
+				texts = append(texts, sys)
 			}
 		case []interface{}:
 			for _, block := range sys {
 				if blockMap, ok := block.(map[string]interface{}); ok {
 					if text, ok := blockMap["text"].(string); ok && text != "" {
-						parts = append(parts, map[string]interface{}{
-							"text": text,
-						})
+						texts = append(texts, text)
 					}
 				}
 			}
 		}
 	}
 
-	// 4. Add end marker (if we injected Antigravity identity)
+	// Inject Antigravity identity and end marker according to the policy
 	// Reference: Antigravity-Manager line 488-491
-	if !userHasAntigravity {
-		parts = append(parts, map[string]interface{}{
-			"text": "\n--- [SYSTEM_PROMPT_END] ---",
-		})
-	}
-
-	if len(parts) == 0 {
+	texts = policy.apply(texts)
+	if len(texts) == 0 {
 		return nil
 	}
 
+	return newSystemInstruction(texts)
+}
+
+// systemInstructionRole is the role v1internal expects on systemInstruction.
+// Gemini's schema leaves it optional, but the Antigravity client always sends
+// "user" and the endpoint rejects the "system" and "model" roles there.
+const systemInstructionRole = "user"
+
+// newSystemInstruction builds a systemInstruction in the shape v1internal accepts:
+// the user role and one text part per text
+func newSystemInstruction(texts []string) map[string]interface{} {
 	return map[string]interface{}{
-		"role":  "user",
-		"parts": parts,
+		"role":  systemInstructionRole,
+		"parts": textParts(texts),
+	}
+}
+
+func textParts(texts []string) []interface{} {
+	parts := make([]interface{}, 0, len(texts))
+	for _, text := range texts {
+		parts = append(parts, map[string]interface{}{"text": text})
+	}
+	return parts
+}
+
+// normalizeSystemInstruction brings the request's existing systemInstruction to
+// the shape v1internal accepts: the user role and a list of parts, a single part
+// object being wrapped in one. Returns whether anything changed.
+func normalizeSystemInstruction(request map[string]interface{}) bool {
+	sysInst, ok := request["systemInstruction"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	modified := false
+	if role, _ := sysInst["role"].(string); role != systemInstructionRole {
+		sysInst["role"] = systemInstructionRole
+		modified = true
+	}
+	if part, ok := sysInst["parts"].(map[string]interface{}); ok {
+		sysInst["parts"] = []interface{}{part}
+		modified = true
 	}
+	return modified
 }
 
 // AntigravityIdentity is the system identity injected into all requests
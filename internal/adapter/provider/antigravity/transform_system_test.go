@@ -0,0 +1,240 @@
+package antigravity
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func systemTexts(t *testing.T, sysInst map[string]interface{}) []string {
+	t.Helper()
+	if sysInst == nil {
+		return nil
+	}
+	var texts []string
+	for _, part := range sysInst["parts"].([]interface{}) {
+		texts = append(texts, part.(map[string]interface{})["text"].(string))
+	}
+	return texts
+}
+
+func TestBuildSystemInstructionPolicies(t *testing.T) {
+	const clientPrompt = "You are a helpful assistant."
+	const clientIdentity = "You are Antigravity, but customised."
+
+	tests := []struct {
+		name   string
+		policy SystemPromptPolicy
+		system interface{}
+		want   []string
+	}{
+		{"merge", SystemPromptPolicy{Mode: SystemPromptMerge}, clientPrompt,
+			[]string{AntigravityIdentity, clientPrompt, systemPromptEndMarker}},
+		{"merge with identity", SystemPromptPolicy{Mode: SystemPromptMerge}, clientIdentity,
+			[]string{clientIdentity}},
+		{"default is merge", SystemPromptPolicy{}, clientPrompt,
+			[]string{AntigravityIdentity, clientPrompt, systemPromptEndMarker}},
+		{"merge without client prompt", SystemPromptPolicy{}, nil,
+			[]string{AntigravityIdentity, systemPromptEndMarker}},
+		{"keep", SystemPromptPolicy{Mode: SystemPromptKeep}, clientPrompt,
+			[]string{clientPrompt}},
+		{"keep with identity", SystemPromptPolicy{Mode: SystemPromptKeep}, clientIdentity,
+			[]string{clientIdentity}},
+		{"keep without client prompt", SystemPromptPolicy{Mode: SystemPromptKeep}, nil,
+			[]string{AntigravityIdentity, systemPromptEndMarker}},
+		{"replace", SystemPromptPolicy{Mode: SystemPromptReplace}, clientPrompt,
+			[]string{AntigravityIdentity, systemPromptEndMarker}},
+		{"replace with identity", SystemPromptPolicy{Mode: SystemPromptReplace}, clientIdentity,
+			[]string{AntigravityIdentity, systemPromptEndMarker}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := systemTexts(t, buildSystemInstruction(&ClaudeRequest{System: tt.system}, "gemini-2.5-pro", tt.policy))
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("parts = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildSystemInstructionStripsProblematicInstructions(t *testing.T) {
+	system := []interface{}{
+		map[string]interface{}{"type": "text", "text": "x-anthropic-billing-header: cc_version=2.0"},
+		map[string]interface{}{"type": "text", "text": "You are Claude Code, Anthropic's official CLI for Claude.\nBe concise.\n--- [SYSTEM_PROMPT_END] ---"},
+	}
+
+	policy := SystemPromptPolicy{Mode: SystemPromptMerge, StripProblematic: true}
+	got := systemTexts(t, buildSystemInstruction(&ClaudeRequest{System: system}, "gemini-2.5-pro", policy))
+	if len(got) != 3 || got[0] != AntigravityIdentity || got[2] != systemPromptEndMarker {
+		t.Fatalf("parts = %q, want identity, stripped prompt and end marker", got)
+	}
+	if strings.TrimSpace(got[1]) != "Be concise." {
+		t.Errorf("stripped client prompt = %q, want %q", got[1], "Be concise.")
+	}
+
+	// Without the option the client's text is kept verbatim
+	got = systemTexts(t, buildSystemInstruction(&ClaudeRequest{System: system}, "gemini-2.5-pro", SystemPromptPolicy{}))
+	if len(got) != 4 {
+		t.Errorf("unstripped parts = %d, want 4", len(got))
+	}
+}
+
+func TestInjectAntigravityIdentityPolicies(t *testing.T) {
+	request := func(texts ...string) map[string]interface{} {
+		parts := make([]interface{}, 0, len(texts))
+		for _, text := range texts {
+			parts = append(parts, map[string]interface{}{"text": text})
+		}
+		return map[string]interface{}{
+			"systemInstruction": map[string]interface{}{"role": "user", "parts": parts},
+		}
+	}
+	partsOf := func(req map[string]interface{}) []string {
+		var texts []string
+		for _, part := range req["systemInstruction"].(map[string]interface{})["parts"].([]interface{}) {
+			texts = append(texts, part.(map[string]interface{})["text"].(string))
+		}
+		return texts
+	}
+
+	tests := []struct {
+		name     string
+		policy   SystemPromptPolicy
+		texts    []string
+		modified bool
+		want     []string
+	}{
+		{"merge", SystemPromptPolicy{}, []string{"client"}, true, []string{AntigravityIdentity, "client", systemPromptEndMarker}},
+		{"merge with identity", SystemPromptPolicy{}, []string{"You are Antigravity."}, false, []string{"You are Antigravity."}},
+		{"keep", SystemPromptPolicy{Mode: SystemPromptKeep}, []string{"client"}, false, []string{"client"}},
+		{"keep with identity", SystemPromptPolicy{Mode: SystemPromptKeep}, []string{"You are Antigravity."}, false, []string{"You are Antigravity."}},
+		{"replace", SystemPromptPolicy{Mode: SystemPromptReplace}, []string{"client"}, true, []string{AntigravityIdentity, systemPromptEndMarker}},
+		{"replace with identity", SystemPromptPolicy{Mode: SystemPromptReplace}, []string{"You are Antigravity."}, true, []string{AntigravityIdentity, systemPromptEndMarker}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request(tt.texts...)
+			if modified := injectAntigravityIdentity(req, tt.policy); modified != tt.modified {
+				t.Errorf("modified = %v, want %v", modified, tt.modified)
+			}
+			if got := partsOf(req); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("parts = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// A request without system instruction gets the identity under every policy
+	for _, mode := range []string{SystemPromptMerge, SystemPromptKeep, SystemPromptReplace} {
+		req := map[string]interface{}{}
+		if !injectAntigravityIdentity(req, SystemPromptPolicy{Mode: mode}) {
+			t.Errorf("%s: request without system instruction was not modified", mode)
+			continue
+		}
+		if got := partsOf(req); len(got) != 2 || got[0] != AntigravityIdentity {
+			t.Errorf("%s: parts = %q, want identity and end marker", mode, got)
+		}
+	}
+}
+
+// checkSystemInstruction fails unless the request's systemInstruction has the
+// shape v1internal accepts: role "user" and a non-empty list of text parts
+func checkSystemInstruction(t *testing.T, request map[string]interface{}) {
+	t.Helper()
+	body, err := json.Marshal(request["systemInstruction"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sysInst struct {
+		Role  string `json:"role"`
+		Parts []struct {
+			Text string `json:"text"`
+		} `json:"parts"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&sysInst); err != nil {
+		t.Fatalf("systemInstruction %s: %v", body, err)
+	}
+	if sysInst.Role != "user" || len(sysInst.Parts) == 0 {
+		t.Fatalf("systemInstruction %s: want role user and parts", body)
+	}
+	for _, part := range sysInst.Parts {
+		if part.Text == "" {
+			t.Fatalf("systemInstruction %s: part without text", body)
+		}
+	}
+}
+
+func TestSystemInstructionShape(t *testing.T) {
+	for _, mode := range []string{SystemPromptMerge, SystemPromptKeep, SystemPromptReplace} {
+		sysInst := buildSystemInstruction(&ClaudeRequest{System: "client"}, "gemini-2.5-pro", SystemPromptPolicy{Mode: mode})
+		checkSystemInstruction(t, map[string]interface{}{"systemInstruction": sysInst})
+	}
+
+	existing := map[string]map[string]interface{}{
+		"none":          {},
+		"user role":     {"systemInstruction": map[string]interface{}{"role": "user", "parts": []interface{}{map[string]interface{}{"text": "client"}}}},
+		"system role":   {"systemInstruction": map[string]interface{}{"role": "system", "parts": []interface{}{map[string]interface{}{"text": "client"}}}},
+		"no role":       {"systemInstruction": map[string]interface{}{"parts": []interface{}{map[string]interface{}{"text": "client"}}}},
+		"single part":   {"systemInstruction": map[string]interface{}{"parts": map[string]interface{}{"text": "client"}}},
+		"with identity": {"systemInstruction": map[string]interface{}{"role": "model", "parts": []interface{}{map[string]interface{}{"text": "You are Antigravity."}}}},
+	}
+	for name, request := range existing {
+		t.Run(name, func(t *testing.T) {
+			for _, mode := range []string{SystemPromptMerge, SystemPromptKeep, SystemPromptReplace} {
+				req := cloneRequest(t, request)
+				injectAntigravityIdentity(req, SystemPromptPolicy{Mode: mode})
+				checkSystemInstruction(t, req)
+			}
+
+			req := cloneRequest(t, request)
+			if !injectInterleavedHint(req) {
+				t.Fatal("interleaved hint not injected")
+			}
+			checkSystemInstruction(t, req)
+			if _, ok := request["systemInstruction"]; ok {
+				// The client's prompt is kept ahead of the hint
+				if texts := systemTexts(t, req["systemInstruction"].(map[string]interface{})); len(texts) != 2 {
+					t.Errorf("parts = %q, want the client's prompt and the hint", texts)
+				}
+			}
+		})
+	}
+
+	// A keep policy with a correct instruction leaves the request untouched,
+	// a wrong role alone is reported as a modification
+	req := cloneRequest(t, existing["user role"])
+	if injectAntigravityIdentity(req, SystemPromptPolicy{Mode: SystemPromptKeep}) {
+		t.Error("well-formed systemInstruction reported as modified")
+	}
+	req = cloneRequest(t, existing["system role"])
+	if !injectAntigravityIdentity(req, SystemPromptPolicy{Mode: SystemPromptKeep}) {
+		t.Error("role correction not reported as modified")
+	}
+
+	// The whole post-processing pipeline, with tools and thinking enabled
+	body := []byte(`{"systemInstruction":{"role":"system","parts":{"text":"client"}},` +
+		`"contents":[{"role":"user","parts":[{"text":"hi"}]}],` +
+		`"tools":[{"functionDeclarations":[{"name":"read","parameters":{"type":"object"}}]}]}`)
+	var processed map[string]interface{}
+	if err := json.Unmarshal(PostProcessClaudeRequest(body, "", true, nil, "gemini-2.5-pro", SystemPromptPolicy{}), &processed); err != nil {
+		t.Fatal(err)
+	}
+	checkSystemInstruction(t, processed)
+}
+
+func cloneRequest(t *testing.T, request map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	body, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var clone map[string]interface{}
+	if err := json.Unmarshal(body, &clone); err != nil {
+		t.Fatal(err)
+	}
+	return clone
+}
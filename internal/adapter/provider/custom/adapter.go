@@ -23,15 +23,25 @@ func init() {
 }
 
 type CustomAdapter struct {
-	provider *domain.Provider
+	provider   *domain.Provider
+	httpClient *http.Client
 }
 
 func NewAdapter(p *domain.Provider) (provider.ProviderAdapter, error) {
 	if p.Config == nil || p.Config.Custom == nil {
 		return nil, fmt.Errorf("provider %s missing custom config", p.Name)
 	}
+	if err := validateSigning(p.Config.Custom.Signing); err != nil {
+		return nil, fmt.Errorf("provider %s: %w", p.Name, err)
+	}
 	return &CustomAdapter{
 		provider: p,
+		httpClient: &http.Client{
+			Transport: provider.NewPooledTransport(p, func() *http.Transport {
+				return http.DefaultTransport.(*http.Transport).Clone()
+			}),
+			Timeout: 10 * time.Minute, // Long timeout for LLM requests
+		},
 	}, nil
 }
 
@@ -73,7 +83,17 @@ func (a *CustomAdapter) Execute(ctx context.Context, w http.ResponseWriter, req
 	upstreamReq.Header = originalHeaders
 
 	// Override auth headers with provider's credentials
-	if a.provider.Config.Custom.APIKey != "" {
+	if signing := a.provider.Config.Custom.Signing; signing != nil {
+		// Signed upstreams authenticate by signature only, drop the client's credentials
+		header := http.Header{}
+		for k, v := range upstreamReq.Header {
+			header[k] = v
+		}
+		upstreamReq.Header = header
+		upstreamReq.Header.Del("x-api-key")
+		upstreamReq.Header.Del("x-goog-api-key")
+		signRequestV4(upstreamReq, requestBody, signing, time.Now())
+	} else if a.provider.Config.Custom.APIKey != "" {
 		setAuthHeader(upstreamReq, clientType, a.provider.Config.Custom.APIKey)
 	}
 
@@ -87,11 +107,7 @@ func (a *CustomAdapter) Execute(ctx context.Context, w http.ResponseWriter, req
 		})
 	}
 
-	// Execute request with reasonable timeout
-	client := &http.Client{
-		Timeout: 10 * time.Minute, // Long timeout for LLM requests
-	}
-	resp, err := client.Do(upstreamReq)
+	resp, err := a.httpClient.Do(upstreamReq)
 	if err != nil {
 		proxyErr := domain.NewProxyErrorWithMessage(domain.ErrUpstreamError, true, "failed to connect to upstream")
 		proxyErr.IsNetworkError = true
@@ -132,6 +148,11 @@ func (a *CustomAdapter) Execute(ctx context.Context, w http.ResponseWriter, req
 		return proxyErr
 	}
 
+	// Upstreams speaking AWS EventStream are decoded before the usual handling
+	if a.isEventStreamResponse(resp) {
+		return a.handleEventStreamResponse(ctx, w, resp, clientType, stream)
+	}
+
 	// Handle response
 	// Note: Response format conversion is handled by Executor's ConvertingResponseWriter
 	// Adapters simply pass through the upstream response
@@ -205,6 +226,11 @@ func (a *CustomAdapter) handleNonStreamResponse(ctx context.Context, w http.Resp
 func (a *CustomAdapter) handleStreamResponse(ctx context.Context, w http.ResponseWriter, resp *http.Response, clientType domain.ClientType) error {
 	eventChan := ctxutil.GetEventChan(ctx)
 
+	// Abort the upstream as soon as the client goes away
+	guard := provider.GuardStream(ctx, resp.Body)
+	defer guard.Stop()
+	w = guard.Writer(w)
+
 	// Send initial response info (for streaming, we only capture status and headers)
 	eventChan.SendResponseInfo(&domain.ResponseInfo{
 		Status:  resp.StatusCode,
@@ -238,22 +264,26 @@ func (a *CustomAdapter) handleStreamResponse(ctx context.Context, w http.Respons
 	// Note: Response format conversion is handled by Executor's ConvertingResponseWriter
 	// Adapter simply passes through the upstream SSE data
 
-	// Collect all SSE events for response body and token extraction
-	var sseBuffer strings.Builder
+	// Collect all SSE events for response body and token extraction,
+	// large responses spill to a temp file
+	sseBuffer := provider.NewSpillBuffer()
+	defer sseBuffer.Close()
 	var sseError error // Track any SSE error event
 
 	// Helper to send final events via EventChannel
 	sendFinalEvents := func() {
 		if sseBuffer.Len() > 0 {
+			body := sseBuffer.String()
+
 			// Send updated response body
 			eventChan.SendResponseInfo(&domain.ResponseInfo{
 				Status:  resp.StatusCode,
 				Headers: flattenHeaders(resp.Header),
-				Body:    sseBuffer.String(),
+				Body:    body,
 			})
 
 			// Extract and send token usage
-			if metrics := usage.ExtractFromStreamContent(sseBuffer.String()); metrics != nil {
+			if metrics := usage.ExtractFromStreamContent(body); metrics != nil {
 				// Adjust for client-specific quirks (e.g., Codex input_tokens includes cached tokens)
 				metrics = usage.AdjustForClientType(metrics, clientType)
 				eventChan.SendMetrics(&domain.AdapterMetrics{
@@ -267,7 +297,7 @@ func (a *CustomAdapter) handleStreamResponse(ctx context.Context, w http.Respons
 			}
 
 			// Extract and send responseModel
-			if responseModel := extractResponseModelFromSSE(sseBuffer.String(), clientType); responseModel != "" {
+			if responseModel := extractResponseModelFromSSE(body, clientType); responseModel != "" {
 				eventChan.SendResponseModel(responseModel)
 			}
 		}
@@ -545,6 +575,10 @@ func copyHeadersFiltered(dst, src http.Header) {
 		if filteredHeaders[lowerKey] {
 			continue
 		}
+		// maxx control headers (X-Maxx-*) are internal and never forwarded upstream
+		if strings.HasPrefix(lowerKey, "x-maxx-") {
+			continue
+		}
 		for _, v := range values {
 			dst.Add(key, v)
 		}
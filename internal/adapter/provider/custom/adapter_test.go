@@ -0,0 +1,138 @@
+package custom
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ctxutil "github.com/awsl-project/maxx/internal/context"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// cancelOnWrite cancels the client context after the first chunk reaches the client
+type cancelOnWrite struct {
+	*httptest.ResponseRecorder
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnWrite) Write(b []byte) (int, error) {
+	n, err := c.ResponseRecorder.Write(b)
+	c.cancel()
+	return n, err
+}
+
+func TestStreamClientCancelAbortsUpstream(t *testing.T) {
+	const totalChunks = 200
+	upstreamCancelled := make(chan struct{})
+	sent := make(chan int, 1)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for i := 0; i < totalChunks; i++ {
+			select {
+			case <-r.Context().Done():
+				sent <- i
+				close(upstreamCancelled)
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+			fmt.Fprintf(w, "data: {\"type\":\"ping\",\"n\":%d}\n\n", i)
+			flusher.Flush()
+		}
+		sent <- totalChunks
+	}))
+	defer upstream.Close()
+
+	p := &domain.Provider{
+		Name:   "upstream",
+		Type:   "custom",
+		Config: &domain.ProviderConfig{Custom: &domain.ProviderConfigCustom{BaseURL: upstream.URL}},
+	}
+	adapter, err := NewAdapter(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx := ctxutil.WithClientType(clientCtx, domain.ClientTypeClaude)
+	ctx = ctxutil.WithRequestBody(ctx, []byte(`{"model":"m","stream":true}`))
+	ctx = ctxutil.WithRequestURI(ctx, "/v1/messages")
+	ctx = ctxutil.WithRequestHeaders(ctx, http.Header{})
+	ctx = ctxutil.WithEventChan(ctx, domain.NewAdapterEventChan())
+
+	w := &cancelOnWrite{ResponseRecorder: httptest.NewRecorder(), cancel: cancel}
+	done := make(chan error, 1)
+	go func() {
+		done <- adapter.Execute(ctx, w, httptest.NewRequest(http.MethodPost, "/v1/messages", nil), p)
+	}()
+
+	select {
+	case <-upstreamCancelled:
+	case <-time.After(time.Second):
+		t.Fatal("upstream request was not cancelled after the client disconnected")
+	}
+	if n := <-sent; n >= totalChunks {
+		t.Errorf("upstream streamed all %d chunks, want it stopped early", n)
+	}
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Execute returned nil, want client disconnected error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Execute did not return after the client disconnected")
+	}
+}
+
+func TestGeminiMappedModelInPath(t *testing.T) {
+	tests := []struct {
+		name        string
+		requestURI  string
+		mappedModel string
+		wantURI     string
+	}{
+		{"mapped", "/v1beta/models/gemini-pro:generateContent", "gemini-2.5-pro", "/v1beta/models/gemini-2.5-pro:generateContent"},
+		{"stream query kept", "/v1beta/models/gemini-pro:streamGenerateContent?alt=sse", "gemini-2.5-flash", "/v1beta/models/gemini-2.5-flash:streamGenerateContent?alt=sse"},
+		{"unmapped passthrough", "/v1beta/models/gemini-pro:generateContent", "gemini-pro", "/v1beta/models/gemini-pro:generateContent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotURI := make(chan string, 1)
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotURI <- r.URL.RequestURI()
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, `{"candidates":[]}`)
+			}))
+			defer upstream.Close()
+
+			p := &domain.Provider{
+				Name:   "gemini",
+				Type:   "custom",
+				Config: &domain.ProviderConfig{Custom: &domain.ProviderConfigCustom{BaseURL: upstream.URL}},
+			}
+			adapter, err := NewAdapter(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := ctxutil.WithClientType(context.Background(), domain.ClientTypeGemini)
+			ctx = ctxutil.WithMappedModel(ctx, tt.mappedModel)
+			ctx = ctxutil.WithRequestBody(ctx, []byte(`{"contents":[]}`))
+			ctx = ctxutil.WithRequestURI(ctx, tt.requestURI)
+			ctx = ctxutil.WithRequestHeaders(ctx, http.Header{})
+			ctx = ctxutil.WithEventChan(ctx, domain.NewAdapterEventChan())
+
+			if err := adapter.Execute(ctx, httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, tt.requestURI, nil), p); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if got := <-gotURI; got != tt.wantURI {
+				t.Errorf("upstream URI = %q, want %q", got, tt.wantURI)
+			}
+		})
+	}
+}
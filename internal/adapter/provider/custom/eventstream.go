@@ -0,0 +1,91 @@
+package custom
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/awsl-project/maxx/internal/adapter/provider/eventstream"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// isEventStreamResponse reports whether a successful response must be decoded from
+// AWS EventStream. Upstreams opted into EventStream may still answer with plain
+// JSON or SSE, e.g. Bedrock's non-streaming invoke, those pass through as usual.
+func (a *CustomAdapter) isEventStreamResponse(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == eventstream.ContentType {
+		return a.provider.Config.Custom.EventStream
+	}
+	return a.provider.Config.Custom.EventStream && mediaType != "application/json" && mediaType != "text/event-stream"
+}
+
+// eventStreamBody transcodes the EventStream body to SSE while it is read
+type eventStreamBody struct {
+	*eventstream.SSEReader
+	io.Closer
+}
+
+// handleEventStreamResponse converts an EventStream response to the client's expected
+// format: SSE events for streaming requests, the single event's JSON document otherwise
+func (a *CustomAdapter) handleEventStreamResponse(ctx context.Context, w http.ResponseWriter, resp *http.Response, clientType domain.ClientType, stream bool) error {
+	resp.Header = resp.Header.Clone()
+	resp.Header.Del("Content-Length")
+
+	if stream {
+		resp.Body = eventStreamBody{SSEReader: eventstream.NewSSEReader(resp.Body), Closer: resp.Body}
+		resp.Header.Set("Content-Type", "text/event-stream")
+		return a.handleStreamResponse(ctx, w, resp, clientType)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return domain.NewProxyErrorWithMessage(domain.ErrUpstreamError, true, "failed to read upstream response")
+	}
+	messages, _ := eventstream.NewDecoder().ParseStream(data)
+
+	var payloads [][]byte
+	for _, msg := range messages {
+		if errData := eventstream.ErrorPayload(msg); errData != nil {
+			return eventStreamError(errData)
+		}
+		if payload := bytes.TrimSpace(eventstream.Payload(msg)); len(payload) > 0 {
+			payloads = append(payloads, payload)
+		}
+	}
+	if len(payloads) != 1 {
+		return domain.NewProxyErrorWithMessage(
+			fmt.Errorf("expected one EventStream event for a non-streaming request, got %d", len(payloads)),
+			false,
+			"unexpected EventStream response",
+		)
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(payloads[0]))
+	resp.Header.Set("Content-Type", "application/json")
+	return a.handleNonStreamResponse(ctx, w, resp, clientType)
+}
+
+// eventStreamError converts an EventStream exception to a proxy error
+func eventStreamError(data []byte) error {
+	var payload struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+			Code    int    `json:"code"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(data, &payload)
+	proxyErr := domain.NewProxyErrorWithMessage(
+		fmt.Errorf("upstream error: %s", string(data)),
+		isRetryableSSEError(payload.Error.Code, payload.Error.Type, payload.Error.Message),
+		payload.Error.Message,
+	)
+	proxyErr.HTTPStatusCode = payload.Error.Code
+	proxyErr.IsServerError = payload.Error.Code >= 500
+	return proxyErr
+}
@@ -0,0 +1,109 @@
+package custom
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/adapter/provider/eventstream"
+	ctxutil "github.com/awsl-project/maxx/internal/context"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// bedrockChunk encodes a Claude event the way Bedrock's response stream carries it
+func bedrockChunk(event string) []byte {
+	payload := `{"bytes":"` + base64.StdEncoding.EncodeToString([]byte(event)) + `"}`
+	return eventstream.Encode(map[string]string{
+		":message-type": "event",
+		":event-type":   "chunk",
+		":content-type": "application/json",
+	}, []byte(payload))
+}
+
+func executeEventStream(t *testing.T, body []byte, requestBody string) (*httptest.ResponseRecorder, error) {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", eventstream.ContentType)
+		w.Write(body)
+	}))
+	defer upstream.Close()
+
+	p := &domain.Provider{
+		Name: "bedrock",
+		Type: "custom",
+		Config: &domain.ProviderConfig{Custom: &domain.ProviderConfigCustom{
+			BaseURL:     upstream.URL,
+			EventStream: true,
+		}},
+	}
+	adapter, err := NewAdapter(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := ctxutil.WithClientType(context.Background(), domain.ClientTypeClaude)
+	ctx = ctxutil.WithRequestBody(ctx, []byte(requestBody))
+	ctx = ctxutil.WithRequestURI(ctx, "/v1/messages")
+	ctx = ctxutil.WithRequestHeaders(ctx, http.Header{})
+	ctx = ctxutil.WithEventChan(ctx, domain.NewAdapterEventChan())
+
+	w := httptest.NewRecorder()
+	err = adapter.Execute(ctx, w, httptest.NewRequest(http.MethodPost, "/v1/messages", nil), p)
+	return w, err
+}
+
+func TestEventStreamTranscodedToSSE(t *testing.T) {
+	var body bytes.Buffer
+	body.Write(bedrockChunk(`{"type":"message_start","message":{"id":"msg_1","model":"claude"}}`))
+	body.Write(bedrockChunk(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hi"}}`))
+	body.Write(bedrockChunk(`{"type":"message_stop"}`))
+
+	w, err := executeEventStream(t, body.Bytes(), `{"model":"claude","stream":true}`)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	got := w.Body.String()
+	for _, want := range []string{
+		"event: message_start\ndata: {\"type\":\"message_start\"",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hi\"}}\n\n",
+		"event: message_stop\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("stream lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestEventStreamExceptionMidStream(t *testing.T) {
+	var body bytes.Buffer
+	body.Write(bedrockChunk(`{"type":"message_start","message":{"id":"msg_1"}}`))
+	body.Write(eventstream.Encode(map[string]string{
+		":message-type":   "exception",
+		":exception-type": "throttlingException",
+	}, []byte(`{"message":"Too many requests, please wait"}`)))
+
+	_, err := executeEventStream(t, body.Bytes(), `{"model":"claude","stream":true}`)
+	var proxyErr *domain.ProxyError
+	if !errors.As(err, &proxyErr) || !proxyErr.Retryable {
+		t.Errorf("Execute() error = %v, want a retryable proxy error", err)
+	}
+}
+
+func TestEventStreamNonStreaming(t *testing.T) {
+	message := `{"id":"msg_1","type":"message","content":[{"type":"text","text":"hi"}]}`
+	w, err := executeEventStream(t, bedrockChunk(message), `{"model":"claude"}`)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if w.Body.String() != message || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("response = %q (%s), want the event's JSON", w.Body.String(), w.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,113 @@
+package custom
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/adapter/provider"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// anthropicVersion is sent on model list requests to Claude-compatible upstreams
+const anthropicVersion = "2023-06-01"
+
+// ListModels asks the upstream for its model list, using the first supported client
+// type with a list endpoint: GET /v1/models for Claude and OpenAI, /v1beta/models for Gemini
+func (a *CustomAdapter) ListModels(ctx context.Context) ([]domain.ModelInfo, error) {
+	for _, clientType := range a.provider.SupportedClientTypes {
+		switch clientType {
+		case domain.ClientTypeClaude, domain.ClientTypeOpenAI, domain.ClientTypeGemini:
+			return a.listModels(ctx, clientType)
+		}
+	}
+	return nil, provider.ErrModelListNotSupported
+}
+
+func (a *CustomAdapter) listModels(ctx context.Context, clientType domain.ClientType) ([]domain.ModelInfo, error) {
+	path := "/v1/models"
+	if clientType == domain.ClientTypeGemini {
+		path = "/v1beta/models"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, buildUpstreamURL(a.getBaseURL(clientType), path), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	config := a.provider.Config.Custom
+	if config.Signing != nil {
+		signRequestV4(req, nil, config.Signing, time.Now())
+	} else if config.APIKey != "" {
+		switch clientType {
+		case domain.ClientTypeClaude:
+			req.Header.Set("x-api-key", config.APIKey)
+		case domain.ClientTypeGemini:
+			req.Header.Set("x-goog-api-key", config.APIKey)
+		default:
+			req.Header.Set("Authorization", "Bearer "+config.APIKey)
+		}
+	}
+	if clientType == domain.ClientTypeClaude {
+		req.Header.Set("anthropic-version", anthropicVersion)
+	}
+
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return parseModelList(body, clientType)
+}
+
+// parseModelList reads the model list response of a Claude, OpenAI or Gemini upstream.
+// OpenAI-compatible aggregators often add context_length, it is kept when present.
+func parseModelList(body []byte, clientType domain.ClientType) ([]domain.ModelInfo, error) {
+	if clientType == domain.ClientTypeGemini {
+		var list struct {
+			Models []struct {
+				Name             string `json:"name"`
+				InputTokenLimit  int    `json:"inputTokenLimit"`
+				OutputTokenLimit int    `json:"outputTokenLimit"`
+			} `json:"models"`
+		}
+		if err := json.Unmarshal(body, &list); err != nil {
+			return nil, fmt.Errorf("invalid model list: %w", err)
+		}
+		models := make([]domain.ModelInfo, 0, len(list.Models))
+		for _, m := range list.Models {
+			models = append(models, domain.ModelInfo{
+				ID:              strings.TrimPrefix(m.Name, "models/"),
+				ContextWindow:   m.InputTokenLimit,
+				MaxOutputTokens: m.OutputTokenLimit,
+			})
+		}
+		return models, nil
+	}
+
+	var list struct {
+		Data []struct {
+			ID            string `json:"id"`
+			ContextLength int    `json:"context_length"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("invalid model list: %w", err)
+	}
+	models := make([]domain.ModelInfo, 0, len(list.Data))
+	for _, m := range list.Data {
+		models = append(models, domain.ModelInfo{ID: m.ID, ContextWindow: m.ContextLength})
+	}
+	return models, nil
+}
@@ -0,0 +1,58 @@
+package custom
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestListModels(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/models" && r.Header.Get("x-api-key") == "sk-test" && r.Header.Get("anthropic-version") != "":
+			w.Write([]byte(`{"data":[{"id":"claude-sonnet-4-5","display_name":"Claude Sonnet 4.5"}]}`))
+		case r.URL.Path == "/v1/models" && r.Header.Get("Authorization") == "Bearer sk-test":
+			w.Write([]byte(`{"object":"list","data":[{"id":"gpt-4o","context_length":128000}]}`))
+		case r.URL.Path == "/v1beta/models" && r.Header.Get("x-goog-api-key") == "sk-test":
+			w.Write([]byte(`{"models":[{"name":"models/gemini-2.5-flash","inputTokenLimit":1048576,"outputTokenLimit":65536}]}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		clientType domain.ClientType
+		want       domain.ModelInfo
+	}{
+		{domain.ClientTypeClaude, domain.ModelInfo{ID: "claude-sonnet-4-5"}},
+		{domain.ClientTypeOpenAI, domain.ModelInfo{ID: "gpt-4o", ContextWindow: 128000}},
+		{domain.ClientTypeGemini, domain.ModelInfo{ID: "gemini-2.5-flash", ContextWindow: 1048576, MaxOutputTokens: 65536}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.clientType), func(t *testing.T) {
+			a := &CustomAdapter{provider: &domain.Provider{
+				SupportedClientTypes: []domain.ClientType{tt.clientType},
+				Config:               &domain.ProviderConfig{Custom: &domain.ProviderConfigCustom{BaseURL: upstream.URL, APIKey: "sk-test"}},
+			}}
+			models, err := a.ListModels(context.Background())
+			if err != nil {
+				t.Fatalf("ListModels failed: %v", err)
+			}
+			if len(models) != 1 || models[0] != tt.want {
+				t.Errorf("ListModels = %+v, want %+v", models, tt.want)
+			}
+		})
+	}
+
+	a := &CustomAdapter{provider: &domain.Provider{
+		SupportedClientTypes: []domain.ClientType{domain.ClientTypeOpenAI},
+		Config:               &domain.ProviderConfig{Custom: &domain.ProviderConfigCustom{BaseURL: upstream.URL, APIKey: "wrong"}},
+	}}
+	if _, err := a.ListModels(context.Background()); err == nil {
+		t.Error("rejected request returned no error")
+	}
+}
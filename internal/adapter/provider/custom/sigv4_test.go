@@ -0,0 +1,144 @@
+package custom
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	ctxutil "github.com/awsl-project/maxx/internal/context"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// Credentials and expected signatures from the AWS SigV4 test suite
+var testSigning = &domain.ProviderConfigCustomSigning{
+	Mode:            domain.CustomSigningSigV4,
+	Region:          "us-east-1",
+	Service:         "service",
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+func TestSignRequestV4KnownVectors(t *testing.T) {
+	signedAt := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		method    string
+		signature string
+	}{
+		{"get-vanilla", http.MethodGet, "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"post-vanilla", http.MethodPost, "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "https://example.amazonaws.com/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signRequestV4(req, nil, testSigning, signedAt)
+
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date = %q", got)
+			}
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=host;x-amz-date, Signature=" + tt.signature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestSignRequestV4SessionToken(t *testing.T) {
+	signing := *testSigning
+	signing.SessionToken = "session"
+
+	req, _ := http.NewRequest(http.MethodPost, "https://example.amazonaws.com/model/a:0/invoke?b=2&a=1", strings.NewReader("{}"))
+	signRequestV4(req, []byte("{}"), &signing, time.Now())
+
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "session" {
+		t.Errorf("X-Amz-Security-Token = %q", got)
+	}
+	if auth := req.Header.Get("Authorization"); !strings.Contains(auth, "SignedHeaders=host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("Authorization = %q, want the session token signed", auth)
+	}
+}
+
+func TestSigV4CanonicalPathAndQuery(t *testing.T) {
+	if got := sigV4EscapePath("/model/anthropic.claude-3:0/invoke"); got != "/model/anthropic.claude-3%3A0/invoke" {
+		t.Errorf("sigV4EscapePath() = %q", got)
+	}
+	if got := sigV4EscapePath("/a%20b"); got != "/a%2520b" {
+		t.Errorf("sigV4EscapePath() = %q, want the escaped path encoded again", got)
+	}
+	if got := sigV4CanonicalQuery(map[string][]string{"b": {"2"}, "a": {"x y"}}); got != "a=x%20y&b=2" {
+		t.Errorf("sigV4CanonicalQuery() = %q", got)
+	}
+}
+
+func TestCustomAdapterSignsUpstreamRequest(t *testing.T) {
+	var got http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"x"}`))
+	}))
+	defer upstream.Close()
+
+	p := &domain.Provider{
+		Name: "bedrock",
+		Type: "custom",
+		Config: &domain.ProviderConfig{Custom: &domain.ProviderConfigCustom{
+			BaseURL: upstream.URL,
+			APIKey:  "unused",
+			Signing: testSigning,
+		}},
+	}
+	adapter, err := NewAdapter(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientHeaders := http.Header{"X-Api-Key": {"client-key"}, "Anthropic-Version": {"2023-06-01"}}
+	ctx := ctxutil.WithClientType(context.Background(), domain.ClientTypeClaude)
+	ctx = ctxutil.WithRequestBody(ctx, []byte(`{"model":"m"}`))
+	ctx = ctxutil.WithRequestURI(ctx, "/v1/messages")
+	ctx = ctxutil.WithRequestHeaders(ctx, clientHeaders)
+	ctx = ctxutil.WithEventChan(ctx, domain.NewAdapterEventChan())
+
+	if err := adapter.Execute(ctx, httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/messages", nil), p); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(got.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+		t.Errorf("Authorization = %q, want a SigV4 signature", got.Get("Authorization"))
+	}
+	if got.Get("X-Amz-Date") == "" {
+		t.Error("X-Amz-Date header missing")
+	}
+	if got.Get("X-Api-Key") != "" {
+		t.Error("client x-api-key was forwarded to a signed upstream")
+	}
+	if got.Get("Anthropic-Version") != "2023-06-01" {
+		t.Error("client headers were not forwarded")
+	}
+	if clientHeaders.Get("X-Api-Key") != "client-key" {
+		t.Error("signing modified the client's request headers")
+	}
+}
+
+func TestNewAdapterRejectsIncompleteSigning(t *testing.T) {
+	for _, signing := range []*domain.ProviderConfigCustomSigning{
+		{Mode: "hmac", Region: "r", Service: "s", AccessKeyID: "a", SecretAccessKey: "b"},
+		{Mode: domain.CustomSigningSigV4, Service: "s", AccessKeyID: "a", SecretAccessKey: "b"},
+		{Mode: domain.CustomSigningSigV4, Region: "r", Service: "s", AccessKeyID: "a"},
+	} {
+		p := &domain.Provider{Name: "p", Config: &domain.ProviderConfig{Custom: &domain.ProviderConfigCustom{Signing: signing}}}
+		if _, err := NewAdapter(p); err == nil {
+			t.Errorf("NewAdapter(%+v) succeeded, want error", signing)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package eventstream
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"maps"
+	"sync"
+	"time"
+)
+
+// Decoder parses AWS EventStream frames with error recovery. Data may arrive in
+// arbitrary chunks, incomplete frames are kept until the rest arrives and bytes
+// that can't start a frame are skipped.
+type Decoder struct {
+	buffer         *bytes.Buffer
+	errorCount     int
+	maxErrors      int
+	defaultHeaders map[string]HeaderValue
+	mu             sync.Mutex
+}
+
+// NewDecoder creates a decoder instance.
+func NewDecoder() *Decoder {
+	return &Decoder{
+		buffer:    &bytes.Buffer{},
+		maxErrors: 10,
+	}
+}
+
+// SetMaxErrors overrides the maximum tolerated errors.
+func (d *Decoder) SetMaxErrors(maxErrors int) {
+	d.maxErrors = maxErrors
+}
+
+// SetDefaultHeaders sets the headers assumed for messages whose headers are
+// missing or can't be parsed.
+func (d *Decoder) SetDefaultHeaders(headers map[string]HeaderValue) {
+	d.defaultHeaders = headers
+}
+
+// Reset clears decoder state.
+func (d *Decoder) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.buffer.Reset()
+	d.errorCount = 0
+}
+
+// ParseStream parses incoming bytes into EventStream messages.
+func (d *Decoder) ParseStream(data []byte) ([]*Message, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := d.buffer.Write(data); err != nil {
+		return nil, err
+	}
+
+	messages := make([]*Message, 0, 8)
+
+	for d.buffer.Len() >= MinMessageSize {
+		bufferBytes := d.buffer.Bytes()
+		totalLength := binary.BigEndian.Uint32(bufferBytes[:4])
+		if totalLength < MinMessageSize || totalLength > MaxMessageSize {
+			d.buffer.Next(1)
+			d.errorCount++
+			continue
+		}
+
+		if d.buffer.Len() < int(totalLength) {
+			break
+		}
+
+		messageData := make([]byte, totalLength)
+		n, err := d.buffer.Read(messageData)
+		if err != nil || n != int(totalLength) {
+			break
+		}
+
+		message, err := d.parseMessage(messageData)
+		if err != nil {
+			d.errorCount++
+			continue
+		}
+		messages = append(messages, message)
+	}
+
+	if d.errorCount >= d.maxErrors {
+		return messages, fmt.Errorf("too many parse errors (%d)", d.errorCount)
+	}
+
+	return messages, nil
+}
+
+func (d *Decoder) parseMessage(data []byte) (*Message, error) {
+	if len(data) < MinMessageSize {
+		return nil, NewParseError("eventstream data too short", nil)
+	}
+
+	totalLength := binary.BigEndian.Uint32(data[:4])
+	headerLength := binary.BigEndian.Uint32(data[4:8])
+
+	if int(totalLength) != len(data) {
+		return nil, NewParseError("eventstream length mismatch", nil)
+	}
+
+	if headerLength > totalLength-16 {
+		return nil, NewParseError("eventstream header length invalid", nil)
+	}
+
+	headerData := data[12 : 12+headerLength]
+	payloadStart := int(12 + headerLength)
+	payloadEnd := int(totalLength) - 4
+	if payloadStart > payloadEnd || payloadEnd > len(data) {
+		return nil, NewParseError("eventstream payload bounds invalid", nil)
+	}
+
+	headers, ok := parseHeaders(headerData)
+	if !ok || len(headers) == 0 {
+		headers = maps.Clone(d.defaultHeaders)
+		if headers == nil {
+			headers = make(map[string]HeaderValue)
+		}
+	}
+
+	message := &Message{
+		Headers: headers,
+		Payload: data[payloadStart:payloadEnd],
+	}
+	message.MessageType = message.GetMessageType()
+	message.EventType = message.GetEventType()
+	message.ContentType = message.GetContentType()
+
+	return message, nil
+}
+
+// parseHeaders decodes the header block, reporting false when it is malformed
+func parseHeaders(data []byte) (map[string]HeaderValue, bool) {
+	headers := make(map[string]HeaderValue)
+	offset := 0
+	for offset < len(data) {
+		nameLen := int(data[offset])
+		offset++
+		if nameLen == 0 || offset+nameLen+1 > len(data) {
+			return nil, false
+		}
+		name := string(data[offset : offset+nameLen])
+		offset += nameLen
+
+		valueType := ValueType(data[offset])
+		offset++
+
+		var size int
+		switch valueType {
+		case ValueTypeBoolTrue, ValueTypeBoolFalse:
+		case ValueTypeByte:
+			size = 1
+		case ValueTypeShort:
+			size = 2
+		case ValueTypeInteger:
+			size = 4
+		case ValueTypeLong, ValueTypeTimestamp:
+			size = 8
+		case ValueTypeUUID:
+			size = 16
+		case ValueTypeByteArray, ValueTypeString:
+			if offset+2 > len(data) {
+				return nil, false
+			}
+			size = int(binary.BigEndian.Uint16(data[offset : offset+2]))
+			offset += 2
+		default:
+			return nil, false
+		}
+		if offset+size > len(data) {
+			return nil, false
+		}
+		raw := data[offset : offset+size]
+		offset += size
+
+		var value any
+		switch valueType {
+		case ValueTypeBoolTrue:
+			value = true
+		case ValueTypeBoolFalse:
+			value = false
+		case ValueTypeByte:
+			value = int8(raw[0])
+		case ValueTypeShort:
+			value = int16(binary.BigEndian.Uint16(raw))
+		case ValueTypeInteger:
+			value = int32(binary.BigEndian.Uint32(raw))
+		case ValueTypeLong:
+			value = int64(binary.BigEndian.Uint64(raw))
+		case ValueTypeTimestamp:
+			value = time.UnixMilli(int64(binary.BigEndian.Uint64(raw)))
+		case ValueTypeString:
+			value = string(raw)
+		default:
+			value = bytes.Clone(raw)
+		}
+		headers[name] = HeaderValue{Type: valueType, Value: value}
+	}
+	return headers, true
+}
+
+// Encode builds an EventStream message with string headers, used for fixtures
+// and for upstreams that expect EventStream requests.
+func Encode(headers map[string]string, payload []byte) []byte {
+	var headerData bytes.Buffer
+	for name, value := range headers {
+		headerData.WriteByte(byte(len(name)))
+		headerData.WriteString(name)
+		headerData.WriteByte(byte(ValueTypeString))
+		_ = binary.Write(&headerData, binary.BigEndian, uint16(len(value)))
+		headerData.WriteString(value)
+	}
+
+	totalLength := MinMessageSize + headerData.Len() + len(payload)
+	msg := make([]byte, 0, totalLength)
+	msg = binary.BigEndian.AppendUint32(msg, uint32(totalLength))
+	msg = binary.BigEndian.AppendUint32(msg, uint32(headerData.Len()))
+	msg = binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+	msg = append(msg, headerData.Bytes()...)
+	msg = append(msg, payload...)
+	return binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+}
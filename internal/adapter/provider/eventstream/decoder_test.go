@@ -0,0 +1,89 @@
+package eventstream
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+)
+
+func chunkEvent(payload string) []byte {
+	wrapped := `{"bytes":"` + base64.StdEncoding.EncodeToString([]byte(payload)) + `"}`
+	return Encode(map[string]string{
+		":message-type": "event",
+		":event-type":   "chunk",
+		":content-type": "application/json",
+	}, []byte(wrapped))
+}
+
+func TestDecoderSplitFrames(t *testing.T) {
+	data := append(Encode(map[string]string{":event-type": "a"}, []byte(`{"n":1}`)),
+		Encode(map[string]string{":event-type": "b"}, []byte(`{"n":2}`))...)
+
+	d := NewDecoder()
+	var messages []*Message
+	// Feed the frames a few bytes at a time
+	for i := 0; i < len(data); i += 7 {
+		got, err := d.ParseStream(data[i:min(i+7, len(data))])
+		if err != nil {
+			t.Fatalf("ParseStream() error = %v", err)
+		}
+		messages = append(messages, got...)
+	}
+
+	if len(messages) != 2 {
+		t.Fatalf("decoded %d messages, want 2", len(messages))
+	}
+	if messages[0].EventType != "a" || string(messages[1].Payload) != `{"n":2}` {
+		t.Errorf("messages = %q %q / %q %q", messages[0].EventType, messages[0].Payload, messages[1].EventType, messages[1].Payload)
+	}
+	if messages[0].MessageType != MessageTypeEvent || messages[0].ContentType != "application/json" {
+		t.Errorf("defaults = %q %q", messages[0].MessageType, messages[0].ContentType)
+	}
+}
+
+func TestDecoderSkipsGarbage(t *testing.T) {
+	data := append([]byte{0xff, 0xff, 0xff, 0xff}, Encode(map[string]string{":event-type": "a"}, []byte(`{}`))...)
+	messages, _ := NewDecoder().ParseStream(data)
+	if len(messages) != 1 || messages[0].EventType != "a" {
+		t.Errorf("messages = %+v, want the frame after the garbage", messages)
+	}
+}
+
+func TestDecoderDefaultHeaders(t *testing.T) {
+	d := NewDecoder()
+	d.SetDefaultHeaders(map[string]HeaderValue{":event-type": {Type: ValueTypeString, Value: "assistantResponseEvent"}})
+	messages, _ := d.ParseStream(Encode(nil, []byte(`{"content":"hi"}`)))
+	if len(messages) != 1 || messages[0].EventType != "assistantResponseEvent" {
+		t.Errorf("messages = %+v, want default event type", messages)
+	}
+}
+
+func TestSSEReader(t *testing.T) {
+	var upstream bytes.Buffer
+	upstream.Write(chunkEvent(`{"type":"message_start","message":{"id":"msg_1"}}`))
+	upstream.Write(chunkEvent(`{"type":"content_block_delta","delta":{"type":"text_delta","text":"hi"}}`))
+	upstream.Write(Encode(map[string]string{
+		":message-type":   "exception",
+		":exception-type": "throttlingException",
+	}, []byte(`{"message":"Too many requests"}`)))
+
+	out, err := io.ReadAll(NewSSEReader(&upstream))
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	want := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"hi\"}}\n\n" +
+		"event: error\ndata: {\"error\":{\"code\":429,\"message\":\"Too many requests\",\"type\":\"throttlingException\"},\"type\":\"error\"}\n\n"
+	if string(out) != want {
+		t.Errorf("SSE =\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestToSSEUsesEventTypeWithoutTypeField(t *testing.T) {
+	msg, _ := NewDecoder().ParseStream(Encode(map[string]string{":event-type": "contentBlockDelta"}, []byte(`{"delta":{"text":"hi"}}`)))
+	if got := string(ToSSE(msg[0])); !strings.HasPrefix(got, "event: contentBlockDelta\n") {
+		t.Errorf("ToSSE() = %q", got)
+	}
+}
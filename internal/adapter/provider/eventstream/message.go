@@ -0,0 +1,106 @@
+// Package eventstream decodes the AWS EventStream binary framing used by Bedrock
+// and CodeWhisperer streaming responses.
+package eventstream
+
+// ValueType represents AWS EventStream header value types.
+type ValueType byte
+
+const (
+	ValueTypeBoolTrue  ValueType = 0
+	ValueTypeBoolFalse ValueType = 1
+	ValueTypeByte      ValueType = 2
+	ValueTypeShort     ValueType = 3
+	ValueTypeInteger   ValueType = 4
+	ValueTypeLong      ValueType = 5
+	ValueTypeByteArray ValueType = 6
+	ValueTypeString    ValueType = 7
+	ValueTypeTimestamp ValueType = 8
+	ValueTypeUUID      ValueType = 9
+)
+
+const (
+	// MinMessageSize is the size of a message without headers and payload:
+	// prelude (total length, headers length), prelude CRC and message CRC
+	MinMessageSize = 16
+	// MaxMessageSize bounds a single message, larger length prefixes are treated as corruption
+	MaxMessageSize = 16 * 1024 * 1024
+)
+
+// Message types carried in the ":message-type" header
+const (
+	MessageTypeEvent     = "event"
+	MessageTypeError     = "error"
+	MessageTypeException = "exception"
+)
+
+// HeaderValue stores a parsed header value.
+type HeaderValue struct {
+	Type  ValueType
+	Value any
+}
+
+// Message represents a decoded EventStream message.
+type Message struct {
+	Headers     map[string]HeaderValue
+	Payload     []byte
+	MessageType string
+	EventType   string
+	ContentType string
+}
+
+// GetMessageType returns the message type from headers.
+func (m *Message) GetMessageType() string {
+	if header, ok := m.Headers[":message-type"]; ok {
+		if msgType, ok := header.Value.(string); ok {
+			return msgType
+		}
+	}
+	return MessageTypeEvent
+}
+
+// GetEventType returns the event type from headers.
+func (m *Message) GetEventType() string {
+	if header, ok := m.Headers[":event-type"]; ok {
+		if eventType, ok := header.Value.(string); ok {
+			return eventType
+		}
+	}
+	return ""
+}
+
+// GetContentType returns the content type from headers.
+func (m *Message) GetContentType() string {
+	if header, ok := m.Headers[":content-type"]; ok {
+		if contentType, ok := header.Value.(string); ok {
+			return contentType
+		}
+	}
+	return "application/json"
+}
+
+// StringHeader returns a string header value, empty when missing.
+func (m *Message) StringHeader(name string) string {
+	if header, ok := m.Headers[name]; ok {
+		if s, ok := header.Value.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// ParseError represents a parser error.
+type ParseError struct {
+	Message string
+	Cause   error
+}
+
+func (e *ParseError) Error() string {
+	if e.Cause == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Cause.Error()
+}
+
+func NewParseError(message string, cause error) *ParseError {
+	return &ParseError{Message: message, Cause: cause}
+}
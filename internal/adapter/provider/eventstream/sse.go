@@ -0,0 +1,147 @@
+package eventstream
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// ContentType is the media type of EventStream responses
+const ContentType = "application/vnd.amazon.eventstream"
+
+// exceptionStatus maps AWS exception types to the HTTP status they stand for
+var exceptionStatus = map[string]int{
+	"throttlingException":           http.StatusTooManyRequests,
+	"serviceUnavailableException":   http.StatusServiceUnavailable,
+	"internalServerException":       http.StatusInternalServerError,
+	"modelStreamErrorException":     http.StatusBadGateway,
+	"modelTimeoutException":         http.StatusGatewayTimeout,
+	"validationException":           http.StatusBadRequest,
+	"accessDeniedException":         http.StatusForbidden,
+	"resourceNotFoundException":     http.StatusNotFound,
+	"serviceQuotaExceededException": http.StatusTooManyRequests,
+}
+
+// Payload returns the JSON document carried by an event message. Bedrock wraps
+// each model event base64-encoded in {"bytes": ...}, other upstreams send the
+// event as the payload itself.
+func Payload(msg *Message) []byte {
+	var wrapped struct {
+		Bytes string `json:"bytes"`
+	}
+	if err := json.Unmarshal(msg.Payload, &wrapped); err == nil && wrapped.Bytes != "" {
+		if decoded, err := base64.StdEncoding.DecodeString(wrapped.Bytes); err == nil {
+			return decoded
+		}
+	}
+	return msg.Payload
+}
+
+// ErrorPayload converts an exception or error message to an error event in the
+// {"type":"error","error":{...}} shape SSE error detection understands.
+// It returns nil for regular event messages.
+func ErrorPayload(msg *Message) []byte {
+	var errType, message string
+	switch msg.MessageType {
+	case MessageTypeException:
+		errType = msg.StringHeader(":exception-type")
+		var body struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(msg.Payload, &body); err == nil && body.Message != "" {
+			message = body.Message
+		} else {
+			message = string(msg.Payload)
+		}
+	case MessageTypeError:
+		errType = msg.StringHeader(":error-code")
+		message = msg.StringHeader(":error-message")
+	default:
+		return nil
+	}
+	if errType == "" {
+		errType = "upstream_error"
+	}
+	if message == "" {
+		message = errType
+	}
+
+	errObj := map[string]any{"type": errType, "message": message}
+	if status, ok := exceptionStatus[errType]; ok {
+		errObj["code"] = status
+	}
+	data, _ := json.Marshal(map[string]any{"type": "error", "error": errObj})
+	return data
+}
+
+// ToSSE renders a message as an SSE event. The event name is the "type" field of
+// the payload when it has one (Claude events), otherwise the EventStream event type.
+func ToSSE(msg *Message) []byte {
+	data := ErrorPayload(msg)
+	event := "error"
+	if data == nil {
+		data = bytes.TrimSpace(Payload(msg))
+		if len(data) == 0 {
+			return nil
+		}
+		var typed struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(data, &typed); err == nil && typed.Type != "" {
+			event = typed.Type
+		} else {
+			event = msg.EventType
+		}
+	}
+
+	var buf bytes.Buffer
+	if event != "" {
+		buf.WriteString("event: " + event + "\n")
+	}
+	buf.WriteString("data: ")
+	buf.Write(data)
+	buf.WriteString("\n\n")
+	return buf.Bytes()
+}
+
+// SSEReader transcodes an EventStream body to SSE as it is read
+type SSEReader struct {
+	src     io.Reader
+	decoder *Decoder
+	pending bytes.Buffer
+	buf     []byte
+	err     error
+}
+
+// NewSSEReader wraps an EventStream body
+func NewSSEReader(src io.Reader) *SSEReader {
+	decoder := NewDecoder()
+	decoder.SetMaxErrors(1 << 30) // corrupt bytes are skipped, never fatal for a stream
+	return &SSEReader{
+		src:     src,
+		decoder: decoder,
+		buf:     make([]byte, 4096),
+	}
+}
+
+// Read returns transcoded SSE bytes, reading more of the source as needed
+func (r *SSEReader) Read(p []byte) (int, error) {
+	for r.pending.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		n, err := r.src.Read(r.buf)
+		if n > 0 {
+			messages, _ := r.decoder.ParseStream(r.buf[:n])
+			for _, msg := range messages {
+				r.pending.Write(ToSSE(msg))
+			}
+		}
+		if err != nil {
+			r.err = err
+		}
+	}
+	return r.pending.Read(p)
+}
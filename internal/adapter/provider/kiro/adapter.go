@@ -3,7 +3,6 @@ package kiro
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
@@ -54,7 +53,7 @@ func NewAdapter(p *domain.Provider) (provider.ProviderAdapter, error) {
 		provider:   p,
 		tokenCache: &TokenCache{},
 		usageCache: &UsageCache{},
-		httpClient: newKiroHTTPClient(),
+		httpClient: newKiroHTTPClient(p),
 	}, nil
 }
 
@@ -80,7 +79,7 @@ func (a *KiroAdapter) Execute(ctx context.Context, w http.ResponseWriter, req *h
 	// Get access token
 	accessToken, err := a.getAccessToken(ctx)
 	if err != nil {
-		return domain.NewProxyErrorWithMessage(err, true, "failed to get access token")
+		return err
 	}
 
 	// Convert Claude request to CodeWhisperer format (传入 req 用于生成稳定会话ID)
@@ -146,7 +145,7 @@ func (a *KiroAdapter) Execute(ctx context.Context, w http.ResponseWriter, req *h
 		// Get new token
 		accessToken, err = a.getAccessToken(ctx)
 		if err != nil {
-			return domain.NewProxyErrorWithMessage(err, true, "failed to refresh access token")
+			return err
 		}
 
 		// Retry request (matching kiro2api headers)
@@ -201,7 +200,14 @@ func (a *KiroAdapter) Execute(ctx context.Context, w http.ResponseWriter, req *h
 	return a.handleCollectedStreamResponse(ctx, w, resp, requestModel, inputTokens)
 }
 
+// Warmup pre-refreshes the access token so the first request doesn't pay the refresh latency
+func (a *KiroAdapter) Warmup(ctx context.Context) error {
+	_, err := a.getAccessToken(ctx)
+	return err
+}
+
 // getAccessToken gets a valid access token, refreshing if necessary
+// A failed refresh is returned as the *domain.ProxyError of the request
 func (a *KiroAdapter) getAccessToken(ctx context.Context) (string, error) {
 	// Check cache
 	a.tokenMu.RLock()
@@ -212,11 +218,13 @@ func (a *KiroAdapter) getAccessToken(ctx context.Context) (string, error) {
 	}
 	a.tokenMu.RUnlock()
 
-	// Refresh token
+	// Refresh token, retrying transient failures
 	config := a.provider.Config.Kiro
-	tokenInfo, err := a.refreshToken(ctx, config)
+	tokenInfo, err := provider.RefreshWithRetry(ctx, func(ctx context.Context) (*RefreshResponse, error) {
+		return a.refreshToken(ctx, config)
+	})
 	if err != nil {
-		return "", err
+		return "", provider.RefreshProxyError(err)
 	}
 
 	// Cache token
@@ -265,7 +273,7 @@ func (a *KiroAdapter) refreshSocialToken(ctx context.Context, refreshToken strin
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("refresh failed: status %d, response: %s", resp.StatusCode, string(body))
+		return nil, &provider.RefreshError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var result RefreshResponse
@@ -319,7 +327,7 @@ func (a *KiroAdapter) refreshIdCToken(ctx context.Context, config *domain.Provid
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("IdC refresh failed: status %d, response: %s", resp.StatusCode, string(body))
+		return nil, &provider.RefreshError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var result RefreshResponse
@@ -338,6 +346,11 @@ func (a *KiroAdapter) refreshIdCToken(ctx context.Context, config *domain.Provid
 func (a *KiroAdapter) handleStreamResponse(ctx context.Context, w http.ResponseWriter, resp *http.Response, requestModel string, inputTokens int) error {
 	eventChan := ctxutil.GetEventChan(ctx)
 
+	// Abort the upstream as soon as the client goes away
+	guard := provider.GuardStream(ctx, resp.Body)
+	defer guard.Stop()
+	w = guard.Writer(w)
+
 	// Send initial response info
 	eventChan.SendResponseInfo(&domain.ResponseInfo{
 		Status:  resp.StatusCode,
@@ -351,9 +364,10 @@ func (a *KiroAdapter) handleStreamResponse(ctx context.Context, w http.ResponseW
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no")
 
-	// Capture SSE output for attempt record
-	var sseBuffer strings.Builder
-	tee := &teeWriter{primary: w, buffer: &sseBuffer}
+	// Capture SSE output for attempt record, large responses spill to a temp file
+	sseBuffer := provider.NewSpillBuffer()
+	defer sseBuffer.Close()
+	tee := &teeWriter{primary: w, buffer: sseBuffer}
 
 	streamCtx, err := newStreamProcessorContext(w, requestModel, inputTokens, tee)
 	if err != nil {
@@ -368,10 +382,10 @@ func (a *KiroAdapter) handleStreamResponse(ctx context.Context, w http.ResponseW
 
 	err = streamCtx.processEventStream(ctx, resp.Body)
 	if err != nil {
-		if ctx.Err() != nil {
+		if ctx.Err() != nil || guard.Aborted() {
 			inTok, outTok := streamCtx.GetTokenCounts()
 			a.sendFinalEvents(ctx, sseBuffer.String(), inTok, outTok, requestModel)
-			return domain.NewProxyErrorWithMessage(ctx.Err(), false, "client disconnected")
+			return domain.NewProxyErrorWithMessage(err, false, "client disconnected")
 		}
 
 		_ = streamCtx.sendFinalEvents()
@@ -686,33 +700,26 @@ func isRetryableStatusCode(status int) bool {
 }
 
 // newKiroHTTPClient creates an HTTP client for Kiro/CodeWhisperer API
-// 匹配 kiro2api/utils/client.go:26-52
-func newKiroHTTPClient() *http.Client {
+// 匹配 kiro2api/utils/client.go:26-52，连接池参数由 provider.PooledTransport 按设置统一配置
+func newKiroHTTPClient(p *domain.Provider) *http.Client {
 	return &http.Client{
-		Transport: &http.Transport{
-			// 连接建立配置 (匹配 kiro2api)
-			DialContext: (&net.Dialer{
-				Timeout:   15 * time.Second,
-				KeepAlive: 30 * time.Second,
-				DualStack: true,
-			}).DialContext,
-
-			// TLS配置 (匹配 kiro2api)
-			TLSHandshakeTimeout: 15 * time.Second,
-			TLSClientConfig: &tls.Config{
-				MinVersion: tls.VersionTLS12,
-				MaxVersion: tls.VersionTLS13,
-				CipherSuites: []uint16{
-					tls.TLS_AES_256_GCM_SHA384,
-					tls.TLS_CHACHA20_POLY1305_SHA256,
-					tls.TLS_AES_128_GCM_SHA256,
-				},
-			},
-
-			// HTTP配置 (匹配 kiro2api)
-			ForceAttemptHTTP2:  false,
-			DisableCompression: false,
-		},
+		Transport: provider.NewPooledTransport(p, func() *http.Transport {
+			return &http.Transport{
+				// 连接建立配置 (匹配 kiro2api)
+				DialContext: (&net.Dialer{
+					Timeout:   15 * time.Second,
+					KeepAlive: 30 * time.Second,
+					DualStack: true,
+				}).DialContext,
+
+				// TLS 版本与加密套件由 provider.PooledTransport 按全局 TLS 策略统一配置
+				TLSHandshakeTimeout: 15 * time.Second,
+
+				// HTTP配置 (匹配 kiro2api)
+				ForceAttemptHTTP2:  false,
+				DisableCompression: false,
+			}
+		}),
 		// 注意: kiro2api 不设置整体 Timeout
 	}
 }
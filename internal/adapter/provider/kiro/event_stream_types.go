@@ -1,67 +1,13 @@
 package kiro
 
-import "time"
+import (
+	"time"
 
-// ValueType represents AWS Event Stream header value types.
-type ValueType byte
-
-const (
-	ValueTypeBoolTrue  ValueType = 0
-	ValueTypeBoolFalse ValueType = 1
-	ValueTypeByte      ValueType = 2
-	ValueTypeShort     ValueType = 3
-	ValueTypeInteger   ValueType = 4
-	ValueTypeLong      ValueType = 5
-	ValueTypeByteArray ValueType = 6
-	ValueTypeString    ValueType = 7
-	ValueTypeTimestamp ValueType = 8
-	ValueTypeUUID      ValueType = 9
+	"github.com/awsl-project/maxx/internal/adapter/provider/eventstream"
 )
 
-// HeaderValue stores a parsed header value.
-type HeaderValue struct {
-	Type  ValueType
-	Value any
-}
-
 // EventStreamMessage represents a decoded EventStream message.
-type EventStreamMessage struct {
-	Headers     map[string]HeaderValue
-	Payload     []byte
-	MessageType string
-	EventType   string
-	ContentType string
-}
-
-// GetMessageType returns the message type from headers.
-func (esm *EventStreamMessage) GetMessageType() string {
-	if header, ok := esm.Headers[":message-type"]; ok {
-		if msgType, ok := header.Value.(string); ok {
-			return msgType
-		}
-	}
-	return "event"
-}
-
-// GetEventType returns the event type from headers.
-func (esm *EventStreamMessage) GetEventType() string {
-	if header, ok := esm.Headers[":event-type"]; ok {
-		if eventType, ok := header.Value.(string); ok {
-			return eventType
-		}
-	}
-	return ""
-}
-
-// GetContentType returns the content type from headers.
-func (esm *EventStreamMessage) GetContentType() string {
-	if header, ok := esm.Headers[":content-type"]; ok {
-		if contentType, ok := header.Value.(string); ok {
-			return contentType
-		}
-	}
-	return "application/json"
-}
+type EventStreamMessage = eventstream.Message
 
 // MessageTypes defines the core EventStream message types.
 var MessageTypes = struct {
@@ -172,25 +118,3 @@ type SessionInfo struct {
 	StartTime time.Time  `json:"start_time"`
 	EndTime   *time.Time `json:"end_time,omitempty"`
 }
-
-// ParseError represents a parser error.
-type ParseError struct {
-	Message string
-	Cause   error
-}
-
-func (e *ParseError) Error() string {
-	if e.Cause == nil {
-		return e.Message
-	}
-	return e.Message + ": " + e.Cause.Error()
-}
-
-func NewParseError(message string, cause error) *ParseError {
-	return &ParseError{Message: message, Cause: cause}
-}
-
-const (
-	EventStreamMinMessageSize = 16
-	EventStreamMaxMessageSize = 16 * 1024 * 1024
-)
@@ -1,5 +1,7 @@
 package kiro
 
+import "github.com/awsl-project/maxx/internal/converter"
+
 // StopReasonManager 管理符合Claude规范的stop_reason决策
 type StopReasonManager struct {
 	hasActiveToolCalls bool
@@ -28,11 +30,11 @@ func (srm *StopReasonManager) DetermineStopReason() string {
 	// 只要消息中包含任何 tool_use 内容块（无论是正在流式传输还是已完成），
 	// stop_reason 就应该是 "tool_use"
 	if srm.hasActiveToolCalls || srm.hasCompletedTools {
-		return "tool_use"
+		return string(converter.StopReasonToolUse)
 	}
 
 	// 默认情况 - 自然完成响应
-	return "end_turn"
+	return string(converter.StopReasonEndTurn)
 }
 
 // DetermineStopReasonFromUpstream 从上游响应中提取stop_reason
@@ -41,35 +43,27 @@ func (srm *StopReasonManager) DetermineStopReasonFromUpstream(upstreamStopReason
 		return srm.DetermineStopReason()
 	}
 
-	// 验证上游stop_reason是否符合Claude规范
-	validStopReasons := map[string]bool{
-		"end_turn":      true,
-		"max_tokens":    true,
-		"stop_sequence": true,
-		"tool_use":      true,
-		"pause_turn":    true,
-		"refusal":       true,
-	}
-
-	if !validStopReasons[upstreamStopReason] {
+	// 验证上游stop_reason是否符合Claude规范，取值与转换器共用的规范 stop reason 一致
+	stopReason, ok := converter.ParseClaudeStopReason(upstreamStopReason)
+	if !ok {
 		return srm.DetermineStopReason()
 	}
 
-	return upstreamStopReason
+	return string(stopReason)
 }
 
 // GetStopReasonDescription 获取stop_reason的描述（用于调试）
 func GetStopReasonDescription(stopReason string) string {
-	descriptions := map[string]string{
-		"end_turn":      "Claude自然完成了响应",
-		"max_tokens":    "达到了token限制",
-		"stop_sequence": "遇到了自定义停止序列",
-		"tool_use":      "Claude正在调用工具并期待执行",
-		"pause_turn":    "服务器工具操作暂停",
-		"refusal":       "Claude拒绝生成响应",
+	descriptions := map[converter.StopReason]string{
+		converter.StopReasonEndTurn:      "Claude自然完成了响应",
+		converter.StopReasonMaxTokens:    "达到了token限制",
+		converter.StopReasonStopSequence: "遇到了自定义停止序列",
+		converter.StopReasonToolUse:      "Claude正在调用工具并期待执行",
+		converter.StopReasonPauseTurn:    "服务器工具操作暂停",
+		converter.StopReasonRefusal:      "Claude拒绝生成响应",
 	}
 
-	if desc, exists := descriptions[stopReason]; exists {
+	if desc, exists := descriptions[converter.StopReason(stopReason)]; exists {
 		return desc
 	}
 	return "未知的stop_reason"
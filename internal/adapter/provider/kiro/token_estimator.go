@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/awsl-project/maxx/internal/converter"
+	"github.com/awsl-project/maxx/internal/tokenizer"
 )
 
 // TokenEstimator 本地 token 估算器
@@ -124,78 +125,7 @@ func (e *TokenEstimator) EstimateInputTokens(req *converter.ClaudeRequest) int {
 // EstimateTextTokens 估算纯文本的 token 数量
 // 匹配 kiro2api/utils/token_estimator.go:EstimateTextTokens
 func (e *TokenEstimator) EstimateTextTokens(text string) int {
-	if text == "" {
-		return 0
-	}
-
-	runes := []rune(text)
-	runeCount := len(runes)
-
-	if runeCount == 0 {
-		return 0
-	}
-
-	// 统计中文字符数
-	chineseChars := 0
-	for _, r := range runes {
-		if r >= 0x4E00 && r <= 0x9FFF {
-			chineseChars++
-		}
-	}
-
-	nonChineseChars := runeCount - chineseChars
-	isPureChinese := (nonChineseChars == 0)
-
-	// 中文 token 计算
-	chineseTokens := 0
-	if chineseChars > 0 {
-		if isPureChinese {
-			chineseTokens = 1 + chineseChars
-		} else {
-			chineseTokens = chineseChars
-		}
-	}
-
-	// 英文/数字字符
-	nonChineseTokens := 0
-	if nonChineseChars > 0 {
-		var charsPerToken float64
-		if nonChineseChars < 50 {
-			charsPerToken = 2.8
-		} else if nonChineseChars < 100 {
-			charsPerToken = 2.6
-		} else {
-			charsPerToken = 2.5
-		}
-
-		nonChineseTokens = int(math.Ceil(float64(nonChineseChars) / charsPerToken))
-		if nonChineseTokens < 1 {
-			nonChineseTokens = 1
-		}
-	}
-
-	tokens := chineseTokens + nonChineseTokens
-
-	// 长文本压缩系数
-	if runeCount >= 1000 {
-		tokens = int(float64(tokens) * 0.60)
-	} else if runeCount >= 500 {
-		tokens = int(float64(tokens) * 0.70)
-	} else if runeCount >= 300 {
-		tokens = int(float64(tokens) * 0.80)
-	} else if runeCount >= 200 {
-		tokens = int(float64(tokens) * 0.85)
-	} else if runeCount >= 100 {
-		tokens = int(float64(tokens) * 0.90)
-	} else if runeCount >= 50 {
-		tokens = int(float64(tokens) * 0.95)
-	}
-
-	if tokens < 1 {
-		tokens = 1
-	}
-
-	return tokens
+	return tokenizer.Heuristic{}.Count(text)
 }
 
 // estimateToolName 估算工具名称的 token 数量
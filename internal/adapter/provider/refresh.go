@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// Token refresh bounds, independent of the executor's request retries
+var (
+	// RefreshTimeout bounds each call to a token endpoint
+	RefreshTimeout = 15 * time.Second
+	// RefreshRetryDelays are the waits before each retry of a transient refresh failure
+	RefreshRetryDelays = []time.Duration{500 * time.Millisecond, 2 * time.Second}
+)
+
+// RefreshError is a token endpoint response other than a success
+type RefreshError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *RefreshError) Error() string {
+	return fmt.Sprintf("token refresh failed: status %d, response: %s", e.StatusCode, e.Body)
+}
+
+// InvalidCredentials reports whether the token endpoint rejected the credentials
+// themselves, a refresh retried later fails the same way
+func (e *RefreshError) InvalidCredentials() bool {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return true
+	case http.StatusBadRequest:
+		// OAuth 2.0 error codes (RFC 6749 5.2), also used by AWS SSO OIDC in its own casing
+		body := strings.ToLower(e.Body)
+		for _, code := range []string{"invalid_grant", "invalid_client", "unauthorized_client", "invalidgrantexception", "invalidclientexception", "unauthorizedclientexception"} {
+			if strings.Contains(body, code) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// retryable reports whether the failure is likely transient
+func (e *RefreshError) retryable() bool {
+	return e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// IsInvalidCredentials reports whether err is a refresh rejected for invalid credentials
+func IsInvalidCredentials(err error) bool {
+	var refreshErr *RefreshError
+	return errors.As(err, &refreshErr) && refreshErr.InvalidCredentials()
+}
+
+// RefreshWithRetry calls refresh with a RefreshTimeout bound, retrying network errors,
+// timeouts, 408, 429 and 5xx responses after RefreshRetryDelays. Other token endpoint
+// responses, rejected credentials in particular, are returned at once.
+func RefreshWithRetry[T any](ctx context.Context, refresh func(ctx context.Context) (T, error)) (T, error) {
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, RefreshTimeout)
+		result, err := refresh(attemptCtx)
+		cancel()
+		if err == nil || attempt >= len(RefreshRetryDelays) || ctx.Err() != nil {
+			return result, err
+		}
+		var refreshErr *RefreshError
+		if errors.As(err, &refreshErr) && !refreshErr.retryable() {
+			return result, err
+		}
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-time.After(RefreshRetryDelays[attempt]):
+		}
+	}
+}
+
+// RefreshProxyError converts a failed token refresh to the error of the request. Rejected
+// credentials are not retryable and cool the provider down; other failures are treated
+// as network errors.
+func RefreshProxyError(err error) *domain.ProxyError {
+	if IsInvalidCredentials(err) {
+		return domain.NewProxyErrorWithMessage(domain.ErrInvalidCredentials, false, err.Error())
+	}
+	proxyErr := domain.NewProxyErrorWithMessage(err, true, "failed to refresh access token")
+	proxyErr.IsNetworkError = true
+	return proxyErr
+}
@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// shortRefreshRetries makes refresh retries fast for the test
+func shortRefreshRetries(t *testing.T, timeout time.Duration) {
+	t.Helper()
+	oldTimeout, oldDelays := RefreshTimeout, RefreshRetryDelays
+	RefreshTimeout = timeout
+	RefreshRetryDelays = []time.Duration{time.Millisecond, time.Millisecond}
+	t.Cleanup(func() { RefreshTimeout, RefreshRetryDelays = oldTimeout, oldDelays })
+}
+
+// flakyTokenServer answers with the given handlers in turn, the last one repeatedly
+func flakyTokenServer(t *testing.T, handlers ...http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1)) - 1
+		handlers[min(n, len(handlers)-1)](w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func status(code int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+		_, _ = io.WriteString(w, body)
+	}
+}
+
+// refreshFrom is a minimal token refresh against url
+func refreshFrom(url string) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			return "", &RefreshError{StatusCode: resp.StatusCode, Body: string(body)}
+		}
+		return string(body), nil
+	}
+}
+
+func TestRefreshWithRetryRecoversFromTransientFailures(t *testing.T) {
+	shortRefreshRetries(t, 100*time.Millisecond)
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}
+	srv, calls := flakyTokenServer(t, slow, status(http.StatusServiceUnavailable, "try later"), status(http.StatusOK, "token"))
+
+	token, err := RefreshWithRetry(context.Background(), refreshFrom(srv.URL))
+	if err != nil || token != "token" {
+		t.Fatalf("RefreshWithRetry = %q, %v; want the token after a timeout and a 503", token, err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("token endpoint called %d times, want 3", n)
+	}
+}
+
+func TestRefreshWithRetryGivesUp(t *testing.T) {
+	shortRefreshRetries(t, time.Second)
+	srv, calls := flakyTokenServer(t, status(http.StatusBadGateway, "down"))
+
+	_, err := RefreshWithRetry(context.Background(), refreshFrom(srv.URL))
+	if n := calls.Load(); n != int32(len(RefreshRetryDelays)+1) {
+		t.Errorf("token endpoint called %d times, want %d", n, len(RefreshRetryDelays)+1)
+	}
+	proxyErr := RefreshProxyError(err)
+	if !proxyErr.Retryable || !proxyErr.IsNetworkError || errors.Is(proxyErr, domain.ErrInvalidCredentials) {
+		t.Errorf("proxy error = %+v, want a retryable network error", proxyErr)
+	}
+}
+
+func TestRefreshWithRetryStopsOnInvalidCredentials(t *testing.T) {
+	shortRefreshRetries(t, time.Second)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"google invalid_grant", status(http.StatusBadRequest, `{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`)},
+		{"aws invalid grant", status(http.StatusBadRequest, `{"error":"InvalidGrantException"}`)},
+		{"unauthorized", status(http.StatusUnauthorized, `{"message":"bad token"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls := flakyTokenServer(t, tt.handler)
+			_, err := RefreshWithRetry(context.Background(), refreshFrom(srv.URL))
+			if n := calls.Load(); n != 1 {
+				t.Errorf("token endpoint called %d times, want no retry", n)
+			}
+			if !IsInvalidCredentials(err) {
+				t.Fatalf("IsInvalidCredentials(%v) = false", err)
+			}
+			proxyErr := RefreshProxyError(err)
+			if proxyErr.Retryable || !errors.Is(proxyErr, domain.ErrInvalidCredentials) {
+				t.Errorf("proxy error = %+v, want non-retryable invalid credentials", proxyErr)
+			}
+		})
+	}
+}
+
+func TestRefreshWithRetryOtherClientErrorsAreNotRetried(t *testing.T) {
+	shortRefreshRetries(t, time.Second)
+	srv, calls := flakyTokenServer(t, status(http.StatusBadRequest, `{"error":"invalid_request"}`))
+	_, err := RefreshWithRetry(context.Background(), refreshFrom(srv.URL))
+	if n := calls.Load(); n != 1 || err == nil || IsInvalidCredentials(err) {
+		t.Errorf("calls = %d, err = %v; want one call failing without blaming the credentials", n, err)
+	}
+}
@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+// spillThreshold is the captured response size in bytes past which
+// SpillBuffer moves to a temp file, 0 keeps everything in memory
+var spillThreshold atomic.Int64
+
+// SetSpillThreshold sets the spill threshold in bytes, <= 0 disables spilling
+func SetSpillThreshold(bytes int64) {
+	spillThreshold.Store(max(bytes, 0))
+}
+
+// LoadSpillThresholdFromSettings applies the response spill setting (in KB)
+func LoadSpillThresholdFromSettings(settingRepo repository.SystemSettingRepository) {
+	val, err := settingRepo.Get(domain.SettingKeyResponseSpillThreshold)
+	if err != nil || val == "" {
+		SetSpillThreshold(0)
+		return
+	}
+	kb, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		SetSpillThreshold(0)
+		return
+	}
+	SetSpillThreshold(kb * 1024)
+}
+
+// SpillBuffer captures a streamed response for the attempt record and usage extraction.
+// It buffers in memory until the configured threshold, then moves the data to a temp
+// file so many large concurrent streams don't each hold their whole response in memory.
+// Close must be called when done, it removes the temp file.
+type SpillBuffer struct {
+	threshold int64
+	mem       strings.Builder
+	file      *os.File
+	fileSize  int64
+	noSpill   bool // creating or writing the temp file failed, stay in memory
+}
+
+// NewSpillBuffer creates a buffer using the current spill threshold
+func NewSpillBuffer() *SpillBuffer {
+	return &SpillBuffer{threshold: spillThreshold.Load()}
+}
+
+// WriteString appends s, it never fails: on file errors the data stays in memory
+func (b *SpillBuffer) WriteString(s string) (int, error) {
+	if b.file == nil && !b.noSpill && b.threshold > 0 && int64(b.mem.Len()+len(s)) > b.threshold {
+		b.spill()
+	}
+	if b.file != nil && !b.noSpill {
+		n, err := b.file.WriteString(s)
+		b.fileSize += int64(n)
+		if err == nil {
+			return len(s), nil
+		}
+		b.noSpill = true
+		s = s[n:]
+	}
+	b.mem.WriteString(s)
+	return len(s), nil
+}
+
+// spill moves the in-memory data to a new temp file
+func (b *SpillBuffer) spill() {
+	f, err := os.CreateTemp("", "maxx-response-*")
+	if err != nil {
+		b.noSpill = true
+		return
+	}
+	n, err := f.WriteString(b.mem.String())
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		b.noSpill = true
+		return
+	}
+	b.file = f
+	b.fileSize = int64(n)
+	b.mem.Reset()
+}
+
+// Len returns the number of bytes captured
+func (b *SpillBuffer) Len() int {
+	return int(b.fileSize) + b.mem.Len()
+}
+
+// Spilled reports whether the data was moved to a temp file
+func (b *SpillBuffer) Spilled() bool {
+	return b.file != nil
+}
+
+// String returns the captured data, reading it back from the temp file if spilled.
+// Call it once at the end of the stream and reuse the result.
+func (b *SpillBuffer) String() string {
+	if b.file == nil {
+		return b.mem.String()
+	}
+	var sb strings.Builder
+	sb.Grow(b.Len())
+	if _, err := b.file.Seek(0, io.SeekStart); err == nil {
+		_, _ = io.CopyN(&sb, b.file, b.fileSize)
+	}
+	_, _ = b.file.Seek(0, io.SeekEnd)
+	sb.WriteString(b.mem.String())
+	return sb.String()
+}
+
+// Close removes the temp file, the buffer must not be used afterwards
+func (b *SpillBuffer) Close() error {
+	b.mem.Reset()
+	if b.file == nil {
+		return nil
+	}
+	name := b.file.Name()
+	err := b.file.Close()
+	b.file = nil
+	b.fileSize = 0
+	if rmErr := os.Remove(name); err == nil {
+		err = rmErr
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSpillBufferMovesToDiskPastThreshold(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	SetSpillThreshold(64)
+	defer SetSpillThreshold(0)
+
+	b := NewSpillBuffer()
+	var want strings.Builder
+	for i := 0; i < 10; i++ {
+		line := "data: {\"chunk\":" + strings.Repeat("x", 10) + "}\n\n"
+		b.WriteString(line)
+		want.WriteString(line)
+		if i == 0 && b.Spilled() {
+			t.Fatal("spilled before reaching the threshold")
+		}
+	}
+	if !b.Spilled() {
+		t.Fatal("expected the buffer to spill to disk")
+	}
+	if b.Len() != want.Len() {
+		t.Errorf("Len = %d, want %d", b.Len(), want.Len())
+	}
+	if got := b.String(); got != want.String() {
+		t.Errorf("String = %q, want %q", got, want.String())
+	}
+
+	// Writes after reading back keep appending
+	b.WriteString("data: [DONE]\n\n")
+	want.WriteString("data: [DONE]\n\n")
+	if got := b.String(); got != want.String() {
+		t.Errorf("String after more writes = %q", got)
+	}
+
+	name := b.file.Name()
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("temp file still exists after Close: %v", err)
+	}
+}
+
+func TestSpillBufferStaysInMemoryWhenDisabled(t *testing.T) {
+	SetSpillThreshold(0)
+	b := NewSpillBuffer()
+	defer b.Close()
+
+	b.WriteString(strings.Repeat("a", 1<<20))
+	if b.Spilled() {
+		t.Error("spilled with spilling disabled")
+	}
+	if b.Len() != 1<<20 {
+		t.Errorf("Len = %d", b.Len())
+	}
+}
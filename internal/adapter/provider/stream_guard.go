@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// StreamGuard aborts an upstream streaming response as soon as the client
+// disconnect is detected, either through the request context or a failed
+// write to the client. Closing the response body tears down the upstream
+// connection (or HTTP/2 stream) so the upstream stops generating instead of
+// streaming to EOF into a dead client.
+type StreamGuard struct {
+	body    io.Closer
+	once    sync.Once
+	done    chan struct{}
+	aborted atomic.Bool
+}
+
+// GuardStream watches ctx and closes body when it is cancelled.
+// Call Stop once the stream has been fully handled.
+func GuardStream(ctx context.Context, body io.Closer) *StreamGuard {
+	g := &StreamGuard{body: body, done: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			g.Abort()
+		case <-g.done:
+		}
+	}()
+	return g
+}
+
+// Abort closes the upstream body immediately
+func (g *StreamGuard) Abort() {
+	if g.aborted.CompareAndSwap(false, true) {
+		_ = g.body.Close()
+	}
+}
+
+// Aborted reports whether the upstream was aborted because the client went away
+func (g *StreamGuard) Aborted() bool {
+	return g.aborted.Load()
+}
+
+// Stop releases the context watcher
+func (g *StreamGuard) Stop() {
+	g.once.Do(func() { close(g.done) })
+}
+
+// Writer wraps the client writer so a failed write aborts the upstream right away
+func (g *StreamGuard) Writer(w http.ResponseWriter) http.ResponseWriter {
+	return &guardedWriter{ResponseWriter: w, guard: g}
+}
+
+type guardedWriter struct {
+	http.ResponseWriter
+	guard *StreamGuard
+}
+
+func (gw *guardedWriter) Write(b []byte) (int, error) {
+	n, err := gw.ResponseWriter.Write(b)
+	if err != nil {
+		gw.guard.Abort()
+	}
+	return n, err
+}
+
+// Flush implements http.Flusher for streaming support
+func (gw *guardedWriter) Flush() {
+	if f, ok := gw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
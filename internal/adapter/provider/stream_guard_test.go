@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestStreamGuardWriteFailureAbortsUpstream(t *testing.T) {
+	upstreamCancelled := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(upstreamCancelled)
+	}))
+	defer upstream.Close()
+
+	// The request context stays alive, only the failed client write signals the disconnect
+	resp, err := http.Get(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	guard := GuardStream(context.Background(), resp.Body)
+	defer guard.Stop()
+	w := guard.Writer(failingWriter{httptest.NewRecorder()})
+
+	if _, err := w.Write([]byte("data: first\n\n")); err == nil {
+		t.Fatal("write to failing client succeeded")
+	}
+	if !guard.Aborted() {
+		t.Error("guard not aborted after failed client write")
+	}
+
+	select {
+	case <-upstreamCancelled:
+	case <-time.After(time.Second):
+		t.Fatal("upstream request was not cancelled after the client write failed")
+	}
+}
@@ -0,0 +1,141 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+// timingCapture makes attempts record the DNS/connect/TLS/first byte timings of
+// their upstream request, off by default
+var timingCapture atomic.Bool
+
+// SetTimingCapture enables or disables upstream timing capture
+func SetTimingCapture(enabled bool) {
+	timingCapture.Store(enabled)
+}
+
+// TimingCaptureEnabled reports whether upstream timing capture is on
+func TimingCaptureEnabled() bool {
+	return timingCapture.Load()
+}
+
+// LoadTimingCaptureFromSettings applies the upstream timing capture setting
+func LoadTimingCaptureFromSettings(settingRepo repository.SystemSettingRepository) {
+	val, _ := settingRepo.Get(domain.SettingKeyUpstreamTimingCapture)
+	SetTimingCapture(val == "true")
+}
+
+// TimingRecorder collects the phase timings of the upstream requests made with
+// its context. When an adapter sends several requests (token refresh retries),
+// the last one wins.
+type TimingRecorder struct {
+	mu sync.Mutex
+	timingState
+}
+
+type timingState struct {
+	started  bool
+	timing   domain.UpstreamTiming
+	dnsStart time.Time
+	dialAt   time.Time
+	tlsStart time.Time
+	wroteAt  time.Time
+}
+
+type timingRecorderKey struct{}
+
+// WithTimingRecorder attaches rec to ctx, PooledTransport fills it for requests made with ctx
+func WithTimingRecorder(ctx context.Context, rec *TimingRecorder) context.Context {
+	return context.WithValue(ctx, timingRecorderKey{}, rec)
+}
+
+func timingRecorderFrom(ctx context.Context) *TimingRecorder {
+	rec, _ := ctx.Value(timingRecorderKey{}).(*TimingRecorder)
+	return rec
+}
+
+// Timing returns the timings of the last upstream request, nil when none was sent
+func (r *TimingRecorder) Timing() *domain.UpstreamTiming {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.started {
+		return nil
+	}
+	timing := r.timing
+	return &timing
+}
+
+// start resets the recorder for a new request
+func (r *TimingRecorder) start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timingState = timingState{started: true}
+}
+
+func (r *TimingRecorder) record(fn func()) {
+	r.mu.Lock()
+	fn()
+	r.mu.Unlock()
+}
+
+// trace returns the client trace hooks filling the recorder, calling gotConn as well
+func (r *TimingRecorder) trace(gotConn func(httptrace.GotConnInfo)) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			gotConn(info)
+			r.record(func() { r.timing.Reused = info.Reused })
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			r.record(func() { r.dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			r.record(func() {
+				if !r.dnsStart.IsZero() {
+					r.timing.DNS = time.Since(r.dnsStart)
+				}
+			})
+		},
+		// With several addresses the dials race, the time to the first connection counts
+		ConnectStart: func(string, string) {
+			r.record(func() {
+				if r.dialAt.IsZero() {
+					r.dialAt = time.Now()
+				}
+			})
+		},
+		ConnectDone: func(_, _ string, err error) {
+			r.record(func() {
+				if err == nil && !r.dialAt.IsZero() && r.timing.Connect == 0 {
+					r.timing.Connect = time.Since(r.dialAt)
+				}
+			})
+		},
+		TLSHandshakeStart: func() {
+			r.record(func() { r.tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			r.record(func() {
+				if !r.tlsStart.IsZero() {
+					r.timing.TLS = time.Since(r.tlsStart)
+				}
+			})
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			r.record(func() { r.wroteAt = time.Now() })
+		},
+		GotFirstResponseByte: func() {
+			r.record(func() {
+				if !r.wroteAt.IsZero() {
+					r.timing.FirstByte = time.Since(r.wroteAt)
+				}
+			})
+		},
+	}
+}
@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+type testSettings map[string]string
+
+func (m testSettings) Get(key string) (string, error)           { return m[key], nil }
+func (m testSettings) Set(key, value string) error              { m[key] = value; return nil }
+func (m testSettings) Delete(key string) error                  { delete(m, key); return nil }
+func (m testSettings) GetAll() ([]*domain.SystemSetting, error) { return nil, nil }
+
+func TestPooledTransportRecordsTimings(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	// Dial by name so the request goes through DNS resolution
+	url := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+
+	transport := NewPooledTransport(&domain.Provider{ID: 1101}, func() *http.Transport {
+		return &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	})
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	get := func() *domain.UpstreamTiming {
+		rec := &TimingRecorder{}
+		if rec.Timing() != nil {
+			t.Fatal("timing recorded before any request")
+		}
+		req, _ := http.NewRequestWithContext(WithTimingRecorder(t.Context(), rec), http.MethodGet, url, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		timing := rec.Timing()
+		if timing == nil {
+			t.Fatal("no timing recorded")
+		}
+		return timing
+	}
+
+	first := get()
+	if first.Reused || first.DNS <= 0 || first.Connect <= 0 || first.TLS <= 0 || first.FirstByte <= 0 {
+		t.Errorf("new connection timing = %+v, want all phases", first)
+	}
+
+	second := get()
+	if !second.Reused || second.DNS != 0 || second.Connect != 0 || second.TLS != 0 || second.FirstByte <= 0 {
+		t.Errorf("reused connection timing = %+v, want only first byte", second)
+	}
+
+	// Requests without a recorder are still counted
+	doRequest(t, client, url)
+	if requests, _, _ := ConnStatsFor(1101).Counts(); requests != 3 {
+		t.Errorf("requests = %d, want 3", requests)
+	}
+}
+
+func TestLoadTimingCaptureFromSettings(t *testing.T) {
+	t.Cleanup(func() { SetTimingCapture(false) })
+	settings := testSettings{}
+	LoadTimingCaptureFromSettings(settings)
+	if TimingCaptureEnabled() {
+		t.Error("capture enabled by default")
+	}
+	settings[domain.SettingKeyUpstreamTimingCapture] = "true"
+	LoadTimingCaptureFromSettings(settings)
+	if !TimingCaptureEnabled() {
+		t.Error("capture not enabled by the setting")
+	}
+}
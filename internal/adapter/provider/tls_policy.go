@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+// TLSPolicy restricts the TLS of all upstream connections. The zero policy
+// keeps the Go defaults (TLS 1.2 minimum, Go's cipher suite selection).
+type TLSPolicy struct {
+	MinVersion uint16
+
+	// TLS 1.2 cipher suites offered, nil for the defaults. TLS 1.3 suites
+	// are not configurable in Go.
+	CipherSuites []uint16
+}
+
+var tlsPolicy atomic.Pointer[TLSPolicy]
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// SetTLSPolicy sets the TLS policy, transports are rebuilt with it on their next request
+func SetTLSPolicy(p TLSPolicy) {
+	tlsPolicy.Store(&p)
+	poolVersion.Add(1)
+}
+
+// CurrentTLSPolicy returns the TLS policy in effect
+func CurrentTLSPolicy() TLSPolicy {
+	if p := tlsPolicy.Load(); p != nil {
+		return *p
+	}
+	return TLSPolicy{}
+}
+
+// ParseTLSPolicy parses the minimum version ("1.2" or "1.3") and the comma
+// separated cipher suite names of a TLS policy, empty values keep the defaults.
+// Insecure and TLS 1.3 only suites are rejected.
+func ParseTLSPolicy(minVersion, cipherSuites string) (TLSPolicy, error) {
+	var policy TLSPolicy
+	if v := strings.TrimSpace(minVersion); v != "" {
+		version, ok := tlsVersions[v]
+		if !ok {
+			return TLSPolicy{}, fmt.Errorf("invalid %s %q: must be \"1.2\" or \"1.3\"", domain.SettingKeyUpstreamTLSMinVersion, v)
+		}
+		policy.MinVersion = version
+	}
+
+	for _, name := range strings.Split(cipherSuites, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, err := cipherSuiteID(name)
+		if err != nil {
+			return TLSPolicy{}, fmt.Errorf("invalid %s: %w", domain.SettingKeyUpstreamTLSCipherSuites, err)
+		}
+		if !slices.Contains(policy.CipherSuites, id) {
+			policy.CipherSuites = append(policy.CipherSuites, id)
+		}
+	}
+	return policy, nil
+}
+
+func cipherSuiteID(name string) (uint16, error) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name != name {
+			continue
+		}
+		if !slices.Contains(suite.SupportedVersions, tls.VersionTLS12) {
+			return 0, fmt.Errorf("%s is a TLS 1.3 cipher suite, those are not configurable", name)
+		}
+		return suite.ID, nil
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.Name == name {
+			return 0, fmt.Errorf("%s is insecure", name)
+		}
+	}
+	return 0, fmt.Errorf("unknown cipher suite %s", name)
+}
+
+// ValidateTLSSetting checks a TLS policy setting before it is saved
+func ValidateTLSSetting(key, value string) error {
+	switch key {
+	case domain.SettingKeyUpstreamTLSMinVersion:
+		_, err := ParseTLSPolicy(value, "")
+		return err
+	case domain.SettingKeyUpstreamTLSCipherSuites:
+		_, err := ParseTLSPolicy("", value)
+		return err
+	}
+	return nil
+}
+
+// LoadTLSPolicyFromSettings applies the upstream TLS policy settings. An
+// invalid policy is returned as an error and the previous policy stays in effect.
+func LoadTLSPolicyFromSettings(settingRepo repository.SystemSettingRepository) error {
+	minVersion, _ := settingRepo.Get(domain.SettingKeyUpstreamTLSMinVersion)
+	cipherSuites, _ := settingRepo.Get(domain.SettingKeyUpstreamTLSCipherSuites)
+	policy, err := ParseTLSPolicy(minVersion, cipherSuites)
+	if err != nil {
+		return err
+	}
+	SetTLSPolicy(policy)
+	return nil
+}
+
+// applyTLSPolicy applies the policy to a transport built by an adapter
+func applyTLSPolicy(transport *http.Transport, policy TLSPolicy) {
+	if policy.MinVersion == 0 && policy.CipherSuites == nil {
+		return
+	}
+	if transport.TLSClientConfig == nil {
+		// net/http enables HTTP/2 by itself for transports without custom dialer
+		// or TLS config, adding the config must not turn it off
+		if transport.DialContext == nil && transport.DialTLSContext == nil {
+			transport.ForceAttemptHTTP2 = true
+		}
+		transport.TLSClientConfig = &tls.Config{}
+	} else {
+		transport.TLSClientConfig = transport.TLSClientConfig.Clone()
+	}
+	config := transport.TLSClientConfig
+	if policy.MinVersion > config.MinVersion {
+		config.MinVersion = policy.MinVersion
+	}
+	if config.MaxVersion != 0 && config.MaxVersion < config.MinVersion {
+		config.MaxVersion = 0
+	}
+	if policy.CipherSuites != nil {
+		config.CipherSuites = slices.Clone(policy.CipherSuites)
+	}
+}
@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestParseTLSPolicy(t *testing.T) {
+	policy, err := ParseTLSPolicy(" 1.3 ", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}
+	if policy.MinVersion != tls.VersionTLS13 || !slices.Equal(policy.CipherSuites, want) {
+		t.Errorf("policy = %+v", policy)
+	}
+
+	if policy, err := ParseTLSPolicy("", ""); err != nil || policy.MinVersion != 0 || policy.CipherSuites != nil {
+		t.Errorf("empty policy = %+v, %v", policy, err)
+	}
+
+	for _, tc := range []struct{ version, ciphers string }{
+		{"1.1", ""},
+		{"tls1.2", ""},
+		{"", "TLS_AES_128_GCM_SHA256"},   // TLS 1.3, not configurable
+		{"", "TLS_RSA_WITH_RC4_128_SHA"}, // insecure
+		{"", "TLS_NOT_A_CIPHER_SUITE"},   // unknown
+	} {
+		if _, err := ParseTLSPolicy(tc.version, tc.ciphers); err == nil {
+			t.Errorf("ParseTLSPolicy(%q, %q) accepted", tc.version, tc.ciphers)
+		}
+	}
+}
+
+func TestLoadTLSPolicyKeepsPreviousOnError(t *testing.T) {
+	t.Cleanup(func() { SetTLSPolicy(TLSPolicy{}) })
+	settings := testSettings{domain.SettingKeyUpstreamTLSMinVersion: "1.3"}
+	if err := LoadTLSPolicyFromSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	settings[domain.SettingKeyUpstreamTLSMinVersion] = "1.0"
+	if err := LoadTLSPolicyFromSettings(settings); err == nil {
+		t.Fatal("invalid min version accepted")
+	}
+	if got := CurrentTLSPolicy().MinVersion; got != tls.VersionTLS13 {
+		t.Errorf("min version = %x after an invalid update, want TLS 1.3 kept", got)
+	}
+}
+
+// newTLS12Server serves TLS 1.2 only with a single cipher suite
+func newTLS12Server(t *testing.T, cipher uint16) *httptest.Server {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{cipher}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPooledTransportAppliesTLSPolicy(t *testing.T) {
+	t.Cleanup(func() { SetTLSPolicy(TLSPolicy{}) })
+	srv := newTLS12Server(t, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)
+	trusted := srv.Client().Transport.(*http.Transport).TLSClientConfig
+
+	// Adapter transports pinning their own TLS versions (like kiro) get the policy too
+	transport := NewPooledTransport(&domain.Provider{ID: 1101}, func() *http.Transport {
+		config := trusted.Clone()
+		config.MaxVersion = tls.VersionTLS12
+		return &http.Transport{TLSClientConfig: config}
+	})
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	doRequest(t, client, srv.URL)
+
+	SetTLSPolicy(TLSPolicy{CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}})
+	built := transport.current().TLSClientConfig
+	if !slices.Equal(built.CipherSuites, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}) {
+		t.Errorf("cipher suites = %v, want the policy", built.CipherSuites)
+	}
+	if trusted.CipherSuites != nil {
+		t.Error("policy modified the adapter's TLS config")
+	}
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Error("handshake succeeded with a cipher suite outside the policy")
+	}
+
+	SetTLSPolicy(TLSPolicy{MinVersion: tls.VersionTLS13})
+	built = transport.current().TLSClientConfig
+	if built.MinVersion != tls.VersionTLS13 || built.MaxVersion != 0 {
+		t.Errorf("versions = %x-%x, want TLS 1.3 minimum without the adapter's lower maximum", built.MinVersion, built.MaxVersion)
+	}
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Error("handshake succeeded with a TLS 1.2 server under a TLS 1.3 minimum")
+	}
+}
+
+func TestTLSPolicyKeepsHTTP2(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		transport *http.Transport
+		want      bool
+	}{
+		{"default transport", &http.Transport{}, true},
+		{"custom dialer", &http.Transport{DialContext: http.DefaultTransport.(*http.Transport).DialContext}, false},
+		{"custom dialer forcing HTTP/2", &http.Transport{DialContext: http.DefaultTransport.(*http.Transport).DialContext, ForceAttemptHTTP2: true}, true},
+	} {
+		applyTLSPolicy(tc.transport, TLSPolicy{MinVersion: tls.VersionTLS13})
+		if tc.transport.ForceAttemptHTTP2 != tc.want || tc.transport.TLSClientConfig.MinVersion != tls.VersionTLS13 {
+			t.Errorf("%s: ForceAttemptHTTP2 = %v, TLS config %+v", tc.name, tc.transport.ForceAttemptHTTP2, tc.transport.TLSClientConfig)
+		}
+	}
+}
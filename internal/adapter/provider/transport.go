@@ -0,0 +1,185 @@
+package provider
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+// PoolConfig tunes the idle connection pool of upstream transports
+type PoolConfig struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+}
+
+// DefaultPoolConfig keeps enough idle connections per upstream host that bursts of
+// concurrent requests don't dial again (net/http defaults to 2 per host)
+var DefaultPoolConfig = PoolConfig{
+	MaxIdleConns:        100,
+	MaxIdleConnsPerHost: 16,
+	IdleConnTimeout:     90 * time.Second,
+}
+
+var (
+	poolConfig atomic.Pointer[PoolConfig]
+	// poolVersion changes with the pool config and the TLS policy
+	poolVersion atomic.Uint64
+)
+
+// SetPoolConfig sets the pool config, transports pick it up on their next request
+func SetPoolConfig(c PoolConfig) {
+	poolConfig.Store(&c)
+	poolVersion.Add(1)
+}
+
+// CurrentPoolConfig returns the pool config in effect
+func CurrentPoolConfig() PoolConfig {
+	if c := poolConfig.Load(); c != nil {
+		return *c
+	}
+	return DefaultPoolConfig
+}
+
+// LoadPoolConfigFromSettings applies the upstream pool settings, unset or invalid
+// values keep their defaults
+func LoadPoolConfigFromSettings(settingRepo repository.SystemSettingRepository) {
+	setting := func(key string, def int) int {
+		val, err := settingRepo.Get(key)
+		if err != nil || val == "" {
+			return def
+		}
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			return def
+		}
+		return n
+	}
+	SetPoolConfig(PoolConfig{
+		MaxIdleConns:        setting(domain.SettingKeyUpstreamMaxIdleConns, DefaultPoolConfig.MaxIdleConns),
+		MaxIdleConnsPerHost: setting(domain.SettingKeyUpstreamMaxIdleConnsPerHost, DefaultPoolConfig.MaxIdleConnsPerHost),
+		IdleConnTimeout:     time.Duration(setting(domain.SettingKeyUpstreamIdleConnTimeout, int(DefaultPoolConfig.IdleConnTimeout/time.Second))) * time.Second,
+	})
+}
+
+// ConnStats counts how one provider's upstream requests got their connection
+type ConnStats struct {
+	requests atomic.Int64
+	reused   atomic.Int64
+	dialed   atomic.Int64
+}
+
+// Counts returns the number of requests and how many reused an idle connection or dialed a new one
+func (s *ConnStats) Counts() (requests, reused, dialed int64) {
+	return s.requests.Load(), s.reused.Load(), s.dialed.Load()
+}
+
+var connStats sync.Map // providerID -> *ConnStats
+
+// ConnStatsFor returns the connection stats of a provider, nil when it made no requests yet
+func ConnStatsFor(providerID uint64) *ConnStats {
+	if s, ok := connStats.Load(providerID); ok {
+		return s.(*ConnStats)
+	}
+	return nil
+}
+
+// PooledTransport is the upstream transport of one provider. It applies the pool
+// config and TLS policy to the transport built by newTransport, rebuilding it when
+// either changes, and records for each request whether its connection was reused, plus
+// its phase timings when the request context carries a TimingRecorder.
+type PooledTransport struct {
+	newTransport func() *http.Transport
+	forceHTTP1   bool
+	stats        *ConnStats
+
+	mu        sync.Mutex
+	transport *http.Transport
+	version   uint64
+}
+
+// NewPooledTransport creates the transport of a provider. newTransport builds the
+// provider specific transport (dialer, TLS), its pool fields are overwritten, the
+// TLS policy is applied and HTTP/2 is turned off when the provider forces HTTP/1.1.
+// Stats are kept per provider across transports, so they survive adapter refreshes.
+func NewPooledTransport(p *domain.Provider, newTransport func() *http.Transport) *PooledTransport {
+	stats, _ := connStats.LoadOrStore(p.ID, &ConnStats{})
+	return &PooledTransport{
+		newTransport: newTransport,
+		forceHTTP1:   p.Config != nil && p.Config.ForceHTTP1,
+		stats:        stats.(*ConnStats),
+	}
+}
+
+func (t *PooledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.stats.requests.Add(1)
+	gotConn := func(info httptrace.GotConnInfo) {
+		if info.Reused {
+			t.stats.reused.Add(1)
+		} else {
+			t.stats.dialed.Add(1)
+		}
+	}
+	trace := &httptrace.ClientTrace{GotConn: gotConn}
+	if rec := timingRecorderFrom(req.Context()); rec != nil {
+		rec.start()
+		trace = rec.trace(gotConn)
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	return t.current().RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of the current transport
+func (t *PooledTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.transport != nil {
+		t.transport.CloseIdleConnections()
+	}
+}
+
+// current returns the transport, rebuilt when the pool config or TLS policy changed since it was built
+func (t *PooledTransport) current() *http.Transport {
+	version := poolVersion.Load()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.transport != nil && t.version == version {
+		return t.transport
+	}
+	old := t.transport
+	cfg := CurrentPoolConfig()
+	transport := t.newTransport()
+	transport.MaxIdleConns = cfg.MaxIdleConns
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = cfg.IdleConnTimeout
+	applyTLSPolicy(transport, CurrentTLSPolicy())
+	if t.forceHTTP1 {
+		useHTTP1Only(transport)
+	}
+	t.transport, t.version = transport, version
+	if old != nil {
+		// In-flight requests keep their connections, only idle ones are dropped
+		old.CloseIdleConnections()
+	}
+	return transport
+}
+
+// useHTTP1Only makes a transport speak HTTP/1.1 only, HTTP/2 is neither negotiated
+// over TLS (ALPN) nor attempted
+func useHTTP1Only(transport *http.Transport) {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	transport.Protocols = &protocols
+	transport.ForceAttemptHTTP2 = false
+	if transport.TLSClientConfig != nil {
+		// A TLS config offering h2 would still let the upstream pick it
+		transport.TLSClientConfig = transport.TLSClientConfig.Clone()
+		transport.TLSClientConfig.NextProtos = []string{"http/1.1"}
+	}
+}
@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func newTestServer(t testing.TB) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func doRequest(t testing.TB, client *http.Client, url string) {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+func TestPooledTransportReusesConnections(t *testing.T) {
+	srv := newTestServer(t)
+	transport := NewPooledTransport(&domain.Provider{ID: 1001}, func() *http.Transport { return &http.Transport{} })
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	const n = 10
+	for i := 0; i < n; i++ {
+		doRequest(t, client, srv.URL)
+	}
+
+	requests, reused, dialed := ConnStatsFor(1001).Counts()
+	if requests != n || dialed != 1 || reused != n-1 {
+		t.Errorf("requests=%d reused=%d dialed=%d, want %d, %d, 1", requests, reused, dialed, n, n-1)
+	}
+	if ConnStatsFor(1002) != nil {
+		t.Error("provider without requests should have no stats")
+	}
+}
+
+func TestPooledTransportAppliesPoolConfig(t *testing.T) {
+	t.Cleanup(func() { SetPoolConfig(DefaultPoolConfig) })
+	srv := newTestServer(t)
+	transport := NewPooledTransport(&domain.Provider{ID: 1003}, func() *http.Transport { return &http.Transport{} })
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	doRequest(t, client, srv.URL)
+	first := transport.current()
+	if first.MaxIdleConnsPerHost != DefaultPoolConfig.MaxIdleConnsPerHost {
+		t.Errorf("MaxIdleConnsPerHost = %d, want the default", first.MaxIdleConnsPerHost)
+	}
+
+	SetPoolConfig(PoolConfig{MaxIdleConns: 8, MaxIdleConnsPerHost: 4, IdleConnTimeout: time.Second})
+	doRequest(t, client, srv.URL)
+	second := transport.current()
+	if second == first {
+		t.Fatal("transport was not rebuilt after the pool config changed")
+	}
+	if second.MaxIdleConns != 8 || second.MaxIdleConnsPerHost != 4 || second.IdleConnTimeout != time.Second {
+		t.Errorf("pool fields = %d/%d/%s, want 8/4/1s", second.MaxIdleConns, second.MaxIdleConnsPerHost, second.IdleConnTimeout)
+	}
+
+	// The rebuilt transport dialed again, the next request reuses its connection
+	doRequest(t, client, srv.URL)
+	if _, reused, dialed := ConnStatsFor(1003).Counts(); dialed != 2 || reused != 1 {
+		t.Errorf("reused=%d dialed=%d, want 1, 2", reused, dialed)
+	}
+}
+
+func TestPooledTransportForceHTTP1(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	proto := func(p *domain.Provider) string {
+		transport := NewPooledTransport(p, func() *http.Transport {
+			return srv.Client().Transport.(*http.Transport).Clone()
+		})
+		defer transport.CloseIdleConnections()
+		resp, err := (&http.Client{Transport: transport}).Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	// Default behavior negotiates HTTP/2 with an upstream that offers it
+	if got := proto(&domain.Provider{ID: 1005}); got != "HTTP/2.0" {
+		t.Errorf("default protocol = %s, want HTTP/2.0", got)
+	}
+	forced := &domain.Provider{ID: 1006, Config: &domain.ProviderConfig{ForceHTTP1: true}}
+	if got := proto(forced); got != "HTTP/1.1" {
+		t.Errorf("forced protocol = %s, want HTTP/1.1", got)
+	}
+
+	transport := NewPooledTransport(forced, func() *http.Transport { return &http.Transport{ForceAttemptHTTP2: true} })
+	if tr := transport.current(); tr.ForceAttemptHTTP2 || tr.Protocols == nil || tr.Protocols.HTTP2() || !tr.Protocols.HTTP1() {
+		t.Errorf("transport protocols = %v, ForceAttemptHTTP2 = %v; want HTTP/1.1 only", tr.Protocols, tr.ForceAttemptHTTP2)
+	}
+}
+
+func BenchmarkPooledTransport(b *testing.B) {
+	srv := newTestServer(b)
+	transport := NewPooledTransport(&domain.Provider{ID: 1004}, func() *http.Transport { return &http.Transport{} })
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		doRequest(b, client, srv.URL)
+	}
+	b.StopTimer()
+	_, reused, dialed := ConnStatsFor(1004).Counts()
+	b.ReportMetric(float64(reused)*100/float64(reused+dialed), "reuse%")
+}
@@ -0,0 +1,345 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ctxutil "github.com/awsl-project/maxx/internal/context"
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/event"
+	"github.com/awsl-project/maxx/internal/executor"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+const (
+	// DefaultConcurrency is used when the batch concurrency setting is not configured
+	DefaultConcurrency = 1
+
+	// pollInterval is how often the processor looks for work without being notified
+	pollInterval = 5 * time.Second
+)
+
+// Processor executes the items of Message Batches in the background.
+// Each item runs through the executor like a regular non-streaming
+// /v1/messages request, so routing, retries, cooldowns and request logging
+// all apply. Up to the configured batch concurrency (DefaultConcurrency when
+// unset) items run at once, but batch work has lower priority than live
+// traffic: while live requests are in flight at most one item runs. Items
+// bypass the global concurrency limiter, see limiter.Global.
+type Processor struct {
+	executor    *executor.Executor
+	repo        repository.MessageBatchRepository
+	settingRepo repository.SystemSettingRepository
+	broadcaster event.Broadcaster
+
+	running atomic.Int64
+	wake    chan struct{}
+	countMu sync.Mutex // serializes batch count refreshes
+	now     func() time.Time
+}
+
+// NewProcessor creates a batch processor, call Start to begin processing
+func NewProcessor(
+	exec *executor.Executor,
+	repo repository.MessageBatchRepository,
+	settingRepo repository.SystemSettingRepository,
+	bc event.Broadcaster,
+) *Processor {
+	return &Processor{
+		executor:    exec,
+		repo:        repo,
+		settingRepo: settingRepo,
+		broadcaster: bc,
+		wake:        make(chan struct{}, 1),
+		now:         time.Now,
+	}
+}
+
+// Notify wakes the processor after a batch was created or canceled
+func (p *Processor) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the processing loop until ctx is done.
+// Items left in processing by a previous run are put back to pending first.
+func (p *Processor) Start(ctx context.Context) {
+	p.recover()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		p.sweep()
+		p.dispatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// recover resets items interrupted by a restart so they are processed again
+func (p *Processor) recover() {
+	batches, err := p.repo.ListUnfinished()
+	if err != nil {
+		log.Printf("[Batch] Failed to list unfinished batches: %v", err)
+		return
+	}
+	for _, b := range batches {
+		n, err := p.repo.UpdateItemsStatus(b.ID, []string{domain.MessageBatchItemProcessing}, domain.MessageBatchItemPending)
+		if err != nil {
+			log.Printf("[Batch] Failed to reset items of %s: %v", b.BatchID, err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("[Batch] Resumed %d interrupted items of %s", n, b.BatchID)
+		}
+	}
+}
+
+// sweep finalizes canceled and expired batches
+func (p *Processor) sweep() {
+	batches, err := p.repo.ListUnfinished()
+	if err != nil {
+		log.Printf("[Batch] Failed to list unfinished batches: %v", err)
+		return
+	}
+	now := p.now()
+	for _, b := range batches {
+		switch {
+		case b.ProcessingStatus == domain.MessageBatchCanceling:
+			_, err = p.repo.UpdateItemsStatus(b.ID, []string{domain.MessageBatchItemPending}, domain.MessageBatchItemCanceled)
+		case !now.Before(b.ExpiresAt):
+			_, err = p.repo.UpdateItemsStatus(b.ID, []string{domain.MessageBatchItemPending}, domain.MessageBatchItemExpired)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Printf("[Batch] Failed to finalize pending items of %s: %v", b.BatchID, err)
+			continue
+		}
+		p.refresh(b.ID)
+	}
+}
+
+// dispatch starts items until the concurrency limit is reached or no work is left
+func (p *Processor) dispatch(ctx context.Context) {
+	for ctx.Err() == nil {
+		running := p.running.Load()
+		if running >= p.limit() {
+			return
+		}
+
+		item, err := p.repo.ClaimNextItem()
+		if err != nil {
+			log.Printf("[Batch] Failed to claim next item: %v", err)
+			return
+		}
+		if item == nil {
+			return
+		}
+
+		p.running.Add(1)
+		go func() {
+			defer func() {
+				p.running.Add(-1)
+				p.Notify()
+			}()
+			p.process(ctx, item)
+		}()
+	}
+}
+
+// limit returns how many items may run right now
+func (p *Processor) limit() int64 {
+	concurrency := int64(DefaultConcurrency)
+	if val, err := p.settingRepo.Get(domain.SettingKeyBatchConcurrency); err == nil && val != "" {
+		if n, err := strconv.ParseInt(val, 10, 64); err == nil && n > 0 {
+			concurrency = n
+		}
+	}
+	// Live requests are everything in flight that isn't one of ours
+	if p.executor.InFlight()-p.running.Load() > 0 {
+		return 1
+	}
+	return concurrency
+}
+
+// process executes a single item and stores its result
+func (p *Processor) process(ctx context.Context, item *domain.MessageBatchItem) {
+	b, err := p.repo.GetByID(item.MessageBatchID)
+	if err != nil {
+		log.Printf("[Batch] Failed to load batch %d: %v", item.MessageBatchID, err)
+		return
+	}
+
+	status, result := p.execute(ctx, b, item)
+	if ctx.Err() != nil || status == "" {
+		// Shutting down, the item is picked up again on the next start
+		return
+	}
+
+	item.Status = status
+	item.Result = string(result)
+	if err := p.repo.UpdateItem(item); err != nil {
+		log.Printf("[Batch] Failed to save result of %s/%s: %v", b.BatchID, item.CustomID, err)
+	}
+	p.refresh(b.ID)
+}
+
+// execute runs the item through the executor and returns its status and
+// Anthropic batch result object, or an empty status when the server shutdown
+// interrupted it
+func (p *Processor) execute(ctx context.Context, b *domain.MessageBatch, item *domain.MessageBatchItem) (string, json.RawMessage) {
+	body := []byte(item.Params)
+	var params struct {
+		Model string `json:"model"`
+	}
+	_ = json.Unmarshal(body, &params)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return domain.MessageBatchItemErrored, erroredResult("api_error", err.Error())
+	}
+	for key, value := range b.RequestHeaders {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	ctx = ctxutil.WithClientType(ctx, domain.ClientTypeClaude)
+	ctx = ctxutil.WithSessionID(ctx, b.BatchID)
+	ctx = ctxutil.WithRequestModel(ctx, params.Model)
+	ctx = ctxutil.WithRequestBody(ctx, body)
+	ctx = ctxutil.WithRequestHeaders(ctx, req.Header)
+	ctx = ctxutil.WithRequestURI(ctx, "/v1/messages")
+	ctx = ctxutil.WithIsStream(ctx, false)
+	ctx = ctxutil.WithAPITokenID(ctx, b.APITokenID)
+	ctx = ctxutil.WithProjectID(ctx, b.ProjectID)
+
+	w := newBufferedResponseWriter()
+	if err := p.executor.Execute(ctx, w, req); err != nil {
+		if errors.Is(err, domain.CancelReasonShutdown) {
+			return "", nil
+		}
+		return domain.MessageBatchItemErrored, erroredResult("api_error", err.Error())
+	}
+
+	if w.status != http.StatusOK {
+		var upstream struct {
+			Error json.RawMessage `json:"error"`
+		}
+		if json.Unmarshal(w.body.Bytes(), &upstream) == nil && len(upstream.Error) > 0 {
+			result, _ := json.Marshal(map[string]interface{}{
+				"type":  domain.MessageBatchItemErrored,
+				"error": map[string]interface{}{"type": "error", "error": upstream.Error},
+			})
+			return domain.MessageBatchItemErrored, result
+		}
+		return domain.MessageBatchItemErrored, erroredResult("api_error", "upstream returned status "+strconv.Itoa(w.status))
+	}
+
+	if !json.Valid(w.body.Bytes()) {
+		return domain.MessageBatchItemErrored, erroredResult("api_error", "upstream returned an invalid message")
+	}
+	result, _ := json.Marshal(map[string]interface{}{
+		"type":    domain.MessageBatchItemSucceeded,
+		"message": json.RawMessage(w.body.Bytes()),
+	})
+	return domain.MessageBatchItemSucceeded, result
+}
+
+// refresh recomputes the batch's request counts and ends it once no item is left
+func (p *Processor) refresh(batchID uint64) {
+	p.countMu.Lock()
+	defer p.countMu.Unlock()
+
+	b, err := p.repo.GetByID(batchID)
+	if err != nil {
+		log.Printf("[Batch] Failed to load batch %d: %v", batchID, err)
+		return
+	}
+	counts, err := p.repo.CountItems(batchID)
+	if err != nil {
+		log.Printf("[Batch] Failed to count items of %s: %v", b.BatchID, err)
+		return
+	}
+
+	b.RequestCounts = domain.MessageBatchCounts{
+		Processing: counts[domain.MessageBatchItemPending] + counts[domain.MessageBatchItemProcessing],
+		Succeeded:  counts[domain.MessageBatchItemSucceeded],
+		Errored:    counts[domain.MessageBatchItemErrored],
+		Canceled:   counts[domain.MessageBatchItemCanceled],
+		Expired:    counts[domain.MessageBatchItemExpired],
+	}
+	if b.RequestCounts.Processing == 0 && b.ProcessingStatus != domain.MessageBatchEnded {
+		now := p.now()
+		b.ProcessingStatus = domain.MessageBatchEnded
+		b.EndedAt = &now
+		log.Printf("[Batch] %s ended: succeeded=%d errored=%d canceled=%d expired=%d", b.BatchID,
+			b.RequestCounts.Succeeded, b.RequestCounts.Errored, b.RequestCounts.Canceled, b.RequestCounts.Expired)
+	}
+	if err := p.repo.Update(b); err != nil {
+		log.Printf("[Batch] Failed to update batch %s: %v", b.BatchID, err)
+		return
+	}
+
+	if p.broadcaster != nil {
+		p.broadcaster.BroadcastMessage("message_batch_update", b)
+	}
+}
+
+// erroredResult builds an "errored" batch result with an Anthropic error object
+func erroredResult(errType, message string) json.RawMessage {
+	result, _ := json.Marshal(map[string]interface{}{
+		"type": domain.MessageBatchItemErrored,
+		"error": map[string]interface{}{
+			"type": "error",
+			"error": map[string]interface{}{
+				"type":    errType,
+				"message": message,
+			},
+		},
+	})
+	return result
+}
+
+// bufferedResponseWriter collects the executor's response in memory
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{header: make(http.Header)}
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
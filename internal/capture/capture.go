@@ -0,0 +1,270 @@
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/redaction"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+// Defaults used when the capture settings are not configured
+const (
+	DefaultMaxSizeMB  = 100
+	DefaultMaxFiles   = 10
+	DefaultBufferSize = 1000
+)
+
+// failureBackoff is how long records are dropped after a failed write, so a full
+// disk isn't retried for every request
+const failureBackoff = 30 * time.Second
+
+// Config selects the capture file and what is written to it
+type Config struct {
+	// Path is the JSONL file, empty disables capture
+	Path string
+	// MaxSize is the size in bytes at which the file is rotated, 0 never rotates
+	MaxSize int64
+	// MaxFiles is the number of rotated files kept, 0 keeps all
+	MaxFiles int
+	// SampleRate is the fraction of completed requests captured, from 0 to 1
+	SampleRate float64
+	// RedactionRules are applied to the captured bodies on top of the global and provider rules
+	RedactionRules []domain.RedactionRule
+	// BufferSize is the number of records waiting to be written before new ones are dropped
+	BufferSize int
+}
+
+// Stats is a snapshot of the capture state
+type Stats struct {
+	Enabled  bool   `json:"enabled"`
+	Buffered int    `json:"buffered"`
+	Written  uint64 `json:"written"`
+	Dropped  uint64 `json:"dropped"` // buffer was full
+	Failed   uint64 `json:"failed"`  // write failed, e.g. the disk is full
+}
+
+// Capturer mirrors sampled request/response pairs of completed requests to JSONL
+// files for offline processing. Submit never blocks the request path: records
+// wait in a bounded buffer for a background writer and are dropped when it is full.
+type Capturer struct {
+	mu       sync.RWMutex
+	pipeline *pipeline
+	sample   func() float64
+
+	written  atomic.Uint64
+	dropped  atomic.Uint64
+	failed   atomic.Uint64
+	dropping atomic.Bool
+}
+
+// pipeline is a capture file with its buffer and writer
+type pipeline struct {
+	config   Config
+	redactor *redaction.Redactor
+	queue    chan Record
+	done     chan struct{}
+}
+
+// New creates a capturer without a file
+func New() *Capturer {
+	return &Capturer{sample: rand.Float64}
+}
+
+// Configure replaces the capture file. Records buffered for the previous file are
+// still written to it in the background.
+func (c *Capturer) Configure(config Config) error {
+	if config.Path == "" {
+		c.swap(nil)
+		return nil
+	}
+	redactor, err := redaction.New(config.RedactionRules)
+	if err != nil {
+		return err
+	}
+	p := &pipeline{
+		config:   config,
+		redactor: redactor,
+		queue:    make(chan Record, max(config.BufferSize, 1)),
+		done:     make(chan struct{}),
+	}
+	go c.run(p, newRotatingFile(config.Path, config.MaxSize, config.MaxFiles))
+	c.swap(p)
+	return nil
+}
+
+// swap installs p and stops the previous pipeline, returning it
+func (c *Capturer) swap(p *pipeline) *pipeline {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := c.pipeline
+	c.pipeline = p
+	if old != nil {
+		close(old.queue)
+	}
+	return old
+}
+
+// Close stops capturing and waits until the buffered records are written
+func (c *Capturer) Close() {
+	if old := c.swap(nil); old != nil {
+		<-old.done
+	}
+}
+
+// Submit queues a sample of completed requests, dropping it when the buffer is full
+func (c *Capturer) Submit(req *domain.ProxyRequest) {
+	if req.Status != "COMPLETED" || req.RequestInfo == nil || req.ResponseInfo == nil {
+		return
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	p := c.pipeline
+	if p == nil || c.sample() >= p.config.SampleRate {
+		return
+	}
+	select {
+	case p.queue <- NewRecord(req, p.redactor):
+		c.dropping.Store(false)
+	default:
+		c.dropped.Add(1)
+		if !c.dropping.Swap(true) {
+			log.Printf("[Capture] Buffer full, dropping captured requests until the writer catches up")
+		}
+	}
+}
+
+// Stats returns the current capture state
+func (c *Capturer) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	stats := Stats{
+		Written: c.written.Load(),
+		Dropped: c.dropped.Load(),
+		Failed:  c.failed.Load(),
+	}
+	if c.pipeline != nil {
+		stats.Enabled = true
+		stats.Buffered = len(c.pipeline.queue)
+	}
+	return stats
+}
+
+// run writes the buffered records until the queue is closed. After a failed write
+// records are dropped for failureBackoff, then writing is tried again.
+func (c *Capturer) run(p *pipeline, file *rotatingFile) {
+	defer close(p.done)
+	defer file.Close()
+
+	var failedAt time.Time
+	for record := range p.queue {
+		if !failedAt.IsZero() && time.Since(failedAt) < failureBackoff {
+			c.failed.Add(1)
+			continue
+		}
+		line, err := json.Marshal(record)
+		if err != nil {
+			c.failed.Add(1)
+			continue
+		}
+		if err := file.Write(append(line, '\n')); err != nil {
+			c.failed.Add(1)
+			if failedAt.IsZero() {
+				log.Printf("[Capture] Failed to write to %s, dropping captured requests for %v: %v", p.config.Path, failureBackoff, err)
+			}
+			failedAt = time.Now()
+			// Reopen on the next try, the file may have been removed or the disk replaced
+			_ = file.Close()
+			continue
+		}
+		if !failedAt.IsZero() {
+			log.Printf("[Capture] Writing to %s again", p.config.Path)
+			failedAt = time.Time{}
+		}
+		c.written.Add(1)
+	}
+}
+
+// ==================== Global capturer ====================
+
+var global = New()
+
+// Global returns the process-wide capturer used by the executor
+func Global() *Capturer {
+	return global
+}
+
+// LoadFromSettings applies the capture settings to the global capturer
+func LoadFromSettings(settingRepo repository.SystemSettingRepository) error {
+	config, err := ConfigFromSettings(settingRepo)
+	if err != nil {
+		return err
+	}
+	return global.Configure(config)
+}
+
+// ConfigFromSettings reads the capture config, invalid numbers fall back to the defaults
+func ConfigFromSettings(settingRepo repository.SystemSettingRepository) (Config, error) {
+	path, _ := settingRepo.Get(domain.SettingKeyCapturePath)
+	config := Config{
+		Path:       strings.TrimSpace(path),
+		MaxSize:    int64(settingInt(settingRepo, domain.SettingKeyCaptureMaxSizeMB, DefaultMaxSizeMB)) << 20,
+		MaxFiles:   settingInt(settingRepo, domain.SettingKeyCaptureMaxFiles, DefaultMaxFiles),
+		SampleRate: 1,
+		BufferSize: settingInt(settingRepo, domain.SettingKeyCaptureBuffer, DefaultBufferSize),
+	}
+	if val, err := settingRepo.Get(domain.SettingKeyCaptureSampleRate); err == nil && val != "" {
+		if rate, err := ParseSampleRate(val); err == nil {
+			config.SampleRate = rate
+		}
+	}
+	if raw, err := settingRepo.Get(domain.SettingKeyCaptureRedactionRules); err == nil {
+		rules, err := redaction.ParseRules(raw)
+		if err != nil {
+			return config, err
+		}
+		config.RedactionRules = rules
+	}
+	return config, nil
+}
+
+// ParseSampleRate parses a sample rate between 0 and 1
+func ParseSampleRate(val string) (float64, error) {
+	rate, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("invalid capture sample rate %q: must be between 0 and 1", val)
+	}
+	return rate, nil
+}
+
+func settingInt(settingRepo repository.SystemSettingRepository, key string, def int) int {
+	val, err := settingRepo.Get(key)
+	if err != nil || val == "" {
+		return def
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
+
+// ValidatePath checks that the capture file can be written, creating it
+func ValidatePath(path string) error {
+	if path == "" {
+		return nil
+	}
+	f := newRotatingFile(path, 0, 0)
+	if err := f.open(); err != nil {
+		return fmt.Errorf("invalid capture path %q: %w", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,207 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func completedRequest(id string) *domain.ProxyRequest {
+	return &domain.ProxyRequest{
+		RequestID:    id,
+		ClientType:   domain.ClientTypeClaude,
+		RequestModel: "claude-sonnet-4",
+		ProviderID:   1,
+		Status:       "COMPLETED",
+		StatusCode:   200,
+		RequestInfo: &domain.RequestInfo{
+			Method:  "POST",
+			URL:     "/v1/messages",
+			Headers: map[string]string{"authorization": "Bearer maxx_secret"},
+			Body:    "{\n  \"messages\": [{\"role\": \"user\", \"content\": \"hi\"}],\n  \"metadata\": {\"user_id\": \"u1\"}\n}",
+		},
+		ResponseInfo: &domain.ResponseInfo{Status: 200, Body: "event: message_start\ndata: {}\n\n"},
+	}
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+func TestCaptureWritesJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture", "pairs.jsonl")
+	c := New()
+	err := c.Configure(Config{
+		Path:           path,
+		SampleRate:     1,
+		BufferSize:     10,
+		RedactionRules: []domain.RedactionRule{{Type: "jsonpath", Pattern: "$.metadata.user_id", Target: "request"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Submit(completedRequest("a"))
+	failed := completedRequest("b")
+	failed.Status = "FAILED"
+	c.Submit(failed)
+	c.Submit(completedRequest("c"))
+	c.Close()
+
+	lines := readLines(t, path)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want the 2 completed requests", len(lines))
+	}
+	var record struct {
+		RequestID string          `json:"requestID"`
+		Request   json.RawMessage `json:"request"`
+		Response  json.RawMessage `json:"response"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("invalid line %q: %v", lines[0], err)
+	}
+	if record.RequestID != "a" {
+		t.Errorf("requestID = %q", record.RequestID)
+	}
+	// JSON bodies are embedded as objects, other bodies as strings
+	var body map[string]interface{}
+	if err := json.Unmarshal(record.Request, &body); err != nil || body["messages"] == nil {
+		t.Errorf("request = %s, want the embedded JSON body", record.Request)
+	}
+	if strings.Contains(string(record.Request), "u1") {
+		t.Errorf("request = %s, capture redaction rule not applied", record.Request)
+	}
+	var response string
+	if err := json.Unmarshal(record.Response, &response); err != nil || !strings.HasPrefix(response, "event: message_start") {
+		t.Errorf("response = %s, want the SSE text as a string", record.Response)
+	}
+	if strings.Contains(lines[0], "maxx_secret") {
+		t.Error("client credentials captured")
+	}
+	if stats := c.Stats(); stats.Written != 2 || stats.Enabled {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestCaptureRotates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pairs.jsonl")
+	f := newRotatingFile(path, 100, 2)
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	rotations := 0
+	f.now = func() time.Time {
+		rotations++
+		return base.Add(time.Duration(rotations) * time.Second)
+	}
+	defer f.Close()
+
+	line := []byte(strings.Repeat("x", 39) + "\n") // 40 bytes, two per file
+	for i := 0; i < 7; i++ {
+		if err := f.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 7 lines: 3 full files rotated, the oldest pruned, 1 line in the current file
+	rotated := f.rotated()
+	want := []string{
+		filepath.Join(dir, "pairs-20260102-030407.000.jsonl"),
+		filepath.Join(dir, "pairs-20260102-030408.000.jsonl"),
+	}
+	if strings.Join(rotated, ",") != strings.Join(want, ",") {
+		t.Errorf("rotated files = %v, want %v", rotated, want)
+	}
+	for _, name := range rotated {
+		if lines := readLines(t, name); len(lines) != 2 {
+			t.Errorf("%s has %d lines, want 2", name, len(lines))
+		}
+	}
+	if lines := readLines(t, path); len(lines) != 1 {
+		t.Errorf("current file has %d lines, want 1", len(lines))
+	}
+
+	// A rotation in the same instant gets its own file
+	f.now = func() time.Time { return base.Add(3 * time.Second) }
+	f.maxFiles = 0
+	for i := 0; i < 2; i++ {
+		if err := f.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := len(f.rotated()); got != 3 {
+		t.Errorf("rotated files after a same-instant rotation = %d, want 3", got)
+	}
+}
+
+func TestCaptureSamples(t *testing.T) {
+	for _, tt := range []struct {
+		rate float64
+		want uint64
+	}{{0, 0}, {0.25, 25}, {1, 100}} {
+		c := New()
+		// Deterministic samples spread evenly over [0, 1)
+		n := 0
+		c.sample = func() float64 {
+			n++
+			return float64(n-1) / 100
+		}
+		if err := c.Configure(Config{Path: filepath.Join(t.TempDir(), "pairs.jsonl"), SampleRate: tt.rate, BufferSize: 100}); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100; i++ {
+			c.Submit(completedRequest("r"))
+		}
+		c.Close()
+		if got := c.Stats().Written; got != tt.want {
+			t.Errorf("rate %v captured %d of 100 requests, want %d", tt.rate, got, tt.want)
+		}
+	}
+
+	for _, val := range []string{"-0.1", "1.5", "half"} {
+		if _, err := ParseSampleRate(val); err == nil {
+			t.Errorf("ParseSampleRate(%q) accepted", val)
+		}
+	}
+}
+
+func TestCaptureSurvivesFullDisk(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("/dev/full not available")
+	}
+	c := New()
+	if err := c.Configure(Config{Path: "/dev/full", SampleRate: 1, BufferSize: 10}); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			c.Submit(completedRequest("r"))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Submit blocked on a full disk")
+	}
+	c.Close()
+	if stats := c.Stats(); stats.Failed != 5 || stats.Written != 0 {
+		t.Errorf("stats = %+v, want 5 failed writes", stats)
+	}
+}
@@ -0,0 +1,122 @@
+package capture
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rotatingFile appends lines to a file, moving it aside once it reaches maxSize.
+// Rotated files are named after the rotation time (capture.jsonl becomes
+// capture-20060102-150405.000.jsonl) and are never written again, so offline
+// jobs can pick them up as they appear.
+type rotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int // rotated files kept, 0 keeps all
+	now      func() time.Time
+
+	file *os.File
+	size int64
+}
+
+func newRotatingFile(path string, maxSize int64, maxFiles int) *rotatingFile {
+	return &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles, now: time.Now}
+}
+
+// Write appends a line, rotating first when it would overflow the file. A failed
+// write is rolled back so the file never ends with a partial line.
+func (f *rotatingFile) Write(line []byte) error {
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(line)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := f.file.Write(line)
+	if err != nil {
+		if n > 0 {
+			_ = f.file.Truncate(f.size)
+		}
+		return err
+	}
+	f.size += int64(n)
+	return nil
+}
+
+func (f *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// rotate moves the current file aside and starts a new one
+func (f *rotatingFile) rotate() error {
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// Several rotations in the same millisecond must not overwrite each other
+	t := f.now()
+	name := f.rotatedName(t)
+	for _, err := os.Stat(name); err == nil; _, err = os.Stat(name) {
+		t = t.Add(time.Millisecond)
+		name = f.rotatedName(t)
+	}
+	if err := os.Rename(f.path, name); err != nil {
+		return err
+	}
+	f.prune()
+	return f.open()
+}
+
+func (f *rotatingFile) rotatedName(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(f.path, ext), t.Format("20060102-150405.000"), ext)
+}
+
+// rotated lists the rotated files, oldest first
+func (f *rotatingFile) rotated() []string {
+	ext := filepath.Ext(f.path)
+	matches, _ := filepath.Glob(strings.TrimSuffix(f.path, ext) + "-*" + ext)
+	sort.Strings(matches)
+	return matches
+}
+
+// prune deletes the oldest rotated files beyond maxFiles
+func (f *rotatingFile) prune() {
+	if f.maxFiles <= 0 {
+		return
+	}
+	files := f.rotated()
+	for len(files) > f.maxFiles {
+		_ = os.Remove(files[0])
+		files = files[1:]
+	}
+}
+
+func (f *rotatingFile) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,73 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/redaction"
+)
+
+// Record is one request/response pair, a line of the capture files
+type Record struct {
+	Time          time.Time         `json:"time"`
+	RequestID     string            `json:"requestID"`
+	SessionID     string            `json:"sessionID,omitempty"`
+	ClientType    string            `json:"clientType"`
+	RequestModel  string            `json:"requestModel"`
+	ResponseModel string            `json:"responseModel,omitempty"`
+	ProviderID    uint64            `json:"providerID"`
+	ProjectID     uint64            `json:"projectID,omitempty"`
+	StatusCode    int               `json:"statusCode"`
+	DurationMs    int64             `json:"durationMs"`
+	IsStream      bool              `json:"isStream"`
+	InputTokens   uint64            `json:"inputTokens"`
+	OutputTokens  uint64            `json:"outputTokens"`
+	Tags          []string          `json:"tags,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	// Request and Response are the bodies, embedded as JSON when they parse and as
+	// a string otherwise (streamed responses are kept as the SSE text)
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+}
+
+// NewRecord builds the record of a completed request. Bodies are the stored ones,
+// already redacted by the global and provider rules; redactor applies the capture
+// rules on top when set.
+func NewRecord(req *domain.ProxyRequest, redactor *redaction.Redactor) Record {
+	record := Record{
+		Time:          req.EndTime,
+		RequestID:     req.RequestID,
+		SessionID:     req.SessionID,
+		ClientType:    string(req.ClientType),
+		RequestModel:  req.RequestModel,
+		ResponseModel: req.ResponseModel,
+		ProviderID:    req.ProviderID,
+		ProjectID:     req.ProjectID,
+		StatusCode:    req.StatusCode,
+		DurationMs:    req.Duration.Milliseconds(),
+		IsStream:      req.IsStream,
+		InputTokens:   req.InputTokenCount,
+		OutputTokens:  req.OutputTokenCount,
+		Tags:          req.Tags,
+		Metadata:      req.Metadata,
+	}
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	record.Request = embedBody(redactor.Redact(domain.RedactionTargetRequest, req.RequestInfo.Body))
+	record.Response = embedBody(redactor.Redact(domain.RedactionTargetResponse, req.ResponseInfo.Body))
+	return record
+}
+
+// embedBody returns a body as JSON to embed in the record, compacted so the record
+// stays on one line
+func embedBody(body string) json.RawMessage {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(body)); err == nil {
+		return compact.Bytes()
+	}
+	encoded, _ := json.Marshal(body)
+	return encoded
+}
@@ -2,6 +2,7 @@ package context
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/awsl-project/maxx/internal/domain"
@@ -27,6 +28,10 @@ const (
 	CtxKeyIsStream           contextKey = "is_stream"
 	CtxKeyAPITokenID         contextKey = "api_token_id"
 	CtxKeyEventChan          contextKey = "event_chan"
+	CtxKeySkipModelMapping   contextKey = "skip_model_mapping" // Forward the request model as is, without model mapping
+	CtxKeyForcedProviderID   contextKey = "forced_provider_id" // Admin debug bypass: send the request to this provider only
+	CtxKeyReplay             contextKey = "replay"             // The request is an admin replay of a captured request
+	CtxKeyModelDefaulted     contextKey = "model_defaulted"    // The request named no model and was given the project's default
 )
 
 // Setters
@@ -206,3 +211,61 @@ func GetEventChan(ctx context.Context) domain.AdapterEventChan {
 	}
 	return nil
 }
+
+func WithSkipModelMapping(ctx context.Context, skip bool) context.Context {
+	return context.WithValue(ctx, CtxKeySkipModelMapping, skip)
+}
+
+func GetSkipModelMapping(ctx context.Context) bool {
+	if v, ok := ctx.Value(CtxKeySkipModelMapping).(bool); ok {
+		return v
+	}
+	return false
+}
+
+func WithForcedProviderID(ctx context.Context, providerID uint64) context.Context {
+	return context.WithValue(ctx, CtxKeyForcedProviderID, providerID)
+}
+
+func GetForcedProviderID(ctx context.Context) uint64 {
+	if v, ok := ctx.Value(CtxKeyForcedProviderID).(uint64); ok {
+		return v
+	}
+	return 0
+}
+
+func WithReplay(ctx context.Context, replay bool) context.Context {
+	return context.WithValue(ctx, CtxKeyReplay, replay)
+}
+
+func GetReplay(ctx context.Context) bool {
+	if v, ok := ctx.Value(CtxKeyReplay).(bool); ok {
+		return v
+	}
+	return false
+}
+
+func WithModelDefaulted(ctx context.Context, defaulted bool) context.Context {
+	return context.WithValue(ctx, CtxKeyModelDefaulted, defaulted)
+}
+
+func GetModelDefaulted(ctx context.Context) bool {
+	if v, ok := ctx.Value(CtxKeyModelDefaulted).(bool); ok {
+		return v
+	}
+	return false
+}
+
+// GetCancelReason returns why the request's context was cancelled, a client
+// disconnect unless the canceller recorded a domain.CancelReason as the cause.
+// Returns an empty reason while the context is live.
+func GetCancelReason(ctx context.Context) domain.CancelReason {
+	if ctx.Err() == nil {
+		return ""
+	}
+	var reason domain.CancelReason
+	if errors.As(context.Cause(ctx), &reason) {
+		return reason
+	}
+	return domain.CancelReasonClientDisconnect
+}
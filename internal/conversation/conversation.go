@@ -0,0 +1,363 @@
+// Package conversation reconstructs the flow of a session from its proxy requests:
+// the ordered turns with their models, providers and status, and a compact view
+// of the last message sent and the response received.
+package conversation
+
+import (
+	"bufio"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// MaxTextLength caps the text of a compact message, in runes
+const MaxTextLength = 500
+
+// Message is a compact view of a request or response message
+type Message struct {
+	Role string `json:"role,omitempty"`
+	Text string `json:"text,omitempty"`
+
+	// Names of the tools called (or whose results are returned) in the message
+	ToolCalls []string `json:"toolCalls,omitempty"`
+
+	// Text was cut to MaxTextLength
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Turn is one request of a session
+type Turn struct {
+	ProxyRequestID uint64            `json:"proxyRequestID"`
+	RequestID      string            `json:"requestID"`
+	ClientType     domain.ClientType `json:"clientType"`
+	StartTime      time.Time         `json:"startTime"`
+	Duration       time.Duration     `json:"duration"`
+
+	RequestModel  string `json:"requestModel"`
+	MappedModel   string `json:"mappedModel,omitempty"`
+	ResponseModel string `json:"responseModel,omitempty"`
+
+	ProviderID   uint64 `json:"providerID"`
+	ProviderName string `json:"providerName,omitempty"`
+
+	Status     string `json:"status"`
+	StatusCode int    `json:"statusCode"`
+	Error      string `json:"error,omitempty"`
+
+	InputTokenCount  uint64 `json:"inputTokenCount"`
+	OutputTokenCount uint64 `json:"outputTokenCount"`
+	Cost             uint64 `json:"cost"`
+
+	// Number of messages in the request, the whole history the client sent
+	MessageCount int `json:"messageCount"`
+
+	LastMessage *Message `json:"lastMessage,omitempty"`
+	Response    *Message `json:"response,omitempty"`
+}
+
+// Conversation is the reconstructed flow of a session, oldest turn first
+type Conversation struct {
+	SessionID string  `json:"sessionID"`
+	Turns     []*Turn `json:"turns"`
+}
+
+// Build reconstructs the conversation of a session from its requests.
+// Bodies are read as stored, so redaction rules must already have been applied.
+func Build(sessionID string, requests []*domain.ProxyRequest) *Conversation {
+	sorted := make([]*domain.ProxyRequest, len(requests))
+	copy(sorted, requests)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ti, tj := startTime(sorted[i]), startTime(sorted[j])
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	conv := &Conversation{SessionID: sessionID, Turns: make([]*Turn, 0, len(sorted))}
+	for _, req := range sorted {
+		conv.Turns = append(conv.Turns, newTurn(req))
+	}
+	return conv
+}
+
+// startTime falls back to the creation time for requests that never started
+func startTime(req *domain.ProxyRequest) time.Time {
+	if req.StartTime.IsZero() {
+		return req.CreatedAt
+	}
+	return req.StartTime
+}
+
+func newTurn(req *domain.ProxyRequest) *Turn {
+	turn := &Turn{
+		ProxyRequestID:   req.ID,
+		RequestID:        req.RequestID,
+		ClientType:       req.ClientType,
+		StartTime:        startTime(req),
+		Duration:         req.Duration,
+		RequestModel:     req.RequestModel,
+		ResponseModel:    req.ResponseModel,
+		ProviderID:       req.ProviderID,
+		Status:           req.Status,
+		StatusCode:       req.StatusCode,
+		Error:            req.Error,
+		InputTokenCount:  req.InputTokenCount,
+		OutputTokenCount: req.OutputTokenCount,
+		Cost:             req.Cost,
+	}
+	if req.RequestInfo != nil {
+		turn.LastMessage, turn.MessageCount = LastMessage(req.ClientType, req.RequestInfo.Body)
+	}
+	if req.ResponseInfo != nil {
+		turn.Response = ResponseMessage(req.ClientType, req.ResponseInfo.Body)
+	}
+	return turn
+}
+
+// LastMessage returns a compact view of the last message of a request body
+// and the number of messages in it
+func LastMessage(clientType domain.ClientType, body string) (*Message, int) {
+	var req map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		return nil, 0
+	}
+
+	var items []json.RawMessage
+	switch {
+	case clientType == domain.ClientTypeGemini || req["contents"] != nil:
+		_ = json.Unmarshal(req["contents"], &items)
+	case clientType == domain.ClientTypeCodex || req["input"] != nil:
+		// Responses API input is either a plain string or a list of items
+		var text string
+		if json.Unmarshal(req["input"], &text) == nil {
+			return compact(&Message{Role: "user", Text: text}), 1
+		}
+		_ = json.Unmarshal(req["input"], &items)
+	default:
+		_ = json.Unmarshal(req["messages"], &items)
+	}
+	if len(items) == 0 {
+		return nil, 0
+	}
+
+	var item map[string]any
+	if err := json.Unmarshal(items[len(items)-1], &item); err != nil {
+		return nil, len(items)
+	}
+	msg := &Message{}
+	msg.Role, _ = item["role"].(string)
+	if msg.Role == "" {
+		// Responses API function_call / function_call_output items have a type instead
+		msg.Role, _ = item["type"].(string)
+	}
+	var text strings.Builder
+	collect(item, &text, msg)
+	msg.Text = text.String()
+	return compact(msg), len(items)
+}
+
+// ResponseMessage returns a compact view of a response body, JSON or SSE
+func ResponseMessage(clientType domain.ClientType, body string) *Message {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil
+	}
+
+	msg := &Message{Role: "assistant"}
+	var text strings.Builder
+	if strings.HasPrefix(body, "{") {
+		var resp map[string]any
+		if err := json.Unmarshal([]byte(body), &resp); err != nil {
+			return nil
+		}
+		collectResponse(clientType, resp, &text, msg)
+	} else {
+		collectStream(clientType, body, &text, msg)
+	}
+	msg.Text = text.String()
+	if msg.Text == "" && len(msg.ToolCalls) == 0 {
+		return nil
+	}
+	return compact(msg)
+}
+
+// collectResponse gathers the text and tool calls of a non-streaming response
+func collectResponse(clientType domain.ClientType, resp map[string]any, text *strings.Builder, msg *Message) {
+	switch clientType {
+	case domain.ClientTypeOpenAI:
+		if choices, ok := resp["choices"].([]any); ok && len(choices) > 0 {
+			if choice, ok := choices[0].(map[string]any); ok {
+				collect(choice["message"], text, msg)
+			}
+		}
+	case domain.ClientTypeCodex:
+		collect(resp["output"], text, msg)
+	case domain.ClientTypeGemini:
+		if candidates, ok := resp["candidates"].([]any); ok && len(candidates) > 0 {
+			if candidate, ok := candidates[0].(map[string]any); ok {
+				collect(candidate["content"], text, msg)
+			}
+		}
+	default:
+		collect(resp["content"], text, msg)
+	}
+}
+
+// collectStream gathers the text deltas and tool calls of an SSE response
+func collectStream(clientType domain.ClientType, body string, text *strings.Builder, msg *Message) {
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(strings.TrimRight(scanner.Text(), "\r"), "data:")
+		if !ok {
+			continue
+		}
+		var event map[string]any
+		if json.Unmarshal([]byte(strings.TrimSpace(data)), &event) != nil {
+			continue
+		}
+
+		switch clientType {
+		case domain.ClientTypeOpenAI:
+			choices, _ := event["choices"].([]any)
+			if len(choices) == 0 {
+				continue
+			}
+			choice, _ := choices[0].(map[string]any)
+			delta, _ := choice["delta"].(map[string]any)
+			if content, ok := delta["content"].(string); ok {
+				text.WriteString(content)
+			}
+			collectToolCalls(delta["tool_calls"], msg)
+		case domain.ClientTypeCodex:
+			switch event["type"] {
+			case "response.output_text.delta":
+				if delta, ok := event["delta"].(string); ok {
+					text.WriteString(delta)
+				}
+			case "response.output_item.added":
+				if item, ok := event["item"].(map[string]any); ok && item["type"] == "function_call" {
+					appendToolCall(msg, item["name"])
+				}
+			}
+		case domain.ClientTypeGemini:
+			// Every Gemini chunk is a partial response whose parts continue the text
+			candidates, _ := event["candidates"].([]any)
+			if len(candidates) == 0 {
+				continue
+			}
+			candidate, _ := candidates[0].(map[string]any)
+			content, _ := candidate["content"].(map[string]any)
+			parts, _ := content["parts"].([]any)
+			for _, part := range parts {
+				p, _ := part.(map[string]any)
+				if p["thought"] == true {
+					continue
+				}
+				if t, ok := p["text"].(string); ok {
+					text.WriteString(t)
+				}
+				if call, ok := p["functionCall"].(map[string]any); ok {
+					appendToolCall(msg, call["name"])
+				}
+			}
+		default:
+			switch event["type"] {
+			case "content_block_start":
+				if block, ok := event["content_block"].(map[string]any); ok && block["type"] == "tool_use" {
+					appendToolCall(msg, block["name"])
+				}
+			case "content_block_delta":
+				if delta, ok := event["delta"].(map[string]any); ok && delta["type"] == "text_delta" {
+					if t, ok := delta["text"].(string); ok {
+						text.WriteString(t)
+					}
+				}
+			}
+		}
+	}
+}
+
+// collect walks a message (or part of one) of any supported format, gathering
+// its text and the names of the tools it calls or answers
+func collect(node any, text *strings.Builder, msg *Message) {
+	switch v := node.(type) {
+	case string:
+		appendText(text, v)
+	case []any:
+		for _, item := range v {
+			collect(item, text, msg)
+		}
+	case map[string]any:
+		switch v["type"] {
+		case "tool_use", "function_call", "tool_result", "function_call_output":
+			appendToolCall(msg, v["name"])
+			if v["type"] == "tool_result" {
+				collect(v["content"], text, msg)
+			} else if output, ok := v["output"].(string); ok {
+				appendText(text, output)
+			}
+			return
+		case "thinking", "redacted_thinking", "reasoning", "image", "image_url", "input_image":
+			return
+		}
+		if v["thought"] == true {
+			return
+		}
+		if t, ok := v["text"].(string); ok {
+			appendText(text, t)
+		}
+		for _, key := range []string{"content", "parts"} {
+			if child, ok := v[key]; ok {
+				collect(child, text, msg)
+			}
+		}
+		collectToolCalls(v["tool_calls"], msg)
+		for _, key := range []string{"functionCall", "functionResponse"} {
+			if call, ok := v[key].(map[string]any); ok {
+				appendToolCall(msg, call["name"])
+			}
+		}
+	}
+}
+
+// collectToolCalls gathers OpenAI tool_calls entries
+func collectToolCalls(node any, msg *Message) {
+	calls, _ := node.([]any)
+	for _, call := range calls {
+		c, _ := call.(map[string]any)
+		if fn, ok := c["function"].(map[string]any); ok {
+			appendToolCall(msg, fn["name"])
+		}
+	}
+}
+
+func appendToolCall(msg *Message, name any) {
+	if s, ok := name.(string); ok && s != "" {
+		msg.ToolCalls = append(msg.ToolCalls, s)
+	}
+}
+
+func appendText(text *strings.Builder, s string) {
+	if s == "" {
+		return
+	}
+	if text.Len() > 0 {
+		text.WriteString("\n")
+	}
+	text.WriteString(s)
+}
+
+// compact trims the message text to MaxTextLength
+func compact(msg *Message) *Message {
+	msg.Text = strings.TrimSpace(msg.Text)
+	if runes := []rune(msg.Text); len(runes) > MaxTextLength {
+		msg.Text = string(runes[:MaxTextLength]) + "…"
+		msg.Truncated = true
+	}
+	return msg
+}
@@ -0,0 +1,213 @@
+package conversation
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestBuildOrdersTurnsByStartTime(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	requests := []*domain.ProxyRequest{
+		{ID: 3, StartTime: base.Add(2 * time.Second), Status: "COMPLETED"},
+		{ID: 1, StartTime: base, Status: "FAILED"},
+		// Same start time, the lower ID came first
+		{ID: 4, StartTime: base.Add(time.Second), Status: "COMPLETED"},
+		{ID: 2, StartTime: base.Add(time.Second), Status: "COMPLETED"},
+		// Rejected before it started, ordered by creation time
+		{ID: 5, CreatedAt: base.Add(3 * time.Second), Status: "REJECTED"},
+	}
+
+	conv := Build("s1", requests)
+	if conv.SessionID != "s1" {
+		t.Errorf("session = %q, want s1", conv.SessionID)
+	}
+	var ids []uint64
+	for _, turn := range conv.Turns {
+		ids = append(ids, turn.ProxyRequestID)
+	}
+	want := []uint64{1, 2, 4, 3, 5}
+	if len(ids) != len(want) {
+		t.Fatalf("turns = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("turns = %v, want %v", ids, want)
+		}
+	}
+	if requests[0].ID != 3 {
+		t.Error("Build reordered the caller's slice")
+	}
+}
+
+func TestLastMessage(t *testing.T) {
+	tests := []struct {
+		name       string
+		clientType domain.ClientType
+		body       string
+		role       string
+		text       string
+		tools      []string
+		count      int
+	}{
+		{
+			name:       "claude text",
+			clientType: domain.ClientTypeClaude,
+			body:       `{"messages":[{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},{"role":"user","content":[{"type":"text","text":"fix the bug"}]}]}`,
+			role:       "user",
+			text:       "fix the bug",
+			count:      3,
+		},
+		{
+			name:       "claude tool result",
+			clientType: domain.ClientTypeClaude,
+			body:       `{"messages":[{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":[{"type":"text","text":"exit 1"}]}]}]}`,
+			role:       "user",
+			text:       "exit 1",
+			count:      1,
+		},
+		{
+			name:       "openai tool calls",
+			clientType: domain.ClientTypeOpenAI,
+			body:       `{"messages":[{"role":"user","content":"weather?"},{"role":"assistant","content":null,"tool_calls":[{"function":{"name":"get_weather"}}]}]}`,
+			role:       "assistant",
+			tools:      []string{"get_weather"},
+			count:      2,
+		},
+		{
+			name:       "codex string input",
+			clientType: domain.ClientTypeCodex,
+			body:       `{"input":"list files"}`,
+			role:       "user",
+			text:       "list files",
+			count:      1,
+		},
+		{
+			name:       "codex function call output",
+			clientType: domain.ClientTypeCodex,
+			body:       `{"input":[{"type":"message","role":"user","content":[{"type":"input_text","text":"run ls"}]},{"type":"function_call","name":"shell"},{"type":"function_call_output","call_id":"c1","output":"a.go"}]}`,
+			role:       "function_call_output",
+			text:       "a.go",
+			count:      3,
+		},
+		{
+			name:       "gemini",
+			clientType: domain.ClientTypeGemini,
+			body:       `{"contents":[{"role":"user","parts":[{"text":"explain"},{"functionResponse":{"name":"search"}}]}]}`,
+			role:       "user",
+			text:       "explain",
+			tools:      []string{"search"},
+			count:      1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, count := LastMessage(tt.clientType, tt.body)
+			if count != tt.count {
+				t.Errorf("count = %d, want %d", count, tt.count)
+			}
+			if msg == nil {
+				t.Fatal("no message")
+			}
+			if msg.Role != tt.role || msg.Text != tt.text {
+				t.Errorf("message = %q %q, want %q %q", msg.Role, msg.Text, tt.role, tt.text)
+			}
+			if strings.Join(msg.ToolCalls, ",") != strings.Join(tt.tools, ",") {
+				t.Errorf("tool calls = %v, want %v", msg.ToolCalls, tt.tools)
+			}
+		})
+	}
+
+	if msg, count := LastMessage(domain.ClientTypeClaude, "not json"); msg != nil || count != 0 {
+		t.Errorf("invalid body = %v, %d", msg, count)
+	}
+}
+
+func TestLastMessageTruncates(t *testing.T) {
+	body := `{"messages":[{"role":"user","content":"` + strings.Repeat("é", MaxTextLength+10) + `"}]}`
+	msg, _ := LastMessage(domain.ClientTypeClaude, body)
+	if !msg.Truncated || len([]rune(msg.Text)) != MaxTextLength+1 {
+		t.Errorf("truncated = %v, length %d", msg.Truncated, len([]rune(msg.Text)))
+	}
+}
+
+func TestResponseMessage(t *testing.T) {
+	tests := []struct {
+		name       string
+		clientType domain.ClientType
+		body       string
+		text       string
+		tools      []string
+	}{
+		{
+			name:       "claude json",
+			clientType: domain.ClientTypeClaude,
+			body:       `{"content":[{"type":"thinking","thinking":"hmm"},{"type":"text","text":"Done."},{"type":"tool_use","name":"bash"}]}`,
+			text:       "Done.",
+			tools:      []string{"bash"},
+		},
+		{
+			name:       "claude stream",
+			clientType: domain.ClientTypeClaude,
+			body: "event: content_block_start\ndata: {\"type\":\"content_block_start\",\"content_block\":{\"type\":\"text\"}}\n\n" +
+				"data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Hel\"}}\n\n" +
+				"data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"lo\"}}\n\n" +
+				"data: {\"type\":\"content_block_start\",\"content_block\":{\"type\":\"tool_use\",\"name\":\"read\"}}\n\n",
+			text:  "Hello",
+			tools: []string{"read"},
+		},
+		{
+			name:       "openai stream",
+			clientType: domain.ClientTypeOpenAI,
+			body: "data: {\"choices\":[{\"delta\":{\"content\":\"Hi \"}}]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\"there\"}}]}\n\n" +
+				"data: [DONE]\n\n",
+			text: "Hi there",
+		},
+		{
+			name:       "openai json",
+			clientType: domain.ClientTypeOpenAI,
+			body:       `{"choices":[{"message":{"role":"assistant","content":"ok","tool_calls":[{"function":{"name":"lookup"}}]}}]}`,
+			text:       "ok",
+			tools:      []string{"lookup"},
+		},
+		{
+			name:       "codex stream",
+			clientType: domain.ClientTypeCodex,
+			body: "data: {\"type\":\"response.output_text.delta\",\"delta\":\"ru\"}\n\n" +
+				"data: {\"type\":\"response.output_text.delta\",\"delta\":\"nning\"}\n\n" +
+				"data: {\"type\":\"response.output_item.added\",\"item\":{\"type\":\"function_call\",\"name\":\"shell\"}}\n\n",
+			text:  "running",
+			tools: []string{"shell"},
+		},
+		{
+			name:       "gemini stream",
+			clientType: domain.ClientTypeGemini,
+			body: "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"thinking\",\"thought\":true},{\"text\":\"Par\"}]}}]}\n\n" +
+				"data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"is\"}]}}]}\n\n",
+			text: "Paris",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := ResponseMessage(tt.clientType, tt.body)
+			if msg == nil {
+				t.Fatal("no message")
+			}
+			if msg.Text != tt.text {
+				t.Errorf("text = %q, want %q", msg.Text, tt.text)
+			}
+			if strings.Join(msg.ToolCalls, ",") != strings.Join(tt.tools, ",") {
+				t.Errorf("tool calls = %v, want %v", msg.ToolCalls, tt.tools)
+			}
+		})
+	}
+
+	if msg := ResponseMessage(domain.ClientTypeClaude, ""); msg != nil {
+		t.Errorf("empty body = %+v, want nil", msg)
+	}
+}
@@ -19,6 +19,9 @@ func (c *claudeToCodexRequest) Transform(body []byte, model string, stream bool)
 	if err := json.Unmarshal(body, &req); err != nil {
 		return nil, err
 	}
+	if err := checkClaudeCodeExecution(&req, domain.ClientTypeCodex); err != nil {
+		return nil, err
+	}
 
 	codexReq := CodexRequest{
 		Model:           model,
@@ -149,6 +152,7 @@ func (c *claudeToCodexResponse) Transform(body []byte) ([]byte, error) {
 			})
 		}
 	}
+	setCodexStopReason(&codexResp, StopReasonFromClaude(resp.StopReason))
 
 	return json.Marshal(codexResp)
 }
@@ -195,13 +199,15 @@ func (c *claudeToCodexResponse) TransformChunk(chunk []byte, state *TransformSta
 				output = append(output, FormatSSE("", codexEvent)...)
 			}
 
+		case "message_delta":
+			if claudeEvent.Delta != nil {
+				state.StopReason = claudeEvent.Delta.StopReason
+			}
+
 		case "message_stop":
 			codexEvent := map[string]interface{}{
-				"type": "response.done",
-				"response": map[string]interface{}{
-					"id":     state.MessageID,
-					"status": "completed",
-				},
+				"type":     "response.done",
+				"response": codexStreamResponse(state.MessageID, StopReasonFromClaude(state.StopReason)),
 			}
 			output = append(output, FormatSSE("", codexEvent)...)
 		}
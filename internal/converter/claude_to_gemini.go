@@ -524,6 +524,10 @@ func (c *claudeToGeminiRequest) Transform(body []byte, model string, stream bool
 		}
 	}
 
+	if rf := claudeResponseFormat(req.OutputFormat); rf != nil {
+		rf.applyToGemini(genConfig)
+	}
+
 	// Add thinking config if enabled
 	if isThinkingEnabled {
 		genConfig.ThinkingConfig = &GeminiThinkingConfig{
@@ -649,11 +653,6 @@ func (c *claudeToGeminiRequest) Transform(body []byte, model string, stream bool
 					name, _ := m["name"].(string)
 					input, _ := m["input"].(map[string]interface{})
 
-					// Clean input schema
-					if input != nil {
-						cleanJSONSchema(input)
-					}
-
 					// Store id -> name mapping
 					if id != "" && name != "" {
 						toolIDToName[id] = name
@@ -678,21 +677,7 @@ func (c *claudeToGeminiRequest) Transform(body []byte, model string, stream bool
 					toolUseID, _ := m["tool_use_id"].(string)
 
 					// Handle content: can be string or array
-					var resultContent string
-					switch c := m["content"].(type) {
-					case string:
-						resultContent = c
-					case []interface{}:
-						var textParts []string
-						for _, block := range c {
-							if blockMap, ok := block.(map[string]interface{}); ok {
-								if text, ok := blockMap["text"].(string); ok {
-									textParts = append(textParts, text)
-								}
-							}
-						}
-						resultContent = strings.Join(textParts, "\n")
-					}
+					resultContent := claudeToolResultText(m["content"])
 
 					// Handle empty content
 					if strings.TrimSpace(resultContent) == "" {
@@ -713,7 +698,7 @@ func (c *claudeToGeminiRequest) Transform(body []byte, model string, stream bool
 					part := GeminiPart{
 						FunctionResponse: &GeminiFunctionResponse{
 							Name:     funcName,
-							Response: map[string]string{"result": resultContent},
+							Response: geminiToolResponse(resultContent),
 							ID:       toolUseID, // Include ID (like Antigravity-Manager)
 						},
 					}
@@ -767,7 +752,20 @@ func (c *claudeToGeminiRequest) Transform(body []byte, model string, stream bool
 						Text: fmt.Sprintf("[Redacted Thinking: %s]", data),
 					})
 
-				case "server_tool_use", "web_search_tool_result":
+				case "server_tool_use", "code_execution_tool_result", "bash_code_execution_tool_result",
+					"text_editor_code_execution_tool_result", "container_upload":
+					// Code execution maps onto Gemini's own, other server tool blocks
+					// should not be sent to upstream
+					if !isClaudeCodeExecutionBlock(m) {
+						continue
+					}
+					part, err := claudeCodeExecutionToGemini(m)
+					if err != nil {
+						return nil, err
+					}
+					parts = append(parts, part)
+
+				case "web_search_tool_result":
 					// Server tool blocks should not be sent to upstream
 					continue
 				}
@@ -802,8 +800,15 @@ func (c *claudeToGeminiRequest) Transform(body []byte, model string, stream bool
 	if len(req.Tools) > 0 {
 		var funcDecls []GeminiFunctionDecl
 		hasGoogleSearch := hasWebSearch
+		hasCodeExecution := false
 
 		for _, tool := range req.Tools {
+			// 0. Code execution runs on Gemini's code execution tool
+			if tool.IsCodeExecution() {
+				hasCodeExecution = true
+				continue
+			}
+
 			// 1. Detect server tools / built-in tools like web_search
 			if tool.IsWebSearch() {
 				hasGoogleSearch = true
@@ -858,12 +863,19 @@ func (c *claudeToGeminiRequest) Transform(body []byte, model string, stream bool
 					Mode: "VALIDATED",
 				},
 			}
+			// An explicit choice overrides the default; auto stays VALIDATED
+			if tc := claudeToolChoice(req.ToolChoice); tc != nil && tc.Mode != toolChoiceAuto {
+				geminiReq.ToolConfig = tc.toGemini()
+			}
 		} else if hasGoogleSearch {
 			// Only inject Google Search if no local tools
 			geminiReq.Tools = []GeminiTool{{
 				GoogleSearch: &struct{}{},
 			}}
 		}
+		if hasCodeExecution {
+			geminiReq.Tools = append(geminiReq.Tools, GeminiTool{CodeExecution: &struct{}{}})
+		}
 	}
 
 	return json.Marshal(geminiReq)
@@ -901,11 +913,7 @@ func (c *claudeToGeminiResponse) Transform(body []byte) ([]byte, error) {
 	}
 
 	geminiResp := GeminiResponse{
-		UsageMetadata: &GeminiUsageMetadata{
-			PromptTokenCount:     resp.Usage.InputTokens,
-			CandidatesTokenCount: resp.Usage.OutputTokens,
-			TotalTokenCount:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
-		},
+		UsageMetadata: claudeUsageToGemini(resp.Usage),
 	}
 
 	candidate := GeminiCandidate{
@@ -918,6 +926,12 @@ func (c *claudeToGeminiResponse) Transform(body []byte) ([]byte, error) {
 		switch block.Type {
 		case "text":
 			candidate.Content.Parts = append(candidate.Content.Parts, GeminiPart{Text: block.Text})
+		case "thinking":
+			candidate.Content.Parts = append(candidate.Content.Parts, GeminiPart{
+				Text:             block.Thinking,
+				Thought:          true,
+				ThoughtSignature: block.Signature,
+			})
 		case "tool_use":
 			inputMap, _ := block.Input.(map[string]interface{})
 			candidate.Content.Parts = append(candidate.Content.Parts, GeminiPart{
@@ -930,15 +944,7 @@ func (c *claudeToGeminiResponse) Transform(body []byte) ([]byte, error) {
 		}
 	}
 
-	// Map stop reason
-	switch resp.StopReason {
-	case "end_turn":
-		candidate.FinishReason = "STOP"
-	case "max_tokens":
-		candidate.FinishReason = "MAX_TOKENS"
-	case "tool_use":
-		candidate.FinishReason = "STOP"
-	}
+	candidate.FinishReason = StopReasonFromClaude(resp.StopReason).Gemini()
 
 	geminiResp.Candidates = []GeminiCandidate{candidate}
 	return json.Marshal(geminiResp)
@@ -975,6 +981,9 @@ func (c *claudeToGeminiResponse) TransformChunk(chunk []byte, state *TransformSt
 			}
 
 		case "message_delta":
+			if claudeEvent.Delta != nil {
+				state.StopReason = claudeEvent.Delta.StopReason
+			}
 			if claudeEvent.Usage != nil {
 				state.Usage.OutputTokens = claudeEvent.Usage.OutputTokens
 			}
@@ -982,7 +991,7 @@ func (c *claudeToGeminiResponse) TransformChunk(chunk []byte, state *TransformSt
 		case "message_stop":
 			geminiChunk := GeminiStreamChunk{
 				Candidates: []GeminiCandidate{{
-					FinishReason: "STOP",
+					FinishReason: StopReasonFromClaude(state.StopReason).Gemini(),
 					Index:        0,
 				}},
 				UsageMetadata: &GeminiUsageMetadata{
@@ -997,3 +1006,15 @@ func (c *claudeToGeminiResponse) TransformChunk(chunk []byte, state *TransformSt
 
 	return output, nil
 }
+
+// claudeUsageToGemini converts Claude usage. Gemini counts cached tokens as part
+// of promptTokenCount while Claude reports them next to input_tokens.
+func claudeUsageToGemini(u ClaudeUsage) *GeminiUsageMetadata {
+	prompt := u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens
+	return &GeminiUsageMetadata{
+		PromptTokenCount:        prompt,
+		CandidatesTokenCount:    u.OutputTokens,
+		TotalTokenCount:         prompt + u.OutputTokens,
+		CachedContentTokenCount: u.CacheReadInputTokens,
+	}
+}
@@ -3,6 +3,7 @@ package converter
 import (
 	"encoding/json"
 	"time"
+	"unicode/utf8"
 
 	"github.com/awsl-project/maxx/internal/domain"
 )
@@ -19,6 +20,9 @@ func (c *claudeToOpenAIRequest) Transform(body []byte, model string, stream bool
 	if err := json.Unmarshal(body, &req); err != nil {
 		return nil, err
 	}
+	if err := checkClaudeCodeExecution(&req, domain.ClientTypeOpenAI); err != nil {
+		return nil, err
+	}
 
 	openaiReq := OpenAIRequest{
 		Model:       model,
@@ -27,6 +31,9 @@ func (c *claudeToOpenAIRequest) Transform(body []byte, model string, stream bool
 		Temperature: req.Temperature,
 		TopP:        req.TopP,
 	}
+	if req.Metadata != nil {
+		openaiReq.User = req.Metadata.UserID
+	}
 
 	// Convert system to first message
 	if req.System != nil {
@@ -71,6 +78,20 @@ func (c *claudeToOpenAIRequest) Transform(body []byte, model string, stream bool
 						if text, ok := m["text"].(string); ok {
 							parts = append(parts, OpenAIContentPart{Type: "text", Text: text})
 						}
+					case "image":
+						source, _ := m["source"].(map[string]interface{})
+						if source == nil {
+							continue
+						}
+						url, _ := source["url"].(string)
+						if sourceType, _ := source["type"].(string); sourceType == "base64" {
+							mediaType, _ := source["media_type"].(string)
+							data, _ := source["data"].(string)
+							url = imageDataURL(mediaType, data)
+						}
+						if url != "" {
+							parts = append(parts, OpenAIContentPart{Type: "image_url", ImageURL: &OpenAIImageURL{URL: url}})
+						}
 					case "tool_use":
 						id, _ := m["id"].(string)
 						name, _ := m["name"].(string)
@@ -83,10 +104,9 @@ func (c *claudeToOpenAIRequest) Transform(body []byte, model string, stream bool
 						})
 					case "tool_result":
 						toolUseID, _ := m["tool_use_id"].(string)
-						content, _ := m["content"].(string)
 						openaiReq.Messages = append(openaiReq.Messages, OpenAIMessage{
 							Role:       "tool",
-							Content:    content,
+							Content:    claudeToolResultText(m["content"]),
 							ToolCallID: toolUseID,
 						})
 						continue
@@ -101,6 +121,10 @@ func (c *claudeToOpenAIRequest) Transform(body []byte, model string, stream bool
 			} else if len(parts) > 0 {
 				openaiMsg.Content = parts
 			}
+			// A user turn holding only tool results was fully emitted as tool messages
+			if openaiMsg.Content == nil && len(openaiMsg.ToolCalls) == 0 {
+				continue
+			}
 		}
 		openaiReq.Messages = append(openaiReq.Messages, openaiMsg)
 	}
@@ -117,11 +141,26 @@ func (c *claudeToOpenAIRequest) Transform(body []byte, model string, stream bool
 		})
 	}
 
+	if tc := claudeToolChoice(req.ToolChoice); tc != nil && len(openaiReq.Tools) > 0 {
+		openaiReq.ToolChoice = tc.toOpenAI()
+		if tc.DisableParallel {
+			parallel := false
+			openaiReq.ParallelToolCalls = &parallel
+		}
+	}
+
 	// Convert stop sequences
 	if len(req.StopSequences) > 0 {
 		openaiReq.Stop = req.StopSequences
 	}
 
+	if enabled, budget := claudeThinkingBudget(req.Thinking); enabled {
+		openaiReq.ReasoningEffort = budgetToEffort(budget)
+	}
+	if rf := claudeResponseFormat(req.OutputFormat); rf != nil {
+		openaiReq.ResponseFormat = rf.toOpenAI()
+	}
+
 	return json.Marshal(openaiReq)
 }
 
@@ -136,11 +175,7 @@ func (c *claudeToOpenAIResponse) Transform(body []byte) ([]byte, error) {
 		Object:  "chat.completion",
 		Created: time.Now().Unix(),
 		Model:   resp.Model,
-		Usage: OpenAIUsage{
-			PromptTokens:     resp.Usage.InputTokens,
-			CompletionTokens: resp.Usage.OutputTokens,
-			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
-		},
+		Usage:   claudeUsageToOpenAI(resp.Usage),
 	}
 
 	// Convert content to message
@@ -151,7 +186,11 @@ func (c *claudeToOpenAIResponse) Transform(body []byte) ([]byte, error) {
 	for _, block := range resp.Content {
 		switch block.Type {
 		case "text":
+			start := utf8.RuneCountInString(textContent)
 			textContent += block.Text
+			msg.Annotations = append(msg.Annotations, CitationAnnotations(block.Citations, start, utf8.RuneCountInString(textContent))...)
+		case "thinking":
+			msg.ReasoningContent += block.Thinking
 		case "tool_use":
 			inputJSON, _ := json.Marshal(block.Input)
 			toolCalls = append(toolCalls, OpenAIToolCall{
@@ -169,21 +208,10 @@ func (c *claudeToOpenAIResponse) Transform(body []byte) ([]byte, error) {
 		msg.ToolCalls = toolCalls
 	}
 
-	// Map stop reason
-	finishReason := "stop"
-	switch resp.StopReason {
-	case "end_turn":
-		finishReason = "stop"
-	case "max_tokens":
-		finishReason = "length"
-	case "tool_use":
-		finishReason = "tool_calls"
-	}
-
 	openaiResp.Choices = []OpenAIChoice{{
 		Index:        0,
 		Message:      &msg,
-		FinishReason: finishReason,
+		FinishReason: StopReasonFromClaude(resp.StopReason).OpenAI(),
 	}}
 
 	return json.Marshal(openaiResp)
@@ -225,6 +253,8 @@ func (c *claudeToOpenAIResponse) TransformChunk(chunk []byte, state *TransformSt
 			if claudeEvent.ContentBlock != nil {
 				state.CurrentBlockType = claudeEvent.ContentBlock.Type
 				state.CurrentIndex = claudeEvent.Index
+				state.BlockStart = state.ContentLength
+				state.Citations = claudeEvent.ContentBlock.Citations
 				if claudeEvent.ContentBlock.Type == "tool_use" {
 					state.ToolCalls[claudeEvent.Index] = &ToolCallState{
 						ID:   claudeEvent.ContentBlock.ID,
@@ -237,6 +267,7 @@ func (c *claudeToOpenAIResponse) TransformChunk(chunk []byte, state *TransformSt
 			if claudeEvent.Delta != nil {
 				switch claudeEvent.Delta.Type {
 				case "text_delta":
+					state.ContentLength += utf8.RuneCountInString(claudeEvent.Delta.Text)
 					chunk := OpenAIStreamChunk{
 						ID:      state.MessageID,
 						Object:  "chat.completion.chunk",
@@ -247,6 +278,10 @@ func (c *claudeToOpenAIResponse) TransformChunk(chunk []byte, state *TransformSt
 						}},
 					}
 					output = append(output, FormatSSE("", chunk)...)
+				case "citations_delta":
+					if claudeEvent.Delta.Citation != nil {
+						state.Citations = append(state.Citations, *claudeEvent.Delta.Citation)
+					}
 				case "input_json_delta":
 					if tc, ok := state.ToolCalls[state.CurrentIndex]; ok {
 						tc.Arguments += claudeEvent.Delta.PartialJSON
@@ -271,6 +306,22 @@ func (c *claudeToOpenAIResponse) TransformChunk(chunk []byte, state *TransformSt
 				}
 			}
 
+		case "content_block_stop":
+			// Citations cover the whole text block, sent once its length is known
+			if annotations := CitationAnnotations(state.Citations, state.BlockStart, state.ContentLength); len(annotations) > 0 {
+				chunk := OpenAIStreamChunk{
+					ID:      state.MessageID,
+					Object:  "chat.completion.chunk",
+					Created: time.Now().Unix(),
+					Choices: []OpenAIChoice{{
+						Index: 0,
+						Delta: &OpenAIMessage{Annotations: annotations},
+					}},
+				}
+				output = append(output, FormatSSE("", chunk)...)
+			}
+			state.Citations = nil
+
 		case "message_delta":
 			if claudeEvent.Delta != nil {
 				state.StopReason = claudeEvent.Delta.StopReason
@@ -280,15 +331,7 @@ func (c *claudeToOpenAIResponse) TransformChunk(chunk []byte, state *TransformSt
 			}
 
 		case "message_stop":
-			finishReason := "stop"
-			switch state.StopReason {
-			case "end_turn":
-				finishReason = "stop"
-			case "max_tokens":
-				finishReason = "length"
-			case "tool_use":
-				finishReason = "tool_calls"
-			}
+			finishReason := StopReasonFromClaude(state.StopReason).OpenAI()
 			chunk := OpenAIStreamChunk{
 				ID:      state.MessageID,
 				Object:  "chat.completion.chunk",
@@ -314,3 +357,18 @@ type OpenAIToolCallWithIndex struct {
 	Type     string             `json:"type,omitempty"`
 	Function OpenAIFunctionCall `json:"function,omitempty"`
 }
+
+// claudeUsageToOpenAI converts Claude usage. OpenAI counts cached tokens as part
+// of prompt_tokens while Claude reports them next to input_tokens.
+func claudeUsageToOpenAI(u ClaudeUsage) OpenAIUsage {
+	prompt := u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens
+	usage := OpenAIUsage{
+		PromptTokens:     prompt,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      prompt + u.OutputTokens,
+	}
+	if u.CacheReadInputTokens > 0 {
+		usage.PromptTokensDetails = &OpenAIPromptDetails{CachedTokens: u.CacheReadInputTokens}
+	}
+	return usage
+}
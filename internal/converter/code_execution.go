@@ -0,0 +1,104 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// ErrUnsupportedTool is returned when a request uses a tool or tool block the target format
+// has no equivalent for. The request must not be forwarded without it.
+var ErrUnsupportedTool = errors.New("tool not supported by target provider")
+
+// unsupportedTool wraps ErrUnsupportedTool with the tool and target format
+func unsupportedTool(tool string, target domain.ClientType) error {
+	return fmt.Errorf("%w: %s cannot be converted to %s", ErrUnsupportedTool, tool, target)
+}
+
+// Claude code execution server tool and block names
+const (
+	claudeCodeExecution           = "code_execution"
+	claudeBashCodeExecution       = "bash_code_execution"
+	claudeTextEditorCodeExecution = "text_editor_code_execution"
+)
+
+// isClaudeCodeExecutionBlock reports whether a content block belongs to Claude's code
+// execution tool: its server tool calls, their results and files uploaded to the container
+func isClaudeCodeExecutionBlock(block map[string]interface{}) bool {
+	blockType, _ := block["type"].(string)
+	switch blockType {
+	case "server_tool_use":
+		name, _ := block["name"].(string)
+		return name == claudeCodeExecution || name == claudeBashCodeExecution || name == claudeTextEditorCodeExecution
+	case "code_execution_tool_result", "bash_code_execution_tool_result", "text_editor_code_execution_tool_result", "container_upload":
+		return true
+	}
+	return false
+}
+
+// claudeCodeExecutionBlockName names a code execution block in errors
+func claudeCodeExecutionBlockName(block map[string]interface{}) string {
+	if name, _ := block["name"].(string); name != "" {
+		return name
+	}
+	blockType, _ := block["type"].(string)
+	return blockType
+}
+
+// checkClaudeCodeExecution returns an ErrUnsupportedTool error when the request declares
+// the code execution tool or carries its blocks, for targets without code execution
+func checkClaudeCodeExecution(req *ClaudeRequest, target domain.ClientType) error {
+	for i := range req.Tools {
+		if req.Tools[i].IsCodeExecution() {
+			return unsupportedTool(req.Tools[i].Type, target)
+		}
+	}
+	for _, msg := range req.Messages {
+		blocks, _ := msg.Content.([]interface{})
+		for _, block := range blocks {
+			if m, ok := block.(map[string]interface{}); ok && isClaudeCodeExecutionBlock(m) {
+				return unsupportedTool(claudeCodeExecutionBlockName(m), target)
+			}
+		}
+	}
+	return nil
+}
+
+// claudeCodeExecutionToGemini converts a Claude code execution block into Gemini's
+// executableCode / codeExecutionResult parts. Gemini only runs Python, so the bash and
+// text editor variants and container uploads have no equivalent.
+func claudeCodeExecutionToGemini(block map[string]interface{}) (GeminiPart, error) {
+	blockType, _ := block["type"].(string)
+	switch blockType {
+	case "server_tool_use":
+		if name, _ := block["name"].(string); name == claudeCodeExecution {
+			input, _ := block["input"].(map[string]interface{})
+			code, _ := input["code"].(string)
+			return GeminiPart{ExecutableCode: &GeminiExecutableCode{Language: "PYTHON", Code: code}}, nil
+		}
+	case "code_execution_tool_result":
+		return GeminiPart{CodeExecutionResult: claudeCodeExecutionResultToGemini(block["content"])}, nil
+	}
+	return GeminiPart{}, unsupportedTool(claudeCodeExecutionBlockName(block), domain.ClientTypeGemini)
+}
+
+// claudeCodeExecutionResultToGemini converts the content of a code_execution_tool_result block
+func claudeCodeExecutionResultToGemini(content interface{}) *GeminiCodeExecutionResult {
+	m, _ := content.(map[string]interface{})
+	if resultType, _ := m["type"].(string); resultType != "code_execution_result" {
+		// code_execution_tool_result_error, e.g. a timeout or an unavailable container
+		errorCode, _ := m["error_code"].(string)
+		return &GeminiCodeExecutionResult{Outcome: "OUTCOME_FAILED", Output: errorCode}
+	}
+
+	stdout, _ := m["stdout"].(string)
+	stderr, _ := m["stderr"].(string)
+	output := strings.TrimSuffix(stdout+stderr, "\n")
+	outcome := "OUTCOME_OK"
+	if code, _ := m["return_code"].(float64); code != 0 {
+		outcome = "OUTCOME_FAILED"
+	}
+	return &GeminiCodeExecutionResult{Outcome: outcome, Output: output}
+}
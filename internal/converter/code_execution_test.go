@@ -0,0 +1,123 @@
+package converter
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// A Python code execution turn: the tool, the model's server tool call and its result
+const claudeCodeExecutionRequest = `{"model":"claude-sonnet-4-5","max_tokens":1024,
+"tools":[{"type":"code_execution_20250522","name":"code_execution"}],
+"messages":[
+{"role":"user","content":"What is 2**100?"},
+{"role":"assistant","content":[
+{"type":"server_tool_use","id":"srvtoolu_1","name":"code_execution","input":{"code":"print(2**100)"}},
+{"type":"code_execution_tool_result","tool_use_id":"srvtoolu_1","content":{"type":"code_execution_result","stdout":"1267650600228229401496703205376\n","stderr":"","return_code":0}},
+{"type":"text","text":"It is 1267650600228229401496703205376."}]},
+{"role":"user","content":"And 1/0?"}]}`
+
+// The bash variant of code execution, which only exists in Claude's sandbox
+const claudeBashCodeExecutionRequest = `{"model":"claude-sonnet-4-5","max_tokens":1024,
+"tools":[{"type":"code_execution_20250825","name":"code_execution"}],
+"messages":[
+{"role":"user","content":"List the files"},
+{"role":"assistant","content":[
+{"type":"server_tool_use","id":"srvtoolu_1","name":"bash_code_execution","input":{"command":"ls"}},
+{"type":"bash_code_execution_tool_result","tool_use_id":"srvtoolu_1","content":{"type":"bash_code_execution_result","stdout":"a.csv\n","stderr":"","return_code":0}}]},
+{"role":"user","content":"Thanks"}]}`
+
+func TestClaudeCodeExecutionToGemini(t *testing.T) {
+	out, err := (&claudeToGeminiRequest{}).Transform([]byte(claudeCodeExecutionRequest), "gemini-2.5-pro", false)
+	if err != nil {
+		t.Fatalf("Transform failed: %v", err)
+	}
+	var req GeminiRequest
+	if err := json.Unmarshal(out, &req); err != nil {
+		t.Fatalf("invalid Gemini request: %v", err)
+	}
+
+	if len(req.Tools) != 1 || req.Tools[0].CodeExecution == nil || len(req.Tools[0].FunctionDeclarations) > 0 {
+		t.Errorf("tools = %s, want only the code execution tool", out)
+	}
+
+	var code *GeminiExecutableCode
+	var result *GeminiCodeExecutionResult
+	for _, c := range req.Contents {
+		for _, p := range c.Parts {
+			if p.ExecutableCode != nil {
+				code = p.ExecutableCode
+			}
+			if p.CodeExecutionResult != nil {
+				result = p.CodeExecutionResult
+			}
+		}
+	}
+	if code == nil || code.Language != "PYTHON" || code.Code != "print(2**100)" {
+		t.Errorf("executable code = %+v", code)
+	}
+	if result == nil || result.Outcome != "OUTCOME_OK" || result.Output != "1267650600228229401496703205376" {
+		t.Errorf("code execution result = %+v", result)
+	}
+}
+
+func TestClaudeCodeExecutionResultToGemini(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    GeminiCodeExecutionResult
+	}{
+		{"failed run", `{"type":"code_execution_result","stdout":"","stderr":"ZeroDivisionError\n","return_code":1}`,
+			GeminiCodeExecutionResult{Outcome: "OUTCOME_FAILED", Output: "ZeroDivisionError"}},
+		{"tool error", `{"type":"code_execution_tool_result_error","error_code":"execution_time_exceeded"}`,
+			GeminiCodeExecutionResult{Outcome: "OUTCOME_FAILED", Output: "execution_time_exceeded"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var content interface{}
+			_ = json.Unmarshal([]byte(tt.content), &content)
+			if got := claudeCodeExecutionResultToGemini(content); *got != tt.want {
+				t.Errorf("result = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaudeCodeExecutionUnsupported(t *testing.T) {
+	tests := []struct {
+		name    string
+		request RequestTransformer
+		body    string
+		tool    string
+	}{
+		{"bash to Gemini", &claudeToGeminiRequest{}, claudeBashCodeExecutionRequest, "bash_code_execution"},
+		{"to OpenAI", &claudeToOpenAIRequest{}, claudeCodeExecutionRequest, "code_execution_20250522"},
+		{"to Codex", &claudeToCodexRequest{}, claudeCodeExecutionRequest, "code_execution_20250522"},
+		// The tool was dropped from the request but the history still holds its blocks
+		{"history only to OpenAI", &claudeToOpenAIRequest{},
+			strings.Replace(claudeCodeExecutionRequest, `{"type":"code_execution_20250522","name":"code_execution"}`, `{"name":"calc","input_schema":{"type":"object"}}`, 1),
+			"code_execution"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := tt.request.Transform([]byte(tt.body), "target-model", false)
+			if !errors.Is(err, ErrUnsupportedTool) {
+				t.Fatalf("Transform = %s, %v; want an unsupported tool error", out, err)
+			}
+			if !strings.Contains(err.Error(), tt.tool) {
+				t.Errorf("error %q doesn't name %s", err, tt.tool)
+			}
+		})
+	}
+}
+
+func TestClaudeCodeExecutionThroughRegistry(t *testing.T) {
+	_, err := NewRegistry().TransformRequest(domain.ClientTypeClaude, domain.ClientTypeOpenAI,
+		[]byte(claudeCodeExecutionRequest), "gpt-4o", true)
+	if !errors.Is(err, ErrUnsupportedTool) {
+		t.Errorf("TransformRequest error = %v, want ErrUnsupportedTool", err)
+	}
+}
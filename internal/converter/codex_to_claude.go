@@ -144,11 +144,7 @@ func (c *codexToClaudeResponse) Transform(body []byte) ([]byte, error) {
 		}
 	}
 
-	if hasToolCall {
-		claudeResp.StopReason = "tool_use"
-	} else {
-		claudeResp.StopReason = "end_turn"
-	}
+	claudeResp.StopReason = codexResponseStopReason(&resp, hasToolCall).Claude()
 
 	return json.Marshal(claudeResp)
 }
@@ -217,7 +213,7 @@ func (c *codexToClaudeResponse) TransformChunk(chunk []byte, state *TransformSta
 			msgDelta := map[string]interface{}{
 				"type": "message_delta",
 				"delta": map[string]interface{}{
-					"stop_reason": "end_turn",
+					"stop_reason": codexEventStopReason(codexEvent).Claude(),
 				},
 				"usage": map[string]int{"output_tokens": 0},
 			}
@@ -150,15 +150,10 @@ func (c *codexToOpenAIResponse) Transform(body []byte) ([]byte, error) {
 		msg.ToolCalls = toolCalls
 	}
 
-	finishReason := "stop"
-	if len(toolCalls) > 0 {
-		finishReason = "tool_calls"
-	}
-
 	openaiResp.Choices = []OpenAIChoice{{
 		Index:        0,
 		Message:      &msg,
-		FinishReason: finishReason,
+		FinishReason: codexResponseStopReason(&resp, len(toolCalls) > 0).OpenAI(),
 	}}
 
 	return json.Marshal(openaiResp)
@@ -217,7 +212,7 @@ func (c *codexToOpenAIResponse) TransformChunk(chunk []byte, state *TransformSta
 				Choices: []OpenAIChoice{{
 					Index:        0,
 					Delta:        &OpenAIMessage{},
-					FinishReason: "stop",
+					FinishReason: codexEventStopReason(codexEvent).OpenAI(),
 				}},
 			}
 			output = append(output, FormatSSE("", openaiChunk)...)
@@ -0,0 +1,107 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// imageDataURL renders base64 image data as a data URL
+func imageDataURL(mediaType, data string) string {
+	return fmt.Sprintf("data:%s;base64,%s", mediaType, data)
+}
+
+// parseImageDataURL splits a base64 data URL into media type and data.
+// ok is false for remote URLs, which only OpenAI can reference.
+func parseImageDataURL(url string) (mediaType, data string, ok bool) {
+	rest, found := strings.CutPrefix(url, "data:")
+	if !found {
+		return "", "", false
+	}
+	meta, data, found := strings.Cut(rest, ",")
+	if !found {
+		return "", "", false
+	}
+	mediaType, found = strings.CutSuffix(meta, ";base64")
+	if !found {
+		return "", "", false
+	}
+	return mediaType, data, true
+}
+
+// openaiImageURL returns the URL of an OpenAI image_url content part
+func openaiImageURL(part map[string]interface{}) string {
+	switch v := part["image_url"].(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		url, _ := v["url"].(string)
+		return url
+	}
+	return ""
+}
+
+// claudeToolResultText returns the text of a Claude tool_result content, a string or text blocks
+func claudeToolResultText(content interface{}) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []interface{}:
+		var texts []string
+		for _, block := range c {
+			if m, ok := block.(map[string]interface{}); ok {
+				if text, ok := m["text"].(string); ok {
+					texts = append(texts, text)
+				}
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+	return ""
+}
+
+// openaiContentText returns the text of an OpenAI message content, a string or content parts
+func openaiContentText(content interface{}) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []interface{}:
+		var sb strings.Builder
+		for _, part := range c {
+			if m, ok := part.(map[string]interface{}); ok && m["type"] == "text" {
+				text, _ := m["text"].(string)
+				sb.WriteString(text)
+			}
+		}
+		return sb.String()
+	}
+	return ""
+}
+
+// geminiToolResponse wraps tool output for a Gemini functionResponse.
+// Gemini expects an object, so plain text goes under "result".
+func geminiToolResponse(text string) interface{} {
+	return map[string]string{"result": text}
+}
+
+// geminiToolResponseText unwraps a Gemini functionResponse into tool output text.
+// Responses holding only "result" return it as is, others are returned as JSON.
+func geminiToolResponseText(response interface{}) string {
+	if m, ok := response.(map[string]interface{}); ok && len(m) == 1 {
+		if text, ok := m["result"].(string); ok {
+			return text
+		}
+	}
+	b, _ := json.Marshal(response)
+	return string(b)
+}
+
+// geminiToolCallID returns the ID of a Gemini function call or response, derived
+// from the function name when Gemini doesn't supply one, so a call and its
+// response get the same ID
+func geminiToolCallID(id, name string) string {
+	if id != "" {
+		return id
+	}
+	return "call_" + name
+}
@@ -0,0 +1,243 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// HasContent reports whether a successful response in the client's format carries any
+// content: non-blank text or reasoning, a tool call or another block. Responses whose
+// candidates were all safety-filtered or came back empty have none. Streaming bodies are
+// scanned event by event. Formats without a detector and unparseable bodies count as content.
+func HasContent(clientType domain.ClientType, body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return false
+	}
+
+	switch trimmed[0] {
+	case '{':
+		return messageHasContent(clientType, trimmed, false)
+	case '[':
+		// Gemini streams without alt=sse as a JSON array of chunks
+		var chunks []json.RawMessage
+		if err := json.Unmarshal(trimmed, &chunks); err != nil {
+			return true
+		}
+		for _, chunk := range chunks {
+			if messageHasContent(clientType, chunk, true) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, line := range strings.Split(string(trimmed), "\n") {
+		if data, ok := sseData(line); ok && StreamEventHasContent(clientType, data) {
+			return true
+		}
+	}
+	return false
+}
+
+// StreamEventHasContent reports whether the data payload of a single SSE event carries content
+func StreamEventHasContent(clientType domain.ClientType, data []byte) bool {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return false // [DONE] and keep-alives
+	}
+	return messageHasContent(clientType, data, true)
+}
+
+// sseData returns the payload of an SSE data line
+func sseData(line string) ([]byte, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "data:") {
+		return nil, false
+	}
+	return []byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), true
+}
+
+func messageHasContent(clientType domain.ClientType, data []byte, stream bool) bool {
+	switch clientType {
+	case domain.ClientTypeClaude:
+		if stream {
+			return claudeEventHasContent(data)
+		}
+		return claudeMessageHasContent(data)
+	case domain.ClientTypeOpenAI:
+		return openAIHasContent(data, stream)
+	case domain.ClientTypeGemini:
+		return geminiHasContent(data, stream)
+	default:
+		return true
+	}
+}
+
+// claudeBlock is a content block of a Claude message or content_block_start event
+type claudeBlock struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Thinking string `json:"thinking"`
+}
+
+// hasContent reports whether the block is more than empty text. Streamed blocks start
+// empty, their content arrives in the deltas.
+func (b claudeBlock) hasContent(stream bool) bool {
+	switch b.Type {
+	case "text":
+		return strings.TrimSpace(b.Text) != ""
+	case "thinking":
+		return !stream && strings.TrimSpace(b.Thinking) != ""
+	default:
+		return b.Type != "" // tool_use, redacted_thinking, server_tool_use, ...
+	}
+}
+
+func claudeMessageHasContent(data []byte) bool {
+	var msg struct {
+		Type    string        `json:"type"`
+		Content []claudeBlock `json:"content"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type == "error" {
+		return true
+	}
+	for _, block := range msg.Content {
+		if block.hasContent(false) {
+			return true
+		}
+	}
+	return false
+}
+
+func claudeEventHasContent(data []byte) bool {
+	var event struct {
+		Type         string      `json:"type"`
+		ContentBlock claudeBlock `json:"content_block"`
+		Delta        struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			Thinking string `json:"thinking"`
+		} `json:"delta"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return false
+	}
+	switch event.Type {
+	case "content_block_start":
+		return event.ContentBlock.hasContent(true)
+	case "content_block_delta":
+		switch event.Delta.Type {
+		case "text_delta":
+			return strings.TrimSpace(event.Delta.Text) != ""
+		case "thinking_delta":
+			return strings.TrimSpace(event.Delta.Thinking) != ""
+		case "input_json_delta":
+			return true
+		}
+	}
+	return false
+}
+
+func openAIHasContent(data []byte, stream bool) bool {
+	type message struct {
+		Content          json.RawMessage `json:"content"`
+		ReasoningContent string          `json:"reasoning_content"`
+		Refusal          string          `json:"refusal"`
+		ToolCalls        []any           `json:"tool_calls"`
+		FunctionCall     any             `json:"function_call"`
+	}
+	var resp struct {
+		Choices []struct {
+			Message message `json:"message"`
+			Delta   message `json:"delta"`
+		} `json:"choices"`
+		Error any `json:"error"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return !stream
+	}
+	if resp.Error != nil {
+		return true
+	}
+	for _, choice := range resp.Choices {
+		msg := choice.Message
+		if stream {
+			msg = choice.Delta
+		}
+		if len(msg.ToolCalls) > 0 || msg.FunctionCall != nil ||
+			strings.TrimSpace(msg.Refusal) != "" || strings.TrimSpace(msg.ReasoningContent) != "" {
+			return true
+		}
+		if openAIContentHasText(msg.Content) {
+			return true
+		}
+	}
+	return false
+}
+
+// openAIContentHasText reads message content, a string or an array of content parts
+func openAIContentHasText(raw json.RawMessage) bool {
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return strings.TrimSpace(text) != ""
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(raw, &parts) != nil {
+		return false
+	}
+	for _, part := range parts {
+		if part.Type != "text" || strings.TrimSpace(part.Text) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func geminiHasContent(data []byte, stream bool) bool {
+	var resp struct {
+		Candidates []struct {
+			Content struct {
+				Parts []map[string]json.RawMessage `json:"parts"`
+			} `json:"content"`
+		} `json:"candidates"`
+		Error any `json:"error"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return !stream
+	}
+	if resp.Error != nil {
+		return true
+	}
+	for _, candidate := range resp.Candidates {
+		for _, part := range candidate.Content.Parts {
+			if geminiPartHasContent(part) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// geminiPartHasContent reports whether a part is more than empty text or a bare thought signature
+func geminiPartHasContent(part map[string]json.RawMessage) bool {
+	if raw, ok := part["text"]; ok {
+		var text string
+		_ = json.Unmarshal(raw, &text)
+		return strings.TrimSpace(text) != ""
+	}
+	for key := range part {
+		switch key {
+		case "thought", "thoughtSignature":
+		default:
+			return true // functionCall, inlineData, executableCode, ...
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestHasContent(t *testing.T) {
+	tests := []struct {
+		name       string
+		clientType domain.ClientType
+		body       string
+		want       bool
+	}{
+		{"claude text", domain.ClientTypeClaude, `{"type":"message","content":[{"type":"text","text":"hi"}]}`, true},
+		{"claude tool use", domain.ClientTypeClaude, `{"type":"message","content":[{"type":"tool_use","id":"t1","name":"ls","input":{}}]}`, true},
+		{"claude no blocks", domain.ClientTypeClaude, `{"type":"message","content":[],"stop_reason":"end_turn"}`, false},
+		{"claude blank text", domain.ClientTypeClaude, `{"type":"message","content":[{"type":"text","text":"  \n"}]}`, false},
+		{"claude error", domain.ClientTypeClaude, `{"type":"error","error":{"type":"overloaded_error"}}`, true},
+
+		{"openai text", domain.ClientTypeOpenAI, `{"choices":[{"message":{"role":"assistant","content":"hi"}}]}`, true},
+		{"openai tool calls", domain.ClientTypeOpenAI, `{"choices":[{"message":{"content":null,"tool_calls":[{"id":"c1"}]}}]}`, true},
+		{"openai refusal", domain.ClientTypeOpenAI, `{"choices":[{"message":{"content":null,"refusal":"I can't help with that"}}]}`, true},
+		{"openai filtered", domain.ClientTypeOpenAI, `{"choices":[{"message":{"content":null},"finish_reason":"content_filter"}]}`, false},
+		{"openai no choices", domain.ClientTypeOpenAI, `{"choices":[]}`, false},
+
+		{"gemini text", domain.ClientTypeGemini, `{"candidates":[{"content":{"parts":[{"text":"hi"}]}}]}`, true},
+		{"gemini function call", domain.ClientTypeGemini, `{"candidates":[{"content":{"parts":[{"functionCall":{"name":"ls"}}]}}]}`, true},
+		{"gemini empty candidates", domain.ClientTypeGemini, `{"candidates":[],"promptFeedback":{"blockReason":"SAFETY"}}`, false},
+		{"gemini safety filtered", domain.ClientTypeGemini, `{"candidates":[{"finishReason":"SAFETY","safetyRatings":[{"blocked":true}]}]}`, false},
+		{"gemini bare signature", domain.ClientTypeGemini, `{"candidates":[{"content":{"parts":[{"text":"","thoughtSignature":"sig"}]}}]}`, false},
+		{"gemini array stream", domain.ClientTypeGemini, `[{"candidates":[]},{"candidates":[{"content":{"parts":[{"text":"hi"}]}}]}]`, true},
+
+		{"empty body", domain.ClientTypeClaude, ``, false},
+		{"unparseable body", domain.ClientTypeOpenAI, `{not json`, true},
+		{"unknown format", domain.ClientTypeCodex, `{"output":[]}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasContent(tt.clientType, []byte(tt.body)); got != tt.want {
+				t.Errorf("HasContent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasContentStreams(t *testing.T) {
+	tests := []struct {
+		name       string
+		clientType domain.ClientType
+		body       string
+		want       bool
+	}{
+		{
+			"claude text delta", domain.ClientTypeClaude,
+			"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"content\":[]}}\n\n" +
+				"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"hi\"}}\n\n",
+			true,
+		},
+		{
+			"claude thinking delta", domain.ClientTypeClaude,
+			"data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"thinking_delta\",\"thinking\":\"hmm\"}}\n\n",
+			true,
+		},
+		{
+			"claude empty message", domain.ClientTypeClaude,
+			"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"content\":[]}}\n\n" +
+				"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+				"event: content_block_stop\ndata: {\"type\":\"content_block_stop\"}\n\n" +
+				"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+			false,
+		},
+		{
+			"openai deltas", domain.ClientTypeOpenAI,
+			"data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n",
+			true,
+		},
+		{
+			"openai filtered stream", domain.ClientTypeOpenAI,
+			"data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\ndata: {\"choices\":[{\"delta\":{},\"finish_reason\":\"content_filter\"}]}\n\ndata: [DONE]\n\n",
+			false,
+		},
+		{
+			"gemini filtered stream", domain.ClientTypeGemini,
+			"data: {\"candidates\":[{\"finishReason\":\"SAFETY\"}]}\n\n",
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasContent(tt.clientType, []byte(tt.body)); got != tt.want {
+				t.Errorf("HasContent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -114,6 +114,12 @@ func (c *geminiToClaudeRequest) Transform(body []byte, model string, stream bool
 		claudeReq.TopP = req.GenerationConfig.TopP
 		claudeReq.TopK = req.GenerationConfig.TopK
 		claudeReq.StopSequences = req.GenerationConfig.StopSequences
+		if rf := geminiResponseFormat(req.GenerationConfig); rf != nil {
+			claudeReq.OutputFormat = rf.toClaude()
+		}
+		if tc := req.GenerationConfig.ThinkingConfig; tc != nil && tc.IncludeThoughts {
+			claudeReq.Thinking = claudeThinking(tc.ThinkingBudget, claudeReq.MaxTokens)
+		}
 	}
 
 	// Convert systemInstruction
@@ -128,7 +134,6 @@ func (c *geminiToClaudeRequest) Transform(body []byte, model string, stream bool
 	}
 
 	// Convert contents to messages
-	toolCallCounter := 0
 	for _, content := range req.Contents {
 		claudeMsg := ClaudeMessage{}
 		// Map role
@@ -146,21 +151,29 @@ func (c *geminiToClaudeRequest) Transform(body []byte, model string, stream bool
 			if part.Text != "" {
 				blocks = append(blocks, ClaudeContentBlock{Type: "text", Text: part.Text})
 			}
+			if part.InlineData != nil && part.InlineData.Data != "" {
+				blocks = append(blocks, ClaudeContentBlock{
+					Type: "image",
+					Source: &ClaudeImageSource{
+						Type:      "base64",
+						MediaType: part.InlineData.MimeType,
+						Data:      part.InlineData.Data,
+					},
+				})
+			}
 			if part.FunctionCall != nil {
-				toolCallCounter++
 				blocks = append(blocks, ClaudeContentBlock{
 					Type:  "tool_use",
-					ID:    fmt.Sprintf("call_%d", toolCallCounter),
+					ID:    geminiToolCallID(part.FunctionCall.ID, part.FunctionCall.Name),
 					Name:  part.FunctionCall.Name,
 					Input: part.FunctionCall.Args,
 				})
 			}
 			if part.FunctionResponse != nil {
-				respJSON, _ := json.Marshal(part.FunctionResponse.Response)
 				blocks = append(blocks, ClaudeContentBlock{
 					Type:      "tool_result",
-					ToolUseID: part.FunctionResponse.Name,
-					Content:   string(respJSON),
+					ToolUseID: geminiToolCallID(part.FunctionResponse.ID, part.FunctionResponse.Name),
+					Content:   geminiToolResponseText(part.FunctionResponse.Response),
 				})
 			}
 		}
@@ -184,6 +197,9 @@ func (c *geminiToClaudeRequest) Transform(body []byte, model string, stream bool
 			})
 		}
 	}
+	if tc := geminiToolChoice(req.ToolConfig); tc != nil && len(claudeReq.Tools) > 0 {
+		claudeReq.ToolChoice = tc.toClaude()
+	}
 
 	return json.Marshal(claudeReq)
 }
@@ -201,10 +217,7 @@ func (c *geminiToClaudeResponse) Transform(body []byte) ([]byte, error) {
 	}
 
 	if resp.UsageMetadata != nil {
-		claudeResp.Usage = ClaudeUsage{
-			InputTokens:  resp.UsageMetadata.PromptTokenCount,
-			OutputTokens: resp.UsageMetadata.CandidatesTokenCount,
-		}
+		claudeResp.Usage = geminiUsageToClaude(resp.UsageMetadata)
 	}
 
 	hasToolUse := false
@@ -233,28 +246,20 @@ func (c *geminiToClaudeResponse) Transform(body []byte) ([]byte, error) {
 				// Apply argument remapping for Claude Code compatibility
 				args := part.FunctionCall.Args
 				remapFunctionCallArgs(part.FunctionCall.Name, args)
+				id := part.FunctionCall.ID
+				if id == "" {
+					id = fmt.Sprintf("call_%d", toolCallCounter)
+				}
 				claudeResp.Content = append(claudeResp.Content, ClaudeContentBlock{
 					Type:  "tool_use",
-					ID:    fmt.Sprintf("call_%d", toolCallCounter),
+					ID:    id,
 					Name:  part.FunctionCall.Name,
 					Input: args,
 				})
 			}
 		}
 
-		// Map finish reason
-		switch candidate.FinishReason {
-		case "STOP":
-			if hasToolUse {
-				claudeResp.StopReason = "tool_use"
-			} else {
-				claudeResp.StopReason = "end_turn"
-			}
-		case "MAX_TOKENS":
-			claudeResp.StopReason = "max_tokens"
-		default:
-			claudeResp.StopReason = "end_turn"
-		}
+		claudeResp.StopReason = StopReasonFromGemini(candidate.FinishReason, hasToolUse).Claude()
 	}
 
 	return json.Marshal(claudeResp)
@@ -333,15 +338,10 @@ func (c *geminiToClaudeResponse) TransformChunk(chunk []byte, state *TransformSt
 				}
 				output = append(output, FormatSSE("content_block_stop", blockStop)...)
 
-				stopReason := "end_turn"
-				if candidate.FinishReason == "MAX_TOKENS" {
-					stopReason = "max_tokens"
-				}
-
 				msgDelta := map[string]interface{}{
 					"type": "message_delta",
 					"delta": map[string]interface{}{
-						"stop_reason": stopReason,
+						"stop_reason": StopReasonFromGemini(candidate.FinishReason, false).Claude(),
 					},
 					"usage": map[string]int{"output_tokens": state.Usage.OutputTokens},
 				}
@@ -358,3 +358,13 @@ func (c *geminiToClaudeResponse) TransformChunk(chunk []byte, state *TransformSt
 
 	return output, nil
 }
+
+// geminiUsageToClaude converts Gemini usage. Claude reports cached tokens next to
+// input_tokens and counts thinking as output.
+func geminiUsageToClaude(u *GeminiUsageMetadata) ClaudeUsage {
+	return ClaudeUsage{
+		InputTokens:          max(u.PromptTokenCount-u.CachedContentTokenCount, 0),
+		OutputTokens:         u.CandidatesTokenCount + u.ThoughtsTokenCount,
+		CacheReadInputTokens: u.CachedContentTokenCount,
+	}
+}
@@ -152,75 +152,62 @@ func mapGeminiRoleToCodex(role string) string {
 }
 
 func (c *geminiToCodexResponse) Transform(body []byte) ([]byte, error) {
-	var resp CodexResponse
+	var resp GeminiResponse
 	if err := json.Unmarshal(body, &resp); err != nil {
 		return nil, err
 	}
 
-	geminiResp := GeminiResponse{
-		UsageMetadata: &GeminiUsageMetadata{
-			PromptTokenCount:     resp.Usage.InputTokens,
-			CandidatesTokenCount: resp.Usage.OutputTokens,
-			TotalTokenCount:      resp.Usage.TotalTokens,
-		},
+	codexResp := CodexResponse{
+		ID:        "resp_" + time.Now().Format("20060102150405"),
+		Object:    "response",
+		CreatedAt: time.Now().Unix(),
+		Status:    "completed",
 	}
 
-	// Convert output to candidates
-	var parts []GeminiPart
-	for _, out := range resp.Output {
-		switch out.Type {
-		case "message":
-			switch content := out.Content.(type) {
-			case string:
-				parts = append(parts, GeminiPart{Text: content})
-			case []interface{}:
-				for _, c := range content {
-					if cm, ok := c.(map[string]interface{}); ok {
-						if text, ok := cm["text"].(string); ok {
-							parts = append(parts, GeminiPart{Text: text})
-						}
-					}
-				}
-			}
-		case "function_call":
-			var args map[string]interface{}
-			json.Unmarshal([]byte(out.Arguments), &args)
-			// Embed call_id in name for round-trip
-			name := out.Name
-			if out.CallID != "" {
-				name = out.Name + "_" + out.CallID
-			}
-			parts = append(parts, GeminiPart{
-				FunctionCall: &GeminiFunctionCall{
-					Name: name,
-					Args: args,
-				},
-			})
+	if resp.UsageMetadata != nil {
+		codexResp.Usage = CodexUsage{
+			InputTokens:  resp.UsageMetadata.PromptTokenCount,
+			OutputTokens: resp.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:  resp.UsageMetadata.TotalTokenCount,
 		}
 	}
 
-	finishReason := "STOP"
-	if resp.Status == "incomplete" {
-		finishReason = "MAX_TOKENS"
-	}
-	// Check if there are function calls
-	for _, part := range parts {
-		if part.FunctionCall != nil {
-			finishReason = "STOP"
-			break
+	// Convert candidates to output
+	for _, candidate := range resp.Candidates {
+		for _, part := range candidate.Content.Parts {
+			if part.Text != "" {
+				codexResp.Output = append(codexResp.Output, CodexOutput{
+					Type:    "message",
+					ID:      "msg_" + time.Now().Format("20060102150405"),
+					Role:    "assistant",
+					Content: []map[string]interface{}{{"type": "output_text", "text": part.Text}},
+				})
+			}
+			if part.FunctionCall != nil {
+				argsJSON, _ := json.Marshal(part.FunctionCall.Args)
+				// Extract call_id from name if present
+				name := part.FunctionCall.Name
+				callID := "call_" + time.Now().Format("20060102150405")
+				if idx := strings.LastIndex(name, "_"); idx > 0 {
+					callID = name[idx+1:]
+					name = name[:idx]
+				}
+				codexResp.Output = append(codexResp.Output, CodexOutput{
+					Type:      "function_call",
+					ID:        "fc_" + time.Now().Format("20060102150405"),
+					Name:      name,
+					CallID:    callID,
+					Arguments: string(argsJSON),
+					Status:    "completed",
+				})
+			}
 		}
 	}
+	if len(resp.Candidates) > 0 {
+		setCodexStopReason(&codexResp, StopReasonFromGemini(resp.Candidates[0].FinishReason, false))
+	}
 
-	geminiResp.Candidates = []GeminiCandidate{{
-		Content: GeminiContent{
-			Role:  "model",
-			Parts: parts,
-		},
-		FinishReason: finishReason,
-		Index:        0,
-	}}
-
-	return json.Marshal(geminiResp)
+	return json.Marshal(codexResp)
 }
 
 func (c *geminiToCodexResponse) TransformChunk(chunk []byte, state *TransformState) ([]byte, error) {
@@ -230,75 +217,89 @@ func (c *geminiToCodexResponse) TransformChunk(chunk []byte, state *TransformSta
 	var output []byte
 	for _, event := range events {
 		if event.Event == "done" {
+			// Send response.completed event
+			completedEvent := CodexStreamEvent{
+				Type: "response.completed",
+				Response: &CodexResponse{
+					ID:        state.MessageID,
+					Object:    "response",
+					CreatedAt: time.Now().Unix(),
+					Usage: CodexUsage{
+						InputTokens:  state.Usage.InputTokens,
+						OutputTokens: state.Usage.OutputTokens,
+						TotalTokens:  state.Usage.InputTokens + state.Usage.OutputTokens,
+					},
+				},
+			}
+			setCodexStopReason(completedEvent.Response, StopReasonFromClaude(state.StopReason))
+			output = append(output, FormatSSE("response.completed", completedEvent)...)
+			output = append(output, FormatDone()...)
 			continue
 		}
 
-		var codexEvent CodexStreamEvent
-		if err := json.Unmarshal(event.Data, &codexEvent); err != nil {
+		var geminiChunk GeminiStreamChunk
+		if err := json.Unmarshal(event.Data, &geminiChunk); err != nil {
 			continue
 		}
 
-		switch codexEvent.Type {
-		case "response.created":
-			if codexEvent.Response != nil {
-				state.MessageID = codexEvent.Response.ID
+		// Initialize on first chunk
+		if state.MessageID == "" {
+			state.MessageID = "resp_" + time.Now().Format("20060102150405")
+			createdEvent := CodexStreamEvent{
+				Type: "response.created",
+				Response: &CodexResponse{
+					ID:        state.MessageID,
+					Object:    "response",
+					CreatedAt: time.Now().Unix(),
+					Status:    "in_progress",
+				},
 			}
+			output = append(output, FormatSSE("response.created", createdEvent)...)
+		}
 
-		case "response.output_text.delta":
-			if codexEvent.Delta != nil && codexEvent.Delta.Text != "" {
-				geminiChunk := GeminiStreamChunk{
-					Candidates: []GeminiCandidate{{
-						Content: GeminiContent{
-							Role:  "model",
-							Parts: []GeminiPart{{Text: codexEvent.Delta.Text}},
-						},
-						Index: 0,
-					}},
-				}
-				output = append(output, FormatSSE("", geminiChunk)...)
-			}
+		// Update usage
+		if geminiChunk.UsageMetadata != nil {
+			state.Usage.InputTokens = geminiChunk.UsageMetadata.PromptTokenCount
+			state.Usage.OutputTokens = geminiChunk.UsageMetadata.CandidatesTokenCount
+		}
 
-		case "response.output_item.added":
-			if codexEvent.Item != nil && codexEvent.Item.Type == "function_call" {
-				var args map[string]interface{}
-				json.Unmarshal([]byte(codexEvent.Item.Arguments), &args)
-				name := codexEvent.Item.Name
-				if codexEvent.Item.CallID != "" {
-					name = codexEvent.Item.Name + "_" + codexEvent.Item.CallID
-				}
-				geminiChunk := GeminiStreamChunk{
-					Candidates: []GeminiCandidate{{
-						Content: GeminiContent{
-							Role: "model",
-							Parts: []GeminiPart{{
-								FunctionCall: &GeminiFunctionCall{
-									Name: name,
-									Args: args,
-								},
-							}},
+		// Process candidates
+		for _, candidate := range geminiChunk.Candidates {
+			if candidate.FinishReason != "" {
+				state.StopReason = StopReasonFromGemini(candidate.FinishReason, false).Claude()
+			}
+			for _, part := range candidate.Content.Parts {
+				if part.Text != "" {
+					deltaEvent := CodexStreamEvent{
+						Type: "response.output_text.delta",
+						Delta: &CodexDelta{
+							Type: "output_text_delta",
+							Text: part.Text,
 						},
-						Index: 0,
-					}},
+					}
+					output = append(output, FormatSSE("response.output_text.delta", deltaEvent)...)
 				}
-				output = append(output, FormatSSE("", geminiChunk)...)
-			}
-
-		case "response.completed":
-			if codexEvent.Response != nil {
-				finishReason := "STOP"
-				geminiChunk := GeminiStreamChunk{
-					Candidates: []GeminiCandidate{{
-						Content:      GeminiContent{Role: "model", Parts: []GeminiPart{}},
-						FinishReason: finishReason,
-						Index:        0,
-					}},
-					UsageMetadata: &GeminiUsageMetadata{
-						PromptTokenCount:     codexEvent.Response.Usage.InputTokens,
-						CandidatesTokenCount: codexEvent.Response.Usage.OutputTokens,
-						TotalTokenCount:      codexEvent.Response.Usage.TotalTokens,
-					},
+				if part.FunctionCall != nil {
+					argsJSON, _ := json.Marshal(part.FunctionCall.Args)
+					name := part.FunctionCall.Name
+					callID := "call_" + time.Now().Format("20060102150405")
+					if idx := strings.LastIndex(name, "_"); idx > 0 {
+						callID = name[idx+1:]
+						name = name[:idx]
+					}
+					itemEvent := CodexStreamEvent{
+						Type: "response.output_item.added",
+						Item: &CodexOutput{
+							Type:      "function_call",
+							ID:        "fc_" + time.Now().Format("20060102150405"),
+							Name:      name,
+							CallID:    callID,
+							Arguments: string(argsJSON),
+							Status:    "completed",
+						},
+					}
+					output = append(output, FormatSSE("response.output_item.added", itemEvent)...)
 				}
-				output = append(output, FormatSSE("", geminiChunk)...)
 			}
 		}
 	}
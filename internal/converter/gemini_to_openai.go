@@ -2,7 +2,9 @@ package converter
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
+	"unicode/utf8"
 
 	"github.com/awsl-project/maxx/internal/domain"
 )
@@ -32,6 +34,12 @@ func (c *geminiToOpenAIRequest) Transform(body []byte, model string, stream bool
 		if len(req.GenerationConfig.StopSequences) > 0 {
 			openaiReq.Stop = req.GenerationConfig.StopSequences
 		}
+		if rf := geminiResponseFormat(req.GenerationConfig); rf != nil {
+			openaiReq.ResponseFormat = rf.toOpenAI()
+		}
+		if tc := req.GenerationConfig.ThinkingConfig; tc != nil && tc.IncludeThoughts {
+			openaiReq.ReasoningEffort = budgetToEffort(tc.ThinkingBudget)
+		}
 	}
 
 	// Convert systemInstruction
@@ -61,16 +69,23 @@ func (c *geminiToOpenAIRequest) Transform(body []byte, model string, stream bool
 		}
 
 		var textContent string
+		var images []OpenAIContentPart
 		var toolCalls []OpenAIToolCall
 
 		for _, part := range content.Parts {
 			if part.Text != "" {
 				textContent += part.Text
 			}
+			if part.InlineData != nil && part.InlineData.Data != "" {
+				images = append(images, OpenAIContentPart{
+					Type:     "image_url",
+					ImageURL: &OpenAIImageURL{URL: imageDataURL(part.InlineData.MimeType, part.InlineData.Data)},
+				})
+			}
 			if part.FunctionCall != nil {
 				argsJSON, _ := json.Marshal(part.FunctionCall.Args)
 				toolCalls = append(toolCalls, OpenAIToolCall{
-					ID:   "call_" + part.FunctionCall.Name,
+					ID:   geminiToolCallID(part.FunctionCall.ID, part.FunctionCall.Name),
 					Type: "function",
 					Function: OpenAIFunctionCall{
 						Name:      part.FunctionCall.Name,
@@ -79,17 +94,22 @@ func (c *geminiToOpenAIRequest) Transform(body []byte, model string, stream bool
 				})
 			}
 			if part.FunctionResponse != nil {
-				respJSON, _ := json.Marshal(part.FunctionResponse.Response)
 				openaiReq.Messages = append(openaiReq.Messages, OpenAIMessage{
 					Role:       "tool",
-					Content:    string(respJSON),
-					ToolCallID: part.FunctionResponse.Name,
+					Content:    geminiToolResponseText(part.FunctionResponse.Response),
+					ToolCallID: geminiToolCallID(part.FunctionResponse.ID, part.FunctionResponse.Name),
 				})
 				continue
 			}
 		}
 
-		if textContent != "" {
+		if len(images) > 0 {
+			var parts []OpenAIContentPart
+			if textContent != "" {
+				parts = append(parts, OpenAIContentPart{Type: "text", Text: textContent})
+			}
+			openaiMsg.Content = append(parts, images...)
+		} else if textContent != "" {
 			openaiMsg.Content = textContent
 		}
 		if len(toolCalls) > 0 {
@@ -114,6 +134,9 @@ func (c *geminiToOpenAIRequest) Transform(body []byte, model string, stream bool
 			})
 		}
 	}
+	if tc := geminiToolChoice(req.ToolConfig); tc != nil && len(openaiReq.Tools) > 0 {
+		openaiReq.ToolChoice = tc.toOpenAI()
+	}
 
 	return json.Marshal(openaiReq)
 }
@@ -131,11 +154,7 @@ func (c *geminiToOpenAIResponse) Transform(body []byte) ([]byte, error) {
 	}
 
 	if resp.UsageMetadata != nil {
-		openaiResp.Usage = OpenAIUsage{
-			PromptTokens:     resp.UsageMetadata.PromptTokenCount,
-			CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
-			TotalTokens:      resp.UsageMetadata.TotalTokenCount,
-		}
+		openaiResp.Usage = geminiUsageToOpenAI(resp.UsageMetadata)
 	}
 
 	msg := OpenAIMessage{Role: "assistant"}
@@ -146,13 +165,18 @@ func (c *geminiToOpenAIResponse) Transform(body []byte) ([]byte, error) {
 	if len(resp.Candidates) > 0 {
 		candidate := resp.Candidates[0]
 		for _, part := range candidate.Content.Parts {
-			if part.Text != "" {
+			if part.Thought {
+				msg.ReasoningContent += part.Text
+			} else if part.Text != "" {
 				textContent += part.Text
 			}
+			if part.InlineData != nil && part.InlineData.Data != "" {
+				textContent += inlineDataToMarkdown(part.InlineData)
+			}
 			if part.FunctionCall != nil {
 				argsJSON, _ := json.Marshal(part.FunctionCall.Args)
 				toolCalls = append(toolCalls, OpenAIToolCall{
-					ID:   "call_" + part.FunctionCall.Name,
+					ID:   geminiToolCallID(part.FunctionCall.ID, part.FunctionCall.Name),
 					Type: "function",
 					Function: OpenAIFunctionCall{
 						Name:      part.FunctionCall.Name,
@@ -162,16 +186,12 @@ func (c *geminiToOpenAIResponse) Transform(body []byte) ([]byte, error) {
 			}
 		}
 
-		switch candidate.FinishReason {
-		case "STOP":
-			if len(toolCalls) > 0 {
-				finishReason = "tool_calls"
-			} else {
-				finishReason = "stop"
-			}
-		case "MAX_TOKENS":
-			finishReason = "length"
+		if grounding, annotations := GroundingAnnotations(candidate.GroundingMetadata, utf8.RuneCountInString(textContent)); grounding != "" {
+			textContent += grounding
+			msg.Annotations = annotations
 		}
+
+		finishReason = StopReasonFromGemini(candidate.FinishReason, len(toolCalls) > 0).OpenAI()
 	}
 
 	if textContent != "" {
@@ -219,25 +239,57 @@ func (c *geminiToOpenAIResponse) TransformChunk(chunk []byte, state *TransformSt
 		if len(geminiChunk.Candidates) > 0 {
 			candidate := geminiChunk.Candidates[0]
 			for _, part := range candidate.Content.Parts {
-				if part.Text != "" {
+				if part.Thought {
+					if part.Text != "" {
+						openaiChunk := OpenAIStreamChunk{
+							ID:      state.MessageID,
+							Object:  "chat.completion.chunk",
+							Created: time.Now().Unix(),
+							Choices: []OpenAIChoice{{
+								Index: 0,
+								Delta: &OpenAIMessage{ReasoningContent: part.Text},
+							}},
+						}
+						output = append(output, FormatSSE("", openaiChunk)...)
+					}
+					continue
+				}
+				content := part.Text
+				if part.InlineData != nil && part.InlineData.Data != "" {
+					content += inlineDataToMarkdown(part.InlineData)
+				}
+				if content != "" {
+					state.ContentLength += utf8.RuneCountInString(content)
 					openaiChunk := OpenAIStreamChunk{
 						ID:      state.MessageID,
 						Object:  "chat.completion.chunk",
 						Created: time.Now().Unix(),
 						Choices: []OpenAIChoice{{
 							Index: 0,
-							Delta: &OpenAIMessage{Content: part.Text},
+							Delta: &OpenAIMessage{Content: content},
 						}},
 					}
 					output = append(output, FormatSSE("", openaiChunk)...)
 				}
 			}
 
-			if candidate.FinishReason != "" {
-				finishReason := "stop"
-				if candidate.FinishReason == "MAX_TOKENS" {
-					finishReason = "length"
+			// Grounding comes with the last chunk, its sources are appended with their annotations
+			if grounding, annotations := GroundingAnnotations(candidate.GroundingMetadata, state.ContentLength); grounding != "" {
+				state.ContentLength += utf8.RuneCountInString(grounding)
+				openaiChunk := OpenAIStreamChunk{
+					ID:      state.MessageID,
+					Object:  "chat.completion.chunk",
+					Created: time.Now().Unix(),
+					Choices: []OpenAIChoice{{
+						Index: 0,
+						Delta: &OpenAIMessage{Content: grounding, Annotations: annotations},
+					}},
 				}
+				output = append(output, FormatSSE("", openaiChunk)...)
+			}
+
+			if candidate.FinishReason != "" {
+				finishReason := StopReasonFromGemini(candidate.FinishReason, false).OpenAI()
 				openaiChunk := OpenAIStreamChunk{
 					ID:      state.MessageID,
 					Object:  "chat.completion.chunk",
@@ -256,3 +308,26 @@ func (c *geminiToOpenAIResponse) TransformChunk(chunk []byte, state *TransformSt
 
 	return output, nil
 }
+
+// inlineDataToMarkdown renders a Gemini inline image as a markdown image with a data URL,
+// which OpenAI clients display inline in the assistant message content
+func inlineDataToMarkdown(data *GeminiInlineData) string {
+	return fmt.Sprintf("![image](data:%s;base64,%s)", data.MimeType, data.Data)
+}
+
+// geminiUsageToOpenAI converts Gemini usage. OpenAI counts reasoning tokens as
+// part of completion_tokens while Gemini reports thoughts apart from the candidates.
+func geminiUsageToOpenAI(u *GeminiUsageMetadata) OpenAIUsage {
+	usage := OpenAIUsage{
+		PromptTokens:     u.PromptTokenCount,
+		CompletionTokens: u.CandidatesTokenCount + u.ThoughtsTokenCount,
+		TotalTokens:      u.TotalTokenCount,
+	}
+	if u.CachedContentTokenCount > 0 {
+		usage.PromptTokensDetails = &OpenAIPromptDetails{CachedTokens: u.CachedContentTokenCount}
+	}
+	if u.ThoughtsTokenCount > 0 {
+		usage.CompletionTokensDetails = &OpenAICompletionDetails{ReasoningTokens: u.ThoughtsTokenCount}
+	}
+	return usage
+}
@@ -0,0 +1,113 @@
+package converter
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const geminiImageResponse = `{"candidates":[{"content":{"role":"model","parts":[{"text":"Here you go:"},{"inlineData":{"mimeType":"image/png","data":"iVBORw0KGgo="}}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":7,"totalTokenCount":12}}`
+
+const wantImageMarkdown = "![image](data:image/png;base64,iVBORw0KGgo=)"
+
+func TestGeminiToOpenAIResponseInlineData(t *testing.T) {
+	out, err := (&geminiToOpenAIResponse{}).Transform([]byte(geminiImageResponse))
+	if err != nil {
+		t.Fatalf("Transform failed: %v", err)
+	}
+
+	var resp OpenAIResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatalf("invalid OpenAI response: %v", err)
+	}
+	if len(resp.Choices) != 1 || resp.Choices[0].Message == nil {
+		t.Fatalf("unexpected choices: %s", out)
+	}
+	content, _ := resp.Choices[0].Message.Content.(string)
+	if content != "Here you go:"+wantImageMarkdown {
+		t.Errorf("content = %q, want text followed by image data URL", content)
+	}
+}
+
+func TestGeminiToOpenAIStreamInlineData(t *testing.T) {
+	state := &TransformState{}
+	out, err := (&geminiToOpenAIResponse{}).TransformChunk([]byte("data: "+geminiImageResponse+"\n\n"), state)
+	if err != nil {
+		t.Fatalf("TransformChunk failed: %v", err)
+	}
+
+	var content strings.Builder
+	events, _ := ParseSSE(string(out))
+	for _, event := range events {
+		var chunk OpenAIStreamChunk
+		if err := json.Unmarshal(event.Data, &chunk); err != nil {
+			continue
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta != nil {
+			if s, ok := chunk.Choices[0].Delta.Content.(string); ok {
+				content.WriteString(s)
+			}
+		}
+	}
+	if !strings.Contains(content.String(), wantImageMarkdown) {
+		t.Errorf("streamed content %q does not carry the image", content.String())
+	}
+}
+
+const geminiGroundedResponse = `{"candidates":[{"content":{"role":"model","parts":[{"text":"Grounded answer."}]},"finishReason":"STOP","groundingMetadata":{"webSearchQueries":["q"],"groundingChunks":[{"web":{"uri":"https://example.com/a","title":"A"}},{"web":{"uri":"https://example.com/b"}}]}}]}`
+
+func checkAnnotations(t *testing.T, content string, annotations []OpenAIAnnotation) {
+	t.Helper()
+	if len(annotations) != 2 {
+		t.Fatalf("annotations = %+v, want one per source", annotations)
+	}
+	for i, url := range []string{"https://example.com/a", "https://example.com/b"} {
+		c := annotations[i].URLCitation
+		if annotations[i].Type != "url_citation" || c == nil || c.URL != url {
+			t.Fatalf("annotation %d = %+v", i, annotations[i])
+		}
+		if cited := string([]rune(content)[c.StartIndex:c.EndIndex]); !strings.Contains(cited, "("+url+")") {
+			t.Errorf("annotation %d spans %q, want the sources list", i, cited)
+		}
+	}
+}
+
+func TestGeminiToOpenAIResponseGrounding(t *testing.T) {
+	out, err := (&geminiToOpenAIResponse{}).Transform([]byte(geminiGroundedResponse))
+	if err != nil {
+		t.Fatalf("Transform failed: %v", err)
+	}
+	var resp OpenAIResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatalf("invalid OpenAI response: %v", err)
+	}
+	msg := resp.Choices[0].Message
+	content, _ := msg.Content.(string)
+	if !strings.HasPrefix(content, "Grounded answer.\n\n---\n") {
+		t.Errorf("content = %q, want the answer followed by its sources", content)
+	}
+	checkAnnotations(t, content, msg.Annotations)
+}
+
+func TestGeminiToOpenAIStreamGrounding(t *testing.T) {
+	state := &TransformState{}
+	out, err := (&geminiToOpenAIResponse{}).TransformChunk([]byte("data: "+geminiGroundedResponse+"\n\n"), state)
+	if err != nil {
+		t.Fatalf("TransformChunk failed: %v", err)
+	}
+
+	var content strings.Builder
+	var annotations []OpenAIAnnotation
+	events, _ := ParseSSE(string(out))
+	for _, event := range events {
+		var chunk OpenAIStreamChunk
+		if err := json.Unmarshal(event.Data, &chunk); err != nil || len(chunk.Choices) == 0 || chunk.Choices[0].Delta == nil {
+			continue
+		}
+		if s, ok := chunk.Choices[0].Delta.Content.(string); ok {
+			content.WriteString(s)
+		}
+		annotations = append(annotations, chunk.Choices[0].Delta.Annotations...)
+	}
+	checkAnnotations(t, content.String(), annotations)
+}
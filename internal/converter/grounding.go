@@ -0,0 +1,144 @@
+package converter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// GeminiGroundingMetadata is the grounding (web search) metadata of a Gemini candidate
+type GeminiGroundingMetadata struct {
+	WebSearchQueries []string                `json:"webSearchQueries,omitempty"`
+	GroundingChunks  []GeminiGroundingChunk  `json:"groundingChunks,omitempty"`
+	SearchEntryPoint *GeminiSearchEntryPoint `json:"searchEntryPoint,omitempty"`
+}
+
+// GeminiGroundingChunk is a grounding source (web search result)
+type GeminiGroundingChunk struct {
+	Web *GeminiGroundingWeb `json:"web,omitempty"`
+}
+
+// GeminiGroundingWeb is the web page of a grounding chunk
+type GeminiGroundingWeb struct {
+	URI   string `json:"uri,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+// GeminiSearchEntryPoint is the rendered Google Search suggestion
+type GeminiSearchEntryPoint struct {
+	RenderedContent string `json:"renderedContent,omitempty"`
+}
+
+// Empty reports whether the metadata holds neither queries nor sources
+func (g *GeminiGroundingMetadata) Empty() bool {
+	return g == nil || (len(g.WebSearchQueries) == 0 && len(g.GroundingChunks) == 0)
+}
+
+// Merge adds the queries and sources of other. Streams may spread grounding
+// over several chunks, the sources of a later chunk replace earlier ones.
+func (g *GeminiGroundingMetadata) Merge(other *GeminiGroundingMetadata) {
+	if other == nil {
+		return
+	}
+	for _, q := range other.WebSearchQueries {
+		if !slices.Contains(g.WebSearchQueries, q) {
+			g.WebSearchQueries = append(g.WebSearchQueries, q)
+		}
+	}
+	if len(other.GroundingChunks) > 0 {
+		g.GroundingChunks = other.GroundingChunks
+	}
+	if other.SearchEntryPoint != nil {
+		g.SearchEntryPoint = other.SearchEntryPoint
+	}
+}
+
+// GroundingMarkdown renders the search queries and numbered source links as
+// markdown (same format as Antigravity-Manager), appended after the answer
+func GroundingMarkdown(g *GeminiGroundingMetadata) string {
+	if g.Empty() {
+		return ""
+	}
+
+	var b strings.Builder
+	if len(g.WebSearchQueries) > 0 {
+		b.WriteString("\n\n---\n**🔍 已为您搜索：** ")
+		b.WriteString(strings.Join(g.WebSearchQueries, ", "))
+	}
+
+	var links []string
+	for i, chunk := range g.GroundingChunks {
+		if chunk.Web == nil {
+			continue
+		}
+		title := chunk.Web.Title
+		if title == "" {
+			title = "网页来源"
+		}
+		uri := chunk.Web.URI
+		if uri == "" {
+			uri = "#"
+		}
+		links = append(links, fmt.Sprintf("[%d] [%s](%s)", i+1, title, uri))
+	}
+	if len(links) > 0 {
+		b.WriteString("\n\n**🌐 来源引文：**\n")
+		b.WriteString(strings.Join(links, "\n"))
+	}
+	return b.String()
+}
+
+// GroundingCitations returns a Claude web search citation per grounding source with a URL
+func GroundingCitations(g *GeminiGroundingMetadata) []ClaudeCitation {
+	if g == nil {
+		return nil
+	}
+	var citations []ClaudeCitation
+	seen := make(map[string]bool)
+	for _, chunk := range g.GroundingChunks {
+		if chunk.Web == nil || chunk.Web.URI == "" || seen[chunk.Web.URI] {
+			continue
+		}
+		seen[chunk.Web.URI] = true
+		citations = append(citations, ClaudeCitation{
+			Type:  ClaudeCitationWebSearch,
+			URL:   chunk.Web.URI,
+			Title: chunk.Web.Title,
+		})
+	}
+	return citations
+}
+
+// CitationAnnotations converts Claude citations of a text span to OpenAI
+// url_citation annotations. start and end are character offsets in the message content.
+func CitationAnnotations(citations []ClaudeCitation, start, end int) []OpenAIAnnotation {
+	var annotations []OpenAIAnnotation
+	for _, c := range citations {
+		if c.URL == "" {
+			continue
+		}
+		annotations = append(annotations, OpenAIAnnotation{
+			Type: "url_citation",
+			URLCitation: &OpenAIURLCitation{
+				URL:        c.URL,
+				Title:      c.Title,
+				StartIndex: start,
+				EndIndex:   end,
+			},
+		})
+	}
+	return annotations
+}
+
+// GroundingAnnotations renders the grounding of an OpenAI message whose content
+// so far is contentLength characters long. It returns the sources markdown to
+// append and the url_citation annotations pointing at it.
+func GroundingAnnotations(g *GeminiGroundingMetadata, contentLength int) (string, []OpenAIAnnotation) {
+	text := GroundingMarkdown(g)
+	if text == "" {
+		return "", nil
+	}
+	end := contentLength + utf8.RuneCountInString(text)
+	return text, CitationAnnotations(GroundingCitations(g), contentLength, end)
+}
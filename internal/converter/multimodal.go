@@ -0,0 +1,123 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// ErrUnsupportedContent is returned when a request carries a content part the target
+// format has no equivalent for. The request must not be forwarded without it.
+var ErrUnsupportedContent = errors.New("content not supported by target provider")
+
+// unsupportedContent wraps ErrUnsupportedContent with the part and target format
+func unsupportedContent(part string, target domain.ClientType) error {
+	return fmt.Errorf("%w: %s cannot be converted to %s", ErrUnsupportedContent, part, target)
+}
+
+// openaiAudioMimeTypes maps the input_audio formats OpenAI accepts to MIME types
+var openaiAudioMimeTypes = map[string]string{
+	"wav": "audio/wav",
+	"mp3": "audio/mp3",
+}
+
+// openaiInputAudio returns the MIME type and base64 data of an OpenAI input_audio part
+func openaiInputAudio(part map[string]interface{}) (mimeType, data string, ok bool) {
+	audio, _ := part["input_audio"].(map[string]interface{})
+	data, _ = audio["data"].(string)
+	format, _ := audio["format"].(string)
+	mimeType, ok = openaiAudioMimeTypes[strings.ToLower(format)]
+	return mimeType, data, ok && data != ""
+}
+
+// openaiFileData returns the media type and base64 data of an OpenAI file part.
+// ok is false for files referenced by file_id, which only OpenAI can resolve.
+func openaiFileData(part map[string]interface{}) (mediaType, data string, ok bool) {
+	file, _ := part["file"].(map[string]interface{})
+	fileData, _ := file["file_data"].(string)
+	return parseImageDataURL(fileData)
+}
+
+// isRemoteURL reports whether url can be fetched by the upstream itself
+func isRemoteURL(url string) bool {
+	return strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://")
+}
+
+// openaiPartToClaude converts an OpenAI content part to a Claude content block.
+// Claude takes images inline or by URL and PDF files as documents, audio is rejected.
+func openaiPartToClaude(part map[string]interface{}) (ClaudeContentBlock, error) {
+	partType, _ := part["type"].(string)
+	switch partType {
+	case "text":
+		text, _ := part["text"].(string)
+		return ClaudeContentBlock{Type: "text", Text: text}, nil
+	case "refusal":
+		text, _ := part["refusal"].(string)
+		return ClaudeContentBlock{Type: "text", Text: text}, nil
+	case "image_url":
+		url := openaiImageURL(part)
+		if mediaType, data, ok := parseImageDataURL(url); ok && strings.HasPrefix(mediaType, "image/") {
+			return ClaudeContentBlock{
+				Type:   "image",
+				Source: &ClaudeImageSource{Type: "base64", MediaType: mediaType, Data: data},
+			}, nil
+		}
+		if isRemoteURL(url) {
+			return ClaudeContentBlock{Type: "image", Source: &ClaudeImageSource{Type: "url", URL: url}}, nil
+		}
+		return ClaudeContentBlock{}, unsupportedContent("image_url without an image data URL or http(s) URL", domain.ClientTypeClaude)
+	case "file":
+		mediaType, data, ok := openaiFileData(part)
+		if !ok {
+			return ClaudeContentBlock{}, unsupportedContent("file without inline file_data", domain.ClientTypeClaude)
+		}
+		if mediaType != "application/pdf" {
+			return ClaudeContentBlock{}, unsupportedContent("file of type "+mediaType, domain.ClientTypeClaude)
+		}
+		return ClaudeContentBlock{
+			Type:   "document",
+			Source: &ClaudeImageSource{Type: "base64", MediaType: mediaType, Data: data},
+		}, nil
+	}
+	return ClaudeContentBlock{}, unsupportedContent(partName(partType), domain.ClientTypeClaude)
+}
+
+// openaiPartToGemini converts an OpenAI content part to a Gemini part. Gemini takes
+// images, audio and files inline, remote URLs and file IDs are rejected.
+func openaiPartToGemini(part map[string]interface{}) (GeminiPart, error) {
+	partType, _ := part["type"].(string)
+	switch partType {
+	case "text":
+		text, _ := part["text"].(string)
+		return GeminiPart{Text: text}, nil
+	case "refusal":
+		text, _ := part["refusal"].(string)
+		return GeminiPart{Text: text}, nil
+	case "image_url":
+		if mediaType, data, ok := parseImageDataURL(openaiImageURL(part)); ok {
+			return GeminiPart{InlineData: &GeminiInlineData{MimeType: mediaType, Data: data}}, nil
+		}
+		return GeminiPart{}, unsupportedContent("image_url without a data URL", domain.ClientTypeGemini)
+	case "input_audio":
+		if mimeType, data, ok := openaiInputAudio(part); ok {
+			return GeminiPart{InlineData: &GeminiInlineData{MimeType: mimeType, Data: data}}, nil
+		}
+		return GeminiPart{}, unsupportedContent("input_audio without wav or mp3 data", domain.ClientTypeGemini)
+	case "file":
+		if mediaType, data, ok := openaiFileData(part); ok {
+			return GeminiPart{InlineData: &GeminiInlineData{MimeType: mediaType, Data: data}}, nil
+		}
+		return GeminiPart{}, unsupportedContent("file without inline file_data", domain.ClientTypeGemini)
+	}
+	return GeminiPart{}, unsupportedContent(partName(partType), domain.ClientTypeGemini)
+}
+
+// partName names a content part in errors
+func partName(partType string) string {
+	if partType == "" {
+		return "untyped content part"
+	}
+	return partType + " content part"
+}
@@ -0,0 +1,101 @@
+package converter
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// multimodalRequest builds an OpenAI request with a text part followed by the given parts
+func multimodalRequest(parts ...string) []byte {
+	return []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":[{"type":"text","text":"Describe these"},` + strings.Join(parts, ",") + `]}]}`)
+}
+
+const (
+	inlineImagePart = `{"type":"image_url","image_url":{"url":"data:image/png;base64,aW1n"}}`
+	remoteImagePart = `{"type":"image_url","image_url":{"url":"https://example.com/cat.png"}}`
+	audioPart       = `{"type":"input_audio","input_audio":{"data":"YXVkaW8=","format":"wav"}}`
+	pdfPart         = `{"type":"file","file":{"filename":"a.pdf","file_data":"data:application/pdf;base64,cGRm"}}`
+	fileIDPart      = `{"type":"file","file":{"file_id":"file-abc"}}`
+)
+
+func TestOpenAIMultimodalToGemini(t *testing.T) {
+	out, err := (&openaiToGeminiRequest{}).Transform(multimodalRequest(inlineImagePart, audioPart, pdfPart), "gemini-2.5-flash", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req GeminiRequest
+	if err := json.Unmarshal(out, &req); err != nil || len(req.Contents) != 1 {
+		t.Fatalf("Gemini request = %s, %v", out, err)
+	}
+	parts := req.Contents[0].Parts
+	want := []GeminiInlineData{
+		{MimeType: "image/png", Data: "aW1n"},
+		{MimeType: "audio/wav", Data: "YXVkaW8="},
+		{MimeType: "application/pdf", Data: "cGRm"},
+	}
+	if len(parts) != len(want)+1 || parts[0].Text != "Describe these" {
+		t.Fatalf("parts = %s", out)
+	}
+	for i, w := range want {
+		if got := parts[i+1].InlineData; got == nil || *got != w {
+			t.Errorf("part %d inline data = %+v, want %+v", i+1, got, w)
+		}
+	}
+}
+
+func TestOpenAIMultimodalToClaude(t *testing.T) {
+	out, err := (&openaiToClaudeRequest{}).Transform(multimodalRequest(inlineImagePart, remoteImagePart, pdfPart), "claude-sonnet-4", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req struct {
+		Messages []struct {
+			Content []ClaudeContentBlock `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(out, &req); err != nil || len(req.Messages) != 1 {
+		t.Fatalf("Claude request = %s, %v", out, err)
+	}
+	blocks := req.Messages[0].Content
+	want := []struct {
+		blockType string
+		source    ClaudeImageSource
+	}{
+		{"image", ClaudeImageSource{Type: "base64", MediaType: "image/png", Data: "aW1n"}},
+		{"image", ClaudeImageSource{Type: "url", URL: "https://example.com/cat.png"}},
+		{"document", ClaudeImageSource{Type: "base64", MediaType: "application/pdf", Data: "cGRm"}},
+	}
+	if len(blocks) != len(want)+1 || blocks[0].Text != "Describe these" {
+		t.Fatalf("blocks = %s", out)
+	}
+	for i, w := range want {
+		b := blocks[i+1]
+		if b.Type != w.blockType || b.Source == nil || *b.Source != w.source {
+			t.Errorf("block %d = %s %+v, want %s %+v", i+1, b.Type, b.Source, w.blockType, w.source)
+		}
+	}
+}
+
+func TestOpenAIMultimodalUnsupported(t *testing.T) {
+	for _, tc := range []struct {
+		target domain.ClientType
+		part   string
+	}{
+		{domain.ClientTypeClaude, audioPart},
+		{domain.ClientTypeClaude, fileIDPart},
+		{domain.ClientTypeClaude, `{"type":"file","file":{"file_data":"data:text/csv;base64,YSxi"}}`},
+		{domain.ClientTypeClaude, `{"type":"video_url","video_url":{"url":"https://example.com/a.mp4"}}`},
+		{domain.ClientTypeGemini, remoteImagePart},
+		{domain.ClientTypeGemini, fileIDPart},
+		{domain.ClientTypeGemini, `{"type":"input_audio","input_audio":{"data":"YXVkaW8=","format":"flac"}}`},
+	} {
+		_, err := GetGlobalRegistry().TransformRequest(domain.ClientTypeOpenAI, tc.target, multimodalRequest(tc.part), "model", false)
+		if !errors.Is(err, ErrUnsupportedContent) {
+			t.Errorf("%s to %s: err = %v, want ErrUnsupportedContent", tc.part, tc.target, err)
+		}
+	}
+}
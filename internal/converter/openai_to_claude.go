@@ -30,25 +30,15 @@ func (c *openaiToClaudeRequest) Transform(body []byte, model string, stream bool
 	if req.MaxCompletionTokens > 0 && req.MaxTokens == 0 {
 		claudeReq.MaxTokens = req.MaxCompletionTokens
 	}
+	if req.User != "" {
+		claudeReq.Metadata = &ClaudeMetadata{UserID: req.User}
+	}
 
 	// Convert messages
 	for _, msg := range req.Messages {
 		if msg.Role == "system" {
 			// Extract system message
-			switch content := msg.Content.(type) {
-			case string:
-				claudeReq.System = content
-			case []interface{}:
-				var systemText string
-				for _, part := range content {
-					if m, ok := part.(map[string]interface{}); ok {
-						if text, ok := m["text"].(string); ok {
-							systemText += text
-						}
-					}
-				}
-				claudeReq.System = systemText
-			}
+			claudeReq.System = openaiContentText(msg.Content)
 			continue
 		}
 
@@ -57,11 +47,10 @@ func (c *openaiToClaudeRequest) Transform(body []byte, model string, stream bool
 		// Handle tool messages
 		if msg.Role == "tool" {
 			claudeMsg.Role = "user"
-			contentStr, _ := msg.Content.(string)
 			claudeMsg.Content = []ClaudeContentBlock{{
 				Type:      "tool_result",
 				ToolUseID: msg.ToolCallID,
-				Content:   contentStr,
+				Content:   openaiContentText(msg.Content),
 			}}
 			claudeReq.Messages = append(claudeReq.Messages, claudeMsg)
 			continue
@@ -75,12 +64,11 @@ func (c *openaiToClaudeRequest) Transform(body []byte, model string, stream bool
 			var blocks []ClaudeContentBlock
 			for _, part := range content {
 				if m, ok := part.(map[string]interface{}); ok {
-					partType, _ := m["type"].(string)
-					switch partType {
-					case "text":
-						text, _ := m["text"].(string)
-						blocks = append(blocks, ClaudeContentBlock{Type: "text", Text: text})
+					block, err := openaiPartToClaude(m)
+					if err != nil {
+						return nil, err
 					}
+					blocks = append(blocks, block)
 				}
 			}
 			if len(blocks) == 1 && blocks[0].Type == "text" {
@@ -121,6 +109,27 @@ func (c *openaiToClaudeRequest) Transform(body []byte, model string, stream bool
 		})
 	}
 
+	// parallel_tool_calls: false maps to Claude's native single-tool switch
+	if len(req.Tools) > 0 {
+		tc := openaiToolChoice(req.ToolChoice)
+		if req.ParallelToolCalls != nil && !*req.ParallelToolCalls {
+			if tc == nil {
+				tc = &toolChoice{Mode: toolChoiceAuto}
+			}
+			tc.DisableParallel = true
+		}
+		if tc != nil {
+			claudeReq.ToolChoice = tc.toClaude()
+		}
+	}
+
+	if budget := effortToBudget(req.ReasoningEffort); budget > 0 {
+		claudeReq.Thinking = claudeThinking(budget, claudeReq.MaxTokens)
+	}
+	if rf := openaiResponseFormat(req.ResponseFormat); rf != nil {
+		claudeReq.OutputFormat = rf.toClaude()
+	}
+
 	// Convert stop
 	switch stop := req.Stop.(type) {
 	case string:
@@ -147,15 +156,19 @@ func (c *openaiToClaudeResponse) Transform(body []byte) ([]byte, error) {
 		Type:  "message",
 		Role:  "assistant",
 		Model: resp.Model,
-		Usage: ClaudeUsage{
-			InputTokens:  resp.Usage.PromptTokens,
-			OutputTokens: resp.Usage.CompletionTokens,
-		},
+		Usage: openaiUsageToClaude(resp.Usage),
 	}
 
 	if len(resp.Choices) > 0 {
 		choice := resp.Choices[0]
 		if choice.Message != nil {
+			if choice.Message.ReasoningContent != "" {
+				claudeResp.Content = append(claudeResp.Content, ClaudeContentBlock{
+					Type:     "thinking",
+					Thinking: choice.Message.ReasoningContent,
+				})
+			}
+
 			// Convert content
 			if content, ok := choice.Message.Content.(string); ok && content != "" {
 				claudeResp.Content = append(claudeResp.Content, ClaudeContentBlock{
@@ -176,15 +189,7 @@ func (c *openaiToClaudeResponse) Transform(body []byte) ([]byte, error) {
 				})
 			}
 
-			// Map finish reason
-			switch choice.FinishReason {
-			case "stop":
-				claudeResp.StopReason = "end_turn"
-			case "length":
-				claudeResp.StopReason = "max_tokens"
-			case "tool_calls":
-				claudeResp.StopReason = "tool_use"
-			}
+			claudeResp.StopReason = StopReasonFromOpenAI(choice.FinishReason, len(choice.Message.ToolCalls) > 0).Claude()
 		}
 	}
 
@@ -270,20 +275,11 @@ func (c *openaiToClaudeResponse) TransformChunk(chunk []byte, state *TransformSt
 			}
 			output = append(output, FormatSSE("content_block_stop", blockStop)...)
 
-			// Map finish reason
-			stopReason := "end_turn"
-			switch choice.FinishReason {
-			case "length":
-				stopReason = "max_tokens"
-			case "tool_calls":
-				stopReason = "tool_use"
-			}
-
 			// Send message_delta
 			msgDelta := map[string]interface{}{
 				"type": "message_delta",
 				"delta": map[string]interface{}{
-					"stop_reason": stopReason,
+					"stop_reason": StopReasonFromOpenAI(choice.FinishReason, false).Claude(),
 				},
 				"usage": map[string]int{"output_tokens": state.Usage.OutputTokens},
 			}
@@ -293,3 +289,17 @@ func (c *openaiToClaudeResponse) TransformChunk(chunk []byte, state *TransformSt
 
 	return output, nil
 }
+
+// openaiUsageToClaude converts OpenAI usage. OpenAI counts cached tokens as part
+// of prompt_tokens while Claude reports them next to input_tokens.
+func openaiUsageToClaude(u OpenAIUsage) ClaudeUsage {
+	usage := ClaudeUsage{
+		InputTokens:  u.PromptTokens,
+		OutputTokens: u.CompletionTokens,
+	}
+	if u.PromptTokensDetails != nil && u.PromptTokensDetails.CachedTokens > 0 {
+		usage.CacheReadInputTokens = u.PromptTokensDetails.CachedTokens
+		usage.InputTokens = max(u.PromptTokens-u.PromptTokensDetails.CachedTokens, 0)
+	}
+	return usage
+}
@@ -144,6 +144,7 @@ func (c *openaiToCodexResponse) Transform(body []byte) ([]byte, error) {
 					Status:    "completed",
 				})
 			}
+			setCodexStopReason(&codexResp, StopReasonFromOpenAI(choice.FinishReason, len(choice.Message.ToolCalls) > 0))
 		}
 	}
 
@@ -204,11 +205,8 @@ func (c *openaiToCodexResponse) TransformChunk(chunk []byte, state *TransformSta
 
 			if choice.FinishReason != "" {
 				codexEvent := map[string]interface{}{
-					"type": "response.done",
-					"response": map[string]interface{}{
-						"id":     state.MessageID,
-						"status": "completed",
-					},
+					"type":     "response.done",
+					"response": codexStreamResponse(state.MessageID, StopReasonFromOpenAI(choice.FinishReason, false)),
 				}
 				output = append(output, FormatSSE("", codexEvent)...)
 			}
@@ -43,14 +43,18 @@ func (c *openaiToGeminiRequest) Transform(body []byte, model string, stream bool
 		}
 	}
 
+	// Gemini matches function responses by name, OpenAI tool messages only carry the call ID
+	toolNames := make(map[string]string)
+	for _, msg := range req.Messages {
+		for _, tc := range msg.ToolCalls {
+			toolNames[tc.ID] = tc.Function.Name
+		}
+	}
+
 	// Convert messages
 	for _, msg := range req.Messages {
 		if msg.Role == "system" {
-			var systemText string
-			if content, ok := msg.Content.(string); ok {
-				systemText = content
-			}
-			if systemText != "" {
+			if systemText := openaiContentText(msg.Content); systemText != "" {
 				// [FIX] Set role to "user" for systemInstruction (like CLIProxyAPI)
 				geminiReq.SystemInstruction = &GeminiContent{
 					Role:  "user",
@@ -68,11 +72,15 @@ func (c *openaiToGeminiRequest) Transform(body []byte, model string, stream bool
 			geminiContent.Role = "model"
 		case "tool":
 			geminiContent.Role = "user"
-			contentStr, _ := msg.Content.(string)
+			name := toolNames[msg.ToolCallID]
+			if name == "" {
+				name = msg.ToolCallID
+			}
 			geminiContent.Parts = []GeminiPart{{
 				FunctionResponse: &GeminiFunctionResponse{
-					Name:     msg.ToolCallID,
-					Response: map[string]string{"result": contentStr},
+					Name:     name,
+					Response: geminiToolResponse(openaiContentText(msg.Content)),
+					ID:       msg.ToolCallID,
 				},
 			}}
 			geminiReq.Contents = append(geminiReq.Contents, geminiContent)
@@ -86,11 +94,11 @@ func (c *openaiToGeminiRequest) Transform(body []byte, model string, stream bool
 		case []interface{}:
 			for _, part := range content {
 				if m, ok := part.(map[string]interface{}); ok {
-					if m["type"] == "text" {
-						if text, ok := m["text"].(string); ok {
-							geminiContent.Parts = append(geminiContent.Parts, GeminiPart{Text: text})
-						}
+					geminiPart, err := openaiPartToGemini(m)
+					if err != nil {
+						return nil, err
 					}
+					geminiContent.Parts = append(geminiContent.Parts, geminiPart)
 				}
 			}
 		}
@@ -103,6 +111,7 @@ func (c *openaiToGeminiRequest) Transform(body []byte, model string, stream bool
 				FunctionCall: &GeminiFunctionCall{
 					Name: tc.Function.Name,
 					Args: args,
+					ID:   tc.ID,
 				},
 			})
 		}
@@ -121,6 +130,29 @@ func (c *openaiToGeminiRequest) Transform(body []byte, model string, stream bool
 			})
 		}
 		geminiReq.Tools = []GeminiTool{{FunctionDeclarations: funcDecls}}
+
+		// Gemini has no parallel-call switch, so parallel_tool_calls: false becomes a system hint;
+		// the response path enforces it if the model ignores the hint
+		if req.ParallelToolCalls != nil && !*req.ParallelToolCalls {
+			if geminiReq.SystemInstruction == nil {
+				geminiReq.SystemInstruction = &GeminiContent{Role: "user"}
+			}
+			geminiReq.SystemInstruction.Parts = append(geminiReq.SystemInstruction.Parts, GeminiPart{Text: singleToolCallHint})
+		}
+
+		if tc := openaiToolChoice(req.ToolChoice); tc != nil {
+			geminiReq.ToolConfig = tc.toGemini()
+		}
+	}
+
+	if rf := openaiResponseFormat(req.ResponseFormat); rf != nil {
+		rf.applyToGemini(geminiReq.GenerationConfig)
+	}
+	if budget := effortToBudget(req.ReasoningEffort); budget > 0 {
+		geminiReq.GenerationConfig.ThinkingConfig = &GeminiThinkingConfig{
+			IncludeThoughts: true,
+			ThinkingBudget:  budget,
+		}
 	}
 
 	return json.Marshal(geminiReq)
@@ -133,11 +165,7 @@ func (c *openaiToGeminiResponse) Transform(body []byte) ([]byte, error) {
 	}
 
 	geminiResp := GeminiResponse{
-		UsageMetadata: &GeminiUsageMetadata{
-			PromptTokenCount:     resp.Usage.PromptTokens,
-			CandidatesTokenCount: resp.Usage.CompletionTokens,
-			TotalTokenCount:      resp.Usage.TotalTokens,
-		},
+		UsageMetadata: openaiUsageToGemini(resp.Usage),
 	}
 
 	candidate := GeminiCandidate{
@@ -148,6 +176,12 @@ func (c *openaiToGeminiResponse) Transform(body []byte) ([]byte, error) {
 	if len(resp.Choices) > 0 {
 		choice := resp.Choices[0]
 		if choice.Message != nil {
+			if choice.Message.ReasoningContent != "" {
+				candidate.Content.Parts = append(candidate.Content.Parts, GeminiPart{
+					Text:    choice.Message.ReasoningContent,
+					Thought: true,
+				})
+			}
 			if content, ok := choice.Message.Content.(string); ok && content != "" {
 				candidate.Content.Parts = append(candidate.Content.Parts, GeminiPart{Text: content})
 			}
@@ -158,18 +192,12 @@ func (c *openaiToGeminiResponse) Transform(body []byte) ([]byte, error) {
 					FunctionCall: &GeminiFunctionCall{
 						Name: tc.Function.Name,
 						Args: args,
+						ID:   tc.ID,
 					},
 				})
 			}
 
-			switch choice.FinishReason {
-			case "stop":
-				candidate.FinishReason = "STOP"
-			case "length":
-				candidate.FinishReason = "MAX_TOKENS"
-			case "tool_calls":
-				candidate.FinishReason = "STOP"
-			}
+			candidate.FinishReason = StopReasonFromOpenAI(choice.FinishReason, len(choice.Message.ToolCalls) > 0).Gemini()
 		}
 	}
 
@@ -210,13 +238,9 @@ func (c *openaiToGeminiResponse) TransformChunk(chunk []byte, state *TransformSt
 			}
 
 			if choice.FinishReason != "" {
-				finishReason := "STOP"
-				if choice.FinishReason == "length" {
-					finishReason = "MAX_TOKENS"
-				}
 				geminiChunk := GeminiStreamChunk{
 					Candidates: []GeminiCandidate{{
-						FinishReason: finishReason,
+						FinishReason: StopReasonFromOpenAI(choice.FinishReason, false).Gemini(),
 						Index:        0,
 					}},
 				}
@@ -227,3 +251,21 @@ func (c *openaiToGeminiResponse) TransformChunk(chunk []byte, state *TransformSt
 
 	return output, nil
 }
+
+// openaiUsageToGemini converts OpenAI usage. Both count cached tokens as part of
+// the prompt, but Gemini reports thoughts apart from the candidates.
+func openaiUsageToGemini(u OpenAIUsage) *GeminiUsageMetadata {
+	usage := &GeminiUsageMetadata{
+		PromptTokenCount:     u.PromptTokens,
+		CandidatesTokenCount: u.CompletionTokens,
+		TotalTokenCount:      u.TotalTokens,
+	}
+	if u.PromptTokensDetails != nil {
+		usage.CachedContentTokenCount = u.PromptTokensDetails.CachedTokens
+	}
+	if u.CompletionTokensDetails != nil && u.CompletionTokensDetails.ReasoningTokens > 0 {
+		usage.ThoughtsTokenCount = u.CompletionTokensDetails.ReasoningTokens
+		usage.CandidatesTokenCount = max(u.CompletionTokens-u.CompletionTokensDetails.ReasoningTokens, 0)
+	}
+	return usage
+}
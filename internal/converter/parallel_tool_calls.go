@@ -0,0 +1,135 @@
+package converter
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Policies for upstream responses that return several tool calls although the
+// client sent parallel_tool_calls: false
+const (
+	ToolCallPolicyFirst = "first" // keep only the first tool call
+	ToolCallPolicyError = "error" // replace the response with an error
+)
+
+// ErrMultipleToolCalls is returned when a response violates parallel_tool_calls: false under the error policy
+var ErrMultipleToolCalls = errors.New("upstream returned multiple tool calls although parallel_tool_calls is false")
+
+// singleToolCallHint is added to the system prompt of formats without a native single-call switch
+const singleToolCallHint = "Call at most one tool per response. Do not issue parallel tool calls."
+
+// ParallelToolCallsDisabled reports whether an OpenAI request body sets parallel_tool_calls: false
+func ParallelToolCallsDisabled(body []byte) bool {
+	var req struct {
+		ParallelToolCalls *bool `json:"parallel_tool_calls"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false
+	}
+	return req.ParallelToolCalls != nil && !*req.ParallelToolCalls
+}
+
+// openaiToolChoiceToClaude converts an OpenAI tool_choice into Claude's tool_choice object
+func openaiToolChoiceToClaude(choice interface{}) map[string]interface{} {
+	switch v := choice.(type) {
+	case string:
+		switch v {
+		case "required":
+			return map[string]interface{}{"type": "any"}
+		case "none":
+			return map[string]interface{}{"type": "none"}
+		}
+	case map[string]interface{}:
+		if fn, ok := v["function"].(map[string]interface{}); ok {
+			if name, _ := fn["name"].(string); name != "" {
+				return map[string]interface{}{"type": "tool", "name": name}
+			}
+		}
+	}
+	return map[string]interface{}{"type": "auto"}
+}
+
+// LimitOpenAIToolCalls enforces a single tool call on a non-streaming OpenAI response
+func LimitOpenAIToolCalls(body []byte, policy string) ([]byte, error) {
+	var resp OpenAIResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return body, nil
+	}
+
+	changed := false
+	for i := range resp.Choices {
+		msg := resp.Choices[i].Message
+		if msg == nil || len(msg.ToolCalls) <= 1 {
+			continue
+		}
+		if policy == ToolCallPolicyError {
+			return nil, ErrMultipleToolCalls
+		}
+		msg.ToolCalls = msg.ToolCalls[:1]
+		changed = true
+	}
+	if !changed {
+		return body, nil
+	}
+	return json.Marshal(resp)
+}
+
+// LimitOpenAIToolCallsChunk enforces a single tool call on converted OpenAI SSE output.
+// Under the first policy deltas for later tool calls are dropped; under the error policy
+// the stream is terminated with an error event and everything after it is discarded.
+func LimitOpenAIToolCallsChunk(chunk []byte, policy string, state *TransformState) []byte {
+	if state.ToolCallLimitHit && policy == ToolCallPolicyError {
+		return nil
+	}
+
+	events, _ := ParseSSE(string(chunk))
+	var out []byte
+	for _, event := range events {
+		if event.Event == "done" {
+			out = append(out, FormatDone()...)
+			continue
+		}
+
+		var streamChunk OpenAIStreamChunk
+		if err := json.Unmarshal(event.Data, &streamChunk); err != nil {
+			out = append(out, FormatSSE("", []byte(event.Data))...)
+			continue
+		}
+
+		changed := false
+		for i := range streamChunk.Choices {
+			delta := streamChunk.Choices[i].Delta
+			if delta == nil || len(delta.ToolCalls) == 0 {
+				continue
+			}
+			var kept []OpenAIToolCall
+			for _, tc := range delta.ToolCalls {
+				if tc.Index == 0 {
+					kept = append(kept, tc)
+				}
+			}
+			if len(kept) == len(delta.ToolCalls) {
+				continue
+			}
+			state.ToolCallLimitHit = true
+			if policy == ToolCallPolicyError {
+				out = append(out, FormatSSE("", map[string]interface{}{
+					"error": map[string]string{
+						"message": ErrMultipleToolCalls.Error(),
+						"type":    "upstream_error",
+					},
+				})...)
+				return append(out, FormatDone()...)
+			}
+			delta.ToolCalls = kept
+			changed = true
+		}
+
+		if changed {
+			out = append(out, FormatSSE("", streamChunk)...)
+		} else {
+			out = append(out, FormatSSE("", []byte(event.Data))...)
+		}
+	}
+	return out
+}
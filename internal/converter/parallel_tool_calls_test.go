@@ -0,0 +1,88 @@
+package converter
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const openaiSingleToolRequest = `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"tools":[{"type":"function","function":{"name":"a"}},{"type":"function","function":{"name":"b"}}],"parallel_tool_calls":false}`
+
+func TestParallelToolCallsFalseToClaude(t *testing.T) {
+	out, err := (&openaiToClaudeRequest{}).Transform([]byte(openaiSingleToolRequest), "claude-sonnet-4-5", false)
+	if err != nil {
+		t.Fatalf("Transform failed: %v", err)
+	}
+
+	var req struct {
+		ToolChoice map[string]interface{} `json:"tool_choice"`
+	}
+	if err := json.Unmarshal(out, &req); err != nil {
+		t.Fatalf("invalid Claude request: %v", err)
+	}
+	if req.ToolChoice["type"] != "auto" || req.ToolChoice["disable_parallel_tool_use"] != true {
+		t.Errorf("tool_choice = %v, want auto with disable_parallel_tool_use", req.ToolChoice)
+	}
+}
+
+func TestParallelToolCallsFalseToGemini(t *testing.T) {
+	out, err := (&openaiToGeminiRequest{}).Transform([]byte(openaiSingleToolRequest), "gemini-2.5-pro", false)
+	if err != nil {
+		t.Fatalf("Transform failed: %v", err)
+	}
+
+	var req GeminiRequest
+	if err := json.Unmarshal(out, &req); err != nil {
+		t.Fatalf("invalid Gemini request: %v", err)
+	}
+	if req.SystemInstruction == nil || len(req.SystemInstruction.Parts) == 0 ||
+		req.SystemInstruction.Parts[len(req.SystemInstruction.Parts)-1].Text != singleToolCallHint {
+		t.Errorf("system instruction missing single tool hint: %s", out)
+	}
+}
+
+const openaiMultiToolResponse = `{"id":"x","object":"chat.completion","created":1,"model":"m","choices":[{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[{"id":"1","type":"function","function":{"name":"a","arguments":"{}"}},{"id":"2","type":"function","function":{"name":"b","arguments":"{}"}}]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`
+
+func TestLimitOpenAIToolCallsPolicies(t *testing.T) {
+	out, err := LimitOpenAIToolCalls([]byte(openaiMultiToolResponse), ToolCallPolicyFirst)
+	if err != nil {
+		t.Fatalf("first policy returned error: %v", err)
+	}
+	var resp OpenAIResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if calls := resp.Choices[0].Message.ToolCalls; len(calls) != 1 || calls[0].ID != "1" {
+		t.Errorf("tool calls = %+v, want only the first", calls)
+	}
+
+	if _, err := LimitOpenAIToolCalls([]byte(openaiMultiToolResponse), ToolCallPolicyError); !errors.Is(err, ErrMultipleToolCalls) {
+		t.Errorf("error policy returned %v, want ErrMultipleToolCalls", err)
+	}
+}
+
+func TestLimitOpenAIToolCallsChunk(t *testing.T) {
+	first := "data: {\"id\":\"x\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":null,\"tool_calls\":[{\"id\":\"1\",\"type\":\"function\",\"function\":{\"name\":\"a\",\"arguments\":\"\"}}]}}]}\n\n"
+	second := "data: {\"id\":\"x\",\"object\":\"chat.completion.chunk\",\"created\":1,\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":null,\"tool_calls\":[{\"index\":1,\"id\":\"2\",\"type\":\"function\",\"function\":{\"name\":\"b\",\"arguments\":\"\"}}]}}]}\n\n"
+
+	state := NewTransformState()
+	out := string(LimitOpenAIToolCallsChunk([]byte(first), ToolCallPolicyFirst, state))
+	if !strings.Contains(out, `"name":"a"`) {
+		t.Errorf("first tool call dropped: %s", out)
+	}
+	out = string(LimitOpenAIToolCallsChunk([]byte(second), ToolCallPolicyFirst, state))
+	if strings.Contains(out, `"name":"b"`) {
+		t.Errorf("second tool call not filtered: %s", out)
+	}
+
+	state = NewTransformState()
+	LimitOpenAIToolCallsChunk([]byte(first), ToolCallPolicyError, state)
+	out = string(LimitOpenAIToolCallsChunk([]byte(second), ToolCallPolicyError, state))
+	if !strings.Contains(out, `"error"`) || !strings.HasSuffix(out, "data: [DONE]\n\n") {
+		t.Errorf("error policy did not terminate the stream: %s", out)
+	}
+	if rest := LimitOpenAIToolCallsChunk([]byte(first), ToolCallPolicyError, state); rest != nil {
+		t.Errorf("output after error: %s", rest)
+	}
+}
@@ -0,0 +1,206 @@
+package converter
+
+import "strings"
+
+// truncatedKeyMember completes an object cut off right after a comma, where no
+// member can end the object without a key; tools can recognize it as truncation
+const truncatedKeyMember = `"_truncated":true`
+
+// What the JSON scanner expects next
+const (
+	jsonValue      = iota // a value
+	jsonFirstValue        // right after '[': a value or ']'
+	jsonFirstKey          // right after '{': a key or '}'
+	jsonKey               // after ',' in an object: a key
+	jsonColon             // after a key: ':'
+	jsonAfter             // after a value: ',' or the closing bracket
+)
+
+// ClosePartialJSON returns the text that completes a JSON document cut off at any
+// point, so that partial + suffix parses: the open string is closed, an unfinished
+// literal or number completed, a dangling key given a null value and the open
+// objects and arrays closed. An empty document is completed as an empty object.
+// ok is false when partial isn't the beginning of a JSON document.
+func ClosePartialJSON(partial string) (suffix string, ok bool) {
+	var (
+		stack    []byte
+		state    = jsonValue
+		seen     bool   // the document has started
+		inString bool   // inside a string
+		isKey    bool   // the string is an object key
+		escape   int    // 1 after a backslash, 2-5 inside a \u escape
+		literal  string // the rest of true, false or null being read
+		number   string // the number being read
+	)
+
+	for i := 0; i < len(partial); i++ {
+		c := partial[i]
+
+		if inString {
+			switch {
+			case escape == 1:
+				if c == 'u' {
+					escape = 2
+				} else if strings.IndexByte(`"\/bfnrt`, c) >= 0 {
+					escape = 0
+				} else {
+					return "", false
+				}
+			case escape >= 2:
+				if !isHexDigit(c) {
+					return "", false
+				}
+				if escape++; escape == 6 {
+					escape = 0
+				}
+			case c == '\\':
+				escape = 1
+			case c == '"':
+				inString = false
+				if isKey {
+					state = jsonColon
+				} else {
+					state = jsonAfter
+				}
+			case c < 0x20:
+				return "", false
+			}
+			continue
+		}
+
+		if literal != "" {
+			if c != literal[0] {
+				return "", false
+			}
+			if literal = literal[1:]; literal == "" {
+				state = jsonAfter
+			}
+			continue
+		}
+
+		if number != "" {
+			if isDigit(c) || strings.IndexByte(".eE+-", c) >= 0 {
+				number += string(c)
+				continue
+			}
+			number = ""
+			state = jsonAfter
+		}
+
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			continue
+		}
+
+		switch state {
+		case jsonValue, jsonFirstValue:
+			seen = true
+			switch {
+			case c == '{':
+				stack = append(stack, '{')
+				state = jsonFirstKey
+			case c == '[':
+				stack = append(stack, '[')
+				state = jsonFirstValue
+			case c == '"':
+				inString, isKey = true, false
+			case c == 't':
+				literal = "rue"
+			case c == 'f':
+				literal = "alse"
+			case c == 'n':
+				literal = "ull"
+			case c == '-' || isDigit(c):
+				number = string(c)
+			case c == ']' && state == jsonFirstValue:
+				stack = stack[:len(stack)-1]
+				state = jsonAfter
+			default:
+				return "", false
+			}
+		case jsonFirstKey, jsonKey:
+			switch {
+			case c == '"':
+				inString, isKey = true, true
+			case c == '}' && state == jsonFirstKey:
+				stack = stack[:len(stack)-1]
+				state = jsonAfter
+			default:
+				return "", false
+			}
+		case jsonColon:
+			if c != ':' {
+				return "", false
+			}
+			state = jsonValue
+		case jsonAfter:
+			if len(stack) == 0 {
+				return "", false
+			}
+			top := stack[len(stack)-1]
+			switch {
+			case c == ',' && top == '{':
+				state = jsonKey
+			case c == ',' && top == '[':
+				state = jsonValue
+			case c == '}' && top == '{', c == ']' && top == '[':
+				stack = stack[:len(stack)-1]
+				state = jsonAfter
+			default:
+				return "", false
+			}
+		}
+	}
+
+	if !seen {
+		return "{}", true
+	}
+
+	var sb strings.Builder
+	switch {
+	case inString:
+		if escape == 1 {
+			sb.WriteByte('\\')
+		} else if escape >= 2 {
+			sb.WriteString(strings.Repeat("0", 6-escape))
+		}
+		sb.WriteByte('"')
+		if isKey {
+			state = jsonColon
+		} else {
+			state = jsonAfter
+		}
+	case literal != "":
+		sb.WriteString(literal)
+		state = jsonAfter
+	case number != "":
+		if !isDigit(number[len(number)-1]) {
+			sb.WriteByte('0')
+		}
+		state = jsonAfter
+	}
+
+	switch state {
+	case jsonValue:
+		sb.WriteString("null")
+	case jsonKey:
+		sb.WriteString(truncatedKeyMember)
+	case jsonColon:
+		sb.WriteString(":null")
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == '{' {
+			sb.WriteByte('}')
+		} else {
+			sb.WriteByte(']')
+		}
+	}
+	return sb.String(), true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
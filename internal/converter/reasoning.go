@@ -0,0 +1,64 @@
+package converter
+
+// Thinking budgets used when a reasoning effort is converted to a token budget
+const (
+	thinkingBudgetLow    = 2048
+	thinkingBudgetMedium = 8192
+	thinkingBudgetHigh   = 24576
+
+	// claudeMinThinkingBudget is the smallest budget_tokens Claude accepts
+	claudeMinThinkingBudget = 1024
+)
+
+// effortToBudget converts an OpenAI reasoning_effort to a thinking budget, 0 for unknown efforts
+func effortToBudget(effort string) int {
+	switch effort {
+	case "minimal", "low":
+		return thinkingBudgetLow
+	case "medium":
+		return thinkingBudgetMedium
+	case "high":
+		return thinkingBudgetHigh
+	}
+	return 0
+}
+
+// budgetToEffort converts a thinking budget to the closest OpenAI reasoning_effort
+func budgetToEffort(budget int) string {
+	switch {
+	case budget <= 0:
+		return "medium"
+	case budget < (thinkingBudgetLow+thinkingBudgetMedium)/2:
+		return "low"
+	case budget < (thinkingBudgetMedium+thinkingBudgetHigh)/2:
+		return "medium"
+	}
+	return "high"
+}
+
+// claudeThinkingBudget reports whether Claude's thinking config is enabled and its budget, 0 when not set
+func claudeThinkingBudget(thinking map[string]interface{}) (bool, int) {
+	if thinking == nil {
+		return false, 0
+	}
+	if t, _ := thinking["type"].(string); t != "enabled" {
+		return false, 0
+	}
+	budget, _ := thinking["budget_tokens"].(float64)
+	return true, int(budget)
+}
+
+// claudeThinking builds Claude's thinking config. Claude needs a budget of at least
+// 1024 tokens below max_tokens, nil is returned when max_tokens leaves no room for it.
+func claudeThinking(budget, maxTokens int) map[string]interface{} {
+	if budget <= 0 {
+		budget = thinkingBudgetMedium
+	}
+	if maxTokens > 0 && budget >= maxTokens {
+		budget = maxTokens - 1
+	}
+	if budget < claudeMinThinkingBudget {
+		return nil
+	}
+	return map[string]interface{}{"type": "enabled", "budget_tokens": budget}
+}
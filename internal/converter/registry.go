@@ -16,6 +16,12 @@ type TransformState struct {
 	Buffer           string // SSE line buffer
 	Usage            *Usage
 	StopReason       string
+	ToolCallLimitHit bool             // a tool call beyond the first was filtered (parallel_tool_calls: false)
+	ContentLength    int              // characters of assistant content sent so far, for citation offsets
+	BlockStart       int              // ContentLength when the current content block started
+	Citations        []ClaudeCitation // citations of the current text block
+	StreamDone       bool             // the stream's final event was seen, for TrackToolArgs
+	streamTail       string           // the last bytes of the stream, for CloseToolArgs
 }
 
 // ToolCallState tracks tool call conversion state
@@ -0,0 +1,74 @@
+package converter
+
+import "strings"
+
+// jsonMimeType is the Gemini response MIME type for JSON output
+const jsonMimeType = "application/json"
+
+// defaultSchemaName names JSON schemas that come from formats without schema names
+const defaultSchemaName = "response"
+
+// responseFormat is the format-independent form of a structured output setting
+type responseFormat struct {
+	Schema interface{} // nil for free-form JSON (json_object)
+}
+
+// openaiResponseFormat parses OpenAI's response_format, nil for plain text
+func openaiResponseFormat(rf *OpenAIResponseFormat) *responseFormat {
+	if rf == nil {
+		return nil
+	}
+	switch rf.Type {
+	case "json_object":
+		return &responseFormat{}
+	case "json_schema":
+		out := &responseFormat{}
+		if rf.JSONSchema != nil {
+			out.Schema = rf.JSONSchema.Schema
+		}
+		return out
+	}
+	return nil
+}
+
+// toOpenAI renders the format as OpenAI's response_format
+func (f *responseFormat) toOpenAI() *OpenAIResponseFormat {
+	if f.Schema == nil {
+		return &OpenAIResponseFormat{Type: "json_object"}
+	}
+	return &OpenAIResponseFormat{
+		Type:       "json_schema",
+		JSONSchema: &OpenAIJSONSchema{Name: defaultSchemaName, Schema: f.Schema},
+	}
+}
+
+// geminiResponseFormat parses Gemini's response MIME type and schema, nil for plain text
+func geminiResponseFormat(cfg *GeminiGenerationConfig) *responseFormat {
+	if cfg == nil || !strings.EqualFold(cfg.ResponseMimeType, jsonMimeType) {
+		return nil
+	}
+	return &responseFormat{Schema: cfg.ResponseJSONSchema}
+}
+
+// applyToGemini sets the response MIME type and schema on cfg
+func (f *responseFormat) applyToGemini(cfg *GeminiGenerationConfig) {
+	cfg.ResponseMimeType = jsonMimeType
+	cfg.ResponseJSONSchema = f.Schema
+}
+
+// claudeResponseFormat parses Claude's output_format, nil when unset
+func claudeResponseFormat(of *ClaudeOutputFormat) *responseFormat {
+	if of == nil || of.Type != "json_schema" {
+		return nil
+	}
+	return &responseFormat{Schema: of.Schema}
+}
+
+// toClaude renders the format as Claude's output_format. Claude only supports
+// schema-constrained output, free-form JSON has no equivalent and returns nil.
+func (f *responseFormat) toClaude() *ClaudeOutputFormat {
+	if f.Schema == nil {
+		return nil
+	}
+	return &ClaudeOutputFormat{Type: "json_schema", Schema: f.Schema}
+}
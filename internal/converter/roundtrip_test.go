@@ -0,0 +1,708 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// Round-trip fidelity harness: every fixture is converted from its own format to
+// each other format and back, then the semantic fields of the original and the
+// round-tripped body are compared. Differences must be listed in knownLosses.
+
+// fidelity maps a semantic field to its canonical value
+type fidelity map[string]string
+
+// roundTripFormat describes how to read the semantics of one API format
+type roundTripFormat struct {
+	model    string // target model used when converting into this format
+	request  func(body []byte) (fidelity, error)
+	response func(body []byte) (fidelity, error)
+}
+
+var roundTripFormats = map[domain.ClientType]roundTripFormat{
+	domain.ClientTypeClaude: {model: "claude-sonnet-4-5", request: claudeRequestFidelity, response: claudeResponseFidelity},
+	domain.ClientTypeOpenAI: {model: "gpt-4o", request: openaiRequestFidelity, response: openaiResponseFidelity},
+	domain.ClientTypeGemini: {model: "gemini-2.5-flash-thinking", request: geminiRequestFidelity, response: geminiResponseFidelity},
+}
+
+// knownLosses lists the fields that don't survive a client -> target -> client
+// round trip by design, with the reason
+var knownLosses = map[[2]domain.ClientType]map[string]string{
+	{domain.ClientTypeClaude, domain.ClientTypeOpenAI}: {
+		"top_k":              "OpenAI has no top_k",
+		"thinking_signature": "OpenAI has no thinking signatures",
+	},
+	{domain.ClientTypeClaude, domain.ClientTypeGemini}: {
+		"system":     "the Gemini request gets the Antigravity identity patch",
+		"max_tokens": "the Gemini request uses a fixed maxOutputTokens",
+		"stop":       "the Gemini request uses the default stop sequences",
+		"user":       "Gemini has no end-user field",
+	},
+	{domain.ClientTypeOpenAI, domain.ClientTypeClaude}: {
+		"response_format": "Claude has no free-form JSON mode, only schemas",
+	},
+	{domain.ClientTypeOpenAI, domain.ClientTypeGemini}: {
+		"user": "Gemini has no end-user field",
+	},
+	{domain.ClientTypeGemini, domain.ClientTypeClaude}: {
+		"system":     "the Gemini request gets the Antigravity identity patch",
+		"max_tokens": "the Gemini request uses a fixed maxOutputTokens",
+		"stop":       "the Gemini request uses the default stop sequences",
+	},
+	{domain.ClientTypeGemini, domain.ClientTypeOpenAI}: {
+		"top_k":              "OpenAI has no top_k",
+		"thinking_signature": "OpenAI has no thinking signatures",
+	},
+}
+
+type roundTripFixture struct {
+	name   string
+	format domain.ClientType
+	body   string
+}
+
+const testImageData = "iVBORw0KGgo="
+
+var requestFixtures = []roundTripFixture{
+	{"claude text and system", domain.ClientTypeClaude, `{
+		"model": "claude-sonnet-4-5", "max_tokens": 4096, "temperature": 0.5, "top_p": 0.9, "top_k": 40,
+		"stop_sequences": ["END"], "metadata": {"user_id": "user-1"},
+		"system": [{"type": "text", "text": "You are terse."}],
+		"messages": [
+			{"role": "user", "content": "Hello"},
+			{"role": "assistant", "content": [{"type": "text", "text": "Hi."}]},
+			{"role": "user", "content": "Bye"}
+		]}`},
+	{"claude tools", domain.ClientTypeClaude, `{
+		"model": "claude-sonnet-4-5", "max_tokens": 4096,
+		"tools": [{"name": "get_weather", "description": "Weather of a city",
+			"input_schema": {"type": "object", "properties": {"city": {"type": "string", "description": "City name"}}, "required": ["city"]}}],
+		"tool_choice": {"type": "tool", "name": "get_weather"},
+		"messages": [
+			{"role": "user", "content": "Weather in Paris?"},
+			{"role": "assistant", "content": [{"type": "tool_use", "id": "toolu_01", "name": "get_weather", "input": {"city": "Paris", "format": "short"}}]},
+			{"role": "user", "content": [{"type": "tool_result", "tool_use_id": "toolu_01", "content": [{"type": "text", "text": "15C"}]}]}
+		]}`},
+	{"claude image", domain.ClientTypeClaude, `{
+		"model": "claude-sonnet-4-5", "max_tokens": 4096,
+		"messages": [{"role": "user", "content": [
+			{"type": "text", "text": "What is this?"},
+			{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "` + testImageData + `"}}
+		]}]}`},
+	{"claude thinking and output format", domain.ClientTypeClaude, `{
+		"model": "claude-sonnet-4-5", "max_tokens": 32000,
+		"thinking": {"type": "enabled", "budget_tokens": 8192},
+		"output_format": {"type": "json_schema", "schema": {"type": "object", "properties": {"answer": {"type": "string"}}}},
+		"messages": [{"role": "user", "content": "Think hard"}]}`},
+
+	{"openai text and system", domain.ClientTypeOpenAI, `{
+		"model": "gpt-4o", "max_tokens": 4096, "temperature": 0.5, "top_p": 0.9, "stop": ["END"], "user": "user-1",
+		"messages": [
+			{"role": "system", "content": [{"type": "text", "text": "You are terse."}]},
+			{"role": "user", "content": "Hello"},
+			{"role": "assistant", "content": "Hi."},
+			{"role": "user", "content": "Bye"}
+		]}`},
+	{"openai tools", domain.ClientTypeOpenAI, `{
+		"model": "gpt-4o", "max_tokens": 4096,
+		"tools": [{"type": "function", "function": {"name": "get_weather", "description": "Weather of a city",
+			"parameters": {"type": "object", "properties": {"city": {"type": "string", "description": "City name"}}, "required": ["city"]}}}],
+		"tool_choice": "required",
+		"messages": [
+			{"role": "user", "content": "Weather in Paris?"},
+			{"role": "assistant", "content": null, "tool_calls": [{"id": "call_abc", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}]},
+			{"role": "tool", "tool_call_id": "call_abc", "content": "15C"}
+		]}`},
+	{"openai image", domain.ClientTypeOpenAI, `{
+		"model": "gpt-4o", "max_tokens": 4096,
+		"messages": [{"role": "user", "content": [
+			{"type": "text", "text": "What is this?"},
+			{"type": "image_url", "image_url": {"url": "data:image/png;base64,` + testImageData + `"}}
+		]}]}`},
+	{"openai reasoning and json mode", domain.ClientTypeOpenAI, `{
+		"model": "gpt-4o", "max_completion_tokens": 32000, "reasoning_effort": "high",
+		"response_format": {"type": "json_object"},
+		"messages": [{"role": "user", "content": "Think hard"}]}`},
+
+	{"gemini text and system", domain.ClientTypeGemini, `{
+		"systemInstruction": {"parts": [{"text": "You are terse."}]},
+		"generationConfig": {"maxOutputTokens": 4096, "temperature": 0.5, "topP": 0.9, "topK": 40, "stopSequences": ["END"]},
+		"contents": [
+			{"role": "user", "parts": [{"text": "Hello"}]},
+			{"role": "model", "parts": [{"text": "Hi."}]},
+			{"role": "user", "parts": [{"text": "Bye"}]}
+		]}`},
+	{"gemini tools", domain.ClientTypeGemini, `{
+		"generationConfig": {"maxOutputTokens": 4096},
+		"tools": [{"functionDeclarations": [{"name": "get_weather", "description": "Weather of a city",
+			"parameters": {"type": "object", "properties": {"city": {"type": "string", "description": "City name"}}, "required": ["city"]}}]}],
+		"toolConfig": {"functionCallingConfig": {"mode": "ANY"}},
+		"contents": [
+			{"role": "user", "parts": [{"text": "Weather in Paris?"}]},
+			{"role": "model", "parts": [{"functionCall": {"name": "get_weather", "args": {"city": "Paris"}}}]},
+			{"role": "user", "parts": [{"functionResponse": {"name": "get_weather", "response": {"temperature": 15, "unit": "C"}}}]}
+		]}`},
+	{"gemini image", domain.ClientTypeGemini, `{
+		"generationConfig": {"maxOutputTokens": 4096},
+		"contents": [{"role": "user", "parts": [{"text": "What is this?"}, {"inlineData": {"mimeType": "image/png", "data": "` + testImageData + `"}}]}]}`},
+	{"gemini thinking and schema", domain.ClientTypeGemini, `{
+		"generationConfig": {"maxOutputTokens": 32000, "thinkingConfig": {"includeThoughts": true, "thinkingBudget": 2048},
+			"responseMimeType": "application/json", "responseJsonSchema": {"type": "object", "properties": {"answer": {"type": "string"}}}},
+		"contents": [{"role": "user", "parts": [{"text": "Think hard"}]}]}`},
+}
+
+var responseFixtures = []roundTripFixture{
+	{"claude text with thinking and cache", domain.ClientTypeClaude, `{
+		"id": "msg_01", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5",
+		"content": [{"type": "thinking", "thinking": "Let me see.", "signature": "sig-1"}, {"type": "text", "text": "Done."}],
+		"stop_reason": "end_turn",
+		"usage": {"input_tokens": 100, "output_tokens": 40, "cache_read_input_tokens": 50, "cache_creation_input_tokens": 10}}`},
+	{"claude tool use", domain.ClientTypeClaude, `{
+		"id": "msg_02", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5",
+		"content": [{"type": "text", "text": "Checking."}, {"type": "tool_use", "id": "toolu_01", "name": "get_weather", "input": {"city": "Paris"}}],
+		"stop_reason": "tool_use", "usage": {"input_tokens": 20, "output_tokens": 10}}`},
+	{"claude refusal", domain.ClientTypeClaude, `{
+		"id": "msg_03", "type": "message", "role": "assistant", "model": "claude-sonnet-4-5",
+		"content": [], "stop_reason": "refusal", "usage": {"input_tokens": 20, "output_tokens": 0}}`},
+
+	{"openai text with reasoning and cache", domain.ClientTypeOpenAI, `{
+		"id": "chatcmpl-1", "object": "chat.completion", "model": "gpt-4o",
+		"choices": [{"index": 0, "message": {"role": "assistant", "content": "Done.", "reasoning_content": "Let me see."}, "finish_reason": "stop"}],
+		"usage": {"prompt_tokens": 150, "completion_tokens": 40, "total_tokens": 190,
+			"prompt_tokens_details": {"cached_tokens": 50}, "completion_tokens_details": {"reasoning_tokens": 10}}}`},
+	{"openai tool calls", domain.ClientTypeOpenAI, `{
+		"id": "chatcmpl-2", "object": "chat.completion", "model": "gpt-4o",
+		"choices": [{"index": 0, "message": {"role": "assistant", "content": null,
+			"tool_calls": [{"id": "call_abc", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}]},
+			"finish_reason": "tool_calls"}],
+		"usage": {"prompt_tokens": 20, "completion_tokens": 10, "total_tokens": 30}}`},
+	{"openai length", domain.ClientTypeOpenAI, `{
+		"id": "chatcmpl-3", "object": "chat.completion", "model": "gpt-4o",
+		"choices": [{"index": 0, "message": {"role": "assistant", "content": "Cut"}, "finish_reason": "length"}],
+		"usage": {"prompt_tokens": 20, "completion_tokens": 10, "total_tokens": 30}}`},
+
+	{"gemini text with thoughts and cache", domain.ClientTypeGemini, `{
+		"candidates": [{"index": 0, "finishReason": "STOP", "content": {"role": "model", "parts": [
+			{"text": "Let me see.", "thought": true, "thoughtSignature": "sig-1"}, {"text": "Done."}]}}],
+		"usageMetadata": {"promptTokenCount": 150, "candidatesTokenCount": 30, "thoughtsTokenCount": 10, "totalTokenCount": 190, "cachedContentTokenCount": 50}}`},
+	{"gemini function call", domain.ClientTypeGemini, `{
+		"candidates": [{"index": 0, "finishReason": "STOP", "content": {"role": "model", "parts": [
+			{"functionCall": {"name": "get_weather", "args": {"city": "Paris"}}}]}}],
+		"usageMetadata": {"promptTokenCount": 20, "candidatesTokenCount": 10, "totalTokenCount": 30}}`},
+	{"gemini safety", domain.ClientTypeGemini, `{
+		"candidates": [{"index": 0, "finishReason": "SAFETY", "content": {"role": "model", "parts": []}}],
+		"usageMetadata": {"promptTokenCount": 20, "candidatesTokenCount": 0, "totalTokenCount": 20}}`},
+}
+
+func TestRequestRoundTripFidelity(t *testing.T) {
+	registry := GetGlobalRegistry()
+	for _, fx := range requestFixtures {
+		for target, targetFormat := range roundTripFormats {
+			if target == fx.format {
+				continue
+			}
+			t.Run(fmt.Sprintf("%s via %s", fx.name, target), func(t *testing.T) {
+				format := roundTripFormats[fx.format]
+				converted, err := registry.TransformRequest(fx.format, target, []byte(fx.body), targetFormat.model, false)
+				if err != nil {
+					t.Fatalf("%s -> %s: %v", fx.format, target, err)
+				}
+				back, err := registry.TransformRequest(target, fx.format, converted, format.model, false)
+				if err != nil {
+					t.Fatalf("%s -> %s: %v", target, fx.format, err)
+				}
+				assertFidelity(t, fx.format, target, format.request, []byte(fx.body), back, converted)
+			})
+		}
+	}
+}
+
+func TestResponseRoundTripFidelity(t *testing.T) {
+	registry := GetGlobalRegistry()
+	for _, fx := range responseFixtures {
+		for target := range roundTripFormats {
+			if target == fx.format {
+				continue
+			}
+			t.Run(fmt.Sprintf("%s via %s", fx.name, target), func(t *testing.T) {
+				converted, err := registry.TransformResponse(fx.format, target, []byte(fx.body))
+				if err != nil {
+					t.Fatalf("%s -> %s: %v", fx.format, target, err)
+				}
+				back, err := registry.TransformResponse(target, fx.format, converted)
+				if err != nil {
+					t.Fatalf("%s -> %s: %v", target, fx.format, err)
+				}
+				assertFidelity(t, fx.format, target, roundTripFormats[fx.format].response, []byte(fx.body), back, converted)
+			})
+		}
+	}
+}
+
+// assertFidelity compares the semantics of the original and the round-tripped body
+func assertFidelity(t *testing.T, client, target domain.ClientType, extract func([]byte) (fidelity, error), original, back, converted []byte) {
+	t.Helper()
+	want, err := extract(original)
+	if err != nil {
+		t.Fatalf("invalid fixture: %v", err)
+	}
+	got, err := extract(back)
+	if err != nil {
+		t.Fatalf("invalid round-tripped body: %v\n%s", err, back)
+	}
+
+	losses := knownLosses[[2]domain.ClientType{client, target}]
+	fields := make(map[string]bool)
+	for field := range want {
+		fields[field] = true
+	}
+	for field := range got {
+		fields[field] = true
+	}
+	for field := range fields {
+		if _, lost := losses[field]; lost || want[field] == got[field] {
+			continue
+		}
+		t.Errorf("%s changed:\n  was  %q\n  now  %q\n  via  %s", field, want[field], got[field], converted)
+	}
+}
+
+// transcript builds the canonical message history. Adjacent texts of the same
+// role are merged, since formats split and join text parts differently.
+type transcript struct {
+	lines    []string
+	lastRole string
+	lastText *strings.Builder
+}
+
+func (tr *transcript) text(role, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	if tr.lastText != nil && tr.lastRole == role {
+		tr.lastText.WriteString(text)
+		return
+	}
+	tr.flush()
+	tr.lastRole = role
+	tr.lastText = &strings.Builder{}
+	tr.lastText.WriteString(text)
+}
+
+func (tr *transcript) add(role, line string) {
+	tr.flush()
+	tr.lastRole = role
+	tr.lines = append(tr.lines, role+" "+line)
+}
+
+func (tr *transcript) flush() {
+	if tr.lastText != nil {
+		tr.lines = append(tr.lines, tr.lastRole+" text: "+tr.lastText.String())
+		tr.lastText = nil
+	}
+}
+
+func (tr *transcript) String() string {
+	tr.flush()
+	return strings.Join(tr.lines, "\n")
+}
+
+// canonicalJSON renders v with sorted keys
+func canonicalJSON(v interface{}) string {
+	if s, ok := v.(string); ok {
+		var parsed interface{}
+		if json.Unmarshal([]byte(s), &parsed) == nil {
+			v = parsed
+		}
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func canonicalNumber[T int | float64](v *T) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(*v)
+}
+
+func canonicalToolChoice(tc *toolChoice, hasTools bool) string {
+	if !hasTools {
+		return ""
+	}
+	if tc == nil {
+		return toolChoiceAuto
+	}
+	if tc.Mode == toolChoiceTool {
+		return tc.Mode + ":" + tc.Name
+	}
+	return tc.Mode
+}
+
+func canonicalResponseFormat(rf *responseFormat) string {
+	if rf == nil {
+		return ""
+	}
+	if rf.Schema == nil {
+		return "json"
+	}
+	return "schema " + canonicalJSON(rf.Schema)
+}
+
+func canonicalUsage(input, output, cached int) string {
+	return fmt.Sprintf("input=%d output=%d cached=%d", input, output, cached)
+}
+
+func claudeRequestFidelity(body []byte) (fidelity, error) {
+	var req ClaudeRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	f := fidelity{
+		"max_tokens":      fmt.Sprint(req.MaxTokens),
+		"temperature":     canonicalNumber(req.Temperature),
+		"top_p":           canonicalNumber(req.TopP),
+		"top_k":           canonicalNumber(req.TopK),
+		"stop":            strings.Join(req.StopSequences, "|"),
+		"tool_choice":     canonicalToolChoice(claudeToolChoice(req.ToolChoice), len(req.Tools) > 0),
+		"response_format": canonicalResponseFormat(claudeResponseFormat(req.OutputFormat)),
+	}
+	if req.Metadata != nil {
+		f["user"] = req.Metadata.UserID
+	}
+	if enabled, budget := claudeThinkingBudget(req.Thinking); enabled {
+		f["thinking"] = budgetToEffort(budget)
+	}
+
+	switch system := req.System.(type) {
+	case string:
+		f["system"] = strings.TrimSpace(system)
+	case []interface{}:
+		f["system"] = strings.TrimSpace(claudeToolResultText(system))
+	}
+
+	var tools []string
+	for _, tool := range req.Tools {
+		tools = append(tools, tool.Name+" "+tool.Description+" "+canonicalJSON(tool.InputSchema))
+	}
+	f["tools"] = strings.Join(tools, "\n")
+
+	names := make(map[string]string)
+	var tr transcript
+	for _, msg := range req.Messages {
+		switch content := msg.Content.(type) {
+		case string:
+			tr.text(msg.Role, content)
+		case []interface{}:
+			for _, block := range content {
+				m, _ := block.(map[string]interface{})
+				switch m["type"] {
+				case "text":
+					text, _ := m["text"].(string)
+					tr.text(msg.Role, text)
+				case "image":
+					source, _ := m["source"].(map[string]interface{})
+					tr.add(msg.Role, fmt.Sprintf("image: %v %v", source["media_type"], source["data"]))
+				case "tool_use":
+					id, _ := m["id"].(string)
+					name, _ := m["name"].(string)
+					names[id] = name
+					tr.add(msg.Role, "tool_call: "+name+" "+canonicalJSON(m["input"]))
+				case "tool_result":
+					id, _ := m["tool_use_id"].(string)
+					tr.add(msg.Role, fmt.Sprintf("tool_result: %s %s", toolName(names, id), canonicalJSON(claudeToolResultText(m["content"]))))
+				}
+			}
+		}
+	}
+	f["messages"] = tr.String()
+	return f, nil
+}
+
+func openaiRequestFidelity(body []byte) (fidelity, error) {
+	var req OpenAIRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	maxTokens := req.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = req.MaxCompletionTokens
+	}
+	f := fidelity{
+		"max_tokens":      fmt.Sprint(maxTokens),
+		"temperature":     canonicalNumber(req.Temperature),
+		"top_p":           canonicalNumber(req.TopP),
+		"tool_choice":     canonicalToolChoice(openaiToolChoice(req.ToolChoice), len(req.Tools) > 0),
+		"response_format": canonicalResponseFormat(openaiResponseFormat(req.ResponseFormat)),
+		"user":            req.User,
+	}
+	if effortToBudget(req.ReasoningEffort) > 0 {
+		f["thinking"] = req.ReasoningEffort
+	}
+	switch stop := req.Stop.(type) {
+	case string:
+		f["stop"] = stop
+	case []interface{}:
+		var stops []string
+		for _, s := range stop {
+			stops = append(stops, fmt.Sprint(s))
+		}
+		f["stop"] = strings.Join(stops, "|")
+	}
+
+	var tools []string
+	for _, tool := range req.Tools {
+		tools = append(tools, tool.Function.Name+" "+tool.Function.Description+" "+canonicalJSON(tool.Function.Parameters))
+	}
+	f["tools"] = strings.Join(tools, "\n")
+
+	names := make(map[string]string)
+	var tr transcript
+	for _, msg := range req.Messages {
+		role := msg.Role
+		switch role {
+		case "system":
+			f["system"] += strings.TrimSpace(openaiContentText(msg.Content))
+			continue
+		case "tool":
+			tr.add("user", fmt.Sprintf("tool_result: %s %s", toolName(names, msg.ToolCallID), canonicalJSON(openaiContentText(msg.Content))))
+			continue
+		}
+		switch content := msg.Content.(type) {
+		case string:
+			tr.text(role, content)
+		case []interface{}:
+			for _, part := range content {
+				m, _ := part.(map[string]interface{})
+				switch m["type"] {
+				case "text":
+					text, _ := m["text"].(string)
+					tr.text(role, text)
+				case "image_url":
+					mediaType, data, _ := parseImageDataURL(openaiImageURL(m))
+					tr.add(role, fmt.Sprintf("image: %s %s", mediaType, data))
+				}
+			}
+		}
+		for _, tc := range msg.ToolCalls {
+			names[tc.ID] = tc.Function.Name
+			tr.add(role, "tool_call: "+tc.Function.Name+" "+canonicalJSON(tc.Function.Arguments))
+		}
+	}
+	f["messages"] = tr.String()
+	return f, nil
+}
+
+func geminiRequestFidelity(body []byte) (fidelity, error) {
+	var req GeminiRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	f := fidelity{}
+	if cfg := req.GenerationConfig; cfg != nil {
+		f["max_tokens"] = fmt.Sprint(cfg.MaxOutputTokens)
+		f["temperature"] = canonicalNumber(cfg.Temperature)
+		f["top_p"] = canonicalNumber(cfg.TopP)
+		f["top_k"] = canonicalNumber(cfg.TopK)
+		f["stop"] = strings.Join(cfg.StopSequences, "|")
+		f["response_format"] = canonicalResponseFormat(geminiResponseFormat(cfg))
+		if cfg.ThinkingConfig != nil && cfg.ThinkingConfig.IncludeThoughts {
+			f["thinking"] = budgetToEffort(cfg.ThinkingConfig.ThinkingBudget)
+		}
+	}
+	if req.SystemInstruction != nil {
+		var system strings.Builder
+		for _, part := range req.SystemInstruction.Parts {
+			system.WriteString(part.Text)
+		}
+		f["system"] = strings.TrimSpace(system.String())
+	}
+
+	var tools []string
+	for _, tool := range req.Tools {
+		for _, decl := range tool.FunctionDeclarations {
+			tools = append(tools, decl.Name+" "+decl.Description+" "+canonicalJSON(decl.Parameters))
+		}
+	}
+	f["tools"] = strings.Join(tools, "\n")
+	f["tool_choice"] = canonicalToolChoice(geminiToolChoice(req.ToolConfig), len(tools) > 0)
+
+	names := make(map[string]string)
+	var tr transcript
+	for _, content := range req.Contents {
+		role := "user"
+		if content.Role == "model" {
+			role = "assistant"
+		}
+		for _, part := range content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				if part.FunctionCall.ID != "" {
+					names[part.FunctionCall.ID] = part.FunctionCall.Name
+				}
+				tr.add(role, "tool_call: "+part.FunctionCall.Name+" "+canonicalJSON(part.FunctionCall.Args))
+			case part.FunctionResponse != nil:
+				name := part.FunctionResponse.Name
+				if part.FunctionResponse.ID != "" {
+					name = toolName(names, part.FunctionResponse.ID)
+				}
+				tr.add(role, fmt.Sprintf("tool_result: %s %s", name, canonicalJSON(geminiToolResponseText(part.FunctionResponse.Response))))
+			case part.InlineData != nil:
+				tr.add(role, fmt.Sprintf("image: %s %s", part.InlineData.MimeType, part.InlineData.Data))
+			default:
+				tr.text(role, part.Text)
+			}
+		}
+	}
+	f["messages"] = tr.String()
+	return f, nil
+}
+
+// toolName resolves a tool call ID, unresolved IDs show up as such in the transcript
+func toolName(names map[string]string, id string) string {
+	if name, ok := names[id]; ok {
+		return name
+	}
+	return "unknown id " + id
+}
+
+func claudeResponseFidelity(body []byte) (fidelity, error) {
+	var resp ClaudeResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	f := fidelity{
+		"stop":  resp.StopReason,
+		"usage": canonicalUsage(resp.Usage.InputTokens+resp.Usage.CacheReadInputTokens+resp.Usage.CacheCreationInputTokens, resp.Usage.OutputTokens, resp.Usage.CacheReadInputTokens),
+	}
+	var calls []string
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			f["text"] += block.Text
+		case "thinking":
+			f["thinking"] += block.Thinking
+			f["thinking_signature"] += block.Signature
+		case "tool_use":
+			calls = append(calls, block.Name+" "+canonicalJSON(block.Input))
+		}
+	}
+	f["tool_calls"] = strings.Join(calls, "\n")
+	return f, nil
+}
+
+func openaiResponseFidelity(body []byte) (fidelity, error) {
+	var resp OpenAIResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	cached := 0
+	if resp.Usage.PromptTokensDetails != nil {
+		cached = resp.Usage.PromptTokensDetails.CachedTokens
+	}
+	f := fidelity{"usage": canonicalUsage(resp.Usage.PromptTokens, resp.Usage.CompletionTokens, cached)}
+	if len(resp.Choices) == 0 {
+		return f, nil
+	}
+	choice := resp.Choices[0]
+	f["stop"] = StopReasonFromOpenAI(choice.FinishReason, false).Claude()
+	if msg := choice.Message; msg != nil {
+		f["text"] = openaiContentText(msg.Content)
+		f["thinking"] = msg.ReasoningContent
+		var calls []string
+		for _, tc := range msg.ToolCalls {
+			calls = append(calls, tc.Function.Name+" "+canonicalJSON(tc.Function.Arguments))
+		}
+		f["tool_calls"] = strings.Join(calls, "\n")
+	}
+	return f, nil
+}
+
+func geminiResponseFidelity(body []byte) (fidelity, error) {
+	var resp GeminiResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	f := fidelity{}
+	if u := resp.UsageMetadata; u != nil {
+		f["usage"] = canonicalUsage(u.PromptTokenCount, u.CandidatesTokenCount+u.ThoughtsTokenCount, u.CachedContentTokenCount)
+	}
+	if len(resp.Candidates) == 0 {
+		return f, nil
+	}
+	candidate := resp.Candidates[0]
+	var calls []string
+	for _, part := range candidate.Content.Parts {
+		switch {
+		case part.FunctionCall != nil:
+			calls = append(calls, part.FunctionCall.Name+" "+canonicalJSON(part.FunctionCall.Args))
+		case part.Thought:
+			f["thinking"] += part.Text
+			f["thinking_signature"] += part.ThoughtSignature
+		default:
+			f["text"] += part.Text
+		}
+	}
+	f["tool_calls"] = strings.Join(calls, "\n")
+	f["stop"] = StopReasonFromGemini(candidate.FinishReason, len(calls) > 0).Claude()
+	return f, nil
+}
+
+// TestKnownLossesAreReal keeps knownLosses honest: every listed field must
+// actually differ for at least one fixture, so fixed losses get removed.
+func TestKnownLossesAreReal(t *testing.T) {
+	registry := GetGlobalRegistry()
+	seen := make(map[[2]domain.ClientType]map[string]bool)
+	check := func(client, target domain.ClientType, extract func([]byte) (fidelity, error), original, back []byte) {
+		want, _ := extract(original)
+		got, _ := extract(back)
+		key := [2]domain.ClientType{client, target}
+		for field := range knownLosses[key] {
+			if want[field] != got[field] {
+				if seen[key] == nil {
+					seen[key] = make(map[string]bool)
+				}
+				seen[key][field] = true
+			}
+		}
+	}
+	for _, fx := range requestFixtures {
+		for target, targetFormat := range roundTripFormats {
+			if target == fx.format {
+				continue
+			}
+			converted, _ := registry.TransformRequest(fx.format, target, []byte(fx.body), targetFormat.model, false)
+			back, _ := registry.TransformRequest(target, fx.format, converted, roundTripFormats[fx.format].model, false)
+			check(fx.format, target, roundTripFormats[fx.format].request, []byte(fx.body), back)
+		}
+	}
+	for _, fx := range responseFixtures {
+		for target := range roundTripFormats {
+			if target == fx.format {
+				continue
+			}
+			converted, _ := registry.TransformResponse(fx.format, target, []byte(fx.body))
+			back, _ := registry.TransformResponse(target, fx.format, converted)
+			check(fx.format, target, roundTripFormats[fx.format].response, []byte(fx.body), back)
+		}
+	}
+
+	var stale []string
+	for key, fields := range knownLosses {
+		for field := range fields {
+			if !seen[key][field] {
+				stale = append(stale, fmt.Sprintf("%s via %s: %s", key[0], key[1], field))
+			}
+		}
+	}
+	sort.Strings(stale)
+	for _, s := range stale {
+		t.Errorf("known loss no longer happens, remove it: %s", s)
+	}
+}
@@ -3,6 +3,8 @@ package converter
 import (
 	"encoding/json"
 	"strings"
+
+	"github.com/awsl-project/maxx/internal/domain"
 )
 
 // SSEEvent represents a parsed SSE event
@@ -21,11 +23,20 @@ func ParseSSE(text string) ([]SSEEvent, string) {
 	var remaining strings.Builder
 
 	for i, line := range lines {
+		last := i == len(lines)-1
 		// Check if this is the last line and might be incomplete
-		if i == len(lines)-1 && line != "" && !strings.HasSuffix(text, "\n") {
+		if last && line != "" && !strings.HasSuffix(text, "\n") {
+			// Keep the lines of the event it belongs to, they are parsed again with the rest
+			writePendingSSE(&remaining, currentEvent, currentData)
 			remaining.WriteString(line)
 			break
 		}
+		// The text ended between an event's name and its data, e.g. at a keep-alive
+		// comment in between: keep the name for the data still to come
+		if last && line == "" && currentEvent != "" && len(currentData) == 0 {
+			writePendingSSE(&remaining, currentEvent, nil)
+			break
+		}
 
 		line = strings.TrimSpace(line)
 
@@ -50,6 +61,7 @@ func ParseSSE(text string) ([]SSEEvent, string) {
 			continue
 		}
 
+		// Comment lines (": keep-alive") and unknown fields carry nothing
 		if strings.HasPrefix(line, "event:") {
 			currentEvent = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
 		} else if strings.HasPrefix(line, "data:") {
@@ -60,12 +72,22 @@ func ParseSSE(text string) ([]SSEEvent, string) {
 	return events, remaining.String()
 }
 
+// writePendingSSE writes back the lines of an event not terminated yet
+func writePendingSSE(sb *strings.Builder, event string, data []string) {
+	if event != "" {
+		sb.WriteString("event: " + event + "\n")
+	}
+	for _, d := range data {
+		sb.WriteString("data: " + d + "\n")
+	}
+}
+
 // IsSSE checks if text looks like SSE format
 func IsSSE(text string) bool {
 	lines := strings.Split(text, "\n")
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
-		if line == "" {
+		if line == "" || strings.HasPrefix(line, ":") {
 			continue
 		}
 		if strings.HasPrefix(line, "event:") || strings.HasPrefix(line, "data:") {
@@ -109,3 +131,21 @@ func FormatSSE(event string, data interface{}) []byte {
 func FormatDone() []byte {
 	return []byte("data: [DONE]\n\n")
 }
+
+// Heartbeat returns the idle keepalive a streaming client of the type accepts
+// between two events, nil for types without one:
+//   - Claude: the ping event the Anthropic API sends itself
+//   - OpenAI and Codex: an empty SSE comment, which their SSE parsers skip
+//   - Gemini: a blank line. Gemini clients such as gemini-cli reject any line that
+//     isn't data, while a blank line between events dispatches nothing
+func Heartbeat(clientType domain.ClientType) []byte {
+	switch clientType {
+	case domain.ClientTypeClaude:
+		return []byte("event: ping\ndata: {\"type\":\"ping\"}\n\n")
+	case domain.ClientTypeOpenAI, domain.ClientTypeCodex:
+		return []byte(":\n\n")
+	case domain.ClientTypeGemini:
+		return []byte("\n")
+	}
+	return nil
+}
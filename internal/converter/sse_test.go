@@ -0,0 +1,168 @@
+package converter
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// keepAliveFixtures are upstream streams in each format
+var keepAliveFixtures = map[domain.ClientType]string{
+	domain.ClientTypeClaude: `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-x","content":[],"stop_reason":null,"usage":{"input_tokens":5,"output_tokens":0}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: ping
+data: {"type":"ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":2}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`,
+	domain.ClientTypeOpenAI: `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-x","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-x","choices":[{"index":0,"delta":{"content":" world"},"finish_reason":null}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-x","choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}
+
+data: [DONE]
+
+`,
+	domain.ClientTypeGemini: `data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]},"index":0}],"modelVersion":"gemini-x"}
+
+data: {"candidates":[{"content":{"role":"model","parts":[{"text":" world"}]},"finishReason":"STOP","index":0}],"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":2,"totalTokenCount":7},"modelVersion":"gemini-x"}
+
+`,
+	domain.ClientTypeCodex: `event: response.created
+data: {"type":"response.created","response":{"id":"resp_1","object":"response","model":"gpt-x","status":"in_progress","output":[]}}
+
+event: response.output_item.added
+data: {"type":"response.output_item.added","output_index":0,"item":{"type":"message","id":"msg_1","status":"in_progress","role":"assistant","content":[]}}
+
+event: response.output_text.delta
+data: {"type":"response.output_text.delta","item_id":"msg_1","output_index":0,"content_index":0,"delta":"Hello"}
+
+event: response.output_text.delta
+data: {"type":"response.output_text.delta","item_id":"msg_1","output_index":0,"content_index":0,"delta":" world"}
+
+event: response.output_text.done
+data: {"type":"response.output_text.done","item_id":"msg_1","output_index":0,"content_index":0,"text":"Hello world"}
+
+event: response.output_item.done
+data: {"type":"response.output_item.done","output_index":0,"item":{"type":"message","id":"msg_1","status":"completed","role":"assistant","content":[{"type":"output_text","text":"Hello world"}]}}
+
+event: response.completed
+data: {"type":"response.completed","response":{"id":"resp_1","object":"response","model":"gpt-x","status":"completed","output":[{"type":"message","id":"msg_1","status":"completed","role":"assistant","content":[{"type":"output_text","text":"Hello world"}]}],"usage":{"input_tokens":5,"output_tokens":2,"total_tokens":7}}}
+
+`,
+}
+
+// withKeepAlives intersperses SSE comments the way upstreams send them: keep-alive
+// blocks between events, a comment line inside an event and one opening the stream
+func withKeepAlives(stream string) string {
+	events := strings.SplitAfter(stream, "\n\n")
+	var sb strings.Builder
+	sb.WriteString(": keep-alive\n\n")
+	for i, event := range events {
+		if i%2 == 1 {
+			if name, rest, ok := strings.Cut(event, "\n"); ok && strings.HasPrefix(name, "event:") {
+				event = name + "\n: ping\n" + rest
+			} else {
+				event = ":\n" + event
+			}
+		}
+		sb.WriteString(event)
+		if event != "" {
+			sb.WriteString(": keep-alive\n\n")
+		}
+	}
+	return sb.String()
+}
+
+// splitLines feeds a stream the way it arrives over the network, a line at a time
+func splitLines(stream string) []string {
+	return strings.SplitAfter(stream, "\n")
+}
+
+// volatile matches the IDs and timestamps converters generate per stream
+var volatile = regexp.MustCompile(`"(id|created|created_at|item_id|call_id|responseId)":("[^"]*"|\d+)`)
+
+func convertStream(t *testing.T, r *Registry, from, to domain.ClientType, chunks []string) string {
+	t.Helper()
+	state := NewTransformState()
+	var out strings.Builder
+	for _, chunk := range chunks {
+		converted, err := r.TransformStreamChunk(from, to, []byte(chunk), state)
+		if err != nil {
+			t.Fatalf("%s -> %s: %v", from, to, err)
+		}
+		out.Write(converted)
+	}
+	return volatile.ReplaceAllString(out.String(), `"$1":"-"`)
+}
+
+func TestStreamConvertersIgnoreKeepAlives(t *testing.T) {
+	r := NewRegistry()
+	for from, stream := range keepAliveFixtures {
+		for to := range keepAliveFixtures {
+			if from == to {
+				continue
+			}
+			want := convertStream(t, r, from, to, splitLines(stream))
+			got := convertStream(t, r, from, to, splitLines(withKeepAlives(stream)))
+			if got != want {
+				t.Errorf("%s -> %s: keep-alives changed the client stream\nwith:\n%s\nwithout:\n%s", from, to, got, want)
+				continue
+			}
+
+			// The client stream is well-formed, has the text and no stray events
+			events, rest := ParseSSE(got)
+			if rest != "" || len(events) == 0 {
+				t.Errorf("%s -> %s: malformed client stream %q", from, to, got)
+			}
+			if strings.Contains(got, "\n\n\n") || strings.Contains(got, "keep-alive") {
+				t.Errorf("%s -> %s: stray blank event or comment in %q", from, to, got)
+			}
+			// Codex streams name their text events differently from what the Codex converters read
+			if from != domain.ClientTypeCodex && to != domain.ClientTypeCodex && !HasContent(to, []byte(got)) {
+				t.Errorf("%s -> %s: client stream lost the text: %q", from, to, got)
+			}
+		}
+	}
+}
+
+func TestParseSSEComments(t *testing.T) {
+	events, rest := ParseSSE("event: a\n: ping\ndata: {\"n\":1}\n\n:\n\n: keep-alive\r\n\r\ndata: {\"n\":2}\n\n")
+	if rest != "" || len(events) != 2 || events[0].Event != "a" || string(events[1].Data) != `{"n":2}` {
+		t.Errorf("events = %+v, rest = %q", events, rest)
+	}
+
+	// Chunks ending between an event's name and its data keep the name
+	buffer := ""
+	for _, chunk := range []string{"event: b\n", ": ping\n", "data: {\"n\":3}\n\n"} {
+		events, buffer = ParseSSE(buffer + chunk)
+	}
+	if len(events) != 1 || events[0].Event != "b" || buffer != "" {
+		t.Errorf("events split across chunks = %+v, rest = %q", events, buffer)
+	}
+
+	if !IsSSE(": keep-alive\n\ndata: {}\n\n") {
+		t.Error("stream opening with a keep-alive is not recognized as SSE")
+	}
+}
@@ -0,0 +1,174 @@
+package converter
+
+// StopReason is the canonical reason a response ended. Every converter maps the
+// upstream reason to a StopReason and renders it in the client's format, so a
+// reason means the same thing whichever pair of formats is involved.
+// The values are Claude's stop_reason values, the richest of the formats.
+type StopReason string
+
+const (
+	StopReasonEndTurn      StopReason = "end_turn"
+	StopReasonMaxTokens    StopReason = "max_tokens"
+	StopReasonStopSequence StopReason = "stop_sequence"
+	StopReasonToolUse      StopReason = "tool_use"
+	StopReasonPauseTurn    StopReason = "pause_turn"
+	StopReasonRefusal      StopReason = "refusal"
+)
+
+// ParseClaudeStopReason parses a Claude stop_reason, ok is false for unknown values
+func ParseClaudeStopReason(stopReason string) (StopReason, bool) {
+	switch r := StopReason(stopReason); r {
+	case StopReasonEndTurn, StopReasonMaxTokens, StopReasonStopSequence,
+		StopReasonToolUse, StopReasonPauseTurn, StopReasonRefusal:
+		return r, true
+	}
+	return "", false
+}
+
+// StopReasonFromClaude maps a Claude stop_reason, unknown values end the turn
+func StopReasonFromClaude(stopReason string) StopReason {
+	if r, ok := ParseClaudeStopReason(stopReason); ok {
+		return r
+	}
+	return StopReasonEndTurn
+}
+
+// StopReasonFromOpenAI maps an OpenAI finish_reason. Some OpenAI-compatible
+// upstreams report "stop" for tool calls, hasToolCalls tells them apart.
+func StopReasonFromOpenAI(finishReason string, hasToolCalls bool) StopReason {
+	switch finishReason {
+	case "length":
+		return StopReasonMaxTokens
+	case "tool_calls", "function_call":
+		return StopReasonToolUse
+	case "content_filter":
+		return StopReasonRefusal
+	}
+	if hasToolCalls {
+		return StopReasonToolUse
+	}
+	return StopReasonEndTurn
+}
+
+// StopReasonFromGemini maps a Gemini finishReason. Gemini reports STOP for tool
+// calls too, hasToolCalls tells them apart.
+func StopReasonFromGemini(finishReason string, hasToolCalls bool) StopReason {
+	switch {
+	case finishReason == "MAX_TOKENS":
+		return StopReasonMaxTokens
+	case geminiSafetyFinish(finishReason):
+		return StopReasonRefusal
+	case hasToolCalls:
+		return StopReasonToolUse
+	}
+	return StopReasonEndTurn
+}
+
+// StopReasonFromCodex maps a Codex response status and incomplete_details reason
+func StopReasonFromCodex(status, incompleteReason string, hasToolCalls bool) StopReason {
+	switch {
+	case status == "incomplete" && incompleteReason == "content_filter":
+		return StopReasonRefusal
+	case status == "incomplete":
+		return StopReasonMaxTokens
+	case hasToolCalls:
+		return StopReasonToolUse
+	}
+	return StopReasonEndTurn
+}
+
+// geminiSafetyFinish reports whether a Gemini finish reason means the output was blocked.
+// RECITATION, a copyright check on recited training data, isn't a refusal.
+func geminiSafetyFinish(reason string) bool {
+	switch reason {
+	case "SAFETY", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return true
+	}
+	return false
+}
+
+// Claude returns the Claude stop_reason
+func (r StopReason) Claude() string {
+	if _, ok := ParseClaudeStopReason(string(r)); ok {
+		return string(r)
+	}
+	return string(StopReasonEndTurn)
+}
+
+// OpenAI returns the OpenAI finish_reason. OpenAI reports "stop" for stop
+// sequences as well as natural ends.
+func (r StopReason) OpenAI() string {
+	switch r {
+	case StopReasonMaxTokens:
+		return "length"
+	case StopReasonToolUse:
+		return "tool_calls"
+	case StopReasonRefusal:
+		return "content_filter"
+	}
+	return "stop"
+}
+
+// Gemini returns the Gemini finishReason. Gemini has no tool call reason, tool
+// calls end with STOP.
+func (r StopReason) Gemini() string {
+	switch r {
+	case StopReasonMaxTokens:
+		return "MAX_TOKENS"
+	case StopReasonRefusal:
+		return "SAFETY"
+	}
+	return "STOP"
+}
+
+// Codex returns the Codex response status and, for incomplete responses, the
+// incomplete_details reason
+func (r StopReason) Codex() (status, incompleteReason string) {
+	switch r {
+	case StopReasonMaxTokens:
+		return "incomplete", "max_output_tokens"
+	case StopReasonRefusal:
+		return "incomplete", "content_filter"
+	}
+	return "completed", ""
+}
+
+// codexResponseStopReason maps the stop reason of a Codex response
+func codexResponseStopReason(resp *CodexResponse, hasToolCalls bool) StopReason {
+	var incompleteReason string
+	if resp.IncompleteDetails != nil {
+		incompleteReason = resp.IncompleteDetails.Reason
+	}
+	return StopReasonFromCodex(resp.Status, incompleteReason, hasToolCalls)
+}
+
+// setCodexStopReason sets the status and incomplete_details of a Codex response
+func setCodexStopReason(resp *CodexResponse, r StopReason) {
+	status, incompleteReason := r.Codex()
+	resp.Status = status
+	resp.IncompleteDetails = nil
+	if incompleteReason != "" {
+		resp.IncompleteDetails = &CodexIncompleteDetails{Reason: incompleteReason}
+	}
+}
+
+// codexEventStopReason maps the stop reason of the response in a Codex stream event
+func codexEventStopReason(codexEvent map[string]interface{}) StopReason {
+	resp, _ := codexEvent["response"].(map[string]interface{})
+	status, _ := resp["status"].(string)
+	var incompleteReason string
+	if details, ok := resp["incomplete_details"].(map[string]interface{}); ok {
+		incompleteReason, _ = details["reason"].(string)
+	}
+	return StopReasonFromCodex(status, incompleteReason, false)
+}
+
+// codexStreamResponse builds the response object of a final Codex stream event
+func codexStreamResponse(id string, r StopReason) map[string]interface{} {
+	status, incompleteReason := r.Codex()
+	resp := map[string]interface{}{"id": id, "status": status}
+	if incompleteReason != "" {
+		resp["incomplete_details"] = map[string]interface{}{"reason": incompleteReason}
+	}
+	return resp
+}
@@ -0,0 +1,213 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// stopReasonCase is an upstream response ending with a given reason
+type stopReasonCase struct {
+	reason   string // upstream reason, for Codex "status" or "status/incomplete reason"
+	hasTools bool   // the response contains a tool call
+	want     StopReason
+}
+
+// stopReasonUpstreams lists every reason each upstream format can report
+var stopReasonUpstreams = map[domain.ClientType][]stopReasonCase{
+	domain.ClientTypeClaude: {
+		{"end_turn", false, StopReasonEndTurn},
+		{"max_tokens", false, StopReasonMaxTokens},
+		{"stop_sequence", false, StopReasonStopSequence},
+		{"tool_use", true, StopReasonToolUse},
+		{"pause_turn", false, StopReasonPauseTurn},
+		{"refusal", false, StopReasonRefusal},
+		{"max_tokens", true, StopReasonMaxTokens},
+		{"unknown", false, StopReasonEndTurn},
+	},
+	domain.ClientTypeOpenAI: {
+		{"stop", false, StopReasonEndTurn},
+		{"length", false, StopReasonMaxTokens},
+		{"tool_calls", true, StopReasonToolUse},
+		{"function_call", true, StopReasonToolUse},
+		{"content_filter", false, StopReasonRefusal},
+		{"stop", true, StopReasonToolUse},
+		{"length", true, StopReasonMaxTokens},
+	},
+	domain.ClientTypeGemini: {
+		{"STOP", false, StopReasonEndTurn},
+		{"MAX_TOKENS", false, StopReasonMaxTokens},
+		{"SAFETY", false, StopReasonRefusal},
+		{"RECITATION", false, StopReasonEndTurn},
+		{"BLOCKLIST", false, StopReasonRefusal},
+		{"PROHIBITED_CONTENT", false, StopReasonRefusal},
+		{"SPII", false, StopReasonRefusal},
+		{"IMAGE_SAFETY", false, StopReasonRefusal},
+		{"OTHER", false, StopReasonEndTurn},
+		{"STOP", true, StopReasonToolUse},
+		{"MAX_TOKENS", true, StopReasonMaxTokens},
+	},
+	domain.ClientTypeCodex: {
+		{"completed", false, StopReasonEndTurn},
+		{"completed", true, StopReasonToolUse},
+		{"incomplete/max_output_tokens", false, StopReasonMaxTokens},
+		{"incomplete/content_filter", false, StopReasonRefusal},
+		{"incomplete", false, StopReasonMaxTokens},
+	},
+}
+
+// stopReasonResponse builds a non-streaming upstream response ending with the case's reason
+func stopReasonResponse(format domain.ClientType, c stopReasonCase) string {
+	switch format {
+	case domain.ClientTypeClaude:
+		content := `{"type":"text","text":"hi"}`
+		if c.hasTools {
+			content += `,{"type":"tool_use","id":"toolu_1","name":"lookup","input":{}}`
+		}
+		return fmt.Sprintf(`{"id":"msg_1","type":"message","role":"assistant","model":"m","content":[%s],"stop_reason":%q,"usage":{"input_tokens":1,"output_tokens":1}}`, content, c.reason)
+	case domain.ClientTypeOpenAI:
+		toolCalls := ""
+		if c.hasTools {
+			toolCalls = `,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{}"}}]`
+		}
+		return fmt.Sprintf(`{"id":"chatcmpl-1","object":"chat.completion","model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"hi"%s},"finish_reason":%q}]}`, toolCalls, c.reason)
+	case domain.ClientTypeGemini:
+		parts := `{"text":"hi"}`
+		if c.hasTools {
+			parts += `,{"functionCall":{"name":"lookup","args":{}}}`
+		}
+		return fmt.Sprintf(`{"candidates":[{"content":{"role":"model","parts":[%s]},"finishReason":%q}]}`, parts, c.reason)
+	case domain.ClientTypeCodex:
+		output := `{"type":"message","role":"assistant","content":"hi"}`
+		if c.hasTools {
+			output += `,{"type":"function_call","id":"fc_1","call_id":"call_1","name":"lookup","arguments":"{}"}`
+		}
+		status, incomplete, _ := strings.Cut(c.reason, "/")
+		details := ""
+		if incomplete != "" {
+			details = fmt.Sprintf(`,"incomplete_details":{"reason":%q}`, incomplete)
+		}
+		return fmt.Sprintf(`{"id":"resp_1","object":"response","model":"m","status":%q%s,"output":[%s]}`, status, details, output)
+	}
+	return ""
+}
+
+// clientStopReason extracts the stop reason from a converted client response, in
+// the same shape as renderStopReason
+func clientStopReason(format domain.ClientType, body []byte) (string, error) {
+	var resp struct {
+		StopReason string `json:"stop_reason"`
+		Choices    []struct {
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Candidates []struct {
+			FinishReason string `json:"finishReason"`
+		} `json:"candidates"`
+		Status            string                  `json:"status"`
+		IncompleteDetails *CodexIncompleteDetails `json:"incomplete_details"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", err
+	}
+	switch format {
+	case domain.ClientTypeClaude:
+		return resp.StopReason, nil
+	case domain.ClientTypeOpenAI:
+		if len(resp.Choices) == 0 {
+			return "", fmt.Errorf("no choices")
+		}
+		return resp.Choices[0].FinishReason, nil
+	case domain.ClientTypeGemini:
+		if len(resp.Candidates) == 0 {
+			return "", fmt.Errorf("no candidates")
+		}
+		return resp.Candidates[0].FinishReason, nil
+	case domain.ClientTypeCodex:
+		if resp.IncompleteDetails != nil {
+			return resp.Status + "/" + resp.IncompleteDetails.Reason, nil
+		}
+		return resp.Status, nil
+	}
+	return "", fmt.Errorf("unknown format %s", format)
+}
+
+func renderStopReason(format domain.ClientType, r StopReason) string {
+	switch format {
+	case domain.ClientTypeClaude:
+		return r.Claude()
+	case domain.ClientTypeOpenAI:
+		return r.OpenAI()
+	case domain.ClientTypeGemini:
+		return r.Gemini()
+	case domain.ClientTypeCodex:
+		status, incomplete := r.Codex()
+		if incomplete != "" {
+			return status + "/" + incomplete
+		}
+		return status
+	}
+	return ""
+}
+
+// TestStopReasonMatrix converts every upstream reason to every other client format
+// and checks the client sees the canonical reason in its own format
+func TestStopReasonMatrix(t *testing.T) {
+	registry := GetGlobalRegistry()
+	for upstream, cases := range stopReasonUpstreams {
+		for client := range stopReasonUpstreams {
+			if client == upstream {
+				continue
+			}
+			for _, c := range cases {
+				name := fmt.Sprintf("%s->%s/%s", upstream, client, c.reason)
+				if c.hasTools {
+					name += "+tools"
+				}
+				t.Run(name, func(t *testing.T) {
+					converted, err := registry.TransformResponse(upstream, client, []byte(stopReasonResponse(upstream, c)))
+					if err != nil {
+						t.Fatalf("transform: %v", err)
+					}
+					got, err := clientStopReason(client, converted)
+					if err != nil {
+						t.Fatalf("read %s: %v", converted, err)
+					}
+					if want := renderStopReason(client, c.want); got != want {
+						t.Errorf("stop reason = %q, want %q (canonical %s)", got, want, c.want)
+					}
+				})
+			}
+		}
+	}
+}
+
+// TestStopReasonRoundTrip checks every canonical reason survives its own format
+func TestStopReasonRoundTrip(t *testing.T) {
+	for _, r := range []StopReason{StopReasonEndTurn, StopReasonMaxTokens, StopReasonStopSequence, StopReasonToolUse, StopReasonPauseTurn, StopReasonRefusal} {
+		if got := StopReasonFromClaude(r.Claude()); got != r {
+			t.Errorf("claude: %s -> %s", r, got)
+		}
+		hasTools := r == StopReasonToolUse
+		lossy := r == StopReasonStopSequence || r == StopReasonPauseTurn // only Claude tells these apart
+		want := r
+		if lossy {
+			want = StopReasonEndTurn
+		}
+		if got := StopReasonFromOpenAI(r.OpenAI(), hasTools); got != want {
+			t.Errorf("openai: %s -> %s, want %s", r, got, want)
+		}
+		if got := StopReasonFromGemini(r.Gemini(), hasTools); got != want {
+			t.Errorf("gemini: %s -> %s, want %s", r, got, want)
+		}
+		status, incomplete := r.Codex()
+		if got := StopReasonFromCodex(status, incomplete, hasTools); got != want {
+			t.Errorf("codex: %s -> %s, want %s", r, got, want)
+		}
+	}
+	if _, ok := ParseClaudeStopReason("tool_calls"); ok {
+		t.Error("tool_calls is not a Claude stop_reason")
+	}
+}
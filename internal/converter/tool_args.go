@@ -0,0 +1,165 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// TrackToolArgs follows the tool call arguments of a Claude or OpenAI stream as
+// the client receives it, keeping the calls still open and their arguments so far
+// in the state. Other formats send tool calls whole and aren't tracked.
+func TrackToolArgs(clientType domain.ClientType, chunk []byte, state *TransformState) {
+	if clientType != domain.ClientTypeClaude && clientType != domain.ClientTypeOpenAI {
+		return
+	}
+	if len(chunk) > 0 {
+		tail := state.streamTail + string(chunk)
+		state.streamTail = tail[max(0, len(tail)-4):]
+	}
+	events, remaining := ParseSSE(state.Buffer + string(chunk))
+	state.Buffer = remaining
+
+	for _, event := range events {
+		if state.StreamDone {
+			return
+		}
+		if event.Event == "done" {
+			state.StreamDone = true
+			continue
+		}
+		if clientType == domain.ClientTypeClaude {
+			trackClaudeToolArgs(event, state)
+		} else {
+			trackOpenAIToolArgs(event, state)
+		}
+	}
+}
+
+func trackClaudeToolArgs(event SSEEvent, state *TransformState) {
+	var claudeEvent ClaudeStreamEvent
+	if err := json.Unmarshal(event.Data, &claudeEvent); err != nil {
+		return
+	}
+	switch claudeEvent.Type {
+	case "content_block_start":
+		// tool_use, server_tool_use and mcp_tool_use blocks stream their input
+		if block := claudeEvent.ContentBlock; block != nil && strings.HasSuffix(block.Type, "tool_use") {
+			state.ToolCalls[claudeEvent.Index] = &ToolCallState{ID: block.ID, Name: block.Name}
+			state.CurrentIndex = claudeEvent.Index
+		}
+	case "content_block_delta":
+		if tc, ok := state.ToolCalls[claudeEvent.Index]; ok && claudeEvent.Delta != nil && claudeEvent.Delta.Type == "input_json_delta" {
+			tc.Arguments += claudeEvent.Delta.PartialJSON
+		}
+	case "content_block_stop":
+		delete(state.ToolCalls, claudeEvent.Index)
+	case "message_stop", "error":
+		state.StreamDone = true
+	}
+}
+
+func trackOpenAIToolArgs(event SSEEvent, state *TransformState) {
+	var chunk OpenAIStreamChunk
+	if err := json.Unmarshal(event.Data, &chunk); err != nil {
+		return
+	}
+	if chunk.ID != "" {
+		state.MessageID = chunk.ID
+	}
+	for _, choice := range chunk.Choices {
+		if choice.Delta != nil {
+			for _, call := range choice.Delta.ToolCalls {
+				tc, ok := state.ToolCalls[call.Index]
+				if !ok {
+					tc = &ToolCallState{}
+					state.ToolCalls[call.Index] = tc
+				}
+				if call.ID != "" {
+					tc.ID = call.ID
+				}
+				if call.Function.Name != "" {
+					tc.Name = call.Function.Name
+				}
+				tc.Arguments += call.Function.Arguments
+				state.CurrentIndex = call.Index
+			}
+		}
+		if choice.FinishReason != "" {
+			// Every tool call of the message is complete
+			state.StreamDone = true
+		}
+	}
+}
+
+// CloseToolArgs returns the events that close the tool call a stream was cut off
+// in, before its final event: the rest of the arguments as completed by
+// ClosePartialJSON, or an error event naming the call when its arguments can't be
+// repaired. Returns nil when the stream ended properly or outside of a tool call.
+func CloseToolArgs(clientType domain.ClientType, state *TransformState) []byte {
+	// End the event the stream was cut off in, the client reads it before ours
+	var output []byte
+	switch tail := state.streamTail; {
+	case tail == "", strings.HasSuffix(tail, "\n\n"), strings.HasSuffix(tail, "\r\n\r\n"):
+	case strings.HasSuffix(tail, "\n"):
+		output = []byte("\n")
+	default:
+		output = []byte("\n\n")
+	}
+	TrackToolArgs(clientType, output, state)
+	if state.StreamDone {
+		return nil
+	}
+	tc, ok := state.ToolCalls[state.CurrentIndex]
+	if !ok {
+		return nil
+	}
+	state.StreamDone = true
+
+	suffix, repairable := ClosePartialJSON(tc.Arguments)
+	if !repairable {
+		message := fmt.Sprintf("upstream stream ended inside the arguments of tool call %s, which are not valid JSON", tc.Name)
+		if clientType == domain.ClientTypeClaude {
+			return append(output, FormatSSE("error", map[string]interface{}{
+				"type":  "error",
+				"error": map[string]string{"type": "api_error", "message": message},
+			})...)
+		}
+		return append(output, FormatSSE("", map[string]interface{}{
+			"error": map[string]string{"type": "upstream_error", "message": message},
+		})...)
+	}
+
+	if clientType == domain.ClientTypeOpenAI {
+		return append(output, FormatSSE("", map[string]interface{}{
+			"id":      state.MessageID,
+			"object":  "chat.completion.chunk",
+			"created": time.Now().Unix(),
+			"choices": []map[string]interface{}{{
+				"index": 0,
+				"delta": map[string]interface{}{
+					"tool_calls": []map[string]interface{}{{
+						"index":    state.CurrentIndex,
+						"function": map[string]string{"arguments": suffix},
+					}},
+				},
+			}},
+		})...)
+	}
+
+	// Claude clients read a block without input deltas as an empty input
+	if tc.Arguments != "" {
+		output = append(output, FormatSSE("content_block_delta", map[string]interface{}{
+			"type":  "content_block_delta",
+			"index": state.CurrentIndex,
+			"delta": map[string]string{"type": "input_json_delta", "partial_json": suffix},
+		})...)
+	}
+	return append(output, FormatSSE("content_block_stop", map[string]interface{}{
+		"type":  "content_block_stop",
+		"index": state.CurrentIndex,
+	})...)
+}
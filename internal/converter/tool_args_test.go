@@ -0,0 +1,203 @@
+package converter
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestClosePartialJSON(t *testing.T) {
+	tests := []struct {
+		partial string
+		want    string // the completed document
+	}{
+		{``, `{}`},
+		{`{`, `{}`},
+		{`{"pa`, `{"pa":null}`},
+		{`{"path"`, `{"path":null}`},
+		{`{"path": `, `{"path": null}`},
+		{`{"path":"a.txt","content":"hel`, `{"path":"a.txt","content":"hel"}`},
+		{`{"content":"line\`, `{"content":"line\\"}`},
+		{`{"content":"\u00`, `{"content":"\u0000"}`},
+		{`{"n":-`, `{"n":-0}`},
+		{`{"n":1.`, `{"n":1.0}`},
+		{`{"n":2e+`, `{"n":2e+0}`},
+		{`{"n":12`, `{"n":12}`},
+		{`{"ok":tr`, `{"ok":true}`},
+		{`{"v":nu`, `{"v":null}`},
+		{`{"a":1,`, `{"a":1,"_truncated":true}`},
+		{`{"list":[1,`, `{"list":[1,null]}`},
+		{`{"list":[`, `{"list":[]}`},
+		{`{"edits":[{"old":"x","new":"y"},{"old":"`, `{"edits":[{"old":"x","new":"y"},{"old":""}]}`},
+		{`{"a":{"b":[true]}}`, `{"a":{"b":[true]}}`},
+	}
+	for _, tt := range tests {
+		suffix, ok := ClosePartialJSON(tt.partial)
+		if !ok || tt.partial+suffix != tt.want {
+			t.Errorf("ClosePartialJSON(%q) = %q, %v; want %q", tt.partial, suffix, ok, tt.want)
+			continue
+		}
+		if !json.Valid([]byte(tt.want)) {
+			t.Errorf("%q completes to invalid JSON", tt.partial)
+		}
+	}
+
+	for _, partial := range []string{`{"a":1}}`, `{"a" 1`, `{x`, `{"a":tx`, `["a"]{`, `{"s":"\q`} {
+		if suffix, ok := ClosePartialJSON(partial); ok {
+			t.Errorf("ClosePartialJSON(%q) = %q, want it rejected", partial, suffix)
+		}
+	}
+}
+
+const toolArgs = `{"path":"src/main.go","edits":[{"line":12,"text":"fmt.Println(\"hi\\n\")"}],"dry_run":false,"limit":null}`
+
+// splitArgs streams the arguments in small fragments, like upstreams do
+func splitArgs(args string) []string {
+	var parts []string
+	for len(args) > 7 {
+		parts = append(parts, args[:7])
+		args = args[7:]
+	}
+	return append(parts, args)
+}
+
+func claudeToolStream() string {
+	var sb strings.Builder
+	sb.WriteString("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"type\":\"message\",\"role\":\"assistant\",\"content\":[]}}\n\n")
+	sb.WriteString("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n")
+	sb.WriteString("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Editing\"}}\n\n")
+	sb.WriteString("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n")
+	sb.WriteString("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"tool_use\",\"id\":\"toolu_1\",\"name\":\"edit\",\"input\":{}}}\n\n")
+	for _, part := range splitArgs(toolArgs) {
+		sb.Write(FormatSSE("content_block_delta", map[string]interface{}{
+			"type": "content_block_delta", "index": 1,
+			"delta": map[string]string{"type": "input_json_delta", "partial_json": part},
+		}))
+	}
+	sb.WriteString("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":1}\n\n")
+	sb.WriteString("event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"tool_use\"}}\n\n")
+	sb.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	return sb.String()
+}
+
+func openAIToolStream() string {
+	var sb strings.Builder
+	sb.WriteString(`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"read","arguments":""}}]}}]}` + "\n\n")
+	sb.WriteString(`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"path\":\"a\"}"}}]}}]}` + "\n\n")
+	sb.WriteString(`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"edit","arguments":""}}]}}]}` + "\n\n")
+	for _, part := range splitArgs(toolArgs) {
+		sb.Write(FormatSSE("", map[string]interface{}{
+			"id": "chatcmpl-1", "object": "chat.completion.chunk",
+			"choices": []map[string]interface{}{{"index": 0, "delta": map[string]interface{}{
+				"tool_calls": []map[string]interface{}{{"index": 1, "function": map[string]string{"arguments": part}}},
+			}}},
+		}))
+	}
+	sb.WriteString(`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}` + "\n\n")
+	sb.WriteString("data: [DONE]\n\n")
+	return sb.String()
+}
+
+// clientToolInputs reads the tool call arguments out of a stream the way clients
+// assemble them, the Claude inputs of the blocks that were closed
+func clientToolInputs(clientType domain.ClientType, stream string) []string {
+	events, _ := ParseSSE(stream)
+	args := make(map[int]*string)
+	var order []int
+	var closed []string
+	for _, event := range events {
+		if clientType == domain.ClientTypeClaude {
+			var e ClaudeStreamEvent
+			if json.Unmarshal(event.Data, &e) != nil {
+				continue
+			}
+			switch {
+			case e.Type == "content_block_start" && e.ContentBlock.Type == "tool_use":
+				args[e.Index] = new(string)
+			case e.Type == "content_block_delta" && args[e.Index] != nil:
+				*args[e.Index] += e.Delta.PartialJSON
+			case e.Type == "content_block_stop" && args[e.Index] != nil:
+				input := *args[e.Index]
+				if input == "" {
+					input = "{}"
+				}
+				closed = append(closed, input)
+				delete(args, e.Index)
+			}
+			continue
+		}
+		var chunk OpenAIStreamChunk
+		if json.Unmarshal(event.Data, &chunk) != nil || len(chunk.Choices) == 0 || chunk.Choices[0].Delta == nil {
+			continue
+		}
+		for _, call := range chunk.Choices[0].Delta.ToolCalls {
+			if args[call.Index] == nil {
+				args[call.Index] = new(string)
+				order = append(order, call.Index)
+			}
+			*args[call.Index] += call.Function.Arguments
+		}
+	}
+	for _, i := range order {
+		closed = append(closed, *args[i])
+	}
+	return closed
+}
+
+func TestCloseToolArgsOnTruncatedStreams(t *testing.T) {
+	streams := map[domain.ClientType]string{
+		domain.ClientTypeClaude: claudeToolStream(),
+		domain.ClientTypeOpenAI: openAIToolStream(),
+	}
+	for clientType, stream := range streams {
+		argsStart := strings.Index(stream, `\"path\":\"src`)
+		argsEnd := strings.LastIndex(stream, `limit\":null}`)
+
+		// Cut the stream at every byte, inside the arguments and around them
+		for cut := 0; cut <= len(stream); cut++ {
+			state := NewTransformState()
+			prefix := stream[:cut]
+			// Upstreams write in pieces, the tracker must follow them across writes
+			for _, piece := range []string{prefix[:cut/2], prefix[cut/2:]} {
+				TrackToolArgs(clientType, []byte(piece), state)
+			}
+			closing := CloseToolArgs(clientType, state)
+
+			if cut == len(stream) && closing != nil {
+				t.Fatalf("%s: complete stream got closing events %q", clientType, closing)
+			}
+			inputs := clientToolInputs(clientType, prefix+string(closing))
+			for _, input := range inputs {
+				if !json.Valid([]byte(input)) {
+					t.Fatalf("%s cut at %d: client assembled invalid arguments %q", clientType, cut, input)
+				}
+			}
+			if cut > argsStart && cut < argsEnd && len(inputs) == 0 {
+				t.Fatalf("%s cut at %d: tool call inside the arguments was dropped", clientType, cut)
+			}
+			if CloseToolArgs(clientType, state) != nil {
+				t.Fatalf("%s cut at %d: stream closed twice", clientType, cut)
+			}
+		}
+	}
+}
+
+func TestCloseToolArgsUnrepairable(t *testing.T) {
+	state := NewTransformState()
+	TrackToolArgs(domain.ClientTypeClaude, []byte(
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"tool_use\",\"id\":\"toolu_1\",\"name\":\"edit\",\"input\":{}}}\n\n"+
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{path: 1\"}}\n\n"), state)
+	events, _ := ParseSSE(string(CloseToolArgs(domain.ClientTypeClaude, state)))
+	if len(events) != 1 || events[0].Event != "error" || !strings.Contains(string(events[0].Data), "tool call edit") {
+		t.Errorf("closing events = %+v, want an error naming the tool call", events)
+	}
+
+	// Streams that end properly or outside of a tool call are left alone
+	state = NewTransformState()
+	TrackToolArgs(domain.ClientTypeOpenAI, []byte(`data: {"choices":[{"index":0,"delta":{"content":"hi"}}]}`+"\n\n"), state)
+	if closing := CloseToolArgs(domain.ClientTypeOpenAI, state); closing != nil {
+		t.Errorf("text stream got closing events %q", closing)
+	}
+}
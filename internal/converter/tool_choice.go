@@ -0,0 +1,108 @@
+package converter
+
+// Tool choice modes shared by all formats
+const (
+	toolChoiceAuto = "auto" // the model decides
+	toolChoiceAny  = "any"  // the model must call some tool
+	toolChoiceNone = "none" // the model must not call tools
+	toolChoiceTool = "tool" // the model must call the named tool
+)
+
+// toolChoice is the format-independent form of a tool_choice setting
+type toolChoice struct {
+	Mode            string
+	Name            string // for toolChoiceTool
+	DisableParallel bool   // Claude's disable_parallel_tool_use
+}
+
+// claudeToolChoice parses Claude's tool_choice object, nil when unset
+func claudeToolChoice(v interface{}) *toolChoice {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	tc := &toolChoice{Mode: toolChoiceAuto}
+	switch t, _ := m["type"].(string); t {
+	case "any":
+		tc.Mode = toolChoiceAny
+	case "none":
+		tc.Mode = toolChoiceNone
+	case "tool":
+		tc.Mode = toolChoiceTool
+		tc.Name, _ = m["name"].(string)
+	}
+	tc.DisableParallel, _ = m["disable_parallel_tool_use"].(bool)
+	return tc
+}
+
+// toClaude renders the tool choice as Claude's tool_choice object
+func (tc *toolChoice) toClaude() map[string]interface{} {
+	out := map[string]interface{}{"type": tc.Mode}
+	if tc.Mode == toolChoiceTool {
+		out["name"] = tc.Name
+	}
+	if tc.DisableParallel && tc.Mode != toolChoiceNone {
+		out["disable_parallel_tool_use"] = true
+	}
+	return out
+}
+
+// openaiToolChoice parses OpenAI's tool_choice, nil when unset
+func openaiToolChoice(v interface{}) *toolChoice {
+	if v == nil {
+		return nil
+	}
+	c := openaiToolChoiceToClaude(v)
+	return claudeToolChoice(c)
+}
+
+// toOpenAI renders the tool choice as OpenAI's tool_choice
+func (tc *toolChoice) toOpenAI() interface{} {
+	switch tc.Mode {
+	case toolChoiceAny:
+		return "required"
+	case toolChoiceNone:
+		return "none"
+	case toolChoiceTool:
+		return map[string]interface{}{
+			"type":     "function",
+			"function": map[string]interface{}{"name": tc.Name},
+		}
+	}
+	return "auto"
+}
+
+// geminiToolChoice parses Gemini's function calling config, nil when unset
+func geminiToolChoice(cfg *GeminiToolConfig) *toolChoice {
+	if cfg == nil || cfg.FunctionCallingConfig == nil {
+		return nil
+	}
+	fc := cfg.FunctionCallingConfig
+	switch fc.Mode {
+	case "ANY":
+		if len(fc.AllowedFunctionNames) == 1 {
+			return &toolChoice{Mode: toolChoiceTool, Name: fc.AllowedFunctionNames[0]}
+		}
+		return &toolChoice{Mode: toolChoiceAny}
+	case "NONE":
+		return &toolChoice{Mode: toolChoiceNone}
+	case "AUTO":
+		return &toolChoice{Mode: toolChoiceAuto}
+	}
+	return nil
+}
+
+// toGemini renders the tool choice as Gemini's tool config
+func (tc *toolChoice) toGemini() *GeminiToolConfig {
+	fc := &GeminiFunctionCallingConfig{Mode: "AUTO"}
+	switch tc.Mode {
+	case toolChoiceAny:
+		fc.Mode = "ANY"
+	case toolChoiceNone:
+		fc.Mode = "NONE"
+	case toolChoiceTool:
+		fc.Mode = "ANY"
+		fc.AllowedFunctionNames = []string{tc.Name}
+	}
+	return &GeminiToolConfig{FunctionCallingConfig: fc}
+}
@@ -1,5 +1,7 @@
 package converter
 
+import "strings"
+
 // Claude API types
 
 type ClaudeRequest struct {
@@ -17,6 +19,7 @@ type ClaudeRequest struct {
 	ToolChoice    interface{}            `json:"tool_choice,omitempty"`
 	Thinking      map[string]interface{} `json:"thinking,omitempty"` // {"type": "enabled", "budget_tokens": N}
 	OutputConfig  *ClaudeOutputConfig    `json:"output_config,omitempty"`
+	OutputFormat  *ClaudeOutputFormat    `json:"output_format,omitempty"`
 }
 
 // ClaudeMetadata represents request metadata (like Antigravity-Manager)
@@ -29,6 +32,12 @@ type ClaudeOutputConfig struct {
 	Effort string `json:"effort,omitempty"` // "high", "medium", "low"
 }
 
+// ClaudeOutputFormat constrains the response to a JSON schema (structured outputs)
+type ClaudeOutputFormat struct {
+	Type   string      `json:"type"` // "json_schema"
+	Schema interface{} `json:"schema,omitempty"`
+}
+
 type ClaudeMessage struct {
 	Role    string               `json:"role"`
 	Content interface{}          `json:"content"` // string or []ContentBlock
@@ -52,13 +61,28 @@ type ClaudeContentBlock struct {
 	CacheControl interface{} `json:"cache_control,omitempty"`
 	// Image source
 	Source *ClaudeImageSource `json:"source,omitempty"`
+	// Citations of a text block
+	Citations []ClaudeCitation `json:"citations,omitempty"`
+}
+
+// ClaudeCitationWebSearch is the citation type of web search results
+const ClaudeCitationWebSearch = "web_search_result_location"
+
+// ClaudeCitation is a citation of a text block, only web search results are modelled
+type ClaudeCitation struct {
+	Type           string `json:"type"`
+	URL            string `json:"url,omitempty"`
+	Title          string `json:"title,omitempty"`
+	CitedText      string `json:"cited_text"`
+	EncryptedIndex string `json:"encrypted_index"`
 }
 
-// ClaudeImageSource represents image source in Claude API
+// ClaudeImageSource represents image and document source in Claude API
 type ClaudeImageSource struct {
-	Type      string `json:"type"`       // "base64"
-	MediaType string `json:"media_type"` // e.g. "image/png"
-	Data      string `json:"data"`       // base64 data
+	Type      string `json:"type"`                 // "base64" or "url"
+	MediaType string `json:"media_type,omitempty"` // e.g. "image/png"
+	Data      string `json:"data,omitempty"`       // base64 data
+	URL       string `json:"url,omitempty"`        // url sources
 }
 
 type ClaudeTool struct {
@@ -83,6 +107,11 @@ func (t *ClaudeTool) IsWebSearch() bool {
 	return false
 }
 
+// IsCodeExecution checks if this is the code_execution server tool (any version)
+func (t *ClaudeTool) IsCodeExecution() bool {
+	return strings.HasPrefix(t.Type, "code_execution_")
+}
+
 type ClaudeResponse struct {
 	ID           string               `json:"id"`
 	Type         string               `json:"type"`
@@ -117,4 +146,6 @@ type ClaudeStreamDelta struct {
 	PartialJSON  string `json:"partial_json,omitempty"`
 	StopReason   string `json:"stop_reason,omitempty"`
 	StopSequence string `json:"stop_sequence,omitempty"`
+	// Citation of a citations_delta
+	Citation *ClaudeCitation `json:"citation,omitempty"`
 }
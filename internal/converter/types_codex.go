@@ -44,6 +44,12 @@ type CodexResponse struct {
 	Status           string        `json:"status"`
 	Usage            CodexUsage    `json:"usage"`
 	Error            *CodexError   `json:"error,omitempty"`
+	IncompleteDetails *CodexIncompleteDetails `json:"incomplete_details,omitempty"`
+}
+
+// CodexIncompleteDetails explains why a response has status "incomplete"
+type CodexIncompleteDetails struct {
+	Reason string `json:"reason"` // "max_output_tokens" or "content_filter"
 }
 
 type CodexOutput struct {
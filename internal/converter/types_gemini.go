@@ -17,12 +17,14 @@ type GeminiContent struct {
 }
 
 type GeminiPart struct {
-	Text             string                  `json:"text,omitempty"`
-	InlineData       *GeminiInlineData       `json:"inlineData,omitempty"`
-	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
-	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
-	Thought          bool                    `json:"thought,omitempty"`
-	ThoughtSignature string                  `json:"thoughtSignature,omitempty"`
+	Text                string                     `json:"text,omitempty"`
+	InlineData          *GeminiInlineData          `json:"inlineData,omitempty"`
+	FunctionCall        *GeminiFunctionCall        `json:"functionCall,omitempty"`
+	FunctionResponse    *GeminiFunctionResponse    `json:"functionResponse,omitempty"`
+	ExecutableCode      *GeminiExecutableCode      `json:"executableCode,omitempty"`
+	CodeExecutionResult *GeminiCodeExecutionResult `json:"codeExecutionResult,omitempty"`
+	Thought             bool                       `json:"thought,omitempty"`
+	ThoughtSignature    string                     `json:"thoughtSignature,omitempty"`
 }
 
 type GeminiInlineData struct {
@@ -42,16 +44,29 @@ type GeminiFunctionResponse struct {
 	ID       string      `json:"id,omitempty"` // Required for v1internal
 }
 
+// GeminiExecutableCode is code generated by the model for the code execution tool
+type GeminiExecutableCode struct {
+	Language string `json:"language"`
+	Code     string `json:"code"`
+}
+
+// GeminiCodeExecutionResult is the result of running a GeminiExecutableCode part
+type GeminiCodeExecutionResult struct {
+	Outcome string `json:"outcome"`
+	Output  string `json:"output,omitempty"`
+}
+
 type GeminiGenerationConfig struct {
-	Temperature      *float64              `json:"temperature,omitempty"`
-	TopP             *float64              `json:"topP,omitempty"`
-	TopK             *int                  `json:"topK,omitempty"`
-	MaxOutputTokens  int                   `json:"maxOutputTokens,omitempty"`
-	StopSequences    []string              `json:"stopSequences,omitempty"`
-	CandidateCount   int                   `json:"candidateCount,omitempty"`
-	ResponseMimeType string                `json:"responseMimeType,omitempty"`
-	ThinkingConfig   *GeminiThinkingConfig `json:"thinkingConfig,omitempty"`
-	EffortLevel      string                `json:"effortLevel,omitempty"` // Claude API v2.0.67+ effort mapping
+	Temperature        *float64              `json:"temperature,omitempty"`
+	TopP               *float64              `json:"topP,omitempty"`
+	TopK               *int                  `json:"topK,omitempty"`
+	MaxOutputTokens    int                   `json:"maxOutputTokens,omitempty"`
+	StopSequences      []string              `json:"stopSequences,omitempty"`
+	CandidateCount     int                   `json:"candidateCount,omitempty"`
+	ResponseMimeType   string                `json:"responseMimeType,omitempty"`
+	ResponseJSONSchema interface{}           `json:"responseJsonSchema,omitempty"` // JSON Schema of the response when ResponseMimeType is application/json
+	ThinkingConfig     *GeminiThinkingConfig `json:"thinkingConfig,omitempty"`
+	EffortLevel        string                `json:"effortLevel,omitempty"` // Claude API v2.0.67+ effort mapping
 }
 
 type GeminiThinkingConfig struct {
@@ -68,6 +83,7 @@ type GeminiTool struct {
 	FunctionDeclarations []GeminiFunctionDecl `json:"functionDeclarations,omitempty"`
 	GoogleSearch         *struct{}            `json:"googleSearch,omitempty"`
 	GoogleSearchRetrieval *struct{}           `json:"googleSearchRetrieval,omitempty"`
+	CodeExecution        *struct{}            `json:"codeExecution,omitempty"`
 }
 
 type GeminiFunctionDecl struct {
@@ -96,6 +112,8 @@ type GeminiCandidate struct {
 	FinishReason  string              `json:"finishReason,omitempty"`
 	SafetyRatings []GeminiSafetyRating `json:"safetyRatings,omitempty"`
 	Index         int                 `json:"index"`
+	// Web search grounding, see grounding.go
+	GroundingMetadata *GeminiGroundingMetadata `json:"groundingMetadata,omitempty"`
 }
 
 type GeminiSafetyRating struct {
@@ -18,7 +18,9 @@ type OpenAIRequest struct {
 	User             string           `json:"user,omitempty"`
 	Tools            []OpenAITool     `json:"tools,omitempty"`
 	ToolChoice       interface{}      `json:"tool_choice,omitempty"`
+	ParallelToolCalls *bool           `json:"parallel_tool_calls,omitempty"`
 	ResponseFormat   *OpenAIResponseFormat `json:"response_format,omitempty"`
+	ReasoningEffort  string           `json:"reasoning_effort,omitempty"` // "low", "medium", "high"
 }
 
 type OpenAIMessage struct {
@@ -27,6 +29,24 @@ type OpenAIMessage struct {
 	Name       string          `json:"name,omitempty"`
 	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string          `json:"tool_call_id,omitempty"`
+	// Reasoning text of thinking models (DeepSeek-style reasoning_content)
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	// Web search citations of the assistant content
+	Annotations []OpenAIAnnotation `json:"annotations,omitempty"`
+}
+
+// OpenAIAnnotation is a message annotation, only url_citation is used
+type OpenAIAnnotation struct {
+	Type        string             `json:"type"`
+	URLCitation *OpenAIURLCitation `json:"url_citation,omitempty"`
+}
+
+// OpenAIURLCitation cites a web page for the content between StartIndex and EndIndex (characters)
+type OpenAIURLCitation struct {
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
 }
 
 type OpenAIContentPart struct {
@@ -64,7 +84,14 @@ type OpenAIFunctionCall struct {
 }
 
 type OpenAIResponseFormat struct {
-	Type string `json:"type"`
+	Type       string            `json:"type"` // "text", "json_object" or "json_schema"
+	JSONSchema *OpenAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type OpenAIJSONSchema struct {
+	Name   string      `json:"name"`
+	Schema interface{} `json:"schema,omitempty"`
+	Strict *bool       `json:"strict,omitempty"`
 }
 
 type OpenAIResponse struct {
@@ -86,9 +113,21 @@ type OpenAIChoice struct {
 }
 
 type OpenAIUsage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+	PromptTokens            int                      `json:"prompt_tokens"`
+	CompletionTokens        int                      `json:"completion_tokens"`
+	TotalTokens             int                      `json:"total_tokens"`
+	PromptTokensDetails     *OpenAIPromptDetails     `json:"prompt_tokens_details,omitempty"`
+	CompletionTokensDetails *OpenAICompletionDetails `json:"completion_tokens_details,omitempty"`
+}
+
+// OpenAIPromptDetails breaks down prompt tokens, cached tokens are included in prompt_tokens
+type OpenAIPromptDetails struct {
+	CachedTokens int `json:"cached_tokens,omitempty"`
+}
+
+// OpenAICompletionDetails breaks down completion tokens, reasoning tokens are included in completion_tokens
+type OpenAICompletionDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens,omitempty"`
 }
 
 // OpenAI streaming chunk
@@ -9,8 +9,11 @@ import (
 )
 
 // FailureTracker manages failure counts and their persistence
+// Changes are only recorded in memory; the owning Manager flushes dirty keys periodically
 type FailureTracker struct {
 	failureCounts map[FailureKey]int
+	lastFailureAt map[FailureKey]time.Time
+	dirty         map[FailureKey]bool // keys changed since the last flush
 	repository    repository.FailureCountRepository
 }
 
@@ -18,6 +21,8 @@ type FailureTracker struct {
 func NewFailureTracker() *FailureTracker {
 	return &FailureTracker{
 		failureCounts: make(map[FailureKey]int),
+		lastFailureAt: make(map[FailureKey]time.Time),
+		dirty:         make(map[FailureKey]bool),
 	}
 }
 
@@ -38,6 +43,8 @@ func (ft *FailureTracker) LoadFromDatabase() error {
 	}
 
 	ft.failureCounts = make(map[FailureKey]int)
+	ft.lastFailureAt = make(map[FailureKey]time.Time)
+	ft.dirty = make(map[FailureKey]bool)
 	for _, fc := range failureCounts {
 		key := FailureKey{
 			ProviderID: fc.ProviderID,
@@ -45,13 +52,14 @@ func (ft *FailureTracker) LoadFromDatabase() error {
 			Reason:     CooldownReason(fc.Reason),
 		}
 		ft.failureCounts[key] = fc.Count
+		ft.lastFailureAt[key] = fc.LastFailureAt
 	}
 
 	log.Printf("[FailureTracker] Loaded %d failure counts from database", len(failureCounts))
 	return nil
 }
 
-// IncrementFailure increments the failure count and persists to database
+// IncrementFailure increments the failure count and marks it for the next flush
 // Returns the new failure count
 func (ft *FailureTracker) IncrementFailure(providerID uint64, clientType string, reason CooldownReason) int {
 	key := FailureKey{
@@ -61,23 +69,10 @@ func (ft *FailureTracker) IncrementFailure(providerID uint64, clientType string,
 	}
 
 	ft.failureCounts[key]++
-	newCount := ft.failureCounts[key]
+	ft.lastFailureAt[key] = time.Now().UTC()
+	ft.dirty[key] = true
 
-	// Persist to database
-	if ft.repository != nil {
-		fc := &domain.FailureCount{
-			ProviderID:    providerID,
-			ClientType:    clientType,
-			Reason:        string(reason),
-			Count:         newCount,
-			LastFailureAt: time.Now().UTC(),
-		}
-		if err := ft.repository.Upsert(fc); err != nil {
-			log.Printf("[FailureTracker] Failed to persist failure count: %v", err)
-		}
-	}
-
-	return newCount
+	return ft.failureCounts[key]
 }
 
 // GetFailureCount returns the current failure count for a given key
@@ -106,13 +101,8 @@ func (ft *FailureTracker) ResetFailures(providerID uint64, clientType string) {
 	if len(keysToDelete) > 0 {
 		for _, key := range keysToDelete {
 			delete(ft.failureCounts, key)
-		}
-
-		// Delete from database
-		if ft.repository != nil {
-			if err := ft.repository.DeleteAll(providerID, clientType); err != nil {
-				log.Printf("[FailureTracker] Failed to delete failure counts from database: %v", err)
-			}
+			delete(ft.lastFailureAt, key)
+			ft.dirty[key] = true
 		}
 
 		log.Printf("[FailureTracker] Provider %d (clientType=%s): Reset %d failure counts",
@@ -123,7 +113,6 @@ func (ft *FailureTracker) ResetFailures(providerID uint64, clientType string) {
 // CleanupExpired removes failure counts that are too old
 // This prevents indefinite accumulation of failures
 func (ft *FailureTracker) CleanupExpired(olderThanSeconds int64) {
-	// Only cleanup from database - memory will be cleaned on next load
 	if ft.repository != nil {
 		if err := ft.repository.DeleteExpired(olderThanSeconds); err != nil {
 			log.Printf("[FailureTracker] Failed to cleanup expired failure counts: %v", err)
@@ -132,8 +121,32 @@ func (ft *FailureTracker) CleanupExpired(olderThanSeconds int64) {
 		}
 	}
 
-	// Reload from database to sync memory state
-	if err := ft.LoadFromDatabase(); err != nil {
-		log.Printf("[FailureTracker] Failed to reload after cleanup: %v", err)
+	// Prune memory directly instead of reloading, so unflushed counts are not lost
+	cutoff := time.Now().UTC().Add(-time.Duration(olderThanSeconds) * time.Second)
+	for key, at := range ft.lastFailureAt {
+		if at.Before(cutoff) {
+			delete(ft.failureCounts, key)
+			delete(ft.lastFailureAt, key)
+			ft.dirty[key] = true
+		}
+	}
+}
+
+// takeDirty returns the changes since the last call and clears the dirty set
+func (ft *FailureTracker) takeDirty() (upserts []*domain.FailureCount, deletes []FailureKey) {
+	for key := range ft.dirty {
+		if count, ok := ft.failureCounts[key]; ok {
+			upserts = append(upserts, &domain.FailureCount{
+				ProviderID:    key.ProviderID,
+				ClientType:    key.ClientType,
+				Reason:        string(key.Reason),
+				Count:         count,
+				LastFailureAt: ft.lastFailureAt[key],
+			})
+		} else {
+			deletes = append(deletes, key)
+		}
 	}
+	ft.dirty = make(map[FailureKey]bool)
+	return upserts, deletes
 }
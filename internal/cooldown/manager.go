@@ -2,6 +2,7 @@ package cooldown
 
 import (
 	"log"
+	"strconv"
 	"sync"
 	"time"
 
@@ -10,21 +11,30 @@ import (
 )
 
 // Manager manages provider cooldown states
-// Cooldown is stored in memory and persisted to database
+// Cooldown is stored in memory and persisted to database by periodic incremental flushes
 type Manager struct {
 	mu             sync.RWMutex
+	flushMu        sync.Mutex                        // serializes Flush calls
 	cooldowns      map[CooldownKey]time.Time         // cooldown key -> end time
 	reasons        map[CooldownKey]CooldownReason    // cooldown key -> reason
+	dirty          map[CooldownKey]bool              // cooldown keys changed since the last flush
 	failureTracker *FailureTracker                   // tracks failure counts
 	policies       map[CooldownReason]CooldownPolicy // cooldown calculation strategies
 	repository     repository.CooldownRepository
+	eventSink      EventSink
 }
 
+// EventSink receives the provider lifecycle events of the manager, outside of its lock
+type EventSink func(event *domain.ProviderEvent)
+
+const defaultFlushSecs = 10
+
 // NewManager creates a new cooldown manager
 func NewManager() *Manager {
 	return &Manager{
 		cooldowns:      make(map[CooldownKey]time.Time),
 		reasons:        make(map[CooldownKey]CooldownReason),
+		dirty:          make(map[CooldownKey]bool),
 		failureTracker: NewFailureTracker(),
 		policies:       DefaultPolicies(),
 	}
@@ -52,6 +62,34 @@ func (m *Manager) SetFailureCountRepository(repo repository.FailureCountReposito
 	m.failureTracker.SetRepository(repo)
 }
 
+// SetEventSink sets the receiver of provider lifecycle events
+func (m *Manager) SetEventSink(sink EventSink) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.eventSink = sink
+}
+
+// RecordEvent passes a provider event to the sink, for transitions observed
+// outside the manager such as rejected credentials
+func (m *Manager) RecordEvent(event *domain.ProviderEvent) {
+	m.mu.RLock()
+	sink := m.eventSink
+	m.mu.RUnlock()
+	if sink == nil {
+		return
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	sink(event)
+}
+
+func (m *Manager) emit(events []*domain.ProviderEvent) {
+	for _, event := range events {
+		m.RecordEvent(event)
+	}
+}
+
 // LoadFromDatabase loads all active cooldowns and failure counts from database into memory
 func (m *Manager) LoadFromDatabase() error {
 	m.mu.Lock()
@@ -66,6 +104,7 @@ func (m *Manager) LoadFromDatabase() error {
 
 		m.cooldowns = make(map[CooldownKey]time.Time)
 		m.reasons = make(map[CooldownKey]CooldownReason)
+		m.dirty = make(map[CooldownKey]bool)
 		for _, cd := range cooldowns {
 			key := CooldownKey{
 				ProviderID: cd.ProviderID,
@@ -91,12 +130,14 @@ func (m *Manager) LoadFromDatabase() error {
 // Otherwise, the cooldown duration is calculated using the policy for the given reason
 // Returns the calculated cooldown end time
 func (m *Manager) RecordFailure(providerID uint64, clientType string, reason CooldownReason, explicitUntil *time.Time) time.Time {
+	var events []*domain.ProviderEvent
+	defer func() { m.emit(events) }()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	// If explicit until time is provided (e.g., from 429 Retry-After), use it directly
 	if explicitUntil != nil {
-		m.setCooldownLocked(providerID, clientType, *explicitUntil, reason)
+		events = m.setCooldownLocked(providerID, clientType, *explicitUntil, reason)
 		log.Printf("[Cooldown] Provider %d (clientType=%s): Set explicit cooldown until %s (reason=%s)",
 			providerID, clientType, explicitUntil.Format("2006-01-02 15:04:05"), reason)
 		return *explicitUntil
@@ -118,7 +159,7 @@ func (m *Manager) RecordFailure(providerID uint64, clientType string, reason Coo
 	duration := policy.CalculateCooldown(failureCount)
 	until := time.Now().Add(duration)
 
-	m.setCooldownLocked(providerID, clientType, until, reason)
+	events = m.setCooldownLocked(providerID, clientType, until, reason)
 
 	log.Printf("[Cooldown] Provider %d (clientType=%s): Set cooldown for %v until %s (reason=%s, failureCount=%d)",
 		providerID, clientType, duration, until.Format("2006-01-02 15:04:05"), reason, failureCount)
@@ -130,6 +171,8 @@ func (m *Manager) RecordFailure(providerID uint64, clientType string, reason Coo
 // This is used for async updates (e.g., when quota reset time is fetched asynchronously)
 // Keeps the existing reason
 func (m *Manager) UpdateCooldown(providerID uint64, clientType string, until time.Time) {
+	var events []*domain.ProviderEvent
+	defer func() { m.emit(events) }()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -140,7 +183,7 @@ func (m *Manager) UpdateCooldown(providerID uint64, clientType string, until tim
 		reason = ReasonUnknown
 	}
 
-	m.setCooldownLocked(providerID, clientType, until, reason)
+	events = m.setCooldownLocked(providerID, clientType, until, reason)
 	log.Printf("[Cooldown] Provider %d (clientType=%s): Updated cooldown to %s (async update, no count increment)",
 		providerID, clientType, until.Format("2006-01-02 15:04:05"))
 }
@@ -148,19 +191,18 @@ func (m *Manager) UpdateCooldown(providerID uint64, clientType string, until tim
 // RecordSuccess records a successful request and clears cooldown + resets failure counts
 // This ensures the provider is immediately available after a successful request
 func (m *Manager) RecordSuccess(providerID uint64, clientType string) {
+	var events []*domain.ProviderEvent
+	defer func() { m.emit(events) }()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Clear cooldown from memory
+	// Clear cooldown from memory, the next flush deletes it from database
 	key := CooldownKey{ProviderID: providerID, ClientType: clientType}
-	delete(m.cooldowns, key)
-	delete(m.reasons, key)
-
-	// Delete from database
-	if m.repository != nil {
-		if err := m.repository.Delete(providerID, clientType); err != nil {
-			log.Printf("[Cooldown] Failed to delete cooldown for provider %d, client %s from database: %v", providerID, clientType, err)
-		}
+	if until, ok := m.cooldowns[key]; ok {
+		events = append(events, endedEvent(key, until, m.reasons[key], domain.ProviderEventRecovered))
+		delete(m.cooldowns, key)
+		delete(m.reasons, key)
+		m.dirty[key] = true
 	}
 
 	// Reset failure counts
@@ -170,39 +212,67 @@ func (m *Manager) RecordSuccess(providerID uint64, clientType string) {
 }
 
 // setCooldownLocked sets cooldown without acquiring lock (internal use only)
-func (m *Manager) setCooldownLocked(providerID uint64, clientType string, until time.Time, reason CooldownReason) {
+// Returns the events of the transition, none when an active cooldown is extended
+func (m *Manager) setCooldownLocked(providerID uint64, clientType string, until time.Time, reason CooldownReason) []*domain.ProviderEvent {
 	key := CooldownKey{ProviderID: providerID, ClientType: clientType}
+	var events []*domain.ProviderEvent
+	prev, existed := m.cooldowns[key]
+	if !existed || !prev.After(time.Now()) {
+		if existed {
+			// Expired without being cleaned up yet
+			events = append(events, endedEvent(key, prev, m.reasons[key], domain.ProviderEventRecovered))
+		}
+		cooledUntil := until
+		events = append(events, &domain.ProviderEvent{
+			CreatedAt:  time.Now(),
+			ProviderID: providerID,
+			ClientType: clientType,
+			Type:       domain.ProviderEventCooldownEntered,
+			Reason:     string(reason),
+			UntilTime:  &cooledUntil,
+		})
+	}
 	m.cooldowns[key] = until
 	m.reasons[key] = reason
+	m.dirty[key] = true
+	return events
+}
 
-	// Persist to database
-	if m.repository != nil {
-		cd := &domain.Cooldown{
-			ProviderID: providerID,
-			ClientType: clientType,
-			UntilTime:  until,
-			Reason:     domain.CooldownReason(reason),
-		}
-		if err := m.repository.Upsert(cd); err != nil {
-			log.Printf("[Cooldown] Failed to persist cooldown for provider %d: %v", providerID, err)
-		}
+// endedEvent returns the event of a cooldown ended as eventType now, or recovered at
+// its end time when it already expired
+func endedEvent(key CooldownKey, until time.Time, reason CooldownReason, eventType domain.ProviderEventType) *domain.ProviderEvent {
+	at := time.Now()
+	if !until.After(at) {
+		at = until
+		eventType = domain.ProviderEventRecovered
+	}
+	return &domain.ProviderEvent{
+		CreatedAt:  at,
+		ProviderID: key.ProviderID,
+		ClientType: key.ClientType,
+		Type:       eventType,
+		Reason:     string(reason),
 	}
 }
 
 // SetCooldownDuration sets a cooldown for a provider with a duration from now
 // clientType is optional - empty string means cooldown applies to all client types
 func (m *Manager) SetCooldownDuration(providerID uint64, clientType string, duration time.Duration) {
+	var events []*domain.ProviderEvent
+	defer func() { m.emit(events) }()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	until := time.Now().Add(duration)
-	m.setCooldownLocked(providerID, clientType, until, ReasonUnknown)
+	events = m.setCooldownLocked(providerID, clientType, until, ReasonUnknown)
 }
 
 // ClearCooldown removes the cooldown for a provider
 // If clientType is empty, clears ALL cooldowns for the provider (both global and specific)
 // If clientType is specified, only clears that specific cooldown
 func (m *Manager) ClearCooldown(providerID uint64, clientType string) {
+	var events []*domain.ProviderEvent
+	defer func() { m.emit(events) }()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -215,15 +285,10 @@ func (m *Manager) ClearCooldown(providerID uint64, clientType string) {
 			}
 		}
 		for _, key := range keysToDelete {
+			events = append(events, endedEvent(key, m.cooldowns[key], m.reasons[key], domain.ProviderEventCooldownCleared))
 			delete(m.cooldowns, key)
 			delete(m.reasons, key)
-		}
-
-		// Delete from database
-		if m.repository != nil {
-			if err := m.repository.DeleteAll(providerID); err != nil {
-				log.Printf("[Cooldown] Failed to delete all cooldowns for provider %d from database: %v", providerID, err)
-			}
+			m.dirty[key] = true
 		}
 
 		// Also reset all failure counts for this provider
@@ -231,15 +296,12 @@ func (m *Manager) ClearCooldown(providerID uint64, clientType string) {
 	} else {
 		// Clear specific cooldown
 		key := CooldownKey{ProviderID: providerID, ClientType: clientType}
+		if until, ok := m.cooldowns[key]; ok {
+			events = append(events, endedEvent(key, until, m.reasons[key], domain.ProviderEventCooldownCleared))
+		}
 		delete(m.cooldowns, key)
 		delete(m.reasons, key)
-
-		// Delete from database
-		if m.repository != nil {
-			if err := m.repository.Delete(providerID, clientType); err != nil {
-				log.Printf("[Cooldown] Failed to delete cooldown for provider %d, client %s from database: %v", providerID, clientType, err)
-			}
-		}
+		m.dirty[key] = true
 
 		// Also reset failure counts for this provider+clientType
 		m.failureTracker.ResetFailures(providerID, clientType)
@@ -323,6 +385,8 @@ func (m *Manager) GetAllCooldowns() map[CooldownKey]time.Time {
 // CleanupExpired removes expired cooldowns from memory and database
 // Also resets failure counts for expired cooldowns
 func (m *Manager) CleanupExpired() {
+	var events []*domain.ProviderEvent
+	defer func() { m.emit(events) }()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -331,6 +395,7 @@ func (m *Manager) CleanupExpired() {
 
 	for key, until := range m.cooldowns {
 		if now.After(until) {
+			events = append(events, endedEvent(key, until, m.reasons[key], domain.ProviderEventRecovered))
 			delete(m.cooldowns, key)
 			delete(m.reasons, key)
 			expiredKeys = append(expiredKeys, key)
@@ -462,8 +527,115 @@ func formatInt(i int) string {
 	return string(rune('0' + i/10)) + string(rune('0' + i%10))
 }
 
+// Flush persists cooldowns and failure counts changed since the last flush.
+// Only the snapshot is taken under the lock, so RecordFailure/RecordSuccess never wait on database writes.
+func (m *Manager) Flush() error {
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+
+	m.mu.Lock()
+	repo := m.repository
+	failureRepo := m.failureTracker.repository
+	var upserts []*domain.Cooldown
+	var deletes []CooldownKey
+	for key := range m.dirty {
+		if until, ok := m.cooldowns[key]; ok {
+			upserts = append(upserts, &domain.Cooldown{
+				ProviderID: key.ProviderID,
+				ClientType: key.ClientType,
+				UntilTime:  until,
+				Reason:     domain.CooldownReason(m.reasons[key]),
+			})
+		} else {
+			deletes = append(deletes, key)
+		}
+	}
+	m.dirty = make(map[CooldownKey]bool)
+	fcUpserts, fcDeletes := m.failureTracker.takeDirty()
+	m.mu.Unlock()
+
+	var firstErr error
+	var failed []CooldownKey
+	var failedFC []FailureKey
+	record := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if repo != nil {
+		for _, cd := range upserts {
+			if err := repo.Upsert(cd); err != nil {
+				record(err)
+				failed = append(failed, CooldownKey{ProviderID: cd.ProviderID, ClientType: cd.ClientType})
+			}
+		}
+		for _, key := range deletes {
+			if err := repo.Delete(key.ProviderID, key.ClientType); err != nil {
+				record(err)
+				failed = append(failed, key)
+			}
+		}
+	}
+
+	if failureRepo != nil {
+		for _, fc := range fcUpserts {
+			if err := failureRepo.Upsert(fc); err != nil {
+				record(err)
+				failedFC = append(failedFC, FailureKey{ProviderID: fc.ProviderID, ClientType: fc.ClientType, Reason: CooldownReason(fc.Reason)})
+			}
+		}
+		for _, key := range fcDeletes {
+			if err := failureRepo.Delete(key.ProviderID, key.ClientType, string(key.Reason)); err != nil {
+				record(err)
+				failedFC = append(failedFC, key)
+			}
+		}
+	}
+
+	// Mark failed keys dirty again so the next flush retries them
+	if len(failed) > 0 || len(failedFC) > 0 {
+		m.mu.Lock()
+		for _, key := range failed {
+			m.dirty[key] = true
+		}
+		for _, key := range failedFC {
+			m.failureTracker.dirty[key] = true
+		}
+		m.mu.Unlock()
+	}
+
+	return firstErr
+}
+
+// StartPersistence flushes state periodically, using the interval configured in settings
+func (m *Manager) StartPersistence(settings repository.SystemSettingRepository) {
+	go func() {
+		for {
+			interval := defaultFlushSecs
+			if settings != nil {
+				if val, err := settings.Get(domain.SettingKeyCooldownFlushSecs); err == nil && val != "" {
+					if secs, err := strconv.Atoi(val); err == nil && secs > 0 {
+						interval = secs
+					}
+				}
+			}
+
+			time.Sleep(time.Duration(interval) * time.Second)
+			if err := m.Flush(); err != nil {
+				log.Printf("[Cooldown] Failed to persist cooldown state: %v", err)
+			}
+		}
+	}()
+}
+
 // GetAllCooldownsFromDB returns all active cooldowns from the repository
+// Pending changes are flushed first so the result reflects the in-memory state
 func (m *Manager) GetAllCooldownsFromDB() ([]*domain.Cooldown, error) {
+	if err := m.Flush(); err != nil {
+		log.Printf("[Cooldown] Failed to persist cooldown state: %v", err)
+	}
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	
@@ -0,0 +1,210 @@
+package cooldown
+
+import (
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+type memoryCooldownRepo struct {
+	rows   map[CooldownKey]*domain.Cooldown
+	writes int
+}
+
+func (r *memoryCooldownRepo) GetAll() ([]*domain.Cooldown, error) {
+	var result []*domain.Cooldown
+	for _, cd := range r.rows {
+		copied := *cd
+		result = append(result, &copied)
+	}
+	return result, nil
+}
+
+func (r *memoryCooldownRepo) GetByProvider(providerID uint64) ([]*domain.Cooldown, error) {
+	return nil, nil
+}
+
+func (r *memoryCooldownRepo) Upsert(cd *domain.Cooldown) error {
+	copied := *cd
+	r.rows[CooldownKey{ProviderID: cd.ProviderID, ClientType: cd.ClientType}] = &copied
+	r.writes++
+	return nil
+}
+
+func (r *memoryCooldownRepo) Delete(providerID uint64, clientType string) error {
+	delete(r.rows, CooldownKey{ProviderID: providerID, ClientType: clientType})
+	r.writes++
+	return nil
+}
+
+func (r *memoryCooldownRepo) DeleteAll(providerID uint64) error { return nil }
+
+func (r *memoryCooldownRepo) DeleteExpired() error { return nil }
+
+func (r *memoryCooldownRepo) Get(providerID uint64, clientType string) (*domain.Cooldown, error) {
+	return r.rows[CooldownKey{ProviderID: providerID, ClientType: clientType}], nil
+}
+
+type memoryFailureRepo struct {
+	rows map[FailureKey]*domain.FailureCount
+}
+
+func (r *memoryFailureRepo) Get(providerID uint64, clientType string, reason string) (*domain.FailureCount, error) {
+	return r.rows[FailureKey{ProviderID: providerID, ClientType: clientType, Reason: CooldownReason(reason)}], nil
+}
+
+func (r *memoryFailureRepo) GetAll() ([]*domain.FailureCount, error) {
+	var result []*domain.FailureCount
+	for _, fc := range r.rows {
+		copied := *fc
+		result = append(result, &copied)
+	}
+	return result, nil
+}
+
+func (r *memoryFailureRepo) Upsert(fc *domain.FailureCount) error {
+	copied := *fc
+	r.rows[FailureKey{ProviderID: fc.ProviderID, ClientType: fc.ClientType, Reason: CooldownReason(fc.Reason)}] = &copied
+	return nil
+}
+
+func (r *memoryFailureRepo) Delete(providerID uint64, clientType string, reason string) error {
+	delete(r.rows, FailureKey{ProviderID: providerID, ClientType: clientType, Reason: CooldownReason(reason)})
+	return nil
+}
+
+func (r *memoryFailureRepo) DeleteAll(providerID uint64, clientType string) error { return nil }
+
+func (r *memoryFailureRepo) DeleteExpired(olderThan int64) error { return nil }
+
+func newPersistentManager(cooldowns *memoryCooldownRepo, failures *memoryFailureRepo) *Manager {
+	m := NewManager()
+	m.SetRepository(cooldowns)
+	m.SetFailureCountRepository(failures)
+	return m
+}
+
+func TestFlushRestoresState(t *testing.T) {
+	cooldowns := &memoryCooldownRepo{rows: make(map[CooldownKey]*domain.Cooldown)}
+	failures := &memoryFailureRepo{rows: make(map[FailureKey]*domain.FailureCount)}
+
+	before := newPersistentManager(cooldowns, failures)
+	before.RecordFailure(1, "claude", ReasonServerError, nil)
+	before.RecordFailure(1, "claude", ReasonServerError, nil)
+	until := before.RecordFailure(1, "claude", ReasonServerError, nil)
+	before.RecordFailure(2, "openai", ReasonNetworkError, nil)
+	before.RecordSuccess(2, "openai")
+
+	if cooldowns.writes != 0 {
+		t.Fatalf("RecordFailure/RecordSuccess wrote to the repository before Flush")
+	}
+	if err := before.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// Simulate restart
+	after := newPersistentManager(cooldowns, failures)
+	if err := after.LoadFromDatabase(); err != nil {
+		t.Fatalf("LoadFromDatabase failed: %v", err)
+	}
+
+	if got := after.GetCooldownUntil(1, "claude"); !got.Equal(until) {
+		t.Errorf("restored cooldown until = %v, want %v", got, until)
+	}
+	if got := after.failureTracker.GetFailureCount(1, "claude", ReasonServerError); got != 3 {
+		t.Errorf("restored failure count = %d, want 3", got)
+	}
+	if after.IsInCooldown(2, "openai") || after.failureTracker.GetFailureCount(2, "openai", ReasonNetworkError) != 0 {
+		t.Errorf("state cleared by RecordSuccess was restored")
+	}
+}
+
+func TestFlushIsIncremental(t *testing.T) {
+	cooldowns := &memoryCooldownRepo{rows: make(map[CooldownKey]*domain.Cooldown)}
+	failures := &memoryFailureRepo{rows: make(map[FailureKey]*domain.FailureCount)}
+
+	m := newPersistentManager(cooldowns, failures)
+	m.SetCooldownDuration(1, "", time.Minute)
+	m.SetCooldownDuration(2, "", time.Minute)
+	if err := m.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	writes := cooldowns.writes
+
+	if err := m.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if cooldowns.writes != writes {
+		t.Errorf("Flush without changes wrote %d rows", cooldowns.writes-writes)
+	}
+
+	m.SetCooldownDuration(2, "", 2*time.Minute)
+	if err := m.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if cooldowns.writes != writes+1 {
+		t.Errorf("Flush wrote %d rows, want only the changed one", cooldowns.writes-writes)
+	}
+}
+
+func TestEventsRecordTransitions(t *testing.T) {
+	m := NewManager()
+	var events []*domain.ProviderEvent
+	m.SetEventSink(func(e *domain.ProviderEvent) { events = append(events, e) })
+	types := func() []domain.ProviderEventType {
+		var result []domain.ProviderEventType
+		for _, e := range events {
+			result = append(result, e.Type)
+		}
+		events = nil
+		return result
+	}
+	expect := func(step string, want ...domain.ProviderEventType) {
+		t.Helper()
+		got := types()
+		if len(got) != len(want) {
+			t.Fatalf("%s: events = %v, want %v", step, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s: events = %v, want %v", step, got, want)
+			}
+		}
+	}
+
+	until := m.RecordFailure(1, "claude", ReasonServerError, nil)
+	expect("first failure", domain.ProviderEventCooldownEntered)
+	m.RecordFailure(1, "claude", ReasonServerError, nil)
+	expect("failure while cooling down")
+	m.RecordSuccess(1, "claude")
+	expect("success", domain.ProviderEventRecovered)
+	m.RecordSuccess(1, "claude")
+	expect("success without cooldown")
+
+	m.RecordFailure(1, "claude", ReasonServerError, nil)
+	m.RecordFailure(1, "", ReasonInvalidCredentials, nil)
+	types()
+	m.ClearCooldown(1, "")
+	expect("cleared", domain.ProviderEventCooldownCleared, domain.ProviderEventCooldownCleared)
+
+	// A cooldown that expires on its own recovers at its end time
+	past := time.Now().Add(-time.Minute)
+	m.RecordFailure(2, "", ReasonRateLimit, &past)
+	types()
+	m.CleanupExpired()
+	if len(events) != 1 || events[0].Type != domain.ProviderEventRecovered || !events[0].CreatedAt.Equal(past) {
+		t.Fatalf("expired cooldown events = %+v, want recovered at %v", events, past)
+	}
+	types()
+
+	m.RecordFailure(3, "openai", ReasonQuotaExhausted, &past)
+	m.RecordFailure(3, "openai", ReasonNetworkError, nil)
+	expect("failure after unnoticed expiry", domain.ProviderEventCooldownEntered, domain.ProviderEventRecovered, domain.ProviderEventCooldownEntered)
+
+	m.RecordFailure(4, "gemini", ReasonServerError, nil)
+	e := events[0]
+	if e.ProviderID != 4 || e.ClientType != "gemini" || e.Reason != string(ReasonServerError) || e.UntilTime == nil || e.UntilTime.Before(until) {
+		t.Errorf("entered event = %+v", e)
+	}
+}
@@ -62,12 +62,13 @@ func (p *ExponentialBackoffPolicy) CalculateCooldown(failureCount int) time.Dura
 type CooldownReason string
 
 const (
-	ReasonServerError     CooldownReason = "server_error"          // 5xx errors
-	ReasonNetworkError    CooldownReason = "network_error"         // Connection timeout, DNS failure, etc.
-	ReasonQuotaExhausted  CooldownReason = "quota_exhausted"       // API quota exhausted (fallback when no explicit time)
-	ReasonRateLimit       CooldownReason = "rate_limit_exceeded"   // Rate limit (fallback when no explicit time)
-	ReasonConcurrentLimit CooldownReason = "concurrent_limit"      // Concurrent request limit (fallback when no explicit time)
-	ReasonUnknown         CooldownReason = "unknown"               // Unknown error
+	ReasonServerError        CooldownReason = "server_error"        // 5xx errors
+	ReasonNetworkError       CooldownReason = "network_error"       // Connection timeout, DNS failure, etc.
+	ReasonQuotaExhausted     CooldownReason = "quota_exhausted"     // API quota exhausted (fallback when no explicit time)
+	ReasonRateLimit          CooldownReason = "rate_limit_exceeded" // Rate limit (fallback when no explicit time)
+	ReasonConcurrentLimit    CooldownReason = "concurrent_limit"    // Concurrent request limit (fallback when no explicit time)
+	ReasonUnknown            CooldownReason = "unknown"             // Unknown error
+	ReasonInvalidCredentials CooldownReason = "invalid_credentials" // Token refresh rejected the provider's credentials
 )
 
 // DefaultPolicies returns the default policy configuration
@@ -97,6 +98,10 @@ func DefaultPolicies() map[CooldownReason]CooldownPolicy {
 		ReasonConcurrentLimit: &FixedDurationPolicy{
 			Duration: 5 * time.Second,
 		},
+		// Invalid credentials: fixed 30 minutes, they stay invalid until fixed by hand
+		ReasonInvalidCredentials: &FixedDurationPolicy{
+			Duration: 30 * time.Minute,
+		},
 		// Unknown error: linear increment (5s, 10s, 15s, ... max 5min)
 		ReasonUnknown: &LinearIncrementalPolicy{
 			BaseSeconds: 5,
@@ -0,0 +1,68 @@
+package cooldown
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+// QueueStats is a snapshot of the cooldown queue
+type QueueStats struct {
+	// MaxWaitSeconds is how long requests may wait for a provider to recover, 0 when queuing is off
+	MaxWaitSeconds int `json:"maxWaitSeconds"`
+	// Queued is the number of requests currently waiting
+	Queued int `json:"queued"`
+}
+
+// Queue holds requests that found every provider of their routes cooling down,
+// for at most the configured wait, instead of failing them right away. Queuing
+// is off by default.
+type Queue struct {
+	maxWait atomic.Int64 // time.Duration
+	queued  atomic.Int64
+}
+
+var globalQueue = &Queue{}
+
+// GlobalQueue returns the cooldown queue used by the proxy
+func GlobalQueue() *Queue {
+	return globalQueue
+}
+
+// SetMaxWait sets how long requests may wait, 0 turns queuing off
+func (q *Queue) SetMaxWait(d time.Duration) {
+	q.maxWait.Store(int64(max(d, 0)))
+}
+
+// Enter queues a request. It returns the time the request must give up at and
+// the function to call when it leaves the queue, ok is false when queuing is off.
+func (q *Queue) Enter() (deadline time.Time, leave func(), ok bool) {
+	wait := time.Duration(q.maxWait.Load())
+	if wait <= 0 {
+		return time.Time{}, nil, false
+	}
+	q.queued.Add(1)
+	return time.Now().Add(wait), func() { q.queued.Add(-1) }, true
+}
+
+// Stats returns the queue settings and the number of waiting requests
+func (q *Queue) Stats() QueueStats {
+	return QueueStats{
+		MaxWaitSeconds: int(time.Duration(q.maxWait.Load()) / time.Second),
+		Queued:         int(q.queued.Load()),
+	}
+}
+
+// LoadQueueFromSettings applies the cooldown queue wait setting, unset or invalid values turn queuing off
+func LoadQueueFromSettings(settingRepo repository.SystemSettingRepository) {
+	val, _ := settingRepo.Get(domain.SettingKeyCooldownQueueWait)
+	secs, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil || secs < 0 {
+		secs = 0
+	}
+	globalQueue.SetMaxWait(time.Duration(secs) * time.Second)
+}
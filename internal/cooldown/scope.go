@@ -0,0 +1,85 @@
+package cooldown
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// CooldownScope decides whether a failure cools the whole provider or only the failing client type
+type CooldownScope string
+
+const (
+	ScopeProvider   CooldownScope = "provider"    // Cool all client types of the provider
+	ScopeClientType CooldownScope = "client_type" // Cool only the client type of the failed request
+)
+
+// knownReasons lists the reasons a scope policy may configure
+var knownReasons = map[CooldownReason]bool{
+	ReasonServerError:     true,
+	ReasonNetworkError:    true,
+	ReasonQuotaExhausted:  true,
+	ReasonRateLimit:       true,
+	ReasonConcurrentLimit: true,
+	ReasonUnknown:         true,
+}
+
+// ScopePolicy maps failure reasons to cooldown scopes
+// Reasons not in the policy cool only the failing client type
+type ScopePolicy map[CooldownReason]CooldownScope
+
+// ParseScopePolicy parses a JSON object like {"quota_exhausted":"provider"}
+// An empty value returns an empty policy
+func ParseScopePolicy(value string) (ScopePolicy, error) {
+	policy := ScopePolicy{}
+	if value == "" {
+		return policy, nil
+	}
+	if err := json.Unmarshal([]byte(value), &policy); err != nil {
+		return nil, fmt.Errorf("invalid cooldown scope policy: %w", err)
+	}
+	for reason, scope := range policy {
+		if !knownReasons[reason] {
+			return nil, fmt.Errorf("invalid cooldown scope policy: unknown reason %q", reason)
+		}
+		if scope != ScopeProvider && scope != ScopeClientType {
+			return nil, fmt.Errorf("invalid cooldown scope policy: unknown scope %q for %s", scope, reason)
+		}
+	}
+	return policy, nil
+}
+
+var (
+	scopeMu     sync.RWMutex
+	scopePolicy = ScopePolicy{}
+)
+
+// SetScopePolicy replaces the global scope policy, nil resets it
+func SetScopePolicy(policy ScopePolicy) {
+	if policy == nil {
+		policy = ScopePolicy{}
+	}
+	scopeMu.Lock()
+	defer scopeMu.Unlock()
+	scopePolicy = policy
+}
+
+// LoadScopePolicy parses value and makes it the global scope policy
+func LoadScopePolicy(value string) error {
+	policy, err := ParseScopePolicy(value)
+	if err != nil {
+		return err
+	}
+	SetScopePolicy(policy)
+	return nil
+}
+
+// ScopeFor returns the cooldown scope configured for reason
+func ScopeFor(reason CooldownReason) CooldownScope {
+	scopeMu.RLock()
+	defer scopeMu.RUnlock()
+	if scope, ok := scopePolicy[reason]; ok {
+		return scope
+	}
+	return ScopeClientType
+}
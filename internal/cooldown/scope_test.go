@@ -0,0 +1,41 @@
+package cooldown
+
+import "testing"
+
+func TestParseScopePolicy(t *testing.T) {
+	policy, err := ParseScopePolicy(`{"quota_exhausted":"provider","server_error":"client_type"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy[ReasonQuotaExhausted] != ScopeProvider || policy[ReasonServerError] != ScopeClientType {
+		t.Errorf("policy = %v", policy)
+	}
+
+	if policy, err := ParseScopePolicy(""); err != nil || len(policy) != 0 {
+		t.Errorf("empty value = %v, %v, want empty policy", policy, err)
+	}
+	for _, bad := range []string{`{"quota_exhausted":"global"}`, `{"format_error":"provider"}`, `[]`} {
+		if _, err := ParseScopePolicy(bad); err == nil {
+			t.Errorf("ParseScopePolicy(%s) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestScopeForDefaultsToClientType(t *testing.T) {
+	defer SetScopePolicy(nil)
+
+	if err := LoadScopePolicy(`{"quota_exhausted":"provider"}`); err != nil {
+		t.Fatal(err)
+	}
+	if got := ScopeFor(ReasonQuotaExhausted); got != ScopeProvider {
+		t.Errorf("quota_exhausted scope = %s, want provider", got)
+	}
+	if got := ScopeFor(ReasonRateLimit); got != ScopeClientType {
+		t.Errorf("unconfigured reason scope = %s, want client_type", got)
+	}
+
+	SetScopePolicy(nil)
+	if got := ScopeFor(ReasonQuotaExhausted); got != ScopeClientType {
+		t.Errorf("scope after reset = %s, want client_type", got)
+	}
+}
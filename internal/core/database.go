@@ -1,20 +1,32 @@
 package core
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
 	"time"
 
 	"github.com/awsl-project/maxx/internal/adapter/client"
+	"github.com/awsl-project/maxx/internal/adapter/provider"
+	"github.com/awsl-project/maxx/internal/batch"
 	_ "github.com/awsl-project/maxx/internal/adapter/provider/custom"
+	"github.com/awsl-project/maxx/internal/capture"
 	"github.com/awsl-project/maxx/internal/cooldown"
+	"github.com/awsl-project/maxx/internal/domain"
 	"github.com/awsl-project/maxx/internal/event"
 	"github.com/awsl-project/maxx/internal/executor"
 	"github.com/awsl-project/maxx/internal/handler"
+	"github.com/awsl-project/maxx/internal/limiter"
+	"github.com/awsl-project/maxx/internal/logsink"
+	"github.com/awsl-project/maxx/internal/pii"
+	"github.com/awsl-project/maxx/internal/redaction"
 	"github.com/awsl-project/maxx/internal/repository"
 	"github.com/awsl-project/maxx/internal/repository/cached"
 	"github.com/awsl-project/maxx/internal/repository/sqlite"
+	"github.com/awsl-project/maxx/internal/retrybudget"
 	"github.com/awsl-project/maxx/internal/router"
+	"github.com/awsl-project/maxx/internal/secrets"
 	"github.com/awsl-project/maxx/internal/service"
 	"github.com/awsl-project/maxx/internal/stats"
 	"github.com/awsl-project/maxx/internal/waiter"
@@ -43,6 +55,7 @@ type DatabaseRepos struct {
 	AntigravityQuotaRepo     repository.AntigravityQuotaRepository
 	CooldownRepo             repository.CooldownRepository
 	FailureCountRepo         repository.FailureCountRepository
+	RoundRobinStateRepo      repository.RoundRobinStateRepository
 	CachedProviderRepo        *cached.ProviderRepository
 	CachedRouteRepo          *cached.RouteRepository
 	CachedRetryConfigRepo    *cached.RetryConfigRepository
@@ -55,6 +68,8 @@ type DatabaseRepos struct {
 	CachedModelMappingRepo   *cached.ModelMappingRepository
 	UsageStatsRepo           repository.UsageStatsRepository
 	ResponseModelRepo        repository.ResponseModelRepository
+	MessageBatchRepo         repository.MessageBatchRepository
+	ProviderEventRepo        repository.ProviderEventRepository
 }
 
 // ServerComponents 包含服务器运行所需的所有组件
@@ -89,6 +104,10 @@ func InitializeDatabase(config *DatabaseConfig) (*DatabaseRepos, error) {
 		return nil, err
 	}
 
+	if err := secrets.LoadFromEnv(); err != nil {
+		return nil, err
+	}
+
 	providerRepo := sqlite.NewProviderRepository(db)
 	routeRepo := sqlite.NewRouteRepository(db)
 	projectRepo := sqlite.NewProjectRepository(db)
@@ -101,10 +120,20 @@ func InitializeDatabase(config *DatabaseConfig) (*DatabaseRepos, error) {
 	antigravityQuotaRepo := sqlite.NewAntigravityQuotaRepository(db)
 	cooldownRepo := sqlite.NewCooldownRepository(db)
 	failureCountRepo := sqlite.NewFailureCountRepository(db)
+	roundRobinStateRepo := sqlite.NewRoundRobinStateRepository(db)
 	apiTokenRepo := sqlite.NewAPITokenRepository(db)
 	modelMappingRepo := sqlite.NewModelMappingRepository(db)
 	usageStatsRepo := sqlite.NewUsageStatsRepository(db)
 	responseModelRepo := sqlite.NewResponseModelRepository(db)
+	messageBatchRepo := sqlite.NewMessageBatchRepository(db)
+	providerEventRepo := sqlite.NewProviderEventRepository(db)
+
+	// Encrypt credentials stored in plaintext or with the previous master key
+	if count, err := providerRepo.ReencryptSecrets(); err != nil {
+		return nil, fmt.Errorf("failed to re-encrypt provider credentials: %w", err)
+	} else if count > 0 {
+		log.Printf("[Core] Re-encrypted the credentials of %d providers", count)
+	}
 
 	log.Printf("[Core] Creating cached repositories")
 
@@ -131,6 +160,7 @@ func InitializeDatabase(config *DatabaseConfig) (*DatabaseRepos, error) {
 		AntigravityQuotaRepo:     antigravityQuotaRepo,
 		CooldownRepo:             cooldownRepo,
 		FailureCountRepo:         failureCountRepo,
+		RoundRobinStateRepo:      roundRobinStateRepo,
 		CachedProviderRepo:        cachedProviderRepo,
 		CachedRouteRepo:          cachedRouteRepo,
 		CachedRetryConfigRepo:    cachedRetryConfigRepo,
@@ -143,6 +173,8 @@ func InitializeDatabase(config *DatabaseConfig) (*DatabaseRepos, error) {
 		CachedModelMappingRepo:   cachedModelMappingRepo,
 		UsageStatsRepo:           usageStatsRepo,
 		ResponseModelRepo:        responseModelRepo,
+		MessageBatchRepo:         messageBatchRepo,
+		ProviderEventRepo:        providerEventRepo,
 	}
 
 	log.Printf("[Core] Database initialized successfully")
@@ -164,6 +196,11 @@ func InitializeServerComponents(
 	if err := cooldown.Default().LoadFromDatabase(); err != nil {
 		log.Printf("[Core] Warning: Failed to load cooldowns from database: %v", err)
 	}
+	if raw, err := repos.SettingRepo.Get(domain.SettingKeyCooldownScopes); err == nil {
+		if err := cooldown.LoadScopePolicy(raw); err != nil {
+			log.Printf("[Core] Warning: Failed to load cooldown scope policy: %v", err)
+		}
+	}
 
 	log.Printf("[Core] Marking stale requests as failed")
 	if count, err := repos.ProxyRequestRepo.MarkStaleAsFailed(instanceID); err != nil {
@@ -202,13 +239,27 @@ func InitializeServerComponents(
 		repos.CachedRoutingStrategyRepo,
 		repos.CachedRetryConfigRepo,
 		repos.CachedProjectRepo,
+		repos.RoundRobinStateRepo,
 	)
 
+	log.Printf("[Core] Restoring round-robin state")
+	if err := r.RoundRobin().Load(); err != nil {
+		log.Printf("[Core] Warning: Failed to load round-robin state: %v", err)
+	}
+	r.RoundRobin().StartPersistence(repos.SettingRepo)
+
+	cooldown.Default().StartPersistence(repos.SettingRepo)
+
 	log.Printf("[Core] Initializing provider adapters")
 	if err := r.InitAdapters(); err != nil {
 		log.Printf("[Core] Warning: Failed to initialize adapters: %v", err)
 	}
 
+	if val, err := repos.SettingRepo.Get(domain.SettingKeyProviderWarmup); err == nil && val == "true" {
+		log.Printf("[Core] Warming up provider adapters")
+		go r.WarmupAdapters(context.Background(), 30*time.Second)
+	}
+
 	log.Printf("[Core] Starting cooldown cleanup goroutine")
 	go func() {
 		ticker := time.NewTicker(1 * time.Hour)
@@ -227,10 +278,14 @@ func InitializeServerComponents(
 
 	log.Printf("[Core] Creating WebSocket hub")
 	wsHub := handler.NewWebSocketHub()
+	wsHub.SetSettingRepo(repos.SettingRepo)
 
 	log.Printf("[Core] Creating Wails broadcaster (wraps WebSocket hub)")
 	wailsBroadcaster := event.NewWailsBroadcaster(wsHub)
 
+	log.Printf("[Core] Recording provider lifecycle events")
+	cooldown.Default().SetEventSink(service.NewProviderEventLog(repos.ProviderEventRepo, wailsBroadcaster).Record)
+
 	log.Printf("[Core] Setting up log output to broadcast via WebSocket")
 	logWriter := handler.NewWebSocketLogWriter(wsHub, os.Stdout, logPath)
 	log.SetOutput(logWriter)
@@ -241,6 +296,37 @@ func InitializeServerComponents(
 	log.Printf("[Core] Creating stats aggregator")
 	statsAggregator := stats.NewStatsAggregator(repos.UsageStatsRepo)
 
+	log.Printf("[Core] Loading redaction rules")
+	if raw, err := repos.SettingRepo.Get(domain.SettingKeyRedactionRules); err == nil {
+		if err := redaction.LoadGlobalRules(raw); err != nil {
+			log.Printf("[Core] Warning: Failed to load redaction rules: %v", err)
+		}
+	}
+
+	log.Printf("[Core] Loading PII detection settings")
+	if err := pii.LoadFromSettings(repos.SettingRepo); err != nil {
+		log.Printf("[Core] Warning: Failed to load PII detection patterns: %v", err)
+	}
+
+	log.Printf("[Core] Loading concurrency limit settings")
+	limiter.LoadFromSettings(repos.SettingRepo)
+	cooldown.LoadQueueFromSettings(repos.SettingRepo)
+	provider.LoadSpillThresholdFromSettings(repos.SettingRepo)
+	provider.LoadPoolConfigFromSettings(repos.SettingRepo)
+	provider.LoadTimingCaptureFromSettings(repos.SettingRepo)
+	// An invalid upstream TLS policy must not fall back to weaker defaults
+	if err := provider.LoadTLSPolicyFromSettings(repos.SettingRepo); err != nil {
+		return nil, fmt.Errorf("invalid upstream TLS policy: %w", err)
+	}
+	client.LoadPromptCacheKeySessionFromSettings(repos.SettingRepo)
+	retrybudget.LoadFromSettings(repos.SettingRepo)
+	if err := logsink.LoadFromSettings(repos.SettingRepo); err != nil {
+		log.Printf("[Core] Warning: Failed to configure request log sink: %v", err)
+	}
+	if err := capture.LoadFromSettings(repos.SettingRepo); err != nil {
+		log.Printf("[Core] Warning: Failed to configure request capture: %v", err)
+	}
+
 	log.Printf("[Core] Creating executor")
 	exec := executor.NewExecutor(
 		r,
@@ -248,12 +334,20 @@ func InitializeServerComponents(
 		repos.AttemptRepo,
 		repos.CachedRetryConfigRepo,
 		repos.CachedSessionRepo,
+		repos.CachedProjectRepo,
 		repos.CachedModelMappingRepo,
+		repos.SettingRepo,
 		wailsBroadcaster,
 		projectWaiter,
 		instanceID,
 		statsAggregator,
 	)
+	// Route conditions read the current load and timezone from the executor
+	r.SetConditionSource(exec)
+
+	log.Printf("[Core] Starting Message Batches processor")
+	batchProcessor := batch.NewProcessor(exec, repos.MessageBatchRepo, repos.SettingRepo, wailsBroadcaster)
+	go batchProcessor.Start(context.Background())
 
 	log.Printf("[Core] Creating client adapter")
 	clientAdapter := client.NewAdapter()
@@ -273,9 +367,12 @@ func InitializeServerComponents(
 		repos.CachedModelMappingRepo,
 		repos.UsageStatsRepo,
 		repos.ResponseModelRepo,
+		repos.MessageBatchRepo,
+		repos.ProviderEventRepo,
 		addr,
 		r,
 	)
+	go adminService.DiscoverProvidersOnStartup(context.Background())
 
 	log.Printf("[Core] Creating backup service")
 	backupService := service.NewBackupService(
@@ -289,14 +386,19 @@ func InitializeServerComponents(
 		repos.CachedModelMappingRepo,
 		r,
 	)
+	backupService.SetDatabase(repos.DB)
 
 	log.Printf("[Core] Creating handlers")
 	tokenAuthMiddleware := handler.NewTokenAuthMiddleware(repos.CachedAPITokenRepo, repos.SettingRepo)
 	proxyHandler := handler.NewProxyHandler(clientAdapter, exec, repos.CachedSessionRepo, tokenAuthMiddleware)
-	adminHandler := handler.NewAdminHandler(adminService, backupService, logPath)
+	proxyHandler.SetBatchHandler(handler.NewBatchHandler(repos.MessageBatchRepo, repos.CachedSessionRepo, tokenAuthMiddleware, batchProcessor))
+	proxyHandler.SetProjectRepo(repos.CachedProjectRepo)
+	adminHandler := handler.NewAdminHandler(adminService, backupService, wsHub, logPath)
 	antigravityHandler := handler.NewAntigravityHandler(adminService, repos.AntigravityQuotaRepo, wailsBroadcaster)
 	kiroHandler := handler.NewKiroHandler(adminService)
 	projectProxyHandler := handler.NewProjectProxyHandler(proxyHandler, repos.CachedProjectRepo)
+	adminHandler.SetReplayHandler(handler.NewReplayHandler(proxyHandler, projectProxyHandler))
+	adminHandler.SetRequestCanceller(exec)
 
 	components := &ServerComponents{
 		Router:              r,
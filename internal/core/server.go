@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/awsl-project/maxx/internal/cooldown"
+	"github.com/awsl-project/maxx/internal/domain"
 	"github.com/awsl-project/maxx/internal/handler"
 )
 
@@ -56,9 +58,12 @@ func (s *ManagedServer) setupRoutes() *http.ServeMux {
 	mux.Handle("/api/kiro/", http.StripPrefix("/api", components.KiroHandler))
 
 	mux.Handle("/v1/messages", components.ProxyHandler)
+	mux.Handle("/v1/messages/batches", components.ProxyHandler)
+	mux.Handle("/v1/messages/batches/", components.ProxyHandler)
 	mux.Handle("/v1/chat/completions", components.ProxyHandler)
 	mux.Handle("/responses", components.ProxyHandler)
 	mux.Handle("/v1beta/models/", components.ProxyHandler)
+	mux.Handle("/v1/resume", components.ProxyHandler)
 
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -118,6 +123,13 @@ func (s *ManagedServer) Stop(ctx context.Context) error {
 
 	log.Printf("[Server] Stopping HTTP server on %s", s.config.Addr)
 
+	// 先取消进行中的请求，记录为服务关闭导致的取消，仍连接的客户端会收到取消原因
+	if s.config.Components != nil && s.config.Components.Executor != nil {
+		if n := s.config.Components.Executor.CancelAll(domain.CancelReasonShutdown); n > 0 {
+			log.Printf("[Server] Cancelled %d in-flight requests", n)
+		}
+	}
+
 	// 使用较短的超时时间，超时后强制关闭
 	shutdownCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
@@ -134,6 +146,18 @@ func (s *ManagedServer) Stop(ctx context.Context) error {
 		s.cancel()
 	}
 
+	// 持久化冷却与失败计数，保证重启后恢复准确
+	if err := cooldown.Default().Flush(); err != nil {
+		log.Printf("[Server] Failed to persist cooldown state: %v", err)
+	}
+
+	// 持久化轮询计数，避免重启后轮询位置丢失
+	if components := s.config.Components; components != nil && components.Router != nil {
+		if err := components.Router.RoundRobin().Flush(); err != nil {
+			log.Printf("[Server] Failed to persist round-robin state: %v", err)
+		}
+	}
+
 	s.isRunning = false
 	log.Printf("[Server] Server stopped successfully")
 	return nil
@@ -1,14 +1,22 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"log"
+	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/event"
 	"github.com/awsl-project/maxx/internal/repository"
+	"github.com/awsl-project/maxx/internal/router"
 	"github.com/awsl-project/maxx/internal/service"
+	"github.com/awsl-project/maxx/internal/stats"
 )
 
 const (
@@ -19,8 +27,12 @@ const (
 type BackgroundTaskDeps struct {
 	UsageStats          repository.UsageStatsRepository
 	ProxyRequest        repository.ProxyRequestRepository
+	ProviderEvent       repository.ProviderEventRepository
 	Settings            repository.SystemSettingRepository
 	AntigravityTaskSvc  *service.AntigravityTaskService
+	Router              *router.Router
+	Broadcaster         event.Broadcaster
+	LiveDashboard       *stats.LiveDashboard
 }
 
 // StartBackgroundTasks 启动所有后台任务
@@ -69,17 +81,72 @@ func StartBackgroundTasks(deps BackgroundTaskDeps) {
 		}
 	}()
 
+	// 供应商配额同步（每分钟）- 从 usage_stats 刷新当前周期用量
+	if deps.Router != nil {
+		go func() {
+			time.Sleep(25 * time.Second) // 初始延迟
+			deps.runQuotaSync()
+
+			ticker := time.NewTicker(1 * time.Minute)
+			for range ticker.C {
+				deps.runQuotaSync()
+			}
+		}()
+	}
+
+	// 供应商成本占比检查（每 5 分钟）- 单个供应商占比过高时告警
+	if deps.Router != nil {
+		go func() {
+			time.Sleep(35 * time.Second) // 初始延迟
+			deps.runSpendShareCheck()
+
+			ticker := time.NewTicker(5 * time.Minute)
+			for range ticker.C {
+				deps.runSpendShareCheck()
+			}
+		}()
+	}
+
+	// 统计导出（每分钟）- 推送新定稿的分钟级统计到外部时序存储
+	go func() {
+		exporter := stats.NewExporter(deps.UsageStats, deps.Settings)
+		time.Sleep(40 * time.Second) // 初始延迟
+		deps.runStatsExport(exporter)
+
+		ticker := time.NewTicker(1 * time.Minute)
+		for range ticker.C {
+			deps.runStatsExport(exporter)
+		}
+	}()
+
+	// 每日用量摘要（每分钟检查）- 到达配置的推送时间后推送前一天的摘要
+	go func() {
+		reporter := stats.NewDailyReporter(deps.UsageStats, deps.Settings, deps.providerNames)
+		time.Sleep(45 * time.Second) // 初始延迟
+		deps.runDailyReport(reporter)
+
+		ticker := time.NewTicker(1 * time.Minute)
+		for range ticker.C {
+			deps.runDailyReport(reporter)
+		}
+	}()
+
 	// Antigravity 配额刷新任务（动态间隔）
 	if deps.AntigravityTaskSvc != nil {
 		go deps.runAntigravityQuotaRefresh()
 	}
 
-	log.Println("[Task] Background tasks started (minute:30s, hour:1m, day:5m, cleanup:1h)")
+	log.Println("[Task] Background tasks started (minute:30s, hour:1m, day:5m, quota:1m, spend share:5m, stats export:1m, daily report:1m, cleanup:1h)")
 }
 
-// runMinuteAggregation 分钟级聚合：从原始数据聚合到分钟
+// runMinuteAggregation 分钟级聚合：从原始数据聚合到分钟，之后推送仪表盘增量
 func (d *BackgroundTaskDeps) runMinuteAggregation() {
 	_, _ = d.UsageStats.AggregateMinute()
+	if d.LiveDashboard != nil {
+		if err := d.LiveDashboard.Update(d.configuredTimezone()); err != nil {
+			log.Printf("[Task] Failed to update live dashboard: %v", err)
+		}
+	}
 }
 
 // runHourlyRollup 小时级 Roll-up：分钟 → 小时
@@ -97,6 +164,145 @@ func (d *BackgroundTaskDeps) runDailyRollup() {
 	_, _ = d.UsageStats.RollUp(domain.GranularityDay, domain.GranularityMonth)
 }
 
+// runQuotaSync 配额同步：按 usage_stats 刷新各供应商当前周期用量，达到硬上限时冷却供应商
+func (d *BackgroundTaskDeps) runQuotaSync() {
+	d.Router.Quota().SetDefaultLocation(d.configuredTimezone())
+	err := d.Router.SyncQuotas(d.UsageStats, func(p *domain.Provider, level router.QuotaLevel) {
+		if d.Broadcaster == nil {
+			return
+		}
+		d.Broadcaster.BroadcastMessage("provider_quota", d.Router.Quota().Info(p))
+		if level == router.QuotaExhausted {
+			d.Broadcaster.BroadcastMessage("cooldown_update", map[string]interface{}{
+				"providerID": p.ID,
+			})
+		}
+	})
+	if err != nil {
+		log.Printf("[Task] Failed to sync provider quotas: %v", err)
+	}
+}
+
+// runSpendShareCheck 成本占比检查：单个供应商在窗口内的成本或请求占比超过阈值时，
+// 通过 WebSocket 推送告警，并在配置了 Webhook 时 POST 告警内容
+func (d *BackgroundTaskDeps) runSpendShareCheck() {
+	cfg := d.spendShareConfig()
+	if cfg.Threshold <= 0 {
+		return
+	}
+	alerts, err := d.Router.CheckSpendShare(d.UsageStats, cfg)
+	if err != nil {
+		log.Printf("[Task] Failed to check provider spend share: %v", err)
+		return
+	}
+
+	webhook, _ := d.Settings.Get(domain.SettingKeySpendShareWebhook)
+	webhook = strings.TrimSpace(webhook)
+	for _, alert := range alerts {
+		log.Printf("[Task] Provider %d (%s) took %.1f%% of cost and %.1f%% of requests in the last %d minutes (threshold %.0f%%)",
+			alert.ProviderID, alert.ProviderName, alert.CostShare, alert.RequestShare, alert.WindowMinutes, alert.Threshold)
+		if d.Broadcaster != nil {
+			d.Broadcaster.BroadcastMessage("provider_spend_alert", alert)
+		}
+		if webhook != "" {
+			if err := postAlertWebhook(webhook, alert); err != nil {
+				log.Printf("[Task] Failed to send spend share alert to webhook: %v", err)
+			}
+		}
+	}
+}
+
+// runStatsExport 统计导出：失败时导出器自行退避，下次执行时从上次的进度继续
+func (d *BackgroundTaskDeps) runStatsExport(exporter *stats.Exporter) {
+	exported, err := exporter.Run()
+	if err != nil {
+		log.Printf("[Task] %v", err)
+	}
+	if exported > 0 {
+		log.Printf("[Task] Exported %d usage stats records", exported)
+	}
+}
+
+// runDailyReport 每日摘要：推送失败时推送器自行退避，推送成功后记录日期，不会重复推送
+func (d *BackgroundTaskDeps) runDailyReport(reporter *stats.DailyReporter) {
+	sent, err := reporter.Run(d.configuredTimezone())
+	if err != nil {
+		log.Printf("[Task] %v", err)
+	}
+	if sent {
+		log.Printf("[Task] Sent daily usage report")
+	}
+}
+
+// providerNames 返回各供应商的名称，用于每日摘要
+func (d *BackgroundTaskDeps) providerNames() map[uint64]string {
+	if d.Router == nil {
+		return nil
+	}
+	return d.Router.ProviderNames()
+}
+
+// spendShareConfig 读取成本占比检查的设置
+func (d *BackgroundTaskDeps) spendShareConfig() router.SpendShareConfig {
+	cfg := router.SpendShareConfig{
+		Window:      router.DefaultSpendShareWindow,
+		MinRequests: router.DefaultSpendShareMinRequests,
+	}
+	if val, err := d.Settings.Get(domain.SettingKeySpendShareThreshold); err == nil && val != "" {
+		if threshold, err := strconv.ParseFloat(val, 64); err == nil && threshold > 0 && threshold < 100 {
+			cfg.Threshold = threshold
+		}
+	}
+	if val, err := d.Settings.Get(domain.SettingKeySpendShareWindow); err == nil && val != "" {
+		if minutes, err := strconv.Atoi(val); err == nil && minutes > 0 {
+			cfg.Window = min(time.Duration(minutes)*time.Minute, router.MaxSpendShareWindow)
+		}
+	}
+	if val, err := d.Settings.Get(domain.SettingKeySpendShareMinRequests); err == nil && val != "" {
+		if n, err := strconv.ParseUint(val, 10, 64); err == nil {
+			cfg.MinRequests = n
+		}
+	}
+	return cfg
+}
+
+// postAlertWebhook POST 告警内容（JSON）到 Webhook 地址
+func postAlertWebhook(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// configuredTimezone 读取系统时区设置，默认 Asia/Shanghai，与 usage_stats 的时间桶保持一致
+func (d *BackgroundTaskDeps) configuredTimezone() *time.Location {
+	value, err := d.Settings.Get(domain.SettingKeyTimezone)
+	if err != nil || value == "" {
+		value = "Asia/Shanghai"
+	}
+	loc, err := time.LoadLocation(value)
+	if err != nil {
+		return time.FixedZone("UTC+8", 8*60*60)
+	}
+	return loc
+}
+
 // runCleanupTasks 清理任务：清理过期数据
 func (d *BackgroundTaskDeps) runCleanupTasks() {
 	// 1. 清理过期的分钟数据（保留 1 天）
@@ -109,6 +315,12 @@ func (d *BackgroundTaskDeps) runCleanupTasks() {
 
 	// 3. 清理过期请求记录
 	d.cleanupOldRequests()
+
+	// 4. 清理过期的供应商事件（保留 90 天）
+	if d.ProviderEvent != nil {
+		before = time.Now().AddDate(0, 0, -90)
+		_, _ = d.ProviderEvent.DeleteOlderThan(before)
+	}
 }
 
 // cleanupOldRequests 清理过期的请求记录
@@ -0,0 +1,325 @@
+// Package discovery reads provider credentials from a directory of JSON files,
+// the way the native Kiro and Antigravity tools store them, so many accounts
+// can be onboarded at once.
+package discovery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/awsl-project/maxx/internal/adapter/provider/antigravity"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// ErrUnknownFormat is returned for JSON files that hold no known credential format
+var ErrUnknownFormat = errors.New("no known credential format")
+
+// Credential is a provider credential read from a file
+type Credential struct {
+	// File the credential was read from
+	Source string
+
+	// Provider type: kiro or antigravity
+	Type string
+
+	// Account email, empty when the file doesn't say
+	Email string
+
+	Kiro        *domain.ProviderConfigKiro
+	Antigravity *domain.ProviderConfigAntigravity
+}
+
+// FileError is a credential file that could not be read
+type FileError struct {
+	Source string
+	Err    error
+}
+
+// Scan reads all *.json files of dir (not recursively), in name order.
+// A file may hold one credential or an array of them.
+func Scan(dir string) ([]*Credential, []FileError, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.EqualFold(filepath.Ext(entry.Name()), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var creds []*Credential
+	var fileErrors []FileError
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fileErrors = append(fileErrors, FileError{Source: path, Err: err})
+			continue
+		}
+		parsed, err := Parse(data)
+		if err != nil {
+			fileErrors = append(fileErrors, FileError{Source: path, Err: err})
+			continue
+		}
+		for _, cred := range parsed {
+			cred.Source = path
+		}
+		creds = append(creds, parsed...)
+	}
+	return creds, fileErrors, nil
+}
+
+// Parse reads the credentials of one file. Supported formats:
+//   - Kiro token cache (kiro-auth-token.json): refreshToken with authMethod, provider or profileArn,
+//     IdC logins also carry clientId and clientSecret
+//   - Google OAuth credentials (oauth_creds.json): refresh_token, used as an Antigravity account
+//   - Antigravity Manager accounts: email with token.refresh_token and token.project_id
+//   - maxx provider configs: {"kiro": {...}} or {"antigravity": {...}}
+//
+// A top-level array holds several credentials of any of these formats.
+func Parse(data []byte) ([]*Credential, error) {
+	var raw json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	var list []json.RawMessage
+	if json.Unmarshal(raw, &list) != nil {
+		list = []json.RawMessage{raw}
+	}
+	creds := make([]*Credential, 0, len(list))
+	for i, item := range list {
+		cred, err := parseOne(item)
+		if err != nil {
+			if len(list) > 1 {
+				return nil, fmt.Errorf("entry %d: %w", i, err)
+			}
+			return nil, err
+		}
+		creds = append(creds, cred)
+	}
+	if len(creds) == 0 {
+		return nil, ErrUnknownFormat
+	}
+	return creds, nil
+}
+
+// credentialFile is the union of the fields of all supported formats
+type credentialFile struct {
+	// Kiro token cache
+	RefreshToken string `json:"refreshToken"`
+	AuthMethod   string `json:"authMethod"`
+	Provider     string `json:"provider"`
+	ProfileArn   string `json:"profileArn"`
+	Region       string `json:"region"`
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	Email        string `json:"email"`
+
+	// Google OAuth credentials
+	SnakeRefreshToken string `json:"refresh_token"`
+	ProjectID         string `json:"project_id"`
+
+	// Antigravity Manager account
+	Token *struct {
+		RefreshToken string `json:"refresh_token"`
+		ProjectID    string `json:"project_id"`
+	} `json:"token"`
+
+	// maxx provider config
+	Kiro        *domain.ProviderConfigKiro        `json:"kiro"`
+	Antigravity *domain.ProviderConfigAntigravity `json:"antigravity"`
+}
+
+func parseOne(data json.RawMessage) (*Credential, error) {
+	var f credentialFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, ErrUnknownFormat
+	}
+
+	switch {
+	case f.Kiro != nil && f.Kiro.RefreshToken != "":
+		return newKiro(*f.Kiro)
+	case f.Antigravity != nil && f.Antigravity.RefreshToken != "":
+		return newAntigravity(*f.Antigravity), nil
+	case f.RefreshToken != "" && (f.AuthMethod != "" || f.Provider != "" || f.ProfileArn != "" || f.ClientID != ""):
+		return newKiro(domain.ProviderConfigKiro{
+			AuthMethod:   f.AuthMethod,
+			RefreshToken: f.RefreshToken,
+			Region:       f.Region,
+			ClientID:     f.ClientID,
+			ClientSecret: f.ClientSecret,
+			Email:        f.Email,
+		})
+	case f.Token != nil && f.Token.RefreshToken != "":
+		return newAntigravity(domain.ProviderConfigAntigravity{
+			Email:        f.Email,
+			RefreshToken: f.Token.RefreshToken,
+			ProjectID:    f.Token.ProjectID,
+		}), nil
+	case f.SnakeRefreshToken != "":
+		return newAntigravity(domain.ProviderConfigAntigravity{
+			Email:        f.Email,
+			RefreshToken: f.SnakeRefreshToken,
+			ProjectID:    f.ProjectID,
+		}), nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+func newKiro(config domain.ProviderConfigKiro) (*Credential, error) {
+	// The Kiro IDE writes "IdC" and "social", and leaves authMethod out for social logins
+	switch strings.ToLower(config.AuthMethod) {
+	case "idc":
+		config.AuthMethod = "idc"
+	case "", "social":
+		config.AuthMethod = "social"
+		if config.ClientID != "" && config.ClientSecret != "" {
+			config.AuthMethod = "idc"
+		}
+	default:
+		return nil, fmt.Errorf("unsupported kiro auth method %q", config.AuthMethod)
+	}
+	if config.AuthMethod == "idc" && (config.ClientID == "" || config.ClientSecret == "") {
+		return nil, fmt.Errorf("kiro idc credential requires clientId and clientSecret")
+	}
+	return &Credential{Type: "kiro", Email: config.Email, Kiro: &config}, nil
+}
+
+func newAntigravity(config domain.ProviderConfigAntigravity) *Credential {
+	if config.Endpoint == "" && config.ProjectID != "" {
+		config.Endpoint = antigravity.GenerateDefaultEndpoint(config.ProjectID)
+	}
+	return &Credential{Type: "antigravity", Email: config.Email, Antigravity: &config}
+}
+
+// RefreshToken returns the refresh token of the credential
+func (c *Credential) RefreshToken() string {
+	if c.Kiro != nil {
+		return c.Kiro.RefreshToken
+	}
+	if c.Antigravity != nil {
+		return c.Antigravity.RefreshToken
+	}
+	return ""
+}
+
+// Fingerprint identifies the account of the credential: the email when known,
+// otherwise the refresh token
+func (c *Credential) Fingerprint() string {
+	return Fingerprint(c.Type, c.Email, c.RefreshToken())
+}
+
+// Name is the provider name for the credential: the email, or the file name
+func (c *Credential) Name() string {
+	if c.Email != "" {
+		return c.Email
+	}
+	base := filepath.Base(c.Source)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// Fingerprint identifies an account of a provider type by its email, or by
+// its refresh token when the email is unknown
+func Fingerprint(providerType, email, refreshToken string) string {
+	identity := "token:" + refreshToken
+	if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+		identity = "email:" + email
+	}
+	sum := sha256.Sum256([]byte(providerType + "\n" + identity))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ProviderFingerprints returns the fingerprints an existing provider is known by:
+// the one recorded by discovery, and those of its current email and refresh token
+func ProviderFingerprints(p *domain.Provider) []string {
+	if p.Config == nil {
+		return nil
+	}
+	var email, token string
+	switch {
+	case p.Type == "kiro" && p.Config.Kiro != nil:
+		email, token = p.Config.Kiro.Email, p.Config.Kiro.RefreshToken
+	case p.Type == "antigravity" && p.Config.Antigravity != nil:
+		email, token = p.Config.Antigravity.Email, p.Config.Antigravity.RefreshToken
+	default:
+		return nil
+	}
+
+	var fingerprints []string
+	if p.Config.Discovery != nil && p.Config.Discovery.Fingerprint != "" {
+		fingerprints = append(fingerprints, p.Config.Discovery.Fingerprint)
+	}
+	if email != "" {
+		fingerprints = append(fingerprints, Fingerprint(p.Type, email, token))
+	}
+	if token != "" {
+		fingerprints = append(fingerprints, Fingerprint(p.Type, "", token))
+	}
+	return fingerprints
+}
+
+// Checksum hashes the credential fields of a provider config, the fields
+// discovery writes. A provider whose checksum no longer matches the one
+// recorded by discovery has been edited by hand.
+func Checksum(providerType string, config *domain.ProviderConfig) string {
+	var fields []string
+	switch {
+	case providerType == "kiro" && config.Kiro != nil:
+		k := config.Kiro
+		fields = []string{k.AuthMethod, k.RefreshToken, k.Region, k.ClientID, k.ClientSecret, k.Email}
+	case providerType == "antigravity" && config.Antigravity != nil:
+		a := config.Antigravity
+		fields = []string{a.Email, a.RefreshToken, a.ProjectID, a.Endpoint}
+	}
+	sum := sha256.Sum256([]byte(providerType + "\n" + strings.Join(fields, "\n")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Apply writes the credential fields into config, keeping everything else
+// (model mappings, limits, ...) as it is
+func (c *Credential) Apply(config *domain.ProviderConfig) {
+	switch c.Type {
+	case "kiro":
+		if config.Kiro == nil {
+			config.Kiro = &domain.ProviderConfigKiro{}
+		}
+		config.Kiro.AuthMethod = c.Kiro.AuthMethod
+		config.Kiro.RefreshToken = c.Kiro.RefreshToken
+		if c.Kiro.Region != "" {
+			config.Kiro.Region = c.Kiro.Region
+		}
+		config.Kiro.ClientID = c.Kiro.ClientID
+		config.Kiro.ClientSecret = c.Kiro.ClientSecret
+		if c.Kiro.Email != "" {
+			config.Kiro.Email = c.Kiro.Email
+		}
+	case "antigravity":
+		if config.Antigravity == nil {
+			config.Antigravity = &domain.ProviderConfigAntigravity{}
+		}
+		config.Antigravity.RefreshToken = c.Antigravity.RefreshToken
+		if c.Antigravity.Email != "" {
+			config.Antigravity.Email = c.Antigravity.Email
+		}
+		if c.Antigravity.ProjectID != "" {
+			config.Antigravity.ProjectID = c.Antigravity.ProjectID
+			config.Antigravity.Endpoint = c.Antigravity.Endpoint
+		}
+	}
+	config.Discovery = &domain.ProviderDiscovery{
+		Fingerprint: c.Fingerprint(),
+		Source:      c.Source,
+		Checksum:    Checksum(c.Type, config),
+	}
+}
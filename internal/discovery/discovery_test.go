@@ -0,0 +1,166 @@
+package discovery
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestParseFormats(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		check func(t *testing.T, c *Credential)
+	}{
+		{
+			name: "kiro social token cache",
+			data: `{"accessToken":"at","refreshToken":"rt-social","expiresAt":"2026-01-01T00:00:00Z","authMethod":"social","provider":"Github"}`,
+			check: func(t *testing.T, c *Credential) {
+				if c.Type != "kiro" || c.Kiro.AuthMethod != "social" || c.Kiro.RefreshToken != "rt-social" {
+					t.Errorf("credential = %+v %+v", c, c.Kiro)
+				}
+			},
+		},
+		{
+			name: "kiro idc token cache",
+			data: `{"refreshToken":"rt-idc","authMethod":"IdC","region":"eu-west-1","clientId":"cid","clientSecret":"secret"}`,
+			check: func(t *testing.T, c *Credential) {
+				k := c.Kiro
+				if c.Type != "kiro" || k.AuthMethod != "idc" || k.Region != "eu-west-1" || k.ClientID != "cid" || k.ClientSecret != "secret" {
+					t.Errorf("credential = %+v", k)
+				}
+			},
+		},
+		{
+			name: "google oauth credentials",
+			data: `{"access_token":"at","refresh_token":"1//rt","scope":"openid","token_type":"Bearer","expiry_date":1760000000000}`,
+			check: func(t *testing.T, c *Credential) {
+				if c.Type != "antigravity" || c.Antigravity.RefreshToken != "1//rt" || c.Email != "" {
+					t.Errorf("credential = %+v %+v", c, c.Antigravity)
+				}
+			},
+		},
+		{
+			name: "antigravity manager account",
+			data: `{"id":"a1","email":"Dev@Example.com","token":{"access_token":"at","refresh_token":"1//rt2","project_id":"proj-1"}}`,
+			check: func(t *testing.T, c *Credential) {
+				a := c.Antigravity
+				if c.Type != "antigravity" || c.Email != "Dev@Example.com" || a.RefreshToken != "1//rt2" || a.ProjectID != "proj-1" || a.Endpoint == "" {
+					t.Errorf("credential = %+v", a)
+				}
+			},
+		},
+		{
+			name: "maxx provider config",
+			data: `{"kiro":{"authMethod":"social","refreshToken":"rt3","email":"k@example.com"}}`,
+			check: func(t *testing.T, c *Credential) {
+				if c.Type != "kiro" || c.Email != "k@example.com" || c.Name() != "k@example.com" {
+					t.Errorf("credential = %+v", c)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds, err := Parse([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(creds) != 1 {
+				t.Fatalf("got %d credentials, want 1", len(creds))
+			}
+			tt.check(t, creds[0])
+		})
+	}
+}
+
+func TestParseArrayAndErrors(t *testing.T) {
+	creds, err := Parse([]byte(`[{"refreshToken":"a","authMethod":"social"},{"email":"x@example.com","token":{"refresh_token":"b"}}]`))
+	if err != nil || len(creds) != 2 || creds[0].Type != "kiro" || creds[1].Type != "antigravity" {
+		t.Fatalf("array = %v, %v", creds, err)
+	}
+
+	if _, err := Parse([]byte(`{"foo":"bar"}`)); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("unknown format err = %v", err)
+	}
+	if _, err := Parse([]byte(`not json`)); err == nil {
+		t.Error("invalid JSON accepted")
+	}
+	if _, err := Parse([]byte(`{"refreshToken":"a","authMethod":"IdC"}`)); err == nil {
+		t.Error("idc credential without client accepted")
+	}
+}
+
+func TestFingerprintDedupesAccounts(t *testing.T) {
+	byEmail := Fingerprint("antigravity", "Dev@Example.com", "token-1")
+	if byEmail != Fingerprint("antigravity", "dev@example.com ", "token-2") {
+		t.Error("same email with a rotated token has a different fingerprint")
+	}
+	if byEmail == Fingerprint("kiro", "dev@example.com", "token-1") {
+		t.Error("same email on another provider type has the same fingerprint")
+	}
+	if Fingerprint("kiro", "", "a") == Fingerprint("kiro", "", "b") {
+		t.Error("different tokens without email have the same fingerprint")
+	}
+
+	p := &domain.Provider{Type: "antigravity", Config: &domain.ProviderConfig{
+		Antigravity: &domain.ProviderConfigAntigravity{Email: "dev@example.com", RefreshToken: "token-1"},
+	}}
+	found := false
+	for _, fp := range ProviderFingerprints(p) {
+		found = found || fp == byEmail
+	}
+	if !found {
+		t.Error("existing provider is not found by the credential fingerprint")
+	}
+}
+
+func TestApplyRecordsChecksum(t *testing.T) {
+	creds, err := Parse([]byte(`{"refreshToken":"rt","authMethod":"social","email":"k@example.com"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &domain.ProviderConfig{Kiro: &domain.ProviderConfigKiro{ModelMapping: map[string]string{"a": "b"}}}
+	creds[0].Apply(config)
+
+	if config.Kiro.RefreshToken != "rt" || config.Kiro.ModelMapping["a"] != "b" {
+		t.Errorf("config = %+v", config.Kiro)
+	}
+	if config.Discovery == nil || config.Discovery.Checksum != Checksum("kiro", config) {
+		t.Fatalf("discovery = %+v", config.Discovery)
+	}
+	config.Kiro.RefreshToken = "edited"
+	if config.Discovery.Checksum == Checksum("kiro", config) {
+		t.Error("checksum unchanged after a manual edit")
+	}
+}
+
+func TestScanSkipsNonJSONAndReportsBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"b.json":    `{"refresh_token":"rt"}`,
+		"a.json":    `{"refreshToken":"rt","authMethod":"social"}`,
+		"bad.json":  `{}`,
+		"notes.txt": `{"refresh_token":"ignored"}`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	creds, fileErrors, err := Scan(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(creds) != 2 || filepath.Base(creds[0].Source) != "a.json" || creds[1].Name() != "b" {
+		t.Errorf("credentials = %+v", creds)
+	}
+	if len(fileErrors) != 1 || filepath.Base(fileErrors[0].Source) != "bad.json" {
+		t.Errorf("file errors = %+v", fileErrors)
+	}
+}
@@ -42,9 +42,14 @@ type BackupProvider struct {
 
 // BackupProject represents a project for backup (using slug as identifier)
 type BackupProject struct {
-	Name                string       `json:"name"`
-	Slug                string       `json:"slug"`
-	EnabledCustomRoutes []ClientType `json:"enabledCustomRoutes,omitempty"`
+	Name                string                  `json:"name"`
+	Slug                string                  `json:"slug"`
+	EnabledCustomRoutes []ClientType            `json:"enabledCustomRoutes,omitempty"`
+	Guardrail           *GuardrailConfig        `json:"guardrail,omitempty"`
+	DefaultClientType   ClientType              `json:"defaultClientType,omitempty"`
+	CostPreference      CostPreference          `json:"costPreference,omitempty"`
+	FallbackResponse    *FallbackResponseConfig `json:"fallbackResponse,omitempty"`
+	DefaultModel        string                  `json:"defaultModel,omitempty"`
 }
 
 // BackupRetryConfig represents a retry config for backup
@@ -59,13 +64,21 @@ type BackupRetryConfig struct {
 
 // BackupRoute represents a route for backup (using names instead of IDs)
 type BackupRoute struct {
-	IsEnabled       bool       `json:"isEnabled"`
-	IsNative        bool       `json:"isNative"`
-	ProjectSlug     string     `json:"projectSlug"`     // empty = global
-	ClientType      ClientType `json:"clientType"`
-	ProviderName    string     `json:"providerName"`
-	Position        int        `json:"position"`
-	RetryConfigName string     `json:"retryConfigName"` // empty = default
+	IsEnabled        bool                    `json:"isEnabled"`
+	IsNative         bool                    `json:"isNative"`
+	ProjectSlug      string                  `json:"projectSlug"` // empty = global
+	ClientType       ClientType              `json:"clientType"`
+	ProviderName     string                  `json:"providerName"`
+	Position         int                     `json:"position"`
+	RetryConfigName  string                  `json:"retryConfigName"` // empty = default
+	HedgeDelayMs     int                     `json:"hedgeDelayMs,omitempty"`
+	FailFast         bool                    `json:"failFast,omitempty"`
+	EnsembleSize     int                     `json:"ensembleSize,omitempty"`
+	EnsembleStrategy string                  `json:"ensembleStrategy,omitempty"`
+	APITokenNames    []string                `json:"apiTokenNames,omitempty"` // tokens the route is pinned to, empty = any
+	Conditions       *RouteConditions        `json:"conditions,omitempty"`
+	FallbackResponse *FallbackResponseConfig `json:"fallbackResponse,omitempty"`
+	DefaultModel     string                  `json:"defaultModel,omitempty"`
 }
 
 // BackupRoutingStrategy represents a routing strategy for backup
@@ -82,6 +95,8 @@ type BackupAPIToken struct {
 	ProjectSlug string     `json:"projectSlug"` // empty = global
 	IsEnabled   bool       `json:"isEnabled"`
 	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+
+	DefaultClientType ClientType `json:"defaultClientType,omitempty"`
 }
 
 // BackupModelMapping represents a model mapping for backup
@@ -95,13 +110,41 @@ type BackupModelMapping struct {
 	APITokenName string            `json:"apiTokenName,omitempty"` // instead of APITokenID
 	Pattern      string            `json:"pattern"`
 	Target       string            `json:"target"`
-	Priority     int               `json:"priority"`
+	// Weighted targets, see ModelMapping
+	Targets       []ModelMappingTarget `json:"targets,omitempty"`
+	StickySession bool                 `json:"stickySession,omitempty"`
+	Priority      int                  `json:"priority"`
 }
 
 // ImportOptions defines options for import operation
 type ImportOptions struct {
 	ConflictStrategy string `json:"conflictStrategy"` // "skip", "overwrite", "error"
-	DryRun           bool   `json:"dryRun"`
+	// How imported model mappings that conflict with existing ones are resolved,
+	// empty follows ConflictStrategy
+	MappingConflictStrategy string `json:"mappingConflictStrategy,omitempty"` // "incoming", "existing", "error"
+	DryRun                  bool   `json:"dryRun"`
+}
+
+// Model mapping conflict strategies, see ImportOptions.MappingConflictStrategy
+const (
+	MappingConflictIncoming = "incoming" // the imported mapping replaces the existing ones it conflicts with
+	MappingConflictExisting = "existing" // the imported mapping is skipped
+	MappingConflictError    = "error"    // the import is refused
+)
+
+// MappingConflicts returns the strategy for conflicting model mappings: the explicit
+// one, otherwise incoming for "overwrite", error for "error" and existing for "skip"
+func (o ImportOptions) MappingConflicts() string {
+	if o.MappingConflictStrategy != "" {
+		return o.MappingConflictStrategy
+	}
+	switch o.ConflictStrategy {
+	case "overwrite":
+		return MappingConflictIncoming
+	case "error":
+		return MappingConflictError
+	}
+	return MappingConflictExisting
 }
 
 // ImportSummary contains counts for a single entity type
@@ -128,3 +171,35 @@ func NewImportResult() *ImportResult {
 		Warnings: []string{},
 	}
 }
+
+// Import plan actions
+const (
+	ImportActionCreate   = "create"
+	ImportActionUpdate   = "update"
+	ImportActionSkip     = "skip"
+	ImportActionConflict = "conflict"
+	ImportActionError    = "error"
+)
+
+// ImportPlanItem describes what an import would do with a single entity
+type ImportPlanItem struct {
+	Kind    string `json:"kind"` // providers, projects, routes, ...
+	Name    string `json:"name"`
+	Action  string `json:"action"`
+	Message string `json:"message,omitempty"`
+}
+
+// ImportPlan is the validation report produced before an import is applied
+type ImportPlan struct {
+	Valid       bool             `json:"valid"`       // no conflict or error items
+	Fingerprint string           `json:"fingerprint"` // must be echoed back to apply this exact plan
+	Summary     map[string]int   `json:"summary"`     // action -> count
+	Items       []ImportPlanItem `json:"items"`
+}
+
+// ConfigImportResult is returned by the plan-then-apply config import
+type ConfigImportResult struct {
+	Applied bool          `json:"applied"`
+	Plan    *ImportPlan   `json:"plan"`
+	Result  *ImportResult `json:"result,omitempty"`
+}
@@ -12,6 +12,7 @@ const (
 	CooldownReasonRateLimitExceeded  CooldownReason = "rate_limit_exceeded"
 	CooldownReasonConcurrentLimit    CooldownReason = "concurrent_limit"
 	CooldownReasonUnknown            CooldownReason = "unknown"
+	CooldownReasonInvalidCredentials CooldownReason = "invalid_credentials"
 )
 
 // Cooldown represents a provider cooldown record
@@ -0,0 +1,47 @@
+package domain
+
+// ProviderDiscovery 通过凭证目录自动发现创建或更新的供应商的来源信息
+type ProviderDiscovery struct {
+	// 凭证指纹（供应商类型 + 账号），用于去重
+	Fingerprint string `json:"fingerprint"`
+
+	// 凭证文件路径
+	Source string `json:"source"`
+
+	// 上次自动发现写入的凭证字段的校验和，与当前配置不一致说明之后被手动修改过
+	Checksum string `json:"checksum"`
+}
+
+// 凭证自动发现对单个凭证的处理结果
+const (
+	DiscoveryActionCreate    = "create"    // 新建供应商
+	DiscoveryActionUpdate    = "update"    // 更新已有供应商的凭证
+	DiscoveryActionUnchanged = "unchanged" // 凭证与已有供应商一致
+	DiscoveryActionSkip      = "skip"      // 已有供应商被手动修改过，需确认覆盖
+	DiscoveryActionInvalid   = "invalid"   // 凭证验证失败
+	DiscoveryActionError     = "error"     // 文件无法解析或保存失败
+)
+
+// DiscoveryOptions 凭证自动发现的选项
+type DiscoveryOptions struct {
+	// 覆盖手动创建或手动修改过的供应商的凭证
+	Overwrite bool `json:"overwrite"`
+}
+
+// DiscoveryItem 单个凭证的处理结果
+type DiscoveryItem struct {
+	Source      string `json:"source"`
+	Type        string `json:"type,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Action      string `json:"action"`
+	ProviderID  uint64 `json:"providerID,omitempty"`
+	Message     string `json:"message,omitempty"`
+}
+
+// DiscoveryReport 一次凭证自动发现的结果
+type DiscoveryReport struct {
+	Dir     string          `json:"dir"`
+	Summary map[string]int  `json:"summary"` // action -> count
+	Items   []DiscoveryItem `json:"items"`
+}
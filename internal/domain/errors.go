@@ -18,6 +18,12 @@ var (
     ErrUpstreamError     = errors.New("upstream error")
     ErrFormatConversion  = errors.New("format conversion error")
     ErrUnsupportedFormat = errors.New("unsupported format")
+    ErrPolicyViolation   = errors.New("policy violation")
+    ErrGuardrailFailed   = errors.New("guardrail check failed")
+    ErrEmptyResponse     = errors.New("empty response")
+    ErrInvalidCredentials = errors.New("invalid credentials")
+    ErrTokenRoutesDenied  = errors.New("API token is not allowed on any matching route")
+    ErrMissingModel       = errors.New("request is missing the model")
 )
 
 // ProxyError represents an error during proxy execution
@@ -33,6 +39,8 @@ type ProxyError struct {
     IsServerError      bool          // True for 5xx errors (triggers incremental cooldown)
     IsNetworkError     bool          // True for network errors (connection timeout, DNS failure, etc.)
     HTTPStatusCode     int           // HTTP status code (for logging and error handling)
+    ClientStatusCode   int           // Status answered to the client once all routes failed (status mapping), 0 = 502
+    RouteFailures      []RouteFailure // Per-route failure summary once all routes failed, filtered for the client
 }
 
 // RateLimitInfo contains detailed rate limit information from providers
@@ -0,0 +1,82 @@
+package domain
+
+import "time"
+
+// Message Batch 处理状态（与 Anthropic Message Batches API 一致）
+const (
+	MessageBatchInProgress = "in_progress"
+	MessageBatchCanceling  = "canceling"
+	MessageBatchEnded      = "ended"
+)
+
+// Message Batch 中单个请求的状态
+// pending/processing 为未完成状态，其余为 Anthropic 定义的结果类型
+const (
+	MessageBatchItemPending    = "pending"
+	MessageBatchItemProcessing = "processing"
+	MessageBatchItemSucceeded  = "succeeded"
+	MessageBatchItemErrored    = "errored"
+	MessageBatchItemCanceled   = "canceled"
+	MessageBatchItemExpired    = "expired"
+)
+
+// MessageBatchExpiry 批次创建后未处理完的请求在此时长后过期
+const MessageBatchExpiry = 24 * time.Hour
+
+// MessageBatch 通过 /v1/messages/batches 提交的批量请求，逐条交给 Executor 异步处理
+type MessageBatch struct {
+	ID        uint64    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// 对外暴露的批次 ID，格式为 msgbatch_xxx
+	BatchID string `json:"batchID"`
+
+	// in_progress, canceling, ended
+	ProcessingStatus string `json:"processingStatus"`
+
+	// 提交批次时使用的 API Token 和项目，0 表示无
+	APITokenID uint64 `json:"apiTokenID"`
+	ProjectID  uint64 `json:"projectID"`
+
+	// 提交时的 anthropic-version / anthropic-beta 等请求头，处理每条请求时原样带上
+	RequestHeaders map[string]string `json:"requestHeaders,omitempty"`
+
+	// 各状态的请求数量
+	RequestCounts MessageBatchCounts `json:"requestCounts"`
+
+	ExpiresAt         time.Time  `json:"expiresAt"`
+	CancelInitiatedAt *time.Time `json:"cancelInitiatedAt,omitempty"`
+	EndedAt           *time.Time `json:"endedAt,omitempty"`
+}
+
+// MessageBatchCounts 批次内各状态的请求数量，pending 和 processing 都计入 Processing
+type MessageBatchCounts struct {
+	Processing uint64 `json:"processing"`
+	Succeeded  uint64 `json:"succeeded"`
+	Errored    uint64 `json:"errored"`
+	Canceled   uint64 `json:"canceled"`
+	Expired    uint64 `json:"expired"`
+}
+
+// MessageBatchItem 批次中的单条请求
+type MessageBatchItem struct {
+	ID        uint64    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// 所属批次（MessageBatch.ID）
+	MessageBatchID uint64 `json:"messageBatchID"`
+
+	// 客户端指定的请求 ID，批次内唯一
+	CustomID string `json:"customID"`
+
+	// Messages API 请求参数（JSON）
+	Params string `json:"params"`
+
+	// pending, processing, succeeded, errored, canceled, expired
+	Status string `json:"status"`
+
+	// 处理结果（JSON）：成功时为 Message，失败时为 error 对象
+	Result string `json:"result,omitempty"`
+}
@@ -24,6 +24,32 @@ type ProviderConfigCustom struct {
 
 	// Model 映射: RequestModel → MappedModel
 	ModelMapping map[string]string `json:"modelMapping,omitempty"`
+
+	// 上游请求签名（可选），为空时使用 APIKey 请求头认证
+	Signing *ProviderConfigCustomSigning `json:"signing,omitempty"`
+
+	// 上游以 AWS EventStream 格式返回响应（如 Bedrock 流式接口），转换为客户端期望的 SSE/JSON
+	EventStream bool `json:"eventStream,omitempty"`
+}
+
+// 上游请求签名方式
+const (
+	// AWS Signature Version 4，用于 Bedrock 等 AWS 托管的上游
+	CustomSigningSigV4 = "sigv4"
+)
+
+type ProviderConfigCustomSigning struct {
+	// 签名方式: "sigv4"
+	Mode string `json:"mode"`
+
+	// SigV4 区域和服务名，如 "us-east-1"、"bedrock"
+	Region  string `json:"region"`
+	Service string `json:"service"`
+
+	// AWS 凭证，SessionToken 仅临时凭证需要
+	AccessKeyID     string `json:"accessKeyID"`
+	SecretAccessKey string `json:"secretAccessKey"`
+	SessionToken    string `json:"sessionToken,omitempty"`
 }
 
 type ProviderConfigAntigravity struct {
@@ -45,6 +71,25 @@ type ProviderConfigAntigravity struct {
 	// Haiku 模型映射目标 (默认 "gemini-2.5-flash-lite" 省钱，可选 "claude-sonnet-4-5" 更强)
 	// 空值使用默认 gemini-2.5-flash-lite
 	HaikuTarget string `json:"haikuTarget,omitempty"`
+
+	// 系统提示词策略: "merge"（默认，注入 Antigravity 身份并保留客户端提示词）、
+	// "keep"（保留客户端提示词，不注入身份）、"replace"（丢弃客户端提示词，仅使用身份）
+	SystemPromptPolicy string `json:"systemPromptPolicy,omitempty"`
+
+	// 是否移除客户端系统提示词中已知会引发问题的指令（如自带的 [SYSTEM_PROMPT_END] 标记）
+	StripProblematicInstructions bool `json:"stripProblematicInstructions,omitempty"`
+
+	// 是否从返回给客户端的响应中移除上游回显的注入内容（Antigravity 身份、[SYSTEM_PROMPT_END] 标记）
+	StripResponseArtifacts bool `json:"stripResponseArtifacts,omitempty"`
+
+	// 思考签名无效时的处理方式: "filter"（默认，丢弃或降级无效的思考块，保持思考模式）、
+	// "disable"（移除全部思考内容，以非思考模式发送请求）
+	ThinkingSignatureFallback string `json:"thinkingSignatureFallback,omitempty"`
+
+	// 工具调用签名缓存的作用域: "global"（默认，按工具 ID 缓存）、
+	// "session"（按会话隔离，不同会话中相同的工具 ID 互不影响）
+	// 同一会话内重复使用的工具 ID 始终按出现次序区分
+	ToolSignatureScope string `json:"toolSignatureScope,omitempty"`
 }
 
 type ProviderConfigKiro struct {
@@ -70,6 +115,263 @@ type ProviderConfig struct {
 	Custom      *ProviderConfigCustom      `json:"custom,omitempty"`
 	Antigravity *ProviderConfigAntigravity `json:"antigravity,omitempty"`
 	Kiro        *ProviderConfigKiro        `json:"kiro,omitempty"`
+
+	// 该供应商专属的脱敏规则，与全局规则叠加生效
+	RedactionRules []RedactionRule `json:"redactionRules,omitempty"`
+
+	// 每分钟 token 上限，用于 tpm 路由策略，0 表示不限制
+	TPMLimit int64 `json:"tpmLimit,omitempty"`
+
+	// 最大输出 tokens 上限，请求中的 max_tokens / maxOutputTokens 超过时会被下调，0 表示不限制
+	MaxOutputTokens int `json:"maxOutputTokens,omitempty"`
+
+	// 根据历史延迟自适应调整请求超时，nil 表示不启用
+	AdaptiveTimeout *AdaptiveTimeoutConfig `json:"adaptiveTimeout,omitempty"`
+
+	// 按周期统计的用量配额，nil 表示不限制
+	Quota *ProviderQuotaConfig `json:"quota,omitempty"`
+
+	// 相邻两次上游调用的最小间隔（毫秒），超出频率的请求排队等待而不是突发，0 表示不限制
+	MinIntervalMs int `json:"minIntervalMs,omitempty"`
+
+	// 上游不接受的请求参数（如 prompt_cache_key、safety_identifier），转发前移除并记录在尝试上
+	UnsupportedParams []string `json:"unsupportedParams,omitempty"`
+
+	// 按上游错误内容判定可重试的规则，命中时原本不可重试的错误也会重试
+	RetryRules []RetryRule `json:"retryRules,omitempty"`
+
+	// 强制使用 HTTP/1.1 请求上游，用于绕过对 HTTP/2 支持有问题的上游或中间代理
+	ForceHTTP1 bool `json:"forceHTTP1,omitempty"`
+
+	// 客户端响应状态码映射（如 529→503），优先于全局映射，尝试记录保留原始状态码
+	StatusMapping StatusMapping `json:"statusMapping,omitempty"`
+
+	// 通过凭证目录自动发现创建的供应商的来源信息，nil 表示手动创建
+	Discovery *ProviderDiscovery `json:"discovery,omitempty"`
+
+	// 成本档位，用于项目的成本偏好排序；为空时按价格表中请求模型的输入价格推断
+	CostTier CostTier `json:"costTier,omitempty"`
+
+	// WASM 转换插件，转发前改写请求体、返回前改写非流式响应体，nil 表示不启用
+	TransformPlugin *TransformPluginConfig `json:"transformPlugin,omitempty"`
+
+	// 上游 Retry-After 或配额重置时间所要求冷却的上限（秒），0 使用默认上限（30 分钟），-1 表示不设上限
+	MaxCooldownSecs int `json:"maxCooldownSecs,omitempty"`
+}
+
+// TransformPluginConfig 供应商的 WASM 转换插件，用于适配非标准的上游请求/响应格式
+// 插件在沙箱中运行，没有文件系统和网络访问；调用失败、超时或超出内存上限时原样透传
+type TransformPluginConfig struct {
+	// .wasm 文件路径，文件变化后自动重新加载
+	Path string `json:"path"`
+
+	// 单次调用的超时（毫秒），0 使用默认值 100
+	TimeoutMs int `json:"timeoutMs,omitempty"`
+
+	// 插件内存上限（MB），0 使用默认值 16
+	MemoryLimitMB int `json:"memoryLimitMB,omitempty"`
+}
+
+// RetryRule 上游错误重试规则，匹配错误信息和上游响应体
+// 认证失败、资源不存在、请求过大等客户端错误不受规则影响
+type RetryRule struct {
+	// contains（默认，不区分大小写的子串匹配）或 regex
+	Type string `json:"type,omitempty"`
+
+	// 子串或正则表达式
+	Pattern string `json:"pattern"`
+
+	// 限定生效的上游 HTTP 状态码，空表示任意状态码（流中的错误事件状态码为 200）
+	StatusCodes []int `json:"statusCodes,omitempty"`
+}
+
+const (
+	RetryRuleTypeContains = "contains" // 不区分大小写的子串匹配
+	RetryRuleTypeRegex    = "regex"    // 正则匹配
+)
+
+// AdaptiveTimeoutConfig 自适应超时配置
+// 超时 = clamp(Multiplier × 近期成功请求耗时的 p99, FloorSeconds, CeilingSeconds)
+// 样本不足时使用 CeilingSeconds
+type AdaptiveTimeoutConfig struct {
+	// 超时下限（秒），0 表示默认 60
+	FloorSeconds int `json:"floorSeconds,omitempty"`
+
+	// 超时上限（秒），0 表示默认 600
+	CeilingSeconds int `json:"ceilingSeconds,omitempty"`
+
+	// p99 的倍数，0 表示默认 3
+	Multiplier float64 `json:"multiplier,omitempty"`
+}
+
+// AdaptiveTimeoutInfo 供应商当前的自适应超时，用于诊断
+type AdaptiveTimeoutInfo struct {
+	ProviderID   uint64 `json:"providerID"`
+	ProviderName string `json:"providerName"`
+
+	// 是否启用了自适应超时
+	Enabled bool `json:"enabled"`
+
+	// 统计窗口内的样本数和 p99 耗时（毫秒）
+	Samples int   `json:"samples"`
+	P99Ms   int64 `json:"p99Ms"`
+
+	// 当前生效的超时（毫秒），未启用时为 0
+	TimeoutMs int64 `json:"timeoutMs"`
+}
+
+// ProviderPacingInfo 供应商当前的调用节奏，用于诊断
+type ProviderPacingInfo struct {
+	ProviderID   uint64 `json:"providerID"`
+	ProviderName string `json:"providerName"`
+
+	// 配置的最小调用间隔（毫秒）
+	MinIntervalMs int `json:"minIntervalMs"`
+
+	// 新请求当前需要等待的时间（毫秒）
+	DelayMs int64 `json:"delayMs"`
+
+	// 正在排队等待的请求数
+	Waiting int `json:"waiting"`
+}
+
+// ProviderConnectionInfo 供应商上游连接池的复用情况，用于诊断连接抖动
+type ProviderConnectionInfo struct {
+	ProviderID   uint64 `json:"providerID"`
+	ProviderName string `json:"providerName"`
+
+	// 启动以来发往上游的请求数
+	Requests int64 `json:"requests"`
+
+	// 复用空闲连接的请求数
+	Reused int64 `json:"reused"`
+
+	// 新建连接的请求数
+	Dialed int64 `json:"dialed"`
+
+	// 复用率（0-100），没有请求时为 0
+	ReuseRate float64 `json:"reuseRate"`
+}
+
+// ModelInfo 上游声明的可用模型及其能力
+type ModelInfo struct {
+	ID string `json:"id"`
+
+	// 上下文窗口（输入 token 上限），上游未声明时为 0
+	ContextWindow int `json:"contextWindow,omitempty"`
+
+	// 最大输出 token 数，上游未声明时为 0
+	MaxOutputTokens int `json:"maxOutputTokens,omitempty"`
+}
+
+// ProviderModelsInfo 供应商上游模型列表的缓存状态
+type ProviderModelsInfo struct {
+	ProviderID   uint64 `json:"providerID"`
+	ProviderName string `json:"providerName"`
+
+	// 缓存的模型列表，从未成功获取时为空
+	Models []ModelInfo `json:"models"`
+
+	// 最近一次成功获取的时间及缓存过期时间，从未成功获取时为空
+	FetchedAt *time.Time `json:"fetchedAt,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+
+	// 缓存已过期或从未获取，下次读取时会在后台刷新
+	Stale bool `json:"stale"`
+
+	// 后台刷新进行中
+	Refreshing bool `json:"refreshing"`
+
+	// 最近一次获取失败的原因
+	Error string `json:"error,omitempty"`
+}
+
+// 配额周期
+const (
+	QuotaPeriodDay   = "day"
+	QuotaPeriodWeek  = "week"  // 周一开始
+	QuotaPeriodMonth = "month" // 每月 1 日开始
+)
+
+// ProviderQuotaConfig 供应商用量配额
+// 用量来自 usage_stats 中观测到的请求，达到软上限时广播告警，达到硬上限时冷却供应商直到周期重置
+type ProviderQuotaConfig struct {
+	// 周期: "day"、"week"、"month"
+	Period string `json:"period"`
+
+	// 每周期 token 上限（输入 + 输出），0 表示不限制
+	MaxTokens int64 `json:"maxTokens,omitempty"`
+
+	// 每周期请求数上限，0 表示不限制
+	MaxRequests int64 `json:"maxRequests,omitempty"`
+
+	// 软上限占硬上限的百分比，0 表示默认 80
+	SoftPercent int `json:"softPercent,omitempty"`
+
+	// 周期重置的小时 (0-23)
+	ResetHour int `json:"resetHour,omitempty"`
+
+	// 重置时间所在时区，如 "America/Los_Angeles"，为空时使用系统时区设置
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// ProviderQuotaInfo 供应商当前周期的配额使用情况
+type ProviderQuotaInfo struct {
+	ProviderID   uint64 `json:"providerID"`
+	ProviderName string `json:"providerName"`
+	Period       string `json:"period"`
+
+	// 当前周期的起止时间
+	PeriodStart time.Time `json:"periodStart"`
+	PeriodEnd   time.Time `json:"periodEnd"`
+
+	// 已用量和上限，上限为 0 表示不限制
+	UsedTokens   int64 `json:"usedTokens"`
+	MaxTokens    int64 `json:"maxTokens"`
+	UsedRequests int64 `json:"usedRequests"`
+	MaxRequests  int64 `json:"maxRequests"`
+
+	// 剩余量，-1 表示不限制
+	RemainingTokens   int64 `json:"remainingTokens"`
+	RemainingRequests int64 `json:"remainingRequests"`
+
+	// 是否已达到软上限 / 硬上限
+	SoftLimitReached bool `json:"softLimitReached"`
+	Exhausted        bool `json:"exhausted"`
+}
+
+// 脱敏规则类型
+const (
+	RedactionRuleTypeJSONPath = "jsonpath" // JSONPath 匹配，命中的字段值替换为 [REDACTED]
+	RedactionRuleTypeRegex    = "regex"    // 正则匹配，命中的文本替换为 [REDACTED]
+)
+
+// 脱敏规则作用目标
+const (
+	RedactionTargetAll      = ""         // 请求体和响应体
+	RedactionTargetRequest  = "request"  // 仅请求体
+	RedactionTargetResponse = "response" // 仅响应体
+)
+
+// RedactionRule 脱敏规则，仅作用于持久化的 RequestInfo.Body / ResponseInfo.Body 副本
+type RedactionRule struct {
+	// jsonpath 或 regex
+	Type string `json:"type"`
+
+	// JSONPath 表达式（如 $.messages[*].content）或正则表达式
+	Pattern string `json:"pattern"`
+
+	// 作用目标：request / response，空表示两者
+	Target string `json:"target,omitempty"`
+}
+
+// PIIPattern 个人信息检测规则，命中时仅在 ProxyRequest 上记录标记，不修改请求
+type PIIPattern struct {
+	// 标记名称，如 email、credit_card、ssn
+	Name string `json:"name"`
+
+	// 正则表达式
+	Pattern string `json:"pattern"`
 }
 
 // Provider 供应商
@@ -116,6 +418,87 @@ type Project struct {
 
 	// 启用自定义路由的 ClientType 列表，空数组表示所有 ClientType 都使用全局路由
 	EnabledCustomRoutes []ClientType `json:"enabledCustomRoutes"`
+
+	// 默认客户端类型，优先级低于 Token 的默认客户端类型，为空时按请求体推断
+	DefaultClientType ClientType `json:"defaultClientType,omitempty"`
+
+	// 内容审核钩子，nil 表示不审核
+	Guardrail *GuardrailConfig `json:"guardrail,omitempty"`
+
+	// 按供应商成本档位排序候选路由的偏好，空表示只按路由策略排序
+	CostPreference CostPreference `json:"costPreference,omitempty"`
+
+	// 所有路由均失败时的兜底回复，路由上配置的优先；nil 表示返回错误
+	FallbackResponse *FallbackResponseConfig `json:"fallbackResponse,omitempty"`
+
+	// 请求缺少 model 时使用的默认模型，优先于路由的配置；为空时由路由决定
+	DefaultModel string `json:"defaultModel,omitempty"`
+}
+
+// CostPreference 项目对供应商成本档位的偏好
+// 在路由策略的排序之上按档位稳定排序，同档位内保持策略顺序；近期不可用的供应商会被跳过
+type CostPreference string
+
+const (
+	CostPreferenceCheapestFirst CostPreference = "cheapest_first" // 低档位优先，不可用时才使用高档位
+	CostPreferenceBestFirst     CostPreference = "best_first"     // 高档位优先
+)
+
+// CostTier 供应商成本档位
+type CostTier string
+
+const (
+	CostTierEconomy  CostTier = "economy"
+	CostTierStandard CostTier = "standard"
+	CostTierPremium  CostTier = "premium"
+)
+
+// DefaultFallbackMessage 兜底回复未配置文本时使用的默认文本
+const DefaultFallbackMessage = "The service is temporarily unavailable, please try again later."
+
+// FallbackResponseConfig 所有路由均失败（或全部处于冷却）时代替错误返回的兜底回复
+// 以客户端格式的正常助手回复返回（支持流式），避免后台任务和 Agent 循环因短暂的全面故障而中断；
+// 请求仍记为失败并标记 FallbackResponse，不计入成功统计。已向客户端发送部分响应时不再兜底
+type FallbackResponseConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// 回复文本，支持占位符 {model}（请求模型）、{error}（最后的错误）、{requestID}、{time}（RFC3339）
+	// 为空时使用 DefaultFallbackMessage
+	Message string `json:"message,omitempty"`
+}
+
+// GuardrailAction 审核命中后的处理方式
+type GuardrailAction string
+
+const (
+	GuardrailActionBlock    GuardrailAction = "block"    // 拒绝请求（默认）
+	GuardrailActionAnnotate GuardrailAction = "annotate" // 放行，仅在请求记录上标记
+)
+
+// GuardrailConfig 项目级内容审核钩子，在路由前检查请求内容
+// 关键词和审核模型可同时配置，任一命中即视为违规
+type GuardrailConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// 请求文本包含任一关键词（不区分大小写）即命中
+	BlockedKeywords []string `json:"blockedKeywords,omitempty"`
+
+	// 审核模型，通过 OpenAI Chat Completions 兼容接口调用，模型回复以 BLOCK 开头即命中
+	// 指向 maxx 自身的 /v1/chat/completions 即可经由 maxx 路由；所用 Token 的项目不应再开启审核
+	ModerationURL    string `json:"moderationURL,omitempty"`
+	ModerationModel  string `json:"moderationModel,omitempty"`
+	ModerationAPIKey string `json:"moderationAPIKey,omitempty"`
+	// 审核模型调用超时（毫秒），0 使用默认 5 秒
+	TimeoutMs int `json:"timeoutMs,omitempty"`
+
+	// 命中后的处理方式，为空时为 block
+	Action GuardrailAction `json:"action,omitempty"`
+
+	// 审核调用失败时拒绝请求，默认放行
+	FailClosed bool `json:"failClosed,omitempty"`
+
+	// 请求成功后用关键词检查响应内容，响应已发送给客户端，只做标记
+	CheckResponse bool `json:"checkResponse,omitempty"`
 }
 
 type Session struct {
@@ -161,6 +544,34 @@ type Route struct {
 
 	// 重试配置，0 表示使用系统默认
 	RetryConfigID uint64 `json:"retryConfigID"`
+
+	// 对冲延迟（毫秒），首个尝试在此时间内未收到首字节时并行请求下一条路由，0 表示不对冲
+	HedgeDelayMs int `json:"hedgeDelayMs"`
+
+	// 快速失败：不重试（覆盖重试配置），任何失败都让供应商立即进入短暂冷却，直接切换到下一条路由
+	FailFast bool `json:"failFast"`
+
+	// 集成路由（实验性）：作为首条路由时，非流式请求并行发送到该路由及其后的路由，共 N 条（最多 5 条），0 或 1 表示不启用
+	EnsembleSize int `json:"ensembleSize"`
+
+	// 集成路由从成功响应中选择返回给客户端的响应的方式，见 EnsembleStrategy*，空表示 first
+	EnsembleStrategy string `json:"ensembleStrategy,omitempty"`
+
+	// 仅允许这些 API Token 使用该路由，空表示不限制
+	// 出现在任一路由上的 Token 只能使用列出它的路由，用于将 Token 固定到专属的供应商
+	APITokenIDs []uint64 `json:"apiTokenIDs,omitempty"`
+
+	// 按时间段和负载动态启用或降级该路由，nil 表示始终生效
+	Conditions *RouteConditions `json:"conditions,omitempty"`
+
+	// 所有路由均失败时的兜底回复，按匹配顺序取第一条配置了的路由，优先于项目的配置；nil 表示未配置
+	FallbackResponse *FallbackResponseConfig `json:"fallbackResponse,omitempty"`
+
+	// 请求缺少 model 且项目未配置默认模型时该路由使用的模型；为空时跳过该路由
+	DefaultModel string `json:"defaultModel,omitempty"`
+
+	// 自适应排序后的实际顺序（从 1 开始，只读，由路由器计算，不保存）；路由策略未启用自适应排序时为 0
+	AdaptivePosition int `json:"adaptivePosition,omitempty"`
 }
 
 // RoutePositionUpdate represents a route position update
@@ -169,6 +580,54 @@ type RoutePositionUpdate struct {
 	Position int    `json:"position"`
 }
 
+// RoutingSignature 路由模拟使用的请求特征
+type RoutingSignature struct {
+	ClientType   ClientType `json:"clientType"`
+	ProjectID    uint64     `json:"projectID"`
+	RequestModel string     `json:"requestModel"`
+
+	// 该特征代表的请求数，<= 0 按 1 计
+	Count int `json:"count,omitempty"`
+}
+
+// RoutingSimulationRequest 假设的路由配置，省略的部分使用当前配置
+type RoutingSimulationRequest struct {
+	// 假设的路由，nil 表示使用当前路由
+	Routes []*Route `json:"routes,omitempty"`
+
+	// 假设的路由策略，nil 表示使用当前策略
+	Strategies []*RoutingStrategy `json:"strategies,omitempty"`
+
+	// 请求样本，为空时使用最近的请求
+	Samples []*RoutingSignature `json:"samples,omitempty"`
+}
+
+// ProviderTrafficShare 供应商预计承担的流量
+type ProviderTrafficShare struct {
+	ProviderID   uint64 `json:"providerID"`
+	ProviderName string `json:"providerName"`
+
+	// 预计请求数（轮询类策略按候选路由平分，可为小数）和占全部样本的比例
+	Requests float64 `json:"requests"`
+	Share    float64 `json:"share"`
+}
+
+// RoutingDistribution 一组样本在某个路由配置下的流量分布
+type RoutingDistribution struct {
+	TotalRequests int `json:"totalRequests"`
+
+	// 没有可用路由的请求数
+	UnroutedRequests int `json:"unroutedRequests"`
+
+	Providers []*ProviderTrafficShare `json:"providers"`
+}
+
+// RoutingSimulation 路由模拟结果，同时给出当前配置下的分布用于对比
+type RoutingSimulation struct {
+	Projected *RoutingDistribution `json:"projected"`
+	Current   *RoutingDistribution `json:"current"`
+}
+
 type RequestInfo struct {
 	Method  string            `json:"method"`
 	Headers map[string]string `json:"headers"`
@@ -244,8 +703,155 @@ type ProxyRequest struct {
 
 	// 使用的 API Token ID，0 表示未使用 Token
 	APITokenID uint64 `json:"apiTokenID"`
+
+	// 通过 X-Maxx-Max-Retries 请求头覆盖的最大重试次数（已按上限截断），nil 表示未覆盖
+	MaxRetriesOverride *int `json:"maxRetriesOverride,omitempty"`
+
+	// 客户端请求中 OpenAI metadata 字段的键值对，用于关联客户端自身的追踪信息
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// 请求体中检测到的疑似个人信息类型（PIIPattern.Name），仅作提示
+	PIIFlags []string `json:"piiFlags,omitempty"`
+
+	// 根据 User-Agent / X-Stainless-* 请求头识别的客户端应用，如 "claude-cli/2.0"，版本只保留 major.minor
+	ClientApp string `json:"clientApp,omitempty"`
+
+	// 内容审核命中但未拦截时的原因（annotate 模式或响应检查）
+	GuardrailFlag string `json:"guardrailFlag,omitempty"`
+
+	// 客户端通过标签请求头（默认 X-Maxx-Tag）指定的成本分摊标签，已规范化并去重
+	Tags []string `json:"tags,omitempty"`
+
+	// 全部路由失败时每个已尝试路由的失败摘要，按尝试顺序排列
+	RouteFailures []RouteFailure `json:"routeFailures,omitempty"`
+
+	// 成功响应中没有任何内容（候选全部被过滤或为空）
+	EmptyResponse bool `json:"emptyResponse,omitempty"`
+
+	// 所有路由均失败后向客户端返回了兜底回复，请求状态仍为 FAILED
+	FallbackResponse bool `json:"fallbackResponse,omitempty"`
+
+	// 请求未指定 model，使用了项目或路由的默认模型（RequestModel 为该默认模型）
+	ModelDefaulted bool `json:"modelDefaulted,omitempty"`
+
+	// 管理员通过 X-Maxx-Provider-ID 请求头强制使用的供应商（绕过路由和冷却，用于排查），0 表示未强制
+	ForcedProviderID uint64 `json:"forcedProviderID,omitempty"`
+
+	// 管理员从 curl / HAR 导入并重放的请求，同时带有 replay 标签
+	Replay bool `json:"replay,omitempty"`
+
+	// 请求被取消（CANCELLED）的原因，见 CancelReason
+	CancelReason CancelReason `json:"cancelReason,omitempty"`
+}
+
+// CancelReason 请求被取消的原因
+type CancelReason string
+
+const (
+	CancelReasonClientDisconnect CancelReason = "client_disconnect" // 客户端断开连接
+	CancelReasonResumeAbandoned  CancelReason = "resume_abandoned"  // 可恢复的流在宽限期内没有客户端重新连接
+	CancelReasonAdmin            CancelReason = "admin"             // 管理员手动取消
+	CancelReasonShutdown         CancelReason = "shutdown"          // 服务关闭
+)
+
+// Error 使 CancelReason 可作为 context.WithCancelCause 的取消原因
+func (r CancelReason) Error() string {
+	return "request cancelled: " + string(r)
+}
+
+// RouteFailure 单个路由的失败摘要，用于排查全部路由失败的请求
+type RouteFailure struct {
+	// 尝试顺序，从 1 开始
+	Position int `json:"position"`
+
+	RouteID      uint64 `json:"routeID,omitempty"`
+	ProviderID   uint64 `json:"providerID,omitempty"`
+	ProviderName string `json:"providerName,omitempty"`
+
+	// 该路由上的尝试次数（含重试）
+	Attempts int `json:"attempts"`
+
+	// 最后一次尝试的上游 HTTP 状态码，未收到响应时为 0
+	StatusCode int `json:"statusCode,omitempty"`
+
+	// 错误类别，如 "upstream error"、"network error"、"first byte timeout"
+	Error string `json:"error"`
+
+	// 最后一次尝试的完整错误信息
+	Detail string `json:"detail,omitempty"`
+}
+
+// 返回给客户端的路由失败摘要详细程度
+const (
+	RouteFailureDetailNone  = "none"  // 不返回
+	RouteFailureDetailBasic = "basic" // 仅尝试顺序、次数、状态码与错误类别（默认）
+	RouteFailureDetailFull  = "full"  // 另含路由、供应商与完整错误信息
+)
+
+// 成功但没有内容的响应的处理方式
+const (
+	EmptyResponsePassthrough = "passthrough" // 原样返回（默认），仅记录
+	EmptyResponseMarker      = "marker"      // 原样返回并添加 X-Maxx-Empty-Response 响应头
+	EmptyResponseRetry       = "retry"       // 视为失败，重试或切换到下一个路由
+)
+
+// 缺少 model 的请求的处理方式
+const (
+	MissingModelDefault = "default" // 使用项目或路由的默认模型（默认），均未配置时拒绝
+	MissingModelReject  = "reject"  // 始终以 400 拒绝
+)
+
+// 集成路由选择响应的方式
+const (
+	EnsembleStrategyFirst   = "first"   // 最先完成的成功响应（默认），其余尝试随即取消
+	EnsembleStrategyLongest = "longest" // 文本内容最长的响应
+	EnsembleStrategyVote    = "vote"    // 多数响应一致的工具调用，票数相同时取最先完成的
+)
+
+// PIIFilterAny 匹配任意 PII 标记的筛选值
+const PIIFilterAny = "any"
+
+// ProxyRequestFilter 请求列表的筛选条件
+type ProxyRequestFilter struct {
+	// 按 metadata 标签筛选，所有键值对都需匹配
+	Metadata map[string]string
+
+	// 按项目筛选，0 表示不筛选
+	ProjectID uint64
+
+	// 按 PII 标记筛选：any 表示任意标记，其他值匹配指定的 PIIPattern.Name，空表示不筛选
+	PII string
+
+	// 按成本分摊标签筛选，空表示不筛选
+	Tag string
+
+	// 全文搜索关键词，空表示不搜索
+	Search string
+
+	// 搜索范围：error、body、model，空表示全部
+	SearchField string
 }
 
+// 流式请求的实时输出 token 进度（WebSocket "token_progress" 消息）
+// 生成过程中按估算值节流广播，尝试结束时发送以上游用量为准的最终值
+type TokenProgress struct {
+	ProxyRequestID uint64 `json:"proxyRequestID"`
+	AttemptID      uint64 `json:"attemptID"`
+	OutputTokens   uint64 `json:"outputTokens"`
+	// 是否为尝试结束时的最终值
+	Final bool `json:"final"`
+}
+
+// 请求全文搜索的范围
+const (
+	RequestSearchFieldError = "error" // 错误信息
+	RequestSearchFieldBody  = "body"  // 请求/响应内容
+	RequestSearchFieldModel = "model" // 请求/响应模型
+)
+
+// MinRequestSearchLength 搜索关键词的最小长度（trigram 分词要求至少 3 个字符）
+const MinRequestSearchLength = 3
+
 type ProxyUpstreamAttempt struct {
 	ID        uint64    `json:"id"`
 	CreatedAt time.Time `json:"createdAt"`
@@ -293,6 +899,43 @@ type ProxyUpstreamAttempt struct {
 	Cache1hWriteCount uint64 `json:"cache1hWriteCount"`
 
 	Cost uint64 `json:"cost"`
+
+	// 上游响应的 HTTP 状态码（冗余存储，用于错误原因统计），0 表示未收到响应
+	StatusCode int `json:"statusCode"`
+
+	// 因供应商 MaxOutputTokens 上限被下调前的请求最大输出 tokens，0 表示未下调
+	MaxTokensClampedFrom int `json:"maxTokensClampedFrom,omitempty"`
+
+	// 上游要求的冷却时长（秒），超出供应商冷却上限被截断时记录，0 表示未截断
+	CooldownCappedFromSecs int `json:"cooldownCappedFromSecs,omitempty"`
+
+	// 对冲尝试所对冲的主尝试 ID，0 表示不是对冲尝试
+	HedgeOfAttemptID uint64 `json:"hedgeOfAttemptID,omitempty"`
+
+	// 集成路由中首个尝试的 ID（首个尝试自身为 0），0 表示不是集成路由的其他尝试
+	EnsembleOfAttemptID uint64 `json:"ensembleOfAttemptID,omitempty"`
+
+	// 集成路由中被选为返回给客户端的响应
+	EnsembleChosen bool `json:"ensembleChosen,omitempty"`
+
+	// 上游返回成功但响应中没有任何内容
+	EmptyResponse bool `json:"emptyResponse,omitempty"`
+
+	// 因上游不支持而在转发前移除的客户端请求参数，逗号分隔
+	DroppedParams string `json:"droppedParams,omitempty"`
+
+	// 上游请求各阶段耗时，未开启采集或未发出请求时为 nil
+	Timing *UpstreamTiming `json:"timing,omitempty"`
+}
+
+// UpstreamTiming 上游请求各阶段耗时（由 httptrace 采集）
+// 复用连接时没有 DNS/TCP/TLS 阶段，对应耗时为 0
+type UpstreamTiming struct {
+	DNS       time.Duration `json:"dns"`       // DNS 解析
+	Connect   time.Duration `json:"connect"`   // TCP 建连
+	TLS       time.Duration `json:"tls"`       // TLS 握手
+	FirstByte time.Duration `json:"firstByte"` // 请求发送完毕到收到响应首字节
+	Reused    bool          `json:"reused"`    // 是否复用了空闲连接
 }
 
 // 重试配置
@@ -331,12 +974,19 @@ var (
 	RoutingStrategyPriority RoutingStrategyType = "priority"
 	// 加权随机
 	RoutingStrategyWeightedRandom RoutingStrategyType = "weighted_random"
+	// 轮询，按 Position 排序后每次请求轮换起始路由
+	RoutingStrategyRoundRobin RoutingStrategyType = "round_robin"
+	// 按 TPM 余量，优先选择距离 TPM 上限最远的供应商
+	RoutingStrategyTPM RoutingStrategyType = "tpm"
 )
 
 // 路由策略配置（策略特定参数）
 type RoutingStrategyConfig struct {
 	// 加权随机策略的权重配置等
 	// 根据具体策略扩展
+
+	// 自适应排序（仅 priority 策略）：按供应商近期的成功率和延迟调整路由顺序，Position 作为先验和平局时的依据
+	AdaptiveOrder bool `json:"adaptiveOrder,omitempty"`
 }
 
 // 路由策略
@@ -373,6 +1023,69 @@ const (
 	SettingKeyTimezone               = "timezone"                 // 时区设置，默认 Asia/Shanghai
 	SettingKeyQuotaRefreshInterval   = "quota_refresh_interval"   // Antigravity 配额刷新间隔（分钟），0 表示禁用
 	SettingKeyAutoSortAntigravity    = "auto_sort_antigravity"    // 是否自动排序 Antigravity 路由，"true" 或 "false"
+	SettingKeyRedactionRules         = "redaction_rules"          // 全局脱敏规则，JSON 数组格式的 []RedactionRule
+	SettingKeyRoundRobinPersistSecs  = "round_robin_persist_secs" // 轮询计数持久化间隔（秒），默认 30，0 表示不持久化
+	SettingKeyMaxRetriesOverrideCap  = "max_retries_override_cap" // X-Maxx-Max-Retries 请求头允许的最大重试次数，默认 5，0 表示只允许禁用重试
+	SettingKeyCooldownFlushSecs      = "cooldown_flush_secs"      // 冷却与失败计数持久化间隔（秒），默认 10
+	SettingKeyCooldownScopes         = "cooldown_scopes"          // 按失败原因配置冷却范围，JSON 对象如 {"quota_exhausted":"provider"}，未配置的原因只冷却对应 ClientType
+	SettingKeyCooldownQueueWait      = "cooldown_queue_wait_secs" // 所有可用供应商都在冷却时，请求排队等待其恢复的最长时间（秒），0（默认）表示立即失败
+	SettingKeyParallelToolCallPolicy = "parallel_tool_call_policy" // 客户端 parallel_tool_calls=false 但上游仍返回多个工具调用时的处理方式，"first"（默认，仅保留第一个）或 "error"
+	SettingKeyProviderWarmup         = "provider_warmup"          // 启动时是否预刷新 Kiro/Antigravity 等提供商的访问令牌，"true" 或 "false"（默认）
+	SettingKeyBatchConcurrency       = "batch_concurrency"        // Message Batch 后台并发处理的请求数，默认 1
+	SettingKeyPIIDetection           = "pii_detection"            // 是否检测请求体中的疑似个人信息并标记，"true" 或 "false"（默认）
+	SettingKeyPIIPatterns            = "pii_patterns"             // PII 检测规则，JSON 数组格式的 []PIIPattern，为空时使用内置规则
+	SettingKeyMaxConcurrentRequests  = "max_concurrent_requests"  // 全局最大并发代理请求数，0（默认）表示不限制
+	SettingKeyRequestQueueSize       = "request_queue_size"       // 超过并发上限时排队等待的最大请求数，默认 100
+	SettingKeyRequestQueueTimeout    = "request_queue_timeout_secs" // 排队请求的最长等待时间（秒），默认 10，超时返回 503
+	SettingKeyMaxRequestBodyMB       = "max_request_body_mb"        // 代理请求体的最大大小（MB），默认 32，超出时在读取完整请求体前返回 413，0 表示不限制
+	SettingKeyResponseSpillThreshold = "response_spill_threshold_kb" // 流式响应记录超过该大小（KB）后转存到临时文件，0（默认）表示始终保存在内存
+	SettingKeyRequestTagHeader       = "request_tag_header"       // 携带成本分摊标签的请求头名称，默认 X-Maxx-Tag，多个标签以逗号分隔
+	SettingKeyRouteFailureDetail     = "route_failure_detail"     // 全部路由失败时返回给客户端的失败摘要详细程度，"basic"（默认）、"full" 或 "none"
+	SettingKeyEmptyResponseHandling  = "empty_response_handling"  // 成功但没有内容的响应的处理方式，"passthrough"（默认）、"marker" 或 "retry"
+	SettingKeySpendShareThreshold    = "spend_share_alert_threshold"      // 单个供应商成本或请求占比超过该百分比时告警，0（默认）表示不检查
+	SettingKeySpendShareWindow       = "spend_share_alert_window_minutes" // 占比统计窗口（分钟），默认 60，最长 1440
+	SettingKeySpendShareMinRequests  = "spend_share_alert_min_requests"   // 窗口内总请求数低于该值时不检查，默认 50
+	SettingKeySpendShareWebhook      = "spend_share_alert_webhook"        // 占比告警的 Webhook 地址（POST JSON），空表示仅通过 WebSocket 推送
+	SettingKeyPromptCacheKeySession  = "prompt_cache_key_session"         // 是否将 OpenAI/Codex 请求的 prompt_cache_key 用作会话 ID，"true" 或 "false"（默认）
+	SettingKeyStatsExportURL         = "stats_export_url"                 // 定期推送分钟级统计的地址，空（默认）表示不导出
+	SettingKeyStatsExportFormat      = "stats_export_format"              // 统计导出格式，"json"（默认）或 "influx"（InfluxDB 行协议，VictoriaMetrics 也可接收）
+	SettingKeyStatsExportAuth        = "stats_export_auth"                // 统计导出请求的 Authorization 请求头，如 "Token xxx"，空表示不携带
+	SettingKeyStatsExportCursor      = "stats_export_cursor"              // 最后一个已导出的分钟时间桶（Unix 秒），由导出任务维护，重启后从此继续
+	SettingKeyUpstreamMaxIdleConns        = "upstream_max_idle_conns"           // 每个供应商连接池保留的最大空闲连接数，默认 100，0 表示不限制
+	SettingKeyUpstreamMaxIdleConnsPerHost = "upstream_max_idle_conns_per_host"  // 每个供应商对单个上游主机保留的最大空闲连接数，默认 16
+	SettingKeyUpstreamIdleConnTimeout     = "upstream_idle_conn_timeout_secs"   // 空闲连接保留的秒数，默认 90，0 表示不超时
+	SettingKeyRequestLogSink              = "request_log_sink"                  // 请求审计记录的投递地址：http(s)://（POST NDJSON）、syslog://host:port（UDP）、syslog+tcp://host:port 或 file:///path（追加 NDJSON），空（默认）表示不投递
+	SettingKeyRequestLogSinkAuth          = "request_log_sink_auth"             // HTTP 投递请求的 Authorization 请求头，空表示不携带
+	SettingKeyRequestLogSinkProviders     = "request_log_sink_providers"        // 只投递这些供应商（逗号分隔的 ID）的请求记录，空（默认）表示全部
+	SettingKeyRequestLogSinkBodyLimit     = "request_log_sink_body_limit_kb"    // 记录中请求/响应体的最大大小（KB），超出部分截断，默认 64，0 表示不包含请求体
+	SettingKeyRequestLogSinkBuffer        = "request_log_sink_buffer"           // 等待投递的记录数上限，默认 1000，缓冲区满时丢弃新记录
+	SettingKeyRetryBudgetTokens           = "retry_budget_max_tokens"           // 重试预算的令牌桶容量，失败消耗 1 个令牌，剩余不超过一半时暂停重试和切换路由，0（默认）表示不限制
+	SettingKeyRetryBudgetRatio            = "retry_budget_token_ratio"          // 每个成功请求补充的令牌数，默认 0.1，即约每 10 个请求允许 1 次重试
+	SettingKeyRetryBudgetScope            = "retry_budget_scope"                // 重试预算的范围：provider（默认，每个供应商独立）或 global（所有供应商共享）
+	SettingKeyDailyReportTime             = "daily_report_time"                 // 每日用量摘要的推送时间（配置时区的 HH:MM），推送前一天的摘要，空（默认）表示不推送
+	SettingKeyDailyReportWebhooks         = "daily_report_webhooks"             // 每日用量摘要的接收地址（POST JSON），多个以逗号分隔
+	SettingKeyDailyReportLastDate         = "daily_report_last_date"            // 最后一个已推送摘要的日期，由推送任务维护，避免重复推送
+	SettingKeyCredentialDiscoveryDir      = "credential_discovery_dir"          // 存放 Kiro/Antigravity 凭证 JSON 文件的目录，启动时扫描并创建或更新供应商，空（默认）表示不扫描
+	SettingKeyWSCompressionLevel          = "ws_compression_level"              // WebSocket permessage-deflate 压缩级别（1-9，越大压缩率越高、CPU 开销越大），默认 1，0 表示不压缩，仅对协商了该扩展的客户端生效
+	SettingKeyUpstreamTimingCapture       = "upstream_timing_capture"           // 是否记录每个 attempt 上游请求的 DNS/TCP/TLS/首字节耗时，"true" 或 "false"（默认）
+	SettingKeyUpstreamTLSMinVersion       = "upstream_tls_min_version"          // 上游连接允许的最低 TLS 版本："1.2" 或 "1.3"，空（默认）表示 1.2，对所有供应商生效
+	SettingKeyUpstreamTLSCipherSuites     = "upstream_tls_cipher_suites"        // TLS 1.2 允许的加密套件（逗号分隔的 Go 名称，如 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256），空（默认）表示 Go 默认套件；TLS 1.3 套件不可配置
+	SettingKeyStatsRealtimeCacheSecs      = "stats_realtime_cache_secs"         // 实时统计查询中当前时间桶计算结果的缓存时长（秒），相同过滤条件在此时间内复用结果，默认 5，0 表示不缓存
+	SettingKeyResponseStatusMapping       = "response_status_mapping"           // 全局客户端响应状态码映射，逗号分隔的 "上游:客户端"，如 "529:503,524:504"，供应商配置的映射优先；空（默认）表示不映射
+	SettingKeyMissingModelHandling        = "missing_model_handling"            // 缺少 model 的请求的处理方式，"default"（默认，使用项目或路由的默认模型）或 "reject"
+	SettingKeyCapturePath                 = "capture_path"                      // 按 JSONL 镜像已完成请求的请求/响应对的文件路径，用于离线评测等，空（默认）表示不镜像
+	SettingKeyCaptureMaxSizeMB            = "capture_max_size_mb"               // 镜像文件达到该大小（MB）后轮转为带时间戳的文件，默认 100，0 表示不轮转
+	SettingKeyCaptureMaxFiles             = "capture_max_files"                 // 保留的已轮转镜像文件数，默认 10，0 表示全部保留
+	SettingKeyCaptureSampleRate           = "capture_sample_rate"               // 镜像的请求比例（0-1），默认 1
+	SettingKeyCaptureRedactionRules       = "capture_redaction_rules"           // 仅作用于镜像内容的脱敏规则（JSON 数组，格式同 redaction_rules），在全局和供应商规则之后应用
+	SettingKeyCaptureBuffer               = "capture_buffer"                    // 等待写入的镜像记录数上限，默认 1000，缓冲区满时丢弃新记录
+	SettingKeyStreamHeartbeatSecs         = "stream_heartbeat_secs"             // 流式响应空闲超过该秒数时按客户端格式发送心跳（Claude 为 ping 事件，OpenAI/Codex 为 SSE 注释，Gemini 为空行），0（默认）表示不发送
+)
+
+// 统计导出格式
+const (
+	StatsExportFormatJSON   = "json"   // {"granularity":"minute","stats":[UsageStats...]}
+	StatsExportFormatInflux = "influx" // InfluxDB 行协议，measurement 为 maxx_usage
 )
 
 // Antigravity 模型配额
@@ -436,6 +1149,88 @@ type ProviderStats struct {
 	TotalCost uint64 `json:"totalCost"`
 }
 
+// ProviderReport Provider 对比报表（单个 Provider 在统计窗口内的汇总）
+type ProviderReport struct {
+	ProviderID   uint64 `json:"providerID"`
+	ProviderName string `json:"providerName"`
+	ProviderType string `json:"providerType"`
+
+	// 请求统计
+	TotalRequests      uint64  `json:"totalRequests"`
+	SuccessfulRequests uint64  `json:"successfulRequests"`
+	FailedRequests     uint64  `json:"failedRequests"`
+	SuccessRate        float64 `json:"successRate"` // 0-100
+
+	// 成功请求的耗时分位数（毫秒），来自 proxy_upstream_attempts
+	P50LatencyMs int64 `json:"p50LatencyMs"`
+	P95LatencyMs int64 `json:"p95LatencyMs"`
+
+	// Token 统计
+	TotalInputTokens  uint64 `json:"totalInputTokens"`
+	TotalOutputTokens uint64 `json:"totalOutputTokens"`
+	TotalCacheRead    uint64 `json:"totalCacheRead"`
+	TotalCacheWrite   uint64 `json:"totalCacheWrite"`
+
+	// 成本 (微美元)
+	TotalCost uint64 `json:"totalCost"`
+
+	// 当前冷却状态
+	InCooldown          bool       `json:"inCooldown"`
+	CooldownUntil       *time.Time `json:"cooldownUntil,omitempty"`
+	CooldownClientTypes []string   `json:"cooldownClientTypes,omitempty"` // 空字符串表示所有 ClientType
+}
+
+// ProviderSoakRequest 供应商压测参数，省略的字段使用默认值
+type ProviderSoakRequest struct {
+	Count       int        `json:"count,omitempty"`       // 请求总数，默认 10
+	Concurrency int        `json:"concurrency,omitempty"` // 并发数，默认 1
+	Model       string     `json:"model"`                 // 请求的模型（必填）
+	Prompt      string     `json:"prompt,omitempty"`      // 用户消息，默认一句简短的问候
+	ClientType  ClientType `json:"clientType,omitempty"`  // 请求格式，默认使用供应商支持的第一个格式
+	MaxTokens   int        `json:"maxTokens,omitempty"`   // 每个请求的最大输出 tokens，默认 16
+}
+
+// ProviderSoakReport 供应商压测报告
+// 压测请求直接通过适配器发送，不写入请求日志和统计，也不影响冷却和健康度
+type ProviderSoakReport struct {
+	ProviderID   uint64     `json:"providerID"`
+	ProviderName string     `json:"providerName"`
+	ClientType   ClientType `json:"clientType"`
+	Model        string     `json:"model"`
+	UserAgent    string     `json:"userAgent"` // 压测请求携带的 User-Agent，便于在上游区分压测流量
+
+	StartedAt  time.Time `json:"startedAt"`
+	DurationMs int64     `json:"durationMs"`
+
+	// 请求统计
+	TotalRequests      int     `json:"totalRequests"`
+	SuccessfulRequests int     `json:"successfulRequests"`
+	FailedRequests     int     `json:"failedRequests"`
+	SuccessRate        float64 `json:"successRate"` // 0-100
+
+	// 全部请求的耗时分布（毫秒）
+	MinLatencyMs int64 `json:"minLatencyMs"`
+	AvgLatencyMs int64 `json:"avgLatencyMs"`
+	P50LatencyMs int64 `json:"p50LatencyMs"`
+	P90LatencyMs int64 `json:"p90LatencyMs"`
+	P99LatencyMs int64 `json:"p99LatencyMs"`
+	MaxLatencyMs int64 `json:"maxLatencyMs"`
+
+	// Token 统计（上游报告的用量）
+	TotalInputTokens  uint64 `json:"totalInputTokens"`
+	TotalOutputTokens uint64 `json:"totalOutputTokens"`
+
+	// 按状态码和错误信息分组的失败，按次数从多到少排序
+	Errors []ProviderSoakError `json:"errors"`
+}
+
+// ProviderSoakError 压测中同一种失败的次数
+type ProviderSoakError struct {
+	StatusCode int    `json:"statusCode,omitempty"` // 上游状态码，0 表示没有收到响应
+	Message    string `json:"message"`
+	Count      int    `json:"count"`
+}
+
 // Granularity 统计数据的时间粒度
 type Granularity string
 
@@ -451,6 +1246,7 @@ const (
 type UsageStats struct {
 	ID        uint64    `json:"id"`
 	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"` // 统计值最后一次变化的时间
 
 	// 时间维度
 	TimeBucket  time.Time   `json:"timeBucket"`  // 时间桶（根据粒度截断）
@@ -480,6 +1276,12 @@ type UsageStats struct {
 	Cost uint64 `json:"cost"`
 }
 
+// UsageStatsChanges 增量统计查询结果（用于轮询）
+type UsageStatsChanges struct {
+	Buckets []*UsageStats `json:"buckets"` // since 之后有变化的时间桶，客户端按维度整体替换
+	Cursor  int64         `json:"cursor"`  // 下次轮询使用的 since（毫秒时间戳）
+}
+
 // UsageStatsSummary 统计数据汇总（用于仪表盘）
 type UsageStatsSummary struct {
 	TotalRequests      uint64  `json:"totalRequests"`
@@ -493,6 +1295,109 @@ type UsageStatsSummary struct {
 	TotalCost          uint64  `json:"totalCost"`
 }
 
+// ModelErrorStats 模型错误原因统计
+type ModelErrorStats struct {
+	Reason string `json:"reason"` // rate_limit, server_error, auth_error, client_error, network_error, cancelled
+	Count  uint64 `json:"count"`
+}
+
+// ModelStats 模型维度的可靠性统计
+type ModelStats struct {
+	Model              string  `json:"model"`
+	TotalRequests      uint64  `json:"totalRequests"`
+	SuccessfulRequests uint64  `json:"successfulRequests"`
+	FailedRequests     uint64  `json:"failedRequests"`
+	SuccessRate        float64 `json:"successRate"`   // 0-100
+	AvgDurationMs      float64 `json:"avgDurationMs"` // 平均耗时（毫秒）
+	TotalInputTokens   uint64  `json:"totalInputTokens"`
+	TotalOutputTokens  uint64  `json:"totalOutputTokens"`
+	TotalCacheRead     uint64  `json:"totalCacheRead"`
+	TotalCacheWrite    uint64  `json:"totalCacheWrite"`
+	TotalCost          uint64  `json:"totalCost"`
+
+	// 失败 attempt 的错误原因分布（按数量降序）
+	Errors []ModelErrorStats `json:"errors"`
+}
+
+// ProviderShare 供应商在统计窗口内的成本与请求占比
+type ProviderShare struct {
+	ProviderID   uint64  `json:"providerID"`
+	ProviderName string  `json:"providerName"`
+	Requests     uint64  `json:"requests"`
+	Cost         uint64  `json:"cost"`         // 微美元
+	RequestShare float64 `json:"requestShare"` // 0-100
+	CostShare    float64 `json:"costShare"`    // 0-100
+}
+
+// ProviderSpendAlert 单个供应商的成本或请求占比超过阈值的告警，用于发现路由配置错误
+type ProviderSpendAlert struct {
+	ProviderShare
+	Threshold     float64         `json:"threshold"`     // 占比阈值 0-100
+	WindowMinutes int             `json:"windowMinutes"` // 统计窗口（分钟）
+	Shares        []ProviderShare `json:"shares"`        // 窗口内所有有流量的供应商占比，按成本占比降序
+	TriggeredAt   time.Time       `json:"triggeredAt"`
+}
+
+// DailyUsageReport 每日用量摘要，按计划推送到配置的 Webhook
+type DailyUsageReport struct {
+	Date               string                     `json:"date"`     // 统计日期，如 2026-03-01
+	Timezone           string                     `json:"timezone"` // 日期所在的时区
+	TotalRequests      uint64                     `json:"totalRequests"`
+	SuccessfulRequests uint64                     `json:"successfulRequests"`
+	FailedRequests     uint64                     `json:"failedRequests"`
+	ErrorRate          float64                    `json:"errorRate"` // 0-100
+	InputTokens        uint64                     `json:"inputTokens"`
+	OutputTokens       uint64                     `json:"outputTokens"`
+	CacheRead          uint64                     `json:"cacheRead"`
+	CacheWrite         uint64                     `json:"cacheWrite"`
+	Cost               uint64                     `json:"cost"`      // 微美元
+	TopModels          []DailyUsageReportModel    `json:"topModels"` // 按请求数降序，最多 5 个
+	Providers          []DailyUsageReportProvider `json:"providers"` // 按成本降序
+	Text               string                     `json:"text"`      // 可读的摘要文本，可直接用作聊天机器人消息
+}
+
+// DailyUsageReportModel 每日摘要中单个模型的用量
+type DailyUsageReportModel struct {
+	Model    string `json:"model"`
+	Requests uint64 `json:"requests"`
+	Tokens   uint64 `json:"tokens"` // input + output + cacheRead + cacheWrite
+	Cost     uint64 `json:"cost"`
+}
+
+// DailyUsageReportProvider 每日摘要中单个供应商的用量
+type DailyUsageReportProvider struct {
+	ProviderID         uint64  `json:"providerID"`
+	ProviderName       string  `json:"providerName"`
+	TotalRequests      uint64  `json:"totalRequests"`
+	SuccessfulRequests uint64  `json:"successfulRequests"`
+	FailedRequests     uint64  `json:"failedRequests"`
+	ErrorRate          float64 `json:"errorRate"` // 0-100
+	Tokens             uint64  `json:"tokens"`
+	Cost               uint64  `json:"cost"`
+}
+
+// EmptyResponseStats 供应商返回成功但没有内容的响应统计（按供应商和客户端类型）
+type EmptyResponseStats struct {
+	ProviderID     uint64  `json:"providerID"`
+	ClientType     string  `json:"clientType"`
+	TotalAttempts  uint64  `json:"totalAttempts"`
+	EmptyResponses uint64  `json:"emptyResponses"`
+	EmptyRate      float64 `json:"emptyRate"` // 0-100
+}
+
+// UpstreamTimingStats 供应商上游请求各阶段的平均耗时（仅统计开启采集后的 attempt）
+// DNS/TCP/TLS 只在新建连接的请求中平均
+type UpstreamTimingStats struct {
+	ProviderID     uint64  `json:"providerID"`
+	Attempts       uint64  `json:"attempts"`
+	NewConns       uint64  `json:"newConns"`
+	ReusedConns    uint64  `json:"reusedConns"`
+	AvgDNSMs       float64 `json:"avgDnsMs"`
+	AvgConnectMs   float64 `json:"avgConnectMs"`
+	AvgTLSMs       float64 `json:"avgTlsMs"`
+	AvgFirstByteMs float64 `json:"avgFirstByteMs"`
+}
+
 // APIToken API 访问令牌
 type APIToken struct {
 	ID        uint64    `json:"id"`
@@ -512,9 +1417,19 @@ type APIToken struct {
 	// 关联的项目 ID，0 表示使用全局路由
 	ProjectID uint64 `json:"projectID"`
 
+	// 默认客户端类型，请求路径和 X-Maxx-Client-Type 请求头都无法确定类型时使用，为空时按请求体推断
+	DefaultClientType ClientType `json:"defaultClientType,omitempty"`
+
 	// 是否启用
 	IsEnabled bool `json:"isEnabled"`
 
+	// 是否允许作为管理 API 凭证使用
+	// 关联了项目时只能访问该项目的路由、请求和统计
+	AdminAccess bool `json:"adminAccess"`
+
+	// 未关联项目的管理凭证是否可访问全部数据，需显式开启，未开启时无法访问管理 API
+	GlobalAdmin bool `json:"globalAdmin"`
+
 	// 过期时间，nil 表示永不过期
 	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
 
@@ -528,6 +1443,11 @@ type APIToken struct {
 	DeletedAt *time.Time `json:"deletedAt,omitempty"`
 }
 
+// IsGlobalAdmin 是否为可访问全部数据的管理凭证
+func (t *APIToken) IsGlobalAdmin() bool {
+	return t.AdminAccess && t.GlobalAdmin && t.ProjectID == 0
+}
+
 // APITokenCreateResult 创建 Token 的返回结果（包含明文 Token，仅返回一次）
 type APITokenCreateResult struct {
 	Token    string    `json:"token"`    // 明文 Token（仅创建时返回）
@@ -568,16 +1488,28 @@ type ModelMapping struct {
 	APITokenID   uint64     `json:"apiTokenID,omitempty"`   // Token ID，0 表示所有
 
 	// 映射规则
-	Pattern string `json:"pattern"` // 源模式，支持通配符 *
+	Pattern string `json:"pattern"` // 源模式，支持通配符 *，或以 re: 开头的正则表达式
 	Target  string `json:"target"`  // 目标模型
 
+	// 加权目标（可选）：设置后每个请求按权重从中选择目标模型，取代 Target，用于按比例灰度新模型
+	Targets []ModelMappingTarget `json:"targets,omitempty"`
+
+	// 同一会话始终选择相同的加权目标，避免会话中途切换模型
+	StickySession bool `json:"stickySession,omitempty"`
+
 	// 优先级，数字越小优先级越高
 	Priority int `json:"priority"`
 }
 
+// ModelMappingTarget 加权映射目标
+type ModelMappingTarget struct {
+	Target string `json:"target"` // 目标模型，正则规则同样可引用捕获组
+	Weight int    `json:"weight"` // 权重，按全部目标的权重之和计算比例
+}
+
 // ModelMappingRule 简化的映射规则（用于 API 和内部逻辑）
 type ModelMappingRule struct {
-	Pattern string `json:"pattern"` // 源模式，支持通配符 *
+	Pattern string `json:"pattern"` // 源模式，支持通配符 *，或以 re: 开头的正则表达式
 	Target  string `json:"target"`  // 目标模型
 }
 
@@ -756,3 +1688,34 @@ type DashboardData struct {
 	ProviderStats map[uint64]DashboardProviderStats `json:"providerStats"`
 	Timezone      string                            `json:"timezone"` // 配置的时区，如 "Asia/Shanghai"
 }
+
+// DashboardLiveTotals 实时推送的用量合计，在增量消息中为变化量（可能为负）
+type DashboardLiveTotals struct {
+	Requests   int64 `json:"requests"`
+	Successful int64 `json:"successful"`
+	Tokens     int64 `json:"tokens"` // input + output + cacheRead + cacheWrite
+	Cost       int64 `json:"cost"`
+}
+
+// DashboardMinuteDelta 单个分钟时间桶的用量变化
+type DashboardMinuteDelta struct {
+	Minute int64 `json:"t"` // 时间桶（Unix 秒）
+	DashboardLiveTotals
+}
+
+// DashboardLiveSnapshot 订阅实时仪表盘时下发的初始快照，之后按 Seq 依次累加增量
+type DashboardLiveSnapshot struct {
+	Seq   uint64              `json:"seq"`
+	Date  string              `json:"date"` // 今日日期（配置的时区），如 "2026-03-01"
+	Today DashboardLiveTotals `json:"today"`
+}
+
+// DashboardDelta 分钟聚合后推送的仪表盘增量
+// Seq 不连续或 Reset 为 true（跨天、重新加载）时客户端应重新获取快照
+type DashboardDelta struct {
+	Seq     uint64                 `json:"seq"`
+	Date    string                 `json:"date"`
+	Reset   bool                   `json:"reset,omitempty"`
+	Today   DashboardLiveTotals    `json:"today"`
+	Minutes []DashboardMinuteDelta `json:"minutes,omitempty"`
+}
@@ -0,0 +1,160 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ModelMappingRegexPrefix 以该前缀开头的 Pattern 按正则表达式匹配，Target 中可用 $1、${name} 引用捕获组
+const ModelMappingRegexPrefix = "re:"
+
+// 已编译的正则缓存，key 为去掉前缀后的表达式
+var modelMappingRegexCache sync.Map
+
+// compileModelMappingRegex 编译映射规则的正则（整体匹配），结果会被缓存
+func compileModelMappingRegex(expr string) (*regexp.Regexp, error) {
+	if cached, ok := modelMappingRegexCache.Load(expr); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, err
+	}
+	modelMappingRegexCache.Store(expr, re)
+	return re, nil
+}
+
+// ValidateModelMappingPattern 校验映射规则的 Pattern，正则规则必须能正常编译
+func ValidateModelMappingPattern(pattern string) error {
+	expr, ok := strings.CutPrefix(pattern, ModelMappingRegexPrefix)
+	if !ok {
+		return nil
+	}
+	if _, err := compileModelMappingRegex(expr); err != nil {
+		return fmt.Errorf("invalid regex pattern: %w", err)
+	}
+	return nil
+}
+
+// MatchModelMapping 检查 input 是否匹配规则，匹配时返回映射后的目标模型
+// 正则规则（re: 前缀）会把 Target 中的捕获组引用替换为实际值，无效正则视为不匹配
+// 其余规则按通配符匹配，直接返回 Target
+func MatchModelMapping(pattern, target, input string) (string, bool) {
+	expr, ok := strings.CutPrefix(pattern, ModelMappingRegexPrefix)
+	if !ok {
+		if MatchWildcard(pattern, input) {
+			return target, true
+		}
+		return "", false
+	}
+
+	re, err := compileModelMappingRegex(expr)
+	if err != nil {
+		return "", false
+	}
+	match := re.FindStringSubmatchIndex(input)
+	if match == nil {
+		return "", false
+	}
+	return string(re.ExpandString(nil, target, input, match)), true
+}
+
+// ModelMappingPatternsOverlap 判断两条规则的 Pattern 是否可能匹配同一个模型
+// 通配符模式之间精确判断；正则只能对不含通配符的模式直接匹配，
+// 两条正则或正则与通配符模式无法判断，仅在 Pattern 相同时视为重叠
+func ModelMappingPatternsOverlap(a, b string) bool {
+	if a == b {
+		return true
+	}
+	aRegex := strings.HasPrefix(a, ModelMappingRegexPrefix)
+	bRegex := strings.HasPrefix(b, ModelMappingRegexPrefix)
+	switch {
+	case aRegex && bRegex:
+		return false
+	case aRegex, bRegex:
+		if bRegex {
+			a, b = b, a
+		}
+		if containsWildcard(b) {
+			return false
+		}
+		_, ok := MatchModelMapping(a, "", b)
+		return ok
+	}
+	return wildcardsOverlap(a, b)
+}
+
+// wildcardsOverlap 判断是否存在同时匹配两个通配符模式的字符串
+func wildcardsOverlap(a, b string) bool {
+	visited := make(map[[2]int]bool)
+	var walk func(i, j int) bool
+	walk = func(i, j int) bool {
+		if i == len(a) && j == len(b) {
+			return true
+		}
+		if visited[[2]int{i, j}] {
+			return false
+		}
+		visited[[2]int{i, j}] = true
+		switch {
+		case i < len(a) && a[i] == '*':
+			// * 匹配空串，或吞下另一模式的一个字符
+			return walk(i+1, j) || (j < len(b) && walk(i, j+1))
+		case j < len(b) && b[j] == '*':
+			return walk(i, j+1) || (i < len(a) && walk(i+1, j))
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			return walk(i+1, j+1)
+		}
+		return false
+	}
+	return walk(0, 0)
+}
+
+// ValidateModelMappingTargets 校验加权目标：目标不能为空，权重必须为正数
+func ValidateModelMappingTargets(targets []ModelMappingTarget) error {
+	for _, t := range targets {
+		if t.Target == "" {
+			return errors.New("weighted target cannot be empty")
+		}
+		if t.Weight <= 0 {
+			return fmt.Errorf("weight of target %s must be positive", t.Target)
+		}
+	}
+	return nil
+}
+
+// PickTarget 返回本次请求使用的目标（正则规则的捕获组尚未替换）
+// 未设置加权目标时返回 Target；否则按权重随机选择，
+// StickySession 开启且 sessionID 非空时按会话哈希选择，同一会话始终得到相同目标
+func (m *ModelMapping) PickTarget(sessionID string) string {
+	total := 0
+	for _, t := range m.Targets {
+		total += t.Weight
+	}
+	if total <= 0 {
+		return m.Target
+	}
+
+	var n int
+	if m.StickySession && sessionID != "" {
+		// 按规则区分哈希，使不同规则的灰度互不相关
+		h := fnv.New64a()
+		h.Write([]byte(strconv.FormatUint(m.ID, 10) + ":" + sessionID))
+		n = int(h.Sum64() % uint64(total))
+	} else {
+		n = rand.IntN(total)
+	}
+	for _, t := range m.Targets {
+		if n < t.Weight {
+			return t.Target
+		}
+		n -= t.Weight
+	}
+	return m.Target
+}
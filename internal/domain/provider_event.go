@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// ProviderEventType is the kind of provider lifecycle event
+type ProviderEventType string
+
+const (
+	ProviderEventCooldownEntered  ProviderEventType = "cooldown_entered"  // Provider started cooling down
+	ProviderEventRecovered        ProviderEventType = "recovered"         // Cooldown expired or a request succeeded
+	ProviderEventCooldownCleared  ProviderEventType = "cooldown_cleared"  // Cooldown cleared by an operator or config change
+	ProviderEventCredentialFailed ProviderEventType = "credential_failed" // Upstream rejected the credentials on refresh
+)
+
+// ProviderEvent records a provider lifecycle transition, the operational history
+// of a provider for post-incident review
+type ProviderEvent struct {
+	ID         uint64            `json:"id"`
+	CreatedAt  time.Time         `json:"createdAt"` // When the transition happened
+	ProviderID uint64            `json:"providerID"`
+	ClientType string            `json:"clientType"` // Empty when the whole provider is concerned
+	Type       ProviderEventType `json:"type"`
+	Reason     string            `json:"reason,omitempty"`    // Cooldown reason
+	UntilTime  *time.Time        `json:"untilTime,omitempty"` // End of the cooldown entered
+	Detail     string            `json:"detail,omitempty"`
+}
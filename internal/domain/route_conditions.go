@@ -0,0 +1,144 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RouteConditions 路由的动态生效条件，按当前时间（系统时区）和当前负载决定路由是否参与匹配
+// 例如工作时间使用高级供应商、闲时使用便宜的供应商，或在请求速率超过阈值时溢出到备用供应商
+// 各项条件同时满足时路由生效，未配置的条件视为满足
+type RouteConditions struct {
+	// 生效时间段，格式 "HH:MM-HH:MM"，包含开始不包含结束，结束早于开始表示跨越午夜；满足任一时间段即可，空表示全天
+	TimeWindows []string `json:"timeWindows,omitempty"`
+
+	// 生效的星期（0 为周日，6 为周六），按当前日期判断，空表示每天
+	Weekdays []int `json:"weekdays,omitempty"`
+
+	// 处理中的请求数（含当前请求）不低于该值时生效，0 表示不限制
+	MinInFlight int64 `json:"minInFlight,omitempty"`
+
+	// 处理中的请求数（含当前请求）低于该值时生效，0 表示不限制
+	MaxInFlight int64 `json:"maxInFlight,omitempty"`
+
+	// 最近一分钟的请求数（含当前请求）不低于该值时生效，0 表示不限制
+	MinRPM int64 `json:"minRPM,omitempty"`
+
+	// 最近一分钟的请求数（含当前请求）低于该值时生效，0 表示不限制
+	MaxRPM int64 `json:"maxRPM,omitempty"`
+
+	// 条件不满足时的处理：false 跳过该路由，true 仍保留该路由但排到所有生效的路由之后
+	DemoteWhenUnmet bool `json:"demoteWhenUnmet,omitempty"`
+}
+
+// RouteLoad 评估路由条件时的当前负载
+type RouteLoad struct {
+	InFlight  int64
+	RecentRPM int64
+}
+
+// Validate 校验时间段、星期和负载阈值
+func (c *RouteConditions) Validate() error {
+	if c == nil {
+		return nil
+	}
+	for _, window := range c.TimeWindows {
+		if _, _, err := parseTimeWindow(window); err != nil {
+			return err
+		}
+	}
+	for _, day := range c.Weekdays {
+		if day < 0 || day > 6 {
+			return fmt.Errorf("invalid weekday %d, must be between 0 (Sunday) and 6 (Saturday)", day)
+		}
+	}
+	for _, bound := range []struct {
+		name     string
+		min, max int64
+	}{
+		{"in-flight", c.MinInFlight, c.MaxInFlight},
+		{"RPM", c.MinRPM, c.MaxRPM},
+	} {
+		if bound.min < 0 || bound.max < 0 {
+			return fmt.Errorf("%s thresholds must not be negative", bound.name)
+		}
+		if bound.max > 0 && bound.min >= bound.max {
+			return fmt.Errorf("min %s %d must be lower than max %s %d", bound.name, bound.min, bound.name, bound.max)
+		}
+	}
+	return nil
+}
+
+// Met 判断条件在 now（已转换到系统时区）和 load 下是否满足，nil 表示无条件
+func (c *RouteConditions) Met(now time.Time, load RouteLoad) bool {
+	if c == nil {
+		return true
+	}
+	if !inRange(load.InFlight, c.MinInFlight, c.MaxInFlight) || !inRange(load.RecentRPM, c.MinRPM, c.MaxRPM) {
+		return false
+	}
+	if len(c.Weekdays) > 0 {
+		today := false
+		for _, day := range c.Weekdays {
+			if time.Weekday(day) == now.Weekday() {
+				today = true
+				break
+			}
+		}
+		if !today {
+			return false
+		}
+	}
+	if len(c.TimeWindows) == 0 {
+		return true
+	}
+	minute := now.Hour()*60 + now.Minute()
+	for _, window := range c.TimeWindows {
+		start, end, err := parseTimeWindow(window)
+		if err != nil {
+			continue
+		}
+		if start <= end && minute >= start && minute < end {
+			return true
+		}
+		if start > end && (minute >= start || minute < end) {
+			return true
+		}
+	}
+	return false
+}
+
+func inRange(value, min, max int64) bool {
+	return value >= min && (max == 0 || value < max)
+}
+
+// parseTimeWindow 解析 "HH:MM-HH:MM"，返回一天中的起止分钟数
+func parseTimeWindow(window string) (start, end int, err error) {
+	from, to, ok := strings.Cut(window, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid time window %q, expected HH:MM-HH:MM", window)
+	}
+	if start, err = parseClock(from); err != nil {
+		return 0, 0, fmt.Errorf("invalid time window %q: %w", window, err)
+	}
+	if end, err = parseClock(to); err != nil {
+		return 0, 0, fmt.Errorf("invalid time window %q: %w", window, err)
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("invalid time window %q, start and end are the same", window)
+	}
+	return start, end, nil
+}
+
+// parseClock 解析 "HH:MM"，"24:00" 表示一天结束
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		if strings.TrimSpace(s) == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StatusMapping 返回给客户端的响应状态码映射，key 为上游（或错误）状态码，value 为客户端看到的状态码
+// 只改写客户端响应，尝试记录中保留上游的原始状态码用于统计
+type StatusMapping map[int]int
+
+// ParseStatusMapping 解析全局状态码映射设置，格式为逗号分隔的 "上游:客户端"，如 "529:503,524:504"
+func ParseStatusMapping(value string) (StatusMapping, error) {
+	mapping := StatusMapping{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid status mapping %q, expected from:to", pair)
+		}
+		fromCode, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status mapping %q: %w", pair, err)
+		}
+		toCode, err := strconv.Atoi(strings.TrimSpace(to))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status mapping %q: %w", pair, err)
+		}
+		mapping[fromCode] = toCode
+	}
+	return mapping, mapping.Validate()
+}
+
+// Validate 校验映射中的状态码均为有效的 HTTP 状态码（100-599）
+func (m StatusMapping) Validate() error {
+	for from, to := range m {
+		if !validStatusCode(from) || !validStatusCode(to) {
+			return fmt.Errorf("invalid status mapping %d:%d, status codes must be between 100 and 599", from, to)
+		}
+	}
+	return nil
+}
+
+// Map 返回 status 映射后的状态码，未配置映射时 ok 为 false
+func (m StatusMapping) Map(status int) (mapped int, ok bool) {
+	mapped, ok = m[status]
+	return mapped, ok
+}
+
+func validStatusCode(code int) bool {
+	return code >= 100 && code <= 599
+}
@@ -0,0 +1,81 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// cacheParams are the top-level request parameters that steer prompt caching and
+// abuse detection upstream, per format accepting them. Chat completions and the
+// Responses API share prompt_cache_key and safety_identifier, Gemini references an
+// explicit cache by cachedContent. Claude has no top-level equivalent.
+var cacheParams = map[domain.ClientType][]string{
+	domain.ClientTypeOpenAI: {"prompt_cache_key", "safety_identifier"},
+	domain.ClientTypeCodex:  {"prompt_cache_key", "safety_identifier"},
+	domain.ClientTypeGemini: {"cachedContent"},
+}
+
+// applyCacheParams forwards the cache params of the client's original request to
+// the upstream body when the target format has them and the provider doesn't list
+// them as unsupported, restoring any that format conversion dropped. Params the
+// upstream can't take are removed. It returns the rewritten body and the names of
+// the client's params that were not forwarded.
+func applyCacheParams(original, body []byte, originalType, targetType domain.ClientType, unsupported []string) ([]byte, []string) {
+	names := cacheParams[originalType]
+	if len(names) == 0 || len(original) == 0 || len(body) == 0 {
+		return body, nil
+	}
+
+	var orig map[string]json.RawMessage
+	if err := json.Unmarshal(original, &orig); err != nil {
+		return body, nil
+	}
+	var present []string
+	for _, name := range names {
+		if _, ok := orig[name]; ok {
+			present = append(present, name)
+		}
+	}
+	if len(present) == 0 {
+		return body, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var req map[string]interface{}
+	if err := dec.Decode(&req); err != nil {
+		return body, nil
+	}
+
+	changed := false
+	var dropped []string
+	for _, name := range present {
+		_, inBody := req[name]
+		if slices.Contains(cacheParams[targetType], name) && !slices.Contains(unsupported, name) {
+			if !inBody {
+				req[name] = orig[name]
+				changed = true
+			}
+			continue
+		}
+		dropped = append(dropped, name)
+		if inBody {
+			delete(req, name)
+			changed = true
+		}
+	}
+	if !changed {
+		return body, dropped
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(req); err != nil {
+		return body, dropped
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), dropped
+}
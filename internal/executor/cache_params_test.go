@@ -0,0 +1,73 @@
+package executor
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+const openAICacheRequest = `{"model":"gpt-4o","messages":[],"prompt_cache_key":"conv-1","safety_identifier":"user-hash"}`
+
+func TestApplyCacheParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		original    string
+		body        string // upstream body after conversion, the original when empty
+		from, to    domain.ClientType
+		unsupported []string
+		present     []string
+		dropped     []string
+	}{
+		{"openai forwards both", openAICacheRequest, "", domain.ClientTypeOpenAI, domain.ClientTypeOpenAI, nil,
+			[]string{"prompt_cache_key", "safety_identifier"}, nil},
+		{"codex restores after conversion", openAICacheRequest, `{"model":"gpt-4o","input":[]}`, domain.ClientTypeOpenAI, domain.ClientTypeCodex, nil,
+			[]string{"prompt_cache_key", "safety_identifier"}, nil},
+		{"provider rejects safety_identifier", openAICacheRequest, "", domain.ClientTypeOpenAI, domain.ClientTypeOpenAI, []string{"safety_identifier"},
+			[]string{"prompt_cache_key"}, []string{"safety_identifier"}},
+		{"claude drops both", openAICacheRequest, `{"model":"gpt-4o","messages":[],"prompt_cache_key":"conv-1"}`, domain.ClientTypeOpenAI, domain.ClientTypeClaude, nil,
+			nil, []string{"prompt_cache_key", "safety_identifier"}},
+		{"gemini forwards cachedContent", `{"contents":[],"cachedContent":"cachedContents/abc"}`, "", domain.ClientTypeGemini, domain.ClientTypeGemini, nil,
+			[]string{"cachedContent"}, nil},
+		{"gemini cache not portable to openai", `{"contents":[],"cachedContent":"cachedContents/abc"}`, `{"messages":[]}`, domain.ClientTypeGemini, domain.ClientTypeOpenAI, nil,
+			nil, []string{"cachedContent"}},
+		{"no params", `{"model":"gpt-4o","messages":[]}`, "", domain.ClientTypeOpenAI, domain.ClientTypeClaude, nil, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := tt.body
+			if body == "" {
+				body = tt.original
+			}
+			got, dropped := applyCacheParams([]byte(tt.original), []byte(body), tt.from, tt.to, tt.unsupported)
+			if !slices.Equal(dropped, tt.dropped) {
+				t.Errorf("dropped = %v, want %v", dropped, tt.dropped)
+			}
+			var req map[string]interface{}
+			if err := json.Unmarshal(got, &req); err != nil {
+				t.Fatalf("invalid body %s: %v", got, err)
+			}
+			for _, name := range []string{"prompt_cache_key", "safety_identifier", "cachedContent"} {
+				if _, ok := req[name]; ok != slices.Contains(tt.present, name) {
+					t.Errorf("param %q present = %v (body %s)", name, ok, got)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyCacheParamsKeepsValues(t *testing.T) {
+	body, _ := applyCacheParams([]byte(openAICacheRequest), []byte(`{"input":[]}`), domain.ClientTypeOpenAI, domain.ClientTypeCodex, nil)
+	want := `{"input":[],"prompt_cache_key":"conv-1","safety_identifier":"user-hash"}`
+	if string(body) != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+
+	// Untouched bodies are returned as is
+	original := []byte(openAICacheRequest)
+	if body, _ := applyCacheParams(original, original, domain.ClientTypeOpenAI, domain.ClientTypeOpenAI, nil); string(body) != openAICacheRequest {
+		t.Errorf("unchanged body rewritten: %s", body)
+	}
+}
@@ -0,0 +1,69 @@
+package executor
+
+import (
+	"context"
+	"time"
+
+	ctxutil "github.com/awsl-project/maxx/internal/context"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// track makes the request cancellable with CancelRequest and CancelAll until the
+// returned function is called
+func (e *Executor) track(ctx context.Context, proxyReq *domain.ProxyRequest) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	e.active.Store(proxyReq, cancel)
+	return ctx, func() {
+		e.active.Delete(proxyReq)
+		cancel(nil)
+	}
+}
+
+// CancelRequest cancels the in-flight request with the given ID on this instance,
+// reporting whether it was found
+func (e *Executor) CancelRequest(id uint64, reason domain.CancelReason) bool {
+	found := false
+	e.active.Range(func(key, value any) bool {
+		if key.(*domain.ProxyRequest).ID == id {
+			value.(context.CancelCauseFunc)(reason)
+			found = true
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// CancelAll cancels every in-flight request, returning how many were cancelled
+func (e *Executor) CancelAll(reason domain.CancelReason) int {
+	n := 0
+	e.active.Range(func(_, value any) bool {
+		value.(context.CancelCauseFunc)(reason)
+		n++
+		return true
+	})
+	return n
+}
+
+// markCancelled records the cancellation of the request and its reason.
+// clientMessage is the error recorded when the client disconnected.
+func markCancelled(ctx context.Context, proxyReq *domain.ProxyRequest, clientMessage string) {
+	proxyReq.Status = "CANCELLED"
+	proxyReq.CancelReason = ctxutil.GetCancelReason(ctx)
+	proxyReq.EndTime = time.Now()
+	proxyReq.Duration = proxyReq.EndTime.Sub(proxyReq.StartTime)
+	proxyReq.Error = cancelMessage(proxyReq.CancelReason, clientMessage)
+}
+
+// cancelMessage describes a cancellation, clientMessage is used for client disconnects
+func cancelMessage(reason domain.CancelReason, clientMessage string) string {
+	switch reason {
+	case domain.CancelReasonAdmin:
+		return "cancelled by an admin"
+	case domain.CancelReasonShutdown:
+		return "cancelled by server shutdown"
+	case domain.CancelReasonResumeAbandoned:
+		return "resumable stream abandoned, no client reconnected"
+	}
+	return clientMessage
+}
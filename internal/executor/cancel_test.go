@@ -0,0 +1,75 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestCancellationReasons(t *testing.T) {
+	tests := []struct {
+		name      string
+		cancel    func(e *Executor, proxyReq *domain.ProxyRequest, parent context.CancelCauseFunc)
+		want      domain.CancelReason
+		wantError string
+	}{
+		{"client disconnect", func(_ *Executor, _ *domain.ProxyRequest, parent context.CancelCauseFunc) {
+			parent(nil)
+		}, domain.CancelReasonClientDisconnect, "client disconnected"},
+		{"resumable stream abandoned", func(_ *Executor, _ *domain.ProxyRequest, parent context.CancelCauseFunc) {
+			parent(domain.CancelReasonResumeAbandoned)
+		}, domain.CancelReasonResumeAbandoned, "resumable stream abandoned, no client reconnected"},
+		{"admin", func(e *Executor, proxyReq *domain.ProxyRequest, _ context.CancelCauseFunc) {
+			if !e.CancelRequest(proxyReq.ID, domain.CancelReasonAdmin) {
+				t.Error("in-flight request not found")
+			}
+		}, domain.CancelReasonAdmin, "cancelled by an admin"},
+		{"shutdown", func(e *Executor, _ *domain.ProxyRequest, _ context.CancelCauseFunc) {
+			if n := e.CancelAll(domain.CancelReasonShutdown); n != 1 {
+				t.Errorf("CancelAll cancelled %d requests, want 1", n)
+			}
+		}, domain.CancelReasonShutdown, "cancelled by server shutdown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Executor{}
+			proxyReq := &domain.ProxyRequest{ID: 7}
+			parent, cancelParent := context.WithCancelCause(context.Background())
+			defer cancelParent(nil)
+			ctx, untrack := e.track(parent, proxyReq)
+			defer untrack()
+
+			tt.cancel(e, proxyReq, cancelParent)
+			if ctx.Err() == nil {
+				t.Fatal("request not cancelled")
+			}
+
+			markCancelled(ctx, proxyReq, "client disconnected")
+			if proxyReq.Status != "CANCELLED" || proxyReq.CancelReason != tt.want || proxyReq.Error != tt.wantError {
+				t.Errorf("request = %s %q %q, want CANCELLED %q %q", proxyReq.Status, proxyReq.CancelReason, proxyReq.Error, tt.want, tt.wantError)
+			}
+			// Execute returns the cause, so the proxy handler can tell the client why
+			if tt.want != domain.CancelReasonClientDisconnect && !errors.Is(context.Cause(ctx), tt.want) {
+				t.Errorf("cause = %v, want %v", context.Cause(ctx), tt.want)
+			}
+		})
+	}
+}
+
+func TestCancelRequestUnknown(t *testing.T) {
+	e := &Executor{}
+	ctx, untrack := e.track(context.Background(), &domain.ProxyRequest{ID: 1})
+
+	if e.CancelRequest(2, domain.CancelReasonAdmin) {
+		t.Error("cancelled a request that isn't in flight")
+	}
+	untrack()
+	if e.CancelRequest(1, domain.CancelReasonAdmin) || e.CancelAll(domain.CancelReasonShutdown) != 0 {
+		t.Error("cancelled a finished request")
+	}
+	if ctx.Err() == nil {
+		t.Error("context of a finished request still live")
+	}
+}
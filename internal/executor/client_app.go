@@ -0,0 +1,84 @@
+package executor
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// maxClientAppName bounds the stored app name, the column holds 64 characters
+const maxClientAppName = 48
+
+var (
+	// productToken matches the leading "name/version" product of a User-Agent
+	productToken = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9._-]*)/v?(\d+(?:\.\d+)?)`)
+	// sdkUserAgent matches the User-Agent of the official SDKs, e.g. "Anthropic/JS 0.39.0", "OpenAI/Python 1.40.0"
+	sdkUserAgent = regexp.MustCompile(`^(Anthropic|OpenAI)/([A-Za-z]+) v?(\d+(?:\.\d+)?)`)
+	// leadingVersion extracts major.minor from a version string
+	leadingVersion = regexp.MustCompile(`^v?(\d+(?:\.\d+)?)`)
+	// appNameInvalid matches characters dropped from app names
+	appNameInvalid = regexp.MustCompile(`[^a-z0-9._-]+`)
+)
+
+// genericUserAgents are HTTP libraries and browsers that don't identify the calling app
+var genericUserAgents = map[string]bool{
+	"mozilla":         true,
+	"node":            true,
+	"node-fetch":      true,
+	"undici":          true,
+	"axios":           true,
+	"go-http-client":  true,
+	"python-requests": true,
+	"python-httpx":    true,
+	"aiohttp":         true,
+	"curl":            true,
+	"okhttp":          true,
+	"java":            true,
+	"reqwest":         true,
+	"bun":             true,
+	"deno":            true,
+}
+
+// extractClientApp identifies the client application from the User-Agent and the
+// X-Stainless-* headers the official SDKs send. Versions are cut to major.minor so
+// the number of distinct values stays small. Returns "" when nothing identifies the app.
+//
+// Examples:
+//   - "claude-cli/2.0.14 (external, cli)" -> "claude-cli/2.0"
+//   - "Anthropic/JS 0.39.0" -> "anthropic-js/0.39"
+//   - "node-fetch/1.0" with X-Stainless-Lang: python, X-Stainless-Package-Version: 1.40.2 -> "sdk-python/1.40"
+func extractClientApp(headers http.Header) string {
+	userAgent := strings.TrimSpace(headers.Get("User-Agent"))
+
+	if m := sdkUserAgent.FindStringSubmatch(userAgent); m != nil {
+		return clientAppName(m[1]+"-"+m[2], m[3])
+	}
+	if m := productToken.FindStringSubmatch(userAgent); m != nil && !genericUserAgents[strings.ToLower(m[1])] {
+		return clientAppName(m[1], m[2])
+	}
+
+	if lang := headers.Get("X-Stainless-Lang"); lang != "" {
+		version := ""
+		if m := leadingVersion.FindStringSubmatch(headers.Get("X-Stainless-Package-Version")); m != nil {
+			version = m[1]
+		}
+		return clientAppName("sdk-"+lang, version)
+	}
+	return ""
+}
+
+// clientAppName normalizes an app name and joins it with its version
+func clientAppName(name, version string) string {
+	name = appNameInvalid.ReplaceAllString(strings.ToLower(name), "-")
+	name = strings.Trim(name, "-")
+	if len(name) > maxClientAppName {
+		name = name[:maxClientAppName]
+	}
+	if name == "" {
+		return ""
+	}
+	if version == "" {
+		return name
+	}
+	return name + "/" + version
+}
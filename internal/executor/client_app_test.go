@@ -0,0 +1,44 @@
+package executor
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestExtractClientApp(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"claude cli", map[string]string{"User-Agent": "claude-cli/2.0.14 (external, cli)"}, "claude-cli/2.0"},
+		{"anthropic js sdk", map[string]string{"User-Agent": "Anthropic/JS 0.39.0", "X-Stainless-Lang": "js"}, "anthropic-js/0.39"},
+		{"openai python sdk", map[string]string{"User-Agent": "OpenAI/Python 1.40.2"}, "openai-python/1.40"},
+		{"major version only", map[string]string{"User-Agent": "Cursor/1 (darwin)"}, "cursor/1"},
+		{"generic client falls back to stainless", map[string]string{
+			"User-Agent":                  "node-fetch/1.0",
+			"X-Stainless-Lang":            "python",
+			"X-Stainless-Package-Version": "1.40.2",
+		}, "sdk-python/1.40"},
+		{"stainless without version", map[string]string{"X-Stainless-Lang": "go"}, "sdk-go"},
+		{"browser", map[string]string{"User-Agent": "Mozilla/5.0 (Macintosh)"}, ""},
+		{"no headers", nil, ""},
+		{"unparseable", map[string]string{"User-Agent": "something weird"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := http.Header{}
+			for k, v := range tt.headers {
+				headers.Set(k, v)
+			}
+			if got := extractClientApp(headers); got != tt.want {
+				t.Errorf("extractClientApp = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := extractClientApp(nil); got != "" {
+		t.Errorf("nil headers = %q, want empty", got)
+	}
+}
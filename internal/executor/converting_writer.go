@@ -2,6 +2,7 @@ package executor
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 	"strings"
 
@@ -56,6 +57,10 @@ type ConvertingResponseWriter struct {
 	buffer       bytes.Buffer      // Buffer for non-streaming responses
 	streamState  *converter.TransformState
 	headersSent  bool
+
+	// toolCallPolicy enforces a single tool call for OpenAI clients that sent
+	// parallel_tool_calls: false (empty = no enforcement)
+	toolCallPolicy string
 }
 
 // NewConvertingResponseWriter creates a new ConvertingResponseWriter
@@ -77,6 +82,13 @@ func NewConvertingResponseWriter(
 	}
 }
 
+// SetToolCallPolicy enables single tool call enforcement on the converted response
+func (c *ConvertingResponseWriter) SetToolCallPolicy(policy string) {
+	if c.originalType == domain.ClientTypeOpenAI {
+		c.toolCallPolicy = policy
+	}
+}
+
 // Header returns the header map
 func (c *ConvertingResponseWriter) Header() http.Header {
 	return c.underlying.Header()
@@ -111,6 +123,10 @@ func (c *ConvertingResponseWriter) writeStream(b []byte) (int, error) {
 		return c.underlying.Write(b)
 	}
 
+	if c.toolCallPolicy != "" && len(converted) > 0 {
+		converted = converter.LimitOpenAIToolCallsChunk(converted, c.toolCallPolicy, c.streamState)
+	}
+
 	if len(converted) > 0 {
 		_, writeErr := c.underlying.Write(converted)
 		if writeErr != nil {
@@ -142,6 +158,15 @@ func (c *ConvertingResponseWriter) Finalize() error {
 	if err != nil {
 		// On conversion error, use original body
 		converted = body
+	} else if c.toolCallPolicy != "" {
+		limited, limitErr := converter.LimitOpenAIToolCalls(converted, c.toolCallPolicy)
+		if limitErr != nil {
+			c.statusCode = http.StatusBadGateway
+			limited, _ = json.Marshal(map[string]interface{}{
+				"error": map[string]string{"message": limitErr.Error(), "type": "upstream_error"},
+			})
+		}
+		converted = limited
 	}
 
 	// Update Content-Type header based on original client type
@@ -0,0 +1,37 @@
+package executor
+
+import (
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// DefaultCooldownCap bounds the cooldown an upstream can ask for through
+// Retry-After or a quota reset time when its provider doesn't set a cap
+const DefaultCooldownCap = 30 * time.Minute
+
+// cooldownCap returns the longest cooldown honored for the provider, 0 for no cap
+func cooldownCap(provider *domain.Provider) time.Duration {
+	if provider == nil || provider.Config == nil || provider.Config.MaxCooldownSecs == 0 {
+		return DefaultCooldownCap
+	}
+	if provider.Config.MaxCooldownSecs < 0 {
+		return 0
+	}
+	return time.Duration(provider.Config.MaxCooldownSecs) * time.Second
+}
+
+// capCooldownUntil clamps an upstream-requested cooldown end to now plus the
+// provider's cap, returning the clamped end and the duration originally asked
+// for, 0 when it was within the cap
+func capCooldownUntil(until time.Time, provider *domain.Provider) (time.Time, time.Duration) {
+	limit := cooldownCap(provider)
+	if limit <= 0 {
+		return until, 0
+	}
+	now := time.Now()
+	if requested := until.Sub(now); requested > limit {
+		return now.Add(limit), requested
+	}
+	return until, 0
+}
@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ctxutil "github.com/awsl-project/maxx/internal/context"
+	"github.com/awsl-project/maxx/internal/cooldown"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestHandleCooldownCapsRetryAfter(t *testing.T) {
+	tests := []struct {
+		name           string
+		providerID     uint64
+		config         *domain.ProviderConfig
+		retryAfter     time.Duration
+		wantCooldown   time.Duration
+		wantCappedFrom time.Duration
+	}{
+		{"oversized retry-after capped to the default", 9201, nil, 5 * time.Hour, DefaultCooldownCap, 5 * time.Hour},
+		{"oversized retry-after capped per provider", 9202, &domain.ProviderConfig{MaxCooldownSecs: 120}, time.Hour, 2 * time.Minute, time.Hour},
+		{"reasonable retry-after honored as-is", 9203, nil, 30 * time.Second, 30 * time.Second, 0},
+		{"no cap", 9204, &domain.ProviderConfig{MaxCooldownSecs: -1}, 5 * time.Hour, 5 * time.Hour, 0},
+	}
+
+	e := &Executor{}
+	ctx := ctxutil.WithClientType(context.Background(), domain.ClientTypeClaude)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer cooldown.Default().ClearCooldown(tt.providerID, "")
+			defer cooldown.Default().ClearCooldown(tt.providerID, "claude")
+
+			provider := &domain.Provider{ID: tt.providerID, Config: tt.config}
+			cappedFrom := e.handleCooldown(ctx, &domain.ProxyError{RetryAfter: tt.retryAfter}, provider)
+			if cappedFrom.Round(time.Second) != tt.wantCappedFrom {
+				t.Errorf("capped from = %v, want %v", cappedFrom, tt.wantCappedFrom)
+			}
+
+			for key, until := range cooldown.Default().GetAllCooldowns() {
+				if key.ProviderID != tt.providerID {
+					continue
+				}
+				if got := time.Until(until).Round(time.Second); got != tt.wantCooldown {
+					t.Errorf("cooldown = %v, want %v", got, tt.wantCooldown)
+				}
+				return
+			}
+			t.Error("provider not cooled down")
+		})
+	}
+}
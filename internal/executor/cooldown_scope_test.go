@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ctxutil "github.com/awsl-project/maxx/internal/context"
+	"github.com/awsl-project/maxx/internal/cooldown"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestHandleCooldownAppliesScopePolicy(t *testing.T) {
+	if err := cooldown.LoadScopePolicy(`{"quota_exhausted":"provider","server_error":"client_type"}`); err != nil {
+		t.Fatal(err)
+	}
+	defer cooldown.SetScopePolicy(nil)
+
+	resetTime := time.Now().Add(time.Hour)
+	tests := []struct {
+		name       string
+		providerID uint64
+		err        *domain.ProxyError
+		wantScope  string // cooled client type, "" for the whole provider
+	}{
+		{"quota exhausted cools the provider", 9101, &domain.ProxyError{
+			RateLimitInfo: &domain.RateLimitInfo{Type: "quota_exhausted", QuotaResetTime: resetTime, ClientType: "claude"},
+		}, ""},
+		{"server error cools the client type", 9102, &domain.ProxyError{IsServerError: true}, "claude"},
+		{"unconfigured reason cools the client type", 9103, &domain.ProxyError{IsNetworkError: true}, "claude"},
+	}
+
+	e := &Executor{}
+	ctx := ctxutil.WithClientType(context.Background(), domain.ClientTypeClaude)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer cooldown.Default().ClearCooldown(tt.providerID, "")
+			defer cooldown.Default().ClearCooldown(tt.providerID, tt.wantScope)
+
+			e.handleCooldown(ctx, tt.err, &domain.Provider{ID: tt.providerID})
+
+			var scopes []string
+			for key := range cooldown.Default().GetAllCooldowns() {
+				if key.ProviderID == tt.providerID {
+					scopes = append(scopes, key.ClientType)
+				}
+			}
+			if len(scopes) != 1 || scopes[0] != tt.wantScope {
+				t.Errorf("cooldown client types = %q, want [%q]", scopes, tt.wantScope)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package executor
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/awsl-project/maxx/internal/converter"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// EmptyResponseHeader marks successful responses without content in marker mode
+const EmptyResponseHeader = "X-Maxx-Empty-Response"
+
+// isEmptyResponse reports whether a response sent in the client's format succeeded without content
+func isEmptyResponse(clientType domain.ClientType, statusCode int, body string) bool {
+	return statusCode >= 200 && statusCode < 300 && !converter.HasContent(clientType, []byte(body))
+}
+
+// emptyResponseGate holds back a successful response until it is known to carry content,
+// so an empty one can still be retried or marked. Non-streaming responses are held until
+// the attempt finishes; streams are released at their first content event. Error responses
+// and streams that aren't SSE pass through untouched.
+type emptyResponseGate struct {
+	w          http.ResponseWriter
+	clientType domain.ClientType
+	isStream   bool
+	header     http.Header
+	statusCode int
+	held       bytes.Buffer
+	line       []byte // incomplete SSE line of the stream
+	released   bool
+}
+
+func newEmptyResponseGate(w http.ResponseWriter, clientType domain.ClientType, isStream bool) *emptyResponseGate {
+	return &emptyResponseGate{
+		w:          w,
+		clientType: clientType,
+		isStream:   isStream,
+		header:     make(http.Header),
+		statusCode: http.StatusOK,
+	}
+}
+
+// Header returns the gate's own header map until the response is released
+func (g *emptyResponseGate) Header() http.Header {
+	return g.header
+}
+
+// WriteHeader holds successful status codes and releases errors right away
+func (g *emptyResponseGate) WriteHeader(code int) {
+	if g.released {
+		g.w.WriteHeader(code)
+		return
+	}
+	g.statusCode = code
+	if code < 200 || code >= 300 {
+		g.release()
+	}
+}
+
+// Write holds the body until the response is released
+func (g *emptyResponseGate) Write(b []byte) (int, error) {
+	if g.released {
+		return g.w.Write(b)
+	}
+	g.held.Write(b)
+	if g.isStream && g.streamHasContent(b) {
+		if err := g.release(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush implements http.Flusher for streaming support, held output is not flushed
+func (g *emptyResponseGate) Flush() {
+	if !g.released {
+		return
+	}
+	if f, ok := g.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// streamHasContent scans the complete SSE lines of a chunk for a content event.
+// Streams that don't look like SSE can't be inspected and count as content.
+func (g *emptyResponseGate) streamHasContent(b []byte) bool {
+	g.line = append(g.line, b...)
+	for {
+		idx := bytes.IndexByte(g.line, '\n')
+		if idx < 0 {
+			return false
+		}
+		line := strings.TrimSpace(string(g.line[:idx]))
+		g.line = g.line[idx+1:]
+		switch {
+		case line == "", strings.HasPrefix(line, ":"), strings.HasPrefix(line, "event:"):
+		case strings.HasPrefix(line, "data:"):
+			if converter.StreamEventHasContent(g.clientType, []byte(strings.TrimPrefix(line, "data:"))) {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+}
+
+// release sends the held headers and body, then passes everything through
+func (g *emptyResponseGate) release() error {
+	if g.released {
+		return nil
+	}
+	g.released = true
+	dst := g.w.Header()
+	for k, v := range g.header {
+		dst[k] = v
+	}
+	g.header = dst
+	g.w.WriteHeader(g.statusCode)
+	if g.held.Len() == 0 {
+		return nil
+	}
+	_, err := g.w.Write(g.held.Bytes())
+	g.held.Reset()
+	return err
+}
+
+// finish decides on the held response once the attempt is done and reports whether it
+// was a successful response without content. A failed attempt's held output is dropped,
+// the error is reported to the client instead. An empty response is released with the
+// marker header in marker mode and dropped in retry mode.
+func (g *emptyResponseGate) finish(failed bool, mode string) bool {
+	if g.released {
+		return false
+	}
+	if failed {
+		return false
+	}
+	if !isEmptyResponse(g.clientType, g.statusCode, g.held.String()) {
+		_ = g.release()
+		return false
+	}
+	if mode == domain.EmptyResponseMarker {
+		g.header.Set(EmptyResponseHeader, "true")
+		_ = g.release()
+	}
+	return true
+}
+
+// emptyResponseHandling returns how successful responses without content are handled
+func (e *Executor) emptyResponseHandling() string {
+	if e.settingRepo != nil {
+		if val, err := e.settingRepo.Get(domain.SettingKeyEmptyResponseHandling); err == nil {
+			switch val {
+			case domain.EmptyResponseMarker, domain.EmptyResponseRetry:
+				return val
+			}
+		}
+	}
+	return domain.EmptyResponsePassthrough
+}
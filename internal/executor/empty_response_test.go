@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+const emptyClaudeMessage = `{"type":"message","content":[],"stop_reason":"end_turn"}`
+
+func TestEmptyResponseGateRetryDropsEmptyResponse(t *testing.T) {
+	rec := httptest.NewRecorder()
+	gate := newEmptyResponseGate(rec, domain.ClientTypeClaude, false)
+	gate.Header().Set("Content-Length", "57")
+	gate.WriteHeader(http.StatusOK)
+	gate.Write([]byte(emptyClaudeMessage))
+
+	if !gate.finish(false, domain.EmptyResponseRetry) {
+		t.Fatal("empty response not detected")
+	}
+	if rec.Body.Len() != 0 || rec.Header().Get("Content-Length") != "" {
+		t.Errorf("dropped response reached the client: headers %v body %q", rec.Header(), rec.Body.String())
+	}
+}
+
+func TestEmptyResponseGateMarker(t *testing.T) {
+	rec := httptest.NewRecorder()
+	gate := newEmptyResponseGate(rec, domain.ClientTypeClaude, false)
+	gate.WriteHeader(http.StatusOK)
+	gate.Write([]byte(emptyClaudeMessage))
+
+	if !gate.finish(false, domain.EmptyResponseMarker) {
+		t.Fatal("empty response not detected")
+	}
+	if rec.Header().Get(EmptyResponseHeader) != "true" || rec.Body.String() != emptyClaudeMessage {
+		t.Errorf("marked response = headers %v body %q", rec.Header(), rec.Body.String())
+	}
+}
+
+func TestEmptyResponseGateReleasesContent(t *testing.T) {
+	rec := httptest.NewRecorder()
+	gate := newEmptyResponseGate(rec, domain.ClientTypeOpenAI, false)
+	body := `{"choices":[{"message":{"content":"hi"}}]}`
+	gate.Write([]byte(body))
+
+	if rec.Body.Len() != 0 {
+		t.Error("non-streaming response released before the attempt finished")
+	}
+	if gate.finish(false, domain.EmptyResponseRetry) {
+		t.Error("response with content reported empty")
+	}
+	if rec.Code != http.StatusOK || rec.Body.String() != body {
+		t.Errorf("released response = %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestEmptyResponseGatePassesErrorsThrough(t *testing.T) {
+	rec := httptest.NewRecorder()
+	gate := newEmptyResponseGate(rec, domain.ClientTypeClaude, false)
+	gate.WriteHeader(http.StatusTooManyRequests)
+	gate.Write([]byte(`{"type":"error"}`))
+
+	if rec.Code != http.StatusTooManyRequests || rec.Body.String() != `{"type":"error"}` {
+		t.Errorf("error response = %d %q", rec.Code, rec.Body.String())
+	}
+	if gate.finish(true, domain.EmptyResponseRetry) {
+		t.Error("error response reported empty")
+	}
+}
+
+func TestEmptyResponseGateStream(t *testing.T) {
+	rec := httptest.NewRecorder()
+	gate := newEmptyResponseGate(rec, domain.ClientTypeClaude, true)
+	gate.WriteHeader(http.StatusOK)
+
+	start := "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"content\":[]}}\n\n"
+	gate.Write([]byte(start))
+	if rec.Body.Len() != 0 {
+		t.Fatal("stream released before any content")
+	}
+
+	// The content event is split across writes
+	gate.Write([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\","))
+	if rec.Body.Len() != 0 {
+		t.Fatal("stream released on an incomplete event")
+	}
+	gate.Write([]byte("\"delta\":{\"type\":\"text_delta\",\"text\":\"hi\"}}\n\n"))
+	if rec.Body.Len() == 0 {
+		t.Fatal("stream not released at its first content event")
+	}
+
+	gate.Write([]byte("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"))
+	if gate.finish(false, domain.EmptyResponseRetry) {
+		t.Error("stream with content reported empty")
+	}
+	if got := rec.Body.String(); got[:len(start)] != start {
+		t.Errorf("held events not sent first: %q", got)
+	}
+}
+
+func TestEmptyResponseGateEmptyStream(t *testing.T) {
+	rec := httptest.NewRecorder()
+	gate := newEmptyResponseGate(rec, domain.ClientTypeGemini, true)
+	gate.WriteHeader(http.StatusOK)
+	gate.Write([]byte("data: {\"candidates\":[{\"finishReason\":\"SAFETY\"}]}\n\n"))
+
+	if !gate.finish(false, domain.EmptyResponseRetry) {
+		t.Fatal("filtered stream not detected as empty")
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("empty stream reached the client: %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,301 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// maxEnsembleSize bounds the number of routes an ensemble runs in parallel
+const maxEnsembleSize = 5
+
+// ensembleSize returns the number of routes, starting with route, an ensemble runs on
+// out of the available ones; less than 2 means no ensemble
+func ensembleSize(route *domain.Route, available int) int {
+	return min(route.EnsembleSize, available, maxEnsembleSize)
+}
+
+// ensembleBuffer holds the response of an ensemble attempt until it is chosen
+type ensembleBuffer struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func newEnsembleBuffer() *ensembleBuffer {
+	return &ensembleBuffer{header: make(http.Header), statusCode: http.StatusOK}
+}
+
+func (b *ensembleBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *ensembleBuffer) WriteHeader(code int) {
+	b.statusCode = code
+}
+
+func (b *ensembleBuffer) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+// Flush implements http.Flusher, nothing is sent before the response is chosen
+func (b *ensembleBuffer) Flush() {}
+
+// writeTo sends the held response to the client
+func (b *ensembleBuffer) writeTo(w http.ResponseWriter) {
+	dst := w.Header()
+	for k, v := range b.header {
+		dst[k] = v
+	}
+	w.WriteHeader(b.statusCode)
+	_, _ = w.Write(b.body.Bytes())
+}
+
+// executeEnsemble runs an attempt on each of the plans in parallel, holding their
+// responses back, and sends the successful response picked by strategy to the client.
+// With the first strategy the remaining attempts are cancelled as soon as one succeeds.
+// The chosen attempt, or the first one when none succeeded, is returned for the regular
+// success/failure handling; the other attempts are finished here and returned as well.
+func (e *Executor) executeEnsemble(ctx context.Context, w http.ResponseWriter, req *http.Request, proxyReq *domain.ProxyRequest, plans []*routePlan, strategy string, singleToolCall bool) (*attemptRun, []*attemptRun) {
+	if strategy == "" {
+		strategy = domain.EnsembleStrategyFirst
+	}
+	runs := make([]*attemptRun, len(plans))
+	buffers := make([]*ensembleBuffer, len(plans))
+	cancels := make([]context.CancelFunc, len(plans))
+	done := make(chan int, len(plans))
+	for i, plan := range plans {
+		runs[i] = e.startAttempt(proxyReq, plan, 0)
+		if i > 0 {
+			runs[i].record.EnsembleOfAttemptID = runs[0].record.ID
+		}
+		runs[i].ctx, cancels[i] = context.WithCancel(runs[i].ctx)
+		buffers[i] = newEnsembleBuffer()
+		go func(i int) {
+			defer cancels[i]()
+			e.runAttempt(runs[i], buffers[i], req, singleToolCall)
+			done <- i
+		}(i)
+	}
+
+	// Collect the successful attempts in the order they finish
+	var succeeded []int
+	finished := make([]bool, len(plans))
+	cancelled := make([]bool, len(plans))
+	for range plans {
+		i := <-done
+		finished[i] = true
+		if runs[i].err != nil || cancelled[i] {
+			continue
+		}
+		succeeded = append(succeeded, i)
+		if strategy == domain.EnsembleStrategyFirst {
+			for j := range plans {
+				if !finished[j] && !cancelled[j] {
+					cancelled[j] = true
+					cancels[j]()
+				}
+			}
+		}
+	}
+
+	main := 0
+	if len(succeeded) > 0 {
+		bodies := make([][]byte, len(succeeded))
+		for k, i := range succeeded {
+			bodies[k] = buffers[i].body.Bytes()
+		}
+		main = succeeded[selectEnsembleResponse(strategy, plans[0].originalClientType, bodies)]
+		runs[main].record.EnsembleChosen = true
+		buffers[main].writeTo(w)
+		log.Printf("[Executor] Ensemble of %d routes chose provider %s (%d succeeded, strategy %q)",
+			len(plans), plans[main].route.Provider.Name, len(succeeded), strategy)
+	}
+
+	var others []*attemptRun
+	for i, run := range runs {
+		if i != main {
+			e.finishHedgedAttempt(ctx, run, cancelled[i])
+			others = append(others, run)
+		}
+	}
+	return runs[main], others
+}
+
+// recordEnsembleFailures adds the failed attempts among the other legs of an ensemble
+// to the route failures
+func recordEnsembleFailures(failures []domain.RouteFailure, legs []*attemptRun) []domain.RouteFailure {
+	for _, leg := range legs {
+		if leg.err == nil {
+			continue
+		}
+		statusCode := leg.record.StatusCode
+		if proxyErr, ok := leg.err.(*domain.ProxyError); ok && statusCode == 0 {
+			statusCode = proxyErr.HTTPStatusCode
+		}
+		failures = recordRouteFailure(failures, leg.plan.route, statusCode, leg.err)
+	}
+	return failures
+}
+
+// selectEnsembleResponse picks one of the successful responses of an ensemble, given in
+// the order they finished, and returns its index
+func selectEnsembleResponse(strategy string, clientType domain.ClientType, bodies [][]byte) int {
+	switch strategy {
+	case domain.EnsembleStrategyLongest:
+		best, bestLen := 0, -1
+		for i, body := range bodies {
+			text, _ := ensembleContent(clientType, body)
+			if len(text) > bestLen {
+				best, bestLen = i, len(text)
+			}
+		}
+		return best
+	case domain.EnsembleStrategyVote:
+		// Responses agreeing on the same tool calls (or on making none) vote together,
+		// the largest group wins and ties go to the group that finished first
+		keys := make([]string, len(bodies))
+		votes := make(map[string]int)
+		for i, body := range bodies {
+			_, calls := ensembleContent(clientType, body)
+			keys[i] = strings.Join(calls, "\n")
+			votes[keys[i]]++
+		}
+		best := 0
+		for i, key := range keys {
+			if votes[key] > votes[keys[best]] {
+				best = i
+			}
+		}
+		return best
+	default:
+		return 0
+	}
+}
+
+// ensembleContent extracts the text and the tool calls of a non-streaming response in
+// the client's format. Tool calls are given as "name args" with canonical JSON arguments,
+// sorted so that the order of parallel calls doesn't matter.
+func ensembleContent(clientType domain.ClientType, body []byte) (string, []string) {
+	var text strings.Builder
+	var calls []string
+	switch clientType {
+	case domain.ClientTypeClaude:
+		var msg struct {
+			Content []struct {
+				Type  string          `json:"type"`
+				Text  string          `json:"text"`
+				Name  string          `json:"name"`
+				Input json.RawMessage `json:"input"`
+			} `json:"content"`
+		}
+		_ = json.Unmarshal(body, &msg)
+		for _, block := range msg.Content {
+			switch block.Type {
+			case "text":
+				text.WriteString(block.Text)
+			case "tool_use":
+				calls = append(calls, toolCallKey(block.Name, block.Input))
+			}
+		}
+	case domain.ClientTypeOpenAI:
+		var resp struct {
+			Choices []struct {
+				Message struct {
+					Content   json.RawMessage `json:"content"`
+					ToolCalls []struct {
+						Function struct {
+							Name      string `json:"name"`
+							Arguments string `json:"arguments"`
+						} `json:"function"`
+					} `json:"tool_calls"`
+				} `json:"message"`
+			} `json:"choices"`
+		}
+		_ = json.Unmarshal(body, &resp)
+		if len(resp.Choices) > 0 {
+			msg := resp.Choices[0].Message
+			var content string
+			if json.Unmarshal(msg.Content, &content) == nil {
+				text.WriteString(content)
+			} else {
+				var parts []struct {
+					Text string `json:"text"`
+				}
+				_ = json.Unmarshal(msg.Content, &parts)
+				for _, part := range parts {
+					text.WriteString(part.Text)
+				}
+			}
+			for _, call := range msg.ToolCalls {
+				calls = append(calls, toolCallKey(call.Function.Name, json.RawMessage(call.Function.Arguments)))
+			}
+		}
+	case domain.ClientTypeGemini:
+		var resp struct {
+			Candidates []struct {
+				Content struct {
+					Parts []struct {
+						Text         string `json:"text"`
+						Thought      bool   `json:"thought"`
+						FunctionCall *struct {
+							Name string          `json:"name"`
+							Args json.RawMessage `json:"args"`
+						} `json:"functionCall"`
+					} `json:"parts"`
+				} `json:"content"`
+			} `json:"candidates"`
+		}
+		_ = json.Unmarshal(body, &resp)
+		if len(resp.Candidates) > 0 {
+			for _, part := range resp.Candidates[0].Content.Parts {
+				if part.FunctionCall != nil {
+					calls = append(calls, toolCallKey(part.FunctionCall.Name, part.FunctionCall.Args))
+				} else if !part.Thought {
+					text.WriteString(part.Text)
+				}
+			}
+		}
+	case domain.ClientTypeCodex:
+		var resp struct {
+			Output []struct {
+				Type      string `json:"type"`
+				Name      string `json:"name"`
+				Arguments string `json:"arguments"`
+				Content   []struct {
+					Text string `json:"text"`
+				} `json:"content"`
+			} `json:"output"`
+		}
+		_ = json.Unmarshal(body, &resp)
+		for _, item := range resp.Output {
+			switch item.Type {
+			case "message":
+				for _, part := range item.Content {
+					text.WriteString(part.Text)
+				}
+			case "function_call":
+				calls = append(calls, toolCallKey(item.Name, json.RawMessage(item.Arguments)))
+			}
+		}
+	}
+	sort.Strings(calls)
+	return text.String(), calls
+}
+
+// toolCallKey identifies a tool call by its name and arguments, ignoring argument formatting
+func toolCallKey(name string, args json.RawMessage) string {
+	var parsed interface{}
+	if json.Unmarshal(args, &parsed) == nil {
+		if canonical, err := json.Marshal(parsed); err == nil {
+			args = canonical
+		}
+	}
+	return name + " " + string(bytes.TrimSpace(args))
+}
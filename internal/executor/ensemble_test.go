@@ -0,0 +1,220 @@
+package executor
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/router"
+)
+
+func claudeText(text string) []byte {
+	return []byte(`{"type":"message","content":[{"type":"text","text":"` + text + `"}]}`)
+}
+
+func claudeToolUse(name, input string) []byte {
+	return []byte(`{"type":"message","content":[{"type":"text","text":"calling"},{"type":"tool_use","id":"toolu_x","name":"` + name + `","input":` + input + `}]}`)
+}
+
+func TestEnsembleSize(t *testing.T) {
+	tests := []struct {
+		size, available, want int
+	}{
+		{0, 3, 0},
+		{1, 3, 1},
+		{3, 2, 2},
+		{3, 4, 3},
+		{10, 10, maxEnsembleSize},
+	}
+	for _, tt := range tests {
+		if got := ensembleSize(&domain.Route{EnsembleSize: tt.size}, tt.available); got != tt.want {
+			t.Errorf("ensembleSize(%d, %d) = %d, want %d", tt.size, tt.available, got, tt.want)
+		}
+	}
+}
+
+func TestSelectEnsembleResponseFirst(t *testing.T) {
+	bodies := [][]byte{claudeText("short"), claudeText("a much longer answer")}
+	for _, strategy := range []string{domain.EnsembleStrategyFirst, ""} {
+		if got := selectEnsembleResponse(strategy, domain.ClientTypeClaude, bodies); got != 0 {
+			t.Errorf("strategy %q chose %d, want the first to finish", strategy, got)
+		}
+	}
+}
+
+func TestSelectEnsembleResponseLongest(t *testing.T) {
+	tests := []struct {
+		name       string
+		clientType domain.ClientType
+		bodies     [][]byte
+		want       int
+	}{
+		{
+			"claude",
+			domain.ClientTypeClaude,
+			[][]byte{claudeText("short"), claudeText("a much longer answer"), claudeText("medium one")},
+			1,
+		},
+		{
+			"tie goes to the first to finish",
+			domain.ClientTypeClaude,
+			[][]byte{claudeText("same"), claudeText("size")},
+			0,
+		},
+		{
+			"openai string and parts content",
+			domain.ClientTypeOpenAI,
+			[][]byte{
+				[]byte(`{"choices":[{"message":{"role":"assistant","content":"short"}}]}`),
+				[]byte(`{"choices":[{"message":{"role":"assistant","content":[{"type":"text","text":"longer "},{"type":"text","text":"answer"}]}}]}`),
+			},
+			1,
+		},
+		{
+			"gemini thoughts don't count",
+			domain.ClientTypeGemini,
+			[][]byte{
+				[]byte(`{"candidates":[{"content":{"parts":[{"text":"long hidden reasoning here","thought":true},{"text":"hi"}]}}]}`),
+				[]byte(`{"candidates":[{"content":{"parts":[{"text":"hello there"}]}}]}`),
+			},
+			1,
+		},
+		{
+			"codex",
+			domain.ClientTypeCodex,
+			[][]byte{
+				[]byte(`{"output":[{"type":"message","content":[{"type":"output_text","text":"hello there"}]}]}`),
+				[]byte(`{"output":[{"type":"message","content":[{"type":"output_text","text":"hi"}]}]}`),
+			},
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectEnsembleResponse(domain.EnsembleStrategyLongest, tt.clientType, tt.bodies); got != tt.want {
+				t.Errorf("chose %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectEnsembleResponseVote(t *testing.T) {
+	tests := []struct {
+		name       string
+		clientType domain.ClientType
+		bodies     [][]byte
+		want       int
+	}{
+		{
+			"majority tool call wins, argument formatting ignored",
+			domain.ClientTypeClaude,
+			[][]byte{
+				claudeToolUse("read", `{"path":"a.go"}`),
+				claudeToolUse("read", `{"path":"b.go"}`),
+				claudeToolUse("read", `{ "path" : "b.go" }`),
+			},
+			1,
+		},
+		{
+			"tie goes to the group that finished first",
+			domain.ClientTypeClaude,
+			[][]byte{claudeText("no tools"), claudeToolUse("read", `{"path":"a.go"}`)},
+			0,
+		},
+		{
+			"text answers vote together",
+			domain.ClientTypeClaude,
+			[][]byte{claudeToolUse("read", `{"path":"a.go"}`), claudeText("one"), claudeText("two")},
+			1,
+		},
+		{
+			"openai parallel calls in any order",
+			domain.ClientTypeOpenAI,
+			[][]byte{
+				[]byte(`{"choices":[{"message":{"tool_calls":[{"id":"1","type":"function","function":{"name":"ls","arguments":"{}"}}]}}]}`),
+				[]byte(`{"choices":[{"message":{"tool_calls":[{"id":"1","type":"function","function":{"name":"read","arguments":"{\"path\":\"a\"}"}},{"id":"2","type":"function","function":{"name":"ls","arguments":"{}"}}]}}]}`),
+				[]byte(`{"choices":[{"message":{"tool_calls":[{"id":"3","type":"function","function":{"name":"ls","arguments":"{ }"}},{"id":"4","type":"function","function":{"name":"read","arguments":"{\"path\": \"a\"}"}}]}}]}`),
+			},
+			1,
+		},
+		{
+			"gemini",
+			domain.ClientTypeGemini,
+			[][]byte{
+				[]byte(`{"candidates":[{"content":{"parts":[{"functionCall":{"name":"ls","args":{"dir":"/"}}}]}}]}`),
+				[]byte(`{"candidates":[{"content":{"parts":[{"functionCall":{"name":"ls","args":{"dir":"/tmp"}}}]}}]}`),
+				[]byte(`{"candidates":[{"content":{"parts":[{"functionCall":{"name":"ls","args":{"dir":"/tmp"}}}]}}]}`),
+			},
+			1,
+		},
+		{
+			"codex",
+			domain.ClientTypeCodex,
+			[][]byte{
+				[]byte(`{"output":[{"type":"function_call","name":"shell","arguments":"{\"cmd\":\"ls\"}"}]}`),
+				[]byte(`{"output":[{"type":"function_call","name":"shell","arguments":"{\"cmd\":\"pwd\"}"}]}`),
+				[]byte(`{"output":[{"type":"function_call","name":"shell","arguments":"{\"cmd\":\"pwd\"}"}]}`),
+			},
+			1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectEnsembleResponse(domain.EnsembleStrategyVote, tt.clientType, tt.bodies); got != tt.want {
+				t.Errorf("chose %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnsembleBufferHoldsResponse(t *testing.T) {
+	buf := newEnsembleBuffer()
+	buf.Header().Set("Content-Type", "application/json")
+	buf.WriteHeader(201)
+	_, _ = buf.Write([]byte(`{"ok":`))
+	buf.Flush()
+	_, _ = buf.Write([]byte(`true}`))
+
+	rec := httptest.NewRecorder()
+	buf.writeTo(rec)
+	if rec.Code != 201 || rec.Header().Get("Content-Type") != "application/json" || rec.Body.String() != `{"ok":true}` {
+		t.Errorf("client got %d %v %q", rec.Code, rec.Header(), rec.Body.String())
+	}
+}
+
+func TestEnsembleFailuresRecordEveryLeg(t *testing.T) {
+	main := testRoute(10, 1, "main")
+	leg := func(route *router.MatchedRoute, statusCode int, err error) *attemptRun {
+		return &attemptRun{
+			plan:   &routePlan{route: route},
+			record: &domain.ProxyUpstreamAttempt{StatusCode: statusCode},
+			err:    err,
+		}
+	}
+	legs := []*attemptRun{
+		leg(testRoute(20, 2, "second"), 503, domain.NewProxyError(domain.ErrUpstreamError, true)),
+		leg(testRoute(30, 3, "third"), 0, &domain.ProxyError{Err: domain.ErrUpstreamError, HTTPStatusCode: 429}),
+		leg(testRoute(40, 4, "fourth"), 200, nil),
+	}
+
+	var failures []domain.RouteFailure
+	failures = recordRouteFailure(failures, main, 500, domain.NewProxyError(domain.ErrUpstreamError, true))
+	failures = recordEnsembleFailures(failures, legs)
+
+	want := []struct {
+		routeID    uint64
+		statusCode int
+	}{{10, 500}, {20, 503}, {30, 429}}
+	if len(failures) != len(want) {
+		t.Fatalf("got %d route failures, want %d: %+v", len(failures), len(want), failures)
+	}
+	for i, f := range failures {
+		if f.Position != i+1 || f.RouteID != want[i].routeID || f.StatusCode != want[i].statusCode || f.Attempts != 1 {
+			t.Errorf("failure %d = %+v, want route %d status %d", i, f, want[i].routeID, want[i].statusCode)
+		}
+	}
+
+	if got := recordEnsembleFailures(nil, nil); got != nil {
+		t.Errorf("no legs recorded %+v", got)
+	}
+}
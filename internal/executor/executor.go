@@ -2,23 +2,48 @@ package executor
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/awsl-project/maxx/internal/adapter/provider"
+	"github.com/awsl-project/maxx/internal/capture"
 	"github.com/awsl-project/maxx/internal/converter"
 	"github.com/awsl-project/maxx/internal/cooldown"
 	ctxutil "github.com/awsl-project/maxx/internal/context"
 	"github.com/awsl-project/maxx/internal/domain"
 	"github.com/awsl-project/maxx/internal/event"
+	"github.com/awsl-project/maxx/internal/logsink"
+	"github.com/awsl-project/maxx/internal/pii"
+	"github.com/awsl-project/maxx/internal/plugin"
 	"github.com/awsl-project/maxx/internal/pricing"
+	"github.com/awsl-project/maxx/internal/redaction"
 	"github.com/awsl-project/maxx/internal/repository"
+	"github.com/awsl-project/maxx/internal/retrybudget"
 	"github.com/awsl-project/maxx/internal/router"
 	"github.com/awsl-project/maxx/internal/stats"
+	"github.com/awsl-project/maxx/internal/tokenizer"
 	"github.com/awsl-project/maxx/internal/usage"
 	"github.com/awsl-project/maxx/internal/waiter"
 )
 
+// QuerySkipModelMapping is the query parameter that forwards the request model
+// without model mapping, e.g. /v1beta/models/gemini-2.5-pro:generateContent?maxx_skip_mapping=true.
+// It is removed before the request is forwarded upstream.
+const QuerySkipModelMapping = "maxx_skip_mapping"
+
+// HeaderMaxRetries lets a single request override the route's max retries (bounded by an admin-set cap)
+const HeaderMaxRetries = "X-Maxx-Max-Retries"
+
+// defaultMaxRetriesOverrideCap is used when the override cap setting is not configured
+const defaultMaxRetriesOverrideCap = 5
+
 // Executor handles request execution with retry logic
 type Executor struct {
 	router             *router.Router
@@ -26,12 +51,17 @@ type Executor struct {
 	attemptRepo        repository.ProxyUpstreamAttemptRepository
 	retryConfigRepo    repository.RetryConfigRepository
 	sessionRepo        repository.SessionRepository
+	projectRepo        repository.ProjectRepository
 	modelMappingRepo   repository.ModelMappingRepository
+	settingRepo        repository.SystemSettingRepository
 	broadcaster        event.Broadcaster
 	projectWaiter      *waiter.ProjectWaiter
 	instanceID         string
 	statsAggregator    *stats.StatsAggregator
 	converter          *converter.Registry
+	inFlight           atomic.Int64
+	rate               requestRate // requests started during the last minute, for route load conditions
+	active             sync.Map // *domain.ProxyRequest -> context.CancelCauseFunc of in-flight requests
 }
 
 // NewExecutor creates a new executor
@@ -41,7 +71,9 @@ func NewExecutor(
 	ar repository.ProxyUpstreamAttemptRepository,
 	rcr repository.RetryConfigRepository,
 	sessionRepo repository.SessionRepository,
+	projectRepo repository.ProjectRepository,
 	modelMappingRepo repository.ModelMappingRepository,
+	settingRepo repository.SystemSettingRepository,
 	bc event.Broadcaster,
 	projectWaiter *waiter.ProjectWaiter,
 	instanceID string,
@@ -53,7 +85,9 @@ func NewExecutor(
 		attemptRepo:        ar,
 		retryConfigRepo:    rcr,
 		sessionRepo:        sessionRepo,
+		projectRepo:        projectRepo,
 		modelMappingRepo:   modelMappingRepo,
+		settingRepo:        settingRepo,
 		broadcaster:        bc,
 		projectWaiter:      projectWaiter,
 		instanceID:         instanceID,
@@ -62,59 +96,45 @@ func NewExecutor(
 	}
 }
 
+// InFlight returns the number of requests currently being executed
+func (e *Executor) InFlight() int64 {
+	return e.inFlight.Load()
+}
+
 // Execute handles the proxy request with routing and retry logic
 func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http.Request) error {
+	e.inFlight.Add(1)
+	defer e.inFlight.Add(-1)
+	e.rate.add()
+
 	clientType := ctxutil.GetClientType(ctx)
 	projectID := ctxutil.GetProjectID(ctx)
 	sessionID := ctxutil.GetSessionID(ctx)
 	requestModel := ctxutil.GetRequestModel(ctx)
-	isStream := ctxutil.GetIsStream(ctx)
+	requestBody := ctxutil.GetRequestBody(ctx)
+	singleToolCall := clientType == domain.ClientTypeOpenAI && converter.ParallelToolCallsDisabled(requestBody)
 
 	// Get API Token ID from context
 	apiTokenID := ctxutil.GetAPITokenID(ctx)
 
 	// Create proxy request record immediately (PENDING status)
-	proxyReq := &domain.ProxyRequest{
-		InstanceID:   e.instanceID,
-		RequestID:    generateRequestID(),
-		SessionID:    sessionID,
-		ClientType:   clientType,
-		ProjectID:    projectID,
-		RequestModel: requestModel,
-		StartTime:    time.Now(),
-		IsStream:     isStream,
-		Status:       "PENDING",
-		APITokenID:   apiTokenID,
-	}
-
-	// Capture client's original request info
-	requestURI := ctxutil.GetRequestURI(ctx)
-	requestHeaders := ctxutil.GetRequestHeaders(ctx)
-	requestBody := ctxutil.GetRequestBody(ctx)
-	headers := flattenHeaders(requestHeaders)
-	// Go stores Host separately from headers, add it explicitly
-	if req.Host != "" {
-		if headers == nil {
-			headers = make(map[string]string)
-		}
-		headers["Host"] = req.Host
-	}
-	proxyReq.RequestInfo = &domain.RequestInfo{
-		Method:  req.Method,
-		URL:     requestURI,
-		Headers: headers,
-		Body:    string(requestBody),
-	}
+	proxyReq := e.newProxyRequest(ctx, req)
+	proxyReq.Status = "PENDING"
 
 	if err := e.proxyRequestRepo.Create(proxyReq); err != nil {
 		log.Printf("[Executor] Failed to create proxy request: %v", err)
 	}
+	// Ship the final record once every exit path has settled the request's status
+	defer logsink.Global().Submit(proxyReq)
+	defer capture.Global().Submit(proxyReq)
 
 	// Broadcast the new request immediately
 	if e.broadcaster != nil {
 		e.broadcaster.BroadcastProxyRequest(proxyReq)
 	}
 
+	ctx, untrack := e.track(ctx, proxyReq)
+	defer untrack()
 	ctx = ctxutil.WithProxyRequest(ctx, proxyReq)
 
 	// Check for project binding if required
@@ -131,24 +151,23 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 
 		if err := e.projectWaiter.WaitForProject(ctx, session); err != nil {
 			// Determine status based on error type
-			status := "REJECTED"
-			errorMsg := "project binding timeout: " + err.Error()
 			if err == context.Canceled {
-				status = "CANCELLED"
-				errorMsg = "client cancelled: " + err.Error()
+				markCancelled(ctx, proxyReq, "client cancelled: "+err.Error())
+				err = context.Cause(ctx)
 				// Notify frontend to close the dialog
 				if e.broadcaster != nil {
 					e.broadcaster.BroadcastMessage("session_pending_cancelled", map[string]interface{}{
 						"sessionID": sessionID,
 					})
 				}
+			} else {
+				proxyReq.Status = "REJECTED"
+				proxyReq.Error = "project binding timeout: " + err.Error()
+				proxyReq.EndTime = time.Now()
+				proxyReq.Duration = proxyReq.EndTime.Sub(proxyReq.StartTime)
 			}
 
 			// Update request record with final status
-			proxyReq.Status = status
-			proxyReq.Error = errorMsg
-			proxyReq.EndTime = time.Now()
-			proxyReq.Duration = proxyReq.EndTime.Sub(proxyReq.StartTime)
 			_ = e.proxyRequestRepo.Update(proxyReq)
 
 			// Broadcast the updated request
@@ -165,22 +184,74 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 		ctx = ctxutil.WithProjectID(ctx, projectID)
 	}
 
-	// Match routes
-	routes, err := e.router.Match(&router.MatchContext{
-		ClientType:   clientType,
-		ProjectID:    projectID,
-		RequestModel: requestModel,
-		APITokenID:   apiTokenID,
+	// Run the project's guardrail before routing
+	guard := e.projectGuardrail(projectID)
+	if guard != nil {
+		if reason, rejectErr := e.checkGuardrail(ctx, guard, proxyReq, requestBody); reason != "" {
+			proxyReq.Status = "REJECTED"
+			proxyReq.Error = reason
+			proxyReq.EndTime = time.Now()
+			proxyReq.Duration = proxyReq.EndTime.Sub(proxyReq.StartTime)
+			if ctx.Err() != nil {
+				markCancelled(ctx, proxyReq, reason)
+				rejectErr = context.Cause(ctx)
+			}
+			_ = e.proxyRequestRepo.Update(proxyReq)
+			if e.broadcaster != nil {
+				e.broadcaster.BroadcastProxyRequest(proxyReq)
+			}
+			return domain.NewProxyErrorWithMessage(rejectErr, false, reason)
+		}
+	}
+
+	// Match routes, or only the provider an admin forced for debugging
+	if proxyReq.ForcedProviderID != 0 {
+		log.Printf("[Executor] Request %s forced to provider %d, bypassing routes and cooldowns", proxyReq.RequestID, proxyReq.ForcedProviderID)
+	}
+	routes, err := e.router.MatchOrWait(ctx, &router.MatchContext{
+		ClientType:      clientType,
+		ProjectID:       projectID,
+		RequestModel:    requestModel,
+		APITokenID:      apiTokenID,
+		ForceProviderID: proxyReq.ForcedProviderID,
 	})
 	if err != nil {
 		proxyReq.Status = "FAILED"
 		proxyReq.Error = "no routes available"
+		tokenDenied := errors.Is(err, domain.ErrTokenRoutesDenied)
+		var cooling *router.CooldownError
+		isCooling := errors.As(err, &cooling)
+		if proxyReq.ForcedProviderID != 0 || tokenDenied || isCooling {
+			proxyReq.Error = err.Error()
+		}
 		proxyReq.EndTime = time.Now()
 		proxyReq.Duration = proxyReq.EndTime.Sub(proxyReq.StartTime)
+		if ctx.Err() != nil {
+			// The client went away while queued for a provider to leave cooldown
+			markCancelled(ctx, proxyReq, "client cancelled while waiting for a provider to leave cooldown")
+			_ = e.proxyRequestRepo.Update(proxyReq)
+			if e.broadcaster != nil {
+				e.broadcaster.BroadcastProxyRequest(proxyReq)
+			}
+			return domain.NewProxyErrorWithMessage(context.Cause(ctx), false, "client cancelled")
+		}
+		// Every provider is cooling down, the project's fallback response stands in for the error
+		if isCooling {
+			if fallback := e.fallbackConfig(projectID, nil); fallback != nil {
+				sendFallback(w, fallback, proxyReq, err, ctxutil.GetIsStream(ctx))
+			}
+		}
 		_ = e.proxyRequestRepo.Update(proxyReq)
 		if e.broadcaster != nil {
 			e.broadcaster.BroadcastProxyRequest(proxyReq)
 		}
+		if proxyReq.FallbackResponse {
+			return nil
+		}
+		if tokenDenied {
+			// Tell the client its token is restricted rather than that nothing is configured
+			return domain.NewProxyErrorWithMessage(domain.ErrTokenRoutesDenied, false, "this API token is not allowed to use any route for this request")
+		}
 		return domain.NewProxyErrorWithMessage(domain.ErrNoRoutes, false, "no routes available")
 	}
 
@@ -196,6 +267,10 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 		return domain.NewProxyErrorWithMessage(domain.ErrNoRoutes, false, "no routes configured")
 	}
 
+	// Track whether the client got anything, a fallback response can't follow a partial one
+	client := &commitTracker{ResponseWriter: w}
+	w = client
+
 	// Update status to IN_PROGRESS
 	proxyReq.Status = "IN_PROGRESS"
 	_ = e.proxyRequestRepo.Update(proxyReq)
@@ -218,13 +293,12 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 	defer func() {
 		// If still IN_PROGRESS, mark as cancelled/failed
 		if proxyReq.Status == "IN_PROGRESS" {
-			proxyReq.EndTime = time.Now()
-			proxyReq.Duration = proxyReq.EndTime.Sub(proxyReq.StartTime)
 			if ctx.Err() != nil {
-				proxyReq.Status = "CANCELLED"
-				proxyReq.Error = "client disconnected"
+				markCancelled(ctx, proxyReq, "client disconnected")
 			} else {
 				proxyReq.Status = "FAILED"
+				proxyReq.EndTime = time.Now()
+				proxyReq.Duration = proxyReq.EndTime.Sub(proxyReq.StartTime)
 			}
 			_ = e.proxyRequestRepo.Update(proxyReq)
 			if e.broadcaster != nil {
@@ -248,10 +322,23 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 
 	// Try routes in order with retry logic
 	var lastErr error
-	for _, matchedRoute := range routes {
+	var lastProvider *domain.Provider
+	var routeFailures []domain.RouteFailure
+	attempted := false // an upstream attempt failed, a route skipped before attempting doesn't count
+	ensembleRoutes := make(map[uint64]bool) // routes already attempted as an ensemble leg
+	for routeIdx, matchedRoute := range routes {
 		// Check context before starting new route
 		if ctx.Err() != nil {
-			return ctx.Err()
+			return context.Cause(ctx)
+		}
+		if ensembleRoutes[matchedRoute.Route.ID] {
+			continue
+		}
+
+		// Failing over is another try, refuse it when the provider's retry budget is spent
+		if attempted && !retrybudget.Global().AllowRetry(matchedRoute.Provider.ID) {
+			log.Printf("[Executor] Retry budget exhausted for provider %d, not failing over", matchedRoute.Provider.ID)
+			break
 		}
 
 		// Update proxyReq with current route/provider for real-time tracking
@@ -262,136 +349,95 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 			e.broadcaster.BroadcastProxyRequest(proxyReq)
 		}
 
-		// Determine model mapping
-		// Model mapping is done in Executor after Router has filtered by SupportModels
-		clientType := ctxutil.GetClientType(ctx)
-		mappedModel := e.mapModel(requestModel, matchedRoute.Route, matchedRoute.Provider, clientType, projectID, apiTokenID)
-		ctx = ctxutil.WithMappedModel(ctx, mappedModel)
-
-		// Format conversion: check if client type is supported by provider
-		// If not, convert request to a supported format
-		originalClientType := clientType
-		targetClientType := clientType
-		needsConversion := false
-
-		supportedTypes := matchedRoute.ProviderAdapter.SupportedClientTypes()
-		if e.converter.NeedConvert(clientType, supportedTypes) {
-			targetClientType = GetPreferredTargetType(supportedTypes, clientType)
-			if targetClientType != clientType {
-				needsConversion = true
-				log.Printf("[Executor] Format conversion needed: %s -> %s for provider %s",
-					clientType, targetClientType, matchedRoute.Provider.Name)
-
-				// Convert request body
-				requestBody := ctxutil.GetRequestBody(ctx)
-				convertedBody, convErr := e.converter.TransformRequest(
-					clientType, targetClientType, requestBody, mappedModel, isStream)
-				if convErr != nil {
-					log.Printf("[Executor] Request conversion failed: %v, proceeding with original format", convErr)
-					needsConversion = false
-				} else {
-					// Update context with converted body and new client type
-					ctx = ctxutil.WithRequestBody(ctx, convertedBody)
-					ctx = ctxutil.WithClientType(ctx, targetClientType)
-					ctx = ctxutil.WithOriginalClientType(ctx, originalClientType)
-
-					// Convert request URI to match the target client type
-					originalURI := ctxutil.GetRequestURI(ctx)
-					convertedURI := ConvertRequestURI(originalURI, clientType, targetClientType)
-					if convertedURI != originalURI {
-						ctx = ctxutil.WithRequestURI(ctx, convertedURI)
-						log.Printf("[Executor] URI converted: %s -> %s", originalURI, convertedURI)
+		// Resolve model mapping, format conversion and retry config for this route
+		plan := e.prepareRoute(ctx, matchedRoute, proxyReq)
+		retryConfig := plan.retryConfig
+		if plan.convErr != nil {
+			// Nothing to attempt, the provider can't take the request. A route skipped
+			// for lacking a default model doesn't hide the error of an earlier route.
+			skipErr := domain.NewProxyError(plan.convErr, false)
+			if lastErr == nil || !errors.Is(plan.convErr, domain.ErrMissingModel) {
+				lastErr = skipErr
+				lastProvider = matchedRoute.Provider
+			}
+			routeFailures = recordRouteFailure(routeFailures, matchedRoute, http.StatusBadRequest, skipErr)
+			continue
+		}
+
+		// Run the first attempt of the first route on the following routes too when it is
+		// an ensemble route, non-streaming requests only
+		var ensemble []*routePlan
+		if routeIdx == 0 && !ctxutil.GetIsStream(ctx) {
+			if n := ensembleSize(matchedRoute.Route, len(routes)); n > 1 {
+				ensemble = append(ensemble, plan)
+				for _, r := range routes[1:n] {
+					if p := e.prepareRoute(ctx, r, proxyReq); p.convErr == nil {
+						ensemble = append(ensemble, p)
 					}
 				}
+				if len(ensemble) < 2 {
+					ensemble = nil
+				}
 			}
 		}
 
-		// Get retry config
-		retryConfig := e.getRetryConfig(matchedRoute.RetryConfig)
+		// Hedge the first attempt of the first route against the next route when configured
+		var hedge *routePlan
+		hedgeDelay := time.Duration(matchedRoute.Route.HedgeDelayMs) * time.Millisecond
+		if routeIdx == 0 && hedgeDelay > 0 && len(routes) > 1 && ensemble == nil {
+			hedge = e.prepareRoute(ctx, routes[1], proxyReq)
+			if hedge.convErr != nil {
+				hedge = nil
+			}
+		}
 
 		// Execute with retries
 		for attempt := 0; attempt <= retryConfig.MaxRetries; attempt++ {
 			// Check context before each attempt
 			if ctx.Err() != nil {
-				return ctx.Err()
-			}
-
-			// Create attempt record with start time
-			attemptStartTime := time.Now()
-			attemptRecord := &domain.ProxyUpstreamAttempt{
-				ProxyRequestID: proxyReq.ID,
-				RouteID:        matchedRoute.Route.ID,
-				ProviderID:     matchedRoute.Provider.ID,
-				IsStream:       isStream,
-				Status:         "IN_PROGRESS",
-				StartTime:      attemptStartTime,
-				RequestModel:   requestModel,
-				MappedModel:    mappedModel,
+				return context.Cause(ctx)
 			}
-			if err := e.attemptRepo.Create(attemptRecord); err != nil {
-				log.Printf("[Executor] Failed to create attempt record: %v", err)
-			}
-			currentAttempt = attemptRecord
-
-			// Increment attempt count when creating a new attempt
-			proxyReq.ProxyUpstreamAttemptCount++
-
-			// Broadcast updated request with new attempt count
-			if e.broadcaster != nil {
-				e.broadcaster.BroadcastProxyRequest(proxyReq)
-			}
-
-			// Broadcast new attempt immediately
-			if e.broadcaster != nil {
-				e.broadcaster.BroadcastProxyUpstreamAttempt(attemptRecord)
-			}
-
-			// Put attempt into context so adapter can populate request/response info
-			attemptCtx := ctxutil.WithUpstreamAttempt(ctx, attemptRecord)
-
-			// Create event channel for adapter to send events
-			eventChan := domain.NewAdapterEventChan()
-			attemptCtx = ctxutil.WithEventChan(attemptCtx, eventChan)
 
-			// Start real-time event processing goroutine
-			// This ensures RequestInfo is broadcast as soon as adapter sends it
-			eventDone := make(chan struct{})
-			go e.processAdapterEventsRealtime(eventChan, attemptRecord, eventDone)
-
-			// Wrap ResponseWriter to capture actual client response
-			// If format conversion is needed, use ConvertingResponseWriter
-			var responseWriter http.ResponseWriter
-			var convertingWriter *ConvertingResponseWriter
-			responseCapture := NewResponseCapture(w)
-
-			if needsConversion {
-				// Use ConvertingResponseWriter to transform response from targetType back to originalType
-				convertingWriter = NewConvertingResponseWriter(
-					responseCapture, e.converter, originalClientType, targetClientType, isStream)
-				responseWriter = convertingWriter
+			var run *attemptRun
+			var ensembleLegs []*attemptRun
+			if attempt == 0 && ensemble != nil {
+				run, ensembleLegs = e.executeEnsemble(ctx, w, req, proxyReq, ensemble, matchedRoute.Route.EnsembleStrategy, singleToolCall)
+				for _, leg := range ensembleLegs {
+					ensembleRoutes[leg.plan.route.Route.ID] = true
+				}
+			} else if attempt == 0 && hedge != nil {
+				run = e.executeHedged(ctx, w, req, proxyReq, plan, hedge, hedgeDelay, singleToolCall)
 			} else {
-				responseWriter = responseCapture
+				run = e.startAttempt(proxyReq, plan, 0)
+				e.runAttempt(run, w, req, singleToolCall)
 			}
-
-			// Execute request
-			err := matchedRoute.ProviderAdapter.Execute(attemptCtx, responseWriter, req, matchedRoute.Provider)
-
-			// For non-streaming responses with conversion, finalize the conversion
-			if needsConversion && convertingWriter != nil && !isStream {
-				if finalizeErr := convertingWriter.Finalize(); finalizeErr != nil {
-					log.Printf("[Executor] Response conversion finalize failed: %v", finalizeErr)
-				}
+			currentAttempt = run.record
+			if run.plan != plan {
+				// The hedge won, report its route and provider on the request
+				proxyReq.RouteID = run.plan.route.Route.ID
+				proxyReq.ProviderID = run.plan.route.Provider.ID
 			}
+			matchedRoute, mappedModel := run.plan.route, run.plan.mappedModel
+			attemptRecord, attemptCtx, responseCapture, err := run.record, run.ctx, run.capture, run.err
 
-			// Close event channel and wait for processing goroutine to finish
-			eventChan.Close()
-			<-eventDone
+			// Record upstream status code for error breakdown statistics
+			if attemptRecord.ResponseInfo != nil {
+				attemptRecord.StatusCode = attemptRecord.ResponseInfo.Status
+			}
 
 			if err == nil {
 				// Success - set end time and duration
 				attemptRecord.EndTime = time.Now()
 				attemptRecord.Duration = attemptRecord.EndTime.Sub(attemptRecord.StartTime)
 				attemptRecord.Status = "COMPLETED"
+				e.router.Latency().Record(matchedRoute.Provider.ID, attemptRecord.Duration)
+				e.router.Health().RecordSuccess(matchedRoute.Provider.ID, attemptRecord.Duration)
+
+				// Upstream reported no usage, estimate it with the model's tokenizer
+				if attemptRecord.InputTokenCount == 0 && attemptRecord.OutputTokenCount == 0 {
+					attemptRecord.InputTokenCount, attemptRecord.OutputTokenCount = tokenizer.EstimateUsage(
+						attemptRecord.MappedModel, ctxutil.GetRequestBody(attemptCtx), []byte(responseCapture.Body()))
+				}
 
 				// Calculate cost in executor (unified for all adapters)
 				// Adapter only needs to set token counts, executor handles pricing
@@ -405,7 +451,9 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 						Cache1hCreationCount: attemptRecord.Cache1hWriteCount,
 					}
 					attemptRecord.Cost = pricing.GlobalCalculator().Calculate(attemptRecord.MappedModel, metrics)
+					e.router.TPM().Record(matchedRoute.Provider.ID, int64(attemptRecord.InputTokenCount+attemptRecord.OutputTokenCount))
 				}
+				e.recordQuota(matchedRoute.Provider, attemptRecord)
 
 				_ = e.attemptRepo.Update(attemptRecord)
 				if e.broadcaster != nil {
@@ -416,21 +464,26 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 				// Reset failure counts on success
 				clientType := string(ctxutil.GetClientType(attemptCtx))
 				cooldown.Default().RecordSuccess(matchedRoute.Provider.ID, clientType)
+				retrybudget.Global().RecordSuccess(matchedRoute.Provider.ID)
 
 				proxyReq.Status = "COMPLETED"
 				proxyReq.EndTime = time.Now()
 				proxyReq.Duration = proxyReq.EndTime.Sub(proxyReq.StartTime)
 				proxyReq.FinalProxyUpstreamAttemptID = attemptRecord.ID
 				proxyReq.ResponseModel = mappedModel // Record the actual model used
+				proxyReq.EmptyResponse = attemptRecord.EmptyResponse
 
 				// Capture actual client response (what was sent to client, e.g. Claude format)
 				// This is different from attemptRecord.ResponseInfo which is upstream response (Gemini format)
 				proxyReq.ResponseInfo = &domain.ResponseInfo{
 					Status:  responseCapture.StatusCode(),
 					Headers: responseCapture.CapturedHeaders(),
-					Body:    responseCapture.Body(),
+					Body:    redaction.RedactResponse(matchedRoute.Provider, responseCapture.Body()),
 				}
 				proxyReq.StatusCode = responseCapture.StatusCode()
+				if guard != nil && guard.CheckResponse {
+					e.checkGuardrailResponse(guard, proxyReq, responseCapture.Body())
+				}
 
 				// Extract token usage from final client response (not from upstream attempt)
 				// This ensures we use the correct format (Claude/OpenAI/Gemini) for the client type
@@ -441,6 +494,9 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 					proxyReq.CacheWriteCount = metrics.CacheCreationCount
 					proxyReq.Cache5mWriteCount = metrics.Cache5mCreationCount
 					proxyReq.Cache1hWriteCount = metrics.Cache1hCreationCount
+				} else {
+					proxyReq.InputTokenCount = attemptRecord.InputTokenCount
+					proxyReq.OutputTokenCount = attemptRecord.OutputTokenCount
 				}
 				proxyReq.Cost = attemptRecord.Cost
 
@@ -458,6 +514,8 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 			attemptRecord.EndTime = time.Now()
 			attemptRecord.Duration = attemptRecord.EndTime.Sub(attemptRecord.StartTime)
 			lastErr = err
+			lastProvider = matchedRoute.Provider
+			attempted = true
 
 			// Update attempt status first (before checking context)
 			if ctx.Err() != nil {
@@ -465,6 +523,13 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 			} else {
 				attemptRecord.Status = "FAILED"
 			}
+			if proxyErr, ok := err.(*domain.ProxyError); ok && attemptRecord.StatusCode == 0 {
+				attemptRecord.StatusCode = proxyErr.HTTPStatusCode
+			}
+			routeFailures = recordRouteFailure(routeFailures, matchedRoute, attemptRecord.StatusCode, err)
+			// The whole ensemble failed, its other legs count as tries of their routes too
+			routeFailures = recordEnsembleFailures(routeFailures, ensembleLegs)
+			attributeResponseModel(attemptRecord)
 
 			// Calculate cost in executor even for failed attempts (may have partial token usage)
 			if attemptRecord.InputTokenCount > 0 || attemptRecord.OutputTokenCount > 0 {
@@ -477,7 +542,9 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 					Cache1hCreationCount: attemptRecord.Cache1hWriteCount,
 				}
 				attemptRecord.Cost = pricing.GlobalCalculator().Calculate(attemptRecord.MappedModel, metrics)
+				e.router.TPM().Record(matchedRoute.Provider.ID, int64(attemptRecord.InputTokenCount+attemptRecord.OutputTokenCount))
 			}
+			e.recordQuota(matchedRoute.Provider, attemptRecord)
 
 			_ = e.attemptRepo.Update(attemptRecord)
 			if e.broadcaster != nil {
@@ -493,7 +560,7 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 				proxyReq.ResponseInfo = &domain.ResponseInfo{
 					Status:  responseCapture.StatusCode(),
 					Headers: responseCapture.CapturedHeaders(),
-					Body:    responseCapture.Body(),
+					Body:    redaction.RedactResponse(matchedRoute.Provider, responseCapture.Body()),
 				}
 				proxyReq.StatusCode = responseCapture.StatusCode()
 
@@ -521,7 +588,13 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 				log.Printf("[Executor] ProxyError - IsNetworkError: %v, IsServerError: %v, Retryable: %v, Provider: %d",
 					proxyErr.IsNetworkError, proxyErr.IsServerError, proxyErr.Retryable, matchedRoute.Provider.ID)
 				// Handle cooldown (unified cooldown logic for all providers)
-				e.handleCooldown(attemptCtx, proxyErr, matchedRoute.Provider)
+				if cappedFrom := e.handleCooldown(attemptCtx, proxyErr, matchedRoute.Provider); cappedFrom > 0 {
+					attemptRecord.CooldownCappedFromSecs = int(cappedFrom / time.Second)
+					_ = e.attemptRepo.Update(attemptRecord)
+					if e.broadcaster != nil {
+						e.broadcaster.BroadcastProxyUpstreamAttempt(attemptRecord)
+					}
+				}
 				// Broadcast cooldown update event to frontend
 				if e.broadcaster != nil {
 					e.broadcaster.BroadcastMessage("cooldown_update", map[string]interface{}{
@@ -532,21 +605,30 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 				log.Printf("[Executor] Error is not ProxyError, type: %T, error: %v", err, err)
 			}
 
+			// Fail-fast routes take the provider out of rotation on any failure
+			if matchedRoute.Route.FailFast && ctx.Err() == nil {
+				applyFailFastCooldown(attemptCtx, matchedRoute.Provider)
+				if e.broadcaster != nil {
+					e.broadcaster.BroadcastMessage("cooldown_update", map[string]interface{}{
+						"providerID": matchedRoute.Provider.ID,
+					})
+				}
+			}
+
 			// Check if it's a context cancellation (client disconnect)
 			if ctx.Err() != nil {
 				// Set final status before returning to ensure it's persisted
 				// (defer block also handles this, but we want to be explicit and broadcast immediately)
-				proxyReq.Status = "CANCELLED"
-				proxyReq.EndTime = time.Now()
-				proxyReq.Duration = proxyReq.EndTime.Sub(proxyReq.StartTime)
-				proxyReq.Error = "client disconnected"
+				markCancelled(ctx, proxyReq, "client disconnected")
 				_ = e.proxyRequestRepo.Update(proxyReq)
 				if e.broadcaster != nil {
 					e.broadcaster.BroadcastProxyRequest(proxyReq)
 				}
-				return ctx.Err()
+				return context.Cause(ctx)
 			}
 
+			e.router.Health().RecordFailure(matchedRoute.Provider.ID)
+
 			// Check if retryable (proxyErr already checked above)
 			if !ok {
 				break // Move to next route
@@ -555,9 +637,14 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 			if !proxyErr.Retryable {
 				break // Move to next route
 			}
+			retrybudget.Global().RecordFailure(matchedRoute.Provider.ID)
 
 			// Wait before retry (unless last attempt)
 			if attempt < retryConfig.MaxRetries {
+				if !retrybudget.Global().AllowRetry(matchedRoute.Provider.ID) {
+					log.Printf("[Executor] Retry budget exhausted for provider %d, not retrying", matchedRoute.Provider.ID)
+					break // Move to next route
+				}
 				waitTime := e.calculateBackoff(retryConfig, attempt)
 				if proxyErr.RetryAfter > 0 {
 					waitTime = proxyErr.RetryAfter
@@ -565,15 +652,12 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 				select {
 				case <-ctx.Done():
 					// Set final status before returning
-					proxyReq.Status = "CANCELLED"
-					proxyReq.EndTime = time.Now()
-					proxyReq.Duration = proxyReq.EndTime.Sub(proxyReq.StartTime)
-					proxyReq.Error = "client disconnected during retry wait"
+					markCancelled(ctx, proxyReq, "client disconnected during retry wait")
 					_ = e.proxyRequestRepo.Update(proxyReq)
 					if e.broadcaster != nil {
 						e.broadcaster.BroadcastProxyRequest(proxyReq)
 					}
-					return ctx.Err()
+					return context.Cause(ctx)
 				case <-time.After(waitTime):
 				}
 			}
@@ -588,6 +672,12 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 	if lastErr != nil {
 		proxyReq.Error = lastErr.Error()
 	}
+	proxyReq.RouteFailures = routeFailures
+	// Answer with the fallback response instead of the error when one is configured
+	// A request without a model is the client's error, it isn't covered by the fallback
+	if fallback := e.fallbackConfig(projectID, routes); fallback != nil && !client.committed && !errors.Is(lastErr, domain.ErrMissingModel) {
+		sendFallback(client, fallback, proxyReq, lastErr, ctxutil.GetIsStream(ctx))
+	}
 	_ = e.proxyRequestRepo.Update(proxyReq)
 
 	// Broadcast to WebSocket clients
@@ -595,26 +685,452 @@ func (e *Executor) Execute(ctx context.Context, w http.ResponseWriter, req *http
 		e.broadcaster.BroadcastProxyRequest(proxyReq)
 	}
 
+	if proxyReq.FallbackResponse {
+		return nil
+	}
 	if lastErr != nil {
-		return lastErr
+		return e.exhaustedError(lastErr, routeFailures, lastProvider)
 	}
 	return domain.NewProxyErrorWithMessage(domain.ErrAllRoutesFailed, false, "all routes exhausted")
 }
 
-func (e *Executor) mapModel(requestModel string, route *domain.Route, provider *domain.Provider, clientType domain.ClientType, projectID uint64, apiTokenID uint64) string {
+// routePlan holds the request state prepared for a matched route, shared by all of its attempts
+type routePlan struct {
+	route              *router.MatchedRoute
+	ctx                context.Context
+	mappedModel        string
+	originalClientType domain.ClientType
+	targetClientType   domain.ClientType
+	needsConversion    bool
+	retryConfig        *domain.RetryConfig
+	clampedBody        []byte
+	clampedFrom        int
+	droppedParams      []string
+	convErr            error // the request can't be converted for this route's provider
+	plugin             *plugin.Plugin
+}
+
+// newProxyRequest builds the record of a client request from the request context
+func (e *Executor) newProxyRequest(ctx context.Context, req *http.Request) *domain.ProxyRequest {
+	clientType := ctxutil.GetClientType(ctx)
+	proxyReq := &domain.ProxyRequest{
+		InstanceID:     e.instanceID,
+		RequestID:      generateRequestID(),
+		SessionID:      ctxutil.GetSessionID(ctx),
+		ClientType:     clientType,
+		ProjectID:      ctxutil.GetProjectID(ctx),
+		RequestModel:   ctxutil.GetRequestModel(ctx),
+		ModelDefaulted: ctxutil.GetModelDefaulted(ctx),
+		StartTime:      time.Now(),
+		IsStream:       ctxutil.GetIsStream(ctx),
+		APITokenID:     ctxutil.GetAPITokenID(ctx),
+	}
+
+	// Per-request retry override for debugging, bounded by the admin-set cap
+	if override, ok := resolveMaxRetriesOverride(req.Header.Get(HeaderMaxRetries), e.maxRetriesOverrideCap()); ok {
+		proxyReq.MaxRetriesOverride = &override
+	}
+
+	// Capture client's original request info
+	requestBody := ctxutil.GetRequestBody(ctx)
+	headers := flattenHeaders(ctxutil.GetRequestHeaders(ctx))
+	proxyReq.Metadata = extractRequestMetadata(requestBody, clientType)
+	proxyReq.PIIFlags = pii.Detect(requestBody) // advisory only, the body is left untouched
+	proxyReq.ClientApp = extractClientApp(ctxutil.GetRequestHeaders(ctx))
+	proxyReq.Tags = extractRequestTags(ctxutil.GetRequestHeaders(ctx), e.tagHeader())
+	proxyReq.ForcedProviderID = ctxutil.GetForcedProviderID(ctx)
+	if ctxutil.GetReplay(ctx) {
+		proxyReq.Replay = true
+		proxyReq.Tags = withReplayTag(proxyReq.Tags)
+	}
+	// Go stores Host separately from headers, add it explicitly
+	if req.Host != "" {
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers["Host"] = req.Host
+	}
+	proxyReq.RequestInfo = &domain.RequestInfo{
+		Method:  req.Method,
+		URL:     ctxutil.GetRequestURI(ctx),
+		Headers: headers,
+		Body:    redaction.RedactRequest(nil, string(requestBody)), // provider not yet known, global rules only
+	}
+	return proxyReq
+}
+
+// RecordRejected records a request that was turned away before execution
+func (e *Executor) RecordRejected(ctx context.Context, req *http.Request, reason string) {
+	proxyReq := e.newProxyRequest(ctx, req)
+	proxyReq.Status = "REJECTED"
+	proxyReq.Error = reason
+	proxyReq.EndTime = proxyReq.StartTime
+	if err := e.proxyRequestRepo.Create(proxyReq); err != nil {
+		log.Printf("[Executor] Failed to record rejected request: %v", err)
+		return
+	}
+	if e.broadcaster != nil {
+		e.broadcaster.BroadcastProxyRequest(proxyReq)
+	}
+	logsink.Global().Submit(proxyReq)
+}
+
+// prepareRoute resolves model mapping, format conversion, retry config and
+// output token clamping for a matched route
+func (e *Executor) prepareRoute(ctx context.Context, matchedRoute *router.MatchedRoute, proxyReq *domain.ProxyRequest) *routePlan {
+	// Requests still without a model take the route's default, or skip the route
+	if ctxutil.GetRequestModel(ctx) == "" {
+		var err error
+		if ctx, err = applyRouteDefaultModel(ctx, matchedRoute.Route, proxyReq); err != nil {
+			return &routePlan{route: matchedRoute, convErr: err}
+		}
+	}
+
+	requestModel := ctxutil.GetRequestModel(ctx)
+	isStream := ctxutil.GetIsStream(ctx)
+	originalBody := ctxutil.GetRequestBody(ctx)
+
+	// Determine model mapping
+	// Model mapping is done in Executor after Router has filtered by SupportModels
+	// The client can ask for its model to be forwarded as is
+	clientType := ctxutil.GetClientType(ctx)
+	mappedModel := requestModel
+	if !ctxutil.GetSkipModelMapping(ctx) {
+		mappedModel = e.mapModel(requestModel, matchedRoute.Route, matchedRoute.Provider, clientType, proxyReq)
+	}
+	ctx = ctxutil.WithMappedModel(ctx, mappedModel)
+
+	plan := &routePlan{
+		route:              matchedRoute,
+		mappedModel:        mappedModel,
+		originalClientType: clientType,
+		targetClientType:   clientType,
+	}
+
+	// Format conversion: check if client type is supported by provider
+	// If not, convert request to a supported format
+	supportedTypes := matchedRoute.ProviderAdapter.SupportedClientTypes()
+	if e.converter.NeedConvert(clientType, supportedTypes) {
+		targetClientType := GetPreferredTargetType(supportedTypes, clientType)
+		if targetClientType != clientType {
+			log.Printf("[Executor] Format conversion needed: %s -> %s for provider %s",
+				clientType, targetClientType, matchedRoute.Provider.Name)
+
+			// Convert request body
+			requestBody := ctxutil.GetRequestBody(ctx)
+			convertedBody, convErr := e.converter.TransformRequest(
+				clientType, targetClientType, requestBody, mappedModel, isStream)
+			if errors.Is(convErr, converter.ErrUnsupportedTool) || errors.Is(convErr, converter.ErrUnsupportedContent) {
+				// Forwarding the original format would drop the tool or content silently, fail the route instead
+				log.Printf("[Executor] Request conversion failed: %v, skipping provider %s", convErr, matchedRoute.Provider.Name)
+				plan.convErr = convErr
+			} else if convErr != nil {
+				log.Printf("[Executor] Request conversion failed: %v, proceeding with original format", convErr)
+			} else {
+				plan.needsConversion = true
+				plan.targetClientType = targetClientType
+
+				// Update context with converted body and new client type
+				ctx = ctxutil.WithRequestBody(ctx, convertedBody)
+				ctx = ctxutil.WithClientType(ctx, targetClientType)
+				ctx = ctxutil.WithOriginalClientType(ctx, clientType)
+
+				// Convert request URI to match the target client type
+				originalURI := ctxutil.GetRequestURI(ctx)
+				convertedURI := ConvertRequestURI(originalURI, clientType, targetClientType)
+				if convertedURI != originalURI {
+					ctx = ctxutil.WithRequestURI(ctx, convertedURI)
+					log.Printf("[Executor] URI converted: %s -> %s", originalURI, convertedURI)
+				}
+			}
+		}
+	}
+
+	// Drop store/metadata fields the target format doesn't accept
+	if body, stripped := stripUnsupportedFields(ctxutil.GetRequestBody(ctx), plan.targetClientType); stripped {
+		ctx = ctxutil.WithRequestBody(ctx, body)
+	}
+
+	// Forward cache params the upstream accepts and drop the rest
+	var unsupported []string
+	if cfg := matchedRoute.Provider.Config; cfg != nil {
+		unsupported = cfg.UnsupportedParams
+	}
+	body, dropped := applyCacheParams(originalBody, ctxutil.GetRequestBody(ctx), clientType, plan.targetClientType, unsupported)
+	ctx = ctxutil.WithRequestBody(ctx, body)
+	if len(dropped) > 0 {
+		plan.droppedParams = dropped
+		log.Printf("[Executor] Dropped request params %v unsupported by provider %s",
+			dropped, matchedRoute.Provider.Name)
+	}
+	plan.ctx = ctx
+
+	// Get retry config
+	plan.retryConfig = e.routeRetryConfig(matchedRoute, proxyReq)
+
+	// Clamp the requested output tokens to the provider's limit
+	if cfg := matchedRoute.Provider.Config; cfg != nil && cfg.MaxOutputTokens > 0 {
+		if body, original := clampMaxOutputTokens(ctxutil.GetRequestBody(ctx), plan.targetClientType, cfg.MaxOutputTokens); original > 0 {
+			plan.clampedBody, plan.clampedFrom = body, original
+			log.Printf("[Executor] Clamped max output tokens from %d to %d for provider %s",
+				original, cfg.MaxOutputTokens, matchedRoute.Provider.Name)
+		}
+	}
+
+	// Rewrite the request into the upstream's schema with the provider's transform plugin
+	if plan.plugin = transformPlugin(matchedRoute.Provider); plan.plugin != nil {
+		if plan.clampedBody != nil {
+			plan.clampedBody = transformPluginRequest(ctx, plan, plan.clampedBody)
+		} else {
+			plan.ctx = ctxutil.WithRequestBody(plan.ctx, transformPluginRequest(ctx, plan, ctxutil.GetRequestBody(plan.ctx)))
+		}
+	}
+
+	return plan
+}
+
+// attemptRun is a single upstream attempt and its outcome
+type attemptRun struct {
+	plan    *routePlan
+	record  *domain.ProxyUpstreamAttempt
+	ctx     context.Context
+	capture *ResponseCapture
+	err     error
+}
+
+// startAttempt creates and broadcasts the attempt record for a route
+// hedgeOf is the primary attempt ID when this attempt is a hedge, 0 otherwise
+func (e *Executor) startAttempt(proxyReq *domain.ProxyRequest, plan *routePlan, hedgeOf uint64) *attemptRun {
+	// Create attempt record with start time
+	attemptRecord := &domain.ProxyUpstreamAttempt{
+		ProxyRequestID: proxyReq.ID,
+		RouteID:        plan.route.Route.ID,
+		ProviderID:     plan.route.Provider.ID,
+		IsStream:       ctxutil.GetIsStream(plan.ctx),
+		Status:         "IN_PROGRESS",
+		StartTime:      time.Now(),
+		RequestModel:   ctxutil.GetRequestModel(plan.ctx),
+		MappedModel:    plan.mappedModel,
+
+		MaxTokensClampedFrom: plan.clampedFrom,
+		HedgeOfAttemptID:     hedgeOf,
+		DroppedParams:        strings.Join(plan.droppedParams, ","),
+	}
+	if err := e.attemptRepo.Create(attemptRecord); err != nil {
+		log.Printf("[Executor] Failed to create attempt record: %v", err)
+	}
+
+	// Increment attempt count when creating a new attempt
+	proxyReq.ProxyUpstreamAttemptCount++
+
+	// Broadcast updated request with new attempt count
+	if e.broadcaster != nil {
+		e.broadcaster.BroadcastProxyRequest(proxyReq)
+	}
+
+	// Broadcast new attempt immediately
+	if e.broadcaster != nil {
+		e.broadcaster.BroadcastProxyUpstreamAttempt(attemptRecord)
+	}
+
+	// Put attempt into context so adapter can populate request/response info
+	attemptCtx := ctxutil.WithUpstreamAttempt(plan.ctx, attemptRecord)
+	if plan.clampedBody != nil {
+		attemptCtx = ctxutil.WithRequestBody(attemptCtx, plan.clampedBody)
+	}
+
+	return &attemptRun{plan: plan, record: attemptRecord, ctx: attemptCtx}
+}
+
+// runAttempt executes the attempt against its provider, writing the response to w
+func (e *Executor) runAttempt(run *attemptRun, w http.ResponseWriter, req *http.Request, singleToolCall bool) {
+	plan := run.plan
+	isStream := ctxutil.GetIsStream(run.ctx)
+
+	// Space out calls to providers with a minimum interval, before the adaptive
+	// timeout starts so queueing doesn't count against the upstream
+	if err := e.router.Pace(run.ctx, plan.route.Provider); err != nil {
+		run.capture = NewResponseCapture(w)
+		run.err = err
+		return
+	}
+
+	// Create event channel for adapter to send events
+	eventChan := domain.NewAdapterEventChan()
+	attemptCtx := ctxutil.WithEventChan(run.ctx, eventChan)
+
+	// Record the phase timings of the upstream request when capture is on
+	var timing *provider.TimingRecorder
+	if provider.TimingCaptureEnabled() {
+		timing = &provider.TimingRecorder{}
+		attemptCtx = provider.WithTimingRecorder(attemptCtx, timing)
+	}
+
+	// Bound the attempt by the provider's adaptive timeout, derived from its recent latency
+	timeout := e.router.AdaptiveTimeout(plan.route.Provider)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(attemptCtx, timeout)
+		defer cancel()
+	}
+
+	// Start real-time event processing goroutine
+	// This ensures RequestInfo is broadcast as soon as adapter sends it
+	eventDone := make(chan struct{})
+	go e.processAdapterEventsRealtime(eventChan, run.record, plan.route.Provider, eventDone)
+
+	// Keep quiet streams alive with heartbeats in the client's format
+	if isStream {
+		if heartbeat := newHeartbeatWriter(w, plan.originalClientType, e.streamHeartbeatInterval()); heartbeat != nil {
+			defer heartbeat.stop()
+			w = heartbeat
+		}
+	}
+
+	// Hold back successful responses until they are known to carry content,
+	// unless empty ones are passed through as they are
+	emptyMode := e.emptyResponseHandling()
+	var gate *emptyResponseGate
+	if emptyMode != domain.EmptyResponsePassthrough {
+		gate = newEmptyResponseGate(w, plan.originalClientType, isStream)
+		w = gate
+	}
+
+	// Broadcast the running output token count of streams for live monitoring
+	var progress *tokenProgressWriter
+	if isStream && e.broadcaster != nil {
+		progress = newTokenProgressWriter(w, e.broadcaster, run.record)
+		w = progress
+	}
+
+	// Wrap ResponseWriter to capture actual client response
+	// If format conversion is needed, use ConvertingResponseWriter
+	var responseWriter http.ResponseWriter
+	var convertingWriter *ConvertingResponseWriter
+	run.capture = NewResponseCapture(w)
+
+	// Rewrite the statuses the client can't handle, the capture records what it got
+	var clientWriter http.ResponseWriter = run.capture
+	if mapStatus := e.statusMapper(plan.route.Provider); mapStatus != nil {
+		clientWriter = &statusMappingWriter{ResponseWriter: run.capture, mapStatus: mapStatus}
+	}
+
+	// Close tool call arguments left open by streams cut off mid-call
+	var toolArgs *toolArgsWriter
+	if isStream {
+		toolArgs = newToolArgsWriter(clientWriter, plan.originalClientType)
+		clientWriter = toolArgs
+	}
+
+	if plan.needsConversion {
+		// Use ConvertingResponseWriter to transform response from targetType back to originalType
+		convertingWriter = NewConvertingResponseWriter(
+			clientWriter, e.converter, plan.originalClientType, plan.targetClientType, isStream)
+		if singleToolCall {
+			convertingWriter.SetToolCallPolicy(e.parallelToolCallPolicy())
+		}
+		responseWriter = convertingWriter
+	} else {
+		responseWriter = clientWriter
+	}
+
+	// Rewrite non-streaming responses from the upstream's schema with the provider's transform plugin
+	var pluginWriter *pluginResponseWriter
+	if plan.plugin != nil && plan.plugin.TransformsResponses() && !isStream {
+		pluginWriter = newPluginResponseWriter(responseWriter, plan)
+		responseWriter = pluginWriter
+	}
+
+	// Execute request
+	run.err = plan.route.ProviderAdapter.Execute(attemptCtx, responseWriter, req, plan.route.Provider)
+	if pluginWriter != nil {
+		pluginWriter.finish(run.ctx)
+	}
+	if toolArgs != nil {
+		toolArgs.finish(plan.route.Provider.Name)
+	}
+	if timing != nil {
+		run.record.Timing = timing.Timing()
+	}
+	if run.err != nil && timeout > 0 && run.ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		log.Printf("[Executor] Provider %s exceeded its adaptive timeout of %v", plan.route.Provider.Name, timeout)
+		run.err = &domain.ProxyError{
+			Err:            domain.ErrUpstreamError,
+			Message:        fmt.Sprintf("upstream exceeded adaptive timeout of %v", timeout),
+			Retryable:      run.capture.Body() == "", // don't retry once the client got part of the response
+			IsNetworkError: true,
+		}
+	}
+
+	// For non-streaming responses with conversion, finalize the conversion
+	if convertingWriter != nil && !isStream {
+		if finalizeErr := convertingWriter.Finalize(); finalizeErr != nil {
+			log.Printf("[Executor] Response conversion finalize failed: %v", finalizeErr)
+		}
+	}
+
+	// Detect successful responses without content, all candidates filtered or empty
+	if gate != nil {
+		run.record.EmptyResponse = gate.finish(run.err != nil, emptyMode)
+	} else if run.err == nil {
+		run.record.EmptyResponse = isEmptyResponse(plan.originalClientType, run.capture.StatusCode(), run.capture.Body())
+	}
+	if run.record.EmptyResponse {
+		log.Printf("[Executor] Provider %s returned a successful response without content", plan.route.Provider.Name)
+		if emptyMode == domain.EmptyResponseRetry {
+			run.err = &domain.ProxyError{
+				Err:            domain.ErrEmptyResponse,
+				Message:        "upstream returned a successful response without content",
+				Retryable:      true,
+				HTTPStatusCode: run.capture.StatusCode(),
+			}
+		}
+	}
+
+	// Close event channel and wait for processing goroutine to finish
+	eventChan.Close()
+	<-eventDone
+
+	// The adapter's usage, when it reported any, supersedes the running estimate
+	if progress != nil {
+		progress.finish(run.record.OutputTokenCount)
+	}
+
+	// Let the provider's retry rules mark quirky transient errors as retryable
+	applyRetryRules(run)
+}
+
+// attributeResponseModel attributes a failed attempt to its mapped model so per-model
+// statistics include its failure. Failed attempts rarely carry a response model; one
+// the upstream reported, even in an error body, is kept.
+func attributeResponseModel(record *domain.ProxyUpstreamAttempt) {
+	if record.ResponseModel == "" {
+		record.ResponseModel = record.MappedModel
+	}
+}
+
+func (e *Executor) mapModel(requestModel string, route *domain.Route, provider *domain.Provider, clientType domain.ClientType, proxyReq *domain.ProxyRequest) string {
 	// Database model mapping with full query conditions
 	query := &domain.ModelMappingQuery{
 		ClientType:   clientType,
 		ProviderType: provider.Type,
 		ProviderID:   provider.ID,
-		ProjectID:    projectID,
+		ProjectID:    proxyReq.ProjectID,
 		RouteID:      route.ID,
-		APITokenID:   apiTokenID,
+		APITokenID:   proxyReq.APITokenID,
 	}
 	mappings, _ := e.modelMappingRepo.ListByQuery(query)
+	return matchModelMapping(mappings, requestModel, proxyReq.SessionID)
+}
+
+// matchModelMapping returns the target of the first rule matching requestModel,
+// rules are already ordered by scope and priority. Regex rules ("re:" prefix)
+// substitute capture groups into the target. Rules with weighted targets pick
+// one per request, or per session when they are sticky.
+func matchModelMapping(mappings []*domain.ModelMapping, requestModel, sessionID string) string {
 	for _, m := range mappings {
-		if domain.MatchWildcard(m.Pattern, requestModel) {
-			return m.Target
+		if target, ok := domain.MatchModelMapping(m.Pattern, m.PickTarget(sessionID), requestModel); ok {
+			return target
 		}
 	}
 
@@ -642,6 +1158,46 @@ func (e *Executor) getRetryConfig(config *domain.RetryConfig) *domain.RetryConfi
 	}
 }
 
+// resolveMaxRetriesOverride parses the X-Maxx-Max-Retries header value and clamps it to limit.
+// Returns false when the header is absent or invalid.
+func resolveMaxRetriesOverride(value string, limit int) (int, bool) {
+	if value == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("[Executor] Ignoring invalid %s header: %q", HeaderMaxRetries, value)
+		return 0, false
+	}
+	if n > limit {
+		log.Printf("[Executor] %s=%d exceeds cap %d, clamping", HeaderMaxRetries, n, limit)
+		n = limit
+	}
+	return n, true
+}
+
+// maxRetriesOverrideCap returns the admin-set upper bound for the X-Maxx-Max-Retries header
+func (e *Executor) maxRetriesOverrideCap() int {
+	if e.settingRepo != nil {
+		if val, err := e.settingRepo.Get(domain.SettingKeyMaxRetriesOverrideCap); err == nil && val != "" {
+			if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+				return n
+			}
+		}
+	}
+	return defaultMaxRetriesOverrideCap
+}
+
+// parallelToolCallPolicy returns how to handle extra tool calls when the client disabled parallel calls
+func (e *Executor) parallelToolCallPolicy() string {
+	if e.settingRepo != nil {
+		if val, err := e.settingRepo.Get(domain.SettingKeyParallelToolCallPolicy); err == nil && val == converter.ToolCallPolicyError {
+			return converter.ToolCallPolicyError
+		}
+	}
+	return converter.ToolCallPolicyFirst
+}
+
 func (e *Executor) calculateBackoff(config *domain.RetryConfig, attempt int) time.Duration {
 	wait := float64(config.InitialInterval)
 	for i := 0; i < attempt; i++ {
@@ -673,7 +1229,9 @@ func flattenHeaders(h http.Header) map[string]string {
 
 // handleCooldown processes cooldown information from ProxyError and sets provider cooldown
 // Priority: 1) Explicit time from API, 2) Policy-based calculation based on failure reason
-func (e *Executor) handleCooldown(ctx context.Context, proxyErr *domain.ProxyError, provider *domain.Provider) {
+// Explicit times are capped per provider; returns the duration the upstream asked
+// for when it was capped, 0 otherwise
+func (e *Executor) handleCooldown(ctx context.Context, proxyErr *domain.ProxyError, provider *domain.Provider) time.Duration {
 	// Determine which client type to apply cooldown to
 	clientType := proxyErr.CooldownClientType
 	if proxyErr.RateLimitInfo != nil && proxyErr.RateLimitInfo.ClientType != "" {
@@ -710,6 +1268,15 @@ func (e *Executor) handleCooldown(ctx context.Context, proxyErr *domain.ProxyErr
 		untilTime := time.Now().Add(proxyErr.RetryAfter)
 		explicitUntil = &untilTime
 		reason = cooldown.ReasonRateLimit
+	} else if errors.Is(proxyErr, domain.ErrInvalidCredentials) {
+		// Credentials rejected on token refresh - no explicit time, use policy
+		reason = cooldown.ReasonInvalidCredentials
+		explicitUntil = nil
+		cooldown.Default().RecordEvent(&domain.ProviderEvent{
+			ProviderID: provider.ID,
+			Type:       domain.ProviderEventCredentialFailed,
+			Detail:     proxyErr.Error(),
+		})
 	} else if proxyErr.IsServerError {
 		// Server error (5xx) - no explicit time, use policy
 		reason = cooldown.ReasonServerError
@@ -724,6 +1291,23 @@ func (e *Executor) handleCooldown(ctx context.Context, proxyErr *domain.ProxyErr
 		explicitUntil = nil
 	}
 
+	// Some upstreams ask for hours, don't keep the provider out that long
+	var cappedFrom time.Duration
+	if explicitUntil != nil {
+		var capped time.Time
+		if capped, cappedFrom = capCooldownUntil(*explicitUntil, provider); cappedFrom > 0 {
+			log.Printf("[Executor] Provider %d asked for a %v cooldown, capped to %v",
+				provider.ID, cappedFrom.Round(time.Second), cooldownCap(provider))
+			explicitUntil = &capped
+		}
+	}
+
+	// The scope policy decides whether the failure cools the whole provider,
+	// rejected credentials fail every client type
+	if reason == cooldown.ReasonInvalidCredentials || cooldown.ScopeFor(reason) == cooldown.ScopeProvider {
+		clientType = ""
+	}
+
 	// Record failure and apply cooldown
 	// If explicitUntil is not nil, it will be used directly
 	// Otherwise, cooldown duration is calculated based on policy and failure count
@@ -733,6 +1317,7 @@ func (e *Executor) handleCooldown(ctx context.Context, proxyErr *domain.ProxyErr
 	if proxyErr.CooldownUpdateChan != nil {
 		go e.handleAsyncCooldownUpdate(proxyErr.CooldownUpdateChan, provider, clientType)
 	}
+	return cappedFrom
 }
 
 // mapRateLimitTypeToReason maps RateLimitInfo.Type to CooldownReason
@@ -754,6 +1339,7 @@ func (e *Executor) handleAsyncCooldownUpdate(updateChan chan time.Time, provider
 	select {
 	case newCooldownTime := <-updateChan:
 		if !newCooldownTime.IsZero() {
+			newCooldownTime, _ = capCooldownUntil(newCooldownTime, provider)
 			cooldown.Default().UpdateCooldown(provider.ID, clientType, newCooldownTime)
 		}
 	case <-time.After(15 * time.Second):
@@ -762,7 +1348,7 @@ func (e *Executor) handleAsyncCooldownUpdate(updateChan chan time.Time, provider
 }
 
 // processAdapterEvents drains the event channel and updates attempt record
-func (e *Executor) processAdapterEvents(eventChan domain.AdapterEventChan, attempt *domain.ProxyUpstreamAttempt) {
+func (e *Executor) processAdapterEvents(eventChan domain.AdapterEventChan, attempt *domain.ProxyUpstreamAttempt, provider *domain.Provider) {
 	if eventChan == nil || attempt == nil {
 		return
 	}
@@ -781,11 +1367,11 @@ func (e *Executor) processAdapterEvents(eventChan domain.AdapterEventChan, attem
 			switch event.Type {
 			case domain.EventRequestInfo:
 				if event.RequestInfo != nil {
-					attempt.RequestInfo = event.RequestInfo
+					attempt.RequestInfo = redactRequestInfo(event.RequestInfo, provider)
 				}
 			case domain.EventResponseInfo:
 				if event.ResponseInfo != nil {
-					attempt.ResponseInfo = event.ResponseInfo
+					attempt.ResponseInfo = redactResponseInfo(event.ResponseInfo, provider)
 				}
 			case domain.EventMetrics:
 				if event.Metrics != nil {
@@ -810,7 +1396,7 @@ func (e *Executor) processAdapterEvents(eventChan domain.AdapterEventChan, attem
 
 // processAdapterEventsRealtime processes events in real-time during adapter execution
 // It broadcasts updates immediately when RequestInfo/ResponseInfo are received
-func (e *Executor) processAdapterEventsRealtime(eventChan domain.AdapterEventChan, attempt *domain.ProxyUpstreamAttempt, done chan struct{}) {
+func (e *Executor) processAdapterEventsRealtime(eventChan domain.AdapterEventChan, attempt *domain.ProxyUpstreamAttempt, provider *domain.Provider, done chan struct{}) {
 	defer close(done)
 
 	if eventChan == nil || attempt == nil {
@@ -827,12 +1413,12 @@ func (e *Executor) processAdapterEventsRealtime(eventChan domain.AdapterEventCha
 		switch event.Type {
 		case domain.EventRequestInfo:
 			if event.RequestInfo != nil {
-				attempt.RequestInfo = event.RequestInfo
+				attempt.RequestInfo = redactRequestInfo(event.RequestInfo, provider)
 				needsBroadcast = true
 			}
 		case domain.EventResponseInfo:
 			if event.ResponseInfo != nil {
-				attempt.ResponseInfo = event.ResponseInfo
+				attempt.ResponseInfo = redactResponseInfo(event.ResponseInfo, provider)
 				needsBroadcast = true
 			}
 		case domain.EventMetrics:
@@ -859,3 +1445,17 @@ func (e *Executor) processAdapterEventsRealtime(eventChan domain.AdapterEventCha
 	}
 }
 
+// redactRequestInfo returns a copy of info with redaction rules applied to the body.
+// The adapter's original RequestInfo is left untouched.
+func redactRequestInfo(info *domain.RequestInfo, provider *domain.Provider) *domain.RequestInfo {
+	redacted := *info
+	redacted.Body = redaction.RedactRequest(provider, info.Body)
+	return &redacted
+}
+
+// redactResponseInfo returns a copy of info with redaction rules applied to the body
+func redactResponseInfo(info *domain.ResponseInfo, provider *domain.Provider) *domain.ResponseInfo {
+	redacted := *info
+	redacted.Body = redaction.RedactResponse(provider, info.Body)
+	return &redacted
+}
@@ -0,0 +1,128 @@
+package executor
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestResolveMaxRetriesOverride(t *testing.T) {
+	tests := []struct {
+		value  string
+		limit  int
+		want   int
+		wantOK bool
+	}{
+		{"", 5, 0, false},
+		{"abc", 5, 0, false},
+		{"-1", 5, 0, false},
+		{"0", 5, 0, true},  // force no retry
+		{"3", 5, 3, true},  // within the cap
+		{"5", 5, 5, true},  // at the cap
+		{"20", 5, 5, true}, // beyond the cap is clamped
+		{"2", 0, 0, true},  // cap 0 only allows disabling retries
+	}
+
+	for _, tt := range tests {
+		got, ok := resolveMaxRetriesOverride(tt.value, tt.limit)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("resolveMaxRetriesOverride(%q, %d) = (%d, %v), want (%d, %v)",
+				tt.value, tt.limit, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestClampMaxOutputTokens(t *testing.T) {
+	tests := []struct {
+		name       string
+		clientType domain.ClientType
+		body       string
+		want       string // expected fragment after clamping, empty = unchanged
+		original   int
+	}{
+		{"claude over", domain.ClientTypeClaude, `{"model":"m","max_tokens":64000}`, `"max_tokens":8192`, 64000},
+		{"claude under", domain.ClientTypeClaude, `{"model":"m","max_tokens":4096}`, "", 0},
+		{"openai over", domain.ClientTypeOpenAI, `{"model":"m","max_completion_tokens":32000}`, `"max_completion_tokens":8192`, 32000},
+		{"openai under", domain.ClientTypeOpenAI, `{"model":"m","max_tokens":100}`, "", 0},
+		{"gemini over", domain.ClientTypeGemini, `{"generationConfig":{"maxOutputTokens":65536}}`, `"maxOutputTokens":8192`, 65536},
+		{"gemini under", domain.ClientTypeGemini, `{"generationConfig":{"maxOutputTokens":8192}}`, "", 0},
+		{"gemini without config", domain.ClientTypeGemini, `{"contents":[]}`, "", 0},
+	}
+
+	for _, tt := range tests {
+		got, original := clampMaxOutputTokens([]byte(tt.body), tt.clientType, 8192)
+		if original != tt.original {
+			t.Errorf("%s: original = %d, want %d", tt.name, original, tt.original)
+		}
+		if tt.want == "" {
+			if string(got) != tt.body {
+				t.Errorf("%s: body changed to %s", tt.name, got)
+			}
+		} else if !strings.Contains(string(got), tt.want) {
+			t.Errorf("%s: body %s does not contain %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHedgeWriterFirstWriterWins(t *testing.T) {
+	rec := httptest.NewRecorder()
+	race := newHedgeRace()
+	primaryCtx, cancelPrimary := context.WithCancel(context.Background())
+	defer cancelPrimary()
+	race.start(0, cancelPrimary)
+	race.start(1, func() {})
+
+	primary := newHedgeWriter(rec, race, 0)
+	hedge := newHedgeWriter(rec, race, 1)
+
+	primary.Header().Set("X-Leg", "primary")
+	hedge.Header().Set("X-Leg", "hedge")
+	hedge.WriteHeader(200)
+	if _, err := hedge.Write([]byte("hedge")); err != nil {
+		t.Fatalf("winner write failed: %v", err)
+	}
+
+	if primaryCtx.Err() == nil {
+		t.Error("loser was not cancelled")
+	}
+	if _, err := primary.Write([]byte("primary")); err != errHedgeLost {
+		t.Errorf("loser write error = %v, want errHedgeLost", err)
+	}
+	if got := rec.Body.String(); got != "hedge" {
+		t.Errorf("client body = %q, want only the winner's output", got)
+	}
+	if got := rec.Header().Get("X-Leg"); got != "hedge" {
+		t.Errorf("client header X-Leg = %q, want hedge", got)
+	}
+	if race.start(0, func() {}) {
+		t.Error("start after the race was decided should fail")
+	}
+}
+
+func TestFailedAttemptResponseModel(t *testing.T) {
+	e := &Executor{}
+	provider := &domain.Provider{ID: 1, Name: "primary"}
+
+	// An upstream that names the model in its error body keeps that model
+	record := &domain.ProxyUpstreamAttempt{MappedModel: "claude-sonnet-4", Status: "FAILED"}
+	events := domain.NewAdapterEventChan()
+	done := make(chan struct{})
+	go e.processAdapterEventsRealtime(events, record, provider, done)
+	events.SendResponseModel("claude-sonnet-4-20250514")
+	events.Close()
+	<-done
+	attributeResponseModel(record)
+	if record.ResponseModel != "claude-sonnet-4-20250514" {
+		t.Errorf("response model = %q, want the upstream's", record.ResponseModel)
+	}
+
+	// Without one the failure is attributed to the mapped model
+	record = &domain.ProxyUpstreamAttempt{MappedModel: "claude-sonnet-4", Status: "FAILED"}
+	attributeResponseModel(record)
+	if record.ResponseModel != "claude-sonnet-4" {
+		t.Errorf("response model = %q, want the mapped model", record.ResponseModel)
+	}
+}
@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"context"
+	"time"
+
+	ctxutil "github.com/awsl-project/maxx/internal/context"
+	"github.com/awsl-project/maxx/internal/cooldown"
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/router"
+)
+
+// failFastCooldown is the shortest cooldown a fail-fast route puts its provider in after a failure
+const failFastCooldown = 30 * time.Second
+
+// routeRetryConfig resolves the retry config of a matched route: the route's own or the
+// default, then the request's max retries override. Fail-fast routes never retry, whatever
+// either of them says.
+func (e *Executor) routeRetryConfig(route *router.MatchedRoute, proxyReq *domain.ProxyRequest) *domain.RetryConfig {
+	config := e.getRetryConfig(route.RetryConfig)
+	maxRetries := config.MaxRetries
+	if proxyReq.MaxRetriesOverride != nil {
+		maxRetries = *proxyReq.MaxRetriesOverride
+	}
+	if route.Route.FailFast {
+		maxRetries = 0
+	}
+	if maxRetries == config.MaxRetries {
+		return config
+	}
+	overridden := *config
+	overridden.MaxRetries = maxRetries
+	return &overridden
+}
+
+// applyFailFastCooldown cools a fail-fast route's provider down for at least failFastCooldown
+// after any failure, so following requests move on to the next route straight away. A longer
+// cooldown already caused by the error is kept.
+func applyFailFastCooldown(ctx context.Context, provider *domain.Provider) {
+	clientType := string(ctxutil.GetOriginalClientType(ctx))
+	if clientType == "" {
+		clientType = string(ctxutil.GetClientType(ctx))
+	}
+	until := time.Now().Add(failFastCooldown)
+	if cooldown.Default().GetCooldownUntil(provider.ID, clientType).Before(until) {
+		cooldown.Default().UpdateCooldown(provider.ID, clientType, until)
+	}
+}
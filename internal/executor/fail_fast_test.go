@@ -0,0 +1,80 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ctxutil "github.com/awsl-project/maxx/internal/context"
+	"github.com/awsl-project/maxx/internal/cooldown"
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/router"
+)
+
+func TestRouteRetryConfigFailFast(t *testing.T) {
+	config := &domain.RetryConfig{MaxRetries: 3, BackoffRate: 2}
+	override := 5
+
+	tests := []struct {
+		name     string
+		failFast bool
+		override *int
+		want     int
+	}{
+		{"route config", false, nil, 3},
+		{"request override", false, &override, 5},
+		{"fail-fast skips retries", true, nil, 0},
+		{"fail-fast wins over request override", true, &override, 0},
+	}
+
+	e := &Executor{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := &router.MatchedRoute{Route: &domain.Route{FailFast: tt.failFast}, RetryConfig: config}
+			got := e.routeRetryConfig(route, &domain.ProxyRequest{MaxRetriesOverride: tt.override})
+			if got.MaxRetries != tt.want {
+				t.Errorf("MaxRetries = %d, want %d", got.MaxRetries, tt.want)
+			}
+			if got.BackoffRate != config.BackoffRate {
+				t.Errorf("BackoffRate = %v, want the route config's %v", got.BackoffRate, config.BackoffRate)
+			}
+		})
+	}
+	if config.MaxRetries != 3 {
+		t.Errorf("shared retry config modified: MaxRetries = %d", config.MaxRetries)
+	}
+}
+
+func TestApplyFailFastCooldown(t *testing.T) {
+	ctx := ctxutil.WithClientType(context.Background(), domain.ClientTypeClaude)
+
+	tests := []struct {
+		name       string
+		providerID uint64
+		existing   time.Duration // cooldown already set by the error, 0 for none
+		wantMin    time.Duration
+		wantMax    time.Duration
+	}{
+		{"first error cools down", 9201, 0, failFastCooldown - time.Second, failFastCooldown},
+		{"shorter cooldown is extended", 9202, 5 * time.Second, failFastCooldown - time.Second, failFastCooldown},
+		{"longer cooldown is kept", 9203, time.Hour, time.Hour - time.Second, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer cooldown.Default().ClearCooldown(tt.providerID, "")
+			if tt.existing > 0 {
+				cooldown.Default().UpdateCooldown(tt.providerID, "claude", time.Now().Add(tt.existing))
+			}
+
+			applyFailFastCooldown(ctx, &domain.Provider{ID: tt.providerID})
+
+			if !cooldown.Default().IsInCooldown(tt.providerID, "claude") {
+				t.Fatal("provider not in cooldown")
+			}
+			left := time.Until(cooldown.Default().GetCooldownUntil(tt.providerID, "claude"))
+			if left < tt.wantMin || left > tt.wantMax {
+				t.Errorf("cooldown left = %v, want between %v and %v", left, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package executor
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/converter"
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/router"
+)
+
+// FallbackResponseHeader marks the canned responses sent when every route failed
+const FallbackResponseHeader = "X-Maxx-Fallback-Response"
+
+// fallbackConfig returns the fallback response for a request whose routes all failed:
+// the first matched route's that is enabled, else the project's. nil when none is.
+func (e *Executor) fallbackConfig(projectID uint64, routes []*router.MatchedRoute) *domain.FallbackResponseConfig {
+	for _, r := range routes {
+		if cfg := r.Route.FallbackResponse; cfg != nil && cfg.Enabled {
+			return cfg
+		}
+	}
+	if projectID == 0 || e.projectRepo == nil {
+		return nil
+	}
+	project, err := e.projectRepo.GetByID(projectID)
+	if err != nil || project.FallbackResponse == nil || !project.FallbackResponse.Enabled {
+		return nil
+	}
+	return project.FallbackResponse
+}
+
+// fallbackMessage fills in the placeholders of the configured message
+func fallbackMessage(cfg *domain.FallbackResponseConfig, proxyReq *domain.ProxyRequest, lastErr error, now time.Time) string {
+	message := cfg.Message
+	if strings.TrimSpace(message) == "" {
+		message = domain.DefaultFallbackMessage
+	}
+	errText := "all routes failed"
+	if lastErr != nil {
+		errText = lastErr.Error()
+	}
+	return strings.NewReplacer(
+		"{model}", proxyReq.RequestModel,
+		"{error}", errText,
+		"{requestID}", proxyReq.RequestID,
+		"{time}", now.Format(time.RFC3339),
+	).Replace(message)
+}
+
+// sendFallback answers the client with the fallback response in place of the error
+// and marks the request. The request stays FAILED, so stats don't count it as a success.
+func sendFallback(w http.ResponseWriter, cfg *domain.FallbackResponseConfig, proxyReq *domain.ProxyRequest, lastErr error, stream bool) {
+	now := time.Now()
+	id := strings.ReplaceAll(proxyReq.RequestID, ".", "")
+	body := fallbackBody(proxyReq.ClientType, stream, proxyReq.RequestModel, id, fallbackMessage(cfg, proxyReq, lastErr, now), now)
+
+	if stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set(FallbackResponseHeader, "true")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	proxyReq.FallbackResponse = true
+	proxyReq.ResponseInfo = &domain.ResponseInfo{
+		Status:  http.StatusOK,
+		Headers: flattenHeaders(w.Header()),
+		Body:    string(body),
+	}
+}
+
+// fallbackBody builds a complete assistant reply carrying text in the client's format,
+// as a whole response or as the stream of events that makes it up
+func fallbackBody(clientType domain.ClientType, stream bool, model, id, text string, now time.Time) []byte {
+	switch clientType {
+	case domain.ClientTypeClaude:
+		message := map[string]any{
+			"id":            "msg_fallback_" + id,
+			"type":          "message",
+			"role":          "assistant",
+			"model":         model,
+			"content":       []map[string]any{{"type": "text", "text": text}},
+			"stop_reason":   "end_turn",
+			"stop_sequence": nil,
+			"usage":         map[string]int{"input_tokens": 0, "output_tokens": 0},
+		}
+		if !stream {
+			return mustJSON(message)
+		}
+		message["content"] = []any{}
+		message["stop_reason"] = nil
+		var out []byte
+		out = append(out, converter.FormatSSE("message_start", map[string]any{"type": "message_start", "message": message})...)
+		out = append(out, converter.FormatSSE("content_block_start", map[string]any{
+			"type": "content_block_start", "index": 0, "content_block": map[string]any{"type": "text", "text": ""},
+		})...)
+		out = append(out, converter.FormatSSE("content_block_delta", map[string]any{
+			"type": "content_block_delta", "index": 0, "delta": map[string]any{"type": "text_delta", "text": text},
+		})...)
+		out = append(out, converter.FormatSSE("content_block_stop", map[string]any{"type": "content_block_stop", "index": 0})...)
+		out = append(out, converter.FormatSSE("message_delta", map[string]any{
+			"type":  "message_delta",
+			"delta": map[string]any{"stop_reason": "end_turn", "stop_sequence": nil},
+			"usage": map[string]int{"output_tokens": 0},
+		})...)
+		out = append(out, converter.FormatSSE("message_stop", map[string]any{"type": "message_stop"})...)
+		return out
+
+	case domain.ClientTypeCodex:
+		item := map[string]any{
+			"type":    "message",
+			"id":      "msg_fallback_" + id,
+			"status":  "completed",
+			"role":    "assistant",
+			"content": []map[string]any{{"type": "output_text", "text": text, "annotations": []any{}}},
+		}
+		response := map[string]any{
+			"id":         "resp_fallback_" + id,
+			"object":     "response",
+			"created_at": now.Unix(),
+			"model":      model,
+			"status":     "completed",
+			"output":     []any{item},
+			"usage":      map[string]int{"input_tokens": 0, "output_tokens": 0, "total_tokens": 0},
+		}
+		if !stream {
+			return mustJSON(response)
+		}
+		created := map[string]any{}
+		for k, v := range response {
+			created[k] = v
+		}
+		created["status"] = "in_progress"
+		created["output"] = []any{}
+		itemID := item["id"]
+		var out []byte
+		for _, event := range []map[string]any{
+			{"type": "response.created", "response": created},
+			{"type": "response.output_item.added", "output_index": 0,
+				"item": map[string]any{"type": "message", "id": itemID, "status": "in_progress", "role": "assistant", "content": []any{}}},
+			{"type": "response.content_part.added", "item_id": itemID, "output_index": 0, "content_index": 0,
+				"part": map[string]any{"type": "output_text", "text": "", "annotations": []any{}}},
+			{"type": "response.output_text.delta", "item_id": itemID, "output_index": 0, "content_index": 0, "delta": text},
+			{"type": "response.output_text.done", "item_id": itemID, "output_index": 0, "content_index": 0, "text": text},
+			{"type": "response.content_part.done", "item_id": itemID, "output_index": 0, "content_index": 0,
+				"part": map[string]any{"type": "output_text", "text": text, "annotations": []any{}}},
+			{"type": "response.output_item.done", "output_index": 0, "item": item},
+			{"type": "response.completed", "response": response},
+		} {
+			out = append(out, converter.FormatSSE(event["type"].(string), event)...)
+		}
+		return out
+
+	case domain.ClientTypeGemini:
+		response := map[string]any{
+			"candidates": []map[string]any{{
+				"content":      map[string]any{"role": "model", "parts": []map[string]any{{"text": text}}},
+				"finishReason": "STOP",
+				"index":        0,
+			}},
+			"usageMetadata": map[string]int{"promptTokenCount": 0, "candidatesTokenCount": 0, "totalTokenCount": 0},
+			"modelVersion":  model,
+		}
+		if !stream {
+			return mustJSON(response)
+		}
+		return converter.FormatSSE("", response)
+
+	default:
+		// OpenAI Chat Completions
+		if !stream {
+			return mustJSON(map[string]any{
+				"id":      "chatcmpl-fallback-" + id,
+				"object":  "chat.completion",
+				"created": now.Unix(),
+				"model":   model,
+				"choices": []map[string]any{{
+					"index":         0,
+					"message":       map[string]any{"role": "assistant", "content": text},
+					"finish_reason": "stop",
+				}},
+				"usage": map[string]int{"prompt_tokens": 0, "completion_tokens": 0, "total_tokens": 0},
+			})
+		}
+		chunk := func(delta map[string]any, finishReason any) []byte {
+			return converter.FormatSSE("", map[string]any{
+				"id":      "chatcmpl-fallback-" + id,
+				"object":  "chat.completion.chunk",
+				"created": now.Unix(),
+				"model":   model,
+				"choices": []map[string]any{{"index": 0, "delta": delta, "finish_reason": finishReason}},
+			})
+		}
+		var out []byte
+		out = append(out, chunk(map[string]any{"role": "assistant", "content": text}, nil)...)
+		out = append(out, chunk(map[string]any{}, "stop")...)
+		return append(out, converter.FormatDone()...)
+	}
+}
+
+func mustJSON(v any) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+// commitTracker records whether anything was sent to the client, after which
+// a fallback response can no longer replace the error
+type commitTracker struct {
+	http.ResponseWriter
+	committed bool
+}
+
+func (c *commitTracker) WriteHeader(code int) {
+	c.committed = true
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *commitTracker) Write(b []byte) (int, error) {
+	c.committed = true
+	return c.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher for streaming support
+func (c *commitTracker) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
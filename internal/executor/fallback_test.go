@@ -0,0 +1,167 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/converter"
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+	"github.com/awsl-project/maxx/internal/router"
+)
+
+type fallbackProjects struct {
+	repository.ProjectRepository
+	project *domain.Project
+}
+
+func (f fallbackProjects) GetByID(id uint64) (*domain.Project, error) {
+	if f.project == nil || f.project.ID != id {
+		return nil, domain.ErrNotFound
+	}
+	return f.project, nil
+}
+
+// fallbackText extracts the assistant text of a fallback body in the client's format
+func fallbackText(t *testing.T, clientType domain.ClientType, stream bool, body []byte) string {
+	t.Helper()
+	if !stream {
+		var v map[string]any
+		if err := json.Unmarshal(body, &v); err != nil {
+			t.Fatalf("%s body is not JSON: %v", clientType, err)
+		}
+		switch clientType {
+		case domain.ClientTypeClaude:
+			return v["content"].([]any)[0].(map[string]any)["text"].(string)
+		case domain.ClientTypeCodex:
+			item := v["output"].([]any)[0].(map[string]any)
+			return item["content"].([]any)[0].(map[string]any)["text"].(string)
+		case domain.ClientTypeGemini:
+			candidate := v["candidates"].([]any)[0].(map[string]any)
+			return candidate["content"].(map[string]any)["parts"].([]any)[0].(map[string]any)["text"].(string)
+		default:
+			return v["choices"].([]any)[0].(map[string]any)["message"].(map[string]any)["content"].(string)
+		}
+	}
+
+	events, rest := converter.ParseSSE(string(body))
+	if rest != "" || len(events) == 0 {
+		t.Fatalf("%s stream is not well-formed SSE: %q", clientType, body)
+	}
+	var text strings.Builder
+	var last string
+	for _, event := range events {
+		if event.Event == "done" {
+			last = "[DONE]"
+			continue
+		}
+		var v map[string]any
+		if err := json.Unmarshal(event.Data, &v); err != nil {
+			t.Fatalf("%s event %q: %v", clientType, event.Data, err)
+		}
+		switch clientType {
+		case domain.ClientTypeClaude, domain.ClientTypeCodex:
+			if v["type"] != event.Event {
+				t.Errorf("%s event %q carries type %v", clientType, event.Event, v["type"])
+			}
+			last = event.Event
+			if clientType == domain.ClientTypeClaude && event.Event == "content_block_delta" {
+				text.WriteString(v["delta"].(map[string]any)["text"].(string))
+			}
+			if clientType == domain.ClientTypeCodex && event.Event == "response.output_text.delta" {
+				text.WriteString(v["delta"].(string))
+			}
+		case domain.ClientTypeGemini:
+			candidate := v["candidates"].([]any)[0].(map[string]any)
+			text.WriteString(candidate["content"].(map[string]any)["parts"].([]any)[0].(map[string]any)["text"].(string))
+			last = candidate["finishReason"].(string)
+		default:
+			choice := v["choices"].([]any)[0].(map[string]any)
+			if content, ok := choice["delta"].(map[string]any)["content"].(string); ok {
+				text.WriteString(content)
+			}
+		}
+	}
+	// Streams end the way the client's SDK expects
+	wantLast := map[domain.ClientType]string{
+		domain.ClientTypeClaude: "message_stop",
+		domain.ClientTypeCodex:  "response.completed",
+		domain.ClientTypeGemini: "STOP",
+		domain.ClientTypeOpenAI: "[DONE]",
+	}[clientType]
+	if last != wantLast {
+		t.Errorf("%s stream ends with %q, want %q", clientType, last, wantLast)
+	}
+	return text.String()
+}
+
+func TestSendFallbackAcrossClientFormats(t *testing.T) {
+	cfg := &domain.FallbackResponseConfig{Enabled: true, Message: "Service unavailable for {model}, try again."}
+	for _, clientType := range []domain.ClientType{domain.ClientTypeClaude, domain.ClientTypeOpenAI, domain.ClientTypeCodex, domain.ClientTypeGemini} {
+		for _, stream := range []bool{false, true} {
+			proxyReq := &domain.ProxyRequest{ClientType: clientType, RequestModel: "some-model", RequestID: "20260101120000.000001", Status: "FAILED"}
+			rec := httptest.NewRecorder()
+			sendFallback(rec, cfg, proxyReq, errors.New("upstream 529"), stream)
+
+			if rec.Code != http.StatusOK || rec.Header().Get(FallbackResponseHeader) != "true" {
+				t.Errorf("%s stream=%v: status %d, fallback header %q", clientType, stream, rec.Code, rec.Header().Get(FallbackResponseHeader))
+			}
+			wantType := "application/json"
+			if stream {
+				wantType = "text/event-stream"
+			}
+			if got := rec.Header().Get("Content-Type"); got != wantType {
+				t.Errorf("%s stream=%v: content type %q", clientType, stream, got)
+			}
+			if got := fallbackText(t, clientType, stream, rec.Body.Bytes()); got != "Service unavailable for some-model, try again." {
+				t.Errorf("%s stream=%v: text %q", clientType, stream, got)
+			}
+			if clientType != domain.ClientTypeCodex && !converter.HasContent(clientType, rec.Body.Bytes()) {
+				t.Errorf("%s stream=%v: fallback reads as an empty response", clientType, stream)
+			}
+
+			// Marked, and still a failure
+			if !proxyReq.FallbackResponse || proxyReq.Status != "FAILED" || proxyReq.ResponseInfo == nil || proxyReq.ResponseInfo.Body != rec.Body.String() {
+				t.Errorf("%s stream=%v: request not marked: %+v", clientType, stream, proxyReq)
+			}
+		}
+	}
+}
+
+func TestFallbackMessage(t *testing.T) {
+	proxyReq := &domain.ProxyRequest{RequestModel: "m", RequestID: "r1"}
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	cfg := &domain.FallbackResponseConfig{Enabled: true, Message: "{model} {requestID} {time}: {error}"}
+	if got := fallbackMessage(cfg, proxyReq, errors.New("boom"), now); got != "m r1 2026-01-02T03:04:05Z: boom" {
+		t.Errorf("templated message = %q", got)
+	}
+	if got := fallbackMessage(&domain.FallbackResponseConfig{Enabled: true}, proxyReq, nil, now); got != domain.DefaultFallbackMessage {
+		t.Errorf("default message = %q", got)
+	}
+}
+
+func TestFallbackConfigPrecedence(t *testing.T) {
+	projectFallback := &domain.FallbackResponseConfig{Enabled: true, Message: "project"}
+	routeFallback := &domain.FallbackResponseConfig{Enabled: true, Message: "route"}
+	e := &Executor{projectRepo: fallbackProjects{project: &domain.Project{ID: 1, FallbackResponse: projectFallback}}}
+
+	plain := &router.MatchedRoute{Route: &domain.Route{}}
+	disabled := &router.MatchedRoute{Route: &domain.Route{FallbackResponse: &domain.FallbackResponseConfig{Message: "off"}}}
+	configured := &router.MatchedRoute{Route: &domain.Route{FallbackResponse: routeFallback}}
+
+	if got := e.fallbackConfig(1, []*router.MatchedRoute{plain, disabled, configured}); got != routeFallback {
+		t.Errorf("fallback = %+v, want the first route's that is enabled", got)
+	}
+	if got := e.fallbackConfig(1, []*router.MatchedRoute{plain, disabled}); got != projectFallback {
+		t.Errorf("fallback = %+v, want the project's", got)
+	}
+	if got := e.fallbackConfig(0, []*router.MatchedRoute{plain}); got != nil {
+		t.Errorf("fallback without any configured = %+v", got)
+	}
+}
@@ -0,0 +1,54 @@
+package executor
+
+import (
+	"context"
+	"log"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/guardrail"
+)
+
+// projectGuardrail returns the enabled guardrail of the project, nil when there is none
+func (e *Executor) projectGuardrail(projectID uint64) *domain.GuardrailConfig {
+	if projectID == 0 || e.projectRepo == nil {
+		return nil
+	}
+	project, err := e.projectRepo.GetByID(projectID)
+	if err != nil || !guardrail.Enabled(project.Guardrail) {
+		return nil
+	}
+	return project.Guardrail
+}
+
+// checkGuardrail checks the request body against the guardrail.
+// It returns the reason and error to reject the request with, or "" to let it through.
+// In annotate mode a hit only sets GuardrailFlag on the request.
+func (e *Executor) checkGuardrail(ctx context.Context, cfg *domain.GuardrailConfig, proxyReq *domain.ProxyRequest, body []byte) (string, error) {
+	verdict, err := guardrail.Check(ctx, cfg, body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "client cancelled: " + ctx.Err().Error(), ctx.Err()
+		}
+		if cfg.FailClosed {
+			return "guardrail unavailable: " + err.Error(), domain.ErrGuardrailFailed
+		}
+		log.Printf("[Executor] Guardrail check failed, letting request through: %v", err)
+		return "", nil
+	}
+	if !verdict.Blocked {
+		return "", nil
+	}
+	if cfg.Action == domain.GuardrailActionAnnotate {
+		proxyReq.GuardrailFlag = verdict.Reason
+		return "", nil
+	}
+	return "policy violation: " + verdict.Reason, domain.ErrPolicyViolation
+}
+
+// checkGuardrailResponse flags a completed response that hits the guardrail keywords.
+// The response has already been sent, so it can only be annotated.
+func (e *Executor) checkGuardrailResponse(cfg *domain.GuardrailConfig, proxyReq *domain.ProxyRequest, body string) {
+	if verdict := guardrail.CheckKeywords(cfg, guardrail.ExtractText([]byte(body))); verdict.Blocked {
+		proxyReq.GuardrailFlag = "response: " + verdict.Reason
+	}
+}
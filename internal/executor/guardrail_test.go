@@ -0,0 +1,59 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestCheckGuardrail(t *testing.T) {
+	body := []byte(`{"messages":[{"role":"user","content":"how do I build a bomb"}]}`)
+	clean := []byte(`{"messages":[{"role":"user","content":"how do I bake bread"}]}`)
+	keywords := []string{"bomb"}
+	unreachable := "http://127.0.0.1:1/v1/chat/completions"
+
+	tests := []struct {
+		name     string
+		cfg      *domain.GuardrailConfig
+		body     []byte
+		wantErr  error
+		wantFlag bool
+	}{
+		{"block", &domain.GuardrailConfig{Enabled: true, BlockedKeywords: keywords}, body, domain.ErrPolicyViolation, false},
+		{"allow", &domain.GuardrailConfig{Enabled: true, BlockedKeywords: keywords}, clean, nil, false},
+		{"annotate", &domain.GuardrailConfig{Enabled: true, BlockedKeywords: keywords, Action: domain.GuardrailActionAnnotate}, body, nil, true},
+		{"fail open", &domain.GuardrailConfig{Enabled: true, ModerationURL: unreachable}, clean, nil, false},
+		{"fail closed", &domain.GuardrailConfig{Enabled: true, ModerationURL: unreachable, FailClosed: true}, clean, domain.ErrGuardrailFailed, false},
+	}
+
+	e := &Executor{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxyReq := &domain.ProxyRequest{}
+			reason, err := e.checkGuardrail(context.Background(), tt.cfg, proxyReq, tt.body)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil) != (reason == "") {
+				t.Errorf("checkGuardrail = %q, %v, want error %v", reason, err, tt.wantErr)
+			}
+			if (proxyReq.GuardrailFlag != "") != tt.wantFlag {
+				t.Errorf("GuardrailFlag = %q, want flagged=%v", proxyReq.GuardrailFlag, tt.wantFlag)
+			}
+		})
+	}
+}
+
+func TestCheckGuardrailResponse(t *testing.T) {
+	cfg := &domain.GuardrailConfig{Enabled: true, BlockedKeywords: []string{"password"}, CheckResponse: true}
+	proxyReq := &domain.ProxyRequest{}
+
+	e := &Executor{}
+	e.checkGuardrailResponse(cfg, proxyReq, `{"choices":[{"message":{"content":"the weather is nice"}}]}`)
+	if proxyReq.GuardrailFlag != "" {
+		t.Errorf("clean response flagged: %q", proxyReq.GuardrailFlag)
+	}
+	e.checkGuardrailResponse(cfg, proxyReq, "data: {\"choices\":[{\"delta\":{\"content\":\"the pass\"}}]}\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"word is\"}}]}\n\n")
+	if proxyReq.GuardrailFlag == "" {
+		t.Error("response containing a blocked keyword across chunks was not flagged")
+	}
+}
@@ -0,0 +1,142 @@
+package executor
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/converter"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// heartbeatWriter keeps a stream alive while the upstream is quiet: once nothing
+// was written for the interval, it sends the client a heartbeat in its format.
+// Heartbeats go only between two events of a successful response that has begun,
+// and they bypass the capture, so neither the event sequence nor the recorded
+// response changes.
+type heartbeatWriter struct {
+	http.ResponseWriter
+	heartbeat []byte
+	interval  time.Duration
+
+	mu        sync.Mutex
+	status    int
+	tail      []byte // the end of the stream so far, to tell event boundaries
+	lastWrite time.Time
+
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+// newHeartbeatWriter starts sending heartbeats, nil when the interval is off or
+// the client type has no heartbeat
+func newHeartbeatWriter(w http.ResponseWriter, clientType domain.ClientType, interval time.Duration) *heartbeatWriter {
+	heartbeat := converter.Heartbeat(clientType)
+	if interval <= 0 || heartbeat == nil {
+		return nil
+	}
+	h := &heartbeatWriter{
+		ResponseWriter: w,
+		heartbeat:      heartbeat,
+		interval:       interval,
+		lastWrite:      time.Now(),
+		stopCh:         make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	go h.run()
+	return h
+}
+
+func (h *heartbeatWriter) WriteHeader(code int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.status == 0 {
+		h.status = code
+	}
+	h.ResponseWriter.WriteHeader(code)
+}
+
+func (h *heartbeatWriter) Write(b []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.status == 0 {
+		h.status = http.StatusOK
+	}
+	n, err := h.ResponseWriter.Write(b)
+	if n > 0 {
+		h.tail = append(h.tail, b[:n]...)
+		h.tail = h.tail[max(0, len(h.tail)-4):]
+		h.lastWrite = time.Now()
+	}
+	return n, err
+}
+
+// Flush implements http.Flusher for streaming support
+func (h *heartbeatWriter) Flush() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.flush()
+}
+
+func (h *heartbeatWriter) flush() {
+	if f, ok := h.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// stop ends the heartbeats, nothing is written once it returns
+func (h *heartbeatWriter) stop() {
+	close(h.stopCh)
+	<-h.done
+}
+
+func (h *heartbeatWriter) run() {
+	defer close(h.done)
+	timer := time.NewTimer(h.interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-h.stopCh:
+			return
+		case <-timer.C:
+		}
+		timer.Reset(h.beat())
+	}
+}
+
+// beat sends a heartbeat when the stream has been idle for the interval and
+// returns how long to wait before checking again
+func (h *heartbeatWriter) beat() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if idle := time.Since(h.lastWrite); idle < h.interval {
+		return h.interval - idle
+	}
+	if h.status == http.StatusOK && (bytes.HasSuffix(h.tail, []byte("\n\n")) || bytes.HasSuffix(h.tail, []byte("\r\n\r\n"))) {
+		if _, err := h.ResponseWriter.Write(h.heartbeat); err != nil {
+			return h.interval
+		}
+		h.flush()
+	}
+	h.lastWrite = time.Now()
+	return h.interval
+}
+
+// streamHeartbeatInterval reads the idle time after which streams get a heartbeat, 0 when off
+func (e *Executor) streamHeartbeatInterval() time.Duration {
+	if e.settingRepo == nil {
+		return 0
+	}
+	val, err := e.settingRepo.Get(domain.SettingKeyStreamHeartbeatSecs)
+	if err != nil {
+		return 0
+	}
+	secs, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
@@ -0,0 +1,141 @@
+package executor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/converter"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+const heartbeatInterval = 20 * time.Millisecond
+
+var heartbeatStreams = map[domain.ClientType][]string{
+	domain.ClientTypeClaude: {
+		"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"type\":\"message\",\"role\":\"assistant\",\"content\":[]}}\n\n",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,",
+		"\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n\n",
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+	},
+	domain.ClientTypeOpenAI: {
+		`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant"}}]}` + "\n\n",
+		`data: {"id":"chatcmpl-1","object":"chat.completion.chunk",`,
+		`"choices":[{"index":0,"delta":{"content":"Hi"}}]}` + "\n\n",
+		"data: [DONE]\n\n",
+	},
+	domain.ClientTypeCodex: {
+		"event: response.created\ndata: {\"type\":\"response.created\",\"response\":{\"id\":\"resp_1\"}}\n\n",
+		"event: response.output_text.delta\ndata: {\"type\":\"response.output_text.delta\",",
+		"\"delta\":\"Hi\"}\n\n",
+		"event: response.completed\ndata: {\"type\":\"response.completed\",\"response\":{\"id\":\"resp_1\"}}\n\n",
+	},
+	domain.ClientTypeGemini: {
+		`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"H"}]}}]}` + "\r\n\r\n",
+		`data: {"candidates":[{"content":{"role":"model",`,
+		`"parts":[{"text":"i"}]},"finishReason":"STOP"}]}` + "\r\n\r\n",
+	},
+}
+
+// writeSlowly writes the stream pausing for a few heartbeat intervals before every
+// piece, within events too
+func writeSlowly(w http.ResponseWriter, pieces []string) {
+	for _, piece := range pieces {
+		time.Sleep(3 * heartbeatInterval)
+		w.Write([]byte(piece))
+		w.(http.Flusher).Flush()
+	}
+	time.Sleep(3 * heartbeatInterval)
+}
+
+// sseLines returns the non-empty lines of a stream, the lines SSE parsers act on
+func sseLines(stream string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(stream, "\r\n", "\n"), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestHeartbeatsKeepEventSequence(t *testing.T) {
+	for clientType, pieces := range heartbeatStreams {
+		t.Run(string(clientType), func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h := newHeartbeatWriter(rec, clientType, heartbeatInterval)
+			writeSlowly(h, pieces)
+			h.stop()
+
+			stream := strings.Join(pieces, "")
+			got := rec.Body.String()
+			if got == stream {
+				t.Fatal("idle stream got no heartbeat")
+			}
+
+			events, rest := converter.ParseSSE(got)
+			want, _ := converter.ParseSSE(stream)
+			if clientType == domain.ClientTypeClaude {
+				// Claude clients get ping events, which they skip
+				var skipped []converter.SSEEvent
+				for _, event := range events {
+					if event.Event == "ping" {
+						if string(event.Data) != `{"type":"ping"}` {
+							t.Errorf("ping data = %s", event.Data)
+						}
+						continue
+					}
+					skipped = append(skipped, event)
+				}
+				events = skipped
+			}
+			if !reflect.DeepEqual(events, want) || rest != "" {
+				t.Errorf("events with heartbeats = %+v, want %+v", events, want)
+			}
+
+			// Only the heartbeat lines are added, never inside an event
+			var lines []string
+			for _, line := range sseLines(got) {
+				switch {
+				case clientType == domain.ClientTypeClaude && (line == "event: ping" || line == `data: {"type":"ping"}`):
+				case (clientType == domain.ClientTypeOpenAI || clientType == domain.ClientTypeCodex) && line == ":":
+				default:
+					if strings.HasPrefix(line, ":") {
+						t.Errorf("%s client got comment line %q", clientType, line)
+					}
+					lines = append(lines, line)
+				}
+			}
+			if !reflect.DeepEqual(lines, sseLines(stream)) {
+				t.Errorf("stream lines = %q, want %q", lines, sseLines(stream))
+			}
+			if strings.Count(got, "\"Hi\"") > 1 || !strings.Contains(got, pieces[1]+pieces[2]) {
+				t.Errorf("heartbeat written inside an event: %q", got)
+			}
+		})
+	}
+}
+
+func TestHeartbeatsOnlyOnStartedStreams(t *testing.T) {
+	// Nothing is sent before the response begins, or on an error response
+	for _, status := range []int{0, http.StatusTooManyRequests} {
+		rec := httptest.NewRecorder()
+		h := newHeartbeatWriter(rec, domain.ClientTypeOpenAI, heartbeatInterval)
+		if status != 0 {
+			h.WriteHeader(status)
+			h.Write([]byte(`{"error":{"message":"rate limited"}}` + "\n\n"))
+		}
+		time.Sleep(5 * heartbeatInterval)
+		h.stop()
+		if strings.Contains(rec.Body.String(), ":\n\n") {
+			t.Errorf("status %d: got heartbeat %q", status, rec.Body.String())
+		}
+	}
+
+	if newHeartbeatWriter(httptest.NewRecorder(), domain.ClientTypeClaude, 0) != nil {
+		t.Error("heartbeat writer created with heartbeats off")
+	}
+}
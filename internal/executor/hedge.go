@@ -0,0 +1,235 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/pricing"
+	"github.com/awsl-project/maxx/internal/usage"
+)
+
+// errHedgeLost is returned to a hedged attempt writing after the other attempt claimed the client
+var errHedgeLost = errors.New("hedged attempt lost the race")
+
+// hedgeRace decides which of two hedged attempts gets to write to the client.
+// The first attempt to write claims the client and cancels the other one.
+type hedgeRace struct {
+	mu      sync.Mutex
+	winner  int // -1 until an attempt claims the client
+	cancels [2]context.CancelFunc
+	claimed chan struct{}
+}
+
+func newHedgeRace() *hedgeRace {
+	return &hedgeRace{winner: -1, claimed: make(chan struct{})}
+}
+
+// start registers the cancel function of an attempt about to run
+// Returns false when the client was already claimed and the attempt should not run
+func (r *hedgeRace) start(leg int, cancel context.CancelFunc) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.winner != -1 {
+		return false
+	}
+	r.cancels[leg] = cancel
+	return true
+}
+
+// claim reports whether leg owns the client, claiming it if nobody has yet
+func (r *hedgeRace) claim(leg int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.winner == -1 {
+		r.winner = leg
+		close(r.claimed)
+		if cancel := r.cancels[1-leg]; cancel != nil {
+			cancel()
+		}
+	}
+	return r.winner == leg
+}
+
+func (r *hedgeRace) result() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.winner
+}
+
+// hedgeWriter buffers headers until its attempt claims the client, then passes
+// everything through. Writes from the losing attempt are dropped.
+type hedgeWriter struct {
+	w       http.ResponseWriter
+	race    *hedgeRace
+	leg     int
+	header  http.Header
+	claimed bool
+}
+
+func newHedgeWriter(w http.ResponseWriter, race *hedgeRace, leg int) *hedgeWriter {
+	return &hedgeWriter{w: w, race: race, leg: leg, header: make(http.Header)}
+}
+
+func (h *hedgeWriter) claim() bool {
+	if h.claimed {
+		return true
+	}
+	if !h.race.claim(h.leg) {
+		return false
+	}
+	h.claimed = true
+	dst := h.w.Header()
+	for k, v := range h.header {
+		dst[k] = v
+	}
+	h.header = dst
+	return true
+}
+
+// Header returns the attempt's own header map until it claims the client
+func (h *hedgeWriter) Header() http.Header {
+	return h.header
+}
+
+// WriteHeader claims the client and forwards the status code
+func (h *hedgeWriter) WriteHeader(code int) {
+	if h.claim() {
+		h.w.WriteHeader(code)
+	}
+}
+
+// Write claims the client and forwards the body
+func (h *hedgeWriter) Write(b []byte) (int, error) {
+	if !h.claim() {
+		return 0, errHedgeLost
+	}
+	return h.w.Write(b)
+}
+
+// Flush implements http.Flusher for streaming support
+func (h *hedgeWriter) Flush() {
+	if !h.claimed {
+		return
+	}
+	if f, ok := h.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// executeHedged runs the primary attempt and, if it has not written its first
+// byte to the client within delay, a parallel attempt on the hedge route.
+// The first attempt to write wins and the other one is cancelled. The winner,
+// or the primary attempt when neither wrote anything, is returned for the
+// regular success/failure handling; the other attempt is finished here.
+func (e *Executor) executeHedged(ctx context.Context, w http.ResponseWriter, req *http.Request, proxyReq *domain.ProxyRequest, primary, hedge *routePlan, delay time.Duration, singleToolCall bool) *attemptRun {
+	race := newHedgeRace()
+	var runs [2]*attemptRun
+	done := make(chan struct{}, 2)
+
+	launch := func(leg int, run *attemptRun) bool {
+		legCtx, cancel := context.WithCancel(run.ctx)
+		if !race.start(leg, cancel) {
+			cancel()
+			return false
+		}
+		run.ctx = legCtx
+		go func() {
+			defer cancel()
+			e.runAttempt(run, newHedgeWriter(w, race, leg), req, singleToolCall)
+			done <- struct{}{}
+		}()
+		return true
+	}
+
+	runs[0] = e.startAttempt(proxyReq, primary, 0)
+	launch(0, runs[0])
+	running := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		log.Printf("[Executor] No response from provider %s after %v, hedging to provider %s",
+			primary.route.Provider.Name, delay, hedge.route.Provider.Name)
+		runs[1] = e.startAttempt(proxyReq, hedge, runs[0].record.ID)
+		if launch(1, runs[1]) {
+			running++
+		} else {
+			// The primary attempt claimed the client in the meantime
+			runs[1].err = context.Canceled
+		}
+	case <-race.claimed:
+	case <-done:
+		running--
+	case <-ctx.Done():
+	}
+	for ; running > 0; running-- {
+		<-done
+	}
+
+	// Pick the attempt reported as the request's outcome
+	main := 0
+	switch winner := race.result(); {
+	case winner != -1:
+		main = winner
+	case runs[0].err != nil && runs[1] != nil && runs[1].err == nil:
+		main = 1
+	}
+
+	if other := runs[1-main]; other != nil {
+		e.finishHedgedAttempt(ctx, other, race.result() != -1)
+	}
+	return runs[main]
+}
+
+// finishHedgedAttempt records the outcome of the hedged attempt that was not
+// reported as the request's outcome. An attempt that lost the race is marked
+// cancelled; one that failed on its own is marked failed and counts towards cooldown.
+func (e *Executor) finishHedgedAttempt(ctx context.Context, run *attemptRun, lost bool) {
+	record := run.record
+	record.EndTime = time.Now()
+	record.Duration = record.EndTime.Sub(record.StartTime)
+	if record.ResponseInfo != nil {
+		record.StatusCode = record.ResponseInfo.Status
+	}
+	attributeResponseModel(record)
+
+	switch {
+	case lost || ctx.Err() != nil:
+		record.Status = "CANCELLED"
+	case run.err == nil:
+		record.Status = "COMPLETED"
+	default:
+		record.Status = "FAILED"
+		if proxyErr, ok := run.err.(*domain.ProxyError); ok {
+			if cappedFrom := e.handleCooldown(run.ctx, proxyErr, run.plan.route.Provider); cappedFrom > 0 {
+				record.CooldownCappedFromSecs = int(cappedFrom / time.Second)
+			}
+		}
+	}
+
+	// The cancelled attempt may still have consumed tokens upstream
+	if record.InputTokenCount > 0 || record.OutputTokenCount > 0 {
+		metrics := &usage.Metrics{
+			InputTokens:          record.InputTokenCount,
+			OutputTokens:         record.OutputTokenCount,
+			CacheReadCount:       record.CacheReadCount,
+			CacheCreationCount:   record.CacheWriteCount,
+			Cache5mCreationCount: record.Cache5mWriteCount,
+			Cache1hCreationCount: record.Cache1hWriteCount,
+		}
+		record.Cost = pricing.GlobalCalculator().Calculate(record.MappedModel, metrics)
+		e.router.TPM().Record(run.plan.route.Provider.ID, int64(record.InputTokenCount+record.OutputTokenCount))
+	}
+	e.recordQuota(run.plan.route.Provider, record)
+
+	_ = e.attemptRepo.Update(record)
+	if e.broadcaster != nil {
+		e.broadcaster.BroadcastProxyUpstreamAttempt(record)
+	}
+}
@@ -0,0 +1,80 @@
+package executor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+const (
+	rateSlotSpan  = time.Second
+	rateSlotCount = 60 // 60 x 1s = rolling one-minute window
+)
+
+// requestRate counts the requests started during a rolling one-minute window.
+// The zero value is ready to use.
+type requestRate struct {
+	mu     sync.Mutex
+	slots  [rateSlotCount]int64 // slot number (unix time / span) of each bucket
+	counts [rateSlotCount]int64
+	now    func() time.Time
+}
+
+func (r *requestRate) slot() int64 {
+	now := time.Now
+	if r.now != nil {
+		now = r.now
+	}
+	return now().UnixNano() / int64(rateSlotSpan)
+}
+
+// add records a request started now
+func (r *requestRate) add() {
+	slot := r.slot()
+	idx := int(slot % rateSlotCount)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.slots[idx] != slot {
+		r.slots[idx] = slot
+		r.counts[idx] = 0
+	}
+	r.counts[idx]++
+}
+
+// perMinute returns the requests started during the last minute
+func (r *requestRate) perMinute() int64 {
+	slot := r.slot()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var total int64
+	for i := range r.slots {
+		if slot-r.slots[i] < rateSlotCount {
+			total += r.counts[i]
+		}
+	}
+	return total
+}
+
+// RecentRPM returns the number of requests started during the last minute
+func (e *Executor) RecentRPM() int64 {
+	return e.rate.perMinute()
+}
+
+// Timezone returns the configured timezone, default Asia/Shanghai like the usage stats buckets
+func (e *Executor) Timezone() *time.Location {
+	value := ""
+	if e.settingRepo != nil {
+		value, _ = e.settingRepo.Get(domain.SettingKeyTimezone)
+	}
+	if value == "" {
+		value = "Asia/Shanghai"
+	}
+	loc, err := time.LoadLocation(value)
+	if err != nil {
+		return time.FixedZone("UTC+8", 8*60*60)
+	}
+	return loc
+}
@@ -0,0 +1,70 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// maxOutputTokenFields lists the output limit fields of each request format
+var maxOutputTokenFields = map[domain.ClientType][]string{
+	domain.ClientTypeClaude: {"max_tokens"},
+	domain.ClientTypeOpenAI: {"max_tokens", "max_completion_tokens"},
+	domain.ClientTypeCodex:  {"max_output_tokens"},
+}
+
+// clampMaxOutputTokens lowers the requested output token limit in body to limit.
+// It returns the rewritten body and the original requested value, or the untouched
+// body and 0 when the request is already within the limit.
+func clampMaxOutputTokens(body []byte, clientType domain.ClientType, limit int) ([]byte, int) {
+	if limit <= 0 || len(body) == 0 {
+		return body, 0
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var req map[string]interface{}
+	if err := dec.Decode(&req); err != nil {
+		return body, 0
+	}
+
+	// Gemini nests the limit inside generationConfig
+	target := req
+	fields := maxOutputTokenFields[clientType]
+	if clientType == domain.ClientTypeGemini {
+		config, ok := req["generationConfig"].(map[string]interface{})
+		if !ok {
+			return body, 0
+		}
+		target = config
+		fields = []string{"maxOutputTokens"}
+	}
+
+	original := 0
+	for _, field := range fields {
+		num, ok := target[field].(json.Number)
+		if !ok {
+			continue
+		}
+		value, err := num.Int64()
+		if err != nil || value <= int64(limit) {
+			continue
+		}
+		if int(value) > original {
+			original = int(value)
+		}
+		target[field] = limit
+	}
+	if original == 0 {
+		return body, 0
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(req); err != nil {
+		return body, 0
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), original
+}
@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"context"
+	"log"
+
+	"github.com/awsl-project/maxx/internal/adapter/client"
+	ctxutil "github.com/awsl-project/maxx/internal/context"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// missingModelHandling returns how requests without a model are handled
+func (e *Executor) missingModelHandling() string {
+	if e.settingRepo != nil {
+		if val, err := e.settingRepo.Get(domain.SettingKeyMissingModelHandling); err == nil && val == domain.MissingModelReject {
+			return val
+		}
+	}
+	return domain.MissingModelDefault
+}
+
+// ResolveMissingModel returns the model a request of the project that names no model
+// is sent with, empty when the project has no default and each route's default applies.
+// Returns domain.ErrMissingModel when such requests are rejected.
+func (e *Executor) ResolveMissingModel(projectID uint64) (string, error) {
+	if e.missingModelHandling() == domain.MissingModelReject {
+		return "", domain.ErrMissingModel
+	}
+	if projectID == 0 || e.projectRepo == nil {
+		return "", nil
+	}
+	project, err := e.projectRepo.GetByID(projectID)
+	if err != nil {
+		return "", nil
+	}
+	return project.DefaultModel, nil
+}
+
+// applyRouteDefaultModel sends a request without a model to the route with the
+// route's default model, returning domain.ErrMissingModel when it has none
+func applyRouteDefaultModel(ctx context.Context, route *domain.Route, proxyReq *domain.ProxyRequest) (context.Context, error) {
+	if route.DefaultModel == "" {
+		return ctx, domain.ErrMissingModel
+	}
+	clientType := ctxutil.GetClientType(ctx)
+	body, uri, err := client.SetModel(clientType, ctxutil.GetRequestURI(ctx), ctxutil.GetRequestBody(ctx), route.DefaultModel)
+	if err != nil {
+		return ctx, domain.ErrMissingModel
+	}
+	log.Printf("[Executor] Request without a model sent to route %d with its default model %s", route.ID, route.DefaultModel)
+
+	ctx = ctxutil.WithRequestBody(ctx, body)
+	ctx = ctxutil.WithRequestURI(ctx, uri)
+	ctx = ctxutil.WithRequestModel(ctx, route.DefaultModel)
+	proxyReq.ModelDefaulted = true
+	if proxyReq.RequestModel == "" {
+		proxyReq.RequestModel = route.DefaultModel
+	}
+	return ctx, nil
+}
@@ -0,0 +1,36 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	ctxutil "github.com/awsl-project/maxx/internal/context"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestApplyRouteDefaultModel(t *testing.T) {
+	ctx := ctxutil.WithClientType(context.Background(), domain.ClientTypeGemini)
+	ctx = ctxutil.WithRequestURI(ctx, "/v1beta/models/:streamGenerateContent")
+	ctx = ctxutil.WithRequestBody(ctx, []byte(`{"contents":[]}`))
+
+	// Routes without a default model are skipped
+	proxyReq := &domain.ProxyRequest{}
+	if _, err := applyRouteDefaultModel(ctx, &domain.Route{ID: 1}, proxyReq); !errors.Is(err, domain.ErrMissingModel) {
+		t.Fatalf("err = %v, want ErrMissingModel", err)
+	}
+	if proxyReq.ModelDefaulted {
+		t.Error("request marked as defaulted by a skipped route")
+	}
+
+	got, err := applyRouteDefaultModel(ctx, &domain.Route{ID: 2, DefaultModel: "gemini-2.5-flash"}, proxyReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri := ctxutil.GetRequestURI(got); uri != "/v1beta/models/gemini-2.5-flash:streamGenerateContent" {
+		t.Errorf("uri = %q", uri)
+	}
+	if ctxutil.GetRequestModel(got) != "gemini-2.5-flash" || !proxyReq.ModelDefaulted || proxyReq.RequestModel != "gemini-2.5-flash" {
+		t.Errorf("model = %q, record = %+v", ctxutil.GetRequestModel(got), proxyReq)
+	}
+}
@@ -0,0 +1,21 @@
+package executor
+
+import (
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/router"
+)
+
+// recordQuota counts a finished attempt against the provider's quota and
+// tells the frontend when it crosses the soft or the hard cap
+func (e *Executor) recordQuota(provider *domain.Provider, attempt *domain.ProxyUpstreamAttempt) {
+	level := e.router.RecordQuota(provider, int64(attempt.InputTokenCount+attempt.OutputTokenCount))
+	if level == router.QuotaOK || e.broadcaster == nil {
+		return
+	}
+	e.broadcaster.BroadcastMessage("provider_quota", e.router.Quota().Info(provider))
+	if level == router.QuotaExhausted {
+		e.broadcaster.BroadcastMessage("cooldown_update", map[string]interface{}{
+			"providerID": provider.ID,
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// extractRequestMetadata returns the string pairs of an OpenAI-style "metadata"
+// object so the proxy request can be tagged with the client's own identifiers.
+// Non-string values are skipped, OpenAI only accepts strings there.
+func extractRequestMetadata(body []byte, clientType domain.ClientType) map[string]string {
+	if clientType != domain.ClientTypeOpenAI && clientType != domain.ClientTypeCodex {
+		return nil
+	}
+
+	var req struct {
+		Metadata map[string]interface{} `json:"metadata"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || len(req.Metadata) == 0 {
+		return nil
+	}
+
+	tags := make(map[string]string, len(req.Metadata))
+	for key, value := range req.Metadata {
+		if s, ok := value.(string); ok && key != "" {
+			tags[key] = s
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+// stripUnsupportedFields removes the "store" and "metadata" request fields the
+// target format rejects:
+//   - openai: metadata is only accepted together with store=true
+//   - codex: both are accepted
+//   - claude: store is unknown, metadata may only carry user_id
+//   - gemini: neither exists
+//
+// It returns the rewritten body and whether anything was removed.
+func stripUnsupportedFields(body []byte, targetType domain.ClientType) ([]byte, bool) {
+	if targetType == domain.ClientTypeCodex || len(body) == 0 {
+		return body, false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var req map[string]interface{}
+	if err := dec.Decode(&req); err != nil {
+		return body, false
+	}
+	_, hasStore := req["store"]
+	metadata, hasMetadata := req["metadata"]
+	if !hasStore && !hasMetadata {
+		return body, false
+	}
+
+	stripped := false
+	switch targetType {
+	case domain.ClientTypeOpenAI:
+		if store, _ := req["store"].(bool); hasMetadata && !store {
+			delete(req, "metadata")
+			stripped = true
+		}
+	case domain.ClientTypeClaude:
+		if hasStore {
+			delete(req, "store")
+			stripped = true
+		}
+		if fields, ok := metadata.(map[string]interface{}); hasMetadata && (!ok || len(fields) != 1 || fields["user_id"] == nil) {
+			if userID, ok := fields["user_id"]; ok {
+				req["metadata"] = map[string]interface{}{"user_id": userID}
+			} else {
+				delete(req, "metadata")
+			}
+			stripped = true
+		}
+	case domain.ClientTypeGemini:
+		delete(req, "store")
+		delete(req, "metadata")
+		stripped = true
+	}
+	if !stripped {
+		return body, false
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(req); err != nil {
+		return body, false
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true
+}
@@ -0,0 +1,70 @@
+package executor
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestExtractRequestMetadata(t *testing.T) {
+	body := []byte(`{"model":"gpt-4o","metadata":{"app":"billing","trace_id":"t-1","count":3},"store":true}`)
+
+	got := extractRequestMetadata(body, domain.ClientTypeOpenAI)
+	if len(got) != 2 || got["app"] != "billing" || got["trace_id"] != "t-1" {
+		t.Errorf("extractRequestMetadata = %v, want app and trace_id only", got)
+	}
+	if got := extractRequestMetadata(body, domain.ClientTypeCodex); got["app"] != "billing" {
+		t.Errorf("codex metadata = %v, want extracted", got)
+	}
+	if got := extractRequestMetadata(body, domain.ClientTypeClaude); got != nil {
+		t.Errorf("claude metadata = %v, want nil", got)
+	}
+	if got := extractRequestMetadata([]byte(`{"model":"gpt-4o"}`), domain.ClientTypeOpenAI); got != nil {
+		t.Errorf("missing metadata = %v, want nil", got)
+	}
+}
+
+func TestStripUnsupportedFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   domain.ClientType
+		body     string
+		stripped bool
+		want     map[string]bool // field -> expected to be present
+	}{
+		{"openai keeps both with store", domain.ClientTypeOpenAI, `{"store":true,"metadata":{"a":"b"}}`, false, map[string]bool{"store": true, "metadata": true}},
+		{"openai drops metadata without store", domain.ClientTypeOpenAI, `{"metadata":{"a":"b"}}`, true, map[string]bool{"metadata": false}},
+		{"openai drops metadata with store false", domain.ClientTypeOpenAI, `{"store":false,"metadata":{"a":"b"}}`, true, map[string]bool{"store": true, "metadata": false}},
+		{"codex keeps both", domain.ClientTypeCodex, `{"store":false,"metadata":{"a":"b"}}`, false, map[string]bool{"store": true, "metadata": true}},
+		{"claude drops store", domain.ClientTypeClaude, `{"store":true,"metadata":{"user_id":"u"}}`, true, map[string]bool{"store": false, "metadata": true}},
+		{"claude keeps user_id metadata", domain.ClientTypeClaude, `{"metadata":{"user_id":"u"}}`, false, map[string]bool{"metadata": true}},
+		{"claude drops other metadata", domain.ClientTypeClaude, `{"metadata":{"app":"x"}}`, true, map[string]bool{"metadata": false}},
+		{"gemini drops both", domain.ClientTypeGemini, `{"store":true,"metadata":{"a":"b"}}`, true, map[string]bool{"store": false, "metadata": false}},
+		{"no fields", domain.ClientTypeGemini, `{"contents":[]}`, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, stripped := stripUnsupportedFields([]byte(tt.body), tt.target)
+			if stripped != tt.stripped {
+				t.Fatalf("stripped = %v, want %v (body %s)", stripped, tt.stripped, body)
+			}
+			var req map[string]interface{}
+			if err := json.Unmarshal(body, &req); err != nil {
+				t.Fatalf("invalid body %s: %v", body, err)
+			}
+			for field, present := range tt.want {
+				if _, ok := req[field]; ok != present {
+					t.Errorf("field %q present = %v, want %v (body %s)", field, ok, present, body)
+				}
+			}
+		})
+	}
+
+	// Extra metadata keys are dropped for claude but user_id is kept
+	body, _ := stripUnsupportedFields([]byte(`{"metadata":{"user_id":"u","app":"x"}}`), domain.ClientTypeClaude)
+	if string(body) != `{"metadata":{"user_id":"u"}}` {
+		t.Errorf("claude metadata = %s, want only user_id", body)
+	}
+}
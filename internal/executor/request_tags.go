@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/replay"
+)
+
+// DefaultTagHeader carries the client's cost allocation tags when no header is configured
+const DefaultTagHeader = "X-Maxx-Tag"
+
+const (
+	// maxRequestTags caps the tags stored per request, extra ones are dropped
+	maxRequestTags = 5
+	// maxRequestTagLength bounds a single tag, the column holds 64 characters
+	maxRequestTagLength = 48
+)
+
+// tagInvalid matches characters dropped from tags, "env:prod" and "team/search" style tags are kept
+var tagInvalid = regexp.MustCompile(`[^a-z0-9:/._-]+`)
+
+// NormalizeRequestTag lowercases a tag and replaces unsupported characters with "-".
+// Returns "" when nothing usable remains.
+func NormalizeRequestTag(tag string) string {
+	tag = tagInvalid.ReplaceAllString(strings.ToLower(strings.TrimSpace(tag)), "-")
+	tag = strings.Trim(tag, "-")
+	if len(tag) > maxRequestTagLength {
+		tag = strings.TrimRight(tag[:maxRequestTagLength], "-")
+	}
+	return tag
+}
+
+// extractRequestTags reads the comma separated tags of the header, which may be repeated.
+// Tags are normalized and deduplicated in order, at most maxRequestTags are kept.
+func extractRequestTags(headers http.Header, name string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, value := range headers.Values(name) {
+		for _, raw := range strings.Split(value, ",") {
+			tag := NormalizeRequestTag(raw)
+			if tag == "" || seen[tag] {
+				continue
+			}
+			if len(tags) == maxRequestTags {
+				return tags
+			}
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// tagHeader returns the header clients put their cost allocation tags in
+func (e *Executor) tagHeader() string {
+	if e.settingRepo != nil {
+		if val, err := e.settingRepo.Get(domain.SettingKeyRequestTagHeader); err == nil && strings.TrimSpace(val) != "" {
+			return strings.TrimSpace(val)
+		}
+	}
+	return DefaultTagHeader
+}
+
+// withReplayTag puts the replay tag first so replays stand out in the tag stats,
+// the client's own tags follow within the cap
+func withReplayTag(tags []string) []string {
+	out := []string{replay.Tag}
+	for _, tag := range tags {
+		if tag != replay.Tag && len(out) < maxRequestTags {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
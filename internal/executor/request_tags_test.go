@@ -0,0 +1,54 @@
+package executor
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExtractRequestTags(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   []string
+	}{
+		{"single", []string{"search"}, []string{"search"}},
+		{"comma separated", []string{"env:prod, team/search"}, []string{"env:prod", "team/search"}},
+		{"repeated header", []string{"env:prod", "feature:chat"}, []string{"env:prod", "feature:chat"}},
+		{"normalized", []string{"  Env:Prod ", "Feature Chat!"}, []string{"env:prod", "feature-chat"}},
+		{"deduplicated", []string{"a, A, a "}, []string{"a"}},
+		{"empty values dropped", []string{", ,!!,b"}, []string{"b"}},
+		{"capped", []string{"a,b,c,d,e,f,g"}, []string{"a", "b", "c", "d", "e"}},
+		{"absent", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := http.Header{}
+			for _, v := range tt.values {
+				headers.Add("X-Cost-Tag", v)
+			}
+			if got := extractRequestTags(headers, "X-Cost-Tag"); !slices.Equal(got, tt.want) {
+				t.Errorf("extractRequestTags = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeRequestTagLength(t *testing.T) {
+	got := NormalizeRequestTag(strings.Repeat("x", 100))
+	if len(got) != maxRequestTagLength {
+		t.Errorf("long tag kept %d characters, want %d", len(got), maxRequestTagLength)
+	}
+}
+
+func TestWithReplayTag(t *testing.T) {
+	if got := withReplayTag(nil); !slices.Equal(got, []string{"replay"}) {
+		t.Errorf("withReplayTag(nil) = %q", got)
+	}
+	got := withReplayTag([]string{"a", "replay", "b", "c", "d", "e"})
+	if want := []string{"replay", "a", "b", "c", "d"}; !slices.Equal(got, want) {
+		t.Errorf("withReplayTag = %q, want %q", got, want)
+	}
+}
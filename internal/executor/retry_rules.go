@@ -0,0 +1,44 @@
+package executor
+
+import (
+	"log"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/retryrule"
+)
+
+// applyRetryRules marks a failed attempt's non-retryable error as retryable when it
+// matches one of the provider's retry rules, so quirky transient upstream errors
+// ("overloaded" in a 400, an error body in a 200) are retried like 5xx. Attempts
+// that already sent part of the response to the client are never retried.
+func applyRetryRules(run *attemptRun) {
+	proxyErr, ok := run.err.(*domain.ProxyError)
+	if !ok || proxyErr.Retryable || run.ctx.Err() != nil {
+		return
+	}
+	if run.capture != nil && run.capture.Body() != "" {
+		return
+	}
+	cfg := run.plan.route.Provider.Config
+	if cfg == nil || len(cfg.RetryRules) == 0 {
+		return
+	}
+	matcher, err := retryrule.New(cfg.RetryRules)
+	if err != nil {
+		log.Printf("[Executor] Invalid retry rules for provider %s: %v", run.plan.route.Provider.Name, err)
+		return
+	}
+
+	statusCode := proxyErr.HTTPStatusCode
+	text := proxyErr.Error()
+	if info := run.record.ResponseInfo; info != nil {
+		if statusCode == 0 {
+			statusCode = info.Status
+		}
+		text += "\n" + info.Body
+	}
+	if matcher.Match(statusCode, text) {
+		log.Printf("[Executor] Error from provider %s matched a retry rule, retrying", run.plan.route.Provider.Name)
+		proxyErr.Retryable = true
+	}
+}
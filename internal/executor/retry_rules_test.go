@@ -0,0 +1,50 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/router"
+)
+
+func retryRuleRun(err error) *attemptRun {
+	provider := &domain.Provider{
+		Name:   "quirky",
+		Config: &domain.ProviderConfig{RetryRules: []domain.RetryRule{{Pattern: "capacity"}}},
+	}
+	return &attemptRun{
+		plan:    &routePlan{route: &router.MatchedRoute{Provider: provider}},
+		record:  &domain.ProxyUpstreamAttempt{},
+		ctx:     context.Background(),
+		capture: NewResponseCapture(httptest.NewRecorder()),
+		err:     err,
+	}
+}
+
+func TestApplyRetryRules(t *testing.T) {
+	matched := &domain.ProxyError{Err: errors.New(`upstream error: {"error":"insufficient capacity"}`), HTTPStatusCode: 400}
+	run := retryRuleRun(matched)
+	applyRetryRules(run)
+	if !matched.Retryable {
+		t.Error("matching 400 not marked retryable")
+	}
+
+	other := &domain.ProxyError{Err: errors.New(`upstream error: {"error":"invalid model"}`), HTTPStatusCode: 400}
+	run = retryRuleRun(other)
+	applyRetryRules(run)
+	if other.Retryable {
+		t.Error("non-matching 400 marked retryable")
+	}
+
+	// The client already received part of the response
+	partial := &domain.ProxyError{Err: errors.New("SSE error: capacity"), HTTPStatusCode: 200}
+	run = retryRuleRun(partial)
+	run.capture.Write([]byte("data: {}\n\n"))
+	applyRetryRules(run)
+	if partial.Retryable {
+		t.Error("partially sent response marked retryable")
+	}
+}
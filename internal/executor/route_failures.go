@@ -0,0 +1,105 @@
+package executor
+
+import (
+	"errors"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/router"
+)
+
+// maxRouteFailureDetail bounds the stored error message of a route, upstream bodies can be large
+const maxRouteFailureDetail = 300
+
+// recordRouteFailure adds a failed attempt to the summary of its route. Retries on the
+// same route count as attempts, the status and error of the latest one are kept.
+func recordRouteFailure(failures []domain.RouteFailure, route *router.MatchedRoute, statusCode int, err error) []domain.RouteFailure {
+	idx := -1
+	for i := range failures {
+		if failures[i].RouteID == route.Route.ID {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		failures = append(failures, domain.RouteFailure{
+			Position:     len(failures) + 1,
+			RouteID:      route.Route.ID,
+			ProviderID:   route.Provider.ID,
+			ProviderName: route.Provider.Name,
+		})
+		idx = len(failures) - 1
+	}
+
+	f := &failures[idx]
+	f.Attempts++
+	f.StatusCode = statusCode
+	f.Error = routeFailureKind(err)
+	f.Detail = err.Error()
+	if len(f.Detail) > maxRouteFailureDetail {
+		f.Detail = f.Detail[:maxRouteFailureDetail] + "..."
+	}
+	return failures
+}
+
+// routeFailureKind returns the error category, which doesn't reveal upstream details
+func routeFailureKind(err error) string {
+	var proxyErr *domain.ProxyError
+	if !errors.As(err, &proxyErr) {
+		return "internal error"
+	}
+	if proxyErr.IsNetworkError {
+		return "network error"
+	}
+	if proxyErr.Err == nil {
+		return "upstream error"
+	}
+	return proxyErr.Err.Error()
+}
+
+// clientRouteFailures filters the summary for the client according to the detail level:
+// basic keeps only what is safe to show untrusted clients, none drops it entirely
+func clientRouteFailures(failures []domain.RouteFailure, detail string) []domain.RouteFailure {
+	switch detail {
+	case domain.RouteFailureDetailNone:
+		return nil
+	case domain.RouteFailureDetailFull:
+		return failures
+	}
+	basic := make([]domain.RouteFailure, len(failures))
+	for i, f := range failures {
+		basic[i] = domain.RouteFailure{
+			Position:   f.Position,
+			Attempts:   f.Attempts,
+			StatusCode: f.StatusCode,
+			Error:      f.Error,
+		}
+	}
+	return basic
+}
+
+// routeFailureDetail returns how much of the route failure summary clients see
+func (e *Executor) routeFailureDetail() string {
+	if e.settingRepo != nil {
+		if val, err := e.settingRepo.Get(domain.SettingKeyRouteFailureDetail); err == nil {
+			switch val {
+			case domain.RouteFailureDetailNone, domain.RouteFailureDetailFull:
+				return val
+			}
+		}
+	}
+	return domain.RouteFailureDetailBasic
+}
+
+// exhaustedError returns the error of the last attempt carrying the client's view of the
+// route failure summary and the status mapped for the client from the last provider's
+// status mapping. The error is copied so the attempt's own record stays untouched.
+func (e *Executor) exhaustedError(lastErr error, failures []domain.RouteFailure, lastProvider *domain.Provider) error {
+	var proxyErr *domain.ProxyError
+	if !errors.As(lastErr, &proxyErr) {
+		proxyErr = domain.NewProxyErrorWithMessage(domain.ErrAllRoutesFailed, false, lastErr.Error())
+	}
+	summarized := *proxyErr
+	summarized.RouteFailures = clientRouteFailures(failures, e.routeFailureDetail())
+	summarized.ClientStatusCode = clientStatusCode(e.statusMapper(lastProvider), proxyErr)
+	return &summarized
+}
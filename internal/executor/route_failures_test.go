@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/router"
+)
+
+func testRoute(routeID, providerID uint64, name string) *router.MatchedRoute {
+	return &router.MatchedRoute{
+		Route:    &domain.Route{ID: routeID, ProviderID: providerID},
+		Provider: &domain.Provider{ID: providerID, Name: name},
+	}
+}
+
+func TestRouteFailuresListAllProviders(t *testing.T) {
+	primary := testRoute(10, 1, "primary")
+	backup := testRoute(20, 2, "backup")
+	last := testRoute(30, 3, "last-resort")
+
+	rateLimited := &domain.ProxyError{Err: domain.ErrUpstreamError, Message: "429 from https://internal.example/v1", Retryable: true}
+	var failures []domain.RouteFailure
+	failures = recordRouteFailure(failures, primary, 429, rateLimited)
+	failures = recordRouteFailure(failures, primary, 429, rateLimited) // retry on the same route
+	failures = recordRouteFailure(failures, backup, 0, &domain.ProxyError{Err: domain.ErrUpstreamError, IsNetworkError: true})
+	failures = recordRouteFailure(failures, last, 504, domain.NewProxyError(domain.ErrFirstByteTimeout, true))
+
+	want := []domain.RouteFailure{
+		{Position: 1, RouteID: 10, ProviderID: 1, ProviderName: "primary", Attempts: 2, StatusCode: 429, Error: "upstream error"},
+		{Position: 2, RouteID: 20, ProviderID: 2, ProviderName: "backup", Attempts: 1, Error: "network error"},
+		{Position: 3, RouteID: 30, ProviderID: 3, ProviderName: "last-resort", Attempts: 1, StatusCode: 504, Error: "first byte timeout"},
+	}
+	if len(failures) != len(want) {
+		t.Fatalf("got %d route failures, want %d: %+v", len(failures), len(want), failures)
+	}
+	for i, f := range failures {
+		f.Detail = ""
+		if f != want[i] {
+			t.Errorf("route %d = %+v, want %+v", i+1, f, want[i])
+		}
+	}
+	if failures[0].Detail != rateLimited.Error() {
+		t.Errorf("detail = %q, want the full error", failures[0].Detail)
+	}
+
+	e := &Executor{}
+	err := e.exhaustedError(rateLimited, failures, nil)
+	var proxyErr *domain.ProxyError
+	if !errors.As(err, &proxyErr) || !errors.Is(err, domain.ErrUpstreamError) {
+		t.Fatalf("exhausted error = %v, want a ProxyError wrapping the last error", err)
+	}
+	if len(rateLimited.RouteFailures) != 0 {
+		t.Error("the last attempt's error was modified")
+	}
+	// Without a setting the client sees the basic summary: no providers, no upstream messages
+	if len(proxyErr.RouteFailures) != 3 {
+		t.Fatalf("client summary has %d routes, want 3", len(proxyErr.RouteFailures))
+	}
+	for _, f := range proxyErr.RouteFailures {
+		if f.ProviderName != "" || f.ProviderID != 0 || f.RouteID != 0 || f.Detail != "" {
+			t.Errorf("basic summary leaks provider details: %+v", f)
+		}
+	}
+}
+
+func TestClientRouteFailuresDetail(t *testing.T) {
+	failures := recordRouteFailure(nil, testRoute(1, 1, "primary"), 500, domain.NewProxyError(domain.ErrUpstreamError, true))
+
+	if got := clientRouteFailures(failures, domain.RouteFailureDetailNone); got != nil {
+		t.Errorf("none = %+v, want nil", got)
+	}
+	if got := clientRouteFailures(failures, domain.RouteFailureDetailFull); got[0] != failures[0] {
+		t.Errorf("full = %+v, want %+v", got[0], failures[0])
+	}
+	basic := clientRouteFailures(failures, domain.RouteFailureDetailBasic)
+	want := domain.RouteFailure{Position: 1, Attempts: 1, StatusCode: 500, Error: "upstream error"}
+	if basic[0] != want {
+		t.Errorf("basic = %+v, want %+v", basic[0], want)
+	}
+}
@@ -0,0 +1,81 @@
+package executor
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// statusMapper returns the status mapping applied to responses of a provider on
+// their way to the client, the provider's own mappings over the global setting.
+// nil when neither maps anything. Attempt records keep the upstream status.
+func (e *Executor) statusMapper(provider *domain.Provider) func(status int) (int, bool) {
+	var own domain.StatusMapping
+	if provider != nil && provider.Config != nil {
+		own = provider.Config.StatusMapping
+	}
+	global := e.globalStatusMapping()
+	if len(own) == 0 && len(global) == 0 {
+		return nil
+	}
+	return func(status int) (int, bool) {
+		if mapped, ok := own.Map(status); ok {
+			return mapped, true
+		}
+		return global.Map(status)
+	}
+}
+
+// globalStatusMapping returns the configured global status mapping, nil when unset or invalid
+func (e *Executor) globalStatusMapping() domain.StatusMapping {
+	if e.settingRepo == nil {
+		return nil
+	}
+	val, err := e.settingRepo.Get(domain.SettingKeyResponseStatusMapping)
+	if err != nil || val == "" {
+		return nil
+	}
+	mapping, err := domain.ParseStatusMapping(val)
+	if err != nil {
+		log.Printf("[Executor] Invalid %s setting: %v", domain.SettingKeyResponseStatusMapping, err)
+		return nil
+	}
+	return mapping
+}
+
+// clientStatusCode returns the status to answer the client with when all routes
+// failed and the last one ended with err: the mapped upstream status, else the
+// mapped 502 the client would get otherwise. 0 when nothing is mapped.
+func clientStatusCode(mapStatus func(int) (int, bool), err *domain.ProxyError) int {
+	if mapStatus == nil {
+		return 0
+	}
+	if mapped, ok := mapStatus(err.HTTPStatusCode); ok {
+		return mapped
+	}
+	if mapped, ok := mapStatus(http.StatusBadGateway); ok {
+		return mapped
+	}
+	return 0
+}
+
+// statusMappingWriter rewrites the status written to the client with a status mapping
+type statusMappingWriter struct {
+	http.ResponseWriter
+	mapStatus func(status int) (int, bool)
+}
+
+func (s *statusMappingWriter) WriteHeader(code int) {
+	if mapped, ok := s.mapStatus(code); ok {
+		code = mapped
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Flush implements http.Flusher for streaming support
+func (s *statusMappingWriter) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,90 @@
+package executor
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// mapSettings is an in-memory SystemSettingRepository
+type mapSettings map[string]string
+
+func (m mapSettings) Get(key string) (string, error) { return m[key], nil }
+func (m mapSettings) Set(key, value string) error    { m[key] = value; return nil }
+func (m mapSettings) Delete(key string) error        { delete(m, key); return nil }
+func (m mapSettings) GetAll() ([]*domain.SystemSetting, error) {
+	var all []*domain.SystemSetting
+	for k, v := range m {
+		all = append(all, &domain.SystemSetting{Key: k, Value: v})
+	}
+	return all, nil
+}
+
+func TestStatusMappingRewritesClientResponse(t *testing.T) {
+	settings := mapSettings{domain.SettingKeyResponseStatusMapping: "529:500, 524:504"}
+	e := &Executor{settingRepo: settings}
+	provider := &domain.Provider{ID: 1, Config: &domain.ProviderConfig{StatusMapping: domain.StatusMapping{529: 503}}}
+
+	for _, tc := range []struct{ upstream, client int }{
+		{529, 503}, // the provider's mapping wins over the global one
+		{524, 504}, // global mapping
+		{200, 200}, // unmapped
+	} {
+		rec := httptest.NewRecorder()
+		capture := NewResponseCapture(rec)
+		w := &statusMappingWriter{ResponseWriter: capture, mapStatus: e.statusMapper(provider)}
+		w.WriteHeader(tc.upstream)
+		if rec.Code != tc.client || capture.StatusCode() != tc.client {
+			t.Errorf("upstream %d: client got %d (captured %d), want %d", tc.upstream, rec.Code, capture.StatusCode(), tc.client)
+		}
+	}
+
+	// All routes failed: the client is answered with the mapped status, the
+	// attempt's error keeps the upstream one
+	overloaded := &domain.ProxyError{Err: domain.ErrUpstreamError, HTTPStatusCode: 529}
+	var proxyErr *domain.ProxyError
+	if !errors.As(e.exhaustedError(overloaded, nil, provider), &proxyErr) {
+		t.Fatal("exhausted error is not a ProxyError")
+	}
+	if proxyErr.ClientStatusCode != 503 || proxyErr.HTTPStatusCode != 529 {
+		t.Errorf("client status = %d, upstream status = %d, want 503 and 529", proxyErr.ClientStatusCode, proxyErr.HTTPStatusCode)
+	}
+	if overloaded.ClientStatusCode != 0 {
+		t.Error("the last attempt's error was modified")
+	}
+
+	// Errors without a mapped upstream status keep the default 502 unless it is mapped
+	networkErr := &domain.ProxyError{Err: domain.ErrUpstreamError, IsNetworkError: true}
+	_ = errors.As(e.exhaustedError(networkErr, nil, provider), &proxyErr)
+	if proxyErr.ClientStatusCode != 0 {
+		t.Errorf("unmapped client status = %d, want 0", proxyErr.ClientStatusCode)
+	}
+	settings[domain.SettingKeyResponseStatusMapping] = "502:503"
+	_ = errors.As(e.exhaustedError(networkErr, nil, provider), &proxyErr)
+	if proxyErr.ClientStatusCode != http.StatusServiceUnavailable {
+		t.Errorf("mapped 502 client status = %d, want 503", proxyErr.ClientStatusCode)
+	}
+
+	// Nothing configured, nothing to wrap
+	if (&Executor{settingRepo: mapSettings{}}).statusMapper(&domain.Provider{}) != nil {
+		t.Error("status mapper without mappings should be nil")
+	}
+}
+
+func TestParseStatusMapping(t *testing.T) {
+	mapping, err := domain.ParseStatusMapping(" 529:503 ,524:504,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mapping) != 2 || mapping[529] != 503 || mapping[524] != 504 {
+		t.Errorf("mapping = %v", mapping)
+	}
+	for _, invalid := range []string{"529", "529:abc", "529:700", "42:503"} {
+		if _, err := domain.ParseStatusMapping(invalid); err == nil {
+			t.Errorf("ParseStatusMapping(%q) accepted", invalid)
+		}
+	}
+}
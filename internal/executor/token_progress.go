@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/event"
+	"github.com/awsl-project/maxx/internal/tokenizer"
+)
+
+// tokenProgressInterval throttles the token progress broadcasts of a stream
+const tokenProgressInterval = 500 * time.Millisecond
+
+// tokenProgressWriter estimates the output tokens of a stream as its SSE events
+// pass through and broadcasts the running count at most once per interval, so the
+// dashboard can show a live counter for in-flight requests.
+type tokenProgressWriter struct {
+	http.ResponseWriter
+	broadcaster event.Broadcaster
+	model       string
+	interval    time.Duration
+
+	mu       sync.Mutex
+	progress domain.TokenProgress
+	line     []byte // incomplete SSE line of the stream
+	lastSent time.Time
+	sent     uint64 // output tokens of the last broadcast
+}
+
+func newTokenProgressWriter(w http.ResponseWriter, bc event.Broadcaster, record *domain.ProxyUpstreamAttempt) *tokenProgressWriter {
+	return &tokenProgressWriter{
+		ResponseWriter: w,
+		broadcaster:    bc,
+		model:          record.MappedModel,
+		interval:       tokenProgressInterval,
+		progress:       domain.TokenProgress{ProxyRequestID: record.ProxyRequestID, AttemptID: record.ID},
+	}
+}
+
+// Write counts the text of the complete SSE data lines and forwards the chunk
+func (t *tokenProgressWriter) Write(b []byte) (int, error) {
+	t.count(b)
+	return t.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher for streaming support
+func (t *tokenProgressWriter) Flush() {
+	if f, ok := t.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (t *tokenProgressWriter) count(b []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.line = append(t.line, b...)
+	for {
+		idx := bytes.IndexByte(t.line, '\n')
+		if idx < 0 {
+			break
+		}
+		line := bytes.TrimSpace(t.line[:idx])
+		t.line = t.line[idx+1:]
+		if payload, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			t.progress.OutputTokens += uint64(tokenizer.CountJSON(t.model, bytes.TrimSpace(payload)))
+		}
+	}
+	if t.progress.OutputTokens > t.sent && time.Since(t.lastSent) >= t.interval {
+		t.send()
+	}
+}
+
+// finish broadcasts the final count: outputTokens reported by the upstream, or
+// the estimate of the whole stream when it reported none
+func (t *tokenProgressWriter) finish(outputTokens uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if outputTokens > 0 {
+		t.progress.OutputTokens = outputTokens
+	}
+	t.progress.Final = true
+	t.send()
+}
+
+func (t *tokenProgressWriter) send() {
+	t.lastSent = time.Now()
+	t.sent = t.progress.OutputTokens
+	progress := t.progress
+	t.broadcaster.BroadcastMessage("token_progress", &progress)
+}
@@ -0,0 +1,70 @@
+package executor
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/event"
+)
+
+type progressBroadcaster struct {
+	event.NopBroadcaster
+	progress []domain.TokenProgress
+}
+
+func (b *progressBroadcaster) BroadcastMessage(messageType string, data interface{}) {
+	if messageType == "token_progress" {
+		b.progress = append(b.progress, *data.(*domain.TokenProgress))
+	}
+}
+
+const textDeltaEvent = "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hello world \"}}\n\n"
+
+func TestTokenProgressBroadcastsDuringStream(t *testing.T) {
+	bc := &progressBroadcaster{}
+	rec := httptest.NewRecorder()
+	w := newTokenProgressWriter(rec, bc, &domain.ProxyUpstreamAttempt{ID: 7, ProxyRequestID: 3, MappedModel: "claude-sonnet-4"})
+	w.interval = 50 * time.Millisecond
+
+	// A burst of chunks is broadcast once, an event split across writes counts when complete
+	for range 5 {
+		w.Write([]byte(textDeltaEvent))
+	}
+	half := len(textDeltaEvent) / 2
+	w.Write([]byte(textDeltaEvent[:half]))
+	if len(bc.progress) != 1 || bc.progress[0].OutputTokens == 0 {
+		t.Fatalf("progress during the burst = %+v, want one broadcast", bc.progress)
+	}
+	burst := bc.progress[0].OutputTokens
+
+	time.Sleep(60 * time.Millisecond)
+	w.Write([]byte(textDeltaEvent[half:]))
+	if len(bc.progress) != 2 || bc.progress[1].OutputTokens <= burst || bc.progress[1].Final {
+		t.Fatalf("progress after the interval = %+v, want a higher running count", bc.progress)
+	}
+	estimate := bc.progress[1].OutputTokens
+
+	// No new tokens, nothing to broadcast
+	time.Sleep(60 * time.Millisecond)
+	w.Write([]byte(": keepalive\n\n"))
+	if len(bc.progress) != 2 {
+		t.Fatalf("broadcast without new tokens: %+v", bc.progress)
+	}
+
+	if got := rec.Body.String(); got != strings.Repeat(textDeltaEvent, 6)+": keepalive\n\n" {
+		t.Errorf("stream altered: %q", got)
+	}
+
+	w.finish(0)
+	last := bc.progress[len(bc.progress)-1]
+	if !last.Final || last.OutputTokens != estimate || last.ProxyRequestID != 3 || last.AttemptID != 7 {
+		t.Errorf("final progress without upstream usage = %+v, want the estimate %d", last, estimate)
+	}
+	w.finish(42)
+	if last := bc.progress[len(bc.progress)-1]; !last.Final || last.OutputTokens != 42 {
+		t.Errorf("final progress = %+v, want the upstream's 42 tokens", last)
+	}
+}
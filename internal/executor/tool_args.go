@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/awsl-project/maxx/internal/converter"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// toolArgsWriter follows the tool call arguments of a stream as the client receives
+// it, native or converted, so a stream the upstream cuts off inside a tool call's
+// arguments can be closed with valid JSON instead of leaving the client to parse
+// a fragment
+type toolArgsWriter struct {
+	http.ResponseWriter
+	clientType domain.ClientType
+	state      *converter.TransformState
+}
+
+func newToolArgsWriter(w http.ResponseWriter, clientType domain.ClientType) *toolArgsWriter {
+	return &toolArgsWriter{ResponseWriter: w, clientType: clientType, state: converter.NewTransformState()}
+}
+
+func (t *toolArgsWriter) Write(b []byte) (int, error) {
+	converter.TrackToolArgs(t.clientType, b, t.state)
+	return t.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher for streaming support
+func (t *toolArgsWriter) Flush() {
+	if f, ok := t.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finish closes the tool call the stream ended in, if it ended before its final event
+func (t *toolArgsWriter) finish(provider string) {
+	closing := converter.CloseToolArgs(t.clientType, t.state)
+	if len(closing) == 0 {
+		return
+	}
+	log.Printf("[Executor] Stream from provider %s ended inside a tool call's arguments, closing them", provider)
+	if _, err := t.ResponseWriter.Write(closing); err != nil {
+		return
+	}
+	t.Flush()
+}
@@ -0,0 +1,41 @@
+package executor
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/converter"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestToolArgsWriterClosesTruncatedCall(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := newToolArgsWriter(rec, domain.ClientTypeOpenAI)
+	_, _ = w.Write([]byte(`data: {"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"read","arguments":"{\"path\":\"a.t"}}]}}]}` + "\n\n"))
+	w.finish("p")
+
+	events, _ := converter.ParseSSE(rec.Body.String())
+	var args string
+	for _, event := range events {
+		var chunk converter.OpenAIStreamChunk
+		if err := json.Unmarshal(event.Data, &chunk); err == nil && len(chunk.Choices) > 0 {
+			for _, call := range chunk.Choices[0].Delta.ToolCalls {
+				args += call.Function.Arguments
+			}
+		}
+	}
+	if args != `{"path":"a.t"}` {
+		t.Errorf("client arguments = %q in %q", args, rec.Body.String())
+	}
+
+	// Finished streams are left as they are
+	rec = httptest.NewRecorder()
+	w = newToolArgsWriter(rec, domain.ClientTypeOpenAI)
+	_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	w.finish("p")
+	if strings.Count(rec.Body.String(), "data:") != 1 {
+		t.Errorf("finished stream = %q", rec.Body.String())
+	}
+}
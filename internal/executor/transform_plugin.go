@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/plugin"
+)
+
+// transformPlugin returns the provider's WASM transform plugin, nil when it has none
+// or it doesn't load, in which case requests pass through untransformed
+func transformPlugin(p *domain.Provider) *plugin.Plugin {
+	if p.Config == nil || p.Config.TransformPlugin == nil {
+		return nil
+	}
+	tp, err := plugin.Load(p.Config.TransformPlugin)
+	if err != nil {
+		log.Printf("[Executor] Transform plugin of provider %s failed to load, passing through: %v", p.Name, err)
+		return nil
+	}
+	return tp
+}
+
+// transformPluginRequest rewrites the request body with the plan's transform plugin,
+// the body is forwarded as it is when the plugin fails
+func transformPluginRequest(ctx context.Context, plan *routePlan, body []byte) []byte {
+	out, err := plan.plugin.TransformRequest(ctx, plan.targetClientType, body)
+	if err != nil {
+		log.Printf("[Executor] Transform plugin of provider %s failed on the request, passing through: %v",
+			plan.route.Provider.Name, err)
+		return body
+	}
+	return out
+}
+
+// pluginResponseWriter holds a non-streaming response until the attempt finishes,
+// so the provider's transform plugin can rewrite the whole body. Error responses
+// and responses the plugin fails on are released as they are.
+type pluginResponseWriter struct {
+	http.ResponseWriter
+	plugin     *plugin.Plugin
+	clientType domain.ClientType
+	provider   string
+	statusCode int
+	body       bytes.Buffer
+}
+
+func newPluginResponseWriter(w http.ResponseWriter, plan *routePlan) *pluginResponseWriter {
+	return &pluginResponseWriter{
+		ResponseWriter: w,
+		plugin:         plan.plugin,
+		clientType:     plan.targetClientType,
+		provider:       plan.route.Provider.Name,
+	}
+}
+
+// WriteHeader holds the status code until the response is released
+func (p *pluginResponseWriter) WriteHeader(code int) {
+	if p.statusCode == 0 {
+		p.statusCode = code
+	}
+}
+
+// Write holds the body until the response is released
+func (p *pluginResponseWriter) Write(b []byte) (int, error) {
+	if p.statusCode == 0 {
+		p.statusCode = http.StatusOK
+	}
+	return p.body.Write(b)
+}
+
+// Flush implements http.Flusher, held output is not flushed
+func (p *pluginResponseWriter) Flush() {}
+
+// finish releases the held response, rewritten by the plugin when it was successful
+func (p *pluginResponseWriter) finish(ctx context.Context) {
+	if p.statusCode == 0 {
+		return
+	}
+	body := p.body.Bytes()
+	if p.statusCode >= 200 && p.statusCode < 300 {
+		out, err := p.plugin.TransformResponse(ctx, p.clientType, body)
+		if err != nil {
+			log.Printf("[Executor] Transform plugin of provider %s failed on the response, passing through: %v", p.provider, err)
+		} else {
+			body = out
+		}
+	}
+	p.ResponseWriter.Header().Del("Content-Length")
+	p.ResponseWriter.WriteHeader(p.statusCode)
+	_, _ = p.ResponseWriter.Write(body)
+}
@@ -0,0 +1,55 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/plugin"
+	"github.com/awsl-project/maxx/internal/router"
+)
+
+func TestTransformPluginPassesThroughOnFailure(t *testing.T) {
+	ctx := context.Background()
+	wasm, err := os.ReadFile("../plugin/testdata/transform.wasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp, err := plugin.Compile(ctx, wasm, 0, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tp.Close(ctx)
+	plan := &routePlan{
+		route:            &router.MatchedRoute{Provider: &domain.Provider{Name: "p"}},
+		targetClientType: domain.ClientTypeOpenAI,
+		plugin:           tp,
+	}
+
+	if got := transformPluginRequest(ctx, plan, []byte(`{"model":"m"}`)); string(got) != `{"MODEL":"M"}` {
+		t.Errorf("transformed request = %s", got)
+	}
+	// The sample plugin never returns for client types starting with "l"
+	plan.targetClientType = "loop"
+	if got := transformPluginRequest(ctx, plan, []byte(`{"model":"m"}`)); string(got) != `{"model":"m"}` {
+		t.Errorf("request after a failed transform = %s, want it untouched", got)
+	}
+
+	// Held responses are released once the attempt finishes
+	rec := httptest.NewRecorder()
+	w := newPluginResponseWriter(rec, plan)
+	w.Header().Set("Content-Length", "16")
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write([]byte(`{"id":"r1"}`))
+	if rec.Body.Len() != 0 {
+		t.Fatal("response released before the attempt finished")
+	}
+	w.finish(ctx)
+	if rec.Code != http.StatusCreated || rec.Body.String() != `{"id":"r1"}` || rec.Header().Get("Content-Length") != "" {
+		t.Errorf("released response = %d %q, content length %q", rec.Code, rec.Body.String(), rec.Header().Get("Content-Length"))
+	}
+}
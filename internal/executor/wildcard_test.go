@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/awsl-project/maxx/internal/domain"
@@ -109,3 +110,134 @@ func TestMatchModelMapping(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchModelMappingRegex(t *testing.T) {
+	mappings := []*domain.ModelMapping{
+		{Pattern: "re:claude-(.+)-20240620", Target: "gemini-$1"},
+		{Pattern: "re:gpt-(?P<family>4o|4\\.1)-(?P<size>mini|nano)", Target: "openai-${family}-${size}-latest"},
+		{Pattern: "re:claude-(sonnet|opus)-4-(\\d{8})", Target: "claude-$1-4"},
+	}
+
+	tests := []struct {
+		requestModel string
+		want         string
+	}{
+		// Positional capture groups
+		{"claude-3-5-sonnet-20240620", "gemini-3-5-sonnet"},
+		{"claude-sonnet-4-20250514", "claude-sonnet-4"},
+
+		// Named capture groups
+		{"gpt-4o-mini", "openai-4o-mini-latest"},
+		{"gpt-4.1-nano", "openai-4.1-nano-latest"},
+
+		// Regex rules match the whole model name
+		{"claude-3-5-sonnet-20240620-v2", "claude-3-5-sonnet-20240620-v2"},
+		{"my-gpt-4o-mini", "my-gpt-4o-mini"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.requestModel, func(t *testing.T) {
+			if got := matchModelMapping(mappings, tt.requestModel, ""); got != tt.want {
+				t.Errorf("matchModelMapping(%q) = %q, want %q", tt.requestModel, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchModelMappingInvalidRegex(t *testing.T) {
+	if err := domain.ValidateModelMappingPattern("re:claude-(.+"); err == nil {
+		t.Error("ValidateModelMappingPattern accepted an invalid regex")
+	}
+	if err := domain.ValidateModelMappingPattern("claude-(*"); err != nil {
+		t.Errorf("ValidateModelMappingPattern rejected a wildcard pattern: %v", err)
+	}
+
+	// An invalid regex rule never matches and doesn't stop later rules
+	mappings := []*domain.ModelMapping{
+		{Pattern: "re:claude-(.+", Target: "broken"},
+		{Pattern: "claude-*", Target: "fallback"},
+	}
+	if got := matchModelMapping(mappings, "claude-sonnet-4", ""); got != "fallback" {
+		t.Errorf("matchModelMapping = %q, want fallback", got)
+	}
+}
+
+func TestMatchModelMappingPrecedence(t *testing.T) {
+	// Rules are evaluated in order, the first match wins regardless of rule kind
+	mappings := []*domain.ModelMapping{
+		{Pattern: "claude-sonnet-4", Target: "exact-target"},
+		{Pattern: "re:claude-(sonnet|opus)-4", Target: "regex-$1"},
+		{Pattern: "claude-*", Target: "wildcard-target"},
+	}
+
+	tests := []struct {
+		requestModel string
+		want         string
+	}{
+		{"claude-sonnet-4", "exact-target"},
+		{"claude-opus-4", "regex-opus"},
+		{"claude-haiku-4", "wildcard-target"},
+		{"gpt-4o", "gpt-4o"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.requestModel, func(t *testing.T) {
+			if got := matchModelMapping(mappings, tt.requestModel, ""); got != tt.want {
+				t.Errorf("matchModelMapping(%q) = %q, want %q", tt.requestModel, got, tt.want)
+			}
+		})
+	}
+
+	// A wildcard pattern containing "re:" elsewhere is still a wildcard
+	if target, ok := domain.MatchModelMapping("*re:*", "t", "pre:fix"); !ok || target != "t" {
+		t.Errorf("MatchModelMapping(*re:*) = %q, %v, want t, true", target, ok)
+	}
+}
+
+func TestMatchModelMappingWeighted(t *testing.T) {
+	canary := &domain.ModelMapping{
+		ID:      3,
+		Pattern: "re:claude-(sonnet)-4",
+		Target:  "stable-$1",
+		Targets: []domain.ModelMappingTarget{
+			{Target: "stable-$1", Weight: 90},
+			{Target: "canary-$1", Weight: 10},
+		},
+	}
+	mappings := []*domain.ModelMapping{canary}
+
+	// Without a session each request is drawn by weight
+	const n = 10000
+	counts := map[string]int{}
+	for i := 0; i < n; i++ {
+		counts[matchModelMapping(mappings, "claude-sonnet-4", "")]++
+	}
+	if len(counts) != 2 {
+		t.Fatalf("targets = %v, want stable-sonnet and canary-sonnet", counts)
+	}
+	if c := counts["canary-sonnet"]; c < n*7/100 || c > n*13/100 {
+		t.Errorf("canary picked %d of %d times, want about 10%%", c, n)
+	}
+
+	// Sticky rules keep a session on one target and spread sessions by weight
+	canary.StickySession = true
+	counts = map[string]int{}
+	for i := 0; i < 1000; i++ {
+		session := fmt.Sprintf("session-%d", i)
+		first := matchModelMapping(mappings, "claude-sonnet-4", session)
+		for j := 0; j < 5; j++ {
+			if got := matchModelMapping(mappings, "claude-sonnet-4", session); got != first {
+				t.Fatalf("session %s mapped to %s then %s", session, first, got)
+			}
+		}
+		counts[first]++
+	}
+	if c := counts["canary-sonnet"]; c < 50 || c > 150 {
+		t.Errorf("canary picked for %d of 1000 sessions, want about 100", c)
+	}
+
+	// Invalid weights are rejected
+	if err := domain.ValidateModelMappingTargets([]domain.ModelMappingTarget{{Target: "x", Weight: 0}}); err == nil {
+		t.Error("zero weight accepted")
+	}
+}
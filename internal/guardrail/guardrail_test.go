@@ -0,0 +1,113 @@
+package guardrail
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestExtractText(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+		skip []string
+	}{
+		{"openai chat", `{"model":"gpt-4o","messages":[{"role":"user","content":"hello there"}]}`, []string{"hello there"}, []string{"gpt-4o", "user"}},
+		{"claude blocks", `{"system":[{"type":"text","text":"be nice"}],"messages":[{"role":"user","content":[{"type":"text","text":"hi"}]}]}`, []string{"be nice", "hi"}, []string{"type"}},
+		{"gemini", `{"contents":[{"role":"user","parts":[{"text":"ciao"}]}]}`, []string{"ciao"}, nil},
+		{"sse deltas are joined", "data: {\"choices\":[{\"delta\":{\"content\":\"forbid\"}}]}\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"den\"}}]}\n\ndata: [DONE]\n\n", []string{"forbidden"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExtractText([]byte(tt.body))
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("ExtractText = %q, want it to contain %q", got, w)
+				}
+			}
+			for _, s := range tt.skip {
+				if strings.Contains(got, s) {
+					t.Errorf("ExtractText = %q, should not contain %q", got, s)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckKeywords(t *testing.T) {
+	cfg := &domain.GuardrailConfig{Enabled: true, BlockedKeywords: []string{"Secret Project"}}
+	body := []byte(`{"messages":[{"role":"user","content":"tell me about the secret project"}]}`)
+
+	v, err := Check(context.Background(), cfg, body)
+	if err != nil || !v.Blocked {
+		t.Fatalf("Check = %+v, %v, want blocked", v, err)
+	}
+	v, err = Check(context.Background(), cfg, []byte(`{"messages":[{"role":"user","content":"hello"}]}`))
+	if err != nil || v.Blocked {
+		t.Errorf("Check = %+v, %v, want allowed", v, err)
+	}
+}
+
+func newModerationServer(t *testing.T, reply string, status int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model != "mod-model" || len(req.Messages) != 2 {
+			t.Errorf("unexpected moderation request: %+v, %v", req, err)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": reply}}},
+		})
+	}))
+}
+
+func TestCheckModeration(t *testing.T) {
+	body := []byte(`{"messages":[{"role":"user","content":"something"}]}`)
+
+	tests := []struct {
+		name       string
+		reply      string
+		status     int
+		wantBlock  bool
+		wantReason string
+		wantErr    bool
+	}{
+		{"allow", "ALLOW", http.StatusOK, false, "", false},
+		{"block with reason", "BLOCK: violence", http.StatusOK, true, "violence", false},
+		{"block without reason", "block", http.StatusOK, true, "flagged by moderation model", false},
+		{"upstream error", "", http.StatusInternalServerError, false, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newModerationServer(t, tt.reply, tt.status)
+			defer srv.Close()
+			cfg := &domain.GuardrailConfig{Enabled: true, ModerationURL: srv.URL, ModerationModel: "mod-model", ModerationAPIKey: "key"}
+
+			v, err := Check(context.Background(), cfg, body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if v.Blocked != tt.wantBlock || v.Reason != tt.wantReason {
+				t.Errorf("Check = %+v, want blocked=%v reason=%q", v, tt.wantBlock, tt.wantReason)
+			}
+		})
+	}
+}
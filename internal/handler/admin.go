@@ -2,15 +2,21 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/awsl-project/maxx/internal/adapter/provider/antigravity"
 	"github.com/awsl-project/maxx/internal/cooldown"
 	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/executor"
 	"github.com/awsl-project/maxx/internal/repository"
 	"github.com/awsl-project/maxx/internal/service"
+	"github.com/awsl-project/maxx/internal/soak"
 )
 
 // AdminHandler handles admin API requests over HTTP
@@ -18,18 +24,38 @@ import (
 type AdminHandler struct {
 	svc       *service.AdminService
 	backupSvc *service.BackupService
+	wsHub     *WebSocketHub
 	logPath   string
+	replay    *ReplayHandler
+	canceller RequestCanceller
+}
+
+// RequestCanceller cancels in-flight proxy requests
+// Implemented by Executor, which tracks the requests it runs
+type RequestCanceller interface {
+	CancelRequest(id uint64, reason domain.CancelReason) bool
 }
 
 // NewAdminHandler creates a new admin handler
-func NewAdminHandler(svc *service.AdminService, backupSvc *service.BackupService, logPath string) *AdminHandler {
+func NewAdminHandler(svc *service.AdminService, backupSvc *service.BackupService, wsHub *WebSocketHub, logPath string) *AdminHandler {
 	return &AdminHandler{
 		svc:       svc,
 		backupSvc: backupSvc,
+		wsHub:     wsHub,
 		logPath:   logPath,
 	}
 }
 
+// SetReplayHandler enables replaying captured requests at /admin/requests/replay
+func (h *AdminHandler) SetReplayHandler(replay *ReplayHandler) {
+	h.replay = replay
+}
+
+// SetRequestCanceller enables cancelling in-flight requests at /admin/requests/{id}/cancel
+func (h *AdminHandler) SetRequestCanceller(canceller RequestCanceller) {
+	h.canceller = canceller
+}
+
 // ServeHTTP routes admin requests
 func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/admin")
@@ -47,12 +73,25 @@ func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		id, _ = strconv.ParseUint(parts[2], 10, 64)
 	}
 
+	// Admin API tokens bound to a project only reach that project's data
+	if scope := AdminScopeFromContext(r.Context()); scope.IsScoped() {
+		if !scopedAdminAllowed(r.Method, resource, parts) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden: token is scoped to a project"})
+			return
+		}
+		scoped := *h
+		scoped.svc = h.svc.WithScope(scope)
+		h = &scoped
+	}
+
 	switch resource {
 	case "providers":
 		h.handleProviders(w, r, id)
 	case "routes":
 		if len(parts) > 2 && parts[2] == "batch-positions" {
 			h.handleBatchUpdateRoutePositions(w, r)
+		} else if len(parts) > 2 && parts[2] == "simulate" {
+			h.handleSimulateRouting(w, r)
 		} else {
 			h.handleRoutes(w, r, id)
 		}
@@ -82,12 +121,22 @@ func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.handleModelMappings(w, r, id)
 	case "usage-stats":
 		h.handleUsageStats(w, r)
+	case "usage":
+		h.handleUsage(w, r, parts)
 	case "dashboard":
 		h.handleDashboard(w, r)
 	case "response-models":
 		h.handleResponseModels(w, r)
 	case "backup":
 		h.handleBackup(w, r, parts)
+	case "config":
+		h.handleConfig(w, r, parts)
+	case "batches":
+		h.handleMessageBatches(w, r, parts)
+	case "secrets":
+		h.handleSecrets(w, r, parts)
+	case "signature-cache":
+		h.handleSignatureCache(w, r)
 	default:
 		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
 	}
@@ -105,6 +154,38 @@ func (h *AdminHandler) handleProviders(w http.ResponseWriter, r *http.Request, i
 		h.handleProvidersImport(w, r)
 		return
 	}
+	if strings.HasSuffix(path, "/discover") {
+		h.handleProvidersDiscover(w, r)
+		return
+	}
+	if strings.HasSuffix(path, "/report") {
+		h.handleProvidersReport(w, r)
+		return
+	}
+	if strings.HasSuffix(path, "/timeouts") {
+		h.handleProviderTimeouts(w, r)
+		return
+	}
+	if strings.HasSuffix(path, "/pacing") {
+		h.handleProviderPacing(w, r)
+		return
+	}
+	if strings.HasSuffix(path, "/connections") {
+		h.handleProviderConnections(w, r)
+		return
+	}
+	if strings.HasSuffix(path, "/models") {
+		h.handleProviderModels(w, r, id)
+		return
+	}
+	if strings.HasSuffix(path, "/soak") {
+		h.handleProviderSoak(w, r, id)
+		return
+	}
+	if strings.HasSuffix(path, "/events") {
+		h.handleProviderEvents(w, r, id)
+		return
+	}
 
 	switch r.Method {
 	case http.MethodGet:
@@ -114,14 +195,18 @@ func (h *AdminHandler) handleProviders(w http.ResponseWriter, r *http.Request, i
 				writeJSON(w, http.StatusNotFound, map[string]string{"error": "provider not found"})
 				return
 			}
-			writeJSON(w, http.StatusOK, provider)
+			writeJSON(w, http.StatusOK, service.RedactProviderSecrets(provider))
 		} else {
 			providers, err := h.svc.GetProviders()
 			if err != nil {
 				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 				return
 			}
-			writeJSON(w, http.StatusOK, providers)
+			redacted := make([]*domain.Provider, len(providers))
+			for i, p := range providers {
+				redacted[i] = service.RedactProviderSecrets(p)
+			}
+			writeJSON(w, http.StatusOK, redacted)
 		}
 	case http.MethodPost:
 		var provider domain.Provider
@@ -133,7 +218,7 @@ func (h *AdminHandler) handleProviders(w http.ResponseWriter, r *http.Request, i
 			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
 		}
-		writeJSON(w, http.StatusCreated, provider)
+		writeJSON(w, http.StatusCreated, service.RedactProviderSecrets(&provider))
 	case http.MethodPut:
 		if id == 0 {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "id required"})
@@ -155,11 +240,37 @@ func (h *AdminHandler) handleProviders(w http.ResponseWriter, r *http.Request, i
 		// Preserve ID and timestamps
 		provider.ID = existing.ID
 		provider.CreatedAt = existing.CreatedAt
+		// Keep secrets the form left empty instead of wiping them
+		service.PreserveProviderSecrets(existing, &provider)
 		if err := h.svc.UpdateProvider(&provider); err != nil {
 			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
 		}
-		writeJSON(w, http.StatusOK, provider)
+		writeJSON(w, http.StatusOK, service.RedactProviderSecrets(&provider))
+	case http.MethodPatch:
+		// JSON merge patch: omitted fields and empty secrets keep their current value
+		if id == 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "id required"})
+			return
+		}
+		patch, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		provider, err := h.svc.PatchProvider(id, patch)
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrNotFound):
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": "provider not found"})
+			case errors.Is(err, service.ErrInvalidProviderConfig):
+				writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			default:
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			return
+		}
+		writeJSON(w, http.StatusOK, service.RedactProviderSecrets(provider))
 	case http.MethodDelete:
 		if id == 0 {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "id required"})
@@ -216,6 +327,36 @@ func (h *AdminHandler) handleProvidersImport(w http.ResponseWriter, r *http.Requ
 	writeJSON(w, http.StatusOK, result)
 }
 
+// handleProvidersDiscover handles POST /admin/providers/discover
+// Scans the credential discovery directory and creates or updates a provider per
+// credential. Body: {"overwrite": true} also replaces providers edited by hand.
+func (h *AdminHandler) handleProvidersDiscover(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	var opts domain.DiscoveryOptions
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && !errors.Is(err, io.EOF) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON: " + err.Error()})
+			return
+		}
+	}
+
+	report, err := h.svc.DiscoverProviders(r.Context(), opts)
+	if err != nil {
+		if errors.Is(err, service.ErrDiscoveryDirNotConfigured) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
 // Route handlers
 func (h *AdminHandler) handleRoutes(w http.ResponseWriter, r *http.Request, id uint64) {
 	switch r.Method {
@@ -242,7 +383,11 @@ func (h *AdminHandler) handleRoutes(w http.ResponseWriter, r *http.Request, id u
 			return
 		}
 		if err := h.svc.CreateRoute(&route); err != nil {
-			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			status := http.StatusInternalServerError
+			if errors.Is(err, domain.ErrInvalidInput) {
+				status = http.StatusBadRequest
+			}
+			writeJSON(w, status, map[string]string{"error": err.Error()})
 			return
 		}
 		writeJSON(w, http.StatusCreated, route)
@@ -299,8 +444,73 @@ func (h *AdminHandler) handleRoutes(w http.ResponseWriter, r *http.Request, id u
 				existing.RetryConfigID = uint64(f)
 			}
 		}
+		if v, ok := updates["hedgeDelayMs"]; ok {
+			if f, ok := v.(float64); ok && f >= 0 {
+				existing.HedgeDelayMs = int(f)
+			}
+		}
+		if v, ok := updates["failFast"]; ok {
+			if b, ok := v.(bool); ok {
+				existing.FailFast = b
+			}
+		}
+		if v, ok := updates["ensembleSize"]; ok {
+			if f, ok := v.(float64); ok && f >= 0 {
+				existing.EnsembleSize = int(f)
+			}
+		}
+		if v, ok := updates["ensembleStrategy"]; ok {
+			switch s, _ := v.(string); s {
+			case "", domain.EnsembleStrategyFirst, domain.EnsembleStrategyLongest, domain.EnsembleStrategyVote:
+				existing.EnsembleStrategy = s
+			}
+		}
+		if v, ok := updates["apiTokenIDs"]; ok {
+			// null or an empty list removes the restriction
+			list, _ := v.([]interface{})
+			ids := make([]uint64, 0, len(list))
+			for _, item := range list {
+				if f, ok := item.(float64); ok && f > 0 {
+					ids = append(ids, uint64(f))
+				}
+			}
+			existing.APITokenIDs = ids
+		}
+		if v, ok := updates["conditions"]; ok {
+			// null removes the conditions
+			var conditions *domain.RouteConditions
+			if v != nil {
+				raw, _ := json.Marshal(v)
+				if err := json.Unmarshal(raw, &conditions); err != nil {
+					writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid conditions: " + err.Error()})
+					return
+				}
+			}
+			existing.Conditions = conditions
+		}
+		if v, ok := updates["fallbackResponse"]; ok {
+			// null removes the fallback response
+			var fallback *domain.FallbackResponseConfig
+			if v != nil {
+				raw, _ := json.Marshal(v)
+				if err := json.Unmarshal(raw, &fallback); err != nil {
+					writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid fallbackResponse: " + err.Error()})
+					return
+				}
+			}
+			existing.FallbackResponse = fallback
+		}
+		if v, ok := updates["defaultModel"]; ok {
+			// null or "" removes the default model
+			s, _ := v.(string)
+			existing.DefaultModel = strings.TrimSpace(s)
+		}
 		if err := h.svc.UpdateRoute(existing); err != nil {
-			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			status := http.StatusInternalServerError
+			if errors.Is(err, domain.ErrInvalidInput) {
+				status = http.StatusBadRequest
+			}
+			writeJSON(w, status, map[string]string{"error": err.Error()})
 			return
 		}
 		writeJSON(w, http.StatusOK, existing)
@@ -310,6 +520,10 @@ func (h *AdminHandler) handleRoutes(w http.ResponseWriter, r *http.Request, id u
 			return
 		}
 		if err := h.svc.DeleteRoute(id); err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": "route not found"})
+				return
+			}
 			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
 		}
@@ -340,6 +554,28 @@ func (h *AdminHandler) handleBatchUpdateRoutePositions(w http.ResponseWriter, r
 	writeJSON(w, http.StatusOK, map[string]string{"message": "positions updated successfully"})
 }
 
+// handleSimulateRouting handles POST /admin/routes/simulate
+// Projects the traffic distribution under a proposed routing config without applying it
+func (h *AdminHandler) handleSimulateRouting(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	var req domain.RoutingSimulationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	result, err := h.svc.SimulateRouting(&req)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
 // Project handlers
 func (h *AdminHandler) handleProjects(w http.ResponseWriter, r *http.Request, id uint64, parts []string) {
 	// Check for by-slug endpoint: /admin/projects/by-slug/{slug}
@@ -436,7 +672,8 @@ func (h *AdminHandler) handleProjectBySlug(w http.ResponseWriter, r *http.Reques
 }
 
 // Session handlers
-// Routes: /admin/sessions, /admin/sessions/{sessionID}/project, /admin/sessions/{sessionID}/reject
+// Routes: /admin/sessions, /admin/sessions/{sessionID}, /admin/sessions/{sessionID}/project, /admin/sessions/{sessionID}/reject,
+// /admin/sessions/{sessionID}/conversation
 func (h *AdminHandler) handleSessions(w http.ResponseWriter, r *http.Request, parts []string) {
 	// Check for sub-resource: /admin/sessions/{sessionID}/project
 	if len(parts) > 3 && parts[3] == "project" {
@@ -450,6 +687,12 @@ func (h *AdminHandler) handleSessions(w http.ResponseWriter, r *http.Request, pa
 		return
 	}
 
+	// Check for sub-resource: /admin/sessions/{sessionID}/conversation
+	if len(parts) > 3 && parts[3] == "conversation" {
+		h.handleSessionConversation(w, r, parts[2])
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		sessions, err := h.svc.GetSessions()
@@ -458,11 +701,36 @@ func (h *AdminHandler) handleSessions(w http.ResponseWriter, r *http.Request, pa
 			return
 		}
 		writeJSON(w, http.StatusOK, sessions)
+	case http.MethodDelete:
+		if len(parts) < 3 || parts[2] == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "session ID required"})
+			return
+		}
+		h.handleSessionReset(w, parts[2])
 	default:
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 	}
 }
 
+// handleSessionReset handles DELETE /admin/sessions/{sessionID}
+// Clears the session's project binding and rejection and broadcasts the reset
+func (h *AdminHandler) handleSessionReset(w http.ResponseWriter, sessionID string) {
+	result, err := h.svc.ResetSession(sessionID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if h.wsHub != nil {
+		h.wsHub.BroadcastMessage("session_reset", result)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
 // handleSessionProject handles PUT /admin/sessions/{sessionID}/project
 func (h *AdminHandler) handleSessionProject(w http.ResponseWriter, r *http.Request, sessionID string) {
 	if r.Method != http.MethodPut {
@@ -513,6 +781,31 @@ func (h *AdminHandler) handleSessionReject(w http.ResponseWriter, r *http.Reques
 	writeJSON(w, http.StatusOK, session)
 }
 
+// handleSessionConversation handles GET /admin/sessions/{sessionID}/conversation
+// Returns the session's requests oldest first with a compact view of each turn
+func (h *AdminHandler) handleSessionConversation(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	if sessionID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "session ID required"})
+		return
+	}
+
+	conv, err := h.svc.GetSessionConversation(sessionID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, conv)
+}
+
 // RetryConfig handlers
 func (h *AdminHandler) handleRetryConfigs(w http.ResponseWriter, r *http.Request, id uint64) {
 	switch r.Method {
@@ -650,7 +943,7 @@ func (h *AdminHandler) handleRoutingStrategies(w http.ResponseWriter, r *http.Re
 }
 
 // ProxyRequest handlers
-// Routes: /admin/requests, /admin/requests/count, /admin/requests/active, /admin/requests/{id}, /admin/requests/{id}/attempts
+// Routes: /admin/requests, /admin/requests/count, /admin/requests/active, /admin/requests/search, /admin/requests/{id}, /admin/requests/{id}/attempts
 func (h *AdminHandler) handleProxyRequests(w http.ResponseWriter, r *http.Request, id uint64, parts []string) {
 	// Check for count endpoint: /admin/requests/count
 	if len(parts) > 2 && parts[2] == "count" {
@@ -658,12 +951,40 @@ func (h *AdminHandler) handleProxyRequests(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Check for replay endpoint: /admin/requests/replay
+	if len(parts) > 2 && parts[2] == "replay" {
+		if h.replay == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+			return
+		}
+		h.replay.ServeHTTP(w, r)
+		return
+	}
+
+	// Check for search endpoint: /admin/requests/search
+	if len(parts) > 2 && parts[2] == "search" {
+		h.handleProxyRequestsSearch(w, r)
+		return
+	}
+
 	// Check for active endpoint: /admin/requests/active
 	if len(parts) > 2 && parts[2] == "active" {
 		h.handleActiveProxyRequests(w, r)
 		return
 	}
 
+	// Check for sub-resource: /admin/requests/{id}/cancel
+	if len(parts) > 3 && parts[3] == "cancel" && id > 0 {
+		h.handleCancelProxyRequest(w, r, id)
+		return
+	}
+
+	// Check for sub-resource: /admin/requests/{id}/attempts/stream
+	if len(parts) > 4 && parts[3] == "attempts" && parts[4] == "stream" && id > 0 {
+		h.handleProxyUpstreamAttemptsStream(w, r, id)
+		return
+	}
+
 	// Check for sub-resource: /admin/requests/{id}/attempts
 	if len(parts) > 3 && parts[3] == "attempts" && id > 0 {
 		h.handleProxyUpstreamAttempts(w, r, id)
@@ -691,7 +1012,34 @@ func (h *AdminHandler) handleProxyRequests(w http.ResponseWriter, r *http.Reques
 			if a := r.URL.Query().Get("after"); a != "" {
 				after, _ = strconv.ParseUint(a, 10, 64)
 			}
-			result, err := h.svc.GetProxyRequestsCursor(limit, before, after)
+			// metadata=key:value filters by client metadata tags, repeat to match several
+			var filter *domain.ProxyRequestFilter
+			for _, tag := range r.URL.Query()["metadata"] {
+				key, value, ok := strings.Cut(tag, ":")
+				if !ok || key == "" {
+					writeJSON(w, http.StatusBadRequest, map[string]string{"error": "metadata filter must be key:value"})
+					return
+				}
+				if filter == nil {
+					filter = &domain.ProxyRequestFilter{Metadata: make(map[string]string)}
+				}
+				filter.Metadata[key] = value
+			}
+			// pii=any lists requests with any PII flag, pii=<name> a specific one
+			if flag := r.URL.Query().Get("pii"); flag != "" {
+				if filter == nil {
+					filter = &domain.ProxyRequestFilter{}
+				}
+				filter.PII = flag
+			}
+			// tag=<tag> lists requests carrying a cost allocation tag
+			if tag := r.URL.Query().Get("tag"); tag != "" {
+				if filter == nil {
+					filter = &domain.ProxyRequestFilter{}
+				}
+				filter.Tag = executor.NormalizeRequestTag(tag)
+			}
+			result, err := h.svc.GetProxyRequestsCursor(limit, before, after, filter)
 			if err != nil {
 				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 				return
@@ -704,6 +1052,50 @@ func (h *AdminHandler) handleProxyRequests(w http.ResponseWriter, r *http.Reques
 }
 
 // ProxyRequestsCount handler
+// handleMessageBatches handles GET /admin/batches and GET /admin/batches/{batchID}
+func (h *AdminHandler) handleMessageBatches(w http.ResponseWriter, r *http.Request, parts []string) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	if len(parts) > 2 && parts[2] != "" {
+		detail, err := h.svc.GetMessageBatch(parts[2])
+		if err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": "message batch not found"})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, detail)
+		return
+	}
+
+	limit := 100
+	var before, after uint64
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, _ = strconv.Atoi(l)
+	}
+	if b := r.URL.Query().Get("before"); b != "" {
+		before, _ = strconv.ParseUint(b, 10, 64)
+	}
+	if a := r.URL.Query().Get("after"); a != "" {
+		after, _ = strconv.ParseUint(a, 10, 64)
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+
+	page, err := h.svc.GetMessageBatches(limit, before, after)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
 func (h *AdminHandler) handleProxyRequestsCount(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
@@ -718,6 +1110,45 @@ func (h *AdminHandler) handleProxyRequestsCount(w http.ResponseWriter, r *http.R
 	writeJSON(w, http.StatusOK, count)
 }
 
+// handleProxyRequestsSearch handles GET /admin/requests/search?q=...&field=error|body|model
+// Results are paged like the request list, newest first, with limit and before.
+func (h *AdminHandler) handleProxyRequestsSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len([]rune(q)) < domain.MinRequestSearchLength {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("q must be at least %d characters", domain.MinRequestSearchLength)})
+		return
+	}
+	field := r.URL.Query().Get("field")
+	switch field {
+	case "", domain.RequestSearchFieldError, domain.RequestSearchFieldBody, domain.RequestSearchFieldModel:
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "field must be error, body or model"})
+		return
+	}
+
+	limit := 100
+	var before uint64
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, _ = strconv.Atoi(l)
+	}
+	if b := r.URL.Query().Get("before"); b != "" {
+		before, _ = strconv.ParseUint(b, 10, 64)
+	}
+
+	filter := &domain.ProxyRequestFilter{Search: q, SearchField: field}
+	result, err := h.svc.GetProxyRequestsCursor(limit, before, 0, filter)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
 // ActiveProxyRequests handler - returns all requests with PENDING or IN_PROGRESS status
 func (h *AdminHandler) handleActiveProxyRequests(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -733,6 +1164,20 @@ func (h *AdminHandler) handleActiveProxyRequests(w http.ResponseWriter, r *http.
 	writeJSON(w, http.StatusOK, requests)
 }
 
+// handleCancelProxyRequest handles POST /admin/requests/{id}/cancel, the request
+// ends as CANCELLED with the admin reason and a streaming client is told why
+func (h *AdminHandler) handleCancelProxyRequest(w http.ResponseWriter, r *http.Request, id uint64) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if h.canceller == nil || !h.canceller.CancelRequest(id, domain.CancelReasonAdmin) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "request is not in flight on this instance"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"cancelled": true})
+}
+
 // ProxyUpstreamAttempt handlers
 func (h *AdminHandler) handleProxyUpstreamAttempts(w http.ResponseWriter, r *http.Request, proxyRequestID uint64) {
 	if r.Method != http.MethodGet {
@@ -748,6 +1193,86 @@ func (h *AdminHandler) handleProxyUpstreamAttempts(w http.ResponseWriter, r *htt
 	writeJSON(w, http.StatusOK, attempts)
 }
 
+// handleProxyUpstreamAttemptsStream streams attempt updates of one request as SSE.
+// Stored attempts are replayed first; the stream ends once the request reaches a terminal state.
+func (h *AdminHandler) handleProxyUpstreamAttemptsStream(w http.ResponseWriter, r *http.Request, proxyRequestID uint64) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok || h.wsHub == nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming not supported"})
+		return
+	}
+
+	proxyReq, err := h.svc.GetProxyRequest(proxyRequestID)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "proxy request not found"})
+		return
+	}
+
+	// Subscribe before replaying so no update falls between the two
+	updates, unsubscribe := h.wsHub.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, data interface{}) {
+		payload, _ := json.Marshal(data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+		flusher.Flush()
+	}
+
+	attempts, err := h.svc.GetProxyUpstreamAttempts(proxyRequestID)
+	if err == nil {
+		for _, attempt := range attempts {
+			send("attempt", attempt)
+		}
+	}
+	if isTerminalRequestStatus(proxyReq.Status) {
+		send("done", proxyReq)
+		return
+	}
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case msg := <-updates:
+			switch data := msg.Data.(type) {
+			case *domain.ProxyUpstreamAttempt:
+				if data.ProxyRequestID == proxyRequestID {
+					send("attempt", data)
+				}
+			case *domain.ProxyRequest:
+				if data.ID == proxyRequestID && isTerminalRequestStatus(data.Status) {
+					send("done", data)
+					return
+				}
+			}
+		}
+	}
+}
+
+// isTerminalRequestStatus reports whether a proxy request has finished
+func isTerminalRequestStatus(status string) bool {
+	switch status {
+	case "COMPLETED", "FAILED", "CANCELLED", "REJECTED":
+		return true
+	}
+	return false
+}
+
 // Settings handlers
 func (h *AdminHandler) handleSettings(w http.ResponseWriter, r *http.Request, parts []string) {
 	var key string
@@ -832,16 +1357,194 @@ func (h *AdminHandler) handleProviderStats(w http.ResponseWriter, r *http.Reques
 	writeJSON(w, http.StatusOK, stats)
 }
 
-// Logs handler
-func (h *AdminHandler) handleLogs(w http.ResponseWriter, r *http.Request) {
+// handleProvidersReport handles GET /admin/providers/report?range=7d
+// Returns a per-provider comparison over the selected window
+func (h *AdminHandler) handleProvidersReport(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 		return
 	}
 
-	limit := 100
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
+	window := 7 * 24 * time.Hour
+	if rangeStr := r.URL.Query().Get("range"); rangeStr != "" {
+		parsed, err := parseReportRange(rangeStr)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		window = parsed
+	}
+
+	report, err := h.svc.GetProviderReport(window)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleProviderTimeouts handles GET /admin/providers/timeouts
+// Returns each provider's observed p99 latency and current adaptive timeout
+func (h *AdminHandler) handleProviderTimeouts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	writeJSON(w, http.StatusOK, h.svc.GetProviderTimeouts())
+}
+
+// handleProviderPacing handles GET /admin/providers/pacing
+// Returns each paced provider's minimum interval, current delay and queued calls
+func (h *AdminHandler) handleProviderPacing(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	writeJSON(w, http.StatusOK, h.svc.GetProviderPacing())
+}
+
+// handleProviderConnections handles GET /admin/providers/connections
+// Returns how many of each provider's upstream requests reused an idle connection or dialed a new one
+func (h *AdminHandler) handleProviderConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	writeJSON(w, http.StatusOK, h.svc.GetProviderConnections())
+}
+
+// handleProviderModels handles the cached upstream model lists
+// GET /admin/providers/models - cached models of every provider that can list them
+// GET /admin/providers/{id}/models - cached models of one provider
+// DELETE /admin/providers/models - invalidate all cached lists
+// DELETE /admin/providers/{id}/models - invalidate one provider's list
+func (h *AdminHandler) handleProviderModels(w http.ResponseWriter, r *http.Request, id uint64) {
+	switch r.Method {
+	case http.MethodGet:
+		infos := h.svc.GetProviderModels()
+		if id == 0 {
+			writeJSON(w, http.StatusOK, infos)
+			return
+		}
+		for _, info := range infos {
+			if info.ProviderID == id {
+				writeJSON(w, http.StatusOK, info)
+				return
+			}
+		}
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "provider not found or can't list models"})
+	case http.MethodDelete:
+		h.svc.InvalidateProviderModels(id)
+		writeJSON(w, http.StatusNoContent, nil)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+// handleProviderSoak runs a soak test against a provider: POST /admin/providers/{id}/soak
+func (h *AdminHandler) handleProviderSoak(w http.ResponseWriter, r *http.Request, id uint64) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if id == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "id required"})
+		return
+	}
+
+	var req domain.ProviderSoakRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	report, err := h.svc.SoakProvider(r.Context(), id, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "provider not found"})
+		case errors.Is(err, soak.ErrInvalidRequest):
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		case errors.Is(err, soak.ErrRunning):
+			writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		default:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleProviderEvents lists a provider's lifecycle events, newest first:
+// GET /admin/providers/{id}/events?start=&end=&limit=, start and end in RFC3339
+// GET /admin/providers/events lists the events of every provider
+func (h *AdminHandler) handleProviderEvents(w http.ResponseWriter, r *http.Request, id uint64) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	query := r.URL.Query()
+	var since, until time.Time
+	for _, param := range []struct {
+		name string
+		dst  *time.Time
+	}{{"start", &since}, {"end", &until}} {
+		if value := query.Get(param.name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid " + param.name + ": " + err.Error()})
+				return
+			}
+			*param.dst = t
+		}
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	events, err := h.svc.GetProviderEvents(id, since, until, limit)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "provider not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// parseReportRange parses a report window such as "7d", "24h" or "30m"
+func parseReportRange(s string) (time.Duration, error) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid range %q", s)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid range %q", s)
+		}
+		d = parsed
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("range must be positive: %q", s)
+	}
+	return d, nil
+}
+
+// Logs handler
+func (h *AdminHandler) handleLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 {
 			limit = parsed
 		}
 	}
@@ -928,7 +1631,11 @@ func (h *AdminHandler) handleAPITokens(w http.ResponseWriter, r *http.Request, i
 			Name        string  `json:"name"`
 			Description string  `json:"description"`
 			ProjectID   uint64  `json:"projectID"`
+			AdminAccess bool    `json:"adminAccess"`
+			GlobalAdmin bool    `json:"globalAdmin"`
 			ExpiresAt   *string `json:"expiresAt"`
+
+			DefaultClientType domain.ClientType `json:"defaultClientType"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
@@ -947,7 +1654,7 @@ func (h *AdminHandler) handleAPITokens(w http.ResponseWriter, r *http.Request, i
 			}
 			expiresAt = &t
 		}
-		result, err := h.svc.CreateAPIToken(body.Name, body.Description, body.ProjectID, expiresAt)
+		result, err := h.svc.CreateAPIToken(body.Name, body.Description, body.ProjectID, body.AdminAccess, body.GlobalAdmin, expiresAt, body.DefaultClientType)
 		if err != nil {
 			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
@@ -968,7 +1675,11 @@ func (h *AdminHandler) handleAPITokens(w http.ResponseWriter, r *http.Request, i
 			Description *string `json:"description"`
 			ProjectID   *uint64 `json:"projectID"`
 			IsEnabled   *bool   `json:"isEnabled"`
+			AdminAccess *bool   `json:"adminAccess"`
+			GlobalAdmin *bool   `json:"globalAdmin"`
 			ExpiresAt   *string `json:"expiresAt"`
+
+			DefaultClientType *domain.ClientType `json:"defaultClientType"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
@@ -990,6 +1701,15 @@ func (h *AdminHandler) handleAPITokens(w http.ResponseWriter, r *http.Request, i
 		if body.IsEnabled != nil {
 			existing.IsEnabled = *body.IsEnabled
 		}
+		if body.AdminAccess != nil {
+			existing.AdminAccess = *body.AdminAccess
+		}
+		if body.GlobalAdmin != nil {
+			existing.GlobalAdmin = *body.GlobalAdmin
+		}
+		if body.DefaultClientType != nil {
+			existing.DefaultClientType = *body.DefaultClientType
+		}
 		if body.ExpiresAt != nil {
 			if *body.ExpiresAt == "" {
 				existing.ExpiresAt = nil
@@ -1063,10 +1783,27 @@ func (h *AdminHandler) handleModelMappings(w http.ResponseWriter, r *http.Reques
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "pattern is required"})
 			return
 		}
+		if err := domain.ValidateModelMappingTargets(mapping.Targets); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if mapping.Target == "" {
+			// Weighted rules fall back to their heaviest target
+			weight := 0
+			for _, t := range mapping.Targets {
+				if t.Weight > weight {
+					mapping.Target, weight = t.Target, t.Weight
+				}
+			}
+		}
 		if mapping.Target == "" {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "target is required"})
 			return
 		}
+		if err := domain.ValidateModelMappingPattern(mapping.Pattern); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
 		if err := h.svc.CreateModelMapping(&mapping); err != nil {
 			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
@@ -1083,10 +1820,12 @@ func (h *AdminHandler) handleModelMappings(w http.ResponseWriter, r *http.Reques
 			return
 		}
 		var body struct {
-			ClientType *string `json:"clientType"`
-			Pattern    *string `json:"pattern"`
-			Target     *string `json:"target"`
-			Priority   *int    `json:"priority"`
+			ClientType    *string                      `json:"clientType"`
+			Pattern       *string                      `json:"pattern"`
+			Target        *string                      `json:"target"`
+			Targets       *[]domain.ModelMappingTarget `json:"targets"`
+			StickySession *bool                        `json:"stickySession"`
+			Priority      *int                         `json:"priority"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
@@ -1100,6 +1839,10 @@ func (h *AdminHandler) handleModelMappings(w http.ResponseWriter, r *http.Reques
 				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "pattern cannot be empty"})
 				return
 			}
+			if err := domain.ValidateModelMappingPattern(*body.Pattern); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
 			existing.Pattern = *body.Pattern
 		}
 		if body.Target != nil {
@@ -1109,6 +1852,16 @@ func (h *AdminHandler) handleModelMappings(w http.ResponseWriter, r *http.Reques
 			}
 			existing.Target = *body.Target
 		}
+		if body.Targets != nil {
+			if err := domain.ValidateModelMappingTargets(*body.Targets); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			existing.Targets = *body.Targets
+		}
+		if body.StickySession != nil {
+			existing.StickySession = *body.StickySession
+		}
 		if body.Priority != nil {
 			existing.Priority = *body.Priority
 		}
@@ -1168,13 +1921,28 @@ func (h *AdminHandler) handleUsageStats(w http.ResponseWriter, r *http.Request)
 		h.handleRecalculateUsageStats(w, r)
 		return
 	}
+	if strings.HasSuffix(path, "/changes") {
+		h.handleUsageStatsChanges(w, r)
+		return
+	}
 
 	if r.Method != http.MethodGet {
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 		return
 	}
 
-	// Parse query parameters for filtering
+	filter := parseUsageStatsFilter(r)
+
+	stats, err := h.svc.GetUsageStats(filter)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// parseUsageStatsFilter parses usage stats query parameters shared by usage endpoints
+func parseUsageStatsFilter(r *http.Request) repository.UsageStatsFilter {
 	query := r.URL.Query()
 	filter := repository.UsageStatsFilter{}
 
@@ -1237,7 +2005,106 @@ func (h *AdminHandler) handleUsageStats(w http.ResponseWriter, r *http.Request)
 		filter.Model = &model
 	}
 
-	stats, err := h.svc.GetUsageStats(filter)
+	return filter
+}
+
+// handleUsage routes /admin/usage/* requests
+func (h *AdminHandler) handleUsage(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) < 3 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+
+	switch parts[2] {
+	case "models":
+		h.handleUsageModelStats(w, r)
+	case "client-apps":
+		h.handleUsageClientAppStats(w, r)
+	case "tags":
+		h.handleUsageTagStats(w, r)
+	case "empty-responses":
+		h.handleUsageEmptyResponseStats(w, r)
+	case "upstream-timings":
+		h.handleUsageUpstreamTimingStats(w, r)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+// handleUsageModelStats handles GET /admin/usage/models
+// Returns per-model success rate, latency, token usage, cost and error breakdown
+func (h *AdminHandler) handleUsageModelStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	stats, err := h.svc.GetModelStats(parseUsageStatsFilter(r))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleUsageClientAppStats handles GET /admin/usage/client-apps
+// Returns usage totals keyed by client app, e.g. "claude-cli/2.0"
+func (h *AdminHandler) handleUsageClientAppStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	stats, err := h.svc.GetClientAppStats(parseUsageStatsFilter(r))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleUsageTagStats handles GET /admin/usage/tags
+// Returns usage totals keyed by cost allocation tag
+func (h *AdminHandler) handleUsageTagStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	stats, err := h.svc.GetTagStats(parseUsageStatsFilter(r))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleUsageEmptyResponseStats handles GET /admin/usage/empty-responses
+// Returns the rate of successful responses without content per provider and client type
+func (h *AdminHandler) handleUsageEmptyResponseStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	stats, err := h.svc.GetEmptyResponseStats(parseUsageStatsFilter(r))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleUsageUpstreamTimingStats handles GET /admin/usage/upstream-timings
+// Returns the average DNS/TCP/TLS/first byte times per provider, for attempts
+// captured with the upstream_timing_capture setting on
+func (h *AdminHandler) handleUsageUpstreamTimingStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	stats, err := h.svc.GetUpstreamTimingStats(parseUsageStatsFilter(r))
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
@@ -1245,6 +2112,28 @@ func (h *AdminHandler) handleUsageStats(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusOK, stats)
 }
 
+// handleUsageStatsChanges handles GET /admin/usage-stats/changes?since=<ms>
+// Returns only the buckets changed since the cursor of the previous poll, filters match /admin/usage-stats
+func (h *AdminHandler) handleUsageStatsChanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	since, err := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+	if err != nil || since < 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "since must be a millisecond timestamp"})
+		return
+	}
+
+	changes, err := h.svc.GetUsageStatsChanges(parseUsageStatsFilter(r), time.UnixMilli(since))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
+
 // handleRecalculateUsageStats handles POST /admin/usage-stats/recalculate
 func (h *AdminHandler) handleRecalculateUsageStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -1275,14 +2164,29 @@ func (h *AdminHandler) handleResponseModels(w http.ResponseWriter, r *http.Reque
 }
 
 // handleDashboard handles GET /admin/dashboard
-// Returns all dashboard data in a single request
+// Returns all dashboard data in a single request, scoped to one project with ?projectID=N
+// (projectId, as on the usage stats endpoints, is accepted too)
 func (h *AdminHandler) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 		return
 	}
 
-	data, err := h.svc.GetDashboardData()
+	var projectID *uint64
+	projectIDStr := r.URL.Query().Get("projectID")
+	if projectIDStr == "" {
+		projectIDStr = r.URL.Query().Get("projectId")
+	}
+	if projectIDStr != "" {
+		id, err := strconv.ParseUint(projectIDStr, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid projectID"})
+			return
+		}
+		projectID = &id
+	}
+
+	data, err := h.svc.GetDashboardData(projectID)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
@@ -1292,8 +2196,8 @@ func (h *AdminHandler) handleDashboard(w http.ResponseWriter, r *http.Request) {
 
 // handleBackup routes backup requests
 func (h *AdminHandler) handleBackup(w http.ResponseWriter, r *http.Request, parts []string) {
-	if len(parts) < 3 {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	if len(parts) < 3 || parts[2] == "" {
+		h.handleDatabaseBackup(w, r)
 		return
 	}
 
@@ -1308,6 +2212,71 @@ func (h *AdminHandler) handleBackup(w http.ResponseWriter, r *http.Request, part
 	}
 }
 
+// handleSecrets reports and rotates the master key of stored credentials
+// GET /admin/secrets - encryption status
+// POST /admin/secrets/rotate - body {"masterKey": "..."}, re-encrypts all credentials with the new key
+func (h *AdminHandler) handleSecrets(w http.ResponseWriter, r *http.Request, parts []string) {
+	action := ""
+	if len(parts) > 2 {
+		action = parts[2]
+	}
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, h.svc.GetMasterKeyStatus())
+	case action == "rotate" && r.Method == http.MethodPost:
+		var body struct {
+			MasterKey string `json:"masterKey"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON: " + err.Error()})
+			return
+		}
+		result, err := h.svc.RotateMasterKey(body.MasterKey)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidMasterKey) {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			} else {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	case action == "" || action == "rotate":
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+// handleSignatureCache inspects or clears the Antigravity thought signature cache.
+// DELETE clears everything, or only the signatures of ?sessionID= or ?toolID=,
+// to recover conversations broken by a bad cached signature without a restart.
+func (h *AdminHandler) handleSignatureCache(w http.ResponseWriter, r *http.Request) {
+	cache := antigravity.GlobalSignatureCache()
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, cache.Stats())
+	case http.MethodDelete:
+		sessionID := r.URL.Query().Get("sessionID")
+		toolID := r.URL.Query().Get("toolID")
+		var removed int
+		switch {
+		case sessionID != "" && toolID != "":
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "sessionID and toolID are exclusive"})
+			return
+		case sessionID != "":
+			removed = cache.ClearSession(sessionID)
+		case toolID != "":
+			removed = cache.ClearTool(toolID)
+		default:
+			removed = cache.ClearAll()
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"removed": removed})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
 // handleBackupExport exports all configuration data
 func (h *AdminHandler) handleBackupExport(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -1328,6 +2297,37 @@ func (h *AdminHandler) handleBackupExport(w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(backup)
 }
 
+// handleDatabaseBackup downloads a consistent SQLite snapshot of the whole database.
+// GET /admin/backup?sanitize=true redacts credentials and clears request/response
+// bodies so the file can be shared for support. See BackupService.DatabaseBackup
+// for restoring; configuration alone round-trips through export/import.
+func (h *AdminHandler) handleDatabaseBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	sanitize := r.URL.Query().Get("sanitize") == "true"
+	snapshot, err := h.backupSvc.DatabaseBackup(sanitize)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrDatabaseBackupUnsupported) {
+			status = http.StatusNotImplemented
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	defer snapshot.Close()
+
+	filename := "maxx-backup-" + time.Now().Format("2006-01-02")
+	if sanitize {
+		filename += "-sanitized"
+	}
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", "attachment; filename="+filename+".db")
+	io.Copy(w, snapshot)
+}
+
 // handleBackupImport imports configuration data from backup
 func (h *AdminHandler) handleBackupImport(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -1343,8 +2343,9 @@ func (h *AdminHandler) handleBackupImport(w http.ResponseWriter, r *http.Request
 
 	// Parse options from query params
 	opts := domain.ImportOptions{
-		ConflictStrategy: r.URL.Query().Get("conflictStrategy"),
-		DryRun:           r.URL.Query().Get("dryRun") == "true",
+		ConflictStrategy:        r.URL.Query().Get("conflictStrategy"),
+		MappingConflictStrategy: r.URL.Query().Get("mappingConflictStrategy"),
+		DryRun:                  r.URL.Query().Get("dryRun") == "true",
 	}
 	if opts.ConflictStrategy == "" {
 		opts.ConflictStrategy = "skip"
@@ -1359,6 +2360,69 @@ func (h *AdminHandler) handleBackupImport(w http.ResponseWriter, r *http.Request
 	writeJSON(w, http.StatusOK, result)
 }
 
+// handleConfig routes config bundle requests
+func (h *AdminHandler) handleConfig(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) < 3 || parts[2] != "import" {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	h.handleConfigImport(w, r)
+}
+
+// handleConfigImport plans or applies a config bundle import.
+// Without ?confirm= it only validates and returns the plan; with ?confirm=<fingerprint>
+// it applies the plan atomically, provided it is unchanged and valid.
+func (h *AdminHandler) handleConfigImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	var backup domain.BackupFile
+	if err := json.NewDecoder(r.Body).Decode(&backup); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON: " + err.Error()})
+		return
+	}
+
+	opts := domain.ImportOptions{
+		ConflictStrategy:        r.URL.Query().Get("conflictStrategy"),
+		MappingConflictStrategy: r.URL.Query().Get("mappingConflictStrategy"),
+	}
+	if opts.ConflictStrategy == "" {
+		opts.ConflictStrategy = "skip"
+	}
+
+	confirm := r.URL.Query().Get("confirm")
+	if confirm == "" {
+		plan, err := h.backupSvc.PlanImport(&backup, opts)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, domain.ConfigImportResult{Plan: plan})
+		return
+	}
+
+	result, err := h.backupSvc.ApplyImport(&backup, opts, confirm)
+	if err != nil {
+		if result == nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrImportPlanChanged):
+			status = http.StatusConflict
+		case errors.Is(err, service.ErrImportPlanInvalid):
+			status = http.StatusUnprocessableEntity
+		}
+		writeJSON(w, status, map[string]interface{}{"error": err.Error(), "plan": result.Plan, "result": result.Result})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
 func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
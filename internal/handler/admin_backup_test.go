@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/repository/sqlite"
+	"github.com/awsl-project/maxx/internal/service"
+)
+
+func TestDatabaseBackupDownload(t *testing.T) {
+	db, err := sqlite.NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	backupSvc := service.NewBackupService(nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	h := NewAdminHandler(nil, backupSvc, nil, "")
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get("/admin/backup"); rec.Code != http.StatusNotImplemented {
+		t.Errorf("without a database: status = %d, want 501", rec.Code)
+	}
+
+	backupSvc.SetDatabase(db)
+	for _, path := range []string{"/admin/backup", "/admin/backup?sanitize=true"} {
+		rec := get(path)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d: %s", path, rec.Code, rec.Body)
+		}
+		if !bytes.HasPrefix(rec.Body.Bytes(), []byte("SQLite format 3\x00")) {
+			t.Errorf("GET %s: body is not an SQLite database", path)
+		}
+		disposition := rec.Header().Get("Content-Disposition")
+		if sanitized := strings.Contains(path, "sanitize"); strings.Contains(disposition, "-sanitized") != sanitized {
+			t.Errorf("GET %s: Content-Disposition = %q", path, disposition)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/backup", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/awsl-project/maxx/internal/service"
+)
+
+type adminScopeKey struct{}
+
+// WithAdminScope stores the caller's admin scope in the request context
+func WithAdminScope(ctx context.Context, scope service.AdminScope) context.Context {
+	return context.WithValue(ctx, adminScopeKey{}, scope)
+}
+
+// AdminScopeFromContext returns the caller's admin scope, unscoped when none was set
+func AdminScopeFromContext(ctx context.Context) service.AdminScope {
+	scope, _ := ctx.Value(adminScopeKey{}).(service.AdminScope)
+	return scope
+}
+
+// scopedAdminAllowed reports whether a project-scoped admin token may call the endpoint.
+// Scoped tokens can read their project, the providers its routes use, its requests
+// and stats, and manage its routes. Global resources (settings, tokens, sessions,
+// backups, provider management, global reports) stay with unscoped admins.
+func scopedAdminAllowed(method, resource string, parts []string) bool {
+	sub := ""
+	if len(parts) > 2 {
+		sub = parts[2]
+	}
+
+	switch resource {
+	case "routes":
+		// Simulation works on the global routing config
+		return sub != "simulate"
+	case "providers":
+		// Provider endpoints are dispatched on the path suffix, /providers/{id}/pacing
+		// reaches the same global report as /providers/pacing
+		switch parts[len(parts)-1] {
+		case "export", "report", "timeouts", "connections", "pacing":
+			return false
+		}
+		return method == http.MethodGet
+	case "requests":
+		return method == http.MethodGet && sub != "count"
+	case "projects", "provider-stats", "usage-stats", "usage", "proxy-status":
+		return method == http.MethodGet
+	default:
+		return false
+	}
+}
@@ -0,0 +1,313 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository/cached"
+	"github.com/awsl-project/maxx/internal/repository/sqlite"
+	"github.com/awsl-project/maxx/internal/service"
+)
+
+type scopeFixture struct {
+	handler         http.Handler
+	scopedToken     string
+	adminToken      string
+	ownReqID        uint64
+	otherReqID      uint64
+	ownProviderID   uint64
+	otherProviderID uint64
+	ownRouteID      uint64
+}
+
+// modelListCache serves fixed upstream model lists
+type modelListCache struct {
+	lists []*domain.ProviderModelsInfo
+}
+
+func (c *modelListCache) RefreshAdapter(*domain.Provider) error { return nil }
+func (c *modelListCache) RemoveAdapter(uint64)                  {}
+func (c *modelListCache) InvalidateModels(uint64)               {}
+func (c *modelListCache) ModelLists() []*domain.ProviderModelsInfo {
+	return c.lists
+}
+
+func newScopeFixture(t *testing.T) *scopeFixture {
+	t.Helper()
+	db, err := sqlite.NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	providerRepo := sqlite.NewProviderRepository(db)
+	routeRepo := sqlite.NewRouteRepository(db)
+	projectRepo := sqlite.NewProjectRepository(db)
+	requestRepo := sqlite.NewProxyRequestRepository(db)
+	usageRepo := sqlite.NewUsageStatsRepository(db)
+	settingRepo := sqlite.NewSystemSettingRepository(db)
+	eventRepo := sqlite.NewProviderEventRepository(db)
+	tokenRepo := cached.NewAPITokenRepository(sqlite.NewAPITokenRepository(db))
+
+	own := &domain.Project{Name: "own", Slug: "own"}
+	other := &domain.Project{Name: "other", Slug: "other"}
+	for _, p := range []*domain.Project{own, other} {
+		if err := projectRepo.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := &scopeFixture{}
+	models := &modelListCache{}
+	for _, p := range []*domain.Project{own, other} {
+		provider := &domain.Provider{Name: p.Name, Type: "custom"}
+		if err := providerRepo.Create(provider); err != nil {
+			t.Fatal(err)
+		}
+		route := &domain.Route{IsEnabled: true, ProjectID: p.ID, ClientType: domain.ClientTypeClaude, ProviderID: provider.ID}
+		if err := routeRepo.Create(route); err != nil {
+			t.Fatal(err)
+		}
+		models.lists = append(models.lists, &domain.ProviderModelsInfo{ProviderID: provider.ID, ProviderName: provider.Name})
+		if err := eventRepo.Create(&domain.ProviderEvent{ProviderID: provider.ID, Type: domain.ProviderEventCooldownEntered}); err != nil {
+			t.Fatal(err)
+		}
+		if p == own {
+			f.ownProviderID, f.ownRouteID = provider.ID, route.ID
+		} else {
+			f.otherProviderID = provider.ID
+		}
+
+		req := &domain.ProxyRequest{Status: "COMPLETED", ProjectID: p.ID}
+		if err := requestRepo.Create(req); err != nil {
+			t.Fatal(err)
+		}
+		if p == own {
+			f.ownReqID = req.ID
+		} else {
+			f.otherReqID = req.ID
+		}
+
+		bucket := time.Now().UTC().Add(-48 * time.Hour).Truncate(24 * time.Hour)
+		if err := usageRepo.Upsert(&domain.UsageStats{
+			TimeBucket:    bucket,
+			Granularity:   domain.GranularityDay,
+			ProjectID:     p.ID,
+			ProviderID:    1,
+			ClientType:    "claude",
+			Model:         "claude-sonnet",
+			TotalRequests: 1,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	svc := service.NewAdminService(providerRepo, routeRepo, projectRepo, nil, nil, nil, requestRepo, nil, settingRepo, tokenRepo, nil, usageRepo, nil, nil, eventRepo, "", models)
+	tokenAuth := NewTokenAuthMiddleware(tokenRepo, settingRepo)
+	for _, tc := range []struct {
+		projectID uint64
+		dst       *string
+	}{{own.ID, &f.scopedToken}, {0, &f.adminToken}} {
+		result, err := svc.CreateAPIToken("admin", "", tc.projectID, true, tc.projectID == 0, nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		*tc.dst = result.Token
+	}
+
+	auth := NewAuthMiddleware()
+	auth.SetTokenAuth(tokenAuth)
+	f.handler = auth.Wrap(NewAdminHandler(svc, nil, nil, ""))
+	return f
+}
+
+func (f *scopeFixture) get(t *testing.T, token, path string, out any) int {
+	t.Helper()
+	return f.do(t, http.MethodGet, token, path, "", out)
+}
+
+func (f *scopeFixture) do(t *testing.T, method, token, path, body string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	f.handler.ServeHTTP(rec, req)
+	if out != nil && rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return rec.Code
+}
+
+func TestScopedTokenSeesOnlyItsProjectRequests(t *testing.T) {
+	f := newScopeFixture(t)
+
+	var page struct {
+		Items []*domain.ProxyRequest `json:"items"`
+	}
+	if code := f.get(t, f.scopedToken, "/admin/requests", &page); code != http.StatusOK {
+		t.Fatalf("list status = %d", code)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != f.ownReqID {
+		t.Errorf("scoped list = %d requests, want only the own project's", len(page.Items))
+	}
+
+	if code := f.get(t, f.scopedToken, "/admin/requests/"+itoa(f.otherReqID), nil); code != http.StatusNotFound {
+		t.Errorf("reading another project's request: status = %d, want 404", code)
+	}
+	if code := f.get(t, f.scopedToken, "/admin/requests/"+itoa(f.otherReqID)+"/attempts", nil); code == http.StatusOK {
+		t.Error("reading another project's attempts succeeded")
+	}
+	if code := f.get(t, f.scopedToken, "/admin/requests/"+itoa(f.ownReqID), nil); code != http.StatusOK {
+		t.Errorf("reading own request: status = %d, want 200", code)
+	}
+
+	if code := f.get(t, f.adminToken, "/admin/requests", &page); code != http.StatusOK || len(page.Items) != 2 {
+		t.Errorf("unscoped list = %d requests (status %d), want 2", len(page.Items), code)
+	}
+}
+
+func TestScopedTokenSeesOnlyItsProjectStats(t *testing.T) {
+	f := newScopeFixture(t)
+
+	count := func(token string) int {
+		t.Helper()
+		var stats []*domain.UsageStats
+		if code := f.get(t, token, "/admin/usage-stats?granularity=day", &stats); code != http.StatusOK {
+			t.Fatalf("usage-stats status = %d", code)
+		}
+		projects := make(map[uint64]bool)
+		for _, s := range stats {
+			projects[s.ProjectID] = true
+		}
+		return len(projects)
+	}
+
+	if got := count(f.scopedToken); got != 1 {
+		t.Errorf("scoped usage stats span %d projects, want 1", got)
+	}
+	if got := count(f.adminToken); got != 2 {
+		t.Errorf("unscoped usage stats span %d projects, want 2", got)
+	}
+}
+
+func TestScopedTokenCannotReachGlobalResources(t *testing.T) {
+	f := newScopeFixture(t)
+
+	for _, path := range []string{
+		"/admin/settings", "/admin/api-tokens", "/admin/sessions", "/admin/dashboard", "/admin/requests/count",
+		"/admin/providers/pacing", "/admin/providers/" + itoa(f.ownProviderID) + "/pacing", "/admin/providers/1/connections",
+	} {
+		if code := f.get(t, f.scopedToken, path, nil); code != http.StatusForbidden {
+			t.Errorf("GET %s: status = %d, want 403", path, code)
+		}
+	}
+
+	var projects []*domain.Project
+	if code := f.get(t, f.scopedToken, "/admin/projects", &projects); code != http.StatusOK || len(projects) != 1 {
+		t.Errorf("scoped project list = %d projects (status %d), want 1", len(projects), code)
+	}
+}
+
+func TestScopedTokenCannotRouteToForeignProviders(t *testing.T) {
+	f := newScopeFixture(t)
+
+	create := func(providerID uint64) int {
+		return f.do(t, http.MethodPost, f.scopedToken, "/admin/routes",
+			`{"isEnabled":true,"clientType":"claude","providerID":`+itoa(providerID)+`}`, nil)
+	}
+	if code := create(f.otherProviderID); code != http.StatusBadRequest {
+		t.Errorf("creating a route to another project's provider: status = %d, want 400", code)
+	}
+	if code := f.do(t, http.MethodPut, f.scopedToken, "/admin/routes/"+itoa(f.ownRouteID),
+		`{"providerID":`+itoa(f.otherProviderID)+`}`, nil); code != http.StatusBadRequest {
+		t.Errorf("moving a route to another project's provider: status = %d, want 400", code)
+	}
+	if code := f.get(t, f.scopedToken, "/admin/providers/"+itoa(f.otherProviderID), nil); code != http.StatusNotFound {
+		t.Errorf("reading another project's provider: status = %d, want 404", code)
+	}
+
+	if code := create(f.ownProviderID); code != http.StatusCreated {
+		t.Errorf("creating a route to the own provider: status = %d, want 201", code)
+	}
+}
+
+func TestAdminTokenWithoutProjectNeedsGlobalAccess(t *testing.T) {
+	db, err := sqlite.NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	settingRepo := sqlite.NewSystemSettingRepository(db)
+	tokenRepo := cached.NewAPITokenRepository(sqlite.NewAPITokenRepository(db))
+	svc := service.NewAdminService(nil, nil, sqlite.NewProjectRepository(db), nil, nil, nil, nil, nil, settingRepo, tokenRepo, nil, nil, nil, nil, nil, "", nil)
+
+	auth := NewAuthMiddleware()
+	auth.SetTokenAuth(NewTokenAuthMiddleware(tokenRepo, settingRepo))
+	handler := auth.Wrap(NewAdminHandler(svc, nil, nil, ""))
+
+	for _, globalAdmin := range []bool{false, true} {
+		result, err := svc.CreateAPIToken("admin", "", 0, true, globalAdmin, nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/admin/projects", nil)
+		req.Header.Set("Authorization", "Bearer "+result.Token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		want := http.StatusUnauthorized
+		if globalAdmin {
+			want = http.StatusOK
+		}
+		if rec.Code != want {
+			t.Errorf("globalAdmin=%v: status = %d, want %d", globalAdmin, rec.Code, want)
+		}
+	}
+}
+
+func TestScopedTokenSeesOnlyItsProviderEvents(t *testing.T) {
+	f := newScopeFixture(t)
+
+	var events []*domain.ProviderEvent
+	if code := f.get(t, f.scopedToken, "/admin/providers/events", &events); code != http.StatusOK {
+		t.Fatalf("events status = %d", code)
+	}
+	if len(events) != 1 || events[0].ProviderID != f.ownProviderID {
+		t.Errorf("scoped events = %+v, want only the own provider's", events)
+	}
+	if code := f.get(t, f.scopedToken, "/admin/providers/"+itoa(f.otherProviderID)+"/events", nil); code != http.StatusNotFound {
+		t.Errorf("reading another project's provider events: status = %d, want 404", code)
+	}
+	if code := f.get(t, f.scopedToken, "/admin/providers/"+itoa(f.ownProviderID)+"/events", &events); code != http.StatusOK || len(events) != 1 {
+		t.Errorf("own provider events = %d (status %d), want 1", len(events), code)
+	}
+
+	if code := f.get(t, f.adminToken, "/admin/providers/events", &events); code != http.StatusOK || len(events) != 2 {
+		t.Errorf("unscoped events = %d (status %d), want 2", len(events), code)
+	}
+}
+
+func TestScopedTokenSeesOnlyItsProviderModels(t *testing.T) {
+	f := newScopeFixture(t)
+
+	var infos []*domain.ProviderModelsInfo
+	if code := f.get(t, f.scopedToken, "/admin/providers/models", &infos); code != http.StatusOK {
+		t.Fatalf("models status = %d", code)
+	}
+	if len(infos) != 1 || infos[0].ProviderID != f.ownProviderID {
+		t.Errorf("scoped model lists = %+v, want only the own provider's", infos)
+	}
+	if code := f.get(t, f.scopedToken, "/admin/providers/"+itoa(f.otherProviderID)+"/models", nil); code != http.StatusNotFound {
+		t.Errorf("reading another project's provider models: status = %d, want 404", code)
+	}
+
+	if code := f.get(t, f.adminToken, "/admin/providers/models", &infos); code != http.StatusOK || len(infos) != 2 {
+		t.Errorf("unscoped model lists = %d (status %d), want 2", len(infos), code)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/awsl-project/maxx/internal/service"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -20,9 +21,11 @@ const (
 	TokenExpiry = 7 * 24 * time.Hour // 7 days
 )
 
-// AuthMiddleware provides JWT authentication for admin API
+// AuthMiddleware provides JWT authentication for admin API.
+// API tokens with admin access are accepted as well, scoped to their project.
 type AuthMiddleware struct {
-	password string
+	password  string
+	tokenAuth *TokenAuthMiddleware
 }
 
 // NewAuthMiddleware creates a new auth middleware
@@ -32,6 +35,11 @@ func NewAuthMiddleware() *AuthMiddleware {
 	}
 }
 
+// SetTokenAuth lets API tokens with admin access authenticate against the admin API
+func (m *AuthMiddleware) SetTokenAuth(tokenAuth *TokenAuthMiddleware) {
+	m.tokenAuth = tokenAuth
+}
+
 // IsEnabled returns true if authentication is enabled
 func (m *AuthMiddleware) IsEnabled() bool {
 	return m.password != ""
@@ -64,18 +72,37 @@ func (m *AuthMiddleware) ValidateToken(tokenString string) bool {
 // Wrap wraps a handler with JWT authentication
 func (m *AuthMiddleware) Wrap(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get(AuthHeader)
+		token := strings.TrimPrefix(authHeader, "Bearer ")
+
+		// API tokens are checked even when password auth is disabled, so a
+		// project-scoped token never gets more than its project
+		if m.tokenAuth != nil && strings.HasPrefix(authHeader, "Bearer ") && strings.HasPrefix(token, TokenPrefix) {
+			apiToken, err := m.tokenAuth.LookupToken(token)
+			if err != nil || !apiToken.AdminAccess {
+				writeUnauthorized(w)
+				return
+			}
+			// A token without a project only reaches all data when granted explicitly
+			if apiToken.ProjectID == 0 && !apiToken.IsGlobalAdmin() {
+				writeUnauthorized(w)
+				return
+			}
+			scope := service.AdminScope{ProjectID: apiToken.ProjectID}
+			next.ServeHTTP(w, r.WithContext(WithAdminScope(r.Context(), scope)))
+			return
+		}
+
 		if !m.IsEnabled() {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		authHeader := r.Header.Get(AuthHeader)
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
 			writeUnauthorized(w)
 			return
 		}
 
-		token := strings.TrimPrefix(authHeader, "Bearer ")
 		if !m.ValidateToken(token) {
 			writeUnauthorized(w)
 			return
@@ -0,0 +1,451 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/batch"
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/limiter"
+	"github.com/awsl-project/maxx/internal/repository"
+	"github.com/awsl-project/maxx/internal/repository/cached"
+)
+
+const (
+	batchPathPrefix      = "/v1/messages/batches"
+	maxBatchRequests     = 100000
+	maxBatchCustomIDLen  = 64
+	defaultBatchPageSize = 20
+	maxBatchPageSize     = 1000
+)
+
+// batchForwardedHeaders are the client headers stored with a batch and sent with every item
+var batchForwardedHeaders = []string{"anthropic-version", "anthropic-beta", "User-Agent"}
+
+// BatchHandler implements the Anthropic Message Batches API (/v1/messages/batches).
+// Batches are persisted and their items executed asynchronously by batch.Processor.
+type BatchHandler struct {
+	repo        repository.MessageBatchRepository
+	sessionRepo *cached.SessionRepository
+	tokenAuth   *TokenAuthMiddleware
+	processor   *batch.Processor
+}
+
+// NewBatchHandler creates a new Message Batches handler
+func NewBatchHandler(
+	repo repository.MessageBatchRepository,
+	sessionRepo *cached.SessionRepository,
+	tokenAuth *TokenAuthMiddleware,
+	processor *batch.Processor,
+) *BatchHandler {
+	return &BatchHandler{
+		repo:        repo,
+		sessionRepo: sessionRepo,
+		tokenAuth:   tokenAuth,
+		processor:   processor,
+	}
+}
+
+// isBatchPath reports whether the path belongs to the Message Batches API
+func isBatchPath(path string) bool {
+	return path == batchPathPrefix || strings.HasPrefix(path, batchPathPrefix+"/")
+}
+
+// ServeHTTP routes Message Batches requests
+func (h *BatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Batch] Received request: %s %s", r.Method, r.URL.Path)
+
+	var apiToken *domain.APIToken
+	if h.tokenAuth != nil {
+		var err error
+		apiToken, err = h.tokenAuth.ValidateRequest(r, domain.ClientTypeClaude)
+		if err != nil {
+			log.Printf("[Batch] Token auth failed: %v", err)
+			writeAnthropicError(w, http.StatusUnauthorized, "authentication_error", err.Error())
+			return
+		}
+	}
+
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, batchPathPrefix), "/")
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+
+	switch {
+	case path == "":
+		switch r.Method {
+		case http.MethodPost:
+			h.handleCreate(w, r, apiToken)
+		case http.MethodGet:
+			h.handleList(w, r, apiToken)
+		default:
+			writeAnthropicError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		}
+	case len(parts) == 1:
+		switch r.Method {
+		case http.MethodGet:
+			if b := h.lookup(w, parts[0], apiToken); b != nil {
+				writeJSON(w, http.StatusOK, toBatchObject(r, b))
+			}
+		case http.MethodDelete:
+			h.handleDelete(w, parts[0], apiToken)
+		default:
+			writeAnthropicError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		}
+	case len(parts) == 2 && parts[1] == "cancel":
+		if r.Method != http.MethodPost {
+			writeAnthropicError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		h.handleCancel(w, r, parts[0], apiToken)
+	case len(parts) == 2 && parts[1] == "results":
+		if r.Method != http.MethodGet {
+			writeAnthropicError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		h.handleResults(w, parts[0], apiToken)
+	default:
+		writeAnthropicError(w, http.StatusNotFound, "not_found_error", "not found")
+	}
+}
+
+// handleCreate handles POST /v1/messages/batches
+func (h *BatchHandler) handleCreate(w http.ResponseWriter, r *http.Request, apiToken *domain.APIToken) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		if isBodyTooLarge(err) {
+			writeBatchTooLarge(w, limiter.MaxBatchBodyBytes())
+			return
+		}
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", "failed to read request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req struct {
+		Requests []struct {
+			CustomID string          `json:"custom_id"`
+			Params   json.RawMessage `json:"params"`
+		} `json:"requests"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", "invalid request body: "+err.Error())
+		return
+	}
+	if len(req.Requests) == 0 {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", "requests: must contain at least one request")
+		return
+	}
+	if len(req.Requests) > maxBatchRequests {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("requests: must contain at most %d requests", maxBatchRequests))
+		return
+	}
+
+	items := make([]*domain.MessageBatchItem, 0, len(req.Requests))
+	seen := make(map[string]bool, len(req.Requests))
+	for i, entry := range req.Requests {
+		if entry.CustomID == "" || len(entry.CustomID) > maxBatchCustomIDLen {
+			writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("requests.%d.custom_id: must be 1 to %d characters", i, maxBatchCustomIDLen))
+			return
+		}
+		if seen[entry.CustomID] {
+			writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("requests.%d.custom_id: duplicate custom_id %q", i, entry.CustomID))
+			return
+		}
+		seen[entry.CustomID] = true
+
+		params, err := normalizeBatchParams(entry.Params)
+		if err != nil {
+			writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("requests.%d.params: %v", i, err))
+			return
+		}
+		items = append(items, &domain.MessageBatchItem{
+			CustomID: entry.CustomID,
+			Params:   string(params),
+			Status:   domain.MessageBatchItemPending,
+		})
+	}
+
+	var apiTokenID, projectID uint64
+	if apiToken != nil {
+		apiTokenID = apiToken.ID
+		projectID = apiToken.ProjectID
+	}
+	// Header set by ProjectProxyHandler takes priority over the token's project
+	if pidStr := r.Header.Get("X-Maxx-Project-ID"); pidStr != "" {
+		if pid, err := strconv.ParseUint(pidStr, 10, 64); err == nil {
+			projectID = pid
+		}
+	}
+
+	headers := make(map[string]string)
+	for _, key := range batchForwardedHeaders {
+		if v := r.Header.Get(key); v != "" {
+			headers[key] = v
+		}
+	}
+
+	now := time.Now()
+	b := &domain.MessageBatch{
+		BatchID:          generateBatchID(),
+		ProcessingStatus: domain.MessageBatchInProgress,
+		APITokenID:       apiTokenID,
+		ProjectID:        projectID,
+		RequestHeaders:   headers,
+		RequestCounts:    domain.MessageBatchCounts{Processing: uint64(len(items))},
+		ExpiresAt:        now.Add(domain.MessageBatchExpiry),
+	}
+	if err := h.repo.Create(b, items); err != nil {
+		log.Printf("[Batch] Failed to create batch: %v", err)
+		writeAnthropicError(w, http.StatusInternalServerError, "api_error", "failed to create batch")
+		return
+	}
+
+	// All items share one session named after the batch, so its requests can be found together
+	_ = h.sessionRepo.Create(&domain.Session{
+		SessionID:  b.BatchID,
+		ClientType: domain.ClientTypeClaude,
+		ProjectID:  projectID,
+	})
+
+	log.Printf("[Batch] Created %s with %d requests (token=%d, project=%d)", b.BatchID, len(items), apiTokenID, projectID)
+	if h.processor != nil {
+		h.processor.Notify()
+	}
+	writeJSON(w, http.StatusOK, toBatchObject(r, b))
+}
+
+// handleList handles GET /v1/messages/batches
+func (h *BatchHandler) handleList(w http.ResponseWriter, r *http.Request, apiToken *domain.APIToken) {
+	limit := defaultBatchPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxBatchPageSize {
+			writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("limit: must be between 1 and %d", maxBatchPageSize))
+			return
+		}
+		limit = n
+	}
+
+	var before, after uint64
+	for param, target := range map[string]*uint64{"before_id": &before, "after_id": &after} {
+		batchID := r.URL.Query().Get(param)
+		if batchID == "" {
+			continue
+		}
+		b := h.lookup(w, batchID, apiToken)
+		if b == nil {
+			return
+		}
+		*target = b.ID
+	}
+
+	// Fetch one extra record to detect has_more
+	batches, err := h.repo.List(tokenScope(apiToken), limit+1, before, after)
+	if err != nil {
+		writeAnthropicError(w, http.StatusInternalServerError, "api_error", err.Error())
+		return
+	}
+	hasMore := len(batches) > limit
+	if hasMore {
+		batches = batches[:limit]
+	}
+
+	data := make([]map[string]interface{}, len(batches))
+	for i, b := range batches {
+		data[i] = toBatchObject(r, b)
+	}
+	var firstID, lastID interface{}
+	if len(batches) > 0 {
+		firstID = batches[0].BatchID
+		lastID = batches[len(batches)-1].BatchID
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data":     data,
+		"has_more": hasMore,
+		"first_id": firstID,
+		"last_id":  lastID,
+	})
+}
+
+// handleCancel handles POST /v1/messages/batches/{id}/cancel
+func (h *BatchHandler) handleCancel(w http.ResponseWriter, r *http.Request, batchID string, apiToken *domain.APIToken) {
+	b := h.lookup(w, batchID, apiToken)
+	if b == nil {
+		return
+	}
+
+	if b.ProcessingStatus == domain.MessageBatchInProgress {
+		now := time.Now()
+		b.ProcessingStatus = domain.MessageBatchCanceling
+		b.CancelInitiatedAt = &now
+		if err := h.repo.Update(b); err != nil {
+			writeAnthropicError(w, http.StatusInternalServerError, "api_error", err.Error())
+			return
+		}
+		log.Printf("[Batch] Cancel initiated for %s", b.BatchID)
+		if h.processor != nil {
+			h.processor.Notify()
+		}
+	}
+	writeJSON(w, http.StatusOK, toBatchObject(r, b))
+}
+
+// handleResults handles GET /v1/messages/batches/{id}/results
+func (h *BatchHandler) handleResults(w http.ResponseWriter, batchID string, apiToken *domain.APIToken) {
+	b := h.lookup(w, batchID, apiToken)
+	if b == nil {
+		return
+	}
+	if b.ProcessingStatus != domain.MessageBatchEnded {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("batch %s has not ended yet", b.BatchID))
+		return
+	}
+
+	items, err := h.repo.ListItems(b.ID)
+	if err != nil {
+		writeAnthropicError(w, http.StatusInternalServerError, "api_error", err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-jsonl")
+	w.WriteHeader(http.StatusOK)
+	for _, item := range items {
+		result := json.RawMessage(item.Result)
+		if len(result) == 0 {
+			result, _ = json.Marshal(map[string]string{"type": item.Status})
+		}
+		line, _ := json.Marshal(map[string]interface{}{
+			"custom_id": item.CustomID,
+			"result":    result,
+		})
+		w.Write(line)
+		w.Write([]byte("\n"))
+	}
+}
+
+// handleDelete handles DELETE /v1/messages/batches/{id}
+func (h *BatchHandler) handleDelete(w http.ResponseWriter, batchID string, apiToken *domain.APIToken) {
+	b := h.lookup(w, batchID, apiToken)
+	if b == nil {
+		return
+	}
+	if b.ProcessingStatus != domain.MessageBatchEnded {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("batch %s must be ended or canceled before it can be deleted", b.BatchID))
+		return
+	}
+	if err := h.repo.Delete(b.ID); err != nil {
+		writeAnthropicError(w, http.StatusInternalServerError, "api_error", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"id":   b.BatchID,
+		"type": "message_batch_deleted",
+	})
+}
+
+// lookup loads a batch visible to the caller, writing a 404 if there is none
+func (h *BatchHandler) lookup(w http.ResponseWriter, batchID string, apiToken *domain.APIToken) *domain.MessageBatch {
+	b, err := h.repo.GetByBatchID(batchID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		writeAnthropicError(w, http.StatusInternalServerError, "api_error", err.Error())
+		return nil
+	}
+	if b == nil || (apiToken != nil && b.APITokenID != apiToken.ID) {
+		writeAnthropicError(w, http.StatusNotFound, "not_found_error", fmt.Sprintf("message batch %s not found", batchID))
+		return nil
+	}
+	return b
+}
+
+// tokenScope returns the token ID batches are filtered by, 0 when auth is disabled
+func tokenScope(apiToken *domain.APIToken) uint64 {
+	if apiToken == nil {
+		return 0
+	}
+	return apiToken.ID
+}
+
+// normalizeBatchParams validates a request's Messages API params and
+// disables streaming, batch results are always complete messages
+func normalizeBatchParams(raw json.RawMessage) ([]byte, error) {
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &params); err != nil || params == nil {
+		return nil, errors.New("must be a Messages API request object")
+	}
+	var model string
+	if err := json.Unmarshal(params["model"], &model); err != nil || model == "" {
+		return nil, errors.New("model: field required")
+	}
+	if _, ok := params["messages"]; !ok {
+		return nil, errors.New("messages: field required")
+	}
+	delete(params, "stream")
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(params); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// toBatchObject renders a batch in the Anthropic Message Batch format
+func toBatchObject(r *http.Request, b *domain.MessageBatch) map[string]interface{} {
+	obj := map[string]interface{}{
+		"id":                  b.BatchID,
+		"type":                "message_batch",
+		"processing_status":   b.ProcessingStatus,
+		"request_counts":      b.RequestCounts,
+		"created_at":          b.CreatedAt.UTC().Format(time.RFC3339),
+		"expires_at":          b.ExpiresAt.UTC().Format(time.RFC3339),
+		"ended_at":            nil,
+		"cancel_initiated_at": nil,
+		"archived_at":         nil,
+		"results_url":         nil,
+	}
+	if b.CancelInitiatedAt != nil {
+		obj["cancel_initiated_at"] = b.CancelInitiatedAt.UTC().Format(time.RFC3339)
+	}
+	if b.EndedAt != nil {
+		obj["ended_at"] = b.EndedAt.UTC().Format(time.RFC3339)
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		obj["results_url"] = fmt.Sprintf("%s://%s%s/%s/results", scheme, r.Host, batchPathPrefix, b.BatchID)
+	}
+	return obj
+}
+
+// generateBatchID returns a new Anthropic-style batch ID
+func generateBatchID() string {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return "msgbatch_" + hex.EncodeToString(buf)
+}
+
+// writeBatchTooLarge rejects a batch whose body exceeds limit
+func writeBatchTooLarge(w http.ResponseWriter, limit int64) {
+	writeAnthropicError(w, http.StatusRequestEntityTooLarge, "request_too_large", fmt.Sprintf("batch body exceeds the limit of %d bytes", limit))
+}
+
+// writeAnthropicError writes an error in the Anthropic API error format
+func writeAnthropicError(w http.ResponseWriter, status int, errType, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"type": "error",
+		"error": map[string]string{
+			"type":    errType,
+			"message": message,
+		},
+	})
+}
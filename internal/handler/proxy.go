@@ -1,25 +1,51 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/awsl-project/maxx/internal/adapter/client"
 	ctxutil "github.com/awsl-project/maxx/internal/context"
 	"github.com/awsl-project/maxx/internal/domain"
 	"github.com/awsl-project/maxx/internal/executor"
+	"github.com/awsl-project/maxx/internal/limiter"
+	"github.com/awsl-project/maxx/internal/repository"
 	"github.com/awsl-project/maxx/internal/repository/cached"
+	"github.com/awsl-project/maxx/internal/resume"
+	"github.com/awsl-project/maxx/internal/tokenizer"
 )
 
+// ClientTypeHeader lets clients name their request format when the endpoint path doesn't
+const ClientTypeHeader = "X-Maxx-Client-Type"
+
+// ProviderIDHeader sends a request to one provider, bypassing routes and cooldowns. It is
+// for operators testing an upstream and is only honoured with admin credentials: an API
+// token with admin access to all projects, or an admin JWT in AdminTokenHeader.
+const ProviderIDHeader = "X-Maxx-Provider-ID"
+
+// AdminTokenHeader carries the admin JWT authorizing ProviderIDHeader, the Authorization
+// header being taken by the client's API key
+const AdminTokenHeader = "X-Maxx-Admin-Token"
+
 // ProxyHandler handles AI API proxy requests
 type ProxyHandler struct {
 	clientAdapter *client.Adapter
 	executor      *executor.Executor
 	sessionRepo   *cached.SessionRepository
+	projectRepo   repository.ProjectRepository
 	tokenAuth     *TokenAuthMiddleware
+	adminAuth     *AuthMiddleware
+	batchHandler  *BatchHandler
+	limiter       *limiter.Limiter
+	resumes       *resume.Registry
 }
 
 // NewProxyHandler creates a new proxy handler
@@ -34,11 +60,47 @@ func NewProxyHandler(
 		executor:      exec,
 		sessionRepo:   sessionRepo,
 		tokenAuth:     tokenAuth,
+		limiter:       limiter.Global(),
+		resumes:       resume.NewRegistry(0, 0, 0),
 	}
 }
 
+// SetProjectRepo enables project default client types for requests the path and
+// header leave ambiguous
+func (h *ProxyHandler) SetProjectRepo(projectRepo repository.ProjectRepository) {
+	h.projectRepo = projectRepo
+}
+
+// SetAdminAuth lets admin JWTs authorize the X-Maxx-Provider-ID debug bypass
+func (h *ProxyHandler) SetAdminAuth(adminAuth *AuthMiddleware) {
+	h.adminAuth = adminAuth
+}
+
+// SetBatchHandler enables the Message Batches API under /v1/messages/batches
+func (h *ProxyHandler) SetBatchHandler(batchHandler *BatchHandler) {
+	h.batchHandler = batchHandler
+}
+
 // ServeHTTP handles proxy requests
 func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Message Batches API, also reachable through project proxy paths
+	if h.batchHandler != nil && isBatchPath(r.URL.Path) {
+		// Batches carry many requests, they are bounded by the larger batch limit
+		if bodyLimit := limiter.MaxBatchBodyBytes(); bodyLimit > 0 {
+			if r.ContentLength > bodyLimit {
+				writeBatchTooLarge(w, bodyLimit)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, bodyLimit)
+		}
+		h.batchHandler.ServeHTTP(w, r)
+		return
+	}
+	if r.URL.Path == resumePath {
+		h.handleResume(w, r)
+		return
+	}
+
 	log.Printf("[Proxy] Received request: %s %s", r.Method, r.URL.Path)
 
 	if r.Method != http.MethodPost {
@@ -46,15 +108,35 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Claude Desktop / Anthropic compatibility: count_tokens placeholder
+	// Bound the body before buffering it, this protects memory whatever is captured later
+	bodyLimit := limiter.MaxRequestBodyBytes()
+	if bodyLimit > 0 {
+		if r.ContentLength > bodyLimit {
+			h.rejectOversizedBody(w, r, bodyLimit)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, bodyLimit)
+	}
+
+	// Claude Desktop / Anthropic compatibility: count_tokens is answered locally
+	// with a pre-flight estimate from the model family's tokenizer
 	if r.URL.Path == "/v1/messages/count_tokens" {
-		_, _ = io.Copy(io.Discard, r.Body)
+		body, err := io.ReadAll(r.Body)
 		_ = r.Body.Close()
+		if isBodyTooLarge(err) {
+			h.rejectOversizedBody(w, r, bodyLimit)
+			return
+		}
+
+		var req struct {
+			Model string `json:"model"`
+		}
+		_ = json.Unmarshal(body, &req)
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		_ = json.NewEncoder(w).Encode(map[string]interface{}{
-			"input_tokens":  0,
+			"input_tokens":  tokenizer.CountJSON(req.Model, body),
 			"output_tokens": 0,
 		})
 		return
@@ -62,21 +144,28 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Read body
 	body, err := io.ReadAll(r.Body)
+	if isBodyTooLarge(err) {
+		h.rejectOversizedBody(w, r, bodyLimit)
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "failed to read request body")
 		return
 	}
 	defer r.Body.Close()
 
-	// Detect client type and extract info
-	clientType := h.clientAdapter.DetectClientType(r, body)
-	log.Printf("[Proxy] Detected client type: %s", clientType)
-	if clientType == "" {
-		writeError(w, http.StatusBadRequest, "unable to detect client type")
+	// Detect client type, first match wins:
+	//   1. endpoint path (/v1/messages, /v1/chat/completions, ...)
+	//   2. X-Maxx-Client-Type header
+	//   3. API token default, then project default (needs the token, so after auth)
+	//   4. body shape heuristic
+	clientType, err := requestedClientType(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Token authentication (uses clientType for primary header, with fallback)
+	// Token authentication (uses clientType for primary header when known, with fallback)
 	var apiToken *domain.APIToken
 	var apiTokenID uint64
 	if h.tokenAuth != nil {
@@ -92,6 +181,33 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Admin debug bypass, the headers never reach the record or the upstream
+	forcedProviderID, err := h.forcedProviderID(r, apiToken)
+	if err != nil {
+		log.Printf("[Proxy] Forced provider rejected: %v", err)
+		status := http.StatusForbidden
+		if errors.Is(err, errInvalidProviderID) {
+			status = http.StatusBadRequest
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+
+	if clientType == "" {
+		clientType = h.defaultClientType(r, apiToken)
+	}
+	if clientType == "" {
+		clientType = h.clientAdapter.DetectClientTypeFromBody(body)
+	}
+	log.Printf("[Proxy] Detected client type: %s", clientType)
+	if clientType == "" {
+		writeError(w, http.StatusBadRequest, "unable to detect client type")
+		return
+	}
+
+	// Strip the skip-mapping flag so it never reaches the upstream
+	skipMapping := popSkipModelMapping(r.URL)
+
 	requestModel := h.clientAdapter.ExtractModel(r, body, clientType)
 	log.Printf("[Proxy] Extracted model: %s (path: %s)", requestModel, r.URL.Path)
 	sessionID := h.clientAdapter.ExtractSessionID(r, body, clientType)
@@ -107,6 +223,11 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx = ctxutil.WithRequestURI(ctx, r.URL.RequestURI())
 	ctx = ctxutil.WithIsStream(ctx, stream)
 	ctx = ctxutil.WithAPITokenID(ctx, apiTokenID)
+	ctx = ctxutil.WithSkipModelMapping(ctx, skipMapping)
+	if forcedProviderID != 0 {
+		log.Printf("[Proxy] Request forced to provider %d by %s", forcedProviderID, ProviderIDHeader)
+		ctx = ctxutil.WithForcedProviderID(ctx, forcedProviderID)
+	}
 
 	// Check for project ID from header (set by ProjectProxyHandler)
 	var projectID uint64
@@ -145,15 +266,70 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	ctx = ctxutil.WithProjectID(ctx, projectID)
 
+	// Requests without a model get the project's default model or are turned away
+	if requestModel == "" {
+		var missingErr error
+		if ctx, missingErr = h.applyMissingModel(ctx, clientType, projectID); missingErr != nil {
+			log.Printf("[Proxy] Request rejected: %v", missingErr)
+			h.executor.RecordRejected(ctx, r, missingErr.Error())
+			writeErrorType(w, http.StatusBadRequest, missingErr.Error(), "invalid_request_error")
+			return
+		}
+	}
+
+	// Global concurrency limit, protects the process from being overwhelmed by a spike
+	slot, err := h.limiter.AcquireSlot(ctx)
+	if err != nil {
+		log.Printf("[Proxy] Request rejected by concurrency limit: %v", err)
+		h.executor.RecordRejected(ctx, r, err.Error())
+		if ctx.Err() != nil {
+			return // client gave up while queued
+		}
+		sec := int64(h.limiter.QueueTimeout().Seconds())
+		if sec <= 0 {
+			sec = 1
+		}
+		w.Header().Set("Retry-After", strconv.FormatInt(sec, 10))
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	defer slot.Release()
+	ctx = limiter.WithSlot(ctx, slot)
+
+	// Resumable streams keep running when the client drops, so it can reconnect
+	// with the resume token and Last-Event-ID and catch up
+	if stream && r.Header.Get(resume.HeaderResumable) == "true" {
+		var cancel context.CancelCauseFunc
+		ctx, cancel = context.WithCancelCause(context.WithoutCancel(ctx))
+		defer cancel(nil)
+		rs := h.resumes.Start(w, clientType, apiTokenID, func() { cancel(domain.CancelReasonResumeAbandoned) })
+		defer rs.Finish()
+		go rs.WatchClient(r.Context())
+		w = rs
+	}
+
 	// Execute request (executor handles request recording, project binding, routing, etc.)
 	err = h.executor.Execute(ctx, w, r)
 	if err != nil {
+		var reason domain.CancelReason
 		proxyErr, ok := err.(*domain.ProxyError)
-		if ok {
+		if errors.As(err, &reason) {
+			// Cancelled by the proxy (admin, shutdown), the client is still there to be told why
+			writeCancelled(w, reason, stream)
+		} else if ok && errors.Is(err, domain.ErrPolicyViolation) {
+			writeErrorType(w, http.StatusBadRequest, proxyErr.Message, "policy_violation")
+		} else if ok && errors.Is(err, domain.ErrTokenRoutesDenied) {
+			writeErrorType(w, http.StatusForbidden, proxyErr.Message, "permission_error")
+		} else if ok && errors.Is(err, domain.ErrGuardrailFailed) {
+			writeErrorType(w, http.StatusServiceUnavailable, proxyErr.Message, "guardrail_error")
+		} else if ok && errors.Is(err, domain.ErrMissingModel) {
+			// No matching route has a default model for the request
+			writeErrorType(w, http.StatusBadRequest, domain.ErrMissingModel.Error(), "invalid_request_error")
+		} else if ok {
 			if stream {
-				writeStreamError(w, proxyErr)
+				writeStreamError(w, proxyErr, clientType)
 			} else {
-				writeProxyError(w, proxyErr)
+				writeProxyError(w, proxyErr, clientType)
 			}
 		} else {
 			writeError(w, http.StatusInternalServerError, err.Error())
@@ -163,18 +339,161 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // Helper functions
 
+// applyMissingModel sends a request without a model with the project's default
+// model. Without one the request goes on to the routes, which use their own default
+// model or are skipped; the error is domain.ErrMissingModel when such requests are
+// rejected.
+func (h *ProxyHandler) applyMissingModel(ctx context.Context, clientType domain.ClientType, projectID uint64) (context.Context, error) {
+	model, err := h.executor.ResolveMissingModel(projectID)
+	if err != nil || model == "" {
+		return ctx, err
+	}
+	body, uri, err := client.SetModel(clientType, ctxutil.GetRequestURI(ctx), ctxutil.GetRequestBody(ctx), model)
+	if err != nil {
+		return ctx, domain.ErrMissingModel
+	}
+	log.Printf("[Proxy] Request without a model sent with the default model %s of project %d", model, projectID)
+
+	ctx = ctxutil.WithRequestBody(ctx, body)
+	ctx = ctxutil.WithRequestURI(ctx, uri)
+	ctx = ctxutil.WithRequestModel(ctx, model)
+	return ctxutil.WithModelDefaulted(ctx, true), nil
+}
+
+// rejectOversizedBody answers 413 to a request whose body is over limit and records it
+func (h *ProxyHandler) rejectOversizedBody(w http.ResponseWriter, r *http.Request, limit int64) {
+	reason := fmt.Sprintf("request body exceeds the limit of %d bytes", limit)
+	log.Printf("[Proxy] Request rejected: %s (%s %s)", reason, r.Method, r.URL.Path)
+
+	ctx := r.Context()
+	if clientType, err := requestedClientType(r); err == nil {
+		ctx = ctxutil.WithClientType(ctx, clientType)
+	}
+	ctx = ctxutil.WithRequestHeaders(ctx, r.Header)
+	ctx = ctxutil.WithRequestURI(ctx, r.URL.RequestURI())
+	h.executor.RecordRejected(ctx, r, reason)
+
+	writeError(w, http.StatusRequestEntityTooLarge, reason)
+}
+
+// isBodyTooLarge reports whether reading the body failed on the size limit
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// requestedClientType returns the client type named by the endpoint path, or else by
+// the X-Maxx-Client-Type header. It is empty when neither identifies the format and
+// fails on an unknown header value.
+func requestedClientType(r *http.Request) (domain.ClientType, error) {
+	if clientType := client.DetectClientTypeFromPath(r.URL.Path); clientType != "" {
+		return clientType, nil
+	}
+	value := r.Header.Get(ClientTypeHeader)
+	if value == "" {
+		return "", nil
+	}
+	clientType := client.ParseClientType(value)
+	if clientType == "" {
+		return "", fmt.Errorf("invalid %s header %q", ClientTypeHeader, value)
+	}
+	return clientType, nil
+}
+
+var (
+	errInvalidProviderID    = errors.New("invalid " + ProviderIDHeader + " header")
+	errForcedProviderDenied = errors.New(ProviderIDHeader + " requires admin credentials")
+)
+
+// forcedProviderID returns the provider an admin forced with ProviderIDHeader, 0 when
+// the header is absent. Both debug headers are removed from the request.
+func (h *ProxyHandler) forcedProviderID(r *http.Request, apiToken *domain.APIToken) (uint64, error) {
+	value := r.Header.Get(ProviderIDHeader)
+	adminToken := strings.TrimPrefix(r.Header.Get(AdminTokenHeader), "Bearer ")
+	r.Header.Del(ProviderIDHeader)
+	r.Header.Del(AdminTokenHeader)
+	if value == "" {
+		return 0, nil
+	}
+	providerID, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+	if err != nil || providerID == 0 {
+		return 0, errInvalidProviderID
+	}
+
+	// A project-scoped admin token must not reach providers outside its routes
+	if apiToken != nil && apiToken.IsGlobalAdmin() {
+		return providerID, nil
+	}
+	// Without an admin password every JWT signed with the empty key would validate
+	if h.adminAuth != nil && h.adminAuth.IsEnabled() && adminToken != "" && h.adminAuth.ValidateToken(adminToken) {
+		return providerID, nil
+	}
+	return 0, errForcedProviderDenied
+}
+
+// defaultClientType returns the configured default client type for the request: the
+// API token's, then that of the project the request is routed through (set by the
+// project proxy) or the token belongs to
+func (h *ProxyHandler) defaultClientType(r *http.Request, apiToken *domain.APIToken) domain.ClientType {
+	if apiToken != nil && apiToken.DefaultClientType != "" {
+		return apiToken.DefaultClientType
+	}
+	if h.projectRepo == nil {
+		return ""
+	}
+	var projectID uint64
+	if pid, err := strconv.ParseUint(r.Header.Get("X-Maxx-Project-ID"), 10, 64); err == nil {
+		projectID = pid
+	} else if apiToken != nil {
+		projectID = apiToken.ProjectID
+	}
+	if projectID == 0 {
+		return ""
+	}
+	project, err := h.projectRepo.GetByID(projectID)
+	if err != nil {
+		return ""
+	}
+	return project.DefaultClientType
+}
+
+// popSkipModelMapping removes the skip-mapping query parameter from u, keeping the
+// other parameters in order, and reports whether it was set. A bare parameter counts as true.
+func popSkipModelMapping(u *url.URL) bool {
+	if u.RawQuery == "" {
+		return false
+	}
+	skip := false
+	var kept []string
+	for _, param := range strings.Split(u.RawQuery, "&") {
+		key, value, _ := strings.Cut(param, "=")
+		if key != executor.QuerySkipModelMapping {
+			kept = append(kept, param)
+			continue
+		}
+		enabled, err := strconv.ParseBool(value)
+		skip = value == "" || (err == nil && enabled)
+	}
+	u.RawQuery = strings.Join(kept, "&")
+	return skip
+}
+
 func writeError(w http.ResponseWriter, status int, message string) {
+	writeErrorType(w, status, message, "proxy_error")
+}
+
+func writeErrorType(w http.ResponseWriter, status int, message, errType string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"error": map[string]interface{}{
 			"message": message,
-			"type":    "proxy_error",
+			"type":    errType,
 		},
 	})
 }
 
-func writeProxyError(w http.ResponseWriter, err *domain.ProxyError) {
+func writeProxyError(w http.ResponseWriter, err *domain.ProxyError, clientType domain.ClientType) {
 	w.Header().Set("Content-Type", "application/json")
 	if err.RetryAfter > 0 {
 		sec := int64(err.RetryAfter.Seconds())
@@ -183,17 +502,17 @@ func writeProxyError(w http.ResponseWriter, err *domain.ProxyError) {
 		}
 		w.Header().Set("Retry-After", strconv.FormatInt(sec, 10))
 	}
-	w.WriteHeader(http.StatusBadGateway)
+	status := http.StatusBadGateway
+	if err.ClientStatusCode != 0 {
+		status = err.ClientStatusCode
+	}
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": map[string]interface{}{
-			"message":   err.Error(),
-			"type":      "upstream_error",
-			"retryable": err.Retryable,
-		},
+		"error": proxyErrorBody(err, clientType),
 	})
 }
 
-func writeStreamError(w http.ResponseWriter, err *domain.ProxyError) {
+func writeStreamError(w http.ResponseWriter, err *domain.ProxyError, clientType domain.ClientType) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	if err.RetryAfter > 0 {
@@ -206,12 +525,8 @@ func writeStreamError(w http.ResponseWriter, err *domain.ProxyError) {
 	w.WriteHeader(http.StatusOK)
 
 	errorEvent := map[string]interface{}{
-		"type": "error",
-		"error": map[string]interface{}{
-			"message":   err.Error(),
-			"type":      "upstream_error",
-			"retryable": err.Retryable,
-		},
+		"type":  "error",
+		"error": proxyErrorBody(err, clientType),
 	}
 	data, _ := json.Marshal(errorEvent)
 	w.Write([]byte("data: "))
@@ -222,3 +537,86 @@ func writeStreamError(w http.ResponseWriter, err *domain.ProxyError) {
 		f.Flush()
 	}
 }
+
+// writeCancelled tells the client its request was cancelled by the proxy and why.
+// Streams get an SSE error event after whatever was already sent.
+func writeCancelled(w http.ResponseWriter, reason domain.CancelReason, stream bool) {
+	body := map[string]interface{}{
+		"type": "error",
+		"error": map[string]interface{}{
+			"type":    "request_cancelled",
+			"reason":  reason,
+			"message": reason.Error(),
+		},
+	}
+	if !stream {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(body)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream") // no effect once the stream started
+	data, _ := json.Marshal(body)
+	w.Write([]byte("event: error\ndata: "))
+	w.Write(data)
+	w.Write([]byte("\n\n"))
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// proxyErrorBody builds the error object sent to the client. When all routes failed the
+// message summarizes every route tried, and the structured summary goes where the
+// client's SDK exposes it: error.details for Gemini, error.routes otherwise.
+func proxyErrorBody(err *domain.ProxyError, clientType domain.ClientType) map[string]interface{} {
+	body := map[string]interface{}{
+		"message":   err.Error(),
+		"type":      "upstream_error",
+		"retryable": err.Retryable,
+	}
+	if len(err.RouteFailures) == 0 {
+		return body
+	}
+
+	body["message"] = "all routes failed: " + summarizeRouteFailures(err.RouteFailures)
+	if clientType == domain.ClientTypeGemini {
+		body["code"] = http.StatusBadGateway
+		body["status"] = "UNAVAILABLE"
+		body["details"] = []interface{}{
+			map[string]interface{}{
+				"@type":  "type.googleapis.com/maxx.RouteFailures",
+				"routes": err.RouteFailures,
+			},
+		}
+	} else {
+		body["routes"] = err.RouteFailures
+	}
+	return body
+}
+
+// summarizeRouteFailures renders the summary on one line, e.g.
+// "#1 provider-a: upstream error (429, 3 attempts); #2: network error"
+func summarizeRouteFailures(failures []domain.RouteFailure) string {
+	parts := make([]string, len(failures))
+	for i, f := range failures {
+		var b strings.Builder
+		fmt.Fprintf(&b, "#%d", f.Position)
+		if f.ProviderName != "" {
+			b.WriteString(" " + f.ProviderName)
+		}
+		b.WriteString(": " + f.Error)
+		var notes []string
+		if f.StatusCode != 0 {
+			notes = append(notes, strconv.Itoa(f.StatusCode))
+		}
+		if f.Attempts > 1 {
+			notes = append(notes, fmt.Sprintf("%d attempts", f.Attempts))
+		}
+		if len(notes) > 0 {
+			b.WriteString(" (" + strings.Join(notes, ", ") + ")")
+		}
+		parts[i] = b.String()
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,448 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/adapter/client"
+	ctxutil "github.com/awsl-project/maxx/internal/context"
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/executor"
+	"github.com/awsl-project/maxx/internal/limiter"
+	"github.com/awsl-project/maxx/internal/repository"
+	"github.com/awsl-project/maxx/internal/repository/cached"
+	"github.com/awsl-project/maxx/internal/repository/sqlite"
+)
+
+func TestWriteProxyErrorRouteFailures(t *testing.T) {
+	err := &domain.ProxyError{
+		Err: domain.ErrUpstreamError,
+		RouteFailures: []domain.RouteFailure{
+			{Position: 1, ProviderName: "primary", Attempts: 3, StatusCode: 429, Error: "upstream error"},
+			{Position: 2, Attempts: 1, Error: "network error"},
+		},
+	}
+
+	for _, clientType := range []domain.ClientType{domain.ClientTypeClaude, domain.ClientTypeOpenAI, domain.ClientTypeGemini} {
+		rec := httptest.NewRecorder()
+		writeProxyError(rec, err, clientType)
+
+		var body struct {
+			Error struct {
+				Message string                `json:"message"`
+				Routes  []domain.RouteFailure `json:"routes"`
+				Details []struct {
+					Routes []domain.RouteFailure `json:"routes"`
+				} `json:"details"`
+			} `json:"error"`
+		}
+		if e := json.Unmarshal(rec.Body.Bytes(), &body); e != nil {
+			t.Fatalf("%s: invalid body: %v", clientType, e)
+		}
+
+		const wantMessage = "all routes failed: #1 primary: upstream error (429, 3 attempts); #2: network error"
+		if body.Error.Message != wantMessage {
+			t.Errorf("%s: message = %q, want %q", clientType, body.Error.Message, wantMessage)
+		}
+		routes := body.Error.Routes
+		if clientType == domain.ClientTypeGemini {
+			if len(body.Error.Details) != 1 {
+				t.Fatalf("gemini: got %d details, want 1", len(body.Error.Details))
+			}
+			routes = body.Error.Details[0].Routes
+		}
+		if len(routes) != 2 || routes[0].ProviderName != "primary" {
+			t.Errorf("%s: routes = %+v", clientType, routes)
+		}
+	}
+
+	// Without a summary the error is written as before
+	rec := httptest.NewRecorder()
+	writeProxyError(rec, domain.NewProxyErrorWithMessage(domain.ErrUpstreamError, true, "boom"), domain.ClientTypeClaude)
+	if strings.Contains(rec.Body.String(), "routes") || !strings.Contains(rec.Body.String(), "boom: upstream error") {
+		t.Errorf("plain error body = %s", rec.Body.String())
+	}
+}
+
+func TestWriteProxyErrorClientStatus(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeProxyError(rec, &domain.ProxyError{Err: domain.ErrUpstreamError, HTTPStatusCode: 529}, domain.ClientTypeClaude)
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	writeProxyError(rec, &domain.ProxyError{Err: domain.ErrUpstreamError, HTTPStatusCode: 529, ClientStatusCode: 503}, domain.ClientTypeClaude)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("mapped status = %d, want 503", rec.Code)
+	}
+}
+
+func TestWriteCancelled(t *testing.T) {
+	// A stream already under way gets an error event naming the reason
+	rec := httptest.NewRecorder()
+	rec.WriteString("event: message_start\ndata: {}\n\n")
+	writeCancelled(rec, domain.CancelReasonShutdown, true)
+	events := strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n")
+	last := events[len(events)-1]
+	if len(events) != 2 || !strings.HasPrefix(last, "event: error\ndata: ") {
+		t.Fatalf("stream = %q", rec.Body.String())
+	}
+	var event struct {
+		Error struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(last, "event: error\ndata: ")), &event); err != nil {
+		t.Fatalf("invalid event: %v", err)
+	}
+	if event.Error.Type != "request_cancelled" || event.Error.Reason != "shutdown" {
+		t.Errorf("event = %+v", event)
+	}
+
+	rec = httptest.NewRecorder()
+	writeCancelled(rec, domain.CancelReasonAdmin, false)
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"reason":"admin"`) {
+		t.Errorf("response = %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestPopSkipModelMapping(t *testing.T) {
+	tests := []struct {
+		query     string
+		skip      bool
+		remaining string
+	}{
+		{"alt=sse&maxx_skip_mapping=true&key=k", true, "alt=sse&key=k"},
+		{"maxx_skip_mapping", true, ""},
+		{"maxx_skip_mapping=1", true, ""},
+		{"maxx_skip_mapping=false&alt=sse", false, "alt=sse"},
+		{"alt=sse", false, "alt=sse"},
+		{"", false, ""},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse("/v1beta/models/gemini-2.5-pro:streamGenerateContent?" + tt.query)
+		if got := popSkipModelMapping(u); got != tt.skip {
+			t.Errorf("%q: skip = %v, want %v", tt.query, got, tt.skip)
+		}
+		if u.RawQuery != tt.remaining {
+			t.Errorf("%q: remaining query = %q, want %q", tt.query, u.RawQuery, tt.remaining)
+		}
+	}
+}
+
+func TestRequestedClientType(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		header  string
+		want    domain.ClientType
+		wantErr bool
+	}{
+		{"path", "/v1/messages", "", domain.ClientTypeClaude, false},
+		{"path wins over header", "/v1/chat/completions", "claude", domain.ClientTypeOpenAI, false},
+		{"header on ambiguous path", "/custom/generate", "Claude", domain.ClientTypeClaude, false},
+		{"ambiguous without header", "/custom/generate", "", "", false},
+		{"unknown header value", "/custom/generate", "anthropic", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", tt.path, nil)
+			if tt.header != "" {
+				r.Header.Set(ClientTypeHeader, tt.header)
+			}
+			got, err := requestedClientType(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("client type = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type stubProjectRepo struct {
+	repository.ProjectRepository
+	projects map[uint64]*domain.Project
+}
+
+func (r *stubProjectRepo) GetByID(id uint64) (*domain.Project, error) {
+	if p, ok := r.projects[id]; ok {
+		return p, nil
+	}
+	return nil, domain.ErrNotFound
+}
+
+func TestDefaultClientType(t *testing.T) {
+	h := &ProxyHandler{projectRepo: &stubProjectRepo{projects: map[uint64]*domain.Project{
+		1: {ID: 1, DefaultClientType: domain.ClientTypeClaude},
+		2: {ID: 2, DefaultClientType: domain.ClientTypeGemini},
+		3: {ID: 3},
+	}}}
+
+	tests := []struct {
+		name      string
+		token     *domain.APIToken
+		projectID string // X-Maxx-Project-ID set by the project proxy
+		want      domain.ClientType
+	}{
+		{"token default", &domain.APIToken{DefaultClientType: domain.ClientTypeCodex, ProjectID: 1}, "", domain.ClientTypeCodex},
+		{"token default wins over routed project", &domain.APIToken{DefaultClientType: domain.ClientTypeCodex}, "2", domain.ClientTypeCodex},
+		{"token project default", &domain.APIToken{ProjectID: 1}, "", domain.ClientTypeClaude},
+		{"routed project wins over token project", &domain.APIToken{ProjectID: 1}, "2", domain.ClientTypeGemini},
+		{"routed project without token", nil, "2", domain.ClientTypeGemini},
+		{"project without default", &domain.APIToken{ProjectID: 3}, "", ""},
+		{"unknown project", &domain.APIToken{ProjectID: 9}, "", ""},
+		{"no token or project", nil, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/custom/generate", nil)
+			if tt.projectID != "" {
+				r.Header.Set("X-Maxx-Project-ID", tt.projectID)
+			}
+			if got := h.defaultClientType(r, tt.token); got != tt.want {
+				t.Errorf("client type = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientTypeBodyFallback(t *testing.T) {
+	// Body shape is the last resort, after path, header and configured defaults
+	a := client.NewAdapter()
+	tests := []struct {
+		body string
+		want domain.ClientType
+	}{
+		{`{"model":"claude-sonnet-4","system":"be brief","messages":[]}`, domain.ClientTypeClaude},
+		{`{"model":"gpt-4o","messages":[]}`, domain.ClientTypeOpenAI},
+		{`{"model":"gpt-5","input":"hi"}`, domain.ClientTypeCodex},
+		{`{"contents":[]}`, domain.ClientTypeGemini},
+		{`{"prompt":"hi"}`, ""},
+	}
+	for _, tt := range tests {
+		if got := a.DetectClientTypeFromBody([]byte(tt.body)); got != tt.want {
+			t.Errorf("%s: client type = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestOversizedBatchBodyRejected(t *testing.T) {
+	oldRequest, oldBatch := limiter.MaxRequestBodyBytes(), limiter.MaxBatchBodyBytes()
+	limiter.SetMaxRequestBodyBytes(1024)
+	limiter.SetMaxBatchBodyBytes(4096)
+	t.Cleanup(func() {
+		limiter.SetMaxRequestBodyBytes(oldRequest)
+		limiter.SetMaxBatchBodyBytes(oldBatch)
+	})
+
+	h := NewProxyHandler(client.NewAdapter(), nil, nil, nil)
+	h.SetBatchHandler(NewBatchHandler(nil, nil, nil, nil))
+
+	oversized := `{"requests":[` + strings.Repeat(" ", 8192) + `]}`
+	tests := []struct {
+		name string
+		body io.Reader
+		want int
+	}{
+		{"content length", strings.NewReader(oversized), http.StatusRequestEntityTooLarge},
+		{"chunked", io.MultiReader(strings.NewReader(oversized)), http.StatusRequestEntityTooLarge},
+		// Over the request limit, within the batch limit
+		{"within batch limit", strings.NewReader(`{"requests":[` + strings.Repeat(" ", 2048) + `]}`), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/messages/batches", tt.body)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestOversizedRequestBodyRejected(t *testing.T) {
+	old := limiter.MaxRequestBodyBytes()
+	limiter.SetMaxRequestBodyBytes(1024)
+	t.Cleanup(func() { limiter.SetMaxRequestBodyBytes(old) })
+
+	db, err := sqlite.NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestRepo := sqlite.NewProxyRequestRepository(db)
+	exec := executor.NewExecutor(nil, requestRepo, nil, nil, nil, nil, nil, nil, nil, nil, "test", nil)
+	h := NewProxyHandler(client.NewAdapter(), exec, nil, nil)
+
+	oversized := `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"` + strings.Repeat("x", 2048) + `"}]}`
+	tests := []struct {
+		name string
+		body io.Reader
+	}{
+		// Declared length, rejected before reading anything
+		{"content length", strings.NewReader(oversized)},
+		// Chunked body, rejected once reading passes the limit
+		{"chunked", io.MultiReader(strings.NewReader(oversized))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/messages", tt.body)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			if rec.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("status = %d, want 413: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	reqs, err := requestRepo.List(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != len(tests) {
+		t.Fatalf("recorded %d requests, want %d", len(reqs), len(tests))
+	}
+	for _, req := range reqs {
+		if req.Status != "REJECTED" || req.ClientType != domain.ClientTypeClaude || !strings.Contains(req.Error, "exceeds the limit") {
+			t.Errorf("recorded %s %s %q, want a rejected claude request", req.Status, req.ClientType, req.Error)
+		}
+	}
+}
+
+func TestForcedProviderIDRequiresAdmin(t *testing.T) {
+	auth := &AuthMiddleware{password: "secret"}
+	adminJWT, err := auth.GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherJWT, _ := (&AuthMiddleware{password: "other"}).GenerateToken()
+
+	tests := []struct {
+		name      string
+		adminAuth *AuthMiddleware
+		token     *domain.APIToken
+		headers   map[string]string
+		want      uint64
+		wantErr   error
+	}{
+		{"no header", auth, nil, nil, 0, nil},
+		{"no credentials", auth, nil, map[string]string{ProviderIDHeader: "3"}, 0, errForcedProviderDenied},
+		{"proxy token", auth, &domain.APIToken{}, map[string]string{ProviderIDHeader: "3"}, 0, errForcedProviderDenied},
+		{"project admin token", auth, &domain.APIToken{AdminAccess: true, ProjectID: 7}, map[string]string{ProviderIDHeader: "3"}, 0, errForcedProviderDenied},
+		{"global admin token", auth, &domain.APIToken{AdminAccess: true, GlobalAdmin: true}, map[string]string{ProviderIDHeader: "3"}, 3, nil},
+		{"admin token without project", auth, &domain.APIToken{AdminAccess: true}, map[string]string{ProviderIDHeader: "3"}, 0, errForcedProviderDenied},
+		{"admin jwt", auth, nil, map[string]string{ProviderIDHeader: "3", AdminTokenHeader: "Bearer " + adminJWT}, 3, nil},
+		{"foreign jwt", auth, nil, map[string]string{ProviderIDHeader: "3", AdminTokenHeader: otherJWT}, 0, errForcedProviderDenied},
+		{"jwt with admin auth disabled", &AuthMiddleware{}, nil, map[string]string{ProviderIDHeader: "3", AdminTokenHeader: adminJWT}, 0, errForcedProviderDenied},
+		{"not a number", auth, &domain.APIToken{AdminAccess: true, GlobalAdmin: true}, map[string]string{ProviderIDHeader: "primary"}, 0, errInvalidProviderID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			h := &ProxyHandler{adminAuth: tt.adminAuth}
+			got, err := h.forcedProviderID(r, tt.token)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("forcedProviderID = %d, %v; want %d, %v", got, err, tt.want, tt.wantErr)
+			}
+			if r.Header.Get(ProviderIDHeader) != "" || r.Header.Get(AdminTokenHeader) != "" {
+				t.Errorf("debug headers left on the request: %v", r.Header)
+			}
+		})
+	}
+}
+
+func TestMissingModel(t *testing.T) {
+	db, err := sqlite.NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestRepo := sqlite.NewProxyRequestRepository(db)
+	projectRepo := sqlite.NewProjectRepository(db)
+	settingRepo := sqlite.NewSystemSettingRepository(db)
+	project := &domain.Project{Name: "p", Slug: "p", DefaultModel: "default-model"}
+	if err := projectRepo.Create(project); err != nil {
+		t.Fatal(err)
+	}
+	exec := executor.NewExecutor(nil, requestRepo, nil, nil, nil, projectRepo, nil, settingRepo, nil, nil, "test", nil)
+	h := NewProxyHandler(client.NewAdapter(), exec, cached.NewSessionRepository(sqlite.NewSessionRepository(db)), nil)
+
+	tests := []struct {
+		clientType domain.ClientType
+		uri        string
+		body       string
+		wantURI    string
+		wantBody   string
+	}{
+		{domain.ClientTypeClaude, "/v1/messages", `{"messages":[]}`, "/v1/messages", `{"messages":[],"model":"default-model"}`},
+		{domain.ClientTypeOpenAI, "/v1/chat/completions", `{"messages":[]}`, "/v1/chat/completions", `{"messages":[],"model":"default-model"}`},
+		{domain.ClientTypeCodex, "/responses", `{"input":"hi"}`, "/responses", `{"input":"hi","model":"default-model"}`},
+		{domain.ClientTypeGemini, "/v1beta/models/:generateContent?alt=sse", `{"contents":[]}`, "/v1beta/models/default-model:generateContent?alt=sse", `{"contents":[]}`},
+	}
+
+	// The project's default model is filled in where the client would have sent it
+	for _, tt := range tests {
+		ctx := ctxutil.WithRequestURI(context.Background(), tt.uri)
+		ctx = ctxutil.WithRequestBody(ctx, []byte(tt.body))
+		ctx, err := h.applyMissingModel(ctx, tt.clientType, project.ID)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.clientType, err)
+		}
+		if got := ctxutil.GetRequestURI(ctx); got != tt.wantURI {
+			t.Errorf("%s: uri = %q, want %q", tt.clientType, got, tt.wantURI)
+		}
+		if got := string(ctxutil.GetRequestBody(ctx)); got != tt.wantBody {
+			t.Errorf("%s: body = %s, want %s", tt.clientType, got, tt.wantBody)
+		}
+		if ctxutil.GetRequestModel(ctx) != "default-model" || !ctxutil.GetModelDefaulted(ctx) {
+			t.Errorf("%s: model = %q, defaulted = %v", tt.clientType, ctxutil.GetRequestModel(ctx), ctxutil.GetModelDefaulted(ctx))
+		}
+	}
+
+	// Without a project default the routes' defaults apply
+	ctx := ctxutil.WithRequestBody(context.Background(), []byte(`{"messages":[]}`))
+	if ctx2, err := h.applyMissingModel(ctx, domain.ClientTypeClaude, 0); err != nil || ctx2 != ctx {
+		t.Errorf("request without a project default was changed: %v", err)
+	}
+
+	// Rejected with a 400 before routing when configured
+	if err := settingRepo.Set(domain.SettingKeyMissingModelHandling, domain.MissingModelReject); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, tt.uri, strings.NewReader(tt.body))
+		r.Header.Set("X-Maxx-Project-ID", strconv.FormatUint(project.ID, 10))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid_request_error") {
+			t.Errorf("%s: response = %d %s, want a 400", tt.clientType, rec.Code, rec.Body.String())
+		}
+	}
+	reqs, err := requestRepo.List(10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != len(tests) {
+		t.Fatalf("recorded %d requests, want %d", len(reqs), len(tests))
+	}
+	for _, req := range reqs {
+		if req.Status != "REJECTED" || req.Error != domain.ErrMissingModel.Error() {
+			t.Errorf("recorded %s %q, want a rejected request", req.Status, req.Error)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/awsl-project/maxx/internal/adapter/client"
+	ctxutil "github.com/awsl-project/maxx/internal/context"
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/replay"
+)
+
+// ReplayHandler runs a captured client request (curl command or HAR entry) through
+// the proxy again, for reproducing a problem a user reported. Replays go through
+// token auth, routing and the executor like live traffic and are recorded with the
+// replay flag and tag, so they can be told apart in the request log and stats.
+type ReplayHandler struct {
+	proxyHandler        *ProxyHandler
+	projectProxyHandler *ProjectProxyHandler
+}
+
+// NewReplayHandler creates a replay handler serving POST /admin/requests/replay
+func NewReplayHandler(proxyHandler *ProxyHandler, projectProxyHandler *ProjectProxyHandler) *ReplayHandler {
+	return &ReplayHandler{
+		proxyHandler:        proxyHandler,
+		projectProxyHandler: projectProxyHandler,
+	}
+}
+
+// ReplayRequest is the body of POST /admin/requests/replay, set either curl or har
+type ReplayRequest struct {
+	Curl   string          `json:"curl,omitempty"`
+	HAR    json.RawMessage `json:"har,omitempty"`
+	DryRun bool            `json:"dryRun,omitempty"`
+}
+
+// ReplayExplain describes how a captured request was understood
+type ReplayExplain struct {
+	Method      string            `json:"method"`
+	Path        string            `json:"path"`
+	ProjectSlug string            `json:"projectSlug,omitempty"`
+	ClientType  domain.ClientType `json:"clientType"`
+	Model       string            `json:"model,omitempty"`
+	Stream      bool              `json:"stream"`
+	Headers     map[string]string `json:"headers"`
+	BodyBytes   int               `json:"bodyBytes"`
+}
+
+// ReplayResult is the response of a replay, or only the explain for a dry run
+type ReplayResult struct {
+	Explain *ReplayExplain    `json:"explain"`
+	DryRun  bool              `json:"dryRun,omitempty"`
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// replayCredentialHeaders are masked in the explain
+var replayCredentialHeaders = map[string]bool{
+	"Authorization":       true,
+	"X-Api-Key":           true,
+	"X-Goog-Api-Key":      true,
+	"Cookie":              true,
+	AdminTokenHeader:      true,
+	"Proxy-Authorization": true,
+}
+
+func (h *ReplayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	var body ReplayRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	var captured *replay.Request
+	var err error
+	switch {
+	case body.Curl != "" && len(body.HAR) > 0:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "set either curl or har, not both"})
+		return
+	case body.Curl != "":
+		captured, err = replay.ParseCurl(body.Curl)
+	case len(body.HAR) > 0:
+		captured, err = replay.ParseHAR(body.HAR)
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "curl or har is required"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Only the path and query are replayed, against this instance whatever host was captured
+	target, slug, apiPath := h.target(captured.URL.Path)
+	if target == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "not a proxy endpoint: " + captured.URL.Path})
+		return
+	}
+
+	ctx := ctxutil.WithReplay(r.Context(), true)
+	req, err := http.NewRequestWithContext(ctx, captured.Method, captured.URL.RequestURI(), bytes.NewReader(captured.Body))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	req.Header = captured.Header
+	req.Host = r.Host
+	req.RemoteAddr = r.RemoteAddr
+
+	explain := h.explain(req, captured.Body, slug, apiPath)
+	if body.DryRun {
+		writeJSON(w, http.StatusOK, &ReplayResult{Explain: explain, DryRun: true})
+		return
+	}
+
+	log.Printf("[Replay] Replaying %s %s (client type %s, model %s)", req.Method, req.URL.Path, explain.ClientType, explain.Model)
+	rec := newReplayRecorder()
+	target.ServeHTTP(rec, req)
+	writeJSON(w, http.StatusOK, &ReplayResult{
+		Explain: explain,
+		Status:  rec.status,
+		Headers: flattenReplayHeaders(rec.header, false),
+		Body:    rec.body.String(),
+	})
+}
+
+// target returns the handler serving the path: the project proxy for project
+// prefixed paths, the proxy for the API paths, nil otherwise. Message Batches
+// are not replayed, they would create a new batch.
+func (h *ReplayHandler) target(path string) (http.Handler, string, string) {
+	if isProjectProxyPath(path) {
+		slug, apiPath, _ := h.projectProxyHandler.parseProjectPath(path)
+		if isBatchPath(apiPath) {
+			return nil, "", ""
+		}
+		return h.projectProxyHandler, slug, apiPath
+	}
+	if !isValidAPIPath(path) || isBatchPath(path) {
+		return nil, "", ""
+	}
+	return h.proxyHandler, "", path
+}
+
+// explain reports what the proxy will see, the client type is detected from the
+// path as it is for live traffic
+func (h *ReplayHandler) explain(req *http.Request, body []byte, slug, apiPath string) *ReplayExplain {
+	clientType := client.DetectClientTypeFromPath(apiPath)
+	apiReq := req.Clone(req.Context())
+	apiReq.URL.Path = apiPath
+	return &ReplayExplain{
+		Method:      req.Method,
+		Path:        req.URL.RequestURI(),
+		ProjectSlug: slug,
+		ClientType:  clientType,
+		Model:       h.proxyHandler.clientAdapter.ExtractModel(apiReq, body, clientType),
+		Stream:      h.proxyHandler.clientAdapter.IsStreamRequest(apiReq, body),
+		Headers:     flattenReplayHeaders(req.Header, true),
+		BodyBytes:   len(body),
+	}
+}
+
+func flattenReplayHeaders(header http.Header, maskCredentials bool) map[string]string {
+	out := make(map[string]string, len(header))
+	for name, values := range header {
+		value := strings.Join(values, ", ")
+		if maskCredentials && replayCredentialHeaders[http.CanonicalHeaderKey(name)] {
+			value = "***"
+		}
+		out[name] = value
+	}
+	return out
+}
+
+// replayRecorder collects the proxy's response in memory, streamed responses
+// are collected whole
+type replayRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newReplayRecorder() *replayRecorder {
+	return &replayRecorder{header: make(http.Header)}
+}
+
+func (w *replayRecorder) Header() http.Header {
+	return w.header
+}
+
+func (w *replayRecorder) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+func (w *replayRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Flush implements http.Flusher for streaming requests, there is nothing to flush to
+func (w *replayRecorder) Flush() {}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/awsl-project/maxx/internal/resume"
+)
+
+// resumePath is where clients reconnect to a resumable stream
+const resumePath = "/v1/resume"
+
+// handleResume handles GET /v1/resume
+// The stream is identified by the X-Maxx-Resume-Token header, events after
+// Last-Event-ID are replayed before the live stream continues.
+func (h *ProxyHandler) handleResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	rs := h.resumes.Get(r.Header.Get(resume.HeaderResumeToken))
+	if rs == nil {
+		writeError(w, http.StatusNotFound, resume.ErrNotFound.Error())
+		return
+	}
+
+	// Only the caller of the original request may resume it
+	if h.tokenAuth != nil {
+		apiToken, err := h.tokenAuth.ValidateRequest(r, rs.ClientType())
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		var apiTokenID uint64
+		if apiToken != nil {
+			apiTokenID = apiToken.ID
+		}
+		if apiTokenID != rs.APITokenID() {
+			writeError(w, http.StatusNotFound, resume.ErrNotFound.Error())
+			return
+		}
+	}
+
+	lastEventID := resume.ParseLastEventID(r.Header.Get(resume.HeaderLastEventID))
+	log.Printf("[Proxy] Resuming stream after event %d", lastEventID)
+	if err := rs.Resume(r.Context(), w, lastEventID); err != nil {
+		if errors.Is(err, resume.ErrEventsExpired) {
+			writeError(w, http.StatusGone, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
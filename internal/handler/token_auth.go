@@ -114,6 +114,11 @@ func (m *TokenAuthMiddleware) ValidateRequest(req *http.Request, clientType doma
 		return nil, ErrMissingToken
 	}
 
+	return m.LookupToken(token)
+}
+
+// LookupToken validates a token value regardless of whether proxy token auth is enabled
+func (m *TokenAuthMiddleware) LookupToken(token string) (*domain.APIToken, error) {
 	// Check if it's a maxx token
 	if !strings.HasPrefix(token, TokenPrefix) {
 		return nil, ErrInvalidToken
@@ -2,17 +2,24 @@ package handler
 
 import (
 	"bufio"
+	"encoding/json"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
 	"github.com/gorilla/websocket"
 )
 
+// DefaultWSCompressionLevel is the permessage-deflate level used when the setting is unset:
+// flate.BestSpeed, which already shrinks the repetitive JSON broadcasts several times over
+const DefaultWSCompressionLevel = 1
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true // 生产环境需要严格检查
@@ -25,15 +32,22 @@ type WSMessage struct {
 }
 
 type WebSocketHub struct {
-	clients   map[*websocket.Conn]bool
-	broadcast chan WSMessage
-	mu        sync.RWMutex
+	clients     map[*websocket.Conn]bool
+	subscribers map[chan WSMessage]struct{}
+	snapshots   map[string]func() WSMessage
+	broadcast   chan WSMessage
+	mu          sync.RWMutex
+
+	// settingRepo provides the compression level, nil uses DefaultWSCompressionLevel
+	settingRepo repository.SystemSettingRepository
 }
 
 func NewWebSocketHub() *WebSocketHub {
 	hub := &WebSocketHub{
-		clients:   make(map[*websocket.Conn]bool),
-		broadcast: make(chan WSMessage, 100),
+		clients:     make(map[*websocket.Conn]bool),
+		subscribers: make(map[chan WSMessage]struct{}),
+		snapshots:   make(map[string]func() WSMessage),
+		broadcast:   make(chan WSMessage, 100),
 	}
 	go hub.run()
 	return hub
@@ -49,16 +63,108 @@ func (h *WebSocketHub) run() {
 				delete(h.clients, client)
 			}
 		}
+		for sub := range h.subscribers {
+			// Slow subscribers miss messages instead of blocking the hub
+			select {
+			case sub <- msg:
+			default:
+			}
+		}
 		h.mu.RUnlock()
 	}
 }
 
+// SetSettingRepo makes new connections read the compression level from settings
+func (h *WebSocketHub) SetSettingRepo(repo repository.SystemSettingRepository) {
+	h.mu.Lock()
+	h.settingRepo = repo
+	h.mu.Unlock()
+}
+
+// compressionLevel returns the configured permessage-deflate level, 0 disables compression
+func (h *WebSocketHub) compressionLevel() int {
+	h.mu.RLock()
+	repo := h.settingRepo
+	h.mu.RUnlock()
+	if repo == nil {
+		return DefaultWSCompressionLevel
+	}
+	val, err := repo.Get(domain.SettingKeyWSCompressionLevel)
+	if err != nil || strings.TrimSpace(val) == "" {
+		return DefaultWSCompressionLevel
+	}
+	level, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil || level < 0 || level > 9 {
+		return DefaultWSCompressionLevel
+	}
+	return level
+}
+
+// Subscribe registers an in-process listener that receives every broadcast message.
+// Call the returned function to unsubscribe.
+func (h *WebSocketHub) Subscribe() (<-chan WSMessage, func()) {
+	ch := make(chan WSMessage, 64)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subscribers, ch)
+		h.mu.Unlock()
+	}
+}
+
+// RegisterSnapshot answers client messages {"type":"subscribe","data":topic} with the
+// message returned by snapshot, sent only to that client. Clients use it as the starting
+// point for incremental updates that are broadcast afterwards.
+func (h *WebSocketHub) RegisterSnapshot(topic string, snapshot func() WSMessage) {
+	h.mu.Lock()
+	h.snapshots[topic] = snapshot
+	h.mu.Unlock()
+}
+
+// handleClientMessage serves subscribe requests, other client messages (heartbeats) are ignored
+func (h *WebSocketHub) handleClientMessage(conn *websocket.Conn, data []byte) {
+	var msg struct {
+		Type string `json:"type"`
+		Data string `json:"data"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type != "subscribe" {
+		return
+	}
+	h.mu.RLock()
+	snapshot := h.snapshots[msg.Data]
+	h.mu.RUnlock()
+	if snapshot == nil {
+		return
+	}
+	reply := snapshot()
+
+	// The write lock keeps run() from writing to the same connection concurrently
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[conn] {
+		_ = conn.WriteJSON(reply)
+	}
+}
+
+// HandleWebSocket upgrades the connection and keeps it registered for broadcasts.
+// Clients that offer permessage-deflate get every message compressed, broadcasts
+// and subscription snapshots alike, unless the compression level is set to 0.
 func (h *WebSocketHub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	level := h.compressionLevel()
+	up := upgrader
+	up.EnableCompression = level > 0
+	conn, err := up.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
 		return
 	}
+	if level > 0 {
+		// Only takes effect when the client negotiated the extension
+		_ = conn.SetCompressionLevel(level)
+	}
 
 	h.mu.Lock()
 	h.clients[conn] = true
@@ -71,12 +177,13 @@ func (h *WebSocketHub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		conn.Close()
 	}()
 
-	// 保持连接，处理客户端消息（心跳等）
+	// 保持连接，处理客户端消息（订阅、心跳等）
 	for {
-		_, _, err := conn.ReadMessage()
+		_, data, err := conn.ReadMessage()
 		if err != nil {
 			break
 		}
+		h.handleClientMessage(conn, data)
 	}
 }
 
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/gorilla/websocket"
+)
+
+type memorySettings map[string]string
+
+func (m memorySettings) Get(key string) (string, error) { return m[key], nil }
+func (m memorySettings) Set(key, value string) error    { m[key] = value; return nil }
+func (m memorySettings) Delete(key string) error        { delete(m, key); return nil }
+func (m memorySettings) GetAll() ([]*domain.SystemSetting, error) {
+	var all []*domain.SystemSetting
+	for k, v := range m {
+		all = append(all, &domain.SystemSetting{Key: k, Value: v})
+	}
+	return all, nil
+}
+
+// recordingConn keeps every byte read from the server so the raw frames can be inspected
+type recordingConn struct {
+	net.Conn
+	mu   sync.Mutex
+	read bytes.Buffer
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.mu.Lock()
+	c.read.Write(p[:n])
+	c.mu.Unlock()
+	return n, err
+}
+
+func (c *recordingConn) frames() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, body, _ := bytes.Cut(c.read.Bytes(), []byte("\r\n\r\n"))
+	return append([]byte(nil), body...)
+}
+
+func dialHub(t *testing.T, hub *WebSocketHub) (*websocket.Conn, *http.Response, *recordingConn) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(hub.HandleWebSocket))
+	t.Cleanup(srv.Close)
+
+	var rec *recordingConn
+	dialer := websocket.Dialer{
+		EnableCompression: true,
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			rec = &recordingConn{Conn: conn}
+			return rec, nil
+		},
+	}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn, resp, rec
+}
+
+func TestWebSocketCompressesBroadcastsAndSnapshots(t *testing.T) {
+	hub := NewWebSocketHub()
+	hub.RegisterSnapshot("dashboard", func() WSMessage {
+		return WSMessage{Type: "dashboard_snapshot", Data: strings.Repeat("snapshot ", 50)}
+	})
+
+	conn, resp, rec := dialHub(t, hub)
+	if ext := resp.Header.Get("Sec-Websocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Fatalf("extensions = %q, want permessage-deflate", ext)
+	}
+
+	// The snapshot reply also confirms the connection is registered for broadcasts
+	if err := conn.WriteJSON(map[string]string{"type": "subscribe", "data": "dashboard"}); err != nil {
+		t.Fatal(err)
+	}
+	var snapshot WSMessage
+	if err := conn.ReadJSON(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Type != "dashboard_snapshot" || snapshot.Data != strings.Repeat("snapshot ", 50) {
+		t.Errorf("snapshot = %+v", snapshot)
+	}
+
+	payload := strings.Repeat(`{"status":"COMPLETED"}`, 100)
+	hub.BroadcastMessage("proxy_request_update", payload)
+	var msg WSMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != "proxy_request_update" || msg.Data != payload {
+		t.Errorf("broadcast = %+v", msg.Type)
+	}
+
+	// Both frames carry RSV1 (compressed) and are much smaller than the payload
+	frames := rec.frames()
+	if len(frames) == 0 || frames[0]&0x40 == 0 {
+		t.Fatalf("first frame header %x, want RSV1 set", frames[:min(len(frames), 2)])
+	}
+	if len(frames) >= len(payload)/2 {
+		t.Errorf("received %d bytes for a %d byte broadcast, want compressed", len(frames), len(payload))
+	}
+}
+
+func TestWebSocketCompressionDisabled(t *testing.T) {
+	hub := NewWebSocketHub()
+	hub.SetSettingRepo(memorySettings{domain.SettingKeyWSCompressionLevel: "0"})
+
+	conn, resp, rec := dialHub(t, hub)
+	if ext := resp.Header.Get("Sec-Websocket-Extensions"); ext != "" {
+		t.Fatalf("extensions = %q, want none", ext)
+	}
+
+	hub.RegisterSnapshot("dashboard", func() WSMessage { return WSMessage{Type: "dashboard_snapshot"} })
+	if err := conn.WriteJSON(map[string]string{"type": "subscribe", "data": "dashboard"}); err != nil {
+		t.Fatal(err)
+	}
+	var msg WSMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if frames := rec.frames(); len(frames) == 0 || frames[0]&0x40 != 0 {
+		t.Errorf("frame header %x, want uncompressed", frames[:min(len(frames), 2)])
+	}
+}
+
+func TestWebSocketCompressionLevelSetting(t *testing.T) {
+	hub := NewWebSocketHub()
+	if got := hub.compressionLevel(); got != DefaultWSCompressionLevel {
+		t.Errorf("default level = %d", got)
+	}
+	settings := memorySettings{}
+	hub.SetSettingRepo(settings)
+	for value, want := range map[string]int{"": DefaultWSCompressionLevel, "9": 9, "0": 0, "12": DefaultWSCompressionLevel, "x": DefaultWSCompressionLevel} {
+		settings[domain.SettingKeyWSCompressionLevel] = value
+		if got := hub.compressionLevel(); got != want {
+			t.Errorf("level for %q = %d, want %d", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package limiter
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+// Defaults used when the queue settings are not configured
+const (
+	DefaultQueueSize    = 100
+	DefaultQueueTimeout = 10 * time.Second
+	// DefaultMaxRequestBodyMB matches the request size limit of the Anthropic API
+	DefaultMaxRequestBodyMB = 32
+	// DefaultMaxBatchBodyMB matches the Message Batches size limit of the Anthropic API
+	DefaultMaxBatchBodyMB = 256
+)
+
+var (
+	// ErrQueueFull is returned when the limit is reached and the wait queue is full
+	ErrQueueFull = errors.New("too many concurrent requests, queue is full")
+	// ErrQueueTimeout is returned when a queued request doesn't get a slot in time
+	ErrQueueTimeout = errors.New("too many concurrent requests, timed out waiting in queue")
+)
+
+// Stats is a snapshot of the limiter state
+type Stats struct {
+	// MaxConcurrent is the configured limit, 0 means unlimited
+	MaxConcurrent int `json:"maxConcurrent"`
+	QueueSize     int `json:"queueSize"`
+	Active        int `json:"active"`
+	Queued        int `json:"queued"`
+}
+
+// Limiter caps the number of concurrently executing requests.
+// Requests over the limit wait in a bounded FIFO queue; when the queue is full
+// or the wait times out they are turned away.
+type Limiter struct {
+	mu           sync.Mutex
+	max          int
+	queueSize    int
+	queueTimeout time.Duration
+	active       int
+	waiters      *list.List // of chan struct{}, closed when a slot is handed over
+}
+
+// New creates an unlimited limiter
+func New() *Limiter {
+	return &Limiter{
+		queueSize:    DefaultQueueSize,
+		queueTimeout: DefaultQueueTimeout,
+		waiters:      list.New(),
+	}
+}
+
+// Configure changes the limits. maxConcurrent <= 0 disables the limit.
+// Requests already running or queued keep their slot or place.
+func (l *Limiter) Configure(maxConcurrent, queueSize int, queueTimeout time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.max = max(maxConcurrent, 0)
+	l.queueSize = max(queueSize, 0)
+	l.queueTimeout = queueTimeout
+	// A raised or removed limit frees slots for queued requests
+	for l.waiters.Len() > 0 && (l.max == 0 || l.active < l.max) {
+		l.handOver()
+	}
+}
+
+// QueueTimeout returns how long a request may wait for a slot
+func (l *Limiter) QueueTimeout() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.queueTimeout
+}
+
+// Acquire takes a slot, waiting in the queue when the limit is reached.
+// The returned release function must be called once the request finishes.
+func (l *Limiter) Acquire(ctx context.Context) (release func(), err error) {
+	l.mu.Lock()
+	if l.max == 0 || (l.active < l.max && l.waiters.Len() == 0) {
+		l.active++
+		l.mu.Unlock()
+		return l.releaseFunc(), nil
+	}
+	if l.waiters.Len() >= l.queueSize {
+		l.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	ready := make(chan struct{})
+	elem := l.waiters.PushBack(ready)
+	timeout := l.queueTimeout
+	l.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-ready:
+		return l.releaseFunc(), nil
+	case <-timer.C:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-ready:
+		// The slot was handed over while giving up, take it after all
+		return l.releaseFunc(), nil
+	default:
+	}
+	l.waiters.Remove(elem)
+	return nil, err
+}
+
+func (l *Limiter) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(l.release)
+	}
+}
+
+func (l *Limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// A lowered limit drains running requests before handing slots over
+	if l.waiters.Len() > 0 && (l.max == 0 || l.active <= l.max) {
+		l.active--
+		l.handOver()
+		return
+	}
+	l.active--
+}
+
+// Slot is a request's hold on a limiter slot. A request waiting on something
+// other than an upstream, such as a provider cooldown, yields it meanwhile so it
+// doesn't turn away requests that could run.
+type Slot struct {
+	limiter *Limiter
+	mu      sync.Mutex
+	release func() // nil while yielded
+}
+
+// AcquireSlot takes a slot like Acquire. Release must be called once the request finishes.
+func (l *Limiter) AcquireSlot(ctx context.Context) (*Slot, error) {
+	release, err := l.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Slot{limiter: l, release: release}, nil
+}
+
+// Release gives the slot back, it is a no-op on a nil or yielded slot
+func (s *Slot) Release() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.release != nil {
+		s.release()
+		s.release = nil
+	}
+}
+
+// Yield gives the slot back until Resume
+func (s *Slot) Yield() {
+	s.Release()
+}
+
+// Resume takes a slot again after Yield, waiting in the queue like Acquire.
+// It is a no-op on a nil slot or one that is still held.
+func (s *Slot) Resume(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.release != nil {
+		return nil
+	}
+	release, err := s.limiter.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	s.release = release
+	return nil
+}
+
+type slotKey struct{}
+
+// WithSlot stores the request's slot in ctx, so code waiting further down can yield it
+func WithSlot(ctx context.Context, slot *Slot) context.Context {
+	return context.WithValue(ctx, slotKey{}, slot)
+}
+
+// SlotFromContext returns the slot stored by WithSlot, nil when there is none
+func SlotFromContext(ctx context.Context) *Slot {
+	slot, _ := ctx.Value(slotKey{}).(*Slot)
+	return slot
+}
+
+// handOver gives a slot to the first queued request, must be called with mu held
+func (l *Limiter) handOver() {
+	elem := l.waiters.Front()
+	l.waiters.Remove(elem)
+	l.active++
+	close(elem.Value.(chan struct{}))
+}
+
+// Stats returns the current limiter state
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Stats{
+		MaxConcurrent: l.max,
+		QueueSize:     l.queueSize,
+		Active:        l.active,
+		Queued:        l.waiters.Len(),
+	}
+}
+
+// ==================== Global limiter ====================
+
+var global = New()
+
+// Global returns the process-wide limiter used by the proxy handler.
+// Message Batches items don't take its slots: the batch processor bounds them
+// with its own concurrency setting and holds back while live requests are in
+// flight, so they can't crowd out live traffic.
+func Global() *Limiter {
+	return global
+}
+
+// maxRequestBodyBytes bounds proxied request bodies, 0 means unlimited
+var maxRequestBodyBytes atomic.Int64
+
+// maxBatchBodyBytes bounds Message Batches bodies, which carry many requests, 0 means unlimited
+var maxBatchBodyBytes atomic.Int64
+
+func init() {
+	SetMaxRequestBodyBytes(DefaultMaxRequestBodyMB << 20)
+	SetMaxBatchBodyBytes(DefaultMaxBatchBodyMB << 20)
+}
+
+// SetMaxRequestBodyBytes sets the request body limit, <= 0 removes it
+func SetMaxRequestBodyBytes(n int64) {
+	maxRequestBodyBytes.Store(max(n, 0))
+}
+
+// MaxRequestBodyBytes returns the request body limit the proxy handler enforces
+// before buffering a body, 0 means unlimited
+func MaxRequestBodyBytes() int64 {
+	return maxRequestBodyBytes.Load()
+}
+
+// SetMaxBatchBodyBytes sets the Message Batches body limit, <= 0 removes it
+func SetMaxBatchBodyBytes(n int64) {
+	maxBatchBodyBytes.Store(max(n, 0))
+}
+
+// MaxBatchBodyBytes returns the body limit the proxy handler enforces on the Message
+// Batches API, 0 means unlimited
+func MaxBatchBodyBytes() int64 {
+	return maxBatchBodyBytes.Load()
+}
+
+// LoadFromSettings applies the concurrency and request body settings to the global limiter
+func LoadFromSettings(settingRepo repository.SystemSettingRepository) {
+	maxConcurrent := settingInt(settingRepo, domain.SettingKeyMaxConcurrentRequests, 0)
+	queueSize := settingInt(settingRepo, domain.SettingKeyRequestQueueSize, DefaultQueueSize)
+	timeout := DefaultQueueTimeout
+	if secs := settingInt(settingRepo, domain.SettingKeyRequestQueueTimeout, -1); secs >= 0 {
+		timeout = time.Duration(secs) * time.Second
+	}
+	global.Configure(maxConcurrent, queueSize, timeout)
+	SetMaxRequestBodyBytes(int64(settingInt(settingRepo, domain.SettingKeyMaxRequestBodyMB, DefaultMaxRequestBodyMB)) << 20)
+}
+
+func settingInt(settingRepo repository.SystemSettingRepository, key string, def int) int {
+	val, err := settingRepo.Get(key)
+	if err != nil || val == "" {
+		return def
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		return def
+	}
+	return n
+}
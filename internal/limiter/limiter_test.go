@@ -0,0 +1,131 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiterCapHoldsUnderLoad(t *testing.T) {
+	const limit = 8
+	l := New()
+	l.Configure(limit, 1000, 5*time.Second)
+
+	var running, peak atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 500; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.Acquire(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer release()
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	if p := peak.Load(); p > limit {
+		t.Errorf("peak concurrency = %d, want <= %d", p, limit)
+	}
+	if s := l.Stats(); s.Active != 0 || s.Queued != 0 {
+		t.Errorf("after load: %+v, want no active or queued requests", s)
+	}
+}
+
+func TestLimiterRejectsWhenQueueFullOrTimedOut(t *testing.T) {
+	l := New()
+	l.Configure(1, 1, 50*time.Millisecond)
+
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	queued := make(chan error, 1)
+	go func() {
+		_, err := l.Acquire(context.Background())
+		queued <- err
+	}()
+	for l.Stats().Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Acquire with full queue = %v, want ErrQueueFull", err)
+	}
+	if err := <-queued; !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("queued Acquire = %v, want ErrQueueTimeout", err)
+	}
+	if s := l.Stats(); s.Active != 1 || s.Queued != 0 {
+		t.Errorf("stats = %+v, want 1 active and an empty queue", s)
+	}
+}
+
+func TestLimiterHandsSlotsToQueuedRequests(t *testing.T) {
+	l := New()
+	l.Configure(1, 10, time.Second)
+
+	release, _ := l.Acquire(context.Background())
+	got := make(chan func(), 1)
+	go func() {
+		r, err := l.Acquire(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		got <- r
+	}()
+	for l.Stats().Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	release()
+	release() // releasing twice is a no-op
+	second := <-got
+	if s := l.Stats(); s.Active != 1 {
+		t.Errorf("active after hand-over = %d, want 1", s.Active)
+	}
+	second()
+	if s := l.Stats(); s.Active != 0 {
+		t.Errorf("active after release = %d, want 0", s.Active)
+	}
+}
+
+func TestLimiterRaisingLimitWakesQueue(t *testing.T) {
+	l := New()
+	l.Configure(1, 10, time.Second)
+	release, _ := l.Acquire(context.Background())
+	defer release()
+
+	done := make(chan error, 1)
+	go func() {
+		r, err := l.Acquire(context.Background())
+		if err == nil {
+			r()
+		}
+		done <- err
+	}()
+	for l.Stats().Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	l.Configure(0, 10, time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("queued Acquire after removing the limit = %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package logsink
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+// Defaults used when the sink settings are not configured
+const (
+	DefaultBufferSize  = 1000
+	DefaultBodyLimitKB = 64
+)
+
+// maxBatchSize caps the number of records written to the sink at once
+const maxBatchSize = 100
+
+// retryDelays are the waits before each redelivery of a failed batch
+var retryDelays = []time.Duration{time.Second, 5 * time.Second}
+
+// Config selects the sink and what is shipped to it
+type Config struct {
+	// Target is the sink address (see NewSink), empty disables shipping
+	Target string
+	// Auth is sent as the Authorization header to HTTP sinks
+	Auth string
+	// Providers limits shipping to these providers' requests, empty ships all requests
+	Providers map[uint64]bool
+	// BodyLimit is the maximum size in bytes of each body in a record, 0 omits bodies
+	BodyLimit int
+	// BufferSize is the number of records waiting for delivery before new ones are dropped
+	BufferSize int
+}
+
+// Stats is a snapshot of the shipper state
+type Stats struct {
+	Enabled   bool   `json:"enabled"`
+	Buffered  int    `json:"buffered"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"` // buffer was full
+	Failed    uint64 `json:"failed"`  // delivery failed after retries
+}
+
+// Shipper delivers the records of finished requests to an external sink.
+// Submit never blocks the request path: records wait in a bounded buffer for a
+// background worker, and are dropped when the buffer is full because the sink
+// is slow or down.
+type Shipper struct {
+	mu       sync.RWMutex
+	pipeline *pipeline
+
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+	failed    atomic.Uint64
+	dropping  atomic.Bool
+}
+
+// pipeline is a sink with its buffer and worker
+type pipeline struct {
+	config Config
+	sink   Sink
+	queue  chan Record
+	done   chan struct{}
+}
+
+// New creates a shipper without a sink
+func New() *Shipper {
+	return &Shipper{}
+}
+
+// Configure replaces the sink. Records buffered for the previous sink are still
+// delivered to it in the background.
+func (s *Shipper) Configure(config Config) error {
+	if config.Target == "" {
+		s.swap(nil)
+		return nil
+	}
+	sink, err := NewSink(config.Target, config.Auth)
+	if err != nil {
+		return err
+	}
+	s.swap(s.start(config, sink))
+	return nil
+}
+
+// start runs a pipeline delivering to sink
+func (s *Shipper) start(config Config, sink Sink) *pipeline {
+	p := &pipeline{
+		config: config,
+		sink:   sink,
+		queue:  make(chan Record, max(config.BufferSize, 1)),
+		done:   make(chan struct{}),
+	}
+	go s.run(p)
+	return p
+}
+
+// swap installs p and stops the previous pipeline, returning it
+func (s *Shipper) swap(p *pipeline) *pipeline {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.pipeline
+	s.pipeline = p
+	if old != nil {
+		close(old.queue)
+	}
+	return old
+}
+
+// Close stops shipping and waits until the buffered records are delivered
+func (s *Shipper) Close() {
+	if old := s.swap(nil); old != nil {
+		<-old.done
+	}
+}
+
+// Submit queues the record of a finished request, dropping it when the buffer is full
+func (s *Shipper) Submit(req *domain.ProxyRequest) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p := s.pipeline
+	if p == nil || (len(p.config.Providers) > 0 && !p.config.Providers[req.ProviderID]) {
+		return
+	}
+	select {
+	case p.queue <- NewRecord(req, p.config.BodyLimit):
+		s.dropping.Store(false)
+	default:
+		s.dropped.Add(1)
+		if !s.dropping.Swap(true) {
+			log.Printf("[LogSink] Buffer full, dropping request records until the sink catches up")
+		}
+	}
+}
+
+// Stats returns the current shipper state
+func (s *Shipper) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stats := Stats{
+		Delivered: s.delivered.Load(),
+		Dropped:   s.dropped.Load(),
+		Failed:    s.failed.Load(),
+	}
+	if s.pipeline != nil {
+		stats.Enabled = true
+		stats.Buffered = len(s.pipeline.queue)
+	}
+	return stats
+}
+
+// run delivers the buffered records in batches until the queue is closed
+func (s *Shipper) run(p *pipeline) {
+	defer close(p.done)
+	defer p.sink.Close()
+
+	batch := make([]Record, 0, maxBatchSize)
+	for record := range p.queue {
+		batch = append(batch[:0], record)
+	drain:
+		for len(batch) < maxBatchSize {
+			select {
+			case next, ok := <-p.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+		s.deliver(p.sink, batch)
+	}
+}
+
+// deliver writes a batch, retrying failures. While it waits new records fill the buffer.
+func (s *Shipper) deliver(sink Sink, batch []Record) {
+	err := sink.Write(batch)
+	for _, delay := range retryDelays {
+		if err == nil {
+			break
+		}
+		time.Sleep(delay)
+		err = sink.Write(batch)
+	}
+	if err != nil {
+		s.failed.Add(uint64(len(batch)))
+		log.Printf("[LogSink] Failed to deliver %d request records: %v", len(batch), err)
+		return
+	}
+	s.delivered.Add(uint64(len(batch)))
+}
+
+// ==================== Global shipper ====================
+
+var global = New()
+
+// Global returns the process-wide shipper used by the executor
+func Global() *Shipper {
+	return global
+}
+
+// LoadFromSettings applies the log sink settings to the global shipper
+func LoadFromSettings(settingRepo repository.SystemSettingRepository) error {
+	target, _ := settingRepo.Get(domain.SettingKeyRequestLogSink)
+	auth, _ := settingRepo.Get(domain.SettingKeyRequestLogSinkAuth)
+	providers, _ := settingRepo.Get(domain.SettingKeyRequestLogSinkProviders)
+	return global.Configure(Config{
+		Target:     strings.TrimSpace(target),
+		Auth:       auth,
+		Providers:  parseProviderIDs(providers),
+		BodyLimit:  settingInt(settingRepo, domain.SettingKeyRequestLogSinkBodyLimit, DefaultBodyLimitKB) * 1024,
+		BufferSize: settingInt(settingRepo, domain.SettingKeyRequestLogSinkBuffer, DefaultBufferSize),
+	})
+}
+
+// parseProviderIDs parses a comma separated list of provider IDs, skipping invalid entries
+func parseProviderIDs(val string) map[uint64]bool {
+	ids := make(map[uint64]bool)
+	for _, part := range strings.Split(val, ",") {
+		if id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64); err == nil {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+func settingInt(settingRepo repository.SystemSettingRepository, key string, def int) int {
+	val, err := settingRepo.Get(key)
+	if err != nil || val == "" {
+		return def
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
@@ -0,0 +1,175 @@
+package logsink
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/redaction"
+)
+
+func finishedRequest(id string, providerID uint64) *domain.ProxyRequest {
+	return &domain.ProxyRequest{
+		RequestID:  id,
+		ProviderID: providerID,
+		Status:     "COMPLETED",
+		RequestInfo: &domain.RequestInfo{
+			Method:  "POST",
+			URL:     "/v1/messages",
+			Headers: map[string]string{"authorization": "Bearer maxx_secret", "Content-Type": "application/json"},
+			Body:    `{"prompt":"` + strings.Repeat("x", 100) + `"}`,
+		},
+		ResponseInfo: &domain.ResponseInfo{Status: 200, Body: `{"ok":true}`},
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for delivery")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestShipperDeliversAsynchronouslyOverHTTP(t *testing.T) {
+	var mu sync.Mutex
+	var records []Record
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		auth = r.Header.Get("Authorization")
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var record Record
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Errorf("invalid record line %q: %v", scanner.Text(), err)
+			}
+			records = append(records, record)
+		}
+	}))
+	defer srv.Close()
+
+	s := New()
+	defer s.Close()
+	if err := s.Configure(Config{Target: srv.URL, Auth: "Bearer sink", BodyLimit: 20, BufferSize: 10}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		s.Submit(finishedRequest(id, 1))
+	}
+	waitFor(t, func() bool { return s.Stats().Delivered == 3 })
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(records) != 3 || records[0].RequestID != "a" || records[2].RequestID != "c" {
+		t.Fatalf("records = %+v, want a, b, c in order", records)
+	}
+	if auth != "Bearer sink" {
+		t.Errorf("sink Authorization = %q", auth)
+	}
+	req := records[0].Request
+	if len(req.Body) != 20 || !req.BodyTruncated {
+		t.Errorf("request body = %q (truncated %v), want cut to 20 bytes", req.Body, req.BodyTruncated)
+	}
+	if req.Headers["authorization"] != redaction.Placeholder || req.Headers["Content-Type"] != "application/json" {
+		t.Errorf("request headers = %v, want only the credential replaced", req.Headers)
+	}
+	if records[0].Response.Body != `{"ok":true}` || records[0].Response.BodyTruncated {
+		t.Errorf("response = %+v, want the short body kept", records[0].Response)
+	}
+}
+
+// blockingSink holds every write until released
+type blockingSink struct {
+	release chan struct{}
+	mu      sync.Mutex
+	written int
+}
+
+func (s *blockingSink) Write(records []Record) error {
+	<-s.release
+	s.mu.Lock()
+	s.written += len(records)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *blockingSink) Close() error { return nil }
+
+func TestShipperDropsWhenBufferFull(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	s := New()
+	s.swap(s.start(Config{BufferSize: 2}, sink))
+
+	// The sink is stuck, submitting must not block the request path
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		s.Submit(finishedRequest("r", 1))
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Submit blocked for %v", elapsed)
+	}
+
+	// The worker batches what is buffered when it wakes up, so besides the 2 buffered
+	// records it holds 1 or 2 more, depending on scheduling
+	if dropped := s.Stats().Dropped; dropped < 6 || dropped > 8 {
+		t.Errorf("dropped = %d, want 6 to 8", dropped)
+	}
+
+	close(sink.release)
+	s.Close()
+	stats := s.Stats()
+	if stats.Enabled || stats.Delivered+stats.Dropped != 10 || int(stats.Delivered) != sink.written {
+		t.Errorf("stats after close = %+v, written %d, want every record delivered or dropped", stats, sink.written)
+	}
+}
+
+func TestShipperProviderFilterAndFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.ndjson")
+	s := New()
+	if err := s.Configure(Config{Target: "file://" + path, Providers: parseProviderIDs("2, 3"), BufferSize: 10}); err != nil {
+		t.Fatal(err)
+	}
+	s.Submit(finishedRequest("other-provider", 1))
+	s.Submit(finishedRequest("shipped", 2))
+	s.Submit(finishedRequest("no-bodies", 3))
+	s.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"requestID":"shipped"`) || !strings.Contains(lines[1], `"requestID":"no-bodies"`) {
+		t.Fatalf("file contents = %s, want only providers 2 and 3", data)
+	}
+	// BodyLimit 0 leaves bodies out
+	if strings.Contains(lines[0], "prompt") {
+		t.Errorf("record includes a body with BodyLimit 0: %s", lines[0])
+	}
+}
+
+func TestValidateTarget(t *testing.T) {
+	for _, target := range []string{"", "https://logs.example.com/ingest", "syslog://127.0.0.1:514", "syslog+tcp://logs:6514", "file://" + filepath.Join(t.TempDir(), "r.log")} {
+		if err := ValidateTarget(target); err != nil {
+			t.Errorf("ValidateTarget(%q) = %v", target, err)
+		}
+	}
+	for _, target := range []string{"ftp://logs", "syslog://", "file://"} {
+		if err := ValidateTarget(target); err == nil {
+			t.Errorf("ValidateTarget(%q) should fail", target)
+		}
+	}
+}
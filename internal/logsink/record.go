@@ -0,0 +1,136 @@
+package logsink
+
+import (
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/redaction"
+)
+
+// credentialHeaders are replaced in shipped records, they carry the client's maxx token
+var credentialHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"X-Api-Key":           true,
+	"X-Goog-Api-Key":      true,
+	"Cookie":              true,
+}
+
+// Record is the audit record of a finished request
+type Record struct {
+	Time          time.Time         `json:"time"`
+	InstanceID    string            `json:"instanceID"`
+	RequestID     string            `json:"requestID"`
+	SessionID     string            `json:"sessionID,omitempty"`
+	ClientType    string            `json:"clientType"`
+	ClientApp     string            `json:"clientApp,omitempty"`
+	RequestModel  string            `json:"requestModel"`
+	ResponseModel string            `json:"responseModel,omitempty"`
+	Status        string            `json:"status"`
+	StatusCode    int               `json:"statusCode,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	StartTime     time.Time         `json:"startTime"`
+	DurationMs    int64             `json:"durationMs"`
+	IsStream      bool              `json:"isStream"`
+	RouteID       uint64            `json:"routeID,omitempty"`
+	ProviderID    uint64            `json:"providerID,omitempty"`
+	ProjectID     uint64            `json:"projectID,omitempty"`
+	APITokenID    uint64            `json:"apiTokenID,omitempty"`
+	Attempts      uint64            `json:"attempts"`
+	InputTokens   uint64            `json:"inputTokens"`
+	OutputTokens  uint64            `json:"outputTokens"`
+	CacheRead     uint64            `json:"cacheRead"`
+	CacheWrite    uint64            `json:"cacheWrite"`
+	Cost          uint64            `json:"cost"` // micro USD
+	Tags          []string          `json:"tags,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Request       *RecordMessage    `json:"request,omitempty"`
+	Response      *RecordMessage    `json:"response,omitempty"`
+}
+
+// RecordMessage is the request or response of a record
+type RecordMessage struct {
+	Method        string            `json:"method,omitempty"`
+	URL           string            `json:"url,omitempty"`
+	Status        int               `json:"status,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Body          string            `json:"body,omitempty"`
+	BodyTruncated bool              `json:"bodyTruncated,omitempty"`
+}
+
+// NewRecord builds the record of a request. Bodies are the stored ones, already
+// redacted, and are cut to bodyLimit bytes (0 omits them); credential headers are replaced.
+func NewRecord(req *domain.ProxyRequest, bodyLimit int) Record {
+	record := Record{
+		Time:          req.EndTime,
+		InstanceID:    req.InstanceID,
+		RequestID:     req.RequestID,
+		SessionID:     req.SessionID,
+		ClientType:    string(req.ClientType),
+		ClientApp:     req.ClientApp,
+		RequestModel:  req.RequestModel,
+		ResponseModel: req.ResponseModel,
+		Status:        req.Status,
+		StatusCode:    req.StatusCode,
+		Error:         req.Error,
+		StartTime:     req.StartTime,
+		DurationMs:    req.Duration.Milliseconds(),
+		IsStream:      req.IsStream,
+		RouteID:       req.RouteID,
+		ProviderID:    req.ProviderID,
+		ProjectID:     req.ProjectID,
+		APITokenID:    req.APITokenID,
+		Attempts:      req.ProxyUpstreamAttemptCount,
+		InputTokens:   req.InputTokenCount,
+		OutputTokens:  req.OutputTokenCount,
+		CacheRead:     req.CacheReadCount,
+		CacheWrite:    req.CacheWriteCount,
+		Cost:          req.Cost,
+		Tags:          req.Tags,
+		Metadata:      req.Metadata,
+	}
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	if info := req.RequestInfo; info != nil {
+		record.Request = &RecordMessage{Method: info.Method, URL: info.URL, Headers: scrubHeaders(info.Headers)}
+		record.Request.Body, record.Request.BodyTruncated = truncateBody(info.Body, bodyLimit)
+	}
+	if info := req.ResponseInfo; info != nil {
+		record.Response = &RecordMessage{Status: info.Status, Headers: scrubHeaders(info.Headers)}
+		record.Response.Body, record.Response.BodyTruncated = truncateBody(info.Body, bodyLimit)
+	}
+	return record
+}
+
+// scrubHeaders copies headers with credential values replaced
+func scrubHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	scrubbed := make(map[string]string, len(headers))
+	for key, value := range headers {
+		if credentialHeaders[http.CanonicalHeaderKey(key)] {
+			value = redaction.Placeholder
+		}
+		scrubbed[key] = value
+	}
+	return scrubbed
+}
+
+// truncateBody cuts a body to limit bytes without splitting a UTF-8 character
+func truncateBody(body string, limit int) (string, bool) {
+	if limit <= 0 {
+		return "", body != ""
+	}
+	if len(body) <= limit {
+		return body, false
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return body[:cut], true
+}
@@ -0,0 +1,195 @@
+package logsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const sinkTimeout = 10 * time.Second
+
+// Sink receives batches of records. Writes come from a single goroutine.
+type Sink interface {
+	Write(records []Record) error
+	Close() error
+}
+
+// NewSink creates the sink for a target address:
+//   - http(s)://host/path: POST of the batch as newline delimited JSON
+//   - syslog://host:port or syslog+udp://host:port: one RFC 5424 UDP message per record
+//   - syslog+tcp://host:port: RFC 5424 messages over TCP with octet counting framing
+//   - file:///path/to/file: newline delimited JSON appended to the file
+func NewSink(target, auth string) (Sink, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log sink %q: %w", target, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &httpSink{url: target, auth: auth, client: &http.Client{Timeout: sinkTimeout}}, nil
+	case "syslog", "syslog+udp", "syslog+tcp":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid log sink %q: missing host", target)
+		}
+		network := "udp"
+		if u.Scheme == "syslog+tcp" {
+			network = "tcp"
+		}
+		hostname, _ := os.Hostname()
+		return &syslogSink{network: network, addr: u.Host, hostname: hostname}, nil
+	case "file":
+		path := u.Path
+		if path == "" {
+			path = u.Opaque
+		}
+		if path == "" {
+			return nil, fmt.Errorf("invalid log sink %q: missing path", target)
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log sink file: %w", err)
+		}
+		return &fileSink{file: f}, nil
+	default:
+		return nil, fmt.Errorf("invalid log sink %q: unsupported scheme, use http(s)://, syslog://, syslog+tcp:// or file://", target)
+	}
+}
+
+// ValidateTarget checks that a target address is supported. Network sinks are not
+// contacted, a file sink's file is opened (and created).
+func ValidateTarget(target string) error {
+	if target == "" {
+		return nil
+	}
+	sink, err := NewSink(target, "")
+	if err != nil {
+		return err
+	}
+	return sink.Close()
+}
+
+func encodeNDJSON(records []Record) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// httpSink posts batches to a collector
+type httpSink struct {
+	url    string
+	auth   string
+	client *http.Client
+}
+
+func (s *httpSink) Write(records []Record) error {
+	body, err := encodeNDJSON(records)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sinkTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.auth != "" {
+		req.Header.Set("Authorization", s.auth)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sink returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *httpSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// fileSink appends batches to a local file
+type fileSink struct {
+	file *os.File
+}
+
+func (s *fileSink) Write(records []Record) error {
+	body, err := encodeNDJSON(records)
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(body)
+	return err
+}
+
+func (s *fileSink) Close() error {
+	return s.file.Close()
+}
+
+// syslogSink sends each record as an RFC 5424 message with a JSON payload,
+// reconnecting on the next batch after a failure
+type syslogSink struct {
+	network  string
+	addr     string
+	hostname string
+	conn     net.Conn
+}
+
+// Syslog priority: facility local0, severity informational or warning for failed requests
+const (
+	syslogPriorityInfo    = 16*8 + 6
+	syslogPriorityWarning = 16*8 + 4
+)
+
+func (s *syslogSink) Write(records []Record) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.addr, sinkTimeout)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	_ = s.conn.SetWriteDeadline(time.Now().Add(sinkTimeout))
+	for _, record := range records {
+		payload, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		priority := syslogPriorityInfo
+		if record.Status != "COMPLETED" {
+			priority = syslogPriorityWarning
+		}
+		msg := fmt.Sprintf("<%d>1 %s %s maxx - - - %s", priority, record.Time.UTC().Format(time.RFC3339Nano), s.hostname, payload)
+		if s.network == "tcp" {
+			msg = fmt.Sprintf("%d %s", len(msg), msg)
+		}
+		if _, err := s.conn.Write([]byte(msg)); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
@@ -0,0 +1,186 @@
+package pii
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+// MaxScanBytes bounds how much of a request body is scanned, so huge bodies
+// (long conversations, base64 images) don't slow down the request path
+const MaxScanBytes = 256 << 10
+
+// maxCandidates bounds how many regex matches are checked per pattern
+const maxCandidates = 64
+
+// Built-in pattern names
+const (
+	PatternEmail      = "email"
+	PatternCreditCard = "credit_card"
+	PatternSSN        = "ssn"
+)
+
+// DefaultPatterns are used when no patterns are configured
+func DefaultPatterns() []domain.PIIPattern {
+	return []domain.PIIPattern{
+		{Name: PatternEmail, Pattern: `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`},
+		{Name: PatternCreditCard, Pattern: `\b\d(?:[ -]?\d){12,18}\b`},
+		{Name: PatternSSN, Pattern: `\b\d{3}-\d{2}-\d{4}\b`},
+	}
+}
+
+type compiledPattern struct {
+	name  string
+	re    *regexp.Regexp
+	check func(match string) bool // optional validation of a regex match
+}
+
+// Detector flags request bodies that likely contain personal information.
+// It is advisory only: bodies are never modified.
+type Detector struct {
+	patterns []compiledPattern
+}
+
+// New compiles detection patterns, failing on the first invalid pattern.
+// Matches of a pattern named credit_card must also pass the Luhn check.
+func New(patterns []domain.PIIPattern) (*Detector, error) {
+	d := &Detector{}
+	for i, p := range patterns {
+		if strings.TrimSpace(p.Name) == "" {
+			return nil, fmt.Errorf("pii pattern %d: empty name", i)
+		}
+		if p.Pattern == "" {
+			return nil, fmt.Errorf("pii pattern %d (%s): empty regex pattern", i, p.Name)
+		}
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pii pattern %d (%s): invalid regex: %w", i, p.Name, err)
+		}
+		compiled := compiledPattern{name: p.Name, re: re}
+		if p.Name == PatternCreditCard {
+			compiled.check = luhnValid
+		}
+		d.patterns = append(d.patterns, compiled)
+	}
+	return d, nil
+}
+
+// ParsePatterns parses and validates a JSON array of patterns.
+// An empty value selects DefaultPatterns.
+func ParsePatterns(raw string) ([]domain.PIIPattern, error) {
+	if strings.TrimSpace(raw) == "" {
+		return DefaultPatterns(), nil
+	}
+	var patterns []domain.PIIPattern
+	if err := json.Unmarshal([]byte(raw), &patterns); err != nil {
+		return nil, fmt.Errorf("invalid pii patterns JSON: %w", err)
+	}
+	if _, err := New(patterns); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
+// Detect returns the sorted names of the patterns found in the first
+// MaxScanBytes of body, nil when nothing matched
+func (d *Detector) Detect(body []byte) []string {
+	if d == nil || len(body) == 0 {
+		return nil
+	}
+	if len(body) > MaxScanBytes {
+		body = body[:MaxScanBytes]
+	}
+	text := string(body)
+
+	var flags []string
+	for _, p := range d.patterns {
+		if p.matches(text) && !contains(flags, p.name) {
+			flags = append(flags, p.name)
+		}
+	}
+	sort.Strings(flags)
+	return flags
+}
+
+func (p compiledPattern) matches(text string) bool {
+	if p.check == nil {
+		return p.re.MatchString(text)
+	}
+	for _, m := range p.re.FindAllString(text, maxCandidates) {
+		if p.check(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// luhnValid reports whether the digits of s pass the Luhn checksum
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if n%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ==================== Global detector ====================
+
+var globalDetector atomic.Pointer[Detector]
+
+// Load applies the pii_detection and pii_patterns setting values.
+// Detection is disabled unless enabled is "true".
+func Load(enabled, rawPatterns string) error {
+	if enabled != "true" {
+		globalDetector.Store(nil)
+		return nil
+	}
+	patterns, err := ParsePatterns(rawPatterns)
+	if err != nil {
+		return err
+	}
+	d, err := New(patterns)
+	if err != nil {
+		return err
+	}
+	globalDetector.Store(d)
+	return nil
+}
+
+// LoadFromSettings reads both settings from the repository and applies them
+func LoadFromSettings(settingRepo repository.SystemSettingRepository) error {
+	enabled, _ := settingRepo.Get(domain.SettingKeyPIIDetection)
+	patterns, _ := settingRepo.Get(domain.SettingKeyPIIPatterns)
+	return Load(enabled, patterns)
+}
+
+// Detect runs the global detector, returning nil when detection is disabled
+func Detect(body []byte) []string {
+	return globalDetector.Load().Detect(body)
+}
@@ -0,0 +1,103 @@
+package pii
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDetectDefaultPatterns(t *testing.T) {
+	d, err := New(DefaultPatterns())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"email", `{"content":"reach me at jane.doe@example.com"}`, []string{PatternEmail}},
+		{"credit card", `{"content":"card 4111 1111 1111 1111 exp 12/29"}`, []string{PatternCreditCard}},
+		{"credit card dashes", `{"content":"4111-1111-1111-1111"}`, []string{PatternCreditCard}},
+		{"luhn failure", `{"content":"order 4111 1111 1111 1112"}`, nil},
+		{"ssn", `{"content":"SSN 123-45-6789"}`, []string{PatternSSN}},
+		{"several", `{"a":"123-45-6789","b":"x@y.io"}`, []string{PatternEmail, PatternSSN}},
+		{"clean", `{"content":"hello world 2024-01-01"}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.Detect([]byte(tt.body)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Detect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomPatterns(t *testing.T) {
+	patterns, err := ParsePatterns(`[{"name":"employee_id","pattern":"EMP-\\d{6}"}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := New(patterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Detect([]byte(`ticket for EMP-004211, mail a@b.co`)); !reflect.DeepEqual(got, []string{"employee_id"}) {
+		t.Errorf("Detect() = %v, want only the custom pattern", got)
+	}
+
+	for _, raw := range []string{`[{"name":"","pattern":"x"}]`, `[{"name":"bad","pattern":"("}]`, `{`} {
+		if _, err := ParsePatterns(raw); err == nil {
+			t.Errorf("ParsePatterns(%s) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestDetectBoundsLargeBodies(t *testing.T) {
+	d, err := New(DefaultPatterns())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Digit runs produce many credit card candidates, each needing a Luhn check
+	filler := bytes.Repeat([]byte("4111 1111 1111 1112, "), 4<<20/21)
+
+	early := append([]byte("ssn 123-45-6789 "), filler...)
+	late := append(append([]byte{}, filler...), []byte(" ssn 123-45-6789")...)
+
+	start := time.Now()
+	if got := d.Detect(early); !reflect.DeepEqual(got, []string{PatternSSN}) {
+		t.Errorf("Detect(early) = %v, want ssn", got)
+	}
+	if got := d.Detect(late); got != nil {
+		t.Errorf("Detect(late) = %v, PII beyond MaxScanBytes must not be scanned", got)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("scanning 4MiB bodies took %v", elapsed)
+	}
+}
+
+func TestGlobalDetectorDisabledByDefault(t *testing.T) {
+	t.Cleanup(func() { Load("", "") })
+
+	body := []byte(`{"content":"jane@example.com"}`)
+	if err := Load("false", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := Detect(body); got != nil {
+		t.Errorf("Detect() with detection disabled = %v", got)
+	}
+
+	if err := Load("true", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := Detect(body); !reflect.DeepEqual(got, []string{PatternEmail}) {
+		t.Errorf("Detect() = %v, want email", got)
+	}
+
+	if err := Load("true", `[{"name":"x","pattern":"["}]`); err == nil {
+		t.Error("Load() with an invalid pattern succeeded")
+	}
+}
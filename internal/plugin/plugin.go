@@ -0,0 +1,286 @@
+// Package plugin runs the WASM transform plugins of providers, which rewrite
+// request bodies before they are sent upstream and non-streaming response bodies
+// before they are returned, for upstreams with non-standard schemas.
+//
+// A plugin is a WASM module exporting:
+//
+//	memory                                                   the linear memory
+//	alloc(size i32) i32                                      a buffer of size bytes for the host to write to
+//	transform_request(ptr, len, ctPtr, ctLen i32) i64        optional
+//	transform_response(ptr, len, ctPtr, ctLen i32) i64       optional
+//
+// The transforms get the body and the client type (the format the body is in) as
+// bytes in memory and return the new body packed as ptr<<32 | len, or 0 to leave
+// the body unchanged. Every call runs in a fresh instance, so plugins keep no state
+// between calls. Plugins run sandboxed: WASI is available for toolchains that need
+// it but without filesystem, network, environment or real clocks, and each call is
+// bounded in time and memory.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+const (
+	// DefaultTimeout bounds a plugin call when the provider doesn't set a timeout
+	DefaultTimeout = 100 * time.Millisecond
+	// MaxTimeout caps the configurable timeout of a plugin call
+	MaxTimeout = 5 * time.Second
+	// DefaultMemoryLimitMB is the memory of a plugin when the provider doesn't set a limit
+	DefaultMemoryLimitMB = 16
+	// MaxMemoryLimitMB caps the configurable memory of a plugin
+	MaxMemoryLimitMB = 512
+
+	exportAlloc             = "alloc"
+	exportTransformRequest  = "transform_request"
+	exportTransformResponse = "transform_response"
+
+	// pagesPerMB is the number of 64 KiB WASM pages in a megabyte
+	pagesPerMB = 16
+)
+
+// ErrInvalidPlugin is returned for plugin files that don't implement the ABI
+var ErrInvalidPlugin = errors.New("invalid transform plugin")
+
+// Plugin is a compiled transform plugin, safe for concurrent use
+type Plugin struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	timeout  time.Duration
+
+	hasRequest  bool
+	hasResponse bool
+}
+
+// Compile compiles a plugin with the given memory limit and call timeout,
+// 0 means the default
+func Compile(ctx context.Context, wasm []byte, memoryLimitMB int, timeout time.Duration) (*Plugin, error) {
+	if memoryLimitMB <= 0 {
+		memoryLimitMB = DefaultMemoryLimitMB
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	config := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(memoryLimitMB * pagesPerMB)).
+		WithCloseOnContextDone(true)
+	runtime := wazero.NewRuntimeWithConfig(ctx, config)
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		_ = runtime.Close(ctx)
+		return nil, err
+	}
+	compiled, err := runtime.CompileModule(ctx, wasm)
+	if err != nil {
+		_ = runtime.Close(ctx)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPlugin, err)
+	}
+
+	p := &Plugin{runtime: runtime, compiled: compiled, timeout: timeout}
+	if err := p.checkExports(); err != nil {
+		_ = runtime.Close(ctx)
+		return nil, err
+	}
+	return p, nil
+}
+
+// checkExports verifies the module exports the ABI with the right signatures
+func (p *Plugin) checkExports() error {
+	exports := p.compiled.ExportedFunctions()
+	i32, i64 := api.ValueTypeI32, api.ValueTypeI64
+	if !signature(exports[exportAlloc], []api.ValueType{i32}, []api.ValueType{i32}) {
+		return fmt.Errorf("%w: missing %s(i32) i32", ErrInvalidPlugin, exportAlloc)
+	}
+	if _, ok := p.compiled.ExportedMemories()["memory"]; !ok {
+		return fmt.Errorf("%w: missing exported memory", ErrInvalidPlugin)
+	}
+	transform := []api.ValueType{i32, i32, i32, i32}
+	for _, name := range []string{exportTransformRequest, exportTransformResponse} {
+		def, ok := exports[name]
+		if !ok {
+			continue
+		}
+		if !signature(def, transform, []api.ValueType{i64}) {
+			return fmt.Errorf("%w: %s must be (i32, i32, i32, i32) i64", ErrInvalidPlugin, name)
+		}
+		if name == exportTransformRequest {
+			p.hasRequest = true
+		} else {
+			p.hasResponse = true
+		}
+	}
+	if !p.hasRequest && !p.hasResponse {
+		return fmt.Errorf("%w: exports neither %s nor %s", ErrInvalidPlugin, exportTransformRequest, exportTransformResponse)
+	}
+	return nil
+}
+
+func signature(def api.FunctionDefinition, params, results []api.ValueType) bool {
+	return def != nil && bytes.Equal(def.ParamTypes(), params) && bytes.Equal(def.ResultTypes(), results)
+}
+
+// TransformRequest returns the request body rewritten by the plugin, the body
+// itself when the plugin doesn't transform requests or leaves it unchanged
+func (p *Plugin) TransformRequest(ctx context.Context, clientType domain.ClientType, body []byte) ([]byte, error) {
+	if !p.hasRequest {
+		return body, nil
+	}
+	return p.call(ctx, exportTransformRequest, clientType, body)
+}
+
+// TransformResponse returns the response body rewritten by the plugin, the body
+// itself when the plugin doesn't transform responses or leaves it unchanged
+func (p *Plugin) TransformResponse(ctx context.Context, clientType domain.ClientType, body []byte) ([]byte, error) {
+	if !p.hasResponse {
+		return body, nil
+	}
+	return p.call(ctx, exportTransformResponse, clientType, body)
+}
+
+// TransformsResponses reports whether the plugin exports a response transform
+func (p *Plugin) TransformsResponses() bool {
+	return p.hasResponse
+}
+
+// Close releases the runtime of the plugin
+func (p *Plugin) Close(ctx context.Context) error {
+	return p.runtime.Close(ctx)
+}
+
+// call runs one transform in a fresh instance of the module
+func (p *Plugin) call(ctx context.Context, export string, clientType domain.ClientType, body []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	// Anonymous instances so calls can run concurrently, without start functions
+	// besides a reactor's initializer
+	config := wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize")
+	mod, err := p.runtime.InstantiateModule(ctx, p.compiled, config)
+	if err != nil {
+		return nil, p.callError(ctx, err)
+	}
+	defer mod.Close(context.Background())
+
+	bodyPtr, err := p.write(ctx, mod, body)
+	if err != nil {
+		return nil, err
+	}
+	ctPtr, err := p.write(ctx, mod, []byte(clientType))
+	if err != nil {
+		return nil, err
+	}
+	results, err := mod.ExportedFunction(export).Call(ctx,
+		uint64(bodyPtr), uint64(len(body)), uint64(ctPtr), uint64(len(clientType)))
+	if err != nil {
+		return nil, p.callError(ctx, err)
+	}
+
+	packed := results[0]
+	if packed == 0 {
+		return body, nil
+	}
+	out, ok := mod.Memory().Read(uint32(packed>>32), uint32(packed))
+	if !ok {
+		return nil, fmt.Errorf("%s returned a body out of memory bounds", export)
+	}
+	return bytes.Clone(out), nil
+}
+
+// write copies data into a buffer allocated by the module
+func (p *Plugin) write(ctx context.Context, mod api.Module, data []byte) (uint32, error) {
+	results, err := mod.ExportedFunction(exportAlloc).Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, p.callError(ctx, err)
+	}
+	ptr := uint32(results[0])
+	if !mod.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("%s returned a buffer out of memory bounds", exportAlloc)
+	}
+	return ptr, nil
+}
+
+// callError reports calls interrupted by the timeout as such
+func (p *Plugin) callError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("plugin call exceeded %v", p.timeout)
+	}
+	return err
+}
+
+// loaded is a plugin compiled from a file, with the file state it was compiled from
+type loaded struct {
+	modTime       time.Time
+	size          int64
+	memoryLimitMB int
+	timeout       time.Duration
+	plugin        *Plugin
+	err           error
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]*loaded)
+)
+
+// Load returns the plugin of a provider's config, compiled from its file the first
+// time and again when the file or the limits change. Failures are cached the same
+// way, so a broken file isn't recompiled on every request.
+func Load(cfg *domain.TransformPluginConfig) (*Plugin, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("%w: path is required", ErrInvalidPlugin)
+	}
+	info, err := os.Stat(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(cfg.TimeoutMs) * time.Millisecond
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if l, ok := cache[cfg.Path]; ok && l.modTime.Equal(info.ModTime()) && l.size == info.Size() &&
+		l.memoryLimitMB == cfg.MemoryLimitMB && l.timeout == timeout {
+		return l.plugin, l.err
+	}
+
+	l := &loaded{modTime: info.ModTime(), size: info.Size(), memoryLimitMB: cfg.MemoryLimitMB, timeout: timeout}
+	wasm, err := os.ReadFile(cfg.Path)
+	if err == nil {
+		l.plugin, l.err = Compile(context.Background(), wasm, cfg.MemoryLimitMB, timeout)
+	} else {
+		l.err = err
+	}
+	if old, ok := cache[cfg.Path]; ok && old.plugin != nil {
+		// Calls still running on the old version fail and pass their body through
+		_ = old.plugin.Close(context.Background())
+	}
+	cache[cfg.Path] = l
+	return l.plugin, l.err
+}
+
+// Validate rejects plugin configs with limits out of bounds or a file that
+// doesn't load as a plugin
+func Validate(cfg *domain.TransformPluginConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.TimeoutMs < 0 || time.Duration(cfg.TimeoutMs)*time.Millisecond > MaxTimeout {
+		return fmt.Errorf("invalid transform plugin timeout %dms: must be between 0 and %d", cfg.TimeoutMs, MaxTimeout.Milliseconds())
+	}
+	if cfg.MemoryLimitMB < 0 || cfg.MemoryLimitMB > MaxMemoryLimitMB {
+		return fmt.Errorf("invalid transform plugin memory limit %dMB: must be between 0 and %d", cfg.MemoryLimitMB, MaxMemoryLimitMB)
+	}
+	if _, err := Load(cfg); err != nil {
+		return fmt.Errorf("invalid transform plugin %q: %w", cfg.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// testdata/transform.wasm is assembled from testdata/transform.wat
+func sample(t *testing.T) []byte {
+	t.Helper()
+	wasm, err := os.ReadFile("testdata/transform.wasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wasm
+}
+
+func TestTransform(t *testing.T) {
+	ctx := context.Background()
+	p, err := Compile(ctx, sample(t), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close(ctx)
+
+	got, err := p.TransformRequest(ctx, domain.ClientTypeClaude, []byte(`{"model":"claude-x"}`))
+	if err != nil || string(got) != `{"MODEL":"CLAUDE-X"}` {
+		t.Errorf("TransformRequest = %q, %v", got, err)
+	}
+
+	// transform_response returns 0, the body passes through
+	body := []byte(`{"type":"message"}`)
+	got, err = p.TransformResponse(ctx, domain.ClientTypeClaude, body)
+	if err != nil || !bytes.Equal(got, body) {
+		t.Errorf("TransformResponse = %q, %v", got, err)
+	}
+}
+
+func TestCallLimits(t *testing.T) {
+	ctx := context.Background()
+	p, err := Compile(ctx, sample(t), 1, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close(ctx)
+
+	// The sample spins forever for client types starting with "l"
+	start := time.Now()
+	if _, err := p.TransformRequest(ctx, domain.ClientType("loop"), []byte("{}")); err == nil {
+		t.Error("endless call succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("endless call took %v, want it stopped at the timeout", elapsed)
+	}
+
+	// Bodies that don't fit in the memory limit fail
+	if _, err := p.TransformRequest(ctx, domain.ClientTypeOpenAI, make([]byte, 2<<20)); err == nil {
+		t.Error("call beyond the memory limit succeeded")
+	}
+
+	// Failed calls leave the plugin usable
+	if got, err := p.TransformRequest(ctx, domain.ClientTypeOpenAI, []byte("ok")); err != nil || string(got) != "OK" {
+		t.Errorf("TransformRequest after failures = %q, %v", got, err)
+	}
+}
+
+func TestCompileRejectsModulesWithoutABI(t *testing.T) {
+	ctx := context.Background()
+	for name, wasm := range map[string][]byte{
+		"not wasm":     []byte("not wasm"),
+		"empty module": []byte("\x00asm\x01\x00\x00\x00"),
+	} {
+		if _, err := Compile(ctx, wasm, 0, 0); !errors.Is(err, ErrInvalidPlugin) {
+			t.Errorf("%s: error = %v, want ErrInvalidPlugin", name, err)
+		}
+	}
+}
+
+func TestLoadReloadsChangedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transform.wasm")
+	if err := os.WriteFile(path, sample(t), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &domain.TransformPluginConfig{Path: path}
+
+	first, err := Load(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Load(cfg); again != first {
+		t.Error("unchanged plugin was compiled again")
+	}
+	if err := Validate(cfg); err != nil {
+		t.Errorf("Validate = %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("not wasm"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(cfg); !errors.Is(err, ErrInvalidPlugin) {
+		t.Errorf("Load after the file broke = %v, want ErrInvalidPlugin", err)
+	}
+
+	for _, invalid := range []*domain.TransformPluginConfig{
+		{Path: path},
+		{Path: filepath.Join(t.TempDir(), "missing.wasm")},
+		{Path: path, TimeoutMs: -1},
+		{Path: path, MemoryLimitMB: MaxMemoryLimitMB + 1},
+	} {
+		if err := Validate(invalid); err == nil {
+			t.Errorf("Validate(%+v) succeeded", invalid)
+		}
+	}
+}
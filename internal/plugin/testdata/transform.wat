@@ -0,0 +1,97 @@
+;; Sample transform plugin used by the tests, transform.wasm is its binary.
+;;
+;; transform_request upper-cases the ASCII letters of the body, or spins forever
+;; when the client type starts with "l" (to exercise the timeout).
+;; transform_response leaves the body unchanged.
+;; alloc traps when memory can't grow (to exercise the memory limit).
+(module
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+
+  (func (export "alloc") (param $size i32) (result i32)
+    (local $ptr i32) (local $need i32)
+    global.get $heap
+    local.set $ptr
+    local.get $ptr
+    local.get $size
+    i32.add
+    global.set $heap
+    ;; pages missing to hold the heap
+    global.get $heap
+    i32.const 65535
+    i32.add
+    i32.const 16
+    i32.shr_u
+    memory.size
+    i32.sub
+    local.tee $need
+    i32.const 0
+    i32.gt_s
+    if
+      local.get $need
+      memory.grow
+      i32.const -1
+      i32.eq
+      if
+        unreachable
+      end
+    end
+    local.get $ptr)
+
+  (func (export "transform_request")
+    (param $ptr i32) (param $len i32) (param $ct i32) (param $ctlen i32) (result i64)
+    (local $i i32) (local $c i32) (local $addr i32)
+    local.get $ct
+    i32.load8_u
+    i32.const 108 ;; 'l'
+    i32.eq
+    if
+      loop $spin
+        br $spin
+      end
+    end
+    block $done
+      loop $next
+        local.get $i
+        local.get $len
+        i32.ge_u
+        br_if $done
+        local.get $ptr
+        local.get $i
+        i32.add
+        local.set $addr
+        local.get $addr
+        i32.load8_u
+        local.set $c
+        local.get $c
+        i32.const 97 ;; 'a'
+        i32.ge_u
+        local.get $c
+        i32.const 122 ;; 'z'
+        i32.le_u
+        i32.and
+        if
+          local.get $addr
+          local.get $c
+          i32.const 32
+          i32.sub
+          i32.store8
+        end
+        local.get $i
+        i32.const 1
+        i32.add
+        local.set $i
+        br $next
+      end
+    end
+    ;; the body was rewritten in place: return its pointer and length
+    local.get $ptr
+    i64.extend_i32_u
+    i64.const 32
+    i64.shl
+    local.get $len
+    i64.extend_i32_u
+    i64.or)
+
+  (func (export "transform_response") (param i32 i32 i32 i32) (result i64)
+    i64.const 0))
@@ -0,0 +1,115 @@
+package redaction
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathSegment is a single step of a parsed JSONPath expression
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parseJSONPath parses the supported JSONPath subset:
+// $.a.b, $.a[0], $.a[*], $.a.*, $['a'] and $["a"]
+func parseJSONPath(expr string) ([]pathSegment, error) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("jsonpath must start with $: %q", expr)
+	}
+
+	var segments []pathSegment
+	rest := expr[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			if name == "" {
+				return nil, fmt.Errorf("empty field name in jsonpath: %q", expr)
+			}
+			if name == "*" {
+				segments = append(segments, pathSegment{wildcard: true})
+			} else {
+				segments = append(segments, pathSegment{key: name})
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed bracket in jsonpath: %q", expr)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				segments = append(segments, pathSegment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				segments = append(segments, pathSegment{key: inner[1 : len(inner)-1]})
+			default:
+				idx, err := strconv.Atoi(inner)
+				if err != nil || idx < 0 {
+					return nil, fmt.Errorf("invalid index %q in jsonpath: %q", inner, expr)
+				}
+				segments = append(segments, pathSegment{index: idx, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("unexpected character %q in jsonpath: %q", rest[0], expr)
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("jsonpath must select a field: %q", expr)
+	}
+	return segments, nil
+}
+
+// redactPath replaces every value selected by segments with the placeholder.
+// Returns the (possibly replaced) node and whether anything changed.
+func redactPath(node interface{}, segments []pathSegment) (interface{}, bool) {
+	if len(segments) == 0 {
+		return Placeholder, true
+	}
+
+	seg := segments[0]
+	changed := false
+
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if seg.isIndex {
+			return node, false
+		}
+		for key, child := range v {
+			if !seg.wildcard && key != seg.key {
+				continue
+			}
+			if newChild, ok := redactPath(child, segments[1:]); ok {
+				v[key] = newChild
+				changed = true
+			}
+		}
+	case []interface{}:
+		if !seg.isIndex && !seg.wildcard {
+			return node, false
+		}
+		for i, child := range v {
+			if seg.isIndex && i != seg.index {
+				continue
+			}
+			if newChild, ok := redactPath(child, segments[1:]); ok {
+				v[i] = newChild
+				changed = true
+			}
+		}
+	}
+
+	return node, changed
+}
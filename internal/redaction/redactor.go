@@ -0,0 +1,249 @@
+package redaction
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// Placeholder replaces redacted content in stored bodies
+const Placeholder = "[REDACTED]"
+
+type compiledRule struct {
+	target string
+	path   []pathSegment
+	re     *regexp.Regexp
+}
+
+// Redactor applies a compiled set of redaction rules to captured bodies.
+// It only ever returns a new string; the bytes sent to clients/upstreams are never touched.
+type Redactor struct {
+	rules []compiledRule
+}
+
+// New compiles redaction rules, failing on the first invalid rule
+func New(rules []domain.RedactionRule) (*Redactor, error) {
+	r := &Redactor{}
+	for i, rule := range rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("redaction rule %d: %w", i, err)
+		}
+		r.rules = append(r.rules, compiled)
+	}
+	return r, nil
+}
+
+// Validate checks that all rules compile
+func Validate(rules []domain.RedactionRule) error {
+	_, err := New(rules)
+	return err
+}
+
+func compileRule(rule domain.RedactionRule) (compiledRule, error) {
+	switch rule.Target {
+	case domain.RedactionTargetAll, domain.RedactionTargetRequest, domain.RedactionTargetResponse:
+	default:
+		return compiledRule{}, fmt.Errorf("invalid target %q", rule.Target)
+	}
+
+	compiled := compiledRule{target: rule.Target}
+	switch rule.Type {
+	case domain.RedactionRuleTypeJSONPath:
+		path, err := parseJSONPath(rule.Pattern)
+		if err != nil {
+			return compiledRule{}, err
+		}
+		compiled.path = path
+	case domain.RedactionRuleTypeRegex:
+		if rule.Pattern == "" {
+			return compiledRule{}, fmt.Errorf("empty regex pattern")
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return compiledRule{}, fmt.Errorf("invalid regex: %w", err)
+		}
+		compiled.re = re
+	default:
+		return compiledRule{}, fmt.Errorf("invalid type %q", rule.Type)
+	}
+	return compiled, nil
+}
+
+// Redact returns a redacted copy of body for the given target (request or response)
+func (r *Redactor) Redact(target, body string) string {
+	if r == nil || body == "" {
+		return body
+	}
+
+	var paths [][]pathSegment
+	for _, rule := range r.rules {
+		if rule.path != nil && (rule.target == domain.RedactionTargetAll || rule.target == target) {
+			paths = append(paths, rule.path)
+		}
+	}
+	if len(paths) > 0 {
+		body = redactJSONPaths(body, paths)
+	}
+
+	for _, rule := range r.rules {
+		if rule.re != nil && (rule.target == domain.RedactionTargetAll || rule.target == target) {
+			body = rule.re.ReplaceAllString(body, Placeholder)
+		}
+	}
+	return body
+}
+
+// redactJSONPaths applies JSONPath rules to a JSON body, or to each data line of an SSE body
+func redactJSONPaths(body string, paths [][]pathSegment) string {
+	if redacted, ok := redactJSONDocument(body, paths); ok {
+		return redacted
+	}
+
+	if !strings.Contains(body, "data:") {
+		return body
+	}
+
+	lines := strings.Split(body, "\n")
+	changed := false
+	for i, line := range lines {
+		trimmed := strings.TrimRight(line, "\r")
+		if !strings.HasPrefix(trimmed, "data:") {
+			continue
+		}
+		payload := strings.TrimLeft(strings.TrimPrefix(trimmed, "data:"), " ")
+		if redacted, ok := redactJSONDocument(payload, paths); ok && redacted != payload {
+			lines[i] = "data: " + redacted + line[len(trimmed):]
+			changed = true
+		}
+	}
+	if !changed {
+		return body
+	}
+	return strings.Join(lines, "\n")
+}
+
+// redactJSONDocument returns the redacted document and whether body was valid JSON
+func redactJSONDocument(body string, paths [][]pathSegment) (string, bool) {
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		return body, false
+	}
+
+	changed := false
+	for _, path := range paths {
+		var ok bool
+		if doc, ok = redactPath(doc, path); ok {
+			changed = true
+		}
+	}
+	if !changed {
+		return body, true
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return body, true
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), true
+}
+
+// ==================== Global and per-provider rules ====================
+
+type providerEntry struct {
+	updatedAt int64
+	redactor  *Redactor
+}
+
+var (
+	globalRedactor atomic.Pointer[Redactor]
+	providerCache  sync.Map // provider ID -> *providerEntry
+)
+
+// SetGlobalRules replaces the global redaction rules
+func SetGlobalRules(rules []domain.RedactionRule) error {
+	r, err := New(rules)
+	if err != nil {
+		return err
+	}
+	globalRedactor.Store(r)
+	return nil
+}
+
+// LoadGlobalRules parses the JSON value of the redaction_rules setting and applies it.
+// An empty value clears the global rules.
+func LoadGlobalRules(raw string) error {
+	rules, err := ParseRules(raw)
+	if err != nil {
+		return err
+	}
+	return SetGlobalRules(rules)
+}
+
+// ParseRules parses and validates a JSON array of redaction rules
+func ParseRules(raw string) ([]domain.RedactionRule, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var rules []domain.RedactionRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, fmt.Errorf("invalid redaction rules JSON: %w", err)
+	}
+	if err := Validate(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// RedactRequest returns a redacted copy of a request body using global and provider rules
+func RedactRequest(provider *domain.Provider, body string) string {
+	return redact(provider, domain.RedactionTargetRequest, body)
+}
+
+// RedactResponse returns a redacted copy of a response body using global and provider rules
+func RedactResponse(provider *domain.Provider, body string) string {
+	return redact(provider, domain.RedactionTargetResponse, body)
+}
+
+func redact(provider *domain.Provider, target, body string) string {
+	body = globalRedactor.Load().Redact(target, body)
+	return providerRedactor(provider).Redact(target, body)
+}
+
+// providerRedactor returns the cached redactor for a provider, recompiling when the provider changes
+func providerRedactor(provider *domain.Provider) *Redactor {
+	if provider == nil || provider.Config == nil || len(provider.Config.RedactionRules) == 0 {
+		return nil
+	}
+
+	updatedAt := provider.UpdatedAt.UnixNano()
+	if v, ok := providerCache.Load(provider.ID); ok {
+		if entry := v.(*providerEntry); entry.updatedAt == updatedAt {
+			return entry.redactor
+		}
+	}
+
+	// Skip invalid rules instead of dropping the whole set
+	r := &Redactor{}
+	for i, rule := range provider.Config.RedactionRules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			log.Printf("[Redaction] Skipping invalid rule %d for provider %d: %v", i, provider.ID, err)
+			continue
+		}
+		r.rules = append(r.rules, compiled)
+	}
+	providerCache.Store(provider.ID, &providerEntry{updatedAt: updatedAt, redactor: r})
+	return r
+}
@@ -0,0 +1,114 @@
+package redaction
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestRedactJSONPath(t *testing.T) {
+	r, err := New([]domain.RedactionRule{
+		{Type: domain.RedactionRuleTypeJSONPath, Pattern: "$.messages[*].content"},
+		{Type: domain.RedactionRuleTypeJSONPath, Pattern: "$.metadata['user_id']"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	body := `{"model":"claude","messages":[{"role":"user","content":"my ssn is 123"},{"role":"assistant","content":"ok"}],"metadata":{"user_id":"u1"},"max_tokens":1024}`
+	got := r.Redact(domain.RedactionTargetRequest, body)
+
+	if strings.Contains(got, "123") || strings.Contains(got, "u1") {
+		t.Errorf("sensitive values not redacted: %s", got)
+	}
+	if strings.Count(got, Placeholder) != 3 {
+		t.Errorf("expected 3 placeholders, got %s", got)
+	}
+	if !strings.Contains(got, `"max_tokens":1024`) || !strings.Contains(got, `"role":"user"`) {
+		t.Errorf("unrelated fields changed: %s", got)
+	}
+}
+
+func TestRedactRegexAndTarget(t *testing.T) {
+	r, err := New([]domain.RedactionRule{
+		{Type: domain.RedactionRuleTypeRegex, Pattern: `sk-[A-Za-z0-9]+`, Target: domain.RedactionTargetResponse},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	body := `{"text":"your key is sk-abc123"}`
+	if got := r.Redact(domain.RedactionTargetRequest, body); got != body {
+		t.Errorf("response-only rule applied to request: %s", got)
+	}
+	if got := r.Redact(domain.RedactionTargetResponse, body); got != `{"text":"your key is [REDACTED]"}` {
+		t.Errorf("unexpected redaction: %s", got)
+	}
+}
+
+func TestRedactSSEBody(t *testing.T) {
+	r, err := New([]domain.RedactionRule{
+		{Type: domain.RedactionRuleTypeJSONPath, Pattern: "$.delta.text"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	body := "event: content_block_delta\ndata: {\"delta\":{\"text\":\"secret\"}}\n\nevent: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+	got := r.Redact(domain.RedactionTargetResponse, body)
+
+	if strings.Contains(got, "secret") {
+		t.Errorf("SSE data not redacted: %q", got)
+	}
+	if !strings.Contains(got, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n") {
+		t.Errorf("untouched events changed: %q", got)
+	}
+}
+
+func TestLiveBodyUntouched(t *testing.T) {
+	if err := SetGlobalRules([]domain.RedactionRule{
+		{Type: domain.RedactionRuleTypeRegex, Pattern: `sk-[a-z0-9]+`},
+	}); err != nil {
+		t.Fatalf("SetGlobalRules failed: %v", err)
+	}
+	defer SetGlobalRules(nil)
+
+	provider := &domain.Provider{
+		ID:        1,
+		UpdatedAt: time.Now(),
+		Config: &domain.ProviderConfig{
+			RedactionRules: []domain.RedactionRule{
+				{Type: domain.RedactionRuleTypeJSONPath, Pattern: "$.email"},
+			},
+		},
+	}
+
+	live := []byte(`{"email":"a@b.c","key":"sk-abc"}`)
+	original := string(live)
+
+	stored := RedactResponse(provider, string(live))
+
+	if string(live) != original {
+		t.Errorf("live body modified: %s", live)
+	}
+	if strings.Contains(stored, "a@b.c") || strings.Contains(stored, "sk-abc") {
+		t.Errorf("stored copy not redacted: %s", stored)
+	}
+}
+
+func TestValidateRejectsInvalidRules(t *testing.T) {
+	cases := []domain.RedactionRule{
+		{Type: domain.RedactionRuleTypeJSONPath, Pattern: "messages.content"},
+		{Type: domain.RedactionRuleTypeJSONPath, Pattern: "$.a[x]"},
+		{Type: domain.RedactionRuleTypeRegex, Pattern: "("},
+		{Type: "xpath", Pattern: "//a"},
+		{Type: domain.RedactionRuleTypeRegex, Pattern: "a", Target: "headers"},
+	}
+	for _, rule := range cases {
+		if err := Validate([]domain.RedactionRule{rule}); err == nil {
+			t.Errorf("expected error for rule %+v", rule)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+// Package replay turns a captured client request, a curl command or a HAR entry,
+// back into an HTTP request that can be run through the proxy again.
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Tag marks replayed requests in the request log and the tag stats
+const Tag = "replay"
+
+// Request is a captured client request
+type Request struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+}
+
+// droppedHeaders are connection-level headers that belong to the captured
+// connection, not to the request, and are not replayed
+var droppedHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Connection":        true,
+	"Transfer-Encoding": true,
+	"Keep-Alive":        true,
+}
+
+func newRequest(method, rawURL string, header http.Header, body []byte) (*Request, error) {
+	if rawURL == "" {
+		return nil, errors.New("no URL in the captured request")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	if method == "" {
+		method = http.MethodGet
+		if len(body) > 0 {
+			method = http.MethodPost
+		}
+	}
+	for name := range header {
+		// HTTP/2 pseudo headers (:authority, :path, ...) show up in browser captures
+		if strings.HasPrefix(name, ":") || droppedHeaders[name] {
+			header.Del(name)
+		}
+	}
+	return &Request{
+		Method: strings.ToUpper(method),
+		URL:    u,
+		Header: header,
+		Body:   body,
+	}, nil
+}
+
+// ParseHAR parses a HAR capture: a whole HAR file (its first entry is used),
+// a single entry, or an entry's request object
+func ParseHAR(data []byte) (*Request, error) {
+	var har struct {
+		Log *struct {
+			Entries []json.RawMessage `json:"entries"`
+		} `json:"log"`
+		Request json.RawMessage `json:"request"`
+	}
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("invalid HAR: %w", err)
+	}
+	switch {
+	case har.Log != nil:
+		if len(har.Log.Entries) == 0 {
+			return nil, errors.New("HAR has no entries")
+		}
+		return ParseHAR(har.Log.Entries[0])
+	case har.Request != nil:
+		data = har.Request
+	}
+
+	var req struct {
+		Method  string `json:"method"`
+		URL     string `json:"url"`
+		Headers []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"headers"`
+		PostData *struct {
+			Text string `json:"text"`
+		} `json:"postData"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid HAR request: %w", err)
+	}
+	header := make(http.Header)
+	for _, h := range req.Headers {
+		header.Add(h.Name, h.Value)
+	}
+	var body []byte
+	if req.PostData != nil {
+		body = []byte(req.PostData.Text)
+	}
+	return newRequest(req.Method, req.URL, header, body)
+}
+
+// ParseCurl parses a curl command line as copied from a browser, a terminal or
+// a log. Only the options that shape the request are understood, output and
+// connection options are ignored, anything else is an error so that a request
+// is never replayed differently from how it was captured.
+func ParseCurl(command string) (*Request, error) {
+	args, err := splitArgs(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		return nil, errors.New("not a curl command")
+	}
+
+	var method, rawURL string
+	var data []string
+	header := make(http.Header)
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if rawURL != "" {
+				return nil, fmt.Errorf("more than one URL: %q and %q", rawURL, arg)
+			}
+			rawURL = arg
+			continue
+		}
+
+		name, value, hasValue := arg, "", false
+		if strings.HasPrefix(arg, "--") {
+			name, value, hasValue = strings.Cut(arg, "=")
+		} else if len(arg) > 2 && curlValueOptions[arg[:2]] {
+			// Short options can carry their value: -XPOST
+			name, value, hasValue = arg[:2], arg[2:], true
+		}
+		if curlValueOptions[name] && !hasValue {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("option %s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		switch name {
+		case "-X", "--request":
+			method = value
+		case "-H", "--header":
+			key, val, ok := strings.Cut(value, ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid header %q", value)
+			}
+			header.Add(strings.TrimSpace(key), strings.TrimSpace(val))
+		case "-d", "--data", "--data-ascii", "--data-binary":
+			if strings.HasPrefix(value, "@") {
+				return nil, fmt.Errorf("%s %s reads a file, paste the body inline instead", name, value)
+			}
+			data = append(data, value)
+		case "--data-raw":
+			data = append(data, value)
+		case "--json":
+			data = append(data, value)
+			if header.Get("Content-Type") == "" {
+				header.Set("Content-Type", "application/json")
+			}
+			if header.Get("Accept") == "" {
+				header.Set("Accept", "application/json")
+			}
+		case "-A", "--user-agent":
+			header.Set("User-Agent", value)
+		case "--url":
+			rawURL = value
+		default:
+			if !curlIgnoredOptions[name] && !curlValueOptions[name] {
+				return nil, fmt.Errorf("unsupported curl option %s", name)
+			}
+		}
+	}
+
+	var body []byte
+	if len(data) > 0 {
+		// curl joins repeated data options with &
+		body = []byte(strings.Join(data, "&"))
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	return newRequest(method, rawURL, header, body)
+}
+
+// curlValueOptions are the options followed by a value
+var curlValueOptions = map[string]bool{
+	"-X":            true,
+	"--request":     true,
+	"-H":            true,
+	"--header":      true,
+	"-d":            true,
+	"--data":        true,
+	"--data-raw":    true,
+	"--data-ascii":  true,
+	"--data-binary": true,
+	"--json":        true,
+	"-A":            true,
+	"--user-agent":  true,
+	"--url":         true,
+	// Ignored
+	"-o":                true,
+	"--output":          true,
+	"-m":                true,
+	"--max-time":        true,
+	"--connect-timeout": true,
+	"-w":                true,
+	"--write-out":       true,
+	"--retry":           true,
+}
+
+// curlIgnoredOptions don't change the request, only how curl sends it or shows the response
+var curlIgnoredOptions = map[string]bool{
+	"-s":           true,
+	"--silent":     true,
+	"-S":           true,
+	"--show-error": true,
+	"-sS":          true,
+	"-v":           true,
+	"--verbose":    true,
+	"-i":           true,
+	"--include":    true,
+	"-k":           true,
+	"--insecure":   true,
+	"-L":           true,
+	"--location":   true,
+	"-N":           true,
+	"--no-buffer":  true,
+	"-f":           true,
+	"--fail":       true,
+	"--compressed": true,
+	"--http1.1":    true,
+	"--http2":      true,
+}
+
+// splitArgs splits a shell command line into words, handling single quotes,
+// double quotes, ANSI-C $'...' quotes (used by Chrome's "Copy as cURL"),
+// backslash escapes and line continuations
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && (s[i+1] == '\n' || s[i+1] == '\r'):
+			// Line continuation
+			i++
+			if s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\\':
+			inWord = true
+			if i+1 < len(s) {
+				i++
+				word.WriteByte(s[i])
+			}
+		case c == '\'':
+			inWord = true
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			inWord = true
+			n, err := readANSIQuoted(s[i+2:], &word)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 2
+		case c == '"':
+			inWord = true
+			closed := false
+			for i++; i < len(s); i++ {
+				if s[i] == '"' {
+					closed = true
+					break
+				}
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`\n", s[i+1]) >= 0 {
+					i++
+				}
+				word.WriteByte(s[i])
+			}
+			if !closed {
+				return nil, errors.New("unterminated double quote")
+			}
+		default:
+			inWord = true
+			word.WriteByte(c)
+		}
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
+
+// readANSIQuoted reads the body of a $'...' word up to and including the
+// closing quote, returning the number of bytes consumed
+func readANSIQuoted(s string, word *strings.Builder) (int, error) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\'' {
+			return i + 1, nil
+		}
+		if c != '\\' || i+1 >= len(s) {
+			word.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			word.WriteByte('\n')
+		case 't':
+			word.WriteByte('\t')
+		case 'r':
+			word.WriteByte('\r')
+		case 'u', 'x':
+			// \uXXXX and \xHH
+			size := 4
+			if s[i] == 'x' {
+				size = 2
+			}
+			var r rune
+			if i+size >= len(s) {
+				return 0, errors.New("truncated escape in $'...' quote")
+			}
+			if _, err := fmt.Sscanf(s[i+1:i+1+size], "%x", &r); err != nil {
+				return 0, fmt.Errorf("invalid escape in $'...' quote: %w", err)
+			}
+			if size == 2 {
+				word.WriteByte(byte(r))
+			} else {
+				word.WriteRune(r)
+			}
+			i += size
+		default:
+			// \\, \', \" and anything else stand for themselves
+			word.WriteByte(s[i])
+		}
+	}
+	return 0, errors.New("unterminated $'...' quote")
+}
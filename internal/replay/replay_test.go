@@ -0,0 +1,116 @@
+package replay
+
+import (
+	"strings"
+	"testing"
+)
+
+// As copied with Chrome's "Copy as cURL (bash)"
+const sampleCurl = `curl 'https://maxx.example.com/v1/messages' \
+  -H 'content-type: application/json' \
+  -H 'x-api-key: maxx_test' \
+  -H 'anthropic-version: 2023-06-01' \
+  --data-raw $'{"model":"claude-sonnet-4-5","max_tokens":64,"messages":[{"role":"user","content":"It\'s café time\\n"}]}' \
+  --compressed`
+
+const sampleHAR = `{"log":{"version":"1.2","entries":[{
+"startedDateTime":"2026-10-01T10:00:00.000Z",
+"request":{"method":"POST","url":"https://maxx.example.com/my-project/v1/chat/completions?trace=1",
+"httpVersion":"HTTP/2","headers":[
+{"name":":authority","value":"maxx.example.com"},
+{"name":"authorization","value":"Bearer maxx_test"},
+{"name":"content-type","value":"application/json"},
+{"name":"content-length","value":"61"}],
+"postData":{"mimeType":"application/json","text":"{\"model\":\"gpt-4o\",\"stream\":true,\"messages\":[]}"}},
+"response":{"status":200}}]}}`
+
+func TestParseCurl(t *testing.T) {
+	req, err := ParseCurl(sampleCurl)
+	if err != nil {
+		t.Fatalf("ParseCurl: %v", err)
+	}
+	if req.Method != "POST" || req.URL.Path != "/v1/messages" {
+		t.Errorf("request = %s %s, want POST /v1/messages", req.Method, req.URL)
+	}
+	if got := req.Header.Get("X-Api-Key"); got != "maxx_test" {
+		t.Errorf("x-api-key = %q", got)
+	}
+	if got := req.Header.Get("Anthropic-Version"); got != "2023-06-01" {
+		t.Errorf("anthropic-version = %q", got)
+	}
+	want := `{"model":"claude-sonnet-4-5","max_tokens":64,"messages":[{"role":"user","content":"It's café time\n"}]}`
+	if string(req.Body) != want {
+		t.Errorf("body = %s, want %s", req.Body, want)
+	}
+}
+
+func TestParseCurlOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		method  string
+		body    string
+		err     string
+	}{
+		{"explicit method", `curl -XPUT http://localhost/v1/messages -d '{}'`, "PUT", "{}", ""},
+		{"double quotes", `curl -s "http://localhost/responses" -H "Content-Type: application/json" --data "{\"input\":\"hi\"}"`, "POST", `{"input":"hi"}`, ""},
+		{"json option", `curl --json '{"a":1}' --url=http://localhost/v1/chat/completions`, "POST", `{"a":1}`, ""},
+		{"no body", `curl http://localhost/v1beta/models/gemini-2.5-pro:generateContent`, "GET", "", ""},
+		{"body from file", `curl http://localhost/v1/messages -d @body.json`, "", "", "reads a file"},
+		{"unknown option", `curl http://localhost/v1/messages --proxy http://p:8080`, "", "", "unsupported curl option --proxy"},
+		{"not curl", `wget http://localhost/v1/messages`, "", "", "not a curl command"},
+		{"unterminated quote", `curl 'http://localhost/v1/messages`, "", "", "unterminated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := ParseCurl(tt.command)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCurl: %v", err)
+			}
+			if req.Method != tt.method || string(req.Body) != tt.body {
+				t.Errorf("request = %s %s, want %s %s", req.Method, req.Body, tt.method, tt.body)
+			}
+		})
+	}
+}
+
+func TestParseHAR(t *testing.T) {
+	req, err := ParseHAR([]byte(sampleHAR))
+	if err != nil {
+		t.Fatalf("ParseHAR: %v", err)
+	}
+	if req.Method != "POST" || req.URL.RequestURI() != "/my-project/v1/chat/completions?trace=1" {
+		t.Errorf("request = %s %s", req.Method, req.URL.RequestURI())
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer maxx_test" {
+		t.Errorf("authorization = %q", got)
+	}
+	// Pseudo and connection headers are not replayed
+	if len(req.Header) != 2 {
+		t.Errorf("headers = %v, want authorization and content-type only", req.Header)
+	}
+	if string(req.Body) != `{"model":"gpt-4o","stream":true,"messages":[]}` {
+		t.Errorf("body = %s", req.Body)
+	}
+
+	// A single entry or its request parse the same
+	for _, entry := range []string{
+		`{"request":{"method":"GET","url":"http://localhost/v1/messages","headers":[]}}`,
+		`{"method":"GET","url":"http://localhost/v1/messages","headers":[]}`,
+	} {
+		req, err := ParseHAR([]byte(entry))
+		if err != nil || req.Method != "GET" || req.URL.Path != "/v1/messages" {
+			t.Errorf("ParseHAR(%s) = %+v, %v", entry, req, err)
+		}
+	}
+
+	if _, err := ParseHAR([]byte(`{"log":{"entries":[]}}`)); err == nil {
+		t.Error("empty HAR parsed")
+	}
+}
@@ -85,6 +85,11 @@ func (r *ProviderRepository) List() ([]*domain.Provider, error) {
 	return list, nil
 }
 
+// ReencryptSecrets only rewrites stored credentials, the cached providers keep their plaintext
+func (r *ProviderRepository) ReencryptSecrets() (int, error) {
+	return r.repo.ReencryptSecrets()
+}
+
 func (r *ProviderRepository) GetAll() map[uint64]*domain.Provider {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
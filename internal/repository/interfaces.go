@@ -12,6 +12,8 @@ type ProviderRepository interface {
 	Delete(id uint64) error
 	GetByID(id uint64) (*domain.Provider, error)
 	List() ([]*domain.Provider, error)
+	// ReencryptSecrets 用当前主密钥重新加密所有供应商（含已删除）的凭证，返回改写的供应商数
+	ReencryptSecrets() (int, error)
 }
 
 type RouteRepository interface {
@@ -67,9 +69,11 @@ type ProxyRequestRepository interface {
 	// ListCursor 基于游标的分页查询
 	// before: 获取 id < before 的记录 (向后翻页)
 	// after: 获取 id > after 的记录 (向前翻页/获取新数据)
-	ListCursor(limit int, before, after uint64) ([]*domain.ProxyRequest, error)
+	ListCursor(limit int, before, after uint64, filter *domain.ProxyRequestFilter) ([]*domain.ProxyRequest, error)
 	// ListActive 获取所有活跃请求 (PENDING 或 IN_PROGRESS 状态)
 	ListActive() ([]*domain.ProxyRequest, error)
+	// ListBySessionID 获取指定 sessionID 的请求（含请求/响应内容），按开始时间升序，最多 limit 条
+	ListBySessionID(sessionID string, limit int) ([]*domain.ProxyRequest, error)
 	Count() (int64, error)
 	// UpdateProjectIDBySessionID 批量更新指定 sessionID 的所有请求的 projectID
 	UpdateProjectIDBySessionID(sessionID string, projectID uint64) (int64, error)
@@ -115,8 +119,8 @@ type UsageStatsRepository interface {
 	Query(filter UsageStatsFilter) ([]*domain.UsageStats, error)
 	// QueryWithRealtime 查询统计数据并合并当前周期的实时数据
 	QueryWithRealtime(filter UsageStatsFilter) ([]*domain.UsageStats, error)
-	// QueryDashboardData 查询 Dashboard 所需的所有数据（单次请求，并发执行）
-	QueryDashboardData() (*domain.DashboardData, error)
+	// QueryDashboardData 查询 Dashboard 所需的所有数据（单次请求，并发执行），projectID 为 nil 表示全局
+	QueryDashboardData(projectID *uint64) (*domain.DashboardData, error)
 	// GetSummary 获取汇总统计数据（总计）
 	GetSummary(filter UsageStatsFilter) (*domain.UsageStatsSummary, error)
 	// GetSummaryByProvider 按 Provider 维度获取汇总统计
@@ -129,12 +133,24 @@ type UsageStatsRepository interface {
 	GetSummaryByAPIToken(filter UsageStatsFilter) (map[uint64]*domain.UsageStatsSummary, error)
 	// GetSummaryByClientType 按 ClientType 维度获取汇总统计
 	GetSummaryByClientType(filter UsageStatsFilter) (map[string]*domain.UsageStatsSummary, error)
+	// GetSummaryByClientApp 按客户端应用维度获取汇总统计（基于 proxy_requests）
+	GetSummaryByClientApp(filter UsageStatsFilter) (map[string]*domain.UsageStatsSummary, error)
+	// GetSummaryByTag 按成本分摊标签维度获取汇总统计（基于 proxy_request_tags）
+	GetSummaryByTag(filter UsageStatsFilter) (map[string]*domain.UsageStatsSummary, error)
+	// GetEmptyResponseStats 按供应商和客户端类型统计成功但没有内容的响应（基于 proxy_upstream_attempts）
+	GetEmptyResponseStats(filter UsageStatsFilter) ([]*domain.EmptyResponseStats, error)
+	// GetUpstreamTimingStats 按供应商统计上游请求各阶段的平均耗时（基于开启采集后的 proxy_upstream_attempts）
+	GetUpstreamTimingStats(filter UsageStatsFilter) ([]*domain.UpstreamTimingStats, error)
 	// DeleteOlderThan 删除指定粒度下指定时间之前的统计记录
 	DeleteOlderThan(granularity domain.Granularity, before time.Time) (int64, error)
 	// GetLatestTimeBucket 获取指定粒度的最新时间桶
 	GetLatestTimeBucket(granularity domain.Granularity) (*time.Time, error)
 	// GetProviderStats 获取 Provider 统计数据
 	GetProviderStats(clientType string, projectID uint64) (map[uint64]*domain.ProviderStats, error)
+	// GetModelStats 按模型维度获取成功率、耗时、Token、成本及错误原因分布
+	GetModelStats(filter UsageStatsFilter) ([]*domain.ModelStats, error)
+	// GetProviderReport 获取 start 之后各 Provider 的请求量、成功率、耗时分位数、Token 和成本
+	GetProviderReport(start time.Time) (map[uint64]*domain.ProviderReport, error)
 	// AggregateMinute 从原始数据聚合到分钟级别
 	AggregateMinute() (int, error)
 	// RollUp 从细粒度上卷到粗粒度
@@ -154,6 +170,8 @@ type UsageStatsFilter struct {
 	APITokenID  *uint64            // API Token ID
 	ClientType  *string            // 客户端类型
 	Model       *string            // 模型名称
+
+	UpdatedSince *time.Time // 仅返回该时间之后有变化的时间桶
 }
 
 type APITokenRepository interface {
@@ -191,3 +209,53 @@ type ResponseModelRepository interface {
 	// ListNames 获取所有 response model 名称
 	ListNames() ([]string, error)
 }
+
+type MessageBatchRepository interface {
+	// Create 在同一事务中创建批次及其全部请求
+	Create(batch *domain.MessageBatch, items []*domain.MessageBatchItem) error
+	Update(batch *domain.MessageBatch) error
+	// Delete 删除批次及其全部请求
+	Delete(id uint64) error
+	GetByID(id uint64) (*domain.MessageBatch, error)
+	GetByBatchID(batchID string) (*domain.MessageBatch, error)
+	// List 按 ID 倒序分页列出批次，apiTokenID 为 0 时不按 Token 过滤
+	// before: 获取 id < before 的记录，after: 获取 id > after 的记录
+	List(apiTokenID uint64, limit int, before, after uint64) ([]*domain.MessageBatch, error)
+	// ListUnfinished 列出尚未结束的批次
+	ListUnfinished() ([]*domain.MessageBatch, error)
+	// ListItems 按提交顺序列出批次中的请求
+	ListItems(messageBatchID uint64) ([]*domain.MessageBatchItem, error)
+	// ClaimNextItem 取出最早的、所属批次仍在处理中的 pending 请求并标记为 processing，没有时返回 nil
+	ClaimNextItem() (*domain.MessageBatchItem, error)
+	UpdateItem(item *domain.MessageBatchItem) error
+	// UpdateItemsStatus 将批次中处于 from 状态的请求批量改为 to 状态，返回更新数量
+	UpdateItemsStatus(messageBatchID uint64, from []string, to string) (int64, error)
+	// CountItems 统计批次内各状态的请求数量
+	CountItems(messageBatchID uint64) (map[string]uint64, error)
+}
+
+type ProviderEventRepository interface {
+	Create(event *domain.ProviderEvent) error
+	// List 按发生时间倒序列出供应商事件，providerID 为 0 时列出全部供应商
+	// since/until 为零值时不限制该端
+	List(providerID uint64, since, until time.Time, limit int) ([]*domain.ProviderEvent, error)
+	// DeleteOlderThan 删除指定时间之前的事件
+	DeleteOlderThan(before time.Time) (int64, error)
+}
+
+// ConfigRepositories 配置导入写入的仓库
+type ConfigRepositories struct {
+	Providers         ProviderRepository
+	Routes            RouteRepository
+	Projects          ProjectRepository
+	RetryConfigs      RetryConfigRepository
+	RoutingStrategies RoutingStrategyRepository
+	Settings          SystemSettingRepository
+	APITokens         APITokenRepository
+	ModelMappings     ModelMappingRepository
+}
+
+// ConfigTransactor 在单个数据库事务中执行 fn，fn 返回 nil 时提交，否则回滚
+type ConfigTransactor interface {
+	ConfigTransaction(fn func(repos *ConfigRepositories) error) error
+}
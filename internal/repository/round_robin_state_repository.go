@@ -0,0 +1,10 @@
+package repository
+
+// RoundRobinStateRepository persists round-robin routing counters so rotation survives restarts
+type RoundRobinStateRepository interface {
+	// GetAll retrieves all counters keyed by rotation key ("projectID:clientType")
+	GetAll() (map[string]uint64, error)
+
+	// SaveAll inserts or updates the given counters
+	SaveAll(counters map[string]uint64) error
+}
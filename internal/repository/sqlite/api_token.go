@@ -34,12 +34,16 @@ func (r *APITokenRepository) Update(t *domain.APIToken) error {
 	return r.db.gorm.Model(&APIToken{}).
 		Where("id = ?", t.ID).
 		Updates(map[string]any{
-			"updated_at":  toTimestamp(t.UpdatedAt),
-			"name":        t.Name,
-			"description": LongText(t.Description),
-			"project_id":  t.ProjectID,
-			"is_enabled":  boolToInt(t.IsEnabled),
-			"expires_at":  toTimestampPtr(t.ExpiresAt),
+			"updated_at":   toTimestamp(t.UpdatedAt),
+			"name":         t.Name,
+			"description":  LongText(t.Description),
+			"project_id":   t.ProjectID,
+			"is_enabled":   boolToInt(t.IsEnabled),
+			"admin_access": boolToInt(t.AdminAccess),
+			"global_admin": boolToInt(t.GlobalAdmin),
+			"expires_at":   toTimestampPtr(t.ExpiresAt),
+
+			"default_client_type": string(t.DefaultClientType),
 		}).Error
 }
 
@@ -115,9 +119,13 @@ func (r *APITokenRepository) toModel(t *domain.APIToken) *APIToken {
 		Description: LongText(t.Description),
 		ProjectID:   t.ProjectID,
 		IsEnabled:   boolToInt(t.IsEnabled),
+		AdminAccess: boolToInt(t.AdminAccess),
+		GlobalAdmin: boolToInt(t.GlobalAdmin),
 		ExpiresAt:   toTimestampPtr(t.ExpiresAt),
 		LastUsedAt:  toTimestampPtr(t.LastUsedAt),
 		UseCount:    t.UseCount,
+
+		DefaultClientType: string(t.DefaultClientType),
 	}
 }
 
@@ -133,9 +141,13 @@ func (r *APITokenRepository) toDomain(m *APIToken) *domain.APIToken {
 		Description: string(m.Description),
 		ProjectID:   m.ProjectID,
 		IsEnabled:   m.IsEnabled == 1,
+		AdminAccess: m.AdminAccess == 1,
+		GlobalAdmin: m.GlobalAdmin == 1,
 		ExpiresAt:   fromTimestampPtr(m.ExpiresAt),
 		LastUsedAt:  fromTimestampPtr(m.LastUsedAt),
 		UseCount:    m.UseCount,
+
+		DefaultClientType: domain.ClientType(m.DefaultClientType),
 	}
 }
 
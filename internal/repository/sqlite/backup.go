@@ -0,0 +1,145 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// redactedPlaceholder replaces secrets in sanitized snapshots
+const redactedPlaceholder = "[REDACTED]"
+
+// providerSecretKeys are the provider config fields holding credentials, at any depth
+var providerSecretKeys = map[string]bool{
+	"apiKey":          true,
+	"accessKeyID":     true,
+	"secretAccessKey": true,
+	"sessionToken":    true,
+	"refreshToken":    true,
+	"clientSecret":    true,
+}
+
+// secretSettingKeys are system settings that may carry credentials
+var secretSettingKeys = []string{
+	domain.SettingKeyStatsExportAuth,
+	domain.SettingKeyStatsExportURL,
+	domain.SettingKeySpendShareWebhook,
+	domain.SettingKeyRequestLogSink,
+	domain.SettingKeyRequestLogSinkAuth,
+	domain.SettingKeyDailyReportWebhooks,
+}
+
+// bodyColumns are the columns holding request and response bodies, cleared in sanitized snapshots
+var bodyColumns = map[string][]string{
+	"proxy_requests":          {"request_info", "response_info"},
+	"proxy_upstream_attempts": {"request_info", "response_info"},
+	"message_batches":         {"request_headers"},
+	"message_batch_items":     {"params", "result"},
+}
+
+// Snapshot writes a consistent copy of the SQLite database to path, which must not exist.
+// VACUUM INTO reads the database in a single transaction, so the copy is consistent under
+// live traffic and, in WAL mode, writers are not blocked while it runs.
+// With sanitize, credentials are redacted and request/response bodies cleared in the copy.
+func (d *DB) Snapshot(path string, sanitize bool) error {
+	if d.dialector != "sqlite" {
+		return fmt.Errorf("snapshot is not supported for %s databases", d.dialector)
+	}
+	if err := d.gorm.Exec("VACUUM INTO ?", path).Error; err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	if !sanitize {
+		return nil
+	}
+	return sanitizeSnapshot(path)
+}
+
+// sanitizeSnapshot redacts a snapshot in place, then vacuums it so the removed
+// data doesn't survive in free pages
+func sanitizeSnapshot(path string) error {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := redactProviderConfigs(tx); err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE api_tokens SET token = ?", redactedPlaceholder).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE antigravity_quotas SET email = 'redacted-' || id, picture = ''").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE system_settings SET value = ? WHERE setting_key IN ? AND value <> ''", redactedPlaceholder, secretSettingKeys).Error; err != nil {
+			return err
+		}
+		for table, columns := range bodyColumns {
+			for _, column := range columns {
+				if err := tx.Exec(fmt.Sprintf("UPDATE %s SET %s = ''", table, column)).Error; err != nil {
+					return err
+				}
+			}
+		}
+		// The index triggers only add delete markers, the bodies' postings stay in the
+		// index segments until it is rebuilt from the cleared rows
+		if tx.Migrator().HasTable("proxy_requests_fts") {
+			for _, command := range []string{"delete-all", "rebuild"} {
+				if err := tx.Exec("INSERT INTO proxy_requests_fts(proxy_requests_fts) VALUES (?)", command).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to sanitize snapshot: %w", err)
+	}
+	return db.Exec("VACUUM").Error
+}
+
+func redactProviderConfigs(tx *gorm.DB) error {
+	var providers []Provider
+	if err := tx.Select("id", "config").Find(&providers).Error; err != nil {
+		return err
+	}
+	for _, p := range providers {
+		var config interface{}
+		if p.Config == "" || json.Unmarshal([]byte(p.Config), &config) != nil {
+			continue
+		}
+		redactSecrets(config)
+		if err := tx.Model(&Provider{}).Where("id = ?", p.ID).UpdateColumn("config", LongText(toJSON(config))).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redactSecrets replaces non-empty credential fields in a decoded JSON value
+func redactSecrets(v interface{}) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for key, value := range node {
+			if s, ok := value.(string); ok && providerSecretKeys[key] && s != "" {
+				node[key] = redactedPlaceholder
+				continue
+			}
+			redactSecrets(value)
+		}
+	case []interface{}:
+		for _, item := range node {
+			redactSecrets(item)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package sqlite
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func seedBackupDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	provider := &domain.Provider{
+		Type: "custom",
+		Name: "relay",
+		Config: &domain.ProviderConfig{Custom: &domain.ProviderConfigCustom{
+			BaseURL: "https://relay.example.com",
+			APIKey:  "sk-provider-secret",
+		}},
+	}
+	if err := NewProviderRepository(db).Create(provider); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewAPITokenRepository(db).Create(&domain.APIToken{Name: "ci", Token: "maxx_token_secret", IsEnabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewSystemSettingRepository(db).Set(domain.SettingKeyStatsExportAuth, "Token export-secret"); err != nil {
+		t.Fatal(err)
+	}
+	request := &domain.ProxyRequest{
+		Status:      "COMPLETED",
+		RequestInfo: &domain.RequestInfo{Method: "POST", Body: `{"prompt":"private prompt"}`},
+	}
+	if err := NewProxyRequestRepository(db).Create(request); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func openSnapshot(t *testing.T, path string) *gorm.DB {
+	t.Helper()
+	snapshot, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := snapshot.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return snapshot
+}
+
+func TestSnapshotContainsAllTables(t *testing.T) {
+	db := seedBackupDB(t)
+
+	// Writers keep going while the snapshot is taken
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		repo := NewProxyRequestRepository(db)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				_ = repo.Create(&domain.ProxyRequest{Status: "IN_PROGRESS"})
+			}
+		}
+	}()
+
+	path := filepath.Join(t.TempDir(), "backup.db")
+	err := db.Snapshot(path, false)
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := openSnapshot(t, path)
+	for _, model := range AllModels() {
+		if !snapshot.Migrator().HasTable(model) {
+			t.Errorf("snapshot is missing table for %T", model)
+		}
+	}
+	var result string
+	if err := snapshot.Raw("PRAGMA integrity_check").Scan(&result).Error; err != nil || result != "ok" {
+		t.Errorf("integrity_check = %q, %v", result, err)
+	}
+	var token string
+	snapshot.Raw("SELECT token FROM api_tokens").Scan(&token)
+	if token != "maxx_token_secret" {
+		t.Errorf("full snapshot token = %q, want the original", token)
+	}
+
+	if err := db.Snapshot(path, false); err == nil {
+		t.Error("snapshot over an existing file should fail")
+	}
+}
+
+func TestSnapshotSanitized(t *testing.T) {
+	db := seedBackupDB(t)
+	path := filepath.Join(t.TempDir(), "backup.db")
+	if err := db.Snapshot(path, true); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"sk-provider-secret", "maxx_token_secret", "export-secret", "private prompt"} {
+		if strings.Contains(string(raw), secret) {
+			t.Errorf("sanitized snapshot still contains %q", secret)
+		}
+	}
+
+	snapshot := openSnapshot(t, path)
+	var config string
+	snapshot.Raw("SELECT config FROM providers").Scan(&config)
+	if !strings.Contains(config, `"apiKey":"[REDACTED]"`) || !strings.Contains(config, "https://relay.example.com") {
+		t.Errorf("provider config = %s, want the key redacted and the rest kept", config)
+	}
+	var requests int64
+	snapshot.Table("proxy_requests").Count(&requests)
+	if requests != 1 {
+		t.Errorf("sanitized snapshot has %d requests, want the row kept without its body", requests)
+	}
+}
+
+func TestSnapshotSanitizedSearchIndex(t *testing.T) {
+	db := seedBackupDB(t)
+	path := filepath.Join(t.TempDir(), "backup.db")
+	if err := db.Snapshot(path, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// The full-text index must not keep the trigrams of the cleared bodies
+	snapshot := openSnapshot(t, path)
+	var blocks [][]byte
+	if err := snapshot.Raw("SELECT block FROM proxy_requests_fts_data").Scan(&blocks).Error; err != nil {
+		t.Fatal(err)
+	}
+	var index []byte
+	for _, block := range blocks {
+		index = append(index, block...)
+	}
+	body := "private prompt"
+	for i := 0; i+3 <= len(body); i++ {
+		if trigram := body[i : i+3]; strings.Contains(string(index), trigram) {
+			t.Errorf("search index of the sanitized snapshot still holds body trigram %q", trigram)
+		}
+	}
+}
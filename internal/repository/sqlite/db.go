@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/awsl-project/maxx/internal/repository"
 	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
@@ -110,6 +111,23 @@ func (d *DB) autoMigrate() error {
 	return d.gorm.AutoMigrate(AllModels()...)
 }
 
+// ConfigTransaction 在单个事务中执行 fn，传入的仓库都绑定到该事务
+func (d *DB) ConfigTransaction(fn func(repos *repository.ConfigRepositories) error) error {
+	return d.gorm.Transaction(func(tx *gorm.DB) error {
+		txDB := &DB{gorm: tx, dialector: d.dialector}
+		return fn(&repository.ConfigRepositories{
+			Providers:         NewProviderRepository(txDB),
+			Routes:            NewRouteRepository(txDB),
+			Projects:          NewProjectRepository(txDB),
+			RetryConfigs:      NewRetryConfigRepository(txDB),
+			RoutingStrategies: NewRoutingStrategyRepository(txDB),
+			Settings:          NewSystemSettingRepository(txDB),
+			APITokens:         NewAPITokenRepository(txDB),
+			ModelMappings:     NewModelMappingRepository(txDB),
+		})
+	})
+}
+
 func (d *DB) Close() error {
 	sqlDB, err := d.gorm.DB()
 	if err != nil {
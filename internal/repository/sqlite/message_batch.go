@@ -0,0 +1,267 @@
+package sqlite
+
+import (
+	"errors"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"gorm.io/gorm"
+)
+
+type MessageBatchRepository struct {
+	db *DB
+}
+
+func NewMessageBatchRepository(db *DB) *MessageBatchRepository {
+	return &MessageBatchRepository{db: db}
+}
+
+func (r *MessageBatchRepository) Create(batch *domain.MessageBatch, items []*domain.MessageBatchItem) error {
+	now := time.Now()
+	batch.CreatedAt = now
+	batch.UpdatedAt = now
+
+	return r.db.gorm.Transaction(func(tx *gorm.DB) error {
+		model := r.toModel(batch)
+		if err := tx.Create(model).Error; err != nil {
+			return err
+		}
+		batch.ID = model.ID
+
+		if len(items) == 0 {
+			return nil
+		}
+		models := make([]*MessageBatchItem, len(items))
+		for i, item := range items {
+			item.MessageBatchID = batch.ID
+			item.CreatedAt = now
+			item.UpdatedAt = now
+			models[i] = r.toItemModel(item)
+		}
+		// 分批插入，避免超出 SQL 参数数量限制
+		if err := tx.CreateInBatches(models, 100).Error; err != nil {
+			return err
+		}
+		for i, m := range models {
+			items[i].ID = m.ID
+		}
+		return nil
+	})
+}
+
+func (r *MessageBatchRepository) Update(batch *domain.MessageBatch) error {
+	batch.UpdatedAt = time.Now()
+	return r.db.gorm.Save(r.toModel(batch)).Error
+}
+
+func (r *MessageBatchRepository) Delete(id uint64) error {
+	return r.db.gorm.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("message_batch_id = ?", id).Delete(&MessageBatchItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&MessageBatch{}, id).Error
+	})
+}
+
+func (r *MessageBatchRepository) GetByID(id uint64) (*domain.MessageBatch, error) {
+	var model MessageBatch
+	if err := r.db.gorm.First(&model, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return r.toDomain(&model), nil
+}
+
+func (r *MessageBatchRepository) GetByBatchID(batchID string) (*domain.MessageBatch, error) {
+	var model MessageBatch
+	if err := r.db.gorm.Where("batch_id = ?", batchID).First(&model).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return r.toDomain(&model), nil
+}
+
+func (r *MessageBatchRepository) List(apiTokenID uint64, limit int, before, after uint64) ([]*domain.MessageBatch, error) {
+	query := r.db.gorm.Model(&MessageBatch{})
+	if apiTokenID > 0 {
+		query = query.Where("api_token_id = ?", apiTokenID)
+	}
+	if after > 0 {
+		query = query.Where("id > ?", after)
+	} else if before > 0 {
+		query = query.Where("id < ?", before)
+	}
+
+	var models []MessageBatch
+	if err := query.Order("id DESC").Limit(limit).Find(&models).Error; err != nil {
+		return nil, err
+	}
+	return r.toDomainList(models), nil
+}
+
+func (r *MessageBatchRepository) ListUnfinished() ([]*domain.MessageBatch, error) {
+	var models []MessageBatch
+	if err := r.db.gorm.Where("processing_status <> ?", domain.MessageBatchEnded).Order("id").Find(&models).Error; err != nil {
+		return nil, err
+	}
+	return r.toDomainList(models), nil
+}
+
+func (r *MessageBatchRepository) ListItems(messageBatchID uint64) ([]*domain.MessageBatchItem, error) {
+	var models []MessageBatchItem
+	if err := r.db.gorm.Where("message_batch_id = ?", messageBatchID).Order("id").Find(&models).Error; err != nil {
+		return nil, err
+	}
+	items := make([]*domain.MessageBatchItem, len(models))
+	for i := range models {
+		items[i] = r.toItemDomain(&models[i])
+	}
+	return items, nil
+}
+
+func (r *MessageBatchRepository) ClaimNextItem() (*domain.MessageBatchItem, error) {
+	var claimed *domain.MessageBatchItem
+	err := r.db.gorm.Transaction(func(tx *gorm.DB) error {
+		var model MessageBatchItem
+		err := tx.Where("status = ? AND message_batch_id IN (?)", domain.MessageBatchItemPending,
+			tx.Model(&MessageBatch{}).Select("id").Where("processing_status = ?", domain.MessageBatchInProgress)).
+			Order("id").First(&model).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		model.Status = domain.MessageBatchItemProcessing
+		model.UpdatedAt = toTimestamp(time.Now())
+		result := tx.Model(&MessageBatchItem{}).
+			Where("id = ? AND status = ?", model.ID, domain.MessageBatchItemPending).
+			Updates(map[string]any{"status": model.Status, "updated_at": model.UpdatedAt})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			claimed = r.toItemDomain(&model)
+		}
+		return nil
+	})
+	return claimed, err
+}
+
+func (r *MessageBatchRepository) UpdateItem(item *domain.MessageBatchItem) error {
+	item.UpdatedAt = time.Now()
+	return r.db.gorm.Save(r.toItemModel(item)).Error
+}
+
+func (r *MessageBatchRepository) UpdateItemsStatus(messageBatchID uint64, from []string, to string) (int64, error) {
+	result := r.db.gorm.Model(&MessageBatchItem{}).
+		Where("message_batch_id = ? AND status IN ?", messageBatchID, from).
+		Updates(map[string]any{"status": to, "updated_at": toTimestamp(time.Now())})
+	return result.RowsAffected, result.Error
+}
+
+func (r *MessageBatchRepository) CountItems(messageBatchID uint64) (map[string]uint64, error) {
+	var rows []struct {
+		Status string
+		Count  uint64
+	}
+	if err := r.db.gorm.Model(&MessageBatchItem{}).
+		Select("status, COUNT(*) AS count").
+		Where("message_batch_id = ?", messageBatchID).
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[string]uint64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *MessageBatchRepository) toModel(b *domain.MessageBatch) *MessageBatch {
+	return &MessageBatch{
+		BaseModel: BaseModel{
+			ID:        b.ID,
+			CreatedAt: toTimestamp(b.CreatedAt),
+			UpdatedAt: toTimestamp(b.UpdatedAt),
+		},
+		BatchID:           b.BatchID,
+		ProcessingStatus:  b.ProcessingStatus,
+		APITokenID:        b.APITokenID,
+		ProjectID:         b.ProjectID,
+		RequestHeaders:    LongText(toJSON(b.RequestHeaders)),
+		ProcessingCount:   b.RequestCounts.Processing,
+		SucceededCount:    b.RequestCounts.Succeeded,
+		ErroredCount:      b.RequestCounts.Errored,
+		CanceledCount:     b.RequestCounts.Canceled,
+		ExpiredCount:      b.RequestCounts.Expired,
+		ExpiresAt:         toTimestamp(b.ExpiresAt),
+		CancelInitiatedAt: toTimestampPtr(b.CancelInitiatedAt),
+		EndedAt:           toTimestampPtr(b.EndedAt),
+	}
+}
+
+func (r *MessageBatchRepository) toDomain(m *MessageBatch) *domain.MessageBatch {
+	return &domain.MessageBatch{
+		ID:               m.ID,
+		CreatedAt:        fromTimestamp(m.CreatedAt),
+		UpdatedAt:        fromTimestamp(m.UpdatedAt),
+		BatchID:          m.BatchID,
+		ProcessingStatus: m.ProcessingStatus,
+		APITokenID:       m.APITokenID,
+		ProjectID:        m.ProjectID,
+		RequestHeaders:   fromJSON[map[string]string](string(m.RequestHeaders)),
+		RequestCounts: domain.MessageBatchCounts{
+			Processing: m.ProcessingCount,
+			Succeeded:  m.SucceededCount,
+			Errored:    m.ErroredCount,
+			Canceled:   m.CanceledCount,
+			Expired:    m.ExpiredCount,
+		},
+		ExpiresAt:         fromTimestamp(m.ExpiresAt),
+		CancelInitiatedAt: fromTimestampPtr(m.CancelInitiatedAt),
+		EndedAt:           fromTimestampPtr(m.EndedAt),
+	}
+}
+
+func (r *MessageBatchRepository) toDomainList(models []MessageBatch) []*domain.MessageBatch {
+	batches := make([]*domain.MessageBatch, len(models))
+	for i := range models {
+		batches[i] = r.toDomain(&models[i])
+	}
+	return batches
+}
+
+func (r *MessageBatchRepository) toItemModel(item *domain.MessageBatchItem) *MessageBatchItem {
+	return &MessageBatchItem{
+		BaseModel: BaseModel{
+			ID:        item.ID,
+			CreatedAt: toTimestamp(item.CreatedAt),
+			UpdatedAt: toTimestamp(item.UpdatedAt),
+		},
+		MessageBatchID: item.MessageBatchID,
+		CustomID:       item.CustomID,
+		Params:         LongText(item.Params),
+		Status:         item.Status,
+		Result:         LongText(item.Result),
+	}
+}
+
+func (r *MessageBatchRepository) toItemDomain(m *MessageBatchItem) *domain.MessageBatchItem {
+	return &domain.MessageBatchItem{
+		ID:             m.ID,
+		CreatedAt:      fromTimestamp(m.CreatedAt),
+		UpdatedAt:      fromTimestamp(m.UpdatedAt),
+		MessageBatchID: m.MessageBatchID,
+		CustomID:       m.CustomID,
+		Params:         string(m.Params),
+		Status:         m.Status,
+		Result:         string(m.Result),
+	}
+}
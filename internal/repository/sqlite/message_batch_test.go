@@ -0,0 +1,90 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestMessageBatchClaimAndCount(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewMessageBatchRepository(db)
+
+	newBatch := func(batchID string, n int) *domain.MessageBatch {
+		t.Helper()
+		b := &domain.MessageBatch{
+			BatchID:          batchID,
+			ProcessingStatus: domain.MessageBatchInProgress,
+			RequestHeaders:   map[string]string{"anthropic-version": "2023-06-01"},
+			ExpiresAt:        time.Now().Add(domain.MessageBatchExpiry),
+		}
+		items := make([]*domain.MessageBatchItem, n)
+		for i := range items {
+			items[i] = &domain.MessageBatchItem{CustomID: string(rune('a' + i)), Params: `{}`, Status: domain.MessageBatchItemPending}
+		}
+		if err := repo.Create(b, items); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	canceled := newBatch("msgbatch_canceled", 2)
+	active := newBatch("msgbatch_active", 2)
+
+	canceled.ProcessingStatus = domain.MessageBatchCanceling
+	if err := repo.Update(canceled); err != nil {
+		t.Fatal(err)
+	}
+
+	// Items of a canceling batch are skipped
+	for _, want := range []string{"a", "b"} {
+		item, err := repo.ClaimNextItem()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item == nil || item.MessageBatchID != active.ID || item.CustomID != want {
+			t.Fatalf("claimed %+v, want item %q of the active batch", item, want)
+		}
+		if item.Status != domain.MessageBatchItemProcessing {
+			t.Errorf("claimed item status = %q, want processing", item.Status)
+		}
+	}
+	if item, err := repo.ClaimNextItem(); err != nil || item != nil {
+		t.Fatalf("ClaimNextItem() = %+v, %v, want nothing left", item, err)
+	}
+
+	n, err := repo.UpdateItemsStatus(canceled.ID, []string{domain.MessageBatchItemPending}, domain.MessageBatchItemCanceled)
+	if err != nil || n != 2 {
+		t.Fatalf("UpdateItemsStatus() = %d, %v, want 2", n, err)
+	}
+
+	counts, err := repo.CountItems(active.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[domain.MessageBatchItemProcessing] != 2 || counts[domain.MessageBatchItemPending] != 0 {
+		t.Errorf("active batch counts = %v, want 2 processing", counts)
+	}
+
+	got, err := repo.GetByBatchID("msgbatch_active")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.RequestHeaders["anthropic-version"] != "2023-06-01" {
+		t.Errorf("request headers = %v, not persisted", got.RequestHeaders)
+	}
+
+	if err := repo.Delete(canceled.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetByBatchID("msgbatch_canceled"); err != domain.ErrNotFound {
+		t.Errorf("GetByBatchID() after delete = %v, want ErrNotFound", err)
+	}
+	if items, _ := repo.ListItems(canceled.ID); len(items) != 0 {
+		t.Errorf("%d items left after delete", len(items))
+	}
+}
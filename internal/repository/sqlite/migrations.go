@@ -18,7 +18,77 @@ type Migration struct {
 
 // 所有迁移按版本号注册
 // 注意：GORM AutoMigrate 会自动处理新增列，这里只需要处理特殊情况（重命名、数据迁移等）
-var migrations = []Migration{}
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Add full-text index over proxy request errors, bodies and models",
+		Up:          createProxyRequestSearchIndex,
+		Down:        dropProxyRequestSearchIndex,
+	},
+}
+
+// proxyRequestSearchColumns 全文索引覆盖的 proxy_requests 列
+const proxyRequestSearchColumns = "request_model, response_model, error, request_info, response_info"
+
+// createProxyRequestSearchIndex 创建请求全文索引（仅 SQLite，其他数据库搜索时回退为 LIKE）
+// 索引为外部内容表，由触发器与 proxy_requests 同步：清理过期请求时索引随之删除，
+// 存储的内容已经过脱敏，索引不会包含原始敏感数据
+func createProxyRequestSearchIndex(db *gorm.DB) error {
+	if db.Dialector.Name() != "sqlite" {
+		return nil
+	}
+	statements := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS proxy_requests_fts USING fts5(
+			` + proxyRequestSearchColumns + `,
+			content='proxy_requests', content_rowid='id', tokenize='trigram'
+		)`,
+		`CREATE TRIGGER IF NOT EXISTS proxy_requests_fts_ai AFTER INSERT ON proxy_requests BEGIN
+			INSERT INTO proxy_requests_fts(rowid, ` + proxyRequestSearchColumns + `)
+			VALUES (new.id, new.request_model, new.response_model, new.error, new.request_info, new.response_info);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS proxy_requests_fts_ad AFTER DELETE ON proxy_requests BEGIN
+			INSERT INTO proxy_requests_fts(proxy_requests_fts, rowid, ` + proxyRequestSearchColumns + `)
+			VALUES ('delete', old.id, old.request_model, old.response_model, old.error, old.request_info, old.response_info);
+		END`,
+		// Update 会写回所有列，只在索引列实际变化时重建索引
+		`CREATE TRIGGER IF NOT EXISTS proxy_requests_fts_au AFTER UPDATE ON proxy_requests
+		WHEN old.request_model IS NOT new.request_model OR old.response_model IS NOT new.response_model
+			OR old.error IS NOT new.error OR old.request_info IS NOT new.request_info
+			OR old.response_info IS NOT new.response_info
+		BEGIN
+			INSERT INTO proxy_requests_fts(proxy_requests_fts, rowid, ` + proxyRequestSearchColumns + `)
+			VALUES ('delete', old.id, old.request_model, old.response_model, old.error, old.request_info, old.response_info);
+			INSERT INTO proxy_requests_fts(rowid, ` + proxyRequestSearchColumns + `)
+			VALUES (new.id, new.request_model, new.response_model, new.error, new.request_info, new.response_info);
+		END`,
+		// 为已有请求建立索引
+		`INSERT INTO proxy_requests_fts(proxy_requests_fts) VALUES ('rebuild')`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropProxyRequestSearchIndex 删除请求全文索引及其触发器
+func dropProxyRequestSearchIndex(db *gorm.DB) error {
+	if db.Dialector.Name() != "sqlite" {
+		return nil
+	}
+	for _, stmt := range []string{
+		"DROP TRIGGER IF EXISTS proxy_requests_fts_ai",
+		"DROP TRIGGER IF EXISTS proxy_requests_fts_ad",
+		"DROP TRIGGER IF EXISTS proxy_requests_fts_au",
+		"DROP TABLE IF EXISTS proxy_requests_fts",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
 // RunMigrations 运行所有待执行的迁移
 func (d *DB) RunMigrations() error {
@@ -158,16 +158,18 @@ func (r *ModelMappingRepository) toModel(mapping *domain.ModelMapping) *ModelMap
 			},
 			DeletedAt: toTimestampPtr(mapping.DeletedAt),
 		},
-		Scope:        scope,
-		ClientType:   string(mapping.ClientType),
-		ProviderType: mapping.ProviderType,
-		ProviderID:   mapping.ProviderID,
-		ProjectID:    mapping.ProjectID,
-		RouteID:      mapping.RouteID,
-		APITokenID:   mapping.APITokenID,
-		Pattern:      mapping.Pattern,
-		Target:       mapping.Target,
-		Priority:     mapping.Priority,
+		Scope:         scope,
+		ClientType:    string(mapping.ClientType),
+		ProviderType:  mapping.ProviderType,
+		ProviderID:    mapping.ProviderID,
+		ProjectID:     mapping.ProjectID,
+		RouteID:       mapping.RouteID,
+		APITokenID:    mapping.APITokenID,
+		Pattern:       mapping.Pattern,
+		Target:        mapping.Target,
+		Targets:       LongText(modelMappingTargetsToJSON(mapping.Targets)),
+		StickySession: boolToInt(mapping.StickySession),
+		Priority:      mapping.Priority,
 	}
 }
 
@@ -177,20 +179,22 @@ func (r *ModelMappingRepository) toDomain(m *ModelMapping) *domain.ModelMapping
 		scope = domain.ModelMappingScopeGlobal
 	}
 	return &domain.ModelMapping{
-		ID:           m.ID,
-		CreatedAt:    fromTimestamp(m.CreatedAt),
-		UpdatedAt:    fromTimestamp(m.UpdatedAt),
-		DeletedAt:    fromTimestampPtr(m.DeletedAt),
-		Scope:        scope,
-		ClientType:   domain.ClientType(m.ClientType),
-		ProviderType: m.ProviderType,
-		ProviderID:   m.ProviderID,
-		ProjectID:    m.ProjectID,
-		RouteID:      m.RouteID,
-		APITokenID:   m.APITokenID,
-		Pattern:      m.Pattern,
-		Target:       m.Target,
-		Priority:     m.Priority,
+		ID:            m.ID,
+		CreatedAt:     fromTimestamp(m.CreatedAt),
+		UpdatedAt:     fromTimestamp(m.UpdatedAt),
+		DeletedAt:     fromTimestampPtr(m.DeletedAt),
+		Scope:         scope,
+		ClientType:    domain.ClientType(m.ClientType),
+		ProviderType:  m.ProviderType,
+		ProviderID:    m.ProviderID,
+		ProjectID:     m.ProjectID,
+		RouteID:       m.RouteID,
+		APITokenID:    m.APITokenID,
+		Pattern:       m.Pattern,
+		Target:        m.Target,
+		Targets:       fromJSON[[]domain.ModelMappingTarget](string(m.Targets)),
+		StickySession: m.StickySession == 1,
+		Priority:      m.Priority,
 	}
 }
 
@@ -201,3 +205,11 @@ func (r *ModelMappingRepository) toDomainList(models []ModelMapping) []*domain.M
 	}
 	return mappings
 }
+
+// modelMappingTargetsToJSON 序列化加权目标，未设置时存为空字符串
+func modelMappingTargetsToJSON(targets []domain.ModelMappingTarget) string {
+	if len(targets) == 0 {
+		return ""
+	}
+	return toJSON(targets)
+}
@@ -77,6 +77,11 @@ type Project struct {
 	Name                string   `gorm:"size:255"`
 	Slug                string   `gorm:"size:128"`
 	EnabledCustomRoutes LongText
+	Guardrail           LongText
+	DefaultClientType   string `gorm:"size:64"`
+	CostPreference      string `gorm:"size:32"`
+	FallbackResponse    LongText
+	DefaultModel        string `gorm:"size:255"`
 }
 
 func (Project) TableName() string { return "projects" }
@@ -95,13 +100,21 @@ func (Session) TableName() string { return "sessions" }
 // Route model
 type Route struct {
 	SoftDeleteModel
-	IsEnabled     int    `gorm:"default:1"`
-	IsNative      int    `gorm:"default:1"`
-	ProjectID     uint64
-	ClientType    string `gorm:"size:64"`
-	ProviderID    uint64
-	Position      int
-	RetryConfigID uint64
+	IsEnabled        int `gorm:"default:1"`
+	IsNative         int `gorm:"default:1"`
+	ProjectID        uint64
+	ClientType       string `gorm:"size:64"`
+	ProviderID       uint64
+	Position         int
+	RetryConfigID    uint64
+	HedgeDelayMs     int
+	FailFast         int
+	EnsembleSize     int
+	EnsembleStrategy string `gorm:"size:16"`
+	APITokenIDs      LongText `gorm:"column:api_token_ids"`
+	Conditions       LongText
+	FallbackResponse LongText
+	DefaultModel     string `gorm:"size:255"`
 }
 
 func (Route) TableName() string { return "routes" }
@@ -138,9 +151,13 @@ type APIToken struct {
 	Description LongText
 	ProjectID   uint64
 	IsEnabled   int `gorm:"default:1"`
+	AdminAccess int
+	GlobalAdmin int
 	ExpiresAt   int64
 	LastUsedAt  int64
 	UseCount    uint64
+
+	DefaultClientType string `gorm:"size:64"`
 }
 
 func (APIToken) TableName() string { return "api_tokens" }
@@ -148,16 +165,18 @@ func (APIToken) TableName() string { return "api_tokens" }
 // ModelMapping model
 type ModelMapping struct {
 	SoftDeleteModel
-	Scope        string `gorm:"size:64;default:'global'"`
-	ClientType   string `gorm:"size:64"`
-	ProviderType string `gorm:"size:64"`
-	ProviderID   uint64
-	ProjectID    uint64
-	RouteID      uint64
-	APITokenID   uint64
-	Pattern      string `gorm:"size:255"`
-	Target       string `gorm:"size:255"`
-	Priority     int
+	Scope         string `gorm:"size:64;default:'global'"`
+	ClientType    string `gorm:"size:64"`
+	ProviderType  string `gorm:"size:64"`
+	ProviderID    uint64
+	ProjectID     uint64
+	RouteID       uint64
+	APITokenID    uint64
+	Pattern       string `gorm:"size:255"`
+	Target        string `gorm:"size:255"`
+	Targets       LongText
+	StickySession int
+	Priority      int
 }
 
 func (ModelMapping) TableName() string { return "model_mappings" }
@@ -209,10 +228,33 @@ type ProxyRequest struct {
 	StatusCode                  int
 	ProjectID                   uint64
 	APITokenID                  uint64
+	MaxRetriesOverride          *int
+	Metadata                    LongText
+	PIIFlags                    LongText `gorm:"column:pii_flags"`
+	ClientApp                   string   `gorm:"size:64;index"`
+	GuardrailFlag               string   `gorm:"size:255"`
+	Tags                        LongText
+	RouteFailures               LongText
+	EmptyResponse               int
+	FallbackResponse            int
+	ModelDefaulted              int
+	ForcedProviderID            uint64
+	Replay                      int
+	CancelReason                string `gorm:"size:64"`
 }
 
 func (ProxyRequest) TableName() string { return "proxy_requests" }
 
+// ProxyRequestTag model - 请求的成本分摊标签，每个标签一行，用于按标签汇总而不增加 usage_stats 的维度
+type ProxyRequestTag struct {
+	ID             uint64 `gorm:"primaryKey;autoIncrement"`
+	ProxyRequestID uint64 `gorm:"index"`
+	Tag            string `gorm:"size:64;index"`
+	CreatedAt      int64
+}
+
+func (ProxyRequestTag) TableName() string { return "proxy_request_tags" }
+
 // ProxyUpstreamAttempt model
 type ProxyUpstreamAttempt struct {
 	BaseModel
@@ -236,6 +278,20 @@ type ProxyUpstreamAttempt struct {
 	RequestModel      string `gorm:"size:128"`
 	MappedModel       string `gorm:"size:128"`
 	ResponseModel     string `gorm:"size:128"`
+	StatusCode        int
+	MaxTokensClampedFrom   int
+	CooldownCappedFromSecs int
+	HedgeOfAttemptID       uint64
+	EnsembleOfAttemptID    uint64
+	EnsembleChosen         int
+	EmptyResponse          int
+	DroppedParams          string `gorm:"size:255"`
+	TimingCaptured         int
+	TimingDNSUs            int64 `gorm:"column:timing_dns_us"`
+	TimingConnectUs        int64 `gorm:"column:timing_connect_us"`
+	TimingTLSUs            int64 `gorm:"column:timing_tls_us"`
+	TimingFirstByteUs      int64 `gorm:"column:timing_first_byte_us"`
+	TimingReused           int
 }
 
 func (ProxyUpstreamAttempt) TableName() string { return "proxy_upstream_attempts" }
@@ -273,10 +329,20 @@ type FailureCount struct {
 
 func (FailureCount) TableName() string { return "failure_counts" }
 
+// RoundRobinState model - 轮询路由策略的计数器，定期持久化以便重启后继续轮询
+type RoundRobinState struct {
+	BaseModel
+	StateKey string `gorm:"size:255;uniqueIndex"`
+	Counter  uint64
+}
+
+func (RoundRobinState) TableName() string { return "round_robin_states" }
+
 // UsageStats model
 type UsageStats struct {
 	ID                 uint64 `gorm:"primaryKey;autoIncrement"`
 	CreatedAt          int64
+	UpdatedAt          int64  `gorm:"autoUpdateTime:false;index"`
 	TimeBucket         int64  `gorm:"uniqueIndex:idx_usage_stats_unique"`
 	Granularity        string `gorm:"size:32;uniqueIndex:idx_usage_stats_unique;index:idx_usage_stats_granularity_time"`
 	RouteID            uint64 `gorm:"uniqueIndex:idx_usage_stats_unique;index:idx_usage_stats_route_id"`
@@ -309,6 +375,52 @@ type ResponseModel struct {
 
 func (ResponseModel) TableName() string { return "response_models" }
 
+// MessageBatch model - Message Batches API 提交的批次
+type MessageBatch struct {
+	BaseModel
+	BatchID           string `gorm:"size:64;uniqueIndex"`
+	ProcessingStatus  string `gorm:"size:32;index"`
+	APITokenID        uint64 `gorm:"index"`
+	ProjectID         uint64
+	RequestHeaders    LongText
+	ProcessingCount   uint64
+	SucceededCount    uint64
+	ErroredCount      uint64
+	CanceledCount     uint64
+	ExpiredCount      uint64
+	ExpiresAt         int64
+	CancelInitiatedAt int64
+	EndedAt           int64
+}
+
+func (MessageBatch) TableName() string { return "message_batches" }
+
+// MessageBatchItem model - 批次中的单条请求
+type MessageBatchItem struct {
+	BaseModel
+	MessageBatchID uint64 `gorm:"index"`
+	CustomID       string `gorm:"size:255"`
+	Params         LongText
+	Status         string `gorm:"size:32;index"`
+	Result         LongText
+}
+
+func (MessageBatchItem) TableName() string { return "message_batch_items" }
+
+// ProviderEvent model - 供应商生命周期事件（冷却、恢复、凭证失效）
+type ProviderEvent struct {
+	ID         uint64 `gorm:"primaryKey;autoIncrement"`
+	CreatedAt  int64  `gorm:"index"`
+	ProviderID uint64 `gorm:"index"`
+	ClientType string `gorm:"size:64"`
+	Type       string `gorm:"size:32"`
+	Reason     string `gorm:"size:64"`
+	UntilTime  int64
+	Detail     LongText
+}
+
+func (ProviderEvent) TableName() string { return "provider_events" }
+
 // SchemaMigration tracks applied migrations
 type SchemaMigration struct {
 	Version     int    `gorm:"primaryKey"`
@@ -333,12 +445,17 @@ func AllModels() []any {
 		&ModelMapping{},
 		&AntigravityQuota{},
 		&ProxyRequest{},
+		&ProxyRequestTag{},
 		&ProxyUpstreamAttempt{},
 		&SystemSetting{},
 		&Cooldown{},
 		&FailureCount{},
+		&RoundRobinState{},
 		&UsageStats{},
 		&ResponseModel{},
+		&MessageBatch{},
+		&MessageBatchItem{},
+		&ProviderEvent{},
 		&SchemaMigration{},
 	}
 }
@@ -125,6 +125,11 @@ func (r *ProjectRepository) toModel(p *domain.Project) *Project {
 		Name:                p.Name,
 		Slug:                p.Slug,
 		EnabledCustomRoutes: LongText(toJSON(p.EnabledCustomRoutes)),
+		Guardrail:           LongText(toJSON(p.Guardrail)),
+		DefaultClientType:   string(p.DefaultClientType),
+		CostPreference:      string(p.CostPreference),
+		FallbackResponse:    LongText(toJSON(p.FallbackResponse)),
+		DefaultModel:        p.DefaultModel,
 	}
 }
 
@@ -137,6 +142,11 @@ func (r *ProjectRepository) toDomain(m *Project) *domain.Project {
 		Name:                m.Name,
 		Slug:                m.Slug,
 		EnabledCustomRoutes: fromJSON[[]domain.ClientType](string(m.EnabledCustomRoutes)),
+		Guardrail:           fromJSON[*domain.GuardrailConfig](string(m.Guardrail)),
+		DefaultClientType:   domain.ClientType(m.DefaultClientType),
+		CostPreference:      domain.CostPreference(m.CostPreference),
+		FallbackResponse:    fromJSON[*domain.FallbackResponseConfig](string(m.FallbackResponse)),
+		DefaultModel:        m.DefaultModel,
 	}
 }
 
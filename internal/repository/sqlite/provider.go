@@ -2,18 +2,22 @@ package sqlite
 
 import (
 	"errors"
+	"log"
 	"time"
 
 	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/secrets"
 	"gorm.io/gorm"
 )
 
+// ProviderRepository stores providers, their credentials encrypted when a master key is set
 type ProviderRepository struct {
-	db *DB
+	db      *DB
+	keyring *secrets.Keyring
 }
 
 func NewProviderRepository(db *DB) *ProviderRepository {
-	return &ProviderRepository{db: db}
+	return &ProviderRepository{db: db, keyring: secrets.Default()}
 }
 
 func (r *ProviderRepository) Create(p *domain.Provider) error {
@@ -21,7 +25,10 @@ func (r *ProviderRepository) Create(p *domain.Provider) error {
 	p.CreatedAt = now
 	p.UpdatedAt = now
 
-	model := r.toModel(p)
+	model, err := r.toModel(p)
+	if err != nil {
+		return err
+	}
 	if err := r.db.gorm.Create(model).Error; err != nil {
 		return err
 	}
@@ -31,7 +38,10 @@ func (r *ProviderRepository) Create(p *domain.Provider) error {
 
 func (r *ProviderRepository) Update(p *domain.Provider) error {
 	p.UpdatedAt = time.Now()
-	model := r.toModel(p)
+	model, err := r.toModel(p)
+	if err != nil {
+		return err
+	}
 	return r.db.gorm.Save(model).Error
 }
 
@@ -70,7 +80,11 @@ func (r *ProviderRepository) List() ([]*domain.Provider, error) {
 }
 
 // toModel converts domain.Provider to sqlite.Provider
-func (r *ProviderRepository) toModel(p *domain.Provider) *Provider {
+func (r *ProviderRepository) toModel(p *domain.Provider) (*Provider, error) {
+	config, err := r.sealConfig(p.Config)
+	if err != nil {
+		return nil, err
+	}
 	return &Provider{
 		SoftDeleteModel: SoftDeleteModel{
 			BaseModel: BaseModel{
@@ -82,14 +96,18 @@ func (r *ProviderRepository) toModel(p *domain.Provider) *Provider {
 		},
 		Type:                 p.Type,
 		Name:                 p.Name,
-		Config:               LongText(toJSON(p.Config)),
+		Config:               LongText(config),
 		SupportedClientTypes: LongText(toJSON(p.SupportedClientTypes)),
 		SupportModels:        LongText(toJSON(p.SupportModels)),
-	}
+	}, nil
 }
 
 // toDomain converts sqlite.Provider to domain.Provider
 func (r *ProviderRepository) toDomain(m *Provider) *domain.Provider {
+	config, err := r.openConfig(string(m.Config))
+	if err != nil {
+		log.Printf("[Provider] Failed to decrypt credentials of provider %d: %v", m.ID, err)
+	}
 	return &domain.Provider{
 		ID:                   m.ID,
 		CreatedAt:            fromTimestamp(m.CreatedAt),
@@ -97,7 +115,7 @@ func (r *ProviderRepository) toDomain(m *Provider) *domain.Provider {
 		DeletedAt:            fromTimestampPtr(m.DeletedAt),
 		Type:                 m.Type,
 		Name:                 m.Name,
-		Config:               fromJSON[*domain.ProviderConfig](string(m.Config)),
+		Config:               config,
 		SupportedClientTypes: fromJSON[[]domain.ClientType](string(m.SupportedClientTypes)),
 		SupportModels:        fromJSON[[]string](string(m.SupportModels)),
 	}
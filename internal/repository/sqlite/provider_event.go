@@ -0,0 +1,82 @@
+package sqlite
+
+import (
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+type ProviderEventRepository struct {
+	db *DB
+}
+
+func NewProviderEventRepository(db *DB) *ProviderEventRepository {
+	return &ProviderEventRepository{db: db}
+}
+
+func (r *ProviderEventRepository) Create(event *domain.ProviderEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	model := r.toModel(event)
+	if err := r.db.gorm.Create(model).Error; err != nil {
+		return err
+	}
+	event.ID = model.ID
+	return nil
+}
+
+func (r *ProviderEventRepository) List(providerID uint64, since, until time.Time, limit int) ([]*domain.ProviderEvent, error) {
+	query := r.db.gorm.Model(&ProviderEvent{})
+	if providerID > 0 {
+		query = query.Where("provider_id = ?", providerID)
+	}
+	if !since.IsZero() {
+		query = query.Where("created_at >= ?", toTimestamp(since))
+	}
+	if !until.IsZero() {
+		query = query.Where("created_at < ?", toTimestamp(until))
+	}
+
+	var models []ProviderEvent
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&models).Error; err != nil {
+		return nil, err
+	}
+	events := make([]*domain.ProviderEvent, len(models))
+	for i := range models {
+		events[i] = r.toDomain(&models[i])
+	}
+	return events, nil
+}
+
+// DeleteOlderThan 删除指定时间之前的事件
+func (r *ProviderEventRepository) DeleteOlderThan(before time.Time) (int64, error) {
+	result := r.db.gorm.Where("created_at < ?", toTimestamp(before)).Delete(&ProviderEvent{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *ProviderEventRepository) toModel(e *domain.ProviderEvent) *ProviderEvent {
+	return &ProviderEvent{
+		ID:         e.ID,
+		CreatedAt:  toTimestamp(e.CreatedAt),
+		ProviderID: e.ProviderID,
+		ClientType: e.ClientType,
+		Type:       string(e.Type),
+		Reason:     e.Reason,
+		UntilTime:  toTimestampPtr(e.UntilTime),
+		Detail:     LongText(e.Detail),
+	}
+}
+
+func (r *ProviderEventRepository) toDomain(m *ProviderEvent) *domain.ProviderEvent {
+	return &domain.ProviderEvent{
+		ID:         m.ID,
+		CreatedAt:  fromTimestamp(m.CreatedAt),
+		ProviderID: m.ProviderID,
+		ClientType: m.ClientType,
+		Type:       domain.ProviderEventType(m.Type),
+		Reason:     m.Reason,
+		UntilTime:  fromTimestampPtr(m.UntilTime),
+		Detail:     string(m.Detail),
+	}
+}
@@ -0,0 +1,56 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/cooldown"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestProviderEventsFromCooldowns(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewProviderEventRepository(db)
+
+	m := cooldown.NewManager()
+	m.SetEventSink(func(e *domain.ProviderEvent) {
+		if err := repo.Create(e); err != nil {
+			t.Error(err)
+		}
+	})
+	start := time.Now()
+	m.RecordFailure(1, "claude", cooldown.ReasonServerError, nil)
+	m.RecordFailure(2, "", cooldown.ReasonNetworkError, nil)
+	m.RecordSuccess(1, "claude")
+
+	events, err := repo.List(1, time.Time{}, time.Time{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Type != domain.ProviderEventRecovered || events[1].Type != domain.ProviderEventCooldownEntered {
+		t.Fatalf("provider 1 events = %+v, want recovered after cooldown_entered", events)
+	}
+	entered := events[1]
+	if entered.ClientType != "claude" || entered.Reason != "server_error" || entered.UntilTime == nil || !entered.UntilTime.After(start) {
+		t.Errorf("cooldown_entered event = %+v", entered)
+	}
+
+	all, err := repo.List(0, start.Add(-time.Second), time.Time{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Errorf("events of every provider = %d, want 3", len(all))
+	}
+	if later, _ := repo.List(0, time.Now().Add(time.Minute), time.Time{}, 10); len(later) != 0 {
+		t.Errorf("events after now = %d, want 0", len(later))
+	}
+
+	if n, err := repo.DeleteOlderThan(time.Now().Add(time.Second)); err != nil || n != 3 {
+		t.Errorf("DeleteOlderThan = %d, %v; want 3", n, err)
+	}
+}
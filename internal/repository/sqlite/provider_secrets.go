@@ -0,0 +1,117 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/secrets"
+	"gorm.io/gorm"
+)
+
+// transformSecrets replaces the non-empty credential fields in a decoded provider
+// config with fn's result. The first error is returned after all fields are visited,
+// failed fields are cleared.
+func transformSecrets(v interface{}, fn func(string) (string, error)) error {
+	var firstErr error
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			for key, value := range node {
+				if s, ok := value.(string); ok && providerSecretKeys[key] && s != "" {
+					out, err := fn(s)
+					if err != nil && firstErr == nil {
+						firstErr = err
+					}
+					node[key] = out
+					continue
+				}
+				walk(value)
+			}
+		case []interface{}:
+			for _, item := range node {
+				walk(item)
+			}
+		}
+	}
+	walk(v)
+	return firstErr
+}
+
+// sealConfig encodes a provider config for storage, credentials encrypted with the current master key
+func (r *ProviderRepository) sealConfig(config *domain.ProviderConfig) (string, error) {
+	raw := toJSON(config)
+	if config == nil || !r.keyring.Enabled() {
+		return raw, nil
+	}
+	var tree interface{}
+	if err := json.Unmarshal([]byte(raw), &tree); err != nil {
+		return "", err
+	}
+	if err := transformSecrets(tree, r.keyring.Encrypt); err != nil {
+		return "", err
+	}
+	return toJSON(tree), nil
+}
+
+// openConfig decodes a stored provider config, decrypting its credentials.
+// Credentials that can't be decrypted are cleared and the first error returned.
+func (r *ProviderRepository) openConfig(raw string) (*domain.ProviderConfig, error) {
+	if !strings.Contains(raw, secrets.Prefix) {
+		return fromJSON[*domain.ProviderConfig](raw), nil
+	}
+	var tree interface{}
+	if err := json.Unmarshal([]byte(raw), &tree); err != nil {
+		return nil, err
+	}
+	err := transformSecrets(tree, r.keyring.Decrypt)
+	return fromJSON[*domain.ProviderConfig](toJSON(tree)), err
+}
+
+// ReencryptSecrets rewrites the credentials of all providers, deleted ones
+// included, with the current master key: after a rotation, or to encrypt
+// credentials stored before encryption was enabled. Nothing is written unless
+// every provider's credentials can be decrypted.
+func (r *ProviderRepository) ReencryptSecrets() (int, error) {
+	var changed bool
+	reencrypt := func(value string) (string, error) {
+		if r.keyring.IsCurrent(value) {
+			return value, nil
+		}
+		plaintext, err := r.keyring.Decrypt(value)
+		if err != nil {
+			return "", err
+		}
+		out, err := r.keyring.Encrypt(plaintext)
+		changed = changed || out != value
+		return out, err
+	}
+
+	updated := 0
+	err := r.db.gorm.Transaction(func(tx *gorm.DB) error {
+		var models []Provider
+		if err := tx.Select("id", "config").Find(&models).Error; err != nil {
+			return err
+		}
+		for _, m := range models {
+			var tree interface{}
+			if m.Config == "" || json.Unmarshal([]byte(m.Config), &tree) != nil {
+				continue
+			}
+			changed = false
+			if err := transformSecrets(tree, reencrypt); err != nil {
+				return err
+			}
+			if !changed {
+				continue
+			}
+			if err := tx.Model(&Provider{}).Where("id = ?", m.ID).UpdateColumn("config", LongText(toJSON(tree))).Error; err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	})
+	return updated, err
+}
@@ -0,0 +1,134 @@
+package sqlite
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/secrets"
+)
+
+func newSecretsTestRepo(t *testing.T, masterKey string) (*ProviderRepository, *secrets.Keyring) {
+	t.Helper()
+	db, err := NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	keyring := &secrets.Keyring{}
+	if masterKey != "" {
+		c, err := secrets.NewCipher(masterKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyring.Set(c)
+	}
+	repo := NewProviderRepository(db)
+	repo.keyring = keyring
+	return repo, keyring
+}
+
+// storedConfig reads a provider's config column as stored
+func storedConfig(t *testing.T, repo *ProviderRepository, id uint64) string {
+	t.Helper()
+	var m Provider
+	if err := repo.db.gorm.Select("config").First(&m, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return string(m.Config)
+}
+
+func TestProviderCredentialsEncryptedAtRest(t *testing.T) {
+	repo, _ := newSecretsTestRepo(t, "correct horse battery staple")
+	p := &domain.Provider{Type: "kiro", Name: "kiro", Config: &domain.ProviderConfig{Kiro: &domain.ProviderConfigKiro{
+		AuthMethod: "idc", RefreshToken: "refresh-secret", ClientID: "client", ClientSecret: "client-secret",
+	}}}
+	if err := repo.Create(p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Config.Kiro.RefreshToken != "refresh-secret" {
+		t.Error("Create encrypted the caller's provider")
+	}
+
+	stored := storedConfig(t, repo, p.ID)
+	if strings.Contains(stored, "refresh-secret") || strings.Contains(stored, "client-secret") || !strings.Contains(stored, `"clientId":"client"`) {
+		t.Errorf("stored config = %s, want only the credentials encrypted", stored)
+	}
+
+	got, err := repo.GetByID(p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Config.Kiro.RefreshToken != "refresh-secret" || got.Config.Kiro.ClientSecret != "client-secret" {
+		t.Errorf("read back %+v", got.Config.Kiro)
+	}
+}
+
+func TestReencryptSecretsRotatesAllProviders(t *testing.T) {
+	repo, keyring := newSecretsTestRepo(t, "")
+
+	// Stored in plaintext before encryption was enabled, one of them deleted since
+	var ids []uint64
+	for i, key := range []string{"sk-one", "sk-two", "sk-three"} {
+		p := &domain.Provider{Type: "custom", Name: key, Config: &domain.ProviderConfig{Custom: &domain.ProviderConfigCustom{
+			BaseURL: "https://relay.example.com",
+			APIKey:  key,
+			Signing: &domain.ProviderConfigCustomSigning{Mode: domain.CustomSigningSigV4, AccessKeyID: "AKID", SecretAccessKey: "aws-" + key},
+		}}}
+		if err := repo.Create(p); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, p.ID)
+		if i == 2 {
+			if err := repo.Delete(p.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	first, _ := secrets.NewCipher("correct horse battery staple")
+	keyring.Set(first)
+	if n, err := repo.ReencryptSecrets(); err != nil || n != 3 {
+		t.Fatalf("ReencryptSecrets = %d, %v, want all 3 providers encrypted", n, err)
+	}
+	if n, err := repo.ReencryptSecrets(); err != nil || n != 0 {
+		t.Errorf("second ReencryptSecrets = %d, %v, want nothing left to do", n, err)
+	}
+
+	if _, err := keyring.Rotate("another long master key"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := repo.ReencryptSecrets(); err != nil || n != 3 {
+		t.Fatalf("ReencryptSecrets after rotation = %d, %v", n, err)
+	}
+
+	// Only the new key is needed from now on
+	second, _ := secrets.NewCipher("another long master key")
+	keyring.Set(second)
+	for i, id := range ids {
+		stored := storedConfig(t, repo, id)
+		if !strings.Contains(stored, secrets.Prefix+second.ID()+":") || strings.Contains(stored, "sk-") {
+			t.Errorf("provider %d stored config = %s, want encrypted with the new key", id, stored)
+		}
+		got, err := repo.GetByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"sk-one", "sk-two", "sk-three"}[i]
+		if got.Config.Custom.APIKey != want || got.Config.Custom.Signing.SecretAccessKey != "aws-"+want {
+			t.Errorf("provider %d credentials = %+v", id, got.Config.Custom)
+		}
+	}
+
+	// Without the key nothing is rewritten
+	keyring.Set(first)
+	if _, err := repo.ReencryptSecrets(); !errors.Is(err, secrets.ErrUnknownKey) {
+		t.Errorf("ReencryptSecrets with an unknown key: err = %v", err)
+	}
+	keyring.Set(second)
+	if got, _ := repo.GetByID(ids[0]); got.Config.Custom.APIKey != "sk-one" {
+		t.Error("failed re-encryption modified stored credentials")
+	}
+}
@@ -1,7 +1,9 @@
 package sqlite
 
 import (
+	"encoding/json"
 	"errors"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -35,7 +37,20 @@ func (r *ProxyRequestRepository) Create(p *domain.ProxyRequest) error {
 	p.UpdatedAt = now
 
 	model := r.toModel(p)
-	if err := r.db.gorm.Create(model).Error; err != nil {
+	err := r.db.gorm.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(model).Error; err != nil {
+			return err
+		}
+		if len(p.Tags) == 0 {
+			return nil
+		}
+		tags := make([]ProxyRequestTag, len(p.Tags))
+		for i, tag := range p.Tags {
+			tags[i] = ProxyRequestTag{ProxyRequestID: model.ID, Tag: tag, CreatedAt: model.CreatedAt}
+		}
+		return tx.Create(&tags).Error
+	})
+	if err != nil {
 		return err
 	}
 	p.ID = model.ID
@@ -75,16 +90,38 @@ func (r *ProxyRequestRepository) List(limit, offset int) ([]*domain.ProxyRequest
 // before: 获取 id < before 的记录 (向后翻页)
 // after: 获取 id > after 的记录 (向前翻页/获取新数据)
 // 注意：列表查询不返回 request_info 和 response_info 大字段
-func (r *ProxyRequestRepository) ListCursor(limit int, before, after uint64) ([]*domain.ProxyRequest, error) {
+// filter: 可选的筛选条件，nil 表示不筛选
+func (r *ProxyRequestRepository) ListCursor(limit int, before, after uint64, filter *domain.ProxyRequestFilter) ([]*domain.ProxyRequest, error) {
 	// 使用 Select 排除大字段
 	query := r.db.gorm.Model(&ProxyRequest{}).
-		Select("id, created_at, updated_at, instance_id, request_id, session_id, client_type, request_model, response_model, start_time, end_time, duration_ms, is_stream, status, status_code, error, proxy_upstream_attempt_count, final_proxy_upstream_attempt_id, route_id, provider_id, project_id, input_token_count, output_token_count, cache_read_count, cache_write_count, cache_5m_write_count, cache_1h_write_count, cost, api_token_id")
+		Select("id, created_at, updated_at, instance_id, request_id, session_id, client_type, request_model, response_model, start_time, end_time, duration_ms, is_stream, status, status_code, error, proxy_upstream_attempt_count, final_proxy_upstream_attempt_id, route_id, provider_id, project_id, input_token_count, output_token_count, cache_read_count, cache_write_count, cache_5m_write_count, cache_1h_write_count, cost, api_token_id, max_retries_override, metadata, pii_flags, client_app, guardrail_flag, tags, empty_response")
 
 	if after > 0 {
 		query = query.Where("id > ?", after)
 	} else if before > 0 {
 		query = query.Where("id < ?", before)
 	}
+	if filter != nil {
+		for key, value := range filter.Metadata {
+			query = query.Where("metadata LIKE ? ESCAPE '!'", "%"+metadataLikePattern(key, value)+"%")
+		}
+		if filter.ProjectID > 0 {
+			query = query.Where("project_id = ?", filter.ProjectID)
+		}
+		switch filter.PII {
+		case "":
+		case domain.PIIFilterAny:
+			query = query.Where("pii_flags IS NOT NULL AND pii_flags <> ''")
+		default:
+			query = query.Where("pii_flags LIKE ? ESCAPE '!'", "%"+jsonStringLikePattern(filter.PII)+"%")
+		}
+		if filter.Tag != "" {
+			query = query.Where("id IN (?)", r.db.gorm.Model(&ProxyRequestTag{}).Select("proxy_request_id").Where("tag = ?", filter.Tag))
+		}
+		if filter.Search != "" {
+			query = r.whereSearch(query, filter.Search, filter.SearchField)
+		}
+	}
 
 	var models []ProxyRequest
 	if err := query.Order("id DESC").Limit(limit).Find(&models).Error; err != nil {
@@ -97,7 +134,7 @@ func (r *ProxyRequestRepository) ListCursor(limit int, before, after uint64) ([]
 func (r *ProxyRequestRepository) ListActive() ([]*domain.ProxyRequest, error) {
 	var models []ProxyRequest
 	if err := r.db.gorm.Model(&ProxyRequest{}).
-		Select("id, created_at, updated_at, instance_id, request_id, session_id, client_type, request_model, response_model, start_time, end_time, duration_ms, is_stream, status, status_code, error, proxy_upstream_attempt_count, final_proxy_upstream_attempt_id, route_id, provider_id, project_id, input_token_count, output_token_count, cache_read_count, cache_write_count, cache_5m_write_count, cache_1h_write_count, cost, api_token_id").
+		Select("id, created_at, updated_at, instance_id, request_id, session_id, client_type, request_model, response_model, start_time, end_time, duration_ms, is_stream, status, status_code, error, proxy_upstream_attempt_count, final_proxy_upstream_attempt_id, route_id, provider_id, project_id, input_token_count, output_token_count, cache_read_count, cache_write_count, cache_5m_write_count, cache_1h_write_count, cost, api_token_id, max_retries_override, metadata, pii_flags, client_app, guardrail_flag, tags, empty_response").
 		Where("status IN ?", []string{"PENDING", "IN_PROGRESS"}).
 		Order("id DESC").
 		Find(&models).Error; err != nil {
@@ -106,6 +143,18 @@ func (r *ProxyRequestRepository) ListActive() ([]*domain.ProxyRequest, error) {
 	return r.toDomainList(models), nil
 }
 
+// ListBySessionID 获取指定 sessionID 的请求（含请求/响应内容），按开始时间升序，最多 limit 条
+func (r *ProxyRequestRepository) ListBySessionID(sessionID string, limit int) ([]*domain.ProxyRequest, error) {
+	var models []ProxyRequest
+	if err := r.db.gorm.Where("session_id = ?", sessionID).
+		Order("start_time ASC, id ASC").
+		Limit(limit).
+		Find(&models).Error; err != nil {
+		return nil, err
+	}
+	return r.toDomainList(models), nil
+}
+
 func (r *ProxyRequestRepository) Count() (int64, error) {
 	return atomic.LoadInt64(&r.count), nil
 }
@@ -172,6 +221,11 @@ func (r *ProxyRequestRepository) DeleteOlderThan(before time.Time) (int64, error
 		return 0, err
 	}
 
+	// 删除关联的标签
+	if err := r.db.gorm.Where("proxy_request_id IN ?", requestIDs).Delete(&ProxyRequestTag{}).Error; err != nil {
+		return 0, err
+	}
+
 	// 删除 requests
 	result := r.db.gorm.Where("id IN ?", requestIDs).Delete(&ProxyRequest{})
 	if result.Error != nil {
@@ -197,6 +251,81 @@ func (r *ProxyRequestRepository) HasRecentRequests(since time.Time) (bool, error
 	return count > 0, nil
 }
 
+// metadataToJSON 序列化 metadata，空 map 存为空字符串
+// encoding/json 对 map 按 key 排序且不含空格，与 metadataLikePattern 生成的片段一致
+func metadataToJSON(metadata map[string]string) string {
+	if len(metadata) == 0 {
+		return ""
+	}
+	return toJSON(metadata)
+}
+
+// stringListToJSON 序列化 PII 标记、标签等字符串列表，空列表存为空字符串，便于按是否为空筛选
+func stringListToJSON(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return toJSON(values)
+}
+
+// routeFailuresToJSON 序列化路由失败摘要，无失败时存为空字符串
+func routeFailuresToJSON(failures []domain.RouteFailure) string {
+	if len(failures) == 0 {
+		return ""
+	}
+	return toJSON(failures)
+}
+
+// metadataLikePattern 生成匹配单个 metadata 键值对的 LIKE 片段（已转义通配符）
+// key 和 value 按 JSON 编码，其中的引号会被转义，因此不会误匹配到其他键值的一部分
+func metadataLikePattern(key, value string) string {
+	k, _ := json.Marshal(key)
+	v, _ := json.Marshal(value)
+	return likeEscaper.Replace(string(k) + ":" + string(v))
+}
+
+// jsonStringLikePattern 生成匹配 JSON 数组中单个字符串元素的 LIKE 片段（已转义通配符）
+func jsonStringLikePattern(value string) string {
+	v, _ := json.Marshal(value)
+	return likeEscaper.Replace(string(v))
+}
+
+// searchColumns 各搜索范围对应的列，空范围搜索全部列
+func searchColumns(field string) []string {
+	switch field {
+	case domain.RequestSearchFieldError:
+		return []string{"error"}
+	case domain.RequestSearchFieldBody:
+		return []string{"request_info", "response_info"}
+	case domain.RequestSearchFieldModel:
+		return []string{"request_model", "response_model"}
+	default:
+		return []string{"request_model", "response_model", "error", "request_info", "response_info"}
+	}
+}
+
+// whereSearch 按关键词搜索请求：SQLite 使用 proxy_requests_fts 全文索引，其他数据库回退为 LIKE
+func (r *ProxyRequestRepository) whereSearch(query *gorm.DB, search, field string) *gorm.DB {
+	columns := searchColumns(field)
+	if r.db.dialector == "sqlite" {
+		// 关键词作为短语匹配，并限定在对应列内
+		match := "{" + strings.Join(columns, " ") + "} : \"" + strings.ReplaceAll(search, `"`, `""`) + "\""
+		return query.Where("id IN (SELECT rowid FROM proxy_requests_fts WHERE proxy_requests_fts MATCH ?)", match)
+	}
+
+	pattern := "%" + likeEscaper.Replace(strings.ToLower(search)) + "%"
+	conditions := make([]string, len(columns))
+	args := make([]any, len(columns))
+	for i, col := range columns {
+		conditions[i] = "LOWER(" + col + ") LIKE ? ESCAPE '!'"
+		args[i] = pattern
+	}
+	return query.Where(strings.Join(conditions, " OR "), args...)
+}
+
+// likeEscaper 以 ! 为转义符转义 LIKE 通配符
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
 func (r *ProxyRequestRepository) toModel(p *domain.ProxyRequest) *ProxyRequest {
 	return &ProxyRequest{
 		BaseModel: BaseModel{
@@ -232,6 +361,19 @@ func (r *ProxyRequestRepository) toModel(p *domain.ProxyRequest) *ProxyRequest {
 		Cache1hWriteCount:          p.Cache1hWriteCount,
 		Cost:                       p.Cost,
 		APITokenID:                 p.APITokenID,
+		MaxRetriesOverride:         p.MaxRetriesOverride,
+		Metadata:                   LongText(metadataToJSON(p.Metadata)),
+		PIIFlags:                   LongText(stringListToJSON(p.PIIFlags)),
+		ClientApp:                  p.ClientApp,
+		GuardrailFlag:              p.GuardrailFlag,
+		Tags:                       LongText(stringListToJSON(p.Tags)),
+		RouteFailures:              LongText(routeFailuresToJSON(p.RouteFailures)),
+		EmptyResponse:              boolToInt(p.EmptyResponse),
+		FallbackResponse:           boolToInt(p.FallbackResponse),
+		ModelDefaulted:             boolToInt(p.ModelDefaulted),
+		ForcedProviderID:           p.ForcedProviderID,
+		Replay:                     boolToInt(p.Replay),
+		CancelReason:               string(p.CancelReason),
 	}
 }
 
@@ -268,6 +410,19 @@ func (r *ProxyRequestRepository) toDomain(m *ProxyRequest) *domain.ProxyRequest
 		Cache1hWriteCount:           m.Cache1hWriteCount,
 		Cost:                        m.Cost,
 		APITokenID:                  m.APITokenID,
+		MaxRetriesOverride:          m.MaxRetriesOverride,
+		Metadata:                    fromJSON[map[string]string](string(m.Metadata)),
+		PIIFlags:                    fromJSON[[]string](string(m.PIIFlags)),
+		ClientApp:                   m.ClientApp,
+		GuardrailFlag:               m.GuardrailFlag,
+		Tags:                        fromJSON[[]string](string(m.Tags)),
+		RouteFailures:               fromJSON[[]domain.RouteFailure](string(m.RouteFailures)),
+		EmptyResponse:               m.EmptyResponse == 1,
+		FallbackResponse:            m.FallbackResponse == 1,
+		ModelDefaulted:              m.ModelDefaulted == 1,
+		ForcedProviderID:            m.ForcedProviderID,
+		Replay:                      m.Replay == 1,
+		CancelReason:                domain.CancelReason(m.CancelReason),
 	}
 }
 
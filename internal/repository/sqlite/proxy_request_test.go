@@ -0,0 +1,165 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestListCursorFiltersByMetadata(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewProxyRequestRepository(db)
+
+	requests := []map[string]string{
+		{"app": "billing", "env": "prod"},
+		{"app": "billing", "env": "staging"},
+		{"app": "search_v2"},
+		{"app": "search%v2"},
+		nil,
+	}
+	for _, metadata := range requests {
+		if err := repo.Create(&domain.ProxyRequest{Status: "COMPLETED", Metadata: metadata}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count := func(metadata map[string]string) int {
+		t.Helper()
+		items, err := repo.ListCursor(100, 0, 0, &domain.ProxyRequestFilter{Metadata: metadata})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(items)
+	}
+
+	if got := count(map[string]string{"app": "billing"}); got != 2 {
+		t.Errorf("app=billing matched %d, want 2", got)
+	}
+	if got := count(map[string]string{"app": "billing", "env": "prod"}); got != 1 {
+		t.Errorf("app=billing,env=prod matched %d, want 1", got)
+	}
+	if got := count(map[string]string{"app": "bill"}); got != 0 {
+		t.Errorf("app=bill matched %d, want 0", got)
+	}
+	// LIKE wildcards in the filter are matched literally
+	if got := count(map[string]string{"app": "search_v2"}); got != 1 {
+		t.Errorf("app=search_v2 matched %d, want 1", got)
+	}
+	if got := count(nil); got != len(requests) {
+		t.Errorf("unfiltered matched %d, want %d", got, len(requests))
+	}
+
+	items, err := repo.ListCursor(1, 0, 0, &domain.ProxyRequestFilter{Metadata: map[string]string{"env": "prod"}})
+	if err != nil || len(items) != 1 || items[0].Metadata["app"] != "billing" {
+		t.Errorf("list result metadata = %v, err %v", items, err)
+	}
+}
+
+func TestListCursorFiltersByPIIFlag(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewProxyRequestRepository(db)
+
+	for _, flags := range [][]string{{"email"}, {"credit_card", "email"}, {"ssn"}, nil} {
+		if err := repo.Create(&domain.ProxyRequest{Status: "COMPLETED", PIIFlags: flags}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count := func(flag string) int {
+		t.Helper()
+		items, err := repo.ListCursor(100, 0, 0, &domain.ProxyRequestFilter{PII: flag})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(items)
+	}
+
+	if got := count(domain.PIIFilterAny); got != 3 {
+		t.Errorf("pii=any matched %d, want 3", got)
+	}
+	if got := count("email"); got != 2 {
+		t.Errorf("pii=email matched %d, want 2", got)
+	}
+	if got := count("credit"); got != 0 {
+		t.Errorf("pii=credit matched %d, want 0 (no partial match)", got)
+	}
+	if got := count(""); got != 4 {
+		t.Errorf("empty pii filter matched %d, want 4", got)
+	}
+}
+
+func TestListCursorSearch(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewProxyRequestRepository(db)
+
+	requests := []*domain.ProxyRequest{
+		{Status: "FAILED", RequestModel: "claude-sonnet-4", Error: "upstream returned 529 Overloaded"},
+		{Status: "FAILED", RequestModel: "gpt-4o", Error: "context deadline exceeded"},
+		{Status: "COMPLETED", RequestModel: "claude-opus-4", ResponseModel: "claude-opus-4-20250514"},
+		{Status: "COMPLETED", RequestModel: "gemini-2.5-pro", Error: ""},
+	}
+	for _, req := range requests {
+		if err := repo.Create(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search := func(q, field string) int {
+		t.Helper()
+		items, err := repo.ListCursor(100, 0, 0, &domain.ProxyRequestFilter{Search: q, SearchField: field})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(items)
+	}
+
+	if got := search("overloaded", domain.RequestSearchFieldError); got != 1 {
+		t.Errorf("error search for overloaded matched %d, want 1", got)
+	}
+	if got := search("claude", domain.RequestSearchFieldModel); got != 2 {
+		t.Errorf("model search for claude matched %d, want 2", got)
+	}
+	if got := search("20250514", domain.RequestSearchFieldModel); got != 1 {
+		t.Errorf("response model search matched %d, want 1", got)
+	}
+	// Fields are searched separately, a model name doesn't match the error field
+	if got := search("claude", domain.RequestSearchFieldError); got != 0 {
+		t.Errorf("error search for claude matched %d, want 0", got)
+	}
+	if got := search("deadline", ""); got != 1 {
+		t.Errorf("search across all fields matched %d, want 1", got)
+	}
+	// Quotes in the query are taken literally rather than as FTS syntax
+	if got := search(`"529" OR claude`, ""); got != 0 {
+		t.Errorf("query with FTS syntax matched %d, want 0", got)
+	}
+
+	// Updates reindex the changed error
+	requests[3].Status = "FAILED"
+	requests[3].Error = "rate limit exceeded"
+	if err := repo.Update(requests[3]); err != nil {
+		t.Fatal(err)
+	}
+	if got := search("exceeded", domain.RequestSearchFieldError); got != 2 {
+		t.Errorf("error search after update matched %d, want 2", got)
+	}
+
+	// Requests removed by retention disappear from the index
+	if _, err := repo.DeleteOlderThan(time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got := search("claude", ""); got != 0 {
+		t.Errorf("search after cleanup matched %d, want 0", got)
+	}
+}
@@ -42,61 +42,96 @@ func (r *ProxyUpstreamAttemptRepository) ListByProxyRequestID(proxyRequestID uin
 }
 
 func (r *ProxyUpstreamAttemptRepository) toModel(a *domain.ProxyUpstreamAttempt) *ProxyUpstreamAttempt {
-	return &ProxyUpstreamAttempt{
+	m := &ProxyUpstreamAttempt{
 		BaseModel: BaseModel{
 			ID:        a.ID,
 			CreatedAt: toTimestamp(a.CreatedAt),
 			UpdatedAt: toTimestamp(a.UpdatedAt),
 		},
-		StartTime:         toTimestamp(a.StartTime),
-		EndTime:           toTimestamp(a.EndTime),
-		DurationMs:        a.Duration.Milliseconds(),
-		Status:            a.Status,
-		ProxyRequestID:    a.ProxyRequestID,
-		IsStream:          boolToInt(a.IsStream),
-		RequestModel:      a.RequestModel,
-		MappedModel:       a.MappedModel,
-		ResponseModel:     a.ResponseModel,
-		RequestInfo:       LongText(toJSON(a.RequestInfo)),
-		ResponseInfo:      LongText(toJSON(a.ResponseInfo)),
-		RouteID:           a.RouteID,
-		ProviderID:        a.ProviderID,
-		InputTokenCount:   a.InputTokenCount,
-		OutputTokenCount:  a.OutputTokenCount,
-		CacheReadCount:    a.CacheReadCount,
-		CacheWriteCount:   a.CacheWriteCount,
-		Cache5mWriteCount: a.Cache5mWriteCount,
-		Cache1hWriteCount: a.Cache1hWriteCount,
-		Cost:              a.Cost,
+		StartTime:              toTimestamp(a.StartTime),
+		EndTime:                toTimestamp(a.EndTime),
+		DurationMs:             a.Duration.Milliseconds(),
+		Status:                 a.Status,
+		ProxyRequestID:         a.ProxyRequestID,
+		IsStream:               boolToInt(a.IsStream),
+		RequestModel:           a.RequestModel,
+		MappedModel:            a.MappedModel,
+		ResponseModel:          a.ResponseModel,
+		RequestInfo:            LongText(toJSON(a.RequestInfo)),
+		ResponseInfo:           LongText(toJSON(a.ResponseInfo)),
+		RouteID:                a.RouteID,
+		ProviderID:             a.ProviderID,
+		InputTokenCount:        a.InputTokenCount,
+		OutputTokenCount:       a.OutputTokenCount,
+		CacheReadCount:         a.CacheReadCount,
+		CacheWriteCount:        a.CacheWriteCount,
+		Cache5mWriteCount:      a.Cache5mWriteCount,
+		Cache1hWriteCount:      a.Cache1hWriteCount,
+		Cost:                   a.Cost,
+		StatusCode:             a.StatusCode,
+		MaxTokensClampedFrom:   a.MaxTokensClampedFrom,
+		CooldownCappedFromSecs: a.CooldownCappedFromSecs,
+		HedgeOfAttemptID:       a.HedgeOfAttemptID,
+		EnsembleOfAttemptID:    a.EnsembleOfAttemptID,
+		EnsembleChosen:         boolToInt(a.EnsembleChosen),
+		EmptyResponse:          boolToInt(a.EmptyResponse),
+		DroppedParams:          a.DroppedParams,
 	}
+	if t := a.Timing; t != nil {
+		m.TimingCaptured = 1
+		m.TimingDNSUs = t.DNS.Microseconds()
+		m.TimingConnectUs = t.Connect.Microseconds()
+		m.TimingTLSUs = t.TLS.Microseconds()
+		m.TimingFirstByteUs = t.FirstByte.Microseconds()
+		m.TimingReused = boolToInt(t.Reused)
+	}
+	return m
 }
 
 func (r *ProxyUpstreamAttemptRepository) toDomain(m *ProxyUpstreamAttempt) *domain.ProxyUpstreamAttempt {
-	return &domain.ProxyUpstreamAttempt{
-		ID:                m.ID,
-		CreatedAt:         fromTimestamp(m.CreatedAt),
-		UpdatedAt:         fromTimestamp(m.UpdatedAt),
-		StartTime:         fromTimestamp(m.StartTime),
-		EndTime:           fromTimestamp(m.EndTime),
-		Duration:          time.Duration(m.DurationMs) * time.Millisecond,
-		Status:            m.Status,
-		ProxyRequestID:    m.ProxyRequestID,
-		IsStream:          m.IsStream == 1,
-		RequestModel:      m.RequestModel,
-		MappedModel:       m.MappedModel,
-		ResponseModel:     m.ResponseModel,
-		RequestInfo:       fromJSON[*domain.RequestInfo](string(m.RequestInfo)),
-		ResponseInfo:      fromJSON[*domain.ResponseInfo](string(m.ResponseInfo)),
-		RouteID:           m.RouteID,
-		ProviderID:        m.ProviderID,
-		InputTokenCount:   m.InputTokenCount,
-		OutputTokenCount:  m.OutputTokenCount,
-		CacheReadCount:    m.CacheReadCount,
-		CacheWriteCount:   m.CacheWriteCount,
-		Cache5mWriteCount: m.Cache5mWriteCount,
-		Cache1hWriteCount: m.Cache1hWriteCount,
-		Cost:              m.Cost,
+	a := &domain.ProxyUpstreamAttempt{
+		ID:                     m.ID,
+		CreatedAt:              fromTimestamp(m.CreatedAt),
+		UpdatedAt:              fromTimestamp(m.UpdatedAt),
+		StartTime:              fromTimestamp(m.StartTime),
+		EndTime:                fromTimestamp(m.EndTime),
+		Duration:               time.Duration(m.DurationMs) * time.Millisecond,
+		Status:                 m.Status,
+		ProxyRequestID:         m.ProxyRequestID,
+		IsStream:               m.IsStream == 1,
+		RequestModel:           m.RequestModel,
+		MappedModel:            m.MappedModel,
+		ResponseModel:          m.ResponseModel,
+		RequestInfo:            fromJSON[*domain.RequestInfo](string(m.RequestInfo)),
+		ResponseInfo:           fromJSON[*domain.ResponseInfo](string(m.ResponseInfo)),
+		RouteID:                m.RouteID,
+		ProviderID:             m.ProviderID,
+		InputTokenCount:        m.InputTokenCount,
+		OutputTokenCount:       m.OutputTokenCount,
+		CacheReadCount:         m.CacheReadCount,
+		CacheWriteCount:        m.CacheWriteCount,
+		Cache5mWriteCount:      m.Cache5mWriteCount,
+		Cache1hWriteCount:      m.Cache1hWriteCount,
+		Cost:                   m.Cost,
+		StatusCode:             m.StatusCode,
+		MaxTokensClampedFrom:   m.MaxTokensClampedFrom,
+		CooldownCappedFromSecs: m.CooldownCappedFromSecs,
+		HedgeOfAttemptID:       m.HedgeOfAttemptID,
+		EnsembleOfAttemptID:    m.EnsembleOfAttemptID,
+		EnsembleChosen:         m.EnsembleChosen == 1,
+		EmptyResponse:          m.EmptyResponse == 1,
+		DroppedParams:          m.DroppedParams,
+	}
+	if m.TimingCaptured == 1 {
+		a.Timing = &domain.UpstreamTiming{
+			DNS:       time.Duration(m.TimingDNSUs) * time.Microsecond,
+			Connect:   time.Duration(m.TimingConnectUs) * time.Microsecond,
+			TLS:       time.Duration(m.TimingTLSUs) * time.Microsecond,
+			FirstByte: time.Duration(m.TimingFirstByteUs) * time.Microsecond,
+			Reused:    m.TimingReused == 1,
+		}
 	}
+	return a
 }
 
 func (r *ProxyUpstreamAttemptRepository) toDomainList(models []ProxyUpstreamAttempt) []*domain.ProxyUpstreamAttempt {
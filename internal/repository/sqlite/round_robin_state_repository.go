@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"time"
+
+	"github.com/awsl-project/maxx/internal/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RoundRobinStateRepository struct {
+	db *DB
+}
+
+func NewRoundRobinStateRepository(db *DB) repository.RoundRobinStateRepository {
+	return &RoundRobinStateRepository{db: db}
+}
+
+func (r *RoundRobinStateRepository) GetAll() (map[string]uint64, error) {
+	var models []RoundRobinState
+	if err := r.db.gorm.Find(&models).Error; err != nil {
+		return nil, err
+	}
+	counters := make(map[string]uint64, len(models))
+	for _, m := range models {
+		counters[m.StateKey] = m.Counter
+	}
+	return counters, nil
+}
+
+func (r *RoundRobinStateRepository) SaveAll(counters map[string]uint64) error {
+	if len(counters) == 0 {
+		return nil
+	}
+
+	now := toTimestamp(time.Now())
+	return r.db.gorm.Transaction(func(tx *gorm.DB) error {
+		for key, counter := range counters {
+			model := &RoundRobinState{
+				BaseModel: BaseModel{
+					CreatedAt: now,
+					UpdatedAt: now,
+				},
+				StateKey: key,
+				Counter:  counter,
+			}
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "state_key"}},
+				DoUpdates: clause.Assignments(map[string]any{
+					"counter":    counter,
+					"updated_at": now,
+				}),
+			}).Create(model).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
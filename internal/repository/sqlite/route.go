@@ -119,28 +119,44 @@ func (r *RouteRepository) toModel(route *domain.Route) *Route {
 			},
 			DeletedAt: toTimestampPtr(route.DeletedAt),
 		},
-		IsEnabled:     isEnabled,
-		IsNative:      isNative,
-		ProjectID:     route.ProjectID,
-		ClientType:    string(route.ClientType),
-		ProviderID:    route.ProviderID,
-		Position:      route.Position,
-		RetryConfigID: route.RetryConfigID,
+		IsEnabled:        isEnabled,
+		IsNative:         isNative,
+		ProjectID:        route.ProjectID,
+		ClientType:       string(route.ClientType),
+		ProviderID:       route.ProviderID,
+		Position:         route.Position,
+		RetryConfigID:    route.RetryConfigID,
+		HedgeDelayMs:     route.HedgeDelayMs,
+		FailFast:         boolToInt(route.FailFast),
+		EnsembleSize:     route.EnsembleSize,
+		EnsembleStrategy: route.EnsembleStrategy,
+		APITokenIDs:      LongText(toJSON(route.APITokenIDs)),
+		Conditions:       LongText(toJSON(route.Conditions)),
+		FallbackResponse: LongText(toJSON(route.FallbackResponse)),
+		DefaultModel:     route.DefaultModel,
 	}
 }
 
 func (r *RouteRepository) toDomain(m *Route) *domain.Route {
 	return &domain.Route{
-		ID:            m.ID,
-		CreatedAt:     fromTimestamp(m.CreatedAt),
-		UpdatedAt:     fromTimestamp(m.UpdatedAt),
-		DeletedAt:     fromTimestampPtr(m.DeletedAt),
-		IsEnabled:     m.IsEnabled == 1,
-		IsNative:      m.IsNative == 1,
-		ProjectID:     m.ProjectID,
-		ClientType:    domain.ClientType(m.ClientType),
-		ProviderID:    m.ProviderID,
-		Position:      m.Position,
-		RetryConfigID: m.RetryConfigID,
+		ID:               m.ID,
+		CreatedAt:        fromTimestamp(m.CreatedAt),
+		UpdatedAt:        fromTimestamp(m.UpdatedAt),
+		DeletedAt:        fromTimestampPtr(m.DeletedAt),
+		IsEnabled:        m.IsEnabled == 1,
+		IsNative:         m.IsNative == 1,
+		ProjectID:        m.ProjectID,
+		ClientType:       domain.ClientType(m.ClientType),
+		ProviderID:       m.ProviderID,
+		Position:         m.Position,
+		RetryConfigID:    m.RetryConfigID,
+		HedgeDelayMs:     m.HedgeDelayMs,
+		FailFast:         m.FailFast == 1,
+		EnsembleSize:     m.EnsembleSize,
+		EnsembleStrategy: m.EnsembleStrategy,
+		APITokenIDs:      fromJSON[[]uint64](string(m.APITokenIDs)),
+		Conditions:       fromJSON[*domain.RouteConditions](string(m.Conditions)),
+		FallbackResponse: fromJSON[*domain.FallbackResponseConfig](string(m.FallbackResponse)),
+		DefaultModel:     m.DefaultModel,
 	}
 }
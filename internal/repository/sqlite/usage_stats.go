@@ -3,6 +3,8 @@ package sqlite
 import (
 	"fmt"
 	"log"
+	"math"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -14,11 +16,12 @@ import (
 )
 
 type UsageStatsRepository struct {
-	db *DB
+	db            *DB
+	realtimeCache *realtimeStatsCache
 }
 
 func NewUsageStatsRepository(db *DB) *UsageStatsRepository {
-	return &UsageStatsRepository{db: db}
+	return &UsageStatsRepository{db: db, realtimeCache: newRealtimeStatsCache()}
 }
 
 // getConfiguredTimezone 获取配置的时区，默认 Asia/Shanghai
@@ -89,11 +92,41 @@ func TruncateToGranularityInTimezone(t time.Time, g domain.Granularity, loc *tim
 }
 
 // Upsert 更新或插入统计记录
+// 聚合任务会反复写入最近的时间桶，只有统计值变化时才更新 updated_at，
+// 以便增量查询（UpdatedSince）只返回真正变化的时间桶
 func (r *UsageStatsRepository) Upsert(stats *domain.UsageStats) error {
+	defer r.realtimeCache.invalidate()
 	now := time.Now()
 	stats.CreatedAt = now
+	stats.UpdatedAt = now
 
 	model := r.toModel(stats)
+	columns := []string{
+		"total_requests", "successful_requests", "failed_requests", "total_duration_ms",
+		"input_tokens", "output_tokens", "cache_read", "cache_write", "cost",
+	}
+	values := []any{
+		stats.TotalRequests, stats.SuccessfulRequests, stats.FailedRequests, stats.TotalDurationMs,
+		stats.InputTokens, stats.OutputTokens, stats.CacheRead, stats.CacheWrite, stats.Cost,
+	}
+
+	// 1. 已存在且统计值有变化：更新统计值和 updated_at
+	changed := make([]string, len(columns))
+	updates := map[string]any{"updated_at": model.UpdatedAt}
+	for i, column := range columns {
+		changed[i] = column + " <> ?"
+		updates[column] = values[i]
+	}
+	result := r.db.gorm.Model(&UsageStats{}).
+		Where("granularity = ? AND time_bucket = ? AND route_id = ? AND provider_id = ? AND project_id = ? AND api_token_id = ? AND client_type = ? AND model = ?",
+			model.Granularity, model.TimeBucket, model.RouteID, model.ProviderID, model.ProjectID, model.APITokenID, model.ClientType, model.Model).
+		Where("("+strings.Join(changed, " OR ")+")", values...).
+		UpdateColumns(updates)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+
+	// 2. 不存在则插入，已存在（统计值未变化）则保持不变
 	return r.db.gorm.Clauses(clause.OnConflict{
 		Columns: []clause.Column{
 			{Name: "granularity"},
@@ -105,17 +138,7 @@ func (r *UsageStatsRepository) Upsert(stats *domain.UsageStats) error {
 			{Name: "client_type"},
 			{Name: "model"},
 		},
-		DoUpdates: clause.Assignments(map[string]any{
-			"total_requests":      stats.TotalRequests,
-			"successful_requests": stats.SuccessfulRequests,
-			"failed_requests":     stats.FailedRequests,
-			"total_duration_ms":   stats.TotalDurationMs,
-			"input_tokens":        stats.InputTokens,
-			"output_tokens":       stats.OutputTokens,
-			"cache_read":          stats.CacheRead,
-			"cache_write":         stats.CacheWrite,
-			"cost":                stats.Cost,
-		}),
+		DoNothing: true,
 	}).Create(model).Error
 }
 
@@ -171,6 +194,10 @@ func (r *UsageStatsRepository) Query(filter repository.UsageStatsFilter) ([]*dom
 		conditions = append(conditions, "model = ?")
 		args = append(args, *filter.Model)
 	}
+	if filter.UpdatedSince != nil {
+		conditions = append(conditions, "updated_at > ?")
+		args = append(args, toTimestamp(*filter.UpdatedSince))
+	}
 
 	var models []UsageStats
 	err := r.db.gorm.Where(strings.Join(conditions, " AND "), args...).
@@ -223,6 +250,14 @@ func (r *UsageStatsRepository) QueryWithRealtime(filter repository.UsageStatsFil
 		return results, nil
 	}
 
+	// 当前时间桶的计算结果在缓存窗口内按过滤条件复用
+	cacheWindow := r.getRealtimeCacheWindow()
+	cacheKey := realtimeCacheKey(filter, currentBucket)
+	cached, generation, ok := r.realtimeCache.get(cacheKey, cacheWindow)
+	if ok {
+		return r.mergeCurrentBucketStats(results, cached, currentBucket, filter.Granularity), nil
+	}
+
 	// 2. 对于当前时间桶，并发分层查询（每层用最粗粒度的预聚合数据）：
 	//    - 已完成的周: usage_stats (granularity='week') [仅 month 粒度]
 	//    - 已完成的天: usage_stats (granularity='day') [week/month 粒度]
@@ -325,6 +360,9 @@ func (r *UsageStatsRepository) QueryWithRealtime(filter repository.UsageStatsFil
 
 	// 3. 将所有数据聚合为当前时间桶
 	currentBucketStats := r.aggregateToTargetBucket(allStats, currentBucket, filter.Granularity)
+	if cacheWindow > 0 {
+		r.realtimeCache.put(cacheKey, generation, currentBucketStats, cacheWindow)
+	}
 
 	// 4. 将当前时间桶数据合并到结果中（替换预聚合数据）
 	results = r.mergeCurrentBucketStats(results, currentBucketStats, currentBucket, filter.Granularity)
@@ -797,8 +835,289 @@ func (r *UsageStatsRepository) GetSummaryByClientType(filter repository.UsageSta
 	return results, rows.Err()
 }
 
+// GetSummaryByClientApp 按客户端应用维度获取汇总统计
+// client_app 不在 usage_stats 中，直接聚合 proxy_requests，Granularity 和 RouteID 过滤不适用
+func (r *UsageStatsRepository) GetSummaryByClientApp(filter repository.UsageStatsFilter) (map[string]*domain.UsageStatsSummary, error) {
+	var conditions []string
+	var args []interface{}
+
+	conditions = append(conditions, "status IN ('COMPLETED', 'FAILED', 'CANCELLED')")
+
+	if filter.StartTime != nil {
+		conditions = append(conditions, "end_time >= ?")
+		args = append(args, toTimestamp(*filter.StartTime))
+	}
+	if filter.EndTime != nil {
+		conditions = append(conditions, "end_time <= ?")
+		args = append(args, toTimestamp(*filter.EndTime))
+	}
+	if filter.ProviderID != nil {
+		conditions = append(conditions, "provider_id = ?")
+		args = append(args, *filter.ProviderID)
+	}
+	if filter.ProjectID != nil {
+		conditions = append(conditions, "project_id = ?")
+		args = append(args, *filter.ProjectID)
+	}
+	if filter.ClientType != nil {
+		conditions = append(conditions, "client_type = ?")
+		args = append(args, *filter.ClientType)
+	}
+	if filter.APITokenID != nil {
+		conditions = append(conditions, "api_token_id = ?")
+		args = append(args, *filter.APITokenID)
+	}
+	if filter.Model != nil {
+		conditions = append(conditions, "response_model = ?")
+		args = append(args, *filter.Model)
+	}
+
+	query := `
+		SELECT
+			COALESCE(client_app, ''),
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN status = 'COMPLETED' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status IN ('FAILED', 'CANCELLED') THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(input_token_count), 0),
+			COALESCE(SUM(output_token_count), 0),
+			COALESCE(SUM(cache_read_count), 0),
+			COALESCE(SUM(cache_write_count), 0),
+			COALESCE(SUM(cost), 0)
+		FROM proxy_requests
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		GROUP BY COALESCE(client_app, '')
+	`
+
+	rows, err := r.db.gorm.Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make(map[string]*domain.UsageStatsSummary)
+	for rows.Next() {
+		var clientApp string
+		var s domain.UsageStatsSummary
+		err := rows.Scan(
+			&clientApp,
+			&s.TotalRequests, &s.SuccessfulRequests, &s.FailedRequests,
+			&s.TotalInputTokens, &s.TotalOutputTokens,
+			&s.TotalCacheRead, &s.TotalCacheWrite, &s.TotalCost,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if s.TotalRequests > 0 {
+			s.SuccessRate = float64(s.SuccessfulRequests) / float64(s.TotalRequests) * 100
+		}
+		results[clientApp] = &s
+	}
+	return results, rows.Err()
+}
+
+// GetSummaryByTag 按成本分摊标签维度获取汇总统计
+// 标签存放在 proxy_request_tags 中，与 proxy_requests 关联聚合，Granularity 和 RouteID 过滤不适用
+// 一个请求带多个标签时会计入每个标签，因此各标签之和可能大于总量
+func (r *UsageStatsRepository) GetSummaryByTag(filter repository.UsageStatsFilter) (map[string]*domain.UsageStatsSummary, error) {
+	var conditions []string
+	var args []interface{}
+
+	conditions = append(conditions, "p.status IN ('COMPLETED', 'FAILED', 'CANCELLED')")
+
+	if filter.StartTime != nil {
+		conditions = append(conditions, "p.end_time >= ?")
+		args = append(args, toTimestamp(*filter.StartTime))
+	}
+	if filter.EndTime != nil {
+		conditions = append(conditions, "p.end_time <= ?")
+		args = append(args, toTimestamp(*filter.EndTime))
+	}
+	if filter.ProviderID != nil {
+		conditions = append(conditions, "p.provider_id = ?")
+		args = append(args, *filter.ProviderID)
+	}
+	if filter.ProjectID != nil {
+		conditions = append(conditions, "p.project_id = ?")
+		args = append(args, *filter.ProjectID)
+	}
+	if filter.ClientType != nil {
+		conditions = append(conditions, "p.client_type = ?")
+		args = append(args, *filter.ClientType)
+	}
+	if filter.APITokenID != nil {
+		conditions = append(conditions, "p.api_token_id = ?")
+		args = append(args, *filter.APITokenID)
+	}
+	if filter.Model != nil {
+		conditions = append(conditions, "p.response_model = ?")
+		args = append(args, *filter.Model)
+	}
+
+	query := `
+		SELECT
+			t.tag,
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN p.status = 'COMPLETED' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN p.status IN ('FAILED', 'CANCELLED') THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(p.input_token_count), 0),
+			COALESCE(SUM(p.output_token_count), 0),
+			COALESCE(SUM(p.cache_read_count), 0),
+			COALESCE(SUM(p.cache_write_count), 0),
+			COALESCE(SUM(p.cost), 0)
+		FROM proxy_request_tags t
+		JOIN proxy_requests p ON p.id = t.proxy_request_id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		GROUP BY t.tag
+	`
+
+	rows, err := r.db.gorm.Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make(map[string]*domain.UsageStatsSummary)
+	for rows.Next() {
+		var tag string
+		var s domain.UsageStatsSummary
+		err := rows.Scan(
+			&tag,
+			&s.TotalRequests, &s.SuccessfulRequests, &s.FailedRequests,
+			&s.TotalInputTokens, &s.TotalOutputTokens,
+			&s.TotalCacheRead, &s.TotalCacheWrite, &s.TotalCost,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if s.TotalRequests > 0 {
+			s.SuccessRate = float64(s.SuccessfulRequests) / float64(s.TotalRequests) * 100
+		}
+		results[tag] = &s
+	}
+	return results, rows.Err()
+}
+
+// GetEmptyResponseStats 按供应商和客户端类型统计成功但没有内容的响应（基于 proxy_upstream_attempts）
+// retry 模式下空响应的 attempt 记为失败，因此总数包含所有已结束的 attempt
+func (r *UsageStatsRepository) GetEmptyResponseStats(filter repository.UsageStatsFilter) ([]*domain.EmptyResponseStats, error) {
+	conditions, args := attemptFilterConditions(filter)
+	conditions = append(conditions, "a.status IN ('COMPLETED', 'FAILED', 'CANCELLED')")
+
+	query := `
+		SELECT
+			COALESCE(a.provider_id, 0), COALESCE(r.client_type, ''),
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN a.empty_response = 1 THEN 1 ELSE 0 END), 0)
+		FROM proxy_upstream_attempts a
+		LEFT JOIN proxy_requests r ON a.proxy_request_id = r.id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		GROUP BY a.provider_id, r.client_type
+		HAVING SUM(CASE WHEN a.empty_response = 1 THEN 1 ELSE 0 END) > 0
+		ORDER BY a.provider_id, r.client_type
+	`
+
+	rows, err := r.db.gorm.Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*domain.EmptyResponseStats
+	for rows.Next() {
+		var s domain.EmptyResponseStats
+		if err := rows.Scan(&s.ProviderID, &s.ClientType, &s.TotalAttempts, &s.EmptyResponses); err != nil {
+			return nil, err
+		}
+		if s.TotalAttempts > 0 {
+			s.EmptyRate = float64(s.EmptyResponses) / float64(s.TotalAttempts) * 100
+		}
+		results = append(results, &s)
+	}
+	return results, rows.Err()
+}
+
+// GetUpstreamTimingStats 按供应商统计上游请求各阶段的平均耗时（基于开启采集后的 proxy_upstream_attempts）
+func (r *UsageStatsRepository) GetUpstreamTimingStats(filter repository.UsageStatsFilter) ([]*domain.UpstreamTimingStats, error) {
+	conditions, args := attemptFilterConditions(filter)
+	conditions = append(conditions, "a.timing_captured = 1")
+
+	query := `
+		SELECT
+			COALESCE(a.provider_id, 0),
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN a.timing_reused = 0 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN a.timing_reused = 1 THEN 1 ELSE 0 END), 0),
+			COALESCE(AVG(CASE WHEN a.timing_reused = 0 THEN a.timing_dns_us END), 0),
+			COALESCE(AVG(CASE WHEN a.timing_reused = 0 THEN a.timing_connect_us END), 0),
+			COALESCE(AVG(CASE WHEN a.timing_reused = 0 THEN a.timing_tls_us END), 0),
+			COALESCE(AVG(a.timing_first_byte_us), 0)
+		FROM proxy_upstream_attempts a
+		LEFT JOIN proxy_requests r ON a.proxy_request_id = r.id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		GROUP BY a.provider_id
+		ORDER BY a.provider_id
+	`
+
+	rows, err := r.db.gorm.Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*domain.UpstreamTimingStats
+	for rows.Next() {
+		var s domain.UpstreamTimingStats
+		var dnsUs, connectUs, tlsUs, firstByteUs float64
+		if err := rows.Scan(&s.ProviderID, &s.Attempts, &s.NewConns, &s.ReusedConns, &dnsUs, &connectUs, &tlsUs, &firstByteUs); err != nil {
+			return nil, err
+		}
+		s.AvgDNSMs = dnsUs / 1000
+		s.AvgConnectMs = connectUs / 1000
+		s.AvgTLSMs = tlsUs / 1000
+		s.AvgFirstByteMs = firstByteUs / 1000
+		results = append(results, &s)
+	}
+	return results, rows.Err()
+}
+
+// attemptFilterConditions 将统计过滤条件转换为 proxy_upstream_attempts a LEFT JOIN proxy_requests r 上的 WHERE 条件
+func attemptFilterConditions(filter repository.UsageStatsFilter) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if filter.StartTime != nil {
+		conditions = append(conditions, "a.end_time >= ?")
+		args = append(args, toTimestamp(*filter.StartTime))
+	}
+	if filter.EndTime != nil {
+		conditions = append(conditions, "a.end_time <= ?")
+		args = append(args, toTimestamp(*filter.EndTime))
+	}
+	if filter.ProviderID != nil {
+		conditions = append(conditions, "a.provider_id = ?")
+		args = append(args, *filter.ProviderID)
+	}
+	if filter.ProjectID != nil {
+		conditions = append(conditions, "r.project_id = ?")
+		args = append(args, *filter.ProjectID)
+	}
+	if filter.ClientType != nil {
+		conditions = append(conditions, "r.client_type = ?")
+		args = append(args, *filter.ClientType)
+	}
+	if filter.APITokenID != nil {
+		conditions = append(conditions, "r.api_token_id = ?")
+		args = append(args, *filter.APITokenID)
+	}
+	if filter.Model != nil {
+		conditions = append(conditions, "a.response_model = ?")
+		args = append(args, *filter.Model)
+	}
+	return conditions, args
+}
+
 // DeleteOlderThan 删除指定粒度下指定时间之前的统计记录
 func (r *UsageStatsRepository) DeleteOlderThan(granularity domain.Granularity, before time.Time) (int64, error) {
+	defer r.realtimeCache.invalidate()
 	result := r.db.gorm.Where("granularity = ? AND time_bucket < ?", granularity, toTimestamp(before)).Delete(&UsageStats{})
 	if result.Error != nil {
 		return 0, result.Error
@@ -884,9 +1203,312 @@ func (r *UsageStatsRepository) GetProviderStats(clientType string, projectID uin
 	return stats, rows.Err()
 }
 
+// GetProviderReport 获取 start 之后各 Provider 的汇总报表
+// 计数、Token、成本来自 usage_stats 预聚合表（30 天内用小时粒度，更长窗口用天粒度），
+// 耗时分位数无法从预聚合数据得出，回退到 proxy_upstream_attempts 中成功记录的 duration_ms
+func (r *UsageStatsRepository) GetProviderReport(start time.Time) (map[uint64]*domain.ProviderReport, error) {
+	granularity := domain.GranularityHour
+	if time.Since(start) > 30*24*time.Hour {
+		granularity = domain.GranularityDay
+	}
+	bucketStart := TruncateToGranularityInTimezone(start, granularity, r.getConfiguredTimezone())
+
+	query := `
+		SELECT
+			provider_id,
+			COALESCE(SUM(total_requests), 0),
+			COALESCE(SUM(successful_requests), 0),
+			COALESCE(SUM(failed_requests), 0),
+			COALESCE(SUM(input_tokens), 0),
+			COALESCE(SUM(output_tokens), 0),
+			COALESCE(SUM(cache_read), 0),
+			COALESCE(SUM(cache_write), 0),
+			COALESCE(SUM(cost), 0)
+		FROM usage_stats
+		WHERE granularity = ? AND time_bucket >= ? AND provider_id > 0
+		GROUP BY provider_id
+	`
+
+	rows, err := r.db.gorm.Raw(query, granularity, toTimestamp(bucketStart)).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := make(map[uint64]*domain.ProviderReport)
+	for rows.Next() {
+		var rp domain.ProviderReport
+		err := rows.Scan(
+			&rp.ProviderID,
+			&rp.TotalRequests, &rp.SuccessfulRequests, &rp.FailedRequests,
+			&rp.TotalInputTokens, &rp.TotalOutputTokens,
+			&rp.TotalCacheRead, &rp.TotalCacheWrite, &rp.TotalCost,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if rp.TotalRequests > 0 {
+			rp.SuccessRate = float64(rp.SuccessfulRequests) / float64(rp.TotalRequests) * 100
+		}
+		reports[rp.ProviderID] = &rp
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 耗时分位数：只取 provider_id 和 duration_ms 两列，按 Provider 分组排序后在内存中计算
+	latencyRows, err := r.db.gorm.Raw(`
+		SELECT provider_id, duration_ms
+		FROM proxy_upstream_attempts
+		WHERE status = 'COMPLETED' AND end_time >= ? AND provider_id > 0
+		ORDER BY provider_id, duration_ms
+	`, toTimestamp(start)).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer latencyRows.Close()
+
+	durations := make(map[uint64][]int64)
+	for latencyRows.Next() {
+		var providerID uint64
+		var durationMs int64
+		if err := latencyRows.Scan(&providerID, &durationMs); err != nil {
+			return nil, err
+		}
+		durations[providerID] = append(durations[providerID], durationMs)
+	}
+	if err := latencyRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for providerID, sorted := range durations {
+		rp, ok := reports[providerID]
+		if !ok {
+			rp = &domain.ProviderReport{ProviderID: providerID}
+			reports[providerID] = rp
+		}
+		rp.P50LatencyMs = percentile(sorted, 50)
+		rp.P95LatencyMs = percentile(sorted, 95)
+	}
+
+	return reports, nil
+}
+
+// percentile 使用最近秩法计算已排序数据的分位数
+func percentile(sorted []int64, p float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// GetModelStats 按模型维度获取成功率、耗时、Token、成本及错误原因分布
+// 计数类数据来自 usage_stats 预聚合表，错误原因来自 proxy_upstream_attempts 中的失败记录
+func (r *UsageStatsRepository) GetModelStats(filter repository.UsageStatsFilter) ([]*domain.ModelStats, error) {
+	var conditions []string
+	var args []interface{}
+
+	conditions = append(conditions, "granularity = ?")
+	args = append(args, filter.Granularity)
+
+	if filter.StartTime != nil {
+		conditions = append(conditions, "time_bucket >= ?")
+		args = append(args, toTimestamp(*filter.StartTime))
+	}
+	if filter.EndTime != nil {
+		conditions = append(conditions, "time_bucket <= ?")
+		args = append(args, toTimestamp(*filter.EndTime))
+	}
+	if filter.ProviderID != nil {
+		conditions = append(conditions, "provider_id = ?")
+		args = append(args, *filter.ProviderID)
+	}
+	if filter.ProjectID != nil {
+		conditions = append(conditions, "project_id = ?")
+		args = append(args, *filter.ProjectID)
+	}
+	if filter.ClientType != nil {
+		conditions = append(conditions, "client_type = ?")
+		args = append(args, *filter.ClientType)
+	}
+	if filter.Model != nil {
+		conditions = append(conditions, "model = ?")
+		args = append(args, *filter.Model)
+	}
+
+	query := `
+		SELECT
+			model,
+			COALESCE(SUM(total_requests), 0),
+			COALESCE(SUM(successful_requests), 0),
+			COALESCE(SUM(failed_requests), 0),
+			COALESCE(SUM(total_duration_ms), 0),
+			COALESCE(SUM(input_tokens), 0),
+			COALESCE(SUM(output_tokens), 0),
+			COALESCE(SUM(cache_read), 0),
+			COALESCE(SUM(cache_write), 0),
+			COALESCE(SUM(cost), 0)
+		FROM usage_stats
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		GROUP BY model
+		ORDER BY SUM(total_requests) DESC
+	`
+
+	rows, err := r.db.gorm.Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*domain.ModelStats
+	byModel := make(map[string]*domain.ModelStats)
+	for rows.Next() {
+		var s domain.ModelStats
+		var durationMs uint64
+		err := rows.Scan(
+			&s.Model,
+			&s.TotalRequests, &s.SuccessfulRequests, &s.FailedRequests, &durationMs,
+			&s.TotalInputTokens, &s.TotalOutputTokens,
+			&s.TotalCacheRead, &s.TotalCacheWrite, &s.TotalCost,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if s.TotalRequests > 0 {
+			s.SuccessRate = float64(s.SuccessfulRequests) / float64(s.TotalRequests) * 100
+			s.AvgDurationMs = float64(durationMs) / float64(s.TotalRequests)
+		}
+		s.Errors = []domain.ModelErrorStats{}
+		results = append(results, &s)
+		byModel[s.Model] = &s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	errorCounts, err := r.queryModelErrorCounts(filter)
+	if err != nil {
+		return nil, err
+	}
+	for model, reasons := range errorCounts {
+		s, ok := byModel[model]
+		if !ok {
+			continue
+		}
+		for reason, count := range reasons {
+			s.Errors = append(s.Errors, domain.ModelErrorStats{Reason: reason, Count: count})
+		}
+		sort.Slice(s.Errors, func(i, j int) bool {
+			if s.Errors[i].Count != s.Errors[j].Count {
+				return s.Errors[i].Count > s.Errors[j].Count
+			}
+			return s.Errors[i].Reason < s.Errors[j].Reason
+		})
+	}
+
+	return results, nil
+}
+
+// queryModelErrorCounts 统计失败 attempt 的错误原因分布
+// 按 (model, status, status_code) 分组后在内存中归类，避免扫描响应体
+func (r *UsageStatsRepository) queryModelErrorCounts(filter repository.UsageStatsFilter) (map[string]map[string]uint64, error) {
+	var conditions []string
+	var args []interface{}
+
+	conditions = append(conditions, "a.status IN ('FAILED', 'CANCELLED')")
+
+	if filter.StartTime != nil {
+		conditions = append(conditions, "a.end_time >= ?")
+		args = append(args, toTimestamp(*filter.StartTime))
+	}
+	if filter.EndTime != nil {
+		conditions = append(conditions, "a.end_time <= ?")
+		args = append(args, toTimestamp(*filter.EndTime))
+	}
+	if filter.ProviderID != nil {
+		conditions = append(conditions, "a.provider_id = ?")
+		args = append(args, *filter.ProviderID)
+	}
+	if filter.ProjectID != nil {
+		conditions = append(conditions, "r.project_id = ?")
+		args = append(args, *filter.ProjectID)
+	}
+	if filter.ClientType != nil {
+		conditions = append(conditions, "r.client_type = ?")
+		args = append(args, *filter.ClientType)
+	}
+	if filter.Model != nil {
+		conditions = append(conditions, "a.response_model = ?")
+		args = append(args, *filter.Model)
+	}
+
+	query := `
+		SELECT
+			COALESCE(a.response_model, ''),
+			a.status,
+			COALESCE(a.status_code, 0),
+			COUNT(*)
+		FROM proxy_upstream_attempts a
+		LEFT JOIN proxy_requests r ON a.proxy_request_id = r.id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		GROUP BY a.response_model, a.status, a.status_code
+	`
+
+	rows, err := r.db.gorm.Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make(map[string]map[string]uint64)
+	for rows.Next() {
+		var model, status string
+		var statusCode int
+		var count uint64
+		if err := rows.Scan(&model, &status, &statusCode, &count); err != nil {
+			return nil, err
+		}
+		reason := classifyAttemptError(status, statusCode)
+		if results[model] == nil {
+			results[model] = make(map[string]uint64)
+		}
+		results[model][reason] += count
+	}
+	return results, rows.Err()
+}
+
+// classifyAttemptError 根据 attempt 状态和上游状态码归类错误原因
+func classifyAttemptError(status string, statusCode int) string {
+	switch {
+	case status == "CANCELLED":
+		return "cancelled"
+	case statusCode == 0:
+		return "network_error"
+	case statusCode == 429:
+		return "rate_limit"
+	case statusCode == 401 || statusCode == 403:
+		return "auth_error"
+	case statusCode >= 500:
+		return "server_error"
+	case statusCode >= 400:
+		return "client_error"
+	default:
+		return "unknown"
+	}
+}
+
 // AggregateMinute 从原始数据聚合到分钟级别
 // 只聚合已完成的请求（COMPLETED/FAILED/CANCELLED），使用 end_time 作为时间桶
 func (r *UsageStatsRepository) AggregateMinute() (int, error) {
+	defer r.realtimeCache.invalidate()
 	now := time.Now().UTC()
 	currentMinute := now.Truncate(time.Minute)
 
@@ -1035,6 +1657,7 @@ func (r *UsageStatsRepository) AggregateMinute() (int, error) {
 // RollUp 从细粒度上卷到粗粒度
 // 对于 day/week/month 粒度，使用配置的时区来划分边界
 func (r *UsageStatsRepository) RollUp(from, to domain.Granularity) (int, error) {
+	defer r.realtimeCache.invalidate()
 	now := time.Now().UTC()
 
 	// 对于 day 及以上粒度，使用配置的时区
@@ -1159,6 +1782,7 @@ func (r *UsageStatsRepository) RollUp(from, to domain.Granularity) (int, error)
 // RollUpAll 从细粒度上卷到粗粒度（处理所有历史数据，用于重新计算）
 // 对于 day/week/month 粒度，使用配置的时区来划分边界
 func (r *UsageStatsRepository) RollUpAll(from, to domain.Granularity) (int, error) {
+	defer r.realtimeCache.invalidate()
 	now := time.Now().UTC()
 
 	// 对于 day 及以上粒度，使用配置的时区
@@ -1262,6 +1886,7 @@ func (r *UsageStatsRepository) RollUpAll(from, to domain.Granularity) (int, erro
 
 // ClearAndRecalculate 清空统计数据并重新从原始数据计算
 func (r *UsageStatsRepository) ClearAndRecalculate() error {
+	defer r.realtimeCache.invalidate()
 	// 1. 清空所有统计数据
 	if err := r.db.gorm.Exec(`DELETE FROM usage_stats`).Error; err != nil {
 		return fmt.Errorf("failed to clear usage_stats: %w", err)
@@ -1423,6 +2048,7 @@ func (r *UsageStatsRepository) toModel(s *domain.UsageStats) *UsageStats {
 	return &UsageStats{
 		ID:                 s.ID,
 		CreatedAt:          toTimestamp(s.CreatedAt),
+		UpdatedAt:          toTimestamp(s.UpdatedAt),
 		TimeBucket:         toTimestamp(s.TimeBucket),
 		Granularity:        string(s.Granularity),
 		RouteID:            s.RouteID,
@@ -1447,6 +2073,7 @@ func (r *UsageStatsRepository) toDomain(m *UsageStats) *domain.UsageStats {
 	return &domain.UsageStats{
 		ID:                 m.ID,
 		CreatedAt:          fromTimestamp(m.CreatedAt),
+		UpdatedAt:          fromTimestamp(m.UpdatedAt),
 		TimeBucket:         fromTimestamp(m.TimeBucket),
 		Granularity:        domain.Granularity(m.Granularity),
 		RouteID:            m.RouteID,
@@ -1480,7 +2107,9 @@ func (r *UsageStatsRepository) toDomainList(models []UsageStats) []*domain.Usage
 //   1. 历史 day 粒度数据 (371天) → 热力图、昨日、Provider统计(30天)
 //   2. 今日实时 hour 粒度 (QueryWithRealtime) → 今日统计、24h趋势、今日热力图
 //   3. 全量 month 粒度 (QueryWithRealtime) → 全量统计、Top模型(全量)
-func (r *UsageStatsRepository) QueryDashboardData() (*domain.DashboardData, error) {
+//
+// projectID 不为 nil 时只统计该项目的数据（每个查询都加上项目条件），nil 表示全局
+func (r *UsageStatsRepository) QueryDashboardData(projectID *uint64) (*domain.DashboardData, error) {
 	// 获取配置的时区
 	loc := r.getConfiguredTimezone()
 	now := time.Now().In(loc)
@@ -1505,6 +2134,12 @@ func (r *UsageStatsRepository) QueryDashboardData() (*domain.DashboardData, erro
 	// 查询1: 历史 day 粒度数据 (371天，不含今天)
 	// 用于：热力图历史、昨日统计、Provider统计(30天)
 	g.Go(func() error {
+		projectCondition := ""
+		args := []interface{}{toTimestamp(days371Ago), toTimestamp(todayStart)}
+		if projectID != nil {
+			projectCondition = "AND project_id = ?"
+			args = append(args, *projectID)
+		}
 		query := `
 			SELECT time_bucket, provider_id, model,
 				SUM(total_requests), SUM(successful_requests),
@@ -1512,9 +2147,10 @@ func (r *UsageStatsRepository) QueryDashboardData() (*domain.DashboardData, erro
 			FROM usage_stats
 			WHERE granularity = 'day'
 			AND time_bucket >= ? AND time_bucket < ?
+			` + projectCondition + `
 			GROUP BY time_bucket, provider_id, model
 		`
-		rows, err := r.db.gorm.Raw(query, toTimestamp(days371Ago), toTimestamp(todayStart)).Rows()
+		rows, err := r.db.gorm.Raw(query, args...).Rows()
 		if err != nil {
 			return err
 		}
@@ -1608,6 +2244,7 @@ func (r *UsageStatsRepository) QueryDashboardData() (*domain.DashboardData, erro
 		filter := repository.UsageStatsFilter{
 			Granularity: domain.GranularityHour,
 			StartTime:   &hours24Ago,
+			ProjectID:   projectID,
 		}
 		stats, err := r.QueryWithRealtime(filter)
 		if err != nil {
@@ -1738,6 +2375,7 @@ func (r *UsageStatsRepository) QueryDashboardData() (*domain.DashboardData, erro
 	g.Go(func() error {
 		filter := repository.UsageStatsFilter{
 			Granularity: domain.GranularityMonth,
+			ProjectID:   projectID,
 		}
 		stats, err := r.QueryWithRealtime(filter)
 		if err != nil {
@@ -1771,7 +2409,12 @@ func (r *UsageStatsRepository) QueryDashboardData() (*domain.DashboardData, erro
 
 		// 从 proxy_requests 表获取真正的首次使用时间
 		var firstRequestTime *int64
-		err = r.db.gorm.Raw("SELECT MIN(created_at) FROM proxy_requests").Scan(&firstRequestTime).Error
+		firstQuery, firstArgs := "SELECT MIN(created_at) FROM proxy_requests", []interface{}{}
+		if projectID != nil {
+			firstQuery += " WHERE project_id = ?"
+			firstArgs = append(firstArgs, *projectID)
+		}
+		err = r.db.gorm.Raw(firstQuery, firstArgs...).Scan(&firstRequestTime).Error
 		if err == nil && firstRequestTime != nil && *firstRequestTime > 0 {
 			firstUse := fromTimestamp(*firstRequestTime)
 			allTimeSummary.FirstUseDate = &firstUse
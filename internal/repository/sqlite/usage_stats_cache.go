@@ -0,0 +1,121 @@
+package sqlite
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+// defaultRealtimeCacheWindow 当前时间桶计算结果的默认缓存时长
+const defaultRealtimeCacheWindow = 5 * time.Second
+
+// realtimeStatsCache 缓存 QueryWithRealtime 中当前时间桶的计算结果
+// Dashboard 等页面会在短时间内以相同过滤条件反复查询，当前时间桶需要多层查询和实时扫描，
+// 缓存窗口内直接复用结果；聚合任务写入新数据时整体失效
+type realtimeStatsCache struct {
+	mu         sync.Mutex
+	entries    map[string]realtimeCacheEntry
+	generation uint64
+	now        func() time.Time
+}
+
+type realtimeCacheEntry struct {
+	stats    []*domain.UsageStats
+	cachedAt time.Time
+}
+
+func newRealtimeStatsCache() *realtimeStatsCache {
+	return &realtimeStatsCache{
+		entries: make(map[string]realtimeCacheEntry),
+		now:     time.Now,
+	}
+}
+
+// get 返回 window 内缓存的结果（副本）以及当前代数，写回缓存时需带上该代数
+func (c *realtimeStatsCache) get(key string, window time.Duration) ([]*domain.UsageStats, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || c.now().Sub(entry.cachedAt) >= window {
+		return nil, c.generation, false
+	}
+	return cloneUsageStats(entry.stats), c.generation, true
+}
+
+// put 写入缓存，计算期间缓存已失效（代数变化）时丢弃结果，避免写回旧数据
+// 写入时顺带清理超出 window 的条目，已切换到新时间桶的过滤条件不会一直占用内存
+func (c *realtimeStatsCache) put(key string, generation uint64, stats []*domain.UsageStats, window time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	now := c.now()
+	for k, entry := range c.entries {
+		if now.Sub(entry.cachedAt) >= window {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = realtimeCacheEntry{stats: cloneUsageStats(stats), cachedAt: now}
+}
+
+// invalidate 清空缓存
+func (c *realtimeStatsCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	clear(c.entries)
+}
+
+// cloneUsageStats 复制统计记录，调用方可以修改返回结果而不影响缓存
+func cloneUsageStats(stats []*domain.UsageStats) []*domain.UsageStats {
+	out := make([]*domain.UsageStats, len(stats))
+	for i, s := range stats {
+		copied := *s
+		out[i] = &copied
+	}
+	return out
+}
+
+// realtimeCacheKey 由粒度、当前时间桶和维度过滤条件组成缓存键
+// 起止时间只影响历史数据部分，不参与当前时间桶的计算
+func realtimeCacheKey(filter repository.UsageStatsFilter, currentBucket time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s|%d", filter.Granularity, currentBucket.Unix())
+	for _, id := range []*uint64{filter.RouteID, filter.ProviderID, filter.ProjectID, filter.APITokenID} {
+		if id == nil {
+			b.WriteString("|-")
+		} else {
+			fmt.Fprintf(&b, "|%d", *id)
+		}
+	}
+	for _, s := range []*string{filter.ClientType, filter.Model} {
+		if s == nil {
+			b.WriteString("|-")
+		} else {
+			b.WriteString("|" + strconv.Quote(*s))
+		}
+	}
+	return b.String()
+}
+
+// getRealtimeCacheWindow 获取配置的当前时间桶缓存时长，默认 5 秒，0 表示不缓存
+func (r *UsageStatsRepository) getRealtimeCacheWindow() time.Duration {
+	var value string
+	err := r.db.gorm.Table("system_settings").
+		Where("setting_key = ?", domain.SettingKeyStatsRealtimeCacheSecs).
+		Pluck("value", &value).Error
+	if err != nil || strings.TrimSpace(value) == "" {
+		return defaultRealtimeCacheWindow
+	}
+	secs, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || secs < 0 {
+		return defaultRealtimeCacheWindow
+	}
+	return time.Duration(secs) * time.Second
+}
@@ -0,0 +1,373 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+func TestGetSummaryByClientApp(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestRepo := NewProxyRequestRepository(db)
+	usageRepo := NewUsageStatsRepository(db)
+
+	now := time.Now()
+	for _, r := range []*domain.ProxyRequest{
+		{ClientApp: "claude-cli/2.0", Status: "COMPLETED", ProjectID: 1, InputTokenCount: 10, Cost: 5},
+		{ClientApp: "claude-cli/2.0", Status: "FAILED", ProjectID: 1, InputTokenCount: 3},
+		{ClientApp: "sdk-python/1.40", Status: "COMPLETED", ProjectID: 2, InputTokenCount: 7},
+		{Status: "COMPLETED", ProjectID: 1},
+		{ClientApp: "claude-cli/2.0", Status: "IN_PROGRESS", ProjectID: 1},
+	} {
+		r.EndTime = now
+		if err := requestRepo.Create(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := usageRepo.GetSummaryByClientApp(repository.UsageStatsFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d client apps, want 3: %v", len(got), got)
+	}
+	cli := got["claude-cli/2.0"]
+	if cli.TotalRequests != 2 || cli.SuccessfulRequests != 1 || cli.FailedRequests != 1 || cli.TotalInputTokens != 13 || cli.TotalCost != 5 {
+		t.Errorf("claude-cli summary = %+v", cli)
+	}
+	if got[""].TotalRequests != 1 {
+		t.Errorf("unidentified requests = %d, want 1", got[""].TotalRequests)
+	}
+
+	projectID := uint64(2)
+	got, err = usageRepo.GetSummaryByClientApp(repository.UsageStatsFilter{ProjectID: &projectID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got["sdk-python/1.40"] == nil {
+		t.Errorf("project 2 summary = %v, want only sdk-python/1.40", got)
+	}
+}
+
+func TestGetSummaryByTag(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestRepo := NewProxyRequestRepository(db)
+	usageRepo := NewUsageStatsRepository(db)
+
+	now := time.Now()
+	for _, r := range []*domain.ProxyRequest{
+		{Tags: []string{"env:prod", "feature:chat"}, Status: "COMPLETED", ProjectID: 1, InputTokenCount: 10, Cost: 5},
+		{Tags: []string{"env:prod"}, Status: "FAILED", ProjectID: 2, InputTokenCount: 3},
+		{Tags: []string{"env:dev"}, Status: "COMPLETED", ProjectID: 1, Cost: 2},
+		{Status: "COMPLETED", ProjectID: 1, Cost: 100},
+		{Tags: []string{"env:prod"}, Status: "IN_PROGRESS", ProjectID: 1},
+	} {
+		r.EndTime = now
+		if err := requestRepo.Create(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := usageRepo.GetSummaryByTag(repository.UsageStatsFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d tags, want 3: %v", len(got), got)
+	}
+	prod := got["env:prod"]
+	if prod.TotalRequests != 2 || prod.SuccessfulRequests != 1 || prod.FailedRequests != 1 || prod.TotalInputTokens != 13 || prod.TotalCost != 5 {
+		t.Errorf("env:prod summary = %+v", prod)
+	}
+	if got["feature:chat"].TotalCost != 5 || got["env:dev"].TotalCost != 2 {
+		t.Errorf("feature:chat = %+v, env:dev = %+v", got["feature:chat"], got["env:dev"])
+	}
+
+	projectID := uint64(2)
+	got, err = usageRepo.GetSummaryByTag(repository.UsageStatsFilter{ProjectID: &projectID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got["env:prod"] == nil || got["env:prod"].TotalRequests != 1 {
+		t.Errorf("project 2 summary = %v, want one env:prod request", got)
+	}
+
+	// The requests list filters on the same tags
+	requests, err := requestRepo.ListCursor(10, 0, 0, &domain.ProxyRequestFilter{Tag: "env:prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 3 {
+		t.Fatalf("tag filter returned %d requests, want 3", len(requests))
+	}
+	for _, r := range requests {
+		if !slices.Contains(r.Tags, "env:prod") {
+			t.Errorf("request %d has tags %v", r.ID, r.Tags)
+		}
+	}
+}
+
+func TestQueryUpdatedSince(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewUsageStatsRepository(db)
+
+	minute := time.Now().UTC().Truncate(time.Minute)
+	bucket := func(model string, requests uint64) *domain.UsageStats {
+		return &domain.UsageStats{TimeBucket: minute, Granularity: domain.GranularityMinute, ProviderID: 1, Model: model, TotalRequests: requests}
+	}
+	changedSince := func(since time.Time) []string {
+		t.Helper()
+		stats, err := repo.Query(repository.UsageStatsFilter{Granularity: domain.GranularityMinute, UpdatedSince: &since})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var models []string
+		for _, s := range stats {
+			models = append(models, s.Model)
+		}
+		slices.Sort(models)
+		return models
+	}
+
+	if err := repo.BatchUpsert([]*domain.UsageStats{bucket("unchanged", 1), bucket("grows", 1)}); err != nil {
+		t.Fatal(err)
+	}
+	cursor := time.Now()
+	time.Sleep(5 * time.Millisecond)
+
+	// The aggregator rewrites recent buckets on every run, only changed values move updated_at
+	if err := repo.BatchUpsert([]*domain.UsageStats{bucket("unchanged", 1), bucket("grows", 2), bucket("new", 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := changedSince(cursor); !slices.Equal(got, []string{"grows", "new"}) {
+		t.Errorf("changed since cursor = %v, want [grows new]", got)
+	}
+	if got := changedSince(time.Time{}); len(got) != 3 {
+		t.Errorf("changed since zero = %v, want all 3 buckets", got)
+	}
+
+	stats, err := repo.Query(repository.UsageStatsFilter{Granularity: domain.GranularityMinute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range stats {
+		if s.Model == "grows" && s.TotalRequests != 2 {
+			t.Errorf("grows total = %d, want the updated value", s.TotalRequests)
+		}
+	}
+}
+
+func TestQueryDashboardDataByProject(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewUsageStatsRepository(db)
+
+	loc := repo.getConfiguredTimezone()
+	now := time.Now().In(loc)
+	yesterday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, -1)
+	lastHour := now.Add(-time.Hour).Truncate(time.Hour)
+	twoMonthsAgo := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -2, 0)
+	stat := func(granularity domain.Granularity, bucket time.Time, projectID, providerID uint64, model string, requests uint64) *domain.UsageStats {
+		return &domain.UsageStats{
+			TimeBucket: bucket, Granularity: granularity, ProjectID: projectID, ProviderID: providerID, Model: model,
+			TotalRequests: requests, SuccessfulRequests: requests, InputTokens: requests * 10, Cost: requests * 100,
+		}
+	}
+	if err := repo.BatchUpsert([]*domain.UsageStats{
+		stat(domain.GranularityDay, yesterday, 1, 1, "claude-sonnet-4", 10),
+		stat(domain.GranularityDay, yesterday, 2, 2, "gpt-4o", 5),
+		stat(domain.GranularityHour, lastHour, 1, 1, "claude-sonnet-4", 3),
+		stat(domain.GranularityHour, lastHour, 2, 1, "gpt-4o", 4),
+		stat(domain.GranularityMonth, twoMonthsAgo, 1, 1, "claude-sonnet-4", 100),
+		stat(domain.GranularityMonth, twoMonthsAgo, 2, 2, "gpt-4o", 50),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	dashboard := func(projectID *uint64) *domain.DashboardData {
+		t.Helper()
+		data, err := repo.QueryDashboardData(projectID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	trendRequests := func(d *domain.DashboardData) (n uint64) {
+		for _, p := range d.Trend24h {
+			n += p.Requests
+		}
+		return n
+	}
+	heatmapRequests := func(d *domain.DashboardData) (n uint64) {
+		for _, p := range d.Heatmap {
+			n += p.Count
+		}
+		return n
+	}
+	project1, project2 := uint64(1), uint64(2)
+	all, p1, p2 := dashboard(nil), dashboard(&project1), dashboard(&project2)
+
+	if all.Yesterday.Requests != 15 || p1.Yesterday.Requests != 10 || p2.Yesterday.Requests != 5 {
+		t.Errorf("yesterday requests = %d / %d / %d, want 15 / 10 / 5", all.Yesterday.Requests, p1.Yesterday.Requests, p2.Yesterday.Requests)
+	}
+	if trendRequests(all) != 7 || trendRequests(p1) != 3 || trendRequests(p2) != 4 {
+		t.Errorf("24h trend requests = %d / %d / %d, want 7 / 3 / 4", trendRequests(all), trendRequests(p1), trendRequests(p2))
+	}
+	if p1.AllTime.Requests < 100 || all.AllTime.Requests != p1.AllTime.Requests+p2.AllTime.Requests {
+		t.Errorf("all-time requests = %d, projects %d + %d", all.AllTime.Requests, p1.AllTime.Requests, p2.AllTime.Requests)
+	}
+	if all.AllTime.Cost != p1.AllTime.Cost+p2.AllTime.Cost || all.Today.Requests != p1.Today.Requests+p2.Today.Requests {
+		t.Errorf("all-time cost or today's requests don't add up")
+	}
+	if heatmapRequests(all) != heatmapRequests(p1)+heatmapRequests(p2) {
+		t.Errorf("heatmap requests = %d, projects %d + %d", heatmapRequests(all), heatmapRequests(p1), heatmapRequests(p2))
+	}
+
+	// Provider 2 only served project 2, gpt-4o never ran in project 1
+	if _, ok := p1.ProviderStats[2]; ok {
+		t.Errorf("project 1 provider stats include provider 2: %v", p1.ProviderStats)
+	}
+	if all.ProviderStats[1].Requests != p1.ProviderStats[1].Requests+p2.ProviderStats[1].Requests {
+		t.Errorf("provider 1 requests = %d, projects %d + %d", all.ProviderStats[1].Requests, p1.ProviderStats[1].Requests, p2.ProviderStats[1].Requests)
+	}
+	for _, m := range p1.TopModels {
+		if m.Model == "gpt-4o" {
+			t.Errorf("project 1 top models include gpt-4o: %v", p1.TopModels)
+		}
+	}
+}
+
+func TestGetUpstreamTimingStats(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attemptRepo := NewProxyUpstreamAttemptRepository(db)
+	usageRepo := NewUsageStatsRepository(db)
+
+	now := time.Now()
+	for _, a := range []*domain.ProxyUpstreamAttempt{
+		{ProviderID: 1, Timing: &domain.UpstreamTiming{DNS: 2 * time.Millisecond, Connect: 10 * time.Millisecond, TLS: 30 * time.Millisecond, FirstByte: 400 * time.Millisecond}},
+		{ProviderID: 1, Timing: &domain.UpstreamTiming{FirstByte: 200 * time.Millisecond, Reused: true}},
+		{ProviderID: 1},
+		{ProviderID: 2, Timing: &domain.UpstreamTiming{FirstByte: 50 * time.Millisecond, Reused: true}},
+	} {
+		a.Status = "COMPLETED"
+		a.EndTime = now
+		if err := attemptRepo.Create(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	attempts, err := attemptRepo.ListByProxyRequestID(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 4 || attempts[2].Timing != nil {
+		t.Fatalf("attempts = %v, want 4 with the third untimed", attempts)
+	}
+	if got := attempts[0].Timing; got == nil || got.TLS != 30*time.Millisecond || got.FirstByte != 400*time.Millisecond || got.Reused {
+		t.Errorf("stored timing = %+v", got)
+	}
+
+	stats, err := usageRepo.GetUpstreamTimingStats(repository.UsageStatsFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("got %d providers, want 2", len(stats))
+	}
+	p1 := stats[0]
+	if p1.ProviderID != 1 || p1.Attempts != 2 || p1.NewConns != 1 || p1.ReusedConns != 1 {
+		t.Errorf("provider 1 counts = %+v", p1)
+	}
+	// Connection phases only average over new connections, first byte over all
+	if p1.AvgDNSMs != 2 || p1.AvgConnectMs != 10 || p1.AvgTLSMs != 30 || p1.AvgFirstByteMs != 300 {
+		t.Errorf("provider 1 averages = %+v", p1)
+	}
+	if p2 := stats[1]; p2.AvgTLSMs != 0 || p2.AvgFirstByteMs != 50 {
+		t.Errorf("provider 2 averages = %+v", p2)
+	}
+}
+
+func TestQueryWithRealtimeCachesCurrentBucket(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attemptRepo := NewProxyUpstreamAttemptRepository(db)
+	usageRepo := NewUsageStatsRepository(db)
+	clock := time.Now()
+	usageRepo.realtimeCache.now = func() time.Time { return clock }
+
+	addAttempt := func() {
+		t.Helper()
+		a := &domain.ProxyUpstreamAttempt{ProviderID: 1, Status: "COMPLETED", EndTime: time.Now()}
+		if err := attemptRepo.Create(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	totalRequests := func(filter repository.UsageStatsFilter) uint64 {
+		t.Helper()
+		stats, err := usageRepo.QueryWithRealtime(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var total uint64
+		for _, s := range stats {
+			total += s.TotalRequests
+		}
+		return total
+	}
+	filter := repository.UsageStatsFilter{Granularity: domain.GranularityDay}
+
+	addAttempt()
+	if got := totalRequests(filter); got != 1 {
+		t.Fatalf("first query = %d requests, want 1", got)
+	}
+	addAttempt()
+	if got := totalRequests(filter); got != 1 {
+		t.Errorf("query within the window = %d requests, want the cached 1", got)
+	}
+	providerID := uint64(1)
+	if got := totalRequests(repository.UsageStatsFilter{Granularity: domain.GranularityDay, ProviderID: &providerID}); got != 2 {
+		t.Errorf("query with another filter = %d requests, want 2", got)
+	}
+
+	clock = clock.Add(defaultRealtimeCacheWindow)
+	if got := totalRequests(filter); got != 2 {
+		t.Errorf("query after the window = %d requests, want 2", got)
+	}
+
+	addAttempt()
+	if _, err := usageRepo.AggregateMinute(); err != nil {
+		t.Fatal(err)
+	}
+	if got := totalRequests(filter); got != 3 {
+		t.Errorf("query after aggregation = %d requests, want 3", got)
+	}
+
+	if err := NewSystemSettingRepository(db).Set(domain.SettingKeyStatsRealtimeCacheSecs, "0"); err != nil {
+		t.Fatal(err)
+	}
+	addAttempt()
+	if got := totalRequests(filter); got != 4 {
+		t.Errorf("query with caching off = %d requests, want 4", got)
+	}
+}
@@ -0,0 +1,377 @@
+package resume
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+const (
+	// HeaderResumable opts a streaming request into resumption when set to "true"
+	HeaderResumable = "X-Maxx-Resumable"
+	// HeaderResumeToken carries the token identifying a resumable stream,
+	// returned on the original response and sent back when reconnecting
+	HeaderResumeToken = "X-Maxx-Resume-Token"
+	// HeaderLastEventID is the standard SSE reconnect header
+	HeaderLastEventID = "Last-Event-ID"
+)
+
+// Defaults for the registry
+const (
+	DefaultWindow = 1000             // events kept per stream for replay
+	DefaultGrace  = 30 * time.Second // how long a stream without a client keeps running
+	DefaultLinger = 30 * time.Second // how long a finished stream stays replayable
+)
+
+var (
+	// ErrNotFound is returned for unknown or expired resume tokens
+	ErrNotFound = errors.New("resumable stream not found")
+	// ErrEventsExpired is returned when events after Last-Event-ID already left the window
+	ErrEventsExpired = errors.New("missed events are no longer buffered")
+)
+
+// Registry tracks in-flight resumable streams by token
+type Registry struct {
+	mu      sync.Mutex
+	streams map[string]*Stream
+	window  int
+	grace   time.Duration
+	linger  time.Duration
+}
+
+// NewRegistry creates a registry, zero values use the defaults
+func NewRegistry(window int, grace, linger time.Duration) *Registry {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	if grace <= 0 {
+		grace = DefaultGrace
+	}
+	if linger <= 0 {
+		linger = DefaultLinger
+	}
+	return &Registry{
+		streams: make(map[string]*Stream),
+		window:  window,
+		grace:   grace,
+		linger:  linger,
+	}
+}
+
+// Start registers a new resumable stream written to client w and returns it.
+// cancel stops the upstream request when no client reconnects within the grace period.
+// The stream replaces w as the response writer of the request; Finish must be called
+// once the request is done.
+func (r *Registry) Start(w http.ResponseWriter, clientType domain.ClientType, apiTokenID uint64, cancel context.CancelFunc) *Stream {
+	s := &Stream{
+		registry:   r,
+		token:      newToken(),
+		clientType: clientType,
+		apiTokenID: apiTokenID,
+		header:     w.Header(),
+		status:     http.StatusOK,
+		nextID:     1,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+	s.attachLocked(w)
+	s.header.Set(HeaderResumeToken, s.token)
+
+	r.mu.Lock()
+	r.streams[s.token] = s
+	r.mu.Unlock()
+	return s
+}
+
+// Get returns the stream for token, nil when unknown or expired
+func (r *Registry) Get(token string) *Stream {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.streams[token]
+}
+
+// Len returns the number of tracked streams
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.streams)
+}
+
+func (r *Registry) remove(token string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.streams, token)
+}
+
+// event is one framed SSE event
+type event struct {
+	id   uint64
+	data []byte // "id: N\n" + event + "\n\n"
+}
+
+// attachment is a connected client
+type attachment struct {
+	w    http.ResponseWriter
+	gone chan struct{} // closed when the client is detached
+}
+
+// Stream is an http.ResponseWriter that numbers SSE events, keeps a window of
+// recent events and forwards them to whichever client is currently attached.
+// Writes never fail: while no client is attached the events are only buffered,
+// so the upstream keeps streaming and a reconnecting client can catch up.
+type Stream struct {
+	registry   *Registry
+	token      string
+	clientType domain.ClientType
+	apiTokenID uint64
+
+	mu          sync.Mutex
+	header      http.Header
+	status      int
+	wroteHeader bool
+	client      *attachment
+	pending     []byte // tail of the last write, not yet a complete event
+	events      []event
+	nextID      uint64
+	dropped     bool // events have left the window
+	finished    bool
+	graceTimer  *time.Timer
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+// Token returns the resume token
+func (s *Stream) Token() string { return s.token }
+
+// ClientType returns the client type of the original request
+func (s *Stream) ClientType() domain.ClientType { return s.clientType }
+
+// APITokenID returns the API token of the original request, 0 when none
+func (s *Stream) APITokenID() uint64 { return s.apiTokenID }
+
+// Header implements http.ResponseWriter
+func (s *Stream) Header() http.Header {
+	return s.header
+}
+
+// WriteHeader implements http.ResponseWriter
+func (s *Stream) WriteHeader(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.wroteHeader {
+		return
+	}
+	s.wroteHeader = true
+	s.status = status
+	if s.client != nil {
+		s.client.w.WriteHeader(status)
+	}
+}
+
+// Write implements http.ResponseWriter, splitting the data into SSE events
+func (s *Stream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.wroteHeader {
+		s.wroteHeader = true
+		if s.client != nil {
+			s.client.w.WriteHeader(s.status)
+		}
+	}
+
+	s.pending = append(s.pending, p...)
+	for {
+		end := bytes.Index(s.pending, []byte("\n\n"))
+		if end < 0 {
+			break
+		}
+		s.appendEventLocked(s.pending[:end])
+		s.pending = s.pending[end+2:]
+	}
+	return len(p), nil
+}
+
+// appendEventLocked numbers an event, stores it in the window and sends it to the client
+func (s *Stream) appendEventLocked(body []byte) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return
+	}
+	id := s.nextID
+	s.nextID++
+
+	var buf bytes.Buffer
+	buf.WriteString("id: ")
+	buf.WriteString(strconv.FormatUint(id, 10))
+	buf.WriteByte('\n')
+	buf.Write(body)
+	buf.WriteString("\n\n")
+	ev := event{id: id, data: buf.Bytes()}
+
+	s.events = append(s.events, ev)
+	if len(s.events) > s.registry.window {
+		s.events = append(s.events[:0:0], s.events[len(s.events)-s.registry.window:]...)
+		s.dropped = true
+	}
+
+	if s.client != nil {
+		if _, err := s.client.w.Write(ev.data); err != nil {
+			s.detachLocked(s.client)
+		}
+	}
+}
+
+// Flush implements http.Flusher
+func (s *Stream) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == nil {
+		return
+	}
+	if f, ok := s.client.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// WatchClient detaches the original client once its request context ends
+func (s *Stream) WatchClient(ctx context.Context) {
+	s.mu.Lock()
+	a := s.client
+	s.mu.Unlock()
+	if a == nil {
+		return
+	}
+	select {
+	case <-ctx.Done():
+		s.mu.Lock()
+		s.detachLocked(a)
+		s.mu.Unlock()
+	case <-a.gone:
+	case <-s.done:
+	}
+}
+
+// Resume replays the events after lastEventID to w, then streams live events to it
+// until the stream finishes, the client goes away or another client takes over.
+// A client already attached is replaced, its connection is most likely dead.
+func (s *Stream) Resume(ctx context.Context, w http.ResponseWriter, lastEventID uint64) error {
+	s.mu.Lock()
+	if s.dropped && len(s.events) > 0 && lastEventID+1 < s.events[0].id {
+		s.mu.Unlock()
+		return ErrEventsExpired
+	}
+	if s.client != nil {
+		s.detachLocked(s.client)
+	}
+
+	for k, v := range s.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(s.status)
+	a := s.attachLocked(w)
+	for _, ev := range s.events {
+		if ev.id <= lastEventID {
+			continue
+		}
+		if _, err := w.Write(ev.data); err != nil {
+			s.detachLocked(a)
+			break
+		}
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	finished := s.finished
+	s.mu.Unlock()
+
+	if finished {
+		return nil
+	}
+	select {
+	case <-s.done:
+	case <-a.gone:
+	case <-ctx.Done():
+		s.mu.Lock()
+		s.detachLocked(a)
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// attachLocked makes w the live client and stops the grace timer
+func (s *Stream) attachLocked(w http.ResponseWriter) *attachment {
+	a := &attachment{w: w, gone: make(chan struct{})}
+	s.client = a
+	if s.graceTimer != nil {
+		s.graceTimer.Stop()
+		s.graceTimer = nil
+	}
+	return a
+}
+
+// detachLocked disconnects a and, while the stream is running, starts the grace
+// period after which the upstream is cancelled
+func (s *Stream) detachLocked(a *attachment) {
+	if s.client != a {
+		return
+	}
+	s.client = nil
+	close(a.gone)
+	if s.finished || s.graceTimer != nil {
+		return
+	}
+	s.graceTimer = time.AfterFunc(s.registry.grace, func() {
+		s.mu.Lock()
+		abandoned := s.client == nil && !s.finished
+		s.mu.Unlock()
+		if abandoned && s.cancel != nil {
+			s.cancel()
+		}
+	})
+}
+
+// Finish marks the stream complete. It stays replayable for the linger period.
+func (s *Stream) Finish() {
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		return
+	}
+	if len(s.pending) > 0 {
+		s.appendEventLocked(s.pending)
+		s.pending = nil
+	}
+	s.finished = true
+	if s.graceTimer != nil {
+		s.graceTimer.Stop()
+		s.graceTimer = nil
+	}
+	close(s.done)
+	s.mu.Unlock()
+
+	time.AfterFunc(s.registry.linger, func() {
+		s.registry.remove(s.token)
+	})
+}
+
+// ParseLastEventID parses the Last-Event-ID header, 0 replays from the start
+func ParseLastEventID(value string) uint64 {
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,168 @@
+package resume
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// flakyWriter records writes and fails once the connection "drops"
+type flakyWriter struct {
+	*httptest.ResponseRecorder
+	mu      sync.Mutex
+	dropped bool
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.dropped {
+		return 0, errors.New("connection reset")
+	}
+	return w.ResponseRecorder.Write(p)
+}
+
+func (w *flakyWriter) drop() {
+	w.mu.Lock()
+	w.dropped = true
+	w.mu.Unlock()
+}
+
+// lockedRecorder is a ResponseRecorder safe to read while another goroutine writes
+type lockedRecorder struct {
+	mu  sync.Mutex
+	rec *httptest.ResponseRecorder
+}
+
+func (l *lockedRecorder) Header() http.Header { return l.rec.Header() }
+func (l *lockedRecorder) WriteHeader(code int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rec.WriteHeader(code)
+}
+func (l *lockedRecorder) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rec.Write(p)
+}
+func (l *lockedRecorder) body() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rec.Body.String()
+}
+
+func sendEvent(t *testing.T, s *Stream, n string) {
+	t.Helper()
+	// Split the event across two writes like a chunked upstream would
+	if _, err := s.Write([]byte("data: {\"n\":")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Write([]byte(n + "}\n\n")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamResumesAfterMidStreamDisconnect(t *testing.T) {
+	reg := NewRegistry(100, time.Minute, time.Minute)
+	first := &flakyWriter{ResponseRecorder: httptest.NewRecorder()}
+	s := reg.Start(first, domain.ClientTypeClaude, 7, func() { t.Error("upstream cancelled during the grace period") })
+
+	if got := first.Header().Get(HeaderResumeToken); got == "" || reg.Get(got) != s {
+		t.Fatalf("resume token header = %q, not registered", got)
+	}
+
+	sendEvent(t, s, "1")
+	sendEvent(t, s, "2")
+	first.drop()
+	sendEvent(t, s, "3") // fails on the client, detaches it
+	sendEvent(t, s, "4") // buffered only
+
+	if body := first.Body.String(); !strings.Contains(body, "id: 1\ndata: {\"n\":1}\n\n") || !strings.Contains(body, "id: 2\n") || strings.Contains(body, "id: 3") {
+		t.Fatalf("first client body = %q", body)
+	}
+
+	second := &lockedRecorder{rec: httptest.NewRecorder()}
+	resumed := make(chan error, 1)
+	go func() {
+		resumed <- reg.Get(s.Token()).Resume(context.Background(), second, 2)
+	}()
+
+	// Wait for the replay, then continue the live stream
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(second.body(), "id: 4") {
+		if time.Now().After(deadline) {
+			t.Fatalf("replay not received, body = %q", second.body())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	sendEvent(t, s, "5")
+	s.Finish()
+
+	if err := <-resumed; err != nil {
+		t.Fatal(err)
+	}
+	want := "id: 3\ndata: {\"n\":3}\n\nid: 4\ndata: {\"n\":4}\n\nid: 5\ndata: {\"n\":5}\n\n"
+	if got := second.body(); got != want {
+		t.Errorf("resumed body = %q, want %q", got, want)
+	}
+	if got := second.Header().Get(HeaderResumeToken); got != s.Token() {
+		t.Errorf("resumed response token = %q", got)
+	}
+}
+
+func TestStreamReplayAfterFinish(t *testing.T) {
+	reg := NewRegistry(100, time.Minute, time.Minute)
+	s := reg.Start(httptest.NewRecorder(), domain.ClientTypeOpenAI, 0, nil)
+	sendEvent(t, s, "1")
+	s.Write([]byte("data: [DONE]")) // unterminated tail is flushed on Finish
+	s.Finish()
+
+	rec := httptest.NewRecorder()
+	if err := s.Resume(context.Background(), rec, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.Body.String(); got != "id: 2\ndata: [DONE]\n\n" {
+		t.Errorf("replay = %q", got)
+	}
+}
+
+func TestStreamEventsExpired(t *testing.T) {
+	reg := NewRegistry(2, time.Minute, time.Minute)
+	s := reg.Start(httptest.NewRecorder(), domain.ClientTypeClaude, 0, nil)
+	for _, n := range []string{"1", "2", "3", "4"} {
+		sendEvent(t, s, n)
+	}
+	s.Finish()
+
+	if err := s.Resume(context.Background(), httptest.NewRecorder(), 1); !errors.Is(err, ErrEventsExpired) {
+		t.Errorf("Resume from event 1 = %v, want ErrEventsExpired", err)
+	}
+	if err := s.Resume(context.Background(), httptest.NewRecorder(), 2); err != nil {
+		t.Errorf("Resume from event 2 = %v, want replay of 3 and 4", err)
+	}
+}
+
+func TestStreamCancelsUpstreamWithoutReconnect(t *testing.T) {
+	reg := NewRegistry(10, 20*time.Millisecond, time.Minute)
+	cancelled := make(chan struct{})
+	clientCtx, disconnect := context.WithCancel(context.Background())
+	s := reg.Start(httptest.NewRecorder(), domain.ClientTypeClaude, 0, func() { close(cancelled) })
+	go s.WatchClient(clientCtx)
+
+	sendEvent(t, s, "1")
+	disconnect()
+
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream not cancelled after the grace period")
+	}
+	s.Finish()
+}
@@ -0,0 +1,209 @@
+package retrybudget
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+// Budget scopes
+const (
+	ScopeProvider = "provider" // each provider has its own budget
+	ScopeGlobal   = "global"   // one budget shared by all providers
+)
+
+// DefaultTokenRatio is the token refilled by each successful request, allowing
+// roughly one retry per ten requests
+const DefaultTokenRatio = 0.1
+
+// Config sets up the budget
+type Config struct {
+	// MaxTokens is the bucket size, 0 disables the budget
+	MaxTokens float64
+	// TokenRatio is the token added back by each successful request
+	TokenRatio float64
+	// Scope is ScopeProvider or ScopeGlobal
+	Scope string
+}
+
+// Stats is a snapshot of the budget state
+type Stats struct {
+	Enabled    bool          `json:"enabled"`
+	Scope      string        `json:"scope"`
+	MaxTokens  float64       `json:"maxTokens"`
+	TokenRatio float64       `json:"tokenRatio"`
+	Buckets    []BucketStats `json:"buckets"`
+}
+
+// BucketStats is the state of one provider's budget, ProviderID is 0 for the global budget
+type BucketStats struct {
+	ProviderID uint64  `json:"providerID"`
+	Tokens     float64 `json:"tokens"`
+	Throttled  bool    `json:"throttled"`
+	Suppressed uint64  `json:"suppressed"` // retries and failovers refused
+}
+
+type bucket struct {
+	tokens     float64
+	suppressed uint64
+}
+
+// Budget throttles retries when too many requests fail, in the manner of gRPC retry
+// throttling. Every bucket starts full; a retryable failure takes a token, a success
+// adds TokenRatio back. While a bucket holds no more than half of MaxTokens, retries
+// and failovers to its provider are refused so an outage is not amplified, first
+// attempts are unaffected and refill the bucket once the upstream recovers.
+type Budget struct {
+	mu      sync.Mutex
+	config  Config
+	buckets map[uint64]*bucket
+}
+
+// New creates a disabled budget
+func New() *Budget {
+	return &Budget{
+		config:  Config{TokenRatio: DefaultTokenRatio, Scope: ScopeProvider},
+		buckets: make(map[uint64]*bucket),
+	}
+}
+
+// Configure replaces the config and refills every bucket
+func (b *Budget) Configure(config Config) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if config.Scope != ScopeGlobal {
+		config.Scope = ScopeProvider
+	}
+	if config.TokenRatio <= 0 {
+		config.TokenRatio = DefaultTokenRatio
+	}
+	b.config = config
+	b.buckets = make(map[uint64]*bucket)
+}
+
+// bucket returns the provider's bucket, creating a full one; must be called with mu held
+func (b *Budget) bucket(providerID uint64) *bucket {
+	if b.config.Scope == ScopeGlobal {
+		providerID = 0
+	}
+	bk, ok := b.buckets[providerID]
+	if !ok {
+		bk = &bucket{tokens: b.config.MaxTokens}
+		b.buckets[providerID] = bk
+	}
+	return bk
+}
+
+// RecordSuccess refills the provider's budget after a successful attempt
+func (b *Budget) RecordSuccess(providerID uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.config.MaxTokens <= 0 {
+		return
+	}
+	bk := b.bucket(providerID)
+	bk.tokens = min(bk.tokens+b.config.TokenRatio, b.config.MaxTokens)
+}
+
+// RecordFailure drains the provider's budget after a retryable failure
+func (b *Budget) RecordFailure(providerID uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.config.MaxTokens <= 0 {
+		return
+	}
+	bk := b.bucket(providerID)
+	bk.tokens = max(bk.tokens-1, 0)
+}
+
+// AllowRetry reports whether another attempt may be sent to the provider after a
+// failure. Refusals are counted in the stats.
+func (b *Budget) AllowRetry(providerID uint64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.config.MaxTokens <= 0 {
+		return true
+	}
+	bk := b.bucket(providerID)
+	if bk.tokens > b.config.MaxTokens/2 {
+		return true
+	}
+	bk.suppressed++
+	return false
+}
+
+// Stats returns the current budget state, buckets ordered by provider
+func (b *Budget) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := Stats{
+		Enabled:    b.config.MaxTokens > 0,
+		Scope:      b.config.Scope,
+		MaxTokens:  b.config.MaxTokens,
+		TokenRatio: b.config.TokenRatio,
+		Buckets:    make([]BucketStats, 0, len(b.buckets)),
+	}
+	for id, bk := range b.buckets {
+		stats.Buckets = append(stats.Buckets, BucketStats{
+			ProviderID: id,
+			Tokens:     bk.tokens,
+			Throttled:  bk.tokens <= b.config.MaxTokens/2,
+			Suppressed: bk.suppressed,
+		})
+	}
+	sort.Slice(stats.Buckets, func(i, j int) bool {
+		return stats.Buckets[i].ProviderID < stats.Buckets[j].ProviderID
+	})
+	return stats
+}
+
+// ==================== Global budget ====================
+
+var global = New()
+
+// Global returns the process-wide budget used by the executor
+func Global() *Budget {
+	return global
+}
+
+// LoadFromSettings applies the retry budget settings to the global budget
+func LoadFromSettings(settingRepo repository.SystemSettingRepository) {
+	config := Config{TokenRatio: DefaultTokenRatio}
+	if val, err := settingRepo.Get(domain.SettingKeyRetryBudgetTokens); err == nil && val != "" {
+		if n, err := strconv.ParseFloat(val, 64); err == nil && n > 0 {
+			config.MaxTokens = n
+		}
+	}
+	if val, err := settingRepo.Get(domain.SettingKeyRetryBudgetRatio); err == nil && val != "" {
+		if n, err := strconv.ParseFloat(val, 64); err == nil && n > 0 {
+			config.TokenRatio = n
+		}
+	}
+	scope, _ := settingRepo.Get(domain.SettingKeyRetryBudgetScope)
+	config.Scope = strings.TrimSpace(scope)
+	global.Configure(config)
+}
+
+// ValidateSetting checks the value of a retry budget setting
+func ValidateSetting(key, value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	switch key {
+	case domain.SettingKeyRetryBudgetTokens, domain.SettingKeyRetryBudgetRatio:
+		if n, err := strconv.ParseFloat(value, 64); err != nil || n < 0 {
+			return fmt.Errorf("invalid %s %q: must be a non-negative number", key, value)
+		}
+	case domain.SettingKeyRetryBudgetScope:
+		if value != ScopeProvider && value != ScopeGlobal {
+			return fmt.Errorf("invalid %s %q: must be %q or %q", key, value, ScopeProvider, ScopeGlobal)
+		}
+	}
+	return nil
+}
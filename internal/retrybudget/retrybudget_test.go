@@ -0,0 +1,102 @@
+package retrybudget
+
+import "testing"
+
+func TestDisabledBudgetAlwaysAllowsRetries(t *testing.T) {
+	b := New()
+	for i := 0; i < 100; i++ {
+		b.RecordFailure(1)
+	}
+	if !b.AllowRetry(1) {
+		t.Error("disabled budget refused a retry")
+	}
+	if stats := b.Stats(); stats.Enabled || len(stats.Buckets) != 0 {
+		t.Errorf("disabled budget stats = %+v", stats)
+	}
+}
+
+func TestExhaustedBudgetSuppressesRetriesUntilRecovery(t *testing.T) {
+	b := New()
+	b.Configure(Config{MaxTokens: 10, TokenRatio: 0.5})
+
+	// Failures down to half of the bucket still allow retries
+	for i := 0; i < 4; i++ {
+		b.RecordFailure(1)
+		if !b.AllowRetry(1) {
+			t.Fatalf("retry refused after %d failures", i+1)
+		}
+	}
+	b.RecordFailure(1)
+	if b.AllowRetry(1) || b.AllowRetry(1) {
+		t.Fatal("retry allowed with half of the bucket spent")
+	}
+
+	// Other providers keep their own budget
+	if !b.AllowRetry(2) {
+		t.Error("retry refused for an unaffected provider")
+	}
+
+	stats := b.Stats()
+	if len(stats.Buckets) != 2 || stats.Buckets[0].ProviderID != 1 || !stats.Buckets[0].Throttled ||
+		stats.Buckets[0].Tokens != 5 || stats.Buckets[0].Suppressed != 2 {
+		t.Fatalf("stats = %+v, want provider 1 throttled at 5 tokens with 2 refusals", stats)
+	}
+	if stats.Buckets[1].Throttled {
+		t.Errorf("provider 2 bucket = %+v, want not throttled", stats.Buckets[1])
+	}
+
+	// Successful first attempts refill the bucket and retries resume
+	b.RecordSuccess(1)
+	if !b.AllowRetry(1) {
+		t.Error("retry refused after recovery")
+	}
+	for i := 0; i < 100; i++ {
+		b.RecordSuccess(1)
+	}
+	if tokens := b.Stats().Buckets[0].Tokens; tokens != 10 {
+		t.Errorf("tokens = %v, want capped at 10", tokens)
+	}
+}
+
+func TestGlobalScopeSharesOneBudget(t *testing.T) {
+	b := New()
+	b.Configure(Config{MaxTokens: 4, Scope: ScopeGlobal})
+	b.RecordFailure(1)
+	b.RecordFailure(2)
+	if b.AllowRetry(3) {
+		t.Error("retry allowed with the shared budget spent")
+	}
+	stats := b.Stats()
+	if stats.Scope != ScopeGlobal || stats.TokenRatio != DefaultTokenRatio || len(stats.Buckets) != 1 || stats.Buckets[0].ProviderID != 0 {
+		t.Errorf("stats = %+v, want a single global bucket", stats)
+	}
+
+	// Reconfiguring refills the budget
+	b.Configure(Config{MaxTokens: 4, Scope: ScopeGlobal})
+	if !b.AllowRetry(3) {
+		t.Error("retry refused after reconfiguring")
+	}
+}
+
+func TestValidateSetting(t *testing.T) {
+	valid := map[string]string{
+		"retry_budget_max_tokens":  "10",
+		"retry_budget_token_ratio": "0.1",
+		"retry_budget_scope":       "global",
+	}
+	for key, value := range valid {
+		if err := ValidateSetting(key, value); err != nil {
+			t.Errorf("ValidateSetting(%s, %q) = %v", key, value, err)
+		}
+	}
+	invalid := map[string]string{
+		"retry_budget_max_tokens":  "-1",
+		"retry_budget_token_ratio": "ten percent",
+		"retry_budget_scope":       "route",
+	}
+	for key, value := range invalid {
+		if err := ValidateSetting(key, value); err == nil {
+			t.Errorf("ValidateSetting(%s, %q) should fail", key, value)
+		}
+	}
+}
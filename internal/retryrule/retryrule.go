@@ -0,0 +1,98 @@
+package retryrule
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// clientFaultStatuses are never retried by rules, the same request fails the same way
+var clientFaultStatuses = []int{
+	http.StatusUnauthorized,
+	http.StatusForbidden,
+	http.StatusNotFound,
+	http.StatusRequestEntityTooLarge,
+}
+
+// clientFaultMarkers identify 400s caused by the request itself, they win over any
+// rule so a broad pattern like "invalid_request_error" can't retry them
+var clientFaultMarkers = []string{
+	"context_length_exceeded",
+	"maximum context length",
+	"prompt is too long",
+	"invalid_api_key",
+}
+
+type compiledRule struct {
+	pattern     string
+	re          *regexp.Regexp
+	statusCodes []int
+}
+
+// Matcher decides whether an upstream error matches one of a provider's retry rules
+type Matcher struct {
+	rules []compiledRule
+}
+
+// New compiles retry rules, failing on the first invalid rule
+func New(rules []domain.RetryRule) (*Matcher, error) {
+	m := &Matcher{}
+	for i, rule := range rules {
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("retry rule %d: empty pattern", i)
+		}
+		compiled := compiledRule{statusCodes: rule.StatusCodes}
+		switch rule.Type {
+		case "", domain.RetryRuleTypeContains:
+			compiled.pattern = strings.ToLower(rule.Pattern)
+		case domain.RetryRuleTypeRegex:
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("retry rule %d: %w", i, err)
+			}
+			compiled.re = re
+		default:
+			return nil, fmt.Errorf("retry rule %d: invalid type %q", i, rule.Type)
+		}
+		m.rules = append(m.rules, compiled)
+	}
+	return m, nil
+}
+
+// Validate checks that all rules compile
+func Validate(rules []domain.RetryRule) error {
+	_, err := New(rules)
+	return err
+}
+
+// Match reports whether an error with the given upstream status code and text
+// (error message and response body) matches a rule. Errors caused by the request
+// itself never match.
+func (m *Matcher) Match(statusCode int, text string) bool {
+	if m == nil || len(m.rules) == 0 || text == "" || slices.Contains(clientFaultStatuses, statusCode) {
+		return false
+	}
+	lower := strings.ToLower(text)
+	for _, marker := range clientFaultMarkers {
+		if strings.Contains(lower, marker) {
+			return false
+		}
+	}
+	for _, rule := range m.rules {
+		if len(rule.statusCodes) > 0 && !slices.Contains(rule.statusCodes, statusCode) {
+			continue
+		}
+		if rule.re != nil {
+			if rule.re.MatchString(text) {
+				return true
+			}
+		} else if strings.Contains(lower, rule.pattern) {
+			return true
+		}
+	}
+	return false
+}
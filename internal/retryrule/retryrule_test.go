@@ -0,0 +1,55 @@
+package retryrule
+
+import (
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestMatch(t *testing.T) {
+	m, err := New([]domain.RetryRule{
+		{Pattern: "Overloaded"},
+		{Type: domain.RetryRuleTypeRegex, Pattern: `no capacity (left|available)`, StatusCodes: []int{400}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		statusCode int
+		text       string
+		want       bool
+	}{
+		{"substring in 400", 400, `upstream error: {"error":{"message":"Model is overloaded, try again"}}`, true},
+		{"substring in stream error", 0, "SSE error (code=0): overloaded", true},
+		{"regex with matching status", 400, "no capacity available for model", true},
+		{"regex with other status", 422, "no capacity available for model", false},
+		{"no match passes through", 400, `{"error":{"type":"invalid_request_error","message":"messages: field required"}}`, false},
+		{"auth failure never retried", 401, "overloaded", false},
+		{"context length never retried", 400, `{"error":{"code":"context_length_exceeded","message":"server overloaded"}}`, false},
+		{"empty text", 400, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Match(tt.statusCode, tt.text); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate([]domain.RetryRule{{Type: domain.RetryRuleTypeRegex, Pattern: "("}}); err == nil {
+		t.Error("invalid regex accepted")
+	}
+	if err := Validate([]domain.RetryRule{{Pattern: ""}}); err == nil {
+		t.Error("empty pattern accepted")
+	}
+	if err := Validate([]domain.RetryRule{{Type: "glob", Pattern: "x"}}); err == nil {
+		t.Error("unknown type accepted")
+	}
+	if err := Validate(nil); err != nil {
+		t.Errorf("no rules rejected: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package router
+
+import (
+	"math"
+	"sort"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+const (
+	// adaptiveFailurePenalty moves a route down one place per 10% of failed attempts
+	adaptiveFailurePenalty = 10.0
+	// adaptiveLatencyPenalty moves a route down one place each time its median
+	// latency doubles over the fastest route
+	adaptiveLatencyPenalty = 1.0
+)
+
+// adaptiveOrder reorders routes already sorted by position by the recent health of
+// their providers. The static order is the prior: each route starts at its index and
+// is pushed down by its failure rate and by how much slower it is than the fastest
+// route, so healthy fast providers float above flaky or slow ones. Providers without
+// enough recent attempts keep their place, which also lets a sunk provider recover
+// once its failures age out.
+func adaptiveOrder(routes []*domain.Route, health func(providerID uint64) ProviderHealth) {
+	if len(routes) < 2 {
+		return
+	}
+
+	healths := make([]ProviderHealth, len(routes))
+	var fastest float64
+	for i, route := range routes {
+		healths[i] = health(route.ProviderID)
+		if h := healths[i]; h.Scored() && h.MedianLatency > 0 && (fastest == 0 || float64(h.MedianLatency) < fastest) {
+			fastest = float64(h.MedianLatency)
+		}
+	}
+
+	type scoredRoute struct {
+		route *domain.Route
+		score float64
+	}
+	scored := make([]scoredRoute, len(routes))
+	for i, route := range routes {
+		score := float64(i)
+		if h := healths[i]; h.Scored() {
+			score += (1 - h.SuccessRate) * adaptiveFailurePenalty
+			if h.MedianLatency > 0 && fastest > 0 {
+				score += math.Log2(float64(h.MedianLatency)/fastest) * adaptiveLatencyPenalty
+			}
+		}
+		scored[i] = scoredRoute{route: route, score: score}
+	}
+	sort.SliceStable(scored, func(i, j int) bool { return scored[i].score < scored[j].score })
+	for i, s := range scored {
+		routes[i] = s.route
+	}
+}
+
+// adaptiveOrderEnabled reports whether a strategy orders its routes adaptively,
+// only the priority strategy has a static order to adjust
+func adaptiveOrderEnabled(strategy *domain.RoutingStrategy) bool {
+	if strategy.Config == nil || !strategy.Config.AdaptiveOrder {
+		return false
+	}
+	switch strategy.Type {
+	case domain.RoutingStrategyWeightedRandom, domain.RoutingStrategyRoundRobin, domain.RoutingStrategyTPM:
+		return false
+	}
+	return true
+}
+
+// AdaptiveRoutePositions returns the current place (from 1) of each enabled route in
+// the adaptive order of its project and client type, for the routes whose routing
+// strategy enables adaptive ordering. Cooldowns and model support are not considered.
+func (r *Router) AdaptiveRoutePositions(routes []*domain.Route) map[uint64]int {
+	type groupKey struct {
+		projectID  uint64
+		clientType domain.ClientType
+	}
+	groups := make(map[groupKey][]*domain.Route)
+	for _, route := range routes {
+		if route.IsEnabled {
+			key := groupKey{route.ProjectID, route.ClientType}
+			groups[key] = append(groups[key], route)
+		}
+	}
+
+	positions := make(map[uint64]int)
+	for key, group := range groups {
+		if !adaptiveOrderEnabled(r.getRoutingStrategy(key.projectID)) {
+			continue
+		}
+		group = append([]*domain.Route(nil), group...)
+		sort.SliceStable(group, func(i, j int) bool { return group[i].Position < group[j].Position })
+		adaptiveOrder(group, r.health.Health)
+		for i, route := range group {
+			positions[route.ID] = i + 1
+		}
+	}
+	return positions
+}
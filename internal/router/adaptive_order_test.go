@@ -0,0 +1,140 @@
+package router
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// recordHealth adds n attempts of a provider, failing every failEvery-th one (0 = none)
+func recordHealth(tracker *HealthTracker, providerID uint64, n, failEvery int, latency time.Duration) {
+	for i := 1; i <= n; i++ {
+		if failEvery > 0 && i%failEvery == 0 {
+			tracker.RecordFailure(providerID)
+		} else {
+			tracker.RecordSuccess(providerID, latency)
+		}
+	}
+}
+
+func routeOrder(routes []*domain.Route) []uint64 {
+	ids := make([]uint64, len(routes))
+	for i, route := range routes {
+		ids[i] = route.ProviderID
+	}
+	return ids
+}
+
+func TestAdaptiveOrder(t *testing.T) {
+	routes := func() []*domain.Route {
+		return []*domain.Route{
+			{ID: 1, ProviderID: 1, Position: 1},
+			{ID: 2, ProviderID: 2, Position: 2},
+			{ID: 3, ProviderID: 3, Position: 3},
+		}
+	}
+	tests := []struct {
+		name   string
+		record func(tracker *HealthTracker)
+		want   []uint64
+	}{
+		{"no data keeps the static order", func(*HealthTracker) {}, []uint64{1, 2, 3}},
+		{"equal health keeps the static order", func(tracker *HealthTracker) {
+			for id := uint64(1); id <= 3; id++ {
+				recordHealth(tracker, id, 50, 0, time.Second)
+			}
+		}, []uint64{1, 2, 3}},
+		{"a flaky provider sinks", func(tracker *HealthTracker) {
+			recordHealth(tracker, 1, 50, 4, time.Second) // 25% failures
+			recordHealth(tracker, 2, 50, 0, time.Second)
+			recordHealth(tracker, 3, 50, 0, time.Second)
+		}, []uint64{2, 3, 1}},
+		{"a few failures are outweighed by position", func(tracker *HealthTracker) {
+			recordHealth(tracker, 1, 50, 25, time.Second) // 4% failures
+			recordHealth(tracker, 2, 50, 0, time.Second)
+		}, []uint64{1, 2, 3}},
+		{"a fast provider floats above slow ones", func(tracker *HealthTracker) {
+			recordHealth(tracker, 1, 50, 0, 8*time.Second)
+			recordHealth(tracker, 2, 50, 0, 4*time.Second)
+			recordHealth(tracker, 3, 50, 0, time.Second)
+		}, []uint64{3, 1, 2}},
+		{"too few attempts to judge", func(tracker *HealthTracker) {
+			recordHealth(tracker, 1, healthMinSamples-1, 1, 0)
+		}, []uint64{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewHealthTracker()
+			tt.record(tracker)
+			got := routes()
+			adaptiveOrder(got, tracker.Health)
+			if order := routeOrder(got); !slices.Equal(order, tt.want) {
+				t.Errorf("order = %v, want %v", order, tt.want)
+			}
+		})
+	}
+}
+
+func TestHealthAgesOut(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tracker := NewHealthTracker()
+	tracker.now = func() time.Time { return now }
+
+	recordHealth(tracker, 1, 40, 2, time.Second)
+	h := tracker.Health(1)
+	if !h.Scored() || h.SuccessRate != 0.5 || h.MedianLatency != time.Second {
+		t.Fatalf("health = %+v, want 50%% success at 1s", h)
+	}
+
+	// Cached until the next recompute
+	recordHealth(tracker, 1, 40, 0, time.Second)
+	if got := tracker.Health(1); got != h {
+		t.Errorf("health recomputed early: %+v", got)
+	}
+	now = now.Add(healthRecomputeInterval)
+	if got := tracker.Health(1); got.Samples != 80 || got.SuccessRate != 0.75 {
+		t.Errorf("health = %+v, want 75%% success over 80 attempts", got)
+	}
+
+	// A provider without recent traffic loses its score and returns to its static place
+	now = now.Add(healthMaxAge)
+	if got := tracker.Health(1); got.Scored() {
+		t.Errorf("stale health still scored: %+v", got)
+	}
+}
+
+func TestAdaptiveRoutePositions(t *testing.T) {
+	r, providers := newForcedRouter(t)
+	second := &domain.Route{IsEnabled: true, ClientType: domain.ClientTypeClaude, ProviderID: providers[1].ID, Position: 1}
+	if err := r.routeRepo.Create(second); err != nil {
+		t.Fatal(err)
+	}
+	routes := r.routeRepo.GetAll()
+
+	if positions := r.AdaptiveRoutePositions(routes); len(positions) != 0 {
+		t.Fatalf("positions without adaptive order = %v", positions)
+	}
+
+	strategy := &domain.RoutingStrategy{
+		Type:   domain.RoutingStrategyPriority,
+		Config: &domain.RoutingStrategyConfig{AdaptiveOrder: true},
+	}
+	if err := r.routingStrategyRepo.Create(strategy); err != nil {
+		t.Fatal(err)
+	}
+	// The first route's provider fails half of its attempts
+	recordHealth(r.health, providers[0].ID, 40, 2, time.Second)
+	recordHealth(r.health, providers[1].ID, 40, 0, time.Second)
+
+	positions := r.AdaptiveRoutePositions(routes)
+	if positions[second.ID] != 1 || len(positions) != 2 {
+		t.Errorf("positions = %v, want route %d first", positions, second.ID)
+	}
+
+	matched, err := r.Match(&MatchContext{ClientType: domain.ClientTypeClaude})
+	if err != nil || len(matched) != 2 || matched[0].Provider.ID != providers[1].ID {
+		t.Errorf("Match = %v, %v; want provider %d first", matched, err, providers[1].ID)
+	}
+}
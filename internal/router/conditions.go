@@ -0,0 +1,67 @@
+package router
+
+import (
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// ConditionSource supplies the live inputs route conditions are evaluated against.
+// The executor implements it: it sees every request and reads the configured timezone.
+type ConditionSource interface {
+	// InFlight returns the number of requests being executed
+	InFlight() int64
+	// RecentRPM returns the number of requests started during the last minute
+	RecentRPM() int64
+	// Timezone returns the timezone the time windows of route conditions are in
+	Timezone() *time.Location
+}
+
+// SetConditionSource sets where route conditions read the current load and timezone.
+// Without a source the load is zero and time windows are in the local timezone.
+func (r *Router) SetConditionSource(src ConditionSource) {
+	r.conditionSource = src
+}
+
+// applyConditions drops the routes whose conditions aren't met right now, or
+// moves them after the routes in effect when they demote instead. Each group
+// keeps the order it had.
+func (r *Router) applyConditions(routes []*domain.Route) []*domain.Route {
+	var (
+		now       time.Time
+		load      domain.RouteLoad
+		evaluated bool
+		demoted   []*domain.Route
+	)
+	active := make([]*domain.Route, 0, len(routes))
+	for _, route := range routes {
+		if route.Conditions == nil {
+			active = append(active, route)
+			continue
+		}
+		if !evaluated {
+			now, load = r.conditionInputs()
+			evaluated = true
+		}
+		switch {
+		case route.Conditions.Met(now, load):
+			active = append(active, route)
+		case route.Conditions.DemoteWhenUnmet:
+			demoted = append(demoted, route)
+		}
+	}
+	return append(active, demoted...)
+}
+
+// conditionInputs returns the current time in the configured timezone and the current load
+func (r *Router) conditionInputs() (time.Time, domain.RouteLoad) {
+	now := r.now()
+	src := r.conditionSource
+	if src == nil {
+		return now, domain.RouteLoad{}
+	}
+	if loc := src.Timezone(); loc != nil {
+		now = now.In(loc)
+	}
+	return now, domain.RouteLoad{InFlight: src.InFlight(), RecentRPM: src.RecentRPM()}
+}
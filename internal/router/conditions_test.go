@@ -0,0 +1,153 @@
+package router
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+type fakeConditionSource struct {
+	inFlight, rpm int64
+	loc           *time.Location
+}
+
+func (f *fakeConditionSource) InFlight() int64          { return f.inFlight }
+func (f *fakeConditionSource) RecentRPM() int64         { return f.rpm }
+func (f *fakeConditionSource) Timezone() *time.Location { return f.loc }
+
+// newConditionRouter returns a router with the premium, cheap and spillover routes in
+// that order, each with the given conditions, and a func returning the matched providers
+func newConditionRouter(t *testing.T, premium, cheap, spillover *domain.RouteConditions) (*Router, func() []string) {
+	t.Helper()
+	r, providers := newForcedRouter(t)
+	routes := r.routeRepo.GetAll()
+	routes[0].Conditions = premium
+	if err := r.routeRepo.Update(routes[0]); err != nil {
+		t.Fatal(err)
+	}
+	providers[0].Name = "premium"
+	if err := r.providerRepo.Update(providers[0]); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"cheap", "spillover"} {
+		p := &domain.Provider{Name: name, Type: "custom"}
+		if err := r.providerRepo.Create(p); err != nil {
+			t.Fatal(err)
+		}
+		r.adapters[p.ID] = stubAdapter{}
+		route := &domain.Route{IsEnabled: true, ClientType: domain.ClientTypeClaude, ProviderID: p.ID, Position: i + 1,
+			Conditions: []*domain.RouteConditions{cheap, spillover}[i]}
+		if err := r.routeRepo.Create(route); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return r, func() []string {
+		t.Helper()
+		matched, err := r.Match(&MatchContext{ClientType: domain.ClientTypeClaude})
+		if err != nil && !errors.Is(err, domain.ErrNoRoutes) {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, m := range matched {
+			names = append(names, m.Provider.Name)
+		}
+		return names
+	}
+}
+
+func TestMatchTimeWindowConditions(t *testing.T) {
+	// Premium during business hours on weekdays, cheap off-peak, the spillover always last
+	r, match := newConditionRouter(t,
+		&domain.RouteConditions{TimeWindows: []string{"09:00-18:00"}, Weekdays: []int{1, 2, 3, 4, 5}},
+		&domain.RouteConditions{TimeWindows: []string{"18:00-09:00"}},
+		nil,
+	)
+	// Time windows are in the configured timezone, not the server's
+	shanghai := time.FixedZone("UTC+8", 8*60*60)
+	r.SetConditionSource(&fakeConditionSource{loc: shanghai})
+
+	for _, tc := range []struct {
+		name  string
+		clock time.Time
+		want  []string
+	}{
+		{"weekday business hours", time.Date(2026, 10, 14, 2, 0, 0, 0, time.UTC), []string{"premium", "spillover"}}, // Wed 10:00 UTC+8
+		{"window start is included", time.Date(2026, 10, 14, 9, 0, 0, 0, shanghai), []string{"premium", "spillover"}},
+		{"window end is excluded", time.Date(2026, 10, 14, 18, 0, 0, 0, shanghai), []string{"cheap", "spillover"}},
+		{"weekday night", time.Date(2026, 10, 14, 23, 30, 0, 0, shanghai), []string{"cheap", "spillover"}},
+		{"early morning across midnight", time.Date(2026, 10, 15, 8, 59, 0, 0, shanghai), []string{"cheap", "spillover"}},
+		{"weekend business hours", time.Date(2026, 10, 17, 11, 0, 0, 0, shanghai), []string{"spillover"}},
+	} {
+		clock := tc.clock
+		r.now = func() time.Time { return clock }
+		if got := match(); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: matched %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMatchLoadConditions(t *testing.T) {
+	// Premium demoted behind cheap above 5 in-flight requests, spillover only from 100 RPM
+	r, match := newConditionRouter(t,
+		&domain.RouteConditions{MaxInFlight: 5, DemoteWhenUnmet: true},
+		nil,
+		&domain.RouteConditions{MinRPM: 100},
+	)
+	load := &fakeConditionSource{}
+	r.SetConditionSource(load)
+
+	for _, tc := range []struct {
+		name          string
+		inFlight, rpm int64
+		want          []string
+	}{
+		{"idle", 1, 10, []string{"premium", "cheap"}},
+		{"below the in-flight threshold", 4, 99, []string{"premium", "cheap"}},
+		{"at the in-flight threshold", 5, 99, []string{"cheap", "premium"}},
+		{"at the RPM threshold", 2, 100, []string{"premium", "cheap", "spillover"}},
+		{"both thresholds", 20, 500, []string{"cheap", "spillover", "premium"}},
+	} {
+		load.inFlight, load.rpm = tc.inFlight, tc.rpm
+		if got := match(); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: matched %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMatchWithoutConditionSource(t *testing.T) {
+	// Without a source the load is zero: routes waiting for load never match
+	_, match := newConditionRouter(t, nil, &domain.RouteConditions{MinInFlight: 1}, &domain.RouteConditions{MaxRPM: 10})
+	if got := match(); !slices.Equal(got, []string{"premium", "spillover"}) {
+		t.Errorf("matched %v, want premium and spillover", got)
+	}
+}
+
+func TestRouteConditionsValidate(t *testing.T) {
+	valid := []*domain.RouteConditions{
+		nil,
+		{TimeWindows: []string{"22:00-06:00", "12:00-24:00"}, Weekdays: []int{0, 6}},
+		{MinInFlight: 5, MaxRPM: 100},
+	}
+	for _, c := range valid {
+		if err := c.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v", c, err)
+		}
+	}
+	invalid := []*domain.RouteConditions{
+		{TimeWindows: []string{"9-18"}},
+		{TimeWindows: []string{"09:00"}},
+		{TimeWindows: []string{"10:00-10:00"}},
+		{Weekdays: []int{7}},
+		{MinRPM: 100, MaxRPM: 100},
+		{MaxInFlight: -1},
+	}
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Validate(%+v) accepted invalid conditions", c)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/cooldown"
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/limiter"
+)
+
+// cooldownQueuePoll bounds how long a queued request sleeps before matching
+// again, so cooldowns cleared early (a success, an admin) are noticed
+const cooldownQueuePoll = time.Second
+
+// CooldownError is returned by Match when routes exist for the request but
+// all their providers are cooling down. It wraps domain.ErrNoRoutes.
+type CooldownError struct {
+	// RecoversAt is when the first of these cooldowns ends
+	RecoversAt time.Time
+}
+
+func (e *CooldownError) Error() string {
+	return "no routes available: all providers are cooling down"
+}
+
+func (e *CooldownError) Unwrap() error {
+	return domain.ErrNoRoutes
+}
+
+// MatchOrWait matches routes like Match. When every provider is cooling down
+// and the cooldown queue is on, the request waits for one to recover, up to the
+// queue's max wait, and is matched again. The wait ends early with the context's
+// cause when ctx is done.
+func (r *Router) MatchOrWait(ctx context.Context, mc *MatchContext) ([]*MatchedRoute, error) {
+	return r.matchOrWait(ctx, mc, cooldown.GlobalQueue())
+}
+
+func (r *Router) matchOrWait(ctx context.Context, mc *MatchContext, queue *cooldown.Queue) ([]*MatchedRoute, error) {
+	matched, err := r.Match(mc)
+	var cooling *CooldownError
+	if !errors.As(err, &cooling) {
+		return matched, err
+	}
+	deadline, leave, ok := queue.Enter()
+	if !ok {
+		return nil, err
+	}
+	defer leave()
+
+	// The request gives its concurrency slot up while it waits, so it doesn't turn
+	// away requests other providers could serve, and takes one again once matched
+	slot := limiter.SlotFromContext(ctx)
+	slot.Yield()
+
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, err
+		}
+		wait := min(time.Until(cooling.RecoversAt), remaining, cooldownQueuePoll)
+		timer := time.NewTimer(max(wait, 10*time.Millisecond))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, context.Cause(ctx)
+		case <-timer.C:
+		}
+
+		// Matching again keeps the request's place in the round-robin rotation
+		matched, err = r.match(mc, false)
+		if !errors.As(err, &cooling) {
+			if err == nil {
+				if err := slot.Resume(ctx); err != nil {
+					return nil, err
+				}
+			}
+			return matched, err
+		}
+	}
+}
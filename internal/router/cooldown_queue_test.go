@@ -0,0 +1,111 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/cooldown"
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/limiter"
+)
+
+func TestMatchOrWaitRecoversFromCooldown(t *testing.T) {
+	r, providers := newForcedRouter(t)
+	r.cooldownManager.SetCooldownDuration(providers[0].ID, "", 100*time.Millisecond)
+
+	mc := &MatchContext{ClientType: domain.ClientTypeClaude, RequestModel: "claude-sonnet-4"}
+	var cooling *CooldownError
+	if _, err := r.Match(mc); !errors.As(err, &cooling) || !errors.Is(err, domain.ErrNoRoutes) {
+		t.Fatalf("match during cooldown: err = %v, want a cooldown error", err)
+	}
+
+	queue := &cooldown.Queue{}
+	queue.SetMaxWait(5 * time.Second)
+	matched, err := r.matchOrWait(context.Background(), mc, queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matched) != 1 || matched[0].Provider.ID != providers[0].ID {
+		t.Fatalf("matched = %+v, want the recovered provider", matched)
+	}
+	if queued := queue.Stats().Queued; queued != 0 {
+		t.Errorf("queued = %d after the request left", queued)
+	}
+}
+
+func TestMatchOrWaitGivesUp(t *testing.T) {
+	r, providers := newForcedRouter(t)
+	r.cooldownManager.SetCooldownDuration(providers[0].ID, "", time.Minute)
+	mc := &MatchContext{ClientType: domain.ClientTypeClaude, RequestModel: "claude-sonnet-4"}
+
+	// Queuing off: fail right away
+	var cooling *CooldownError
+	if _, err := r.matchOrWait(context.Background(), mc, &cooldown.Queue{}); !errors.As(err, &cooling) {
+		t.Fatalf("err = %v, want a cooldown error", err)
+	}
+
+	queue := &cooldown.Queue{}
+	queue.SetMaxWait(50 * time.Millisecond)
+	start := time.Now()
+	if _, err := r.matchOrWait(context.Background(), mc, queue); !errors.As(err, &cooling) {
+		t.Fatalf("err = %v, want a cooldown error after the max wait", err)
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("gave up after %v, before the max wait", waited)
+	}
+
+	queue.SetMaxWait(time.Minute)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancelled := errors.New("client gone")
+	time.AfterFunc(20*time.Millisecond, func() { cancel(cancelled) })
+	if _, err := r.matchOrWait(ctx, mc, queue); !errors.Is(err, cancelled) {
+		t.Fatalf("err = %v, want the context cause", err)
+	}
+	if queued := queue.Stats().Queued; queued != 0 {
+		t.Errorf("queued = %d after the request left", queued)
+	}
+}
+
+func TestMatchOrWaitYieldsConcurrencySlot(t *testing.T) {
+	r, providers := newForcedRouter(t)
+	r.cooldownManager.SetCooldownDuration(providers[0].ID, "", 200*time.Millisecond)
+	mc := &MatchContext{ClientType: domain.ClientTypeClaude, RequestModel: "claude-sonnet-4"}
+
+	l := limiter.New()
+	l.Configure(1, 10, time.Second)
+	slot, err := l.AcquireSlot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slot.Release()
+	ctx := limiter.WithSlot(context.Background(), slot)
+
+	queue := &cooldown.Queue{}
+	queue.SetMaxWait(5 * time.Second)
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.matchOrWait(ctx, mc, queue)
+		done <- err
+	}()
+
+	// The only slot is free for other requests while this one waits out the cooldown
+	time.Sleep(50 * time.Millisecond)
+	other, err := l.AcquireSlot(context.Background())
+	if err != nil {
+		t.Fatalf("acquire while the request waits: %v", err)
+	}
+	other.Release()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if active := l.Stats().Active; active != 1 {
+		t.Errorf("active = %d after matching, want the slot taken again", active)
+	}
+	slot.Release()
+	if active := l.Stats().Active; active != 0 {
+		t.Errorf("active = %d after release", active)
+	}
+}
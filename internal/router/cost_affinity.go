@@ -0,0 +1,130 @@
+package router
+
+import (
+	"sort"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/pricing"
+)
+
+const (
+	// costAffinityDownRate is the recent success rate below which a provider counts
+	// as down and is left out of a cost ordered route list
+	costAffinityDownRate = 0.5
+
+	// Input price bands (microUSD per M tokens) that place providers without a cost
+	// tier: up to $1/M is economy, from $10/M premium, standard in between
+	economyMaxInputPriceMicro = 1_000_000
+	premiumMinInputPriceMicro = 10_000_000
+)
+
+// costTierRank orders cost tiers from cheapest (0) to best
+func costTierRank(tier domain.CostTier) int {
+	switch tier {
+	case domain.CostTierEconomy:
+		return 0
+	case domain.CostTierPremium:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// priceCostTier places a model in a cost tier by its input price in the pricing
+// table, unknown models are standard
+func priceCostTier(model string, prices func(model string) *pricing.ModelPricing) domain.CostTier {
+	p := prices(model)
+	switch {
+	case p == nil:
+		return domain.CostTierStandard
+	case p.InputPriceMicro <= economyMaxInputPriceMicro:
+		return domain.CostTierEconomy
+	case p.InputPriceMicro >= premiumMinInputPriceMicro:
+		return domain.CostTierPremium
+	}
+	return domain.CostTierStandard
+}
+
+// providerCostTier returns the cost tier configured on a provider, or the tier of
+// the request model's price when it has none
+func providerCostTier(p *domain.Provider, requestModel string, prices func(model string) *pricing.ModelPricing) domain.CostTier {
+	if p.Config != nil && p.Config.CostTier != "" {
+		return p.Config.CostTier
+	}
+	return priceCostTier(requestModel, prices)
+}
+
+// orderByCost stably reorders routes, already sorted by the routing strategy, by
+// the cost tier of their providers: cheapest first or best first. Within a tier
+// the strategy order is kept. Providers whose recent attempts mostly fail are left
+// out, unless all of them do, so a cheap provider that is down doesn't keep
+// taking the first attempt.
+func orderByCost(routes []*domain.Route, pref domain.CostPreference, tier func(providerID uint64) domain.CostTier, health func(providerID uint64) ProviderHealth) []*domain.Route {
+	up := make([]*domain.Route, 0, len(routes))
+	for _, route := range routes {
+		if h := health(route.ProviderID); h.Scored() && h.SuccessRate < costAffinityDownRate {
+			continue
+		}
+		up = append(up, route)
+	}
+	if len(up) == 0 {
+		up = append(up, routes...)
+	}
+
+	ranks := make(map[uint64]int, len(up))
+	for _, route := range up {
+		rank := costTierRank(tier(route.ProviderID))
+		if pref == domain.CostPreferenceBestFirst {
+			rank = -rank
+		}
+		ranks[route.ProviderID] = rank
+	}
+	sort.SliceStable(up, func(i, j int) bool {
+		return ranks[up[i].ProviderID] < ranks[up[j].ProviderID]
+	})
+	return up
+}
+
+// costPreference returns the cost preference of a project, none without a project
+func (r *Router) costPreference(projectID uint64) domain.CostPreference {
+	if projectID == 0 {
+		return ""
+	}
+	project, err := r.projectRepo.GetByID(projectID)
+	if err != nil || project == nil {
+		return ""
+	}
+	return project.CostPreference
+}
+
+// applyCostPreference orders routes by the project's cost preference, if it has one
+func (r *Router) applyCostPreference(routes []*domain.Route, projectID uint64, requestModel string) []*domain.Route {
+	pref := r.costPreference(projectID)
+	if pref == "" {
+		return routes
+	}
+	return orderByCost(routes, pref, costTiers(r.providerRepo.GetAll(), requestModel), r.health.Health)
+}
+
+// preferredTierRoutes keeps the routes of the tier a cost preference tries first,
+// ignoring provider health
+func preferredTierRoutes(routes []*domain.Route, pref domain.CostPreference, tier func(providerID uint64) domain.CostTier) []*domain.Route {
+	ordered := orderByCost(routes, pref, tier, func(uint64) ProviderHealth { return ProviderHealth{} })
+	first := tier(ordered[0].ProviderID)
+	n := 1
+	for n < len(ordered) && costTierRank(tier(ordered[n].ProviderID)) == costTierRank(first) {
+		n++
+	}
+	return ordered[:n]
+}
+
+// costTiers returns the cost tier of each provider for a request model
+func costTiers(providers map[uint64]*domain.Provider, requestModel string) func(providerID uint64) domain.CostTier {
+	prices := pricing.GlobalCalculator().GetPricing
+	return func(providerID uint64) domain.CostTier {
+		if p, ok := providers[providerID]; ok {
+			return providerCostTier(p, requestModel, prices)
+		}
+		return domain.CostTierStandard
+	}
+}
@@ -0,0 +1,125 @@
+package router
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/pricing"
+)
+
+// newCostRouter sets up three global routes ordered standard, economy, premium and a
+// project per cost preference
+func newCostRouter(t *testing.T) (r *Router, providers map[domain.CostTier]*domain.Provider, projects map[domain.CostPreference]uint64) {
+	t.Helper()
+	r, _ = newForcedRouter(t)
+	for _, route := range r.routeRepo.GetAll() {
+		if err := r.routeRepo.Delete(route.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	providers = make(map[domain.CostTier]*domain.Provider)
+	// The standard provider has no tier, it is placed by the price of the request model
+	for i, tier := range []domain.CostTier{domain.CostTierStandard, domain.CostTierEconomy, domain.CostTierPremium} {
+		p := &domain.Provider{Name: string(tier), Type: "custom", Config: &domain.ProviderConfig{CostTier: tier}}
+		if tier == domain.CostTierStandard {
+			p.Config.CostTier = ""
+		}
+		if err := r.providerRepo.Create(p); err != nil {
+			t.Fatal(err)
+		}
+		r.adapters[p.ID] = stubAdapter{}
+		route := &domain.Route{IsEnabled: true, ClientType: domain.ClientTypeClaude, ProviderID: p.ID, Position: i + 1}
+		if err := r.routeRepo.Create(route); err != nil {
+			t.Fatal(err)
+		}
+		providers[tier] = p
+	}
+
+	projects = make(map[domain.CostPreference]uint64)
+	for _, pref := range []domain.CostPreference{"", domain.CostPreferenceCheapestFirst, domain.CostPreferenceBestFirst} {
+		project := &domain.Project{Name: "project " + string(pref), Slug: "p-" + string(pref), CostPreference: pref}
+		if err := r.projectRepo.Create(project); err != nil {
+			t.Fatal(err)
+		}
+		projects[pref] = project.ID
+	}
+	return r, providers, projects
+}
+
+func matchOrder(t *testing.T, r *Router, projectID uint64) []string {
+	t.Helper()
+	matched, err := r.Match(&MatchContext{ClientType: domain.ClientTypeClaude, ProjectID: projectID, RequestModel: "claude-sonnet-4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(matched))
+	for i, m := range matched {
+		names[i] = m.Provider.Name
+	}
+	return names
+}
+
+func TestMatchOrdersByCostPreference(t *testing.T) {
+	r, _, projects := newCostRouter(t)
+
+	tests := []struct {
+		pref domain.CostPreference
+		want []string
+	}{
+		{"", []string{"standard", "economy", "premium"}},
+		{domain.CostPreferenceCheapestFirst, []string{"economy", "standard", "premium"}},
+		{domain.CostPreferenceBestFirst, []string{"premium", "standard", "economy"}},
+	}
+	for _, tt := range tests {
+		if got := matchOrder(t, r, projects[tt.pref]); !slices.Equal(got, tt.want) {
+			t.Errorf("preference %q: order = %v, want %v", tt.pref, got, tt.want)
+		}
+	}
+}
+
+func TestMatchCostPreferenceSkipsDownProviders(t *testing.T) {
+	r, providers, projects := newCostRouter(t)
+	recordHealth(r.health, providers[domain.CostTierEconomy].ID, 40, 1, 0)
+	recordHealth(r.health, providers[domain.CostTierPremium].ID, 40, 2, time.Second) // half fail, still up
+
+	if got := matchOrder(t, r, projects[domain.CostPreferenceCheapestFirst]); !slices.Equal(got, []string{"standard", "premium"}) {
+		t.Errorf("cheapest first = %v, want the economy provider skipped", got)
+	}
+	if got := matchOrder(t, r, projects[domain.CostPreferenceBestFirst]); !slices.Equal(got, []string{"premium", "standard"}) {
+		t.Errorf("best first = %v, want the economy provider skipped", got)
+	}
+
+	// With every provider down they are all still tried, in preference order
+	recordHealth(r.health, providers[domain.CostTierStandard].ID, 40, 1, 0)
+	recordHealth(r.health, providers[domain.CostTierPremium].ID, 40, 1, 0)
+	r.health.now = func() time.Time { return time.Now().Add(healthRecomputeInterval) }
+	if got := matchOrder(t, r, projects[domain.CostPreferenceCheapestFirst]); !slices.Equal(got, []string{"economy", "standard", "premium"}) {
+		t.Errorf("all down = %v, want all providers", got)
+	}
+}
+
+func TestPriceCostTier(t *testing.T) {
+	table := pricing.NewPriceTable("test")
+	table.Set(&pricing.ModelPricing{ModelID: "small", InputPriceMicro: 250_000})
+	table.Set(&pricing.ModelPricing{ModelID: "mid", InputPriceMicro: 3_000_000})
+	table.Set(&pricing.ModelPricing{ModelID: "large", InputPriceMicro: 15_000_000})
+
+	for model, want := range map[string]domain.CostTier{
+		"small-2025": domain.CostTierEconomy,
+		"mid":        domain.CostTierStandard,
+		"large":      domain.CostTierPremium,
+		"unknown":    domain.CostTierStandard,
+	} {
+		if got := priceCostTier(model, table.Get); got != want {
+			t.Errorf("tier of %s = %s, want %s", model, got, want)
+		}
+	}
+
+	tiered := &domain.Provider{Config: &domain.ProviderConfig{CostTier: domain.CostTierPremium}}
+	if got := providerCostTier(tiered, "small", table.Get); got != domain.CostTierPremium {
+		t.Errorf("configured tier = %s, want premium", got)
+	}
+}
@@ -0,0 +1,39 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// matchForced returns the provider named by ctx.ForceProviderID as the only match,
+// bypassing routes, cooldowns and the provider's supported models. It is used by
+// operators testing a specific upstream through the normal request path.
+func (r *Router) matchForced(ctx *MatchContext) ([]*MatchedRoute, error) {
+	prov, ok := r.providerRepo.GetAll()[ctx.ForceProviderID]
+	if !ok {
+		return nil, fmt.Errorf("%w: forced provider %d not found", domain.ErrNoRoutes, ctx.ForceProviderID)
+	}
+
+	r.mu.RLock()
+	adp, ok := r.adapters[prov.ID]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: forced provider %d has no adapter", domain.ErrNoRoutes, prov.ID)
+	}
+
+	// Not a configured route, only what the executor needs to attempt the provider
+	route := &domain.Route{
+		IsEnabled:  true,
+		ProjectID:  ctx.ProjectID,
+		ClientType: ctx.ClientType,
+		ProviderID: prov.ID,
+	}
+	retryConfig, _ := r.retryConfigRepo.GetDefault()
+	return []*MatchedRoute{{
+		Route:           route,
+		Provider:        prov,
+		ProviderAdapter: adp,
+		RetryConfig:     retryConfig,
+	}}, nil
+}
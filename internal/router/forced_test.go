@@ -0,0 +1,98 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/cooldown"
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository/cached"
+	"github.com/awsl-project/maxx/internal/repository/sqlite"
+)
+
+type stubAdapter struct{}
+
+func (stubAdapter) SupportedClientTypes() []domain.ClientType {
+	return []domain.ClientType{domain.ClientTypeClaude}
+}
+
+func (stubAdapter) Execute(ctx context.Context, w http.ResponseWriter, req *http.Request, provider *domain.Provider) error {
+	return nil
+}
+
+func newForcedRouter(t *testing.T) (*Router, []*domain.Provider) {
+	t.Helper()
+	db, err := sqlite.NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRouter(
+		cached.NewRouteRepository(sqlite.NewRouteRepository(db)),
+		cached.NewProviderRepository(sqlite.NewProviderRepository(db)),
+		cached.NewRoutingStrategyRepository(sqlite.NewRoutingStrategyRepository(db)),
+		cached.NewRetryConfigRepository(sqlite.NewRetryConfigRepository(db)),
+		cached.NewProjectRepository(sqlite.NewProjectRepository(db)),
+		sqlite.NewRoundRobinStateRepository(db),
+	)
+	r.cooldownManager = cooldown.NewManager()
+
+	providers := []*domain.Provider{
+		{Name: "routed", Type: "custom"},
+		{Name: "unrouted", Type: "custom", SupportModels: []string{"gpt-*"}},
+	}
+	for _, p := range providers {
+		if err := r.providerRepo.Create(p); err != nil {
+			t.Fatal(err)
+		}
+		r.adapters[p.ID] = stubAdapter{}
+	}
+	route := &domain.Route{IsEnabled: true, ClientType: domain.ClientTypeClaude, ProviderID: providers[0].ID}
+	if err := r.routeRepo.Create(route); err != nil {
+		t.Fatal(err)
+	}
+	return r, providers
+}
+
+func TestMatchForcedProviderIgnoresCooldown(t *testing.T) {
+	r, providers := newForcedRouter(t)
+	cooled := providers[0]
+	r.cooldownManager.RecordFailure(cooled.ID, "", cooldown.ReasonServerError, nil)
+
+	ctx := &MatchContext{ClientType: domain.ClientTypeClaude, RequestModel: "claude-sonnet-4"}
+	if _, err := r.Match(ctx); !errors.Is(err, domain.ErrNoRoutes) {
+		t.Fatalf("regular match with the only route cooling down: err = %v, want no routes", err)
+	}
+
+	ctx.ForceProviderID = cooled.ID
+	matched, err := r.Match(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matched) != 1 || matched[0].Provider.ID != cooled.ID || matched[0].Route.ID != 0 {
+		t.Fatalf("forced match = %+v, want only provider %d on an unsaved route", matched, cooled.ID)
+	}
+}
+
+func TestMatchForcedProviderWithoutRoute(t *testing.T) {
+	r, providers := newForcedRouter(t)
+
+	// No route and an unsupported model don't matter either
+	matched, err := r.Match(&MatchContext{
+		ClientType:      domain.ClientTypeClaude,
+		RequestModel:    "claude-sonnet-4",
+		ForceProviderID: providers[1].ID,
+	})
+	if err != nil || len(matched) != 1 || matched[0].Provider.ID != providers[1].ID {
+		t.Fatalf("forced match = %+v, %v", matched, err)
+	}
+	if route := matched[0].Route; route.ProviderID != providers[1].ID || route.ClientType != domain.ClientTypeClaude {
+		t.Errorf("route = %+v", route)
+	}
+
+	if _, err := r.Match(&MatchContext{ClientType: domain.ClientTypeClaude, ForceProviderID: 999}); !errors.Is(err, domain.ErrNoRoutes) {
+		t.Errorf("unknown forced provider: err = %v, want no routes", err)
+	}
+}
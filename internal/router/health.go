@@ -0,0 +1,129 @@
+package router
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	healthWindowSize        = 100              // most recent attempts kept per provider
+	healthMaxAge            = 10 * time.Minute // older attempts no longer count
+	healthMinSamples        = 20               // below this a provider has no health score
+	healthRecomputeInterval = 30 * time.Second
+)
+
+type healthSample struct {
+	at      time.Time
+	ok      bool
+	latency time.Duration // successful attempts only
+}
+
+type healthWindow struct {
+	samples [healthWindowSize]healthSample
+	count   int // number of valid samples, up to healthWindowSize
+	next    int // ring buffer write position
+
+	// health is recomputed at most once per healthRecomputeInterval
+	health     ProviderHealth
+	computedAt time.Time
+}
+
+// ProviderHealth summarizes the recent attempts of a provider
+type ProviderHealth struct {
+	Samples       int           // attempts within healthMaxAge
+	SuccessRate   float64       // 0..1
+	MedianLatency time.Duration // of the successful attempts
+}
+
+// Scored reports whether there are enough recent attempts to judge the provider
+func (h ProviderHealth) Scored() bool {
+	return h.Samples >= healthMinSamples
+}
+
+// HealthTracker keeps the outcome and duration of the recent attempts of each
+// provider in memory, for the adaptive route order.
+type HealthTracker struct {
+	mu      sync.Mutex
+	windows map[uint64]*healthWindow
+	now     func() time.Time
+}
+
+// NewHealthTracker creates an empty tracker
+func NewHealthTracker() *HealthTracker {
+	return &HealthTracker{
+		windows: make(map[uint64]*healthWindow),
+		now:     time.Now,
+	}
+}
+
+// RecordSuccess adds a successful attempt and its duration
+func (t *HealthTracker) RecordSuccess(providerID uint64, d time.Duration) {
+	t.record(providerID, healthSample{ok: true, latency: d})
+}
+
+// RecordFailure adds a failed attempt
+func (t *HealthTracker) RecordFailure(providerID uint64) {
+	t.record(providerID, healthSample{})
+}
+
+func (t *HealthTracker) record(providerID uint64, s healthSample) {
+	s.at = t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w, ok := t.windows[providerID]
+	if !ok {
+		w = &healthWindow{}
+		t.windows[providerID] = w
+	}
+	w.samples[w.next] = s
+	w.next = (w.next + 1) % healthWindowSize
+	if w.count < healthWindowSize {
+		w.count++
+	}
+}
+
+// Health returns the provider's recent health. The value is cached and recomputed
+// periodically, attempts older than healthMaxAge are left out so a provider that
+// stopped receiving traffic loses its score instead of keeping a stale one.
+func (t *HealthTracker) Health(providerID uint64) ProviderHealth {
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w, ok := t.windows[providerID]
+	if !ok {
+		return ProviderHealth{}
+	}
+	if w.computedAt.IsZero() || now.Sub(w.computedAt) >= healthRecomputeInterval {
+		w.health = computeHealth(w.samples[:w.count], now.Add(-healthMaxAge))
+		w.computedAt = now
+	}
+	return w.health
+}
+
+func computeHealth(samples []healthSample, since time.Time) ProviderHealth {
+	var h ProviderHealth
+	var successes int
+	var latencies []time.Duration
+	for _, s := range samples {
+		if s.at.Before(since) {
+			continue
+		}
+		h.Samples++
+		if s.ok {
+			successes++
+			latencies = append(latencies, s.latency)
+		}
+	}
+	if h.Samples == 0 {
+		return h
+	}
+	h.SuccessRate = float64(successes) / float64(h.Samples)
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		h.MedianLatency = latencies[len(latencies)/2]
+	}
+	return h
+}
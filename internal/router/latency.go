@@ -0,0 +1,126 @@
+package router
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+const (
+	latencyWindowSize        = 200 // most recent successful attempts kept per provider
+	latencyMinSamples        = 20  // below this the ceiling is used
+	latencyRecomputeInterval = 30 * time.Second
+
+	DefaultAdaptiveTimeoutFloor      = 60 * time.Second
+	DefaultAdaptiveTimeoutCeiling    = 10 * time.Minute
+	DefaultAdaptiveTimeoutMultiplier = 3.0
+)
+
+type latencyWindow struct {
+	samples [latencyWindowSize]time.Duration
+	count   int // number of valid samples, up to latencyWindowSize
+	next    int // ring buffer write position
+
+	// p99 is recomputed at most once per latencyRecomputeInterval
+	p99        time.Duration
+	p99Samples int
+	computedAt time.Time
+}
+
+// LatencyTracker keeps the recent successful attempt durations of each provider
+// in memory and derives an adaptive request timeout from their p99.
+type LatencyTracker struct {
+	mu      sync.Mutex
+	windows map[uint64]*latencyWindow
+	now     func() time.Time
+}
+
+// NewLatencyTracker creates an empty tracker
+func NewLatencyTracker() *LatencyTracker {
+	return &LatencyTracker{
+		windows: make(map[uint64]*latencyWindow),
+		now:     time.Now,
+	}
+}
+
+// Record adds the duration of a successful attempt
+func (t *LatencyTracker) Record(providerID uint64, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w, ok := t.windows[providerID]
+	if !ok {
+		w = &latencyWindow{}
+		t.windows[providerID] = w
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % latencyWindowSize
+	if w.count < latencyWindowSize {
+		w.count++
+	}
+}
+
+// P99 returns the provider's p99 latency and the number of samples it is based on.
+// The value is cached and recomputed periodically.
+func (t *LatencyTracker) P99(providerID uint64) (time.Duration, int) {
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w, ok := t.windows[providerID]
+	if !ok {
+		return 0, 0
+	}
+	if w.computedAt.IsZero() || now.Sub(w.computedAt) >= latencyRecomputeInterval {
+		sorted := slices.Clone(w.samples[:w.count])
+		slices.Sort(sorted)
+		idx := int(math.Ceil(0.99*float64(len(sorted)))) - 1
+		w.p99 = sorted[max(idx, 0)]
+		w.p99Samples = len(sorted)
+		w.computedAt = now
+	}
+	return w.p99, w.p99Samples
+}
+
+// Timeout returns the adaptive request timeout for a provider,
+// max(floor, multiplier × p99) bounded by the ceiling.
+// It returns 0 when cfg is nil, i.e. adaptive timeout is disabled.
+func (t *LatencyTracker) Timeout(providerID uint64, cfg *domain.AdaptiveTimeoutConfig) time.Duration {
+	if cfg == nil {
+		return 0
+	}
+	floor, ceiling, multiplier := adaptiveTimeoutBounds(cfg)
+
+	p99, samples := t.P99(providerID)
+	if samples < latencyMinSamples {
+		return ceiling
+	}
+	timeout := time.Duration(multiplier * float64(p99))
+	return min(max(timeout, floor), ceiling)
+}
+
+// adaptiveTimeoutBounds applies the defaults to unset config fields
+func adaptiveTimeoutBounds(cfg *domain.AdaptiveTimeoutConfig) (floor, ceiling time.Duration, multiplier float64) {
+	floor = DefaultAdaptiveTimeoutFloor
+	if cfg.FloorSeconds > 0 {
+		floor = time.Duration(cfg.FloorSeconds) * time.Second
+	}
+	ceiling = DefaultAdaptiveTimeoutCeiling
+	if cfg.CeilingSeconds > 0 {
+		ceiling = time.Duration(cfg.CeilingSeconds) * time.Second
+	}
+	if ceiling < floor {
+		ceiling = floor
+	}
+	multiplier = DefaultAdaptiveTimeoutMultiplier
+	if cfg.Multiplier > 0 {
+		multiplier = cfg.Multiplier
+	}
+	return floor, ceiling, multiplier
+}
@@ -0,0 +1,75 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestAdaptiveTimeoutFromP99(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tracker := NewLatencyTracker()
+	tracker.now = func() time.Time { return now }
+	cfg := &domain.AdaptiveTimeoutConfig{FloorSeconds: 10, CeilingSeconds: 300, Multiplier: 2}
+
+	// Too few samples, fall back to the ceiling
+	tracker.Record(1, 5*time.Second)
+	if got := tracker.Timeout(1, cfg); got != 300*time.Second {
+		t.Fatalf("timeout with 1 sample = %v, want ceiling", got)
+	}
+
+	// 99 fast requests and one slow one, p99 is the 99th value
+	for i := 0; i < 98; i++ {
+		tracker.Record(1, 2*time.Second)
+	}
+	tracker.Record(1, 30*time.Second)
+	now = now.Add(latencyRecomputeInterval)
+	if p99, samples := tracker.P99(1); p99 != 5*time.Second || samples != 100 {
+		t.Errorf("p99 = %v over %d samples, want 5s over 100", p99, samples)
+	}
+	if got := tracker.Timeout(1, cfg); got != 10*time.Second {
+		t.Errorf("timeout = %v, want 2 x 5s = 10s", got)
+	}
+
+	// Slow providers are bounded by the ceiling, fast ones by the floor
+	for i := 0; i < latencyWindowSize; i++ {
+		tracker.Record(2, 200*time.Second)
+		tracker.Record(3, 100*time.Millisecond)
+	}
+	if got := tracker.Timeout(2, cfg); got != 300*time.Second {
+		t.Errorf("slow provider timeout = %v, want ceiling 300s", got)
+	}
+	if got := tracker.Timeout(3, cfg); got != 10*time.Second {
+		t.Errorf("fast provider timeout = %v, want floor 10s", got)
+	}
+
+	if got := tracker.Timeout(1, nil); got != 0 {
+		t.Errorf("disabled timeout = %v, want 0", got)
+	}
+}
+
+func TestLatencyP99RecomputedPeriodically(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tracker := NewLatencyTracker()
+	tracker.now = func() time.Time { return now }
+
+	for i := 0; i < latencyMinSamples; i++ {
+		tracker.Record(1, time.Second)
+	}
+	if p99, _ := tracker.P99(1); p99 != time.Second {
+		t.Fatalf("p99 = %v, want 1s", p99)
+	}
+
+	// New samples only show up after the recompute interval
+	for i := 0; i < latencyWindowSize; i++ {
+		tracker.Record(1, 4*time.Second)
+	}
+	if p99, _ := tracker.P99(1); p99 != time.Second {
+		t.Errorf("p99 before recompute = %v, want cached 1s", p99)
+	}
+	now = now.Add(latencyRecomputeInterval)
+	if p99, samples := tracker.P99(1); p99 != 4*time.Second || samples != latencyWindowSize {
+		t.Errorf("p99 after recompute = %v over %d samples, want 4s over %d", p99, samples, latencyWindowSize)
+	}
+}
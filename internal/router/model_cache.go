@@ -0,0 +1,171 @@
+package router
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+const (
+	// defaultModelCacheTTL is how long a provider's model list is served before it is refreshed
+	defaultModelCacheTTL = time.Hour
+	// modelCacheRetryInterval bounds how often a failing provider is asked again
+	modelCacheRetryInterval = time.Minute
+	// modelFetchTimeout bounds a single upstream model list fetch
+	modelFetchTimeout = 30 * time.Second
+)
+
+// ModelFetcher fetches the model list of a provider from its upstream
+type ModelFetcher func(ctx context.Context, providerID uint64) ([]domain.ModelInfo, error)
+
+// ModelCache keeps the upstream model list of each provider. Reads never wait on
+// the upstream: a missing or expired entry is refreshed in the background while
+// the previous list, if any, keeps being served. Invalidate drops an entry so the
+// next read fetches it again, e.g. after the provider config changed.
+type ModelCache struct {
+	mu      sync.Mutex
+	entries map[uint64]*modelCacheEntry
+	// generation is bumped on invalidation so refreshes started before it are discarded
+	generation map[uint64]uint64
+	fetch      ModelFetcher
+	ttl        time.Duration
+	now        func() time.Time
+}
+
+type modelCacheEntry struct {
+	models     []domain.ModelInfo
+	fetchedAt  time.Time // last successful fetch
+	checkedAt  time.Time // last fetch attempt
+	err        error     // error of the last attempt, nil when it succeeded
+	refreshing bool
+}
+
+// NewModelCache creates an empty cache serving fetched lists for ttl
+func NewModelCache(fetch ModelFetcher, ttl time.Duration) *ModelCache {
+	return &ModelCache{
+		entries:    make(map[uint64]*modelCacheEntry),
+		generation: make(map[uint64]uint64),
+		fetch:      fetch,
+		ttl:        ttl,
+		now:        time.Now,
+	}
+}
+
+// Get returns the cached model list of a provider and whether one was ever fetched.
+// A missing or expired list is refreshed in the background.
+func (c *ModelCache) Get(providerID uint64) ([]domain.ModelInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.entries[providerID]
+	if entry == nil {
+		entry = &modelCacheEntry{}
+		c.entries[providerID] = entry
+	}
+	if !entry.refreshing && c.dueLocked(entry) {
+		entry.refreshing = true
+		go c.refreshInBackground(providerID, c.generation[providerID])
+	}
+	return entry.models, !entry.fetchedAt.IsZero()
+}
+
+// Refresh fetches the model list of a provider now and stores it.
+// On failure the previous list is kept and the error returned.
+func (c *ModelCache) Refresh(ctx context.Context, providerID uint64) ([]domain.ModelInfo, error) {
+	c.mu.Lock()
+	gen := c.generation[providerID]
+	c.mu.Unlock()
+	return c.load(ctx, providerID, gen)
+}
+
+// Invalidate drops the cached model list of a provider
+func (c *ModelCache) Invalidate(providerID uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, providerID)
+	c.generation[providerID]++
+}
+
+// InvalidateAll drops the cached model lists of every provider
+func (c *ModelCache) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id := range c.entries {
+		c.generation[id]++
+	}
+	c.entries = make(map[uint64]*modelCacheEntry)
+}
+
+// Info reports the cache state of a provider without triggering a refresh
+func (c *ModelCache) Info(providerID uint64) *domain.ProviderModelsInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info := &domain.ProviderModelsInfo{ProviderID: providerID, Models: []domain.ModelInfo{}}
+	entry := c.entries[providerID]
+	if entry == nil {
+		return info
+	}
+	if entry.models != nil {
+		info.Models = entry.models
+	}
+	if !entry.fetchedAt.IsZero() {
+		fetchedAt := entry.fetchedAt
+		expiresAt := entry.fetchedAt.Add(c.ttl)
+		info.FetchedAt = &fetchedAt
+		info.ExpiresAt = &expiresAt
+	}
+	info.Stale = c.dueLocked(entry)
+	info.Refreshing = entry.refreshing
+	if entry.err != nil {
+		info.Error = entry.err.Error()
+	}
+	return info
+}
+
+// dueLocked reports whether the entry should be fetched again, failures are retried sooner
+func (c *ModelCache) dueLocked(entry *modelCacheEntry) bool {
+	if entry.checkedAt.IsZero() {
+		return true
+	}
+	interval := c.ttl
+	if entry.err != nil {
+		interval = min(interval, modelCacheRetryInterval)
+	}
+	return !c.now().Before(entry.checkedAt.Add(interval))
+}
+
+func (c *ModelCache) refreshInBackground(providerID uint64, gen uint64) {
+	ctx, cancel := context.WithTimeout(context.Background(), modelFetchTimeout)
+	defer cancel()
+	if _, err := c.load(ctx, providerID, gen); err != nil {
+		log.Printf("[ModelCache] Failed to refresh models of provider %d: %v", providerID, err)
+	}
+}
+
+// load fetches and stores the list unless the provider was invalidated meanwhile
+func (c *ModelCache) load(ctx context.Context, providerID uint64, gen uint64) ([]domain.ModelInfo, error) {
+	models, err := c.fetch(ctx, providerID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation[providerID] != gen {
+		return models, err
+	}
+	entry := c.entries[providerID]
+	if entry == nil {
+		entry = &modelCacheEntry{}
+		c.entries[providerID] = entry
+	}
+	entry.refreshing = false
+	entry.checkedAt = c.now()
+	entry.err = err
+	if err == nil {
+		entry.models = models
+		entry.fetchedAt = entry.checkedAt
+	}
+	return models, err
+}
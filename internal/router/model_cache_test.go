@@ -0,0 +1,158 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// fakeUpstream serves a model list whose version changes on every fetch
+type fakeUpstream struct {
+	mu      sync.Mutex
+	fetches int
+	err     error
+	block   chan struct{} // when set, fetches wait on it
+}
+
+func (f *fakeUpstream) fetch(ctx context.Context, providerID uint64) ([]domain.ModelInfo, error) {
+	f.mu.Lock()
+	block := f.block
+	f.mu.Unlock()
+	if block != nil {
+		<-block
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fetches++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []domain.ModelInfo{{ID: fmt.Sprintf("model-v%d", f.fetches)}}, nil
+}
+
+func (f *fakeUpstream) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.fetches
+}
+
+func modelID(models []domain.ModelInfo) string {
+	if len(models) == 0 {
+		return ""
+	}
+	return models[0].ID
+}
+
+func TestModelCacheTTL(t *testing.T) {
+	upstream := &fakeUpstream{}
+	now := time.Unix(1_700_000_000, 0)
+	cache := NewModelCache(upstream.fetch, time.Hour)
+	cache.now = func() time.Time { return now }
+
+	// The first read finds nothing and fetches in the background
+	if models, ok := cache.Get(1); ok || models != nil {
+		t.Fatalf("empty cache returned %v", models)
+	}
+	waitFor(t, func() bool { _, ok := cache.Get(1); return ok })
+	if models, _ := cache.Get(1); modelID(models) != "model-v1" {
+		t.Errorf("cached models = %v, want model-v1", models)
+	}
+
+	// Fresh entries are served without asking the upstream again
+	now = now.Add(59 * time.Minute)
+	cache.Get(1)
+	if upstream.count() != 1 {
+		t.Errorf("fresh entry fetched %d times, want 1", upstream.count())
+	}
+
+	// Expired entries keep being served while they refresh
+	now = now.Add(2 * time.Minute)
+	if models, _ := cache.Get(1); modelID(models) != "model-v1" {
+		t.Errorf("expired read returned %v, want the stale model-v1", models)
+	}
+	waitFor(t, func() bool { models, _ := cache.Get(1); return modelID(models) == "model-v2" })
+	if upstream.count() != 2 {
+		t.Errorf("upstream fetched %d times, want 2", upstream.count())
+	}
+}
+
+func TestModelCacheFailureKeepsList(t *testing.T) {
+	upstream := &fakeUpstream{}
+	now := time.Unix(1_700_000_000, 0)
+	cache := NewModelCache(upstream.fetch, time.Hour)
+	cache.now = func() time.Time { return now }
+
+	if _, err := cache.Refresh(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	upstream.mu.Lock()
+	upstream.err = errors.New("boom")
+	upstream.mu.Unlock()
+	now = now.Add(2 * time.Hour)
+	if _, err := cache.Refresh(context.Background(), 1); err == nil {
+		t.Fatal("failed refresh returned no error")
+	}
+
+	info := cache.Info(1)
+	if modelID(info.Models) != "model-v1" || info.Error != "boom" {
+		t.Errorf("after failure info = %+v, want model-v1 kept with the error", info)
+	}
+
+	// Failures are retried after the retry interval rather than the full TTL
+	if info.Stale {
+		t.Error("entry is due right after a failed fetch")
+	}
+	now = now.Add(modelCacheRetryInterval)
+	if !cache.Info(1).Stale {
+		t.Error("failed entry not due after the retry interval")
+	}
+}
+
+func TestModelCacheInvalidate(t *testing.T) {
+	upstream := &fakeUpstream{}
+	cache := NewModelCache(upstream.fetch, time.Hour)
+
+	for _, id := range []uint64{1, 2} {
+		if _, err := cache.Refresh(context.Background(), id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache.Invalidate(1)
+	if _, ok := cache.Get(1); ok {
+		t.Error("invalidated provider still cached")
+	}
+	if _, ok := cache.Get(2); !ok {
+		t.Error("invalidating provider 1 dropped provider 2")
+	}
+	waitFor(t, func() bool { _, ok := cache.Get(1); return ok })
+
+	cache.InvalidateAll()
+	for _, id := range []uint64{1, 2} {
+		if info := cache.Info(id); info.FetchedAt != nil {
+			t.Errorf("provider %d still cached after InvalidateAll", id)
+		}
+	}
+}
+
+func TestModelCacheInvalidateDiscardsInflightRefresh(t *testing.T) {
+	upstream := &fakeUpstream{block: make(chan struct{})}
+	cache := NewModelCache(upstream.fetch, time.Hour)
+
+	// A refresh started before the provider config changed must not repopulate the cache
+	cache.Get(1)
+	cache.Invalidate(1)
+	close(upstream.block)
+	waitFor(t, func() bool { return upstream.count() == 1 })
+
+	if info := cache.Info(1); info.FetchedAt != nil {
+		t.Errorf("refresh started before invalidation was stored: %+v", info)
+	}
+}
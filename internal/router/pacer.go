@@ -0,0 +1,75 @@
+package router
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Pacer spaces out the upstream calls of each provider by a minimum interval.
+// Calls arriving faster are queued, each reserving the next free slot, so a
+// burst is smoothed into a steady stream instead of being rejected.
+type Pacer struct {
+	mu      sync.Mutex
+	next    map[uint64]time.Time // earliest start of the provider's next call
+	waiting map[uint64]int
+	now     func() time.Time
+}
+
+// NewPacer creates an empty pacer
+func NewPacer() *Pacer {
+	return &Pacer{
+		next:    make(map[uint64]time.Time),
+		waiting: make(map[uint64]int),
+		now:     time.Now,
+	}
+}
+
+// Wait blocks until the provider may be called again, at least interval after
+// its previous call started. It returns the context error when ctx ends first,
+// giving the reserved slot back if no later call queued behind it.
+func (p *Pacer) Wait(ctx context.Context, providerID uint64, interval time.Duration) error {
+	if interval <= 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	now := p.now()
+	slot := now
+	if next := p.next[providerID]; next.After(now) {
+		slot = next
+	}
+	p.next[providerID] = slot.Add(interval)
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	p.waiting[providerID]++
+	p.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		p.mu.Lock()
+		p.waiting[providerID]--
+		p.mu.Unlock()
+		return nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		p.waiting[providerID]--
+		if p.next[providerID].Equal(slot.Add(interval)) {
+			p.next[providerID] = slot
+		}
+		p.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Delay returns how long a call to the provider would wait now and how many calls are queued
+func (p *Pacer) Delay(providerID uint64) (time.Duration, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return max(p.next[providerID].Sub(p.now()), 0), p.waiting[providerID]
+}
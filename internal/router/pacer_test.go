@@ -0,0 +1,103 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPacerSpacesConcurrentCalls(t *testing.T) {
+	const interval = 30 * time.Millisecond
+	pacer := NewPacer()
+
+	var mu sync.Mutex
+	var starts []time.Time
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := pacer.Wait(context.Background(), 1, interval); err != nil {
+				t.Errorf("Wait failed: %v", err)
+				return
+			}
+			mu.Lock()
+			starts = append(starts, time.Now())
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	slices.SortFunc(starts, func(a, b time.Time) int { return a.Compare(b) })
+	for i := 1; i < len(starts); i++ {
+		// Timers never fire early, allow a little slack for clock granularity
+		if gap := starts[i].Sub(starts[i-1]); gap < interval-time.Millisecond {
+			t.Errorf("call %d started %v after the previous one, want at least %v", i, gap, interval)
+		}
+	}
+
+	// Other providers are not held back
+	begin := time.Now()
+	if err := pacer.Wait(context.Background(), 2, interval); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if waited := time.Since(begin); waited > interval/2 {
+		t.Errorf("first call to another provider waited %v", waited)
+	}
+}
+
+func TestPacerCancelReleasesSlot(t *testing.T) {
+	const interval = time.Hour
+	now := time.Unix(1_700_000_000, 0)
+	pacer := NewPacer()
+	pacer.now = func() time.Time { return now }
+
+	if err := pacer.Wait(context.Background(), 1, interval); err != nil {
+		t.Fatalf("first call waited: %v", err)
+	}
+
+	// The second call queues for an hour, cancelling it gives its slot back
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- pacer.Wait(ctx, 1, interval) }()
+	waitFor(t, func() bool { _, waiting := pacer.Delay(1); return waiting == 1 })
+	if delay, _ := pacer.Delay(1); delay != 2*interval {
+		t.Errorf("delay with one queued call = %v, want %v", delay, 2*interval)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled Wait returned %v, want context.Canceled", err)
+	}
+
+	delay, waiting := pacer.Delay(1)
+	if delay != interval || waiting != 0 {
+		t.Errorf("after cancel delay = %v with %d waiting, want %v with none", delay, waiting, interval)
+	}
+}
+
+func TestPacerNoInterval(t *testing.T) {
+	pacer := NewPacer()
+	for i := 0; i < 3; i++ {
+		if err := pacer.Wait(context.Background(), 1, 0); err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	}
+	if delay, waiting := pacer.Delay(1); delay != 0 || waiting != 0 {
+		t.Errorf("unpaced provider has delay %v with %d waiting", delay, waiting)
+	}
+}
+
+// waitFor polls cond until it holds or a second passes
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not reached")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,44 @@
+package router
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// tokenPinned reports whether any route, enabled or not, is pinned to the token.
+// Disabling a token's dedicated routes must not let it fall back to shared ones.
+func tokenPinned(routes []*domain.Route, tokenID uint64) bool {
+	for _, route := range routes {
+		if slices.Contains(route.APITokenIDs, tokenID) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterByAPIToken keeps the candidate routes an API token may use. A token pinned
+// to routes only uses the routes listing it; other tokens, and requests without a
+// token, only use routes that aren't pinned. A pinned token without any of its
+// routes among the candidates is denied with ErrTokenRoutesDenied.
+func filterByAPIToken(candidates, all []*domain.Route, tokenID uint64) ([]*domain.Route, error) {
+	pinned := tokenID != 0 && tokenPinned(all, tokenID)
+
+	allowed := make([]*domain.Route, 0, len(candidates))
+	for _, route := range candidates {
+		if len(route.APITokenIDs) == 0 {
+			if !pinned {
+				allowed = append(allowed, route)
+			}
+			continue
+		}
+		if tokenID != 0 && slices.Contains(route.APITokenIDs, tokenID) {
+			allowed = append(allowed, route)
+		}
+	}
+	if pinned && len(allowed) == 0 {
+		return nil, fmt.Errorf("%w: API token %d is pinned to routes that don't serve this request: %w", domain.ErrNoRoutes, tokenID, domain.ErrTokenRoutesDenied)
+	}
+	return allowed, nil
+}
@@ -0,0 +1,59 @@
+package router
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestMatchPinsRoutesToAPITokens(t *testing.T) {
+	r, providers := newForcedRouter(t)
+	shared, byo := providers[0], providers[1]
+	pinned := &domain.Route{IsEnabled: true, ClientType: domain.ClientTypeClaude, ProviderID: byo.ID, Position: 2, APITokenIDs: []uint64{7}}
+	if err := r.routeRepo.Create(pinned); err != nil {
+		t.Fatal(err)
+	}
+	openai := &domain.Route{IsEnabled: true, ClientType: domain.ClientTypeOpenAI, ProviderID: shared.ID}
+	if err := r.routeRepo.Create(openai); err != nil {
+		t.Fatal(err)
+	}
+
+	match := func(clientType domain.ClientType, tokenID uint64) ([]uint64, error) {
+		matched, err := r.Match(&MatchContext{ClientType: clientType, APITokenID: tokenID})
+		var ids []uint64
+		for _, m := range matched {
+			ids = append(ids, m.Provider.ID)
+		}
+		return ids, err
+	}
+
+	// The pinned token only reaches its dedicated provider
+	if ids, err := match(domain.ClientTypeClaude, 7); err != nil || len(ids) != 1 || ids[0] != byo.ID {
+		t.Errorf("pinned token = %v, %v; want only provider %d", ids, err, byo.ID)
+	}
+	// Other tokens and requests without a token never reach the pinned route
+	for _, tokenID := range []uint64{8, 0} {
+		if ids, err := match(domain.ClientTypeClaude, tokenID); err != nil || len(ids) != 1 || ids[0] != shared.ID {
+			t.Errorf("token %d = %v, %v; want only provider %d", tokenID, ids, err, shared.ID)
+		}
+	}
+
+	// No pinned route serves OpenAI requests, the token is denied rather than falling back
+	_, err := match(domain.ClientTypeOpenAI, 7)
+	if !errors.Is(err, domain.ErrTokenRoutesDenied) || !errors.Is(err, domain.ErrNoRoutes) {
+		t.Errorf("pinned token on an unpinned client type: err = %v, want token routes denied", err)
+	}
+	if ids, err := match(domain.ClientTypeOpenAI, 8); err != nil || len(ids) != 1 {
+		t.Errorf("unpinned token on OpenAI = %v, %v", ids, err)
+	}
+
+	// Disabling the dedicated route keeps the token pinned
+	pinned.IsEnabled = false
+	if err := r.routeRepo.Update(pinned); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := match(domain.ClientTypeClaude, 7); !errors.Is(err, domain.ErrTokenRoutesDenied) {
+		t.Errorf("pinned token with its route disabled: err = %v, want token routes denied", err)
+	}
+}
@@ -0,0 +1,298 @@
+package router
+
+import (
+	"sync"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+// DefaultQuotaSoftPercent is the share of the hard cap that triggers the soft alert
+const DefaultQuotaSoftPercent = 80
+
+// QuotaLevel is the quota state of a provider after recording usage
+type QuotaLevel int
+
+const (
+	QuotaOK        QuotaLevel = iota
+	QuotaSoftLimit            // usage crossed the soft cap
+	QuotaExhausted            // usage reached the hard cap
+)
+
+// QuotaPeriodBounds returns the period containing now. The period resets at
+// cfg.ResetHour in loc: daily, on Mondays for weekly and on the 1st for monthly quotas.
+func QuotaPeriodBounds(now time.Time, cfg *domain.ProviderQuotaConfig, loc *time.Location) (start, end time.Time) {
+	t := now.In(loc)
+	hour := min(max(cfg.ResetHour, 0), 23)
+
+	switch cfg.Period {
+	case domain.QuotaPeriodWeek:
+		weekday := int(t.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		start = time.Date(t.Year(), t.Month(), t.Day()-(weekday-1), hour, 0, 0, 0, loc)
+		if start.After(t) {
+			start = start.AddDate(0, 0, -7)
+		}
+		end = start.AddDate(0, 0, 7)
+	case domain.QuotaPeriodMonth:
+		start = time.Date(t.Year(), t.Month(), 1, hour, 0, 0, 0, loc)
+		if start.After(t) {
+			start = start.AddDate(0, -1, 0)
+		}
+		end = start.AddDate(0, 1, 0)
+	default:
+		start = time.Date(t.Year(), t.Month(), t.Day(), hour, 0, 0, 0, loc)
+		if start.After(t) {
+			start = start.AddDate(0, 0, -1)
+		}
+		end = start.AddDate(0, 0, 1)
+	}
+	return start, end
+}
+
+// quotaUsage is the usage of one provider in its current period
+type quotaUsage struct {
+	periodStart time.Time
+	tokens      int64
+	requests    int64
+	softAlerted bool // the soft alert was sent for this period
+	exhausted   bool // the provider was cooled for this period
+}
+
+// QuotaTracker counts per-provider usage in the current quota period. Usage is
+// seeded from usage_stats by Sync and counted in memory between syncs, so the
+// hot path never touches the database.
+type QuotaTracker struct {
+	mu         sync.Mutex
+	usage      map[uint64]*quotaUsage
+	defaultLoc *time.Location
+	now        func() time.Time
+}
+
+// NewQuotaTracker creates an empty tracker
+func NewQuotaTracker() *QuotaTracker {
+	return &QuotaTracker{
+		usage:      make(map[uint64]*quotaUsage),
+		defaultLoc: time.Local,
+		now:        time.Now,
+	}
+}
+
+// SetDefaultLocation sets the timezone of quotas that don't configure one
+func (t *QuotaTracker) SetDefaultLocation(loc *time.Location) {
+	if loc == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.defaultLoc = loc
+}
+
+// locationLocked resolves the timezone of cfg, must be called with mu held
+func (t *QuotaTracker) locationLocked(cfg *domain.ProviderQuotaConfig) *time.Location {
+	if cfg.Timezone != "" {
+		if loc, err := time.LoadLocation(cfg.Timezone); err == nil {
+			return loc
+		}
+	}
+	return t.defaultLoc
+}
+
+// usageLocked returns the provider's usage, resetting it when a new period started
+func (t *QuotaTracker) usageLocked(providerID uint64, periodStart time.Time) *quotaUsage {
+	u, ok := t.usage[providerID]
+	if !ok || !u.periodStart.Equal(periodStart) {
+		u = &quotaUsage{periodStart: periodStart}
+		t.usage[providerID] = u
+	}
+	return u
+}
+
+// Record adds one request and its tokens to the provider's usage. It returns the
+// level newly reached, so each alert fires once per period, and the period end.
+func (t *QuotaTracker) Record(p *domain.Provider, tokens int64) (QuotaLevel, time.Time) {
+	cfg := providerQuota(p)
+	if cfg == nil {
+		return QuotaOK, time.Time{}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	start, end := QuotaPeriodBounds(t.now(), cfg, t.locationLocked(cfg))
+	u := t.usageLocked(p.ID, start)
+	u.requests++
+	if tokens > 0 {
+		u.tokens += tokens
+	}
+	return u.crossLevel(cfg), end
+}
+
+// crossLevel returns the level reached for the first time in this period
+func (u *quotaUsage) crossLevel(cfg *domain.ProviderQuotaConfig) QuotaLevel {
+	hard, soft := quotaReached(cfg, u.tokens, u.requests)
+	switch {
+	case hard && !u.exhausted:
+		u.exhausted = true
+		u.softAlerted = true
+		return QuotaExhausted
+	case soft && !u.softAlerted:
+		u.softAlerted = true
+		return QuotaSoftLimit
+	}
+	return QuotaOK
+}
+
+// Sync loads the usage observed in usage_stats since each quota period started.
+// The in-memory count is kept when higher, since the latest requests may not be
+// aggregated yet. onLevel is called for every provider newly reaching a cap.
+func (t *QuotaTracker) Sync(providers []*domain.Provider, usageRepo repository.UsageStatsRepository, onLevel func(p *domain.Provider, level QuotaLevel, periodEnd time.Time)) error {
+	now := t.now()
+	for _, p := range providers {
+		cfg := providerQuota(p)
+		if cfg == nil {
+			continue
+		}
+		t.mu.Lock()
+		start, end := QuotaPeriodBounds(now, cfg, t.locationLocked(cfg))
+		t.mu.Unlock()
+
+		tokens, requests, err := observedUsage(usageRepo, p.ID, start, now)
+		if err != nil {
+			return err
+		}
+
+		t.mu.Lock()
+		u := t.usageLocked(p.ID, start)
+		u.tokens = max(u.tokens, tokens)
+		u.requests = max(u.requests, requests)
+		level := u.crossLevel(cfg)
+		t.mu.Unlock()
+
+		if level != QuotaOK && onLevel != nil {
+			onLevel(p, level, end)
+		}
+	}
+	return nil
+}
+
+// observedUsage sums a provider's usage_stats from since to now. Whole hours come
+// from hour buckets, the partial hours at both ends from minute buckets.
+func observedUsage(usageRepo repository.UsageStatsRepository, providerID uint64, since, now time.Time) (tokens, requests int64, err error) {
+	firstHour := since.Truncate(time.Hour)
+	if firstHour.Before(since) {
+		firstHour = firstHour.Add(time.Hour)
+	}
+	currentHour := now.Truncate(time.Hour)
+
+	type span struct {
+		granularity domain.Granularity
+		start, end  time.Time
+	}
+	var spans []span
+	if !firstHour.Before(currentHour) {
+		spans = append(spans, span{domain.GranularityMinute, since, now})
+	} else {
+		if firstHour.After(since) {
+			spans = append(spans, span{domain.GranularityMinute, since, firstHour.Add(-time.Minute)})
+		}
+		spans = append(spans,
+			span{domain.GranularityHour, firstHour, currentHour.Add(-time.Hour)},
+			span{domain.GranularityMinute, currentHour, now},
+		)
+	}
+
+	for _, s := range spans {
+		start, end := s.start, s.end
+		summary, err := usageRepo.GetSummary(repository.UsageStatsFilter{
+			Granularity: s.granularity,
+			StartTime:   &start,
+			EndTime:     &end,
+			ProviderID:  &providerID,
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+		if summary != nil {
+			tokens += int64(summary.TotalInputTokens + summary.TotalOutputTokens)
+			requests += int64(summary.TotalRequests)
+		}
+	}
+	return tokens, requests, nil
+}
+
+// Info reports the provider's quota usage in the current period, nil without a quota
+func (t *QuotaTracker) Info(p *domain.Provider) *domain.ProviderQuotaInfo {
+	cfg := providerQuota(p)
+	if cfg == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	start, end := QuotaPeriodBounds(t.now(), cfg, t.locationLocked(cfg))
+	var tokens, requests int64
+	if u, ok := t.usage[p.ID]; ok && u.periodStart.Equal(start) {
+		tokens, requests = u.tokens, u.requests
+	}
+	hard, soft := quotaReached(cfg, tokens, requests)
+	return &domain.ProviderQuotaInfo{
+		ProviderID:        p.ID,
+		ProviderName:      p.Name,
+		Period:            cfg.Period,
+		PeriodStart:       start,
+		PeriodEnd:         end,
+		UsedTokens:        tokens,
+		MaxTokens:         cfg.MaxTokens,
+		UsedRequests:      requests,
+		MaxRequests:       cfg.MaxRequests,
+		RemainingTokens:   remaining(cfg.MaxTokens, tokens),
+		RemainingRequests: remaining(cfg.MaxRequests, requests),
+		SoftLimitReached:  soft,
+		Exhausted:         hard,
+	}
+}
+
+// providerQuota returns the provider's quota config, nil when it sets no limit
+func providerQuota(p *domain.Provider) *domain.ProviderQuotaConfig {
+	if p == nil || p.Config == nil || p.Config.Quota == nil {
+		return nil
+	}
+	cfg := p.Config.Quota
+	if cfg.MaxTokens <= 0 && cfg.MaxRequests <= 0 {
+		return nil
+	}
+	return cfg
+}
+
+// quotaReached reports whether usage reached the hard or the soft cap of any limit
+func quotaReached(cfg *domain.ProviderQuotaConfig, tokens, requests int64) (hard, soft bool) {
+	softPercent := int64(cfg.SoftPercent)
+	if softPercent <= 0 || softPercent > 100 {
+		softPercent = DefaultQuotaSoftPercent
+	}
+	check := func(used, limit int64) {
+		if limit <= 0 {
+			return
+		}
+		if used >= limit {
+			hard = true
+		}
+		if used*100 >= limit*softPercent {
+			soft = true
+		}
+	}
+	check(tokens, cfg.MaxTokens)
+	check(requests, cfg.MaxRequests)
+	return hard, soft
+}
+
+// remaining returns limit - used floored at 0, or -1 without a limit
+func remaining(limit, used int64) int64 {
+	if limit <= 0 {
+		return -1
+	}
+	return max(limit-used, 0)
+}
@@ -0,0 +1,173 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestQuotaPeriodBounds(t *testing.T) {
+	shanghai := time.FixedZone("UTC+8", 8*60*60)
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		cfg       domain.ProviderQuotaConfig
+		loc       *time.Location
+		now       time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{
+			name:      "day before reset hour belongs to previous period",
+			cfg:       domain.ProviderQuotaConfig{Period: domain.QuotaPeriodDay, ResetHour: 8},
+			loc:       shanghai,
+			now:       time.Date(2025, 3, 10, 7, 59, 59, 0, shanghai),
+			wantStart: time.Date(2025, 3, 9, 8, 0, 0, 0, shanghai),
+			wantEnd:   time.Date(2025, 3, 10, 8, 0, 0, 0, shanghai),
+		},
+		{
+			name:      "day at reset hour starts new period",
+			cfg:       domain.ProviderQuotaConfig{Period: domain.QuotaPeriodDay, ResetHour: 8},
+			loc:       shanghai,
+			now:       time.Date(2025, 3, 10, 8, 0, 0, 0, shanghai),
+			wantStart: time.Date(2025, 3, 10, 8, 0, 0, 0, shanghai),
+			wantEnd:   time.Date(2025, 3, 11, 8, 0, 0, 0, shanghai),
+		},
+		{
+			name:      "day uses quota timezone, not UTC",
+			cfg:       domain.ProviderQuotaConfig{Period: domain.QuotaPeriodDay},
+			loc:       shanghai,
+			now:       time.Date(2025, 3, 9, 16, 30, 0, 0, time.UTC), // 00:30 on the 10th in UTC+8
+			wantStart: time.Date(2025, 3, 10, 0, 0, 0, 0, shanghai),
+			wantEnd:   time.Date(2025, 3, 11, 0, 0, 0, 0, shanghai),
+		},
+		{
+			name:      "day across DST start is 23 hours long",
+			cfg:       domain.ProviderQuotaConfig{Period: domain.QuotaPeriodDay},
+			loc:       la,
+			now:       time.Date(2025, 3, 9, 12, 0, 0, 0, la),
+			wantStart: time.Date(2025, 3, 9, 0, 0, 0, 0, la),
+			wantEnd:   time.Date(2025, 3, 10, 0, 0, 0, 0, la),
+		},
+		{
+			name:      "week starts on Monday",
+			cfg:       domain.ProviderQuotaConfig{Period: domain.QuotaPeriodWeek},
+			loc:       shanghai,
+			now:       time.Date(2025, 3, 16, 23, 0, 0, 0, shanghai), // Sunday
+			wantStart: time.Date(2025, 3, 10, 0, 0, 0, 0, shanghai),
+			wantEnd:   time.Date(2025, 3, 17, 0, 0, 0, 0, shanghai),
+		},
+		{
+			name:      "Monday before reset hour belongs to previous week",
+			cfg:       domain.ProviderQuotaConfig{Period: domain.QuotaPeriodWeek, ResetHour: 9},
+			loc:       shanghai,
+			now:       time.Date(2025, 3, 17, 8, 0, 0, 0, shanghai),
+			wantStart: time.Date(2025, 3, 10, 9, 0, 0, 0, shanghai),
+			wantEnd:   time.Date(2025, 3, 17, 9, 0, 0, 0, shanghai),
+		},
+		{
+			name:      "first of month before reset hour belongs to previous month",
+			cfg:       domain.ProviderQuotaConfig{Period: domain.QuotaPeriodMonth, ResetHour: 6},
+			loc:       shanghai,
+			now:       time.Date(2025, 3, 1, 5, 0, 0, 0, shanghai),
+			wantStart: time.Date(2025, 2, 1, 6, 0, 0, 0, shanghai),
+			wantEnd:   time.Date(2025, 3, 1, 6, 0, 0, 0, shanghai),
+		},
+		{
+			name:      "month across year boundary",
+			cfg:       domain.ProviderQuotaConfig{Period: domain.QuotaPeriodMonth},
+			loc:       shanghai,
+			now:       time.Date(2025, 12, 31, 23, 59, 0, 0, shanghai),
+			wantStart: time.Date(2025, 12, 1, 0, 0, 0, 0, shanghai),
+			wantEnd:   time.Date(2026, 1, 1, 0, 0, 0, 0, shanghai),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := QuotaPeriodBounds(tt.now, &tt.cfg, tt.loc)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("bounds = [%s, %s), want [%s, %s)", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestQuotaTrackerResetsAtPeriodBoundary(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*60*60)
+	now := time.Date(2025, 3, 10, 7, 0, 0, 0, loc)
+	tracker := NewQuotaTracker()
+	tracker.SetDefaultLocation(loc)
+	tracker.now = func() time.Time { return now }
+
+	p := &domain.Provider{ID: 1, Config: &domain.ProviderConfig{Quota: &domain.ProviderQuotaConfig{
+		Period:    domain.QuotaPeriodDay,
+		MaxTokens: 1000,
+		ResetHour: 8,
+	}}}
+
+	if level, _ := tracker.Record(p, 700); level != QuotaOK {
+		t.Fatalf("700/1000: level = %v, want OK", level)
+	}
+	if level, _ := tracker.Record(p, 100); level != QuotaSoftLimit {
+		t.Fatalf("800/1000: level = %v, want soft limit", level)
+	}
+	if level, _ := tracker.Record(p, 50); level != QuotaOK {
+		t.Fatalf("soft alert must fire once per period, got %v", level)
+	}
+	level, end := tracker.Record(p, 200)
+	if level != QuotaExhausted {
+		t.Fatalf("1050/1000: level = %v, want exhausted", level)
+	}
+	if want := time.Date(2025, 3, 10, 8, 0, 0, 0, loc); !end.Equal(want) {
+		t.Errorf("period end = %s, want %s", end, want)
+	}
+	if info := tracker.Info(p); info.RemainingTokens != 0 || !info.Exhausted || info.UsedRequests != 4 {
+		t.Errorf("info = %+v, want exhausted with 0 remaining after 4 requests", info)
+	}
+
+	// The reset hour starts a fresh period
+	now = time.Date(2025, 3, 10, 8, 0, 0, 0, loc)
+	info := tracker.Info(p)
+	if info.UsedTokens != 0 || info.RemainingTokens != 1000 || info.Exhausted {
+		t.Errorf("after reset: info = %+v, want fresh period", info)
+	}
+	if info.RemainingRequests != -1 {
+		t.Errorf("remaining requests = %d, want -1 (unlimited)", info.RemainingRequests)
+	}
+	if level, _ := tracker.Record(p, 100); level != QuotaOK {
+		t.Errorf("after reset: level = %v, want OK", level)
+	}
+}
+
+func TestQuotaTrackerRequestLimit(t *testing.T) {
+	tracker := NewQuotaTracker()
+	p := &domain.Provider{ID: 2, Config: &domain.ProviderConfig{Quota: &domain.ProviderQuotaConfig{
+		Period:      domain.QuotaPeriodMonth,
+		MaxRequests: 2,
+		SoftPercent: 50,
+	}}}
+
+	if level, _ := tracker.Record(p, 0); level != QuotaSoftLimit {
+		t.Errorf("1/2 requests at 50%%: level = %v, want soft limit", level)
+	}
+	if level, _ := tracker.Record(p, 0); level != QuotaExhausted {
+		t.Errorf("2/2 requests: level = %v, want exhausted", level)
+	}
+}
+
+func TestQuotaTrackerIgnoresProvidersWithoutQuota(t *testing.T) {
+	tracker := NewQuotaTracker()
+	p := &domain.Provider{ID: 3, Config: &domain.ProviderConfig{Quota: &domain.ProviderQuotaConfig{Period: domain.QuotaPeriodDay}}}
+	if level, _ := tracker.Record(p, 1_000_000); level != QuotaOK {
+		t.Errorf("level = %v, want OK without limits", level)
+	}
+	if info := tracker.Info(p); info != nil {
+		t.Errorf("info = %+v, want nil without limits", info)
+	}
+}
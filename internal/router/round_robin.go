@@ -0,0 +1,145 @@
+package router
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+const defaultRoundRobinPersistSecs = 30
+
+// RoundRobinState tracks per-key rotation counters for the round_robin strategy.
+// Counters are persisted periodically so rotation continues across restarts;
+// keys without a persisted counter start at a random position.
+type RoundRobinState struct {
+	mu       sync.Mutex
+	counters map[string]uint64
+	dirty    map[string]bool
+	repo     repository.RoundRobinStateRepository
+}
+
+// NewRoundRobinState creates a rotation state backed by repo (nil keeps state in memory only)
+func NewRoundRobinState(repo repository.RoundRobinStateRepository) *RoundRobinState {
+	return &RoundRobinState{
+		counters: make(map[string]uint64),
+		dirty:    make(map[string]bool),
+		repo:     repo,
+	}
+}
+
+// roundRobinKey builds the rotation key for a project and client type
+func roundRobinKey(projectID uint64, clientType domain.ClientType) string {
+	return fmt.Sprintf("%d:%s", projectID, clientType)
+}
+
+// Load restores persisted counters
+func (s *RoundRobinState) Load() error {
+	if s.repo == nil {
+		return nil
+	}
+	counters, err := s.repo.GetAll()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, counter := range counters {
+		s.counters[key] = counter
+	}
+	return nil
+}
+
+// Next returns the current counter for key and advances it
+func (s *RoundRobinState) Next(key string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.next(key)
+}
+
+// next advances the counter for key, must be called with mu held
+func (s *RoundRobinState) next(key string) uint64 {
+	counter, ok := s.counters[key]
+	if !ok {
+		// Randomize the starting position so the first route doesn't absorb every post-restart burst
+		counter = uint64(rand.Int63())
+	}
+	s.counters[key] = counter + 1
+	s.dirty[key] = true
+	return counter
+}
+
+// Peek returns the counter Next last returned for key without advancing it, so
+// a request matched again keeps its place in the rotation
+func (s *RoundRobinState) Peek(key string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counter, ok := s.counters[key]
+	if !ok {
+		return s.next(key)
+	}
+	return counter - 1
+}
+
+// Flush persists counters changed since the last flush
+func (s *RoundRobinState) Flush() error {
+	if s.repo == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	if len(s.dirty) == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	changed := make(map[string]uint64, len(s.dirty))
+	for key := range s.dirty {
+		changed[key] = s.counters[key]
+	}
+	s.dirty = make(map[string]bool)
+	s.mu.Unlock()
+
+	if err := s.repo.SaveAll(changed); err != nil {
+		// Mark as dirty again so the next flush retries
+		s.mu.Lock()
+		for key := range changed {
+			s.dirty[key] = true
+		}
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// StartPersistence flushes counters periodically, using the interval configured in settings
+func (s *RoundRobinState) StartPersistence(settings repository.SystemSettingRepository) {
+	go func() {
+		for {
+			interval := defaultRoundRobinPersistSecs
+			if settings != nil {
+				if val, err := settings.Get(domain.SettingKeyRoundRobinPersistSecs); err == nil && val != "" {
+					if secs, err := strconv.Atoi(val); err == nil {
+						interval = secs
+					}
+				}
+			}
+			if interval <= 0 {
+				// Disabled, re-check the setting every minute
+				time.Sleep(1 * time.Minute)
+				continue
+			}
+
+			time.Sleep(time.Duration(interval) * time.Second)
+			if err := s.Flush(); err != nil {
+				log.Printf("[Router] Failed to persist round-robin state: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,108 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+type memoryRoundRobinRepo struct {
+	counters map[string]uint64
+}
+
+func (m *memoryRoundRobinRepo) GetAll() (map[string]uint64, error) {
+	result := make(map[string]uint64, len(m.counters))
+	for k, v := range m.counters {
+		result[k] = v
+	}
+	return result, nil
+}
+
+func (m *memoryRoundRobinRepo) SaveAll(counters map[string]uint64) error {
+	for k, v := range counters {
+		m.counters[k] = v
+	}
+	return nil
+}
+
+func TestRoundRobinStateSurvivesRestart(t *testing.T) {
+	repo := &memoryRoundRobinRepo{counters: make(map[string]uint64)}
+	key := roundRobinKey(0, domain.ClientTypeClaude)
+
+	before := NewRoundRobinState(repo)
+	first := before.Next(key)
+	before.Next(key)
+	last := before.Next(key)
+	if last != first+2 {
+		t.Fatalf("counter did not advance: first=%d last=%d", first, last)
+	}
+	if err := before.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// Simulate restart: a new state loads what was persisted
+	after := NewRoundRobinState(repo)
+	if err := after.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := after.Next(key); got != last+1 {
+		t.Errorf("after restart Next = %d, want %d", got, last+1)
+	}
+}
+
+func TestRoundRobinSortRotatesRoutes(t *testing.T) {
+	r := &Router{roundRobin: NewRoundRobinState(nil)}
+	strategy := &domain.RoutingStrategy{Type: domain.RoutingStrategyRoundRobin}
+
+	seen := make(map[uint64]bool)
+	var prev uint64
+	for i := 0; i < 3; i++ {
+		routes := []*domain.Route{
+			{ID: 3, Position: 2},
+			{ID: 1, Position: 0},
+			{ID: 2, Position: 1},
+		}
+		r.sortRoutes(routes, strategy, "0:claude", true)
+
+		head := routes[0].ID
+		if i > 0 && head != prev%3+1 {
+			t.Errorf("rotation %d: head = %d, want %d", i, head, prev%3+1)
+		}
+		// Remaining routes keep position order after the rotation point
+		for j := 1; j < len(routes); j++ {
+			if routes[j].ID != routes[j-1].ID%3+1 {
+				t.Errorf("rotation %d: order broken: %d after %d", i, routes[j].ID, routes[j-1].ID)
+			}
+		}
+		seen[head] = true
+		prev = head
+	}
+	if len(seen) != 3 {
+		t.Errorf("expected every route to lead once, got %v", seen)
+	}
+}
+
+func TestRoundRobinSortKeepsRotationWhenMatchedAgain(t *testing.T) {
+	r := &Router{roundRobin: NewRoundRobinState(nil)}
+	strategy := &domain.RoutingStrategy{Type: domain.RoutingStrategyRoundRobin}
+	newRoutes := func() []*domain.Route {
+		return []*domain.Route{{ID: 1, Position: 0}, {ID: 2, Position: 1}, {ID: 3, Position: 2}}
+	}
+
+	routes := newRoutes()
+	r.sortRoutes(routes, strategy, "0:claude", true)
+	head := routes[0].ID
+	for i := 0; i < 3; i++ {
+		routes = newRoutes()
+		r.sortRoutes(routes, strategy, "0:claude", false)
+		if routes[0].ID != head {
+			t.Fatalf("match again %d: head = %d, want %d", i, routes[0].ID, head)
+		}
+	}
+
+	routes = newRoutes()
+	r.sortRoutes(routes, strategy, "0:claude", true)
+	if want := head%3 + 1; routes[0].ID != want {
+		t.Errorf("next request: head = %d, want %d", routes[0].ID, want)
+	}
+}
@@ -1,14 +1,22 @@
 package router
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
 	"math/rand"
+	"slices"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/awsl-project/maxx/internal/adapter/provider"
 	"github.com/awsl-project/maxx/internal/cooldown"
 	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
 	"github.com/awsl-project/maxx/internal/repository/cached"
+	"github.com/awsl-project/maxx/internal/soak"
 )
 
 // MatchedRoute contains all data needed to execute a proxy request
@@ -25,6 +33,10 @@ type MatchContext struct {
 	ProjectID    uint64
 	RequestModel string
 	APITokenID   uint64
+
+	// ForceProviderID, when set, matches only that provider regardless of routes
+	// and cooldowns (admin debug bypass)
+	ForceProviderID uint64
 }
 
 // Router handles route matching and selection
@@ -41,6 +53,34 @@ type Router struct {
 
 	// Cooldown manager
 	cooldownManager *cooldown.Manager
+
+	// Rotation counters for the round_robin strategy
+	roundRobin *RoundRobinState
+
+	// Rolling per-provider token usage for the tpm strategy
+	tpm *TPMTracker
+
+	// Recent per-provider latency for adaptive timeouts
+	latency *LatencyTracker
+
+	// Recent per-provider success rate and latency for the adaptive route order
+	health *HealthTracker
+
+	// Per-provider usage in the current quota period
+	quota *QuotaTracker
+
+	// Minimum interval between upstream calls of each provider
+	pacer *Pacer
+
+	// Upstream model lists of each provider
+	models *ModelCache
+
+	// Providers alerted for taking an outsized share of cost or requests
+	spendShare *SpendShareMonitor
+
+	// Load and timezone route conditions are evaluated against
+	conditionSource ConditionSource
+	now             func() time.Time
 }
 
 // NewRouter creates a new router
@@ -50,8 +90,9 @@ func NewRouter(
 	routingStrategyRepo *cached.RoutingStrategyRepository,
 	retryConfigRepo *cached.RetryConfigRepository,
 	projectRepo *cached.ProjectRepository,
+	roundRobinRepo repository.RoundRobinStateRepository,
 ) *Router {
-	return &Router{
+	r := &Router{
 		routeRepo:           routeRepo,
 		providerRepo:        providerRepo,
 		routingStrategyRepo: routingStrategyRepo,
@@ -59,7 +100,184 @@ func NewRouter(
 		projectRepo:         projectRepo,
 		adapters:            make(map[uint64]provider.ProviderAdapter),
 		cooldownManager:     cooldown.Default(),
+		roundRobin:          NewRoundRobinState(roundRobinRepo),
+		tpm:                 NewTPMTracker(),
+		latency:             NewLatencyTracker(),
+		health:              NewHealthTracker(),
+		quota:               NewQuotaTracker(),
+		pacer:               NewPacer(),
+		spendShare:          NewSpendShareMonitor(),
+		now:                 time.Now,
+	}
+	r.models = NewModelCache(r.fetchModels, defaultModelCacheTTL)
+	return r
+}
+
+// RoundRobin returns the rotation state used by the round_robin strategy
+func (r *Router) RoundRobin() *RoundRobinState {
+	return r.roundRobin
+}
+
+// TPM returns the token usage tracker used by the tpm strategy
+func (r *Router) TPM() *TPMTracker {
+	return r.tpm
+}
+
+// Latency returns the latency tracker used for adaptive timeouts
+func (r *Router) Latency() *LatencyTracker {
+	return r.latency
+}
+
+// Health returns the recent attempt outcomes of each provider, used by the adaptive route order
+func (r *Router) Health() *HealthTracker {
+	return r.health
+}
+
+// AdaptiveTimeout returns the current adaptive timeout of a provider, 0 when disabled
+func (r *Router) AdaptiveTimeout(p *domain.Provider) time.Duration {
+	if p.Config == nil {
+		return 0
 	}
+	return r.latency.Timeout(p.ID, p.Config.AdaptiveTimeout)
+}
+
+// AdaptiveTimeouts reports the adaptive timeout state of every provider
+func (r *Router) AdaptiveTimeouts() []*domain.AdaptiveTimeoutInfo {
+	providers := r.providerRepo.GetAll()
+	infos := make([]*domain.AdaptiveTimeoutInfo, 0, len(providers))
+	for _, p := range providers {
+		p99, samples := r.latency.P99(p.ID)
+		timeout := r.AdaptiveTimeout(p)
+		infos = append(infos, &domain.AdaptiveTimeoutInfo{
+			ProviderID:   p.ID,
+			ProviderName: p.Name,
+			Enabled:      timeout > 0,
+			Samples:      samples,
+			P99Ms:        p99.Milliseconds(),
+			TimeoutMs:    timeout.Milliseconds(),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ProviderID < infos[j].ProviderID })
+	return infos
+}
+
+// Quota returns the tracker of per-provider quota usage
+func (r *Router) Quota() *QuotaTracker {
+	return r.quota
+}
+
+// RecordQuota counts a finished attempt against the provider's quota and cools
+// the provider until the period resets once the hard cap is reached.
+// It returns the level newly reached so the caller can alert.
+func (r *Router) RecordQuota(p *domain.Provider, tokens int64) QuotaLevel {
+	level, periodEnd := r.quota.Record(p, tokens)
+	if level == QuotaExhausted {
+		r.coolQuotaExhausted(p, periodEnd)
+	}
+	return level
+}
+
+// SyncQuotas refreshes quota usage from usage_stats, cooling providers past their
+// hard cap. onLevel is called for every provider newly reaching a cap.
+func (r *Router) SyncQuotas(usageRepo repository.UsageStatsRepository, onLevel func(p *domain.Provider, level QuotaLevel)) error {
+	return r.quota.Sync(slices.Collect(maps.Values(r.providerRepo.GetAll())), usageRepo, func(p *domain.Provider, level QuotaLevel, periodEnd time.Time) {
+		if level == QuotaExhausted {
+			r.coolQuotaExhausted(p, periodEnd)
+		}
+		if onLevel != nil {
+			onLevel(p, level)
+		}
+	})
+}
+
+// CheckSpendShare computes each provider's share of cost and requests over the
+// configured window and returns alerts for providers newly exceeding the threshold
+func (r *Router) CheckSpendShare(usageRepo repository.UsageStatsRepository, cfg SpendShareConfig) ([]*domain.ProviderSpendAlert, error) {
+	return r.spendShare.Check(slices.Collect(maps.Values(r.providerRepo.GetAll())), usageRepo, cfg)
+}
+
+func (r *Router) coolQuotaExhausted(p *domain.Provider, periodEnd time.Time) {
+	log.Printf("[Router] Provider %d (%s) reached its quota, cooling until %s", p.ID, p.Name, periodEnd.Format(time.RFC3339))
+	r.cooldownManager.RecordFailure(p.ID, "", cooldown.ReasonQuotaExhausted, &periodEnd)
+}
+
+// ProviderNames returns the name of every provider by ID
+func (r *Router) ProviderNames() map[uint64]string {
+	providers := r.providerRepo.GetAll()
+	names := make(map[uint64]string, len(providers))
+	for _, p := range providers {
+		names[p.ID] = p.Name
+	}
+	return names
+}
+
+// Quotas reports the quota usage of every provider that has a quota
+func (r *Router) Quotas() []*domain.ProviderQuotaInfo {
+	providers := r.providerRepo.GetAll()
+	infos := make([]*domain.ProviderQuotaInfo, 0)
+	for _, p := range providers {
+		if info := r.quota.Info(p); info != nil {
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ProviderID < infos[j].ProviderID })
+	return infos
+}
+
+// Pace waits until the provider's minimum interval since its previous upstream
+// call has passed. It returns the context error when ctx ends while waiting.
+func (r *Router) Pace(ctx context.Context, p *domain.Provider) error {
+	if p.Config == nil || p.Config.MinIntervalMs <= 0 {
+		return nil
+	}
+	return r.pacer.Wait(ctx, p.ID, time.Duration(p.Config.MinIntervalMs)*time.Millisecond)
+}
+
+// Pacing reports the current pacing delay of every provider with a minimum interval
+func (r *Router) Pacing() []*domain.ProviderPacingInfo {
+	providers := r.providerRepo.GetAll()
+	infos := make([]*domain.ProviderPacingInfo, 0)
+	for _, p := range providers {
+		if p.Config == nil || p.Config.MinIntervalMs <= 0 {
+			continue
+		}
+		delay, waiting := r.pacer.Delay(p.ID)
+		infos = append(infos, &domain.ProviderPacingInfo{
+			ProviderID:    p.ID,
+			ProviderName:  p.Name,
+			MinIntervalMs: p.Config.MinIntervalMs,
+			DelayMs:       delay.Milliseconds(),
+			Waiting:       waiting,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ProviderID < infos[j].ProviderID })
+	return infos
+}
+
+// Connections reports the upstream connection reuse of every provider that made requests
+func (r *Router) Connections() []*domain.ProviderConnectionInfo {
+	providers := r.providerRepo.GetAll()
+	infos := make([]*domain.ProviderConnectionInfo, 0)
+	for _, p := range providers {
+		stats := provider.ConnStatsFor(p.ID)
+		if stats == nil {
+			continue
+		}
+		requests, reused, dialed := stats.Counts()
+		info := &domain.ProviderConnectionInfo{
+			ProviderID:   p.ID,
+			ProviderName: p.Name,
+			Requests:     requests,
+			Reused:       reused,
+			Dialed:       dialed,
+		}
+		if reused+dialed > 0 {
+			info.ReuseRate = float64(reused) * 100 / float64(reused+dialed)
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ProviderID < infos[j].ProviderID })
+	return infos
 }
 
 // InitAdapters initializes adapters for all providers
@@ -95,6 +313,7 @@ func (r *Router) RefreshAdapter(p *domain.Provider) error {
 	r.mu.Lock()
 	r.adapters[p.ID] = a
 	r.mu.Unlock()
+	r.models.Invalidate(p.ID)
 	return nil
 }
 
@@ -103,69 +322,140 @@ func (r *Router) RemoveAdapter(providerID uint64) {
 	r.mu.Lock()
 	delete(r.adapters, providerID)
 	r.mu.Unlock()
+	r.models.Invalidate(providerID)
 }
 
-// Match returns matched routes for a client type and project
-func (r *Router) Match(ctx *MatchContext) ([]*MatchedRoute, error) {
-	clientType := ctx.ClientType
-	projectID := ctx.ProjectID
-	requestModel := ctx.RequestModel
+// SoakProvider soak tests a provider with synthetic requests through its adapter, see soak.Run
+func (r *Router) SoakProvider(ctx context.Context, providerID uint64, req domain.ProviderSoakRequest) (*domain.ProviderSoakReport, error) {
+	p, ok := r.providerRepo.GetAll()[providerID]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	r.mu.RLock()
+	a, ok := r.adapters[providerID]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("provider %d has no adapter", providerID)
+	}
+	return soak.Run(ctx, a, p, req)
+}
 
-	routes := r.routeRepo.GetAll()
+// fetchModels lists the upstream models of a provider through its adapter
+func (r *Router) fetchModels(ctx context.Context, providerID uint64) ([]domain.ModelInfo, error) {
+	r.mu.RLock()
+	a, ok := r.adapters[providerID]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("provider %d has no adapter", providerID)
+	}
+	return provider.ListModels(ctx, a)
+}
 
-	// Check if ClientType has custom routes enabled for this project
-	useProjectRoutes := false
-	if projectID != 0 {
-		project, err := r.projectRepo.GetByID(projectID)
-		if err == nil && project != nil {
-			// If EnabledCustomRoutes is empty, all ClientTypes use global routes
-			// If EnabledCustomRoutes is not empty, only listed ClientTypes can have custom routes
-			if len(project.EnabledCustomRoutes) > 0 {
-				for _, ct := range project.EnabledCustomRoutes {
-					if ct == clientType {
-						useProjectRoutes = true
-						break
-					}
-				}
-			}
+// Models returns the cached upstream models of a provider and whether they were
+// ever fetched. Stale lists are refreshed in the background, never inline.
+func (r *Router) Models(providerID uint64) ([]domain.ModelInfo, bool) {
+	return r.models.Get(providerID)
+}
+
+// InvalidateModels drops the cached upstream models of a provider, or of all providers when providerID is 0
+func (r *Router) InvalidateModels(providerID uint64) {
+	if providerID == 0 {
+		r.models.InvalidateAll()
+		return
+	}
+	r.models.Invalidate(providerID)
+}
+
+// ModelLists reports the cached upstream models of every provider whose adapter can list them.
+// Missing or expired lists start refreshing in the background.
+func (r *Router) ModelLists() []*domain.ProviderModelsInfo {
+	providers := r.providerRepo.GetAll()
+	r.mu.RLock()
+	listers := make(map[uint64]bool, len(r.adapters))
+	for id, a := range r.adapters {
+		if _, ok := a.(provider.ModelLister); ok {
+			listers[id] = true
 		}
 	}
+	r.mu.RUnlock()
 
-	// Filter routes
-	var filtered []*domain.Route
-	var hasProjectRoutes bool
+	infos := make([]*domain.ProviderModelsInfo, 0)
+	for _, p := range providers {
+		if !listers[p.ID] {
+			continue
+		}
+		r.models.Get(p.ID)
+		info := r.models.Info(p.ID)
+		info.ProviderName = p.Name
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ProviderID < infos[j].ProviderID })
+	return infos
+}
 
-	// Only look for project-specific routes if ClientType is in EnabledCustomRoutes
-	if useProjectRoutes {
-		for _, route := range routes {
-			if !route.IsEnabled {
-				continue
-			}
-			if route.ClientType != clientType {
-				continue
-			}
-			if route.ProjectID == projectID && projectID != 0 {
-				filtered = append(filtered, route)
-				hasProjectRoutes = true
-			}
+// WarmupAdapters concurrently warms up the adapters of all providers used by an
+// enabled route, so the first real request doesn't pay the token refresh inline.
+// Failures are logged and put the provider into cooldown.
+func (r *Router) WarmupAdapters(ctx context.Context, timeout time.Duration) {
+	enabled := make(map[uint64]bool)
+	for _, route := range r.routeRepo.GetAll() {
+		if route.IsEnabled {
+			enabled[route.ProviderID] = true
 		}
 	}
 
-	// If no project-specific routes or ClientType not enabled for custom routes, use global routes
-	if !hasProjectRoutes {
-		for _, route := range routes {
-			if !route.IsEnabled {
-				continue
-			}
-			if route.ClientType != clientType {
-				continue
-			}
-			if route.ProjectID == 0 {
-				filtered = append(filtered, route)
+	r.mu.RLock()
+	adapters := make(map[uint64]provider.ProviderAdapter, len(enabled))
+	for id := range enabled {
+		if a, ok := r.adapters[id]; ok {
+			if _, ok := a.(provider.Warmer); ok {
+				adapters[id] = a
 			}
 		}
 	}
+	r.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for id, a := range adapters {
+		wg.Add(1)
+		go func(id uint64, a provider.ProviderAdapter) {
+			defer wg.Done()
+			warmCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			if err := provider.Warmup(warmCtx, a); err != nil {
+				log.Printf("[Router] Warmup failed for provider %d: %v", id, err)
+				r.cooldownManager.RecordFailure(id, "", cooldown.ReasonUnknown, nil)
+				return
+			}
+			log.Printf("[Router] Warmed up provider %d in %v", id, time.Since(start).Round(time.Millisecond))
+		}(id, a)
+	}
+	wg.Wait()
+}
 
+// Match returns matched routes for a client type and project
+func (r *Router) Match(ctx *MatchContext) ([]*MatchedRoute, error) {
+	return r.match(ctx, true)
+}
+
+// match matches routes, rotate advances the round-robin rotation, which a
+// request matched again while waiting must not do
+func (r *Router) match(ctx *MatchContext, rotate bool) ([]*MatchedRoute, error) {
+	clientType := ctx.ClientType
+	projectID := ctx.ProjectID
+	requestModel := ctx.RequestModel
+
+	if ctx.ForceProviderID != 0 {
+		return r.matchForced(ctx)
+	}
+
+	routes := r.routeRepo.GetAll()
+	filtered, err := filterByAPIToken(r.selectRoutes(routes, clientType, projectID), routes, ctx.APITokenID)
+	if err != nil {
+		return nil, err
+	}
 	if len(filtered) == 0 {
 		return nil, domain.ErrNoRoutes
 	}
@@ -174,7 +464,13 @@ func (r *Router) Match(ctx *MatchContext) ([]*MatchedRoute, error) {
 	strategy := r.getRoutingStrategy(projectID)
 
 	// Sort routes by strategy
-	r.sortRoutes(filtered, strategy)
+	r.sortRoutes(filtered, strategy, roundRobinKey(projectID, clientType), rotate)
+
+	// Prefer cheaper or better providers over the strategy order when the project asks for it
+	filtered = r.applyCostPreference(filtered, projectID, requestModel)
+
+	// Drop or demote the routes whose time of day or load conditions aren't met
+	filtered = r.applyConditions(filtered)
 
 	// Get default retry config
 	defaultRetry, _ := r.retryConfigRepo.GetDefault()
@@ -184,6 +480,7 @@ func (r *Router) Match(ctx *MatchContext) ([]*MatchedRoute, error) {
 	defer r.mu.RUnlock()
 
 	var matched []*MatchedRoute
+	var recoversAt time.Time // earliest end of the cooldowns that skipped a route
 	providers := r.providerRepo.GetAll()
 
 	for _, route := range filtered {
@@ -192,11 +489,6 @@ func (r *Router) Match(ctx *MatchContext) ([]*MatchedRoute, error) {
 			continue
 		}
 
-		// Skip providers in cooldown
-		if r.cooldownManager.IsInCooldown(route.ProviderID, string(clientType)) {
-			continue
-		}
-
 		adp, ok := r.adapters[route.ProviderID]
 		if !ok {
 			continue
@@ -211,6 +503,14 @@ func (r *Router) Match(ctx *MatchContext) ([]*MatchedRoute, error) {
 			}
 		}
 
+		// Skip providers in cooldown
+		if until := r.cooldownManager.GetCooldownUntil(route.ProviderID, string(clientType)); !until.IsZero() {
+			if recoversAt.IsZero() || until.Before(recoversAt) {
+				recoversAt = until
+			}
+			continue
+		}
+
 		var retryConfig *domain.RetryConfig
 		if route.RetryConfigID != 0 {
 			retryConfig, _ = r.retryConfigRepo.GetByID(route.RetryConfigID)
@@ -228,12 +528,74 @@ func (r *Router) Match(ctx *MatchContext) ([]*MatchedRoute, error) {
 	}
 
 	if len(matched) == 0 {
+		if !recoversAt.IsZero() {
+			return nil, &CooldownError{RecoversAt: recoversAt}
+		}
 		return nil, domain.ErrNoRoutes
 	}
 
 	return matched, nil
 }
 
+// selectRoutes returns the enabled routes of a client type that apply to a project:
+// its own routes when the project enables custom routes for the client type, else the global routes
+func (r *Router) selectRoutes(routes []*domain.Route, clientType domain.ClientType, projectID uint64) []*domain.Route {
+	// Check if ClientType has custom routes enabled for this project
+	useProjectRoutes := false
+	if projectID != 0 {
+		project, err := r.projectRepo.GetByID(projectID)
+		if err == nil && project != nil {
+			// If EnabledCustomRoutes is empty, all ClientTypes use global routes
+			// If EnabledCustomRoutes is not empty, only listed ClientTypes can have custom routes
+			if len(project.EnabledCustomRoutes) > 0 {
+				for _, ct := range project.EnabledCustomRoutes {
+					if ct == clientType {
+						useProjectRoutes = true
+						break
+					}
+				}
+			}
+		}
+	}
+
+	// Filter routes
+	var filtered []*domain.Route
+	var hasProjectRoutes bool
+
+	// Only look for project-specific routes if ClientType is in EnabledCustomRoutes
+	if useProjectRoutes {
+		for _, route := range routes {
+			if !route.IsEnabled {
+				continue
+			}
+			if route.ClientType != clientType {
+				continue
+			}
+			if route.ProjectID == projectID && projectID != 0 {
+				filtered = append(filtered, route)
+				hasProjectRoutes = true
+			}
+		}
+	}
+
+	// If no project-specific routes or ClientType not enabled for custom routes, use global routes
+	if !hasProjectRoutes {
+		for _, route := range routes {
+			if !route.IsEnabled {
+				continue
+			}
+			if route.ClientType != clientType {
+				continue
+			}
+			if route.ProjectID == 0 {
+				filtered = append(filtered, route)
+			}
+		}
+	}
+
+	return filtered
+}
+
 // isModelSupported checks if a model matches any pattern in the support list
 func (r *Router) isModelSupported(model string, supportModels []string) bool {
 	for _, pattern := range supportModels {
@@ -259,17 +621,45 @@ func (r *Router) getRoutingStrategy(projectID uint64) *domain.RoutingStrategy {
 	return &domain.RoutingStrategy{Type: domain.RoutingStrategyPriority}
 }
 
-func (r *Router) sortRoutes(routes []*domain.Route, strategy *domain.RoutingStrategy) {
+func (r *Router) sortRoutes(routes []*domain.Route, strategy *domain.RoutingStrategy, rotationKey string, rotate bool) {
 	switch strategy.Type {
 	case domain.RoutingStrategyWeightedRandom:
 		// Shuffle with weights (simplified - just shuffle for now)
 		rand.Shuffle(len(routes), func(i, j int) {
 			routes[i], routes[j] = routes[j], routes[i]
 		})
+	case domain.RoutingStrategyRoundRobin:
+		// Sort by position, then rotate so each request starts at the next route
+		sort.Slice(routes, func(i, j int) bool {
+			return routes[i].Position < routes[j].Position
+		})
+		if len(routes) > 1 {
+			var counter uint64
+			if rotate {
+				counter = r.roundRobin.Next(rotationKey)
+			} else {
+				counter = r.roundRobin.Peek(rotationKey)
+			}
+			offset := int(counter % uint64(len(routes)))
+			rotated := append(append([]*domain.Route{}, routes[offset:]...), routes[:offset]...)
+			copy(routes, rotated)
+		}
+	case domain.RoutingStrategyTPM:
+		// Prefer the provider with the most tokens-per-minute headroom below its cap
+		limits := make(map[uint64]int64)
+		for id, p := range r.providerRepo.GetAll() {
+			if p.Config != nil && p.Config.TPMLimit > 0 {
+				limits[id] = p.Config.TPMLimit
+			}
+		}
+		sortByTPMHeadroom(routes, r.tpm, limits)
 	default: // priority
 		sort.Slice(routes, func(i, j int) bool {
 			return routes[i].Position < routes[j].Position
 		})
+		if adaptiveOrderEnabled(strategy) {
+			adaptiveOrder(routes, r.health.Health)
+		}
 	}
 }
 
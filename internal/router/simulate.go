@@ -0,0 +1,106 @@
+package router
+
+import (
+	"sort"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// SimulateRouting projects how samples would be distributed across providers under
+// the given routes and strategies, using the same route selection as Match.
+// Live state that changes from minute to minute (cooldowns, adapter availability) is
+// ignored; round_robin and weighted_random split each request evenly across the
+// candidates, priority and tpm send it to the first candidate. A project's cost
+// preference narrows the candidates to the tier it tries first.
+// Nothing is written: rotation counters are not advanced.
+func (r *Router) SimulateRouting(routes []*domain.Route, strategies []*domain.RoutingStrategy, samples []*domain.RoutingSignature) *domain.RoutingDistribution {
+	providers := r.providerRepo.GetAll()
+	limits := make(map[uint64]int64)
+	for id, p := range providers {
+		if p.Config != nil && p.Config.TPMLimit > 0 {
+			limits[id] = p.Config.TPMLimit
+		}
+	}
+
+	dist := &domain.RoutingDistribution{Providers: []*domain.ProviderTrafficShare{}}
+	requests := make(map[uint64]float64)
+
+	for _, sample := range samples {
+		count := sample.Count
+		if count <= 0 {
+			count = 1
+		}
+		dist.TotalRequests += count
+
+		var candidates []*domain.Route
+		for _, route := range r.selectRoutes(routes, sample.ClientType, sample.ProjectID) {
+			prov, ok := providers[route.ProviderID]
+			if !ok {
+				continue
+			}
+			if len(prov.SupportModels) > 0 && sample.RequestModel != "" && !r.isModelSupported(sample.RequestModel, prov.SupportModels) {
+				continue
+			}
+			candidates = append(candidates, route)
+		}
+		if len(candidates) == 0 {
+			dist.UnroutedRequests += count
+			continue
+		}
+		if pref := r.costPreference(sample.ProjectID); pref != "" {
+			candidates = preferredTierRoutes(candidates, pref, costTiers(providers, sample.RequestModel))
+		}
+
+		switch findRoutingStrategy(strategies, sample.ProjectID).Type {
+		case domain.RoutingStrategyRoundRobin, domain.RoutingStrategyWeightedRandom:
+			each := float64(count) / float64(len(candidates))
+			for _, route := range candidates {
+				requests[route.ProviderID] += each
+			}
+		case domain.RoutingStrategyTPM:
+			sortByTPMHeadroom(candidates, r.tpm, limits)
+			requests[candidates[0].ProviderID] += float64(count)
+		default: // priority
+			sort.SliceStable(candidates, func(i, j int) bool {
+				return candidates[i].Position < candidates[j].Position
+			})
+			requests[candidates[0].ProviderID] += float64(count)
+		}
+	}
+
+	for id, n := range requests {
+		share := &domain.ProviderTrafficShare{ProviderID: id, Requests: n}
+		if p, ok := providers[id]; ok {
+			share.ProviderName = p.Name
+		}
+		if dist.TotalRequests > 0 {
+			share.Share = n / float64(dist.TotalRequests)
+		}
+		dist.Providers = append(dist.Providers, share)
+	}
+	sort.Slice(dist.Providers, func(i, j int) bool {
+		if dist.Providers[i].Requests != dist.Providers[j].Requests {
+			return dist.Providers[i].Requests > dist.Providers[j].Requests
+		}
+		return dist.Providers[i].ProviderID < dist.Providers[j].ProviderID
+	})
+	return dist
+}
+
+// findRoutingStrategy mirrors getRoutingStrategy over a supplied list:
+// the project's strategy, then the global one, then priority
+func findRoutingStrategy(strategies []*domain.RoutingStrategy, projectID uint64) *domain.RoutingStrategy {
+	var global *domain.RoutingStrategy
+	for _, s := range strategies {
+		if projectID != 0 && s.ProjectID == projectID {
+			return s
+		}
+		if s.ProjectID == 0 && global == nil {
+			global = s
+		}
+	}
+	if global != nil {
+		return global
+	}
+	return &domain.RoutingStrategy{Type: domain.RoutingStrategyPriority}
+}
@@ -0,0 +1,90 @@
+package router
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository/cached"
+	"github.com/awsl-project/maxx/internal/repository/sqlite"
+)
+
+func newSimulationRouter(t *testing.T) *Router {
+	t.Helper()
+	db, err := sqlite.NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	providerRepo := cached.NewProviderRepository(sqlite.NewProviderRepository(db))
+	for _, p := range []*domain.Provider{
+		{Name: "primary", Type: "custom"},
+		{Name: "backup", Type: "custom", SupportModels: []string{"claude-*"}},
+	} {
+		if err := providerRepo.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Router{
+		providerRepo: providerRepo,
+		projectRepo:  cached.NewProjectRepository(sqlite.NewProjectRepository(db)),
+		tpm:          NewTPMTracker(),
+	}
+}
+
+func sharesByProvider(dist *domain.RoutingDistribution) map[uint64]float64 {
+	shares := make(map[uint64]float64)
+	for _, p := range dist.Providers {
+		shares[p.ProviderID] = p.Share
+	}
+	return shares
+}
+
+func TestSimulateRoutingStrategies(t *testing.T) {
+	r := newSimulationRouter(t)
+	routes := []*domain.Route{
+		{ID: 1, IsEnabled: true, ClientType: domain.ClientTypeClaude, ProviderID: 1, Position: 0},
+		{ID: 2, IsEnabled: true, ClientType: domain.ClientTypeClaude, ProviderID: 2, Position: 1},
+	}
+	samples := []*domain.RoutingSignature{
+		{ClientType: domain.ClientTypeClaude, RequestModel: "claude-sonnet", Count: 6},
+		{ClientType: domain.ClientTypeClaude, RequestModel: "other-model", Count: 2},
+		{ClientType: domain.ClientTypeGemini, RequestModel: "gemini-pro", Count: 2},
+	}
+
+	priority := r.SimulateRouting(routes, nil, samples)
+	if priority.TotalRequests != 10 || priority.UnroutedRequests != 2 {
+		t.Fatalf("total/unrouted = %d/%d, want 10/2", priority.TotalRequests, priority.UnroutedRequests)
+	}
+	if shares := sharesByProvider(priority); shares[1] != 0.8 || shares[2] != 0 {
+		t.Errorf("priority shares = %v, want all routed traffic on provider 1", shares)
+	}
+
+	roundRobin := []*domain.RoutingStrategy{{Type: domain.RoutingStrategyRoundRobin}}
+	shares := sharesByProvider(r.SimulateRouting(routes, roundRobin, samples))
+	// claude-sonnet splits 3/3, other-model is only supported by provider 1
+	if shares[1] != 0.5 || shares[2] != 0.3 {
+		t.Errorf("round_robin shares = %v, want 0.5/0.3", shares)
+	}
+
+	disabled := []*domain.Route{routes[1], {ID: 1, IsEnabled: false, ClientType: domain.ClientTypeClaude, ProviderID: 1}}
+	dist := r.SimulateRouting(disabled, nil, samples)
+	if shares := sharesByProvider(dist); shares[2] != 0.6 || dist.UnroutedRequests != 4 {
+		t.Errorf("with primary disabled: shares = %v, unrouted = %d", shares, dist.UnroutedRequests)
+	}
+}
+
+func TestFindRoutingStrategyPrefersProject(t *testing.T) {
+	strategies := []*domain.RoutingStrategy{
+		{ProjectID: 0, Type: domain.RoutingStrategyRoundRobin},
+		{ProjectID: 7, Type: domain.RoutingStrategyTPM},
+	}
+	if got := findRoutingStrategy(strategies, 7).Type; got != domain.RoutingStrategyTPM {
+		t.Errorf("project strategy = %s", got)
+	}
+	if got := findRoutingStrategy(strategies, 8).Type; got != domain.RoutingStrategyRoundRobin {
+		t.Errorf("fallback strategy = %s", got)
+	}
+	if got := findRoutingStrategy(nil, 8).Type; got != domain.RoutingStrategyPriority {
+		t.Errorf("default strategy = %s", got)
+	}
+}
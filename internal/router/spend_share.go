@@ -0,0 +1,151 @@
+package router
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+const (
+	// DefaultSpendShareWindow is the window provider shares are computed over
+	DefaultSpendShareWindow = time.Hour
+	// MaxSpendShareWindow is bounded by the retention of minute usage stats
+	MaxSpendShareWindow = 24 * time.Hour
+	// DefaultSpendShareMinRequests keeps low traffic windows from raising alerts
+	DefaultSpendShareMinRequests = 50
+)
+
+// SpendShareConfig configures the provider spend share check
+type SpendShareConfig struct {
+	Threshold   float64 // share in percent a provider must exceed, 0 disables the check
+	Window      time.Duration
+	MinRequests uint64 // windows with fewer requests in total are not checked
+}
+
+// SpendShareMonitor watches each provider's share of cost and requests over a recent
+// window. An alert fires when a provider crosses the threshold and again only after
+// its share dropped back below it, so a lasting imbalance is reported once.
+type SpendShareMonitor struct {
+	mu      sync.Mutex
+	alerted map[uint64]bool
+	now     func() time.Time
+}
+
+// NewSpendShareMonitor creates a monitor with no provider alerted
+func NewSpendShareMonitor() *SpendShareMonitor {
+	return &SpendShareMonitor{
+		alerted: make(map[uint64]bool),
+		now:     time.Now,
+	}
+}
+
+// Check loads the per-provider usage of the window and evaluates it
+func (m *SpendShareMonitor) Check(providers []*domain.Provider, usageRepo repository.UsageStatsRepository, cfg SpendShareConfig) ([]*domain.ProviderSpendAlert, error) {
+	if cfg.Threshold <= 0 {
+		return nil, nil
+	}
+	end := m.now()
+	start := end.Add(-min(cfg.Window, MaxSpendShareWindow))
+	summaries, err := usageRepo.GetSummaryByProvider(repository.UsageStatsFilter{
+		Granularity: domain.GranularityMinute,
+		StartTime:   &start,
+		EndTime:     &end,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[uint64]string, len(providers))
+	for _, p := range providers {
+		names[p.ID] = p.Name
+	}
+	return m.Evaluate(summaries, names, cfg), nil
+}
+
+// Evaluate computes each provider's share of the window's cost and requests and returns
+// an alert for every provider newly exceeding the threshold on either. With a single
+// configured provider taking everything is expected, so nothing is reported.
+func (m *SpendShareMonitor) Evaluate(summaries map[uint64]*domain.UsageStatsSummary, names map[uint64]string, cfg SpendShareConfig) []*domain.ProviderSpendAlert {
+	if cfg.Threshold <= 0 || len(names) < 2 {
+		return nil
+	}
+
+	shares := ProviderShares(summaries, names)
+	var totalRequests uint64
+	for _, s := range summaries {
+		totalRequests += s.TotalRequests
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if totalRequests < cfg.MinRequests {
+		// Too little traffic to judge, keep the current alert state
+		return nil
+	}
+
+	now := m.now()
+	var alerts []*domain.ProviderSpendAlert
+	over := make(map[uint64]bool)
+	for _, share := range shares {
+		if share.CostShare <= cfg.Threshold && share.RequestShare <= cfg.Threshold {
+			continue
+		}
+		over[share.ProviderID] = true
+		if m.alerted[share.ProviderID] {
+			continue
+		}
+		alerts = append(alerts, &domain.ProviderSpendAlert{
+			ProviderShare: share,
+			Threshold:     cfg.Threshold,
+			WindowMinutes: int(min(cfg.Window, MaxSpendShareWindow) / time.Minute),
+			Shares:        shares,
+			TriggeredAt:   now,
+		})
+	}
+	m.alerted = over
+	return alerts
+}
+
+// ProviderShares returns the cost and request share of every provider with traffic,
+// sorted by cost share and then request share, highest first
+func ProviderShares(summaries map[uint64]*domain.UsageStatsSummary, names map[uint64]string) []domain.ProviderShare {
+	var totalRequests, totalCost uint64
+	for _, s := range summaries {
+		totalRequests += s.TotalRequests
+		totalCost += s.TotalCost
+	}
+
+	shares := make([]domain.ProviderShare, 0, len(summaries))
+	for id, s := range summaries {
+		if s.TotalRequests == 0 && s.TotalCost == 0 {
+			continue
+		}
+		share := domain.ProviderShare{
+			ProviderID:   id,
+			ProviderName: names[id],
+			Requests:     s.TotalRequests,
+			Cost:         s.TotalCost,
+		}
+		if totalRequests > 0 {
+			share.RequestShare = float64(s.TotalRequests) / float64(totalRequests) * 100
+		}
+		if totalCost > 0 {
+			share.CostShare = float64(s.TotalCost) / float64(totalCost) * 100
+		}
+		shares = append(shares, share)
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].CostShare != shares[j].CostShare {
+			return shares[i].CostShare > shares[j].CostShare
+		}
+		if shares[i].RequestShare != shares[j].RequestShare {
+			return shares[i].RequestShare > shares[j].RequestShare
+		}
+		return shares[i].ProviderID < shares[j].ProviderID
+	})
+	return shares
+}
@@ -0,0 +1,100 @@
+package router
+
+import (
+	"math"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+var spendShareNames = map[uint64]string{1: "cheap", 2: "premium", 3: "backup"}
+
+func providerUsage(requests, cost map[uint64]uint64) map[uint64]*domain.UsageStatsSummary {
+	summaries := make(map[uint64]*domain.UsageStatsSummary)
+	for id, n := range requests {
+		summaries[id] = &domain.UsageStatsSummary{TotalRequests: n, TotalCost: cost[id]}
+	}
+	return summaries
+}
+
+func TestProviderShares(t *testing.T) {
+	shares := ProviderShares(providerUsage(
+		map[uint64]uint64{1: 60, 2: 30, 3: 10},
+		map[uint64]uint64{1: 100, 2: 900, 3: 0},
+	), spendShareNames)
+
+	if len(shares) != 3 || shares[0].ProviderID != 2 || shares[1].ProviderID != 1 {
+		t.Fatalf("shares not sorted by cost share: %+v", shares)
+	}
+	if math.Abs(shares[0].CostShare-90) > 1e-9 || math.Abs(shares[0].RequestShare-30) > 1e-9 {
+		t.Errorf("premium share = %.1f%% cost, %.1f%% requests, want 90%%, 30%%", shares[0].CostShare, shares[0].RequestShare)
+	}
+	if shares[0].ProviderName != "premium" {
+		t.Errorf("provider name = %q, want premium", shares[0].ProviderName)
+	}
+}
+
+func TestSpendShareAlertsOnCrossing(t *testing.T) {
+	m := NewSpendShareMonitor()
+	cfg := SpendShareConfig{Threshold: 80, MinRequests: 10}
+
+	// Balanced traffic raises nothing
+	balanced := providerUsage(map[uint64]uint64{1: 50, 2: 50}, map[uint64]uint64{1: 500, 2: 500})
+	if alerts := m.Evaluate(balanced, spendShareNames, cfg); len(alerts) != 0 {
+		t.Fatalf("balanced traffic alerted: %+v", alerts)
+	}
+
+	// Everything moving to the premium provider crosses the threshold on cost
+	skewed := providerUsage(map[uint64]uint64{1: 40, 2: 60}, map[uint64]uint64{1: 100, 2: 900})
+	alerts := m.Evaluate(skewed, spendShareNames, cfg)
+	if len(alerts) != 1 || alerts[0].ProviderID != 2 {
+		t.Fatalf("alerts = %+v, want one for provider 2", alerts)
+	}
+	if alerts[0].Threshold != 80 || len(alerts[0].Shares) != 2 {
+		t.Errorf("alert lacks threshold or shares: %+v", alerts[0])
+	}
+
+	// A lasting imbalance is reported once
+	if alerts := m.Evaluate(skewed, spendShareNames, cfg); len(alerts) != 0 {
+		t.Errorf("ongoing imbalance alerted again: %+v", alerts)
+	}
+
+	// Dropping back below the threshold re-arms the alert
+	m.Evaluate(balanced, spendShareNames, cfg)
+	if alerts := m.Evaluate(skewed, spendShareNames, cfg); len(alerts) != 1 {
+		t.Errorf("re-crossing raised %d alerts, want 1", len(alerts))
+	}
+}
+
+func TestSpendShareRequestShareWithoutCost(t *testing.T) {
+	m := NewSpendShareMonitor()
+	cfg := SpendShareConfig{Threshold: 80, MinRequests: 10}
+
+	// Without pricing the request share alone crosses the threshold
+	usage := providerUsage(map[uint64]uint64{1: 95, 3: 5}, nil)
+	alerts := m.Evaluate(usage, spendShareNames, cfg)
+	if len(alerts) != 1 || alerts[0].ProviderID != 1 || alerts[0].CostShare != 0 {
+		t.Errorf("alerts = %+v, want provider 1 on request share", alerts)
+	}
+}
+
+func TestSpendShareSkipsUnjudgeableWindows(t *testing.T) {
+	skewed := providerUsage(map[uint64]uint64{1: 5, 2: 95}, map[uint64]uint64{1: 5, 2: 95})
+
+	// Too few requests in the window
+	m := NewSpendShareMonitor()
+	if alerts := m.Evaluate(skewed, spendShareNames, SpendShareConfig{Threshold: 80, MinRequests: 1000}); len(alerts) != 0 {
+		t.Errorf("low traffic window alerted: %+v", alerts)
+	}
+
+	// A single configured provider always takes everything
+	single := map[uint64]string{2: "premium"}
+	if alerts := m.Evaluate(skewed, single, SpendShareConfig{Threshold: 80}); len(alerts) != 0 {
+		t.Errorf("single provider alerted: %+v", alerts)
+	}
+
+	// Disabled check
+	if alerts := m.Evaluate(skewed, spendShareNames, SpendShareConfig{}); len(alerts) != 0 {
+		t.Errorf("disabled check alerted: %+v", alerts)
+	}
+}
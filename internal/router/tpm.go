@@ -0,0 +1,104 @@
+package router
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+const (
+	tpmBucketSpan  = 10 * time.Second
+	tpmBucketCount = 6 // 6 x 10s = rolling one-minute window
+)
+
+type tpmWindow struct {
+	slots  [tpmBucketCount]int64 // bucket slot number (unix time / span)
+	tokens [tpmBucketCount]int64
+}
+
+// TPMTracker keeps a rolling per-provider tokens-per-minute count in memory
+// so the tpm strategy can read it on the hot path without touching the database.
+type TPMTracker struct {
+	mu      sync.Mutex
+	windows map[uint64]*tpmWindow
+	now     func() time.Time
+}
+
+// NewTPMTracker creates an empty tracker
+func NewTPMTracker() *TPMTracker {
+	return &TPMTracker{
+		windows: make(map[uint64]*tpmWindow),
+		now:     time.Now,
+	}
+}
+
+// Record adds tokens consumed by a provider
+func (t *TPMTracker) Record(providerID uint64, tokens int64) {
+	if tokens <= 0 {
+		return
+	}
+	slot := t.now().UnixNano() / int64(tpmBucketSpan)
+	idx := int(slot % tpmBucketCount)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w, ok := t.windows[providerID]
+	if !ok {
+		w = &tpmWindow{}
+		t.windows[providerID] = w
+	}
+	if w.slots[idx] != slot {
+		w.slots[idx] = slot
+		w.tokens[idx] = 0
+	}
+	w.tokens[idx] += tokens
+}
+
+// TPM returns the tokens consumed by a provider during the last minute
+func (t *TPMTracker) TPM(providerID uint64) int64 {
+	slot := t.now().UnixNano() / int64(tpmBucketSpan)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w, ok := t.windows[providerID]
+	if !ok {
+		return 0
+	}
+	var total int64
+	for i := range w.slots {
+		if slot-w.slots[i] < tpmBucketCount {
+			total += w.tokens[i]
+		}
+	}
+	return total
+}
+
+// headroom returns the remaining TPM before the provider hits its cap
+// Providers without a cap have unlimited headroom
+func (t *TPMTracker) headroom(providerID uint64, limit int64) int64 {
+	if limit <= 0 {
+		return math.MaxInt64
+	}
+	return limit - t.TPM(providerID)
+}
+
+// sortByTPMHeadroom orders routes by remaining TPM headroom, most first.
+// Routes with equal headroom (e.g. all uncapped) keep position order.
+func sortByTPMHeadroom(routes []*domain.Route, tracker *TPMTracker, limits map[uint64]int64) {
+	headroom := make(map[uint64]int64, len(routes))
+	for _, route := range routes {
+		if _, ok := headroom[route.ProviderID]; !ok {
+			headroom[route.ProviderID] = tracker.headroom(route.ProviderID, limits[route.ProviderID])
+		}
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		hi, hj := headroom[routes[i].ProviderID], headroom[routes[j].ProviderID]
+		if hi != hj {
+			return hi > hj
+		}
+		return routes[i].Position < routes[j].Position
+	})
+}
@@ -0,0 +1,57 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func TestTPMTrackerRollingWindow(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tracker := NewTPMTracker()
+	tracker.now = func() time.Time { return now }
+
+	tracker.Record(1, 1000)
+	now = now.Add(30 * time.Second)
+	tracker.Record(1, 500)
+	if got := tracker.TPM(1); got != 1500 {
+		t.Fatalf("TPM = %d, want 1500", got)
+	}
+
+	// The first record falls out of the one-minute window
+	now = now.Add(40 * time.Second)
+	if got := tracker.TPM(1); got != 500 {
+		t.Errorf("TPM after 70s = %d, want 500", got)
+	}
+}
+
+func TestTPMSelectionShiftsNearCap(t *testing.T) {
+	tracker := NewTPMTracker()
+	limits := map[uint64]int64{1: 10000, 2: 8000}
+
+	order := func() []uint64 {
+		routes := []*domain.Route{
+			{ID: 10, ProviderID: 1, Position: 0},
+			{ID: 20, ProviderID: 2, Position: 1},
+		}
+		sortByTPMHeadroom(routes, tracker, limits)
+		return []uint64{routes[0].ProviderID, routes[1].ProviderID}
+	}
+
+	if got := order(); got[0] != 1 {
+		t.Fatalf("idle: first provider = %d, want 1 (larger cap)", got[0])
+	}
+
+	// Provider 1 approaches its cap, provider 2 now has more headroom
+	tracker.Record(1, 9500)
+	if got := order(); got[0] != 2 {
+		t.Errorf("provider 1 near cap: first provider = %d, want 2", got[0])
+	}
+
+	// Provider 2 catches up and falls behind again
+	tracker.Record(2, 7900)
+	if got := order(); got[0] != 1 {
+		t.Errorf("both loaded: first provider = %d, want 1", got[0])
+	}
+}
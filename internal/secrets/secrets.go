@@ -0,0 +1,233 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// MasterKeyEnvKey holds the master key secrets are encrypted with. Without it
+	// secrets are stored in plaintext.
+	MasterKeyEnvKey = "MAXX_MASTER_KEY"
+	// PreviousMasterKeyEnvKey holds the master key replaced by a rotation, so
+	// secrets still encrypted with it can be read
+	PreviousMasterKeyEnvKey = "MAXX_MASTER_KEY_PREVIOUS"
+
+	// MinMasterKeyLength is the shortest master key accepted
+	MinMasterKeyLength = 16
+
+	// Prefix marks encrypted values: enc:v1:<key id>:<base64 nonce and ciphertext>
+	Prefix = "enc:v1:"
+	// hkdfInfo binds derived keys to their use
+	hkdfInfo = "maxx secrets v1"
+)
+
+var (
+	// ErrDisabled is returned when rotating without a master key configured
+	ErrDisabled = errors.New("secret encryption is not enabled, set " + MasterKeyEnvKey)
+	// ErrUnknownKey is returned for values encrypted with a master key that is not configured
+	ErrUnknownKey = errors.New("secret encrypted with an unknown master key")
+)
+
+// Cipher encrypts secrets with a key derived from a master key
+type Cipher struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// NewCipher derives the encryption key from a master key
+func NewCipher(masterKey string) (*Cipher, error) {
+	if len(masterKey) < MinMasterKeyLength {
+		return nil, fmt.Errorf("master key must be at least %d characters", MinMasterKeyLength)
+	}
+	key, err := hkdf.Key(sha256.New, []byte(masterKey), nil, hkdfInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(key)
+	return &Cipher{id: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+// ID identifies the master key without revealing it
+func (c *Cipher) ID() string {
+	return c.id
+}
+
+func (c *Cipher) encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(c.id))
+	return Prefix + c.id + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *Cipher) decrypt(data string) (string, error) {
+	sealed, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", errors.New("malformed encrypted secret")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(c.id))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted reports whether a stored value is encrypted
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Keyring encrypts with the current master key and decrypts with the current or
+// previous ones. Without a current key values pass through unencrypted.
+type Keyring struct {
+	mu       sync.RWMutex
+	current  *Cipher
+	previous []*Cipher
+}
+
+var defaultKeyring = &Keyring{}
+
+// Default returns the keyring used by the repositories
+func Default() *Keyring {
+	return defaultKeyring
+}
+
+// LoadFromEnv sets the default keyring's keys from MAXX_MASTER_KEY and MAXX_MASTER_KEY_PREVIOUS
+func LoadFromEnv() error {
+	var current *Cipher
+	var previous []*Cipher
+	if key := os.Getenv(MasterKeyEnvKey); key != "" {
+		c, err := NewCipher(key)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", MasterKeyEnvKey, err)
+		}
+		current = c
+	}
+	if key := os.Getenv(PreviousMasterKeyEnvKey); key != "" {
+		c, err := NewCipher(key)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", PreviousMasterKeyEnvKey, err)
+		}
+		previous = append(previous, c)
+	}
+	defaultKeyring.Set(current, previous...)
+	return nil
+}
+
+// Set replaces the keys, a nil current key turns encryption off
+func (k *Keyring) Set(current *Cipher, previous ...*Cipher) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.current = current
+	k.previous = previous
+}
+
+// Enabled reports whether new secrets are encrypted
+func (k *Keyring) Enabled() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current != nil
+}
+
+// KeyID returns the ID of the current master key, empty when encryption is off
+func (k *Keyring) KeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.current == nil {
+		return ""
+	}
+	return k.current.id
+}
+
+// Encrypt encrypts a secret with the current master key. Empty and already
+// encrypted values are returned as they are, as is everything when encryption is off.
+func (k *Keyring) Encrypt(value string) (string, error) {
+	k.mu.RLock()
+	current := k.current
+	k.mu.RUnlock()
+	if current == nil || value == "" || IsEncrypted(value) {
+		return value, nil
+	}
+	return current.encrypt(value)
+}
+
+// Decrypt decrypts a stored secret with the master key it was encrypted with.
+// Plaintext values, stored before encryption was enabled, are returned as they are.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	rest, ok := strings.CutPrefix(value, Prefix)
+	if !ok {
+		return value, nil
+	}
+	id, data, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", errors.New("malformed encrypted secret")
+	}
+	c := k.cipher(id)
+	if c == nil {
+		return "", fmt.Errorf("%w %s", ErrUnknownKey, id)
+	}
+	return c.decrypt(data)
+}
+
+// IsCurrent reports whether a stored value is encrypted with the current master key
+func (k *Keyring) IsCurrent(value string) bool {
+	id := k.KeyID()
+	return id != "" && strings.HasPrefix(value, Prefix+id+":")
+}
+
+func (k *Keyring) cipher(id string) *Cipher {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.current != nil && k.current.id == id {
+		return k.current
+	}
+	for _, c := range k.previous {
+		if c.id == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// Rotate makes masterKey the current key, the replaced key stays available for
+// decryption until the stored secrets are re-encrypted. undo restores the keys
+// in use before the rotation.
+func (k *Keyring) Rotate(masterKey string) (undo func(), err error) {
+	next, err := NewCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.current == nil {
+		return nil, ErrDisabled
+	}
+	if k.current.id == next.id {
+		return nil, errors.New("new master key is the current one")
+	}
+	current, previous := k.current, k.previous
+	k.current = next
+	k.previous = append([]*Cipher{current}, previous...)
+	return func() { k.Set(current, previous...) }, nil
+}
@@ -0,0 +1,94 @@
+package secrets
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestKeyring(t *testing.T, masterKey string) *Keyring {
+	t.Helper()
+	c, err := NewCipher(masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := &Keyring{}
+	k.Set(c)
+	return k
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	k := newTestKeyring(t, "correct horse battery staple")
+	sealed, err := k.Encrypt("sk-provider-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(sealed) || strings.Contains(sealed, "sk-provider-secret") {
+		t.Fatalf("sealed = %q", sealed)
+	}
+	if again, _ := k.Encrypt("sk-provider-secret"); again == sealed {
+		t.Error("encrypting twice gave the same ciphertext")
+	}
+	if plain, err := k.Decrypt(sealed); err != nil || plain != "sk-provider-secret" {
+		t.Errorf("Decrypt = %q, %v", plain, err)
+	}
+
+	// Plaintext stored before encryption was enabled reads as is
+	if plain, err := k.Decrypt("sk-legacy"); err != nil || plain != "sk-legacy" {
+		t.Errorf("Decrypt(plaintext) = %q, %v", plain, err)
+	}
+
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if _, err := k.Decrypt(tampered); err == nil {
+		t.Error("tampered ciphertext decrypted")
+	}
+}
+
+func TestDisabledKeyringPassesThrough(t *testing.T) {
+	k := &Keyring{}
+	if out, err := k.Encrypt("sk-secret"); err != nil || out != "sk-secret" {
+		t.Errorf("Encrypt = %q, %v, want plaintext", out, err)
+	}
+	sealed, _ := newTestKeyring(t, "correct horse battery staple").Encrypt("sk-secret")
+	if _, err := k.Decrypt(sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Decrypt without the key: err = %v, want ErrUnknownKey", err)
+	}
+	if _, err := k.Rotate("another long master key"); !errors.Is(err, ErrDisabled) {
+		t.Errorf("Rotate: err = %v, want ErrDisabled", err)
+	}
+	if _, err := NewCipher("short"); err == nil {
+		t.Error("short master key accepted")
+	}
+}
+
+func TestRotateKeepsPreviousKeyUntilUndone(t *testing.T) {
+	k := newTestKeyring(t, "correct horse battery staple")
+	oldID := k.KeyID()
+	sealed, _ := k.Encrypt("sk-secret")
+
+	if _, err := k.Rotate("correct horse battery staple"); err == nil {
+		t.Error("rotating to the current key accepted")
+	}
+	undo, err := k.Rotate("another long master key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.KeyID() == oldID {
+		t.Fatal("key ID unchanged after rotation")
+	}
+	if plain, err := k.Decrypt(sealed); err != nil || plain != "sk-secret" {
+		t.Errorf("Decrypt with the previous key = %q, %v", plain, err)
+	}
+	resealed, _ := k.Encrypt("sk-secret")
+	if !strings.Contains(resealed, ":"+k.KeyID()+":") {
+		t.Errorf("new secrets not encrypted with the new key: %q", resealed)
+	}
+
+	undo()
+	if k.KeyID() != oldID {
+		t.Error("undo did not restore the previous key")
+	}
+	if _, err := k.Decrypt(resealed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Decrypt after undo: err = %v, want ErrUnknownKey", err)
+	}
+}
@@ -1,17 +1,33 @@
 package service
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/awsl-project/maxx/internal/adapter/client"
+	"github.com/awsl-project/maxx/internal/adapter/provider"
+	"github.com/awsl-project/maxx/internal/capture"
+	"github.com/awsl-project/maxx/internal/conversation"
+	"github.com/awsl-project/maxx/internal/cooldown"
 	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/limiter"
+	"github.com/awsl-project/maxx/internal/logsink"
+	"github.com/awsl-project/maxx/internal/pii"
+	"github.com/awsl-project/maxx/internal/plugin"
+	"github.com/awsl-project/maxx/internal/redaction"
 	"github.com/awsl-project/maxx/internal/repository"
+	"github.com/awsl-project/maxx/internal/retrybudget"
+	"github.com/awsl-project/maxx/internal/retryrule"
+	"github.com/awsl-project/maxx/internal/stats"
 	"github.com/awsl-project/maxx/internal/version"
 )
 
@@ -22,6 +38,55 @@ type ProviderAdapterRefresher interface {
 	RemoveAdapter(providerID uint64)
 }
 
+// AdaptiveTimeoutReporter reports the current adaptive timeout of each provider
+// Implemented by Router, which tracks provider latency
+type AdaptiveTimeoutReporter interface {
+	AdaptiveTimeouts() []*domain.AdaptiveTimeoutInfo
+}
+
+// QuotaReporter reports the quota usage of each provider in its current period
+// Implemented by Router, which tracks quota usage
+type QuotaReporter interface {
+	Quotas() []*domain.ProviderQuotaInfo
+}
+
+// PacingReporter reports the current pacing delay of each provider
+// Implemented by Router, which paces upstream calls
+type PacingReporter interface {
+	Pacing() []*domain.ProviderPacingInfo
+}
+
+// ConnectionReporter reports the upstream connection reuse of each provider
+// Implemented by Router, which owns the provider adapters
+type ConnectionReporter interface {
+	Connections() []*domain.ProviderConnectionInfo
+}
+
+// ModelListCache reports and invalidates the cached upstream model lists of providers
+// Implemented by Router, which owns the model cache
+type ModelListCache interface {
+	ModelLists() []*domain.ProviderModelsInfo
+	InvalidateModels(providerID uint64)
+}
+
+// ProviderSoaker soak tests providers with synthetic requests
+// Implemented by Router, which owns the provider adapters
+type ProviderSoaker interface {
+	SoakProvider(ctx context.Context, providerID uint64, req domain.ProviderSoakRequest) (*domain.ProviderSoakReport, error)
+}
+
+// RoutingSimulator projects traffic distribution under a hypothetical routing config
+// Implemented by Router, which owns the route matching logic
+type RoutingSimulator interface {
+	SimulateRouting(routes []*domain.Route, strategies []*domain.RoutingStrategy, samples []*domain.RoutingSignature) *domain.RoutingDistribution
+}
+
+// AdaptiveRouteOrderer reports the current adaptive order of routes
+// Implemented by Router, which tracks provider health
+type AdaptiveRouteOrderer interface {
+	AdaptiveRoutePositions(routes []*domain.Route) map[uint64]int
+}
+
 // AdminService provides business logic for admin operations
 // Both HTTP handlers and Wails bindings call this service
 type AdminService struct {
@@ -38,8 +103,11 @@ type AdminService struct {
 	modelMappingRepo    repository.ModelMappingRepository
 	usageStatsRepo      repository.UsageStatsRepository
 	responseModelRepo   repository.ResponseModelRepository
+	messageBatchRepo    repository.MessageBatchRepository
+	providerEventRepo   repository.ProviderEventRepository
 	serverAddr          string
 	adapterRefresher    ProviderAdapterRefresher
+	scope               AdminScope
 }
 
 // NewAdminService creates a new admin service
@@ -57,6 +125,8 @@ func NewAdminService(
 	modelMappingRepo repository.ModelMappingRepository,
 	usageStatsRepo repository.UsageStatsRepository,
 	responseModelRepo repository.ResponseModelRepository,
+	messageBatchRepo repository.MessageBatchRepository,
+	providerEventRepo repository.ProviderEventRepository,
 	serverAddr string,
 	adapterRefresher ProviderAdapterRefresher,
 ) *AdminService {
@@ -74,6 +144,8 @@ func NewAdminService(
 		modelMappingRepo:    modelMappingRepo,
 		usageStatsRepo:      usageStatsRepo,
 		responseModelRepo:   responseModelRepo,
+		messageBatchRepo:    messageBatchRepo,
+		providerEventRepo:   providerEventRepo,
 		serverAddr:          serverAddr,
 		adapterRefresher:    adapterRefresher,
 	}
@@ -82,14 +154,59 @@ func NewAdminService(
 // ===== Provider API =====
 
 func (s *AdminService) GetProviders() ([]*domain.Provider, error) {
-	return s.providerRepo.List()
+	providers, err := s.providerRepo.List()
+	if err != nil || !s.scope.IsScoped() {
+		return providers, err
+	}
+	ids, err := s.scopedProviderIDs()
+	if err != nil {
+		return nil, err
+	}
+	scoped := make([]*domain.Provider, 0, len(ids))
+	for _, p := range providers {
+		if ids[p.ID] {
+			scoped = append(scoped, p)
+		}
+	}
+	return scoped, nil
 }
 
 func (s *AdminService) GetProvider(id uint64) (*domain.Provider, error) {
+	if s.scope.IsScoped() {
+		ids, err := s.scopedProviderIDs()
+		if err != nil {
+			return nil, err
+		}
+		if !ids[id] {
+			return nil, domain.ErrNotFound
+		}
+	}
 	return s.providerRepo.GetByID(id)
 }
 
 func (s *AdminService) CreateProvider(provider *domain.Provider) error {
+	if err := validateProviderRedactionRules(provider); err != nil {
+		return err
+	}
+	if err := validateProviderRetryRules(provider); err != nil {
+		return err
+	}
+	if err := validateProviderStatusMapping(provider); err != nil {
+		return err
+	}
+	if err := validateProviderQuota(provider); err != nil {
+		return err
+	}
+	if err := validateProviderCostTier(provider); err != nil {
+		return err
+	}
+	if err := validateProviderTransformPlugin(provider); err != nil {
+		return err
+	}
+	if err := validateProviderCooldownCap(provider); err != nil {
+		return err
+	}
+
 	// Auto-set SupportedClientTypes based on provider type
 	s.autoSetSupportedClientTypes(provider)
 
@@ -104,6 +221,28 @@ func (s *AdminService) CreateProvider(provider *domain.Provider) error {
 }
 
 func (s *AdminService) UpdateProvider(provider *domain.Provider) error {
+	if err := validateProviderRedactionRules(provider); err != nil {
+		return err
+	}
+	if err := validateProviderRetryRules(provider); err != nil {
+		return err
+	}
+	if err := validateProviderStatusMapping(provider); err != nil {
+		return err
+	}
+	if err := validateProviderQuota(provider); err != nil {
+		return err
+	}
+	if err := validateProviderCostTier(provider); err != nil {
+		return err
+	}
+	if err := validateProviderTransformPlugin(provider); err != nil {
+		return err
+	}
+	if err := validateProviderCooldownCap(provider); err != nil {
+		return err
+	}
+
 	// Auto-set SupportedClientTypes based on provider type
 	s.autoSetSupportedClientTypes(provider)
 
@@ -117,6 +256,87 @@ func (s *AdminService) UpdateProvider(provider *domain.Provider) error {
 	return nil
 }
 
+// validateProviderRedactionRules rejects providers with redaction rules that don't compile
+func validateProviderRedactionRules(provider *domain.Provider) error {
+	if provider.Config == nil {
+		return nil
+	}
+	return redaction.Validate(provider.Config.RedactionRules)
+}
+
+// validateProviderRetryRules rejects providers with retry rules that don't compile
+func validateProviderRetryRules(provider *domain.Provider) error {
+	if provider.Config == nil {
+		return nil
+	}
+	return retryrule.Validate(provider.Config.RetryRules)
+}
+
+// validateProviderStatusMapping rejects providers mapping from or to invalid status codes
+func validateProviderStatusMapping(provider *domain.Provider) error {
+	if provider.Config == nil {
+		return nil
+	}
+	return provider.Config.StatusMapping.Validate()
+}
+
+// validateProviderCostTier rejects unknown cost tiers, empty means derived from prices
+func validateProviderCostTier(provider *domain.Provider) error {
+	if provider.Config == nil {
+		return nil
+	}
+	switch provider.Config.CostTier {
+	case "", domain.CostTierEconomy, domain.CostTierStandard, domain.CostTierPremium:
+		return nil
+	}
+	return fmt.Errorf("invalid cost tier %q: must be economy, standard or premium", provider.Config.CostTier)
+}
+
+// validateProviderTransformPlugin rejects transform plugins with limits out of bounds
+// or a file that doesn't load as a plugin
+func validateProviderTransformPlugin(provider *domain.Provider) error {
+	if provider.Config == nil {
+		return nil
+	}
+	return plugin.Validate(provider.Config.TransformPlugin)
+}
+
+// validateProviderCooldownCap rejects negative cooldown caps other than -1 (no cap)
+func validateProviderCooldownCap(provider *domain.Provider) error {
+	if provider.Config == nil || provider.Config.MaxCooldownSecs >= -1 {
+		return nil
+	}
+	return fmt.Errorf("invalid max cooldown %d: must be positive, 0 for the default or -1 for no cap", provider.Config.MaxCooldownSecs)
+}
+
+// validateProviderQuota rejects quota configs with an unknown period, reset hour or timezone
+func validateProviderQuota(provider *domain.Provider) error {
+	if provider.Config == nil || provider.Config.Quota == nil {
+		return nil
+	}
+	q := provider.Config.Quota
+	switch q.Period {
+	case domain.QuotaPeriodDay, domain.QuotaPeriodWeek, domain.QuotaPeriodMonth:
+	default:
+		return fmt.Errorf("invalid quota period %q: must be day, week or month", q.Period)
+	}
+	if q.ResetHour < 0 || q.ResetHour > 23 {
+		return fmt.Errorf("invalid quota reset hour %d: must be between 0 and 23", q.ResetHour)
+	}
+	if q.SoftPercent < 0 || q.SoftPercent > 100 {
+		return fmt.Errorf("invalid quota soft percent %d: must be between 0 and 100", q.SoftPercent)
+	}
+	if q.MaxTokens < 0 || q.MaxRequests < 0 {
+		return errors.New("invalid quota: limits must not be negative")
+	}
+	if q.Timezone != "" {
+		if _, err := time.LoadLocation(q.Timezone); err != nil {
+			return fmt.Errorf("invalid quota timezone %q: %w", q.Timezone, err)
+		}
+	}
+	return nil
+}
+
 func (s *AdminService) DeleteProvider(id uint64) error {
 	// Delete related routes first
 	routes, _ := s.routeRepo.List()
@@ -197,51 +417,181 @@ type ImportResult struct {
 // ===== Route API =====
 
 func (s *AdminService) GetRoutes() ([]*domain.Route, error) {
-	return s.routeRepo.List()
+	routes, err := s.routeRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	if s.scope.IsScoped() {
+		scoped := make([]*domain.Route, 0, len(routes))
+		for _, route := range routes {
+			if s.ownsProject(route.ProjectID) {
+				scoped = append(scoped, route)
+			}
+		}
+		routes = scoped
+	}
+	return s.withAdaptivePositions(routes), nil
+}
+
+// withAdaptivePositions returns copies of routes carrying their current adaptive order
+func (s *AdminService) withAdaptivePositions(routes []*domain.Route) []*domain.Route {
+	orderer, ok := s.adapterRefresher.(AdaptiveRouteOrderer)
+	if !ok {
+		return routes
+	}
+	positions := orderer.AdaptiveRoutePositions(routes)
+	result := make([]*domain.Route, len(routes))
+	for i, route := range routes {
+		// Routes are shared with the repository cache, don't annotate them in place
+		annotated := *route
+		annotated.AdaptivePosition = positions[route.ID]
+		result[i] = &annotated
+	}
+	return result
 }
 
 func (s *AdminService) GetRoute(id uint64) (*domain.Route, error) {
-	return s.routeRepo.GetByID(id)
+	route, err := s.routeRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if !s.ownsProject(route.ProjectID) {
+		return nil, domain.ErrNotFound
+	}
+	return route, nil
 }
 
 func (s *AdminService) CreateRoute(route *domain.Route) error {
+	if s.scope.IsScoped() {
+		route.ProjectID = s.scope.ProjectID
+	}
+	if err := s.checkRouteProvider(route); err != nil {
+		return err
+	}
+	if err := s.validateRouteAPITokens(route); err != nil {
+		return err
+	}
+	if err := route.Conditions.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+	}
 	return s.routeRepo.Create(route)
 }
 
 func (s *AdminService) UpdateRoute(route *domain.Route) error {
+	if err := s.checkRouteOwned(route.ID); err != nil {
+		return err
+	}
+	if s.scope.IsScoped() {
+		route.ProjectID = s.scope.ProjectID
+	}
+	if err := s.checkRouteProvider(route); err != nil {
+		return err
+	}
+	if err := s.validateRouteAPITokens(route); err != nil {
+		return err
+	}
+	if err := route.Conditions.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrInvalidInput, err)
+	}
 	return s.routeRepo.Update(route)
 }
 
+// validateRouteAPITokens checks the tokens a route is pinned to exist and, for a
+// project scoped admin, belong to its project
+func (s *AdminService) validateRouteAPITokens(route *domain.Route) error {
+	for _, id := range route.APITokenIDs {
+		token, err := s.apiTokenRepo.GetByID(id)
+		if err != nil || token == nil || (s.scope.IsScoped() && token.ProjectID != s.scope.ProjectID) {
+			return fmt.Errorf("%w: API token %d not found", domain.ErrInvalidInput, id)
+		}
+	}
+	return nil
+}
+
 func (s *AdminService) BatchUpdateRoutePositions(updates []domain.RoutePositionUpdate) error {
+	for _, u := range updates {
+		if err := s.checkRouteOwned(u.ID); err != nil {
+			return err
+		}
+	}
 	return s.routeRepo.BatchUpdatePositions(updates)
 }
 
 func (s *AdminService) DeleteRoute(id uint64) error {
+	if err := s.checkRouteOwned(id); err != nil {
+		return err
+	}
 	return s.routeRepo.Delete(id)
 }
 
 // ===== Project API =====
 
 func (s *AdminService) GetProjects() ([]*domain.Project, error) {
+	if s.scope.IsScoped() {
+		project, err := s.projectRepo.GetByID(s.scope.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		return []*domain.Project{project}, nil
+	}
 	return s.projectRepo.List()
 }
 
 func (s *AdminService) GetProject(id uint64) (*domain.Project, error) {
+	if !s.ownsProject(id) {
+		return nil, domain.ErrNotFound
+	}
 	return s.projectRepo.GetByID(id)
 }
 
 func (s *AdminService) GetProjectBySlug(slug string) (*domain.Project, error) {
-	return s.projectRepo.GetBySlug(slug)
+	project, err := s.projectRepo.GetBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	if !s.ownsProject(project.ID) {
+		return nil, domain.ErrNotFound
+	}
+	return project, nil
 }
 
 func (s *AdminService) CreateProject(project *domain.Project) error {
+	if err := validateDefaultClientType(project.DefaultClientType); err != nil {
+		return err
+	}
+	if err := validateCostPreference(project.CostPreference); err != nil {
+		return err
+	}
 	return s.projectRepo.Create(project)
 }
 
 func (s *AdminService) UpdateProject(project *domain.Project) error {
+	if err := validateDefaultClientType(project.DefaultClientType); err != nil {
+		return err
+	}
+	if err := validateCostPreference(project.CostPreference); err != nil {
+		return err
+	}
 	return s.projectRepo.Update(project)
 }
 
+// validateDefaultClientType rejects default client types that aren't a known format
+func validateDefaultClientType(clientType domain.ClientType) error {
+	if clientType != "" && client.ParseClientType(string(clientType)) != clientType {
+		return fmt.Errorf("invalid default client type %q", clientType)
+	}
+	return nil
+}
+
+// validateCostPreference rejects unknown cost preferences, empty means none
+func validateCostPreference(pref domain.CostPreference) error {
+	switch pref {
+	case "", domain.CostPreferenceCheapestFirst, domain.CostPreferenceBestFirst:
+		return nil
+	}
+	return fmt.Errorf("invalid cost preference %q: must be cheapest_first or best_first", pref)
+}
+
 func (s *AdminService) DeleteProject(id uint64) error {
 	return s.projectRepo.Delete(id)
 }
@@ -302,6 +652,110 @@ func (s *AdminService) RejectSession(sessionID string) (*domain.Session, error)
 	return session, nil
 }
 
+// ResetSessionResult describes the session state cleared by ResetSession
+type ResetSessionResult struct {
+	Session           *domain.Session `json:"session"`
+	PreviousProjectID uint64          `json:"previousProjectID"`
+	ProjectUnbound    bool            `json:"projectUnbound"`
+	RejectionCleared  bool            `json:"rejectionCleared"`
+}
+
+// ResetSession clears the server-side state accumulated by a session: its
+// project binding and rejection. Requests already in flight keep the project
+// they resolved at start, so an active stream isn't affected. Historical
+// requests keep their recorded project.
+func (s *AdminService) ResetSession(sessionID string) (*ResetSessionResult, error) {
+	session, err := s.sessionRepo.GetBySessionID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ResetSessionResult{
+		Session:           session,
+		PreviousProjectID: session.ProjectID,
+		ProjectUnbound:    session.ProjectID != 0,
+		RejectionCleared:  session.RejectedAt != nil,
+	}
+	if !result.ProjectUnbound && !result.RejectionCleared {
+		return result, nil
+	}
+
+	session.ProjectID = 0
+	session.RejectedAt = nil
+	if err := s.sessionRepo.Update(session); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// maxConversationTurns caps the requests read to reconstruct a session's conversation
+const maxConversationTurns = 1000
+
+// GetSessionConversation reconstructs a session's conversation from its requests,
+// oldest first, with the model each was mapped to and the provider that served it.
+// Bodies are redacted again with the provider's rules, since the request body is
+// captured before the provider is known.
+func (s *AdminService) GetSessionConversation(sessionID string) (*conversation.Conversation, error) {
+	requests, err := s.proxyRequestRepo.ListBySessionID(sessionID, maxConversationTurns)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make([]*domain.ProxyRequest, 0, len(requests))
+	providers := make(map[uint64]*domain.Provider)
+	for _, req := range requests {
+		if !s.ownsProject(req.ProjectID) {
+			continue
+		}
+		var p *domain.Provider
+		if req.ProviderID > 0 {
+			if cached, ok := providers[req.ProviderID]; ok {
+				p = cached
+			} else if p, err = s.providerRepo.GetByID(req.ProviderID); err != nil {
+				p = nil
+			}
+			providers[req.ProviderID] = p
+		}
+		if req.RequestInfo != nil {
+			info := *req.RequestInfo
+			info.Body = redaction.RedactRequest(p, info.Body)
+			req.RequestInfo = &info
+		}
+		if req.ResponseInfo != nil {
+			info := *req.ResponseInfo
+			info.Body = redaction.RedactResponse(p, info.Body)
+			req.ResponseInfo = &info
+		}
+		visible = append(visible, req)
+	}
+	if len(visible) == 0 {
+		return nil, domain.ErrNotFound
+	}
+
+	finalAttempts := make(map[uint64]uint64, len(visible))
+	for _, req := range visible {
+		finalAttempts[req.ID] = req.FinalProxyUpstreamAttemptID
+	}
+	conv := conversation.Build(sessionID, visible)
+	for _, turn := range conv.Turns {
+		if p := providers[turn.ProviderID]; p != nil {
+			turn.ProviderName = p.Name
+		}
+		attempts, err := s.attemptRepo.ListByProxyRequestID(turn.ProxyRequestID)
+		if err != nil {
+			return nil, err
+		}
+		// Hedged and ensemble requests race several attempts, prefer the one that won
+		for _, attempt := range attempts {
+			turn.MappedModel = attempt.MappedModel
+			if attempt.ID == finalAttempts[turn.ProxyRequestID] {
+				break
+			}
+		}
+	}
+	return conv, nil
+}
+
 // ===== RetryConfig API =====
 
 func (s *AdminService) GetRetryConfigs() ([]*domain.RetryConfig, error) {
@@ -360,8 +814,14 @@ type CursorPaginationResult struct {
 	LastID  uint64                 `json:"lastId,omitempty"`
 }
 
-func (s *AdminService) GetProxyRequestsCursor(limit int, before, after uint64) (*CursorPaginationResult, error) {
-	items, err := s.proxyRequestRepo.ListCursor(limit+1, before, after)
+func (s *AdminService) GetProxyRequestsCursor(limit int, before, after uint64, filter *domain.ProxyRequestFilter) (*CursorPaginationResult, error) {
+	if s.scope.IsScoped() {
+		if filter == nil {
+			filter = &domain.ProxyRequestFilter{}
+		}
+		filter.ProjectID = s.scope.ProjectID
+	}
+	items, err := s.proxyRequestRepo.ListCursor(limit+1, before, after, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -384,26 +844,317 @@ func (s *AdminService) GetProxyRequestsCursor(limit int, before, after uint64) (
 	return result, nil
 }
 
+// MessageBatchPage is a cursor page of message batches
+type MessageBatchPage struct {
+	Items   []*domain.MessageBatch `json:"items"`
+	HasMore bool                   `json:"hasMore"`
+	FirstID uint64                 `json:"firstId,omitempty"`
+	LastID  uint64                 `json:"lastId,omitempty"`
+}
+
+// GetMessageBatches lists message batches of all API tokens, newest first
+func (s *AdminService) GetMessageBatches(limit int, before, after uint64) (*MessageBatchPage, error) {
+	items, err := s.messageBatchRepo.List(0, limit+1, before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &MessageBatchPage{Items: items, HasMore: len(items) > limit}
+	if page.HasMore {
+		page.Items = items[:limit]
+	}
+	if len(page.Items) > 0 {
+		page.FirstID = page.Items[0].ID
+		page.LastID = page.Items[len(page.Items)-1].ID
+	}
+	return page, nil
+}
+
+// MessageBatchDetail is a message batch together with its items
+type MessageBatchDetail struct {
+	Batch *domain.MessageBatch       `json:"batch"`
+	Items []*domain.MessageBatchItem `json:"items"`
+}
+
+// GetMessageBatch returns a message batch and the progress of each of its items
+func (s *AdminService) GetMessageBatch(batchID string) (*MessageBatchDetail, error) {
+	batch, err := s.messageBatchRepo.GetByBatchID(batchID)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.messageBatchRepo.ListItems(batch.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &MessageBatchDetail{Batch: batch, Items: items}, nil
+}
+
+// GetProviderTimeouts returns the adaptive timeout diagnostics of all providers
+func (s *AdminService) GetProviderTimeouts() []*domain.AdaptiveTimeoutInfo {
+	if reporter, ok := s.adapterRefresher.(AdaptiveTimeoutReporter); ok {
+		return reporter.AdaptiveTimeouts()
+	}
+	return []*domain.AdaptiveTimeoutInfo{}
+}
+
+// GetProviderPacing returns the pacing diagnostics of providers with a minimum call interval
+func (s *AdminService) GetProviderPacing() []*domain.ProviderPacingInfo {
+	if reporter, ok := s.adapterRefresher.(PacingReporter); ok {
+		return reporter.Pacing()
+	}
+	return []*domain.ProviderPacingInfo{}
+}
+
+// GetProviderConnections returns the upstream connection reuse diagnostics of providers
+func (s *AdminService) GetProviderConnections() []*domain.ProviderConnectionInfo {
+	if reporter, ok := s.adapterRefresher.(ConnectionReporter); ok {
+		return reporter.Connections()
+	}
+	return []*domain.ProviderConnectionInfo{}
+}
+
+// GetProviderModels returns the cached upstream model lists of providers that can list their models,
+// in a scope only those of the providers its routes use
+func (s *AdminService) GetProviderModels() []*domain.ProviderModelsInfo {
+	cache, ok := s.adapterRefresher.(ModelListCache)
+	if !ok {
+		return []*domain.ProviderModelsInfo{}
+	}
+	infos := cache.ModelLists()
+	if !s.scope.IsScoped() {
+		return infos
+	}
+	ids, err := s.scopedProviderIDs()
+	if err != nil {
+		return []*domain.ProviderModelsInfo{}
+	}
+	scoped := make([]*domain.ProviderModelsInfo, 0, len(ids))
+	for _, info := range infos {
+		if ids[info.ProviderID] {
+			scoped = append(scoped, info)
+		}
+	}
+	return scoped
+}
+
+// InvalidateProviderModels drops the cached upstream models of a provider, or of all providers when id is 0
+func (s *AdminService) InvalidateProviderModels(id uint64) {
+	if cache, ok := s.adapterRefresher.(ModelListCache); ok {
+		cache.InvalidateModels(id)
+	}
+}
+
+// SoakProvider sends a capped burst of synthetic requests to a provider and reports
+// their success rate, latency distribution and errors. The requests are kept out
+// of the request log and usage stats.
+func (s *AdminService) SoakProvider(ctx context.Context, id uint64, req domain.ProviderSoakRequest) (*domain.ProviderSoakReport, error) {
+	soaker, ok := s.adapterRefresher.(ProviderSoaker)
+	if !ok {
+		return nil, fmt.Errorf("soak tests are not available")
+	}
+	return soaker.SoakProvider(ctx, id, req)
+}
+
+// maxProviderEvents bounds the events returned by one query
+const maxProviderEvents = 1000
+
+// GetProviderEvents lists the lifecycle events of a provider between since and until,
+// newest first. providerID 0 lists the events of every provider, or in a scope of
+// every provider its routes use.
+func (s *AdminService) GetProviderEvents(providerID uint64, since, until time.Time, limit int) ([]*domain.ProviderEvent, error) {
+	if s.providerEventRepo == nil {
+		return []*domain.ProviderEvent{}, nil
+	}
+	if limit <= 0 || limit > maxProviderEvents {
+		limit = maxProviderEvents
+	}
+	if !s.scope.IsScoped() {
+		return s.providerEventRepo.List(providerID, since, until, limit)
+	}
+
+	ids, err := s.scopedProviderIDs()
+	if err != nil {
+		return nil, err
+	}
+	if providerID > 0 {
+		if !ids[providerID] {
+			return nil, domain.ErrNotFound
+		}
+		return s.providerEventRepo.List(providerID, since, until, limit)
+	}
+	events := []*domain.ProviderEvent{}
+	for id := range ids {
+		list, err := s.providerEventRepo.List(id, since, until, limit)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, list...)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].CreatedAt.After(events[j].CreatedAt)
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// routingSimulationSampleSize is how many recent requests are sampled when a
+// simulation doesn't supply its own samples
+const routingSimulationSampleSize = 1000
+
+// SimulateRouting reports how traffic would be distributed across providers under
+// a proposed routing config, next to the distribution under the live config.
+// Nothing is persisted.
+func (s *AdminService) SimulateRouting(req *domain.RoutingSimulationRequest) (*domain.RoutingSimulation, error) {
+	simulator, ok := s.adapterRefresher.(RoutingSimulator)
+	if !ok {
+		return nil, fmt.Errorf("routing simulation is not available")
+	}
+
+	liveRoutes, err := s.routeRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	liveStrategies, err := s.routingStrategyRepo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	samples := req.Samples
+	if len(samples) == 0 {
+		if samples, err = s.recentRoutingSignatures(); err != nil {
+			return nil, err
+		}
+	}
+
+	routes, strategies := req.Routes, req.Strategies
+	if routes == nil {
+		routes = liveRoutes
+	}
+	if strategies == nil {
+		strategies = liveStrategies
+	}
+
+	return &domain.RoutingSimulation{
+		Projected: simulator.SimulateRouting(routes, strategies, samples),
+		Current:   simulator.SimulateRouting(liveRoutes, liveStrategies, samples),
+	}, nil
+}
+
+// recentRoutingSignatures groups the most recent requests by routing signature
+func (s *AdminService) recentRoutingSignatures() ([]*domain.RoutingSignature, error) {
+	requests, err := s.proxyRequestRepo.ListCursor(routingSimulationSampleSize, 0, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	bySignature := make(map[domain.RoutingSignature]*domain.RoutingSignature)
+	var samples []*domain.RoutingSignature
+	for _, pr := range requests {
+		key := domain.RoutingSignature{ClientType: pr.ClientType, ProjectID: pr.ProjectID, RequestModel: pr.RequestModel}
+		if sample, ok := bySignature[key]; ok {
+			sample.Count++
+			continue
+		}
+		sample := key
+		sample.Count = 1
+		bySignature[key] = &sample
+		samples = append(samples, &sample)
+	}
+	return samples, nil
+}
+
 func (s *AdminService) GetProxyRequestsCount() (int64, error) {
 	return s.proxyRequestRepo.Count()
 }
 
 func (s *AdminService) GetProxyRequest(id uint64) (*domain.ProxyRequest, error) {
-	return s.proxyRequestRepo.GetByID(id)
+	req, err := s.proxyRequestRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if !s.ownsProject(req.ProjectID) {
+		return nil, domain.ErrNotFound
+	}
+	return req, nil
 }
 
 func (s *AdminService) GetActiveProxyRequests() ([]*domain.ProxyRequest, error) {
-	return s.proxyRequestRepo.ListActive()
+	requests, err := s.proxyRequestRepo.ListActive()
+	if err != nil || !s.scope.IsScoped() {
+		return requests, err
+	}
+	scoped := make([]*domain.ProxyRequest, 0, len(requests))
+	for _, req := range requests {
+		if s.ownsProject(req.ProjectID) {
+			scoped = append(scoped, req)
+		}
+	}
+	return scoped, nil
 }
 
 func (s *AdminService) GetProxyUpstreamAttempts(proxyRequestID uint64) ([]*domain.ProxyUpstreamAttempt, error) {
+	if s.scope.IsScoped() {
+		if _, err := s.GetProxyRequest(proxyRequestID); err != nil {
+			return nil, err
+		}
+	}
 	return s.attemptRepo.ListByProxyRequestID(proxyRequestID)
 }
 
 func (s *AdminService) GetProviderStats(clientType string, projectID uint64) (map[uint64]*domain.ProviderStats, error) {
+	if s.scope.IsScoped() {
+		projectID = s.scope.ProjectID
+	}
 	return s.usageStatsRepo.GetProviderStats(clientType, projectID)
 }
 
+// GetProviderReport returns a per-provider comparison over the given window:
+// volume, success rate, latency percentiles, tokens, cost and current cooldown state
+func (s *AdminService) GetProviderReport(window time.Duration) ([]*domain.ProviderReport, error) {
+	stats, err := s.usageStatsRepo.GetProviderReport(time.Now().Add(-window))
+	if err != nil {
+		return nil, err
+	}
+
+	providers, err := s.providerRepo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	// Include every provider, even those without traffic in the window
+	reports := make([]*domain.ProviderReport, 0, len(providers))
+	byID := make(map[uint64]*domain.ProviderReport, len(providers))
+	for _, p := range providers {
+		report, ok := stats[p.ID]
+		if !ok {
+			report = &domain.ProviderReport{ProviderID: p.ID}
+		}
+		report.ProviderName = p.Name
+		report.ProviderType = p.Type
+		reports = append(reports, report)
+		byID[p.ID] = report
+	}
+
+	for key, until := range cooldown.Default().GetAllCooldowns() {
+		report, ok := byID[key.ProviderID]
+		if !ok {
+			continue
+		}
+		report.InCooldown = true
+		report.CooldownClientTypes = append(report.CooldownClientTypes, key.ClientType)
+		if report.CooldownUntil == nil || until.After(*report.CooldownUntil) {
+			u := until
+			report.CooldownUntil = &u
+		}
+	}
+
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].TotalRequests > reports[j].TotalRequests
+	})
+	return reports, nil
+}
+
 // ===== Settings API =====
 
 func (s *AdminService) GetSettings() (map[string]string, error) {
@@ -423,11 +1174,140 @@ func (s *AdminService) GetSetting(key string) (string, error) {
 }
 
 func (s *AdminService) UpdateSetting(key, value string) error {
-	return s.settingRepo.Set(key, value)
+	// Validate before persisting so a bad rule set never reaches the database
+	switch key {
+	case domain.SettingKeyRedactionRules:
+		if _, err := redaction.ParseRules(value); err != nil {
+			return err
+		}
+	case domain.SettingKeyPIIPatterns:
+		if _, err := pii.ParsePatterns(value); err != nil {
+			return err
+		}
+	case domain.SettingKeyCooldownScopes:
+		if _, err := cooldown.ParseScopePolicy(value); err != nil {
+			return err
+		}
+	case domain.SettingKeyRequestLogSink:
+		if err := logsink.ValidateTarget(strings.TrimSpace(value)); err != nil {
+			return err
+		}
+	case domain.SettingKeyCapturePath:
+		if err := capture.ValidatePath(strings.TrimSpace(value)); err != nil {
+			return err
+		}
+	case domain.SettingKeyCaptureSampleRate:
+		if _, err := capture.ParseSampleRate(value); err != nil {
+			return err
+		}
+	case domain.SettingKeyCaptureRedactionRules:
+		if _, err := redaction.ParseRules(value); err != nil {
+			return err
+		}
+	case domain.SettingKeyRetryBudgetTokens, domain.SettingKeyRetryBudgetRatio, domain.SettingKeyRetryBudgetScope:
+		if err := retrybudget.ValidateSetting(key, value); err != nil {
+			return err
+		}
+	case domain.SettingKeyDailyReportTime:
+		if strings.TrimSpace(value) != "" {
+			if _, _, err := stats.ParseReportTime(value); err != nil {
+				return err
+			}
+		}
+	case domain.SettingKeyUpstreamTLSMinVersion, domain.SettingKeyUpstreamTLSCipherSuites:
+		if err := provider.ValidateTLSSetting(key, value); err != nil {
+			return err
+		}
+	case domain.SettingKeyResponseStatusMapping:
+		if _, err := domain.ParseStatusMapping(value); err != nil {
+			return err
+		}
+	case domain.SettingKeyCooldownQueueWait, domain.SettingKeyStatsRealtimeCacheSecs, domain.SettingKeyStreamHeartbeatSecs:
+		if v := strings.TrimSpace(value); v != "" {
+			if secs, err := strconv.Atoi(v); err != nil || secs < 0 {
+				return fmt.Errorf("%s must be a non-negative integer", key)
+			}
+		}
+	case domain.SettingKeyWSCompressionLevel:
+		if v := strings.TrimSpace(value); v != "" {
+			if level, err := strconv.Atoi(v); err != nil || level < 0 || level > 9 {
+				return fmt.Errorf("%s must be an integer between 0 and 9", key)
+			}
+		}
+	}
+	if err := s.settingRepo.Set(key, value); err != nil {
+		return err
+	}
+	switch key {
+	case domain.SettingKeyRedactionRules:
+		return redaction.LoadGlobalRules(value)
+	case domain.SettingKeyPIIDetection, domain.SettingKeyPIIPatterns:
+		return pii.LoadFromSettings(s.settingRepo)
+	case domain.SettingKeyMaxConcurrentRequests, domain.SettingKeyRequestQueueSize, domain.SettingKeyRequestQueueTimeout,
+		domain.SettingKeyMaxRequestBodyMB:
+		limiter.LoadFromSettings(s.settingRepo)
+	case domain.SettingKeyResponseSpillThreshold:
+		provider.LoadSpillThresholdFromSettings(s.settingRepo)
+	case domain.SettingKeyUpstreamMaxIdleConns, domain.SettingKeyUpstreamMaxIdleConnsPerHost, domain.SettingKeyUpstreamIdleConnTimeout:
+		provider.LoadPoolConfigFromSettings(s.settingRepo)
+	case domain.SettingKeyPromptCacheKeySession:
+		client.LoadPromptCacheKeySessionFromSettings(s.settingRepo)
+	case domain.SettingKeyUpstreamTimingCapture:
+		provider.LoadTimingCaptureFromSettings(s.settingRepo)
+	case domain.SettingKeyUpstreamTLSMinVersion, domain.SettingKeyUpstreamTLSCipherSuites:
+		return provider.LoadTLSPolicyFromSettings(s.settingRepo)
+	case domain.SettingKeyCooldownQueueWait:
+		cooldown.LoadQueueFromSettings(s.settingRepo)
+	case domain.SettingKeyCooldownScopes:
+		return cooldown.LoadScopePolicy(value)
+	case domain.SettingKeyRequestLogSink, domain.SettingKeyRequestLogSinkAuth, domain.SettingKeyRequestLogSinkProviders,
+		domain.SettingKeyRequestLogSinkBodyLimit, domain.SettingKeyRequestLogSinkBuffer:
+		return logsink.LoadFromSettings(s.settingRepo)
+	case domain.SettingKeyCapturePath, domain.SettingKeyCaptureMaxSizeMB, domain.SettingKeyCaptureMaxFiles,
+		domain.SettingKeyCaptureSampleRate, domain.SettingKeyCaptureRedactionRules, domain.SettingKeyCaptureBuffer:
+		return capture.LoadFromSettings(s.settingRepo)
+	case domain.SettingKeyRetryBudgetTokens, domain.SettingKeyRetryBudgetRatio, domain.SettingKeyRetryBudgetScope:
+		retrybudget.LoadFromSettings(s.settingRepo)
+	}
+	return nil
 }
 
 func (s *AdminService) DeleteSetting(key string) error {
-	return s.settingRepo.Delete(key)
+	if err := s.settingRepo.Delete(key); err != nil {
+		return err
+	}
+	switch key {
+	case domain.SettingKeyRedactionRules:
+		return redaction.SetGlobalRules(nil)
+	case domain.SettingKeyPIIDetection, domain.SettingKeyPIIPatterns:
+		return pii.LoadFromSettings(s.settingRepo)
+	case domain.SettingKeyMaxConcurrentRequests, domain.SettingKeyRequestQueueSize, domain.SettingKeyRequestQueueTimeout,
+		domain.SettingKeyMaxRequestBodyMB:
+		limiter.LoadFromSettings(s.settingRepo)
+	case domain.SettingKeyResponseSpillThreshold:
+		provider.LoadSpillThresholdFromSettings(s.settingRepo)
+	case domain.SettingKeyUpstreamMaxIdleConns, domain.SettingKeyUpstreamMaxIdleConnsPerHost, domain.SettingKeyUpstreamIdleConnTimeout:
+		provider.LoadPoolConfigFromSettings(s.settingRepo)
+	case domain.SettingKeyPromptCacheKeySession:
+		client.LoadPromptCacheKeySessionFromSettings(s.settingRepo)
+	case domain.SettingKeyUpstreamTimingCapture:
+		provider.LoadTimingCaptureFromSettings(s.settingRepo)
+	case domain.SettingKeyUpstreamTLSMinVersion, domain.SettingKeyUpstreamTLSCipherSuites:
+		return provider.LoadTLSPolicyFromSettings(s.settingRepo)
+	case domain.SettingKeyCooldownQueueWait:
+		cooldown.LoadQueueFromSettings(s.settingRepo)
+	case domain.SettingKeyCooldownScopes:
+		cooldown.SetScopePolicy(nil)
+	case domain.SettingKeyRequestLogSink, domain.SettingKeyRequestLogSinkAuth, domain.SettingKeyRequestLogSinkProviders,
+		domain.SettingKeyRequestLogSinkBodyLimit, domain.SettingKeyRequestLogSinkBuffer:
+		return logsink.LoadFromSettings(s.settingRepo)
+	case domain.SettingKeyCapturePath, domain.SettingKeyCaptureMaxSizeMB, domain.SettingKeyCaptureMaxFiles,
+		domain.SettingKeyCaptureSampleRate, domain.SettingKeyCaptureRedactionRules, domain.SettingKeyCaptureBuffer:
+		return capture.LoadFromSettings(s.settingRepo)
+	case domain.SettingKeyRetryBudgetTokens, domain.SettingKeyRetryBudgetRatio, domain.SettingKeyRetryBudgetScope:
+		retrybudget.LoadFromSettings(s.settingRepo)
+	}
+	return nil
 }
 
 // ===== Proxy Status API =====
@@ -438,6 +1318,24 @@ type ProxyStatus struct {
 	Port    int    `json:"port"`
 	Version string `json:"version"`
 	Commit  string `json:"commit"`
+
+	// Global concurrency limit state: running and queued requests
+	Concurrency limiter.Stats `json:"concurrency"`
+
+	// Usage and remaining quota of providers with a quota
+	Quotas []*domain.ProviderQuotaInfo `json:"quotas"`
+
+	// Delivery state of request records to the external log sink
+	LogSink logsink.Stats `json:"logSink"`
+
+	// Write state of the request/response capture files
+	Capture capture.Stats `json:"capture"`
+
+	// Retry budget state: remaining tokens and refused retries per provider
+	RetryBudget retrybudget.Stats `json:"retryBudget"`
+
+	// Requests waiting for a provider to leave cooldown
+	CooldownQueue cooldown.QueueStats `json:"cooldownQueue"`
 }
 
 func (s *AdminService) GetProxyStatus(r *http.Request) *ProxyStatus {
@@ -480,11 +1378,25 @@ func (s *AdminService) GetProxyStatus(r *http.Request) *ProxyStatus {
 		Running: true,
 		Address: displayAddr,
 		Port:    port,
-		Version: version.Version,
-		Commit:  version.Commit,
+		Version:     version.Version,
+		Commit:      version.Commit,
+		Concurrency: limiter.Global().Stats(),
+		Quotas:      s.GetProviderQuotas(),
+		LogSink:     logsink.Global().Stats(),
+		Capture:     capture.Global().Stats(),
+		RetryBudget: retrybudget.Global().Stats(),
+		CooldownQueue: cooldown.GlobalQueue().Stats(),
 	}
 }
 
+// GetProviderQuotas returns the current quota usage of providers with a quota
+func (s *AdminService) GetProviderQuotas() []*domain.ProviderQuotaInfo {
+	if reporter, ok := s.adapterRefresher.(QuotaReporter); ok {
+		return reporter.Quotas()
+	}
+	return []*domain.ProviderQuotaInfo{}
+}
+
 // ===== Logs API =====
 
 type LogsResult struct {
@@ -535,8 +1447,13 @@ func (s *AdminService) GetAPIToken(id uint64) (*domain.APIToken, error) {
 	return s.apiTokenRepo.GetByID(id)
 }
 
-// CreateAPIToken creates a new API token and returns the plain token (only shown once)
-func (s *AdminService) CreateAPIToken(name, description string, projectID uint64, expiresAt *time.Time) (*domain.APITokenCreateResult, error) {
+// CreateAPIToken creates a new API token and returns the plain token (only shown once).
+// An admin token without a project reaches all data only with globalAdmin set.
+func (s *AdminService) CreateAPIToken(name, description string, projectID uint64, adminAccess, globalAdmin bool, expiresAt *time.Time, defaultClientType domain.ClientType) (*domain.APITokenCreateResult, error) {
+	if err := validateDefaultClientType(defaultClientType); err != nil {
+		return nil, err
+	}
+
 	// Generate token
 	plain, prefix, err := generateAPIToken()
 	if err != nil {
@@ -550,7 +1467,11 @@ func (s *AdminService) CreateAPIToken(name, description string, projectID uint64
 		Description: description,
 		ProjectID:   projectID,
 		IsEnabled:   true,
+		AdminAccess: adminAccess,
+		GlobalAdmin: globalAdmin,
 		ExpiresAt:   expiresAt,
+
+		DefaultClientType: defaultClientType,
 	}
 
 	if err := s.apiTokenRepo.Create(token); err != nil {
@@ -564,6 +1485,9 @@ func (s *AdminService) CreateAPIToken(name, description string, projectID uint64
 }
 
 func (s *AdminService) UpdateAPIToken(token *domain.APIToken) error {
+	if err := validateDefaultClientType(token.DefaultClientType); err != nil {
+		return err
+	}
 	return s.apiTokenRepo.Update(token)
 }
 
@@ -654,12 +1578,85 @@ func (s *AdminService) GetAvailableClientTypes() []domain.ClientType {
 // GetUsageStats queries usage statistics with optional filters
 // Uses QueryWithRealtime to include current period's real-time data
 func (s *AdminService) GetUsageStats(filter repository.UsageStatsFilter) ([]*domain.UsageStats, error) {
+	s.scopeUsageFilter(&filter)
 	return s.usageStatsRepo.QueryWithRealtime(filter)
 }
 
-// GetDashboardData returns all dashboard data in a single query
-func (s *AdminService) GetDashboardData() (*domain.DashboardData, error) {
-	return s.usageStatsRepo.QueryDashboardData()
+// GetUsageStatsChanges returns the persisted usage buckets whose values changed after since,
+// for pollers that keep a local copy. The returned cursor is the since of the next poll.
+// The in-progress minute is only included once the aggregator has persisted it.
+func (s *AdminService) GetUsageStatsChanges(filter repository.UsageStatsFilter, since time.Time) (*domain.UsageStatsChanges, error) {
+	s.scopeUsageFilter(&filter)
+	filter.UpdatedSince = &since
+	buckets, err := s.usageStatsRepo.Query(filter)
+	if err != nil {
+		return nil, err
+	}
+	cursor := since.UnixMilli()
+	for _, b := range buckets {
+		if updated := b.UpdatedAt.UnixMilli(); updated > cursor {
+			cursor = updated
+		}
+	}
+	return &domain.UsageStatsChanges{Buckets: buckets, Cursor: cursor}, nil
+}
+
+// GetDashboardData returns all dashboard data in a single query, for one project
+// when projectID is set. Scoped admins always get their own project's dashboard.
+func (s *AdminService) GetDashboardData(projectID *uint64) (*domain.DashboardData, error) {
+	if s.scope.IsScoped() {
+		projectID = &s.scope.ProjectID
+	}
+	return s.usageStatsRepo.QueryDashboardData(projectID)
+}
+
+// GetModelStats returns per-model success rate and error breakdown
+func (s *AdminService) GetModelStats(filter repository.UsageStatsFilter) ([]*domain.ModelStats, error) {
+	s.scopeUsageFilter(&filter)
+	return s.usageStatsRepo.GetModelStats(filter)
+}
+
+// GetClientAppStats returns usage totals grouped by the client app detected from request headers.
+// Requests without an identifiable app are grouped under "".
+func (s *AdminService) GetClientAppStats(filter repository.UsageStatsFilter) (map[string]*domain.UsageStatsSummary, error) {
+	s.scopeUsageFilter(&filter)
+	return s.usageStatsRepo.GetSummaryByClientApp(filter)
+}
+
+// GetTagStats returns usage totals grouped by the cost allocation tags sent by clients.
+// A request with several tags counts towards each of them, untagged requests are left out.
+func (s *AdminService) GetTagStats(filter repository.UsageStatsFilter) (map[string]*domain.UsageStatsSummary, error) {
+	s.scopeUsageFilter(&filter)
+	return s.usageStatsRepo.GetSummaryByTag(filter)
+}
+
+// GetEmptyResponseStats returns, per provider and client type, how many attempts
+// succeeded upstream without any content. Only pairs with such responses are listed.
+func (s *AdminService) GetEmptyResponseStats(filter repository.UsageStatsFilter) ([]*domain.EmptyResponseStats, error) {
+	s.scopeUsageFilter(&filter)
+	stats, err := s.usageStatsRepo.GetEmptyResponseStats(filter)
+	if err != nil {
+		return nil, err
+	}
+	if stats == nil {
+		stats = []*domain.EmptyResponseStats{}
+	}
+	return stats, nil
+}
+
+// GetUpstreamTimingStats returns, per provider, the average DNS, connect, TLS and
+// first byte times of upstream requests, over attempts captured while
+// upstream_timing_capture was on
+func (s *AdminService) GetUpstreamTimingStats(filter repository.UsageStatsFilter) ([]*domain.UpstreamTimingStats, error) {
+	s.scopeUsageFilter(&filter)
+	stats, err := s.usageStatsRepo.GetUpstreamTimingStats(filter)
+	if err != nil {
+		return nil, err
+	}
+	if stats == nil {
+		stats = []*domain.UpstreamTimingStats{}
+	}
+	return stats, nil
 }
 
 // RecalculateUsageStats clears all usage stats and recalculates from raw data
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+// AdminScope limits the data an admin caller can see and manage.
+// The zero value is unscoped and sees everything.
+type AdminScope struct {
+	// ProjectID restricts access to the routes, requests and stats of one project, 0 means all
+	ProjectID uint64
+}
+
+// IsScoped reports whether the scope restricts access
+func (sc AdminScope) IsScoped() bool {
+	return sc.ProjectID > 0
+}
+
+// WithScope returns a copy of the service restricted to scope.
+// Scoped services only return routes, requests and stats of the scope's project,
+// and only the providers its routes use.
+func (s *AdminService) WithScope(scope AdminScope) *AdminService {
+	scoped := *s
+	scoped.scope = scope
+	return &scoped
+}
+
+// Scope returns the scope the service is restricted to
+func (s *AdminService) Scope() AdminScope {
+	return s.scope
+}
+
+// ownsProject reports whether a record of projectID is visible in the scope
+func (s *AdminService) ownsProject(projectID uint64) bool {
+	return !s.scope.IsScoped() || projectID == s.scope.ProjectID
+}
+
+// checkRouteOwned returns ErrNotFound for routes outside the scope
+func (s *AdminService) checkRouteOwned(id uint64) error {
+	if !s.scope.IsScoped() {
+		return nil
+	}
+	route, err := s.routeRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if !s.ownsProject(route.ProjectID) {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// scopedProviderIDs returns the providers used by the scope's routes
+func (s *AdminService) scopedProviderIDs() (map[uint64]bool, error) {
+	routes, err := s.routeRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[uint64]bool)
+	for _, route := range routes {
+		if s.ownsProject(route.ProjectID) {
+			ids[route.ProviderID] = true
+		}
+	}
+	return ids, nil
+}
+
+// checkRouteProvider returns ErrInvalidInput when a scoped route points at a provider
+// its project's routes don't already use, so a route can't pull in another project's provider
+func (s *AdminService) checkRouteProvider(route *domain.Route) error {
+	if !s.scope.IsScoped() {
+		return nil
+	}
+	ids, err := s.scopedProviderIDs()
+	if err != nil {
+		return err
+	}
+	if !ids[route.ProviderID] {
+		return fmt.Errorf("%w: provider %d not found", domain.ErrInvalidInput, route.ProviderID)
+	}
+	return nil
+}
+
+// scopeUsageFilter restricts a usage stats query to the scope's project
+func (s *AdminService) scopeUsageFilter(filter *repository.UsageStatsFilter) {
+	if s.scope.IsScoped() {
+		projectID := s.scope.ProjectID
+		filter.ProjectID = &projectID
+	}
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/awsl-project/maxx/internal/domain"
@@ -20,6 +22,7 @@ type BackupService struct {
 	apiTokenRepo        repository.APITokenRepository
 	modelMappingRepo    repository.ModelMappingRepository
 	adapterRefresher    ProviderAdapterRefresher
+	database            DatabaseSnapshotter
 }
 
 // NewBackupService creates a new backup service
@@ -55,6 +58,13 @@ type importContext struct {
 	apiTokenNameToID    map[string]uint64
 	// routeKey format: "projectSlug:clientType:providerName"
 	routeKeyToID map[string]uint64
+	// existing model mappings, checked for conflicts with imported ones
+	modelMappings []*domain.ModelMapping
+	// providers created by the import
+	createdProviders []*domain.Provider
+
+	// atomic aborts on the first write failure, which rolls back the import's transaction
+	atomic bool
 }
 
 func newImportContext() *importContext {
@@ -67,6 +77,18 @@ func newImportContext() *importContext {
 	}
 }
 
+// fail records a write failure. In atomic mode it is an error that aborts the import,
+// otherwise a warning and the entity is skipped.
+func (ctx *importContext) fail(result *domain.ImportResult, msg string) bool {
+	if ctx.atomic {
+		result.Success = false
+		result.Errors = append(result.Errors, msg)
+		return true
+	}
+	result.Warnings = append(result.Warnings, msg)
+	return false
+}
+
 // Export exports all configuration data to a backup file
 func (s *BackupService) Export() (*domain.BackupFile, error) {
 	backup := &domain.BackupFile{
@@ -120,6 +142,11 @@ func (s *BackupService) Export() (*domain.BackupFile, error) {
 			Name:                p.Name,
 			Slug:                p.Slug,
 			EnabledCustomRoutes: p.EnabledCustomRoutes,
+			Guardrail:           p.Guardrail,
+			DefaultClientType:   p.DefaultClientType,
+			CostPreference:      p.CostPreference,
+			FallbackResponse:    p.FallbackResponse,
+			DefaultModel:        p.DefaultModel,
 		})
 	}
 
@@ -153,24 +180,7 @@ func (s *BackupService) Export() (*domain.BackupFile, error) {
 		})
 	}
 
-	// 6. Export Routes
-	routes, err := s.routeRepo.List()
-	if err != nil {
-		return nil, fmt.Errorf("failed to export routes: %w", err)
-	}
-	for _, r := range routes {
-		backup.Data.Routes = append(backup.Data.Routes, domain.BackupRoute{
-			IsEnabled:       r.IsEnabled,
-			IsNative:        r.IsNative,
-			ProjectSlug:     projectIDToSlug[r.ProjectID],
-			ClientType:      r.ClientType,
-			ProviderName:    providerIDToName[r.ProviderID],
-			Position:        r.Position,
-			RetryConfigName: retryConfigIDToName[r.RetryConfigID],
-		})
-	}
-
-	// 7. Export APITokens (without token value)
+	// 6. Export APITokens (without token value)
 	tokens, err := s.apiTokenRepo.List()
 	if err != nil {
 		return nil, fmt.Errorf("failed to export api tokens: %w", err)
@@ -183,6 +193,39 @@ func (s *BackupService) Export() (*domain.BackupFile, error) {
 			ProjectSlug: projectIDToSlug[t.ProjectID],
 			IsEnabled:   t.IsEnabled,
 			ExpiresAt:   t.ExpiresAt,
+
+			DefaultClientType: t.DefaultClientType,
+		})
+	}
+
+	// 7. Export Routes
+	routes, err := s.routeRepo.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to export routes: %w", err)
+	}
+	for _, r := range routes {
+		var tokenNames []string
+		for _, id := range r.APITokenIDs {
+			if name, ok := apiTokenIDToName[id]; ok {
+				tokenNames = append(tokenNames, name)
+			}
+		}
+		backup.Data.Routes = append(backup.Data.Routes, domain.BackupRoute{
+			IsEnabled:        r.IsEnabled,
+			IsNative:         r.IsNative,
+			ProjectSlug:      projectIDToSlug[r.ProjectID],
+			ClientType:       r.ClientType,
+			ProviderName:     providerIDToName[r.ProviderID],
+			Position:         r.Position,
+			RetryConfigName:  retryConfigIDToName[r.RetryConfigID],
+			HedgeDelayMs:     r.HedgeDelayMs,
+			FailFast:         r.FailFast,
+			EnsembleSize:     r.EnsembleSize,
+			EnsembleStrategy: r.EnsembleStrategy,
+			APITokenNames:    tokenNames,
+			Conditions:       r.Conditions,
+			FallbackResponse: r.FallbackResponse,
+			DefaultModel:     r.DefaultModel,
 		})
 	}
 
@@ -199,6 +242,9 @@ func (s *BackupService) Export() (*domain.BackupFile, error) {
 			Pattern:      m.Pattern,
 			Target:       m.Target,
 			Priority:     m.Priority,
+			// Weighted targets
+			Targets:       m.Targets,
+			StickySession: m.StickySession,
 		}
 		// Convert IDs to names
 		if m.ProviderID != 0 {
@@ -235,6 +281,9 @@ func (s *BackupService) Import(backup *domain.BackupFile, opts domain.ImportOpti
 	if backup.Version != domain.BackupVersion {
 		return nil, fmt.Errorf("unsupported backup version: %s (expected %s)", backup.Version, domain.BackupVersion)
 	}
+	if err := validateMappingConflictStrategy(opts); err != nil {
+		return nil, err
+	}
 
 	result := domain.NewImportResult()
 	ctx := newImportContext()
@@ -246,7 +295,7 @@ func (s *BackupService) Import(backup *domain.BackupFile, opts domain.ImportOpti
 
 	// Import in dependency order
 	// 1. SystemSettings (no dependencies)
-	s.importSystemSettings(backup.Data.SystemSettings, opts, result)
+	s.importSystemSettings(backup.Data.SystemSettings, opts, result, ctx)
 
 	// 2. RetryConfigs (no dependencies)
 	s.importRetryConfigs(backup.Data.RetryConfigs, opts, result, ctx)
@@ -260,12 +309,12 @@ func (s *BackupService) Import(backup *domain.BackupFile, opts domain.ImportOpti
 	// 5. RoutingStrategies (depends on Projects)
 	s.importRoutingStrategies(backup.Data.RoutingStrategies, opts, result, ctx)
 
-	// 6. Routes (depends on Providers, Projects, RetryConfigs)
-	s.importRoutes(backup.Data.Routes, opts, result, ctx)
-
-	// 7. APITokens (depends on Projects)
+	// 6. APITokens (depends on Projects)
 	s.importAPITokens(backup.Data.APITokens, opts, result, ctx)
 
+	// 7. Routes (depends on Providers, Projects, RetryConfigs, APITokens)
+	s.importRoutes(backup.Data.Routes, opts, result, ctx)
+
 	// 8. ModelMappings (depends on Providers, Projects, Routes, APITokens)
 	s.importModelMappings(backup.Data.ModelMappings, opts, result, ctx)
 
@@ -334,10 +383,17 @@ func (s *BackupService) loadExistingMappings(ctx *importContext) error {
 		ctx.routeKeyToID[key] = r.ID
 	}
 
+	// Load model mappings
+	mappings, err := s.modelMappingRepo.List()
+	if err != nil {
+		return err
+	}
+	ctx.modelMappings = mappings
+
 	return nil
 }
 
-func (s *BackupService) importSystemSettings(settings []domain.BackupSystemSetting, opts domain.ImportOptions, result *domain.ImportResult) {
+func (s *BackupService) importSystemSettings(settings []domain.BackupSystemSetting, opts domain.ImportOptions, result *domain.ImportResult, ctx *importContext) {
 	summary := domain.ImportSummary{}
 
 	for _, bs := range settings {
@@ -349,7 +405,12 @@ func (s *BackupService) importSystemSettings(settings []domain.BackupSystemSetti
 				continue
 			case "overwrite":
 				if !opts.DryRun {
-					s.settingRepo.Set(bs.Key, bs.Value)
+					if err := s.settingRepo.Set(bs.Key, bs.Value); err != nil {
+						if ctx.fail(result, fmt.Sprintf("Failed to import SystemSetting '%s': %v", bs.Key, err)) {
+							return
+						}
+						continue
+					}
 				}
 				summary.Updated++
 			case "error":
@@ -359,7 +420,12 @@ func (s *BackupService) importSystemSettings(settings []domain.BackupSystemSetti
 			}
 		} else {
 			if !opts.DryRun {
-				s.settingRepo.Set(bs.Key, bs.Value)
+				if err := s.settingRepo.Set(bs.Key, bs.Value); err != nil {
+					if ctx.fail(result, fmt.Sprintf("Failed to import SystemSetting '%s': %v", bs.Key, err)) {
+						return
+					}
+					continue
+				}
 			}
 			summary.Imported++
 		}
@@ -400,7 +466,9 @@ func (s *BackupService) importRetryConfigs(configs []domain.BackupRetryConfig, o
 
 		if !opts.DryRun {
 			if err := s.retryConfigRepo.Create(rc); err != nil {
-				result.Warnings = append(result.Warnings, fmt.Sprintf("Failed to import RetryConfig '%s': %v", bc.Name, err))
+				if ctx.fail(result, fmt.Sprintf("Failed to import RetryConfig '%s': %v", bc.Name, err)) {
+					return
+				}
 				continue
 			}
 			ctx.retryConfigNameToID[bc.Name] = rc.ID
@@ -442,11 +510,14 @@ func (s *BackupService) importProviders(providers []domain.BackupProvider, opts
 
 		if !opts.DryRun {
 			if err := s.providerRepo.Create(p); err != nil {
-				result.Warnings = append(result.Warnings, fmt.Sprintf("Failed to import Provider '%s': %v", bp.Name, err))
+				if ctx.fail(result, fmt.Sprintf("Failed to import Provider '%s': %v", bp.Name, err)) {
+					return
+				}
 				continue
 			}
 			ctx.providerNameToID[bp.Name] = p.ID
-			// Refresh adapter
+			ctx.createdProviders = append(ctx.createdProviders, p)
+			// Refresh adapter, a transactional import does it once committed
 			if s.adapterRefresher != nil {
 				s.adapterRefresher.RefreshAdapter(p)
 			}
@@ -481,11 +552,18 @@ func (s *BackupService) importProjects(projects []domain.BackupProject, opts dom
 			Name:                bp.Name,
 			Slug:                bp.Slug,
 			EnabledCustomRoutes: bp.EnabledCustomRoutes,
+			Guardrail:           bp.Guardrail,
+			DefaultClientType:   bp.DefaultClientType,
+			CostPreference:      bp.CostPreference,
+			FallbackResponse:    bp.FallbackResponse,
+			DefaultModel:        bp.DefaultModel,
 		}
 
 		if !opts.DryRun {
 			if err := s.projectRepo.Create(p); err != nil {
-				result.Warnings = append(result.Warnings, fmt.Sprintf("Failed to import Project '%s': %v", bp.Slug, err))
+				if ctx.fail(result, fmt.Sprintf("Failed to import Project '%s': %v", bp.Slug, err)) {
+					return
+				}
 				continue
 			}
 			ctx.projectSlugToID[bp.Slug] = p.ID
@@ -522,7 +600,12 @@ func (s *BackupService) importRoutingStrategies(strategies []domain.BackupRoutin
 				existing.Type = bs.Type
 				existing.Config = bs.Config
 				if !opts.DryRun {
-					s.routingStrategyRepo.Update(existing)
+					if err := s.routingStrategyRepo.Update(existing); err != nil {
+						if ctx.fail(result, fmt.Sprintf("Failed to import RoutingStrategy: %v", err)) {
+							return
+						}
+						continue
+					}
 				}
 				summary.Updated++
 				continue
@@ -541,7 +624,9 @@ func (s *BackupService) importRoutingStrategies(strategies []domain.BackupRoutin
 
 		if !opts.DryRun {
 			if err := s.routingStrategyRepo.Create(rs); err != nil {
-				result.Warnings = append(result.Warnings, fmt.Sprintf("Failed to import RoutingStrategy: %v", err))
+				if ctx.fail(result, fmt.Sprintf("Failed to import RoutingStrategy: %v", err)) {
+					return
+				}
 				continue
 			}
 		}
@@ -580,6 +665,21 @@ func (s *BackupService) importRoutes(routes []domain.BackupRoute, opts domain.Im
 			retryConfigID = ctx.retryConfigNameToID[br.RetryConfigName]
 		}
 
+		// Resolve pinned API tokens, a pinned route must not be opened up to every token
+		var apiTokenIDs []uint64
+		for _, name := range br.APITokenNames {
+			if id, ok := ctx.apiTokenNameToID[name]; ok {
+				apiTokenIDs = append(apiTokenIDs, id)
+			} else {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("Route %s:%s: apiToken '%s' not found", br.ProviderName, br.ClientType, name))
+			}
+		}
+		if len(br.APITokenNames) > 0 && len(apiTokenIDs) == 0 {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Route %s:%s skipped: none of its apiTokens found", br.ProviderName, br.ClientType))
+			summary.Skipped++
+			continue
+		}
+
 		// Check for existing route
 		routeKey := fmt.Sprintf("%s:%s:%s", br.ProviderName, br.ClientType, br.ProjectSlug)
 		if _, exists := ctx.routeKeyToID[routeKey]; exists {
@@ -599,18 +699,28 @@ func (s *BackupService) importRoutes(routes []domain.BackupRoute, opts domain.Im
 		}
 
 		r := &domain.Route{
-			IsEnabled:     br.IsEnabled,
-			IsNative:      br.IsNative,
-			ProjectID:     projectID,
-			ClientType:    br.ClientType,
-			ProviderID:    providerID,
-			Position:      br.Position,
-			RetryConfigID: retryConfigID,
+			IsEnabled:        br.IsEnabled,
+			IsNative:         br.IsNative,
+			ProjectID:        projectID,
+			ClientType:       br.ClientType,
+			ProviderID:       providerID,
+			Position:         br.Position,
+			RetryConfigID:    retryConfigID,
+			HedgeDelayMs:     br.HedgeDelayMs,
+			FailFast:         br.FailFast,
+			EnsembleSize:     br.EnsembleSize,
+			EnsembleStrategy: br.EnsembleStrategy,
+			APITokenIDs:      apiTokenIDs,
+			Conditions:       br.Conditions,
+			FallbackResponse: br.FallbackResponse,
+			DefaultModel:     br.DefaultModel,
 		}
 
 		if !opts.DryRun {
 			if err := s.routeRepo.Create(r); err != nil {
-				result.Warnings = append(result.Warnings, fmt.Sprintf("Failed to import Route: %v", err))
+				if ctx.fail(result, fmt.Sprintf("Failed to import Route: %v", err)) {
+					return
+				}
 				continue
 			}
 			ctx.routeKeyToID[routeKey] = r.ID
@@ -656,7 +766,9 @@ func (s *BackupService) importAPITokens(tokens []domain.BackupAPIToken, opts dom
 		// Generate new token
 		plain, prefix, err := generateAPIToken()
 		if err != nil {
-			result.Warnings = append(result.Warnings, fmt.Sprintf("Failed to generate token for '%s': %v", bt.Name, err))
+			if ctx.fail(result, fmt.Sprintf("Failed to generate token for '%s': %v", bt.Name, err)) {
+				return
+			}
 			continue
 		}
 
@@ -668,11 +780,15 @@ func (s *BackupService) importAPITokens(tokens []domain.BackupAPIToken, opts dom
 			ProjectID:   projectID,
 			IsEnabled:   bt.IsEnabled,
 			ExpiresAt:   bt.ExpiresAt,
+
+			DefaultClientType: bt.DefaultClientType,
 		}
 
 		if !opts.DryRun {
 			if err := s.apiTokenRepo.Create(t); err != nil {
-				result.Warnings = append(result.Warnings, fmt.Sprintf("Failed to import APIToken '%s': %v", bt.Name, err))
+				if ctx.fail(result, fmt.Sprintf("Failed to import APIToken '%s': %v", bt.Name, err)) {
+					return
+				}
 				continue
 			}
 			ctx.apiTokenNameToID[bt.Name] = t.ID
@@ -688,70 +804,142 @@ func (s *BackupService) importModelMappings(mappings []domain.BackupModelMapping
 	summary := domain.ImportSummary{}
 
 	for _, bm := range mappings {
-		// Resolve IDs
-		var providerID, projectID, routeID, apiTokenID uint64
-
-		if bm.ProviderName != "" {
-			var ok bool
-			providerID, ok = ctx.providerNameToID[bm.ProviderName]
-			if !ok {
-				result.Warnings = append(result.Warnings, fmt.Sprintf("ModelMapping skipped: provider '%s' not found", bm.ProviderName))
-				summary.Skipped++
-				continue
-			}
+		m, missing := ctx.resolveModelMapping(bm)
+		if missing != "" {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("ModelMapping skipped: %s not found", missing))
+			summary.Skipped++
+			continue
 		}
 
-		if bm.ProjectSlug != "" {
-			var ok bool
-			projectID, ok = ctx.projectSlugToID[bm.ProjectSlug]
-			if !ok {
-				result.Warnings = append(result.Warnings, fmt.Sprintf("ModelMapping skipped: project '%s' not found", bm.ProjectSlug))
+		name := fmt.Sprintf("%s -> %s", bm.Pattern, bm.Target)
+		replaced := false
+		if conflicts := mappingConflicts(m, ctx.modelMappings); len(conflicts) > 0 {
+			switch opts.MappingConflicts() {
+			case domain.MappingConflictExisting:
+				result.Warnings = append(result.Warnings, fmt.Sprintf("ModelMapping '%s' skipped: conflicts with existing %s", name, describeMappings(conflicts)))
 				summary.Skipped++
 				continue
+			case domain.MappingConflictError:
+				result.Success = false
+				result.Errors = append(result.Errors, fmt.Sprintf("ModelMapping conflict: '%s' conflicts with existing %s", name, describeMappings(conflicts)))
+				return
 			}
-		}
 
-		if bm.RouteName != "" {
-			var ok bool
-			routeID, ok = ctx.routeKeyToID[bm.RouteName]
-			if !ok {
-				result.Warnings = append(result.Warnings, fmt.Sprintf("ModelMapping skipped: route '%s' not found", bm.RouteName))
-				summary.Skipped++
-				continue
+			// Incoming wins: the conflicting mappings are deleted
+			if !opts.DryRun {
+				for _, existing := range conflicts {
+					if err := s.modelMappingRepo.Delete(existing.ID); err != nil {
+						if ctx.fail(result, fmt.Sprintf("Failed to replace ModelMapping '%s': %v", name, err)) {
+							return
+						}
+						continue
+					}
+				}
 			}
+			ctx.modelMappings = slices.DeleteFunc(ctx.modelMappings, func(existing *domain.ModelMapping) bool {
+				return slices.Contains(conflicts, existing)
+			})
+			result.Warnings = append(result.Warnings, fmt.Sprintf("ModelMapping '%s' replaced conflicting %s", name, describeMappings(conflicts)))
+			replaced = true
 		}
 
-		if bm.APITokenName != "" {
-			var ok bool
-			apiTokenID, ok = ctx.apiTokenNameToID[bm.APITokenName]
-			if !ok {
-				result.Warnings = append(result.Warnings, fmt.Sprintf("ModelMapping skipped: apiToken '%s' not found", bm.APITokenName))
-				summary.Skipped++
+		if !opts.DryRun {
+			if err := s.modelMappingRepo.Create(m); err != nil {
+				if ctx.fail(result, fmt.Sprintf("Failed to import ModelMapping: %v", err)) {
+					return
+				}
 				continue
 			}
 		}
+		if replaced {
+			summary.Updated++
+		} else {
+			summary.Imported++
+		}
+	}
+
+	result.Summary["modelMappings"] = summary
+}
+
+// resolveModelMapping builds the mapping to import, resolving its references by name.
+// missing names the reference that doesn't resolve, if any.
+func (ctx *importContext) resolveModelMapping(bm domain.BackupModelMapping) (m *domain.ModelMapping, missing string) {
+	m = &domain.ModelMapping{
+		Scope:        bm.Scope,
+		ClientType:   bm.ClientType,
+		ProviderType: bm.ProviderType,
+		Pattern:      bm.Pattern,
+		Target:       bm.Target,
+		Priority:     bm.Priority,
+		// Weighted targets
+		Targets:       bm.Targets,
+		StickySession: bm.StickySession,
+	}
+	if m.Scope == "" {
+		m.Scope = domain.ModelMappingScopeGlobal
+	}
 
-		m := &domain.ModelMapping{
-			Scope:        bm.Scope,
-			ClientType:   bm.ClientType,
-			ProviderType: bm.ProviderType,
-			ProviderID:   providerID,
-			ProjectID:    projectID,
-			RouteID:      routeID,
-			APITokenID:   apiTokenID,
-			Pattern:      bm.Pattern,
-			Target:       bm.Target,
-			Priority:     bm.Priority,
+	var ok bool
+	if bm.ProviderName != "" {
+		if m.ProviderID, ok = ctx.providerNameToID[bm.ProviderName]; !ok {
+			return nil, fmt.Sprintf("provider '%s'", bm.ProviderName)
+		}
+	}
+	if bm.ProjectSlug != "" {
+		if m.ProjectID, ok = ctx.projectSlugToID[bm.ProjectSlug]; !ok {
+			return nil, fmt.Sprintf("project '%s'", bm.ProjectSlug)
+		}
+	}
+	if bm.RouteName != "" {
+		if m.RouteID, ok = ctx.routeKeyToID[bm.RouteName]; !ok {
+			return nil, fmt.Sprintf("route '%s'", bm.RouteName)
+		}
+	}
+	if bm.APITokenName != "" {
+		if m.APITokenID, ok = ctx.apiTokenNameToID[bm.APITokenName]; !ok {
+			return nil, fmt.Sprintf("apiToken '%s'", bm.APITokenName)
 		}
+	}
+	return m, ""
+}
 
-		if !opts.DryRun {
-			if err := s.modelMappingRepo.Create(m); err != nil {
-				result.Warnings = append(result.Warnings, fmt.Sprintf("Failed to import ModelMapping: %v", err))
-				continue
-			}
+// mappingConflicts returns the existing mappings an imported one conflicts with:
+// same scope, patterns that can match the same model and a different target.
+// An identical rule changes nothing and is not a conflict.
+func mappingConflicts(m *domain.ModelMapping, existing []*domain.ModelMapping) []*domain.ModelMapping {
+	var conflicts []*domain.ModelMapping
+	for _, e := range existing {
+		if e.Scope != m.Scope || e.ClientType != m.ClientType || e.ProviderType != m.ProviderType ||
+			e.ProviderID != m.ProviderID || e.ProjectID != m.ProjectID || e.RouteID != m.RouteID || e.APITokenID != m.APITokenID {
+			continue
 		}
-		summary.Imported++
+		if !domain.ModelMappingPatternsOverlap(e.Pattern, m.Pattern) {
+			continue
+		}
+		if e.Target == m.Target && slices.Equal(e.Targets, m.Targets) {
+			continue
+		}
+		conflicts = append(conflicts, e)
+	}
+	return conflicts
+}
+
+// describeMappings names mappings for conflict reports
+func describeMappings(mappings []*domain.ModelMapping) string {
+	names := make([]string, 0, len(mappings))
+	for _, m := range mappings {
+		names = append(names, fmt.Sprintf("'%s -> %s' (#%d)", m.Pattern, m.Target, m.ID))
+	}
+	if len(names) == 1 {
+		return "mapping " + names[0]
 	}
+	return "mappings " + strings.Join(names, ", ")
+}
 
-	result.Summary["modelMappings"] = summary
+func validateMappingConflictStrategy(opts domain.ImportOptions) error {
+	switch opts.MappingConflictStrategy {
+	case "", domain.MappingConflictIncoming, domain.MappingConflictExisting, domain.MappingConflictError:
+		return nil
+	}
+	return fmt.Errorf("unsupported model mapping conflict strategy: %s", opts.MappingConflictStrategy)
 }
@@ -0,0 +1,526 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/awsl-project/maxx/internal/adapter/client"
+	"github.com/awsl-project/maxx/internal/adapter/provider"
+	"github.com/awsl-project/maxx/internal/capture"
+	"github.com/awsl-project/maxx/internal/cooldown"
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/limiter"
+	"github.com/awsl-project/maxx/internal/logsink"
+	"github.com/awsl-project/maxx/internal/pii"
+	"github.com/awsl-project/maxx/internal/redaction"
+	"github.com/awsl-project/maxx/internal/repository"
+	"github.com/awsl-project/maxx/internal/retrybudget"
+)
+
+var (
+	// ErrImportPlanChanged is returned when the confirmed fingerprint no longer matches the current plan
+	ErrImportPlanChanged = errors.New("import plan changed since it was reviewed")
+	// ErrImportPlanInvalid is returned when applying a plan that contains conflicts or errors
+	ErrImportPlanInvalid = errors.New("import plan has conflicts or errors")
+
+	// errImportAborted rolls back the import transaction after a step failed
+	errImportAborted = errors.New("import aborted")
+)
+
+// importPlanner accumulates plan items for a config import
+type importPlanner struct {
+	plan     *domain.ImportPlan
+	strategy string
+}
+
+func (p *importPlanner) add(kind, name, action, message string) {
+	p.plan.Items = append(p.plan.Items, domain.ImportPlanItem{
+		Kind:    kind,
+		Name:    name,
+		Action:  action,
+		Message: message,
+	})
+	p.plan.Summary[action]++
+	if action == domain.ImportActionConflict || action == domain.ImportActionError {
+		p.plan.Valid = false
+	}
+}
+
+// existing records an entity that already exists, following the conflict strategy
+func (p *importPlanner) existing(kind, name string, updatable bool) {
+	switch p.strategy {
+	case "overwrite":
+		if updatable {
+			p.add(kind, name, domain.ImportActionUpdate, "already exists, will be overwritten")
+		} else {
+			p.add(kind, name, domain.ImportActionSkip, "already exists, overwrite not supported")
+		}
+	case "error":
+		p.add(kind, name, domain.ImportActionConflict, "already exists")
+	default:
+		p.add(kind, name, domain.ImportActionSkip, "already exists")
+	}
+}
+
+// PlanImport validates a config bundle against the current data without writing anything.
+// Every entity in the bundle gets a plan item; references are resolved against both
+// existing data and entities the bundle itself will create.
+func (s *BackupService) PlanImport(backup *domain.BackupFile, opts domain.ImportOptions) (*domain.ImportPlan, error) {
+	if backup.Version != domain.BackupVersion {
+		return nil, fmt.Errorf("unsupported backup version: %s (expected %s)", backup.Version, domain.BackupVersion)
+	}
+	if err := validateMappingConflictStrategy(opts); err != nil {
+		return nil, err
+	}
+
+	ctx := newImportContext()
+	if err := s.loadExistingMappings(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load existing data: %w", err)
+	}
+
+	p := &importPlanner{
+		plan: &domain.ImportPlan{
+			Valid:   true,
+			Summary: make(map[string]int),
+			Items:   []domain.ImportPlanItem{},
+		},
+		strategy: opts.ConflictStrategy,
+	}
+
+	// Names that will resolve once the import is applied
+	providers := make(map[string]bool)
+	for name := range ctx.providerNameToID {
+		providers[name] = true
+	}
+	projects := make(map[string]bool)
+	for slug := range ctx.projectSlugToID {
+		projects[slug] = true
+	}
+	retryConfigs := make(map[string]bool)
+	for name := range ctx.retryConfigNameToID {
+		retryConfigs[name] = true
+	}
+	apiTokens := make(map[string]bool)
+	for name := range ctx.apiTokenNameToID {
+		apiTokens[name] = true
+	}
+	routes := make(map[string]bool)
+	for key := range ctx.routeKeyToID {
+		routes[key] = true
+	}
+
+	// 1. SystemSettings
+	for _, bs := range backup.Data.SystemSettings {
+		if bs.Key == domain.SettingKeyRedactionRules {
+			if _, err := redaction.ParseRules(bs.Value); err != nil {
+				p.add("systemSettings", bs.Key, domain.ImportActionError, err.Error())
+				continue
+			}
+		}
+		if bs.Key == domain.SettingKeyPIIPatterns {
+			if _, err := pii.ParsePatterns(bs.Value); err != nil {
+				p.add("systemSettings", bs.Key, domain.ImportActionError, err.Error())
+				continue
+			}
+		}
+		if existing, _ := s.settingRepo.Get(bs.Key); existing != "" {
+			p.existing("systemSettings", bs.Key, true)
+			continue
+		}
+		p.add("systemSettings", bs.Key, domain.ImportActionCreate, "")
+	}
+
+	// 2. RetryConfigs
+	seen := make(map[string]bool)
+	for _, bc := range backup.Data.RetryConfigs {
+		switch {
+		case bc.Name == "":
+			p.add("retryConfigs", bc.Name, domain.ImportActionError, "name is required")
+		case seen[bc.Name]:
+			p.add("retryConfigs", bc.Name, domain.ImportActionError, "duplicate name in bundle")
+		case retryConfigs[bc.Name]:
+			p.existing("retryConfigs", bc.Name, false)
+		default:
+			p.add("retryConfigs", bc.Name, domain.ImportActionCreate, "")
+			retryConfigs[bc.Name] = true
+		}
+		seen[bc.Name] = true
+	}
+
+	// 3. Providers
+	seen = make(map[string]bool)
+	for _, bp := range backup.Data.Providers {
+		if bp.Name == "" {
+			p.add("providers", bp.Name, domain.ImportActionError, "name is required")
+			continue
+		}
+		if seen[bp.Name] {
+			p.add("providers", bp.Name, domain.ImportActionError, "duplicate name in bundle")
+			continue
+		}
+		seen[bp.Name] = true
+		if err := validateProviderConfig(bp.Name, bp.Type, bp.Config); err != nil {
+			p.add("providers", bp.Name, domain.ImportActionError, err.Error())
+			continue
+		}
+		if providers[bp.Name] {
+			p.existing("providers", bp.Name, false)
+			continue
+		}
+		p.add("providers", bp.Name, domain.ImportActionCreate, "")
+		providers[bp.Name] = true
+	}
+
+	// 4. Projects (slug must be unique)
+	seen = make(map[string]bool)
+	for _, bp := range backup.Data.Projects {
+		switch {
+		case bp.Slug == "":
+			p.add("projects", bp.Slug, domain.ImportActionError, "slug is required")
+		case seen[bp.Slug]:
+			p.add("projects", bp.Slug, domain.ImportActionError, "duplicate slug in bundle")
+		case projects[bp.Slug]:
+			p.existing("projects", bp.Slug, false)
+		default:
+			p.add("projects", bp.Slug, domain.ImportActionCreate, "")
+			projects[bp.Slug] = true
+		}
+		seen[bp.Slug] = true
+	}
+
+	// 5. RoutingStrategies
+	for _, bs := range backup.Data.RoutingStrategies {
+		name := bs.ProjectSlug
+		if name == "" {
+			name = "(global)"
+		}
+		if bs.ProjectSlug != "" && !projects[bs.ProjectSlug] {
+			p.add("routingStrategies", name, domain.ImportActionError, fmt.Sprintf("project '%s' not found", bs.ProjectSlug))
+			continue
+		}
+		if projectID, ok := ctx.projectSlugToID[bs.ProjectSlug]; ok || bs.ProjectSlug == "" {
+			if existing, _ := s.routingStrategyRepo.GetByProjectID(projectID); existing != nil {
+				p.existing("routingStrategies", name, true)
+				continue
+			}
+		}
+		p.add("routingStrategies", name, domain.ImportActionCreate, "")
+	}
+
+	// 6. APITokens
+	seen = make(map[string]bool)
+	for _, bt := range backup.Data.APITokens {
+		switch {
+		case bt.Name == "":
+			p.add("apiTokens", bt.Name, domain.ImportActionError, "name is required")
+		case seen[bt.Name]:
+			p.add("apiTokens", bt.Name, domain.ImportActionError, "duplicate name in bundle")
+		case apiTokens[bt.Name]:
+			p.existing("apiTokens", bt.Name, false)
+		case bt.ProjectSlug != "" && !projects[bt.ProjectSlug]:
+			p.add("apiTokens", bt.Name, domain.ImportActionError, fmt.Sprintf("project '%s' not found", bt.ProjectSlug))
+		default:
+			p.add("apiTokens", bt.Name, domain.ImportActionCreate, "")
+			apiTokens[bt.Name] = true
+		}
+		seen[bt.Name] = true
+	}
+
+	// 7. Routes (provider, project, retry config and pinned API tokens must resolve)
+	seen = make(map[string]bool)
+	for _, br := range backup.Data.Routes {
+		key := fmt.Sprintf("%s:%s:%s", br.ProviderName, br.ClientType, br.ProjectSlug)
+		switch {
+		case !providers[br.ProviderName]:
+			p.add("routes", key, domain.ImportActionError, fmt.Sprintf("provider '%s' not found", br.ProviderName))
+		case br.ProjectSlug != "" && !projects[br.ProjectSlug]:
+			p.add("routes", key, domain.ImportActionError, fmt.Sprintf("project '%s' not found", br.ProjectSlug))
+		case br.RetryConfigName != "" && !retryConfigs[br.RetryConfigName]:
+			p.add("routes", key, domain.ImportActionError, fmt.Sprintf("retry config '%s' not found", br.RetryConfigName))
+		case missingName(br.APITokenNames, apiTokens) != "":
+			p.add("routes", key, domain.ImportActionError, fmt.Sprintf("apiToken '%s' not found", missingName(br.APITokenNames, apiTokens)))
+		case seen[key]:
+			p.add("routes", key, domain.ImportActionError, "duplicate route in bundle")
+		case routes[key]:
+			p.existing("routes", key, false)
+		default:
+			p.add("routes", key, domain.ImportActionCreate, "")
+			routes[key] = true
+		}
+		seen[key] = true
+	}
+
+	// 8. ModelMappings (conflicts with existing mappings follow the mapping conflict strategy)
+	existingMappings := ctx.modelMappings
+	for _, bm := range backup.Data.ModelMappings {
+		name := fmt.Sprintf("%s -> %s", bm.Pattern, bm.Target)
+		switch {
+		case bm.Pattern == "" || bm.Target == "":
+			p.add("modelMappings", name, domain.ImportActionError, "pattern and target are required")
+		case domain.ValidateModelMappingTargets(bm.Targets) != nil:
+			p.add("modelMappings", name, domain.ImportActionError, domain.ValidateModelMappingTargets(bm.Targets).Error())
+		case bm.ProviderName != "" && !providers[bm.ProviderName]:
+			p.add("modelMappings", name, domain.ImportActionError, fmt.Sprintf("provider '%s' not found", bm.ProviderName))
+		case bm.ProjectSlug != "" && !projects[bm.ProjectSlug]:
+			p.add("modelMappings", name, domain.ImportActionError, fmt.Sprintf("project '%s' not found", bm.ProjectSlug))
+		case bm.RouteName != "" && !routes[bm.RouteName]:
+			p.add("modelMappings", name, domain.ImportActionError, fmt.Sprintf("route '%s' not found", bm.RouteName))
+		case bm.APITokenName != "" && !apiTokens[bm.APITokenName]:
+			p.add("modelMappings", name, domain.ImportActionError, fmt.Sprintf("apiToken '%s' not found", bm.APITokenName))
+		default:
+			// Mappings scoped to entities the import creates can't conflict with existing ones
+			var conflicts []*domain.ModelMapping
+			if m, missing := ctx.resolveModelMapping(bm); missing == "" {
+				conflicts = mappingConflicts(m, existingMappings)
+			}
+			if len(conflicts) == 0 {
+				p.add("modelMappings", name, domain.ImportActionCreate, "")
+				continue
+			}
+			switch opts.MappingConflicts() {
+			case domain.MappingConflictIncoming:
+				p.add("modelMappings", name, domain.ImportActionUpdate, "replaces conflicting "+describeMappings(conflicts))
+				existingMappings = slices.DeleteFunc(slices.Clone(existingMappings), func(e *domain.ModelMapping) bool {
+					return slices.Contains(conflicts, e)
+				})
+			case domain.MappingConflictError:
+				p.add("modelMappings", name, domain.ImportActionConflict, "conflicts with existing "+describeMappings(conflicts))
+			default:
+				p.add("modelMappings", name, domain.ImportActionSkip, "conflicts with existing "+describeMappings(conflicts)+", existing kept")
+			}
+		}
+	}
+
+	fingerprint, err := planFingerprint(backup, opts, p.plan.Items)
+	if err != nil {
+		return nil, err
+	}
+	p.plan.Fingerprint = fingerprint
+	return p.plan, nil
+}
+
+// ApplyImport re-plans the import and, if the plan still matches the reviewed fingerprint
+// and is valid, writes it in one database transaction: any write failure leaves the
+// database unchanged.
+func (s *BackupService) ApplyImport(backup *domain.BackupFile, opts domain.ImportOptions, fingerprint string) (*domain.ConfigImportResult, error) {
+	plan, err := s.PlanImport(backup, opts)
+	if err != nil {
+		return nil, err
+	}
+	out := &domain.ConfigImportResult{Plan: plan}
+	if plan.Fingerprint != fingerprint {
+		return out, ErrImportPlanChanged
+	}
+	if !plan.Valid {
+		return out, ErrImportPlanInvalid
+	}
+
+	txer, ok := s.database.(repository.ConfigTransactor)
+	if !ok {
+		return nil, errors.New("transactional import is not available")
+	}
+
+	// All writes go through one transaction; a failing step rolls back the whole import
+	result := domain.NewImportResult()
+	ctx := newImportContext()
+	ctx.atomic = true
+	err = txer.ConfigTransaction(func(repos *repository.ConfigRepositories) error {
+		tx := s.withRepositories(repos)
+		if err := tx.loadExistingMappings(ctx); err != nil {
+			return fmt.Errorf("failed to load existing data: %w", err)
+		}
+		steps := []func(){
+			func() { tx.importSystemSettings(backup.Data.SystemSettings, opts, result, ctx) },
+			func() { tx.importRetryConfigs(backup.Data.RetryConfigs, opts, result, ctx) },
+			func() { tx.importProviders(backup.Data.Providers, opts, result, ctx) },
+			func() { tx.importProjects(backup.Data.Projects, opts, result, ctx) },
+			func() { tx.importRoutingStrategies(backup.Data.RoutingStrategies, opts, result, ctx) },
+			func() { tx.importAPITokens(backup.Data.APITokens, opts, result, ctx) },
+			func() { tx.importRoutes(backup.Data.Routes, opts, result, ctx) },
+			func() { tx.importModelMappings(backup.Data.ModelMappings, opts, result, ctx) },
+		}
+		for _, step := range steps {
+			step()
+			if !result.Success {
+				return errImportAborted
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errImportAborted) {
+		out.Result = result
+		return out, fmt.Errorf("import failed and was rolled back: %v", result.Errors)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Caches, adapters and in-memory settings only pick up the import once it is committed
+	if err := s.reloadImportedConfig(ctx); err != nil {
+		result.Warnings = append(result.Warnings, err.Error())
+	}
+	if err := s.reloadImportedSettings(backup.Data.SystemSettings); err != nil {
+		result.Warnings = append(result.Warnings, err.Error())
+	}
+
+	out.Applied = true
+	out.Result = result
+	return out, nil
+}
+
+// withRepositories returns a copy of the service writing to repos. It leaves the
+// provider adapters alone, they are refreshed once the writes are committed.
+func (s *BackupService) withRepositories(repos *repository.ConfigRepositories) *BackupService {
+	return &BackupService{
+		providerRepo:        repos.Providers,
+		routeRepo:           repos.Routes,
+		projectRepo:         repos.Projects,
+		retryConfigRepo:     repos.RetryConfigs,
+		routingStrategyRepo: repos.RoutingStrategies,
+		settingRepo:         repos.Settings,
+		apiTokenRepo:        repos.APITokens,
+		modelMappingRepo:    repos.ModelMappings,
+	}
+}
+
+// cacheLoader is implemented by the cached repositories, Load rereads the database
+type cacheLoader interface {
+	Load() error
+}
+
+// reloadImportedConfig refreshes the cached repositories and the adapters of the
+// created providers after a transactional import, whose writes bypassed them
+func (s *BackupService) reloadImportedConfig(ctx *importContext) error {
+	for _, repo := range []any{s.providerRepo, s.routeRepo, s.projectRepo, s.retryConfigRepo,
+		s.routingStrategyRepo, s.settingRepo, s.apiTokenRepo, s.modelMappingRepo} {
+		if cache, ok := repo.(cacheLoader); ok {
+			if err := cache.Load(); err != nil {
+				return fmt.Errorf("failed to reload cache after import: %w", err)
+			}
+		}
+	}
+	if s.adapterRefresher != nil {
+		for _, p := range ctx.createdProviders {
+			s.adapterRefresher.RefreshAdapter(p)
+		}
+	}
+	return nil
+}
+
+// reloadImportedSettings applies settings that are cached in memory
+func (s *BackupService) reloadImportedSettings(settings []domain.BackupSystemSetting) error {
+	reloadPII := false
+	for _, bs := range settings {
+		switch bs.Key {
+		case domain.SettingKeyRedactionRules:
+			value, _ := s.settingRepo.Get(bs.Key)
+			if err := redaction.LoadGlobalRules(value); err != nil {
+				return err
+			}
+		case domain.SettingKeyPIIDetection, domain.SettingKeyPIIPatterns:
+			reloadPII = true
+		case domain.SettingKeyMaxConcurrentRequests, domain.SettingKeyRequestQueueSize, domain.SettingKeyRequestQueueTimeout,
+			domain.SettingKeyMaxRequestBodyMB:
+			limiter.LoadFromSettings(s.settingRepo)
+		case domain.SettingKeyResponseSpillThreshold:
+			provider.LoadSpillThresholdFromSettings(s.settingRepo)
+		case domain.SettingKeyUpstreamMaxIdleConns, domain.SettingKeyUpstreamMaxIdleConnsPerHost, domain.SettingKeyUpstreamIdleConnTimeout:
+			provider.LoadPoolConfigFromSettings(s.settingRepo)
+		case domain.SettingKeyPromptCacheKeySession:
+			client.LoadPromptCacheKeySessionFromSettings(s.settingRepo)
+		case domain.SettingKeyUpstreamTimingCapture:
+			provider.LoadTimingCaptureFromSettings(s.settingRepo)
+		case domain.SettingKeyCooldownQueueWait:
+			cooldown.LoadQueueFromSettings(s.settingRepo)
+		case domain.SettingKeyUpstreamTLSMinVersion, domain.SettingKeyUpstreamTLSCipherSuites:
+			if err := provider.LoadTLSPolicyFromSettings(s.settingRepo); err != nil {
+				return err
+			}
+		case domain.SettingKeyCooldownScopes:
+			value, _ := s.settingRepo.Get(bs.Key)
+			if err := cooldown.LoadScopePolicy(value); err != nil {
+				return err
+			}
+		case domain.SettingKeyRequestLogSink, domain.SettingKeyRequestLogSinkAuth, domain.SettingKeyRequestLogSinkProviders,
+			domain.SettingKeyRequestLogSinkBodyLimit, domain.SettingKeyRequestLogSinkBuffer:
+			if err := logsink.LoadFromSettings(s.settingRepo); err != nil {
+				return err
+			}
+		case domain.SettingKeyCapturePath, domain.SettingKeyCaptureMaxSizeMB, domain.SettingKeyCaptureMaxFiles,
+			domain.SettingKeyCaptureSampleRate, domain.SettingKeyCaptureRedactionRules, domain.SettingKeyCaptureBuffer:
+			if err := capture.LoadFromSettings(s.settingRepo); err != nil {
+				return err
+			}
+		case domain.SettingKeyRetryBudgetTokens, domain.SettingKeyRetryBudgetRatio, domain.SettingKeyRetryBudgetScope:
+			retrybudget.LoadFromSettings(s.settingRepo)
+		}
+	}
+	if reloadPII {
+		return pii.LoadFromSettings(s.settingRepo)
+	}
+	return nil
+}
+
+// validateProviderConfig checks that the provider config can build an adapter
+// and carries the fields its adapter requires
+func validateProviderConfig(name, providerType string, config *domain.ProviderConfig) error {
+	factory, ok := provider.GetAdapterFactory(providerType)
+	if !ok {
+		return fmt.Errorf("unsupported provider type '%s'", providerType)
+	}
+	if _, err := factory(&domain.Provider{Name: name, Type: providerType, Config: config}); err != nil {
+		return err
+	}
+
+	switch providerType {
+	case "custom":
+		if config.Custom.BaseURL == "" {
+			return fmt.Errorf("custom config requires baseURL")
+		}
+	case "antigravity":
+		if config.Antigravity.RefreshToken == "" {
+			return fmt.Errorf("antigravity config requires refreshToken")
+		}
+	case "kiro":
+		if config.Kiro.RefreshToken == "" {
+			return fmt.Errorf("kiro config requires refreshToken")
+		}
+		if config.Kiro.AuthMethod == "idc" && (config.Kiro.ClientID == "" || config.Kiro.ClientSecret == "") {
+			return fmt.Errorf("kiro idc config requires clientId and clientSecret")
+		}
+	}
+
+	if err := redaction.Validate(config.RedactionRules); err != nil {
+		return err
+	}
+	return nil
+}
+
+// missingName returns the first name not in known, empty when all are
+func missingName(names []string, known map[string]bool) string {
+	for _, name := range names {
+		if !known[name] {
+			return name
+		}
+	}
+	return ""
+}
+
+// planFingerprint identifies a plan so the apply call can confirm it is unchanged
+func planFingerprint(backup *domain.BackupFile, opts domain.ImportOptions, items []domain.ImportPlanItem) (string, error) {
+	data, err := json.Marshal(struct {
+		Backup          *domain.BackupFile      `json:"backup"`
+		Strategy        string                  `json:"strategy"`
+		MappingStrategy string                  `json:"mappingStrategy,omitempty"`
+		Items           []domain.ImportPlanItem `json:"items"`
+	}{backup, opts.ConflictStrategy, opts.MappingConflictStrategy, items})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+	"github.com/awsl-project/maxx/internal/repository/cached"
+	"github.com/awsl-project/maxx/internal/repository/sqlite"
+)
+
+func newTestBackupService(t *testing.T) (*BackupService, *sqlite.ModelMappingRepository) {
+	t.Helper()
+	db, err := sqlite.NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	mappingRepo := sqlite.NewModelMappingRepository(db)
+	if err := mappingRepo.ClearAll(); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewBackupService(
+		sqlite.NewProviderRepository(db),
+		sqlite.NewRouteRepository(db),
+		sqlite.NewProjectRepository(db),
+		sqlite.NewRetryConfigRepository(db),
+		sqlite.NewRoutingStrategyRepository(db),
+		sqlite.NewSystemSettingRepository(db),
+		sqlite.NewAPITokenRepository(db),
+		mappingRepo,
+		nil,
+	)
+	svc.SetDatabase(db)
+	return svc, mappingRepo
+}
+
+// mappingRules lists the mappings as "clientType pattern -> target", sorted
+func mappingRules(t *testing.T, repo *sqlite.ModelMappingRepository) []string {
+	t.Helper()
+	mappings, err := repo.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rules []string
+	for _, m := range mappings {
+		rules = append(rules, string(m.ClientType)+" "+m.Pattern+" -> "+m.Target)
+	}
+	sort.Strings(rules)
+	return rules
+}
+
+func TestImportModelMappingConflicts(t *testing.T) {
+	bundle := &domain.BackupFile{
+		Version: domain.BackupVersion,
+		Data: domain.BackupData{
+			ModelMappings: []domain.BackupModelMapping{
+				// Overlaps both existing claude rules
+				{ClientType: domain.ClientTypeClaude, Pattern: "claude-sonnet-4-*", Target: "gemini-flash"},
+				// Same rule as an existing one
+				{ClientType: domain.ClientTypeClaude, Pattern: "gpt-*", Target: "gemini-pro"},
+				// Overlapping pattern in another scope
+				{ClientType: domain.ClientTypeOpenAI, Pattern: "claude-*", Target: "gpt-4o"},
+				// No overlap
+				{ClientType: domain.ClientTypeClaude, Pattern: "o3-*", Target: "gemini-pro"},
+			},
+		},
+	}
+	existing := []string{
+		"claude claude-*-4 -> claude-opus",
+		"claude claude-sonnet-* -> claude-sonnet",
+		"claude gpt-* -> gemini-pro",
+		"claude re:o1-.* -> gemini-pro",
+	}
+
+	tests := []struct {
+		strategy string
+		planned  string // plan action of the conflicting mapping
+		want     []string
+	}{
+		{domain.MappingConflictExisting, domain.ImportActionSkip, []string{
+			"claude claude-*-4 -> claude-opus",
+			"claude claude-sonnet-* -> claude-sonnet",
+			"claude gpt-* -> gemini-pro",
+			"claude gpt-* -> gemini-pro",
+			"claude o3-* -> gemini-pro",
+			"claude re:o1-.* -> gemini-pro",
+			"openai claude-* -> gpt-4o",
+		}},
+		{domain.MappingConflictIncoming, domain.ImportActionUpdate, []string{
+			"claude claude-sonnet-4-* -> gemini-flash",
+			"claude gpt-* -> gemini-pro",
+			"claude gpt-* -> gemini-pro",
+			"claude o3-* -> gemini-pro",
+			"claude re:o1-.* -> gemini-pro",
+			"openai claude-* -> gpt-4o",
+		}},
+		{domain.MappingConflictError, domain.ImportActionConflict, existing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			svc, repo := newTestBackupService(t)
+			for _, rule := range existing {
+				clientType, rest, _ := strings.Cut(rule, " ")
+				pattern, target, _ := strings.Cut(rest, " -> ")
+				if err := repo.Create(&domain.ModelMapping{
+					Scope: domain.ModelMappingScopeGlobal, ClientType: domain.ClientType(clientType), Pattern: pattern, Target: target,
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opts := domain.ImportOptions{ConflictStrategy: "skip", MappingConflictStrategy: tt.strategy}
+			plan, err := svc.PlanImport(bundle, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actions := make([]string, 0, len(plan.Items))
+			for _, item := range plan.Items {
+				actions = append(actions, item.Action)
+			}
+			want := []string{tt.planned, domain.ImportActionCreate, domain.ImportActionCreate, domain.ImportActionCreate}
+			if strings.Join(actions, ",") != strings.Join(want, ",") {
+				t.Fatalf("plan actions = %v, want %v", actions, want)
+			}
+			if msg := plan.Items[0].Message; !strings.Contains(msg, "claude-*-4 -> claude-opus") || !strings.Contains(msg, "claude-sonnet-* -> claude-sonnet") {
+				t.Errorf("conflict message %q doesn't name both conflicting mappings", msg)
+			}
+
+			out, err := svc.ApplyImport(bundle, opts, plan.Fingerprint)
+			if tt.strategy == domain.MappingConflictError {
+				if !errors.Is(err, ErrImportPlanInvalid) {
+					t.Fatalf("err = %v, want ErrImportPlanInvalid", err)
+				}
+			} else if err != nil || !out.Applied {
+				t.Fatalf("apply failed: %v", err)
+			}
+			if got := mappingRules(t, repo); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("mappings after import:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestImportModelMappingConflictsDefaultStrategy(t *testing.T) {
+	bundle := &domain.BackupFile{
+		Version: domain.BackupVersion,
+		Data: domain.BackupData{
+			ModelMappings: []domain.BackupModelMapping{{Pattern: "gemini-*", Target: "gemini-2.5-flash"}},
+		},
+	}
+	for strategy, want := range map[string]string{
+		"skip":      domain.ImportActionSkip,
+		"overwrite": domain.ImportActionUpdate,
+		"error":     domain.ImportActionConflict,
+	} {
+		svc, repo := newTestBackupService(t)
+		if err := repo.Create(&domain.ModelMapping{Pattern: "*-pro", Target: "gemini-2.5-pro"}); err != nil {
+			t.Fatal(err)
+		}
+		plan, err := svc.PlanImport(bundle, domain.ImportOptions{ConflictStrategy: strategy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := plan.Items[0].Action; got != want {
+			t.Errorf("%s: action = %q, want %q", strategy, got, want)
+		}
+	}
+
+	svc, _ := newTestBackupService(t)
+	if _, err := svc.PlanImport(bundle, domain.ImportOptions{MappingConflictStrategy: "newest"}); err == nil {
+		t.Error("unknown mapping conflict strategy accepted")
+	}
+}
+
+func TestModelMappingPatternsOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"claude-*", "claude-sonnet-*", true},
+		{"*-sonnet-*", "claude-*-4", true},
+		{"claude-*", "*-haiku", true},
+		{"gpt-*", "claude-*", false},
+		{"*-pro", "*-flash", false},
+		{"claude-sonnet-4", "claude-*", true},
+		{"claude-sonnet-4", "claude-opus-4", false},
+		{"*", "anything", true},
+		{"re:claude-(sonnet|opus)-4", "claude-opus-4", true},
+		{"re:claude-(sonnet|opus)-4", "claude-haiku-4", false},
+		{"re:claude-.*", "claude-*", false},
+		{"re:claude-.*", "re:claude-.*", true},
+	}
+	for _, tt := range tests {
+		if got := domain.ModelMappingPatternsOverlap(tt.a, tt.b); got != tt.want {
+			t.Errorf("overlap(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := domain.ModelMappingPatternsOverlap(tt.b, tt.a); got != tt.want {
+			t.Errorf("overlap(%q, %q) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+// failingMappingsDB runs imports in real transactions whose model mapping writes fail
+type failingMappingsDB struct {
+	*sqlite.DB
+}
+
+type failingMappingRepo struct {
+	repository.ModelMappingRepository
+}
+
+func (failingMappingRepo) Create(*domain.ModelMapping) error {
+	return errors.New("disk full")
+}
+
+func (db failingMappingsDB) ConfigTransaction(fn func(repos *repository.ConfigRepositories) error) error {
+	return db.DB.ConfigTransaction(func(repos *repository.ConfigRepositories) error {
+		repos.ModelMappings = failingMappingRepo{repos.ModelMappings}
+		return fn(repos)
+	})
+}
+
+func TestApplyImportIsAtomic(t *testing.T) {
+	db, err := sqlite.NewDB(filepath.Join(t.TempDir(), "maxx.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	retryConfigRepo := cached.NewRetryConfigRepository(sqlite.NewRetryConfigRepository(db))
+	projectRepo := cached.NewProjectRepository(sqlite.NewProjectRepository(db))
+	settingRepo := sqlite.NewSystemSettingRepository(db)
+	mappingRepo := sqlite.NewModelMappingRepository(db)
+	if err := mappingRepo.ClearAll(); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewBackupService(
+		sqlite.NewProviderRepository(db),
+		sqlite.NewRouteRepository(db),
+		projectRepo,
+		retryConfigRepo,
+		sqlite.NewRoutingStrategyRepository(db),
+		settingRepo,
+		sqlite.NewAPITokenRepository(db),
+		mappingRepo,
+		nil,
+	)
+
+	bundle := &domain.BackupFile{
+		Version: domain.BackupVersion,
+		Data: domain.BackupData{
+			SystemSettings: []domain.BackupSystemSetting{{Key: "import_marker", Value: "imported"}},
+			RetryConfigs:   []domain.BackupRetryConfig{{Name: "imported", MaxRetries: 2}},
+			Projects:       []domain.BackupProject{{Name: "Imported", Slug: "imported"}},
+			ModelMappings:  []domain.BackupModelMapping{{Pattern: "gpt-*", Target: "claude-sonnet"}},
+		},
+	}
+	opts := domain.ImportOptions{ConflictStrategy: "skip"}
+	plan, err := svc.PlanImport(bundle, opts)
+	if err != nil || !plan.Valid {
+		t.Fatalf("plan = %+v, %v", plan, err)
+	}
+
+	// The last step fails, after settings, retry configs and projects were written
+	svc.SetDatabase(failingMappingsDB{db})
+	out, err := svc.ApplyImport(bundle, opts, plan.Fingerprint)
+	if err == nil || out.Applied {
+		t.Fatalf("import with a failing write applied: %+v", out)
+	}
+	if !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("err = %v, want the failed write", err)
+	}
+	if configs, _ := sqlite.NewRetryConfigRepository(db).List(); len(configs) != 0 {
+		t.Errorf("%d retry configs left after the rolled back import", len(configs))
+	}
+	if projects, _ := sqlite.NewProjectRepository(db).List(); len(projects) != 0 {
+		t.Errorf("%d projects left after the rolled back import", len(projects))
+	}
+	if value, _ := settingRepo.Get("import_marker"); value != "" {
+		t.Errorf("setting left after the rolled back import: %q", value)
+	}
+	if configs, _ := retryConfigRepo.List(); len(configs) != 0 {
+		t.Errorf("retry config cache has %d configs of the rolled back import", len(configs))
+	}
+
+	// Applied for real, the caches pick the import up after commit
+	svc.SetDatabase(db)
+	if out, err := svc.ApplyImport(bundle, opts, plan.Fingerprint); err != nil || !out.Applied {
+		t.Fatalf("import failed: %v", err)
+	}
+	if configs, _ := retryConfigRepo.List(); len(configs) != 1 || configs[0].Name != "imported" {
+		t.Errorf("retry config cache after import = %+v", configs)
+	}
+	if project, err := projectRepo.GetBySlug("imported"); err != nil || project == nil {
+		t.Errorf("project cache misses the imported project: %v", err)
+	}
+	if got := mappingRules(t, mappingRepo); len(got) != 1 {
+		t.Errorf("mappings after import = %v", got)
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrDatabaseBackupUnsupported is returned when the database can't be copied as a file
+var ErrDatabaseBackupUnsupported = errors.New("database backup is only supported for SQLite")
+
+// DatabaseSnapshotter copies the live database to a file
+// Implemented by the SQLite DB
+type DatabaseSnapshotter interface {
+	Dialector() string
+	Snapshot(path string, sanitize bool) error
+}
+
+// SetDatabase enables full database backups
+func (s *BackupService) SetDatabase(db DatabaseSnapshotter) {
+	s.database = db
+}
+
+// DatabaseBackup takes a consistent snapshot of the SQLite database, optionally
+// sanitized (credentials redacted, request/response bodies cleared) for sharing.
+// The snapshot is a temporary file removed when the returned reader is closed.
+//
+// To restore, stop maxx and replace maxx.db in the data directory with the
+// snapshot, removing any maxx.db-wal and maxx.db-shm files next to it. A
+// sanitized snapshot has no credentials, so providers and API tokens must be
+// re-entered before it can serve traffic.
+func (s *BackupService) DatabaseBackup(sanitize bool) (io.ReadCloser, error) {
+	if s.database == nil || s.database.Dialector() != "sqlite" {
+		return nil, ErrDatabaseBackupUnsupported
+	}
+	dir, err := os.MkdirTemp("", "maxx-backup-*")
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "maxx.db")
+	if err := s.database.Snapshot(path, sanitize); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &tempSnapshot{File: f, dir: dir}, nil
+}
+
+// tempSnapshot removes its directory once closed
+type tempSnapshot struct {
+	*os.File
+	dir string
+}
+
+func (t *tempSnapshot) Close() error {
+	err := t.File.Close()
+	os.RemoveAll(t.dir)
+	return err
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/adapter/provider"
+	"github.com/awsl-project/maxx/internal/discovery"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// ErrDiscoveryDirNotConfigured is returned when no credential directory is set
+var ErrDiscoveryDirNotConfigured = errors.New("credential discovery directory not configured")
+
+// discoveryValidateTimeout bounds the token refresh that validates each credential
+const discoveryValidateTimeout = 30 * time.Second
+
+// DiscoverProviders scans the configured credential directory and creates or
+// updates a provider per credential, deduplicated by account fingerprint.
+// Each new or changed credential is validated by refreshing its access token
+// first. Providers created by hand, or whose credentials were edited since
+// discovery last wrote them, are only overwritten with opts.Overwrite.
+func (s *AdminService) DiscoverProviders(ctx context.Context, opts domain.DiscoveryOptions) (*domain.DiscoveryReport, error) {
+	dir, _ := s.settingRepo.Get(domain.SettingKeyCredentialDiscoveryDir)
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, ErrDiscoveryDirNotConfigured
+	}
+
+	creds, fileErrors, err := discovery.Scan(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+
+	report := &domain.DiscoveryReport{
+		Dir:     dir,
+		Summary: make(map[string]int),
+		Items:   []domain.DiscoveryItem{},
+	}
+	add := func(item domain.DiscoveryItem) {
+		report.Items = append(report.Items, item)
+		report.Summary[item.Action]++
+	}
+	for _, fe := range fileErrors {
+		add(domain.DiscoveryItem{Source: fe.Source, Action: domain.DiscoveryActionError, Message: fe.Err.Error()})
+	}
+
+	providers, err := s.providerRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	byFingerprint := make(map[string]*domain.Provider)
+	for _, p := range providers {
+		for _, fp := range discovery.ProviderFingerprints(p) {
+			if _, ok := byFingerprint[fp]; !ok {
+				byFingerprint[fp] = p
+			}
+		}
+	}
+
+	for _, cred := range creds {
+		item := domain.DiscoveryItem{
+			Source:      cred.Source,
+			Type:        cred.Type,
+			Name:        cred.Name(),
+			Fingerprint: cred.Fingerprint(),
+		}
+
+		existing := byFingerprint[item.Fingerprint]
+		if existing == nil {
+			existing = byFingerprint[discovery.Fingerprint(cred.Type, "", cred.RefreshToken())]
+		}
+		if existing != nil {
+			item.ProviderID = existing.ID
+			item.Name = existing.Name
+			if discovery.Checksum(cred.Type, applyCredential(existing, cred).Config) == discovery.Checksum(existing.Type, existing.Config) {
+				item.Action = domain.DiscoveryActionUnchanged
+				add(item)
+				continue
+			}
+			if !opts.Overwrite && !discoveredUnedited(existing) {
+				item.Action = domain.DiscoveryActionSkip
+				item.Message = "provider was created or edited by hand, overwrite to replace its credentials"
+				add(item)
+				continue
+			}
+		}
+
+		candidate := &domain.Provider{Name: item.Name, Type: cred.Type, Config: &domain.ProviderConfig{}}
+		if existing != nil {
+			candidate = existing
+		}
+		candidate = applyCredential(candidate, cred)
+		if err := validateProviderConfig(candidate.Name, candidate.Type, candidate.Config); err != nil {
+			item.Action = domain.DiscoveryActionError
+			item.Message = err.Error()
+			add(item)
+			continue
+		}
+		if err := validateCredential(ctx, candidate); err != nil {
+			item.Action = domain.DiscoveryActionInvalid
+			item.Message = err.Error()
+			add(item)
+			continue
+		}
+
+		if existing != nil {
+			item.Action = domain.DiscoveryActionUpdate
+			err = s.UpdateProvider(candidate)
+		} else {
+			item.Action = domain.DiscoveryActionCreate
+			err = s.CreateProvider(candidate)
+			byFingerprint[item.Fingerprint] = candidate
+		}
+		if err != nil {
+			item.Action = domain.DiscoveryActionError
+			item.Message = err.Error()
+		}
+		item.ProviderID = candidate.ID
+		add(item)
+	}
+	return report, nil
+}
+
+// DiscoverProvidersOnStartup runs credential discovery when a directory is
+// configured, without overwriting providers edited by hand
+func (s *AdminService) DiscoverProvidersOnStartup(ctx context.Context) {
+	report, err := s.DiscoverProviders(ctx, domain.DiscoveryOptions{})
+	if errors.Is(err, ErrDiscoveryDirNotConfigured) {
+		return
+	}
+	if err != nil {
+		log.Printf("[Discovery] Credential discovery failed: %v", err)
+		return
+	}
+	log.Printf("[Discovery] Scanned %s: %v", report.Dir, report.Summary)
+	for _, item := range report.Items {
+		switch item.Action {
+		case domain.DiscoveryActionSkip, domain.DiscoveryActionInvalid, domain.DiscoveryActionError:
+			log.Printf("[Discovery] %s %s: %s", item.Action, item.Source, item.Message)
+		}
+	}
+}
+
+// applyCredential returns a copy of p with the credential written into its config.
+// Providers come from the cached repository, so the original is left untouched.
+func applyCredential(p *domain.Provider, cred *discovery.Credential) *domain.Provider {
+	updated := *p
+	config := domain.ProviderConfig{}
+	if p.Config != nil {
+		config = *p.Config
+	}
+	if config.Kiro != nil {
+		kiro := *config.Kiro
+		config.Kiro = &kiro
+	}
+	if config.Antigravity != nil {
+		ag := *config.Antigravity
+		config.Antigravity = &ag
+	}
+	cred.Apply(&config)
+	updated.Config = &config
+	return &updated
+}
+
+// discoveredUnedited reports whether the provider was written by discovery
+// and its credentials haven't been changed by hand since
+func discoveredUnedited(p *domain.Provider) bool {
+	if p.Config == nil || p.Config.Discovery == nil {
+		return false
+	}
+	return p.Config.Discovery.Checksum == discovery.Checksum(p.Type, p.Config)
+}
+
+// validateCredential tests the provider's credentials by refreshing its access token
+func validateCredential(ctx context.Context, p *domain.Provider) error {
+	factory, ok := provider.GetAdapterFactory(p.Type)
+	if !ok {
+		return fmt.Errorf("unsupported provider type '%s'", p.Type)
+	}
+	adapter, err := factory(p)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, discoveryValidateTimeout)
+	defer cancel()
+	return provider.Warmup(ctx, adapter)
+}
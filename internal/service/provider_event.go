@@ -0,0 +1,31 @@
+package service
+
+import (
+	"log"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/event"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+// ProviderEventLog saves provider lifecycle events as they occur and broadcasts
+// them, it is the event sink of the cooldown manager
+type ProviderEventLog struct {
+	repo        repository.ProviderEventRepository
+	broadcaster event.Broadcaster
+}
+
+// NewProviderEventLog creates a new ProviderEventLog
+func NewProviderEventLog(repo repository.ProviderEventRepository, broadcaster event.Broadcaster) *ProviderEventLog {
+	return &ProviderEventLog{repo: repo, broadcaster: broadcaster}
+}
+
+// Record saves the event and broadcasts it as a provider_event message
+func (l *ProviderEventLog) Record(e *domain.ProviderEvent) {
+	if err := l.repo.Create(e); err != nil {
+		log.Printf("[ProviderEvents] Failed to save %s event of provider %d: %v", e.Type, e.ProviderID, err)
+	}
+	if l.broadcaster != nil {
+		l.broadcaster.BroadcastMessage("provider_event", e)
+	}
+}
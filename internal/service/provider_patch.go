@@ -0,0 +1,174 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// ErrInvalidProviderConfig is returned when a provider update fails adapter config validation
+var ErrInvalidProviderConfig = errors.New("invalid provider config")
+
+// PatchProvider applies a JSON merge patch (RFC 7396) to a provider.
+// Fields omitted from the patch keep their current value, and secret fields
+// (API keys, refresh tokens, client secrets) sent empty are preserved as well.
+// The resulting adapter config is validated before saving.
+func (s *AdminService) PatchProvider(id uint64, patch []byte) (*domain.Provider, error) {
+	existing, err := s.providerRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := patchProvider(existing, patch)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateProviderConfig(updated.Name, updated.Type, updated.Config); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProviderConfig, err)
+	}
+
+	if err := s.UpdateProvider(updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// patchProvider returns a copy of existing with the merge patch applied
+func patchProvider(existing *domain.Provider, patch []byte) (*domain.Provider, error) {
+	var patchDoc interface{}
+	if err := json.Unmarshal(patch, &patchDoc); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+
+	current, err := json.Marshal(existing)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(current, &doc); err != nil {
+		return nil, err
+	}
+
+	merged, err := json.Marshal(mergePatch(doc, patchDoc))
+	if err != nil {
+		return nil, err
+	}
+	var updated domain.Provider
+	if err := json.Unmarshal(merged, &updated); err != nil {
+		return nil, fmt.Errorf("invalid provider: %w", err)
+	}
+
+	// Identity fields can't be patched
+	updated.ID = existing.ID
+	updated.CreatedAt = existing.CreatedAt
+	updated.DeletedAt = existing.DeletedAt
+
+	PreserveProviderSecrets(existing, &updated)
+	return &updated, nil
+}
+
+// mergePatch applies an RFC 7396 merge patch to target
+func mergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = make(map[string]interface{})
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
+
+// PreserveProviderSecrets copies secret fields from existing into updated when
+// the update left them empty, so an update that omits them can't wipe credentials.
+// Secrets are only carried over while the provider type is unchanged.
+func PreserveProviderSecrets(existing, updated *domain.Provider) {
+	if existing.Config == nil || updated.Type != existing.Type {
+		return
+	}
+	if updated.Config == nil {
+		// The whole config was omitted, keep it as is
+		config := *existing.Config
+		updated.Config = &config
+		return
+	}
+
+	if old := existing.Config.Custom; old != nil {
+		if updated.Config.Custom == nil {
+			custom := *old
+			updated.Config.Custom = &custom
+		}
+		keepSecret(&updated.Config.Custom.APIKey, old.APIKey)
+		// Signing secrets belong to the access key, keep them only while it is unchanged
+		if oldSigning, signing := old.Signing, updated.Config.Custom.Signing; oldSigning != nil && signing != nil && signing.AccessKeyID == oldSigning.AccessKeyID {
+			keepSecret(&signing.SecretAccessKey, oldSigning.SecretAccessKey)
+			keepSecret(&signing.SessionToken, oldSigning.SessionToken)
+		}
+	}
+	if old := existing.Config.Antigravity; old != nil {
+		if updated.Config.Antigravity == nil {
+			antigravity := *old
+			updated.Config.Antigravity = &antigravity
+		}
+		keepSecret(&updated.Config.Antigravity.RefreshToken, old.RefreshToken)
+	}
+	if old := existing.Config.Kiro; old != nil {
+		if updated.Config.Kiro == nil {
+			kiro := *old
+			updated.Config.Kiro = &kiro
+		}
+		keepSecret(&updated.Config.Kiro.RefreshToken, old.RefreshToken)
+		keepSecret(&updated.Config.Kiro.ClientSecret, old.ClientSecret)
+	}
+}
+
+// RedactProviderSecrets returns a copy of the provider without its secret fields,
+// for API responses. The fields PreserveProviderSecrets keeps on update are cleared,
+// so a redacted provider sent back unchanged keeps its credentials.
+func RedactProviderSecrets(p *domain.Provider) *domain.Provider {
+	if p == nil || p.Config == nil {
+		return p
+	}
+	redacted := *p
+	config := *p.Config
+	redacted.Config = &config
+	if custom := config.Custom; custom != nil {
+		c := *custom
+		c.APIKey = ""
+		if c.Signing != nil {
+			signing := *c.Signing
+			signing.SecretAccessKey = ""
+			signing.SessionToken = ""
+			c.Signing = &signing
+		}
+		config.Custom = &c
+	}
+	if antigravity := config.Antigravity; antigravity != nil {
+		a := *antigravity
+		a.RefreshToken = ""
+		config.Antigravity = &a
+	}
+	if kiro := config.Kiro; kiro != nil {
+		k := *kiro
+		k.RefreshToken = ""
+		k.ClientSecret = ""
+		config.Kiro = &k
+	}
+	return &redacted
+}
+
+func keepSecret(dst *string, existing string) {
+	if *dst == "" {
+		*dst = existing
+	}
+}
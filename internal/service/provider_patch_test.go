@@ -0,0 +1,145 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+func kiroProvider() *domain.Provider {
+	return &domain.Provider{
+		ID:   7,
+		Name: "kiro-main",
+		Type: "kiro",
+		Config: &domain.ProviderConfig{
+			Kiro: &domain.ProviderConfigKiro{
+				AuthMethod:   "idc",
+				RefreshToken: "refresh-secret",
+				ClientID:     "client-id",
+				ClientSecret: "client-secret",
+				Region:       "us-east-1",
+				ModelMapping: map[string]string{"claude-*": "claude-sonnet-4"},
+			},
+			TPMLimit: 1000,
+		},
+	}
+}
+
+func TestPatchProviderRenameKeepsSecrets(t *testing.T) {
+	existing := kiroProvider()
+
+	updated, err := patchProvider(existing, []byte(`{"name":"kiro-renamed"}`))
+	if err != nil {
+		t.Fatalf("patch failed: %v", err)
+	}
+	if updated.Name != "kiro-renamed" {
+		t.Errorf("name = %q, want kiro-renamed", updated.Name)
+	}
+	kiro := updated.Config.Kiro
+	if kiro.RefreshToken != "refresh-secret" || kiro.ClientSecret != "client-secret" {
+		t.Errorf("secrets not preserved: refreshToken=%q clientSecret=%q", kiro.RefreshToken, kiro.ClientSecret)
+	}
+	if kiro.ModelMapping["claude-*"] != "claude-sonnet-4" || updated.Config.TPMLimit != 1000 {
+		t.Error("untouched config fields were not preserved")
+	}
+	if existing.Name != "kiro-main" {
+		t.Error("patch modified the existing provider")
+	}
+}
+
+func TestPatchProviderPartialConfigKeepsSecrets(t *testing.T) {
+	updated, err := patchProvider(kiroProvider(), []byte(`{"config":{"kiro":{"region":"eu-west-1","clientSecret":""}}}`))
+	if err != nil {
+		t.Fatalf("patch failed: %v", err)
+	}
+	kiro := updated.Config.Kiro
+	if kiro.Region != "eu-west-1" {
+		t.Errorf("region = %q, want eu-west-1", kiro.Region)
+	}
+	if kiro.RefreshToken != "refresh-secret" {
+		t.Errorf("omitted refreshToken = %q, want preserved", kiro.RefreshToken)
+	}
+	if kiro.ClientSecret != "client-secret" {
+		t.Errorf("empty clientSecret = %q, want preserved", kiro.ClientSecret)
+	}
+}
+
+func TestPatchProviderReplacesSecret(t *testing.T) {
+	updated, err := patchProvider(kiroProvider(), []byte(`{"config":{"kiro":{"refreshToken":"new-token"}}}`))
+	if err != nil {
+		t.Fatalf("patch failed: %v", err)
+	}
+	if got := updated.Config.Kiro.RefreshToken; got != "new-token" {
+		t.Errorf("refreshToken = %q, want new-token", got)
+	}
+}
+
+func TestPatchProviderKeepsIdentity(t *testing.T) {
+	updated, err := patchProvider(kiroProvider(), []byte(`{"id":99,"config":{"tpmLimit":null}}`))
+	if err != nil {
+		t.Fatalf("patch failed: %v", err)
+	}
+	if updated.ID != 7 {
+		t.Errorf("id = %d, want 7", updated.ID)
+	}
+	if updated.Config.TPMLimit != 0 {
+		t.Errorf("tpmLimit = %d, want removed by null", updated.Config.TPMLimit)
+	}
+}
+
+func TestPreserveProviderSecretsOnFullUpdate(t *testing.T) {
+	existing := &domain.Provider{
+		Type: "custom",
+		Config: &domain.ProviderConfig{
+			Custom: &domain.ProviderConfigCustom{BaseURL: "https://a.example", APIKey: "sk-secret"},
+		},
+	}
+
+	// A form update that omits the API key
+	updated := &domain.Provider{
+		Type: "custom",
+		Config: &domain.ProviderConfig{
+			Custom: &domain.ProviderConfigCustom{BaseURL: "https://b.example"},
+		},
+	}
+	PreserveProviderSecrets(existing, updated)
+	if updated.Config.Custom.APIKey != "sk-secret" {
+		t.Errorf("apiKey = %q, want preserved", updated.Config.Custom.APIKey)
+	}
+	if updated.Config.Custom.BaseURL != "https://b.example" {
+		t.Errorf("baseURL = %q, want updated value", updated.Config.Custom.BaseURL)
+	}
+
+	// Changing the provider type doesn't carry secrets over
+	retyped := &domain.Provider{Type: "kiro", Config: &domain.ProviderConfig{Kiro: &domain.ProviderConfigKiro{}}}
+	PreserveProviderSecrets(existing, retyped)
+	if retyped.Config.Custom != nil {
+		t.Error("secrets carried over to a provider of another type")
+	}
+}
+
+func TestRedactProviderSecrets(t *testing.T) {
+	provider := &domain.Provider{
+		Type: "custom",
+		Config: &domain.ProviderConfig{
+			Custom: &domain.ProviderConfigCustom{
+				BaseURL: "https://a.example",
+				APIKey:  "sk-secret",
+				Signing: &domain.ProviderConfigCustomSigning{AccessKeyID: "AKID", SecretAccessKey: "aws-secret"},
+			},
+		},
+	}
+	redacted := RedactProviderSecrets(provider)
+	if c := redacted.Config.Custom; c.APIKey != "" || c.Signing.SecretAccessKey != "" || c.Signing.AccessKeyID != "AKID" || c.BaseURL != "https://a.example" {
+		t.Errorf("redacted = %+v %+v", c, c.Signing)
+	}
+	if provider.Config.Custom.APIKey != "sk-secret" || provider.Config.Custom.Signing.SecretAccessKey != "aws-secret" {
+		t.Error("redaction modified the stored provider")
+	}
+
+	// Sending the redacted provider back keeps the credentials
+	PreserveProviderSecrets(provider, redacted)
+	if c := redacted.Config.Custom; c.APIKey != "sk-secret" || c.Signing.SecretAccessKey != "aws-secret" {
+		t.Errorf("round trip lost credentials: %+v %+v", c, c.Signing)
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/awsl-project/maxx/internal/secrets"
+)
+
+// ErrInvalidMasterKey is returned when a rotation is refused before any credential is touched
+var ErrInvalidMasterKey = errors.New("invalid master key")
+
+// MasterKeyStatus describes the encryption of stored credentials
+type MasterKeyStatus struct {
+	Enabled bool   `json:"enabled"`
+	KeyID   string `json:"keyId,omitempty"` // identifies the master key without revealing it
+}
+
+// MasterKeyRotation reports a master key rotation
+type MasterKeyRotation struct {
+	KeyID     string `json:"keyId"`
+	Providers int    `json:"providers"` // providers whose credentials were re-encrypted
+}
+
+// GetMasterKeyStatus reports whether credentials are encrypted and with which master key
+func (s *AdminService) GetMasterKeyStatus() MasterKeyStatus {
+	keyring := secrets.Default()
+	return MasterKeyStatus{Enabled: keyring.Enabled(), KeyID: keyring.KeyID()}
+}
+
+// RotateMasterKey re-encrypts all stored credentials with a new master key. The
+// replaced key stays usable until every provider is rewritten, and is restored if
+// that fails. MAXX_MASTER_KEY must be set to the new key before the next restart,
+// or MAXX_MASTER_KEY_PREVIOUS to the old one until then.
+func (s *AdminService) RotateMasterKey(masterKey string) (*MasterKeyRotation, error) {
+	if masterKey == "" {
+		return nil, fmt.Errorf("%w: masterKey is required", ErrInvalidMasterKey)
+	}
+	keyring := secrets.Default()
+	undo, err := keyring.Rotate(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMasterKey, err)
+	}
+	count, err := s.providerRepo.ReencryptSecrets()
+	if err != nil {
+		undo()
+		return nil, err
+	}
+	return &MasterKeyRotation{KeyID: keyring.KeyID(), Providers: count}, nil
+}
@@ -0,0 +1,343 @@
+package soak
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/adapter/provider"
+	ctxutil "github.com/awsl-project/maxx/internal/context"
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/version"
+)
+
+const (
+	// MaxRequests caps the requests of a soak test so a typo can't burn the provider's quota
+	MaxRequests = 200
+	// MaxConcurrency caps the requests of a soak test in flight at once
+	MaxConcurrency = 10
+
+	defaultCount       = 10
+	defaultConcurrency = 1
+	defaultMaxTokens   = 16
+	maxMaxTokens       = 1024
+	defaultPrompt      = "Hello! Please reply with a short greeting."
+
+	// requestTimeout bounds a single soak request
+	requestTimeout = 2 * time.Minute
+	// maxErrorMessage bounds the error messages kept in the report
+	maxErrorMessage = 300
+)
+
+var (
+	// ErrInvalidRequest is returned for soak parameters out of bounds
+	ErrInvalidRequest = errors.New("invalid soak request")
+	// ErrRunning is returned when the provider is already being soak tested
+	ErrRunning = errors.New("a soak test is already running for this provider")
+)
+
+// UserAgent identifies soak requests to the upstream
+func UserAgent() string {
+	return "maxx-soak/" + version.Version
+}
+
+// running holds the IDs of the providers being soak tested, one test at a time each
+var running sync.Map
+
+// Normalize fills in the defaults of a soak request and checks it against the safety caps
+func Normalize(req *domain.ProviderSoakRequest) error {
+	if req.Model == "" {
+		return fmt.Errorf("%w: model is required", ErrInvalidRequest)
+	}
+	if req.Count == 0 {
+		req.Count = defaultCount
+	}
+	if req.Concurrency == 0 {
+		req.Concurrency = defaultConcurrency
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = defaultMaxTokens
+	}
+	if req.Prompt == "" {
+		req.Prompt = defaultPrompt
+	}
+	switch {
+	case req.Count < 0 || req.Count > MaxRequests:
+		return fmt.Errorf("%w: count must be between 1 and %d", ErrInvalidRequest, MaxRequests)
+	case req.Concurrency < 0 || req.Concurrency > MaxConcurrency:
+		return fmt.Errorf("%w: concurrency must be between 1 and %d", ErrInvalidRequest, MaxConcurrency)
+	case req.MaxTokens < 0 || req.MaxTokens > maxMaxTokens:
+		return fmt.Errorf("%w: maxTokens must be between 1 and %d", ErrInvalidRequest, maxMaxTokens)
+	}
+	req.Concurrency = min(req.Concurrency, req.Count)
+	return nil
+}
+
+// Run sends the soak requests to the provider through its adapter and reports
+// how they went. The requests bypass the executor: they are not recorded in the
+// request log or usage stats and don't touch cooldowns, health or latency
+// tracking. Upstream they carry the soak User-Agent.
+func Run(ctx context.Context, a provider.ProviderAdapter, p *domain.Provider, req domain.ProviderSoakRequest) (*domain.ProviderSoakReport, error) {
+	if err := Normalize(&req); err != nil {
+		return nil, err
+	}
+	clientType, err := pickClientType(a, req.ClientType)
+	if err != nil {
+		return nil, err
+	}
+	if _, busy := running.LoadOrStore(p.ID, struct{}{}); busy {
+		return nil, ErrRunning
+	}
+	defer running.Delete(p.ID)
+
+	started := time.Now()
+	results := make([]result, req.Count)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range req.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = send(ctx, a, p, clientType, req)
+			}
+		}()
+	}
+	for i := range req.Count {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	sent := results[:0]
+	for _, r := range results {
+		if r.sent {
+			sent = append(sent, r)
+		}
+	}
+	report := buildReport(sent)
+	report.ProviderID = p.ID
+	report.ProviderName = p.Name
+	report.ClientType = clientType
+	report.Model = req.Model
+	report.UserAgent = UserAgent()
+	report.StartedAt = started
+	report.DurationMs = time.Since(started).Milliseconds()
+	return report, nil
+}
+
+// pickClientType returns the requested client type if the adapter supports it,
+// else the first one it supports
+func pickClientType(a provider.ProviderAdapter, requested domain.ClientType) (domain.ClientType, error) {
+	supported := a.SupportedClientTypes()
+	if requested != "" {
+		if !slices.Contains(supported, requested) {
+			return "", fmt.Errorf("%w: provider doesn't support client type %s", ErrInvalidRequest, requested)
+		}
+		return requested, nil
+	}
+	if len(supported) == 0 {
+		return "", fmt.Errorf("%w: provider supports no client type", ErrInvalidRequest)
+	}
+	return supported[0], nil
+}
+
+// result is the outcome of one soak request
+type result struct {
+	sent         bool
+	latency      time.Duration
+	statusCode   int
+	err          string
+	inputTokens  uint64
+	outputTokens uint64
+}
+
+// send makes one soak request through the adapter
+func send(ctx context.Context, a provider.ProviderAdapter, p *domain.Provider, clientType domain.ClientType, req domain.ProviderSoakRequest) result {
+	uri, body := buildRequest(clientType, req)
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	headers.Set("User-Agent", UserAgent())
+	if clientType == domain.ClientTypeClaude {
+		headers.Set("anthropic-version", "2023-06-01")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	events := domain.NewAdapterEventChan()
+	ctx = ctxutil.WithClientType(ctx, clientType)
+	ctx = ctxutil.WithOriginalClientType(ctx, clientType)
+	ctx = ctxutil.WithRequestModel(ctx, req.Model)
+	ctx = ctxutil.WithMappedModel(ctx, req.Model)
+	ctx = ctxutil.WithRequestBody(ctx, body)
+	ctx = ctxutil.WithRequestHeaders(ctx, headers)
+	ctx = ctxutil.WithRequestURI(ctx, uri)
+	ctx = ctxutil.WithIsStream(ctx, false)
+	ctx = ctxutil.WithEventChan(ctx, events)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, nil)
+	if err != nil {
+		return result{sent: true, err: err.Error()}
+	}
+	httpReq.Header = headers.Clone()
+
+	w := &discardWriter{header: http.Header{}}
+	start := time.Now()
+	err = a.Execute(ctx, w, httpReq, p)
+	r := result{sent: true, latency: time.Since(start), statusCode: w.status}
+	events.Close()
+	for event := range events {
+		switch {
+		case event.Metrics != nil:
+			r.inputTokens = event.Metrics.InputTokens
+			r.outputTokens = event.Metrics.OutputTokens
+		case event.ResponseInfo != nil && r.statusCode == 0:
+			r.statusCode = event.ResponseInfo.Status
+		}
+	}
+
+	var proxyErr *domain.ProxyError
+	if errors.As(err, &proxyErr) && proxyErr.HTTPStatusCode != 0 {
+		r.statusCode = proxyErr.HTTPStatusCode
+	}
+	switch {
+	case err != nil:
+		r.err = err.Error()
+	case r.statusCode >= http.StatusBadRequest:
+		r.err = fmt.Sprintf("upstream returned status %d", r.statusCode)
+	}
+	return r
+}
+
+// buildRequest returns the request URI and body of a minimal non-streaming request in the client format
+func buildRequest(clientType domain.ClientType, req domain.ProviderSoakRequest) (string, []byte) {
+	var uri string
+	var body any
+	switch clientType {
+	case domain.ClientTypeClaude:
+		uri = "/v1/messages"
+		body = map[string]any{
+			"model":      req.Model,
+			"max_tokens": req.MaxTokens,
+			"messages":   []map[string]any{{"role": "user", "content": req.Prompt}},
+		}
+	case domain.ClientTypeCodex:
+		uri = "/v1/responses"
+		body = map[string]any{
+			"model":             req.Model,
+			"max_output_tokens": req.MaxTokens,
+			"input":             req.Prompt,
+		}
+	case domain.ClientTypeGemini:
+		uri = "/v1beta/models/" + url.PathEscape(req.Model) + ":generateContent"
+		body = map[string]any{
+			"contents":         []map[string]any{{"role": "user", "parts": []map[string]any{{"text": req.Prompt}}}},
+			"generationConfig": map[string]any{"maxOutputTokens": req.MaxTokens},
+		}
+	default:
+		uri = "/v1/chat/completions"
+		body = map[string]any{
+			"model":      req.Model,
+			"max_tokens": req.MaxTokens,
+			"messages":   []map[string]any{{"role": "user", "content": req.Prompt}},
+		}
+	}
+	data, _ := json.Marshal(body)
+	return uri, data
+}
+
+// buildReport summarizes the results of the requests sent
+func buildReport(results []result) *domain.ProviderSoakReport {
+	report := &domain.ProviderSoakReport{
+		TotalRequests: len(results),
+		Errors:        []domain.ProviderSoakError{},
+	}
+	if len(results) == 0 {
+		return report
+	}
+
+	type errorKey struct {
+		status  int
+		message string
+	}
+	errorCounts := make(map[errorKey]int)
+	latencies := make([]int64, 0, len(results))
+	var totalLatency int64
+	for _, r := range results {
+		ms := r.latency.Milliseconds()
+		latencies = append(latencies, ms)
+		totalLatency += ms
+		report.TotalInputTokens += r.inputTokens
+		report.TotalOutputTokens += r.outputTokens
+		if r.err == "" {
+			report.SuccessfulRequests++
+			continue
+		}
+		report.FailedRequests++
+		message := r.err
+		if len(message) > maxErrorMessage {
+			message = message[:maxErrorMessage] + "..."
+		}
+		errorCounts[errorKey{r.statusCode, message}]++
+	}
+	report.SuccessRate = float64(report.SuccessfulRequests) * 100 / float64(len(results))
+
+	slices.Sort(latencies)
+	report.MinLatencyMs = latencies[0]
+	report.MaxLatencyMs = latencies[len(latencies)-1]
+	report.AvgLatencyMs = totalLatency / int64(len(latencies))
+	report.P50LatencyMs = percentile(latencies, 50)
+	report.P90LatencyMs = percentile(latencies, 90)
+	report.P99LatencyMs = percentile(latencies, 99)
+
+	for key, count := range errorCounts {
+		report.Errors = append(report.Errors, domain.ProviderSoakError{StatusCode: key.status, Message: key.message, Count: count})
+	}
+	sort.Slice(report.Errors, func(i, j int) bool {
+		if report.Errors[i].Count != report.Errors[j].Count {
+			return report.Errors[i].Count > report.Errors[j].Count
+		}
+		return report.Errors[i].Message < report.Errors[j].Message
+	})
+	return report
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []int64, p int) int64 {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// discardWriter takes the adapter's response, only its status is kept
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (d *discardWriter) Header() http.Header { return d.header }
+
+func (d *discardWriter) WriteHeader(code int) {
+	if d.status == 0 {
+		d.status = code
+	}
+}
+
+func (d *discardWriter) Write(b []byte) (int, error) {
+	if d.status == 0 {
+		d.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+// Flush implements http.Flusher for adapters that flush
+func (d *discardWriter) Flush() {}
@@ -0,0 +1,157 @@
+package soak
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ctxutil "github.com/awsl-project/maxx/internal/context"
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+// mockAdapter fails every third request with an overloaded upstream
+type mockAdapter struct {
+	calls       atomic.Int64
+	inFlight    atomic.Int64
+	maxInFlight atomic.Int64
+	block       chan struct{}
+
+	mu         sync.Mutex
+	userAgents map[string]bool
+	uris       map[string]bool
+}
+
+func (m *mockAdapter) SupportedClientTypes() []domain.ClientType {
+	return []domain.ClientType{domain.ClientTypeClaude, domain.ClientTypeGemini}
+}
+
+func (m *mockAdapter) Execute(ctx context.Context, w http.ResponseWriter, req *http.Request, p *domain.Provider) error {
+	n := m.calls.Add(1)
+	inFlight := m.inFlight.Add(1)
+	defer m.inFlight.Add(-1)
+	for {
+		peak := m.maxInFlight.Load()
+		if inFlight <= peak || m.maxInFlight.CompareAndSwap(peak, inFlight) {
+			break
+		}
+	}
+	if m.block != nil {
+		<-m.block
+	}
+	time.Sleep(time.Millisecond)
+
+	m.mu.Lock()
+	m.userAgents[ctxutil.GetRequestHeaders(ctx).Get("User-Agent")] = true
+	m.uris[ctxutil.GetRequestURI(ctx)] = true
+	m.mu.Unlock()
+
+	if n%3 == 0 {
+		return &domain.ProxyError{Err: domain.ErrUpstreamError, Message: "upstream returned status 529", HTTPStatusCode: 529, Retryable: true}
+	}
+	ctxutil.GetEventChan(ctx).SendMetrics(&domain.AdapterMetrics{InputTokens: 10, OutputTokens: 2})
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(`{"type":"message"}`))
+	return nil
+}
+
+func newMockAdapter() *mockAdapter {
+	return &mockAdapter{userAgents: map[string]bool{}, uris: map[string]bool{}}
+}
+
+func TestRunReportsReliabilityAndLatency(t *testing.T) {
+	a := newMockAdapter()
+	p := &domain.Provider{ID: 1, Name: "candidate"}
+
+	report, err := Run(context.Background(), a, p, domain.ProviderSoakRequest{Count: 9, Concurrency: 3, Model: "claude-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.calls.Load() != 9 || a.maxInFlight.Load() > 3 {
+		t.Errorf("calls = %d, peak concurrency = %d, want 9 calls at most 3 at once", a.calls.Load(), a.maxInFlight.Load())
+	}
+	if report.TotalRequests != 9 || report.SuccessfulRequests != 6 || report.FailedRequests != 3 {
+		t.Errorf("counts = %d total, %d ok, %d failed", report.TotalRequests, report.SuccessfulRequests, report.FailedRequests)
+	}
+	if report.SuccessRate < 66.6 || report.SuccessRate > 66.7 {
+		t.Errorf("success rate = %v", report.SuccessRate)
+	}
+	if report.ClientType != domain.ClientTypeClaude || report.ProviderName != "candidate" || report.Model != "claude-test" {
+		t.Errorf("report header = %+v", report)
+	}
+	if report.TotalInputTokens != 60 || report.TotalOutputTokens != 12 {
+		t.Errorf("tokens = %d in, %d out", report.TotalInputTokens, report.TotalOutputTokens)
+	}
+	if report.MinLatencyMs > report.P50LatencyMs || report.P50LatencyMs > report.P90LatencyMs ||
+		report.P90LatencyMs > report.P99LatencyMs || report.P99LatencyMs > report.MaxLatencyMs {
+		t.Errorf("latency distribution out of order: %+v", report)
+	}
+	if len(report.Errors) != 1 || report.Errors[0].StatusCode != 529 || report.Errors[0].Count != 3 {
+		t.Errorf("errors = %+v, want the 529 three times", report.Errors)
+	}
+
+	// Soak requests are tagged for the upstream
+	if len(a.userAgents) != 1 || !a.userAgents[UserAgent()] || report.UserAgent != UserAgent() {
+		t.Errorf("user agents = %v", a.userAgents)
+	}
+	if !a.uris["/v1/messages"] {
+		t.Errorf("uris = %v, want the Claude messages endpoint", a.uris)
+	}
+}
+
+func TestRunRequestedClientType(t *testing.T) {
+	a := newMockAdapter()
+	report, err := Run(context.Background(), a, &domain.Provider{ID: 2}, domain.ProviderSoakRequest{
+		Count: 1, Model: "gemini-test", ClientType: domain.ClientTypeGemini,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ClientType != domain.ClientTypeGemini || !a.uris["/v1beta/models/gemini-test:generateContent"] {
+		t.Errorf("client type = %s, uris = %v", report.ClientType, a.uris)
+	}
+
+	_, err = Run(context.Background(), a, &domain.Provider{ID: 2}, domain.ProviderSoakRequest{Model: "m", ClientType: domain.ClientTypeCodex})
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("unsupported client type error = %v", err)
+	}
+}
+
+func TestRunSafetyCaps(t *testing.T) {
+	a := newMockAdapter()
+	p := &domain.Provider{ID: 3}
+	for _, req := range []domain.ProviderSoakRequest{
+		{Model: "m", Count: MaxRequests + 1},
+		{Model: "m", Concurrency: MaxConcurrency + 1},
+		{Model: "m", Count: -1},
+		{Count: 5},
+	} {
+		if _, err := Run(context.Background(), a, p, req); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("Run(%+v) error = %v, want ErrInvalidRequest", req, err)
+		}
+	}
+	if a.calls.Load() != 0 {
+		t.Errorf("rejected soak tests sent %d requests", a.calls.Load())
+	}
+
+	// One soak test per provider at a time
+	a.block = make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, err := Run(context.Background(), a, p, domain.ProviderSoakRequest{Model: "m", Count: 1})
+		done <- err
+	}()
+	for a.inFlight.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := Run(context.Background(), a, p, domain.ProviderSoakRequest{Model: "m", Count: 1}); !errors.Is(err, ErrRunning) {
+		t.Errorf("concurrent soak test error = %v, want ErrRunning", err)
+	}
+	close(a.block)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
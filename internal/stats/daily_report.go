@@ -0,0 +1,317 @@
+package stats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+const (
+	// DailyReportSettleDelay 日期结束后小时时间桶还需要一段时间完成聚合，之后才推送该日期的摘要
+	DailyReportSettleDelay = 5 * time.Minute
+
+	dailyReportTopModels = 5
+	dailyReportTimeout   = 10 * time.Second
+)
+
+// DailyReporter 每天在配置的时间（配置时区）把前一天的用量摘要推送到配置的 Webhook
+// 已推送的日期保存在系统设置中，重启后不会重复推送；没有流量的日期不推送
+// 所有接收地址都推送失败时按指数退避重试
+type DailyReporter struct {
+	usageStats    repository.UsageStatsRepository
+	settings      repository.SystemSettingRepository
+	providerNames func() map[uint64]string
+	client        *http.Client
+	now           func() time.Time
+
+	mu          sync.Mutex
+	failures    int
+	nextAttempt time.Time
+}
+
+// NewDailyReporter 创建每日摘要推送器，providerNames 提供摘要中的供应商名称，可以为 nil
+func NewDailyReporter(usageStats repository.UsageStatsRepository, settings repository.SystemSettingRepository, providerNames func() map[uint64]string) *DailyReporter {
+	return &DailyReporter{
+		usageStats:    usageStats,
+		settings:      settings,
+		providerNames: providerNames,
+		client:        &http.Client{Timeout: dailyReportTimeout},
+		now:           time.Now,
+	}
+}
+
+// ParseReportTime 解析 HH:MM 格式的推送时间
+func ParseReportTime(value string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid daily report time %q, expected HH:MM", value)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// DueReportDay 返回 now 时应推送摘要的日期（loc 中该日 0 点），即每天 hour:minute 之后推送前一天的摘要
+// 当天的推送时间未到、前一天的数据尚未定稿，或该日期不晚于最后已推送的日期 lastDate 时返回 false
+func DueReportDay(now time.Time, loc *time.Location, hour, minute int, lastDate string) (time.Time, bool) {
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	scheduled := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, loc)
+	if local.Before(scheduled) || local.Before(today.Add(DailyReportSettleDelay)) {
+		return time.Time{}, false
+	}
+	day := today.AddDate(0, 0, -1)
+	if lastDate != "" && day.Format(time.DateOnly) <= lastDate {
+		return time.Time{}, false
+	}
+	return day, true
+}
+
+// Run 到达推送时间且前一天的摘要尚未推送时推送摘要，返回是否推送了摘要
+// 未配置推送时间或接收地址、或处于失败退避期时直接返回
+func (r *DailyReporter) Run(loc *time.Location) (bool, error) {
+	at, _ := r.settings.Get(domain.SettingKeyDailyReportTime)
+	webhooks := r.webhooks()
+	if strings.TrimSpace(at) == "" || len(webhooks) == 0 {
+		return false, nil
+	}
+	hour, minute, err := ParseReportTime(at)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if now.Before(r.nextAttempt) {
+		return false, nil
+	}
+	lastDate, _ := r.settings.Get(domain.SettingKeyDailyReportLastDate)
+	day, ok := DueReportDay(now, loc, hour, minute, strings.TrimSpace(lastDate))
+	if !ok {
+		return false, nil
+	}
+
+	report, err := r.Report(day)
+	if err != nil {
+		return false, r.fail(now, day, err)
+	}
+	if report.TotalRequests > 0 {
+		// 部分地址失败时不再重试，避免已成功的地址重复收到摘要
+		if err = r.deliver(webhooks, report); err != nil && !errors.Is(err, errPartialDelivery) {
+			return false, r.fail(now, day, err)
+		}
+	}
+	r.failures = 0
+	r.nextAttempt = time.Time{}
+
+	if setErr := r.settings.Set(domain.SettingKeyDailyReportLastDate, day.Format(time.DateOnly)); setErr != nil {
+		return false, setErr
+	}
+	return report.TotalRequests > 0, err
+}
+
+// fail 记录一次失败并设置退避，必须在持有 mu 时调用
+func (r *DailyReporter) fail(now, day time.Time, err error) error {
+	r.failures++
+	backoff := min(exportBackoffBase<<min(r.failures-1, 10), exportBackoffMax)
+	r.nextAttempt = now.Add(backoff)
+	return fmt.Errorf("daily report for %s failed (%d in a row, retry in %v): %w", day.Format(time.DateOnly), r.failures, backoff, err)
+}
+
+// Report 汇总 day（loc 中该日 0 点）当天的小时级统计
+func (r *DailyReporter) Report(day time.Time) (*domain.DailyUsageReport, error) {
+	start := day
+	end := day.AddDate(0, 0, 1).Add(-time.Hour)
+	stats, err := r.usageStats.Query(repository.UsageStatsFilter{
+		Granularity: domain.GranularityHour,
+		StartTime:   &start,
+		EndTime:     &end,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var names map[uint64]string
+	if r.providerNames != nil {
+		names = r.providerNames()
+	}
+	return SummarizeDay(day, stats, names), nil
+}
+
+// webhooks 读取摘要的接收地址
+func (r *DailyReporter) webhooks() []string {
+	val, _ := r.settings.Get(domain.SettingKeyDailyReportWebhooks)
+	var urls []string
+	for _, url := range strings.Split(val, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// errPartialDelivery 部分接收地址推送失败，已成功的地址不再重复推送
+var errPartialDelivery = errors.New("daily report not delivered to every webhook")
+
+// deliver 把摘要推送到每个接收地址，全部失败时返回错误，部分失败时返回 errPartialDelivery
+func (r *DailyReporter) deliver(webhooks []string, report *domain.DailyUsageReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, url := range webhooks {
+		if err := r.post(url, body); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", url, err))
+		}
+	}
+	switch {
+	case len(errs) == 0:
+		return nil
+	case len(errs) < len(webhooks):
+		return fmt.Errorf("%w: %w", errPartialDelivery, errors.Join(errs...))
+	default:
+		return errors.Join(errs...)
+	}
+}
+
+func (r *DailyReporter) post(url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dailyReportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SummarizeDay 把 day 当天的统计汇总为每日摘要：总量、错误率、请求最多的模型和各供应商明细
+func SummarizeDay(day time.Time, stats []*domain.UsageStats, providerNames map[uint64]string) *domain.DailyUsageReport {
+	report := &domain.DailyUsageReport{
+		Date:      day.Format(time.DateOnly),
+		Timezone:  day.Location().String(),
+		TopModels: []domain.DailyUsageReportModel{},
+		Providers: []domain.DailyUsageReportProvider{},
+	}
+	models := make(map[string]*domain.DailyUsageReportModel)
+	providers := make(map[uint64]*domain.DailyUsageReportProvider)
+	for _, s := range stats {
+		tokens := s.InputTokens + s.OutputTokens + s.CacheRead + s.CacheWrite
+		report.TotalRequests += s.TotalRequests
+		report.SuccessfulRequests += s.SuccessfulRequests
+		report.FailedRequests += s.FailedRequests
+		report.InputTokens += s.InputTokens
+		report.OutputTokens += s.OutputTokens
+		report.CacheRead += s.CacheRead
+		report.CacheWrite += s.CacheWrite
+		report.Cost += s.Cost
+
+		if s.Model != "" {
+			m, ok := models[s.Model]
+			if !ok {
+				m = &domain.DailyUsageReportModel{Model: s.Model}
+				models[s.Model] = m
+			}
+			m.Requests += s.TotalRequests
+			m.Tokens += tokens
+			m.Cost += s.Cost
+		}
+
+		if s.ProviderID > 0 {
+			p, ok := providers[s.ProviderID]
+			if !ok {
+				name := providerNames[s.ProviderID]
+				if name == "" {
+					name = fmt.Sprintf("provider #%d", s.ProviderID)
+				}
+				p = &domain.DailyUsageReportProvider{ProviderID: s.ProviderID, ProviderName: name}
+				providers[s.ProviderID] = p
+			}
+			p.TotalRequests += s.TotalRequests
+			p.SuccessfulRequests += s.SuccessfulRequests
+			p.FailedRequests += s.FailedRequests
+			p.Tokens += tokens
+			p.Cost += s.Cost
+		}
+	}
+	report.ErrorRate = errorRate(report.FailedRequests, report.TotalRequests)
+
+	for _, m := range models {
+		report.TopModels = append(report.TopModels, *m)
+	}
+	sort.Slice(report.TopModels, func(i, j int) bool {
+		a, b := report.TopModels[i], report.TopModels[j]
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return a.Model < b.Model
+	})
+	if len(report.TopModels) > dailyReportTopModels {
+		report.TopModels = report.TopModels[:dailyReportTopModels]
+	}
+
+	for _, p := range providers {
+		p.ErrorRate = errorRate(p.FailedRequests, p.TotalRequests)
+		report.Providers = append(report.Providers, *p)
+	}
+	sort.Slice(report.Providers, func(i, j int) bool {
+		a, b := report.Providers[i], report.Providers[j]
+		if a.Cost != b.Cost {
+			return a.Cost > b.Cost
+		}
+		if a.TotalRequests != b.TotalRequests {
+			return a.TotalRequests > b.TotalRequests
+		}
+		return a.ProviderID < b.ProviderID
+	})
+
+	report.Text = formatDailyReport(report)
+	return report
+}
+
+func errorRate(failed, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(failed) / float64(total) * 100
+}
+
+// formatDailyReport 生成摘要的可读文本
+func formatDailyReport(r *domain.DailyUsageReport) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "maxx daily usage for %s (%s)\n", r.Date, r.Timezone)
+	fmt.Fprintf(&sb, "Requests: %d (%d failed, %.2f%% error rate)\n", r.TotalRequests, r.FailedRequests, r.ErrorRate)
+	fmt.Fprintf(&sb, "Tokens: %d input, %d output, %d cache read, %d cache write\n", r.InputTokens, r.OutputTokens, r.CacheRead, r.CacheWrite)
+	fmt.Fprintf(&sb, "Cost: $%.2f\n", float64(r.Cost)/1e6)
+	if len(r.TopModels) > 0 {
+		sb.WriteString("Top models:\n")
+		for _, m := range r.TopModels {
+			fmt.Fprintf(&sb, "- %s: %d requests, %d tokens, $%.2f\n", m.Model, m.Requests, m.Tokens, float64(m.Cost)/1e6)
+		}
+	}
+	if len(r.Providers) > 0 {
+		sb.WriteString("Providers:\n")
+		for _, p := range r.Providers {
+			fmt.Fprintf(&sb, "- %s: %d requests, %.2f%% errors, %d tokens, $%.2f\n", p.ProviderName, p.TotalRequests, p.ErrorRate, p.Tokens, float64(p.Cost)/1e6)
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -0,0 +1,208 @@
+package stats
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+)
+
+var reportLoc = time.FixedZone("UTC+8", 8*60*60)
+
+func TestDueReportDay(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, reportLoc)
+	at := func(h, m int) time.Time { return time.Date(2026, 3, 2, h, m, 0, 0, reportLoc) }
+	tests := []struct {
+		name         string
+		now          time.Time
+		hour, minute int
+		lastDate     string
+		want         bool
+	}{
+		{"before the report time", at(8, 59), 9, 0, "", false},
+		{"at the report time", at(9, 0), 9, 0, "", true},
+		{"later the same day", at(23, 59), 9, 0, "", true},
+		{"already sent", at(9, 30), 9, 0, "2026-03-01", false},
+		{"previous day sent", at(9, 30), 9, 0, "2026-02-28", true},
+		{"midnight waits for the last hour to settle", at(0, 1), 0, 0, "", false},
+		{"midnight after settling", at(0, 5), 0, 0, "", true},
+		// 00:30 in UTC+8 is still March 1st in UTC, the day follows the configured timezone
+		{"configured timezone", at(0, 30).UTC(), 0, 10, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := DueReportDay(tt.now, reportLoc, tt.hour, tt.minute, tt.lastDate)
+			if ok != tt.want {
+				t.Fatalf("due = %v, want %v", ok, tt.want)
+			}
+			if ok && !got.Equal(day) {
+				t.Errorf("report day = %v, want %v", got, day)
+			}
+		})
+	}
+}
+
+func TestParseReportTime(t *testing.T) {
+	if h, m, err := ParseReportTime(" 09:30 "); err != nil || h != 9 || m != 30 {
+		t.Errorf("ParseReportTime = %d, %d, %v", h, m, err)
+	}
+	for _, bad := range []string{"9am", "24:00", "09:60", ""} {
+		if _, _, err := ParseReportTime(bad); err == nil {
+			t.Errorf("ParseReportTime(%q) accepted", bad)
+		}
+	}
+}
+
+func hourStats(providerID uint64, model string, requests, failed, tokens, cost uint64) *domain.UsageStats {
+	return &domain.UsageStats{
+		Granularity:        domain.GranularityHour,
+		ProviderID:         providerID,
+		Model:              model,
+		TotalRequests:      requests,
+		SuccessfulRequests: requests - failed,
+		FailedRequests:     failed,
+		InputTokens:        tokens,
+		Cost:               cost,
+	}
+}
+
+func TestSummarizeDay(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, reportLoc)
+	stats := []*domain.UsageStats{
+		hourStats(1, "claude-sonnet-4", 60, 3, 6000, 3_000_000),
+		hourStats(1, "claude-sonnet-4", 40, 2, 4000, 2_000_000),
+		hourStats(2, "gpt-4o", 50, 0, 1000, 8_000_000),
+		hourStats(3, "claude-haiku", 10, 5, 100, 10_000),
+		hourStats(0, "", 2, 2, 0, 0), // rejected before routing
+	}
+	for i := range 6 {
+		stats = append(stats, hourStats(2, "model-"+string(rune('a'+i)), 1, 0, 1, 1))
+	}
+
+	report := SummarizeDay(day, stats, map[uint64]string{1: "anthropic", 2: "openai"})
+	if report.Date != "2026-03-01" || report.Timezone != "UTC+8" {
+		t.Errorf("date = %s %s", report.Date, report.Timezone)
+	}
+	if report.TotalRequests != 168 || report.FailedRequests != 12 || report.Cost != 13_010_006 {
+		t.Errorf("totals = %d requests, %d failed, cost %d", report.TotalRequests, report.FailedRequests, report.Cost)
+	}
+	if want := errorRate(12, 168); report.ErrorRate != want {
+		t.Errorf("error rate = %v, want %v", report.ErrorRate, want)
+	}
+
+	var models []string
+	for _, m := range report.TopModels {
+		models = append(models, m.Model)
+	}
+	if got := strings.Join(models, ","); got != "claude-sonnet-4,gpt-4o,claude-haiku,model-a,model-b" {
+		t.Errorf("top models = %s", got)
+	}
+	if m := report.TopModels[0]; m.Requests != 100 || m.Tokens != 10000 || m.Cost != 5_000_000 {
+		t.Errorf("top model = %+v", m)
+	}
+
+	if len(report.Providers) != 3 {
+		t.Fatalf("providers = %+v", report.Providers)
+	}
+	// Most expensive first, unknown names fall back to the ID
+	wantProviders := []struct {
+		name      string
+		requests  uint64
+		errorRate float64
+	}{
+		{"openai", 56, 0},
+		{"anthropic", 100, 5},
+		{"provider #3", 10, 50},
+	}
+	for i, want := range wantProviders {
+		p := report.Providers[i]
+		if p.ProviderName != want.name || p.TotalRequests != want.requests || p.ErrorRate != want.errorRate {
+			t.Errorf("provider %d = %+v, want %+v", i, p, want)
+		}
+	}
+
+	for _, line := range []string{"2026-03-01", "Requests: 168 (12 failed", "Cost: $13.01", "- openai: 56 requests"} {
+		if !strings.Contains(report.Text, line) {
+			t.Errorf("text is missing %q:\n%s", line, report.Text)
+		}
+	}
+}
+
+func TestDailyReporterRun(t *testing.T) {
+	var received []*domain.DailyUsageReport
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report domain.DailyUsageReport
+		_ = json.NewDecoder(r.Body).Decode(&report)
+		received = append(received, &report)
+	}))
+	defer sink.Close()
+
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, reportLoc)
+	inDay := hourStats(1, "claude-sonnet-4", 10, 1, 100, 1000)
+	inDay.TimeBucket = day.Add(23 * time.Hour)
+	nextDay := hourStats(1, "claude-sonnet-4", 99, 0, 100, 1000)
+	nextDay.TimeBucket = day.Add(24 * time.Hour)
+	repo := &minuteStats{rows: []*domain.UsageStats{inDay, nextDay}}
+
+	settings := memorySettings{
+		domain.SettingKeyDailyReportTime:     "09:00",
+		domain.SettingKeyDailyReportWebhooks: sink.URL,
+	}
+	r := NewDailyReporter(repo, settings, nil)
+	r.now = func() time.Time { return time.Date(2026, 3, 2, 9, 1, 0, 0, reportLoc) }
+
+	sent, err := r.Run(reportLoc)
+	if err != nil || !sent {
+		t.Fatalf("Run = %v, %v", sent, err)
+	}
+	if len(received) != 1 || received[0].Date != "2026-03-01" || received[0].TotalRequests != 10 {
+		t.Fatalf("received %+v", received)
+	}
+	if settings[domain.SettingKeyDailyReportLastDate] != "2026-03-01" {
+		t.Errorf("last date = %q", settings[domain.SettingKeyDailyReportLastDate])
+	}
+
+	// Sent once a day
+	if sent, err := r.Run(reportLoc); sent || err != nil || len(received) != 1 {
+		t.Errorf("second Run = %v, %v with %d reports", sent, err, len(received))
+	}
+
+	// A day without traffic is skipped but marked done
+	r.now = func() time.Time { return time.Date(2026, 3, 4, 9, 1, 0, 0, reportLoc) }
+	if sent, err := r.Run(reportLoc); sent || err != nil || len(received) != 1 {
+		t.Errorf("Run on an idle day = %v, %v with %d reports", sent, err, len(received))
+	}
+	if settings[domain.SettingKeyDailyReportLastDate] != "2026-03-03" {
+		t.Errorf("last date = %q", settings[domain.SettingKeyDailyReportLastDate])
+	}
+}
+
+func TestDailyReporterRetriesFailedDelivery(t *testing.T) {
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer sink.Close()
+
+	s := hourStats(1, "claude-sonnet-4", 10, 0, 100, 1000)
+	s.TimeBucket = time.Date(2026, 3, 1, 12, 0, 0, 0, reportLoc)
+	settings := memorySettings{
+		domain.SettingKeyDailyReportTime:     "09:00",
+		domain.SettingKeyDailyReportWebhooks: sink.URL,
+	}
+	r := NewDailyReporter(&minuteStats{rows: []*domain.UsageStats{s}}, settings, nil)
+	r.now = func() time.Time { return time.Date(2026, 3, 2, 9, 1, 0, 0, reportLoc) }
+
+	if sent, err := r.Run(reportLoc); sent || err == nil {
+		t.Fatalf("Run = %v, %v; want a delivery error", sent, err)
+	}
+	if settings[domain.SettingKeyDailyReportLastDate] != "" {
+		t.Errorf("day marked as sent after a failed delivery")
+	}
+	if !r.nextAttempt.After(r.now()) {
+		t.Errorf("no backoff after a failed delivery")
+	}
+}
@@ -0,0 +1,218 @@
+package stats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+const (
+	// ExportSettleDelay 分钟聚合会重算最近几分钟的数据，时间桶结束这么久之后才视为定稿并导出
+	ExportSettleDelay = 3 * time.Minute
+	// ExportBackfill 没有导出进度时，首次导出回溯的时长
+	ExportBackfill = time.Hour
+	// exportWindow 单次推送覆盖的最大时长，积压时分多次推送
+	exportWindow = time.Hour
+
+	exportBackoffBase = 30 * time.Second
+	exportBackoffMax  = 10 * time.Minute
+	exportTimeout     = 10 * time.Second
+)
+
+// Exporter 定期把新定稿的分钟级 usage_stats 推送到外部时序存储
+// 导出进度（最后一个已导出的时间桶）保存在系统设置中，重启后从此继续，不会重复推送
+// 推送失败时按指数退避重试，导出在独立的任务中执行，不影响聚合
+type Exporter struct {
+	usageStats repository.UsageStatsRepository
+	settings   repository.SystemSettingRepository
+	client     *http.Client
+	now        func() time.Time
+
+	mu          sync.Mutex
+	failures    int
+	nextAttempt time.Time
+}
+
+// NewExporter 创建统计导出器
+func NewExporter(usageStats repository.UsageStatsRepository, settings repository.SystemSettingRepository) *Exporter {
+	return &Exporter{
+		usageStats: usageStats,
+		settings:   settings,
+		client:     &http.Client{Timeout: exportTimeout},
+		now:        time.Now,
+	}
+}
+
+// Run 推送上次导出之后所有已定稿的分钟时间桶，返回导出的记录数
+// 未配置导出地址或处于失败退避期时直接返回
+func (e *Exporter) Run() (int, error) {
+	url, _ := e.settings.Get(domain.SettingKeyStatsExportURL)
+	url = strings.TrimSpace(url)
+	if url == "" {
+		return 0, nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	if now.Before(e.nextAttempt) {
+		return 0, nil
+	}
+
+	exported, err := e.export(url, now)
+	if err != nil {
+		e.failures++
+		backoff := min(exportBackoffBase<<min(e.failures-1, 10), exportBackoffMax)
+		e.nextAttempt = now.Add(backoff)
+		return exported, fmt.Errorf("stats export failed (%d in a row, retry in %v): %w", e.failures, backoff, err)
+	}
+	e.failures = 0
+	e.nextAttempt = time.Time{}
+	return exported, nil
+}
+
+// export 按窗口推送 (cursor, cutoff] 内的时间桶，每个窗口推送成功后推进进度
+func (e *Exporter) export(url string, now time.Time) (int, error) {
+	cutoff := ExportCutoff(now)
+	cursor := e.cursor(cutoff)
+
+	exported := 0
+	for cursor.Before(cutoff) {
+		end := cursor.Add(exportWindow)
+		if end.After(cutoff) {
+			end = cutoff
+		}
+		stats, err := e.PendingStats(cursor, end)
+		if err != nil {
+			return exported, err
+		}
+		if len(stats) > 0 {
+			if err := e.push(url, stats); err != nil {
+				return exported, err
+			}
+			exported += len(stats)
+		}
+		if err := e.settings.Set(domain.SettingKeyStatsExportCursor, strconv.FormatInt(end.Unix(), 10)); err != nil {
+			return exported, err
+		}
+		cursor = end
+	}
+	return exported, nil
+}
+
+// ExportCutoff 返回 now 时最后一个已定稿的分钟时间桶
+func ExportCutoff(now time.Time) time.Time {
+	return now.UTC().Truncate(time.Minute).Add(-ExportSettleDelay)
+}
+
+// cursor 读取导出进度，没有进度时从 cutoff 之前 ExportBackfill 开始
+func (e *Exporter) cursor(cutoff time.Time) time.Time {
+	if val, err := e.settings.Get(domain.SettingKeyStatsExportCursor); err == nil && val != "" {
+		if sec, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return time.Unix(sec, 0).UTC()
+		}
+	}
+	return cutoff.Add(-ExportBackfill)
+}
+
+// PendingStats 返回时间桶在 (after, until] 内的分钟级统计，按时间桶升序
+func (e *Exporter) PendingStats(after, until time.Time) ([]*domain.UsageStats, error) {
+	start := after.Add(time.Minute)
+	if start.After(until) {
+		return nil, nil
+	}
+	stats, err := e.usageStats.Query(repository.UsageStatsFilter{
+		Granularity: domain.GranularityMinute,
+		StartTime:   &start,
+		EndTime:     &until,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].TimeBucket.Before(stats[j].TimeBucket)
+	})
+	return stats, nil
+}
+
+// push 按配置的格式推送一批统计
+func (e *Exporter) push(url string, stats []*domain.UsageStats) error {
+	format, _ := e.settings.Get(domain.SettingKeyStatsExportFormat)
+	var body []byte
+	contentType := "application/json"
+	switch format {
+	case domain.StatsExportFormatInflux:
+		body = []byte(FormatLineProtocol(stats))
+		contentType = "text/plain; charset=utf-8"
+	default:
+		var err error
+		body, err = json.Marshal(map[string]interface{}{
+			"granularity": domain.GranularityMinute,
+			"stats":       stats,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if auth, _ := e.settings.Get(domain.SettingKeyStatsExportAuth); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sink returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// lineProtocolEscaper 转义行协议 tag 值中的逗号、等号和空格
+var lineProtocolEscaper = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", `\n`)
+
+// FormatLineProtocol 把统计格式化为 InfluxDB 行协议，每条记录一行，时间戳为纳秒
+// 空的 tag 值会被省略（行协议不允许空 tag）
+func FormatLineProtocol(stats []*domain.UsageStats) string {
+	var sb strings.Builder
+	for _, s := range stats {
+		sb.WriteString("maxx_usage")
+		tags := []struct{ key, value string }{
+			{"api_token_id", strconv.FormatUint(s.APITokenID, 10)},
+			{"client_type", s.ClientType},
+			{"model", s.Model},
+			{"project_id", strconv.FormatUint(s.ProjectID, 10)},
+			{"provider_id", strconv.FormatUint(s.ProviderID, 10)},
+			{"route_id", strconv.FormatUint(s.RouteID, 10)},
+		}
+		for _, tag := range tags {
+			if tag.value == "" {
+				continue
+			}
+			sb.WriteString("," + tag.key + "=" + lineProtocolEscaper.Replace(tag.value))
+		}
+		fmt.Fprintf(&sb, " cache_read=%di,cache_write=%di,cost=%di,duration_ms=%di,failed=%di,input_tokens=%di,output_tokens=%di,requests=%di,successful=%di %d\n",
+			s.CacheRead, s.CacheWrite, s.Cost, s.TotalDurationMs, s.FailedRequests,
+			s.InputTokens, s.OutputTokens, s.TotalRequests, s.SuccessfulRequests, s.TimeBucket.UnixNano())
+	}
+	return sb.String()
+}
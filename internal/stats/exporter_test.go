@@ -0,0 +1,213 @@
+package stats
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+type memorySettings map[string]string
+
+func (m memorySettings) Get(key string) (string, error) { return m[key], nil }
+func (m memorySettings) Set(key, value string) error    { m[key] = value; return nil }
+func (m memorySettings) Delete(key string) error        { delete(m, key); return nil }
+func (m memorySettings) GetAll() ([]*domain.SystemSetting, error) {
+	return nil, nil
+}
+
+// minuteStats serves minute stats from memory, newest first like the database
+type minuteStats struct {
+	repository.UsageStatsRepository
+	rows []*domain.UsageStats
+}
+
+func (m *minuteStats) Query(filter repository.UsageStatsFilter) ([]*domain.UsageStats, error) {
+	var out []*domain.UsageStats
+	for i := len(m.rows) - 1; i >= 0; i-- {
+		s := m.rows[i]
+		if s.TimeBucket.Before(*filter.StartTime) || s.TimeBucket.After(*filter.EndTime) {
+			continue
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+var exportNow = time.Date(2026, 3, 1, 12, 30, 20, 0, time.UTC)
+
+func statsAt(minutesAgo ...int) *minuteStats {
+	repo := &minuteStats{}
+	for _, ago := range minutesAgo {
+		repo.rows = append(repo.rows, &domain.UsageStats{
+			TimeBucket:    exportNow.Truncate(time.Minute).Add(-time.Duration(ago) * time.Minute),
+			Granularity:   domain.GranularityMinute,
+			ProviderID:    1,
+			ClientType:    "claude",
+			Model:         "claude-sonnet-4",
+			TotalRequests: uint64(ago),
+		})
+	}
+	return repo
+}
+
+// sink records the stats pushed to it
+type sink struct {
+	*httptest.Server
+	pushes [][]*domain.UsageStats
+	status int
+}
+
+func newSink(t *testing.T) *sink {
+	s := &sink{status: http.StatusNoContent}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Stats []*domain.UsageStats `json:"stats"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid push body: %v", err)
+		}
+		s.pushes = append(s.pushes, body.Stats)
+		w.WriteHeader(s.status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestExporter(repo *minuteStats, settings memorySettings) *Exporter {
+	e := NewExporter(repo, settings)
+	e.now = func() time.Time { return exportNow }
+	return e
+}
+
+func TestExporterPushesSettledDeltas(t *testing.T) {
+	sink := newSink(t)
+	settings := memorySettings{domain.SettingKeyStatsExportURL: sink.URL}
+	// Buckets 1 and 2 minutes ago may still be re-aggregated, 90 minutes ago is before the backfill
+	repo := statsAt(90, 10, 5, 3, 2, 1)
+	e := newTestExporter(repo, settings)
+
+	exported, err := e.Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if exported != 3 || len(sink.pushes) != 1 {
+		t.Fatalf("exported %d records in %d pushes, want 3 in 1", exported, len(sink.pushes))
+	}
+	var got []uint64
+	for _, s := range sink.pushes[0] {
+		got = append(got, s.TotalRequests)
+	}
+	if len(got) != 3 || got[0] != 10 || got[1] != 5 || got[2] != 3 {
+		t.Errorf("pushed buckets (minutes ago) = %v, want [10 5 3] oldest first", got)
+	}
+	wantCursor := strconv.FormatInt(ExportCutoff(exportNow).Unix(), 10)
+	if settings[domain.SettingKeyStatsExportCursor] != wantCursor {
+		t.Errorf("cursor = %s, want %s", settings[domain.SettingKeyStatsExportCursor], wantCursor)
+	}
+
+	// Nothing new has settled, nothing is pushed again
+	if exported, _ := e.Run(); exported != 0 || len(sink.pushes) != 1 {
+		t.Errorf("second run exported %d records, want 0", exported)
+	}
+}
+
+func TestExporterResumesFromCursor(t *testing.T) {
+	sink := newSink(t)
+	repo := statsAt(10, 5, 3)
+	settings := memorySettings{
+		domain.SettingKeyStatsExportURL: sink.URL,
+		// A previous process already exported everything up to 5 minutes ago
+		domain.SettingKeyStatsExportCursor: strconv.FormatInt(exportNow.Truncate(time.Minute).Add(-5*time.Minute).Unix(), 10),
+	}
+
+	exported, err := newTestExporter(repo, settings).Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if exported != 1 || sink.pushes[0][0].TotalRequests != 3 {
+		t.Errorf("resumed export = %d records %+v, want only the bucket 3 minutes ago", exported, sink.pushes)
+	}
+}
+
+func TestExporterBacksOffOnFailure(t *testing.T) {
+	sink := newSink(t)
+	sink.status = http.StatusServiceUnavailable
+	settings := memorySettings{domain.SettingKeyStatsExportURL: sink.URL}
+	e := newTestExporter(statsAt(5), settings)
+
+	if _, err := e.Run(); err == nil {
+		t.Fatal("failed push not reported")
+	}
+	if settings[domain.SettingKeyStatsExportCursor] != "" {
+		t.Errorf("cursor advanced past a failed push: %s", settings[domain.SettingKeyStatsExportCursor])
+	}
+
+	// Within the backoff nothing is attempted
+	sink.status = http.StatusNoContent
+	if _, err := e.Run(); err != nil || len(sink.pushes) != 1 {
+		t.Fatalf("run during backoff pushed again (%d pushes, err %v)", len(sink.pushes), err)
+	}
+
+	// After the backoff the same records are pushed again
+	e.now = func() time.Time { return exportNow.Add(exportBackoffBase) }
+	if exported, err := e.Run(); err != nil || exported != 1 {
+		t.Errorf("retry exported %d records (err %v), want 1", exported, err)
+	}
+}
+
+func TestExporterDisabledWithoutURL(t *testing.T) {
+	if exported, err := newTestExporter(statsAt(5), memorySettings{}).Run(); exported != 0 || err != nil {
+		t.Errorf("Run() = %d, %v without a URL", exported, err)
+	}
+}
+
+func TestFormatLineProtocol(t *testing.T) {
+	bucket := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	got := FormatLineProtocol([]*domain.UsageStats{{
+		TimeBucket:         bucket,
+		ProviderID:         2,
+		RouteID:            3,
+		Model:              "my model,v2",
+		TotalRequests:      4,
+		SuccessfulRequests: 3,
+		FailedRequests:     1,
+		InputTokens:        100,
+		Cost:               2500,
+	}})
+	want := `maxx_usage,api_token_id=0,model=my\ model\,v2,project_id=0,provider_id=2,route_id=3 ` +
+		`cache_read=0i,cache_write=0i,cost=2500i,duration_ms=0i,failed=1i,input_tokens=100i,output_tokens=0i,requests=4i,successful=3i ` +
+		strconv.FormatInt(bucket.UnixNano(), 10) + "\n"
+	if got != want {
+		t.Errorf("FormatLineProtocol() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExporterLineProtocolPush(t *testing.T) {
+	var body, contentType, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body, contentType, auth = string(b), r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	settings := memorySettings{
+		domain.SettingKeyStatsExportURL:    server.URL,
+		domain.SettingKeyStatsExportFormat: domain.StatsExportFormatInflux,
+		domain.SettingKeyStatsExportAuth:   "Token secret",
+	}
+	if _, err := newTestExporter(statsAt(5), settings).Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.HasPrefix(body, "maxx_usage,") || !strings.HasPrefix(contentType, "text/plain") || auth != "Token secret" {
+		t.Errorf("push = %q (%s, auth %q)", body, contentType, auth)
+	}
+}
@@ -0,0 +1,168 @@
+package stats
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/event"
+	"github.com/awsl-project/maxx/internal/repository"
+)
+
+const (
+	// LiveDashboardLookback 分钟聚合只会重算最近几分钟的时间桶，每次更新只比较这段时间内的数据
+	LiveDashboardLookback = 10 * time.Minute
+
+	// DashboardDeltaMessage 仪表盘增量的 WebSocket 消息类型
+	DashboardDeltaMessage = "dashboard_delta"
+)
+
+// LiveDashboard 在内存中维护今日每个分钟时间桶的用量，每次分钟聚合后与数据库比较，
+// 把变化的时间桶和今日合计的变化量通过 WebSocket 推送，仪表盘据此实时更新，
+// 无需每个请求完成后都重新执行 QueryDashboardData
+type LiveDashboard struct {
+	usageStats  repository.UsageStatsRepository
+	broadcaster event.Broadcaster
+	now         func() time.Time
+
+	mu      sync.Mutex
+	seq     uint64
+	date    string
+	today   domain.DashboardLiveTotals
+	minutes map[int64]domain.DashboardLiveTotals
+}
+
+// NewLiveDashboard 创建实时仪表盘
+func NewLiveDashboard(usageStats repository.UsageStatsRepository, broadcaster event.Broadcaster) *LiveDashboard {
+	return &LiveDashboard{
+		usageStats:  usageStats,
+		broadcaster: broadcaster,
+		now:         time.Now,
+		minutes:     make(map[int64]domain.DashboardLiveTotals),
+	}
+}
+
+// Snapshot 返回当前的今日合计，客户端订阅时作为累加增量的起点
+func (d *LiveDashboard) Snapshot() domain.DashboardLiveSnapshot {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return domain.DashboardLiveSnapshot{Seq: d.seq, Date: d.date, Today: d.today}
+}
+
+// Update 比较最近的分钟时间桶并广播增量，没有变化时不广播
+// 首次调用或跨天时从今日全部分钟数据重新计算，并广播 Reset 消息
+func (d *LiveDashboard) Update(loc *time.Location) error {
+	delta, err := d.update(loc)
+	if err != nil || delta == nil {
+		return err
+	}
+	if d.broadcaster != nil {
+		d.broadcaster.BroadcastMessage(DashboardDeltaMessage, delta)
+	}
+	return nil
+}
+
+func (d *LiveDashboard) update(loc *time.Location) (*domain.DashboardDelta, error) {
+	now := d.now().In(loc)
+	todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	date := todayStart.Format("2006-01-02")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if date != d.date {
+		minutes, err := d.loadMinutes(todayStart, now)
+		if err != nil {
+			return nil, err
+		}
+		d.seq++
+		d.date = date
+		d.minutes = minutes
+		d.today = domain.DashboardLiveTotals{}
+		for _, totals := range minutes {
+			addTotals(&d.today, totals, 1)
+		}
+		return &domain.DashboardDelta{Seq: d.seq, Date: d.date, Reset: true}, nil
+	}
+
+	since := now.Truncate(time.Minute).Add(-LiveDashboardLookback)
+	if since.Before(todayStart) {
+		since = todayStart
+	}
+	fresh, err := d.loadMinutes(since, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []domain.DashboardMinuteDelta
+	for minute, totals := range fresh {
+		if change := diffTotals(totals, d.minutes[minute]); change != (domain.DashboardLiveTotals{}) {
+			changes = append(changes, domain.DashboardMinuteDelta{Minute: minute, DashboardLiveTotals: change})
+		}
+		d.minutes[minute] = totals
+	}
+	// 回溯范围内消失的时间桶按全部减去处理
+	for minute, totals := range d.minutes {
+		if _, ok := fresh[minute]; ok || minute < since.Unix() {
+			continue
+		}
+		changes = append(changes, domain.DashboardMinuteDelta{Minute: minute, DashboardLiveTotals: diffTotals(domain.DashboardLiveTotals{}, totals)})
+		delete(d.minutes, minute)
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Minute < changes[j].Minute })
+
+	delta := &domain.DashboardDelta{Date: d.date, Minutes: changes}
+	for _, change := range changes {
+		addTotals(&delta.Today, change.DashboardLiveTotals, 1)
+	}
+	addTotals(&d.today, delta.Today, 1)
+	d.seq++
+	delta.Seq = d.seq
+	return delta, nil
+}
+
+// loadMinutes 按时间桶汇总 [start, end] 内的分钟级统计
+func (d *LiveDashboard) loadMinutes(start, end time.Time) (map[int64]domain.DashboardLiveTotals, error) {
+	stats, err := d.usageStats.Query(repository.UsageStatsFilter{
+		Granularity: domain.GranularityMinute,
+		StartTime:   &start,
+		EndTime:     &end,
+	})
+	if err != nil {
+		return nil, err
+	}
+	minutes := make(map[int64]domain.DashboardLiveTotals)
+	for _, s := range stats {
+		minute := s.TimeBucket.Unix()
+		totals := minutes[minute]
+		addTotals(&totals, liveTotals(s), 1)
+		minutes[minute] = totals
+	}
+	return minutes, nil
+}
+
+// liveTotals 取出一条统计中仪表盘关心的字段，Tokens 口径与 QueryDashboardData 一致
+func liveTotals(s *domain.UsageStats) domain.DashboardLiveTotals {
+	return domain.DashboardLiveTotals{
+		Requests:   int64(s.TotalRequests),
+		Successful: int64(s.SuccessfulRequests),
+		Tokens:     int64(s.InputTokens + s.OutputTokens + s.CacheRead + s.CacheWrite),
+		Cost:       int64(s.Cost),
+	}
+}
+
+func addTotals(dst *domain.DashboardLiveTotals, src domain.DashboardLiveTotals, sign int64) {
+	dst.Requests += sign * src.Requests
+	dst.Successful += sign * src.Successful
+	dst.Tokens += sign * src.Tokens
+	dst.Cost += sign * src.Cost
+}
+
+func diffTotals(a, b domain.DashboardLiveTotals) domain.DashboardLiveTotals {
+	addTotals(&a, b, -1)
+	return a
+}
@@ -0,0 +1,154 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/awsl-project/maxx/internal/domain"
+	"github.com/awsl-project/maxx/internal/event"
+)
+
+type recordingBroadcaster struct {
+	event.NopBroadcaster
+	deltas []*domain.DashboardDelta
+}
+
+func (b *recordingBroadcaster) BroadcastMessage(messageType string, data interface{}) {
+	if messageType == DashboardDeltaMessage {
+		b.deltas = append(b.deltas, data.(*domain.DashboardDelta))
+	}
+}
+
+func minuteRow(at time.Time, providerID uint64, requests, successful, tokens, cost uint64) *domain.UsageStats {
+	return &domain.UsageStats{
+		TimeBucket:         at.Truncate(time.Minute),
+		Granularity:        domain.GranularityMinute,
+		ProviderID:         providerID,
+		TotalRequests:      requests,
+		SuccessfulRequests: successful,
+		InputTokens:        tokens,
+		OutputTokens:       tokens,
+		Cost:               cost,
+	}
+}
+
+// recompute is the full query the deltas replace: today's totals over all minute stats
+func recompute(repo *minuteStats, todayStart time.Time) domain.DashboardLiveTotals {
+	var totals domain.DashboardLiveTotals
+	for _, s := range repo.rows {
+		if !s.TimeBucket.Before(todayStart) {
+			addTotals(&totals, liveTotals(s), 1)
+		}
+	}
+	return totals
+}
+
+func TestLiveDashboardDeltasMatchRecompute(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 30, 20, 0, time.UTC)
+	todayStart := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	repo := &minuteStats{rows: []*domain.UsageStats{
+		minuteRow(now.Add(-26*time.Hour), 1, 50, 50, 1000, 900), // yesterday, excluded
+		minuteRow(now.Add(-3*time.Hour), 1, 4, 3, 100, 40),
+		minuteRow(now.Add(-2*time.Minute), 1, 2, 2, 10, 5),
+		minuteRow(now.Add(-2*time.Minute), 2, 1, 0, 7, 3),
+	}}
+	b := &recordingBroadcaster{}
+	live := NewLiveDashboard(repo, b)
+	live.now = func() time.Time { return now }
+
+	if err := live.Update(time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := live.Snapshot()
+	if snapshot.Date != "2026-03-01" || snapshot.Today != recompute(repo, todayStart) {
+		t.Fatalf("snapshot = %+v, want today %+v", snapshot, recompute(repo, todayStart))
+	}
+	if len(b.deltas) != 1 || !b.deltas[0].Reset {
+		t.Fatalf("first update should broadcast a reset, got %+v", b.deltas)
+	}
+
+	// Client side: start from the snapshot and accumulate the following deltas
+	client := snapshot
+	apply := func() {
+		t.Helper()
+		for _, delta := range b.deltas {
+			if delta.Seq <= client.Seq {
+				continue
+			}
+			if delta.Seq != client.Seq+1 || delta.Reset {
+				t.Fatalf("unexpected delta %+v after seq %d", delta, client.Seq)
+			}
+			var sum domain.DashboardLiveTotals
+			for _, m := range delta.Minutes {
+				addTotals(&sum, m.DashboardLiveTotals, 1)
+			}
+			if sum != delta.Today {
+				t.Errorf("delta today %+v != sum of minutes %+v", delta.Today, sum)
+			}
+			addTotals(&client.Today, delta.Today, 1)
+			client.Seq = delta.Seq
+		}
+	}
+
+	steps := []func(){
+		// a new minute bucket
+		func() { repo.rows = append(repo.rows, minuteRow(now.Add(-time.Minute), 1, 3, 3, 20, 8)) },
+		// re-aggregation rewrites a recent bucket
+		func() { repo.rows[2] = minuteRow(now.Add(-2*time.Minute), 1, 5, 4, 30, 12) },
+		// a recent bucket disappears
+		func() { repo.rows = repo.rows[:3] },
+		// nothing changed
+		func() {},
+	}
+	for i, step := range steps {
+		step()
+		before := len(b.deltas)
+		if err := live.Update(time.UTC); err != nil {
+			t.Fatal(err)
+		}
+		if i == len(steps)-1 && len(b.deltas) != before {
+			t.Errorf("unchanged data broadcast a delta: %+v", b.deltas[before:])
+		}
+		apply()
+		if want := recompute(repo, todayStart); client.Today != want {
+			t.Errorf("step %d: accumulated %+v, full recompute %+v", i, client.Today, want)
+		}
+		if got := live.Snapshot().Today; got != client.Today {
+			t.Errorf("step %d: server totals %+v, client %+v", i, got, client.Today)
+		}
+	}
+}
+
+func TestLiveDashboardResetsOnNewDay(t *testing.T) {
+	now := time.Date(2026, 3, 1, 23, 59, 30, 0, time.UTC)
+	repo := &minuteStats{rows: []*domain.UsageStats{minuteRow(now, 1, 7, 7, 10, 1)}}
+	b := &recordingBroadcaster{}
+	live := NewLiveDashboard(repo, b)
+	live.now = func() time.Time { return now }
+	if err := live.Update(time.UTC); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Minute)
+	repo.rows = append(repo.rows, minuteRow(now, 1, 2, 2, 5, 1))
+	if err := live.Update(time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	last := b.deltas[len(b.deltas)-1]
+	if !last.Reset || last.Date != "2026-03-02" {
+		t.Fatalf("expected a reset for the new day, got %+v", last)
+	}
+	if got := live.Snapshot().Today; got.Requests != 2 {
+		t.Errorf("today requests = %d, want only the new day's 2", got.Requests)
+	}
+
+	// The configured timezone decides the day boundary
+	shanghai := NewLiveDashboard(repo, nil)
+	shanghai.now = func() time.Time { return now }
+	if err := shanghai.Update(time.FixedZone("UTC+8", 8*60*60)); err != nil {
+		t.Fatal(err)
+	}
+	if got := shanghai.Snapshot(); got.Date != "2026-03-02" || got.Today.Requests != 9 {
+		t.Errorf("UTC+8 snapshot = %+v, want both buckets on 2026-03-02", got)
+	}
+}
@@ -0,0 +1,157 @@
+package tokenizer
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// gptTokenizer approximates BPE tokenization without shipping a vocabulary.
+// Text is split with the cl100k/o200k pre-tokenization rules (contractions,
+// letter runs with an optional leading space, digit groups of at most three,
+// punctuation runs and whitespace), then each piece is assigned the number of
+// merged tokens BPE typically produces for it. Common words end up as a single
+// token just like in the real encoder, so counts stay close on natural text.
+//
+// scale adjusts the result for families whose vocabulary is less (>1) or more
+// (<1) efficient than cl100k.
+type gptTokenizer struct {
+	scale float64
+}
+
+func (g gptTokenizer) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+	n := 0
+	for i := 0; i < len(text); {
+		size, tokens := nextPiece(text[i:])
+		n += tokens
+		i += size
+	}
+	if g.scale > 0 && g.scale != 1 {
+		n = int(math.Ceil(float64(n) * g.scale))
+	}
+	return n
+}
+
+// nextPiece returns the byte length of the next pre-token piece of s and its token cost
+func nextPiece(s string) (int, int) {
+	r, size := utf8.DecodeRuneInString(s)
+
+	// Contractions: 's 't 're 've 'm 'll 'd
+	if r == '\'' {
+		if l := contractionLen(s[size:]); l > 0 {
+			return size + l, 1
+		}
+	}
+
+	// Optional single leading non-letter, non-digit, non-newline char before a letter run
+	if !isLetter(r) && !unicode.IsNumber(r) && r != '\r' && r != '\n' {
+		if next, _ := utf8.DecodeRuneInString(s[size:]); isLetter(next) {
+			l, tokens := letterRun(s[size:])
+			return size + l, tokens
+		}
+	}
+
+	switch {
+	case isLetter(r):
+		return letterRun(s)
+	case unicode.IsNumber(r):
+		// Digits are grouped by at most three
+		l := size
+		for count := 1; count < 3 && l < len(s); count++ {
+			next, nsize := utf8.DecodeRuneInString(s[l:])
+			if !unicode.IsNumber(next) {
+				break
+			}
+			l += nsize
+		}
+		return l, 1
+	case unicode.IsSpace(r):
+		l := size
+		for l < len(s) {
+			next, nsize := utf8.DecodeRuneInString(s[l:])
+			if !unicode.IsSpace(next) {
+				break
+			}
+			l += nsize
+		}
+		// A trailing space before a word belongs to that word
+		if l < len(s) && l > size && s[l-1] == ' ' {
+			l--
+		}
+		return l, 1
+	default:
+		// Punctuation run, optionally followed by newlines
+		l := size
+		count := 1
+		for l < len(s) {
+			next, nsize := utf8.DecodeRuneInString(s[l:])
+			if isLetter(next) || unicode.IsNumber(next) || unicode.IsSpace(next) {
+				break
+			}
+			l += nsize
+			count++
+		}
+		for l < len(s) && (s[l] == '\r' || s[l] == '\n') {
+			l++
+		}
+		return l, (count + 2) / 3
+	}
+}
+
+// letterRun returns the length and token cost of the letter run at the start of s
+func letterRun(s string) (int, int) {
+	l, ascii, wide, other := 0, 0, 0, 0
+	for l < len(s) {
+		r, size := utf8.DecodeRuneInString(s[l:])
+		if !isLetter(r) {
+			break
+		}
+		switch {
+		case r < utf8.RuneSelf:
+			ascii++
+		case isWide(r):
+			wide++
+		default:
+			other++
+		}
+		l += size
+	}
+
+	tokens := 0
+	// Words up to seven letters are usually a single merged token,
+	// longer ones split into roughly five-letter chunks
+	if ascii > 0 {
+		if ascii <= 7 {
+			tokens++
+		} else {
+			tokens += (ascii + 4) / 5
+		}
+	}
+	// CJK and kana mostly map to one token per character
+	tokens += wide
+	// Other scripts (Cyrillic, Greek, accented Latin...) merge about two characters per token
+	tokens += (other + 1) / 2
+	return l, tokens
+}
+
+func contractionLen(s string) int {
+	for _, c := range []string{"ll", "re", "ve", "LL", "RE", "VE", "s", "t", "m", "d", "S", "T", "M", "D"} {
+		if strings.HasPrefix(s, c) {
+			return len(c)
+		}
+	}
+	return 0
+}
+
+func isLetter(r rune) bool {
+	return unicode.IsLetter(r) || unicode.Is(unicode.Mn, r)
+}
+
+func isWide(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
@@ -0,0 +1,132 @@
+package tokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// Tokenizer counts tokens in plain text
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// Model families with a dedicated tokenizer
+const (
+	FamilyGPT     = "gpt"
+	FamilyClaude  = "claude"
+	FamilyGemini  = "gemini"
+	FamilyUnknown = ""
+)
+
+var (
+	mu         sync.RWMutex
+	tokenizers = map[string]Tokenizer{
+		FamilyGPT:     gptTokenizer{scale: 1},
+		FamilyClaude:  gptTokenizer{scale: 1.1},
+		FamilyGemini:  gptTokenizer{scale: 1},
+		FamilyUnknown: Heuristic{},
+	}
+)
+
+// Register replaces the tokenizer used for a model family,
+// e.g. to plug in an exact BPE encoder when its vocabulary is available
+func Register(family string, t Tokenizer) {
+	mu.Lock()
+	defer mu.Unlock()
+	tokenizers[family] = t
+}
+
+// Family returns the tokenizer family of a model name
+func Family(model string) string {
+	m := strings.ToLower(model)
+	switch {
+	case strings.Contains(m, "claude"):
+		return FamilyClaude
+	case strings.Contains(m, "gemini"):
+		return FamilyGemini
+	case strings.Contains(m, "gpt"), strings.Contains(m, "codex"),
+		strings.HasPrefix(m, "o1"), strings.HasPrefix(m, "o3"), strings.HasPrefix(m, "o4"):
+		return FamilyGPT
+	}
+	return FamilyUnknown
+}
+
+// ForModel returns the tokenizer for a model, falling back to the heuristic for unknown families
+func ForModel(model string) Tokenizer {
+	mu.RLock()
+	defer mu.RUnlock()
+	if t, ok := tokenizers[Family(model)]; ok {
+		return t
+	}
+	return tokenizers[FamilyUnknown]
+}
+
+// Count counts the tokens of text for a model
+func Count(model, text string) int {
+	return ForModel(model).Count(text)
+}
+
+// structuralKeys hold protocol values rather than model-visible text
+var structuralKeys = map[string]bool{
+	"id": true, "model": true, "role": true, "type": true, "object": true,
+	"finish_reason": true, "stop_reason": true, "finishReason": true,
+	"system_fingerprint": true, "tool_use_id": true, "tool_call_id": true,
+	"mimeType": true, "media_type": true, "data": true,
+}
+
+// CountJSON counts the tokens of the text carried in a JSON or SSE body.
+// Only string values are counted; protocol fields such as ids, roles and
+// base64 payloads are skipped.
+func CountJSON(model string, body []byte) int {
+	t := ForModel(model)
+	var sb strings.Builder
+
+	collect := func(doc []byte) bool {
+		var v interface{}
+		if err := json.Unmarshal(doc, &v); err != nil {
+			return false
+		}
+		collectText(v, "", &sb)
+		return true
+	}
+
+	if !collect(body) {
+		// SSE: count the text of every data line
+		for _, line := range bytes.Split(body, []byte("\n")) {
+			line = bytes.TrimSpace(line)
+			if payload, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+				collect(bytes.TrimSpace(payload))
+			}
+		}
+	}
+
+	if sb.Len() == 0 {
+		return 0
+	}
+	return t.Count(sb.String())
+}
+
+func collectText(v interface{}, key string, sb *strings.Builder) {
+	switch val := v.(type) {
+	case string:
+		if !structuralKeys[key] {
+			sb.WriteString(val)
+			sb.WriteByte('\n')
+		}
+	case []interface{}:
+		for _, item := range val {
+			collectText(item, key, sb)
+		}
+	case map[string]interface{}:
+		for k, item := range val {
+			collectText(item, k, sb)
+		}
+	}
+}
+
+// EstimateUsage estimates input and output tokens when the upstream reported no usage
+func EstimateUsage(model string, requestBody, responseBody []byte) (input, output uint64) {
+	return uint64(CountJSON(model, requestBody)), uint64(CountJSON(model, responseBody))
+}
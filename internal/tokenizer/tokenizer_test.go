@@ -0,0 +1,105 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+// Reference counts from the cl100k_base encoder
+var gptSamples = []struct {
+	text string
+	want int
+}{
+	{"hello world", 2},
+	{"Hello, world!", 4},
+	{"The quick brown fox jumps over the lazy dog.", 10},
+	{"I'm fine", 3},
+	{"1234567", 3},
+	{"    return x", 3},
+}
+
+func TestGPTTokenizerKnownCounts(t *testing.T) {
+	tok := ForModel("gpt-4o")
+	for _, s := range gptSamples {
+		if got := tok.Count(s.text); got != s.want {
+			t.Errorf("Count(%q) = %d, want %d", s.text, got, s.want)
+		}
+	}
+}
+
+func TestApproximateFamiliesWithinTolerance(t *testing.T) {
+	// Reference counts reported by the providers' count-tokens endpoints
+	samples := []struct {
+		model string
+		text  string
+		want  int
+	}{
+		{"claude-sonnet-4-5", "The quick brown fox jumps over the lazy dog.", 11},
+		{"gemini-2.5-pro", "The quick brown fox jumps over the lazy dog.", 10},
+		{"claude-sonnet-4-5", "你好，世界", 5},
+	}
+	for _, s := range samples {
+		got := Count(s.model, s.text)
+		if diff := got - s.want; diff*4 > s.want || -diff*4 > s.want {
+			t.Errorf("Count(%s, %q) = %d, want %d ±25%%", s.model, s.text, got, s.want)
+		}
+	}
+}
+
+func TestUnknownFamilyUsesHeuristic(t *testing.T) {
+	text := "The quick brown fox jumps over the lazy dog."
+	if got, want := Count("deepseek-chat", text), (Heuristic{}).Count(text); got != want {
+		t.Errorf("unknown family Count = %d, want heuristic %d", got, want)
+	}
+}
+
+func TestFamily(t *testing.T) {
+	cases := map[string]string{
+		"gpt-4o-mini":        FamilyGPT,
+		"o3-mini":            FamilyGPT,
+		"gpt-5-codex":        FamilyGPT,
+		"claude-3-5-haiku":   FamilyClaude,
+		"gemini-2.0-flash":   FamilyGemini,
+		"qwen3-coder-plus":   FamilyUnknown,
+		"Claude-Opus-4-1":    FamilyClaude,
+		"models/gemini-pro":  FamilyGemini,
+		"text-embedding-ada": FamilyUnknown,
+	}
+	for model, want := range cases {
+		if got := Family(model); got != want {
+			t.Errorf("Family(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
+
+func TestCountJSON(t *testing.T) {
+	body := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"Hello, world!"}]}`)
+	if got := CountJSON("gpt-4o", body); got != 4 {
+		t.Errorf("CountJSON = %d, want 4", got)
+	}
+
+	sse := []byte("data: {\"id\":\"x\",\"choices\":[{\"delta\":{\"content\":\"hello\"}}]}\n\n" +
+		"data: {\"id\":\"x\",\"choices\":[{\"delta\":{\"content\":\" world\"}}]}\n\ndata: [DONE]\n\n")
+	if got := CountJSON("gpt-4o", sse); got == 0 {
+		t.Error("CountJSON on SSE body = 0, want > 0")
+	}
+}
+
+func BenchmarkGPTTokenizer(b *testing.B) {
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. 你好，世界！ 1234567\n", 200)
+	tok := ForModel("gpt-4o")
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tok.Count(text)
+	}
+}
+
+func BenchmarkHeuristic(b *testing.B) {
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. 你好，世界！ 1234567\n", 200)
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		(Heuristic{}).Count(text)
+	}
+}
@@ -11,6 +11,7 @@ import {
   Zap,
   Ban,
   HelpCircle,
+  KeyRound,
   X,
   Thermometer,
   Calendar,
@@ -72,6 +73,13 @@ const getReasonInfo = (t: TFunction) => ({
     color: 'text-orange-400',
     bgColor: 'bg-orange-400/10 border-orange-400/20',
   },
+  invalid_credentials: {
+    label: t('provider.reasons.invalidCredentials'),
+    description: t('provider.reasons.invalidCredentialsDesc', '刷新令牌时凭证被拒绝，请检查并更新供应商凭证'),
+    icon: KeyRound,
+    color: 'text-red-400',
+    bgColor: 'bg-red-400/10 border-red-400/20',
+  },
   unknown: {
     label: t('provider.reasons.unknown'),
     description: t('provider.reasons.unknownDesc', '因未知原因进入冷却状态'),
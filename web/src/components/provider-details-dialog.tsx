@@ -10,6 +10,7 @@ import {
   Zap,
   Ban,
   HelpCircle,
+  KeyRound,
   X,
   Thermometer,
   Activity,
@@ -89,6 +90,13 @@ const getReasonInfo = (t: TFunction) => ({
     bgColor:
       'bg-orange-500/10 dark:bg-orange-500/15 border-orange-500/30 dark:border-orange-500/25',
   },
+  invalid_credentials: {
+    label: t('provider.reasons.invalidCredentials'),
+    description: t('provider.reasons.invalidCredentialsDesc', '刷新令牌时凭证被拒绝，请检查并更新供应商凭证'),
+    icon: KeyRound,
+    color: 'text-red-600 dark:text-red-400',
+    bgColor: 'bg-red-500/10 dark:bg-red-500/15 border-red-500/30 dark:border-red-500/25',
+  },
   unknown: {
     label: t('provider.reasons.unknown'),
     description: t('provider.reasons.unknownDesc', '因未知原因进入冷却状态'),
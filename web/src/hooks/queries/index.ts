@@ -138,6 +138,7 @@ export {
   use24HourTrend,
   useFirstUseDate,
   useDashboardProviderStats,
+  useLiveDashboard,
   type DashboardSummary,
   type HeatmapDataPoint,
   type ModelRanking,
@@ -4,10 +4,10 @@
  * 使用单个 API 请求获取所有数据，减少网络开销
  */
 
-import { useMemo } from 'react';
-import { useQuery } from '@tanstack/react-query';
+import { useEffect, useMemo } from 'react';
+import { useQuery, useQueryClient } from '@tanstack/react-query';
 import { getTransport } from '@/lib/transport';
-import type { DashboardData, DashboardHeatmapPoint as APIDashboardHeatmapPoint, DashboardModelStats, DashboardTrendPoint, DashboardProviderStats as APIDashboardProviderStats } from '@/lib/transport';
+import type { DashboardData, DashboardHeatmapPoint as APIDashboardHeatmapPoint, DashboardModelStats, DashboardTrendPoint, DashboardProviderStats as APIDashboardProviderStats, DashboardDelta, DashboardLiveSnapshot, DashboardLiveTotals } from '@/lib/transport';
 
 // ===== 类型定义 =====
 
@@ -51,6 +51,38 @@ function calculateChange(current: number, previous: number): number {
   return ((current - previous) / previous) * 100;
 }
 
+/**
+ * 用实时累加的今日合计覆盖 Dashboard 的今日统计和今日热力图
+ * RPM/TPM 依赖请求耗时，仍使用完整查询的结果
+ */
+function withLiveToday(data: DashboardData, live: DashboardLiveSnapshot): DashboardData {
+  const { today } = live;
+  const heatmap = data.heatmap.some((p) => p.date === live.date)
+    ? data.heatmap.map((p) => (p.date === live.date ? { ...p, count: today.requests } : p))
+    : [...data.heatmap, { date: live.date, count: today.requests }];
+  return {
+    ...data,
+    today: {
+      ...data.today,
+      requests: today.requests,
+      tokens: today.tokens,
+      cost: today.cost,
+      successRate: today.requests > 0 ? (today.successful / today.requests) * 100 : 0,
+    },
+    heatmap: today.requests > 0 ? heatmap : data.heatmap,
+  };
+}
+
+// 当前生效的实时状态（快照 + 已累加的增量），null 表示未订阅或等待快照
+let liveDashboard: DashboardLiveSnapshot | null = null;
+
+/**
+ * 实时增量是否生效，生效时请求完成后无需重新获取整个 Dashboard
+ */
+export function isLiveDashboardActive(): boolean {
+  return liveDashboard !== null;
+}
+
 // ===== 核心 Hook =====
 
 /**
@@ -60,13 +92,80 @@ function calculateChange(current: number, previous: number): number {
 export function useDashboardData() {
   return useQuery<DashboardData>({
     queryKey: ['dashboard'],
-    queryFn: () => getTransport().getDashboardData(),
+    queryFn: async () => {
+      const data = await getTransport().getDashboardData();
+      return liveDashboard ? withLiveToday(data, liveDashboard) : data;
+    },
     staleTime: 5 * 1000, // 5 seconds
-    // 不再轮询，改为通过 WebSocket 事件触发刷新 (useProxyRequestUpdates)
+    // 今日统计由 useLiveDashboard 实时更新，其余部分低频刷新；
+    // 未订阅实时增量时由 useProxyRequestUpdates 在请求完成后触发刷新
+    refetchInterval: () => (liveDashboard ? 5 * 60 * 1000 : false),
     refetchOnWindowFocus: false,
   });
 }
 
+/**
+ * 订阅实时 Dashboard 增量
+ * 订阅后服务端下发快照，之后每次分钟聚合推送增量，按 seq 依次累加到今日统计；
+ * seq 不连续、跨天或重连时重新订阅获取快照
+ */
+export function useLiveDashboard() {
+  const queryClient = useQueryClient();
+
+  useEffect(() => {
+    const transport = getTransport();
+
+    const apply = () => {
+      const live = liveDashboard;
+      if (!live) return;
+      queryClient.setQueryData<DashboardData>(['dashboard'], (old) => (old ? withLiveToday(old, live) : old));
+    };
+
+    const resubscribe = () => {
+      liveDashboard = null;
+      transport.send('subscribe', 'dashboard');
+    };
+
+    const unsubscribeSnapshot = transport.subscribe<DashboardLiveSnapshot>('dashboard_snapshot', (snapshot) => {
+      liveDashboard = { ...snapshot, today: { ...snapshot.today } };
+      apply();
+    });
+
+    const unsubscribeDelta = transport.subscribe<DashboardDelta>('dashboard_delta', (delta) => {
+      const live = liveDashboard;
+      if (!live) return;
+      if (delta.reset || delta.date !== live.date) {
+        resubscribe();
+        queryClient.invalidateQueries({ queryKey: ['dashboard'] });
+        return;
+      }
+      if (delta.seq <= live.seq) return; // 已包含在快照中
+      if (delta.seq !== live.seq + 1) {
+        resubscribe(); // 漏收了增量
+        return;
+      }
+      const today: DashboardLiveTotals = live.today;
+      today.requests += delta.today.requests;
+      today.successful += delta.today.successful;
+      today.tokens += delta.today.tokens;
+      today.cost += delta.today.cost;
+      live.seq = delta.seq;
+      apply();
+    });
+
+    const unsubscribeReconnect = transport.subscribe('_ws_reconnected', resubscribe);
+
+    resubscribe();
+
+    return () => {
+      unsubscribeSnapshot();
+      unsubscribeDelta();
+      unsubscribeReconnect();
+      liveDashboard = null;
+    };
+  }, [queryClient]);
+}
+
 // ===== 派生 Hooks（保持向后兼容） =====
 
 /**
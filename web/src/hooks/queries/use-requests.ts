@@ -11,6 +11,7 @@ import {
   type CursorPaginationParams,
   type CursorPaginationResult,
 } from '@/lib/transport';
+import { isLiveDashboardActive } from './use-dashboard-stats';
 
 // Query Keys
 export const requestKeys = {
@@ -101,8 +102,10 @@ export function useProxyRequestUpdates() {
 
         // 请求完成或失败时刷新相关数据
         if (updatedRequest.status === 'COMPLETED' || updatedRequest.status === 'FAILED') {
-          // 刷新 dashboard 数据
-          queryClient.invalidateQueries({ queryKey: ['dashboard'] });
+          // 刷新 dashboard 数据（订阅了实时增量时今日统计已实时更新）
+          if (!isLiveDashboardActive()) {
+            queryClient.invalidateQueries({ queryKey: ['dashboard'] });
+          }
           // 刷新 provider stats（因为统计数据变化了）
           queryClient.invalidateQueries({ queryKey: ['providers', 'stats'] });
           // 刷新 cooldowns（请求可能触发了冷却，即使最终成功也可能有 provider 进入冷却）
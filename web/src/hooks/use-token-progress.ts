@@ -0,0 +1,41 @@
+/**
+ * Token Progress Hook
+ * 追踪流式请求的实时输出 token 数
+ */
+
+import { useState, useEffect } from 'react';
+import { getTransport } from '@/lib/transport';
+import type { TokenProgressEvent } from '@/lib/transport/types';
+
+/**
+ * 订阅 token_progress 事件，返回 proxyRequestID → 当前输出 token 数
+ * 尝试结束（final）后移除，此时请求记录中已有最终用量
+ */
+export function useTokenProgress(): Map<number, number> {
+  const [progress, setProgress] = useState<Map<number, number>>(new Map());
+
+  useEffect(() => {
+    const transport = getTransport();
+    const unsubscribe = transport.subscribe<TokenProgressEvent>('token_progress', (event) => {
+      setProgress((prev) => {
+        const next = new Map(prev);
+        if (event.final) {
+          next.delete(event.proxyRequestID);
+        } else {
+          next.set(event.proxyRequestID, event.outputTokens);
+        }
+        return next;
+      });
+    });
+    const unsubscribeReconnect = transport.subscribe('_ws_reconnected', () => {
+      setProgress(new Map());
+    });
+
+    return () => {
+      unsubscribe();
+      unsubscribeReconnect();
+    };
+  }, []);
+
+  return progress;
+}
@@ -34,6 +34,8 @@ import type {
   ModelMappingInput,
   ImportResult,
   Cooldown,
+  ProviderEvent,
+  ProviderEventQuery,
   KiroTokenValidationResult,
   KiroQuotaData,
   AuthStatus,
@@ -43,6 +45,7 @@ import type {
   CreateAPITokenData,
   RoutePositionUpdate,
   UsageStats,
+  UsageStatsChanges,
   UsageStatsFilter,
   DashboardData,
   BackupFile,
@@ -463,6 +466,13 @@ export class HttpTransport implements Transport {
     await this.client.delete(`/cooldowns/${providerId}`);
   }
 
+  async getProviderEvents(providerId: number, query?: ProviderEventQuery): Promise<ProviderEvent[]> {
+    const { data } = await this.client.get<ProviderEvent[]>(`/providers/${providerId}/events`, {
+      params: query,
+    });
+    return data ?? [];
+  }
+
   // ===== Auth API =====
 
   async getAuthStatus(): Promise<AuthStatus> {
@@ -511,7 +521,7 @@ export class HttpTransport implements Transport {
 
   // ===== Usage Stats API =====
 
-  async getUsageStats(filter?: UsageStatsFilter): Promise<UsageStats[]> {
+  private usageStatsParams(filter?: UsageStatsFilter): URLSearchParams {
     const params = new URLSearchParams();
     if (filter?.granularity) params.set('granularity', filter.granularity);
     if (filter?.start) params.set('start', filter.start);
@@ -521,21 +531,33 @@ export class HttpTransport implements Transport {
     if (filter?.projectId) params.set('projectId', String(filter.projectId));
     if (filter?.clientType) params.set('clientType', filter.clientType);
     if (filter?.apiTokenId) params.set('apiTokenId', String(filter.apiTokenId));
+    return params;
+  }
 
-    const query = params.toString();
+  async getUsageStats(filter?: UsageStatsFilter): Promise<UsageStats[]> {
+    const query = this.usageStatsParams(filter).toString();
     const url = query ? `/usage-stats?${query}` : '/usage-stats';
     const { data } = await this.client.get<UsageStats[]>(url);
     return data ?? [];
   }
 
+  async getUsageStatsChanges(since: number, filter?: UsageStatsFilter): Promise<UsageStatsChanges> {
+    const params = this.usageStatsParams(filter);
+    params.set('since', String(since));
+    const { data } = await this.client.get<UsageStatsChanges>(`/usage-stats/changes?${params}`);
+    return data;
+  }
+
   async recalculateUsageStats(): Promise<void> {
     await this.client.post('/usage-stats/recalculate');
   }
 
   // ===== Dashboard API =====
 
-  async getDashboardData(): Promise<DashboardData> {
-    const { data } = await this.client.get<DashboardData>('/dashboard');
+  async getDashboardData(projectID?: number): Promise<DashboardData> {
+    const { data } = await this.client.get<DashboardData>('/dashboard', {
+      params: projectID !== undefined ? { projectID } : undefined,
+    });
     return data;
   }
 
@@ -556,6 +578,9 @@ export class HttpTransport implements Transport {
   async importBackup(backup: BackupFile, options?: BackupImportOptions): Promise<BackupImportResult> {
     const params = new URLSearchParams();
     if (options?.conflictStrategy) params.set('conflictStrategy', options.conflictStrategy);
+    if (options?.mappingConflictStrategy) {
+      params.set('mappingConflictStrategy', options.mappingConflictStrategy);
+    }
     if (options?.dryRun) params.set('dryRun', 'true');
 
     const query = params.toString();
@@ -577,6 +602,17 @@ export class HttpTransport implements Transport {
     };
   }
 
+  send(type: string, data?: unknown): void {
+    const message = JSON.stringify({ type, data });
+    if (this.ws?.readyState === WebSocket.OPEN) {
+      this.ws.send(message);
+      return;
+    }
+    this.connect()
+      .then(() => this.ws?.send(message))
+      .catch(console.error);
+  }
+
   // ===== 生命周期 =====
 
   async connect(): Promise<void> {
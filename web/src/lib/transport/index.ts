@@ -58,12 +58,16 @@ export type {
   ImportResult,
   // Cooldown
   Cooldown,
+  ProviderEvent,
+  ProviderEventQuery,
+  ProviderEventType,
   // API Token
   APIToken,
   APITokenCreateResult,
   CreateAPITokenData,
   // Usage Stats
   UsageStats,
+  UsageStatsChanges,
   UsageStatsFilter,
   StatsGranularity,
   // Dashboard
@@ -74,6 +78,10 @@ export type {
   DashboardModelStats,
   DashboardTrendPoint,
   DashboardProviderStats,
+  DashboardLiveTotals,
+  DashboardMinuteDelta,
+  DashboardLiveSnapshot,
+  DashboardDelta,
 } from './types';
 
 export type { Transport, TransportType, TransportConfig } from './interface';
@@ -31,6 +31,8 @@ import type {
   ModelMappingInput,
   ImportResult,
   Cooldown,
+  ProviderEvent,
+  ProviderEventQuery,
   KiroTokenValidationResult,
   KiroQuotaData,
   AuthStatus,
@@ -40,6 +42,7 @@ import type {
   CreateAPITokenData,
   RoutePositionUpdate,
   UsageStats,
+  UsageStatsChanges,
   UsageStatsFilter,
   DashboardData,
   BackupFile,
@@ -147,6 +150,7 @@ export interface Transport {
   // ===== Cooldown API =====
   getCooldowns(): Promise<Cooldown[]>;
   clearCooldown(providerId: number): Promise<void>;
+  getProviderEvents(providerId: number, query?: ProviderEventQuery): Promise<ProviderEvent[]>;
 
   // ===== Auth API =====
   getAuthStatus(): Promise<AuthStatus>;
@@ -163,10 +167,11 @@ export interface Transport {
 
   // ===== Usage Stats API =====
   getUsageStats(filter?: UsageStatsFilter): Promise<UsageStats[]>;
+  getUsageStatsChanges(since: number, filter?: UsageStatsFilter): Promise<UsageStatsChanges>;
   recalculateUsageStats(): Promise<void>;
 
   // ===== Dashboard API =====
-  getDashboardData(): Promise<DashboardData>;
+  getDashboardData(projectID?: number): Promise<DashboardData>;
 
   // ===== Response Model API =====
   getResponseModels(): Promise<string[]>;
@@ -177,6 +182,8 @@ export interface Transport {
 
   // ===== 实时订阅 =====
   subscribe<T = unknown>(eventType: WSMessageType, callback: EventCallback<T>): UnsubscribeFn;
+  /** 向服务端发送消息（如 { type: 'subscribe', data: 'dashboard' } 请求初始快照） */
+  send(type: string, data?: unknown): void;
 
   // ===== 生命周期 =====
   connect(): Promise<void>;
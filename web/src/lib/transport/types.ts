@@ -14,6 +14,17 @@ export interface ProviderConfigCustom {
   apiKey: string;
   clientBaseURL?: Partial<Record<ClientType, string>>;
   modelMapping?: Record<string, string>;
+  signing?: ProviderConfigCustomSigning;
+  eventStream?: boolean; // 上游以 AWS EventStream 格式返回响应，转换为 SSE/JSON
+}
+
+export interface ProviderConfigCustomSigning {
+  mode: 'sigv4';
+  region: string;
+  service: string;
+  accessKeyID: string;
+  secretAccessKey: string;
+  sessionToken?: string;
 }
 
 export interface ProviderConfigAntigravity {
@@ -38,6 +49,48 @@ export interface ProviderConfig {
   custom?: ProviderConfigCustom;
   antigravity?: ProviderConfigAntigravity;
   kiro?: ProviderConfigKiro;
+  quota?: ProviderQuotaConfig;
+  minIntervalMs?: number; // 相邻两次上游调用的最小间隔，0 表示不限制
+  unsupportedParams?: string[]; // 上游不接受的请求参数，转发前移除
+  retryRules?: RetryRule[]; // 按上游错误内容判定可重试的规则
+  forceHTTP1?: boolean; // 强制使用 HTTP/1.1 请求上游，绕过 HTTP/2 有问题的上游或代理
+  statusMapping?: Record<string, number>; // 客户端响应状态码映射（如 {"529": 503}），优先于全局映射
+  discovery?: ProviderDiscovery; // 通过凭证目录自动发现创建的供应商的来源信息
+  costTier?: CostTier; // 成本档位，为空时按价格表推断
+  transformPlugin?: TransformPluginConfig; // WASM 转换插件，改写请求体和非流式响应体
+  maxCooldownSecs?: number; // 上游要求冷却的上限（秒），0 使用默认 30 分钟，-1 不设上限
+}
+
+export type CostTier = 'economy' | 'standard' | 'premium';
+
+// 供应商的 WASM 转换插件，调用失败、超时或超出内存上限时原样透传
+export interface TransformPluginConfig {
+  path: string; // .wasm 文件路径，文件变化后自动重新加载
+  timeoutMs?: number; // 单次调用的超时，默认 100
+  memoryLimitMB?: number; // 内存上限，默认 16
+}
+
+// 凭证自动发现写入的来源信息
+export interface ProviderDiscovery {
+  fingerprint: string;
+  source: string;
+  checksum: string;
+}
+
+// 上游错误重试规则，认证失败、请求过大等客户端错误不受影响
+export interface RetryRule {
+  type?: 'contains' | 'regex'; // 默认 contains（不区分大小写的子串匹配）
+  pattern: string;
+  statusCodes?: number[]; // 限定生效的状态码，空表示任意
+}
+
+export interface ProviderQuotaConfig {
+  period: 'day' | 'week' | 'month';
+  maxTokens?: number; // 0 表示不限制
+  maxRequests?: number; // 0 表示不限制
+  softPercent?: number; // 默认 80
+  resetHour?: number; // 0-23
+  timezone?: string; // 为空时使用系统时区
 }
 
 export interface Provider {
@@ -70,6 +123,33 @@ export interface Project {
   name: string;
   slug: string;
   enabledCustomRoutes: ClientType[];
+  guardrail?: GuardrailConfig;
+  defaultClientType?: ClientType; // 路径和请求头无法确定客户端类型时使用
+  costPreference?: CostPreference; // 按供应商成本档位排序候选路由
+  fallbackResponse?: FallbackResponseConfig; // 所有路由均失败时的兜底回复，路由上的配置优先
+  defaultModel?: string; // 请求缺少 model 时使用的模型，优先于路由的配置
+}
+
+// 所有路由均失败时代替错误返回的兜底回复（客户端格式的正常回复，请求仍记为失败）
+export interface FallbackResponseConfig {
+  enabled: boolean;
+  message?: string; // 支持 {model}、{error}、{requestID}、{time} 占位符，为空使用默认文本
+}
+
+export type CostPreference = 'cheapest_first' | 'best_first';
+
+// 项目级内容审核钩子
+export interface GuardrailConfig {
+  enabled: boolean;
+  blockedKeywords?: string[];
+  // OpenAI Chat Completions 兼容的审核模型接口，回复以 BLOCK 开头视为违规
+  moderationURL?: string;
+  moderationModel?: string;
+  moderationAPIKey?: string;
+  timeoutMs?: number;
+  action?: 'block' | 'annotate';
+  failClosed?: boolean;
+  checkResponse?: boolean;
 }
 
 export type CreateProjectData = Omit<Project, 'id' | 'createdAt' | 'updatedAt' | 'slug'> & {
@@ -101,7 +181,29 @@ export interface Route {
   position: number;
   retryConfigID: number;
   modelMapping?: Record<string, string>;
-}
+  failFast?: boolean; // 快速失败：不重试，失败后供应商立即短暂冷却
+  ensembleSize?: number; // 集成路由（实验性）：非流式请求并行发送到该路由及其后的路由，共 N 条
+  ensembleStrategy?: EnsembleStrategy;
+  apiTokenIDs?: number[]; // 仅允许这些 API Token 使用，被列出的 Token 只能使用列出它的路由
+  conditions?: RouteConditions | null; // 按时间段和负载动态启用或降级，null 表示始终生效
+  fallbackResponse?: FallbackResponseConfig | null; // 所有路由均失败时的兜底回复，优先于项目的配置
+  defaultModel?: string; // 请求缺少 model 且项目未配置默认模型时使用，为空时跳过该路由
+  adaptivePosition?: number; // 自适应排序后的实际顺序（从 1 开始，只读），未启用自适应排序时不返回
+}
+
+// 路由的动态生效条件，各项同时满足时生效，未配置的条件视为满足
+export interface RouteConditions {
+  timeWindows?: string[]; // "HH:MM-HH:MM"（系统时区），结束早于开始表示跨越午夜
+  weekdays?: number[]; // 0 为周日
+  minInFlight?: number; // 处理中的请求数不低于该值时生效
+  maxInFlight?: number; // 处理中的请求数低于该值时生效
+  minRPM?: number; // 最近一分钟请求数不低于该值时生效
+  maxRPM?: number; // 最近一分钟请求数低于该值时生效
+  demoteWhenUnmet?: boolean; // 条件不满足时排到生效路由之后，而不是跳过
+}
+
+// 集成路由选择响应的方式：最先完成、文本最长、工具调用投票
+export type EnsembleStrategy = 'first' | 'longest' | 'vote';
 
 export type CreateRouteData = Omit<Route, 'id' | 'createdAt' | 'updatedAt'>;
 
@@ -132,6 +234,7 @@ export type RoutingStrategyType = 'priority' | 'weighted_random';
 
 export interface RoutingStrategyConfig {
   // 扩展字段
+  adaptiveOrder?: boolean; // 自适应排序（仅 priority 策略）：按供应商近期成功率和延迟调整路由顺序
 }
 
 export interface RoutingStrategy {
@@ -202,6 +305,40 @@ export interface ProxyRequest {
   cost: number;
   // API Token ID
   apiTokenID: number;
+  // 从 User-Agent / X-Stainless-* 识别的客户端应用，如 claude-cli/2.0
+  clientApp?: string;
+  // 内容审核命中但未拦截时的原因
+  guardrailFlag?: string;
+  // 通过标签请求头（默认 X-Maxx-Tag）指定的成本分摊标签
+  tags?: string[];
+  // 全部路由失败时每个已尝试路由的失败摘要
+  routeFailures?: RouteFailure[];
+  // 成功响应中没有任何内容
+  emptyResponse?: boolean;
+  // 所有路由均失败后返回了兜底回复（请求仍为 FAILED）
+  fallbackResponse?: boolean;
+  // 请求未指定 model，使用了项目或路由的默认模型
+  modelDefaulted?: boolean;
+  // 管理员通过 X-Maxx-Provider-ID 请求头强制使用的供应商（绕过路由和冷却）
+  forcedProviderID?: number;
+  // 管理员从 curl / HAR 导入并重放的请求
+  replay?: boolean;
+  // 请求被取消的原因，仅 CANCELLED 状态
+  cancelReason?: CancelReason;
+}
+
+// 请求被取消的原因
+export type CancelReason = 'client_disconnect' | 'resume_abandoned' | 'admin' | 'shutdown';
+
+export interface RouteFailure {
+  position: number; // 尝试顺序，从 1 开始
+  routeID?: number;
+  providerID?: number;
+  providerName?: string;
+  attempts: number;
+  statusCode?: number;
+  error: string; // 错误类别
+  detail?: string; // 完整错误信息
 }
 
 // ===== ProxyUpstreamAttempt =====
@@ -238,6 +375,27 @@ export interface ProxyUpstreamAttempt {
   cache5mWriteCount: number;
   cache1hWriteCount: number;
   cost: number;
+  // 上游返回成功但响应中没有任何内容
+  emptyResponse?: boolean;
+  // 因上游不支持而在转发前移除的请求参数，逗号分隔
+  droppedParams?: string;
+  // 上游要求的冷却时长（秒），超出供应商冷却上限被截断时记录
+  cooldownCappedFromSecs?: number;
+  // 集成路由中首个尝试的 ID，首个尝试自身为 0
+  ensembleOfAttemptID?: number;
+  // 集成路由中被选为返回给客户端的响应
+  ensembleChosen?: boolean;
+  // 上游请求各阶段耗时，开启 upstream_timing_capture 后记录
+  timing?: UpstreamTiming;
+}
+
+/** 上游请求各阶段耗时（纳秒），复用连接时 dns/connect/tls 为 0 */
+export interface UpstreamTiming {
+  dns: number;
+  connect: number;
+  tls: number;
+  firstByte: number;
+  reused: boolean;
 }
 
 // ===== 分页 =====
@@ -277,6 +435,11 @@ export type WSMessageType =
   | 'new_session_pending'
   | 'session_pending_cancelled'
   | 'cooldown_update'
+  | 'provider_spend_alert'
+  | 'dashboard_snapshot'
+  | 'dashboard_delta'
+  | 'token_progress'
+  | 'provider_event'
   | '_ws_reconnected'; // 内部事件：WebSocket 重连成功
 
 export interface WSMessage<T = unknown> {
@@ -296,6 +459,14 @@ export interface SessionPendingCancelledEvent {
   sessionID: string;
 }
 
+// 流式请求的实时输出 token 数，生成中为估算值，final 时为最终用量
+export interface TokenProgressEvent {
+  proxyRequestID: number;
+  attemptID: number;
+  outputTokens: number;
+  final: boolean;
+}
+
 // ===== Proxy Status =====
 
 export interface ProxyStatus {
@@ -304,6 +475,123 @@ export interface ProxyStatus {
   port: number;
   version: string;
   commit: string;
+  concurrency?: ConcurrencyStats;
+  quotas?: ProviderQuotaInfo[];
+  logSink?: LogSinkStats;
+  capture?: CaptureStats;
+  retryBudget?: RetryBudgetStats;
+  cooldownQueue?: CooldownQueueStats;
+}
+
+export interface CooldownQueueStats {
+  maxWaitSeconds: number; // 0 表示未开启排队
+  queued: number; // 正在等待供应商冷却结束的请求数
+}
+
+export interface LogSinkStats {
+  enabled: boolean;
+  buffered: number; // 等待投递的记录数
+  delivered: number;
+  dropped: number; // 缓冲区满时丢弃的记录数
+  failed: number; // 重试后仍投递失败的记录数
+}
+
+export interface CaptureStats {
+  enabled: boolean;
+  buffered: number; // 等待写入的记录数
+  written: number;
+  dropped: number; // 缓冲区满时丢弃的记录数
+  failed: number; // 写入失败（如磁盘已满）的记录数
+}
+
+export interface RetryBudgetStats {
+  enabled: boolean;
+  scope: 'provider' | 'global';
+  maxTokens: number;
+  tokenRatio: number;
+  buckets: RetryBudgetBucket[];
+}
+
+export interface RetryBudgetBucket {
+  providerID: number; // global 范围时为 0
+  tokens: number;
+  throttled: boolean; // 令牌不超过一半，暂停重试和切换路由
+  suppressed: number; // 被拒绝的重试和切换次数
+}
+
+export interface ProviderQuotaInfo {
+  providerID: number;
+  providerName: string;
+  period: string;
+  periodStart: string;
+  periodEnd: string;
+  usedTokens: number;
+  maxTokens: number;
+  usedRequests: number;
+  maxRequests: number;
+  remainingTokens: number; // -1 表示不限制
+  remainingRequests: number; // -1 表示不限制
+  softLimitReached: boolean;
+  exhausted: boolean;
+}
+
+/** 供应商在统计窗口内的成本与请求占比 */
+export interface ProviderShare {
+  providerID: number;
+  providerName: string;
+  requests: number;
+  cost: number; // 微美元
+  requestShare: number; // 0-100
+  costShare: number; // 0-100
+}
+
+/** 单个供应商成本或请求占比超过阈值的告警（provider_spend_alert 消息） */
+export interface ProviderSpendAlert extends ProviderShare {
+  threshold: number; // 0-100
+  windowMinutes: number;
+  shares: ProviderShare[]; // 按成本占比降序
+  triggeredAt: string;
+}
+
+export interface ProviderPacingInfo {
+  providerID: number;
+  providerName: string;
+  minIntervalMs: number;
+  delayMs: number; // 新请求当前需要等待的时间
+  waiting: number; // 正在排队的请求数
+}
+
+export interface ProviderConnectionInfo {
+  providerID: number;
+  providerName: string;
+  requests: number;
+  reused: number; // 复用空闲连接的请求数
+  dialed: number; // 新建连接的请求数
+  reuseRate: number; // 连接复用率（百分比）
+}
+
+export interface ModelInfo {
+  id: string;
+  contextWindow?: number; // 上游未声明时省略
+  maxOutputTokens?: number;
+}
+
+export interface ProviderModelsInfo {
+  providerID: number;
+  providerName: string;
+  models: ModelInfo[];
+  fetchedAt?: string; // 最近一次成功获取的时间
+  expiresAt?: string;
+  stale: boolean; // 已过期或从未获取，读取时在后台刷新
+  refreshing: boolean;
+  error?: string; // 最近一次获取失败的原因
+}
+
+export interface ConcurrencyStats {
+  maxConcurrent: number; // 0 表示不限制
+  queueSize: number;
+  active: number;
+  queued: number;
 }
 
 // ===== Provider Stats =====
@@ -391,11 +679,19 @@ export interface ModelMapping {
   apiTokenID: number; // Token ID，0 表示所有
   pattern: string; // 源模式，支持 * 通配符
   target: string; // 目标模型名
+  targets?: ModelMappingTarget[]; // 加权目标，按权重随机选择
+  stickySession?: boolean; // 同一会话始终选择同一目标
   priority: number; // 优先级，数字越小优先级越高
   isEnabled: boolean; // 是否启用
   isBuiltin: boolean; // 是否为内置规则
 }
 
+// 模型映射的加权目标
+export interface ModelMappingTarget {
+  target: string;
+  weight: number;
+}
+
 // 创建/更新模型映射的请求
 export interface ModelMappingInput {
   scope?: ModelMappingScope;
@@ -407,6 +703,8 @@ export interface ModelMappingInput {
   apiTokenID?: number;
   pattern: string;
   target: string;
+  targets?: ModelMappingTarget[];
+  stickySession?: boolean;
   priority?: number;
   isEnabled?: boolean;
 }
@@ -463,6 +761,7 @@ export type CooldownReason =
   | 'quota_exhausted'
   | 'rate_limit_exceeded'
   | 'concurrent_limit'
+  | 'invalid_credentials'
   | 'unknown';
 
 /**
@@ -479,6 +778,33 @@ export interface Cooldown {
   reason: CooldownReason;
 }
 
+export type ProviderEventType =
+  | 'cooldown_entered' // 进入冷却
+  | 'recovered' // 冷却到期或请求成功后恢复
+  | 'cooldown_cleared' // 手动清除冷却
+  | 'credential_failed'; // 凭证刷新被上游拒绝
+
+/**
+ * ProviderEvent 类型 - 与 Go domain.ProviderEvent 同步
+ * 供应商生命周期事件，通过 provider_event WebSocket 消息实时推送
+ */
+export interface ProviderEvent {
+  id: number;
+  createdAt: string; // 事件发生时间
+  providerID: number;
+  clientType: string; // 为空表示作用于整个供应商
+  type: ProviderEventType;
+  reason?: CooldownReason;
+  untilTime?: string; // 进入冷却时的冷却结束时间
+  detail?: string;
+}
+
+export interface ProviderEventQuery {
+  start?: string; // RFC3339
+  end?: string; // RFC3339
+  limit?: number;
+}
+
 // ===== Auth 相关 =====
 
 export interface AuthStatus {
@@ -503,6 +829,7 @@ export interface APIToken {
   description: string;
   projectID: number;
   isEnabled: boolean;
+  defaultClientType?: ClientType; // 路径和请求头无法确定客户端类型时使用，优先于项目默认值
   expiresAt?: string;
   lastUsedAt?: string;
   useCount: number;
@@ -518,6 +845,7 @@ export interface CreateAPITokenData {
   description?: string;
   projectID?: number;
   expiresAt?: string;
+  defaultClientType?: ClientType;
 }
 
 // ===== Usage Stats =====
@@ -528,6 +856,7 @@ export type StatsGranularity = 'minute' | 'hour' | 'day' | 'week' | 'month';
 export interface UsageStats {
   id: number;
   createdAt: string;
+  updatedAt: string; // 统计值最后一次变化的时间
   timeBucket: string; // 时间桶（根据粒度截断）
   granularity: StatsGranularity; // 时间粒度
   routeID: number;
@@ -547,6 +876,12 @@ export interface UsageStats {
   cost: number;
 }
 
+/** 增量统计查询结果（用于轮询） */
+export interface UsageStatsChanges {
+  buckets: UsageStats[]; // since 之后有变化的时间桶
+  cursor: number; // 下次轮询使用的 since（毫秒时间戳）
+}
+
 /** 统计数据汇总 */
 export interface UsageStatsSummary {
   totalRequests: number;
@@ -560,6 +895,27 @@ export interface UsageStatsSummary {
   totalCost: number; // 微美元
 }
 
+/** 成功但没有内容的响应统计（按供应商和客户端类型） */
+export interface EmptyResponseStats {
+  providerID: number;
+  clientType: string;
+  totalAttempts: number;
+  emptyResponses: number;
+  emptyRate: number; // 0-100
+}
+
+/** 供应商上游请求各阶段的平均耗时（DNS/TCP/TLS 仅统计新建连接） */
+export interface UpstreamTimingStats {
+  providerID: number;
+  attempts: number;
+  newConns: number;
+  reusedConns: number;
+  avgDnsMs: number;
+  avgConnectMs: number;
+  avgTlsMs: number;
+  avgFirstByteMs: number;
+}
+
 export interface UsageStatsFilter {
   granularity?: StatsGranularity; // 时间粒度（必填）
   start?: string; // 开始时间 ISO8601
@@ -670,6 +1026,8 @@ export interface BackupModelMapping {
 /** 导入选项 */
 export interface BackupImportOptions {
   conflictStrategy?: 'skip' | 'overwrite' | 'error';
+  /** 与现有模型映射冲突时的处理方式，默认跟随 conflictStrategy */
+  mappingConflictStrategy?: 'incoming' | 'existing' | 'error';
   dryRun?: boolean;
 }
 
@@ -747,3 +1105,31 @@ export interface DashboardData {
   providerStats: Record<number, DashboardProviderStats>;
   timezone: string; // 配置的时区，如 "Asia/Shanghai"
 }
+
+/** 实时推送的今日用量合计，在增量消息中为变化量（可能为负） */
+export interface DashboardLiveTotals {
+  requests: number;
+  successful: number;
+  tokens: number;
+  cost: number;
+}
+
+export interface DashboardMinuteDelta extends DashboardLiveTotals {
+  t: number; // 分钟时间桶（Unix 秒）
+}
+
+/** 订阅 dashboard 时下发的初始快照 */
+export interface DashboardLiveSnapshot {
+  seq: number;
+  date: string; // 今日日期（配置的时区）
+  today: DashboardLiveTotals;
+}
+
+/** 分钟聚合后推送的增量，seq 不连续或 reset 时需重新获取快照 */
+export interface DashboardDelta {
+  seq: number;
+  date: string;
+  reset?: boolean;
+  today: DashboardLiveTotals;
+  minutes?: DashboardMinuteDelta[];
+}
@@ -35,6 +35,7 @@ import {
   use24HourTrend,
   useFirstUseDate,
   useDashboardProviderStats,
+  useLiveDashboard,
   useProviders,
   useProxyRequests,
   useProxyRequestUpdates,
@@ -207,6 +208,8 @@ export function OverviewPage() {
 
   // 启用请求实时更新
   useProxyRequestUpdates();
+  // 今日统计通过 WebSocket 增量实时更新
+  useLiveDashboard();
 
   const recentRequests = useMemo(() => requestsData?.items ?? [], [requestsData?.items]);
 
@@ -10,6 +10,7 @@ import {
   useAPITokens,
   useSettings,
 } from '@/hooks/queries';
+import { useTokenProgress } from '@/hooks/use-token-progress';
 import {
   Activity,
   RefreshCw,
@@ -74,6 +75,7 @@ export function RequestsPage() {
 
   // Subscribe to real-time updates
   useProxyRequestUpdates();
+  const tokenProgress = useTokenProgress();
 
   const requests = data?.items ?? [];
   const hasMore = data?.hasMore ?? false;
@@ -215,6 +217,7 @@ export function RequestsPage() {
                     providerName={providerMap.get(req.providerID)}
                     projectName={projectMap.get(req.projectID)}
                     tokenName={tokenMap.get(req.apiTokenID)}
+                    liveOutputTokens={tokenProgress.get(req.id)}
                     showProjectColumn={hasProjects}
                     showTokenColumn={apiTokenAuthEnabled}
                     onClick={() => navigate(`/requests/${req.id}`)}
@@ -385,6 +388,7 @@ function LogRow({
   providerName,
   projectName,
   tokenName,
+  liveOutputTokens,
   showProjectColumn,
   showTokenColumn,
   onClick,
@@ -393,6 +397,7 @@ function LogRow({
   providerName?: string;
   projectName?: string;
   tokenName?: string;
+  liveOutputTokens?: number;
   showProjectColumn?: boolean;
   showTokenColumn?: boolean;
   onClick: () => void;
@@ -604,7 +609,10 @@ function LogRow({
 
       {/* Output Tokens - emerald green */}
       <TableCell className="py-1 text-center">
-        <TokenCell count={request.outputTokenCount} color="text-emerald-400" />
+        <TokenCell
+          count={isPending ? (liveOutputTokens ?? request.outputTokenCount) : request.outputTokenCount}
+          color="text-emerald-400"
+        />
       </TableCell>
 
       {/* Cache Read - violet */}
@@ -36,16 +36,19 @@ export function RoutingStrategiesPage() {
 
   const [projectID, setProjectID] = useState('0');
   const [type, setType] = useState<RoutingStrategyType>('priority');
+  const [adaptiveOrder, setAdaptiveOrder] = useState(false);
 
   const resetForm = () => {
     setProjectID('0');
     setType('priority');
+    setAdaptiveOrder(false);
   };
 
   const handleEdit = (strategy: RoutingStrategy) => {
     setEditingStrategy(strategy);
     setProjectID(String(strategy.projectID));
     setType(strategy.type);
+    setAdaptiveOrder(strategy.config?.adaptiveOrder ?? false);
     setShowForm(true);
   };
 
@@ -60,7 +63,7 @@ export function RoutingStrategiesPage() {
     const data = {
       projectID: Number(projectID),
       type,
-      config: null,
+      config: type === 'priority' && adaptiveOrder ? { adaptiveOrder } : null,
     };
 
     if (editingStrategy) {
@@ -130,6 +133,17 @@ export function RoutingStrategiesPage() {
                   </select>
                 </div>
               </div>
+              {type === 'priority' && (
+                <label className="flex items-center gap-2 text-sm">
+                  <input
+                    type="checkbox"
+                    checked={adaptiveOrder}
+                    onChange={(e) => setAdaptiveOrder(e.target.checked)}
+                  />
+                  Adaptive order (move healthy, fast providers up by recent success rate and
+                  latency)
+                </label>
+              )}
               <div className="flex justify-end gap-2">
                 <Button type="button" variant="outline" onClick={handleCloseForm}>
                   {t('common.cancel')}